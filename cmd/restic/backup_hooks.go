@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/restic/restic/internal/archiver"
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/data"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+)
+
+// Status of the backup reported to the post-command.
+const (
+	backupStatusSuccess    = "success"
+	backupStatusIncomplete = "incomplete"
+	backupStatusFailed     = "failed"
+)
+
+// runBackupHook runs the command line of a --pre-command or --post-command
+// with env appended to the environment of restic. The output of the command
+// is passed through, but written to stderr when stdout contains JSON messages.
+func runBackupHook(ctx context.Context, commandLine string, env []string, json bool) error {
+	args, err := backend.SplitShellStrings(commandLine)
+	if err != nil {
+		return err
+	}
+
+	debug.Log("running backup hook %v", args)
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout = os.Stdout
+	if json {
+		cmd.Stdout = os.Stderr
+	}
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// postCommandEnv returns the environment variables describing the result of
+// the backup for the post-command. summary may be nil if the backup failed.
+func postCommandEnv(status string, snapshotID restic.ID, summary *archiver.Summary) []string {
+	id := ""
+	if !snapshotID.IsNull() {
+		id = snapshotID.String()
+	}
+	env := []string{
+		"RESTIC_BACKUP_STATUS=" + status,
+		"RESTIC_SNAPSHOT_ID=" + id,
+	}
+	if summary == nil {
+		return env
+	}
+
+	vars := []struct {
+		name  string
+		value interface{}
+	}{
+		{"RESTIC_FILES_NEW", summary.Files.New},
+		{"RESTIC_FILES_CHANGED", summary.Files.Changed},
+		{"RESTIC_FILES_UNMODIFIED", summary.Files.Unchanged},
+		{"RESTIC_DIRS_NEW", summary.Dirs.New},
+		{"RESTIC_DIRS_CHANGED", summary.Dirs.Changed},
+		{"RESTIC_DIRS_UNMODIFIED", summary.Dirs.Unchanged},
+		{"RESTIC_DATA_ADDED", summary.ItemStats.DataSize + summary.ItemStats.TreeSize},
+		{"RESTIC_DATA_ADDED_PACKED", summary.ItemStats.DataSizeInRepo + summary.ItemStats.TreeSizeInRepo},
+		{"RESTIC_TOTAL_FILES_PROCESSED", summary.Files.New + summary.Files.Changed + summary.Files.Unchanged},
+		{"RESTIC_TOTAL_BYTES_PROCESSED", summary.ProcessedBytes},
+		{"RESTIC_BACKUP_START", summary.BackupStart.Format(time.RFC3339)},
+		{"RESTIC_BACKUP_END", summary.BackupEnd.Format(time.RFC3339)},
+	}
+	for _, v := range vars {
+		env = append(env, fmt.Sprintf("%s=%v", v.name, v.value))
+	}
+	return env
+}
+
+// runPostCommand runs the post-command of the backup. If it fails, the tag
+// --post-command-failure-tag is added to the snapshot, if one was created.
+func runPostCommand(ctx context.Context, opts BackupOptions, repo *repository.Repository, json bool, status string, snapshotID restic.ID, summary *archiver.Summary, printer restic.Printer) error {
+	err := runBackupHook(ctx, opts.PostCommand, postCommandEnv(status, snapshotID, summary), json)
+	if err == nil {
+		return nil
+	}
+	err = errors.Fatalf("post-command failed: %v", err)
+
+	if snapshotID.IsNull() || opts.PostCommandFailureTag == "" {
+		return err
+	}
+
+	sn, lerr := data.LoadSnapshot(ctx, repo, snapshotID)
+	if lerr != nil {
+		printer.E("unable to tag snapshot %v: %v", snapshotID.Str(), lerr)
+		return err
+	}
+	_, terr := changeTags(ctx, repo, sn, nil, []string{opts.PostCommandFailureTag}, nil, func(c changedSnapshot) {
+		printer.E("snapshot %v tagged with %q, saved as new snapshot %v", c.OldSnapshotID.Str(), opts.PostCommandFailureTag, c.NewSnapshotID.Str())
+	})
+	if terr != nil {
+		printer.E("unable to tag snapshot %v: %v", snapshotID.Str(), terr)
+	}
+	return err
+}
+
+// freezeCommand is a --freeze-command, which keeps an application, for
+// example a database, in a consistent state while the backup reads its files.
+//
+// The command prints "ready" on a line of its own once the files can be read.
+// restic then reads the files and closes the stdin of the command afterwards,
+// which lets the command resume normal operation and exit. Lines of the form
+// "label key=value" are stored as labels in the snapshot, for example the log
+// position of a database. All other lines are passed through.
+type freezeCommand struct {
+	cmd      *exec.Cmd
+	stdin    io.WriteCloser
+	stdout   *bufio.Scanner
+	output   io.Writer
+	labels   data.Labels
+	finished bool
+}
+
+// startFreezeCommand runs commandLine and waits until it is ready.
+func startFreezeCommand(ctx context.Context, commandLine string, json bool) (*freezeCommand, error) {
+	args, err := backend.SplitShellStrings(commandLine)
+	if err != nil {
+		return nil, err
+	}
+
+	debug.Log("running freeze command %v", args)
+	f := &freezeCommand{
+		cmd:    exec.CommandContext(ctx, args[0], args[1:]...),
+		output: os.Stdout,
+		labels: data.Labels{},
+	}
+	if json {
+		f.output = os.Stderr
+	}
+	f.cmd.Stderr = os.Stderr
+	f.stdin, err = f.cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := f.cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	f.stdout = bufio.NewScanner(stdout)
+
+	if err := f.cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	ready, err := f.readOutput()
+	if err != nil || !ready {
+		_ = f.stdin.Close()
+		werr := f.cmd.Wait()
+		if err == nil && werr != nil {
+			err = werr
+		}
+		if err == nil {
+			err = errors.New("command exited without printing \"ready\"")
+		}
+		return nil, err
+	}
+	return f, nil
+}
+
+// readOutput processes the output of the command until it prints "ready" or
+// closes its stdout.
+func (f *freezeCommand) readOutput() (ready bool, err error) {
+	for f.stdout.Scan() {
+		line := strings.TrimSpace(f.stdout.Text())
+		switch {
+		case line == "ready":
+			return true, nil
+		case strings.HasPrefix(line, "label "):
+			if err := f.labels.Set(strings.TrimPrefix(line, "label ")); err != nil {
+				return false, err
+			}
+		default:
+			_, _ = fmt.Fprintln(f.output, f.stdout.Text())
+		}
+	}
+	return false, f.stdout.Err()
+}
+
+// Finish notifies the command that all files were read and waits until it
+// exits. It returns the labels printed by the command.
+func (f *freezeCommand) Finish() (data.Labels, error) {
+	if f.finished {
+		return f.labels, nil
+	}
+	f.finished = true
+
+	err := f.stdin.Close()
+	for ready := true; err == nil && ready; {
+		ready, err = f.readOutput()
+	}
+	if werr := f.cmd.Wait(); err == nil {
+		err = werr
+	}
+	return f.labels, err
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/restic/restic/internal/data"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/global"
+	"github.com/restic/restic/internal/repository"
+	"github.com/spf13/cobra"
+)
+
+func newAuditLogCommand(globalOptions *global.Options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "audit-log",
+		Short: "Manage the audit log of operations",
+		Long: `
+The "audit-log" command manages the audit log of the repository. Once enabled
+using "audit-log init", the commands backup, forget, prune and key record each
+operation which modifies the repository in the audit log.
+
+The entries of the audit log are encrypted and authenticated using the
+repository key and are chained to each other. Removing or modifying an entry is
+detected by "audit-log verify". The entries are not signed, thus anyone with
+access to the repository key can create valid entries.
+
+Each host stores the newest entry it has seen in its cache directory. Removing
+the newest entries is detected by the next operation which appends to the
+audit log or by "audit-log verify" on a host that has seen these entries.
+	`,
+		DisableAutoGenTag: true,
+		GroupID:           cmdGroupDefault,
+	}
+
+	cmd.AddCommand(
+		newAuditLogInitCommand(globalOptions),
+		newAuditLogShowCommand(globalOptions),
+		newAuditLogVerifyCommand(globalOptions),
+	)
+	return cmd
+}
+
+// recordAuditLog appends the entry to the audit log if it is enabled for the
+// repository.
+func recordAuditLog(ctx context.Context, repo *repository.Repository, e *data.AuditLogEntry) error {
+	headFile := auditLogHeadFile(repo)
+	var head *data.AuditLogHead
+	if headFile != "" {
+		var err error
+		head, err = data.LoadAuditLogHead(headFile)
+		if err != nil {
+			return errors.Fatalf("unable to load the newest audit log entry: %v", err)
+		}
+	}
+
+	id, err := data.AppendAuditLog(ctx, repo, e, head)
+	if err != nil {
+		return errors.Fatalf("unable to append to the audit log: %v", err)
+	}
+	if id.IsNull() || headFile == "" {
+		return nil
+	}
+	if err := data.SaveAuditLogHead(headFile, data.AuditLogHead{ID: id, Sequence: e.Sequence}); err != nil {
+		return errors.Fatalf("unable to save the newest audit log entry: %v", err)
+	}
+	return nil
+}
+
+// auditLogHeadFile returns the path of the file which stores the newest entry
+// of the audit log seen by this host. It is empty if no cache is used.
+func auditLogHeadFile(repo *repository.Repository) string {
+	if repo.Cache() == nil {
+		return ""
+	}
+	return filepath.Join(repo.Cache().Dir(), "auditlog-head.json")
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/restic/restic/internal/data"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/global"
+	"github.com/restic/restic/internal/ui"
+	"github.com/restic/restic/internal/ui/progress"
+	"github.com/spf13/cobra"
+)
+
+func newAuditLogInitCommand(globalOptions *global.Options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "init",
+		Short: "Enable the audit log",
+		Long: `
+The "audit-log init" command enables the audit log for the repository by
+saving its initial entry and marking it as enabled in the repository config.
+Afterwards, operations which modify the repository are recorded in the audit
+log. Once enabled, the audit log cannot be disabled.
+
+Older restic versions do not record any operations in the audit log.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful.
+Exit status is 1 if there was any error.
+Exit status is 10 if the repository does not exist.
+Exit status is 11 if the repository is already locked.
+Exit status is 12 if the password is incorrect.
+	`,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runAuditLogInit(cmd.Context(), *globalOptions, args, globalOptions.Term)
+		},
+	}
+	return cmd
+}
+
+func runAuditLogInit(ctx context.Context, gopts global.Options, args []string, term ui.Terminal) error {
+	if len(args) > 0 {
+		return fmt.Errorf("the audit-log init command expects no arguments, only options - please see `restic help audit-log init` for usage and flags")
+	}
+
+	printer := progress.NewTerminalPrinter(false, gopts.Verbosity, term)
+	ctx, repo, unlock, err := openWithExclusiveLock(ctx, gopts, false, printer)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	id, err := data.InitAuditLog(ctx, repo, data.NewAuditLogEntry(data.AuditLogInit))
+	if err != nil {
+		return err
+	}
+	if err := repo.EnableAuditLog(ctx); err != nil {
+		return errors.Fatalf("unable to change the repository config: %v", err)
+	}
+	if headFile := auditLogHeadFile(repo); headFile != "" {
+		if err := data.SaveAuditLogHead(headFile, data.AuditLogHead{ID: id}); err != nil {
+			return errors.Fatalf("unable to save the newest audit log entry: %v", err)
+		}
+	}
+
+	printer.P("enabled audit log, initial entry %v", id.Str())
+	return nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/restic/restic/internal/global"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func testRunAuditLogShow(t testing.TB, gopts global.Options) []auditLogEntryJSON {
+	buf, err := withCaptureStdout(t, gopts, func(ctx context.Context, gopts global.Options) error {
+		gopts.JSON = true
+		return runAuditLogShow(ctx, gopts, nil, gopts.Term)
+	})
+	rtest.OK(t, err)
+
+	var entries []auditLogEntryJSON
+	rtest.OK(t, json.Unmarshal(buf.Bytes(), &entries))
+	return entries
+}
+
+func testRunAuditLogVerify(t testing.TB, gopts global.Options, opts AuditLogVerifyOptions) error {
+	return withTermStatus(t, gopts, func(ctx context.Context, gopts global.Options) error {
+		return runAuditLogVerify(ctx, opts, gopts, nil, gopts.Term)
+	})
+}
+
+func TestAuditLog(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	opts := BackupOptions{}
+
+	// operations are not recorded until the audit log is enabled
+	testRunBackup(t, "", []string{env.testdata}, opts, env.gopts)
+	rtest.Equals(t, 0, len(testRunAuditLogShow(t, env.gopts)))
+
+	rtest.OK(t, withTermStatus(t, env.gopts, func(ctx context.Context, gopts global.Options) error {
+		return runAuditLogInit(ctx, gopts, nil, gopts.Term)
+	}))
+	testRunBackup(t, "", []string{env.testdata}, opts, env.gopts)
+	snapshotIDs := testListSnapshots(t, env.gopts, 2)
+	testRunForget(t, env.gopts, ForgetOptions{}, snapshotIDs[0].String())
+
+	entries := testRunAuditLogShow(t, env.gopts)
+	rtest.Equals(t, 3, len(entries))
+	rtest.Equals(t, "init", entries[0].Operation)
+	rtest.Equals(t, "backup", entries[1].Operation)
+	rtest.Equals(t, "forget", entries[2].Operation)
+	rtest.Equals(t, restic.IDs{snapshotIDs[0]}, entries[2].Snapshots)
+
+	head := entries[2].ID.String()
+	rtest.OK(t, testRunAuditLogVerify(t, env.gopts, AuditLogVerifyOptions{Heads: []string{head}}))
+
+	// removing an entry within the chain is detected
+	rtest.OK(t, os.Remove(filepath.Join(env.repo, "auditlog", entries[1].ID.String())))
+	rtest.Assert(t, testRunAuditLogVerify(t, env.gopts, AuditLogVerifyOptions{}) != nil,
+		"expected verify to fail after removing an entry")
+
+	// removing the newest entry is detected using the head stored in the cache
+	// or the previously printed head
+	env2, cleanup2 := withTestEnvironment(t)
+	defer cleanup2()
+	testSetupBackupData(t, env2)
+	rtest.OK(t, withTermStatus(t, env2.gopts, func(ctx context.Context, gopts global.Options) error {
+		return runAuditLogInit(ctx, gopts, nil, gopts.Term)
+	}))
+	testRunBackup(t, "", []string{env2.testdata}, opts, env2.gopts)
+	entries = testRunAuditLogShow(t, env2.gopts)
+	rtest.Equals(t, 2, len(entries))
+	rtest.OK(t, os.Remove(filepath.Join(env2.repo, "auditlog", entries[1].ID.String())))
+	rtest.Assert(t, testRunAuditLogVerify(t, env2.gopts, AuditLogVerifyOptions{}) != nil,
+		"expected verify to fail for removed head")
+	rtest.Assert(t, testRunBackupAssumeFailure(t, "", []string{env2.testdata}, opts, env2.gopts) != nil,
+		"expected backup to fail for removed head")
+
+	noCache := env2.gopts
+	noCache.NoCache = true
+	rtest.OK(t, testRunAuditLogVerify(t, noCache, AuditLogVerifyOptions{}))
+	rtest.Assert(t, testRunAuditLogVerify(t, noCache, AuditLogVerifyOptions{Heads: []string{entries[1].ID.String()}}) != nil,
+		"expected verify to fail for removed head")
+
+	// removing all entries is detected even without a cache
+	rtest.OK(t, os.RemoveAll(filepath.Join(env2.repo, "auditlog")))
+	rtest.OK(t, os.Mkdir(filepath.Join(env2.repo, "auditlog"), 0700))
+	rtest.Assert(t, testRunAuditLogVerify(t, noCache, AuditLogVerifyOptions{}) != nil,
+		"expected verify to fail for removed audit log")
+	rtest.Assert(t, testRunBackupAssumeFailure(t, "", []string{env2.testdata}, opts, noCache) != nil,
+		"expected backup to fail for removed audit log")
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/restic/restic/internal/data"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/global"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui"
+	"github.com/restic/restic/internal/ui/progress"
+	"github.com/restic/restic/internal/ui/table"
+	"github.com/spf13/cobra"
+)
+
+func newAuditLogShowCommand(globalOptions *global.Options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "show",
+		Short: "Show the entries of the audit log",
+		Long: `
+The "audit-log show" command lists all entries of the audit log, sorted by time.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful.
+Exit status is 1 if there was any error.
+Exit status is 10 if the repository does not exist.
+Exit status is 11 if the repository is already locked.
+Exit status is 12 if the password is incorrect.
+	`,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runAuditLogShow(cmd.Context(), *globalOptions, args, globalOptions.Term)
+		},
+	}
+	return cmd
+}
+
+// auditLogEntryJSON is the JSON representation of an audit log entry.
+type auditLogEntryJSON struct {
+	*data.AuditLogEntry
+	ID restic.ID `json:"id"`
+}
+
+func runAuditLogShow(ctx context.Context, gopts global.Options, args []string, term ui.Terminal) error {
+	if len(args) > 0 {
+		return fmt.Errorf("the audit-log show command expects no arguments, only options - please see `restic help audit-log show` for usage and flags")
+	}
+
+	printer := progress.NewTerminalPrinter(gopts.JSON, gopts.Verbosity, term)
+	ctx, repo, unlock, err := openWithReadLock(ctx, gopts, gopts.NoLock, printer)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	entries, err := data.LoadAuditLog(ctx, repo)
+	if err != nil {
+		return err
+	}
+
+	if gopts.JSON {
+		list := make([]auditLogEntryJSON, 0, len(entries))
+		for _, e := range entries {
+			list = append(list, auditLogEntryJSON{AuditLogEntry: e, ID: *e.ID()})
+		}
+		return json.NewEncoder(gopts.Term.OutputWriter()).Encode(list)
+	}
+
+	if !repo.Config().AuditLog {
+		printer.P("the audit log is not enabled")
+		return nil
+	}
+	if err := data.CheckAuditLogHead(entries, nil); err != nil {
+		return errors.Fatalf("%v", err)
+	}
+
+	type row struct {
+		ID        string
+		Time      string
+		Host      string
+		User      string
+		Operation string
+		Details   string
+	}
+
+	tab := table.New()
+	tab.AddColumn("ID", "{{ .ID }}")
+	tab.AddColumn("Time", "{{ .Time }}")
+	tab.AddColumn("Host", "{{ .Host }}")
+	tab.AddColumn("User", "{{ .User }}")
+	tab.AddColumn("Operation", "{{ .Operation }}")
+	tab.AddColumn("Details", "{{ .Details }}")
+
+	for _, e := range entries {
+		tab.AddRow(row{
+			ID:        e.ID().Str(),
+			Time:      e.Time.Local().Format(global.TimeFormat),
+			Host:      e.Hostname,
+			User:      e.Username,
+			Operation: e.Operation,
+			Details:   auditLogDetails(e),
+		})
+	}
+
+	return tab.Write(gopts.Term.OutputWriter())
+}
+
+// auditLogDetails returns a short description of the objects affected by the
+// operation of the entry.
+func auditLogDetails(e *data.AuditLogEntry) string {
+	shortIDs := func(ids restic.IDs) string {
+		strs := make([]string, 0, len(ids))
+		for _, id := range ids {
+			strs = append(strs, id.Str())
+		}
+		return strings.Join(strs, ", ")
+	}
+
+	var details []string
+	if len(e.Snapshots) > 0 {
+		details = append(details, "snapshots "+shortIDs(e.Snapshots))
+	}
+	if len(e.Keys) > 0 {
+		details = append(details, "keys "+shortIDs(e.Keys))
+	}
+	if e.Message != "" {
+		details = append(details, e.Message)
+	}
+	return strings.Join(details, "; ")
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/restic/restic/internal/data"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/global"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui"
+	"github.com/restic/restic/internal/ui/progress"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func newAuditLogVerifyCommand(globalOptions *global.Options) *cobra.Command {
+	var opts AuditLogVerifyOptions
+
+	cmd := &cobra.Command{
+		Use:   "verify [flags]",
+		Short: "Verify the integrity of the audit log",
+		Long: `
+The "audit-log verify" command checks that the audit log is intact. It reports
+entries which reference removed entries and additional initial entries. The
+IDs of the newest entries are printed at the end.
+
+The removal of the newest entries cannot be detected using the audit log alone.
+It is detected using the newest entry seen by this host, which is stored in the
+cache directory. Alternatively, store the printed IDs outside of the
+repository and pass them to "--head" during later runs. This checks that the
+given entries still exist.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful.
+Exit status is 1 if there was any error.
+Exit status is 10 if the repository does not exist.
+Exit status is 11 if the repository is already locked.
+Exit status is 12 if the password is incorrect.
+	`,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runAuditLogVerify(cmd.Context(), opts, *globalOptions, args, globalOptions.Term)
+		},
+	}
+
+	opts.AddFlags(cmd.Flags())
+	return cmd
+}
+
+// AuditLogVerifyOptions bundles all options for the audit-log verify command.
+type AuditLogVerifyOptions struct {
+	Heads []string
+}
+
+func (opts *AuditLogVerifyOptions) AddFlags(f *pflag.FlagSet) {
+	f.StringArrayVar(&opts.Heads, "head", nil, "check that the audit log still contains the entry with the given `id` (can be specified multiple times)")
+}
+
+func runAuditLogVerify(ctx context.Context, opts AuditLogVerifyOptions, gopts global.Options, args []string, term ui.Terminal) error {
+	if len(args) > 0 {
+		return fmt.Errorf("the audit-log verify command expects no arguments, only options - please see `restic help audit-log verify` for usage and flags")
+	}
+
+	printer := progress.NewTerminalPrinter(false, gopts.Verbosity, term)
+	ctx, repo, unlock, err := openWithReadLock(ctx, gopts, gopts.NoLock, printer)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	entries, err := data.LoadAuditLog(ctx, repo)
+	if err != nil {
+		return err
+	}
+	if !repo.Config().AuditLog {
+		if len(entries) != 0 {
+			return errors.Fatal("the repository contains audit log entries, but the audit log is not enabled in the config")
+		}
+		return errors.Fatal("the audit log is not enabled")
+	}
+
+	headFile := auditLogHeadFile(repo)
+	var head *data.AuditLogHead
+	if headFile != "" {
+		head, err = data.LoadAuditLogHead(headFile)
+		if err != nil {
+			return errors.Fatalf("unable to load the newest audit log entry: %v", err)
+		}
+	}
+
+	errs := data.VerifyAuditLog(entries)
+	if err := data.CheckAuditLogHead(entries, head); err != nil {
+		errs = append(errs, err)
+	}
+	for _, head := range opts.Heads {
+		if _, err := restic.Find(ctx, repo, restic.AuditLogFile, head); err != nil {
+			errs = append(errs, errors.Errorf("entry %v was removed: %v", head, err))
+		}
+	}
+
+	for _, err := range errs {
+		printer.E("error: %v", err)
+	}
+
+	printer.P("checked %d entries", len(entries))
+	for _, id := range data.AuditLogHeads(entries) {
+		printer.P("newest entry: %v", id)
+	}
+
+	if len(errs) > 0 {
+		return errors.Fatal("the audit log is damaged, entries were removed or modified")
+	}
+	if headFile != "" {
+		newest := data.NewestAuditLogEntry(entries)
+		if err := data.SaveAuditLogHead(headFile, data.AuditLogHead{ID: *newest.ID(), Sequence: newest.Sequence}); err != nil {
+			return errors.Fatalf("unable to save the newest audit log entry: %v", err)
+		}
+	}
+	printer.P("no errors were found")
+	return nil
+}
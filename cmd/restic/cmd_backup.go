@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -42,12 +43,34 @@ func newBackupCommand(globalOptions *global.Options) *cobra.Command {
 The "backup" command creates a new snapshot and saves the files and directories
 given as the arguments.
 
+With "--dry-run --predict", no data is uploaded, but all new data is chunked,
+deduplicated against the parent snapshot and the repository index, compressed
+and packed as for a real backup. Afterwards, the number and size of the files
+which would be uploaded are printed, including the pack file overhead, the
+index and the snapshot. This allows checking the upload size in advance, for
+example on metered connections.
+
+The "--resource-profile" option limits the resources used by the backup, for
+example on laptops or small NAS devices. The profile "low" uses a single CPU
+core, reads one file at a time, sets a soft memory limit of 512 MiB for the Go
+runtime, stores the index as with "--low-memory-index" where supported, uses
+the fastest compression level and lowers the CPU and I/O priority to idle. The
+profile "medium" uses half of the CPU cores, reads two files concurrently and
+sets a soft memory limit of 2 GiB. The profile "high" does not limit the
+resource usage. Settings which are specified explicitly, for example using
+"--read-concurrency", "--compression", $GOMAXPROCS or $GOMEMLIMIT, take
+precedence over the profile.
+
 EXIT STATUS
 ===========
 
 Exit status is 0 if the command was successful.
-Exit status is 1 if there was a fatal error (no snapshot created).
+Exit status is 1 if there was a fatal error (no snapshot created) or the
+--post-command failed.
 Exit status is 3 if some source data could not be read (incomplete snapshot created).
+With --on-error fail, such errors abort the backup with exit status 1 instead.
+Exit status is also 1 if the repository exceeds its max-repo-size setting,
+unless --ignore-max-repo-size is given.
 Exit status is 10 if the repository does not exist.
 Exit status is 11 if the repository is already locked.
 Exit status is 12 if the password is incorrect.
@@ -78,23 +101,45 @@ type BackupOptions struct {
 	ExcludeCaches     bool
 	ExcludeLargerThan string
 	ExcludeCloudFiles bool
+	DirExcludeFile    string
+	UseIgnoreFiles    bool
 	Stdin             bool
 	StdinFilename     string
 	StdinCommand      bool
+	StdinCommands     string
 	Tags              data.TagLists
+	Labels            data.Labels
 	Host              string
 	FilesFrom         []string
 	FilesFromVerbatim []string
 	FilesFromRaw      []string
 	TimeStamp         string
 	WithAtime         bool
+	FollowSymlinks    archiver.FollowSymlinksMode
 	IgnoreInode       bool
 	IgnoreCtime       bool
 	UseFsSnapshot     bool
 	DryRun            bool
+	Predict           bool
 	ReadConcurrency   uint
+	ScanConcurrency   uint
 	NoScan            bool
 	SkipIfUnchanged   bool
+	UseChangeJournal  bool
+	ReadSpecial       bool
+	SkipZeroBlocks    bool
+	CompressionPolicy string
+	ChangeDetection   string
+	OnError           string
+	OnNetworkFS       string
+	IgnoreMaxRepoSize bool
+	DropPageCache     bool
+	ResourceProfile   string
+
+	PreCommand            string
+	PostCommand           string
+	FreezeCommand         string
+	PostCommandFailureTag string
 
 	readConcurrencyFlag *pflag.Flag
 }
@@ -108,13 +153,17 @@ func (opts *BackupOptions) AddFlags(f *pflag.FlagSet) {
 	opts.ExcludePatternOptions.Add(f)
 
 	f.BoolVarP(&opts.ExcludeOtherFS, "one-file-system", "x", false, "exclude other file systems, don't cross filesystem boundaries and subvolumes")
-	f.StringArrayVar(&opts.ExcludeIfPresent, "exclude-if-present", nil, "takes `filename[:header]`, exclude contents of directories containing filename (except filename itself) if header of that file is as provided (can be specified multiple times)")
+	f.StringArrayVar(&opts.ExcludeIfPresent, "exclude-if-present", nil, "takes `filename[:header]`, exclude contents of directories containing filename (except filename itself) if header of that file is as provided, filename may contain wildcards (can be specified multiple times)")
 	f.BoolVar(&opts.ExcludeCaches, "exclude-caches", false, `excludes cache directories that are marked with a CACHEDIR.TAG file. See https://bford.info/cachedir/ for the Cache Directory Tagging Standard`)
 	f.StringVar(&opts.ExcludeLargerThan, "exclude-larger-than", "", "max `size` of the files to be backed up (allowed suffixes: k/K, m/M, g/G, t/T)")
+	f.StringVar(&opts.DirExcludeFile, "dir-exclude-file", "", "read exclude patterns and --exclude-larger-than for a directory and its subdirectories from files called `name`")
+	f.BoolVar(&opts.UseIgnoreFiles, "use-ignore-files", false, "exclude files according to the gitignore-style .resticignore files in each directory")
 	f.BoolVar(&opts.Stdin, "stdin", false, "read backup from stdin")
 	f.StringVar(&opts.StdinFilename, "stdin-filename", "stdin", "`filename` to use when reading from stdin")
 	f.BoolVar(&opts.StdinCommand, "stdin-from-command", false, "interpret arguments as command to execute and store its stdout")
+	f.StringVar(&opts.StdinCommands, "stdin-from-commands", "", "read lines of the form 'filename: command [args...]' from `file` and store the stdout of each command as filename")
 	f.Var(&opts.Tags, "tag", "add `tags` for the new snapshot in the format `tag[,tag,...]` (can be specified multiple times)")
+	f.Var(&opts.Labels, "label", "add the label `key=value` to the new snapshot (can be specified multiple times)")
 	f.UintVar(&opts.ReadConcurrency, "read-concurrency", 0, "read `n` files concurrently (default: $RESTIC_READ_CONCURRENCY or 2)")
 	f.StringVarP(&opts.Host, "host", "H", "", "set the `hostname` for the snapshot manually (default: $RESTIC_HOST). To prevent an expensive rescan use the \"parent\" flag")
 	f.StringVar(&opts.Host, "hostname", "", "set the `hostname` for the snapshot manually")
@@ -128,17 +177,40 @@ func (opts *BackupOptions) AddFlags(f *pflag.FlagSet) {
 	f.StringArrayVar(&opts.FilesFromRaw, "files-from-raw", nil, "read the files to backup from `file` (can be combined with file args; can be specified multiple times)")
 	f.StringVar(&opts.TimeStamp, "time", "", "`time` of the backup (ex. '2012-11-01 22:08:41') (default: now)")
 	f.BoolVar(&opts.WithAtime, "with-atime", false, "store the atime for all files and directories")
+	f.Var(&opts.FollowSymlinks, "follow-symlinks", "follow symlinks to directories ('dirs') or to directories and files ('all') instead of storing the links")
+	f.Lookup("follow-symlinks").NoOptDefVal = "dirs"
 	f.BoolVar(&opts.IgnoreInode, "ignore-inode", false, "ignore inode number and ctime changes when checking for modified files (default: $RESTIC_IGNORE_INODE or false)")
 	f.BoolVar(&opts.IgnoreCtime, "ignore-ctime", false, "ignore ctime changes when checking for modified files (default: $RESTIC_IGNORE_CTIME or false)")
+	f.StringVar(&opts.OnError, "on-error", "skip", "`policy` for files that cannot be read: 'skip' them, 'fail' the backup or 'retry:N' times before skipping")
+	f.StringVar(&opts.OnNetworkFS, "on-network-fs", networkFSWarn, "`policy` for files/dirs on network or FUSE filesystems: 'warn' and back them up, 'skip' them or 'force' the backup without a warning")
+	f.StringVar(&opts.ChangeDetection, "change-detection", changeDetectionMetadata, "detect modified files using `mode` 'metadata' or 'fingerprint' (also compare a hash of the content of files with changed metadata)")
 	f.BoolVarP(&opts.DryRun, "dry-run", "n", false, "do not upload or write any data, just show what would be done")
+	f.BoolVar(&opts.Predict, "predict", false, "with --dry-run, print the predicted number and size of the files uploaded to the repository")
 	f.BoolVar(&opts.NoScan, "no-scan", false, "do not run scanner to estimate size of backup")
-	if runtime.GOOS == "windows" {
+	f.BoolVar(&opts.ReadSpecial, "read-special", false, "read the content of block devices and store it as regular files, e.g. to create disk images")
+	f.BoolVar(&opts.SkipZeroBlocks, "skip-zero-blocks", false, "do not hash and upload blocks containing only zero bytes more than once, speeds up backups of disk images")
+	f.StringVar(&opts.CompressionPolicy, "compression-policy", "", "select the compression level per file type using the rules in `file` (use 'builtin' for the built-in rules)")
+	f.UintVar(&opts.ScanConcurrency, "read-concurrency-scan", 1, "scan up to `n` files and directories concurrently while estimating the size of and walking the backup targets")
+	switch runtime.GOOS {
+	case "windows":
 		f.BoolVar(&opts.UseFsSnapshot, "use-fs-snapshot", false, "use filesystem snapshot where possible (currently only Windows VSS)")
+	case "linux", "freebsd":
+		f.BoolVar(&opts.UseFsSnapshot, "use-fs-snapshot", false, "read files from temporary read-only snapshots of btrfs subvolumes and ZFS datasets")
 	}
 	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
 		f.BoolVar(&opts.ExcludeCloudFiles, "exclude-cloud-files", false, "excludes online-only cloud files (such as OneDrive, iCloud drive, …)")
+		f.BoolVar(&opts.UseChangeJournal, "use-change-journal", false, "skip unchanged directories using the filesystem change journal (NTFS USN journal or FSEvents, the latter requires a build with cgo)")
+	}
+	if runtime.GOOS == "linux" {
+		f.BoolVar(&opts.DropPageCache, "drop-page-cache", false, "drop the contents of files from the page cache after reading them")
 	}
 	f.BoolVar(&opts.SkipIfUnchanged, "skip-if-unchanged", false, "skip snapshot creation if identical to parent snapshot")
+	f.StringVar(&opts.ResourceProfile, "resource-profile", "", "limit CPU and memory usage using `profile` 'low', 'medium' or 'high' (default: $RESTIC_RESOURCE_PROFILE or no limits)")
+	f.BoolVar(&opts.IgnoreMaxRepoSize, "ignore-max-repo-size", false, "only warn if the repository is larger than its max-repo-size setting")
+	f.StringVar(&opts.PreCommand, "pre-command", "", "run `command` before the backup, abort the backup if it fails")
+	f.StringVar(&opts.PostCommand, "post-command", "", "run `command` after the backup, the result is passed in RESTIC_* environment variables")
+	f.StringVar(&opts.FreezeCommand, "freeze-command", "", "run `command` while reading the files, e.g. to put a database into backup mode (see documentation)")
+	f.StringVar(&opts.PostCommandFailureTag, "post-command-failure-tag", "hook-failed", "add `tag` to the snapshot if the post-command fails (disable with '')")
 
 	opts.readConcurrencyFlag = f.Lookup("read-concurrency")
 
@@ -150,6 +222,7 @@ func (opts *BackupOptions) AddFlags(f *pflag.FlagSet) {
 	if host := os.Getenv("RESTIC_HOST"); host != "" {
 		opts.Host = host
 	}
+	opts.ResourceProfile = os.Getenv("RESTIC_RESOURCE_PROFILE")
 }
 
 func (opts *BackupOptions) Finalize() error {
@@ -173,6 +246,9 @@ func (opts *BackupOptions) Finalize() error {
 
 var backupFSTestHook func(fs fs.FS) fs.FS
 
+// backupRetryDelay is the delay before the first retry for --on-error=retry:N.
+var backupRetryDelay = 500 * time.Millisecond
+
 // ErrInvalidSourceData is used to report an incomplete backup
 var ErrInvalidSourceData = errors.New("at least one source file could not be read")
 
@@ -296,6 +372,76 @@ func readFilenamesRaw(r io.Reader) (names []string, err error) {
 	}
 }
 
+// stdinCommand is a command whose output is stored as filename.
+type stdinCommand struct {
+	filename string
+	args     []string
+}
+
+// readStdinCommands reads the list of commands for --stdin-from-commands.
+// Each line has the form "filename: command [args...]". Arguments are
+// separated by whitespace, empty lines and lines starting with '#' are ignored.
+func readStdinCommands(filename string, stdin io.ReadCloser) ([]stdinCommand, error) {
+	lines, err := readLines(filename, stdin)
+	if err != nil {
+		return nil, err
+	}
+
+	var commands []stdinCommand
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' { // '#' marks a comment.
+			continue
+		}
+
+		name, command, found := strings.Cut(line, ":")
+		name = strings.TrimSpace(name)
+		args := strings.Fields(command)
+		if !found || name == "" || len(args) == 0 {
+			return nil, errors.Fatalf("--stdin-from-commands: invalid line %q, expected 'filename: command [args...]'", line)
+		}
+		commands = append(commands, stdinCommand{filename: path.Join("/", name), args: args})
+	}
+
+	if len(commands) == 0 {
+		return nil, errors.Fatal("--stdin-from-commands: no commands specified")
+	}
+	return commands, nil
+}
+
+// errorPolicy determines how the backup handles source files which cannot be
+// read, see --on-error.
+type errorPolicy struct {
+	// abort the backup on the first error
+	fail bool
+	// number of times a failed operation is repeated
+	retries int
+}
+
+func parseErrorPolicy(s string) (errorPolicy, error) {
+	switch s {
+	case "", "skip":
+		return errorPolicy{}, nil
+	case "fail":
+		return errorPolicy{fail: true}, nil
+	}
+
+	n, found := strings.CutPrefix(s, "retry:")
+	if found {
+		retries, err := strconv.Atoi(n)
+		if err == nil && retries > 0 {
+			return errorPolicy{retries: retries}, nil
+		}
+	}
+	return errorPolicy{}, errors.Fatalf("invalid --on-error policy %q, must be 'skip', 'fail' or 'retry:N' with N > 0", s)
+}
+
+// readsStdin returns whether the backup stores data from stdin or commands
+// instead of files and directories.
+func (opts BackupOptions) readsStdin() bool {
+	return opts.Stdin || opts.StdinCommand || opts.StdinCommands != ""
+}
+
 // Check returns an error when an invalid combination of options was set.
 func (opts BackupOptions) Check(gopts global.Options, args []string) error {
 	if gopts.Password == "" && !gopts.InsecureNoPassword {
@@ -304,6 +450,7 @@ func (opts BackupOptions) Check(gopts global.Options, args []string) error {
 		}
 
 		filesFrom := append(append(opts.FilesFrom, opts.FilesFromVerbatim...), opts.FilesFromRaw...)
+		filesFrom = append(filesFrom, opts.StdinCommands)
 		for _, filename := range filesFrom {
 			if filename == "-" {
 				return errors.Fatal("unable to read password from stdin when data is to be read from stdin, use --password-file or $RESTIC_PASSWORD")
@@ -311,7 +458,27 @@ func (opts BackupOptions) Check(gopts global.Options, args []string) error {
 		}
 	}
 
-	if opts.Stdin || opts.StdinCommand {
+	switch opts.ChangeDetection {
+	case "", changeDetectionMetadata, changeDetectionFingerprint:
+	default:
+		return errors.Fatalf("invalid --change-detection mode %q, must be %q or %q", opts.ChangeDetection, changeDetectionMetadata, changeDetectionFingerprint)
+	}
+
+	switch opts.OnNetworkFS {
+	case "", networkFSWarn, networkFSSkip, networkFSForce:
+	default:
+		return errors.Fatalf("invalid --on-network-fs policy %q, must be %q, %q or %q", opts.OnNetworkFS, networkFSWarn, networkFSSkip, networkFSForce)
+	}
+
+	if opts.Predict && !opts.DryRun {
+		return errors.Fatal("--predict can only be used together with --dry-run")
+	}
+
+	if opts.StdinCommands != "" && (opts.Stdin || opts.StdinCommand) {
+		return errors.Fatal("--stdin-from-commands cannot be combined with --stdin or --stdin-from-command")
+	}
+
+	if opts.readsStdin() {
 		if len(opts.FilesFrom) > 0 {
 			return errors.Fatal("--stdin and --files-from cannot be used together")
 		}
@@ -325,6 +492,15 @@ func (opts BackupOptions) Check(gopts global.Options, args []string) error {
 		if len(args) > 0 && !opts.StdinCommand {
 			return errors.Fatal("--stdin was specified and files/dirs were listed as arguments")
 		}
+		if opts.UseChangeJournal {
+			return errors.Fatal("--stdin and --use-change-journal cannot be used together")
+		}
+		if opts.ChangeDetection == changeDetectionFingerprint {
+			return errors.Fatal("--stdin and --change-detection=fingerprint cannot be used together")
+		}
+		if strings.HasPrefix(opts.OnError, "retry:") {
+			return errors.Fatal("--stdin and --on-error=retry cannot be used together")
+		}
 	}
 
 	return nil
@@ -354,11 +530,72 @@ func collectRejectByNameFuncs(opts BackupOptions, repo *repository.Repository, w
 	return fs, nil
 }
 
+// backupSelection returns an identifier for all options which select the files
+// of a backup or change the metadata stored for them. Subtrees of the parent
+// snapshot are only reused based on the change journal if the parent snapshot
+// was created using the same selection.
+func backupSelection(opts BackupOptions) (string, error) {
+	selection := struct {
+		Excludes                []string
+		InsensitiveExcludes     []string
+		ExcludeFiles            []restic.ID
+		InsensitiveExcludeFiles []restic.ID
+		ExcludeIfPresent        []string
+		ExcludeCaches           bool
+		ExcludeLargerThan       string
+		ExcludeCloudFiles       bool
+		ExcludeOtherFS          bool
+		OnNetworkFS             string
+		DirExcludeFile          string
+		UseIgnoreFiles          bool
+		FollowSymlinks          archiver.FollowSymlinksMode
+		WithAtime               bool
+		ReadSpecial             bool
+	}{
+		Excludes:            opts.Excludes,
+		InsensitiveExcludes: opts.InsensitiveExcludes,
+		ExcludeIfPresent:    opts.ExcludeIfPresent,
+		ExcludeCaches:       opts.ExcludeCaches,
+		ExcludeLargerThan:   opts.ExcludeLargerThan,
+		ExcludeCloudFiles:   opts.ExcludeCloudFiles,
+		ExcludeOtherFS:      opts.ExcludeOtherFS,
+		OnNetworkFS:         opts.OnNetworkFS,
+		DirExcludeFile:      opts.DirExcludeFile,
+		UseIgnoreFiles:      opts.UseIgnoreFiles,
+		FollowSymlinks:      opts.FollowSymlinks,
+		WithAtime:           opts.WithAtime,
+		ReadSpecial:         opts.ReadSpecial,
+	}
+
+	// the content of exclude files may change between backups
+	for _, files := range []struct {
+		names []string
+		ids   *[]restic.ID
+	}{
+		{opts.ExcludeFiles, &selection.ExcludeFiles},
+		{opts.InsensitiveExcludeFiles, &selection.InsensitiveExcludeFiles},
+	} {
+		for _, name := range files.names {
+			buf, err := textfile.Read(name)
+			if err != nil {
+				return "", errors.Fatalf("unable to read exclude file: %v", err)
+			}
+			*files.ids = append(*files.ids, restic.Hash(buf))
+		}
+	}
+
+	buf, err := json.Marshal(selection)
+	if err != nil {
+		return "", err
+	}
+	return restic.Hash(buf).String(), nil
+}
+
 // collectRejectFuncs returns a list of all functions which may reject data
 // from being saved in a snapshot based on path and file info
 func collectRejectFuncs(opts BackupOptions, targets []string, fs fs.FS, warnf func(msg string, args ...interface{})) (funcs []archiver.RejectFunc, err error) {
 	// allowed devices
-	if opts.ExcludeOtherFS && !opts.Stdin && !opts.StdinCommand {
+	if opts.ExcludeOtherFS && !opts.readsStdin() {
 		f, err := archiver.RejectByDevice(targets, fs)
 		if err != nil {
 			return nil, err
@@ -366,7 +603,16 @@ func collectRejectFuncs(opts BackupOptions, targets []string, fs fs.FS, warnf fu
 		funcs = append(funcs, f)
 	}
 
-	if len(opts.ExcludeLargerThan) != 0 && !opts.Stdin && !opts.StdinCommand {
+	// with --one-file-system, other filesystems are already excluded
+	if !opts.ExcludeOtherFS && opts.OnNetworkFS != networkFSForce && !opts.readsStdin() {
+		f, err := archiver.RejectNetworkFilesystems(targets, fs, opts.OnNetworkFS == networkFSSkip, warnf)
+		if err != nil {
+			return nil, err
+		}
+		funcs = append(funcs, f)
+	}
+
+	if len(opts.ExcludeLargerThan) != 0 && !opts.readsStdin() {
 		maxSize, err := ui.ParseBytes(opts.ExcludeLargerThan)
 		if err != nil {
 			return nil, err
@@ -379,7 +625,7 @@ func collectRejectFuncs(opts BackupOptions, targets []string, fs fs.FS, warnf fu
 		funcs = append(funcs, f)
 	}
 
-	if opts.ExcludeCloudFiles && !opts.Stdin && !opts.StdinCommand {
+	if opts.ExcludeCloudFiles && !opts.readsStdin() {
 		f, err := archiver.RejectCloudFiles(warnf)
 		if err != nil {
 			return nil, err
@@ -387,6 +633,22 @@ func collectRejectFuncs(opts BackupOptions, targets []string, fs fs.FS, warnf fu
 		funcs = append(funcs, f)
 	}
 
+	if opts.DirExcludeFile != "" && !opts.readsStdin() {
+		f, err := archiver.RejectByDirExcludeFile(opts.DirExcludeFile, warnf)
+		if err != nil {
+			return nil, err
+		}
+		funcs = append(funcs, f)
+	}
+
+	if opts.UseIgnoreFiles && !opts.readsStdin() {
+		f, err := archiver.RejectByIgnoreFiles(".resticignore", warnf)
+		if err != nil {
+			return nil, err
+		}
+		funcs = append(funcs, f)
+	}
+
 	if opts.ExcludeCaches {
 		opts.ExcludeIfPresent = append(opts.ExcludeIfPresent, "CACHEDIR.TAG:Signature: 8a477f597d28d172789f06886806bc55")
 	}
@@ -405,7 +667,7 @@ func collectRejectFuncs(opts BackupOptions, targets []string, fs fs.FS, warnf fu
 
 // collectTargets returns a list of target files/dirs from several sources.
 func collectTargets(opts BackupOptions, args []string, warnf func(msg string, args ...interface{}), stdin io.ReadCloser) (targets []string, err error) {
-	if opts.Stdin || opts.StdinCommand {
+	if opts.readsStdin() {
 		return nil, nil
 	}
 
@@ -505,6 +767,16 @@ func runBackup(ctx context.Context, opts BackupOptions, gopts global.Options, te
 	} else {
 		printer = backup.NewTextProgress(term, gopts.Verbosity)
 	}
+	socket, err := openProgressSocket(gopts)
+	if err != nil {
+		return err
+	}
+	if socket != nil {
+		defer func() {
+			_ = socket.Close()
+		}()
+		printer = backup.NewTeeProgress(printer, backup.NewJSONProgress(socket.Terminal(), 3), socket.Printer(printer), progressStatusEnabled(gopts, term))
+	}
 	if runtime.GOOS == "windows" {
 		if vsscfg, err = fs.ParseVSSConfig(gopts.Extended); err != nil {
 			return err
@@ -515,17 +787,64 @@ func runBackup(ctx context.Context, opts BackupOptions, gopts global.Options, te
 	if err != nil {
 		return err
 	}
+	policy, err := parseErrorPolicy(opts.OnError)
+	if err != nil {
+		return err
+	}
+	var profile *resourceProfile
+	if opts.ResourceProfile != "" {
+		p, err := parseResourceProfile(opts.ResourceProfile)
+		if err != nil {
+			return err
+		}
+		profile = &p
+	}
+
+	var changeJournal fs.ChangeJournal
+	if opts.UseChangeJournal {
+		changeJournal, err = fs.NewChangeJournal()
+		if err != nil {
+			return errors.Fatalf("--use-change-journal: %v", err)
+		}
+	}
+
+	if opts.PreCommand != "" {
+		if !gopts.JSON {
+			printer.V("run pre-command")
+		}
+		if err := runBackupHook(ctx, opts.PreCommand, nil, gopts.JSON); err != nil {
+			return errors.Fatalf("pre-command failed: %v", err)
+		}
+	}
 
 	success := true
 	targets, err := collectTargets(opts, args, printer.E, term.InputRaw())
 	if err != nil {
-		if errors.Is(err, ErrInvalidSourceData) {
+		if errors.Is(err, ErrInvalidSourceData) && policy.fail {
+			return errors.Fatal("some source files/directories do not exist, aborting due to --on-error=fail")
+		} else if errors.Is(err, ErrInvalidSourceData) {
 			success = false
 		} else {
 			return err
 		}
 	}
 
+	var filesystems map[string]string
+	if !opts.readsStdin() {
+		targets, filesystems, err = checkNetworkFilesystems(opts.OnNetworkFS, targets, printer.E)
+		if err != nil {
+			return err
+		}
+	}
+
+	var commands []stdinCommand
+	if opts.StdinCommands != "" {
+		commands, err = readStdinCommands(opts.StdinCommands, term.InputRaw())
+		if err != nil {
+			return err
+		}
+	}
+
 	timeStamp := time.Now()
 	backupStart := timeStamp
 	if opts.TimeStamp != "" {
@@ -535,6 +854,21 @@ func runBackup(ctx context.Context, opts BackupOptions, gopts global.Options, te
 		}
 	}
 
+	var compressionPolicy *archiver.CompressionPolicy
+	if opts.CompressionPolicy != "" {
+		compressionPolicy, err = archiver.LoadCompressionPolicy(opts.CompressionPolicy)
+		if err != nil {
+			return errors.Fatalf("unable to load compression policy: %v", err)
+		}
+	}
+
+	if profile != nil {
+		defer profile.apply(&opts, &gopts, printer.E)()
+		if !gopts.JSON {
+			printer.V("using resource profile %v: %v", opts.ResourceProfile, formatResourceLimits())
+		}
+	}
+
 	if gopts.Verbosity >= 2 && !gopts.JSON {
 		printer.P("open repository")
 	}
@@ -545,7 +879,7 @@ func runBackup(ctx context.Context, opts BackupOptions, gopts global.Options, te
 	}
 	defer unlock()
 
-	progressReporter := backup.NewProgress(printer, gopts.Quiet, gopts.JSON, term.CanUpdateStatus())
+	progressReporter := backup.NewProgress(printer, gopts.Quiet && socket == nil, gopts.JSON || socket != nil, term.CanUpdateStatus())
 	defer progressReporter.Done()
 
 	// rejectByNameFuncs collect functions that can reject items from the backup based on path only
@@ -579,25 +913,36 @@ func runBackup(ctx context.Context, opts BackupOptions, gopts global.Options, te
 		return err
 	}
 
-	targetFS := fs.NewLocal()
-	if runtime.GOOS == "windows" && opts.UseFsSnapshot {
-		if err = fs.HasSufficientPrivilegesForVSS(); err != nil {
-			return err
-		}
+	repoSize, err := checkRepoSize(ctx, repo, opts.IgnoreMaxRepoSize || opts.DryRun, printer.E)
+	if err != nil {
+		return err
+	}
 
-		errorHandler := func(item string, err error) {
-			_ = progressReporter.Error(item, err)
+	errorHandler := func(item string, err error) {
+		_ = progressReporter.Error(item, err)
+	}
+	messageHandler := func(msg string, args ...interface{}) {
+		if !gopts.JSON {
+			printer.P(msg, args...)
 		}
+	}
 
-		messageHandler := func(msg string, args ...interface{}) {
-			if !gopts.JSON {
-				printer.P(msg, args...)
-			}
+	var targetFS fs.FS = fs.NewLocalWithOptions(fs.LocalOptions{DropPageCache: opts.DropPageCache})
+	var fsSnapshots func() []data.FilesystemSnapshot
+	switch {
+	case runtime.GOOS == "windows" && opts.UseFsSnapshot:
+		if err = fs.HasSufficientPrivilegesForVSS(); err != nil {
+			return err
 		}
 
 		localVss := fs.NewLocalVss(errorHandler, messageHandler, vsscfg)
 		defer localVss.DeleteSnapshots()
 		targetFS = localVss
+	case opts.UseFsSnapshot && !opts.Stdin && !opts.StdinCommand:
+		localSnapshot := fs.NewLocalFsSnapshot(targetFS, targets, errorHandler, messageHandler)
+		defer localSnapshot.DeleteSnapshots()
+		targetFS = localSnapshot
+		fsSnapshots = localSnapshot.Snapshots
 	}
 
 	if opts.Stdin || opts.StdinCommand {
@@ -622,10 +967,48 @@ func runBackup(ctx context.Context, opts BackupOptions, gopts global.Options, te
 		targets = []string{filename}
 	}
 
+	if opts.StdinCommands != "" {
+		var files []fs.ReaderFile
+		targets = nil
+		for _, command := range commands {
+			if !gopts.JSON {
+				printer.V("store output of %v as %v", command.args, command.filename)
+			}
+			// only start the commands once their output is read
+			source, err := fs.NewLazyCommandReader(ctx, command.args, printer.E)
+			if err != nil {
+				return err
+			}
+			files = append(files, fs.ReaderFile{Name: command.filename, Reader: source})
+			targets = append(targets, command.filename)
+		}
+
+		targetFS, err = fs.NewMultiReader(files, fs.ReaderOptions{
+			ModTime: timeStamp,
+			Mode:    0644,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to backup from commands: %w", err)
+		}
+	}
+
 	if backupFSTestHook != nil {
 		targetFS = backupFSTestHook(targetFS)
 	}
 
+	if policy.retries > 0 {
+		targetFS = fs.Retry{
+			FS:      targetFS,
+			Retries: policy.retries,
+			Delay:   backupRetryDelay,
+			Report: func(item string, err error) {
+				if !gopts.JSON {
+					printer.V("retrying %v after error: %v", item, err)
+				}
+			},
+		}
+	}
+
 	// rejectFuncs collect functions that can reject items from the backup based on path and file info
 	rejectFuncs, err := collectRejectFuncs(opts, targets, targetFS, printer.E)
 	if err != nil {
@@ -635,6 +1018,38 @@ func runBackup(ctx context.Context, opts BackupOptions, gopts global.Options, te
 	selectByNameFilter := archiver.CombineRejectByNames(rejectByNameFuncs)
 	selectFilter := archiver.CombineRejects(rejectFuncs)
 
+	var fingerprints *archiver.FingerprintCache
+	var fingerprintFile string
+	if opts.ChangeDetection == changeDetectionFingerprint {
+		if repo.Cache() == nil {
+			return errors.Fatal("--change-detection=fingerprint requires the local cache, it cannot be used with --no-cache")
+		}
+		fingerprintFile, err = fingerprintCacheFile(repo.Cache().Dir(), targets)
+		if err != nil {
+			return err
+		}
+		fingerprints, err = loadFingerprints(fingerprintFile)
+		if err != nil {
+			printer.E("unable to load fingerprints, ignoring: %v", err)
+			fingerprints = archiver.NewFingerprintCache()
+		}
+	}
+
+	var freeze *freezeCommand
+	if opts.FreezeCommand != "" {
+		if !gopts.JSON {
+			printer.V("run freeze-command")
+		}
+		freeze, err = startFreezeCommand(ctx, opts.FreezeCommand, gopts.JSON)
+		if err != nil {
+			return errors.Fatalf("freeze-command failed: %v", err)
+		}
+		defer func() {
+			// only has an effect if the backup did not complete
+			_, _ = freeze.Finish()
+		}()
+	}
+
 	wg, wgCtx := errgroup.WithContext(ctx)
 	cancelCtx, cancel := context.WithCancel(wgCtx)
 	defer cancel()
@@ -645,6 +1060,8 @@ func runBackup(ctx context.Context, opts BackupOptions, gopts global.Options, te
 		sc.Select = selectFilter
 		sc.Error = printer.ScannerError
 		sc.Result = progressReporter.ReportTotal
+		sc.Concurrency = opts.ScanConcurrency
+		sc.ReadSpecial = opts.ReadSpecial
 
 		if !gopts.JSON {
 			printer.V("start scan on %v", targets)
@@ -652,17 +1069,24 @@ func runBackup(ctx context.Context, opts BackupOptions, gopts global.Options, te
 		wg.Go(func() error { return sc.Scan(cancelCtx, targets) })
 	}
 
-	arch := archiver.New(repo, targetFS, archiver.Options{ReadConcurrency: opts.ReadConcurrency})
+	arch := archiver.New(repo, targetFS, archiver.Options{
+		ReadConcurrency:   opts.ReadConcurrency,
+		ScanConcurrency:   opts.ScanConcurrency,
+		CompressionPolicy: compressionPolicy,
+		ReadSpecial:       opts.ReadSpecial,
+		SkipZeroBlocks:    opts.SkipZeroBlocks,
+	})
 	arch.SelectByName = selectByNameFilter
 	arch.Select = selectFilter
 	arch.WithAtime = opts.WithAtime
+	arch.FollowSymlinks = opts.FollowSymlinks
 
 	arch.Error = func(item string, err error) error {
 		success = false
 		reterr := progressReporter.Error(item, err)
 		// If we receive a fatal error during the execution of the snapshot,
 		// we abort the snapshot.
-		if reterr == nil && errors.IsFatal(err) {
+		if reterr == nil && (errors.IsFatal(err) || policy.fail) {
 			reterr = err
 		}
 		return reterr
@@ -680,16 +1104,55 @@ func runBackup(ctx context.Context, opts BackupOptions, gopts global.Options, te
 	if opts.IgnoreCtime {
 		arch.ChangeIgnoreFlags |= archiver.ChangeIgnoreCtime
 	}
+	arch.ChangeJournal = changeJournal
+	arch.Fingerprints = fingerprints
 
 	snapshotOpts := archiver.SnapshotOptions{
-		Excludes:        opts.Excludes,
-		Tags:            opts.Tags.Flatten(),
-		BackupStart:     backupStart,
-		Time:            timeStamp,
-		Hostname:        opts.Host,
-		ParentSnapshot:  parentSnapshot,
-		ProgramVersion:  "restic " + global.Version,
-		SkipIfUnchanged: opts.SkipIfUnchanged,
+		Excludes:            opts.Excludes,
+		Tags:                opts.Tags.Flatten(),
+		Labels:              opts.Labels,
+		BackupStart:         backupStart,
+		Time:                timeStamp,
+		Hostname:            opts.Host,
+		ParentSnapshot:      parentSnapshot,
+		ProgramVersion:      "restic " + global.Version,
+		SkipIfUnchanged:     opts.SkipIfUnchanged,
+		Filesystems:         filesystems,
+		FilesystemSnapshots: fsSnapshots,
+	}
+	if opts.UseChangeJournal {
+		snapshotOpts.Selection, err = backupSelection(opts)
+		if err != nil {
+			return err
+		}
+		if opts.UseIgnoreFiles {
+			snapshotOpts.InheritedExcludeFiles = append(snapshotOpts.InheritedExcludeFiles, ".resticignore")
+		}
+		if opts.DirExcludeFile != "" {
+			snapshotOpts.InheritedExcludeFiles = append(snapshotOpts.InheritedExcludeFiles, opts.DirExcludeFile)
+		}
+	}
+	snapshotOpts.BeforeSave = func(sn *data.Snapshot) error {
+		if freeze != nil {
+			labels, err := freeze.Finish()
+			if err != nil {
+				return errors.Fatalf("freeze-command failed: %v", err)
+			}
+			merged := data.Labels{}
+			for key, value := range sn.Labels {
+				merged[key] = value
+			}
+			for key, value := range labels {
+				merged[key] = value
+			}
+			sn.Labels = merged
+		}
+
+		// without a lock file, a concurrent prune may have removed data used by the snapshot
+		if err := repo.CheckGeneration(ctx); err != nil {
+			return errors.Fatalf("not saving snapshot: %v, repeat the backup", err)
+		}
+		return nil
 	}
 
 	if !gopts.JSON {
@@ -705,11 +1168,70 @@ func runBackup(ctx context.Context, opts BackupOptions, gopts global.Options, te
 
 	// return original error
 	if err != nil {
+		if opts.PostCommand != "" {
+			if perr := runPostCommand(ctx, opts, repo, gopts.JSON, backupStatusFailed, restic.ID{}, nil, printer); perr != nil {
+				printer.E("%v", perr)
+			}
+		}
 		return errors.Fatalf("unable to save snapshot: %v", err)
 	}
 
+	// a prune may have started while the snapshot was saved, remove the snapshot
+	// as it may reference data which is removed by prune
+	if err := repo.CheckGeneration(ctx); err != nil && !id.IsNull() {
+		if rerr := repo.RemoveUnpacked(context.WithoutCancel(ctx), restic.WriteableSnapshotFile, id); rerr != nil {
+			return errors.Fatalf("snapshot %v may be damaged: %v, removing it failed: %v, remove it using `restic forget` and repeat the backup", id.Str(), err, rerr)
+		}
+		return errors.Fatalf("removed snapshot %v: %v, repeat the backup", id.Str(), err)
+	}
+
+	if fingerprints != nil && !opts.DryRun {
+		if err := saveFingerprints(fingerprintFile, fingerprints); err != nil {
+			printer.E("unable to save fingerprints: %v", err)
+		}
+	}
+
 	// Report finished execution
 	progressReporter.Finish(id, summary, opts.DryRun)
+	if opts.Predict {
+		stats := repo.DryRunStats()
+		progressReporter.ReportPrediction(backup.UploadPrediction{
+			Files:     stats.Files,
+			DataPacks: stats.DataPacks,
+			TreePacks: stats.TreePacks,
+			Indexes:   stats.Indexes,
+			Other:     stats.Other,
+		})
+	}
+
+	if maxSize := repo.Config().MaxRepoSize; maxSize > 0 && !opts.DryRun {
+		newSize := repoSize + summary.DataSizeInRepo + summary.TreeSizeInRepo
+		if newSize > maxSize {
+			printer.E("warning: repository size of about %v exceeds max-repo-size of %v", ui.FormatBytes(newSize), ui.FormatBytes(maxSize))
+		}
+	}
+
+	if !opts.DryRun && !id.IsNull() {
+		entry := data.NewAuditLogEntry("backup")
+		entry.Snapshots = restic.IDs{id}
+		if err := recordAuditLog(ctx, repo, entry); err != nil {
+			return err
+		}
+	}
+
+	if opts.PostCommand != "" {
+		status := backupStatusSuccess
+		if !success || werr != nil {
+			status = backupStatusIncomplete
+		}
+		if !gopts.JSON {
+			printer.V("run post-command")
+		}
+		if err := runPostCommand(ctx, opts, repo, gopts.JSON, status, id, summary, printer); err != nil {
+			return err
+		}
+	}
+
 	if !success {
 		return ErrInvalidSourceData
 	}
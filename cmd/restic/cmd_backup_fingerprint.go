@@ -0,0 +1,77 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/restic/restic/internal/archiver"
+	"github.com/restic/restic/internal/errors"
+)
+
+// Values for the --change-detection option of the backup command.
+const (
+	changeDetectionMetadata    = "metadata"
+	changeDetectionFingerprint = "fingerprint"
+)
+
+// fingerprintCacheFile returns the path of the file which stores the
+// fingerprints of a backup of targets, below the cache directory cacheDir.
+func fingerprintCacheFile(cacheDir string, targets []string) (string, error) {
+	abs := make([]string, 0, len(targets))
+	for _, target := range targets {
+		p, err := filepath.Abs(target)
+		if err != nil {
+			return "", err
+		}
+		abs = append(abs, p)
+	}
+	slices.Sort(abs)
+
+	id := sha256.Sum256([]byte(strings.Join(abs, "\x00")))
+	return filepath.Join(cacheDir, "fingerprints", hex.EncodeToString(id[:])), nil
+}
+
+// loadFingerprints reads the fingerprint cache from filename. If the file does
+// not exist yet, an empty cache is returned.
+func loadFingerprints(filename string) (*archiver.FingerprintCache, error) {
+	f, err := os.Open(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return archiver.NewFingerprintCache(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	return archiver.LoadFingerprintCache(f)
+}
+
+// saveFingerprints atomically replaces the fingerprint cache in filename.
+func saveFingerprints(filename string, c *archiver.FingerprintCache) error {
+	dir := filepath.Dir(filename)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(dir, "tmp-")
+	if err != nil {
+		return err
+	}
+	err = c.Save(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filename)
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+	}
+	return err
+}
@@ -1,17 +1,22 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
+	"syscall"
 	"testing"
 	"time"
 
+	"github.com/restic/restic/internal/backend"
 	"github.com/restic/restic/internal/data"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/fs"
@@ -199,6 +204,82 @@ func TestBackupVSS(t *testing.T) {
 	rtest.Equals(t, true, testFS.hasRemoved, "testdata was not removed")
 }
 
+// failingFS fails to open the file name until failures is zero.
+type failingFS struct {
+	fs.FS
+	name     string
+	failures int
+}
+
+func (f *failingFS) OpenFile(name string, flag int, metadataOnly bool) (fs.File, error) {
+	if filepath.Base(name) == f.name && f.failures > 0 {
+		f.failures--
+		return nil, syscall.EIO
+	}
+	return f.FS.OpenFile(name, flag, metadataOnly)
+}
+
+func TestBackupOnError(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	target := filepath.Join(env.testdata, "0", "0", "9")
+	entries, err := os.ReadDir(target)
+	rtest.OK(t, err)
+	failing := entries[0].Name()
+
+	var failures int
+	backupFSTestHook = func(fsys fs.FS) fs.FS {
+		return &failingFS{FS: fsys, name: failing, failures: failures}
+	}
+	oldDelay := backupRetryDelay
+	backupRetryDelay = 0
+	defer func() {
+		backupFSTestHook = nil
+		backupRetryDelay = oldDelay
+	}()
+
+	// skipped files result in an incomplete snapshot
+	failures = 1
+	opts := BackupOptions{NoScan: true}
+	err = testRunBackupAssumeFailure(t, "", []string{target}, opts, env.gopts)
+	rtest.Assert(t, err == ErrInvalidSourceData, "expected ErrInvalidSourceData, got %v", err)
+	testListSnapshots(t, env.gopts, 1)
+
+	// the JSON summary lists the skipped file
+	gopts := env.gopts
+	gopts.JSON = true
+	out, err := testRunBackupOutput(t, opts, gopts, []string{target})
+	rtest.Assert(t, err == ErrInvalidSourceData, "expected ErrInvalidSourceData, got %v", err)
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	var summary struct {
+		SkippedItems []struct {
+			Item string `json:"item"`
+		} `json:"skipped_items"`
+	}
+	rtest.OK(t, json.Unmarshal([]byte(lines[len(lines)-1]), &summary))
+	rtest.Equals(t, 1, len(summary.SkippedItems))
+	rtest.Equals(t, failing, filepath.Base(summary.SkippedItems[0].Item))
+	testListSnapshots(t, env.gopts, 2)
+
+	// fail aborts the backup
+	opts.OnError = "fail"
+	err = testRunBackupAssumeFailure(t, "", []string{target}, opts, env.gopts)
+	rtest.Assert(t, errors.IsFatal(err), "expected fatal error, got %v", err)
+	testListSnapshots(t, env.gopts, 2)
+
+	// retry hides transient errors
+	failures = 2
+	opts.OnError = "retry:2"
+	testRunBackup(t, "", []string{target}, opts, env.gopts)
+	testListSnapshots(t, env.gopts, 3)
+
+	failures = 3
+	err = testRunBackupAssumeFailure(t, "", []string{target}, opts, env.gopts)
+	rtest.Assert(t, err == ErrInvalidSourceData, "expected ErrInvalidSourceData, got %v", err)
+}
+
 func TestBackupParentSelection(t *testing.T) {
 	env, cleanup := withTestEnvironment(t)
 	defer cleanup()
@@ -272,6 +353,42 @@ func TestDryRunBackup(t *testing.T) {
 	rtest.Equals(t, indexIDs, indexIDsAfter)
 }
 
+func TestDryRunBackupPredict(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	gopts := env.gopts
+	gopts.JSON = true
+	opts := BackupOptions{DryRun: true, Predict: true}
+	out, err := testRunBackupOutput(t, opts, gopts, []string{env.testdata})
+	rtest.OK(t, err)
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	var prediction struct {
+		MessageType   string `json:"message_type"`
+		FilesUploaded int    `json:"files_uploaded"`
+		DataPacksSize uint64 `json:"data_packs_size"`
+		TotalSize     uint64 `json:"total_size"`
+	}
+	rtest.OK(t, json.Unmarshal([]byte(lines[len(lines)-1]), &prediction))
+	rtest.Equals(t, "upload_prediction", prediction.MessageType)
+	testListSnapshots(t, env.gopts, 0)
+
+	// the prediction must match the files added by the actual backup, except
+	// for small differences in the length of the timestamps
+	before := dirStats(t, env.repo)
+	testRunBackup(t, "", []string{env.testdata}, BackupOptions{}, env.gopts)
+	after := dirStats(t, env.repo)
+	rtest.Equals(t, after.files-before.files, uint(prediction.FilesUploaded))
+	added := after.size - before.size
+	rtest.Assert(t, prediction.DataPacksSize > 0, "no data packs predicted")
+	rtest.Assert(t, prediction.TotalSize > added-100 && prediction.TotalSize < added+100,
+		"predicted upload of %v bytes differs from actual upload of %v bytes", prediction.TotalSize, added)
+
+	err = testRunBackupAssumeFailure(t, "", []string{env.testdata}, BackupOptions{Predict: true}, env.gopts)
+	rtest.Assert(t, err != nil && strings.Contains(err.Error(), "--dry-run"), "expected error for --predict without --dry-run, got %v", err)
+}
+
 func TestBackupNonExistingFile(t *testing.T) {
 	env, cleanup := withTestEnvironment(t)
 	defer cleanup()
@@ -444,6 +561,67 @@ const (
 	incrementalThirdWrite  = 1 * 1042 * 1024
 )
 
+func TestBackupOptimisticLock(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+
+	// without lock files, files never have to be removed
+	env.gopts.OptimisticLock = true
+	env.gopts.BackendTestHook = func(r backend.Backend) (backend.Backend, error) {
+		return &appendOnlyBackend{r}, nil
+	}
+	testRunBackup(t, "", []string{env.testdata}, BackupOptions{}, env.gopts)
+	snapshotIDs := testListSnapshots(t, env.gopts, 1)
+	testRunRestore(t, env.gopts, filepath.Join(env.base, "restore"), snapshotIDs[0].String())
+
+	env.gopts.BackendTestHook = nil
+	testRunCheck(t, env.gopts)
+}
+
+// generationBumpBackend stores a new generation marker once the first file of
+// type trigger was saved, as if an exclusive operation had started.
+type generationBumpBackend struct {
+	backend.Backend
+	trigger backend.FileType
+	once    sync.Once
+}
+
+func (b *generationBumpBackend) Save(ctx context.Context, h backend.Handle, rd backend.RewindReader) error {
+	err := b.Backend.Save(ctx, h, rd)
+	if err != nil || h.Type != b.trigger {
+		return err
+	}
+	b.once.Do(func() {
+		marker := backend.Handle{Type: backend.GenerationFile, Name: restic.NewRandomID().String()}
+		err = b.Backend.Save(ctx, marker, backend.NewByteReader([]byte("marker"), nil))
+	})
+	return err
+}
+
+func TestBackupOptimisticLockGenerationChanged(t *testing.T) {
+	for _, trigger := range []backend.FileType{backend.PackFile, backend.SnapshotFile} {
+		t.Run(trigger.String(), func(t *testing.T) {
+			env, cleanup := withTestEnvironment(t)
+			defer cleanup()
+
+			testSetupBackupData(t, env)
+
+			gopts := env.gopts
+			gopts.OptimisticLock = true
+			gopts.BackendTestHook = func(r backend.Backend) (backend.Backend, error) {
+				return &generationBumpBackend{Backend: r, trigger: trigger}, nil
+			}
+			err := testRunBackupAssumeFailure(t, "", []string{env.testdata}, BackupOptions{}, gopts)
+			rtest.Assert(t, err != nil, "expected backup to fail")
+
+			// no snapshot which may reference removed data must remain
+			testListSnapshots(t, env.gopts, 0)
+		})
+	}
+}
+
 func TestIncrementalBackup(t *testing.T) {
 	env, cleanup := withTestEnvironment(t)
 	defer cleanup()
@@ -533,6 +711,30 @@ func TestBackupProgramVersion(t *testing.T) {
 		"expected %v, got %v", resticVersion, newest.ProgramVersion)
 }
 
+func TestBackupFilesystems(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	info, err := fs.FilesystemType(env.testdata)
+	rtest.OK(t, err)
+	if info.Network {
+		t.Skip("test data is on a network filesystem")
+	}
+
+	testRunBackup(t, "", []string{env.testdata}, BackupOptions{OnNetworkFS: networkFSSkip}, env.gopts)
+	newest, _ := testRunSnapshots(t, env.gopts)
+	rtest.Assert(t, newest != nil, "expected a backup, got nil")
+
+	abs, err := filepath.Abs(env.testdata)
+	rtest.OK(t, err)
+	if info.Type == "" {
+		rtest.Equals(t, 0, len(newest.Filesystems))
+	} else {
+		rtest.Equals(t, map[string]string{abs: info.Type}, newest.Filesystems)
+	}
+}
+
 func TestQuietBackup(t *testing.T) {
 	env, cleanup := withTestEnvironment(t)
 	defer cleanup()
@@ -664,6 +866,29 @@ func TestStdinFromCommand(t *testing.T) {
 	testRunCheck(t, env.gopts)
 }
 
+func TestStdinFromCommands(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	commands := filepath.Join(env.base, "commands")
+	rtest.OK(t, os.WriteFile(commands, []byte(
+		"dumps/first: python -c print('first')\n"+
+			"dumps/second: python -c print('second')\n"), 0600))
+	opts := BackupOptions{
+		StdinCommands: commands,
+	}
+
+	testRunBackup(t, filepath.Dir(env.testdata), nil, opts, env.gopts)
+	snapshots := testListSnapshots(t, env.gopts, 1)
+	files := testRunLs(t, env.gopts, snapshots[0].String())
+	for _, file := range []string{"/dumps/first", "/dumps/second"} {
+		rtest.Assert(t, includes(files, file), "file %q missing from snapshot, got %v", file, files)
+	}
+
+	testRunCheck(t, env.gopts)
+}
+
 func TestStdinFromCommandNoOutput(t *testing.T) {
 	env, cleanup := withTestEnvironment(t)
 	defer cleanup()
@@ -699,6 +924,97 @@ func TestStdinFromCommandFailExitCode(t *testing.T) {
 	testRunCheck(t, env.gopts)
 }
 
+func TestBackupHooks(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	// the hook writes its environment to the file given as first argument
+	// and exits with the status given as second argument
+	rtest.OK(t, os.WriteFile(filepath.Join(env.base, "hook.py"), []byte(
+		"import os, sys\n"+
+			"with open(sys.argv[1], 'w') as f:\n"+
+			"    for k, v in os.environ.items():\n"+
+			"        f.write(k + '=' + v + '\\n')\n"+
+			"sys.exit(int(sys.argv[2]))\n"), 0600))
+
+	readEnv := func(name string) []string {
+		buf, err := os.ReadFile(filepath.Join(env.base, name))
+		rtest.OK(t, err)
+		return strings.Split(string(buf), "\n")
+	}
+
+	opts := BackupOptions{
+		PreCommand:            "python hook.py pre.env 0",
+		PostCommand:           "python hook.py post.env 0",
+		PostCommandFailureTag: "hook-failed",
+	}
+	testRunBackup(t, env.base, []string{env.testdata}, opts, env.gopts)
+	snapshotIDs := testListSnapshots(t, env.gopts, 1)
+	rtest.Assert(t, len(readEnv("pre.env")) > 1, "pre-command did not write its environment")
+	postEnv := readEnv("post.env")
+	for _, v := range []string{"RESTIC_BACKUP_STATUS=success", "RESTIC_SNAPSHOT_ID=" + snapshotIDs[0].String()} {
+		rtest.Assert(t, includes(postEnv, v), "%v missing from post-command environment", v)
+	}
+
+	// failed pre-command aborts the backup
+	opts.PreCommand = "python hook.py pre.env 1"
+	err := testRunBackupAssumeFailure(t, env.base, []string{env.testdata}, opts, env.gopts)
+	rtest.Assert(t, err != nil && strings.Contains(err.Error(), "pre-command failed"), "expected pre-command error, got %v", err)
+	testListSnapshots(t, env.gopts, 1)
+
+	// failed post-command tags the new snapshot
+	opts.PreCommand = ""
+	opts.PostCommand = "python hook.py post.env 1"
+	err = testRunBackupAssumeFailure(t, env.base, []string{env.testdata}, opts, env.gopts)
+	rtest.Assert(t, err != nil && strings.Contains(err.Error(), "post-command failed"), "expected post-command error, got %v", err)
+
+	var tagged int
+	for _, id := range testListSnapshots(t, env.gopts, 2) {
+		sn := testLoadSnapshot(t, env.gopts, id)
+		if sn.HasTags([]string{"hook-failed"}) {
+			tagged++
+		}
+	}
+	rtest.Equals(t, 1, tagged)
+
+	testRunCheck(t, env.gopts)
+}
+
+func TestBackupFreezeCommand(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	// the command prints a label before and after the files are read, it
+	// exits with the status given as first argument once stdin is closed
+	rtest.OK(t, os.WriteFile(filepath.Join(env.base, "freeze.py"), []byte(
+		"import sys\n"+
+			"print('label db.start_lsn=0/3000028')\n"+
+			"print('ready', flush=True)\n"+
+			"sys.stdin.read()\n"+
+			"print('label db.stop_lsn=0/3000100')\n"+
+			"sys.exit(int(sys.argv[1]))\n"), 0600))
+
+	opts := BackupOptions{FreezeCommand: "python freeze.py 0"}
+	testRunBackup(t, env.base, []string{env.testdata}, opts, env.gopts)
+	snapshotIDs := testListSnapshots(t, env.gopts, 1)
+	sn := testLoadSnapshot(t, env.gopts, snapshotIDs[0])
+	rtest.Equals(t, data.Labels{"db.start_lsn": "0/3000028", "db.stop_lsn": "0/3000100"}, sn.Labels)
+
+	// a failing command does not create a snapshot
+	opts.FreezeCommand = "python freeze.py 1"
+	err := testRunBackupAssumeFailure(t, env.base, []string{env.testdata}, opts, env.gopts)
+	rtest.Assert(t, err != nil && strings.Contains(err.Error(), "freeze-command failed"), "expected freeze-command error, got %v", err)
+	testListSnapshots(t, env.gopts, 1)
+
+	// the backup is not started if the command exits before it is ready
+	opts.FreezeCommand = "python -c pass"
+	err = testRunBackupAssumeFailure(t, env.base, []string{env.testdata}, opts, env.gopts)
+	rtest.Assert(t, err != nil && strings.Contains(err.Error(), "freeze-command failed"), "expected freeze-command error, got %v", err)
+	testListSnapshots(t, env.gopts, 1)
+}
+
 func TestStdinFromCommandFailNoOutputAndExitCode(t *testing.T) {
 	env, cleanup := withTestEnvironment(t)
 	defer cleanup()
@@ -782,3 +1098,46 @@ func TestBackupExcludeWithOutput(t *testing.T) {
 	}
 	rtest.Assert(t, foundExclude, "expected at least one excluded item, but found none")
 }
+
+func TestBackupProgressSocket(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+
+	env.gopts.ProgressSocket = filepath.Join(env.base, "progress.sock")
+	l, err := net.Listen("unix", env.gopts.ProgressSocket)
+	rtest.OK(t, err)
+	defer func() {
+		_ = l.Close()
+	}()
+
+	messageTypes := make(chan map[string]int)
+	go func() {
+		counts := make(map[string]int)
+		defer func() {
+			messageTypes <- counts
+		}()
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		sc := bufio.NewScanner(conn)
+		for sc.Scan() {
+			var mType struct {
+				MessageType string `json:"message_type"`
+			}
+			if err := json.Unmarshal(sc.Bytes(), &mType); err != nil {
+				t.Errorf("invalid event %q: %v", sc.Text(), err)
+			}
+			counts[mType.MessageType]++
+		}
+	}()
+
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, BackupOptions{}, env.gopts)
+	counts := <-messageTypes
+
+	// per-file events are sent independent of the verbosity
+	rtest.Assert(t, counts["verbose_status"] > 0, "missing per-file events, got %v", counts)
+	rtest.Equals(t, 1, counts["summary"])
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"path/filepath"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/fs"
+)
+
+// Values for the --on-network-fs option of the backup command.
+const (
+	networkFSWarn  = "warn"
+	networkFSSkip  = "skip"
+	networkFSForce = "force"
+)
+
+// checkNetworkFilesystems determines the filesystem type of all targets and
+// handles targets on network or FUSE filesystems according to mode. It returns
+// the targets which should be backed up and the filesystem type for each of
+// their absolute paths.
+func checkNetworkFilesystems(mode string, targets []string, warnf func(msg string, args ...interface{})) ([]string, map[string]string, error) {
+	remaining := make([]string, 0, len(targets))
+	filesystems := make(map[string]string)
+	for _, target := range targets {
+		info, err := fs.FilesystemType(target)
+		if err != nil {
+			// errors are reported once the target is read
+			debug.Log("unable to determine filesystem of %v: %v", target, err)
+			remaining = append(remaining, target)
+			continue
+		}
+
+		if info.Network {
+			switch mode {
+			case networkFSSkip:
+				warnf("skipping %v, it is on a network filesystem (%v)\n", target, info.Type)
+				continue
+			case networkFSForce:
+			default:
+				warnf("%v is on a network filesystem (%v), reading it may be slow and change detection may be unreliable\n", target, info.Type)
+			}
+		}
+
+		remaining = append(remaining, target)
+		if info.Type == "" {
+			continue
+		}
+		abs, err := filepath.Abs(target)
+		if err != nil {
+			abs = target
+		}
+		filesystems[abs] = info.Type
+	}
+
+	if len(remaining) == 0 && len(targets) > 0 {
+		return nil, nil, errors.Fatal("all source files/directories are on network filesystems, nothing to back up")
+	}
+	if len(filesystems) == 0 {
+		filesystems = nil
+	}
+	return remaining, filesystems, nil
+}
@@ -139,3 +139,88 @@ func TestReadFilenamesRaw(t *testing.T) {
 	rtest.Assert(t, strings.Contains(err.Error(), "zero byte"),
 		"wrong error message: %v", err.Error())
 }
+
+func TestReadStdinCommands(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "commands")
+	rtest.OK(t, os.WriteFile(filename, []byte(`
+# database dumps
+db/app.sql: pg_dump app
+/db/other.sql:pg_dump   --clean other
+`), 0600))
+
+	commands, err := readStdinCommands(filename, nil)
+	rtest.OK(t, err)
+	rtest.Equals(t, []stdinCommand{
+		{filename: "/db/app.sql", args: []string{"pg_dump", "app"}},
+		{filename: "/db/other.sql", args: []string{"pg_dump", "--clean", "other"}},
+	}, commands)
+
+	for _, content := range []string{"", "# only a comment\n", "no command", "file:", ": command"} {
+		rtest.OK(t, os.WriteFile(filename, []byte(content), 0600))
+		_, err := readStdinCommands(filename, nil)
+		rtest.Assert(t, err != nil, "expected error for %q", content)
+	}
+}
+
+func TestParseErrorPolicy(t *testing.T) {
+	for _, test := range []struct {
+		value  string
+		policy errorPolicy
+	}{
+		{"", errorPolicy{}},
+		{"skip", errorPolicy{}},
+		{"fail", errorPolicy{fail: true}},
+		{"retry:3", errorPolicy{retries: 3}},
+	} {
+		policy, err := parseErrorPolicy(test.value)
+		rtest.OK(t, err)
+		rtest.Equals(t, test.policy, policy)
+	}
+
+	for _, value := range []string{"abort", "retry", "retry:0", "retry:-1", "retry:x"} {
+		_, err := parseErrorPolicy(value)
+		rtest.Assert(t, err != nil, "expected error for %q", value)
+	}
+}
+
+func TestFingerprintCacheFile(t *testing.T) {
+	cacheDir := t.TempDir()
+
+	// the order of the targets does not matter
+	filename, err := fingerprintCacheFile(cacheDir, []string{"/foo", "/bar"})
+	rtest.OK(t, err)
+	other, err := fingerprintCacheFile(cacheDir, []string{"/bar", "/foo"})
+	rtest.OK(t, err)
+	rtest.Equals(t, filename, other)
+	other, err = fingerprintCacheFile(cacheDir, []string{"/foo"})
+	rtest.OK(t, err)
+	rtest.Assert(t, filename != other, "same cache file for different targets")
+
+	c, err := loadFingerprints(filename)
+	rtest.OK(t, err)
+	rtest.OK(t, saveFingerprints(filename, c))
+	_, err = loadFingerprints(filename)
+	rtest.OK(t, err)
+
+	rtest.OK(t, os.WriteFile(filename, []byte("invalid"), 0600))
+	_, err = loadFingerprints(filename)
+	rtest.Assert(t, err != nil, "missing error for invalid cache file")
+}
+
+func TestCheckNetworkFilesystems(t *testing.T) {
+	dir := t.TempDir()
+	missing := filepath.Join(dir, "missing")
+
+	for _, mode := range []string{networkFSWarn, networkFSSkip, networkFSForce} {
+		var warnings []string
+		warnf := func(msg string, args ...interface{}) {
+			warnings = append(warnings, fmt.Sprintf(msg, args...))
+		}
+
+		// local directories are kept, errors are left to the archiver
+		targets, _, err := checkNetworkFilesystems(mode, []string{dir, missing}, warnf)
+		rtest.OK(t, err)
+		rtest.Equals(t, []string{dir, missing}, targets)
+		rtest.Assert(t, len(warnings) == 0, "unexpected warnings: %v", warnings)
+	}
+}
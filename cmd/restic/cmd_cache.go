@@ -25,7 +25,13 @@ func newCacheCommand(globalOptions *global.Options) *cobra.Command {
 		Use:   "cache",
 		Short: "Operate on local cache directories",
 		Long: `
-The "cache" command allows listing and cleaning local cache directories.
+The "cache" command allows listing and cleaning local cache directories. Use
+"restic cache warm" to load the metadata of a snapshot into the cache.
+
+With "--stats", the listing additionally shows how many files were loaded from
+the cache (hits) or had to be downloaded from the repository (misses) over all
+runs, and how many data packs were evicted because the cache exceeded the size
+set using the global "--cache-size" option.
 
 EXIT STATUS
 ===========
@@ -41,6 +47,7 @@ Exit status is 1 if there was any error.
 	}
 
 	opts.AddFlags(cmd.Flags())
+	cmd.AddCommand(newCacheWarmCommand(globalOptions))
 	return cmd
 }
 
@@ -49,12 +56,14 @@ type CacheOptions struct {
 	Cleanup bool
 	MaxAge  uint
 	NoSize  bool
+	Stats   bool
 }
 
 func (opts *CacheOptions) AddFlags(f *pflag.FlagSet) {
 	f.BoolVar(&opts.Cleanup, "cleanup", false, "remove old cache directories")
 	f.UintVar(&opts.MaxAge, "max-age", 30, "max age in `days` for cache directories to be considered old")
 	f.BoolVar(&opts.NoSize, "no-size", false, "do not output the size of the cache directories")
+	f.BoolVar(&opts.Stats, "stats", false, "show the hit rate and number of evictions of the cache directories")
 }
 
 func runCache(opts CacheOptions, gopts global.Options, args []string, term ui.Terminal) error {
@@ -107,10 +116,14 @@ func runCache(opts CacheOptions, gopts global.Options, args []string, term ui.Te
 	tab := table.New()
 
 	type data struct {
-		ID   string
-		Last string
-		Old  string
-		Size string
+		ID        string
+		Last      string
+		Old       string
+		Size      string
+		Hits      uint64
+		Misses    uint64
+		HitRate   string
+		Evictions uint64
 	}
 
 	tab.AddColumn("Repo ID", "{{ .ID }}")
@@ -120,6 +133,12 @@ func runCache(opts CacheOptions, gopts global.Options, args []string, term ui.Te
 	if !opts.NoSize {
 		tab.AddColumn("Size", "{{ .Size }}")
 	}
+	if opts.Stats {
+		tab.AddColumn("Hits", "{{ .Hits }}")
+		tab.AddColumn("Misses", "{{ .Misses }}")
+		tab.AddColumn("Hit Rate", "{{ .HitRate }}")
+		tab.AddColumn("Evictions", "{{ .Evictions }}")
+	}
 
 	dirs, err := cache.All(cachedir)
 	if err != nil {
@@ -150,16 +169,28 @@ func runCache(opts CacheOptions, gopts global.Options, args []string, term ui.Te
 			size = fmt.Sprintf("%11s", ui.FormatBytes(uint64(bytes)))
 		}
 
+		var stats cache.Stats
+		if opts.Stats {
+			stats, err = cache.LoadStats(filepath.Join(cachedir, entry.Name()))
+			if err != nil {
+				return err
+			}
+		}
+
 		name := entry.Name()
 		if !strings.HasPrefix(name, "restic-check-cache-") {
 			name = name[:10]
 		}
 
 		tab.AddRow(data{
-			name,
-			fmt.Sprintf("%d days ago", uint(time.Since(entry.ModTime()).Hours()/24)),
-			old,
-			size,
+			ID:        name,
+			Last:      fmt.Sprintf("%d days ago", uint(time.Since(entry.ModTime()).Hours()/24)),
+			Old:       old,
+			Size:      size,
+			Hits:      stats.Hits,
+			Misses:    stats.Misses,
+			HitRate:   fmt.Sprintf("%.1f%%", stats.HitRate()),
+			Evictions: stats.Evictions,
 		})
 	}
 
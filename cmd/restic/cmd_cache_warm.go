@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"path"
+
+	"github.com/restic/restic/internal/data"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/global"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui"
+	"github.com/restic/restic/internal/ui/progress"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func newCacheWarmCommand(globalOptions *global.Options) *cobra.Command {
+	var opts CacheWarmOptions
+
+	cmd := &cobra.Command{
+		Use:   "warm [flags] snapshotID [path]",
+		Short: "Download the directory metadata of a snapshot into the local cache",
+		Long: `
+The "cache warm" command loads all directories (tree blobs) of a snapshot, or of
+the given path within the snapshot, into the local cache. Afterwards, commands
+like "ls", "find", "mount" or "restore" for that snapshot do not have to
+download metadata from the repository, which is slow for object storage with a
+high latency or for cold storage.
+
+With "--data", the backend is additionally asked to warm up the pack files
+which contain the file contents. This is only supported by backends for cold
+storage, for example S3 with the "s3.enable-restore" option, and only starts
+the warmup without waiting for it to finish. The file contents are not stored
+in the local cache.
+
+The special snapshotID "latest" can be used to refer to the latest snapshot in
+the repository.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful.
+Exit status is 1 if there was any error.
+Exit status is 10 if the repository does not exist.
+Exit status is 11 if the repository is already locked.
+Exit status is 12 if the password is incorrect.
+`,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			finalizeSnapshotFilter(&opts.SnapshotFilter)
+			return runCacheWarm(cmd.Context(), opts, *globalOptions, args, globalOptions.Term)
+		},
+	}
+
+	opts.AddFlags(cmd.Flags())
+	return cmd
+}
+
+// CacheWarmOptions collects all options for the cache warm command.
+type CacheWarmOptions struct {
+	data.SnapshotFilter
+	Data bool
+}
+
+func (opts *CacheWarmOptions) AddFlags(f *pflag.FlagSet) {
+	initSingleSnapshotFilter(f, &opts.SnapshotFilter)
+	f.BoolVar(&opts.Data, "data", false, "also start the warmup of the pack files containing the file contents for cold storage backends")
+}
+
+func runCacheWarm(ctx context.Context, opts CacheWarmOptions, gopts global.Options, args []string, term ui.Terminal) error {
+	printer := progress.NewTerminalPrinter(false, gopts.Verbosity, term)
+
+	if len(args) == 0 || len(args) > 2 {
+		return errors.Fatal("the cache warm command expects a snapshot ID and an optional path - please see `restic help cache warm` for usage and flags")
+	}
+	if gopts.NoCache {
+		return errors.Fatal("Refusing to do anything, the cache is disabled")
+	}
+
+	ctx, repo, unlock, err := openWithReadLock(ctx, gopts, gopts.NoLock, printer)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if repo.Cache() == nil {
+		return errors.Fatal("the cache is not available")
+	}
+
+	sn, subfolder, err := opts.SnapshotFilter.FindLatest(ctx, repo, repo, args[0])
+	if err != nil {
+		return errors.Fatalf("failed to find snapshot: %v", err)
+	}
+	if len(args) > 1 {
+		subfolder = path.Join(subfolder, args[1])
+	}
+
+	if err = repo.LoadIndex(ctx, printer); err != nil {
+		return err
+	}
+
+	// loading the path to the subfolder caches the tree blobs along the way
+	treeID, err := data.FindTreeDirectory(ctx, repo, sn.Tree, subfolder)
+	if err != nil {
+		return err
+	}
+
+	// loading a tree blob automatically stores the whole pack file in the cache
+	usedBlobs := repo.NewAssociatedBlobSet()
+	bar := printer.NewCounter("snapshot")
+	bar.SetMax(uint64(1))
+	err = data.FindUsedBlobs(ctx, repo, restic.IDs{*treeID}, usedBlobs, bar)
+	bar.Done()
+	if err != nil {
+		return err
+	}
+
+	treePacks := restic.NewIDSet()
+	dataPacks := restic.NewIDSet()
+	trees := 0
+	for bh := range usedBlobs.Keys() {
+		packs := treePacks
+		if bh.Type == restic.TreeBlob {
+			trees++
+		} else {
+			packs = dataPacks
+		}
+		for _, blob := range repo.LookupBlob(bh) {
+			packs.Insert(blob.PackID())
+		}
+	}
+
+	printer.P("loaded %d trees from %d pack files into the cache", trees, len(treePacks))
+
+	if opts.Data {
+		job, err := repo.StartWarmup(ctx, dataPacks)
+		if err != nil {
+			return errors.Fatalf("warmup of data pack files failed: %v", err)
+		}
+		printer.P("started warmup of %d of %d data pack files", job.HandleCount(), len(dataPacks))
+	}
+
+	return nil
+}
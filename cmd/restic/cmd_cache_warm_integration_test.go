@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/restic/restic/internal/global"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func testRunCacheWarm(t testing.TB, gopts global.Options, args ...string) {
+	rtest.OK(t, withTermStatus(t, gopts, func(ctx context.Context, gopts global.Options) error {
+		return runCacheWarm(ctx, CacheWarmOptions{}, gopts, args, gopts.Term)
+	}))
+}
+
+func countCachedPacks(t testing.TB, cacheDir string) int {
+	count := 0
+	err := filepath.Walk(cacheDir, func(name string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !fi.IsDir() && filepath.Base(filepath.Dir(filepath.Dir(name))) == "data" {
+			count++
+		}
+		return nil
+	})
+	rtest.OK(t, err)
+	return count
+}
+
+func TestCacheWarm(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	testRunBackup(t, env.testdata+"/0", []string{"."}, BackupOptions{}, env.gopts)
+	cached := countCachedPacks(t, env.cache)
+	rtest.Assert(t, cached > 0, "expected tree packs in the cache after backup")
+
+	dirs, err := os.ReadDir(env.cache)
+	rtest.OK(t, err)
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		rtest.OK(t, os.RemoveAll(filepath.Join(env.cache, dir.Name(), "data")))
+	}
+	rtest.Equals(t, 0, countCachedPacks(t, env.cache))
+
+	testRunCacheWarm(t, env.gopts, "latest", "/0/9")
+	partial := countCachedPacks(t, env.cache)
+	rtest.Assert(t, partial > 0, "expected tree packs in the cache after warming a path")
+
+	testRunCacheWarm(t, env.gopts, "latest")
+	rtest.Equals(t, cached, countCachedPacks(t, env.cache))
+}
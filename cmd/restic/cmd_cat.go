@@ -16,15 +16,18 @@ import (
 	"github.com/restic/restic/internal/ui/progress"
 )
 
-var catAllowedCmds = []string{"config", "index", "snapshot", "key", "masterkey", "lock", "pack", "blob", "tree"}
+var catAllowedCmds = []string{"config", "index", "snapshot", "key", "masterkey", "lock", "pack", "blob", "tree", "capabilities"}
 
 func newCatCommand(globalOptions *global.Options) *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "cat [flags] [masterkey|config|pack ID|blob ID|snapshot ID|index ID|key ID|lock ID|tree snapshot:subfolder]",
+		Use:   "cat [flags] [masterkey|config|capabilities|pack ID|blob ID|snapshot ID|index ID|key ID|lock ID|tree snapshot:subfolder]",
 		Short: "Print internal objects to stdout",
 		Long: `
 The "cat" command is used to print internal objects to stdout.
 
+"restic cat capabilities" prints which operations the backend credentials
+permit. Only some backends, currently B2, support reporting them.
+
 EXIT STATUS
 ===========
 
@@ -60,7 +63,7 @@ func validateCatArgs(args []string) error {
 		return errors.Fatalf("invalid type %q, must be one of [%s]", args[0], strings.Join(catAllowedCmds, "|"))
 	}
 
-	if args[0] != "masterkey" && args[0] != "config" && len(args) != 2 {
+	if args[0] != "masterkey" && args[0] != "config" && args[0] != "capabilities" && len(args) != 2 {
 		return errors.Fatal("ID not specified")
 	}
 
@@ -83,7 +86,7 @@ func runCat(ctx context.Context, gopts global.Options, args []string, term ui.Te
 	tpe := args[0]
 
 	var id restic.ID
-	if tpe != "masterkey" && tpe != "config" && tpe != "snapshot" && tpe != "tree" {
+	if tpe != "masterkey" && tpe != "config" && tpe != "capabilities" && tpe != "snapshot" && tpe != "tree" {
 		id, err = restic.ParseID(args[1])
 		if err != nil {
 			return errors.Fatalf("unable to parse ID: %v", err)
@@ -97,6 +100,19 @@ func runCat(ctx context.Context, gopts global.Options, args []string, term ui.Te
 			return err
 		}
 
+		printer.S(string(buf))
+		return nil
+	case "capabilities":
+		caps, ok := repo.BackendCapabilities()
+		if !ok {
+			return errors.Fatal("the backend does not report the capabilities of its credentials")
+		}
+
+		buf, err := json.MarshalIndent(caps, "", "  ")
+		if err != nil {
+			return err
+		}
+
 		printer.S(string(buf))
 		return nil
 	case "index":
@@ -14,6 +14,7 @@ func TestCatArgsValidation(t *testing.T) {
 	}{
 		{[]string{}, "Fatal: type not specified"},
 		{[]string{"masterkey"}, ""},
+		{[]string{"capabilities"}, ""},
 		{[]string{"invalid"}, `Fatal: invalid type "invalid"`},
 		{[]string{"snapshot"}, "Fatal: ID not specified"},
 		{[]string{"snapshot", "12345678"}, ""},
@@ -2,9 +2,11 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
@@ -16,9 +18,11 @@ import (
 	"github.com/restic/restic/internal/backend/cache"
 	"github.com/restic/restic/internal/checker"
 	"github.com/restic/restic/internal/data"
+	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/global"
 	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/repository/pack"
 	"github.com/restic/restic/internal/restic"
 	"github.com/restic/restic/internal/ui"
 	"github.com/restic/restic/internal/ui/progress"
@@ -40,6 +44,18 @@ backed-up data, use the --read-data or --read-data-subset flags.
 By default, check creates a new temporary cache directory to verify data.
 To reuse the existing cache, use the --with-cache flag.
 
+Whenever data is read, check records when each pack file was last verified
+successfully. The state is stored in the cache directory, or in the file given
+by --read-data-state. With --read-data-stale, only pack files which were not
+verified within the given duration are read. This can be combined with
+--read-data-subset to spread the verification of all data across several runs.
+
+Pack files which contain errors are downloaded again up to --retry-damaged-packs
+times to rule out transient errors. With --quarantine, pack files which are
+still damaged afterwards are moved to the "quarantine" directory of the
+repository. Use --damaged-packs-report to write a JSON report of the damaged
+pack files, which can be passed to "restic repair packs --report".
+
 EXIT STATUS
 ===========
 
@@ -60,6 +76,9 @@ Exit status is 12 if the password is incorrect.
 				}
 				globalOptions.Term.Print(ui.ToJSONString(summary))
 			}
+			if summary.timer != nil {
+				printTiming(*globalOptions, summary.timer.Timing())
+			}
 			return err
 		},
 		PreRunE: func(_ *cobra.Command, _ []string) error {
@@ -75,6 +94,11 @@ Exit status is 12 if the password is incorrect.
 type CheckOptions struct {
 	ReadData       bool
 	ReadDataSubset string
+	ReadDataStale  data.Duration
+	ReadDataState  string
+	PackRetries    uint
+	Quarantine     bool
+	Report         string
 	CheckUnused    bool
 	WithCache      bool
 	data.SnapshotFilter
@@ -83,6 +107,11 @@ type CheckOptions struct {
 func (opts *CheckOptions) AddFlags(f *pflag.FlagSet) {
 	f.BoolVar(&opts.ReadData, "read-data", false, "read all data blobs")
 	f.StringVar(&opts.ReadDataSubset, "read-data-subset", "", "read a `subset` of data packs, specified as 'n/t' for specific part, or either 'x%' or 'x.y%' or a size in bytes with suffixes k/K, m/M, g/G, t/T for a random subset")
+	f.Var(&opts.ReadDataStale, "read-data-stale", "read data packs which were not verified within `duration` (eg. 1y5m7d2h)")
+	f.StringVar(&opts.ReadDataState, "read-data-state", "", "`file` to record when data packs were last verified (default: in the cache directory)")
+	f.UintVar(&opts.PackRetries, "retry-damaged-packs", 1, "download damaged pack files up to `n` more times to detect transient errors")
+	f.BoolVar(&opts.Quarantine, "quarantine", false, "move pack files which are confirmed to be damaged to the quarantine directory")
+	f.StringVar(&opts.Report, "damaged-packs-report", "", "write a JSON report of the damaged pack files to `file`")
 	var ignored bool
 	f.BoolVar(&ignored, "check-unused", false, "find unused blobs")
 	err := f.MarkDeprecated("check-unused", "`--check-unused` is deprecated and will be ignored")
@@ -98,6 +127,12 @@ func checkFlags(opts CheckOptions) error {
 	if opts.ReadData && opts.ReadDataSubset != "" {
 		return errors.Fatal("check flags --read-data and --read-data-subset cannot be used together")
 	}
+	if opts.ReadData && !opts.ReadDataStale.Zero() {
+		return errors.Fatal("check flags --read-data and --read-data-stale cannot be used together")
+	}
+	if opts.Quarantine && !opts.ReadData && opts.ReadDataSubset == "" && opts.ReadDataStale.Zero() {
+		return errors.Fatal("check flag --quarantine requires --read-data, --read-data-subset or --read-data-stale")
+	}
 	if opts.ReadDataSubset != "" {
 		dataSubset, err := stringToIntSlice(opts.ReadDataSubset)
 		argumentError := errors.Fatal("check flag --read-data-subset has invalid value, please see documentation")
@@ -226,8 +261,12 @@ func prepareCheckCache(opts CheckOptions, gopts *global.Options, printer restic.
 	return cleanup
 }
 
+// errCheckFoundErrors is returned by runCheck if the repository is damaged.
+var errCheckFoundErrors = errors.Fatal("repository contains errors")
+
 func runCheck(ctx context.Context, opts CheckOptions, gopts global.Options, args []string, term ui.Terminal) (checkSummary, error) {
 	summary := checkSummary{MessageType: "summary"}
+	var err error
 
 	var printer restic.Printer
 	if !gopts.JSON {
@@ -235,6 +274,17 @@ func runCheck(ctx context.Context, opts CheckOptions, gopts global.Options, args
 	} else {
 		printer = newJSONErrorPrinter(term)
 	}
+	summary.timer = progress.NewPhaseTimer()
+	printer = summary.timer.Printer(printer)
+
+	// the cache directory is replaced by a temporary directory below
+	stateDir := gopts.CacheDir
+	if stateDir == "" && !gopts.NoCache {
+		stateDir, err = cache.DefaultDir()
+		if err != nil {
+			debug.Log("unable to determine cache directory: %v", err)
+		}
+	}
 
 	cleanup := prepareCheckCache(opts, &gopts, printer)
 	defer cleanup()
@@ -248,6 +298,14 @@ func runCheck(ctx context.Context, opts CheckOptions, gopts global.Options, args
 	}
 	defer unlock()
 
+	stateFile := opts.ReadDataState
+	if stateFile == "" && stateDir != "" {
+		stateFile = filepath.Join(stateDir, repo.Config().ID, "check-state.json")
+	}
+	if stateFile == "" && !opts.ReadDataStale.Zero() {
+		return summary, errors.Fatal("--read-data-stale requires a cache directory or --read-data-state")
+	}
+
 	chkr := checker.New(repo, opts.CheckUnused)
 	err = chkr.LoadSnapshots(ctx, &opts.SnapshotFilter, args)
 	if err != nil {
@@ -262,12 +320,14 @@ func runCheck(ctx context.Context, opts CheckOptions, gopts global.Options, args
 
 	errorsFound := false
 	salvagePacks := restic.NewIDSet()
+	var report damagedPacksReport
 
 	for _, hint := range hints {
 		switch hint := hint.(type) {
 		case *repository.ErrIncompletePackEntry:
 			printer.E("%s", hint.Error())
 			salvagePacks.Insert(hint.PackID)
+			report.add(hint.PackID, hint)
 			errorsFound = true
 			summary.NumErrors++
 		case *repository.ErrDuplicatePacks:
@@ -297,7 +357,7 @@ func runCheck(ctx context.Context, opts CheckOptions, gopts global.Options, args
 		summary.NumErrors += len(errs)
 		summary.HintRepairIndex = true
 		printer.E("\nThe repository index is damaged and must be repaired. You must run `restic repair index' to correct this.\n\n")
-		return summary, errors.Fatal("repository contains errors")
+		return summary, errCheckFoundErrors
 	}
 
 	orphanedPacks := 0
@@ -339,7 +399,6 @@ func runCheck(ctx context.Context, opts CheckOptions, gopts global.Options, args
 
 	printer.P("check snapshots, trees and blobs\n")
 	errChan = make(chan error)
-	var brokenSnapshots []string
 	var wg sync.WaitGroup
 
 	wg.Add(1)
@@ -361,7 +420,7 @@ func runCheck(ctx context.Context, opts CheckOptions, gopts global.Options, args
 			}
 		case *checker.SnapshotError:
 			printer.E("snapshot error %v: %v", e.ID, e.Message)
-			brokenSnapshots = append(brokenSnapshots, e.ID)
+			summary.BrokenSnapshots = append(summary.BrokenSnapshots, e.ID)
 		default:
 			summary.NumErrors++
 			printer.E("error: %v\n", err)
@@ -393,8 +452,25 @@ func runCheck(ctx context.Context, opts CheckOptions, gopts global.Options, args
 		return summary, err
 	}
 
+	var state *checker.VerificationState
+	if stateFile != "" && (readDataFilter != nil || !opts.ReadDataStale.Zero()) {
+		state, err = checker.LoadVerificationState(stateFile)
+		if err != nil {
+			return summary, errors.Fatalf("unable to load verification state: %v", err)
+		}
+		readDataFilter = staleFilter(opts, state, printer, readDataFilter)
+
+		start := time.Now()
+		chkr.PackChecked = func(id restic.ID) {
+			state.Verified(id, start)
+		}
+	}
+
+	reconstructable := 0
 	if readDataFilter != nil {
 		errChan := make(chan error)
+		chkr.PackRetries = int(opts.PackRetries)
+		chkr.Quarantine = opts.Quarantine
 
 		go chkr.ReadPacks(ctx, readDataFilter, printer, errChan)
 
@@ -404,6 +480,16 @@ func runCheck(ctx context.Context, opts CheckOptions, gopts global.Options, args
 			printer.E("%v\n", err)
 			if err, ok := err.(*repository.ErrPackData); ok {
 				salvagePacks.Insert(err.PackID)
+				report.add(err.PackID, err)
+				if err.Reconstructable {
+					reconstructable++
+				}
+			}
+		}
+
+		if state != nil {
+			if err := saveVerificationState(ctx, repo, state, stateFile); err != nil {
+				printer.E("unable to save verification state: %v\n", err)
 			}
 		}
 	}
@@ -414,12 +500,21 @@ func runCheck(ctx context.Context, opts CheckOptions, gopts global.Options, args
 			summary.BrokenPacks = append(summary.BrokenPacks, id.String())
 		}
 		printer.E("restic repair packs %v\nrestic repair snapshots --forget\n\n", strings.Join(summary.BrokenPacks, " "))
+		if reconstructable > 0 {
+			printer.E("%d of the damaged pack files can be reconstructed using their parity section. \"restic repair packs\" reconstructs their blobs, \"restic repair snapshots\" is not necessary if all blobs could be healed.\n\n", reconstructable)
+		}
 		printer.E("Damaged pack files can be caused by backend problems, hardware problems or bugs in restic. Please open an issue at https://github.com/restic/restic/issues/new/choose for further troubleshooting!\n")
 	}
 
-	if len(brokenSnapshots) > 0 {
+	if opts.Report != "" {
+		if err := report.save(opts.Report); err != nil {
+			printer.E("unable to save damaged pack files report: %v\n", err)
+		}
+	}
+
+	if len(summary.BrokenSnapshots) > 0 {
 		printer.E("\nThe repository contains damaged snapshot files. These damaged files must be removed to repair the repository. This can be done using the following commands. Please read the troubleshooting guide at https://restic.readthedocs.io/en/stable/077_troubleshooting.html first.\n\n")
-		printer.E("restic repair snapshots --forget %s\n\n", strings.Join(brokenSnapshots, " "))
+		printer.E("restic repair snapshots --forget %s\n\n", strings.Join(summary.BrokenSnapshots, " "))
 		printer.E("Damaged snapshot files can be caused by backend problems, hardware problems or bugs in restic. Please open an issue at https://github.com/restic/restic/issues/new/choose for further troubleshooting!\n")
 	}
 
@@ -428,10 +523,10 @@ func runCheck(ctx context.Context, opts CheckOptions, gopts global.Options, args
 	}
 
 	if errorsFound {
-		if len(salvagePacks) == 0 && len(brokenSnapshots) == 0 {
+		if len(salvagePacks) == 0 && len(summary.BrokenSnapshots) == 0 {
 			printer.E("\nThe repository is damaged and must be repaired. Please follow the troubleshooting guide at https://restic.readthedocs.io/en/stable/077_troubleshooting.html .\n\n")
 		}
-		return summary, errors.Fatal("repository contains errors")
+		return summary, errCheckFoundErrors
 	}
 	printer.P("no errors were found\n")
 	return summary, nil
@@ -495,6 +590,36 @@ func buildPacksFilter(opts CheckOptions, printer restic.Printer,
 	return nil, nil
 }
 
+// staleFilter returns a filter which only selects packs that were not verified
+// within opts.ReadDataStale before applying filter. Without --read-data-stale,
+// filter is returned unchanged.
+func staleFilter(opts CheckOptions, state *checker.VerificationState, printer restic.Printer,
+	filter func(packs map[restic.ID]int64) map[restic.ID]int64) func(packs map[restic.ID]int64) map[restic.ID]int64 {
+	if opts.ReadDataStale.Zero() {
+		return filter
+	}
+
+	d := opts.ReadDataStale
+	cutoff := time.Now().AddDate(-d.Years, -d.Months, -d.Days).Add(time.Hour * time.Duration(-d.Hours))
+	return func(packs map[restic.ID]int64) map[restic.ID]int64 {
+		stale := state.Stale(packs, cutoff)
+		printer.P("%d of %d packs were not verified within %v", len(stale), len(packs), d)
+		if filter == nil {
+			return stale
+		}
+		return filter(stale)
+	}
+}
+
+// saveVerificationState stores state, dropping packs which no longer exist.
+func saveVerificationState(ctx context.Context, repo restic.ListBlobser, state *checker.VerificationState, filename string) error {
+	packs, err := pack.Size(ctx, repo, false)
+	if err != nil {
+		return err
+	}
+	return state.Save(filename, packs)
+}
+
 // selectPacksByBucket selects subsets of packs by ranges of buckets.
 func selectPacksByBucket(allPacks map[restic.ID]int64, bucket, totalBuckets uint) map[restic.ID]int64 {
 	packs := make(map[restic.ID]int64)
@@ -542,9 +667,59 @@ func selectRandomPacksByFileSize(allPacks map[restic.ID]int64, subsetSize int64,
 type checkSummary struct {
 	MessageType     string   `json:"message_type"` // "summary"
 	NumErrors       int      `json:"num_errors"`
-	BrokenPacks     []string `json:"broken_packs"`         // run "restic repair packs ID..." and "restic repair snapshots --forget" to remove damaged files
-	HintRepairIndex bool     `json:"suggest_repair_index"` // run "restic repair index"
-	HintPrune       bool     `json:"suggest_prune"`        // run "restic prune"
+	BrokenPacks     []string `json:"broken_packs"`               // run "restic repair packs ID..." and "restic repair snapshots --forget" to remove damaged files
+	BrokenSnapshots []string `json:"broken_snapshots,omitempty"` // run "restic repair snapshots --forget ID..." to remove unreadable snapshot files
+	HintRepairIndex bool     `json:"suggest_repair_index"`       // run "restic repair index"
+	HintPrune       bool     `json:"suggest_prune"`              // run "restic prune"
+
+	// timer records the duration of the phases of the check
+	timer *progress.PhaseTimer
+}
+
+// damagedPacksReport lists the damaged pack files found by check. It is written
+// by --damaged-packs-report and can be passed to "repair packs --report".
+type damagedPacksReport struct {
+	Packs []damagedPack `json:"packs"`
+}
+
+type damagedPack struct {
+	ID          restic.ID `json:"id"`
+	Attempts    int       `json:"attempts,omitempty"`
+	Quarantined bool      `json:"quarantined"`
+	Error       string    `json:"error"`
+}
+
+func (r *damagedPacksReport) add(id restic.ID, err error) {
+	p := damagedPack{ID: id, Error: err.Error()}
+	if perr, ok := err.(*repository.ErrPackData); ok {
+		p.Attempts = perr.Attempts
+		p.Quarantined = perr.Quarantined
+	}
+	r.Packs = append(r.Packs, p)
+}
+
+func (r *damagedPacksReport) save(filename string) error {
+	if r.Packs == nil {
+		r.Packs = []damagedPack{}
+	}
+	buf, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, append(buf, '\n'), 0o600)
+}
+
+// loadDamagedPacksReport reads a report written by check --damaged-packs-report.
+func loadDamagedPacksReport(filename string) (*damagedPacksReport, error) {
+	buf, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var report damagedPacksReport
+	if err := json.Unmarshal(buf, &report); err != nil {
+		return nil, errors.Fatalf("invalid report %v: %v", filename, err)
+	}
+	return &report, nil
 }
 
 type checkError struct {
@@ -2,9 +2,12 @@ package main
 
 import (
 	"context"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/restic/restic/internal/data"
 	"github.com/restic/restic/internal/global"
 	rtest "github.com/restic/restic/internal/test"
 )
@@ -100,3 +103,59 @@ func TestCheckWithSnaphotFilter(t *testing.T) {
 		rtest.Assert(t, hasOutput, `expected to find substring %q, but did not find it`, testCase.expectedOutput)
 	}
 }
+
+func TestCheckReadDataStale(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	testRunBackup(t, env.testdata+"/0", []string{"for_cmd_ls"}, BackupOptions{}, env.gopts)
+
+	stale := CheckOptions{ReadDataStale: data.ParseDurationOrPanic("1d")}
+	output, err := testRunCheckOutputWithOpts(t, env.gopts, stale, nil)
+	rtest.OK(t, err)
+	rtest.Assert(t, strings.Contains(output, "2 of 2 packs were not verified"), "unexpected output %q", output)
+
+	// all packs were verified by the previous run
+	output, err = testRunCheckOutputWithOpts(t, env.gopts, stale, nil)
+	rtest.OK(t, err)
+	rtest.Assert(t, strings.Contains(output, "0 of 2 packs were not verified"), "unexpected output %q", output)
+
+	// new packs must be verified
+	testRunBackup(t, env.testdata+"/0", []string{"0/9"}, BackupOptions{}, env.gopts)
+	output, err = testRunCheckOutputWithOpts(t, env.gopts, stale, nil)
+	rtest.OK(t, err)
+	rtest.Assert(t, strings.Contains(output, "2 of 4 packs were not verified"), "unexpected output %q", output)
+
+	// a separate state file does not know about the previous runs
+	stale.ReadDataState = filepath.Join(env.base, "check-state.json")
+	output, err = testRunCheckOutputWithOpts(t, env.gopts, stale, nil)
+	rtest.OK(t, err)
+	rtest.Assert(t, strings.Contains(output, "4 of 4 packs were not verified"), "unexpected output %q", output)
+	_, err = os.Stat(stale.ReadDataState)
+	rtest.OK(t, err)
+}
+
+func TestCheckParity(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	env.gopts.Extended["repo.parity"] = "2/10"
+	testSetupBackupData(t, env)
+	opts := BackupOptions{}
+	testRunBackup(t, env.testdata+"/0", []string{"for_cmd_ls"}, opts, env.gopts)
+	testRunBackup(t, env.testdata+"/0", []string{"0/9"}, opts, env.gopts)
+
+	output, err := testRunCheckOutputWithOpts(t, env.gopts, CheckOptions{ReadData: true}, nil)
+	rtest.OK(t, err)
+	rtest.Assert(t, strings.Contains(output, "no errors were found"), "unexpected check output: %v", output)
+
+	// repacking must account for the parity sections
+	testRunForget(t, env.gopts, ForgetOptions{Last: 1})
+	testRunPrune(t, env.gopts, PruneOptions{MaxUnused: "0"})
+
+	// the pack files must also be readable without setting the option
+	delete(env.gopts.Extended, "repo.parity")
+	_, err = testRunCheckOutputWithOpts(t, env.gopts, CheckOptions{ReadData: true}, nil)
+	rtest.OK(t, err)
+}
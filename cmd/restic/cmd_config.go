@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/restic/restic/internal/data"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/global"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/ui"
+	"github.com/restic/restic/internal/ui/progress"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func newConfigCommand(globalOptions *global.Options) *cobra.Command {
+	var opts ConfigOptions
+
+	cmd := &cobra.Command{
+		Use:   "config [flags]",
+		Short: "Show or change repository settings",
+		Long: `
+The "config" command shows the settings stored in the repository config. When
+called with "--max-repo-size", the size budget of the repository is changed.
+
+The budget is meant for storage with a fixed capacity. The "backup" command
+refuses to start if the repository is already larger than the budget, unless
+"--ignore-max-repo-size" is given, and warns if a backup exceeds it. The
+"prune" command repacks as much unused data as necessary to shrink the
+repository down to the budget. Pass "--max-repo-size unlimited" to remove the
+budget.
+
+Older restic versions ignore the budget.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful.
+Exit status is 1 if there was any error.
+Exit status is 10 if the repository does not exist.
+Exit status is 11 if the repository is already locked.
+Exit status is 12 if the password is incorrect.
+`,
+		GroupID:           cmdGroupAdvanced,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.setMaxRepoSize = cmd.Flags().Changed("max-repo-size")
+			return runConfig(cmd.Context(), opts, *globalOptions, args, globalOptions.Term)
+		},
+	}
+
+	opts.AddFlags(cmd.Flags())
+	return cmd
+}
+
+// ConfigOptions collects all options for the config command.
+type ConfigOptions struct {
+	MaxRepoSize    string
+	setMaxRepoSize bool
+}
+
+func (opts *ConfigOptions) AddFlags(f *pflag.FlagSet) {
+	f.StringVar(&opts.MaxRepoSize, "max-repo-size", "", "set the size budget of the repository to `size` (allowed suffixes: k/K, m/M, g/G, t/T, or 'unlimited')")
+}
+
+// parseMaxRepoSize parses the value of --max-repo-size, "unlimited" and zero
+// remove the budget.
+func parseMaxRepoSize(s string) (uint64, error) {
+	if s == "unlimited" {
+		return 0, nil
+	}
+	size, err := ui.ParseBytes(s)
+	if err != nil {
+		return 0, errors.Fatalf("invalid value %q for --max-repo-size: %v", s, err)
+	}
+	if size < 0 {
+		return 0, errors.Fatalf("invalid value %q for --max-repo-size: size must not be negative", s)
+	}
+	return uint64(size), nil
+}
+
+type configInfo struct {
+	RepositoryID string `json:"repository_id"`
+	Version      uint   `json:"version"`
+	MaxRepoSize  uint64 `json:"max_repo_size"`
+	RepoSize     uint64 `json:"repo_size"`
+}
+
+func runConfig(ctx context.Context, opts ConfigOptions, gopts global.Options, args []string, term ui.Terminal) error {
+	if len(args) > 0 {
+		return errors.Fatal("the config command expects no arguments, only options - please see `restic help config` for usage and flags")
+	}
+
+	printer := progress.NewTerminalPrinter(gopts.JSON, gopts.Verbosity, term)
+
+	if opts.setMaxRepoSize {
+		size, err := parseMaxRepoSize(opts.MaxRepoSize)
+		if err != nil {
+			return err
+		}
+
+		ctx, repo, unlock, err := openWithExclusiveLock(ctx, gopts, false, printer)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		if err := repo.SetMaxRepoSize(ctx, size); err != nil {
+			return errors.Fatalf("unable to change the repository config: %v", err)
+		}
+		printer.P("set max-repo-size to %v", formatMaxRepoSize(size))
+
+		entry := data.NewAuditLogEntry("config")
+		entry.Message = fmt.Sprintf("set max-repo-size to %v", formatMaxRepoSize(size))
+		return recordAuditLog(ctx, repo, entry)
+	}
+
+	ctx, repo, unlock, err := openWithReadLock(ctx, gopts, gopts.NoLock, printer)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	cfg := repo.Config()
+	size, err := repo.Size(ctx)
+	if err != nil {
+		return err
+	}
+
+	if gopts.JSON {
+		return json.NewEncoder(gopts.Term.OutputWriter()).Encode(configInfo{
+			RepositoryID: cfg.ID,
+			Version:      cfg.Version,
+			MaxRepoSize:  cfg.MaxRepoSize,
+			RepoSize:     size,
+		})
+	}
+
+	printer.S("repository id:  %v", cfg.ID)
+	printer.S("version:        %v", cfg.Version)
+	printer.S("max-repo-size:  %v", formatMaxRepoSize(cfg.MaxRepoSize))
+	if cfg.MaxRepoSize > 0 {
+		printer.S("current size:   %v (%v of budget)", ui.FormatBytes(size), ui.FormatPercent(size, cfg.MaxRepoSize))
+	} else {
+		printer.S("current size:   %v", ui.FormatBytes(size))
+	}
+	return nil
+}
+
+func formatMaxRepoSize(size uint64) string {
+	if size == 0 {
+		return "unlimited"
+	}
+	return ui.FormatBytes(size)
+}
+
+// checkRepoSize returns an error if the repository is larger than its
+// configured budget. If onlyWarn is set, a warning is printed instead. It
+// returns the current size of the repository, or zero if no budget is set.
+func checkRepoSize(ctx context.Context, repo *repository.Repository, onlyWarn bool, printError func(string, ...interface{})) (uint64, error) {
+	maxSize := repo.Config().MaxRepoSize
+	if maxSize == 0 {
+		return 0, nil
+	}
+
+	size, err := repo.Size(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if size > maxSize {
+		msg := fmt.Sprintf("repository size %v exceeds max-repo-size of %v", ui.FormatBytes(size), ui.FormatBytes(maxSize))
+		if !onlyWarn {
+			return 0, errors.Fatalf("%v, run `restic forget --prune` to free space or use --ignore-max-repo-size", msg)
+		}
+		printError("warning: %v", msg)
+	}
+	return size, nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/restic/restic/internal/global"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func testRunConfigSetMaxRepoSize(t testing.TB, gopts global.Options, size string) {
+	t.Helper()
+	rtest.OK(t, withTermStatus(t, gopts, func(ctx context.Context, gopts global.Options) error {
+		opts := ConfigOptions{MaxRepoSize: size, setMaxRepoSize: true}
+		return runConfig(ctx, opts, gopts, nil, gopts.Term)
+	}))
+}
+
+func testRunConfigJSON(t testing.TB, gopts global.Options) configInfo {
+	t.Helper()
+	buf, err := withCaptureStdout(t, gopts, func(ctx context.Context, gopts global.Options) error {
+		gopts.JSON = true
+		return runConfig(ctx, ConfigOptions{}, gopts, nil, gopts.Term)
+	})
+	rtest.OK(t, err)
+
+	var info configInfo
+	rtest.OK(t, json.Unmarshal(buf.Bytes(), &info))
+	return info
+}
+
+func TestConfigMaxRepoSize(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	target := []string{filepath.Join(env.testdata, "0", "0", "9")}
+	testRunBackup(t, "", target, BackupOptions{}, env.gopts)
+
+	info := testRunConfigJSON(t, env.gopts)
+	rtest.Equals(t, uint64(0), info.MaxRepoSize)
+	rtest.Assert(t, info.RepoSize > 0, "missing repository size")
+
+	testRunConfigSetMaxRepoSize(t, env.gopts, "1k")
+	rtest.Equals(t, uint64(1024), testRunConfigJSON(t, env.gopts).MaxRepoSize)
+
+	// the repository already exceeds the budget
+	err := testRunBackupAssumeFailure(t, "", target, BackupOptions{}, env.gopts)
+	rtest.Assert(t, err != nil, "expected backup to fail")
+	testListSnapshots(t, env.gopts, 1)
+	testRunBackup(t, "", target, BackupOptions{IgnoreMaxRepoSize: true}, env.gopts)
+	testListSnapshots(t, env.gopts, 2)
+
+	testRunConfigSetMaxRepoSize(t, env.gopts, "unlimited")
+	rtest.Equals(t, uint64(0), testRunConfigJSON(t, env.gopts).MaxRepoSize)
+	testRunBackup(t, "", target, BackupOptions{}, env.gopts)
+}
+
+func TestPruneMaxRepoSize(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+
+	// store both files in the same, large enough pack files
+	env.gopts.PackSize = 4
+	datadir := filepath.Join(env.base, "testdata")
+	rtest.OK(t, os.MkdirAll(datadir, 0o755))
+	keep := filepath.Join(datadir, "keep")
+	remove := filepath.Join(datadir, "remove")
+	rtest.OK(t, appendRandomData(keep, 3<<20))
+	rtest.OK(t, appendRandomData(remove, 3<<20))
+	testRunBackup(t, "", []string{keep, remove}, BackupOptions{}, env.gopts)
+	firstSnapshot := testListSnapshots(t, env.gopts, 1)[0]
+	testRunBackup(t, "", []string{keep}, BackupOptions{}, env.gopts)
+	testRunForget(t, env.gopts, ForgetOptions{}, firstSnapshot.String())
+
+	// the budget overrides the tolerated amount of unused data, thus the
+	// unused parts of pack files shared by both files are removed as well
+	testRunConfigSetMaxRepoSize(t, env.gopts, "1k")
+	testRunPrune(t, env.gopts, PruneOptions{MaxUnused: "unlimited"})
+	size := testRunConfigJSON(t, env.gopts).RepoSize
+	rtest.Assert(t, size < 3<<20+100<<10, "repository contains unused data, size %v", size)
+	testRunCheck(t, env.gopts)
+}
+
+func TestLimitUnusedToBudget(t *testing.T) {
+	maxUnused := func(_ uint64) uint64 { return 100 }
+	for _, test := range []struct {
+		maxRepoSize, used, unused uint64
+	}{
+		{1000, 500, 100},
+		{1000, 950, 50},
+		{1000, 1000, 0},
+		{1000, 2000, 0},
+	} {
+		rtest.Equals(t, test.unused, limitUnusedToBudget(maxUnused, test.maxRepoSize)(test.used))
+	}
+}
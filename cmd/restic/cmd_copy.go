@@ -40,6 +40,18 @@ repository, /may occupy up to twice their space/ in the destination repository.
 This can be mitigated by the "--copy-chunker-params" option when initializing a
 new destination repository using the "init" command.
 
+For an existing destination repository with different chunker parameters, the
+"--convert" option re-chunks all files using the parameters of the destination
+repository, such that the copied data deduplicates with future backups to the
+destination. This rewrites all directories and is much slower than a regular
+copy. Data is always compressed according to the settings of the destination
+repository.
+
+The "--bulk" option first determines the data missing in the destination for
+all selected snapshots and then copies it in a single pass. This speeds up
+copying many snapshots, but the snapshots are only saved once all data was
+copied and the list of missing data must fit into memory.
+
 EXIT STATUS
 ===========
 
@@ -65,10 +77,14 @@ Exit status is 12 if the password is incorrect.
 type CopyOptions struct {
 	global.SecondaryRepoOptions
 	data.SnapshotFilter
+	Bulk    bool
+	Convert bool
 }
 
 func (opts *CopyOptions) AddFlags(f *pflag.FlagSet) {
 	opts.SecondaryRepoOptions.AddFlags(f, "destination", "to copy snapshots from")
+	f.BoolVar(&opts.Bulk, "bulk", false, "determine the missing data for all snapshots upfront and copy it in a single pass")
+	f.BoolVar(&opts.Convert, "convert", false, "re-chunk files using the chunker parameters of the destination repository")
 	initMultiSnapshotFilter(f, &opts.SnapshotFilter, true)
 }
 
@@ -95,7 +111,8 @@ func collectAllSnapshots(ctx context.Context, opts CopyOptions,
 			if originalSns, ok := dstSnapshotByOriginal[srcOriginal]; ok {
 				isCopy := false
 				for _, originalSn := range originalSns {
-					if similarSnapshots(originalSn, sn) {
+					// converted copies have a different tree
+					if similarSnapshots(originalSn, sn, opts.Convert) {
 						printer.V("\n%v", sn)
 						printer.V("skipping source snapshot %s, was already copied to snapshot %s", sn.ID().Str(), originalSn.ID().Str())
 						isCopy = true
@@ -119,6 +136,10 @@ func collectAllSnapshots(ctx context.Context, opts CopyOptions,
 
 func runCopy(ctx context.Context, opts CopyOptions, gopts global.Options, args []string, term ui.Terminal) error {
 	printer := progress.NewTerminalPrinter(false, gopts.Verbosity, term)
+	if opts.Convert && opts.Bulk {
+		return errors.Fatal("--convert and --bulk cannot be used together")
+	}
+
 	secondaryGopts, isFromRepo, err := opts.SecondaryRepoOptions.FillGlobalOpts(ctx, gopts, "destination")
 	if err != nil {
 		return err
@@ -159,8 +180,39 @@ func runCopy(ctx context.Context, opts CopyOptions, gopts global.Options, args [
 		return err
 	}
 
+	dstSnapshotByOriginal, err := collectCopiedSnapshots(ctx, &opts.SnapshotFilter, dstSnapshotLister, dstRepo)
+	if err != nil {
+		return err
+	}
+
+	if opts.Convert && sameChunker(srcRepo, dstRepo) {
+		printer.P("source and destination repository use the same chunker parameters, copying without conversion")
+		opts.Convert = false
+	}
+
+	selectedSnapshots := collectAllSnapshots(ctx, opts, srcSnapshotLister, srcRepo, dstSnapshotByOriginal, args, printer)
+
+	if opts.Convert {
+		err = copyTreesConvert(ctx, srcRepo, dstRepo, selectedSnapshots, printer)
+	} else if opts.Bulk {
+		err = copyTreesBulk(ctx, srcRepo, dstRepo, selectedSnapshots, printer)
+	} else {
+		err = copyTreeBatched(ctx, srcRepo, dstRepo, selectedSnapshots, printer, nil)
+	}
+	if err != nil {
+		return err
+	}
+
+	return ctx.Err()
+}
+
+// collectCopiedSnapshots returns the snapshots of the destination repository
+// which match filter, indexed by the ID of their original snapshot.
+func collectCopiedSnapshots(ctx context.Context, filter *data.SnapshotFilter,
+	dstSnapshotLister restic.Lister, dstRepo restic.LoaderUnpacked) (map[restic.ID][]*data.Snapshot, error) {
+
 	dstSnapshotByOriginal := make(map[restic.ID][]*data.Snapshot)
-	err = opts.SnapshotFilter.FindAll(ctx, dstSnapshotLister, dstRepo, nil, func(_ string, sn *data.Snapshot, err error) error {
+	err := filter.FindAll(ctx, dstSnapshotLister, dstRepo, nil, func(_ string, sn *data.Snapshot, err error) error {
 		if err != nil {
 			return err
 		}
@@ -172,21 +224,17 @@ func runCopy(ctx context.Context, opts CopyOptions, gopts global.Options, args [
 		return nil
 	})
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	selectedSnapshots := collectAllSnapshots(ctx, opts, srcSnapshotLister, srcRepo, dstSnapshotByOriginal, args, printer)
-
-	if err := copyTreeBatched(ctx, srcRepo, dstRepo, selectedSnapshots, printer); err != nil {
-		return err
-	}
-
-	return ctx.Err()
+	return dstSnapshotByOriginal, nil
 }
 
-func similarSnapshots(sna *data.Snapshot, snb *data.Snapshot) bool {
+func similarSnapshots(sna *data.Snapshot, snb *data.Snapshot, ignoreTree bool) bool {
 	// everything except Parent and Original must match
-	if !sna.Time.Equal(snb.Time) || !sna.Tree.Equal(*snb.Tree) || sna.Hostname != snb.Hostname ||
+	if !ignoreTree && !sna.Tree.Equal(*snb.Tree) {
+		return false
+	}
+	if !sna.Time.Equal(snb.Time) || sna.Hostname != snb.Hostname ||
 		sna.Username != snb.Username || sna.UID != snb.UID || sna.GID != snb.GID ||
 		len(sna.Paths) != len(snb.Paths) || len(sna.Excludes) != len(snb.Excludes) ||
 		len(sna.Tags) != len(snb.Tags) {
@@ -204,9 +252,11 @@ func similarSnapshots(sna *data.Snapshot, snb *data.Snapshot) bool {
 }
 
 // copyTreeBatched copies multiple snapshots in one go. Snapshots are written after
-// data equivalent to at least 10 packfiles was written.
+// data equivalent to at least 10 packfiles was written. If saved is not nil, it is
+// called with the source snapshot and the ID of the new snapshot for every copy.
 func copyTreeBatched(ctx context.Context, srcRepo *repository.Repository, dstRepo restic.Repository,
-	selectedSnapshots iter.Seq2[*data.Snapshot, error], printer restic.Printer) error {
+	selectedSnapshots iter.Seq2[*data.Snapshot, error], printer restic.Printer,
+	saved func(sn *data.Snapshot, newID restic.ID)) error {
 
 	// remember already processed trees across all snapshots
 	visitedTrees := srcRepo.NewAssociatedBlobSet()
@@ -263,19 +313,90 @@ func copyTreeBatched(ctx context.Context, srcRepo *repository.Repository, dstRep
 		}
 		// save all the snapshots
 		for _, sn := range batch {
-			err := copySaveSnapshot(ctx, sn, dstRepo, printer)
+			newID, err := copySaveSnapshot(ctx, sn, dstRepo, printer)
 			if err != nil {
 				return err
 			}
+			if saved != nil {
+				saved(sn, newID)
+			}
 		}
 	}
 
 	return nil
 }
 
+// copyTreesBulk copies all selected snapshots at once. It first determines the
+// blobs missing in the destination for all snapshots and then copies the packs
+// containing them in a single pass. The snapshots are saved afterwards.
+func copyTreesBulk(ctx context.Context, srcRepo *repository.Repository, dstRepo restic.Repository,
+	selectedSnapshots iter.Seq2[*data.Snapshot, error], printer restic.Printer) error {
+
+	var snapshots []*data.Snapshot
+	var rootTrees restic.IDs
+	for sn, err := range selectedSnapshots {
+		if err != nil {
+			return err
+		}
+		printer.P("\n%v", sn)
+		snapshots = append(snapshots, sn)
+		rootTrees = append(rootTrees, *sn.Tree)
+	}
+	if len(snapshots) == 0 {
+		return nil
+	}
+
+	printer.P("\ndetermining data to copy for %d snapshots", len(snapshots))
+	bar := printer.NewCounter("snapshots")
+	bar.SetMax(uint64(len(rootTrees)))
+	copyBlobs, packList, err := findCopyBlobs(ctx, srcRepo, dstRepo, srcRepo.NewAssociatedBlobSet(), rootTrees, bar)
+	bar.Done()
+	if err != nil {
+		return err
+	}
+
+	copyStats(srcRepo, copyBlobs, packList, printer)
+	err = dstRepo.WithBlobUploader(ctx, func(ctx context.Context, uploader restic.BlobSaverWithAsync) error {
+		bar := printer.NewCounter("packs copied")
+		return repository.CopyBlobs(ctx, srcRepo, dstRepo, uploader, packList, copyBlobs, bar, printer.P)
+	})
+	if err != nil {
+		return errors.Fatalf("%s", err)
+	}
+
+	printer.P("")
+	for _, sn := range snapshots {
+		_, err := copySaveSnapshot(ctx, sn, dstRepo, printer)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func copyTree(ctx context.Context, srcRepo *repository.Repository, dstRepo restic.Repository,
 	visitedTrees restic.AssociatedBlobSet, rootTreeID restic.ID, printer restic.Printer, uploader restic.BlobSaverWithAsync) (uint64, error) {
 
+	copyBlobs, packList, err := findCopyBlobs(ctx, srcRepo, dstRepo, visitedTrees, restic.IDs{rootTreeID}, restic.NoopCounter)
+	if err != nil {
+		return 0, err
+	}
+
+	sizeBlobs := copyStats(srcRepo, copyBlobs, packList, printer)
+	bar := printer.NewCounter("packs copied")
+	err = repository.CopyBlobs(ctx, srcRepo, dstRepo, uploader, packList, copyBlobs, bar, printer.P)
+	if err != nil {
+		return 0, errors.Fatalf("%s", err)
+	}
+	return sizeBlobs, nil
+}
+
+// findCopyBlobs returns the blobs referenced by the given trees which are
+// missing in the destination along with the source packs containing them.
+// Trees contained in visitedTrees are skipped, newly visited trees are added.
+func findCopyBlobs(ctx context.Context, srcRepo *repository.Repository, dstRepo restic.Repository,
+	visitedTrees restic.AssociatedBlobSet, rootTreeIDs restic.IDs, bar restic.Counter) (restic.AssociatedBlobSet, restic.IDSet, error) {
+
 	copyBlobs := srcRepo.NewAssociatedBlobSet()
 	packList := restic.NewIDSet()
 	var lock sync.Mutex
@@ -283,7 +404,7 @@ func copyTree(ctx context.Context, srcRepo *repository.Repository, dstRepo resti
 	enqueue := func(h restic.BlobHandle) {
 		lock.Lock()
 		defer lock.Unlock()
-		if _, ok := dstRepo.LookupBlobSize(h); !ok {
+		if !dstRepo.HasBlob(h) {
 			pb := srcRepo.LookupBlob(h)
 			copyBlobs.Insert(h)
 			for _, p := range pb {
@@ -292,7 +413,7 @@ func copyTree(ctx context.Context, srcRepo *repository.Repository, dstRepo resti
 		}
 	}
 
-	err := data.StreamTrees(ctx, srcRepo, restic.IDs{rootTreeID}, restic.NoopCounter, func(treeID restic.ID) bool {
+	err := data.StreamTrees(ctx, srcRepo, rootTreeIDs, bar, func(treeID restic.ID) bool {
 		handle := restic.BlobHandle{ID: treeID, Type: restic.TreeBlob}
 		visited := visitedTrees.Has(handle)
 		visitedTrees.Insert(handle)
@@ -318,16 +439,9 @@ func copyTree(ctx context.Context, srcRepo *repository.Repository, dstRepo resti
 		return nil
 	})
 	if err != nil {
-		return 0, err
-	}
-
-	sizeBlobs := copyStats(srcRepo, copyBlobs, packList, printer)
-	bar := printer.NewCounter("packs copied")
-	err = repository.CopyBlobs(ctx, srcRepo, dstRepo, uploader, packList, copyBlobs, bar, printer.P)
-	if err != nil {
-		return 0, errors.Fatalf("%s", err)
+		return nil, nil, err
 	}
-	return sizeBlobs, nil
+	return copyBlobs, packList, nil
 }
 
 // copyStats: print statistics for the blobs to be copied
@@ -348,7 +462,7 @@ func copyStats(srcRepo restic.Repository, copyBlobs restic.AssociatedBlobSet, pa
 	return sizeBlobs
 }
 
-func copySaveSnapshot(ctx context.Context, sn *data.Snapshot, dstRepo restic.Repository, printer restic.Printer) error {
+func copySaveSnapshot(ctx context.Context, sn *data.Snapshot, dstRepo restic.Repository, printer restic.Printer) (restic.ID, error) {
 	sn.Parent = nil // Parent does not have relevance in the new repo.
 	// Use Original as a persistent snapshot ID
 	if sn.Original == nil {
@@ -356,8 +470,8 @@ func copySaveSnapshot(ctx context.Context, sn *data.Snapshot, dstRepo restic.Rep
 	}
 	newID, err := data.SaveSnapshot(ctx, dstRepo, sn)
 	if err != nil {
-		return err
+		return restic.ID{}, err
 	}
 	printer.P("snapshot %s saved, copied from source snapshot %s", newID.Str(), sn.ID().Str())
-	return nil
+	return newID, nil
 }
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"iter"
+
+	"github.com/restic/restic/internal/data"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/walker"
+)
+
+// sameChunker returns true if both repositories split files into the same chunks.
+func sameChunker(src, dst restic.Repository) bool {
+	return src.Config().ChunkerPolynomial == dst.Config().ChunkerPolynomial &&
+		src.Config().ChunkerParameters() == dst.Config().ChunkerParameters()
+}
+
+// contentConverter re-chunks file contents using the chunker of the
+// destination repository.
+type contentConverter struct {
+	src     restic.BlobLoader
+	chunker restic.Chunker
+
+	// converted caches the new content for the old content of a file, indexed
+	// by the hash of the old list of blob IDs
+	converted map[restic.ID]restic.IDs
+	buf       []byte
+	chunk     []byte
+}
+
+func newContentConverter(src restic.BlobLoader, dst restic.Repository) *contentConverter {
+	return &contentConverter{
+		src:       src,
+		chunker:   dst.ChunkerFactory().NewChunker(),
+		converted: make(map[restic.ID]restic.IDs),
+	}
+}
+
+// convert loads the blobs in content from the source repository, splits the
+// file data into chunks for the destination repository and saves them using
+// saver. It returns the list of the new blob IDs.
+func (c *contentConverter) convert(ctx context.Context, saver restic.BlobSaver, content restic.IDs) (restic.IDs, error) {
+	key := make([]byte, 0, len(content)*len(restic.ID{}))
+	for _, id := range content {
+		key = append(key, id[:]...)
+	}
+	contentID := restic.Hash(key)
+	if newContent, ok := c.converted[contentID]; ok {
+		return newContent, nil
+	}
+
+	newContent := restic.IDs{}
+	save := func() error {
+		id, _, _, err := saver.SaveBlob(ctx, restic.DataBlob, c.chunk, restic.ID{}, false)
+		if err != nil {
+			return err
+		}
+		newContent = append(newContent, id)
+		c.chunk = c.chunk[:0]
+		return nil
+	}
+
+	c.chunker.Reset()
+	c.chunk = c.chunk[:0]
+	for _, id := range content {
+		var err error
+		c.buf, err = c.src.LoadBlob(ctx, restic.BlobHandle{ID: id, Type: restic.DataBlob}, c.buf)
+		if err != nil {
+			return nil, err
+		}
+
+		buf := c.buf
+		for len(buf) > 0 {
+			split := c.chunker.NextSplitPoint(buf)
+			if split == -1 {
+				c.chunk = append(c.chunk, buf...)
+				break
+			}
+			c.chunk = append(c.chunk, buf[:split]...)
+			buf = buf[split:]
+			if err := save(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(c.chunk) > 0 {
+		if err := save(); err != nil {
+			return nil, err
+		}
+	}
+
+	c.converted[contentID] = newContent
+	return newContent, nil
+}
+
+// copyTreesConvert copies the selected snapshots while re-chunking all files
+// using the chunker parameters of the destination repository. As this changes
+// the IDs of the data blobs, all trees are rewritten as well.
+func copyTreesConvert(ctx context.Context, srcRepo restic.Repository, dstRepo restic.Repository,
+	selectedSnapshots iter.Seq2[*data.Snapshot, error], printer restic.Printer) error {
+
+	converter := newContentConverter(srcRepo, dstRepo)
+	var convertErr error
+	var uploadCtx context.Context
+	var uploader restic.BlobSaver
+
+	// the rewriter remembers converted trees across all snapshots
+	rewriter := walker.NewTreeRewriter(walker.RewriteOpts{
+		RewriteNode: func(node *data.Node, path string) *data.Node {
+			if node.Type != data.NodeTypeFile || convertErr != nil {
+				return node
+			}
+			content, err := converter.convert(uploadCtx, uploader, node.Content)
+			if err != nil {
+				convertErr = errors.Fatalf("converting %v failed: %v", path, err)
+				return node
+			}
+			node.Content = content
+			return node
+		},
+	})
+
+	for sn, err := range selectedSnapshots {
+		if err != nil {
+			return err
+		}
+
+		printer.P("\n%v", sn)
+		printer.P("  copy and convert started, this may take a while...")
+		var newTreeID restic.ID
+		err = dstRepo.WithBlobUploader(ctx, func(ctx context.Context, saver restic.BlobSaverWithAsync) error {
+			uploadCtx, uploader = ctx, saver
+			var err error
+			newTreeID, err = rewriter.RewriteTree(ctx, srcRepo, saver, "/", *sn.Tree)
+			if convertErr != nil {
+				return convertErr
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		sn.Tree = &newTreeID
+		if _, err := copySaveSnapshot(ctx, sn, dstRepo, printer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -2,8 +2,11 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/restic/restic/internal/global"
@@ -13,16 +16,18 @@ import (
 )
 
 func testRunCopy(t testing.TB, srcGopts global.Options, dstGopts global.Options) {
+	testRunCopyWithOpts(t, srcGopts, dstGopts, CopyOptions{})
+}
+
+func testRunCopyWithOpts(t testing.TB, srcGopts global.Options, dstGopts global.Options, copyOpts CopyOptions) {
 	gopts := srcGopts
 	gopts.Repo = dstGopts.Repo
 	gopts.Password = dstGopts.Password
 	gopts.InsecureNoPassword = dstGopts.InsecureNoPassword
-	copyOpts := CopyOptions{
-		SecondaryRepoOptions: global.SecondaryRepoOptions{
-			Repo:               srcGopts.Repo,
-			Password:           srcGopts.Password,
-			InsecureNoPassword: srcGopts.InsecureNoPassword,
-		},
+	copyOpts.SecondaryRepoOptions = global.SecondaryRepoOptions{
+		Repo:               srcGopts.Repo,
+		Password:           srcGopts.Password,
+		InsecureNoPassword: srcGopts.InsecureNoPassword,
 	}
 
 	rtest.OK(t, withTermStatus(t, gopts, func(ctx context.Context, gopts global.Options) error {
@@ -122,6 +127,35 @@ func testPackAndBlobCounts(t testing.TB, gopts global.Options) (countTreePacks i
 	return countTreePacks, countDataPacks, countBlobs
 }
 
+func TestCopyBulk(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+	env2, cleanup2 := withTestEnvironment(t)
+	defer cleanup2()
+
+	testSetupBackupData(t, env)
+	opts := BackupOptions{}
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9")}, opts, env.gopts)
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9", "2")}, opts, env.gopts)
+
+	testRunInit(t, env2.gopts)
+	testRunCopyWithOpts(t, env.gopts, env2.gopts, CopyOptions{Bulk: true})
+	testListSnapshots(t, env2.gopts, 2)
+	testRunCheck(t, env2.gopts)
+
+	_, _, countBlobs := testPackAndBlobCounts(t, env.gopts)
+	countTreePacksDst, countDataPacksDst, countBlobsDst := testPackAndBlobCounts(t, env2.gopts)
+	rtest.Equals(t, countBlobs, countBlobsDst, "expected blob count in both repos to be equal")
+	rtest.Equals(t, countTreePacksDst, 1, "expected 1 tree packfile")
+	rtest.Equals(t, countDataPacksDst, 1, "expected 1 data packfile")
+
+	// already copied snapshots are skipped
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9", "3")}, opts, env.gopts)
+	testRunCopyWithOpts(t, env.gopts, env2.gopts, CopyOptions{Bulk: true})
+	testListSnapshots(t, env2.gopts, 3)
+	testRunCheck(t, env2.gopts)
+}
+
 func TestCopyIncremental(t *testing.T) {
 	env, cleanup := withTestEnvironment(t)
 	defer cleanup()
@@ -161,6 +195,61 @@ func TestCopyIncremental(t *testing.T) {
 	testListSnapshots(t, env.gopts, 3)
 }
 
+// testBackupDataBlobs returns the number of data blobs added by a backup of dir.
+func testBackupDataBlobs(t testing.TB, gopts global.Options, dir string) int {
+	gopts.JSON = true
+	out, err := testRunBackupOutput(t, BackupOptions{Force: true}, gopts, []string{dir})
+	rtest.OK(t, err)
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	var summary struct {
+		DataBlobs int `json:"data_blobs"`
+	}
+	rtest.OK(t, json.Unmarshal([]byte(lines[len(lines)-1]), &summary))
+	return summary.DataBlobs
+}
+
+func TestCopyConvert(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+	env2, cleanup2 := withTestEnvironment(t)
+	defer cleanup2()
+	env3, cleanup3 := withTestEnvironment(t)
+	defer cleanup3()
+
+	// the file must be split into several chunks, which differ between
+	// repositories with different chunker polynomials
+	datadir := filepath.Join(env.base, "convert")
+	rtest.OK(t, os.MkdirAll(datadir, 0o700))
+	rtest.OK(t, appendRandomData(filepath.Join(datadir, "file"), 8*1024*1024))
+	testRunInit(t, env.gopts)
+	testRunBackup(t, "", []string{datadir}, BackupOptions{}, env.gopts)
+	snapshotIDs := testListSnapshots(t, env.gopts, 1)
+
+	// all repositories use different chunker polynomials
+	testRunInit(t, env2.gopts)
+	testRunCopyWithOpts(t, env.gopts, env2.gopts, CopyOptions{Convert: true})
+	copiedSnapshotIDs := testListSnapshots(t, env2.gopts, 1)
+	testRunCheck(t, env2.gopts)
+
+	restoredir := filepath.Join(env.base, "restore")
+	testRunRestore(t, env.gopts, restoredir, snapshotIDs[0].String())
+	restoredir2 := filepath.Join(env2.base, "restore")
+	testRunRestore(t, env2.gopts, restoredir2, copiedSnapshotIDs[0].String())
+	rtest.Assert(t, directoriesContentsDiff(t, restoredir, restoredir2) == "", "converted snapshot differs from the original one")
+
+	// converted snapshots are not copied again
+	testRunCopyWithOpts(t, env.gopts, env2.gopts, CopyOptions{Convert: true})
+	testListSnapshots(t, env2.gopts, 1)
+
+	// a backup of the same data deduplicates against the converted copy
+	rtest.Equals(t, 0, testBackupDataBlobs(t, env2.gopts, datadir))
+
+	// but not against a regular copy
+	testRunInit(t, env3.gopts)
+	testRunCopy(t, env.gopts, env3.gopts)
+	rtest.Assert(t, testBackupDataBlobs(t, env3.gopts, datadir) > 0, "expected new data blobs for a backup after a regular copy")
+}
+
 func TestCopyUnstableJSON(t *testing.T) {
 	env, cleanup := withTestEnvironment(t)
 	defer cleanup()
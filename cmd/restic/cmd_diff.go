@@ -38,6 +38,15 @@ directory:
 Metadata comparison will likely not work if a backup was created using the
 '--ignore-inode' or '--ignore-ctime' option.
 
+The option '--content-only' restricts the output to added, removed and
+modified items and ignores all metadata changes. The option '--metadata-only'
+instead only reports items whose metadata or type has changed, changes to the
+content of files are not shown. Both options still list added and removed
+items.
+
+With '--json', each change lists the modified attributes of an item, for
+example "mode", "mtime", "xattr", "size" or "content".
+
 To only compare files in specific subfolders, you can use the
 "snapshotID:subfolder" syntax, where "subfolder" is a path within the
 snapshot tree as shown by "restic ls".
@@ -65,10 +74,24 @@ Exit status is 12 if the password is incorrect.
 // DiffOptions collects all options for the diff command.
 type DiffOptions struct {
 	ShowMetadata bool
+	MetadataOnly bool
+	ContentOnly  bool
 }
 
 func (opts *DiffOptions) AddFlags(f *pflag.FlagSet) {
 	f.BoolVar(&opts.ShowMetadata, "metadata", false, "print changes in metadata")
+	f.BoolVar(&opts.MetadataOnly, "metadata-only", false, "only print changes in metadata and ignore file contents")
+	f.BoolVar(&opts.ContentOnly, "content-only", false, "only print changes in file contents and ignore metadata")
+}
+
+func (opts *DiffOptions) Check() error {
+	if opts.MetadataOnly && opts.ContentOnly {
+		return errors.Fatal("--metadata-only and --content-only cannot be used together")
+	}
+	if opts.ShowMetadata && opts.ContentOnly {
+		return errors.Fatal("--metadata and --content-only cannot be used together")
+	}
+	return nil
 }
 
 func loadSnapshot(ctx context.Context, be restic.Lister, repo restic.LoaderUnpacked, desc string) (*data.Snapshot, string, error) {
@@ -91,12 +114,66 @@ type Change struct {
 	MessageType string `json:"message_type"` // "change"
 	Path        string `json:"path"`
 	Modifier    string `json:"modifier"`
+	// Changes lists the modified attributes of an item that exists in both
+	// snapshots.
+	Changes []string `json:"changes,omitempty"`
 }
 
 func NewChange(path string, mode string) *Change {
 	return &Change{MessageType: "change", Path: path, Modifier: mode}
 }
 
+// nodeChanges returns the names of the attributes which differ between node1
+// and node2. Changes of the subtree of a directory are not reported.
+func nodeChanges(node1, node2 *data.Node) []string {
+	var changes []string
+	add := func(changed bool, name string) {
+		if changed {
+			changes = append(changes, name)
+		}
+	}
+
+	add(node1.Type != node2.Type, "type")
+	add(!reflect.DeepEqual(node1.Content, node2.Content), "content")
+	add(node1.Size != node2.Size, "size")
+	add(node1.Mode != node2.Mode, "mode")
+	add(!node1.ModTime.Equal(node2.ModTime), "mtime")
+	add(!node1.AccessTime.Equal(node2.AccessTime), "atime")
+	add(!node1.ChangeTime.Equal(node2.ChangeTime), "ctime")
+	add(node1.UID != node2.UID, "uid")
+	add(node1.GID != node2.GID, "gid")
+	add(node1.User != node2.User, "user")
+	add(node1.Group != node2.Group, "group")
+	add(node1.Inode != node2.Inode || node1.DeviceID != node2.DeviceID, "inode")
+	add(node1.Links != node2.Links, "links")
+	add(node1.LinkTarget != node2.LinkTarget, "linktarget")
+	add(node1.Device != node2.Device, "device")
+	// compare only the attributes in question, Equals handles their order
+	add(!data.Node{ExtendedAttributes: node1.ExtendedAttributes}.Equals(
+		data.Node{ExtendedAttributes: node2.ExtendedAttributes}), "xattr")
+	add(!data.Node{GenericAttributes: node1.GenericAttributes}.Equals(
+		data.Node{GenericAttributes: node2.GenericAttributes}), "generic_attributes")
+	return changes
+}
+
+// isMetadataChange reports whether the change returned by nodeChanges
+// concerns the metadata of an item. The size is considered to be part of the
+// content of a file.
+func isMetadataChange(name string) bool {
+	return name != "type" && name != "content" && name != "size"
+}
+
+// filterChanges returns the changes for which keep returns true.
+func filterChanges(changes []string, keep func(name string) bool) []string {
+	var result []string
+	for _, name := range changes {
+		if keep(name) {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
 // DiffStat collects stats for all types of items.
 type DiffStat struct {
 	Files     int    `json:"files"`
@@ -286,9 +363,21 @@ func (c *Comparer) diffTree(ctx context.Context, stats *DiffStatsContainer, pref
 				name += "/"
 			}
 
-			if node1.Type == data.NodeTypeFile &&
+			changes := nodeChanges(node1, node2)
+			contentChanged := node1.Type == data.NodeTypeFile &&
 				node2.Type == data.NodeTypeFile &&
-				!reflect.DeepEqual(node1.Content, node2.Content) {
+				!reflect.DeepEqual(node1.Content, node2.Content)
+
+			switch {
+			case c.opts.MetadataOnly:
+				if contentChanged {
+					stats.ChangedFiles++
+				}
+				// ignore the content of files and the subtree of directories
+				if len(filterChanges(changes, isMetadataChange)) > 0 {
+					mod += "U"
+				}
+			case contentChanged:
 				mod += "M"
 				stats.ChangedFiles++
 
@@ -301,12 +390,21 @@ func (c *Comparer) diffTree(ctx context.Context, stats *DiffStatsContainer, pref
 					// probable bitrot detected
 					mod += "?"
 				}
-			} else if c.opts.ShowMetadata && !node1.Equals(*node2) {
+			case c.opts.ShowMetadata && !node1.Equals(*node2):
 				mod += "U"
 			}
 
 			if mod != "" {
-				c.printChange(NewChange(name, mod))
+				change := NewChange(name, mod)
+				switch {
+				case c.opts.ContentOnly:
+					change.Changes = filterChanges(changes, func(name string) bool { return !isMetadataChange(name) })
+				case c.opts.MetadataOnly:
+					change.Changes = filterChanges(changes, func(name string) bool { return name == "type" || isMetadataChange(name) })
+				default:
+					change.Changes = changes
+				}
+				c.printChange(change)
 			}
 
 			if node1.Type == data.NodeTypeDir && node2.Type == data.NodeTypeDir {
@@ -358,6 +456,9 @@ func runDiff(ctx context.Context, opts DiffOptions, gopts global.Options, args [
 	if len(args) != 2 {
 		return errors.Fatalf("specify two snapshot IDs")
 	}
+	if err := opts.Check(); err != nil {
+		return err
+	}
 
 	printer := progress.NewTerminalPrinter(gopts.JSON, gopts.Verbosity, term)
 
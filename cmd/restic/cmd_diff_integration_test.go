@@ -6,8 +6,10 @@ import (
 	"encoding/json"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 	"testing"
 
@@ -16,10 +18,11 @@ import (
 )
 
 func testRunDiffOutput(t testing.TB, gopts global.Options, firstSnapshotID string, secondSnapshotID string) (string, error) {
+	return testRunDiffOutputWithOptions(t, gopts, DiffOptions{}, firstSnapshotID, secondSnapshotID)
+}
+
+func testRunDiffOutputWithOptions(t testing.TB, gopts global.Options, opts DiffOptions, firstSnapshotID string, secondSnapshotID string) (string, error) {
 	buf, err := withCaptureStdout(t, gopts, func(ctx context.Context, gopts global.Options) error {
-		opts := DiffOptions{
-			ShowMetadata: false,
-		}
 		return runDiff(ctx, opts, gopts, []string{firstSnapshotID, secondSnapshotID}, gopts.Term)
 	})
 	return buf.String(), err
@@ -192,3 +195,50 @@ func TestDiffJSON(t *testing.T) {
 		stat.ChangedFiles == 1, "unexpected statistics")
 	rtest.Assert(t, stat.SourceSnapshot == firstSnapshotID && stat.TargetSnapshot == secondSnapshotID, "unexpected snapshot ids")
 }
+
+func TestDiffModes(t *testing.T) {
+	env, cleanup, firstSnapshotID, secondSnapshotID := setupDiffRepo(t)
+	defer cleanup()
+
+	env.gopts.Quiet = false
+	env.gopts.JSON = true
+
+	readChanges := func(opts DiffOptions) map[string]Change {
+		out, err := testRunDiffOutputWithOptions(t, env.gopts, opts, firstSnapshotID, secondSnapshotID)
+		rtest.OK(t, err)
+
+		changes := make(map[string]Change)
+		scanner := bufio.NewScanner(strings.NewReader(out))
+		for scanner.Scan() {
+			var change Change
+			rtest.OK(t, json.Unmarshal(scanner.Bytes(), &change))
+			if change.MessageType == "change" {
+				changes[path.Base(change.Path)] = change
+			}
+		}
+		return changes
+	}
+
+	changes := readChanges(DiffOptions{ContentOnly: true})
+	rtest.Equals(t, "M", changes["modfile1"].Modifier)
+	rtest.Equals(t, []string{"content", "size"}, changes["modfile1"].Changes)
+	rtest.Equals(t, "+", changes["modfile2"].Modifier)
+	_, ok := changes["moddir"]
+	rtest.Assert(t, !ok, "unexpected metadata change for moddir")
+
+	changes = readChanges(DiffOptions{MetadataOnly: true})
+	rtest.Equals(t, "U", changes["modfile1"].Modifier)
+	rtest.Assert(t, slices.Contains(changes["modfile1"].Changes, "mtime"), "missing mtime change in %v", changes["modfile1"].Changes)
+	rtest.Assert(t, !slices.Contains(changes["modfile1"].Changes, "content"), "unexpected content change in %v", changes["modfile1"].Changes)
+	rtest.Equals(t, "U", changes["moddir"].Modifier)
+	rtest.Equals(t, "+", changes["modfile2"].Modifier)
+
+	// the default output lists all changes of modified files
+	changes = readChanges(DiffOptions{})
+	rtest.Equals(t, "M", changes["modfile1"].Modifier)
+	rtest.Assert(t, slices.Contains(changes["modfile1"].Changes, "content") &&
+		slices.Contains(changes["modfile1"].Changes, "mtime"), "unexpected changes %v", changes["modfile1"].Changes)
+
+	_, err := testRunDiffOutputWithOptions(t, env.gopts, DiffOptions{MetadataOnly: true, ContentOnly: true}, firstSnapshotID, secondSnapshotID)
+	rtest.Assert(t, err != nil, "expected error for conflicting options")
+}
@@ -6,11 +6,14 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
+	"strings"
 
 	"github.com/restic/restic/internal/data"
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/dump"
 	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/filter"
 	"github.com/restic/restic/internal/global"
 	"github.com/restic/restic/internal/restic"
 	"github.com/restic/restic/internal/ui"
@@ -23,7 +26,7 @@ import (
 func newDumpCommand(globalOptions *global.Options) *cobra.Command {
 	var opts DumpOptions
 	cmd := &cobra.Command{
-		Use:   "dump [flags] snapshotID file",
+		Use:   "dump [flags] snapshotID file [file...]",
 		Short: "Print backed-up files or folders to stdout",
 		Long: `
 The "dump" command extracts files from a snapshot from the repository. If a
@@ -38,6 +41,13 @@ To include the folder content at the root of the archive, you can use the
 "snapshotID:subfolder" syntax, where "subfolder" is a path within the
 snapshot tree as shown by "restic ls".
 
+Several files and folders can be dumped into a single archive by passing
+multiple paths. Each path component may contain a glob pattern, for example
+"/home/*/.bashrc". The archive can be restricted to a subset of the selected
+files and folders using include or exclude patterns, which are matched like
+for the "restore" command. The archive always contains the full paths of all
+items within the snapshot.
+
 EXIT STATUS
 ===========
 
@@ -62,12 +72,16 @@ Exit status is 12 if the password is incorrect.
 // DumpOptions collects all options for the dump command.
 type DumpOptions struct {
 	data.SnapshotFilter
+	filter.ExcludePatternOptions
+	filter.IncludePatternOptions
 	Archive string
 	Target  string
 }
 
 func (opts *DumpOptions) AddFlags(f *pflag.FlagSet) {
 	initSingleSnapshotFilter(f, &opts.SnapshotFilter)
+	opts.ExcludePatternOptions.Add(f)
+	opts.IncludePatternOptions.Add(f)
 	f.StringVarP(&opts.Archive, "archive", "a", "tar", "set archive `format` as \"tar\" or \"zip\"")
 	f.StringVarP(&opts.Target, "target", "t", "", "write the output to target `path`")
 }
@@ -132,8 +146,89 @@ func printFromTree(ctx context.Context, tree data.TreeNodeIterator, repo restic.
 	return fmt.Errorf("path %q not found in snapshot", item)
 }
 
+// findNodes returns the nodes in tree which match the path pathComponents.
+// Each component may be a glob pattern. The Path of the returned nodes is set.
+func findNodes(ctx context.Context, tree data.TreeNodeIterator, repo restic.BlobLoader, prefix string, pathComponents []string) ([]*data.Node, error) {
+	var nodes []*data.Node
+	for it := range tree {
+		if it.Error != nil {
+			return nil, it.Error
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		node := it.Node
+
+		// "/" selects all items
+		matched := pathComponents[0] == ""
+		if !matched {
+			var err error
+			matched, err = path.Match(pathComponents[0], node.Name)
+			if err != nil {
+				return nil, errors.Fatalf("invalid pattern %q: %v", pathComponents[0], err)
+			}
+		}
+		if !matched {
+			continue
+		}
+
+		item := path.Join(prefix, node.Name)
+		switch {
+		case len(pathComponents) == 1:
+			node.Path = item
+			nodes = append(nodes, node)
+		case node.Type == data.NodeTypeDir:
+			subtree, err := data.LoadTree(ctx, repo, *node.Subtree)
+			if err != nil {
+				return nil, errors.Wrapf(err, "cannot load subtree for %q", item)
+			}
+			subnodes, err := findNodes(ctx, subtree, repo, item, pathComponents[1:])
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, subnodes...)
+		}
+	}
+	return nodes, nil
+}
+
+// dumpArchive writes all items matching one of the paths to a single archive.
+func dumpArchive(ctx context.Context, repo restic.BlobLoader, root restic.ID, paths []string, d *dump.Dumper) error {
+	var nodes []*data.Node
+	for _, p := range paths {
+		tree, err := data.LoadTree(ctx, repo, root)
+		if err != nil {
+			return err
+		}
+		found, err := findNodes(ctx, tree, repo, "/", splitPath(path.Clean(p)))
+		if err != nil {
+			return err
+		}
+		if len(found) == 0 {
+			return fmt.Errorf("path %q not found in snapshot", p)
+		}
+		nodes = append(nodes, found...)
+	}
+
+	// items must only be stored once, even if several paths match them
+	slices.SortFunc(nodes, func(a, b *data.Node) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	var selected []*data.Node
+	for _, node := range nodes {
+		if len(selected) > 0 {
+			last := selected[len(selected)-1].Path
+			if node.Path == last || strings.HasPrefix(node.Path, last+"/") {
+				continue
+			}
+		}
+		selected = append(selected, node)
+	}
+	return d.DumpNodes(ctx, selected)
+}
+
 func runDump(ctx context.Context, opts DumpOptions, gopts global.Options, args []string, term ui.Terminal) error {
-	if len(args) != 2 {
+	if len(args) < 2 {
 		return errors.Fatal("no file and no snapshot ID specified")
 	}
 
@@ -145,12 +240,22 @@ func runDump(ctx context.Context, opts DumpOptions, gopts global.Options, args [
 		return fmt.Errorf("unknown archive format %q", opts.Archive)
 	}
 
-	snapshotIDString := args[0]
-	pathToPrint := args[1]
+	excludePatternFns, err := opts.ExcludePatternOptions.CollectPatterns(printer.E)
+	if err != nil {
+		return err
+	}
+	includePatternFns, err := opts.IncludePatternOptions.CollectPatterns(printer.E)
+	if err != nil {
+		return err
+	}
+	if len(excludePatternFns) > 0 && len(includePatternFns) > 0 {
+		return errors.Fatal("exclude and include patterns are mutually exclusive")
+	}
 
-	debug.Log("dump file %q from %q", pathToPrint, snapshotIDString)
+	snapshotIDString := args[0]
+	paths := args[1:]
 
-	splittedPath := splitPath(path.Clean(pathToPrint))
+	debug.Log("dump files %q from %q", paths, snapshotIDString)
 
 	ctx, repo, unlock, err := openWithReadLock(ctx, gopts, gopts.NoLock, printer)
 	if err != nil {
@@ -195,7 +300,15 @@ func runDump(ctx context.Context, opts DumpOptions, gopts global.Options, args [
 	}
 
 	d := dump.New(opts.Archive, repo, outputFileWriter)
-	err = printFromTree(ctx, tree, repo, "/", splittedPath, d, canWriteArchiveFunc)
+	if len(paths) == 1 && len(excludePatternFns) == 0 && len(includePatternFns) == 0 && !hasGlob(paths[0]) {
+		err = printFromTree(ctx, tree, repo, "/", splitPath(path.Clean(paths[0])), d, canWriteArchiveFunc)
+	} else {
+		d.SelectFilter = selectPatternFilter(excludePatternFns, includePatternFns)
+		err = canWriteArchiveFunc()
+		if err == nil {
+			err = dumpArchive(ctx, repo, *sn.Tree, paths, d)
+		}
+	}
 	if err != nil {
 		return errors.Fatalf("cannot dump file: %v", err)
 	}
@@ -203,6 +316,12 @@ func runDump(ctx context.Context, opts DumpOptions, gopts global.Options, args [
 	return nil
 }
 
+// hasGlob returns whether p contains characters with a special meaning in glob
+// patterns.
+func hasGlob(p string) bool {
+	return strings.ContainsAny(p, "*?[\\")
+}
+
 func checkStdoutArchive(term ui.Terminal) func() error {
 	if term.OutputIsTerminal() {
 		return func() error { return fmt.Errorf("stdout is the terminal, please redirect output") }
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"archive/tar"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/restic/restic/internal/global"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func testRunDump(t testing.TB, gopts global.Options, opts DumpOptions, args []string) error {
+	return withTermStatus(t, gopts, func(ctx context.Context, gopts global.Options) error {
+		return runDump(ctx, opts, gopts, args, gopts.Term)
+	})
+}
+
+func tarNames(t testing.TB, filename string) []string {
+	f, err := os.Open(filename)
+	rtest.OK(t, err)
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var names []string
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return names
+		}
+		rtest.OK(t, err)
+		names = append(names, hdr.Name)
+	}
+}
+
+func TestDumpMultiplePaths(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+	testRunInit(t, env.gopts)
+
+	for _, name := range []string{"a/x.txt", "a/y.log", "b/z.txt", "c/w.txt"} {
+		p := filepath.Join(env.testdata, "src", filepath.FromSlash(name))
+		rtest.OK(t, os.MkdirAll(filepath.Dir(p), 0o700))
+		rtest.OK(t, os.WriteFile(p, []byte(name), 0o600))
+	}
+	testRunBackup(t, env.testdata, []string{"src"}, BackupOptions{}, env.gopts)
+
+	target := filepath.Join(env.base, "dump.tar")
+	opts := DumpOptions{Archive: "tar", Target: target}
+	rtest.OK(t, testRunDump(t, env.gopts, opts, []string{"latest", "/src/a", "/src/b/z.txt", "/src/a/x.txt"}))
+	rtest.Equals(t, []string{"src/a/", "src/a/x.txt", "src/a/y.log", "src/b/z.txt"}, tarNames(t, target))
+
+	// glob patterns and include patterns
+	opts.Includes = []string{"*.txt"}
+	rtest.OK(t, testRunDump(t, env.gopts, opts, []string{"latest", "/src/[ab]"}))
+	rtest.Equals(t, []string{"src/a/x.txt", "src/b/z.txt"}, tarNames(t, target))
+
+	opts.Includes = nil
+	opts.Excludes = []string{"*.txt"}
+	rtest.OK(t, testRunDump(t, env.gopts, opts, []string{"latest", "/"}))
+	rtest.Equals(t, []string{"src/", "src/a/", "src/a/y.log", "src/b/", "src/c/"}, tarNames(t, target))
+
+	err := testRunDump(t, env.gopts, opts, []string{"latest", "/src/a", "/src/missing"})
+	rtest.Assert(t, err != nil, "expected error for missing path")
+}
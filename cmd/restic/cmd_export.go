@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/global"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/ui"
+	"github.com/restic/restic/internal/ui/progress"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func newExportCommand(globalOptions *global.Options) *cobra.Command {
+	var opts ExportOptions
+
+	cmd := &cobra.Command{
+		Use:   "export [flags] directory",
+		Short: "Export the repository to volumes for offline transport",
+		Long: `
+The "export" command writes the files of the repository to numbered volumes in
+the given directory, for example to transport them on tape or removable media
+to a repository which is not reachable over the network. Use the "import"
+command to store the volumes in the other repository.
+
+Each volume is a tar archive named "restic-export-NNNN.tar", which starts with
+a manifest listing the contained files. The manifest is also written to the
+file "restic-export-NNNN.json". The files are exported without modification,
+thus the other repository uses the same keys and passwords.
+
+The size of the volumes is limited by "--volume-size". To only export files
+which were not transported before, pass the manifests of the earlier exports
+using "--exclude-manifest".
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful.
+Exit status is 1 if there was any error.
+Exit status is 10 if the repository does not exist.
+Exit status is 11 if the repository is already locked.
+Exit status is 12 if the password is incorrect.
+`,
+		GroupID:           cmdGroupAdvanced,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runExport(cmd.Context(), opts, *globalOptions, args, globalOptions.Term)
+		},
+	}
+
+	opts.AddFlags(cmd.Flags())
+	return cmd
+}
+
+// ExportOptions collects all options for the export command.
+type ExportOptions struct {
+	VolumeSize       string
+	ExcludeManifests []string
+}
+
+func (opts *ExportOptions) AddFlags(f *pflag.FlagSet) {
+	f.StringVar(&opts.VolumeSize, "volume-size", "", "limit the volumes to `size` (allowed suffixes: k/K, m/M, g/G, t/T)")
+	f.StringArrayVar(&opts.ExcludeManifests, "exclude-manifest", nil, "do not export files listed in the manifest `file` of an earlier export (can be specified multiple times)")
+}
+
+// exportVolumeName returns the name of the volume with the given number.
+func exportVolumeName(volume int, ext string) string {
+	return fmt.Sprintf("restic-export-%04d%s", volume, ext)
+}
+
+// loadExportManifest reads a manifest written by the export command, either
+// as a separate JSON file or from the start of a volume.
+func loadExportManifest(filename string) (*repository.ExportManifest, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	manifest, _, err := repository.ReadExportManifest(f)
+	if err == nil {
+		return manifest, nil
+	}
+	if _, serr := f.Seek(0, io.SeekStart); serr != nil {
+		return nil, serr
+	}
+	manifest = &repository.ExportManifest{}
+	if jerr := json.NewDecoder(f).Decode(manifest); jerr != nil {
+		return nil, errors.Fatalf("invalid manifest %v: %v", filename, err)
+	}
+	return manifest, nil
+}
+
+func runExport(ctx context.Context, opts ExportOptions, gopts global.Options, args []string, term ui.Terminal) error {
+	if len(args) != 1 {
+		return errors.Fatal("the export command expects a target directory - please see `restic help export` for usage and flags")
+	}
+	dir := args[0]
+
+	var volumeSize int64
+	if opts.VolumeSize != "" {
+		var err error
+		volumeSize, err = ui.ParseBytes(opts.VolumeSize)
+		if err != nil || volumeSize <= 0 {
+			return errors.Fatalf("invalid volume size %q", opts.VolumeSize)
+		}
+	}
+
+	printer := progress.NewTerminalPrinter(gopts.JSON, gopts.Verbosity, term)
+	ctx, repo, unlock, err := openWithReadLock(ctx, gopts, gopts.NoLock, printer)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	var exclude []repository.ExportFile
+	for _, filename := range opts.ExcludeManifests {
+		manifest, err := loadExportManifest(filename)
+		if err != nil {
+			return err
+		}
+		if manifest.RepositoryID != repo.Config().ID {
+			return errors.Fatalf("manifest %v belongs to another repository", filename)
+		}
+		exclude = append(exclude, manifest.Files...)
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return errors.Fatalf("unable to create target directory: %v", err)
+	}
+
+	newVolume := func(volume int) (io.WriteCloser, error) {
+		return os.OpenFile(filepath.Join(dir, exportVolumeName(volume, ".tar")), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	}
+
+	bar := printer.NewCounter("files exported")
+	stats, err := repo.Export(ctx, repository.ExportOptions{
+		VolumeSize: volumeSize,
+		Exclude:    exclude,
+	}, newVolume, bar)
+	if err != nil {
+		return errors.Fatalf("export failed: %v", err)
+	}
+
+	// store a copy of each manifest next to the volume for later use with --exclude-manifest
+	for volume := 1; volume <= stats.Volumes; volume++ {
+		manifest, err := loadExportManifest(filepath.Join(dir, exportVolumeName(volume, ".tar")))
+		if err != nil {
+			return err
+		}
+		buf, err := json.MarshalIndent(manifest, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, exportVolumeName(volume, ".json")), buf, 0o600); err != nil {
+			return err
+		}
+	}
+
+	printer.P("exported %d files (%s) to %d volumes in %s", stats.Files, ui.FormatBytes(stats.Size), stats.Volumes, dir)
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/restic/restic/internal/global"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func testRunExport(t testing.TB, gopts global.Options, opts ExportOptions, dir string) {
+	rtest.OK(t, withTermStatus(t, gopts, func(ctx context.Context, gopts global.Options) error {
+		return runExport(ctx, opts, gopts, []string{dir}, gopts.Term)
+	}))
+}
+
+func testRunImport(t testing.TB, gopts global.Options, volumes []string) {
+	rtest.OK(t, withTermStatus(t, gopts, func(ctx context.Context, gopts global.Options) error {
+		return runImport(ctx, gopts, volumes, gopts.Term)
+	}))
+}
+
+func TestExportImport(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+	// export lists the keys after opening the repository
+	env.gopts.BackendTestHook = nil
+
+	testSetupBackupData(t, env)
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9")}, BackupOptions{}, env.gopts)
+
+	exportDir := filepath.Join(env.base, "export1")
+	testRunExport(t, env.gopts, ExportOptions{VolumeSize: "64k"}, exportDir)
+	volumes, err := filepath.Glob(filepath.Join(exportDir, "*.tar"))
+	rtest.OK(t, err)
+	rtest.Assert(t, len(volumes) > 1, "expected multiple volumes, got %v", volumes)
+
+	// import the volumes in reverse order into a new repository
+	dstOpts := env.gopts
+	dstOpts.Repo = filepath.Join(env.base, "dst-repo")
+	for i, j := 0, len(volumes)-1; i < j; i, j = i+1, j-1 {
+		volumes[i], volumes[j] = volumes[j], volumes[i]
+	}
+	testRunImport(t, dstOpts, volumes)
+	testRunCheck(t, dstOpts)
+	rtest.Equals(t, testListSnapshots(t, env.gopts, 1), testListSnapshots(t, dstOpts, 1))
+
+	// incremental export only contains the new files
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9", "1")}, BackupOptions{}, env.gopts)
+	manifests, err := filepath.Glob(filepath.Join(exportDir, "*.json"))
+	rtest.OK(t, err)
+	exportDir2 := filepath.Join(env.base, "export2")
+	testRunExport(t, env.gopts, ExportOptions{ExcludeManifests: manifests}, exportDir2)
+
+	manifest, err := loadExportManifest(filepath.Join(exportDir2, exportVolumeName(1, ".tar")))
+	rtest.OK(t, err)
+	for _, f := range manifest.Files {
+		rtest.Assert(t, f.Type != "config" && f.Type != "key", "unexpected file %v in incremental export", f)
+	}
+	testRunImport(t, dstOpts, []string{filepath.Join(exportDir2, exportVolumeName(1, ".tar"))})
+	testRunCheck(t, dstOpts)
+	testListSnapshots(t, dstOpts, 2)
+
+	// importing a volume again skips all files
+	testRunImport(t, dstOpts, []string{filepath.Join(exportDir2, exportVolumeName(1, ".tar"))})
+
+	_, err = os.Stat(filepath.Join(exportDir2, exportVolumeName(2, ".tar")))
+	rtest.Assert(t, os.IsNotExist(err), "unexpected second volume")
+}
@@ -1,11 +1,14 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -37,6 +40,21 @@ The "find" command searches for files or directories in snapshots stored in the
 repository. It can also be used to search for restic blobs, trees or pack
 files for troubleshooting.
 
+With "--content" or "--content-hex", only files which contain the given text
+or bytes are reported. The PATTERN arguments then restrict which files are
+searched, all files are searched if no pattern is given. As the content of all
+matching files has to be downloaded, use patterns and "--oldest"/"--newest" to
+limit the amount of data to scan. Files with identical content are only
+searched once.
+
+The options "--inode", "--device", "--owner", "--size", "--mtime-within" and
+"--type" only report files and directories whose metadata matches all given
+values. They are evaluated while walking the trees and can be combined with
+patterns, all files are searched if no pattern is given. "--size" accepts
+"+SIZE" for files of at least SIZE bytes, "-SIZE" for files of at most SIZE bytes
+and "SIZE" for files of exactly SIZE bytes. It can be given twice to specify a
+range. Note that restic only stores the device ID of files with hard links.
+
 The default sort option for the snapshots is youngest to oldest. To sort the
 output from oldest to youngest specify --reverse.
 
@@ -54,7 +72,11 @@ restic find --json "*.yml" "*.json"
 restic find --json --blob 420f620f b46ebe8a ddd38656
 restic find --show-pack-id --blob 420f620f
 restic find --tree 577c2bc9 f81f2e22 a62827a9
-restic find --pack 025c1d06`,
+restic find --pack 025c1d06
+restic find --content "BEGIN RSA PRIVATE KEY" "/home/*"
+restic find --content-hex 89504e470d0a1a0a "*.dat"
+restic find --owner www-data --size +1G --mtime-within 7d
+restic find --type socket --type fifo "/var/*"`,
 		GroupID:           cmdGroupDefault,
 		DisableAutoGenTag: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -78,6 +100,14 @@ type FindOptions struct {
 	ListLong           bool
 	HumanReadable      bool
 	Reverse            bool
+	Content            []string
+	ContentHex         []string
+	Inode              uint64
+	Device             uint64
+	Owner              string
+	Size               []string
+	MTimeWithin        data.Duration
+	Types              []string
 	data.SnapshotFilter
 }
 
@@ -93,6 +123,14 @@ func (opts *FindOptions) AddFlags(f *pflag.FlagSet) {
 	f.BoolVarP(&opts.Reverse, "reverse", "R", false, "reverse sort order oldest to newest")
 	f.BoolVarP(&opts.ListLong, "long", "l", false, "use a long listing format showing size and mode")
 	f.BoolVar(&opts.HumanReadable, "human-readable", false, "print sizes in human readable format")
+	f.StringArrayVar(&opts.Content, "content", nil, "only show files which contain `text` (can be specified multiple times)")
+	f.StringArrayVar(&opts.ContentHex, "content-hex", nil, "only show files which contain the hex-encoded `bytes` (can be specified multiple times)")
+	f.Uint64Var(&opts.Inode, "inode", 0, "only show entries with inode `number`")
+	f.Uint64Var(&opts.Device, "device", 0, "only show entries with device `id` (only stored for files with hard links)")
+	f.StringVar(&opts.Owner, "owner", "", "only show entries owned by `user` (name or numeric uid)")
+	f.StringArrayVar(&opts.Size, "size", nil, "only show files of `size`, +size for at least and -size for at most size bytes, supports the suffixes K, M, G and T (can be specified twice)")
+	f.Var(&opts.MTimeWithin, "mtime-within", "only show entries modified within `duration` before now (e.g. 1y5m7d2h)")
+	f.StringArrayVar(&opts.Types, "type", nil, "only show entries of `type` (file, dir, symlink, dev, chardev, fifo, socket or irregular, can be specified multiple times)")
 
 	initMultiSnapshotFilter(f, &opts.SnapshotFilter, true)
 }
@@ -101,6 +139,134 @@ type findPattern struct {
 	oldest, newest time.Time
 	pattern        []string
 	ignoreCase     bool
+
+	inode, device    uint64
+	owner            string
+	ownerUID         *uint32
+	minSize, maxSize *uint64
+	types            map[data.NodeType]struct{}
+}
+
+// hasPredicates reports whether the metadata of entries is filtered.
+func (pat *findPattern) hasPredicates() bool {
+	return pat.inode != 0 || pat.device != 0 || pat.owner != "" ||
+		pat.minSize != nil || pat.maxSize != nil || len(pat.types) > 0
+}
+
+// matchNode returns true if the metadata of node matches all predicates.
+func (pat *findPattern) matchNode(node *data.Node) bool {
+	if pat.inode != 0 && node.Inode != pat.inode {
+		return false
+	}
+	if pat.device != 0 && node.DeviceID != pat.device {
+		return false
+	}
+	if pat.owner != "" {
+		if pat.ownerUID != nil {
+			if node.UID != *pat.ownerUID {
+				return false
+			}
+		} else if node.User != pat.owner {
+			return false
+		}
+	}
+	if pat.minSize != nil || pat.maxSize != nil {
+		// restic only stores the size of files
+		if node.Type != data.NodeTypeFile {
+			return false
+		}
+		if pat.minSize != nil && node.Size < *pat.minSize {
+			return false
+		}
+		if pat.maxSize != nil && node.Size > *pat.maxSize {
+			return false
+		}
+	}
+	if len(pat.types) > 0 {
+		if _, ok := pat.types[node.Type]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// parsePredicates sets the metadata predicates of the pattern from opts.
+func (pat *findPattern) parsePredicates(opts FindOptions, now time.Time) error {
+	pat.inode = opts.Inode
+	pat.device = opts.Device
+
+	if opts.Owner != "" {
+		pat.owner = opts.Owner
+		if uid, err := strconv.ParseUint(opts.Owner, 10, 32); err == nil {
+			uid32 := uint32(uid)
+			pat.ownerUID = &uid32
+		}
+	}
+
+	if len(opts.Size) > 2 {
+		return errors.Fatal("--size can be specified at most twice")
+	}
+	for _, s := range opts.Size {
+		if err := pat.parseSize(s); err != nil {
+			return err
+		}
+	}
+	if pat.minSize != nil && pat.maxSize != nil && *pat.minSize > *pat.maxSize {
+		return errors.Fatal("--size specifies an empty range")
+	}
+
+	if !opts.MTimeWithin.Zero() {
+		d := opts.MTimeWithin
+		t := now.AddDate(-d.Years, -d.Months, -d.Days).Add(time.Hour * time.Duration(-d.Hours))
+		if t.After(pat.oldest) {
+			pat.oldest = t
+		}
+	}
+
+	for _, t := range opts.Types {
+		nodeType := data.NodeType(t)
+		switch nodeType {
+		case data.NodeTypeFile, data.NodeTypeDir, data.NodeTypeSymlink, data.NodeTypeDev,
+			data.NodeTypeCharDev, data.NodeTypeFifo, data.NodeTypeSocket, data.NodeTypeIrregular:
+		default:
+			return errors.Fatalf("invalid value for --type %q", t)
+		}
+		if pat.types == nil {
+			pat.types = make(map[data.NodeType]struct{})
+		}
+		pat.types[nodeType] = struct{}{}
+	}
+	return nil
+}
+
+// parseSize parses a value of --size, which is either "+SIZE", "-SIZE" or "SIZE".
+func (pat *findPattern) parseSize(s string) error {
+	str := strings.TrimLeft(s, "+-")
+	size, err := ui.ParseBytes(str)
+	if err != nil || len(s)-len(str) > 1 {
+		return errors.Fatalf("invalid value for --size %q", s)
+	}
+	value := uint64(size)
+
+	switch s[0] {
+	case '+':
+		if pat.minSize != nil {
+			return errors.Fatal("--size specifies more than one lower bound")
+		}
+		pat.minSize = &value
+	case '-':
+		if pat.maxSize != nil {
+			return errors.Fatal("--size specifies more than one upper bound")
+		}
+		pat.maxSize = &value
+	default:
+		if pat.minSize != nil || pat.maxSize != nil {
+			return errors.Fatal("--size with an exact size cannot be combined with a range")
+		}
+		pat.minSize = &value
+		pat.maxSize = &value
+	}
+	return nil
 }
 
 var timeFormats = []string{
@@ -279,6 +445,7 @@ type Finder struct {
 	out        statefulOutput
 	blobIDs    map[string]struct{}
 	treeIDs    map[string]struct{}
+	content    *contentMatcher
 	itemsFound int
 	printer    interface {
 		S(string, ...interface{})
@@ -360,12 +527,110 @@ func (f *Finder) findInSnapshot(ctx context.Context, sn *data.Snapshot) error {
 			return errIfNoMatch
 		}
 
+		if !f.pat.matchNode(node) {
+			debug.Log("    metadata does not match\n")
+			return errIfNoMatch
+		}
+
+		if f.content != nil {
+			if node.Type != data.NodeTypeFile {
+				return errIfNoMatch
+			}
+			found, err := f.content.Match(ctx, node)
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				f.printer.E("Unable to read file %s: %v", nodepath, err)
+				return nil
+			}
+			if !found {
+				return nil
+			}
+		}
+
 		debug.Log("    found match\n")
 		f.out.PrintPattern(nodepath, node)
 		return nil
 	}})
 }
 
+// contentMatcher checks whether the content of files contains any of a list
+// of byte sequences. The result is cached per file content, such that files
+// which did not change between snapshots are only read once.
+type contentMatcher struct {
+	repo    restic.BlobLoader
+	needles [][]byte
+	maxLen  int
+	cache   map[restic.ID]bool
+	buf     []byte
+}
+
+func newContentMatcher(repo restic.BlobLoader, needles [][]byte) *contentMatcher {
+	m := &contentMatcher{
+		repo:    repo,
+		needles: needles,
+		cache:   make(map[restic.ID]bool),
+	}
+	for _, needle := range needles {
+		m.maxLen = max(m.maxLen, len(needle))
+	}
+	return m
+}
+
+// Match reports whether the content of the file node contains any needle.
+func (m *contentMatcher) Match(ctx context.Context, node *data.Node) (bool, error) {
+	key := make([]byte, 0, len(node.Content)*len(restic.ID{}))
+	for _, id := range node.Content {
+		key = append(key, id[:]...)
+	}
+	contentID := restic.Hash(key)
+	if found, ok := m.cache[contentID]; ok {
+		return found, nil
+	}
+
+	found, err := m.search(ctx, node.Content)
+	if err != nil {
+		return false, err
+	}
+	m.cache[contentID] = found
+	return found, nil
+}
+
+func (m *contentMatcher) search(ctx context.Context, content restic.IDs) (bool, error) {
+	// tail contains the end of the previous blobs to find needles which
+	// span multiple blobs
+	var tail []byte
+	for _, id := range content {
+		buf, err := m.repo.LoadBlob(ctx, restic.BlobHandle{Type: restic.DataBlob, ID: id}, m.buf)
+		if err != nil {
+			return false, err
+		}
+		m.buf = buf
+
+		window := append(tail[:len(tail):len(tail)], buf[:min(len(buf), m.maxLen-1)]...)
+		if m.contains(window) || m.contains(buf) {
+			return true, nil
+		}
+
+		if len(buf) >= m.maxLen-1 {
+			tail = append(tail[:0], buf[len(buf)-(m.maxLen-1):]...)
+		} else {
+			tail = window[max(0, len(window)-(m.maxLen-1)):]
+		}
+	}
+	return false, nil
+}
+
+func (m *contentMatcher) contains(buf []byte) bool {
+	for _, needle := range m.needles {
+		if bytes.Contains(buf, needle) {
+			return true
+		}
+	}
+	return false
+}
+
 func (f *Finder) findTree(treeID restic.ID, nodepath string) error {
 	found := false
 	if _, ok := f.treeIDs[treeID.String()]; ok {
@@ -594,14 +859,41 @@ func (f *Finder) findObjectsPacks() {
 }
 
 func runFind(ctx context.Context, opts FindOptions, gopts global.Options, args []string, term ui.Terminal) error {
+	needles, err := collectContentNeedles(opts)
+	if err != nil {
+		return err
+	}
+	if len(needles) > 0 {
+		if opts.BlobID || opts.TreeID || opts.PackID {
+			return errors.Fatal("--content and --content-hex cannot be combined with --blob, --tree or --pack")
+		}
+		if len(args) == 0 {
+			// search all files
+			args = []string{"*"}
+		}
+	}
+
+	pat := findPattern{}
+	if err := pat.parsePredicates(opts, time.Now()); err != nil {
+		return err
+	}
+	if pat.hasPredicates() || !opts.MTimeWithin.Zero() {
+		if opts.BlobID || opts.TreeID || opts.PackID {
+			return errors.Fatal("--inode, --device, --owner, --size, --mtime-within and --type cannot be combined with --blob, --tree or --pack")
+		}
+		if len(args) == 0 {
+			// search all files
+			args = []string{"*"}
+		}
+	}
+
 	if len(args) == 0 {
 		return errors.Fatal("wrong number of arguments")
 	}
 
 	printer := progress.NewTerminalPrinter(gopts.JSON, gopts.Verbosity, term)
 
-	var err error
-	pat := findPattern{pattern: args}
+	pat.pattern = args
 	if opts.CaseInsensitive {
 		for i := range pat.pattern {
 			pat.pattern[i] = strings.ToLower(pat.pattern[i])
@@ -610,9 +902,13 @@ func runFind(ctx context.Context, opts FindOptions, gopts global.Options, args [
 	}
 
 	if opts.Oldest != "" {
-		if pat.oldest, err = parseTime(opts.Oldest); err != nil {
+		oldest, err := parseTime(opts.Oldest)
+		if err != nil {
 			return err
 		}
+		if oldest.After(pat.oldest) {
+			pat.oldest = oldest
+		}
 	}
 
 	if opts.Newest != "" {
@@ -654,6 +950,10 @@ func runFind(ctx context.Context, opts FindOptions, gopts global.Options, args [
 		printer: printer,
 	}
 
+	if len(needles) > 0 {
+		f.content = newContentMatcher(repo, needles)
+	}
+
 	if opts.BlobID {
 		f.blobIDs = make(map[string]struct{})
 		for _, pat := range f.pat.pattern {
@@ -712,3 +1012,25 @@ func runFind(ctx context.Context, opts FindOptions, gopts global.Options, args [
 
 	return nil
 }
+
+// collectContentNeedles returns the byte sequences to search for in files.
+func collectContentNeedles(opts FindOptions) ([][]byte, error) {
+	var needles [][]byte
+	for _, s := range opts.Content {
+		if s == "" {
+			return nil, errors.Fatal("--content must not be empty")
+		}
+		needles = append(needles, []byte(s))
+	}
+	for _, s := range opts.ContentHex {
+		buf, err := hex.DecodeString(s)
+		if err != nil {
+			return nil, errors.Fatalf("invalid value for --content-hex %q: %v", s, err)
+		}
+		if len(buf) == 0 {
+			return nil, errors.Fatal("--content-hex must not be empty")
+		}
+		needles = append(needles, buf)
+	}
+	return needles, nil
+}
@@ -3,13 +3,16 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/data"
 	"github.com/restic/restic/internal/global"
 	"github.com/restic/restic/internal/restic"
 	rtest "github.com/restic/restic/internal/test"
@@ -298,3 +301,179 @@ func TestFindPackID(t *testing.T) {
 	// got: "C:/Users/RUNNER~1/AppData/Local/Temp/restic-test-2921201257/testdata/0/0/9"
 	rtest.Equals(t, filepath.ToSlash(record.Path)[2:], filepath.ToSlash(dir009)[2:])
 }
+
+func testRunFindContent(t testing.TB, opts FindOptions, gopts global.Options, patterns ...string) []testMatches {
+	buf, err := withCaptureStdout(t, gopts, func(ctx context.Context, gopts global.Options) error {
+		gopts.JSON = true
+		return runFind(ctx, opts, gopts, patterns, gopts.Term)
+	})
+	rtest.OK(t, err)
+	matches := []testMatches{}
+	rtest.OK(t, json.Unmarshal(buf.Bytes(), &matches))
+	return matches
+}
+
+func TestFindContent(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+	rtest.OK(t, os.MkdirAll(filepath.Join(env.testdata, "sub"), 0755))
+	for name, content := range map[string]string{
+		"secret.txt":     "some text containing a password\n",
+		"other.txt":      "nothing to see here\n",
+		"sub/secret.dat": "\x89PNG\r\n\x1a\n and a password",
+	} {
+		rtest.OK(t, os.WriteFile(filepath.Join(env.testdata, name), []byte(content), 0644))
+	}
+	testRunBackup(t, "", []string{env.testdata}, BackupOptions{}, env.gopts)
+
+	paths := func(matches []testMatches) []string {
+		var result []string
+		for _, m := range matches {
+			for _, match := range m.Matches {
+				result = append(result, filepath.Base(match.Path))
+			}
+		}
+		sort.Strings(result)
+		return result
+	}
+
+	matches := testRunFindContent(t, FindOptions{Content: []string{"password"}}, env.gopts)
+	rtest.Equals(t, []string{"secret.dat", "secret.txt"}, paths(matches))
+
+	// patterns restrict the files to search
+	matches = testRunFindContent(t, FindOptions{Content: []string{"password"}}, env.gopts, "*.txt")
+	rtest.Equals(t, []string{"secret.txt"}, paths(matches))
+
+	matches = testRunFindContent(t, FindOptions{ContentHex: []string{"89504e47"}}, env.gopts)
+	rtest.Equals(t, []string{"secret.dat"}, paths(matches))
+
+	matches = testRunFindContent(t, FindOptions{Content: []string{"missing"}}, env.gopts)
+	rtest.Equals(t, 0, len(matches))
+
+	err := runFind(context.TODO(), FindOptions{ContentHex: []string{"xyz"}}, env.gopts, nil, env.gopts.Term)
+	rtest.Assert(t, err != nil, "expected error for invalid hex string")
+}
+
+type testBlobLoader map[restic.ID][]byte
+
+func (l testBlobLoader) LoadBlob(_ context.Context, h restic.BlobHandle, buf []byte) ([]byte, error) {
+	return append(buf[:0], l[h.ID]...), nil
+}
+
+func TestFindContentAcrossBlobs(t *testing.T) {
+	loader := testBlobLoader{}
+	var content restic.IDs
+	for _, part := range []string{"abc", "d", "efgh", "ij"} {
+		id := restic.Hash([]byte(part))
+		loader[id] = []byte(part)
+		content = append(content, id)
+	}
+	node := &data.Node{Type: data.NodeTypeFile, Content: content}
+
+	for _, test := range []struct {
+		needle string
+		found  bool
+	}{
+		{"abc", true},
+		{"cdef", true},
+		{"bcdefghi", true},
+		{"hij", true},
+		{"abcdefghij", true},
+		{"ac", false},
+		{"jk", false},
+	} {
+		m := newContentMatcher(loader, [][]byte{[]byte(test.needle)})
+		found, err := m.Match(context.TODO(), node)
+		rtest.OK(t, err)
+		rtest.Equals(t, test.found, found, test.needle)
+	}
+}
+
+func TestFindPredicates(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+	rtest.OK(t, os.MkdirAll(filepath.Join(env.testdata, "sub"), 0755))
+	for name, size := range map[string]int{
+		"small":     10,
+		"medium":    2000,
+		"sub/large": 5000,
+	} {
+		rtest.OK(t, os.WriteFile(filepath.Join(env.testdata, name), rtest.Random(size, size), 0644))
+	}
+	old := time.Now().Add(-30 * 24 * time.Hour)
+	rtest.OK(t, os.Chtimes(filepath.Join(env.testdata, "medium"), old, old))
+	testRunBackup(t, "", []string{env.testdata}, BackupOptions{}, env.gopts)
+
+	paths := func(matches []testMatches) []string {
+		var result []string
+		for _, m := range matches {
+			for _, match := range m.Matches {
+				result = append(result, filepath.Base(match.Path))
+			}
+		}
+		sort.Strings(result)
+		return result
+	}
+
+	for _, test := range []struct {
+		opts     FindOptions
+		patterns []string
+		expected []string
+	}{
+		{FindOptions{Size: []string{"+1K"}}, nil, []string{"large", "medium"}},
+		{FindOptions{Size: []string{"-2000"}}, nil, []string{"medium", "small"}},
+		{FindOptions{Size: []string{"+11", "-2K"}}, nil, []string{"medium"}},
+		{FindOptions{Size: []string{"10"}}, nil, []string{"small"}},
+		{FindOptions{Size: []string{"+1K"}}, []string{"*/sub/*"}, []string{"large"}},
+		{FindOptions{MTimeWithin: data.ParseDurationOrPanic("7d"), Types: []string{"file"}}, nil, []string{"large", "small"}},
+		{FindOptions{Types: []string{"dir"}}, []string{"sub"}, []string{"sub"}},
+		{FindOptions{Types: []string{"socket"}}, nil, nil},
+	} {
+		matches := testRunFindContent(t, test.opts, env.gopts, test.patterns...)
+		rtest.Equals(t, test.expected, paths(matches), fmt.Sprintf("%+v", test.opts))
+	}
+
+	for _, opts := range []FindOptions{
+		{Size: []string{"+1X"}},
+		{Size: []string{"+1", "+2"}},
+		{Size: []string{"+2K", "-1K"}},
+		{Types: []string{"unknown"}},
+		{Size: []string{"+1"}, BlobID: true},
+	} {
+		err := runFind(context.TODO(), opts, env.gopts, nil, env.gopts.Term)
+		rtest.Assert(t, err != nil, "expected error for %+v", opts)
+	}
+}
+
+func TestFindMatchNode(t *testing.T) {
+	uid := uint32(33)
+	size := uint64(100)
+	node := &data.Node{Type: data.NodeTypeFile, Inode: 42, DeviceID: 7, UID: 33, User: "www-data", Size: 100}
+
+	for _, test := range []struct {
+		pat   findPattern
+		match bool
+	}{
+		{findPattern{}, true},
+		{findPattern{inode: 42}, true},
+		{findPattern{inode: 43}, false},
+		{findPattern{device: 7}, true},
+		{findPattern{device: 8}, false},
+		{findPattern{owner: "www-data"}, true},
+		{findPattern{owner: "root"}, false},
+		{findPattern{owner: "33", ownerUID: &uid}, true},
+		{findPattern{minSize: &size, maxSize: &size}, true},
+		{findPattern{types: map[data.NodeType]struct{}{data.NodeTypeDir: {}}}, false},
+	} {
+		rtest.Equals(t, test.match, test.pat.matchNode(node), fmt.Sprintf("%+v", test.pat))
+	}
+
+	var pat findPattern
+	rtest.OK(t, pat.parsePredicates(FindOptions{Owner: "33"}, time.Now()))
+	rtest.Assert(t, pat.ownerUID != nil && *pat.ownerUID == 33, "expected numeric owner")
+	rtest.Assert(t, !pat.matchNode(&data.Node{Type: data.NodeTypeDir}), "directory must not match")
+}
@@ -1,18 +1,24 @@
 package main
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/restic/restic/internal/data"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/global"
+	"github.com/restic/restic/internal/repository"
 	"github.com/restic/restic/internal/restic"
 	"github.com/restic/restic/internal/ui"
 	"github.com/restic/restic/internal/ui/progress"
+	"github.com/restic/restic/internal/ui/table"
+	"github.com/restic/restic/internal/walker"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 )
@@ -100,19 +106,23 @@ func (c *ForgetPolicyCount) Type() string {
 
 // ForgetOptions collects all options for the forget command.
 type ForgetOptions struct {
-	Last          ForgetPolicyCount
-	Hourly        ForgetPolicyCount
-	Daily         ForgetPolicyCount
-	Weekly        ForgetPolicyCount
-	Monthly       ForgetPolicyCount
-	Yearly        ForgetPolicyCount
-	Within        data.Duration
-	WithinHourly  data.Duration
-	WithinDaily   data.Duration
-	WithinWeekly  data.Duration
-	WithinMonthly data.Duration
-	WithinYearly  data.Duration
-	KeepTags      data.TagLists
+	Last            ForgetPolicyCount
+	Hourly          ForgetPolicyCount
+	Daily           ForgetPolicyCount
+	Weekly          ForgetPolicyCount
+	Monthly         ForgetPolicyCount
+	Quarterly       ForgetPolicyCount
+	Yearly          ForgetPolicyCount
+	Within          data.Duration
+	WithinHourly    data.Duration
+	WithinDaily     data.Duration
+	WithinWeekly    data.Duration
+	WithinMonthly   data.Duration
+	WithinQuarterly data.Duration
+	WithinYearly    data.Duration
+	MonthlyOnDay    []int
+	KeepCalendar    data.CalendarSpecs
+	KeepTags        data.TagLists
 
 	UnsafeAllowRemoveAll bool
 
@@ -123,22 +133,12 @@ type ForgetOptions struct {
 	GroupBy data.SnapshotGroupByOptions
 	DryRun  bool
 	Prune   bool
+
+	ShowUnreferenced int
 }
 
 func (opts *ForgetOptions) AddFlags(f *pflag.FlagSet) {
-	f.VarP(&opts.Last, "keep-last", "l", "keep the last `n` snapshots (use 'unlimited' to keep all snapshots)")
-	f.VarP(&opts.Hourly, "keep-hourly", "H", "keep the last `n` hourly snapshots (use 'unlimited' to keep all hourly snapshots)")
-	f.VarP(&opts.Daily, "keep-daily", "d", "keep the last `n` daily snapshots (use 'unlimited' to keep all daily snapshots)")
-	f.VarP(&opts.Weekly, "keep-weekly", "w", "keep the last `n` weekly snapshots (use 'unlimited' to keep all weekly snapshots)")
-	f.VarP(&opts.Monthly, "keep-monthly", "m", "keep the last `n` monthly snapshots (use 'unlimited' to keep all monthly snapshots)")
-	f.VarP(&opts.Yearly, "keep-yearly", "y", "keep the last `n` yearly snapshots (use 'unlimited' to keep all yearly snapshots)")
-	f.VarP(&opts.Within, "keep-within", "", "keep snapshots that are newer than `duration` (eg. 1y5m7d2h) relative to the latest snapshot")
-	f.VarP(&opts.WithinHourly, "keep-within-hourly", "", "keep hourly snapshots that are newer than `duration` (eg. 1y5m7d2h) relative to the latest snapshot")
-	f.VarP(&opts.WithinDaily, "keep-within-daily", "", "keep daily snapshots that are newer than `duration` (eg. 1y5m7d2h) relative to the latest snapshot")
-	f.VarP(&opts.WithinWeekly, "keep-within-weekly", "", "keep weekly snapshots that are newer than `duration` (eg. 1y5m7d2h) relative to the latest snapshot")
-	f.VarP(&opts.WithinMonthly, "keep-within-monthly", "", "keep monthly snapshots that are newer than `duration` (eg. 1y5m7d2h) relative to the latest snapshot")
-	f.VarP(&opts.WithinYearly, "keep-within-yearly", "", "keep yearly snapshots that are newer than `duration` (eg. 1y5m7d2h) relative to the latest snapshot")
-	f.Var(&opts.KeepTags, "keep-tag", "keep snapshots with this `taglist` (can be specified multiple times)")
+	opts.addPolicyFlags(f)
 	f.BoolVar(&opts.UnsafeAllowRemoveAll, "unsafe-allow-remove-all", false, "allow deleting all snapshots of a snapshot group")
 
 	f.StringArrayVar(&opts.Hosts, "hostname", nil, "only consider snapshots with the given `hostname` (can be specified multiple times)")
@@ -155,23 +155,76 @@ func (opts *ForgetOptions) AddFlags(f *pflag.FlagSet) {
 	f.VarP(&opts.GroupBy, "group-by", "g", "`group` snapshots by host, paths and/or tags, separated by comma (disable grouping with '')")
 	f.BoolVarP(&opts.DryRun, "dry-run", "n", false, "do not delete anything, just print what would be done")
 	f.BoolVar(&opts.Prune, "prune", false, "automatically run the 'prune' command if snapshots have been removed")
+	f.IntVar(&opts.ShowUnreferenced, "show-unreferenced", 0, "with --dry-run, list the `n` largest files which would no longer be referenced by any snapshot")
 
 	f.SortFlags = false
 }
 
+// addPolicyFlags adds the --keep-* flags, which define the policy.
+func (opts *ForgetOptions) addPolicyFlags(f *pflag.FlagSet) {
+	f.VarP(&opts.Last, "keep-last", "l", "keep the last `n` snapshots (use 'unlimited' to keep all snapshots)")
+	f.VarP(&opts.Hourly, "keep-hourly", "H", "keep the last `n` hourly snapshots (use 'unlimited' to keep all hourly snapshots)")
+	f.VarP(&opts.Daily, "keep-daily", "d", "keep the last `n` daily snapshots (use 'unlimited' to keep all daily snapshots)")
+	f.VarP(&opts.Weekly, "keep-weekly", "w", "keep the last `n` weekly snapshots (use 'unlimited' to keep all weekly snapshots)")
+	f.VarP(&opts.Monthly, "keep-monthly", "m", "keep the last `n` monthly snapshots (use 'unlimited' to keep all monthly snapshots)")
+	f.VarP(&opts.Quarterly, "keep-quarterly", "", "keep the last `n` quarterly snapshots (use 'unlimited' to keep all quarterly snapshots)")
+	f.VarP(&opts.Yearly, "keep-yearly", "y", "keep the last `n` yearly snapshots (use 'unlimited' to keep all yearly snapshots)")
+	f.VarP(&opts.Within, "keep-within", "", "keep snapshots that are newer than `duration` (eg. 1y5m7d2h) relative to the latest snapshot")
+	f.VarP(&opts.WithinHourly, "keep-within-hourly", "", "keep hourly snapshots that are newer than `duration` (eg. 1y5m7d2h) relative to the latest snapshot")
+	f.VarP(&opts.WithinDaily, "keep-within-daily", "", "keep daily snapshots that are newer than `duration` (eg. 1y5m7d2h) relative to the latest snapshot")
+	f.VarP(&opts.WithinWeekly, "keep-within-weekly", "", "keep weekly snapshots that are newer than `duration` (eg. 1y5m7d2h) relative to the latest snapshot")
+	f.VarP(&opts.WithinMonthly, "keep-within-monthly", "", "keep monthly snapshots that are newer than `duration` (eg. 1y5m7d2h) relative to the latest snapshot")
+	f.VarP(&opts.WithinQuarterly, "keep-within-quarterly", "", "keep quarterly snapshots that are newer than `duration` (eg. 1y5m7d2h) relative to the latest snapshot")
+	f.VarP(&opts.WithinYearly, "keep-within-yearly", "", "keep yearly snapshots that are newer than `duration` (eg. 1y5m7d2h) relative to the latest snapshot")
+	f.IntSliceVar(&opts.MonthlyOnDay, "keep-monthly-on-day", nil, "keep the first snapshot made on or after `day` of each month (can be specified multiple times)")
+	f.Var(&opts.KeepCalendar, "keep-calendar", "keep the first snapshot made after each occurrence of the cron-like `schedule` (eg. '0 0 1 1,4,7,10 *', can be specified multiple times)")
+	f.Var(&opts.KeepTags, "keep-tag", "keep snapshots with this `taglist` (can be specified multiple times)")
+}
+
+// expirePolicy returns the policy defined by the --keep-* flags.
+func (opts *ForgetOptions) expirePolicy() data.ExpirePolicy {
+	return data.ExpirePolicy{
+		Last:            int(opts.Last),
+		Hourly:          int(opts.Hourly),
+		Daily:           int(opts.Daily),
+		Weekly:          int(opts.Weekly),
+		Monthly:         int(opts.Monthly),
+		Quarterly:       int(opts.Quarterly),
+		Yearly:          int(opts.Yearly),
+		Within:          opts.Within,
+		WithinHourly:    opts.WithinHourly,
+		WithinDaily:     opts.WithinDaily,
+		WithinWeekly:    opts.WithinWeekly,
+		WithinMonthly:   opts.WithinMonthly,
+		WithinQuarterly: opts.WithinQuarterly,
+		WithinYearly:    opts.WithinYearly,
+		Tags:            opts.KeepTags,
+		Calendar:        opts.KeepCalendar,
+	}
+}
+
 func verifyForgetOptions(opts *ForgetOptions) error {
 	if opts.Last < -1 || opts.Hourly < -1 || opts.Daily < -1 || opts.Weekly < -1 ||
-		opts.Monthly < -1 || opts.Yearly < -1 {
+		opts.Monthly < -1 || opts.Quarterly < -1 || opts.Yearly < -1 {
 		return errors.Fatal("negative values other than -1 are not allowed for --keep-*")
 	}
 
 	for _, d := range []data.Duration{opts.Within, opts.WithinHourly, opts.WithinDaily,
-		opts.WithinMonthly, opts.WithinWeekly, opts.WithinYearly} {
+		opts.WithinMonthly, opts.WithinWeekly, opts.WithinQuarterly, opts.WithinYearly} {
 		if d.Hours < 0 || d.Days < 0 || d.Months < 0 || d.Years < 0 {
 			return errors.Fatal("durations containing negative values are not allowed for --keep-within*")
 		}
 	}
 
+	for _, day := range opts.MonthlyOnDay {
+		spec, err := data.MonthlyOnDay(day)
+		if err != nil {
+			return errors.Fatalf("--keep-monthly-on-day: %v", err)
+		}
+		opts.KeepCalendar = append(opts.KeepCalendar, spec)
+	}
+	opts.MonthlyOnDay = nil
+
 	return nil
 }
 
@@ -189,6 +242,15 @@ func runForget(ctx context.Context, opts ForgetOptions, pruneOptions PruneOption
 	if gopts.NoLock && !opts.DryRun {
 		return errors.Fatal("--no-lock is only applicable in combination with --dry-run for forget command")
 	}
+	if opts.ShowUnreferenced < 0 {
+		return errors.Fatal("--show-unreferenced must not be negative")
+	}
+	if opts.ShowUnreferenced > 0 && !opts.DryRun {
+		return errors.Fatal("--show-unreferenced is only applicable in combination with --dry-run")
+	}
+	if opts.ShowUnreferenced > 0 && gopts.JSON {
+		return errors.Fatal("--show-unreferenced is not supported in combination with --json")
+	}
 
 	printer := progress.NewTerminalPrinter(gopts.JSON, gopts.Verbosity, term)
 	ctx, repo, unlock, err := openWithExclusiveLock(ctx, gopts, opts.DryRun && gopts.NoLock, printer)
@@ -197,10 +259,19 @@ func runForget(ctx context.Context, opts ForgetOptions, pruneOptions PruneOption
 	}
 	defer unlock()
 
+	var snapshotLister restic.Lister = repo
+	if opts.ShowUnreferenced > 0 {
+		// all snapshots are listed again to find the data which is still in use
+		snapshotLister, err = restic.MemorizeList(ctx, repo, restic.SnapshotFile)
+		if err != nil {
+			return err
+		}
+	}
+
 	var snapshots data.Snapshots
 	removeSnIDs := restic.NewIDSet()
 
-	err = opts.SnapshotFilter.FindAll(ctx, repo, repo, args, func(_ string, sn *data.Snapshot, err error) error {
+	err = opts.SnapshotFilter.FindAll(ctx, snapshotLister, repo, args, func(_ string, sn *data.Snapshot, err error) error {
 		if err != nil {
 			return err
 		}
@@ -224,21 +295,7 @@ func runForget(ctx context.Context, opts ForgetOptions, pruneOptions PruneOption
 			return err
 		}
 
-		policy := data.ExpirePolicy{
-			Last:          int(opts.Last),
-			Hourly:        int(opts.Hourly),
-			Daily:         int(opts.Daily),
-			Weekly:        int(opts.Weekly),
-			Monthly:       int(opts.Monthly),
-			Yearly:        int(opts.Yearly),
-			Within:        opts.Within,
-			WithinHourly:  opts.WithinHourly,
-			WithinDaily:   opts.WithinDaily,
-			WithinWeekly:  opts.WithinWeekly,
-			WithinMonthly: opts.WithinMonthly,
-			WithinYearly:  opts.WithinYearly,
-			Tags:          opts.KeepTags,
-		}
+		policy := opts.expirePolicy()
 
 		if policy.Empty() {
 			if opts.UnsafeAllowRemoveAll {
@@ -330,8 +387,26 @@ func runForget(ctx context.Context, opts ForgetOptions, pruneOptions PruneOption
 			if err != nil {
 				return err
 			}
+
+			entry := data.NewAuditLogEntry("forget")
+			entry.Snapshots = removeSnIDs.Sub(failedSnIDs).List()
+			if len(entry.Snapshots) > 0 {
+				if err := recordAuditLog(ctx, repo, entry); err != nil {
+					return err
+				}
+			}
 		} else {
 			printer.P("Would have removed the following snapshots:\n%v\n\n", removeSnIDs)
+
+			if opts.ShowUnreferenced > 0 {
+				files, err := findUnreferencedFiles(ctx, repo, snapshotLister, snapshots, removeSnIDs, opts.ShowUnreferenced, printer)
+				if err != nil {
+					return err
+				}
+				if err := printUnreferencedFiles(gopts.Term.OutputWriter(), files); err != nil {
+					return err
+				}
+			}
 		}
 	}
 
@@ -359,6 +434,118 @@ func runForget(ctx context.Context, opts ForgetOptions, pruneOptions PruneOption
 	return nil
 }
 
+// UnreferencedFile is a file of a removed snapshot whose content is not
+// referenced by any remaining snapshot.
+type UnreferencedFile struct {
+	Path     string
+	Snapshot restic.ID
+	// Size is the size of the unreferenced content as stored in the repository
+	Size uint64
+}
+
+// findUnreferencedFiles returns the n files of the snapshots in removeSnIDs
+// with the largest amount of content which is not referenced by the other
+// snapshots. If a path exists in several removed snapshots, only the version
+// with the most unreferenced content is returned.
+func findUnreferencedFiles(ctx context.Context, repo *repository.Repository, snapshotLister restic.Lister, snapshots data.Snapshots, removeSnIDs restic.IDSet, n int, printer restic.Printer) ([]UnreferencedFile, error) {
+	if err := repo.LoadIndex(ctx, printer); err != nil {
+		return nil, err
+	}
+
+	usedBlobs := restic.NewBlobSet()
+	if _, err := getUsedBlobsFrom(ctx, snapshotLister, repo, usedBlobs, removeSnIDs, printer); err != nil {
+		return nil, err
+	}
+
+	printer.P("finding files which would no longer be referenced")
+	files := make(map[string]UnreferencedFile)
+	visitedTrees := restic.NewIDSet()
+	for _, sn := range snapshots {
+		if !removeSnIDs.Has(*sn.ID()) {
+			continue
+		}
+
+		err := walker.Walk(ctx, repo, *sn.Tree, walker.WalkVisitor{ProcessNode: func(parentTreeID restic.ID, nodepath string, node *data.Node, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if node == nil || node.Type == data.NodeTypeDir {
+				treeID := parentTreeID
+				if node != nil {
+					treeID = *node.Subtree
+				}
+				// the content of trees which are still in use stays referenced,
+				// trees contained in several removed snapshots are only visited once
+				if usedBlobs.Has(restic.BlobHandle{ID: treeID, Type: restic.TreeBlob}) || visitedTrees.Has(treeID) {
+					return walker.ErrSkipNode
+				}
+				visitedTrees.Insert(treeID)
+				return nil
+			}
+			if node.Type != data.NodeTypeFile {
+				return nil
+			}
+
+			var size uint64
+			for _, id := range node.Content {
+				bh := restic.BlobHandle{ID: id, Type: restic.DataBlob}
+				if usedBlobs.Has(bh) {
+					continue
+				}
+				blobSize, _ := repo.LookupBlobSize(bh)
+				size += uint64(blobSize)
+			}
+			if size > 0 && size > files[nodepath].Size {
+				files[nodepath] = UnreferencedFile{Path: nodepath, Snapshot: *sn.ID(), Size: size}
+			}
+			return nil
+		}})
+		if err != nil {
+			return nil, errors.Fatalf("unable to walk snapshot %v: %v", sn.ID().Str(), err)
+		}
+	}
+
+	result := make([]UnreferencedFile, 0, len(files))
+	for _, f := range files {
+		result = append(result, f)
+	}
+	slices.SortFunc(result, func(a, b UnreferencedFile) int {
+		if c := cmp.Compare(b.Size, a.Size); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Path, b.Path)
+	})
+	if len(result) > n {
+		result = result[:n]
+	}
+	return result, nil
+}
+
+func printUnreferencedFiles(stdout io.Writer, files []UnreferencedFile) error {
+	if len(files) == 0 {
+		_, err := fmt.Fprintln(stdout, "No files would become unreferenced.")
+		return err
+	}
+
+	if _, err := fmt.Fprintf(stdout, "The %d largest files which would no longer be referenced:\n", len(files)); err != nil {
+		return err
+	}
+
+	tab := table.New()
+	tab.AddColumn("Size", "{{ .Size }}")
+	tab.AddColumn("Snapshot", "{{ .Snapshot }}")
+	tab.AddColumn("Path", "{{ .Path }}")
+	for _, f := range files {
+		tab.AddRow(struct{ Size, Snapshot, Path string }{ui.FormatBytes(f.Size), f.Snapshot.Str(), f.Path})
+	}
+	if err := tab.Write(stdout); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(stdout)
+	return err
+}
+
 // ForgetGroup helps to print what is forgotten in JSON.
 type ForgetGroup struct {
 	Tags    []string     `json:"tags"`
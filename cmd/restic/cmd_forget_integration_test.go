@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"os"
 	"path/filepath"
 	"strings"
 	"testing"
@@ -64,3 +65,39 @@ func TestRunForgetSafetyNet(t *testing.T) {
 	})
 	testListSnapshots(t, env.gopts, 0)
 }
+
+func TestRunForgetShowUnreferenced(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	target := filepath.Join(env.testdata, "0", "0", "9")
+	testRunBackup(t, "", []string{target}, BackupOptions{}, env.gopts)
+	// the content of the removed file is only referenced by the first snapshot
+	files, err := os.ReadDir(target)
+	rtest.OK(t, err)
+	removed := files[0].Name()
+	rtest.OK(t, os.Remove(filepath.Join(target, removed)))
+	testRunBackup(t, "", []string{target}, BackupOptions{}, env.gopts)
+	testListSnapshots(t, env.gopts, 2)
+
+	opts := ForgetOptions{
+		Last:             1,
+		GroupBy:          data.SnapshotGroupByOptions{Host: true, Path: true},
+		DryRun:           true,
+		ShowUnreferenced: 10,
+	}
+	buf, err := withCaptureStdout(t, env.gopts, func(ctx context.Context, gopts global.Options) error {
+		return runForget(ctx, opts, PruneOptions{MaxUnused: "5%"}, gopts, gopts.Term, nil)
+	})
+	rtest.OK(t, err)
+	rtest.Assert(t, strings.Contains(buf.String(), "largest files which would no longer be referenced"), "missing unreferenced files in output: %v", buf.String())
+	rtest.Assert(t, strings.Contains(buf.String(), "/"+removed+"\n"), "removed file %v missing in output: %v", removed, buf.String())
+	// files which are still contained in the second snapshot are not listed
+	rtest.Assert(t, !strings.Contains(buf.String(), "/"+files[1].Name()+"\n"), "file %v unexpectedly listed: %v", files[1].Name(), buf.String())
+	testListSnapshots(t, env.gopts, 2)
+
+	opts.DryRun = false
+	err = testRunForgetMayFail(t, env.gopts, opts)
+	rtest.Assert(t, err != nil && strings.Contains(err.Error(), "only applicable in combination with --dry-run"), "wrong error %v", err)
+}
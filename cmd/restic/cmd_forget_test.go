@@ -50,6 +50,7 @@ func TestForgetOptionValues(t *testing.T) {
 		{ForgetOptions{Daily: 1}, ""},
 		{ForgetOptions{Weekly: 1}, ""},
 		{ForgetOptions{Monthly: 1}, ""},
+		{ForgetOptions{Quarterly: 1}, ""},
 		{ForgetOptions{Yearly: 1}, ""},
 		{ForgetOptions{Last: 0}, ""},
 		{ForgetOptions{Hourly: 0}, ""},
@@ -62,25 +63,32 @@ func TestForgetOptionValues(t *testing.T) {
 		{ForgetOptions{Daily: -1}, ""},
 		{ForgetOptions{Weekly: -1}, ""},
 		{ForgetOptions{Monthly: -1}, ""},
+		{ForgetOptions{Quarterly: -1}, ""},
 		{ForgetOptions{Yearly: -1}, ""},
 		{ForgetOptions{Last: -2}, negValErrorMsg},
 		{ForgetOptions{Hourly: -2}, negValErrorMsg},
 		{ForgetOptions{Daily: -2}, negValErrorMsg},
 		{ForgetOptions{Weekly: -2}, negValErrorMsg},
 		{ForgetOptions{Monthly: -2}, negValErrorMsg},
+		{ForgetOptions{Quarterly: -2}, negValErrorMsg},
 		{ForgetOptions{Yearly: -2}, negValErrorMsg},
 		{ForgetOptions{Within: data.ParseDurationOrPanic("1y2m3d3h")}, ""},
 		{ForgetOptions{WithinHourly: data.ParseDurationOrPanic("1y2m3d3h")}, ""},
 		{ForgetOptions{WithinDaily: data.ParseDurationOrPanic("1y2m3d3h")}, ""},
 		{ForgetOptions{WithinWeekly: data.ParseDurationOrPanic("1y2m3d3h")}, ""},
 		{ForgetOptions{WithinMonthly: data.ParseDurationOrPanic("2y4m6d8h")}, ""},
+		{ForgetOptions{WithinQuarterly: data.ParseDurationOrPanic("2y4m6d8h")}, ""},
 		{ForgetOptions{WithinYearly: data.ParseDurationOrPanic("2y4m6d8h")}, ""},
 		{ForgetOptions{Within: data.ParseDurationOrPanic("-1y2m3d3h")}, negDurationValErrorMsg},
 		{ForgetOptions{WithinHourly: data.ParseDurationOrPanic("1y-2m3d3h")}, negDurationValErrorMsg},
 		{ForgetOptions{WithinDaily: data.ParseDurationOrPanic("1y2m-3d3h")}, negDurationValErrorMsg},
 		{ForgetOptions{WithinWeekly: data.ParseDurationOrPanic("1y2m3d-3h")}, negDurationValErrorMsg},
 		{ForgetOptions{WithinMonthly: data.ParseDurationOrPanic("-2y4m6d8h")}, negDurationValErrorMsg},
+		{ForgetOptions{WithinQuarterly: data.ParseDurationOrPanic("2y4m-6d8h")}, negDurationValErrorMsg},
 		{ForgetOptions{WithinYearly: data.ParseDurationOrPanic("2y-4m6d8h")}, negDurationValErrorMsg},
+		{ForgetOptions{MonthlyOnDay: []int{1, 15}}, ""},
+		{ForgetOptions{MonthlyOnDay: []int{0}}, "Fatal: --keep-monthly-on-day: invalid day of month 0, must be between 1 and 31"},
+		{ForgetOptions{MonthlyOnDay: []int{32}}, "Fatal: --keep-monthly-on-day: invalid day of month 32, must be between 1 and 31"},
 	}
 
 	for _, testCase := range testCases {
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/restic/restic/internal/data"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/global"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui"
+	"github.com/restic/restic/internal/ui/progress"
+	"github.com/restic/restic/internal/ui/table"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func newGCEstimateCommand(globalOptions *global.Options) *cobra.Command {
+	var opts GCEstimateOptions
+	var pruneOpts PruneOptions
+
+	cmd := &cobra.Command{
+		Use:   "gc-estimate [flags]",
+		Short: "Estimate the space reclaimed by forget policies",
+		Long: `
+The "gc-estimate" command estimates how much space "forget --prune" would
+reclaim for several candidate policies, without modifying the repository.
+
+Each policy is passed to "--policy" using the "--keep-*" options of the
+"forget" command, for example "--policy '--keep-daily 7 --keep-weekly 4'". The
+snapshots are grouped and filtered like for the "forget" command. For each
+policy, restic then plans a prune run which ignores the snapshots removed by
+the policy. As data that is still referenced by a remaining snapshot is not
+removed, the estimate accounts for the deduplication between snapshots. The
+"--max-unused" and related options are taken into account the same way as by
+"prune".
+
+Planning a prune run requires reading all snapshots for each policy, which can
+take a while for large repositories.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful.
+Exit status is 1 if there was any error.
+Exit status is 10 if the repository does not exist.
+Exit status is 11 if the repository is already locked.
+Exit status is 12 if the password is incorrect.
+`,
+		GroupID:           cmdGroupAdvanced,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			finalizeSnapshotFilter(&opts.SnapshotFilter)
+			return runGCEstimate(cmd.Context(), opts, pruneOpts, *globalOptions, globalOptions.Term)
+		},
+	}
+
+	opts.AddFlags(cmd.Flags())
+	pruneOpts.AddLimitedFlags(cmd.Flags())
+	return cmd
+}
+
+// GCEstimateOptions collects all options for the gc-estimate command.
+type GCEstimateOptions struct {
+	Policies []string
+
+	data.SnapshotFilter
+	GroupBy data.SnapshotGroupByOptions
+}
+
+func (opts *GCEstimateOptions) AddFlags(f *pflag.FlagSet) {
+	f.StringArrayVar(&opts.Policies, "policy", nil, "estimate the space reclaimed by the `policy` given as --keep-* options of the forget command (can be specified multiple times)")
+	initMultiSnapshotFilter(f, &opts.SnapshotFilter, false)
+	opts.GroupBy = data.SnapshotGroupByOptions{Host: true, Path: true}
+	f.VarP(&opts.GroupBy, "group-by", "g", "`group` snapshots by host, paths and/or tags, separated by comma (disable grouping with '')")
+}
+
+// parseGCEstimatePolicy parses a policy given as --keep-* options of the
+// forget command.
+func parseGCEstimatePolicy(s string) (data.ExpirePolicy, error) {
+	var opts ForgetOptions
+	f := pflag.NewFlagSet("policy", pflag.ContinueOnError)
+	f.SetOutput(io.Discard)
+	opts.addPolicyFlags(f)
+
+	if err := f.Parse(strings.Fields(s)); err != nil {
+		return data.ExpirePolicy{}, errors.Fatalf("invalid policy %q: %v", s, err)
+	}
+	if f.NArg() > 0 {
+		return data.ExpirePolicy{}, errors.Fatalf("invalid policy %q: unexpected argument %q", s, f.Arg(0))
+	}
+	if err := verifyForgetOptions(&opts); err != nil {
+		return data.ExpirePolicy{}, err
+	}
+
+	policy := opts.expirePolicy()
+	if policy.Empty() {
+		return data.ExpirePolicy{}, errors.Fatalf("invalid policy %q: no --keep-* option was specified", s)
+	}
+	return policy, nil
+}
+
+// GCEstimate is the estimate for a single policy.
+type GCEstimate struct {
+	Policy           string `json:"policy"`
+	SnapshotsKept    int    `json:"snapshots_kept"`
+	SnapshotsRemoved int    `json:"snapshots_removed"`
+	// Reclaimed is the size of the data removed from the repository, Repack
+	// the size of the data which must be rewritten for that.
+	Reclaimed    uint64 `json:"reclaimed"`
+	Repack       uint64 `json:"repack"`
+	Remaining    uint64 `json:"remaining"`
+	RemainUnused uint64 `json:"remaining_unused"`
+}
+
+func runGCEstimate(ctx context.Context, opts GCEstimateOptions, pruneOpts PruneOptions, gopts global.Options, term ui.Terminal) error {
+	if len(opts.Policies) == 0 {
+		return errors.Fatal("no policy was specified, use --policy to pass at least one policy")
+	}
+
+	policies := make([]data.ExpirePolicy, 0, len(opts.Policies))
+	for _, s := range opts.Policies {
+		policy, err := parseGCEstimatePolicy(s)
+		if err != nil {
+			return err
+		}
+		policies = append(policies, policy)
+	}
+
+	if err := verifyPruneOptions(&pruneOpts); err != nil {
+		return err
+	}
+	pruneOpts.DryRun = true
+
+	printer := progress.NewTerminalPrinter(gopts.JSON, gopts.Verbosity, term)
+	// like "forget --dry-run --prune", the estimate requires an exclusive lock
+	// to ensure that the snapshots match the index
+	ctx, repo, unlock, err := openWithExclusiveLock(ctx, gopts, gopts.NoLock, printer)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// the snapshots are listed only once, such that all policies are
+	// estimated for the same set of snapshots
+	snapshotLister, err := restic.MemorizeList(ctx, repo, restic.SnapshotFile)
+	if err != nil {
+		return err
+	}
+
+	var snapshots data.Snapshots
+	err = opts.SnapshotFilter.FindAll(ctx, snapshotLister, repo, nil, func(_ string, sn *data.Snapshot, err error) error {
+		if err != nil {
+			return err
+		}
+		snapshots = append(snapshots, sn)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	snapshotGroups, _, err := data.GroupSnapshots(snapshots, opts.GroupBy)
+	if err != nil {
+		return err
+	}
+
+	if err := repo.LoadIndex(ctx, printer); err != nil {
+		return err
+	}
+
+	estimates := make([]GCEstimate, 0, len(policies))
+	for i, policy := range policies {
+		printer.P("estimating policy %d of %d: %v", i+1, len(policies), policy)
+		estimate, err := estimatePolicy(ctx, repo, snapshotLister, snapshotGroups, policy, pruneOpts, printer)
+		if err != nil {
+			return err
+		}
+		estimates = append(estimates, estimate)
+	}
+
+	if gopts.JSON {
+		return json.NewEncoder(gopts.Term.OutputWriter()).Encode(estimates)
+	}
+
+	type row struct {
+		Policy    string
+		Kept      int
+		Removed   int
+		Reclaimed string
+		Repack    string
+		Remaining string
+	}
+
+	tab := table.New()
+	tab.AddColumn("Policy", "{{ .Policy }}")
+	tab.AddColumn("Kept", "{{ .Kept }}")
+	tab.AddColumn("Removed", "{{ .Removed }}")
+	tab.AddColumn("Reclaimed", "{{ .Reclaimed }}")
+	tab.AddColumn("Repack", "{{ .Repack }}")
+	tab.AddColumn("Remaining", "{{ .Remaining }}")
+	for _, e := range estimates {
+		tab.AddRow(row{
+			Policy:    e.Policy,
+			Kept:      e.SnapshotsKept,
+			Removed:   e.SnapshotsRemoved,
+			Reclaimed: ui.FormatBytes(e.Reclaimed),
+			Repack:    ui.FormatBytes(e.Repack),
+			Remaining: ui.FormatBytes(e.Remaining),
+		})
+	}
+	return tab.Write(gopts.Term.OutputWriter())
+}
+
+// estimatePolicy applies policy to all snapshot groups and plans a prune run
+// which ignores the removed snapshots.
+func estimatePolicy(ctx context.Context, repo *repository.Repository, snapshotLister restic.Lister, snapshotGroups map[string]data.Snapshots, policy data.ExpirePolicy, pruneOpts PruneOptions, printer restic.Printer) (GCEstimate, error) {
+	estimate := GCEstimate{Policy: policy.String()}
+	removeSnIDs := restic.NewIDSet()
+	for k, snapshotGroup := range snapshotGroups {
+		keep, remove, _ := data.ApplyPolicy(snapshotGroup, policy)
+		if len(keep) == 0 {
+			var key data.SnapshotGroupKey
+			if err := json.Unmarshal([]byte(k), &key); err != nil {
+				return estimate, err
+			}
+			return estimate, errors.Fatalf("policy %v would delete all snapshots of snapshot group \"%v\"", policy, key.String())
+		}
+		estimate.SnapshotsKept += len(keep)
+		estimate.SnapshotsRemoved += len(remove)
+		for _, sn := range remove {
+			removeSnIDs.Insert(*sn.ID())
+		}
+	}
+
+	plan, err := repository.PlanPrune(ctx, repositoryPruneOptions(pruneOpts, repo), repo, func(ctx context.Context, repo restic.Repository, usedBlobs restic.FindBlobSet) error {
+		_, err := getUsedBlobsFrom(ctx, snapshotLister, repo, usedBlobs, removeSnIDs, printer)
+		return err
+	}, printer)
+	if err != nil {
+		return estimate, err
+	}
+
+	stats := plan.Stats()
+	estimate.Reclaimed = stats.Size.RemoveTotal
+	estimate.Repack = stats.Size.Repack
+	estimate.Remaining = stats.Size.Remain
+	estimate.RemainUnused = stats.Size.RemainUnused
+	return estimate, nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/restic/restic/internal/data"
+	"github.com/restic/restic/internal/global"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func testRunGCEstimate(t testing.TB, gopts global.Options, opts GCEstimateOptions) []GCEstimate {
+	pruneOpts := PruneOptions{
+		MaxUnused: "0",
+	}
+	opts.GroupBy = data.SnapshotGroupByOptions{Host: true, Path: true}
+	buf, err := withCaptureStdout(t, gopts, func(ctx context.Context, gopts global.Options) error {
+		gopts.JSON = true
+		return runGCEstimate(ctx, opts, pruneOpts, gopts, gopts.Term)
+	})
+	rtest.OK(t, err)
+
+	var estimates []GCEstimate
+	rtest.OK(t, json.Unmarshal(buf.Bytes(), &estimates))
+	return estimates
+}
+
+func TestGCEstimate(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	target := filepath.Join(env.testdata, "0", "0", "9")
+	testRunBackup(t, "", []string{target}, BackupOptions{}, env.gopts)
+	// the data of the removed file is only referenced by the first snapshot
+	files, err := os.ReadDir(target)
+	rtest.OK(t, err)
+	rtest.OK(t, os.Remove(filepath.Join(target, files[0].Name())))
+	testRunBackup(t, "", []string{target}, BackupOptions{}, env.gopts)
+	testListSnapshots(t, env.gopts, 2)
+
+	// planning a prune run lists the pack files once for each policy
+	env.gopts.BackendTestHook = nil
+	estimates := testRunGCEstimate(t, env.gopts, GCEstimateOptions{
+		Policies: []string{"--keep-last 2", "--keep-last=1"},
+	})
+	rtest.Equals(t, 2, len(estimates))
+
+	rtest.Equals(t, 2, estimates[0].SnapshotsKept)
+	rtest.Equals(t, 0, estimates[0].SnapshotsRemoved)
+	rtest.Equals(t, uint64(0), estimates[0].Reclaimed)
+
+	rtest.Equals(t, 1, estimates[1].SnapshotsKept)
+	rtest.Equals(t, 1, estimates[1].SnapshotsRemoved)
+	rtest.Assert(t, estimates[1].Reclaimed > 0, "expected reclaimed space for --keep-last 1")
+	rtest.Equals(t, estimates[0].Remaining, estimates[1].Remaining+estimates[1].Reclaimed)
+
+	// the estimate does not modify the repository
+	testListSnapshots(t, env.gopts, 2)
+}
+
+func TestGCEstimateInvalidPolicy(t *testing.T) {
+	for _, policy := range []string{"", "--keep-daily", "--keep-daily 7 extra", "--keep-last -2", "--host foo"} {
+		_, err := parseGCEstimatePolicy(policy)
+		rtest.Assert(t, err != nil, "expected error for policy %q", policy)
+	}
+
+	policy, err := parseGCEstimatePolicy("--keep-daily 7  --keep-tag a,b --keep-within=1y")
+	rtest.OK(t, err)
+	rtest.Equals(t, 7, policy.Daily)
+	rtest.Equals(t, data.TagLists{data.TagList{"a", "b"}}, policy.Tags)
+	rtest.Equals(t, 1, policy.Within.Years)
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"os"
+	"sort"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/global"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui"
+	"github.com/restic/restic/internal/ui/progress"
+	"github.com/spf13/cobra"
+)
+
+func newImportCommand(globalOptions *global.Options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "import volume...",
+		Short: "Import volumes created by the export command",
+		Long: `
+The "import" command stores the files contained in volumes created by the
+"export" command in the repository. Files which already exist in the
+repository are skipped. The content of each file is verified before it is
+stored.
+
+The volumes must have been exported from the same repository. If the
+repository does not exist yet, it is created from the config and keys stored
+in the first volume of a complete export. Afterwards, the repository can be
+opened using the same passwords as the exported repository.
+
+The volumes are imported in the order of their numbers, independent of the
+order in which they are passed to the command. Run "restic check" after
+importing all volumes of an export.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful.
+Exit status is 1 if there was any error.
+Exit status is 10 if the repository does not exist.
+Exit status is 11 if the repository is already locked.
+Exit status is 12 if the password is incorrect.
+`,
+		GroupID:           cmdGroupAdvanced,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runImport(cmd.Context(), *globalOptions, args, globalOptions.Term)
+		},
+	}
+	return cmd
+}
+
+type importVolume struct {
+	filename string
+	manifest *repository.ExportManifest
+}
+
+func runImport(ctx context.Context, gopts global.Options, args []string, term ui.Terminal) error {
+	if len(args) == 0 {
+		return errors.Fatal("no volumes given - please see `restic help import` for usage and flags")
+	}
+
+	printer := progress.NewTerminalPrinter(gopts.JSON, gopts.Verbosity, term)
+
+	volumes := make([]importVolume, 0, len(args))
+	for _, filename := range args {
+		manifest, err := loadExportManifest(filename)
+		if err != nil {
+			return err
+		}
+		volumes = append(volumes, importVolume{filename, manifest})
+	}
+	sort.SliceStable(volumes, func(i, j int) bool {
+		return volumes[i].manifest.Volume < volumes[j].manifest.Volume
+	})
+
+	if err := importRepositoryConfig(ctx, gopts, volumes, printer); err != nil {
+		return err
+	}
+
+	ctx, repo, unlock, err := openWithAppendLock(ctx, gopts, false, printer)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	var total repository.ImportStats
+	for _, volume := range volumes {
+		printer.P("importing volume %d of %d from %v", volume.manifest.Volume, volume.manifest.Volumes, volume.filename)
+		f, err := os.Open(volume.filename)
+		if err != nil {
+			return err
+		}
+		bar := printer.NewCounter("files imported")
+		stats, err := repo.ImportVolume(ctx, f, bar)
+		_ = f.Close()
+		if err != nil {
+			return errors.Fatalf("importing %v failed: %v", volume.filename, err)
+		}
+		total.Files += stats.Files
+		total.Size += stats.Size
+		total.Skipped += stats.Skipped
+	}
+
+	printer.P("imported %d files (%s), skipped %d existing files", total.Files, ui.FormatBytes(total.Size), total.Skipped)
+	return nil
+}
+
+// importRepositoryConfig stores the config and keys of the exported
+// repository if the repository does not exist yet.
+func importRepositoryConfig(ctx context.Context, gopts global.Options, volumes []importVolume, printer restic.Printer) error {
+	be, err := global.OpenBackend(ctx, gopts, printer)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = be.Close()
+	}()
+
+	_, err = be.Stat(ctx, backend.Handle{Type: backend.ConfigFile})
+	if err == nil || !be.IsNotExist(err) {
+		return err
+	}
+
+	for _, volume := range volumes {
+		for _, file := range volume.manifest.Files {
+			if file.Type != backend.ConfigFile.String() {
+				continue
+			}
+
+			printer.P("creating repository %v from %v", volume.manifest.RepositoryID, volume.filename)
+			f, err := os.Open(volume.filename)
+			if err != nil {
+				return err
+			}
+			_, err = repository.ImportConfig(ctx, be, f)
+			_ = f.Close()
+			return err
+		}
+	}
+	return errors.Fatal("the repository does not exist and none of the volumes contains the repository config")
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"math/bits"
 	"strconv"
 
 	"github.com/restic/chunker"
@@ -26,6 +27,13 @@ func newInitCommand(globalOptions *global.Options) *cobra.Command {
 		Long: `
 The "init" command initializes a new repository.
 
+The sizes of the chunks into which files are split can be tuned using
+--chunk-min-size, --chunk-avg-size and --chunk-max-size. Larger chunks reduce
+the size of the index for large files such as VM images, smaller chunks
+improve deduplication of small changes. The chunk sizes are stored in the
+repository config and cannot be changed later on. Custom chunk sizes require
+repository format version 3, see --repository-version.
+
 EXIT STATUS
 ===========
 
@@ -46,12 +54,18 @@ Exit status is 1 if there was any error.
 type InitOptions struct {
 	global.SecondaryRepoOptions
 	CopyChunkerParameters bool
+	ChunkMinSize          string
+	ChunkAvgSize          string
+	ChunkMaxSize          string
 	RepositoryVersion     string
 }
 
 func (opts *InitOptions) AddFlags(f *pflag.FlagSet) {
 	opts.SecondaryRepoOptions.AddFlags(f, "secondary", "to copy chunker parameters from")
 	f.BoolVar(&opts.CopyChunkerParameters, "copy-chunker-params", false, "copy chunker parameters from the secondary repository (useful with the copy command)")
+	f.StringVar(&opts.ChunkMinSize, "chunk-min-size", "", "minimum `size` of chunks (allowed suffixes: k/K, m/M) (default: 512K)")
+	f.StringVar(&opts.ChunkAvgSize, "chunk-avg-size", "", "average `size` of chunks, must be a power of two (allowed suffixes: k/K, m/M) (default: 1M)")
+	f.StringVar(&opts.ChunkMaxSize, "chunk-max-size", "", "maximum `size` of chunks (allowed suffixes: k/K, m/M) (default: 8M)")
 	f.StringVar(&opts.RepositoryVersion, "repository-version", "stable", "repository format version to use, allowed values are a format version, 'latest' and 'stable'")
 }
 
@@ -76,12 +90,16 @@ func runInit(ctx context.Context, opts InitOptions, gopts global.Options, args [
 		version = uint(v)
 	}
 
-	chunkerPolynomial, err := maybeReadChunkerPolynomial(ctx, opts, gopts, printer)
+	if version < 3 && (opts.ChunkMinSize != "" || opts.ChunkAvgSize != "" || opts.ChunkMaxSize != "") {
+		return errors.Fatal("--chunk-min-size, --chunk-avg-size and --chunk-max-size require at least repository format version 3")
+	}
+
+	chunkerPolynomial, chunkerParams, err := maybeReadChunkerParameters(ctx, opts, gopts, printer)
 	if err != nil {
 		return err
 	}
 
-	s, err := global.CreateRepository(ctx, gopts, version, chunkerPolynomial, printer)
+	s, err := global.CreateRepository(ctx, gopts, version, chunkerPolynomial, chunkerParams, printer)
 	if err != nil {
 		return errors.Fatalf("%s", err)
 	}
@@ -108,26 +126,78 @@ func runInit(ctx context.Context, opts InitOptions, gopts global.Options, args [
 	return nil
 }
 
-func maybeReadChunkerPolynomial(ctx context.Context, opts InitOptions, gopts global.Options, printer restic.Printer) (*chunker.Pol, error) {
+func maybeReadChunkerParameters(ctx context.Context, opts InitOptions, gopts global.Options, printer restic.Printer) (*chunker.Pol, *restic.ChunkerParams, error) {
+	customSizes := opts.ChunkMinSize != "" || opts.ChunkAvgSize != "" || opts.ChunkMaxSize != ""
+
 	if opts.CopyChunkerParameters {
+		if customSizes {
+			return nil, nil, errors.Fatal("--copy-chunker-params cannot be combined with --chunk-min-size, --chunk-avg-size or --chunk-max-size")
+		}
+
 		otherGopts, _, err := opts.SecondaryRepoOptions.FillGlobalOpts(ctx, gopts, "secondary")
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		otherRepo, err := global.OpenRepository(ctx, otherGopts, printer)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		pol := otherRepo.Config().ChunkerPolynomial
-		return &pol, nil
+		params := otherRepo.Config().ChunkerParameters()
+		return &pol, &params, nil
 	}
 
 	if opts.Repo != "" || opts.RepositoryFile != "" || opts.LegacyRepo != "" || opts.LegacyRepositoryFile != "" {
-		return nil, errors.Fatal("Secondary repository must only be specified when copying the chunker parameters")
+		return nil, nil, errors.Fatal("Secondary repository must only be specified when copying the chunker parameters")
+	}
+	if !customSizes {
+		return nil, nil, nil
+	}
+
+	params, err := parseChunkerParams(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return nil, &params, nil
+}
+
+// parseChunkerParams returns the chunk sizes configured using the
+// --chunk-*-size options. Options which are not set use the default.
+func parseChunkerParams(opts InitOptions) (restic.ChunkerParams, error) {
+	params := restic.DefaultChunkerParams
+
+	for _, o := range []struct {
+		name  string
+		value string
+		size  *uint
+	}{
+		{"--chunk-min-size", opts.ChunkMinSize, &params.MinSize},
+		{"--chunk-max-size", opts.ChunkMaxSize, &params.MaxSize},
+	} {
+		if o.value == "" {
+			continue
+		}
+		size, err := ui.ParseBytes(o.value)
+		if err != nil || size <= 0 {
+			return params, errors.Fatalf("invalid value %q for %v", o.value, o.name)
+		}
+		*o.size = uint(size)
+	}
+
+	if opts.ChunkAvgSize != "" {
+		size, err := ui.ParseBytes(opts.ChunkAvgSize)
+		if err != nil || size <= 0 || bits.OnesCount64(uint64(size)) != 1 {
+			return params, errors.Fatalf("invalid value %q for --chunk-avg-size, must be a power of two", opts.ChunkAvgSize)
+		}
+		params.AverageBits = uint(bits.TrailingZeros64(uint64(size)))
+	}
+
+	if err := params.Validate(); err != nil {
+		return params, errors.Fatalf("invalid chunk sizes: %v", err)
 	}
-	return nil, nil
+	return params, nil
 }
 
 type initSuccess struct {
@@ -10,15 +10,20 @@ import (
 	"github.com/restic/restic/internal/repository"
 	"github.com/restic/restic/internal/restic"
 	rtest "github.com/restic/restic/internal/test"
+	"github.com/restic/restic/internal/ui/progress"
 )
 
 func testRunInit(t testing.TB, gopts global.Options) {
+	testRunInitWithOpts(t, InitOptions{}, gopts)
+}
+
+func testRunInitWithOpts(t testing.TB, opts InitOptions, gopts global.Options) {
 	repository.TestUseLowSecurityKDFParameters(t)
 	restic.TestDisableCheckPolynomial(t)
 	repository.TestSetLockTimeout(t, 0)
 
 	err := withTermStatus(t, gopts, func(ctx context.Context, gopts global.Options) error {
-		return runInit(ctx, InitOptions{}, gopts, nil, gopts.Term)
+		return runInit(ctx, opts, gopts, nil, gopts.Term)
 	})
 	rtest.OK(t, err)
 	t.Logf("repository initialized at %v", gopts.Repo)
@@ -73,3 +78,76 @@ func TestInitCopyChunkerParams(t *testing.T) {
 		"expected equal chunker polynomials, got %v expected %v", repo.Config().ChunkerPolynomial,
 		otherRepo.Config().ChunkerPolynomial)
 }
+
+func TestInitChunkerParams(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	for _, opts := range []InitOptions{
+		{ChunkMinSize: "1k"},
+		{ChunkAvgSize: "700k"},
+		{ChunkAvgSize: "16M"},
+		{ChunkMinSize: "2M", ChunkAvgSize: "1M"},
+		{ChunkMaxSize: "invalid"},
+		{ChunkMinSize: "64k", CopyChunkerParameters: true},
+		// custom chunk sizes require repository version 3
+		{ChunkMinSize: "64k", RepositoryVersion: "2"},
+		{ChunkAvgSize: "2M", RepositoryVersion: "stable"},
+	} {
+		err := withTermStatus(t, env.gopts, func(ctx context.Context, gopts global.Options) error {
+			return runInit(ctx, opts, gopts, nil, gopts.Term)
+		})
+		rtest.Assert(t, err != nil, "expected init with %+v to fail", opts)
+	}
+
+	testRunInitWithOpts(t, InitOptions{ChunkMinSize: "64k", ChunkAvgSize: "128k", ChunkMaxSize: "1M"}, env.gopts)
+	expected := restic.ChunkerParams{MinSize: 64 * 1024, MaxSize: 1024 * 1024, AverageBits: 17}
+
+	rtest.SetupTarTestFixture(t, env.testdata, filepath.Join("testdata", "backup-data.tar.gz"))
+	testRunBackup(t, "", []string{env.testdata}, BackupOptions{}, env.gopts)
+	testRunCheck(t, env.gopts)
+
+	err := withTermStatus(t, env.gopts, func(ctx context.Context, gopts global.Options) error {
+		printer := progress.NewTerminalPrinter(false, gopts.Verbosity, gopts.Term)
+		_, repo, unlock, err := openWithReadLock(ctx, gopts, false, printer)
+		rtest.OK(t, err)
+		defer unlock()
+
+		rtest.Equals(t, expected, repo.Config().ChunkerParameters())
+		rtest.OK(t, repo.LoadIndex(ctx, printer))
+		return repo.ListBlobs(ctx, func(pb restic.PackBlob) {
+			if pb.Handle().Type == restic.DataBlob {
+				rtest.Assert(t, pb.PlaintextLength() <= expected.MaxSize, "blob %v is larger than the maximum chunk size", pb.Handle())
+			}
+		})
+	})
+	rtest.OK(t, err)
+
+	// the chunker parameters are copied along with the polynomial
+	env2, cleanup2 := withTestEnvironment(t)
+	defer cleanup2()
+	copyOpts := InitOptions{
+		SecondaryRepoOptions: global.SecondaryRepoOptions{
+			Repo:     env.gopts.Repo,
+			Password: env.gopts.Password,
+		},
+		CopyChunkerParameters: true,
+		RepositoryVersion:     "2",
+	}
+	err = withTermStatus(t, env2.gopts, func(ctx context.Context, gopts global.Options) error {
+		return runInit(ctx, copyOpts, gopts, nil, gopts.Term)
+	})
+	rtest.Assert(t, err != nil, "expected copying custom chunk sizes to a version 2 repository to fail")
+
+	copyOpts.RepositoryVersion = ""
+	testRunInitWithOpts(t, copyOpts, env2.gopts)
+
+	err = withTermStatus(t, env2.gopts, func(ctx context.Context, gopts global.Options) error {
+		repo, err := global.OpenRepository(ctx, gopts, restic.NewNoopPrinter())
+		if err == nil {
+			rtest.Equals(t, expected, repo.Config().ChunkerParameters())
+		}
+		return err
+	})
+	rtest.OK(t, err)
+}
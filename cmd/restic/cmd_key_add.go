@@ -3,10 +3,13 @@ package main
 import (
 	"context"
 	"fmt"
+	"math"
 
+	"github.com/restic/restic/internal/data"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/global"
 	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/repository/crypto"
 	"github.com/restic/restic/internal/restic"
 	"github.com/restic/restic/internal/ui"
 	"github.com/restic/restic/internal/ui/progress"
@@ -23,6 +26,21 @@ func newKeyAddCommand(globalOptions *global.Options) *cobra.Command {
 		Long: `
 The "key add" command creates a new key and validates the key. Returns the new key ID.
 
+With "--recipient", the new key is encrypted for an X25519 public key in the
+format used by age ("age1...") instead of a password. Such a key can only be
+used with the corresponding identity, which is passed to restic using
+"--identity-file". The new key cannot be validated, as the identity is not
+available.
+
+By default, the key is derived from the password using scrypt, with parameters
+calibrated for the current host. With "--kdf argon2id", Argon2id is used
+instead. Its memory usage and number of iterations can be set using
+"--kdf-memory" and "--kdf-iterations". Note that restic versions without
+Argon2id support cannot open a repository containing such a key.
+
+Note that every key, including a key for a recipient, grants full access to
+the repository once it is decrypted.
+
 EXIT STATUS
 ===========
 
@@ -47,6 +65,10 @@ type KeyAddOptions struct {
 	InsecureNoPassword bool
 	Username           string
 	Hostname           string
+	Recipient          string
+	KDF                string
+	KDFMemory          uint
+	KDFIterations      uint
 }
 
 func (opts *KeyAddOptions) Add(flags *pflag.FlagSet) {
@@ -54,6 +76,37 @@ func (opts *KeyAddOptions) Add(flags *pflag.FlagSet) {
 	flags.BoolVar(&opts.InsecureNoPassword, "new-insecure-no-password", false, "add an empty password for the repository (insecure)")
 	flags.StringVarP(&opts.Username, "user", "", "", "the username for new key")
 	flags.StringVarP(&opts.Hostname, "host", "", "", "the hostname for new key")
+	flags.StringVar(&opts.Recipient, "recipient", "", "encrypt the new key for the X25519 `recipient` (age1...) instead of a password")
+	flags.StringVar(&opts.KDF, "kdf", repository.KDFScrypt, "derive the new key from the password using `kdf` 'scrypt' or 'argon2id'")
+	flags.UintVar(&opts.KDFMemory, "kdf-memory", uint(crypto.DefaultArgon2Params.Memory/1024), "memory in `MiB` used by argon2id")
+	flags.UintVar(&opts.KDFIterations, "kdf-iterations", uint(crypto.DefaultArgon2Params.Time), "number of `iterations` of argon2id")
+}
+
+// kdfOptions returns the KDF selected by the options.
+func (opts *KeyAddOptions) kdfOptions() (repository.KDFOptions, error) {
+	switch opts.KDF {
+	case "", repository.KDFScrypt:
+		return repository.KDFOptions{KDF: repository.KDFScrypt}, nil
+	case repository.KDFArgon2id:
+	default:
+		return repository.KDFOptions{}, errors.Fatalf("invalid --kdf %q, must be %q or %q", opts.KDF, repository.KDFScrypt, repository.KDFArgon2id)
+	}
+
+	params := crypto.DefaultArgon2Params
+	if opts.KDFMemory != 0 {
+		// argon2id expects the memory in KiB as uint32
+		if opts.KDFMemory > math.MaxUint32/1024 {
+			return repository.KDFOptions{}, errors.Fatalf("--kdf-memory %d is too large", opts.KDFMemory)
+		}
+		params.Memory = uint32(opts.KDFMemory) * 1024
+	}
+	if opts.KDFIterations != 0 {
+		if opts.KDFIterations > math.MaxUint32 {
+			return repository.KDFOptions{}, errors.Fatalf("--kdf-iterations %d is too large", opts.KDFIterations)
+		}
+		params.Time = uint32(opts.KDFIterations)
+	}
+	return repository.KDFOptions{KDF: repository.KDFArgon2id, Argon2: params}, nil
 }
 
 func runKeyAdd(ctx context.Context, gopts global.Options, opts KeyAddOptions, args []string, term ui.Terminal) error {
@@ -72,12 +125,21 @@ func runKeyAdd(ctx context.Context, gopts global.Options, opts KeyAddOptions, ar
 }
 
 func addKey(ctx context.Context, repo *repository.Repository, gopts global.Options, opts KeyAddOptions, printer restic.Printer) error {
+	if opts.Recipient != "" {
+		return addRecipientKey(ctx, repo, opts, printer)
+	}
+
+	kdf, err := opts.kdfOptions()
+	if err != nil {
+		return err
+	}
+
 	pw, err := getNewPassword(ctx, gopts, opts.NewPasswordFile, opts.InsecureNoPassword)
 	if err != nil {
 		return err
 	}
 
-	id, err := repository.AddKey(ctx, repo, pw, opts.Username, opts.Hostname, repo.Key())
+	id, err := repository.AddKeyWithKDF(ctx, repo, kdf, pw, opts.Username, opts.Hostname, repo.Key())
 	if err != nil {
 		return errors.Fatalf("creating new key failed: %v", err)
 	}
@@ -89,7 +151,36 @@ func addKey(ctx context.Context, repo *repository.Repository, gopts global.Optio
 
 	printer.P("saved new key with ID %s", id.ID())
 
-	return nil
+	return recordKeyAdd(ctx, repo, id)
+}
+
+func addRecipientKey(ctx context.Context, repo *repository.Repository, opts KeyAddOptions, printer restic.Printer) error {
+	if opts.NewPasswordFile != "" || opts.InsecureNoPassword {
+		return errors.Fatal("--recipient cannot be combined with --new-password-file or --new-insecure-no-password")
+	}
+	if opts.KDF == repository.KDFArgon2id {
+		return errors.Fatal("--recipient cannot be combined with --kdf, the key is not derived from a password")
+	}
+
+	recipient, err := crypto.ParseRecipient(opts.Recipient)
+	if err != nil {
+		return errors.Fatalf("%s", err)
+	}
+
+	id, err := repository.AddRecipientKey(ctx, repo, recipient, opts.Username, opts.Hostname, repo.Key())
+	if err != nil {
+		return errors.Fatalf("creating new key failed: %v", err)
+	}
+
+	printer.P("saved new key with ID %s for recipient %s", id.ID(), recipient)
+
+	return recordKeyAdd(ctx, repo, id)
+}
+
+func recordKeyAdd(ctx context.Context, repo *repository.Repository, key *repository.Key) error {
+	entry := data.NewAuditLogEntry("key add")
+	entry.Keys = restic.IDs{key.ID()}
+	return recordAuditLog(ctx, repo, entry)
 }
 
 // testKeyNewPassword is used to set a new password during integration testing.
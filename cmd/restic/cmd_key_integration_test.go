@@ -13,6 +13,7 @@ import (
 	"github.com/restic/restic/internal/backend"
 	"github.com/restic/restic/internal/global"
 	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/repository/crypto"
 	rtest "github.com/restic/restic/internal/test"
 )
 
@@ -167,6 +168,92 @@ func TestKeyAddRemove(t *testing.T) {
 	testRunKeyAddNewKeyUserHost(t, env.gopts)
 }
 
+func TestKeyAddRecipient(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	// must list keys more than once
+	env.gopts.BackendTestHook = nil
+	defer cleanup()
+	testRunInit(t, env.gopts)
+
+	identity, err := crypto.NewIdentity()
+	rtest.OK(t, err)
+	identityFile := filepath.Join(env.base, "identity")
+	rtest.OK(t, os.WriteFile(identityFile, []byte("# test identity\n"+identity.String()+"\n"), 0o600))
+
+	err = withTermStatus(t, env.gopts, func(ctx context.Context, gopts global.Options) error {
+		return runKeyAdd(ctx, gopts, KeyAddOptions{Recipient: identity.Recipient().String()}, []string{}, gopts.Term)
+	})
+	rtest.OK(t, err)
+
+	// the password must still work
+	testRunCheck(t, env.gopts)
+
+	identityOpts := env.gopts
+	identityOpts.Password = ""
+	identityOpts.IdentityFile = identityFile
+	testRunCheck(t, identityOpts)
+	rtest.Equals(t, 1, len(testRunKeyListOtherIDs(t, identityOpts)))
+
+	other, err := crypto.NewIdentity()
+	rtest.OK(t, err)
+	rtest.OK(t, os.WriteFile(identityFile, []byte(other.String()+"\n"), 0o600))
+	err = withTermStatus(t, identityOpts, func(ctx context.Context, gopts global.Options) error {
+		return runKeyList(ctx, gopts, []string{}, gopts.Term)
+	})
+	rtest.Assert(t, err != nil && strings.Contains(err.Error(), "no key found"), "unexpected error, got %v", err)
+
+	err = withTermStatus(t, env.gopts, func(ctx context.Context, gopts global.Options) error {
+		return runKeyAdd(ctx, gopts, KeyAddOptions{Recipient: "age1invalid"}, []string{}, gopts.Term)
+	})
+	rtest.Assert(t, err != nil && strings.Contains(err.Error(), "malformed recipient"), "unexpected error, got %v", err)
+}
+
+func TestKeyArgon2id(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	// must list keys more than once
+	env.gopts.BackendTestHook = nil
+	defer cleanup()
+	testRunInit(t, env.gopts)
+
+	testKeyNewPassword = "argon2 password"
+	defer func() {
+		testKeyNewPassword = ""
+	}()
+	// use small parameters to keep the test fast
+	opts := KeyAddOptions{KDF: repository.KDFArgon2id, KDFMemory: 1, KDFIterations: 1}
+	err := withTermStatus(t, env.gopts, func(ctx context.Context, gopts global.Options) error {
+		return runKeyAdd(ctx, gopts, opts, []string{}, gopts.Term)
+	})
+	rtest.OK(t, err)
+
+	argonOpts := env.gopts
+	argonOpts.Password = testKeyNewPassword
+	_ = withTermStatus(t, argonOpts, func(ctx context.Context, gopts global.Options) error {
+		repo, err := global.OpenRepository(ctx, gopts, restic.NewNoopPrinter())
+		rtest.OK(t, err)
+		key, err := repository.LoadKey(ctx, repo, repo.KeyID())
+		rtest.OK(t, err)
+		rtest.Equals(t, repository.KDFArgon2id, key.KDF)
+		rtest.Equals(t, 1024, key.Memory)
+		rtest.Equals(t, 1, key.Time)
+		return nil
+	})
+
+	// the original password still works and can be upgraded to argon2id
+	testKeyNewPassword = env.gopts.Password
+	err = withTermStatus(t, env.gopts, func(ctx context.Context, gopts global.Options) error {
+		return runKeyPasswd(ctx, gopts, KeyPasswdOptions{KeyAddOptions: opts}, []string{}, gopts.Term)
+	})
+	rtest.OK(t, err)
+	testRunCheck(t, env.gopts)
+	testRunCheck(t, argonOpts)
+
+	err = withTermStatus(t, env.gopts, func(ctx context.Context, gopts global.Options) error {
+		return runKeyAdd(ctx, gopts, KeyAddOptions{KDF: "bcrypt"}, []string{}, gopts.Term)
+	})
+	rtest.Assert(t, err != nil && strings.Contains(err.Error(), "invalid --kdf"), "unexpected error, got %v", err)
+}
+
 func TestKeyAddInvalid(t *testing.T) {
 	env, cleanup := withTestEnvironment(t)
 	defer cleanup()
@@ -292,3 +379,52 @@ func TestKeyCommandInvalidArguments(t *testing.T) {
 	t.Log(err)
 	rtest.Assert(t, err != nil && strings.Contains(err.Error(), "one argument"), "unexpected error for key remove: %v", err)
 }
+
+func testRunKeyRotateMaster(t testing.TB, gopts global.Options, opts KeyRotateMasterOptions) string {
+	gopts.Quiet = false
+	gopts.Verbosity = 1
+	buf, err := withCaptureStdout(t, gopts, func(ctx context.Context, gopts global.Options) error {
+		return runKeyRotateMaster(ctx, gopts, opts, []string{}, gopts.Term)
+	})
+	rtest.OK(t, err)
+	return buf.String()
+}
+
+func TestKeyRotateMaster(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	// must list files more than once
+	env.gopts.BackendTestHook = nil
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	rtest.OK(t, withTermStatus(t, env.gopts, func(ctx context.Context, gopts global.Options) error {
+		return runAuditLogInit(ctx, gopts, nil, gopts.Term)
+	}))
+	testRunBackup(t, filepath.Dir(env.testdata), []string{filepath.Base(env.testdata)}, BackupOptions{}, env.gopts)
+	testRunKeyAddNewKey(t, "other password", env.gopts)
+	rtest.Equals(t, 1, len(testRunKeyListOtherIDs(t, env.gopts)))
+
+	// the other key is only removed on request
+	rtest.Assert(t, withTermStatus(t, env.gopts, func(ctx context.Context, gopts global.Options) error {
+		return runKeyRotateMaster(ctx, gopts, KeyRotateMasterOptions{}, nil, gopts.Term)
+	}) != nil, "expected error for other keys")
+	rtest.Equals(t, 1, len(testRunKeyListOtherIDs(t, env.gopts)))
+
+	out := testRunKeyRotateMaster(t, env.gopts, KeyRotateMasterOptions{MaxRepackSize: "1", RemoveOtherKeys: true})
+	rtest.Assert(t, strings.Contains(out, "add it again using `restic key add`"), "missing removed key in output: %q", out)
+	rtest.Assert(t, !strings.Contains(out, "rotation is complete"), "expected unfinished rotation: %q", out)
+	rtest.Equals(t, 0, len(testRunKeyListOtherIDs(t, env.gopts)))
+	testRunCheck(t, env.gopts)
+
+	for i := 0; !strings.Contains(out, "rotation is complete"); i++ {
+		rtest.Assert(t, i < 10, "rotation did not finish: %q", out)
+		out = testRunKeyRotateMaster(t, env.gopts, KeyRotateMasterOptions{})
+	}
+	testRunCheck(t, env.gopts)
+	rtest.OK(t, testRunAuditLogVerify(t, env.gopts, AuditLogVerifyOptions{}))
+
+	restoredir := filepath.Join(env.base, "restore")
+	testRunRestoreLatest(t, env.gopts, restoredir, nil, nil)
+	diff := directoriesContentsDiff(t, env.testdata, filepath.Join(restoredir, filepath.Base(env.testdata)))
+	rtest.Assert(t, diff == "", "directories are not equal: %v", diff)
+}
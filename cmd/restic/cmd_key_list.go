@@ -58,12 +58,14 @@ func runKeyList(ctx context.Context, gopts global.Options, args []string, term u
 
 func listKeys(ctx context.Context, s *repository.Repository, gopts global.Options, printer restic.Printer) error {
 	type keyInfo struct {
-		Current  bool   `json:"current"`
-		ID       string `json:"id"`
-		ShortID  string `json:"-"`
-		UserName string `json:"userName"`
-		HostName string `json:"hostName"`
-		Created  string `json:"created"`
+		Current   bool   `json:"current"`
+		ID        string `json:"id"`
+		ShortID   string `json:"-"`
+		UserName  string `json:"userName"`
+		HostName  string `json:"hostName"`
+		Created   string `json:"created"`
+		KDF       string `json:"kdf"`
+		Recipient string `json:"recipient,omitempty"`
 	}
 
 	var m sync.Mutex
@@ -77,12 +79,14 @@ func listKeys(ctx context.Context, s *repository.Repository, gopts global.Option
 		}
 
 		key := keyInfo{
-			Current:  id == s.KeyID(),
-			ID:       id.String(),
-			ShortID:  id.Str(),
-			UserName: k.Username,
-			HostName: k.Hostname,
-			Created:  k.Created.Local().Format(global.TimeFormat),
+			Current:   id == s.KeyID(),
+			ID:        id.String(),
+			ShortID:   id.Str(),
+			UserName:  k.Username,
+			HostName:  k.Hostname,
+			Created:   k.Created.Local().Format(global.TimeFormat),
+			KDF:       k.KDF,
+			Recipient: k.Recipient,
 		}
 
 		m.Lock()
@@ -104,6 +108,7 @@ func listKeys(ctx context.Context, s *repository.Repository, gopts global.Option
 	tab.AddColumn("User", "{{ .UserName }}")
 	tab.AddColumn("Host", "{{ .HostName }}")
 	tab.AddColumn("Created", "{{ .Created }}")
+	tab.AddColumn("KDF", "{{ .KDF }}")
 
 	for _, key := range keys {
 		tab.AddRow(key)
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/restic/restic/internal/data"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/global"
 	"github.com/restic/restic/internal/repository"
@@ -24,6 +25,9 @@ func newKeyPasswdCommand(globalOptions *global.Options) *cobra.Command {
 The "key passwd" command creates a new key, validates the key and removes the old key ID.
 Returns the new key ID.
 
+Use "--kdf argon2id" to upgrade the key to the Argon2id key derivation function.
+The password can stay the same in this case.
+
 EXIT STATUS
 ===========
 
@@ -67,12 +71,17 @@ func runKeyPasswd(ctx context.Context, gopts global.Options, opts KeyPasswdOptio
 }
 
 func changePassword(ctx context.Context, repo *repository.Repository, gopts global.Options, opts KeyPasswdOptions, printer restic.Printer) error {
+	kdf, err := opts.kdfOptions()
+	if err != nil {
+		return err
+	}
+
 	pw, err := getNewPassword(ctx, gopts, opts.NewPasswordFile, opts.InsecureNoPassword)
 	if err != nil {
 		return err
 	}
 
-	id, err := repository.AddKey(ctx, repo, pw, opts.Username, opts.Hostname, repo.Key())
+	id, err := repository.AddKeyWithKDF(ctx, repo, kdf, pw, opts.Username, opts.Hostname, repo.Key())
 	if err != nil {
 		return errors.Fatalf("creating new key failed: %v", err)
 	}
@@ -90,5 +99,8 @@ func changePassword(ctx context.Context, repo *repository.Repository, gopts glob
 
 	printer.P("saved new key as %s", id)
 
-	return nil
+	entry := data.NewAuditLogEntry("key passwd")
+	entry.Keys = restic.IDs{id.ID(), oldID}
+	entry.Message = fmt.Sprintf("replaced key %v", oldID.Str())
+	return recordAuditLog(ctx, repo, entry)
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/restic/restic/internal/data"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/global"
 	"github.com/restic/restic/internal/repository"
@@ -69,5 +70,8 @@ func deleteKey(ctx context.Context, repo *repository.Repository, idPrefix string
 	}
 
 	printer.P("removed key %v", id)
-	return nil
+
+	entry := data.NewAuditLogEntry("key remove")
+	entry.Keys = restic.IDs{id}
+	return recordAuditLog(ctx, repo, entry)
 }
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/restic/restic/internal/data"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/global"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui"
+	"github.com/restic/restic/internal/ui/progress"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func newKeyRotateMasterCommand(globalOptions *global.Options) *cobra.Command {
+	var opts KeyRotateMasterOptions
+
+	cmd := &cobra.Command{
+		Use:   "rotate-master",
+		Short: "Replace the master key and re-encrypt the repository",
+		Long: `
+The "key rotate-master" command replaces the master key which encrypts all data
+in the repository, for example after the master key may have been exposed.
+
+The command requires repository format version 3, run "restic migrate
+upgrade_repo_v3" to upgrade a repository.
+
+The first run generates a new master key and stores it in a new key file which
+uses the password (or identity) used to open the repository. All other keys can
+only decrypt the old master key. The command refuses to start if other keys
+exist, unless "--remove-other-keys" is specified, in which case they are
+removed. Add them again afterwards using "restic key add". Then the index,
+snapshot and audit log files are re-encrypted. Note that this changes the IDs
+of all snapshots.
+
+Afterwards the pack files are re-encrypted by repacking them. Use
+"--max-repack-size" to spread this over several runs, the progress is stored in
+the repository. Until the rotation is finished, restic reads data encrypted
+using either master key, older restic versions cannot read the repository. Run
+the command again until it reports that the rotation is complete.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful.
+Exit status is 1 if there was any error.
+Exit status is 10 if the repository does not exist.
+Exit status is 11 if the repository is already locked.
+Exit status is 12 if the password is incorrect.
+	`,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runKeyRotateMaster(cmd.Context(), *globalOptions, opts, args, globalOptions.Term)
+		},
+	}
+
+	opts.AddFlags(cmd.Flags())
+	return cmd
+}
+
+// KeyRotateMasterOptions collects all options for the key rotate-master command.
+type KeyRotateMasterOptions struct {
+	MaxRepackSize   string
+	RemoveOtherKeys bool
+}
+
+func (opts *KeyRotateMasterOptions) AddFlags(f *pflag.FlagSet) {
+	f.StringVar(&opts.MaxRepackSize, "max-repack-size", "", "stop after re-encrypting this much pack data in one run (allowed suffixes for `size`: k/K, m/M, g/G, t/T)")
+	f.BoolVar(&opts.RemoveOtherKeys, "remove-other-keys", false, "remove all other keys when starting the rotation, as they cannot decrypt the new master key")
+}
+
+func runKeyRotateMaster(ctx context.Context, gopts global.Options, opts KeyRotateMasterOptions, args []string, term ui.Terminal) error {
+	if len(args) > 0 {
+		return fmt.Errorf("the key rotate-master command expects no arguments, only options - please see `restic help key rotate-master` for usage and flags")
+	}
+
+	var maxRepackBytes uint64
+	if opts.MaxRepackSize != "" {
+		size, err := ui.ParseBytes(opts.MaxRepackSize)
+		if err != nil {
+			return errors.Fatalf("invalid value for --max-repack-size: %v", err)
+		}
+		if size <= 0 {
+			return errors.Fatal("--max-repack-size must be positive")
+		}
+		maxRepackBytes = uint64(size)
+	}
+
+	printer := progress.NewTerminalPrinter(false, gopts.Verbosity, term)
+	ctx, repo, unlock, err := openWithExclusiveLock(ctx, gopts, false, printer)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	stats, err := repo.RotateMasterKey(ctx, repository.RotateMasterKeyOptions{
+		MaxRepackBytes:  maxRepackBytes,
+		RemoveOtherKeys: opts.RemoveOtherKeys,
+		ReencryptAuditLog: func(ctx context.Context, snapshots map[restic.ID]restic.ID) error {
+			return data.ReencryptAuditLog(ctx, repo, snapshots)
+		},
+	}, printer)
+	if stats.Started {
+		printer.P("saved new master key in key %s", repo.KeyID())
+		for _, id := range stats.RemovedKeys {
+			printer.P("removed key %s, add it again using `restic key add`", id)
+		}
+	}
+	if err != nil {
+		return errors.Fatalf("rotating the master key failed: %v", err)
+	}
+
+	if stats.ReencryptedFiles > 0 {
+		printer.P("re-encrypted %d index, snapshot and audit log files", stats.ReencryptedFiles)
+	}
+	printer.P("re-encrypted %d pack files, %d remaining", stats.ReencryptedPacks, stats.RemainingPacks)
+	if stats.Finished {
+		printer.P("master key rotation is complete")
+	} else {
+		printer.P("run `restic key rotate-master` again to continue the rotation")
+	}
+
+	entry := data.NewAuditLogEntry("key rotate-master")
+	if stats.Started {
+		entry.Keys = append(restic.IDs{repo.KeyID()}, stats.RemovedKeys...)
+	}
+	entry.Message = fmt.Sprintf("re-encrypted %d pack files, %d remaining", stats.ReencryptedPacks, stats.RemainingPacks)
+	return recordAuditLog(ctx, repo, entry)
+}
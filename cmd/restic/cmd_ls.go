@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"slices"
 	"strings"
@@ -51,6 +52,14 @@ File listings can be sorted by specifying --sort followed by one of the
 sort specifiers '(name|size|time=mtime|atime|ctime|extension)'.
 The sorting can be reversed by specifying --reverse.
 
+The --max-depth option limits the listing to entries which are at most the
+given number of levels below the listed directories or the root directory of
+the snapshot. Subdirectories beyond that depth are not loaded.
+
+With -0/--null, each entry is terminated by a NUL byte instead of a newline
+and the snapshot summary line is omitted. The output can be safely passed to
+commands like "xargs -0", even if paths contain newlines.
+
 EXIT STATUS
 ===========
 
@@ -80,6 +89,8 @@ type LsOptions struct {
 	Ncdu          bool
 	Sort          SortMode
 	Reverse       bool
+	MaxDepth      int
+	Null          bool
 }
 
 func (opts *LsOptions) AddFlags(f *pflag.FlagSet) {
@@ -90,6 +101,8 @@ func (opts *LsOptions) AddFlags(f *pflag.FlagSet) {
 	f.BoolVar(&opts.Ncdu, "ncdu", false, "output NCDU export format (pipe into 'ncdu -f -')")
 	f.VarP(&opts.Sort, "sort", "s", "sort output by (name|size|time=mtime|atime|ctime|extension)")
 	f.BoolVar(&opts.Reverse, "reverse", false, "reverse sorted output")
+	f.IntVar(&opts.MaxDepth, "max-depth", 0, "only list entries at most `n` levels below the listed directories (default: unlimited)")
+	f.BoolVarP(&opts.Null, "null", "0", false, "terminate each entry with a NUL byte instead of a newline")
 }
 
 type lsPrinter interface {
@@ -313,6 +326,41 @@ func (p *textLsPrinter) Close() error {
 	return nil
 }
 
+// nullLsPrinter prints one entry per record, each terminated by a NUL byte.
+type nullLsPrinter struct {
+	out           io.Writer
+	ListLong      bool
+	HumanReadable bool
+}
+
+func (p *nullLsPrinter) Snapshot(_ *data.Snapshot) error {
+	return nil
+}
+
+func (p *nullLsPrinter) NodeOutput(node lsNodeOutput, isPrefixDirectory bool) error {
+	if isPrefixDirectory {
+		return nil
+	}
+	_, err := fmt.Fprintf(p.out, "%s\x00", formatNodeOutput(node, p.ListLong, p.HumanReadable))
+	return err
+}
+
+func (p *nullLsPrinter) LeaveDir(_ string) error {
+	return nil
+}
+func (p *nullLsPrinter) Close() error {
+	return nil
+}
+
+// pathDepth returns the number of components of the slash-separated path p.
+func pathDepth(p string) int {
+	p = path.Clean(p)
+	if p == "/" {
+		return 0
+	}
+	return strings.Count(p, "/")
+}
+
 func runLs(ctx context.Context, opts LsOptions, gopts global.Options, args []string, term ui.Terminal) error {
 	termPrinter := progress.NewTerminalPrinter(gopts.JSON, gopts.Verbosity, term)
 
@@ -328,6 +376,12 @@ func runLs(ctx context.Context, opts LsOptions, gopts global.Options, args []str
 	if opts.Reverse && opts.Ncdu {
 		return errors.Fatal("--reverse and --ncdu are mutually exclusive")
 	}
+	if opts.Null && (gopts.JSON || opts.Ncdu) {
+		return errors.Fatal("--null cannot be combined with --json or --ncdu")
+	}
+	if opts.MaxDepth < 0 {
+		return errors.Fatal("--max-depth must not be negative")
+	}
 
 	// extract any specific directories to walk
 	var dirs []string
@@ -340,25 +394,35 @@ func runLs(ctx context.Context, opts LsOptions, gopts global.Options, args []str
 		}
 	}
 
-	withinDir := func(nodepath string) bool {
+	// nodeDepth returns the number of levels nodepath is below the deepest
+	// selected directory containing it or -1 if it is not within any of them
+	nodeDepth := func(nodepath string) int {
 		if len(dirs) == 0 {
-			return true
+			return pathDepth(nodepath)
 		}
 
+		depth := -1
 		for _, dir := range dirs {
 			// we're within one of the selected dirs, example:
 			//   nodepath: "/test/foo"
 			//   dir:      "/test"
 			if fs.HasPathPrefix(dir, nodepath) {
-				return true
+				d := pathDepth(nodepath) - pathDepth(dir)
+				if depth < 0 || d < depth {
+					depth = d
+				}
 			}
 		}
-		return false
+		return depth
+	}
+
+	withinMaxDepth := func(depth int) bool {
+		return opts.MaxDepth == 0 || depth <= opts.MaxDepth
 	}
 
 	approachingMatchingTree := func(nodepath string) bool {
 		if len(dirs) == 0 {
-			return true
+			return opts.MaxDepth == 0 || pathDepth(nodepath) < opts.MaxDepth
 		}
 
 		for _, dir := range dirs {
@@ -399,6 +463,12 @@ func runLs(ctx context.Context, opts LsOptions, gopts global.Options, args []str
 		printer = &ncduLsPrinter{
 			out: gopts.Term.OutputWriter(),
 		}
+	} else if opts.Null {
+		printer = &nullLsPrinter{
+			out:           term.OutputRaw(),
+			ListLong:      opts.ListLong,
+			HumanReadable: opts.HumanReadable,
+		}
 	} else {
 		printer = &textLsPrinter{
 			dirs:          dirs,
@@ -438,7 +508,7 @@ func runLs(ctx context.Context, opts LsOptions, gopts global.Options, args []str
 		}
 
 		printedDir := false
-		if withinDir(nodepath) {
+		if depth := nodeDepth(nodepath); depth >= 0 && withinMaxDepth(depth) {
 			// if we're within a target path, print the node
 			if err := printer.NodeOutput(lsNodeOutputFrom(nodepath, node), false); err != nil {
 				return err
@@ -447,7 +517,7 @@ func runLs(ctx context.Context, opts LsOptions, gopts global.Options, args []str
 
 			// if recursive listing is requested, signal the walker that it
 			// should continue walking recursively
-			if opts.Recursive {
+			if opts.Recursive && withinMaxDepth(depth+1) {
 				return nil
 			}
 		}
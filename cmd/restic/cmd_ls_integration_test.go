@@ -5,6 +5,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 
@@ -163,3 +166,59 @@ func TestRunLsJson(t *testing.T) {
 		rtest.Equals(t, pathList[i], testNode.Path)
 	}
 }
+
+func TestRunLsMaxDepthNull(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	// file names on Windows must not contain control characters
+	special := "with\nnewline"
+	if runtime.GOOS == "windows" {
+		special = "with space"
+	}
+
+	testRunInit(t, env.gopts)
+	rtest.OK(t, os.MkdirAll(filepath.Join(env.testdata, "a", "b", "c"), 0755))
+	for _, name := range []string{"top", "a/x", "a/b/y", "a/b/c/" + special} {
+		rtest.OK(t, os.WriteFile(filepath.Join(env.testdata, filepath.FromSlash(name)), []byte(name), 0644))
+	}
+	testRunBackup(t, env.testdata, []string{"."}, BackupOptions{}, env.gopts)
+
+	for _, test := range []struct {
+		opts     LsOptions
+		args     []string
+		expected []string
+	}{
+		{
+			LsOptions{MaxDepth: 1},
+			[]string{"latest"},
+			[]string{"/a", "/top"},
+		},
+		{
+			LsOptions{MaxDepth: 2},
+			[]string{"latest"},
+			[]string{"/a", "/a/b", "/a/x", "/top"},
+		},
+		{
+			LsOptions{MaxDepth: 1, Recursive: true},
+			[]string{"latest", "/a/b"},
+			[]string{"/a/b", "/a/b/c", "/a/b/y"},
+		},
+		{
+			LsOptions{Recursive: true},
+			[]string{"latest", "/a/b"},
+			[]string{"/a/b", "/a/b/c", "/a/b/c/" + special, "/a/b/y"},
+		},
+	} {
+		test.opts.Null = true
+		out := testRunLsWithOpts(t, env.gopts, test.opts, test.args)
+		rtest.Assert(t, bytes.HasSuffix(out, []byte{0}), "output must end with a NUL byte: %q", out)
+		entries := strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00")
+		rtest.Equals(t, test.expected, entries, fmt.Sprintf("%+v %v", test.opts, test.args))
+	}
+
+	err := runLs(context.TODO(), LsOptions{Null: true, Ncdu: true}, env.gopts, []string{"latest"}, env.gopts.Term)
+	rtest.Assert(t, err != nil, "expected error for --null with --ncdu")
+	err = runLs(context.TODO(), LsOptions{MaxDepth: -1}, env.gopts, []string{"latest"}, env.gopts.Term)
+	rtest.Assert(t, err != nil, "expected error for negative --max-depth")
+}
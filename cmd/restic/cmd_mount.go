@@ -5,8 +5,10 @@ package main
 import (
 	"context"
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -21,10 +23,12 @@ import (
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/fs"
 	"github.com/restic/restic/internal/global"
+	"github.com/restic/restic/internal/restic"
 	"github.com/restic/restic/internal/ui"
 	"github.com/restic/restic/internal/ui/progress"
 
 	"github.com/restic/restic/internal/fuse"
+	"github.com/restic/restic/internal/nfs"
 
 	systemFuse "github.com/anacrolix/fuse"
 	fusefs "github.com/anacrolix/fuse/fs"
@@ -38,12 +42,61 @@ func newMountCommand(globalOptions *global.Options) *cobra.Command {
 	var opts MountOptions
 
 	cmd := &cobra.Command{
-		Use:   "mount [flags] mountpoint",
+		Use:   "mount [flags] [mountpoint]",
 		Short: "Mount the repository",
 		Long: `
 The "mount" command mounts the repository read-only via FUSE at the given
 mountpoint.
 
+NFS Export
+==========
+
+With --serve nfs, no FUSE support is required. Instead, restic starts an NFS
+version 3 server on the address given by --listen, which exports the same
+directory structure read-only. No mountpoint is passed to restic, the export
+is mounted using the NFS client of the operating system. As no portmapper is
+used, the client must be configured to use the port printed by restic for
+both the MOUNT and the NFS protocol, for example on Linux:
+
+    mount -t nfs -o vers=3,proto=tcp,port=PORT,mountport=PORT,mountproto=tcp,nolock,ro localhost:/ /mnt/restic
+
+The server does not authenticate clients. Only listen on other addresses than
+localhost in a trusted network. Like the FUSE mount, the NFS export is only
+available on Linux, macOS and FreeBSD.
+
+Writable Mounts
+===============
+
+With --scratch-dir, the files and directories within snapshots can be modified,
+for example to boot a virtual machine from a disk image or to run tools which
+expect a writable directory. The repository is never modified, instead all
+changes are stored in the scratch directory. A file is restored to the scratch
+directory when it is opened for writing for the first time. Items of a snapshot
+cannot be renamed, tools like "mv" copy them instead. The changes are visible
+again when mounting the repository with the same scratch directory later on.
+
+Ownership and Permissions
+=========================
+
+By default, files and directories are reported with the owner, group and
+permissions stored in the snapshot. When browsing a snapshot as a regular user,
+files owned by other users may therefore not be readable. Use --squash-uid and
+--squash-gid to report a fixed owner and group for all files and directories,
+and --default-perms to replace their permissions with the given octal mode, for
+example:
+
+    restic mount --squash-uid $(id -u) --squash-gid $(id -g) --default-perms 0640 /mnt/restic
+
+Directories and executable files additionally get the execute permission for
+each read permission of --default-perms. The options only change what is
+reported by the mount, files are restored with their original metadata.
+
+Snapshot Diffs
+==============
+
+The directory "diff/<snapA>..<snapB>" in the mountpoint contains only the files
+which were added or modified in snapshot snapB compared to snapA.
+
 Snapshot Directories
 ====================
 
@@ -76,6 +129,25 @@ The default path templates are:
     "hosts/%h/%T"
     "tags/%t/%T"
 
+Path templates can also use the Go template syntax, which is selected if the
+template contains "{{". The following fields are available:
+    {{.ShortID}}    short snapshot ID
+    {{.ID}}         long snapshot ID
+    {{.Username}}   username
+    {{.Host}}       hostname
+    {{.Tag}}        tag, creates one directory per tag of the snapshot
+    {{.Tags}}       list of all tags
+    {{.Paths}}      list of all backed up paths
+    {{.Time}}       time of the snapshot, e.g. {{.Time.Format "2006/01"}}
+    {{.Timestamp}}  timestamp as specified by --time-template
+
+Example:
+
+    --path-template '{{.Host}}/{{.Time.Format "2006-01"}}/{{.ShortID}}'
+
+If the last path element only depends on the time, a "latest" link is created
+like for templates ending with %T.
+
 EXIT STATUS
 ===========
 
@@ -105,12 +177,21 @@ type MountOptions struct {
 	data.SnapshotFilter
 	TimeTemplate  string
 	PathTemplates []string
+	ScratchDir    string
+	Serve         string
+	Listen        string
+	SquashUID     string
+	SquashGID     string
+	DefaultPerms  string
 }
 
 func (opts *MountOptions) AddFlags(f *pflag.FlagSet) {
 	f.BoolVar(&opts.OwnerRoot, "owner-root", false, "use 'root' as the owner of files and dirs")
 	f.BoolVar(&opts.AllowOther, "allow-other", false, "allow other users to access the data in the mounted directory")
 	f.BoolVar(&opts.NoDefaultPermissions, "no-default-permissions", false, "for 'allow-other', ignore Unix permissions and allow users to read all snapshot files")
+	f.StringVar(&opts.SquashUID, "squash-uid", "", "report `uid` as the owner of all files and dirs")
+	f.StringVar(&opts.SquashGID, "squash-gid", "", "report `gid` as the group of all files and dirs")
+	f.StringVar(&opts.DefaultPerms, "default-perms", "", "replace the permissions of all files and dirs with the octal `mode`")
 
 	initMultiSnapshotFilter(f, &opts.SnapshotFilter, true)
 
@@ -118,6 +199,9 @@ func (opts *MountOptions) AddFlags(f *pflag.FlagSet) {
 	f.StringVar(&opts.TimeTemplate, "snapshot-template", time.RFC3339, "set `template` to use for snapshot dirs")
 	f.StringVar(&opts.TimeTemplate, "time-template", time.RFC3339, "set `template` to use for times")
 	_ = f.MarkDeprecated("snapshot-template", "use --time-template")
+	f.StringVar(&opts.ScratchDir, "scratch-dir", "", "make the mount writable and store all modifications in `directory`")
+	f.StringVar(&opts.Serve, "serve", "fuse", "serve the repository via `protocol` (fuse or nfs)")
+	f.StringVar(&opts.Listen, "listen", "localhost:0", "listen on `address` for --serve nfs")
 }
 
 func runMount(ctx context.Context, opts MountOptions, gopts global.Options, args []string, term ui.Terminal) error {
@@ -131,6 +215,31 @@ func runMount(ctx context.Context, opts MountOptions, gopts global.Options, args
 		return errors.Fatal("time template string cannot start or end with '/'")
 	}
 
+	for _, templ := range opts.PathTemplates {
+		if err := fuse.CheckPathTemplate(templ); err != nil {
+			return errors.Fatalf("%v", err)
+		}
+	}
+
+	cfg, err := newMountConfig(opts)
+	if err != nil {
+		return err
+	}
+
+	switch opts.Serve {
+	case "", "fuse":
+	case "nfs":
+		if len(args) != 0 {
+			return errors.Fatal("--serve nfs does not use a mountpoint")
+		}
+		if opts.ScratchDir != "" {
+			return errors.Fatal("--scratch-dir is not supported with --serve nfs")
+		}
+		return runMountNFS(ctx, opts, cfg, gopts, printer)
+	default:
+		return errors.Fatalf("invalid value %q for --serve, must be fuse or nfs", opts.Serve)
+	}
+
 	if len(args) == 0 {
 		return errors.Fatal("wrong number of parameters")
 	}
@@ -140,6 +249,13 @@ func runMount(ctx context.Context, opts MountOptions, gopts global.Options, args
 		return err
 	}
 
+	scratchDir := opts.ScratchDir
+	if scratchDir != "" {
+		if scratchDir, err = validateScratchDir(scratchDir, mountpoint); err != nil {
+			return err
+		}
+	}
+
 	debug.Log("start mount")
 	defer debug.Log("finish mount")
 
@@ -157,10 +273,12 @@ func runMount(ctx context.Context, opts MountOptions, gopts global.Options, args
 	fuseMountName := fmt.Sprintf("restic:%s", repo.Config().ID[:10])
 
 	mountOptions := []systemFuse.MountOption{
-		systemFuse.ReadOnly(),
 		systemFuse.FSName(fuseMountName),
 		systemFuse.MaxReadahead(128 * 1024),
 	}
+	if scratchDir == "" {
+		mountOptions = append(mountOptions, systemFuse.ReadOnly())
+	}
 
 	if opts.AllowOther {
 		mountOptions = append(mountOptions, systemFuse.AllowOther())
@@ -180,12 +298,7 @@ func runMount(ctx context.Context, opts MountOptions, gopts global.Options, args
 		return err
 	}
 
-	cfg := fuse.Config{
-		OwnerIsRoot:   opts.OwnerRoot,
-		Filter:        opts.SnapshotFilter,
-		TimeTemplate:  opts.TimeTemplate,
-		PathTemplates: opts.PathTemplates,
-	}
+	cfg.ScratchDir = scratchDir
 	root := fuse.NewRoot(repo, cfg)
 	// load repository before reporting the mountpoint
 	printer.S("Loading snapshots...")
@@ -222,6 +335,90 @@ func runMount(ctx context.Context, opts MountOptions, gopts global.Options, args
 	return err
 }
 
+// newMountConfig returns the configuration of the mounted file system.
+func newMountConfig(opts MountOptions) (fuse.Config, error) {
+	cfg := fuse.Config{
+		OwnerIsRoot:   opts.OwnerRoot,
+		Filter:        opts.SnapshotFilter,
+		TimeTemplate:  opts.TimeTemplate,
+		PathTemplates: opts.PathTemplates,
+	}
+
+	parseID := func(name, s string) (*uint32, error) {
+		if s == "" {
+			return nil, nil
+		}
+		id, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			return nil, errors.Fatalf("invalid value %q for --%s, must be a numeric ID", s, name)
+		}
+		id32 := uint32(id)
+		return &id32, nil
+	}
+	var err error
+	if cfg.SquashUID, err = parseID("squash-uid", opts.SquashUID); err != nil {
+		return cfg, err
+	}
+	if cfg.SquashGID, err = parseID("squash-gid", opts.SquashGID); err != nil {
+		return cfg, err
+	}
+
+	if opts.DefaultPerms != "" {
+		mode, err := strconv.ParseUint(opts.DefaultPerms, 8, 32)
+		if err != nil || mode > 0777 {
+			return cfg, errors.Fatalf("invalid value %q for --default-perms, must be an octal mode like 0640", opts.DefaultPerms)
+		}
+		perms := os.FileMode(mode)
+		cfg.DefaultPerms = &perms
+	}
+	return cfg, nil
+}
+
+// runMountNFS exports the repository via NFS until ctx is cancelled.
+func runMountNFS(ctx context.Context, opts MountOptions, cfg fuse.Config, gopts global.Options, printer restic.Printer) error {
+	ctx, repo, unlock, err := openWithReadLock(ctx, gopts, gopts.NoLock, printer)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	err = repo.LoadIndex(ctx, printer)
+	if err != nil {
+		return err
+	}
+
+	root := fuse.NewRoot(repo, cfg)
+	printer.S("Loading snapshots...")
+	_, err = root.ReadDirAll(ctx)
+	if err != nil {
+		return err
+	}
+
+	l, err := net.Listen("tcp", opts.Listen)
+	if err != nil {
+		return errors.Fatalf("unable to listen on %v: %v", opts.Listen, err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		err = nfs.NewServer(root).Serve(ctx, l)
+	}()
+
+	addr := l.Addr().(*net.TCPAddr)
+	printer.S("Now serving the repository via NFS at %v", addr)
+	printer.S("Mount it read-only using, for example:")
+	printer.S("    mount -t nfs -o vers=3,proto=tcp,port=%d,mountport=%d,mountproto=tcp,nolock,ro %s:%s /mnt/restic", addr.Port, addr.Port, addr.IP, nfs.ExportPath)
+	printer.S("When finished, unmount the export and quit with Ctrl-c here.")
+	debug.Log("serving NFS at %v", addr)
+
+	<-done
+	if ctx.Err() != nil {
+		return ErrOK
+	}
+	return err
+}
+
 func validateMountpoint(mountpoint string, gopts global.Options) error {
 	// Check the existence of the mount point at the earliest stage to
 	// prevent unnecessary computations while opening the repository.
@@ -254,6 +451,31 @@ func validateMountpoint(mountpoint string, gopts global.Options) error {
 	return nil
 }
 
+// validateScratchDir checks that the scratch directory exists and does not
+// overlap with the mountpoint. It returns the absolute path of the directory.
+func validateScratchDir(scratchDir, mountpoint string) (string, error) {
+	stat, err := os.Stat(scratchDir)
+	if err != nil {
+		return "", errors.Fatalf("scratch directory %s is inaccessible: %v", scratchDir, err)
+	}
+	if !stat.IsDir() {
+		return "", errors.Fatalf("scratch directory %s is not a directory", scratchDir)
+	}
+
+	abs, err := resolvePath(scratchDir)
+	if err != nil {
+		return "", err
+	}
+	mnt, err := resolvePath(mountpoint)
+	if err != nil {
+		return "", err
+	}
+	if fs.HasPathPrefix(mnt, abs) || fs.HasPathPrefix(abs, mnt) {
+		return "", errors.Fatalf("scratch directory %s and mountpoint %s must not overlap", scratchDir, mountpoint)
+	}
+	return abs, nil
+}
+
 // checkMountpointOverlap returns an error.Fatal if the local repository at
 // repoPath and the mountpoint overlap: equal paths, mountpoint nested inside
 // the repo, or the repo nested inside the mountpoint. Any overlap deadlocks
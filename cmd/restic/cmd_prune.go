@@ -2,10 +2,14 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"math"
+	"os"
 	"runtime"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/restic/restic/internal/data"
 	"github.com/restic/restic/internal/debug"
@@ -30,6 +34,16 @@ func newPruneCommand(globalOptions *global.Options) *cobra.Command {
 The "prune" command checks the repository and removes data that is not
 referenced and therefore not needed any more.
 
+Use "--plan-out" to only save the planned changes to a file. The plan can be
+reviewed and applied later on using "--apply". Before applying, restic verifies
+that the repository was not modified since the plan was created.
+
+Use "--concurrent" to allow backups to run while pruning. Unused pack files are
+then only marked for deletion and removed by a later prune run once the
+"--grace-period" has passed. The grace period must be longer than the longest
+backup. A concurrent prune refuses to start while another prune is running.
+This requires repository format version 3.
+
 EXIT STATUS
 ===========
 
@@ -54,6 +68,8 @@ Exit status is 12 if the password is incorrect.
 type PruneOptions struct {
 	DryRun                bool
 	UnsafeNoSpaceRecovery string
+	PlanOut               string
+	Apply                 string
 
 	unsafeRecovery bool
 
@@ -62,28 +78,42 @@ type PruneOptions struct {
 
 	MaxRepackSize  string
 	MaxRepackBytes uint64
+	MaxDuration    time.Duration
 
 	RepackCacheableOnly bool
 	RepackUncompressed  bool
 
+	AvoidRetrievalClasses []string
+	RetrievalCost         float64
+
 	SmallPackSize  string
 	SmallPackBytes uint64
+
+	Concurrent  bool
+	GracePeriod time.Duration
 }
 
 func (opts *PruneOptions) AddFlags(f *pflag.FlagSet) {
 	opts.AddLimitedFlags(f)
 	f.BoolVarP(&opts.DryRun, "dry-run", "n", false, "do not modify the repository, just print what would be done")
+	f.StringVar(&opts.PlanOut, "plan-out", "", "do not modify the repository, but save the planned changes to `file`")
+	f.StringVar(&opts.Apply, "apply", "", "apply the changes planned by a previous run with --plan-out from `file`")
 	f.StringVarP(&opts.UnsafeNoSpaceRecovery, "unsafe-recover-no-free-space", "", "", "UNSAFE, READ THE DOCUMENTATION BEFORE USING! Try to recover a repository stuck with no free space. Do not use without trying out 'prune --max-repack-size 0' first.")
+	f.BoolVar(&opts.Concurrent, "concurrent", false, "allow backups to run concurrently by only marking unused packs for deletion")
+	f.DurationVar(&opts.GracePeriod, "grace-period", 24*time.Hour, "with --concurrent, remove packs marked for deletion and unreferenced packs only after this `duration`")
 }
 
 func (opts *PruneOptions) AddLimitedFlags(f *pflag.FlagSet) {
 	var unused bool
 	f.StringVar(&opts.MaxUnused, "max-unused", "5%", "tolerate given `limit` of unused data (absolute value in bytes with suffixes k/K, m/M, g/G, t/T, a value in % or the word 'unlimited')")
 	f.StringVar(&opts.MaxRepackSize, "max-repack-size", "", "stop after repacking this much data in total (allowed suffixes for `size`: k/K, m/M, g/G, t/T)")
+	f.DurationVar(&opts.MaxDuration, "max-duration", 0, "stop repacking after this `duration`, takes a value like 30m or 2h (default: no limit)")
 	f.BoolVar(&opts.RepackCacheableOnly, "repack-cacheable-only", false, "only repack packs which are cacheable")
 	f.BoolVar(&unused, "repack-small", false, "deprecated. Use --repack-smaller-than to specify a minimum size")
 	f.BoolVar(&opts.RepackUncompressed, "repack-uncompressed", false, "repack all uncompressed data")
 	f.StringVar(&opts.SmallPackSize, "repack-smaller-than", "", "pack `below-limit` packfiles (allowed suffixes: m/M)")
+	f.StringSliceVar(&opts.AvoidRetrievalClasses, "avoid-retrieval-class", nil, "only repack packs stored in the backend storage `class` (e.g. glacier) if required to reach --max-unused (can be specified multiple times)")
+	f.Float64Var(&opts.RetrievalCost, "retrieval-cost", 0, "estimate the cost of reading packs in storage classes passed to --avoid-retrieval-class using this `price` per GiB")
 
 	err := f.MarkDeprecated("repack-small", "small files are automatically repacked. Use --repack-smaller-than to specify a minimum size")
 	if err != nil {
@@ -105,6 +135,24 @@ func verifyPruneOptions(opts *PruneOptions) error {
 		// prevent repacking data to make sure users cannot get stuck.
 		opts.MaxRepackBytes = 0
 	}
+	if opts.PlanOut != "" && opts.Apply != "" {
+		return errors.Fatal("--plan-out and --apply are mutually exclusive")
+	}
+	if opts.UnsafeNoSpaceRecovery != "" && (opts.PlanOut != "" || opts.Apply != "") {
+		return errors.Fatal("--unsafe-recover-no-free-space cannot be combined with --plan-out or --apply")
+	}
+	if opts.Concurrent && (opts.UnsafeNoSpaceRecovery != "" || opts.PlanOut != "" || opts.Apply != "") {
+		return errors.Fatal("--concurrent cannot be combined with --unsafe-recover-no-free-space, --plan-out or --apply")
+	}
+	if opts.GracePeriod < 0 {
+		return errors.Fatalf("invalid value for --grace-period: %v", opts.GracePeriod)
+	}
+	if opts.MaxDuration < 0 {
+		return errors.Fatalf("invalid value for --max-duration: %v", opts.MaxDuration)
+	}
+	if opts.RetrievalCost < 0 {
+		return errors.Fatalf("invalid value for --retrieval-cost: %v", opts.RetrievalCost)
+	}
 
 	maxUnused := strings.TrimSpace(opts.MaxUnused)
 	if maxUnused == "" {
@@ -171,12 +219,38 @@ func runPrune(ctx context.Context, opts PruneOptions, gopts global.Options, term
 		return errors.Fatal("disabled compression and `--repack-uncompressed` are mutually exclusive")
 	}
 
-	if gopts.NoLock && !opts.DryRun {
-		return errors.Fatal("--no-lock is only applicable in combination with --dry-run for prune command")
+	readOnly := opts.DryRun || opts.PlanOut != ""
+	if gopts.NoLock && !readOnly {
+		return errors.Fatal("--no-lock is only applicable in combination with --dry-run or --plan-out for prune command")
+	}
+
+	var planFile *repository.PrunePlanFile
+	if opts.Apply != "" {
+		planFile, err = loadPrunePlanFile(opts.Apply)
+		if err != nil {
+			return err
+		}
 	}
 
 	printer := progress.NewTerminalPrinter(gopts.JSON, gopts.Verbosity, term)
-	ctx, repo, unlock, err := openWithExclusiveLock(ctx, gopts, opts.DryRun && gopts.NoLock, printer)
+	socket, err := openProgressSocket(gopts)
+	if err != nil {
+		return err
+	}
+	if socket != nil {
+		defer func() {
+			_ = socket.Close()
+		}()
+		printer = socket.Printer(printer)
+	}
+	timer := progress.NewPhaseTimer()
+	printer = timer.Printer(printer)
+
+	openWithLock := openWithExclusiveLock
+	if opts.Concurrent {
+		openWithLock = openWithPruneLock
+	}
+	ctx, repo, unlock, err := openWithLock(ctx, gopts, readOnly && gopts.NoLock, printer)
 	if err != nil {
 		return err
 	}
@@ -190,20 +264,27 @@ func runPrune(ctx context.Context, opts PruneOptions, gopts global.Options, term
 		opts.unsafeRecovery = true
 	}
 
-	return runPruneWithRepo(ctx, opts, gopts, repo, restic.NewIDSet(), printer)
-}
-
-func runPruneWithRepo(ctx context.Context, opts PruneOptions, gopts global.Options, repo *repository.Repository, ignoreSnapshots restic.IDSet, printer restic.Printer) error {
-	if repo.Cache() == nil && !gopts.JSON {
-		printer.S("warning: running prune without a cache, this may be very slow!")
+	if planFile != nil {
+		err = runPruneApplyPlan(ctx, opts, gopts, repo, planFile, printer)
+	} else {
+		err = runPruneWithRepo(ctx, opts, gopts, repo, restic.NewIDSet(), printer)
 	}
-
-	// loading the index before the snapshots is ok, as we use an exclusive lock here
-	err := repo.LoadIndex(ctx, printer)
 	if err != nil {
 		return err
 	}
 
+	printTiming(gopts, timer.Timing())
+	return nil
+}
+
+// repositoryPruneOptions converts the verified prune options to the options
+// used to plan the prune run.
+func repositoryPruneOptions(opts PruneOptions, repo *repository.Repository) repository.PruneOptions {
+	var repackDeadline time.Time
+	if opts.MaxDuration > 0 {
+		repackDeadline = time.Now().Add(opts.MaxDuration)
+	}
+
 	popts := repository.PruneOptions{
 		DryRun:         opts.DryRun,
 		UnsafeRecovery: opts.unsafeRecovery,
@@ -214,10 +295,53 @@ func runPruneWithRepo(ctx context.Context, opts PruneOptions, gopts global.Optio
 
 		RepackCacheableOnly: opts.RepackCacheableOnly,
 		RepackUncompressed:  opts.RepackUncompressed,
+		RepackDeadline:      repackDeadline,
+		AvoidStorageClasses: opts.AvoidRetrievalClasses,
+
+		Concurrent:  opts.Concurrent,
+		GracePeriod: opts.GracePeriod,
 	}
 
+	maxRepoSize := repo.Config().MaxRepoSize
+	if maxRepoSize > 0 {
+		popts.MaxUnusedBytes = limitUnusedToBudget(opts.maxUnusedBytes, maxRepoSize)
+	}
+	return popts
+}
+
+func runPruneWithRepo(ctx context.Context, opts PruneOptions, gopts global.Options, repo *repository.Repository, ignoreSnapshots restic.IDSet, printer restic.Printer) error {
+	if repo.Cache() == nil && !gopts.JSON {
+		printer.S("warning: running prune without a cache, this may be very slow!")
+	}
+
+	var snapshotLister restic.Lister = repo
+	if opts.Concurrent {
+		// snapshots created by concurrent backups after loading the index may
+		// reference blobs which are missing from it, thus ignore them. Packs
+		// containing such blobs are only marked for deletion and are restored
+		// by a later prune run.
+		var err error
+		snapshotLister, err = restic.MemorizeList(ctx, repo, restic.SnapshotFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	// loading the index before the snapshots is ok, as we either use an
+	// exclusive lock or the snapshots were listed before
+	err := repo.LoadIndex(ctx, printer)
+	if err != nil {
+		return err
+	}
+
+	popts := repositoryPruneOptions(opts, repo)
+	maxRepoSize := repo.Config().MaxRepoSize
+
+	var snapshots restic.IDs
 	plan, err := repository.PlanPrune(ctx, popts, repo, func(ctx context.Context, repo restic.Repository, usedBlobs restic.FindBlobSet) error {
-		return getUsedBlobs(ctx, repo, usedBlobs, ignoreSnapshots, printer)
+		ids, err := getUsedBlobsFrom(ctx, snapshotLister, repo, usedBlobs, ignoreSnapshots, printer)
+		snapshots = ids
+		return err
 	}, printer)
 	if err != nil {
 		return err
@@ -226,12 +350,19 @@ func runPruneWithRepo(ctx context.Context, opts PruneOptions, gopts global.Optio
 		return ctx.Err()
 	}
 
-	if popts.DryRun {
+	if maxRepoSize > 0 && plan.Stats().Size.Used > maxRepoSize {
+		printer.E("warning: the data used by snapshots (%v) exceeds max-repo-size of %v, forget snapshots to free more space",
+			ui.FormatBytes(plan.Stats().Size.Used), ui.FormatBytes(maxRepoSize))
+	}
+
+	if opts.PlanOut != "" {
+		printer.P("\nPlanned the following changes:")
+	} else if popts.DryRun {
 		printer.P("\nWould have made the following changes:")
 	}
 
 	if !gopts.JSON {
-		err = printPruneStats(printer, plan.Stats())
+		err = printPruneStats(printer, plan.Stats(), opts.RetrievalCost)
 		if err != nil {
 			return err
 		}
@@ -239,14 +370,134 @@ func runPruneWithRepo(ctx context.Context, opts PruneOptions, gopts global.Optio
 		gopts.Term.Print(ui.ToJSONString(plan.Stats()))
 	}
 
+	if opts.PlanOut != "" {
+		err = savePrunePlanFile(opts.PlanOut, plan.Export(snapshots))
+		if err != nil {
+			return err
+		}
+		printer.P("saved prune plan to %v", opts.PlanOut)
+		return nil
+	}
+
 	// Trigger GC to reset garbage collection threshold
 	runtime.GC()
 
-	return plan.Execute(ctx, printer)
+	return executePrunePlan(ctx, plan, popts.DryRun, repo, printer)
+}
+
+// limitUnusedToBudget returns a function which limits the unused data
+// calculated by maxUnusedBytes such that the used and unused data fit into
+// maxRepoSize.
+func limitUnusedToBudget(maxUnusedBytes func(used uint64) uint64, maxRepoSize uint64) func(used uint64) uint64 {
+	return func(used uint64) uint64 {
+		if used >= maxRepoSize {
+			return 0
+		}
+		return min(maxUnusedBytes(used), maxRepoSize-used)
+	}
+}
+
+// runPruneApplyPlan executes a plan created by --plan-out after verifying
+// that it still matches the repository.
+func runPruneApplyPlan(ctx context.Context, opts PruneOptions, gopts global.Options, repo *repository.Repository, planFile *repository.PrunePlanFile, printer restic.Printer) error {
+	var repackDeadline time.Time
+	if opts.MaxDuration > 0 {
+		repackDeadline = time.Now().Add(opts.MaxDuration)
+	}
+
+	if repo.Cache() == nil && !gopts.JSON {
+		printer.S("warning: running prune without a cache, this may be very slow!")
+	}
+
+	err := repo.LoadIndex(ctx, printer)
+	if err != nil {
+		return err
+	}
+
+	popts := repository.PruneOptions{
+		DryRun:         opts.DryRun,
+		RepackDeadline: repackDeadline,
+	}
+
+	plan, err := repository.LoadPrunePlan(ctx, popts, repo, planFile, func(ctx context.Context, repo restic.Repository, usedBlobs restic.FindBlobSet) error {
+		snapshots, err := getUsedBlobs(ctx, repo, usedBlobs, restic.NewIDSet(), printer)
+		if err != nil {
+			return err
+		}
+		if !restic.NewIDSet(snapshots...).Equals(restic.NewIDSet(planFile.Snapshots...)) {
+			printer.E("the snapshots do not match the prune plan")
+			return repository.ErrPrunePlanOutdated
+		}
+		return nil
+	}, printer)
+	if err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	if popts.DryRun {
+		printer.P("\nWould have made the following changes:")
+	}
+
+	if !gopts.JSON {
+		err = printPruneStats(printer, plan.Stats(), opts.RetrievalCost)
+		if err != nil {
+			return err
+		}
+	} else {
+		gopts.Term.Print(ui.ToJSONString(plan.Stats()))
+	}
+
+	runtime.GC()
+
+	return executePrunePlan(ctx, plan, popts.DryRun, repo, printer)
+}
+
+// executePrunePlan executes the plan and records it in the audit log.
+func executePrunePlan(ctx context.Context, plan *repository.PrunePlan, dryRun bool, repo *repository.Repository, printer restic.Printer) error {
+	err := plan.Execute(ctx, printer)
+	if err != nil || dryRun {
+		return err
+	}
+
+	stats := plan.Stats()
+	entry := data.NewAuditLogEntry("prune")
+	entry.Message = fmt.Sprintf("removed %d packs (%s), repacked %d packs",
+		stats.Packs.Remove, ui.FormatBytes(stats.Size.RemoveTotal), stats.Packs.Repack)
+	return recordAuditLog(ctx, repo, entry)
+}
+
+// savePrunePlanFile writes the prune plan as JSON to filename.
+func savePrunePlanFile(filename string, planFile *repository.PrunePlanFile) error {
+	buf, err := json.MarshalIndent(planFile, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(filename, append(buf, '\n'), 0600)
+	if err != nil {
+		return errors.Fatalf("unable to save prune plan: %v", err)
+	}
+	return nil
+}
+
+// loadPrunePlanFile reads a prune plan saved by savePrunePlanFile.
+func loadPrunePlanFile(filename string) (*repository.PrunePlanFile, error) {
+	buf, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Fatalf("unable to read prune plan: %v", err)
+	}
+	var planFile repository.PrunePlanFile
+	err = json.Unmarshal(buf, &planFile)
+	if err != nil {
+		return nil, errors.Fatalf("unable to parse prune plan %v: %v", filename, err)
+	}
+	return &planFile, nil
 }
 
 // printPruneStats prints out the statistics
-func printPruneStats(printer restic.Printer, stats repository.PruneStats) error {
+func printPruneStats(printer restic.Printer, stats repository.PruneStats, retrievalCost float64) error {
 	printer.V("\nused:         %10d blobs / %s", stats.Blobs.Used, ui.FormatBytes(stats.Size.Used))
 	if stats.Blobs.Duplicate > 0 {
 		printer.V("duplicates:   %10d blobs / %s", stats.Blobs.Duplicate, ui.FormatBytes(stats.Size.Duplicate))
@@ -255,12 +506,21 @@ func printPruneStats(printer restic.Printer, stats repository.PruneStats) error
 	if stats.Size.Unref > 0 {
 		printer.V("unreferenced:                    %s", ui.FormatBytes(stats.Size.Unref))
 	}
+	if stats.Size.UnrefLocked > 0 {
+		printer.V("unreferenced, locked:            %s", ui.FormatBytes(stats.Size.UnrefLocked))
+	}
+	if stats.Size.UnrefRecent > 0 {
+		printer.V("unreferenced, recent:            %s", ui.FormatBytes(stats.Size.UnrefRecent))
+	}
+	if pending := stats.Size.Pending + stats.Size.PendingRm + stats.Size.PendingKeep; pending > 0 {
+		printer.V("marked for deletion:             %s", ui.FormatBytes(pending))
+	}
 	printer.V("total:        %10d blobs / %s", stats.Blobs.Total, ui.FormatBytes(stats.Size.Total))
 	printer.V("unused size: %s of total size", ui.FormatPercent(stats.Size.Duplicate+stats.Size.Unused, stats.Size.Total))
 
 	printer.P("\nto repack:    %10d blobs / %s", stats.Blobs.Repack, ui.FormatBytes(stats.Size.Repack))
 	printer.P("this removes: %10d blobs / %s", stats.Blobs.Repackrm, ui.FormatBytes(stats.Size.Repackrm))
-	printer.P("to delete:    %10d blobs / %s", stats.Blobs.Remove, ui.FormatBytes(stats.Size.Remove+stats.Size.Unref))
+	printer.P("to delete:    %10d blobs / %s", stats.Blobs.Remove, ui.FormatBytes(stats.Size.Remove+stats.Size.Unref+stats.Size.PendingRm))
 	printer.P("total prune:  %10d blobs / %s", stats.Blobs.RemoveTotal, ui.FormatBytes(stats.Size.RemoveTotal))
 	if stats.Size.Uncompressed > 0 {
 		printer.P("not yet compressed:              %s", ui.FormatBytes(stats.Size.Uncompressed))
@@ -268,6 +528,21 @@ func printPruneStats(printer restic.Printer, stats repository.PruneStats) error
 	printer.P("remaining:    %10d blobs / %s", stats.Blobs.Remain, ui.FormatBytes(stats.Size.Remain))
 	printer.P("unused size after prune: %s (%s of remaining size)",
 		ui.FormatBytes(stats.Size.RemainUnused), ui.FormatPercent(stats.Size.RemainUnused, stats.Size.Remain))
+	if locked := stats.Packs.Locked + stats.Packs.UnrefLocked; locked > 0 {
+		printer.P("%d packs cannot be deleted or repacked before their retention period expires", locked)
+	}
+	if waiting := stats.Packs.Pending + stats.Packs.UnrefRecent; waiting > 0 {
+		printer.P("%d packs cannot be deleted before the grace period expires", waiting)
+	}
+	if stats.Packs.PendingKeep > 0 {
+		printer.P("%d packs marked for deletion are still in use and will be restored", stats.Packs.PendingKeep)
+	}
+	if stats.Packs.Retrieve > 0 {
+		printer.P("to retrieve:  %10d packs / %s from avoided storage classes", stats.Packs.Retrieve, ui.FormatBytes(stats.Size.Retrieve))
+		if retrievalCost > 0 {
+			printer.P("estimated retrieval cost: %.2f", retrievalCost*float64(stats.Size.Retrieve)/(1<<30))
+		}
+	}
 	printer.P("")
 	printer.V("totally used packs: %10d", stats.Packs.Used)
 	printer.V("partly used packs:  %10d", stats.Packs.PartlyUsed)
@@ -279,24 +554,37 @@ func printPruneStats(printer restic.Printer, stats repository.PruneStats) error
 	if stats.Packs.Unref > 0 {
 		printer.V("to delete:    %10d unreferenced packs\n\n", stats.Packs.Unref)
 	}
+	if stats.Packs.PendingRm > 0 {
+		printer.V("to delete:    %10d packs marked for deletion", stats.Packs.PendingRm)
+	}
 	return nil
 }
 
-func getUsedBlobs(ctx context.Context, repo restic.Repository, usedBlobs restic.FindBlobSet, ignoreSnapshots restic.IDSet, printer restic.Printer) error {
+// getUsedBlobs adds all blobs referenced by the snapshots to usedBlobs and
+// returns the IDs of the snapshots.
+func getUsedBlobs(ctx context.Context, repo restic.Repository, usedBlobs restic.FindBlobSet, ignoreSnapshots restic.IDSet, printer restic.Printer) (restic.IDs, error) {
+	return getUsedBlobsFrom(ctx, repo, repo, usedBlobs, ignoreSnapshots, printer)
+}
+
+// getUsedBlobsFrom is like getUsedBlobs, but lists the snapshots using
+// snapshotLister.
+func getUsedBlobsFrom(ctx context.Context, snapshotLister restic.Lister, repo restic.Repository, usedBlobs restic.FindBlobSet, ignoreSnapshots restic.IDSet, printer restic.Printer) (restic.IDs, error) {
+	var snapshots restic.IDs
 	var snapshotTrees restic.IDs
 	printer.P("loading all snapshots...")
-	err := data.ForAllSnapshots(ctx, repo, repo, ignoreSnapshots,
+	err := data.ForAllSnapshots(ctx, snapshotLister, repo, ignoreSnapshots,
 		func(id restic.ID, sn *data.Snapshot, err error) error {
 			if err != nil {
 				debug.Log("failed to load snapshot %v (error %v)", id, err)
 				return err
 			}
 			debug.Log("add snapshot %v (tree %v)", id, *sn.Tree)
+			snapshots = append(snapshots, id)
 			snapshotTrees = append(snapshotTrees, *sn.Tree)
 			return nil
 		})
 	if err != nil {
-		return errors.Fatalf("failed loading snapshot: %v", err)
+		return nil, errors.Fatalf("failed loading snapshot: %v", err)
 	}
 
 	printer.P("finding data that is still in use for %d snapshots", len(snapshotTrees))
@@ -307,8 +595,8 @@ func getUsedBlobs(ctx context.Context, repo restic.Repository, usedBlobs restic.
 
 	err = data.FindUsedBlobs(ctx, repo, snapshotTrees, usedBlobs, bar)
 	if err != nil {
-		return errors.Fatalf("failed finding blobs: %v", err)
+		return nil, errors.Fatalf("failed finding blobs: %v", err)
 	}
 
-	return nil
+	return snapshots, nil
 }
@@ -5,11 +5,14 @@ import (
 	"encoding/json"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/restic/restic/internal/backend"
 	"github.com/restic/restic/internal/global"
 	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
 	rtest "github.com/restic/restic/internal/test"
+	"github.com/restic/restic/internal/ui/progress"
 )
 
 func testRunPrune(t testing.TB, gopts global.Options, opts PruneOptions) {
@@ -276,10 +279,100 @@ func TestPruneJSON(t *testing.T) {
 	})
 	rtest.OK(t, err)
 
+	dec := json.NewDecoder(buf)
 	var stats repository.PruneStats
-	rtest.OK(t, json.Unmarshal(buf.Bytes(), &stats))
+	rtest.OK(t, dec.Decode(&stats))
 
 	rtest.Equals(t, "summary", stats.MessageType)
 	rtest.Assert(t, stats.Blobs.Total > 0, "expected non-zero total blobs, got %v", stats.Blobs.Total)
 	rtest.Assert(t, stats.Packs.Total > 0, "expected non-zero total packs, got %v", stats.Packs.Total)
+
+	// the timing of all phases is printed last
+	var timing progress.Timing
+	rtest.OK(t, dec.Decode(&timing))
+	rtest.Equals(t, "timing", timing.MessageType)
+	phases := make(map[string]bool)
+	for _, p := range timing.Phases {
+		phases[p.Phase] = true
+		rtest.Assert(t, p.Seconds <= timing.TotalSeconds, "phase %v took longer than prune", p.Phase)
+	}
+	rtest.Assert(t, phases["packs repacked"] && phases["files deleted"], "missing phases in %v", timing.Phases)
+	rtest.Assert(t, !dec.More(), "unexpected output after timing")
+}
+
+func TestPrunePlanApply(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	createPrunableRepo(t, env)
+	planFile := filepath.Join(env.base, "plan.json")
+	packs := testRunList(t, env.gopts, "packs")
+
+	testRunPrune(t, env.gopts, PruneOptions{MaxUnused: "0%", PlanOut: planFile})
+	rtest.Equals(t, packs, testRunList(t, env.gopts, "packs"), "--plan-out must not modify the repository")
+
+	testRunPrune(t, env.gopts, PruneOptions{MaxUnused: "5%", Apply: planFile})
+	rtest.Assert(t, len(testRunList(t, env.gopts, "packs")) < len(packs), "expected packs to be removed")
+	testRunCheck(t, env.gopts)
+
+	// a plan is rejected once the repository was modified
+	testRunPrune(t, env.gopts, PruneOptions{MaxUnused: "0%", PlanOut: planFile})
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9", "4")}, BackupOptions{}, env.gopts)
+	testRunPruneMustFail(t, env.gopts, PruneOptions{MaxUnused: "5%", Apply: planFile})
+
+	testRunPruneMustFail(t, env.gopts, PruneOptions{MaxUnused: "5%", PlanOut: planFile, Apply: planFile})
+}
+
+func TestPruneConcurrent(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	createPrunableRepo(t, env)
+	packs := testRunList(t, env.gopts, "packs")
+
+	// unused packs are only marked for deletion
+	testRunPrune(t, env.gopts, PruneOptions{MaxUnused: "0%", Concurrent: true, GracePeriod: time.Hour})
+	remaining := restic.NewIDSet(testRunList(t, env.gopts, "packs")...)
+	rtest.Equals(t, 0, len(restic.NewIDSet(packs...).Sub(remaining)), "packs must not be removed during the grace period")
+	testRunCheck(t, env.gopts)
+
+	// a backup which runs after marking the packs can still be restored
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9", "2")}, BackupOptions{}, env.gopts)
+
+	// after the grace period, the marked packs are removed
+	testRunPrune(t, env.gopts, PruneOptions{MaxUnused: "0%", Concurrent: true})
+	remaining = restic.NewIDSet(testRunList(t, env.gopts, "packs")...)
+	rtest.Assert(t, len(restic.NewIDSet(packs...).Sub(remaining)) > 0, "expected packs to be removed")
+	rtest.OK(t, withTermStatus(t, env.gopts, func(ctx context.Context, gopts global.Options) error {
+		_, err := runCheck(context.TODO(), CheckOptions{ReadData: true}, gopts, nil, gopts.Term)
+		return err
+	}))
+
+	testRunPruneMustFail(t, env.gopts, PruneOptions{MaxUnused: "5%", Concurrent: true, PlanOut: filepath.Join(env.base, "plan.json")})
+}
+
+func TestPruneConcurrentLocked(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	createPrunableRepo(t, env)
+
+	// hold the lock of another concurrent prune
+	var unlock func()
+	rtest.OK(t, withTermStatus(t, env.gopts, func(_ context.Context, gopts global.Options) error {
+		printer := progress.NewTerminalPrinter(false, gopts.Verbosity, gopts.Term)
+		var err error
+		// the lock is released once its context is canceled
+		_, _, unlock, err = openWithPruneLock(context.Background(), gopts, false, printer)
+		return err
+	}))
+
+	// backups can still run, but neither a second concurrent prune nor a
+	// regular prune
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9", "2")}, BackupOptions{}, env.gopts)
+	testRunPruneMustFail(t, env.gopts, PruneOptions{MaxUnused: "0%", Concurrent: true})
+	testRunPruneMustFail(t, env.gopts, PruneOptions{MaxUnused: "0%"})
+
+	unlock()
+	testRunPrune(t, env.gopts, PruneOptions{MaxUnused: "0%", Concurrent: true})
 }
@@ -12,12 +12,14 @@ func newRepairCommand(globalOptions *global.Options) *cobra.Command {
 		Long: `
 The "repair" command repairs damaged repositories. It provides subcommands to
 rebuild the index, salvage damaged pack files, and repair broken snapshots.
+The "repair all" subcommand runs all necessary repair steps in the correct order.
 `,
 		GroupID:           cmdGroupDefault,
 		DisableAutoGenTag: true,
 	}
 
 	cmd.AddCommand(
+		newRepairAllCommand(globalOptions),
 		newRepairIndexCommand(globalOptions),
 		newRepairPacksCommand(globalOptions),
 		newRepairSnapshotsCommand(globalOptions),
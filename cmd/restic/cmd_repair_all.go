@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/global"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui"
+	"github.com/restic/restic/internal/ui/progress"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func newRepairAllCommand(globalOptions *global.Options) *cobra.Command {
+	var opts RepairAllOptions
+	var pruneOpts PruneOptions
+
+	cmd := &cobra.Command{
+		Use:   "all [flags]",
+		Short: "Check the repository and run all necessary repair steps",
+		Long: `
+The "repair all" command checks the repository and then runs the repair steps
+described in the troubleshooting guide in the correct order:
+
+1. rebuild the index ("repair index")
+2. salvage damaged pack files ("repair packs"), a backup copy of each pack
+   file is saved in the current folder
+3. remove unreadable snapshot files and remove missing data from all
+   snapshots ("repair snapshots --forget")
+4. remove unreferenced data ("prune")
+5. check the repository again
+
+Steps which are not necessary for the problems found by the initial check are
+skipped. Before modifying the repository, the planned steps are shown and must
+be confirmed, unless --yes is specified.
+
+WARNING
+=======
+
+Repairing snapshots causes data loss! Please read the troubleshooting guide at
+https://restic.readthedocs.io/en/stable/077_troubleshooting.html and create a
+copy of the repository first.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful.
+Exit status is 1 if there was any error or the repository still contains errors.
+Exit status is 10 if the repository does not exist.
+Exit status is 11 if the repository is already locked.
+Exit status is 12 if the password is incorrect.
+`,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return runRepairAll(cmd.Context(), opts, pruneOpts, *globalOptions, globalOptions.Term)
+		},
+	}
+
+	opts.AddFlags(cmd.Flags())
+	pruneOpts.AddLimitedFlags(cmd.Flags())
+	return cmd
+}
+
+// RepairAllOptions collects all options for the repair all command.
+type RepairAllOptions struct {
+	ReadData bool
+	Yes      bool
+}
+
+func (opts *RepairAllOptions) AddFlags(f *pflag.FlagSet) {
+	f.BoolVar(&opts.ReadData, "read-data", false, "read all data blobs to find damaged pack files")
+	f.BoolVar(&opts.Yes, "yes", false, "do not ask for confirmation before repairing the repository")
+}
+
+// repairStep is a single step of the repair procedure.
+type repairStep struct {
+	description string
+	run         func() error
+}
+
+// planRepair returns the steps necessary to repair the problems described by
+// the check summary.
+func planRepair(ctx context.Context, summary checkSummary, damaged bool, pruneOpts PruneOptions, gopts global.Options, term ui.Terminal) []repairStep {
+	var steps []repairStep
+	if damaged {
+		steps = append(steps, repairStep{"rebuild the index", func() error {
+			return runRebuildIndex(ctx, RepairIndexOptions{}, gopts, term)
+		}})
+	}
+	if len(summary.BrokenPacks) > 0 {
+		steps = append(steps, repairStep{fmt.Sprintf("salvage %d damaged pack files", len(summary.BrokenPacks)), func() error {
+			return runRepairPacks(ctx, RepairPacksOptions{}, gopts, term, summary.BrokenPacks)
+		}})
+	}
+	if len(summary.BrokenSnapshots) > 0 {
+		steps = append(steps, repairStep{fmt.Sprintf("remove %d unreadable snapshot files", len(summary.BrokenSnapshots)), func() error {
+			return runRepairSnapshots(ctx, gopts, RepairOptions{Forget: true}, summary.BrokenSnapshots, term)
+		}})
+	}
+	if damaged {
+		steps = append(steps, repairStep{"remove missing data from all snapshots", func() error {
+			return runRepairSnapshots(ctx, gopts, RepairOptions{Forget: true}, nil, term)
+		}})
+	}
+	if damaged || summary.HintPrune {
+		steps = append(steps, repairStep{"remove unreferenced data", func() error {
+			return runPrune(ctx, pruneOpts, gopts, term)
+		}})
+	}
+	return steps
+}
+
+// confirmRepair asks the user whether to run the repair.
+func confirmRepair(term ui.Terminal, printer restic.Printer) (bool, error) {
+	if !term.InputIsTerminal() {
+		return false, errors.Fatal("unable to ask for confirmation without a terminal, use --yes to repair the repository")
+	}
+	printer.P("\nrun these steps? [y/N]")
+	line, err := bufio.NewReader(term.InputRaw()).ReadString('\n')
+	if err != nil && line == "" {
+		return false, errors.Fatalf("unable to read answer: %v", err)
+	}
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "y" || answer == "yes", nil
+}
+
+func runRepairAll(ctx context.Context, opts RepairAllOptions, pruneOpts PruneOptions, gopts global.Options, term ui.Terminal) error {
+	if gopts.JSON {
+		return errors.Fatal("repair all does not support --json")
+	}
+	if gopts.NoLock {
+		return errors.Fatal("repair all requires an exclusive lock and cannot be used with --no-lock")
+	}
+	if err := verifyPruneOptions(&pruneOpts); err != nil {
+		return err
+	}
+
+	printer := progress.NewTerminalPrinter(false, gopts.Verbosity, term)
+	printer.P("step 1: check the repository\n")
+	checkOpts := CheckOptions{ReadData: opts.ReadData}
+	summary, err := runCheck(ctx, checkOpts, gopts, nil, term)
+	damaged := errors.Is(err, errCheckFoundErrors)
+	if err != nil && !damaged {
+		return err
+	}
+
+	steps := planRepair(ctx, summary, damaged, pruneOpts, gopts, term)
+	if len(steps) == 0 {
+		printer.P("\nthe repository does not need to be repaired")
+		return nil
+	}
+
+	printer.P("\nthe following steps are necessary to repair the repository:")
+	for i, step := range steps {
+		printer.P("  %d. %s", i+2, step.description)
+	}
+	printer.P("  %d. check the repository again", len(steps)+2)
+
+	if !opts.Yes {
+		ok, err := confirmRepair(term, printer)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			printer.P("repair aborted, the repository was not modified")
+			return nil
+		}
+	}
+
+	for i, step := range steps {
+		printer.P("\nstep %d: %s\n", i+2, step.description)
+		if err := step.run(); err != nil {
+			return errors.Fatalf("step %d (%s) failed: %v", i+2, step.description, err)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+
+	printer.P("\nstep %d: check the repository again\n", len(steps)+2)
+	_, err = runCheck(ctx, checkOpts, gopts, nil, term)
+	if errors.Is(err, errCheckFoundErrors) {
+		return errors.Fatal("the repository still contains errors, please follow the troubleshooting guide at https://restic.readthedocs.io/en/stable/077_troubleshooting.html")
+	}
+	if err != nil {
+		return err
+	}
+
+	printer.P("\nthe repository was repaired successfully")
+	return nil
+}
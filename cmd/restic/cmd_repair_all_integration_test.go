@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/restic/restic/internal/global"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func testRunRepairAll(t testing.TB, gopts global.Options, opts RepairAllOptions) error {
+	return withTermStatus(t, gopts, func(ctx context.Context, gopts global.Options) error {
+		var pruneOpts PruneOptions
+		pruneOpts.MaxUnused = "5%"
+		return runRepairAll(ctx, opts, pruneOpts, gopts, gopts.Term)
+	})
+}
+
+func TestRepairAll(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+	// the repair steps list the repository several times
+	env.gopts.BackendTestHook = nil
+
+	testRunInit(t, env.gopts)
+	createRandomFile(t, env, "foo/bar/file", 512*1024)
+	testRunBackup(t, "", []string{env.testdata}, BackupOptions{}, env.gopts)
+
+	// an intact repository is not modified
+	rtest.OK(t, testRunRepairAll(t, env.gopts, RepairAllOptions{Yes: true}))
+	testListSnapshots(t, env.gopts, 1)
+
+	// damage repository
+	removePacksExcept(env.gopts, t, restic.NewIDSet(), false)
+	createRandomFile(t, env, "foo/bar/file2", 256*1024)
+	testRunBackup(t, "", []string{env.testdata}, BackupOptions{}, env.gopts)
+	snapshotIDs := testListSnapshots(t, env.gopts, 2)
+	testRunCheckMustFail(t, env.gopts)
+
+	// without a terminal, the repair must be confirmed using --yes
+	err := testRunRepairAll(t, env.gopts, RepairAllOptions{})
+	rtest.Assert(t, err != nil, "expected an error without --yes")
+	rtest.Equals(t, snapshotIDs, testListSnapshots(t, env.gopts, 2))
+
+	rtest.OK(t, testRunRepairAll(t, env.gopts, RepairAllOptions{Yes: true}))
+	repairedIDs := restic.NewIDSet(testListSnapshots(t, env.gopts, 2)...)
+	for _, id := range snapshotIDs {
+		rtest.Assert(t, !repairedIDs.Has(id), "damaged snapshot %v was not replaced", id)
+	}
+	_, _, err = testRunCheckOutput(t, env.gopts, false)
+	rtest.OK(t, err)
+}
@@ -13,9 +13,12 @@ import (
 	"github.com/restic/restic/internal/ui"
 	"github.com/restic/restic/internal/ui/progress"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 func newRepairPacksCommand(globalOptions *global.Options) *cobra.Command {
+	var opts RepairPacksOptions
+
 	cmd := &cobra.Command{
 		Use:   "packs [packIDs...]",
 		Short: "Salvage damaged pack files",
@@ -23,6 +26,17 @@ func newRepairPacksCommand(globalOptions *global.Options) *cobra.Command {
 The "repair packs" command extracts intact blobs from the specified pack files, rebuilds
 the index to remove the damaged pack files and removes the pack files from the repository.
 
+Damaged blobs which also exist in other pack files, for example as a duplicate
+created by an interrupted backup, are healed using an intact copy. Damaged
+blobs in pack files with a parity section (see "-o repo.parity") are
+reconstructed using the parity. If all damaged blobs could be healed, no
+snapshots are affected and running "repair snapshots" is not necessary.
+
+The pack files can also be read from a report written by "restic check
+--damaged-packs-report" using --report. Pack files which were moved to the
+quarantine directory by "restic check --quarantine" are moved back before
+salvaging them.
+
 EXIT STATUS
 ===========
 
@@ -34,13 +48,24 @@ Exit status is 12 if the password is incorrect.
 `,
 		DisableAutoGenTag: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runRepairPacks(cmd.Context(), *globalOptions, globalOptions.Term, args)
+			return runRepairPacks(cmd.Context(), opts, *globalOptions, globalOptions.Term, args)
 		},
 	}
+
+	opts.AddFlags(cmd.Flags())
 	return cmd
 }
 
-func runRepairPacks(ctx context.Context, gopts global.Options, term ui.Terminal, args []string) error {
+// RepairPacksOptions collects all options for the repair packs command.
+type RepairPacksOptions struct {
+	Report string
+}
+
+func (opts *RepairPacksOptions) AddFlags(f *pflag.FlagSet) {
+	f.StringVar(&opts.Report, "report", "", "also salvage the pack files listed in the report `file` written by check --damaged-packs-report")
+}
+
+func runRepairPacks(ctx context.Context, opts RepairPacksOptions, gopts global.Options, term ui.Terminal, args []string) error {
 	ids := restic.NewIDSet()
 	for _, arg := range args {
 		id, err := restic.ParseID(arg)
@@ -49,6 +74,15 @@ func runRepairPacks(ctx context.Context, gopts global.Options, term ui.Terminal,
 		}
 		ids.Insert(id)
 	}
+	if opts.Report != "" {
+		report, err := loadDamagedPacksReport(opts.Report)
+		if err != nil {
+			return err
+		}
+		for _, p := range report.Packs {
+			ids.Insert(p.ID)
+		}
+	}
 	if len(ids) == 0 {
 		return errors.Fatal("no ids specified")
 	}
@@ -66,6 +100,14 @@ func runRepairPacks(ctx context.Context, gopts global.Options, term ui.Terminal,
 		return errors.Fatalf("%s", err)
 	}
 
+	restored, err := repository.RestoreQuarantinedPacks(ctx, repo, ids, printer)
+	if err != nil {
+		return errors.Fatalf("restoring quarantined pack files failed: %v", err)
+	}
+	if len(restored) > 0 {
+		printer.P("restored %d pack files from quarantine", len(restored))
+	}
+
 	printer.P("saving backup copies of pack files to current folder")
 	for id := range ids {
 		buf, err := repo.LoadRaw(ctx, restic.PackFile, id)
@@ -91,11 +133,16 @@ func runRepairPacks(ctx context.Context, gopts global.Options, term ui.Terminal,
 		}
 	}
 
-	err = repository.RepairPacks(ctx, repo, ids, printer)
+	lost, err := repository.RepairPacks(ctx, repo, ids, printer)
 	if err != nil {
 		return errors.Fatalf("%s", err)
 	}
 
+	if len(lost) == 0 {
+		printer.P("\nall blobs were salvaged, no snapshots are affected")
+		return nil
+	}
 	printer.E("\nUse `restic repair snapshots --forget` to remove the corrupted data blobs from all snapshots")
+	printer.E("Add `--salvage-report <file>` to list the files which were lost")
 	return nil
 }
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
@@ -15,24 +16,21 @@ import (
 
 // testRunRepairPacks runs `restic repair packs` with capturing stdout and stderr
 func testRunRepairPacks(t testing.TB, gopts global.Options, args []string) (string, string, error) {
+	return testRunRepairPacksWithOpts(t, gopts, RepairPacksOptions{}, args)
+}
+
+func testRunRepairPacksWithOpts(t testing.TB, gopts global.Options, opts RepairPacksOptions, args []string) (string, string, error) {
 	bufStdout, bufStderr, err := withCaptureStdoutStderr(t, gopts, func(ctx context.Context, gopts global.Options) error {
-		return runRepairPacks(ctx, gopts, gopts.Term, args)
+		return runRepairPacks(ctx, opts, gopts, gopts.Term, args)
 	})
 
 	return bufStdout.String(), bufStderr.String(), err
 }
 
-func TestRunRepairPackfiles(t *testing.T) {
-	env, cleanup := withTestEnvironment(t)
-	defer cleanup()
-
-	testSetupBackupData(t, env)
-	// backup of subtree 0/0/9/42
-	testRunBackup(t, env.testdata, []string{filepath.Join(env.testdata, "0", "0", "9", "42")}, BackupOptions{}, env.gopts)
-	testListSnapshots(t, env.gopts, 1)
-
+// testFindDataPack returns the ID of a pack file containing data blobs.
+func testFindDataPack(t testing.TB, gopts global.Options) restic.ID {
 	packfileID := restic.ID{}
-	err := withTermStatus(t, env.gopts, func(ctx context.Context, gopts global.Options) error {
+	err := withTermStatus(t, gopts, func(ctx context.Context, gopts global.Options) error {
 		printer := progress.NewTerminalPrinter(false, gopts.Verbosity, gopts.Term)
 		_, repo, unlock, err := openWithReadLock(ctx, gopts, false, printer)
 		rtest.OK(t, err)
@@ -53,6 +51,19 @@ func TestRunRepairPackfiles(t *testing.T) {
 	rtest.OK(t, err)
 
 	rtest.Assert(t, !packfileID.IsNull(), "expected valid packfile ID")
+	return packfileID
+}
+
+func TestRunRepairPackfiles(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	// backup of subtree 0/0/9/42
+	testRunBackup(t, env.testdata, []string{filepath.Join(env.testdata, "0", "0", "9", "42")}, BackupOptions{}, env.gopts)
+	testListSnapshots(t, env.gopts, 1)
+
+	packfileID := testFindDataPack(t, env.gopts)
 	packIDString := packfileID.String()
 	filename := filepath.Join(env.gopts.Repo, "data", packIDString[0:2], packIDString)
 	rtest.OK(t, os.Remove(filename))
@@ -73,3 +84,50 @@ func TestRunRepairPackfiles(t *testing.T) {
 	_, _, err = testRunCheckOutput(t, env.gopts, false)
 	rtest.OK(t, err)
 }
+
+func TestRepairQuarantinedPackfiles(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	testRunBackup(t, env.testdata, []string{filepath.Join(env.testdata, "0", "0", "9", "42")}, BackupOptions{}, env.gopts)
+
+	packfileID := testFindDataPack(t, env.gopts)
+	packIDString := packfileID.String()
+	filename := filepath.Join(env.gopts.Repo, "data", packIDString[0:2], packIDString)
+	buf, err := os.ReadFile(filename)
+	rtest.OK(t, err)
+	buf[0] ^= 0xff
+	rtest.OK(t, os.Chmod(filename, 0o600))
+	rtest.OK(t, os.WriteFile(filename, buf, 0o600))
+
+	reportFile := filepath.Join(env.base, "report.json")
+	_, _, err = withCaptureStdoutStderr(t, env.gopts, func(ctx context.Context, gopts global.Options) error {
+		_, err := runCheck(ctx, CheckOptions{ReadData: true, PackRetries: 2, Quarantine: true, Report: reportFile}, gopts, nil, gopts.Term)
+		return err
+	})
+	rtest.Assert(t, err != nil, "expected check errors, got none")
+	_, err = os.Stat(filename)
+	rtest.Assert(t, errors.Is(err, os.ErrNotExist), "damaged pack file %v was not removed", filename)
+	quarantined := filepath.Join(env.gopts.Repo, "quarantine", packIDString)
+	_, err = os.Stat(quarantined)
+	rtest.OK(t, err)
+
+	report, err := loadDamagedPacksReport(reportFile)
+	rtest.OK(t, err)
+	rtest.Equals(t, 1, len(report.Packs))
+	rtest.Equals(t, packfileID, report.Packs[0].ID)
+	rtest.Equals(t, 3, report.Packs[0].Attempts)
+	rtest.Assert(t, report.Packs[0].Quarantined, "pack not marked as quarantined")
+
+	cleanupChdir := rtest.Chdir(t, env.base)
+	defer cleanupChdir()
+	_, _, err = testRunRepairPacksWithOpts(t, env.gopts, RepairPacksOptions{Report: reportFile}, nil)
+	rtest.OK(t, err)
+	_, err = os.Stat(quarantined)
+	rtest.Assert(t, errors.Is(err, os.ErrNotExist), "quarantined pack file was not removed")
+
+	testRunRepairSnapshot(t, env.gopts, true)
+	_, _, err = testRunCheckOutput(t, env.gopts, false)
+	rtest.OK(t, err)
+}
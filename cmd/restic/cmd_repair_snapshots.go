@@ -2,6 +2,9 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"os"
 	"slices"
 
 	"github.com/restic/restic/internal/data"
@@ -43,6 +46,9 @@ is to run "backup" which in that case is able to heal existing snapshots. Only
 use the "repair snapshots" command if you need to recover an old and broken
 snapshot!
 
+The --salvage-report option writes a list of all files and directories which
+were lost or damaged to the given file. This also works with --dry-run.
+
 EXIT STATUS
 ===========
 
@@ -65,8 +71,9 @@ Exit status is 12 if the password is incorrect.
 
 // RepairOptions collects all options for the repair command.
 type RepairOptions struct {
-	DryRun bool
-	Forget bool
+	DryRun        bool
+	Forget        bool
+	SalvageReport string
 
 	data.SnapshotFilter
 }
@@ -74,6 +81,7 @@ type RepairOptions struct {
 func (opts *RepairOptions) AddFlags(f *pflag.FlagSet) {
 	f.BoolVarP(&opts.DryRun, "dry-run", "n", false, "do not do anything, just print what would be done")
 	f.BoolVarP(&opts.Forget, "forget", "", false, "remove original snapshots after creating new ones")
+	f.StringVar(&opts.SalvageReport, "salvage-report", "", "write a list of lost files and directories to `file`")
 
 	initMultiSnapshotFilter(f, &opts.SnapshotFilter, true)
 }
@@ -127,6 +135,18 @@ func runRepairSnapshots(ctx context.Context, gopts global.Options, opts RepairOp
 		return err
 	}
 
+	report := &salvageReport{}
+	if opts.SalvageReport != "" {
+		f, err := os.Create(opts.SalvageReport)
+		if err != nil {
+			return errors.Fatalf("unable to create salvage report: %v", err)
+		}
+		defer func() {
+			_ = f.Close()
+		}()
+		report.wr = f
+	}
+
 	// Four error cases are checked:
 	// - tree is a nil tree (-> will be replaced by an empty tree)
 	// - trees which cannot be loaded (-> the tree contents will be removed)
@@ -136,6 +156,7 @@ func runRepairSnapshots(ctx context.Context, gopts global.Options, opts RepairOp
 		RewriteNode: func(node *data.Node, path string) *data.Node {
 			if node.Type == data.NodeTypeIrregular || node.Type == data.NodeTypeInvalid {
 				printer.P("  file %q: removed node with invalid type %q", path, node.Type)
+				report.add(path, "removed node with invalid type")
 				return nil
 			}
 			if node.Type != data.NodeTypeFile {
@@ -156,6 +177,7 @@ func runRepairSnapshots(ctx context.Context, gopts global.Options, opts RepairOp
 			}
 			if !ok {
 				printer.P("  file %q: removed missing content", path)
+				report.add(path, "content lost")
 			} else if newSize != node.Size {
 				printer.P("  file %q: fixed incorrect size", path)
 			}
@@ -167,14 +189,18 @@ func runRepairSnapshots(ctx context.Context, gopts global.Options, opts RepairOp
 		RewriteFailedTree: func(_ restic.ID, path string, _ error) (data.TreeNodeIterator, error) {
 			if path == "/" {
 				printer.P("  dir %q: not readable", path)
+				report.add(path, "snapshot lost")
 				// remove snapshots with invalid root node
 				return nil, nil
 			}
 			// If a subtree fails to load, remove it
 			printer.P("  dir %q: replaced with empty directory", path)
+			report.add(path, "directory lost")
 			return slices.Values([]data.NodeOrError{}), nil
 		},
 		AllowUnstableSerialization: true,
+		// the report must list the damage for every snapshot
+		DisableNodeCache: opts.SalvageReport != "",
 	})
 
 	changedCount := 0
@@ -188,6 +214,7 @@ func runRepairSnapshots(ctx context.Context, gopts global.Options, opts RepairOp
 		}
 
 		printer.P("\n%v", sn)
+		report.snapshot = *sn.ID()
 		changed, err := filterAndReplaceSnapshot(ctx, repo, sn,
 			func(ctx context.Context, sn *data.Snapshot, uploader restic.BlobSaver) (restic.ID, *data.SnapshotSummary, error) {
 				id, err := rewriter.RewriteTree(ctx, repo, uploader, "/", *sn.Tree)
@@ -205,8 +232,14 @@ func runRepairSnapshots(ctx context.Context, gopts global.Options, opts RepairOp
 	if errOuter != nil {
 		return errOuter
 	}
+	if report.err != nil {
+		return errors.Fatalf("unable to write salvage report: %v", report.err)
+	}
 
 	printer.P("")
+	if opts.SalvageReport != "" {
+		printer.P("wrote list of %v lost files and directories to %v", report.count, opts.SalvageReport)
+	}
 	if changedCount == 0 {
 		if !opts.DryRun {
 			printer.P("no snapshots were modified")
@@ -223,3 +256,20 @@ func runRepairSnapshots(ctx context.Context, gopts global.Options, opts RepairOp
 
 	return nil
 }
+
+// salvageReport lists the files and directories which were lost while
+// repairing snapshots. Nothing is written if wr is nil.
+type salvageReport struct {
+	wr       io.Writer
+	snapshot restic.ID
+	count    int
+	err      error
+}
+
+func (r *salvageReport) add(path, status string) {
+	if r.wr == nil || r.err != nil {
+		return
+	}
+	r.count++
+	_, r.err = fmt.Fprintf(r.wr, "%v\t%v\t%q\n", r.snapshot.Str(), status, path)
+}
@@ -10,6 +10,7 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/restic/restic/internal/global"
@@ -73,6 +74,43 @@ func TestRepairSnapshotsWithLostData(t *testing.T) {
 	rtest.OK(t, err)
 }
 
+func TestRepairSnapshotsSalvageReport(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+
+	createRandomFile(t, env, "foo/bar/file", 512*1024)
+	testRunBackup(t, "", []string{env.testdata}, BackupOptions{}, env.gopts)
+	// damage repository
+	removePacksExcept(env.gopts, t, restic.NewIDSet(), false)
+
+	createRandomFile(t, env, "foo/bar/file2", 256*1024)
+	testRunBackup(t, "", []string{env.testdata}, BackupOptions{}, env.gopts)
+	testListSnapshots(t, env.gopts, 2)
+
+	testRunRebuildIndex(t, env.gopts)
+	reportFile := filepath.Join(env.base, "salvage-report")
+	opts := RepairOptions{
+		DryRun:        true,
+		SalvageReport: reportFile,
+	}
+	rtest.OK(t, withTermStatus(t, env.gopts, func(ctx context.Context, gopts global.Options) error {
+		return runRepairSnapshots(ctx, gopts, opts, nil, gopts.Term)
+	}))
+	testListSnapshots(t, env.gopts, 2)
+
+	// the file is lost in both snapshots
+	report, err := os.ReadFile(reportFile)
+	rtest.OK(t, err)
+	lines := strings.Split(strings.TrimSpace(string(report)), "\n")
+	rtest.Equals(t, 2, len(lines), "unexpected report %q", string(report))
+	for _, line := range lines {
+		rtest.Assert(t, strings.Contains(line, "content lost"), "unexpected report line %q", line)
+		rtest.Assert(t, strings.HasSuffix(line, "file\""), "unexpected report line %q", line)
+	}
+}
+
 func TestRepairSnapshotsWithLostTree(t *testing.T) {
 	env, cleanup := withTestEnvironment(t)
 	defer cleanup()
@@ -0,0 +1,383 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/restic/restic/internal/backend/location"
+	"github.com/restic/restic/internal/data"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/global"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui"
+	"github.com/restic/restic/internal/ui/progress"
+)
+
+func newReplicateCommand(globalOptions *global.Options) *cobra.Command {
+	var opts ReplicateOptions
+	cmd := &cobra.Command{
+		Use:   "replicate [flags]",
+		Short: "Mirror snapshots to other repositories",
+		Long: `
+The "replicate" command copies all snapshots of the repository which are missing
+in one or more destination repositories, like the "copy" command does. Snapshots
+which were already copied to a destination are skipped, thus only new snapshots
+are transferred.
+
+The destinations are read from a JSON file, which contains a list of objects with
+the following fields:
+
+* name: name of the destination used in the output (optional)
+* repository: location of the destination repository
+* repository_file: file from which to read the repository location
+* password_file: file to read the repository password from
+* password_command: shell command to obtain the repository password from
+* key_hint: key ID of the key to try decrypting the repository first
+* host, tag, path: only replicate snapshots matching these lists, like the
+  "--host", "--tag" and "--path" options of the "copy" command
+
+If "--interval" is set, the command keeps running and checks the repository for
+new snapshots at the given interval. Otherwise, the destinations are updated
+once. The repository is not locked between two runs.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful.
+Exit status is 1 if there was any error.
+Exit status is 10 if the repository does not exist.
+Exit status is 11 if the repository is already locked.
+Exit status is 12 if the password is incorrect.
+`,
+		GroupID:           cmdGroupDefault,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runReplicate(cmd.Context(), opts, *globalOptions, args, globalOptions.Term)
+		},
+	}
+
+	opts.AddFlags(cmd.Flags())
+	return cmd
+}
+
+// ReplicateOptions bundles all options for the replicate command.
+type ReplicateOptions struct {
+	DestinationsFile string
+	Interval         time.Duration
+}
+
+func (opts *ReplicateOptions) AddFlags(f *pflag.FlagSet) {
+	f.StringVar(&opts.DestinationsFile, "destinations", "", "read the destination repositories from JSON `file`")
+	f.DurationVar(&opts.Interval, "interval", 0, "check for new snapshots every `duration` instead of exiting after one run")
+}
+
+// ReplicateDestination describes a destination in the destinations file.
+type ReplicateDestination struct {
+	Name            string   `json:"name"`
+	Repository      string   `json:"repository"`
+	RepositoryFile  string   `json:"repository_file"`
+	PasswordFile    string   `json:"password_file"`
+	PasswordCommand string   `json:"password_command"`
+	KeyHint         string   `json:"key_hint"`
+	Hosts           []string `json:"host"`
+	Tags            []string `json:"tag"`
+	Paths           []string `json:"path"`
+}
+
+// replicateTarget is a destination with resolved repository options.
+type replicateTarget struct {
+	name   string
+	gopts  global.Options
+	filter data.SnapshotFilter
+}
+
+type replicateSnapshotMessage struct {
+	MessageType      string `json:"message_type"` // "snapshot_copied"
+	Destination      string `json:"destination"`
+	SourceSnapshotID string `json:"source_snapshot_id"`
+	SnapshotID       string `json:"snapshot_id"`
+}
+
+type replicateErrorMessage struct {
+	MessageType string `json:"message_type"` // "error"
+	Destination string `json:"destination,omitempty"`
+	Message     string `json:"message"`
+}
+
+type replicateSummaryMessage struct {
+	MessageType        string `json:"message_type"` // "summary"
+	Destinations       int    `json:"destinations"`
+	FailedDestinations int    `json:"failed_destinations"`
+	CopiedSnapshots    int    `json:"copied_snapshots"`
+}
+
+func loadReplicateDestinations(filename string) ([]ReplicateDestination, error) {
+	if filename == "" {
+		return nil, errors.Fatal("please specify the destination repositories using --destinations")
+	}
+
+	buf, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Fatalf("unable to read destinations: %v", err)
+	}
+
+	var dests []ReplicateDestination
+	if err := json.Unmarshal(buf, &dests); err != nil {
+		return nil, errors.Fatalf("unable to parse destinations file %v: %v", filename, err)
+	}
+	if len(dests) == 0 {
+		return nil, errors.Fatalf("no destinations found in %v", filename)
+	}
+
+	for i, dest := range dests {
+		if (dest.Repository == "") == (dest.RepositoryFile == "") {
+			return nil, errors.Fatalf("destination %d: specify exactly one of repository and repository_file", i+1)
+		}
+	}
+	return dests, nil
+}
+
+// resolveReplicateTargets resolves the repository options of all destinations.
+// This asks for the passwords of destinations without password file or command.
+func resolveReplicateTargets(ctx context.Context, gopts global.Options, dests []ReplicateDestination) ([]replicateTarget, error) {
+	targets := make([]replicateTarget, 0, len(dests))
+	for _, dest := range dests {
+		name := dest.Name
+		if name == "" {
+			name = dest.RepositoryFile
+			if dest.Repository != "" {
+				name = location.StripPassword(gopts.Backends, dest.Repository)
+			}
+		}
+
+		secondary := global.SecondaryRepoOptions{
+			LegacyRepo:            dest.Repository,
+			LegacyRepositoryFile:  dest.RepositoryFile,
+			LegacyPasswordFile:    dest.PasswordFile,
+			LegacyPasswordCommand: dest.PasswordCommand,
+			LegacyKeyHint:         dest.KeyHint,
+		}
+		dstGopts, _, err := secondary.FillGlobalOpts(ctx, gopts, "destination "+name)
+		if err != nil {
+			return nil, err
+		}
+
+		filter := data.SnapshotFilter{Hosts: dest.Hosts, Paths: dest.Paths}
+		for _, tags := range dest.Tags {
+			if err := filter.Tags.Set(tags); err != nil {
+				return nil, err
+			}
+		}
+
+		targets = append(targets, replicateTarget{name: name, gopts: dstGopts, filter: filter})
+	}
+	return targets, nil
+}
+
+// replicator copies new snapshots to all destinations.
+type replicator struct {
+	gopts   global.Options
+	targets []replicateTarget
+	printer restic.Printer
+	term    ui.Terminal
+
+	// source snapshots which were present during the last successful run
+	lastSnapshots restic.IDSet
+}
+
+func (r *replicator) printError(dest string, err error) {
+	if r.gopts.JSON {
+		r.term.Error(ui.ToJSONString(replicateErrorMessage{
+			MessageType: "error",
+			Destination: dest,
+			Message:     err.Error(),
+		}))
+		return
+	}
+	if dest != "" {
+		r.printer.E("replicating to %v failed: %v", dest, err)
+	} else {
+		r.printer.E("replication failed: %v", err)
+	}
+}
+
+// run copies the missing snapshots to all destinations and returns the number
+// of destinations which could not be updated.
+func (r *replicator) run(ctx context.Context) (int, error) {
+	ctx, srcRepo, unlock, err := openWithReadLock(ctx, r.gopts, r.gopts.NoLock, r.printer)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	srcSnapshotLister, err := restic.MemorizeList(ctx, srcRepo, restic.SnapshotFile)
+	if err != nil {
+		return 0, err
+	}
+
+	snapshots := restic.NewIDSet()
+	err = srcSnapshotLister.List(ctx, restic.SnapshotFile, func(id restic.ID, _ int64) error {
+		snapshots.Insert(id)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if r.lastSnapshots != nil && r.lastSnapshots.Equals(snapshots) {
+		debug.Log("no new snapshots")
+		r.printer.V("no new snapshots found")
+		r.printSummary(0, 0)
+		return 0, nil
+	}
+
+	debug.Log("Loading source index")
+	if err := srcRepo.LoadIndex(ctx, r.printer); err != nil {
+		return 0, err
+	}
+
+	failed, copied := 0, 0
+	for _, target := range r.targets {
+		n, err := r.replicateTo(ctx, srcRepo, srcSnapshotLister, target)
+		copied += n
+		if err != nil {
+			if ctx.Err() != nil {
+				return failed, ctx.Err()
+			}
+			r.printError(target.name, err)
+			failed++
+		}
+	}
+
+	if failed == 0 {
+		r.lastSnapshots = snapshots
+	}
+	r.printSummary(failed, copied)
+	return failed, nil
+}
+
+// replicateTo copies the missing snapshots to a single destination and returns
+// the number of copied snapshots.
+func (r *replicator) replicateTo(ctx context.Context, srcRepo *repository.Repository,
+	srcSnapshotLister restic.Lister, target replicateTarget) (int, error) {
+
+	if !r.gopts.JSON {
+		r.printer.P("replicating to %v", target.name)
+	}
+
+	ctx, dstRepo, unlock, err := openWithAppendLock(ctx, target.gopts, false, r.printer)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	dstSnapshotLister, err := restic.MemorizeList(ctx, dstRepo, restic.SnapshotFile)
+	if err != nil {
+		return 0, err
+	}
+
+	debug.Log("Loading index of %v", target.name)
+	if err := dstRepo.LoadIndex(ctx, r.printer); err != nil {
+		return 0, err
+	}
+
+	dstSnapshotByOriginal, err := collectCopiedSnapshots(ctx, &target.filter, dstSnapshotLister, dstRepo)
+	if err != nil {
+		return 0, err
+	}
+
+	selectedSnapshots := collectAllSnapshots(ctx, CopyOptions{SnapshotFilter: target.filter},
+		srcSnapshotLister, srcRepo, dstSnapshotByOriginal, nil, r.printer)
+
+	copied := 0
+	err = copyTreeBatched(ctx, srcRepo, dstRepo, selectedSnapshots, r.printer, func(sn *data.Snapshot, newID restic.ID) {
+		copied++
+		if r.gopts.JSON {
+			r.term.Print(ui.ToJSONString(replicateSnapshotMessage{
+				MessageType:      "snapshot_copied",
+				Destination:      target.name,
+				SourceSnapshotID: sn.ID().String(),
+				SnapshotID:       newID.String(),
+			}))
+		}
+	})
+	if err != nil {
+		return copied, err
+	}
+
+	if !r.gopts.JSON {
+		r.printer.P("copied %d snapshots to %v\n", copied, target.name)
+	}
+	return copied, ctx.Err()
+}
+
+func (r *replicator) printSummary(failed, copied int) {
+	if r.gopts.JSON {
+		r.term.Print(ui.ToJSONString(replicateSummaryMessage{
+			MessageType:        "summary",
+			Destinations:       len(r.targets),
+			FailedDestinations: failed,
+			CopiedSnapshots:    copied,
+		}))
+	}
+}
+
+func runReplicate(ctx context.Context, opts ReplicateOptions, gopts global.Options, args []string, term ui.Terminal) error {
+	if len(args) > 0 {
+		return errors.Fatal("the replicate command expects no arguments, only options - please see `restic help replicate` for usage and flags")
+	}
+	if opts.Interval < 0 {
+		return errors.Fatal("--interval must not be negative")
+	}
+
+	printer := progress.NewTerminalPrinter(gopts.JSON, gopts.Verbosity, term)
+
+	dests, err := loadReplicateDestinations(opts.DestinationsFile)
+	if err != nil {
+		return err
+	}
+	targets, err := resolveReplicateTargets(ctx, gopts, dests)
+	if err != nil {
+		return err
+	}
+
+	r := &replicator{
+		gopts:   gopts,
+		targets: targets,
+		printer: printer,
+		term:    term,
+	}
+
+	for {
+		failed, err := r.run(ctx)
+		if opts.Interval == 0 {
+			if err != nil {
+				return err
+			}
+			if failed > 0 {
+				return errors.Fatalf("failed to replicate to %d of %d destinations", failed, len(targets))
+			}
+			return nil
+		}
+
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			r.printError("", err)
+		}
+
+		debug.Log("waiting %v for the next run", opts.Interval)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(opts.Interval):
+		}
+	}
+}
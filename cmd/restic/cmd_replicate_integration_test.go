@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/restic/restic/internal/data"
+	"github.com/restic/restic/internal/global"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func testWriteReplicateDestinations(t testing.TB, dir string, dests ...ReplicateDestination) string {
+	buf, err := json.Marshal(dests)
+	rtest.OK(t, err)
+	filename := filepath.Join(dir, "destinations.json")
+	rtest.OK(t, os.WriteFile(filename, buf, 0600))
+	return filename
+}
+
+func testReplicateDestination(t testing.TB, env *testEnvironment, tags ...string) ReplicateDestination {
+	passwordFile := filepath.Join(env.base, "password")
+	rtest.OK(t, os.WriteFile(passwordFile, []byte(env.gopts.Password), 0600))
+	return ReplicateDestination{
+		Repository:   env.gopts.Repo,
+		PasswordFile: passwordFile,
+		Tags:         tags,
+	}
+}
+
+func testRunReplicate(t testing.TB, gopts global.Options, destinations string) []byte {
+	buf, err := withCaptureStdout(t, gopts, func(ctx context.Context, gopts global.Options) error {
+		return runReplicate(ctx, ReplicateOptions{DestinationsFile: destinations}, gopts, nil, gopts.Term)
+	})
+	rtest.OK(t, err)
+	return buf.Bytes()
+}
+
+func TestReplicate(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+	env2, cleanup2 := withTestEnvironment(t)
+	defer cleanup2()
+	env3, cleanup3 := withTestEnvironment(t)
+	defer cleanup3()
+
+	testSetupBackupData(t, env)
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9")}, BackupOptions{}, env.gopts)
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9", "2")},
+		BackupOptions{Tags: data.TagLists{data.TagList{"important"}}}, env.gopts)
+
+	testRunInit(t, env2.gopts)
+	testRunInit(t, env3.gopts)
+	destinations := testWriteReplicateDestinations(t, env.base,
+		testReplicateDestination(t, env2),
+		testReplicateDestination(t, env3, "important"))
+
+	testRunReplicate(t, env.gopts, destinations)
+	testListSnapshots(t, env2.gopts, 2)
+	testListSnapshots(t, env3.gopts, 1)
+	testRunCheck(t, env2.gopts)
+	testRunCheck(t, env3.gopts)
+
+	// only the new snapshot must be copied
+	testRunBackup(t, "", []string{filepath.Join(env.testdata, "0", "0", "9", "3")}, BackupOptions{}, env.gopts)
+
+	gopts := env.gopts
+	gopts.JSON = true
+	output := testRunReplicate(t, gopts, destinations)
+
+	var copied []replicateSnapshotMessage
+	var summary replicateSummaryMessage
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		var msg struct {
+			MessageType string `json:"message_type"`
+		}
+		rtest.OK(t, json.Unmarshal(scanner.Bytes(), &msg))
+		switch msg.MessageType {
+		case "snapshot_copied":
+			var c replicateSnapshotMessage
+			rtest.OK(t, json.Unmarshal(scanner.Bytes(), &c))
+			copied = append(copied, c)
+		case "summary":
+			rtest.OK(t, json.Unmarshal(scanner.Bytes(), &summary))
+		}
+	}
+	rtest.OK(t, scanner.Err())
+
+	rtest.Equals(t, 1, len(copied), "unexpected number of copied snapshots")
+	rtest.Equals(t, env2.gopts.Repo, copied[0].Destination)
+	rtest.Equals(t, replicateSummaryMessage{
+		MessageType:     "summary",
+		Destinations:    2,
+		CopiedSnapshots: 1,
+	}, summary)
+
+	copiedIDs := restic.NewIDSet(testListSnapshots(t, env2.gopts, 3)...)
+	rtest.Assert(t, copiedIDs.Has(restic.TestParseID(copied[0].SnapshotID)), "copied snapshot %v not found", copied[0].SnapshotID)
+	testListSnapshots(t, env3.gopts, 1)
+}
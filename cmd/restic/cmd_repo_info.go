@@ -0,0 +1,414 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/global"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui"
+	"github.com/restic/restic/internal/ui/progress"
+)
+
+func newRepoInfoCommand(globalOptions *global.Options) *cobra.Command {
+	var opts RepoInfoOptions
+
+	cmd := &cobra.Command{
+		Use:   "repo-info [flags]",
+		Short: "Show detailed repository metrics",
+		Long: `
+The "repo-info" command shows detailed metrics about the repository: the
+number and size of index, snapshot and pack files, a histogram of the pack
+sizes, the number of blobs and their compression per blob type, how many blobs
+are stored more than once and how many files were loaded from the local cache.
+
+The metrics are only computed from the index and the list of files in the
+repository, thus no pack files are downloaded.
+
+With "--json", the metrics are printed as JSON. With "--prometheus", they are
+printed in the Prometheus text exposition format, for example to be collected
+by the textfile collector of the node exporter after scheduled jobs.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful.
+Exit status is 1 if there was any error.
+Exit status is 10 if the repository does not exist.
+Exit status is 11 if the repository is already locked.
+Exit status is 12 if the password is incorrect.
+`,
+		GroupID:           cmdGroupDefault,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runRepoInfo(cmd.Context(), opts, *globalOptions, args, globalOptions.Term)
+		},
+	}
+
+	opts.AddFlags(cmd.Flags())
+	return cmd
+}
+
+// RepoInfoOptions collects all options for the repo-info command.
+type RepoInfoOptions struct {
+	Prometheus bool
+}
+
+func (opts *RepoInfoOptions) AddFlags(f *pflag.FlagSet) {
+	f.BoolVar(&opts.Prometheus, "prometheus", false, "print the metrics in the Prometheus text exposition format")
+}
+
+// repoInfoPackSizeBuckets are the upper bounds of the pack size histogram.
+var repoInfoPackSizeBuckets = []uint64{
+	1 << 20,
+	4 << 20,
+	8 << 20,
+	16 << 20,
+	32 << 20,
+	64 << 20,
+	128 << 20,
+}
+
+// repoInfo collects the metrics of a repository.
+type repoInfo struct {
+	RepositoryID string             `json:"repository_id"`
+	Version      uint               `json:"version"`
+	Snapshots    repoInfoFiles      `json:"snapshots"`
+	Index        repoInfoFiles      `json:"index"`
+	Packs        repoInfoPacks      `json:"packs"`
+	Blobs        []*repoInfoBlobs   `json:"blobs"`
+	Cache        *repoInfoCacheInfo `json:"cache,omitempty"`
+}
+
+// repoInfoFiles holds the number and total size of files of one type.
+type repoInfoFiles struct {
+	Count uint64 `json:"count"`
+	Size  uint64 `json:"size"`
+}
+
+type repoInfoPacks struct {
+	repoInfoFiles
+	DataPacks  uint64 `json:"data_packs"`
+	TreePacks  uint64 `json:"tree_packs"`
+	MixedPacks uint64 `json:"mixed_packs"`
+	// packs which are not referenced by the index
+	UnindexedPacks uint64               `json:"unindexed_packs"`
+	Histogram      []repoInfoSizeBucket `json:"histogram"`
+}
+
+// repoInfoSizeBucket is a bucket of a histogram. It counts the values which
+// are larger than the upper bound of the previous bucket and not larger than
+// UpperBound. An UpperBound of zero denotes the last bucket without limit.
+type repoInfoSizeBucket struct {
+	UpperBound uint64 `json:"upper_bound,omitempty"`
+	Count      uint64 `json:"count"`
+}
+
+// repoInfoBlobs holds the metrics for one blob type.
+type repoInfoBlobs struct {
+	Type string `json:"type"`
+	// number of distinct blobs
+	Count uint64 `json:"count"`
+	// number of additional copies of blobs stored in more than one pack
+	DuplicateCount uint64 `json:"duplicate_count"`
+	// size of the blobs in the repository, including duplicates
+	Size uint64 `json:"size"`
+	// size of the blobs before compression, including duplicates
+	UncompressedSize uint64 `json:"uncompressed_size"`
+	CompressedCount  uint64 `json:"compressed_count"`
+	// ratio of the uncompressed to the stored size
+	CompressionRatio float64 `json:"compression_ratio"`
+	// ratio of all stored blobs to the distinct blobs
+	DuplicationRatio float64 `json:"duplication_ratio"`
+}
+
+type repoInfoCacheInfo struct {
+	Path   string `json:"path"`
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+}
+
+func runRepoInfo(ctx context.Context, opts RepoInfoOptions, gopts global.Options, args []string, term ui.Terminal) error {
+	if len(args) > 0 {
+		return errors.Fatal("the repo-info command expects no arguments, only options - please see `restic help repo-info` for usage and flags")
+	}
+	if opts.Prometheus && gopts.JSON {
+		return errors.Fatal("--prometheus and --json cannot be used together")
+	}
+
+	printer := progress.NewTerminalPrinter(gopts.JSON || opts.Prometheus, gopts.Verbosity, term)
+
+	ctx, repo, unlock, err := openWithReadLock(ctx, gopts, gopts.NoLock, printer)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// list the snapshots before the index, like all other commands do
+	info, err := listRepoInfoFiles(ctx, repo)
+	if err != nil {
+		return err
+	}
+
+	if err = repo.LoadIndex(ctx, printer); err != nil {
+		return err
+	}
+
+	if err = collectRepoInfo(ctx, repo, info); err != nil {
+		return err
+	}
+	if c := repo.Cache(); c != nil {
+		hits, misses := c.Stats()
+		info.Cache = &repoInfoCacheInfo{Path: c.Dir(), Hits: hits, Misses: misses}
+	}
+
+	switch {
+	case gopts.JSON:
+		err = json.NewEncoder(term.OutputWriter()).Encode(info)
+		if err != nil {
+			return fmt.Errorf("encoding output: %v", err)
+		}
+		return nil
+	case opts.Prometheus:
+		return writeRepoInfoPrometheus(term.OutputWriter(), info)
+	}
+
+	printer.S("Repository %v (version %d)", info.RepositoryID, info.Version)
+	printer.S("")
+	printer.S("Snapshots:         %d", info.Snapshots.Count)
+	printer.S("Index files:       %d (%s)", info.Index.Count, ui.FormatBytes(info.Index.Size))
+	printer.S("Pack files:        %d (%s)", info.Packs.Count, ui.FormatBytes(info.Packs.Size))
+	printer.S("  data packs:      %d", info.Packs.DataPacks)
+	printer.S("  tree packs:      %d", info.Packs.TreePacks)
+	if info.Packs.MixedPacks > 0 {
+		printer.S("  mixed packs:     %d", info.Packs.MixedPacks)
+	}
+	if info.Packs.UnindexedPacks > 0 {
+		printer.S("  unindexed packs: %d", info.Packs.UnindexedPacks)
+	}
+	printer.S("")
+	printer.S("Pack sizes:")
+	lower := "0 B"
+	for _, bucket := range info.Packs.Histogram {
+		upper := "inf"
+		if bucket.UpperBound > 0 {
+			upper = ui.FormatBytes(bucket.UpperBound)
+		}
+		printer.S("  %11s - %-12s %d", lower, upper, bucket.Count)
+		lower = upper
+	}
+	for _, blobs := range info.Blobs {
+		printer.S("")
+		printer.S("%s blobs:", blobs.Type)
+		printer.S("  count:             %d", blobs.Count)
+		printer.S("  duplicates:        %d (ratio %.2f)", blobs.DuplicateCount, blobs.DuplicationRatio)
+		printer.S("  size:              %s", ui.FormatBytes(blobs.Size))
+		printer.S("  uncompressed size: %s", ui.FormatBytes(blobs.UncompressedSize))
+		printer.S("  compressed blobs:  %d (ratio %.2fx)", blobs.CompressedCount, blobs.CompressionRatio)
+	}
+	if info.Cache != nil {
+		printer.S("")
+		printer.S("Cache %v:", info.Cache.Path)
+		printer.S("  hits:   %d", info.Cache.Hits)
+		printer.S("  misses: %d", info.Cache.Misses)
+	}
+	return nil
+}
+
+// repoInfoRepository is the subset of the repository used by collectRepoInfo.
+type repoInfoRepository interface {
+	restic.Lister
+	restic.ListBlobser
+	Config() restic.Config
+}
+
+// listRepoInfoFiles counts the snapshot and index files of repo.
+func listRepoInfoFiles(ctx context.Context, repo repoInfoRepository) (*repoInfo, error) {
+	info := &repoInfo{
+		RepositoryID: repo.Config().ID,
+		Version:      repo.Config().Version,
+	}
+
+	for _, f := range []struct {
+		tpe restic.FileType
+		dst *repoInfoFiles
+	}{
+		{restic.SnapshotFile, &info.Snapshots},
+		{restic.IndexFile, &info.Index},
+	} {
+		err := repo.List(ctx, f.tpe, func(_ restic.ID, size int64) error {
+			f.dst.Count++
+			f.dst.Size += uint64(size)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return info, nil
+}
+
+// collectRepoInfo adds the metrics computed from the loaded index and the list
+// of pack files of repo to info.
+func collectRepoInfo(ctx context.Context, repo repoInfoRepository, info *repoInfo) error {
+	// collect the blob types stored in each pack and count duplicate blobs
+	packTypes := make(map[restic.ID][restic.NumBlobTypes]bool)
+	blobs := [restic.NumBlobTypes]*repoInfoBlobs{}
+	for _, t := range []restic.BlobType{restic.DataBlob, restic.TreeBlob} {
+		blobs[t] = &repoInfoBlobs{Type: t.String()}
+		info.Blobs = append(info.Blobs, blobs[t])
+	}
+	seen := restic.NewBlobSet()
+	err := repo.ListBlobs(ctx, func(pb restic.PackBlob) {
+		h := pb.Handle()
+		types := packTypes[pb.PackID()]
+		types[h.Type] = true
+		packTypes[pb.PackID()] = types
+
+		b := blobs[h.Type]
+		if seen.Has(h) {
+			b.DuplicateCount++
+		} else {
+			seen.Insert(h)
+			b.Count++
+		}
+		b.Size += uint64(pb.CiphertextLength())
+		b.UncompressedSize += uint64(pb.UncompressedCiphertextLength())
+		if pb.IsCompressed() {
+			b.CompressedCount++
+		}
+	})
+	if err != nil {
+		return err
+	}
+	for _, b := range info.Blobs {
+		if b.Size > 0 {
+			b.CompressionRatio = float64(b.UncompressedSize) / float64(b.Size)
+		}
+		if b.Count > 0 {
+			b.DuplicationRatio = float64(b.Count+b.DuplicateCount) / float64(b.Count)
+		}
+	}
+
+	histogram := make([]repoInfoSizeBucket, len(repoInfoPackSizeBuckets)+1)
+	for i, upper := range repoInfoPackSizeBuckets {
+		histogram[i].UpperBound = upper
+	}
+	err = repo.List(ctx, restic.PackFile, func(id restic.ID, size int64) error {
+		info.Packs.Count++
+		info.Packs.Size += uint64(size)
+
+		i := 0
+		for i < len(repoInfoPackSizeBuckets) && uint64(size) > repoInfoPackSizeBuckets[i] {
+			i++
+		}
+		histogram[i].Count++
+
+		types, ok := packTypes[id]
+		switch {
+		case !ok:
+			info.Packs.UnindexedPacks++
+		case types[restic.DataBlob] && types[restic.TreeBlob]:
+			info.Packs.MixedPacks++
+		case types[restic.TreeBlob]:
+			info.Packs.TreePacks++
+		default:
+			info.Packs.DataPacks++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	info.Packs.Histogram = histogram
+
+	return nil
+}
+
+// writeRepoInfoPrometheus writes the metrics in info in the Prometheus text
+// exposition format.
+func writeRepoInfoPrometheus(wr io.Writer, info *repoInfo) error {
+	var out strings.Builder
+	repoLabel := fmt.Sprintf("repository_id=%q", info.RepositoryID)
+
+	metric := func(name, tpe, help string) {
+		fmt.Fprintf(&out, "# HELP restic_repository_%s %s\n", name, help)
+		fmt.Fprintf(&out, "# TYPE restic_repository_%s %s\n", name, tpe)
+	}
+	value := func(name string, labels string, v float64) {
+		fmt.Fprintf(&out, "restic_repository_%s{%s} %s\n", name, labels, strconv.FormatFloat(v, 'f', -1, 64))
+	}
+	withType := func(t string) string {
+		return fmt.Sprintf("%s,type=%q", repoLabel, t)
+	}
+
+	metric("snapshots", "gauge", "Number of snapshots.")
+	value("snapshots", repoLabel, float64(info.Snapshots.Count))
+
+	metric("index_files", "gauge", "Number of index files.")
+	value("index_files", repoLabel, float64(info.Index.Count))
+	metric("index_size_bytes", "gauge", "Total size of all index files.")
+	value("index_size_bytes", repoLabel, float64(info.Index.Size))
+
+	metric("packs", "gauge", "Number of pack files by the type of blobs they contain.")
+	for _, p := range []struct {
+		tpe   string
+		count uint64
+	}{
+		{"data", info.Packs.DataPacks},
+		{"tree", info.Packs.TreePacks},
+		{"mixed", info.Packs.MixedPacks},
+		{"unindexed", info.Packs.UnindexedPacks},
+	} {
+		value("packs", withType(p.tpe), float64(p.count))
+	}
+
+	metric("pack_size_bytes", "histogram", "Size of the pack files.")
+	var cumulative uint64
+	for _, bucket := range info.Packs.Histogram {
+		cumulative += bucket.Count
+		le := "+Inf"
+		if bucket.UpperBound > 0 {
+			le = strconv.FormatUint(bucket.UpperBound, 10)
+		}
+		value("pack_size_bytes_bucket", fmt.Sprintf("%s,le=%q", repoLabel, le), float64(cumulative))
+	}
+	value("pack_size_bytes_sum", repoLabel, float64(info.Packs.Size))
+	value("pack_size_bytes_count", repoLabel, float64(info.Packs.Count))
+
+	for _, m := range []struct {
+		name, help string
+		value      func(b *repoInfoBlobs) float64
+	}{
+		{"blobs", "Number of distinct blobs.", func(b *repoInfoBlobs) float64 { return float64(b.Count) }},
+		{"duplicate_blobs", "Number of additional copies of blobs stored in more than one pack.", func(b *repoInfoBlobs) float64 { return float64(b.DuplicateCount) }},
+		{"blob_size_bytes", "Size of all blobs in the repository.", func(b *repoInfoBlobs) float64 { return float64(b.Size) }},
+		{"blob_uncompressed_size_bytes", "Size of all blobs before compression.", func(b *repoInfoBlobs) float64 { return float64(b.UncompressedSize) }},
+		{"compressed_blobs", "Number of compressed blobs.", func(b *repoInfoBlobs) float64 { return float64(b.CompressedCount) }},
+		{"compression_ratio", "Ratio of the uncompressed to the stored size of all blobs.", func(b *repoInfoBlobs) float64 { return b.CompressionRatio }},
+		{"duplication_ratio", "Ratio of all stored blobs to the distinct blobs.", func(b *repoInfoBlobs) float64 { return b.DuplicationRatio }},
+	} {
+		metric(m.name, "gauge", m.help)
+		for _, b := range info.Blobs {
+			value(m.name, withType(b.Type), m.value(b))
+		}
+	}
+
+	if info.Cache != nil {
+		metric("cache_hits_total", "counter", "Number of files loaded from the local cache by this command.")
+		value("cache_hits_total", repoLabel, float64(info.Cache.Hits))
+		metric("cache_misses_total", "counter", "Number of files loaded from the backend instead of the local cache by this command.")
+		value("cache_misses_total", repoLabel, float64(info.Cache.Misses))
+	}
+
+	_, err := io.WriteString(wr, out.String())
+	return err
+}
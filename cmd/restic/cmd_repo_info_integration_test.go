@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/restic/restic/internal/global"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func testRunRepoInfo(t testing.TB, opts RepoInfoOptions, gopts global.Options) string {
+	buf, err := withCaptureStdout(t, gopts, func(ctx context.Context, gopts global.Options) error {
+		return runRepoInfo(ctx, opts, gopts, nil, gopts.Term)
+	})
+	rtest.OK(t, err)
+	return buf.String()
+}
+
+func TestRepoInfo(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	// repo-info lists the index files before loading the index
+	env.gopts.BackendTestHook = nil
+
+	testSetupBackupData(t, env)
+	testRunBackup(t, env.testdata, []string{"."}, BackupOptions{}, env.gopts)
+
+	env.gopts.JSON = true
+	var info repoInfo
+	rtest.OK(t, json.Unmarshal([]byte(testRunRepoInfo(t, RepoInfoOptions{}, env.gopts)), &info))
+	env.gopts.JSON = false
+
+	rtest.Equals(t, uint64(1), info.Snapshots.Count)
+	rtest.Assert(t, info.Index.Count > 0, "no index files found")
+	rtest.Assert(t, info.Packs.Count > 0 && info.Packs.Count == info.Packs.DataPacks+info.Packs.TreePacks+info.Packs.MixedPacks,
+		"wrong pack counts %+v", info.Packs)
+	rtest.Equals(t, uint64(0), info.Packs.UnindexedPacks)
+
+	var histogramCount uint64
+	for _, bucket := range info.Packs.Histogram {
+		histogramCount += bucket.Count
+	}
+	rtest.Equals(t, info.Packs.Count, histogramCount)
+
+	rtest.Equals(t, 2, len(info.Blobs))
+	for _, blobs := range info.Blobs {
+		rtest.Assert(t, blobs.Count > 0, "no %v blobs found", blobs.Type)
+		rtest.Equals(t, float64(1), blobs.DuplicationRatio, blobs.Type)
+	}
+
+	out := testRunRepoInfo(t, RepoInfoOptions{Prometheus: true}, env.gopts)
+	for _, line := range []string{
+		"# TYPE restic_repository_pack_size_bytes histogram\n",
+		"restic_repository_snapshots{repository_id=",
+		`,le="+Inf"} `,
+		`,type="tree"} `,
+	} {
+		rtest.Assert(t, strings.Contains(out, line), "missing %q in output:\n%s", line, out)
+	}
+}
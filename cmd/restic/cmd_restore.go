@@ -2,14 +2,19 @@ package main
 
 import (
 	"context"
+	"io"
 	"path/filepath"
 	"runtime"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/restic/restic/internal/data"
 	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/dump"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/filter"
+	"github.com/restic/restic/internal/fs"
 	"github.com/restic/restic/internal/global"
 	"github.com/restic/restic/internal/restic"
 	"github.com/restic/restic/internal/restorer"
@@ -18,13 +23,14 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
+	"golang.org/x/sync/errgroup"
 )
 
 func newRestoreCommand(globalOptions *global.Options) *cobra.Command {
 	var opts RestoreOptions
 
 	cmd := &cobra.Command{
-		Use:   "restore [flags] snapshotID",
+		Use:   "restore [flags] snapshotID ...",
 		Short: "Extract the data from a snapshot",
 		Long: `
 The "restore" command extracts the data from a snapshot from the repository to
@@ -37,6 +43,65 @@ To only restore a specific subfolder, you can use the "snapshotID:subfolder"
 syntax, where "subfolder" is a path within the snapshot tree as shown by
 "restic ls".
 
+To restore an explicit list of files and directories, pass a file containing
+one path per line, as shown by "restic ls", to "--files-from-verbatim". Unlike
+include patterns, the paths are used as-is, and everything below a listed
+directory is restored as well.
+
+Several snapshots can be restored at once, sharing the loaded index and the
+connections to the repository. If more than one snapshotID is given, each
+snapshot is restored to a subdirectory of the target directory named after its
+short ID. Alternatively, "--map snapshotID=directory" restores a snapshot to the
+given directory.
+
+With "--resume", restic records which files were completely restored. If the
+restore is interrupted, running the same command again skips these files. Files
+are flushed to disk before they are recorded. The state is removed once the
+restore has finished.
+
+With "--hardlink-index", restic records where hardlinked files were restored.
+Separate restores of parts of the same snapshot, for example of different
+subdirectories, which use the same index file then restore files that share an
+inode in the snapshot as hard links of each other. All restores must target the
+same filesystem.
+
+With "--target -", the snapshot is not restored to a directory but written to
+stdout as an archive. The format of the archive is selected using "--archive",
+which supports "tar" (default) and "zip". Include and exclude patterns are
+applied to the archive contents.
+
+With "--secure-target", restic does not follow symlinks inside the target
+directory and refuses to restore items outside of it. Use this option when
+restoring into a directory which may have been modified by other users. The
+checks do not protect against modifications of the target directory while the
+restore is running.
+
+To reduce the impact of a large restore on other programs running on the same
+system, use "--idle-priority" to lower the CPU and I/O priority of restic. If a
+download limit is set using "--limit-download", fewer pack files are
+downloaded concurrently, such that restored files are completed earlier.
+
+With "--verify", restic reads all restored files again once the restore has
+finished and compares them with the snapshot. "--verify=inline" instead reads
+back the data directly after writing it, which avoids the second pass over all
+files. Only the data written by the restore is verified in this mode. Use
+"--fsync" to flush each file to disk once its content is complete.
+
+With "--metadata-only", restic does not create, remove or modify the content of
+any file. Instead, it re-applies the ownership, permissions, timestamps,
+extended attributes and ACLs stored in the snapshot to the items which already
+exist in the target directory. This repairs the metadata of files restored
+using other tools or after permissions were changed accidentally. Items which
+do not exist or whose type differs from the snapshot are skipped with a warning.
+
+On Windows, "--security-descriptors" selects which parts of the security
+descriptors are restored: "full" (default) restores the owner, group, DACL and
+SACL, "dacl" only restores the DACL and "none" skips them. Restoring the full
+security descriptor requires administrator privileges. Without them, only the
+DACL is restored and a warning lists the parts that could not be restored for
+each item. Alternate data streams of files are restored unless "--skip-ads" is
+specified.
+
 POSIX ACLs are always restored by their numeric value, while file ownership can optionally be restored by name instead of numeric value.
 
 EXIT STATUS
@@ -68,19 +133,36 @@ type RestoreOptions struct {
 	data.SnapshotFilter
 	DryRun              bool
 	Sparse              bool
-	Verify              bool
+	Verify              restorer.VerifyMode
+	Fsync               bool
 	Overwrite           restorer.OverwriteBehavior
 	Delete              bool
 	ExcludeXattrPattern []string
 	IncludeXattrPattern []string
 	OwnershipByName     bool
+	OwnerMap            []string
+	NoOwner             bool
+	NoPerms             bool
+	SecurityDescriptors fs.SecurityDescriptorMode
+	SkipADS             bool
+	Resume              bool
+	ResumeState         string
+	HardlinkIndex       string
+	SecureTarget        bool
+	MetadataOnly        bool
+	IdlePriority        bool
+	Archive             string
+	Map                 []string
+	FilesFromVerbatim   []string
 }
 
 func (opts *RestoreOptions) AddFlags(f *pflag.FlagSet) {
-	f.StringVarP(&opts.Target, "target", "t", "", "directory to extract data to")
+	f.StringVarP(&opts.Target, "target", "t", "", "directory to extract data to, or \"-\" to write an archive to stdout")
+	f.StringVarP(&opts.Archive, "archive", "a", "tar", "set archive `format` as \"tar\" or \"zip\" when restoring to stdout")
 
 	opts.ExcludePatternOptions.Add(f)
 	opts.IncludePatternOptions.Add(f)
+	f.StringArrayVar(&opts.FilesFromVerbatim, "files-from-verbatim", nil, "read the paths to restore from `file`, one path per line (can be specified multiple times)")
 
 	f.StringArrayVar(&opts.ExcludeXattrPattern, "exclude-xattr", nil, "exclude xattr by `pattern` (can be specified multiple times)")
 	f.StringArrayVar(&opts.IncludeXattrPattern, "include-xattr", nil, "include xattr by `pattern` (can be specified multiple times)")
@@ -88,14 +170,33 @@ func (opts *RestoreOptions) AddFlags(f *pflag.FlagSet) {
 	initSingleSnapshotFilter(f, &opts.SnapshotFilter)
 	f.BoolVar(&opts.DryRun, "dry-run", false, "do not write any data, just show what would be done")
 	f.BoolVar(&opts.Sparse, "sparse", false, "restore files as sparse")
-	f.BoolVar(&opts.Verify, "verify", false, "verify restored files content")
+	f.Var(&opts.Verify, "verify", "verify restored files content, by reading them again 'after' the restore or 'inline' while writing them")
+	f.Lookup("verify").NoOptDefVal = "after"
+	f.BoolVar(&opts.Fsync, "fsync", false, "flush each restored file to disk once its content is complete")
 	f.Var(&opts.Overwrite, "overwrite", "overwrite behavior, one of (always|if-changed|if-newer|never)")
 	f.BoolVar(&opts.Delete, "delete", false, "delete files from target directory if they do not exist in snapshot. Use '--dry-run -vv' to check what would be deleted")
+	f.BoolVar(&opts.Resume, "resume", false, "record restored files and skip them when resuming an interrupted restore")
+	f.StringVar(&opts.ResumeState, "resume-state", "", "store the state of a resumable restore in `file` (default: "+defaultResumeStateFile+" in the target directory)")
+	f.StringVar(&opts.HardlinkIndex, "hardlink-index", "", "record restored hardlinks in `file` to link files across separate restores of the same snapshot")
+	f.BoolVar(&opts.SecureTarget, "secure-target", false, "do not follow symlinks inside the target directory and refuse to restore items outside of it")
+	f.BoolVar(&opts.MetadataOnly, "metadata-only", false, "only restore the metadata of existing files and directories, without modifying their content")
+	f.BoolVar(&opts.IdlePriority, "idle-priority", false, "lower the CPU and I/O priority of restic to idle")
+	f.StringArrayVar(&opts.Map, "map", nil, "restore snapshot to directory, in the format `snapshotID=directory` (can be specified multiple times)")
 	if runtime.GOOS != "windows" {
 		f.BoolVar(&opts.OwnershipByName, "ownership-by-name", false, "restore file ownership by user name and group name (except POSIX ACLs)")
+		f.StringArrayVar(&opts.OwnerMap, "owner-map", nil, "remap user and group IDs, in the format `uid:from=to,gid:from=to` (use * as from to map all other IDs, can be specified multiple times)")
+		f.BoolVar(&opts.NoOwner, "no-owner", false, "do not restore the owner of files and directories")
+	} else {
+		f.Var(&opts.SecurityDescriptors, "security-descriptors", "restore security descriptors, one of (full|dacl|none)")
+		f.BoolVar(&opts.SkipADS, "skip-ads", false, "do not restore alternate data streams of files")
 	}
+	f.BoolVar(&opts.NoPerms, "no-perms", false, "do not restore the permissions and ACLs of files and directories")
 }
 
+// defaultResumeStateFile is the name of the resume state file within the
+// target directory if --resume-state is not specified.
+const defaultResumeStateFile = ".restic-restore-state"
+
 func runRestore(ctx context.Context, opts RestoreOptions, gopts global.Options,
 	term ui.Terminal, args []string) error {
 
@@ -105,6 +206,16 @@ func runRestore(ctx context.Context, opts RestoreOptions, gopts global.Options,
 	} else {
 		printer = restoreui.NewTextProgress(term, gopts.Verbosity)
 	}
+	socket, err := openProgressSocket(gopts)
+	if err != nil {
+		return err
+	}
+	if socket != nil {
+		defer func() {
+			_ = socket.Close()
+		}()
+		printer = restoreui.NewTeeProgress(printer, restoreui.NewJSONProgress(socket.Terminal(), 3), socket.Printer(printer), progressStatusEnabled(gopts, term))
+	}
 
 	excludePatternFns, err := opts.ExcludePatternOptions.CollectPatterns(printer.E)
 	if err != nil {
@@ -116,35 +227,71 @@ func runRestore(ctx context.Context, opts RestoreOptions, gopts global.Options,
 		return err
 	}
 
+	if len(opts.FilesFromVerbatim) > 0 {
+		includeFn, err := collectIncludePaths(opts, gopts, term.InputRaw())
+		if err != nil {
+			return err
+		}
+		includePatternFns = append(includePatternFns, includeFn)
+	}
+
 	hasExcludes := len(excludePatternFns) > 0
 	hasIncludes := len(includePatternFns) > 0
 
-	switch {
-	case len(args) == 0:
-		return errors.Fatal("no snapshot ID specified")
-	case len(args) > 1:
-		return errors.Fatalf("more than one snapshot ID specified: %v", args)
+	if hasExcludes && hasIncludes {
+		return errors.Fatal("exclude and include patterns are mutually exclusive")
 	}
 
-	if opts.Target == "" {
-		return errors.Fatal("please specify a directory to restore to (--target)")
+	jobs, err := collectRestoreJobs(opts, args)
+	if err != nil {
+		return err
 	}
 
-	if hasExcludes && hasIncludes {
-		return errors.Fatal("exclude and include patterns are mutually exclusive")
+	if opts.Target == "-" {
+		if err := opts.checkStdoutOptions(); err != nil {
+			return err
+		}
 	}
 
-	if opts.DryRun && opts.Verify {
+	if opts.DryRun && opts.Verify != restorer.VerifyNone {
 		return errors.Fatal("--dry-run and --verify are mutually exclusive")
 	}
 
-	if opts.Delete && filepath.Clean(opts.Target) == "/" && !hasExcludes && !hasIncludes {
-		return errors.Fatal("'--target / --delete' must be combined with an include or exclude filter")
+	if opts.ResumeState != "" && !opts.Resume {
+		return errors.Fatal("--resume-state requires --resume")
+	}
+
+	if opts.MetadataOnly {
+		if err := opts.checkMetadataOnlyOptions(); err != nil {
+			return err
+		}
 	}
 
-	snapshotIDString := args[0]
+	var ownerMap *restorer.OwnerMap
+	if len(opts.OwnerMap) > 0 {
+		if opts.OwnershipByName || opts.NoOwner {
+			return errors.Fatal("--owner-map cannot be combined with --ownership-by-name or --no-owner")
+		}
+		ownerMap, err = restorer.ParseOwnerMap(opts.OwnerMap)
+		if err != nil {
+			return errors.Fatalf("%v", err)
+		}
+	}
+	if opts.NoOwner && opts.OwnershipByName {
+		return errors.Fatal("--no-owner and --ownership-by-name are mutually exclusive")
+	}
+
+	for _, job := range jobs {
+		if opts.Delete && filepath.Clean(job.target) == "/" && !hasExcludes && !hasIncludes {
+			return errors.Fatal("'--target / --delete' must be combined with an include or exclude filter")
+		}
+	}
 
-	debug.Log("restore %v to %v", snapshotIDString, opts.Target)
+	if opts.IdlePriority {
+		if err := setIdlePriority(); err != nil {
+			return errors.Fatalf("lowering the priority failed: %v", err)
+		}
+	}
 
 	ctx, repo, unlock, err := openWithReadLock(ctx, gopts, gopts.NoLock, printer)
 	if err != nil {
@@ -152,9 +299,13 @@ func runRestore(ctx context.Context, opts RestoreOptions, gopts global.Options,
 	}
 	defer unlock()
 
-	sn, subfolder, err := opts.SnapshotFilter.FindLatest(ctx, repo, repo, snapshotIDString)
-	if err != nil {
-		return errors.Fatalf("failed to find snapshot: %v", err)
+	for i := range jobs {
+		debug.Log("restore %v to %v", jobs[i].snapshotID, jobs[i].target)
+
+		jobs[i].sn, jobs[i].subfolder, err = opts.SnapshotFilter.FindLatest(ctx, repo, repo, jobs[i].snapshotID)
+		if err != nil {
+			return errors.Fatalf("failed to find snapshot: %v", err)
+		}
 	}
 
 	err = repo.LoadIndex(ctx, printer)
@@ -162,36 +313,143 @@ func runRestore(ctx context.Context, opts RestoreOptions, gopts global.Options,
 		return err
 	}
 
-	sn.Tree, err = data.FindTreeDirectory(ctx, repo, sn.Tree, subfolder)
+	targets := make(map[string]string)
+	for i := range jobs {
+		job := &jobs[i]
+		job.sn.Tree, err = data.FindTreeDirectory(ctx, repo, job.sn.Tree, job.subfolder)
+		if err != nil {
+			return err
+		}
+
+		if job.target == "" {
+			job.target = filepath.Join(opts.Target, job.sn.ID().Str())
+		}
+		if other, ok := targets[filepath.Clean(job.target)]; ok {
+			return errors.Fatalf("snapshots %v and %v cannot both be restored to %v", other, job.snapshotID, job.target)
+		}
+		targets[filepath.Clean(job.target)] = job.snapshotID
+	}
+
+	selectFilter := selectPatternFilter(excludePatternFns, includePatternFns)
+
+	if opts.Target == "-" {
+		return restoreToStdout(ctx, repo, jobs[0].sn, opts.Archive, selectFilter, term)
+	}
+
+	xattrSelectFilter, err := getXattrSelectFilter(opts, printer)
 	if err != nil {
 		return err
 	}
 
-	progress := restoreui.NewProgress(printer, gopts.Quiet, gopts.JSON, term.CanUpdateStatus())
-	res := restorer.NewRestorer(repo, sn, restorer.Options{
-		DryRun:          opts.DryRun,
-		Sparse:          opts.Sparse,
-		Progress:        progress,
-		Overwrite:       opts.Overwrite,
-		Delete:          opts.Delete,
-		OwnershipByName: opts.OwnershipByName,
-	})
+	progress := restoreui.NewProgress(printer, gopts.Quiet && socket == nil, gopts.JSON || socket != nil, term.CanUpdateStatus())
+
+	var totalErrors atomic.Int64
+	for i := range jobs {
+		job := &jobs[i]
+
+		resumeState := ""
+		if opts.Resume {
+			resumeState = opts.ResumeState
+			if resumeState == "" {
+				resumeState = filepath.Join(job.target, defaultResumeStateFile)
+			}
+		}
 
-	totalErrors := 0
-	res.Error = func(location string, err error) error {
-		totalErrors++
-		return progress.Error(location, err)
+		job.res = restorer.NewRestorer(repo, job.sn, restorer.Options{
+			DryRun:              opts.DryRun,
+			Sparse:              opts.Sparse,
+			Progress:            progress,
+			Overwrite:           opts.Overwrite,
+			Delete:              opts.Delete,
+			OwnershipByName:     opts.OwnershipByName,
+			OwnerMap:            ownerMap,
+			NoOwner:             opts.NoOwner,
+			NoPerms:             opts.NoPerms,
+			ResumeState:         resumeState,
+			HardlinkIndex:       opts.HardlinkIndex,
+			SecureTarget:        opts.SecureTarget,
+			DownloadLimitKb:     gopts.Limits.DownloadKb,
+			MetadataOnly:        opts.MetadataOnly,
+			SecurityDescriptors: opts.SecurityDescriptors,
+			SkipADS:             opts.SkipADS,
+			Verify:              opts.Verify,
+			Fsync:               opts.Fsync,
+		})
+
+		job.res.Error = func(location string, err error) error {
+			totalErrors.Add(1)
+			return progress.Error(location, err)
+		}
+		job.res.Warn = func(message string) {
+			printer.E("Warning: %s\n", message)
+		}
+		job.res.Info = func(message string) {
+			if gopts.JSON {
+				return
+			}
+			printer.P("Info: %s\n", message)
+		}
+		if selectFilter != nil {
+			job.res.SelectFilter = selectFilter
+		}
+		job.res.XattrSelectFilter = xattrSelectFilter
 	}
-	res.Warn = func(message string) {
-		printer.E("Warning: %s\n", message)
+
+	// restore the snapshots in parallel, the connections to the backend are
+	// limited by the repository anyway
+	wg, wgCtx := errgroup.WithContext(ctx)
+	wg.SetLimit(int(repo.Connections()))
+	for i := range jobs {
+		job := &jobs[i]
+		if !gopts.JSON {
+			printer.P("restoring %s to %s\n", job.res.Snapshot(), job.target)
+		}
+
+		wg.Go(func() error {
+			var err error
+			job.countRestoredFiles, err = job.res.RestoreTo(wgCtx, job.target)
+			return err
+		})
+	}
+	if err := wg.Wait(); err != nil {
+		return err
+	}
+
+	progress.Finish()
+
+	if totalErrors.Load() > 0 {
+		return errors.Fatalf("There were %d errors", totalErrors.Load())
 	}
-	res.Info = func(message string) {
-		if gopts.JSON {
-			return
+
+	if opts.Verify == restorer.VerifyAfter {
+		for _, job := range jobs {
+			if !gopts.JSON {
+				printer.P("verifying files in %s\n", job.target)
+			}
+			var count int
+			t0 := time.Now()
+			bar := printer.NewCounterTerminalOnly("files verified")
+			count, err = job.res.VerifyFiles(ctx, job.target, job.countRestoredFiles, bar)
+			if err != nil {
+				return err
+			}
+			if totalErrors.Load() > 0 {
+				return errors.Fatalf("There were %d errors", totalErrors.Load())
+			}
+
+			if !gopts.JSON {
+				printer.P("finished verifying %d files in %s (took %s)\n", count, job.target,
+					time.Since(t0).Round(time.Millisecond))
+			}
 		}
-		printer.P("Info: %s\n", message)
 	}
 
+	return nil
+}
+
+// selectPatternFilter returns a filter which selects items based on the
+// exclude or include patterns. It returns nil if there are no patterns.
+func selectPatternFilter(excludePatternFns []filter.RejectByNameFunc, includePatternFns []filter.IncludeByNameFunc) func(item string, isDir bool) (selectedForRestore bool, childMayBeSelected bool) {
 	selectExcludeFilter := func(item string, isDir bool) (selectedForRestore bool, childMayBeSelected bool) {
 		matched := false
 		for _, rejectFn := range excludePatternFns {
@@ -231,53 +489,180 @@ func runRestore(ctx context.Context, opts RestoreOptions, gopts global.Options,
 		return selectedForRestore, childMayBeSelected
 	}
 
-	if hasExcludes {
-		res.SelectFilter = selectExcludeFilter
-	} else if hasIncludes {
-		res.SelectFilter = selectIncludeFilter
+	if len(excludePatternFns) > 0 {
+		return selectExcludeFilter
+	} else if len(includePatternFns) > 0 {
+		return selectIncludeFilter
 	}
+	return nil
+}
 
-	res.XattrSelectFilter, err = getXattrSelectFilter(opts, printer)
-	if err != nil {
-		return err
+// collectIncludePaths returns a filter which includes the paths listed in the
+// files passed to --files-from-verbatim.
+func collectIncludePaths(opts RestoreOptions, gopts global.Options, stdin io.ReadCloser) (filter.IncludeByNameFunc, error) {
+	var paths []string
+	for _, file := range opts.FilesFromVerbatim {
+		if file == "-" && gopts.Password == "" && !gopts.InsecureNoPassword {
+			return nil, errors.Fatal("unable to read password from stdin when the paths to restore are read from stdin, use --password-file or $RESTIC_PASSWORD")
+		}
+
+		lines, err := readLines(file, stdin)
+		if err != nil {
+			return nil, errors.Fatalf("--files-from-verbatim: %v", err)
+		}
+		for _, line := range lines {
+			if line == "" {
+				continue
+			}
+			paths = append(paths, line)
+		}
 	}
 
-	if !gopts.JSON {
-		printer.P("restoring %s to %s\n", res.Snapshot(), opts.Target)
+	if len(paths) == 0 {
+		return nil, errors.Fatal("--files-from-verbatim: no paths to restore found")
 	}
+	return filter.IncludeByPaths(paths), nil
+}
 
-	countRestoredFiles, err := res.RestoreTo(ctx, opts.Target)
-	if err != nil {
-		return err
+// restoreJob is a snapshot which is restored to target.
+type restoreJob struct {
+	snapshotID string
+	// target is empty if the snapshot is restored to a subdirectory of
+	// --target named after the ID of the snapshot.
+	target string
+
+	sn                 *data.Snapshot
+	subfolder          string
+	res                *restorer.Restorer
+	countRestoredFiles uint64
+}
+
+// collectRestoreJobs returns the snapshots to restore from the arguments and
+// the --map option.
+func collectRestoreJobs(opts RestoreOptions, args []string) ([]restoreJob, error) {
+	if len(args) == 0 && len(opts.Map) == 0 {
+		return nil, errors.Fatal("no snapshot ID specified")
 	}
 
-	progress.Finish()
+	var jobs []restoreJob
+	if len(args) > 0 {
+		if opts.Target == "" {
+			return nil, errors.Fatal("please specify a directory to restore to (--target)")
+		}
+		target := ""
+		if len(args) == 1 && len(opts.Map) == 0 {
+			target = opts.Target
+		}
+		for _, arg := range args {
+			jobs = append(jobs, restoreJob{snapshotID: arg, target: target})
+		}
+	} else if opts.Target != "" {
+		return nil, errors.Fatal("--target requires snapshot IDs as arguments, use --map to specify the directory of each snapshot")
+	}
 
-	if totalErrors > 0 {
-		return errors.Fatalf("There were %d errors", totalErrors)
+	for _, m := range opts.Map {
+		snapshotID, target, found := strings.Cut(m, "=")
+		if !found || snapshotID == "" || target == "" {
+			return nil, errors.Fatalf("invalid --map %q, expected 'snapshotID=directory'", m)
+		}
+		if target == "-" {
+			return nil, errors.Fatal("--map cannot be used to restore to stdout, use --target -")
+		}
+		jobs = append(jobs, restoreJob{snapshotID: snapshotID, target: target})
 	}
 
-	if opts.Verify {
-		if !gopts.JSON {
-			printer.P("verifying files in %s\n", opts.Target)
+	if len(jobs) > 1 {
+		if opts.Target == "-" {
+			return nil, errors.Fatal("only a single snapshot can be restored to stdout")
 		}
-		var count int
-		t0 := time.Now()
-		bar := printer.NewCounterTerminalOnly("files verified")
-		count, err = res.VerifyFiles(ctx, opts.Target, countRestoredFiles, bar)
-		if err != nil {
-			return err
+		if opts.ResumeState != "" {
+			return nil, errors.Fatal("--resume-state cannot be used when restoring multiple snapshots")
 		}
-		if totalErrors > 0 {
-			return errors.Fatalf("There were %d errors", totalErrors)
+		if opts.HardlinkIndex != "" {
+			return nil, errors.Fatal("--hardlink-index cannot be used when restoring multiple snapshots")
 		}
+	}
+	return jobs, nil
+}
 
-		if !gopts.JSON {
-			printer.P("finished verifying %d files in %s (took %s)\n", count, opts.Target,
-				time.Since(t0).Round(time.Millisecond))
+// checkStdoutOptions rejects options which cannot be used when the restored
+// data is written to stdout as an archive.
+func (opts *RestoreOptions) checkStdoutOptions() error {
+	switch opts.Archive {
+	case "tar", "zip":
+	default:
+		return errors.Fatalf("unknown archive format %q", opts.Archive)
+	}
+
+	for _, o := range []struct {
+		set  bool
+		name string
+	}{
+		{opts.DryRun, "--dry-run"},
+		{opts.Sparse, "--sparse"},
+		{opts.Verify != restorer.VerifyNone, "--verify"},
+		{opts.Fsync, "--fsync"},
+		{opts.Overwrite != restorer.OverwriteAlways, "--overwrite"},
+		{opts.Delete, "--delete"},
+		{opts.Resume, "--resume"},
+		{opts.HardlinkIndex != "", "--hardlink-index"},
+		{opts.SecureTarget, "--secure-target"},
+		{opts.MetadataOnly, "--metadata-only"},
+		{opts.OwnershipByName, "--ownership-by-name"},
+		{len(opts.OwnerMap) > 0, "--owner-map"},
+		{opts.NoOwner, "--no-owner"},
+		{opts.NoPerms, "--no-perms"},
+		{opts.SecurityDescriptors != fs.SecurityDescriptorFull, "--security-descriptors"},
+		{opts.SkipADS, "--skip-ads"},
+		{len(opts.ExcludeXattrPattern) > 0, "--exclude-xattr"},
+		{len(opts.IncludeXattrPattern) > 0, "--include-xattr"},
+	} {
+		if o.set {
+			return errors.Fatalf("%s cannot be used when restoring to stdout", o.name)
 		}
 	}
+	return nil
+}
+
+// checkMetadataOnlyOptions rejects options which have no effect or conflict
+// with only restoring the metadata of existing items.
+func (opts *RestoreOptions) checkMetadataOnlyOptions() error {
+	for _, o := range []struct {
+		set  bool
+		name string
+	}{
+		{opts.Sparse, "--sparse"},
+		{opts.Verify != restorer.VerifyNone, "--verify"},
+		{opts.Fsync, "--fsync"},
+		{opts.Delete, "--delete"},
+		{opts.Resume, "--resume"},
+		{opts.HardlinkIndex != "", "--hardlink-index"},
+	} {
+		if o.set {
+			return errors.Fatalf("--metadata-only and %s are mutually exclusive", o.name)
+		}
+	}
+	return nil
+}
+
+// restoreToStdout writes the tree of sn as an archive of the given format to stdout.
+func restoreToStdout(ctx context.Context, repo restic.Repository, sn *data.Snapshot, format string,
+	selectFilter func(item string, isDir bool) (bool, bool), term ui.Terminal) error {
 
+	if err := checkStdoutArchive(term)(); err != nil {
+		return err
+	}
+
+	tree, err := data.LoadTree(ctx, repo, *sn.Tree)
+	if err != nil {
+		return errors.Fatalf("loading tree for snapshot %q failed: %v", sn.ID().Str(), err)
+	}
+
+	d := dump.New(format, repo, term.OutputRaw())
+	d.SelectFilter = selectFilter
+	if err := d.DumpTree(ctx, tree, "/"); err != nil {
+		return errors.Fatalf("cannot restore to stdout: %v", err)
+	}
 	return nil
 }
 
@@ -1,11 +1,15 @@
 package main
 
 import (
+	"archive/tar"
 	"context"
 	"fmt"
+	"io"
 	"math/rand"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
 	"syscall"
 	"testing"
@@ -15,6 +19,7 @@ import (
 	"github.com/restic/restic/internal/global"
 	"github.com/restic/restic/internal/repository"
 	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/restorer"
 	rtest "github.com/restic/restic/internal/test"
 )
 
@@ -167,6 +172,54 @@ func TestRestoreIncludes(t *testing.T) {
 	testRestoreFileInclusions(t)
 }
 
+func TestRestoreFilesFromVerbatim(t *testing.T) {
+	testfiles := []struct {
+		path    string
+		include bool
+	}{
+		{"dir1/include me.txt", true},
+		{"dir1/something_else.txt", false},
+		{"dir2/file.txt", true},
+		{"dir2/subdir/file.txt", true},
+		{"dir3/[a].txt", true},
+		{"dir3/a.txt", false},
+	}
+
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+	for _, testFile := range testfiles {
+		fullPath := filepath.Join(env.testdata, testFile.path)
+		rtest.OK(t, os.MkdirAll(filepath.Dir(fullPath), 0755))
+		rtest.OK(t, appendRandomData(fullPath, 100))
+	}
+	testRunBackup(t, filepath.Dir(env.testdata), []string{filepath.Base(env.testdata)}, BackupOptions{}, env.gopts)
+	snapshotID := testListSnapshots(t, env.gopts, 1)[0]
+
+	// the paths must not be interpreted as patterns
+	paths := []string{"/testdata/dir1/include me.txt", "", "/testdata/dir2", "/testdata/dir3/[a].txt"}
+	pathsFile := filepath.Join(env.base, "paths")
+	rtest.OK(t, os.WriteFile(pathsFile, []byte(strings.Join(paths, "\n")), 0644))
+
+	restoredir := filepath.Join(env.base, "restore")
+	opts := RestoreOptions{Target: restoredir, FilesFromVerbatim: []string{pathsFile}}
+	rtest.OK(t, testRunRestoreAssumeFailure(t, snapshotID.String(), opts, env.gopts))
+
+	for _, testFile := range testfiles {
+		_, err := os.Stat(filepath.Join(restoredir, "testdata", testFile.path))
+		if testFile.include {
+			rtest.OK(t, err)
+		} else {
+			rtest.Assert(t, os.IsNotExist(err), "file %s should not have been restored", testFile.path)
+		}
+	}
+
+	opts.Excludes = []string{"*.txt"}
+	err := testRunRestoreAssumeFailure(t, snapshotID.String(), opts, env.gopts)
+	rtest.Assert(t, err != nil, "expected error for --files-from-verbatim combined with excludes")
+}
+
 func TestRestoreFilter(t *testing.T) {
 	testfiles := []struct {
 		name    string
@@ -262,6 +315,170 @@ func TestRestore(t *testing.T) {
 	rtest.Assert(t, diff == "", "directories are not equal %v", diff)
 }
 
+func TestRestoreVerifyInline(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+
+	for i := 0; i < 5; i++ {
+		p := filepath.Join(env.testdata, fmt.Sprintf("foo/testfile%v", i))
+		rtest.OK(t, os.MkdirAll(filepath.Dir(p), 0755))
+		rtest.OK(t, appendRandomData(p, uint(rand.Intn(2<<21))))
+	}
+
+	testRunBackup(t, env.testdata, []string{"."}, BackupOptions{}, env.gopts)
+	snapshotID := testListSnapshots(t, env.gopts, 1)[0]
+
+	var opts RestoreOptions
+	rtest.OK(t, opts.Verify.Set("inline"))
+	opts.Fsync = true
+	opts.Target = filepath.Join(env.base, "restore")
+	rtest.OK(t, testRunRestoreAssumeFailure(t, snapshotID.String(), opts, env.gopts))
+
+	diff := directoriesContentsDiff(t, env.testdata, opts.Target)
+	rtest.Assert(t, diff == "", "directories are not equal %v", diff)
+
+	opts.DryRun = true
+	err := testRunRestoreAssumeFailure(t, snapshotID.String(), opts, env.gopts)
+	rtest.Assert(t, err != nil && strings.Contains(err.Error(), "mutually exclusive"), "unexpected error %v", err)
+}
+
+func TestRestoreToStdout(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+
+	for _, name := range []string{"foo/testfile", "foo/skip.txt", "bar/testfile"} {
+		p := filepath.Join(env.testdata, name)
+		rtest.OK(t, os.MkdirAll(filepath.Dir(p), 0755))
+		rtest.OK(t, os.WriteFile(p, []byte(name), 0644))
+	}
+
+	testRunBackup(t, env.testdata, []string{"."}, BackupOptions{}, env.gopts)
+	snapshotID := testListSnapshots(t, env.gopts, 1)[0]
+
+	opts := RestoreOptions{Target: "-", Archive: "tar"}
+	opts.Includes = []string{"/foo"}
+	buf, err := withCaptureStdout(t, env.gopts, func(ctx context.Context, gopts global.Options) error {
+		return runRestore(ctx, opts, gopts, gopts.Term, []string{snapshotID.String()})
+	})
+	rtest.OK(t, err)
+
+	var names []string
+	tr := tar.NewReader(buf)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		rtest.OK(t, err)
+		names = append(names, hdr.Name)
+	}
+	rtest.Equals(t, []string{"foo/", "foo/skip.txt", "foo/testfile"}, names)
+
+	opts = RestoreOptions{Target: "-", Archive: "tar", Delete: true}
+	err = testRunRestoreAssumeFailure(t, snapshotID.String(), opts, env.gopts)
+	rtest.Assert(t, err != nil && strings.Contains(err.Error(), "--delete cannot be used"), "unexpected error %v", err)
+
+	opts = RestoreOptions{Target: "-", Archive: "tar", Overwrite: restorer.OverwriteNever}
+	err = testRunRestoreAssumeFailure(t, snapshotID.String(), opts, env.gopts)
+	rtest.Assert(t, err != nil && strings.Contains(err.Error(), "--overwrite cannot be used"), "unexpected error %v", err)
+
+	opts = RestoreOptions{Target: "-", Archive: "rar"}
+	err = testRunRestoreAssumeFailure(t, snapshotID.String(), opts, env.gopts)
+	rtest.Assert(t, err != nil && strings.Contains(err.Error(), "unknown archive format"), "unexpected error %v", err)
+}
+
+func TestRestoreHardlinkIndex(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hardlinks are not restored on windows")
+	}
+
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+
+	for _, dir := range []string{"foo", "bar"} {
+		rtest.OK(t, os.MkdirAll(filepath.Join(env.testdata, dir), 0755))
+	}
+	rtest.OK(t, os.WriteFile(filepath.Join(env.testdata, "foo", "file"), []byte("content"), 0644))
+	rtest.OK(t, os.Link(filepath.Join(env.testdata, "foo", "file"), filepath.Join(env.testdata, "bar", "link")))
+
+	testRunBackup(t, env.testdata, []string{"."}, BackupOptions{}, env.gopts)
+	snapshotID := testListSnapshots(t, env.gopts, 1)[0]
+
+	restoredir := filepath.Join(env.base, "restore")
+	index := filepath.Join(env.base, "hardlinks")
+	for _, dir := range []string{"foo", "bar"} {
+		opts := RestoreOptions{Target: filepath.Join(restoredir, dir), HardlinkIndex: index}
+		rtest.OK(t, testRunRestoreAssumeFailure(t, snapshotID.String()+":"+dir, opts, env.gopts))
+	}
+
+	fi1, err := os.Stat(filepath.Join(restoredir, "foo", "file"))
+	rtest.OK(t, err)
+	fi2, err := os.Stat(filepath.Join(restoredir, "bar", "link"))
+	rtest.OK(t, err)
+	rtest.Assert(t, os.SameFile(fi1, fi2), "expected restored files to be hardlinked")
+}
+
+func TestRestoreMultipleSnapshots(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+
+	for _, dir := range []string{"foo", "bar"} {
+		p := filepath.Join(env.testdata, dir, "file")
+		rtest.OK(t, os.MkdirAll(filepath.Dir(p), 0755))
+		rtest.OK(t, os.WriteFile(p, []byte(dir), 0644))
+		testRunBackup(t, filepath.Join(env.testdata, dir), []string{"."}, BackupOptions{}, env.gopts)
+	}
+	snapshotIDs := testListSnapshots(t, env.gopts, 2)
+
+	var args []string
+	for _, id := range snapshotIDs {
+		args = append(args, id.String())
+	}
+	restoredir := filepath.Join(env.base, "restore")
+	err := withTermStatus(t, env.gopts, func(ctx context.Context, gopts global.Options) error {
+		return runRestore(ctx, RestoreOptions{Target: restoredir}, gopts, gopts.Term, args)
+	})
+	rtest.OK(t, err)
+
+	var contents []string
+	for _, id := range snapshotIDs {
+		buf, err := os.ReadFile(filepath.Join(restoredir, id.Str(), "file"))
+		rtest.OK(t, err)
+		contents = append(contents, string(buf))
+	}
+	sort.Strings(contents)
+	rtest.Equals(t, []string{"bar", "foo"}, contents)
+
+	// restore to explicit directories
+	opts := RestoreOptions{}
+	for i, id := range snapshotIDs {
+		opts.Map = append(opts.Map, fmt.Sprintf("%v=%v", id, filepath.Join(env.base, "map", fmt.Sprint(i))))
+	}
+	err = withTermStatus(t, env.gopts, func(ctx context.Context, gopts global.Options) error {
+		return runRestore(ctx, opts, gopts, gopts.Term, nil)
+	})
+	rtest.OK(t, err)
+	for i := range snapshotIDs {
+		_, err := os.Stat(filepath.Join(env.base, "map", fmt.Sprint(i), "file"))
+		rtest.OK(t, err)
+	}
+
+	// the same directory must not be used twice
+	opts = RestoreOptions{Map: []string{args[0] + "=" + restoredir, args[1] + "=" + restoredir}}
+	err = withTermStatus(t, env.gopts, func(ctx context.Context, gopts global.Options) error {
+		return runRestore(ctx, opts, gopts, gopts.Term, nil)
+	})
+	rtest.Assert(t, err != nil && strings.Contains(err.Error(), "cannot both be restored"), "unexpected error %v", err)
+}
+
 func TestRestoreLatest(t *testing.T) {
 	env, cleanup := withTestEnvironment(t)
 	defer cleanup()
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/global"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/server"
+	"github.com/restic/restic/internal/ui"
+	"github.com/restic/restic/internal/ui/progress"
+)
+
+func newServeCommand(globalOptions *global.Options) *cobra.Command {
+	var opts ServeOptions
+
+	cmd := &cobra.Command{
+		Use:   "serve [flags]",
+		Short: "Serve the repository via a read-only HTTP API",
+		Long: `
+The "serve" command provides read-only access to the repository via an HTTP API
+which returns JSON. The following endpoints are available:
+
+    GET /snapshots          list snapshots, filtered by the query
+                            parameters "host", "path" and "tag"
+    GET /snapshots/<id>     return a single snapshot
+    GET /trees/<id>         return the nodes of a tree
+    GET /blobs/<id>         return the content of a data blob
+
+All requests must carry the header "Authorization: Bearer <token>". The token
+is read from the file passed to "--token-file". If no token file is specified,
+a random token is generated and printed on startup.
+
+The server does not use TLS. It listens on localhost by default; use a reverse
+proxy to make it accessible from other hosts.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful.
+Exit status is 1 if there was any error.
+Exit status is 10 if the repository does not exist.
+Exit status is 11 if the repository is already locked.
+Exit status is 12 if the password is incorrect.
+`,
+		DisableAutoGenTag: true,
+		GroupID:           cmdGroupDefault,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runServe(cmd.Context(), opts, *globalOptions, args, globalOptions.Term)
+		},
+	}
+
+	opts.AddFlags(cmd.Flags())
+	return cmd
+}
+
+// ServeOptions collects all options for the serve command.
+type ServeOptions struct {
+	Listen    string
+	TokenFile string
+}
+
+func (opts *ServeOptions) AddFlags(f *pflag.FlagSet) {
+	f.StringVar(&opts.Listen, "listen", "localhost:8000", "listen on `address`")
+	f.StringVar(&opts.TokenFile, "token-file", "", "read the access token from `file`")
+}
+
+func runServe(ctx context.Context, opts ServeOptions, gopts global.Options, args []string, term ui.Terminal) error {
+	printer := progress.NewTerminalPrinter(false, gopts.Verbosity, term)
+
+	if len(args) != 0 {
+		return errors.Fatal("the serve command expects no arguments")
+	}
+
+	token, err := loadServeToken(opts.TokenFile)
+	if err != nil {
+		return err
+	}
+
+	ctx, repo, unlock, err := openWithReadLock(ctx, gopts, gopts.NoLock, printer)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	err = repo.LoadIndex(ctx, printer)
+	if err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", opts.Listen)
+	if err != nil {
+		return errors.Fatalf("unable to listen on %v: %v", opts.Listen, err)
+	}
+
+	srv := &http.Server{
+		Handler:           server.New(repo, token),
+		ReadHeaderTimeout: 30 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		err = srv.Serve(listener)
+	}()
+
+	printer.S("Now serving the repository at http://%s", listener.Addr())
+	if opts.TokenFile == "" {
+		printer.S("Access token: %s", token)
+	}
+	printer.S("When finished, quit with Ctrl-c here.")
+	debug.Log("serving repository at %v", listener.Addr())
+
+	select {
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			printer.E("unable to shut down server: %v", err)
+		}
+		<-done
+		return ErrOK
+	case <-done:
+	}
+
+	return err
+}
+
+// loadServeToken reads the access token from filename. If filename is empty,
+// a random token is returned.
+func loadServeToken(filename string) (string, error) {
+	if filename == "" {
+		return restic.NewRandomID().String(), nil
+	}
+
+	buf, err := os.ReadFile(filename)
+	if err != nil {
+		return "", errors.Fatalf("unable to read token file: %v", err)
+	}
+
+	token := strings.TrimSpace(string(buf))
+	if token == "" {
+		return "", errors.Fatalf("token file %v is empty", filename)
+	}
+	return token, nil
+}
@@ -5,15 +5,18 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"slices"
 	"sort"
 	"strings"
 	"time"
 
 	"github.com/restic/restic/internal/data"
+	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/global"
 	"github.com/restic/restic/internal/restic"
 	"github.com/restic/restic/internal/ui"
 	"github.com/restic/restic/internal/ui/progress"
+	statsui "github.com/restic/restic/internal/ui/stats"
 	"github.com/restic/restic/internal/ui/table"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
@@ -26,7 +29,9 @@ func newSnapshotsCommand(globalOptions *global.Options) *cobra.Command {
 		Use:   "snapshots [flags] [snapshotID ...]",
 		Short: "List all snapshots",
 		Long: `
-The "snapshots" command lists all snapshots stored in the repository.
+The "snapshots" command lists all snapshots stored in the repository. Use
+"restic snapshots verify-chain" to check that backups were created at the
+expected interval.
 
 EXIT STATUS
 ===========
@@ -49,6 +54,7 @@ Exit status is 12 if the password is incorrect.
 	}
 
 	opts.AddFlags(cmd.Flags())
+	cmd.AddCommand(newSnapshotsVerifyChainCommand(globalOptions))
 	return cmd
 }
 
@@ -59,6 +65,7 @@ type SnapshotOptions struct {
 	last    bool // Deprecated in favour of Latest.
 	Latest  int
 	GroupBy data.SnapshotGroupByOptions
+	Stats   bool
 }
 
 func (opts *SnapshotOptions) AddFlags(f *pflag.FlagSet) {
@@ -72,6 +79,7 @@ func (opts *SnapshotOptions) AddFlags(f *pflag.FlagSet) {
 	}
 	f.IntVar(&opts.Latest, "latest", 0, "only show the last `n` snapshots for each host and path")
 	f.VarP(&opts.GroupBy, "group-by", "g", "`group` snapshots by host, paths and/or tags, separated by comma")
+	f.BoolVar(&opts.Stats, "stats", false, "show aggregated statistics for each group of snapshots (requires --group-by)")
 }
 
 func (opts *SnapshotOptions) Finalize() error {
@@ -82,6 +90,10 @@ func (opts *SnapshotOptions) Finalize() error {
 }
 
 func runSnapshots(ctx context.Context, opts SnapshotOptions, gopts global.Options, args []string, term ui.Terminal) error {
+	if opts.Stats && opts.GroupBy == (data.SnapshotGroupByOptions{}) {
+		return errors.Fatal("--stats requires --group-by")
+	}
+
 	printer := progress.NewTerminalPrinter(gopts.JSON, gopts.Verbosity, term)
 	ctx, repo, unlock, err := openWithReadLock(ctx, gopts, gopts.NoLock, printer)
 	if err != nil {
@@ -122,8 +134,16 @@ func runSnapshots(ctx context.Context, opts SnapshotOptions, gopts global.Option
 		snapshotGroups[k] = list
 	}
 
+	var groupStats map[string]*SnapshotGroupStats
+	if opts.Stats {
+		groupStats, err = collectSnapshotGroupStats(ctx, repo, snapshotGroups, gopts, term, printer)
+		if err != nil {
+			return err
+		}
+	}
+
 	if gopts.JSON {
-		err := printSnapshotGroupJSON(gopts.Term.OutputWriter(), snapshotGroups, grouped)
+		err := printSnapshotGroupJSON(gopts.Term.OutputWriter(), snapshotGroups, groupStats, grouped)
 		if err != nil {
 			printer.E("error printing snapshots: %v", err)
 		}
@@ -145,11 +165,116 @@ func runSnapshots(ctx context.Context, opts SnapshotOptions, gopts global.Option
 		if err != nil {
 			return err
 		}
+		if groupStats != nil {
+			err := printSnapshotGroupStats(gopts.Term.OutputWriter(), groupStats[k])
+			if err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
 }
 
+// SnapshotGroupStats contains aggregated statistics for a group of snapshots.
+// The size and file count are those of the newest snapshot in the group as
+// computed by the restore-size mode of the stats command.
+type SnapshotGroupStats struct {
+	SnapshotsCount int       `json:"snapshots_count"`
+	Oldest         time.Time `json:"oldest"`
+	Newest         time.Time `json:"newest"`
+	TotalSize      uint64    `json:"total_size"`
+	TotalFileCount uint64    `json:"total_file_count"`
+	// Growth is the change of the total size compared to the previous snapshot.
+	// It is not set if the group contains only a single snapshot.
+	Growth *int64 `json:"growth,omitempty"`
+}
+
+// collectSnapshotGroupStats computes the statistics for each group of
+// snapshots.
+func collectSnapshotGroupStats(ctx context.Context, repo restic.Repository, snapshotGroups map[string]data.Snapshots, gopts global.Options, term ui.Terminal, printer restic.Printer) (map[string]*SnapshotGroupStats, error) {
+	if err := repo.LoadIndex(ctx, printer); err != nil {
+		return nil, err
+	}
+
+	// only the newest and the previous snapshot of each group have to be walked
+	var walkCount uint64
+	for _, list := range snapshotGroups {
+		walkCount += uint64(min(len(list), 2))
+	}
+	statsProgress := statsui.NewProgress(term, gopts.Quiet, gopts.JSON, walkCount)
+	defer statsProgress.Done()
+
+	restoreSize := func(sn *data.Snapshot) (*statsContainer, error) {
+		stats := &statsContainer{}
+		err := statsWalkSnapshot(ctx, sn, repo, StatsOptions{countMode: countModeRestoreSize}, stats, statsProgress)
+		if err != nil {
+			return nil, fmt.Errorf("error walking snapshot: %v", err)
+		}
+		return stats, nil
+	}
+
+	groupStats := make(map[string]*SnapshotGroupStats, len(snapshotGroups))
+	for k, list := range snapshotGroups {
+		if len(list) == 0 {
+			continue
+		}
+		// sort a copy newest first to keep the order of the output
+		list = slices.Clone(list)
+		sort.Sort(list)
+
+		newest, err := restoreSize(list[0])
+		if err != nil {
+			return nil, err
+		}
+		stats := &SnapshotGroupStats{
+			SnapshotsCount: len(list),
+			Oldest:         list[len(list)-1].Time,
+			Newest:         list[0].Time,
+			TotalSize:      newest.TotalSize,
+			TotalFileCount: newest.TotalFileCount,
+		}
+
+		if len(list) > 1 {
+			previous, err := restoreSize(list[1])
+			if err != nil {
+				return nil, err
+			}
+			growth := int64(newest.TotalSize) - int64(previous.TotalSize)
+			stats.Growth = &growth
+		}
+		groupStats[k] = stats
+	}
+	// stop progress bar to prevent mangled output
+	statsProgress.Done()
+
+	return groupStats, nil
+}
+
+// printSnapshotGroupStats prints the statistics of a group of snapshots.
+func printSnapshotGroupStats(stdout io.Writer, stats *SnapshotGroupStats) error {
+	if stats == nil {
+		return nil
+	}
+
+	lines := []string{
+		fmt.Sprintf("  Oldest snapshot:  %s", stats.Oldest.Local().Format(global.TimeFormat)),
+		fmt.Sprintf("  Newest snapshot:  %s", stats.Newest.Local().Format(global.TimeFormat)),
+		fmt.Sprintf("       Total size:  %s", ui.FormatBytes(stats.TotalSize)),
+		fmt.Sprintf(" Total file count:  %d", stats.TotalFileCount),
+	}
+	if stats.Growth != nil {
+		growth := "+" + ui.FormatBytes(uint64(*stats.Growth))
+		if *stats.Growth < 0 {
+			growth = "-" + ui.FormatBytes(uint64(-*stats.Growth))
+		}
+		lines = append(lines, fmt.Sprintf("           Growth:  %s since previous snapshot", growth))
+	}
+
+	_, err := fmt.Fprintf(stdout, "%s\n\n", strings.Join(lines, "\n"))
+	return err
+}
+
 // filterLastSnapshotsKey is used by FilterLastSnapshots.
 type filterLastSnapshotsKey struct {
 	Hostname    string
@@ -370,10 +495,11 @@ type Snapshot struct {
 type SnapshotGroup struct {
 	GroupKey  data.SnapshotGroupKey `json:"group_key"`
 	Snapshots []Snapshot            `json:"snapshots"`
+	Stats     *SnapshotGroupStats   `json:"stats,omitempty"`
 }
 
 // printSnapshotGroupJSON writes the JSON representation of list to stdout.
-func printSnapshotGroupJSON(stdout io.Writer, snGroups map[string]data.Snapshots, grouped bool) error {
+func printSnapshotGroupJSON(stdout io.Writer, snGroups map[string]data.Snapshots, groupStats map[string]*SnapshotGroupStats, grouped bool) error {
 	if grouped {
 		snapshotGroups := []SnapshotGroup{}
 
@@ -399,6 +525,7 @@ func printSnapshotGroupJSON(stdout io.Writer, snGroups map[string]data.Snapshots
 			group := SnapshotGroup{
 				GroupKey:  key,
 				Snapshots: snapshots,
+				Stats:     groupStats[k],
 			}
 			snapshotGroups = append(snapshotGroups, group)
 		}
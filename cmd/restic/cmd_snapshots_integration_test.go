@@ -3,7 +3,9 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -93,3 +95,117 @@ func TestSnapshotsLatest(t *testing.T) {
 	rtest.Assert(t, len(snapshots) == 1, "expected only one snapshot, got %d", len(snapshots))
 	rtest.Equals(t, snapshots[0].ID.String(), secondSnapshotID, "unexpected snapshot ID")
 }
+
+func TestSnapshotsGroupByStats(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	testRunBackup(t, "", []string{env.testdata}, BackupOptions{}, env.gopts)
+	// grow the backup data by a single file
+	rtest.OK(t, os.WriteFile(filepath.Join(env.testdata, "new-file"), make([]byte, 1234), 0o644))
+	testRunBackup(t, "", []string{env.testdata}, BackupOptions{}, env.gopts)
+
+	opts := SnapshotOptions{GroupBy: data.SnapshotGroupByOptions{Host: true}, Stats: true}
+	buf, err := withCaptureStdout(t, env.gopts, func(ctx context.Context, gopts global.Options) error {
+		gopts.JSON = true
+		return runSnapshots(ctx, opts, gopts, []string{}, gopts.Term)
+	})
+	rtest.OK(t, err)
+	snapshots := []SnapshotGroup{}
+	rtest.OK(t, json.Unmarshal(buf.Bytes(), &snapshots))
+	rtest.Assert(t, len(snapshots) == 1, "expected only one snapshot group, got %d", len(snapshots))
+
+	stats := snapshots[0].Stats
+	rtest.Assert(t, stats != nil, "missing group stats")
+	rtest.Equals(t, 2, stats.SnapshotsCount)
+	rtest.Assert(t, !stats.Newest.Before(stats.Oldest), "expected newest %v not to be before oldest %v", stats.Newest, stats.Oldest)
+	rtest.Assert(t, stats.TotalSize > 0 && stats.TotalFileCount > 0, "unexpected stats %+v", stats)
+	rtest.Assert(t, stats.Growth != nil, "missing growth")
+	rtest.Equals(t, int64(1234), *stats.Growth)
+
+	buf, err = withCaptureStdout(t, env.gopts, func(ctx context.Context, gopts global.Options) error {
+		return runSnapshots(ctx, opts, gopts, []string{}, gopts.Term)
+	})
+	rtest.OK(t, err)
+	rtest.Assert(t, strings.Contains(buf.String(), "since previous snapshot"), "missing growth in output:\n%s", buf.String())
+
+	err = withTermStatus(t, env.gopts, func(ctx context.Context, gopts global.Options) error {
+		return runSnapshots(ctx, SnapshotOptions{Stats: true}, gopts, []string{}, gopts.Term)
+	})
+	rtest.Assert(t, err != nil, "expected error for --stats without --group-by")
+}
+
+func TestSnapshotsLabelSelector(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	for _, labels := range []data.Labels{{"env": "prod", "app": "db"}, {"env": "prod", "app": "cache"}, {"env": "dev"}} {
+		testRunBackup(t, "", []string{env.testdata}, BackupOptions{Labels: labels}, env.gopts)
+	}
+
+	for _, test := range []struct {
+		selectors []string
+		expected  int
+	}{
+		{nil, 3},
+		{[]string{"env=prod"}, 2},
+		{[]string{"env=prod,app!=cache"}, 1},
+		{[]string{"app=cache", "env=dev"}, 2},
+		{[]string{"app="}, 1},
+	} {
+		var opts SnapshotOptions
+		for _, s := range test.selectors {
+			rtest.OK(t, opts.Labels.Set(s))
+		}
+		buf, err := withCaptureStdout(t, env.gopts, func(ctx context.Context, gopts global.Options) error {
+			gopts.JSON = true
+			return runSnapshots(ctx, opts, gopts, []string{}, gopts.Term)
+		})
+		rtest.OK(t, err)
+		snapshots := []Snapshot{}
+		rtest.OK(t, json.Unmarshal(buf.Bytes(), &snapshots))
+		rtest.Equals(t, test.expected, len(snapshots), opts.Labels.String())
+		for _, sn := range snapshots {
+			rtest.Assert(t, sn.HasLabelSelectors(opts.Labels), "snapshot with labels %v does not match %v", sn.Labels, opts.Labels)
+		}
+	}
+}
+
+func testRunSnapshotsVerifyChain(t testing.TB, gopts global.Options, opts VerifyChainOptions) ([]chainReport, error) {
+	var reports []chainReport
+	buf, err := withCaptureStdout(t, gopts, func(ctx context.Context, gopts global.Options) error {
+		gopts.JSON = true
+		return runSnapshotsVerifyChain(ctx, opts, gopts, []string{}, gopts.Term)
+	})
+	rtest.OK(t, json.Unmarshal(buf.Bytes(), &reports))
+	return reports, err
+}
+
+func TestSnapshotsVerifyChain(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	now := time.Now()
+	for _, age := range []time.Duration{50 * time.Hour, 25 * time.Hour, time.Hour} {
+		opts := BackupOptions{Host: "server", TimeStamp: now.Add(-age).Format(time.DateTime)}
+		testRunBackup(t, "", []string{env.testdata}, opts, env.gopts)
+	}
+
+	reports, err := testRunSnapshotsVerifyChain(t, env.gopts, VerifyChainOptions{Expect: []string{"server=24h"}})
+	rtest.OK(t, err)
+	rtest.Equals(t, 1, len(reports))
+	rtest.Assert(t, reports[0].OK, "unexpected problems %v", reports[0].Problems)
+	rtest.Equals(t, 3, reports[0].Snapshots)
+
+	// a host without snapshots and a shorter interval are reported
+	reports, err = testRunSnapshotsVerifyChain(t, env.gopts, VerifyChainOptions{Expect: []string{"12h", "laptop=24h"}})
+	rtest.Assert(t, err != nil, "missing error")
+	rtest.Equals(t, 2, len(reports))
+	rtest.Equals(t, "laptop", reports[0].Host)
+	rtest.Equals(t, 0, reports[0].Snapshots)
+	rtest.Equals(t, "server", reports[1].Host)
+	rtest.Equals(t, 2, reports[1].Missed)
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/restic/restic/internal/data"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/global"
+	"github.com/restic/restic/internal/ui"
+	"github.com/restic/restic/internal/ui/progress"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func newSnapshotsVerifyChainCommand(globalOptions *global.Options) *cobra.Command {
+	var opts VerifyChainOptions
+
+	cmd := &cobra.Command{
+		Use:   "verify-chain [flags] --expect [host=]interval",
+		Short: "Detect gaps in the expected backup schedule",
+		Long: `
+The "snapshots verify-chain" command checks that backups were created at the
+expected interval. The interval is set per host using "--expect host=interval",
+for example "--expect server=24h". An interval without a host applies to all
+hosts which do not have an explicit interval.
+
+For each host, the time between consecutive snapshots is checked. If it exceeds
+the interval by more than the grace period, the next backup was late. If it
+spans multiple intervals, backups were missed. A host whose latest snapshot is
+older than the interval plus the grace period is overdue, and a host without
+any snapshots is reported as missing. The longest gap between two backups is
+shown for each host. The grace period defaults to 10% of the interval and can
+be set using "--grace".
+
+EXIT STATUS
+===========
+
+Exit status is 0 if all backups were created as expected.
+Exit status is 1 if backups were missed, late, or there was any other error.
+Exit status is 10 if the repository does not exist.
+Exit status is 11 if the repository is already locked.
+Exit status is 12 if the password is incorrect.
+`,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			finalizeSnapshotFilter(&opts.SnapshotFilter)
+			return runSnapshotsVerifyChain(cmd.Context(), opts, *globalOptions, args, globalOptions.Term)
+		},
+	}
+
+	opts.AddFlags(cmd.Flags())
+	return cmd
+}
+
+// VerifyChainOptions bundles all options for the snapshots verify-chain command.
+type VerifyChainOptions struct {
+	data.SnapshotFilter
+	Expect []string
+	Grace  time.Duration
+}
+
+func (opts *VerifyChainOptions) AddFlags(f *pflag.FlagSet) {
+	initMultiSnapshotFilter(f, &opts.SnapshotFilter, true)
+	f.StringArrayVar(&opts.Expect, "expect", nil, "expect a backup every `[host=]interval`, e.g. 'server=24h' (can be specified multiple times)")
+	f.DurationVar(&opts.Grace, "grace", 0, "accept backups up to `duration` later than expected (default: 10% of the interval)")
+}
+
+// parseExpectedIntervals parses the --expect options into a map from host to
+// interval. The interval for all other hosts is stored for the empty host.
+func parseExpectedIntervals(expect []string) (map[string]time.Duration, error) {
+	intervals := make(map[string]time.Duration)
+	for _, e := range expect {
+		host, value, found := strings.Cut(e, "=")
+		if !found {
+			host, value = "", e
+		} else if host == "" {
+			return nil, errors.Fatalf("invalid --expect %q, host must not be empty", e)
+		}
+
+		interval, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, errors.Fatalf("invalid --expect %q: %v", e, err)
+		}
+		if interval <= 0 {
+			return nil, errors.Fatalf("invalid --expect %q, interval must be positive", e)
+		}
+		if _, ok := intervals[host]; ok {
+			return nil, errors.Fatalf("duplicate --expect for host %q", host)
+		}
+		intervals[host] = interval
+	}
+	return intervals, nil
+}
+
+// chainGap is the time between two backups of a host, or between the latest
+// backup and now.
+type chainGap struct {
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Seconds float64   `json:"seconds"`
+	// Missed is the number of backups which should have been created within
+	// the gap.
+	Missed int `json:"missed"`
+	// Late is set if the backup at the end of the gap was created more than
+	// the grace period after it was due.
+	Late bool `json:"late"`
+	// Overdue is set for the gap between the latest backup and now.
+	Overdue bool `json:"overdue"`
+}
+
+func (g chainGap) duration() time.Duration {
+	return g.End.Sub(g.Start)
+}
+
+// chainReport is the result of verify-chain for a single host.
+type chainReport struct {
+	Host            string     `json:"host"`
+	IntervalSeconds float64    `json:"interval_seconds"`
+	GraceSeconds    float64    `json:"grace_seconds"`
+	Snapshots       int        `json:"snapshots"`
+	Missed          int        `json:"missed"`
+	Late            int        `json:"late"`
+	LongestGap      *chainGap  `json:"longest_gap,omitempty"`
+	Problems        []chainGap `json:"problems"`
+	OK              bool       `json:"ok"`
+}
+
+// verifyChain checks that the snapshots of a host, given by their times, were
+// created every interval.
+func verifyChain(host string, times []time.Time, interval, grace time.Duration, now time.Time) chainReport {
+	report := chainReport{
+		Host:            host,
+		IntervalSeconds: interval.Seconds(),
+		GraceSeconds:    grace.Seconds(),
+		Snapshots:       len(times),
+		Problems:        []chainGap{},
+	}
+	if len(times) == 0 {
+		return report
+	}
+
+	times = append([]time.Time(nil), times...)
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+
+	check := func(start, end time.Time, overdue bool) {
+		gap := chainGap{
+			Start:   start,
+			End:     end,
+			Seconds: end.Sub(start).Seconds(),
+			Overdue: overdue,
+		}
+		if report.LongestGap == nil || gap.duration() > report.LongestGap.duration() {
+			g := gap
+			report.LongestGap = &g
+		}
+		if gap.duration() <= interval+grace {
+			return
+		}
+
+		if overdue {
+			// all backups which are due for longer than the grace period
+			// were missed
+			gap.Missed = int((gap.duration() - grace) / interval)
+		} else {
+			// the backup at the end of the gap replaces the backup which was
+			// due closest to it, all backups due before were missed
+			due := time.Duration(math.Round(float64(gap.duration()) / float64(interval)))
+			gap.Missed = int(due) - 1
+			gap.Late = gap.duration() > due*interval+grace
+		}
+		report.Missed += gap.Missed
+		if gap.Late {
+			report.Late++
+		}
+		report.Problems = append(report.Problems, gap)
+	}
+
+	for i := 1; i < len(times); i++ {
+		check(times[i-1], times[i], false)
+	}
+	if now.After(times[len(times)-1]) {
+		check(times[len(times)-1], now, true)
+	}
+
+	report.OK = len(report.Problems) == 0
+	return report
+}
+
+func runSnapshotsVerifyChain(ctx context.Context, opts VerifyChainOptions, gopts global.Options, args []string, term ui.Terminal) error {
+	if len(args) > 0 {
+		return errors.Fatal("the snapshots verify-chain command expects no arguments, only options - please see `restic help snapshots verify-chain` for usage and flags")
+	}
+	if len(opts.Expect) == 0 {
+		return errors.Fatal("at least one expected interval must be specified using --expect")
+	}
+	if opts.Grace < 0 {
+		return errors.Fatal("--grace must not be negative")
+	}
+	intervals, err := parseExpectedIntervals(opts.Expect)
+	if err != nil {
+		return err
+	}
+
+	printer := progress.NewTerminalPrinter(gopts.JSON, gopts.Verbosity, term)
+	ctx, repo, unlock, err := openWithReadLock(ctx, gopts, gopts.NoLock, printer)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	times := make(map[string][]time.Time)
+	err = opts.SnapshotFilter.FindAll(ctx, repo, repo, nil, func(_ string, sn *data.Snapshot, err error) error {
+		if err != nil {
+			return err
+		}
+		times[sn.Hostname] = append(times[sn.Hostname], sn.Time)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// hosts with an explicit interval are always checked, all other hosts
+	// only if a default interval was given
+	hosts := make(map[string]struct{})
+	for host := range intervals {
+		if host != "" {
+			hosts[host] = struct{}{}
+		}
+	}
+	if _, ok := intervals[""]; ok {
+		for host := range times {
+			hosts[host] = struct{}{}
+		}
+	}
+	sortedHosts := make([]string, 0, len(hosts))
+	for host := range hosts {
+		sortedHosts = append(sortedHosts, host)
+	}
+	sort.Strings(sortedHosts)
+
+	now := time.Now()
+	reports := make([]chainReport, 0, len(sortedHosts))
+	ok := true
+	for _, host := range sortedHosts {
+		interval, found := intervals[host]
+		if !found {
+			interval = intervals[""]
+		}
+		grace := opts.Grace
+		if grace == 0 {
+			grace = interval / 10
+		}
+		report := verifyChain(host, times[host], interval, grace, now)
+		reports = append(reports, report)
+		ok = ok && report.OK
+	}
+
+	if gopts.JSON {
+		err := json.NewEncoder(gopts.Term.OutputWriter()).Encode(reports)
+		if err != nil {
+			return err
+		}
+	} else {
+		for _, report := range reports {
+			printChainReport(printer.S, report)
+		}
+	}
+
+	if !ok {
+		return errors.Fatal("backups are missing or late")
+	}
+	return nil
+}
+
+func formatChainDuration(seconds float64) string {
+	return (time.Duration(seconds * float64(time.Second))).Round(time.Second).String()
+}
+
+// printChainReport prints the report for a host in text form.
+func printChainReport(printf func(msg string, args ...interface{}), report chainReport) {
+	interval := formatChainDuration(report.IntervalSeconds)
+	if report.Snapshots == 0 {
+		printf("%v: no snapshots found, expected a backup every %v", report.Host, interval)
+		return
+	}
+
+	status := "ok"
+	if !report.OK {
+		status = fmt.Sprintf("%d missed, %d late", report.Missed, report.Late)
+	}
+	printf("%v: %d snapshots, expected a backup every %v: %v", report.Host, report.Snapshots, interval, status)
+
+	for _, gap := range report.Problems {
+		start := gap.Start.Local().Format(global.TimeFormat)
+		end := gap.End.Local().Format(global.TimeFormat)
+		length := formatChainDuration(gap.Seconds)
+		switch {
+		case gap.Overdue:
+			printf("  overdue: no backup since %v (%v ago), %d missed", start, length, gap.Missed)
+		case gap.Missed > 0:
+			printf("  missed:  %d backups between %v and %v (%v)", gap.Missed, start, end, length)
+		default:
+			printf("  late:    backup at %v, %v after the previous one", end, length)
+		}
+	}
+
+	if report.LongestGap != nil {
+		printf("  longest gap: %v, from %v to %v", formatChainDuration(report.LongestGap.Seconds),
+			report.LongestGap.Start.Local().Format(global.TimeFormat), report.LongestGap.End.Local().Format(global.TimeFormat))
+	}
+}
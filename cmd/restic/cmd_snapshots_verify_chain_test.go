@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestParseExpectedIntervals(t *testing.T) {
+	intervals, err := parseExpectedIntervals([]string{"server=24h", "laptop=1h30m", "6h"})
+	rtest.OK(t, err)
+	rtest.Equals(t, map[string]time.Duration{
+		"server": 24 * time.Hour,
+		"laptop": 90 * time.Minute,
+		"":       6 * time.Hour,
+	}, intervals)
+
+	for _, expect := range [][]string{
+		{"server=1d"},
+		{"server="},
+		{"=24h"},
+		{"server=-1h"},
+		{"server=24h", "server=12h"},
+	} {
+		_, err := parseExpectedIntervals(expect)
+		rtest.Assert(t, err != nil, "missing error for %v", expect)
+	}
+}
+
+func TestVerifyChain(t *testing.T) {
+	start := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	grace := 2 * time.Hour
+	at := func(offsets ...time.Duration) []time.Time {
+		var times []time.Time
+		for _, o := range offsets {
+			times = append(times, start.Add(o))
+		}
+		return times
+	}
+
+	// daily backups with a small jitter
+	report := verifyChain("host", at(0, day+time.Hour, 2*day, 3*day-time.Minute), day, grace, start.Add(3*day+time.Hour))
+	rtest.Assert(t, report.OK, "unexpected problems %v", report.Problems)
+	rtest.Equals(t, 4, report.Snapshots)
+	rtest.Equals(t, (day + time.Hour).Seconds(), report.LongestGap.Seconds)
+
+	// one late backup, then two missed backups, unordered input
+	report = verifyChain("host", at(4*day, 0, day+6*time.Hour), day, grace, start.Add(4*day+time.Hour))
+	rtest.Assert(t, !report.OK, "missing problems")
+	rtest.Equals(t, 1, report.Late)
+	rtest.Equals(t, 2, report.Missed)
+	rtest.Equals(t, 2, len(report.Problems))
+	rtest.Assert(t, report.Problems[0].Late, "first gap is not late")
+	rtest.Equals(t, 2, report.Problems[1].Missed)
+	rtest.Equals(t, start.Add(day+6*time.Hour), report.LongestGap.Start)
+	rtest.Equals(t, start.Add(4*day), report.LongestGap.End)
+
+	// the latest backup is overdue
+	report = verifyChain("host", at(0), day, grace, start.Add(2*day+3*time.Hour))
+	rtest.Equals(t, 1, len(report.Problems))
+	rtest.Assert(t, report.Problems[0].Overdue, "gap is not overdue")
+	rtest.Equals(t, 2, report.Missed)
+
+	// no snapshots at all
+	report = verifyChain("host", nil, day, grace, start)
+	rtest.Assert(t, !report.OK, "host without snapshots is ok")
+	rtest.Assert(t, report.LongestGap == nil, "unexpected longest gap")
+}
@@ -8,8 +8,8 @@ import (
 	"path/filepath"
 	"strings"
 
-	"github.com/restic/chunker"
 	"github.com/restic/restic/internal/data"
+	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/global"
 	"github.com/restic/restic/internal/repository"
 	"github.com/restic/restic/internal/restic"
@@ -49,6 +49,14 @@ The modes are:
 * raw-data: Counts the size of blobs in the repository, regardless of
   how many files reference them.
 * blobs-per-file: A combination of files-by-contents and raw-data.
+* unique-per-snapshot: Counts for each snapshot the size of blobs which are
+  not referenced by any other snapshot in the repository. This is the amount
+  of data that removing only this snapshot would free.
+
+The --by-directory option additionally attributes the restore size and the
+unique (deduplicated) size of files to the directories containing them, up to
+--max-depth levels below the root, and prints the --top directories with the
+largest unique size. It can only be used with the restore-size mode.
 
 Refer to the online manual for more details about each mode.
 
@@ -71,7 +79,7 @@ Exit status is 12 if the password is incorrect.
 
 	opts.AddFlags(cmd.Flags())
 	must(cmd.RegisterFlagCompletionFunc("mode", func(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
-		return []string{countModeRestoreSize, countModeUniqueFilesByContents, countModeBlobsPerFile, countModeRawData}, cobra.ShellCompDirectiveDefault
+		return []string{countModeRestoreSize, countModeUniqueFilesByContents, countModeBlobsPerFile, countModeRawData, countModeUniquePerSnapshot}, cobra.ShellCompDirectiveDefault
 	}))
 	return cmd
 }
@@ -81,11 +89,18 @@ type StatsOptions struct {
 	// the mode of counting to perform (see consts for available modes)
 	countMode string
 
+	ByDirectory bool
+	Top         int
+	MaxDepth    int
+
 	data.SnapshotFilter
 }
 
 func (opts *StatsOptions) AddFlags(f *pflag.FlagSet) {
-	f.StringVar(&opts.countMode, "mode", countModeRestoreSize, "counting mode: restore-size (default), files-by-contents, blobs-per-file or raw-data")
+	f.StringVar(&opts.countMode, "mode", countModeRestoreSize, "counting mode: restore-size (default), files-by-contents, blobs-per-file, raw-data or unique-per-snapshot")
+	f.BoolVar(&opts.ByDirectory, "by-directory", false, "show the directories using the most space")
+	f.IntVar(&opts.Top, "top", 10, "show the `n` largest directories for --by-directory (0 shows all)")
+	f.IntVar(&opts.MaxDepth, "max-depth", 3, "only consider directories up to `depth` levels below the root for --by-directory")
 	initMultiSnapshotFilter(f, &opts.SnapshotFilter, true)
 }
 
@@ -132,6 +147,9 @@ func runStats(ctx context.Context, opts StatsOptions, gopts global.Options, args
 		blobs:          repo.NewAssociatedBlobSet(),
 		SnapshotsCount: 0,
 	}
+	if opts.ByDirectory {
+		stats.dirs = newDirectoryStats(opts.MaxDepth)
+	}
 
 	var snapshots data.Snapshots
 	err = opts.SnapshotFilter.FindAll(ctx, snapshotLister, repo, args, func(_ string, sn *data.Snapshot, err error) error {
@@ -145,6 +163,29 @@ func runStats(ctx context.Context, opts StatsOptions, gopts global.Options, args
 		return err
 	}
 
+	if opts.countMode == countModeUniquePerSnapshot {
+		// whether a blob is unique depends on all snapshots in the repository
+		var allSnapshots data.Snapshots
+		err = data.ForAllSnapshots(ctx, snapshotLister, repo, nil, func(_ restic.ID, sn *data.Snapshot, err error) error {
+			if err != nil {
+				return err
+			}
+			allSnapshots = append(allSnapshots, sn)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		statsProgress := statsui.NewProgress(term, gopts.Quiet, gopts.JSON, uint64(len(allSnapshots)))
+		err = statsUniquePerSnapshot(ctx, repo, snapshots, allSnapshots, stats, statsProgress)
+		statsProgress.Done()
+		if err != nil {
+			return err
+		}
+		return printStats(opts, gopts, printer, stats)
+	}
+
 	statsProgress := statsui.NewProgress(term, gopts.Quiet, gopts.JSON, uint64(len(snapshots)))
 	defer statsProgress.Done()
 
@@ -187,8 +228,17 @@ func runStats(ctx context.Context, opts StatsOptions, gopts global.Options, args
 	// stop progress bar to prevent mangled output
 	statsProgress.Done()
 
+	if stats.dirs != nil {
+		stats.Directories = stats.dirs.top(opts.Top)
+	}
+
+	return printStats(opts, gopts, printer, stats)
+}
+
+// printStats prints the collected statistics, either as JSON or as text.
+func printStats(opts StatsOptions, gopts global.Options, printer restic.Printer, stats *statsContainer) error {
 	if gopts.JSON {
-		err = json.NewEncoder(gopts.Term.OutputWriter()).Encode(stats)
+		err := json.NewEncoder(gopts.Term.OutputWriter()).Encode(stats)
 		if err != nil {
 			return fmt.Errorf("encoding output: %v", err)
 		}
@@ -217,6 +267,22 @@ func runStats(ctx context.Context, opts StatsOptions, gopts global.Options, args
 		printer.S("Compression Space Saving:  %.2f%%", stats.CompressionSpaceSaving)
 	}
 
+	if stats.dirs != nil {
+		printer.S("")
+		printer.S("Largest directories:")
+		if err := printDirectoryStats(gopts.Term.OutputWriter(), stats.Directories); err != nil {
+			return err
+		}
+	}
+
+	if stats.Snapshots != nil {
+		printer.S("")
+		printer.S("Unique size per snapshot:")
+		if err := printUniqueSizes(gopts.Term.OutputWriter(), stats.Snapshots); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -303,13 +369,26 @@ func statsWalkTree(repo restic.Loader, opts StatsOptions, stats *statsContainer,
 			// will still be restored
 			stats.TotalFileCount++
 
+			countSize := false
 			if node.Links == 1 || node.Type == data.NodeTypeDir {
-				stats.TotalSize += node.Size
+				countSize = true
 			} else {
 				// if hardlinks are present only count each deviceID+inode once
 				if !hardLinkIndex.Has(node.Inode, node.DeviceID) || node.Inode == 0 {
 					hardLinkIndex.Add(node.Inode, node.DeviceID, struct{}{})
-					stats.TotalSize += node.Size
+					countSize = true
+				}
+			}
+			if countSize {
+				stats.TotalSize += node.Size
+			}
+
+			if stats.dirs != nil && node.Type == data.NodeTypeFile {
+				err := stats.dirs.addFile(npath, node, countSize, func(id restic.ID) (uint, bool) {
+					return repo.LookupBlobSize(restic.BlobHandle{Type: restic.DataBlob, ID: id})
+				})
+				if err != nil {
+					return err
 				}
 			}
 		}
@@ -334,11 +413,21 @@ func verifyStatsInput(opts StatsOptions) error {
 	case countModeUniqueFilesByContents:
 	case countModeBlobsPerFile:
 	case countModeRawData:
+	case countModeUniquePerSnapshot:
 	case countModeDebug:
 	default:
 		return fmt.Errorf("unknown counting mode: %s (use the -h flag to get a list of supported modes)", opts.countMode)
 	}
 
+	if opts.ByDirectory {
+		if opts.countMode != countModeRestoreSize {
+			return errors.Fatalf("--by-directory can only be used with --mode %s", countModeRestoreSize)
+		}
+		if opts.Top < 0 || opts.MaxDepth < 0 {
+			return errors.Fatal("--top and --max-depth must not be negative")
+		}
+	}
+
 	return nil
 }
 
@@ -357,6 +446,13 @@ type statsContainer struct {
 	TotalBlobCount                       uint64  `json:"total_blob_count,omitempty"`
 	// holds count of all considered snapshots
 	SnapshotsCount int `json:"snapshots_count"`
+	// the largest directories, only set for --by-directory
+	Directories []*directoryStatsEntry `json:"directories,omitempty"`
+	// the unique size of each snapshot, only set for unique-per-snapshot mode
+	Snapshots []*snapshotUniqueSize `json:"snapshots,omitempty"`
+
+	// dirs collects the statistics per directory, if requested
+	dirs *directoryStats
 
 	// uniqueFiles marks visited files according to their
 	// contents (hashed sequence of content blob IDs)
@@ -379,6 +475,7 @@ const (
 	countModeUniqueFilesByContents = "files-by-contents"
 	countModeBlobsPerFile          = "blobs-per-file"
 	countModeRawData               = "raw-data"
+	countModeUniquePerSnapshot     = "unique-per-snapshot"
 	countModeDebug                 = "debug"
 )
 
@@ -416,7 +513,7 @@ func statsDebugFileType(ctx context.Context, repo restic.Lister, tpe restic.File
 func statsDebugBlobs(ctx context.Context, repo restic.Repository) ([restic.NumBlobTypes]*sizeHistogram, error) {
 	var hist [restic.NumBlobTypes]*sizeHistogram
 	for i := 0; i < len(hist); i++ {
-		hist[i] = newSizeHistogram(2 * chunker.MaxSize)
+		hist[i] = newSizeHistogram(2 * uint64(repo.ChunkerFactory().MaxChunkSize()))
 	}
 
 	err := repo.ListBlobs(ctx, func(pb restic.PackBlob) {
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"io"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/restic/restic/internal/data"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui"
+	"github.com/restic/restic/internal/ui/table"
+)
+
+// directoryStats attributes the size of files to the directories containing them,
+// up to maxDepth levels below the root directory.
+type directoryStats struct {
+	maxDepth int
+	dirs     map[string]*directoryStatsEntry
+}
+
+// directoryStatsEntry holds the statistics for a single directory.
+type directoryStatsEntry struct {
+	Path string `json:"path"`
+	// sum of the sizes of all files, as they would be restored
+	RestoreSize uint64 `json:"restore_size"`
+	// sum of the sizes of all distinct blobs referenced by the files
+	UniqueSize uint64 `json:"unique_size"`
+	FileCount  uint64 `json:"file_count"`
+
+	blobs restic.IDSet
+}
+
+func newDirectoryStats(maxDepth int) *directoryStats {
+	return &directoryStats{
+		maxDepth: maxDepth,
+		dirs:     make(map[string]*directoryStatsEntry),
+	}
+}
+
+// addFile adds the file node at npath to all parent directories. If countSize
+// is false, the file only contributes to the unique size. This is used for
+// additional hard links to the same file.
+func (s *directoryStats) addFile(npath string, node *data.Node, countSize bool, lookupSize func(id restic.ID) (uint, bool)) error {
+	dir := path.Dir(npath)
+	parts := strings.Split(strings.Trim(dir, "/"), "/")
+	if dir == "/" {
+		parts = nil
+	}
+	if len(parts) > s.maxDepth {
+		parts = parts[:s.maxDepth]
+	}
+
+	for i := 0; i <= len(parts); i++ {
+		p := "/" + strings.Join(parts[:i], "/")
+		entry, ok := s.dirs[p]
+		if !ok {
+			entry = &directoryStatsEntry{Path: p, blobs: restic.NewIDSet()}
+			s.dirs[p] = entry
+		}
+
+		entry.FileCount++
+		if countSize {
+			entry.RestoreSize += node.Size
+		}
+		for _, id := range node.Content {
+			if entry.blobs.Has(id) {
+				continue
+			}
+			size, found := lookupSize(id)
+			if !found {
+				return errors.Errorf("blob %s of file %v not found", id.Str(), npath)
+			}
+			entry.blobs.Insert(id)
+			entry.UniqueSize += uint64(size)
+		}
+	}
+	return nil
+}
+
+// top returns the n directories with the largest unique size.
+func (s *directoryStats) top(n int) []*directoryStatsEntry {
+	entries := make([]*directoryStatsEntry, 0, len(s.dirs))
+	for _, entry := range s.dirs {
+		entries = append(entries, entry)
+	}
+	slices.SortFunc(entries, func(a, b *directoryStatsEntry) int {
+		switch {
+		case a.UniqueSize != b.UniqueSize:
+			if a.UniqueSize > b.UniqueSize {
+				return -1
+			}
+			return 1
+		case a.RestoreSize != b.RestoreSize:
+			if a.RestoreSize > b.RestoreSize {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Path, b.Path)
+	})
+
+	if n > 0 && len(entries) > n {
+		entries = entries[:n]
+	}
+	return entries
+}
+
+// printDirectoryStats prints a table of the directories in entries.
+func printDirectoryStats(wr io.Writer, entries []*directoryStatsEntry) error {
+	tab := table.New()
+	tab.AddColumn("Unique Size", "{{ .UniqueSize }}")
+	tab.AddColumn("Restore Size", "{{ .RestoreSize }}")
+	tab.AddColumn("Files", "{{ .FileCount }}")
+	tab.AddColumn("Path", "{{ .Path }}")
+
+	type row struct {
+		UniqueSize  string
+		RestoreSize string
+		FileCount   uint64
+		Path        string
+	}
+
+	for _, entry := range entries {
+		tab.AddRow(row{
+			UniqueSize:  ui.FormatBytes(entry.UniqueSize),
+			RestoreSize: ui.FormatBytes(entry.RestoreSize),
+			FileCount:   entry.FileCount,
+			Path:        entry.Path,
+		})
+	}
+
+	return tab.Write(wr)
+}
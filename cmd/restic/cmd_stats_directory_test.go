@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/restic/restic/internal/data"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestDirectoryStats(t *testing.T) {
+	blob1 := restic.NewRandomID()
+	blob2 := restic.NewRandomID()
+	blob3 := restic.NewRandomID()
+	sizes := map[restic.ID]uint{blob1: 100, blob2: 200, blob3: 400}
+	lookup := func(id restic.ID) (uint, bool) {
+		size, ok := sizes[id]
+		return size, ok
+	}
+
+	stats := newDirectoryStats(2)
+	for _, f := range []struct {
+		path      string
+		content   restic.IDs
+		countSize bool
+	}{
+		{"/home/user/a", restic.IDs{blob1, blob2}, true},
+		// duplicate content is only counted once for the unique size
+		{"/home/user/b", restic.IDs{blob1, blob2}, true},
+		// deeper directories are attributed to their parent at maxDepth
+		{"/home/user/dir/c", restic.IDs{blob3}, true},
+		// additional hard link
+		{"/home/other/d", restic.IDs{blob3}, false},
+		{"/e", restic.IDs{blob1}, true},
+	} {
+		size := uint64(0)
+		for _, id := range f.content {
+			size += uint64(sizes[id])
+		}
+		node := &data.Node{Type: data.NodeTypeFile, Size: size, Content: f.content}
+		rtest.OK(t, stats.addFile(f.path, node, f.countSize, lookup))
+	}
+
+	want := map[string]directoryStatsEntry{
+		"/":           {RestoreSize: 1100, UniqueSize: 700, FileCount: 5},
+		"/home":       {RestoreSize: 1000, UniqueSize: 700, FileCount: 4},
+		"/home/user":  {RestoreSize: 1000, UniqueSize: 700, FileCount: 3},
+		"/home/other": {RestoreSize: 0, UniqueSize: 400, FileCount: 1},
+	}
+	rtest.Equals(t, len(want), len(stats.dirs))
+	for p, w := range want {
+		entry, ok := stats.dirs[p]
+		rtest.Assert(t, ok, "missing directory %v", p)
+		rtest.Equals(t, w.RestoreSize, entry.RestoreSize, p)
+		rtest.Equals(t, w.UniqueSize, entry.UniqueSize, p)
+		rtest.Equals(t, w.FileCount, entry.FileCount, p)
+	}
+
+	var paths []string
+	for _, entry := range stats.top(3) {
+		paths = append(paths, entry.Path)
+	}
+	rtest.Equals(t, []string{"/", "/home", "/home/user"}, paths)
+	rtest.Equals(t, 4, len(stats.top(0)))
+
+	buf := &bytes.Buffer{}
+	rtest.OK(t, printDirectoryStats(buf, stats.top(1)))
+	rtest.Assert(t, strings.Contains(buf.String(), "700 B"), "unexpected output %q", buf.String())
+
+	node := &data.Node{Type: data.NodeTypeFile, Content: restic.IDs{restic.NewRandomID()}}
+	rtest.Assert(t, stats.addFile("/missing", node, true, lookup) != nil, "missing error for unknown blob")
+}
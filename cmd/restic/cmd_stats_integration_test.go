@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/restic/restic/internal/global"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func testRunStatsJSON(t testing.TB, gopts global.Options, opts StatsOptions, args []string) statsContainer {
+	buf, err := withCaptureStdout(t, gopts, func(ctx context.Context, gopts global.Options) error {
+		gopts.JSON = true
+		return runStats(ctx, opts, gopts, args, gopts.Term)
+	})
+	rtest.OK(t, err)
+
+	var stats statsContainer
+	rtest.OK(t, json.Unmarshal(buf.Bytes(), &stats))
+	return stats
+}
+
+func TestStatsUniquePerSnapshot(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+	env.gopts.BackendTestHook = nil
+
+	testSetupBackupData(t, env)
+	testRunBackup(t, "", []string{env.testdata}, BackupOptions{}, env.gopts)
+
+	const newFileSize = 2 * 1024 * 1024
+	rtest.OK(t, appendRandomData(filepath.Join(env.testdata, "new-file"), newFileSize))
+	testRunBackup(t, "", []string{env.testdata}, BackupOptions{}, env.gopts)
+
+	opts := StatsOptions{countMode: countModeUniquePerSnapshot}
+	stats := testRunStatsJSON(t, env.gopts, opts, nil)
+	rtest.Equals(t, 2, stats.SnapshotsCount)
+	rtest.Equals(t, 2, len(stats.Snapshots))
+
+	// only the trees containing the new file differ for the first snapshot
+	first, second := stats.Snapshots[0], stats.Snapshots[1]
+	rtest.Assert(t, first.UniqueSize > 0 && first.UniqueSize < newFileSize/2, "unexpected unique size for first snapshot: %v", first.UniqueSize)
+	rtest.Assert(t, second.UniqueSize > newFileSize, "unexpected unique size for second snapshot: %v", second.UniqueSize)
+	rtest.Equals(t, first.UniqueSize+second.UniqueSize, stats.TotalSize)
+
+	// another snapshot of the same data shares the new file with the second
+	// snapshot, only trees with changed metadata can still be unique
+	testRunBackup(t, "", []string{env.testdata}, BackupOptions{}, env.gopts)
+	stats = testRunStatsJSON(t, env.gopts, opts, nil)
+	rtest.Equals(t, 3, len(stats.Snapshots))
+	for _, sn := range stats.Snapshots[1:] {
+		rtest.Assert(t, sn.UniqueSize < newFileSize/2, "unexpected unique size for snapshot %v: %v", sn.ID, sn.UniqueSize)
+	}
+
+	// the unique size depends on all snapshots, not only on the selected ones
+	latest := stats.Snapshots[2]
+	stats = testRunStatsJSON(t, env.gopts, opts, []string{"latest"})
+	rtest.Equals(t, 1, len(stats.Snapshots))
+	rtest.Equals(t, latest.UniqueSize, stats.Snapshots[0].UniqueSize)
+	rtest.Equals(t, latest.UniqueSize, stats.TotalSize)
+}
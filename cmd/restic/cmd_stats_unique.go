@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/restic/restic/internal/data"
+	"github.com/restic/restic/internal/global"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui"
+	statsui "github.com/restic/restic/internal/ui/stats"
+	"github.com/restic/restic/internal/ui/table"
+)
+
+// sharedBlob marks a blob which is referenced by more than one snapshot.
+const sharedBlob = -1
+
+// blobOwners records for each blob the only snapshot referencing it, or
+// sharedBlob if several snapshots reference the blob.
+type blobOwners struct {
+	m      sync.Mutex
+	owners map[restic.BlobHandle]int
+}
+
+func newBlobOwners() *blobOwners {
+	return &blobOwners{owners: make(map[restic.BlobHandle]int)}
+}
+
+// mark records that snapshot sn references the blob h. It returns true if
+// sn has not referenced the blob before and the blob was not already shared.
+func (o *blobOwners) mark(h restic.BlobHandle, sn int) bool {
+	o.m.Lock()
+	defer o.m.Unlock()
+
+	owner, ok := o.owners[h]
+	switch {
+	case !ok:
+		o.owners[h] = sn
+		return true
+	case owner == sharedBlob || owner == sn:
+		return false
+	default:
+		o.owners[h] = sharedBlob
+		return true
+	}
+}
+
+// markSnapshot marks all blobs referenced by the snapshot sn with root tree
+// treeID. The trees are loaded in parallel, subtrees which are already shared
+// or were already visited for this snapshot are skipped.
+func (o *blobOwners) markSnapshot(ctx context.Context, repo restic.Loader, treeID restic.ID, sn int) error {
+	return data.StreamTrees(ctx, repo, restic.IDs{treeID}, restic.NoopCounter, func(treeID restic.ID) bool {
+		return !o.mark(restic.BlobHandle{ID: treeID, Type: restic.TreeBlob}, sn)
+	}, func(_ restic.ID, err error, nodes data.TreeNodeIterator) error {
+		if err != nil {
+			return err
+		}
+
+		for item := range nodes {
+			if item.Error != nil {
+				return item.Error
+			}
+			if item.Node.Type != data.NodeTypeFile {
+				continue
+			}
+			for _, blob := range item.Node.Content {
+				o.mark(restic.BlobHandle{ID: blob, Type: restic.DataBlob}, sn)
+			}
+		}
+		return nil
+	})
+}
+
+// snapshotUniqueSize holds the size of the data only referenced by a single
+// snapshot, which is freed when the snapshot is removed.
+type snapshotUniqueSize struct {
+	ID         string    `json:"id"`
+	Time       time.Time `json:"time"`
+	Hostname   string    `json:"hostname"`
+	Paths      []string  `json:"paths"`
+	UniqueSize uint64    `json:"unique_size"`
+	BlobCount  uint64    `json:"unique_blob_count"`
+}
+
+// statsUniquePerSnapshot computes the unique size of each of the snapshots.
+// As a blob is only unique if no other snapshot references it, all snapshots
+// in allSnapshots are scanned.
+func statsUniquePerSnapshot(ctx context.Context, repo restic.Repository, snapshots, allSnapshots data.Snapshots, stats *statsContainer, sp *statsui.Progress) error {
+	owners := newBlobOwners()
+	for i, sn := range allSnapshots {
+		sp.ProcessSnapshot()
+		if sn.Tree == nil {
+			return fmt.Errorf("snapshot %s has nil tree", sn.ID().Str())
+		}
+		if err := owners.markSnapshot(ctx, repo, *sn.Tree, i); err != nil {
+			return fmt.Errorf("walking snapshot %s: %v", sn.ID().Str(), err)
+		}
+	}
+
+	index := make(map[restic.ID]int, len(allSnapshots))
+	for i, sn := range allSnapshots {
+		index[*sn.ID()] = i
+	}
+
+	entries := make(map[int]*snapshotUniqueSize, len(snapshots))
+	for _, sn := range snapshots {
+		entries[index[*sn.ID()]] = &snapshotUniqueSize{
+			ID:       sn.ID().String(),
+			Time:     sn.Time,
+			Hostname: sn.Hostname,
+			Paths:    sn.Paths,
+		}
+	}
+
+	for h, owner := range owners.owners {
+		entry, ok := entries[owner]
+		if !ok {
+			continue
+		}
+		pbs := repo.LookupBlob(h)
+		if len(pbs) == 0 {
+			return fmt.Errorf("blob %v not found", h)
+		}
+		entry.UniqueSize += uint64(pbs[0].CiphertextLength())
+		entry.BlobCount++
+	}
+
+	stats.SnapshotsCount = len(entries)
+	for _, entry := range entries {
+		stats.TotalSize += entry.UniqueSize
+		stats.TotalBlobCount += entry.BlobCount
+		stats.Snapshots = append(stats.Snapshots, entry)
+	}
+	sort.Slice(stats.Snapshots, func(i, j int) bool {
+		return stats.Snapshots[i].Time.Before(stats.Snapshots[j].Time)
+	})
+	return nil
+}
+
+// printUniqueSizes prints a table of the unique size of each snapshot.
+func printUniqueSizes(wr io.Writer, entries []*snapshotUniqueSize) error {
+	tab := table.New()
+	tab.AddColumn("ID", "{{ .ID }}")
+	tab.AddColumn("Time", "{{ .Time }}")
+	tab.AddColumn("Host", "{{ .Hostname }}")
+	tab.AddColumn("Paths", "{{ .Paths }}")
+	tab.AddColumn("Unique Size", "{{ .UniqueSize }}")
+	tab.AddColumn("Blobs", "{{ .BlobCount }}")
+
+	type row struct {
+		ID         string
+		Time       string
+		Hostname   string
+		Paths      string
+		UniqueSize string
+		BlobCount  uint64
+	}
+
+	for _, entry := range entries {
+		tab.AddRow(row{
+			ID:         entry.ID[:8],
+			Time:       entry.Time.Local().Format(global.TimeFormat),
+			Hostname:   entry.Hostname,
+			Paths:      strings.Join(entry.Paths, ", "),
+			UniqueSize: ui.FormatBytes(entry.UniqueSize),
+			BlobCount:  entry.BlobCount,
+		})
+	}
+
+	return tab.Write(wr)
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/restic/restic/internal/data"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/global"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui"
+	"github.com/restic/restic/internal/ui/progress"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func newTierCommand(globalOptions *global.Options) *cobra.Command {
+	var opts TierOptions
+
+	cmd := &cobra.Command{
+		Use:   "tier [flags]",
+		Short: "Move pack files of old snapshots to a cheaper storage class",
+		Long: `
+The "tier" command moves the pack files which contain the file contents of old
+snapshots to another storage class of the backend, for example to "GLACIER" for
+S3 or to "Archive" for Azure. Only the storage class of the existing files is
+changed, their content remains the same.
+
+Snapshots which are older than "--older-than" and, if given, have one of the
+tags passed to "--tag" are selected. Pack files are only moved if they are not
+used by any other snapshot, such that the remaining snapshots can still be
+restored as usual. Pack files containing directory metadata are never moved.
+
+Restoring a selected snapshot afterwards can require to warm up the pack files
+first, for S3 see the "s3.enable-restore" option.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful.
+Exit status is 1 if there was any error.
+Exit status is 10 if the repository does not exist.
+Exit status is 11 if the repository is already locked.
+Exit status is 12 if the password is incorrect.
+`,
+		GroupID:           cmdGroupAdvanced,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runTier(cmd.Context(), opts, *globalOptions, args, globalOptions.Term)
+		},
+	}
+
+	opts.AddFlags(cmd.Flags())
+	return cmd
+}
+
+// TierOptions collects all options for the tier command.
+type TierOptions struct {
+	StorageClass string
+	OlderThan    data.Duration
+	Tags         data.TagLists
+	DryRun       bool
+}
+
+func (opts *TierOptions) AddFlags(f *pflag.FlagSet) {
+	f.StringVar(&opts.StorageClass, "storage-class", "", "move pack files to the backend storage `class`")
+	f.Var(&opts.OlderThan, "older-than", "select snapshots older than `duration` (eg. 1y5m7d2h)")
+	f.Var(&opts.Tags, "tag", "only select snapshots which include this `taglist` (can be specified multiple times)")
+	f.BoolVarP(&opts.DryRun, "dry-run", "n", false, "do not modify the repository, just print what would be done")
+}
+
+func runTier(ctx context.Context, opts TierOptions, gopts global.Options, args []string, term ui.Terminal) error {
+	if len(args) > 0 {
+		return errors.Fatal("the tier command expects no arguments, only options - please see `restic help tier` for usage and flags")
+	}
+	if opts.StorageClass == "" {
+		return errors.Fatal("--storage-class is required")
+	}
+	if opts.OlderThan.Zero() && len(opts.Tags) == 0 {
+		return errors.Fatal("select snapshots using --older-than or --tag")
+	}
+	if gopts.NoLock && !opts.DryRun {
+		return errors.Fatal("--no-lock is only applicable in combination with --dry-run for tier command")
+	}
+
+	printer := progress.NewTerminalPrinter(false, gopts.Verbosity, term)
+	ctx, repo, unlock, err := openWithExclusiveLock(ctx, gopts, opts.DryRun && gopts.NoLock, printer)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	o := opts.OlderThan
+	cutoff := time.Now().AddDate(-o.Years, -o.Months, -o.Days).Add(time.Hour * time.Duration(-o.Hours))
+	var hotTrees, coldTrees restic.IDs
+	err = data.ForAllSnapshots(ctx, repo, repo, nil, func(id restic.ID, sn *data.Snapshot, err error) error {
+		if err != nil {
+			return err
+		}
+		if sn.Time.Before(cutoff) && (len(opts.Tags) == 0 || sn.HasTagList(opts.Tags)) {
+			printer.V("selected snapshot %v from %v", id.Str(), sn.Time.Format(time.DateTime))
+			coldTrees = append(coldTrees, *sn.Tree)
+		} else {
+			hotTrees = append(hotTrees, *sn.Tree)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	printer.P("selected %d snapshots, %d other snapshots remain readable", len(coldTrees), len(hotTrees))
+	if len(coldTrees) == 0 {
+		return nil
+	}
+
+	if err = repo.LoadIndex(ctx, printer); err != nil {
+		return err
+	}
+
+	hotBlobs := repo.NewAssociatedBlobSet()
+	coldBlobs := repo.NewAssociatedBlobSet()
+	bar := printer.NewCounter("snapshots")
+	bar.SetMax(uint64(len(hotTrees) + len(coldTrees)))
+	err = data.FindUsedBlobs(ctx, repo, hotTrees, hotBlobs, bar)
+	if err == nil {
+		err = data.FindUsedBlobs(ctx, repo, coldTrees, coldBlobs, bar)
+	}
+	bar.Done()
+	if err != nil {
+		return err
+	}
+
+	stats, err := repository.TierPacks(ctx, repo, repository.TierOptions{
+		StorageClass: opts.StorageClass,
+		DryRun:       opts.DryRun,
+	}, hotBlobs, coldBlobs, printer)
+	if err != nil {
+		return errors.Fatalf("moving pack files failed: %v", err)
+	}
+
+	verb := "moved"
+	if opts.DryRun {
+		verb = "would move"
+	}
+	printer.P("%s %d pack files (%s) to storage class %s, %d pack files already were in that class",
+		verb, stats.Packs, ui.FormatBytes(stats.Size), opts.StorageClass, stats.Skipped)
+	return nil
+}
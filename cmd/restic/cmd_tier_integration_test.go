@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/restic/restic/internal/data"
+	"github.com/restic/restic/internal/global"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func testRunTier(t testing.TB, gopts global.Options, opts TierOptions) error {
+	return withTermStatus(t, gopts, func(ctx context.Context, gopts global.Options) error {
+		return runTier(ctx, opts, gopts, nil, gopts.Term)
+	})
+}
+
+func TestTierUnsupportedBackend(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testSetupBackupData(t, env)
+	testRunBackup(t, env.testdata+"/0", []string{"."}, BackupOptions{}, env.gopts)
+
+	err := testRunTier(t, env.gopts, TierOptions{StorageClass: "GLACIER"})
+	rtest.Assert(t, err != nil && strings.Contains(err.Error(), "--older-than"), "expected error for missing selection, got %v", err)
+
+	// select all snapshots, the local backend has no storage classes
+	err = testRunTier(t, env.gopts, TierOptions{StorageClass: "GLACIER", OlderThan: data.Duration{Hours: -1}, DryRun: true})
+	rtest.Assert(t, err != nil && strings.Contains(err.Error(), "does not support"), "expected unsupported error, got %v", err)
+}
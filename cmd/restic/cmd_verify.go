@@ -0,0 +1,403 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"io"
+	"path"
+	"path/filepath"
+	"slices"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/restic/restic/internal/data"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/fs"
+	"github.com/restic/restic/internal/global"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui"
+	"github.com/restic/restic/internal/ui/progress"
+)
+
+func newVerifyCommand(globalOptions *global.Options) *cobra.Command {
+	var opts VerifyOptions
+
+	cmd := &cobra.Command{
+		Use:   "verify [flags] snapshotID directory",
+		Short: "Compare a snapshot with a directory",
+		Long: `
+The "verify" command compares the content of a snapshot with a directory, for
+example a restore target or the original backup source, without restoring any
+data. The first characters in each line display how an item in the directory
+differs from the snapshot:
+
+* +  The item exists in the directory but not in the snapshot
+* -  The item exists in the snapshot but is missing in the directory
+* U  The metadata (access mode, modification time, owner) differs
+* M  The content differs
+* T  The type differs, e.g. a file is a symlink in the directory
+
+The content of files is compared by splitting each file into chunks like the
+"backup" command does and comparing the resulting blob IDs with the snapshot.
+Thus, no file content has to be downloaded from the repository.
+
+The special snapshotID "latest" can be used to compare with the latest snapshot
+in the repository.
+
+To compare a directory with a specific subfolder of a snapshot, use the
+"snapshotID:subfolder" syntax, where "subfolder" is a path within the snapshot
+tree as shown by "restic ls".
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the directory matches the snapshot.
+Exit status is 1 if there was any error or if differences were found.
+Exit status is 10 if the repository does not exist.
+Exit status is 11 if the repository is already locked.
+Exit status is 12 if the password is incorrect.
+`,
+		GroupID:           cmdGroupDefault,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			finalizeSnapshotFilter(&opts.SnapshotFilter)
+			return runVerify(cmd.Context(), opts, *globalOptions, args, globalOptions.Term)
+		},
+	}
+
+	opts.AddFlags(cmd.Flags())
+	return cmd
+}
+
+// VerifyOptions collects all options for the verify command.
+type VerifyOptions struct {
+	data.SnapshotFilter
+	MetadataOnly bool
+}
+
+func (opts *VerifyOptions) AddFlags(f *pflag.FlagSet) {
+	initSingleSnapshotFilter(f, &opts.SnapshotFilter)
+	f.BoolVar(&opts.MetadataOnly, "metadata-only", false, "only compare metadata and size, do not read file contents")
+}
+
+// VerifyStats collects statistics about a comparison.
+type VerifyStats struct {
+	MessageType string `json:"message_type"` // "statistics"
+	Files       int    `json:"files"`
+	Dirs        int    `json:"dirs"`
+	Others      int    `json:"others"`
+	Differences int    `json:"differences"`
+	Errors      int    `json:"errors"`
+}
+
+// Verifier compares a snapshot with a directory.
+type Verifier struct {
+	repo        restic.BlobLoader
+	fs          fs.FS
+	opts        VerifyOptions
+	chunker     restic.Chunker
+	buf         []byte
+	stats       VerifyStats
+	printChange func(change *Change)
+	printError  func(string, ...interface{})
+}
+
+func (v *Verifier) report(name, mod string) {
+	v.stats.Differences++
+	v.printChange(NewChange(name, mod))
+}
+
+func (v *Verifier) error(msg string, args ...interface{}) {
+	v.stats.Errors++
+	v.printError(msg, args...)
+}
+
+// reportExtra reports an item that only exists in the directory.
+func (v *Verifier) reportExtra(prefix, dir, name string) {
+	item := path.Join(prefix, name)
+	if fi, err := v.fs.Lstat(filepath.Join(dir, name)); err == nil && fi.Mode.IsDir() {
+		item += "/"
+	}
+	v.report(item, "+")
+}
+
+// verifyTree compares the tree with the given ID to the directory dir.
+func (v *Verifier) verifyTree(ctx context.Context, prefix, dir string, id restic.ID) error {
+	debug.Log("verify tree %v against %v", id, dir)
+	tree, err := data.LoadTree(ctx, v.repo, id)
+	if err != nil {
+		return err
+	}
+
+	names, err := fs.Readdirnames(v.fs, dir, fs.O_NOFOLLOW)
+	if err != nil {
+		v.error("unable to list directory: %v", err)
+		return nil
+	}
+	slices.Sort(names)
+
+	for item := range tree {
+		if item.Error != nil {
+			return item.Error
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		node := item.Node
+
+		// sockets cannot be restored
+		if node.Type == data.NodeTypeSocket {
+			continue
+		}
+
+		for len(names) > 0 && names[0] < node.Name {
+			v.reportExtra(prefix, dir, names[0])
+			names = names[1:]
+		}
+
+		name := path.Join(prefix, node.Name)
+		if node.Type == data.NodeTypeDir {
+			name += "/"
+		}
+		v.addStats(node)
+
+		if len(names) == 0 || names[0] != node.Name {
+			v.report(name, "-")
+			continue
+		}
+		names = names[1:]
+
+		err := v.verifyNode(ctx, name, filepath.Join(dir, node.Name), node)
+		if err != nil && err != context.Canceled {
+			v.error("error: %v", err)
+		}
+	}
+
+	for _, name := range names {
+		v.reportExtra(prefix, dir, name)
+	}
+
+	return ctx.Err()
+}
+
+func (v *Verifier) addStats(node *data.Node) {
+	switch node.Type {
+	case data.NodeTypeFile:
+		v.stats.Files++
+	case data.NodeTypeDir:
+		v.stats.Dirs++
+	default:
+		v.stats.Others++
+	}
+}
+
+// verifyNode compares node with the item at target.
+func (v *Verifier) verifyNode(ctx context.Context, name, target string, node *data.Node) error {
+	f, err := v.fs.OpenFile(target, fs.O_NOFOLLOW, true)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	live, err := f.ToNode(true, func(format string, args ...any) {
+		debug.Log(format, args...)
+	})
+	if live == nil {
+		return err
+	}
+	if err != nil {
+		debug.Log("incomplete metadata for %v: %v", target, err)
+	}
+
+	if live.Type != node.Type {
+		v.report(name, "T")
+		return nil
+	}
+
+	mod := ""
+	switch node.Type {
+	case data.NodeTypeFile:
+		if live.Size != node.Size {
+			mod += "M"
+		} else if !v.opts.MetadataOnly {
+			same, err := v.sameContent(f, node.Content)
+			if err != nil {
+				return errors.Wrapf(err, "read %v", target)
+			}
+			if !same {
+				mod += "M"
+			}
+		}
+	case data.NodeTypeSymlink:
+		if live.LinkTarget != node.LinkTarget {
+			mod += "M"
+		}
+	case data.NodeTypeDev, data.NodeTypeCharDev:
+		if live.Device != node.Device {
+			mod += "M"
+		}
+	}
+
+	if live.Mode != node.Mode || !live.ModTime.Equal(node.ModTime) ||
+		live.UID != node.UID || live.GID != node.GID {
+		mod += "U"
+	}
+
+	if mod != "" {
+		v.report(name, mod)
+	}
+
+	if node.Type == data.NodeTypeDir {
+		return v.verifyTree(ctx, name, target, *node.Subtree)
+	}
+	return nil
+}
+
+// sameContent splits the content of f into chunks and returns whether the IDs
+// of these chunks match content.
+func (v *Verifier) sameContent(f fs.File, content restic.IDs) (bool, error) {
+	if err := f.MakeReadable(); err != nil {
+		return false, err
+	}
+
+	v.chunker.Reset()
+	h := sha256.New()
+	pending := false
+	ids := restic.IDs{}
+
+	for {
+		n, err := f.Read(v.buf)
+		buf := v.buf[:n]
+		for len(buf) > 0 {
+			split := v.chunker.NextSplitPoint(buf)
+			if split == -1 {
+				_, _ = h.Write(buf)
+				pending = true
+				break
+			}
+
+			_, _ = h.Write(buf[:split])
+			ids = append(ids, restic.IDFromHash(h.Sum(nil)))
+			h.Reset()
+			pending = false
+			buf = buf[split:]
+
+			if len(ids) > len(content) || ids[len(ids)-1] != content[len(ids)-1] {
+				return false, nil
+			}
+		}
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if pending {
+		ids = append(ids, restic.IDFromHash(h.Sum(nil)))
+	}
+	return slices.Equal(ids, content), nil
+}
+
+func runVerify(ctx context.Context, opts VerifyOptions, gopts global.Options, args []string, term ui.Terminal) error {
+	if len(args) != 2 {
+		return errors.Fatal("specify a snapshot ID and a directory")
+	}
+
+	printer := progress.NewTerminalPrinter(gopts.JSON, gopts.Verbosity, term)
+
+	target, err := filepath.Abs(args[1])
+	if err != nil {
+		return errors.Wrap(err, "Abs")
+	}
+	fi, err := fs.Lstat(target)
+	if err != nil {
+		return errors.Fatalf("unable to access directory: %v", err)
+	}
+	if !fi.IsDir() {
+		return errors.Fatalf("%v is not a directory", args[1])
+	}
+
+	ctx, repo, unlock, err := openWithReadLock(ctx, gopts, gopts.NoLock, printer)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	sn, subfolder, err := opts.SnapshotFilter.FindLatest(ctx, repo, repo, args[0])
+	if err != nil {
+		return errors.Fatalf("failed to find snapshot: %v", err)
+	}
+
+	if err = repo.LoadIndex(ctx, printer); err != nil {
+		return err
+	}
+
+	sn.Tree, err = data.FindTreeDirectory(ctx, repo, sn.Tree, subfolder)
+	if err != nil {
+		return err
+	}
+
+	if !gopts.JSON {
+		printer.P("comparing snapshot %v to %v:\n\n", sn.ID().Str(), target)
+	}
+
+	chunkerFactory := repo.ChunkerFactory()
+	v := &Verifier{
+		repo:       repo,
+		fs:         fs.NewLocal(),
+		opts:       opts,
+		chunker:    chunkerFactory.NewChunker(),
+		buf:        make([]byte, chunkerFactory.MaxChunkSize()),
+		stats:      VerifyStats{MessageType: "statistics"},
+		printError: printer.E,
+		printChange: func(change *Change) {
+			printer.S("%-5s%v", change.Modifier, change.Path)
+		},
+	}
+
+	if gopts.JSON {
+		enc := json.NewEncoder(gopts.Term.OutputWriter())
+		v.printChange = func(change *Change) {
+			err := enc.Encode(change)
+			if err != nil {
+				printer.E("JSON encode failed: %v", err)
+			}
+		}
+	}
+
+	if gopts.Quiet {
+		v.printChange = func(_ *Change) {}
+	}
+
+	err = v.verifyTree(ctx, "/", target, *sn.Tree)
+	if err != nil {
+		return err
+	}
+
+	if gopts.JSON {
+		err := json.NewEncoder(gopts.Term.OutputWriter()).Encode(v.stats)
+		if err != nil {
+			printer.E("JSON encode failed: %v", err)
+		}
+	} else {
+		printer.S("")
+		printer.S("Compared %d files, %d dirs and %d other items", v.stats.Files, v.stats.Dirs, v.stats.Others)
+		printer.S("Found %d differences and %d errors", v.stats.Differences, v.stats.Errors)
+	}
+
+	if v.stats.Errors > 0 {
+		return errors.Fatalf("failed to verify %d items", v.stats.Errors)
+	}
+	if v.stats.Differences > 0 {
+		return errors.Fatal("directory does not match the snapshot")
+	}
+	return nil
+}
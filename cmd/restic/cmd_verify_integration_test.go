@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/restic/restic/internal/global"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func testRunVerifyOutput(t testing.TB, gopts global.Options, snapshotID string, dir string) (string, error) {
+	buf, err := withCaptureStdout(t, gopts, func(ctx context.Context, gopts global.Options) error {
+		return runVerify(ctx, VerifyOptions{}, gopts, []string{snapshotID, dir}, gopts.Term)
+	})
+	return buf.String(), err
+}
+
+func TestVerify(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+
+	for _, name := range []string{"foo/modified", "foo/removed", "bar/unchanged"} {
+		p := filepath.Join(env.testdata, name)
+		rtest.OK(t, os.MkdirAll(filepath.Dir(p), 0755))
+		rtest.OK(t, appendRandomData(p, 3*1024*1024))
+	}
+
+	testRunBackup(t, env.testdata, []string{"."}, BackupOptions{}, env.gopts)
+	snapshotID := testListSnapshots(t, env.gopts, 1)[0].String()
+
+	_, err := testRunVerifyOutput(t, env.gopts, snapshotID, env.testdata)
+	rtest.OK(t, err)
+
+	// modify the content without changing size and modification time
+	modified := filepath.Join(env.testdata, "foo", "modified")
+	fi, err := os.Stat(modified)
+	rtest.OK(t, err)
+	f, err := os.OpenFile(modified, os.O_WRONLY, 0)
+	rtest.OK(t, err)
+	_, err = f.WriteAt([]byte("bitrot"), 2*1024*1024)
+	rtest.OK(t, err)
+	rtest.OK(t, f.Close())
+	rtest.OK(t, os.Chtimes(modified, fi.ModTime(), fi.ModTime()))
+
+	rtest.OK(t, os.Remove(filepath.Join(env.testdata, "foo", "removed")))
+	rtest.OK(t, os.WriteFile(filepath.Join(env.testdata, "bar", "added"), []byte("added"), 0644))
+
+	env.gopts.Quiet = false
+	out, err := testRunVerifyOutput(t, env.gopts, snapshotID, env.testdata)
+	rtest.Assert(t, err != nil, "expected differences to be reported as error")
+
+	for _, expected := range []string{"U    /bar/\n", "+    /bar/added\n", "-    /foo/removed\n", "M    /foo/modified\n"} {
+		rtest.Assert(t, strings.Contains(out, expected), "missing change %q in output:\n%s", expected, out)
+	}
+	rtest.Assert(t, !strings.Contains(out, "/bar/unchanged"), "unexpected change for unchanged file:\n%s", out)
+}
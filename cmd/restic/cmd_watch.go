@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/restic/restic/internal/backend/cache"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/fs"
+	"github.com/restic/restic/internal/global"
+	"github.com/restic/restic/internal/ui"
+	"github.com/restic/restic/internal/ui/progress"
+)
+
+func newWatchCommand(globalOptions *global.Options) *cobra.Command {
+	var opts WatchOptions
+
+	cmd := &cobra.Command{
+		Use:   "watch [flags] FILE/DIR [FILE/DIR] ...",
+		Short: "Continuously back up files and directories when they change",
+		Long: `
+The "watch" command creates a snapshot of the given files and directories and
+then watches them for changes. Once changes have been detected and no further
+changes happened for the duration given by --quiet-period, a new snapshot is
+created. Snapshots are created at most once per --min-interval. The command
+runs until it is interrupted.
+
+Changes are detected using inotify on Linux, FSEvents on macOS (only for
+binaries built with cgo) and ReadDirectoryChangesW on Windows. Otherwise, the
+files and directories are scanned for changes every 10 seconds. The watch
+command accepts the same options as the backup command, except for those
+reading from stdin. Changes of excluded files do not trigger a backup.
+
+EXIT STATUS
+===========
+
+Exit status is 0 if the command was successful.
+Exit status is 1 if there was a fatal error.
+Exit status is 10 if the repository does not exist.
+Exit status is 11 if the repository is already locked.
+Exit status is 12 if the password is incorrect.
+Exit status is 130 if the command was interrupted.
+`,
+		PreRunE: func(_ *cobra.Command, _ []string) error {
+			return opts.Finalize()
+		},
+		GroupID:           cmdGroupDefault,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runWatch(cmd.Context(), opts, *globalOptions, globalOptions.Term, args)
+		},
+	}
+
+	opts.AddFlags(cmd.Flags())
+	return cmd
+}
+
+// WatchOptions bundles all options for the watch command.
+type WatchOptions struct {
+	BackupOptions
+
+	QuietPeriod time.Duration
+	MinInterval time.Duration
+}
+
+func (opts *WatchOptions) AddFlags(f *pflag.FlagSet) {
+	opts.BackupOptions.AddFlags(f)
+	f.DurationVar(&opts.QuietPeriod, "quiet-period", 10*time.Second, "wait until no changes happened for `duration` before creating a snapshot")
+	f.DurationVar(&opts.MinInterval, "min-interval", 5*time.Minute, "create snapshots at most once per `duration`")
+}
+
+func runWatch(ctx context.Context, opts WatchOptions, gopts global.Options, term ui.Terminal, args []string) error {
+	if opts.readsStdin() {
+		return errors.Fatal("the watch command cannot read from stdin")
+	}
+	if opts.DryRun {
+		return errors.Fatal("--dry-run is not supported by the watch command")
+	}
+	if opts.QuietPeriod <= 0 {
+		return errors.Fatal("--quiet-period must be positive")
+	}
+
+	printer := progress.NewTerminalPrinter(gopts.JSON, gopts.Verbosity, term)
+
+	targets, err := collectTargets(opts.BackupOptions, args, printer.E, term.InputRaw())
+	if err != nil {
+		return err
+	}
+
+	reject, err := collectWatchRejectFuncs(opts.BackupOptions, gopts, printer.E)
+	if err != nil {
+		return err
+	}
+
+	// start watching before the first backup, so no changes are missed
+	watcher, err := fs.NewWatcher(targets)
+	if err != nil {
+		return errors.Fatalf("unable to watch for changes: %v", err)
+	}
+	defer func() {
+		_ = watcher.Close()
+	}()
+
+	backup := func(ctx context.Context) error {
+		err := runBackup(ctx, opts.BackupOptions, gopts, term, args)
+		if err != nil && ctx.Err() == nil && !errors.IsFatal(err) {
+			// keep watching if some files could not be read or the
+			// repository was temporarily unavailable
+			printer.E("backup failed: %v", err)
+			return nil
+		}
+		return err
+	}
+
+	if err := backup(ctx); err != nil {
+		return err
+	}
+
+	printer.P("watching %d paths for changes", len(targets))
+	return watchLoop(ctx, watcher, opts.QuietPeriod, opts.MinInterval, func(path string) bool {
+		for _, rejectFn := range reject {
+			if rejectFn(path) {
+				return false
+			}
+		}
+		return true
+	}, func(ctx context.Context) error {
+		printer.V("changes detected, creating snapshot")
+		return backup(ctx)
+	}, printer.E)
+}
+
+// collectWatchRejectFuncs returns functions which reject changes of excluded
+// paths, including the restic cache directory.
+func collectWatchRejectFuncs(opts BackupOptions, gopts global.Options, warnf func(msg string, args ...interface{})) ([]func(string) bool, error) {
+	var funcs []func(string) bool
+
+	patterns, err := opts.ExcludePatternOptions.CollectPatterns(warnf)
+	if err != nil {
+		return nil, err
+	}
+	for _, pat := range patterns {
+		funcs = append(funcs, pat)
+	}
+
+	if !gopts.NoCache {
+		cacheDir := gopts.CacheDir
+		if cacheDir == "" {
+			cacheDir, err = cache.DefaultDir()
+			if err != nil {
+				debug.Log("unable to determine cache directory: %v", err)
+			}
+		}
+		if cacheDir != "" {
+			cacheDir, err = filepath.Abs(cacheDir)
+			if err != nil {
+				return nil, err
+			}
+			funcs = append(funcs, func(path string) bool {
+				return fs.HasPathPrefix(cacheDir, path)
+			})
+		}
+	}
+
+	return funcs, nil
+}
+
+// watchLoop calls backup once changes of selected paths were reported by
+// watcher and no further changes happened for quietPeriod. Consecutive calls
+// to backup are at least minInterval apart. The loop runs until ctx is
+// cancelled, the watcher stops or backup returns an error.
+func watchLoop(ctx context.Context, watcher fs.Watcher, quietPeriod, minInterval time.Duration, selected func(path string) bool, backup func(ctx context.Context) error, warnf func(msg string, args ...interface{})) error {
+	lastBackup := time.Now()
+	pending := false
+
+	timer := time.NewTimer(quietPeriod)
+	timer.Stop()
+	defer timer.Stop()
+
+	events, errs := watcher.Events(), watcher.Errors()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case path, ok := <-events:
+			if !ok {
+				return errors.Fatal("watching for changes stopped unexpectedly")
+			}
+			if !selected(path) {
+				continue
+			}
+			debug.Log("change of %v", path)
+			pending = true
+			timer.Reset(quietPeriod)
+
+		case err, ok := <-errs:
+			if ok {
+				warnf("error watching for changes: %v", err)
+			} else {
+				errs = nil
+			}
+
+		case <-timer.C:
+			if !pending {
+				continue
+			}
+			if wait := time.Until(lastBackup.Add(minInterval)); wait > 0 {
+				debug.Log("waiting %v until the minimum interval has passed", wait)
+				timer.Reset(wait)
+				continue
+			}
+
+			pending = false
+			if err := backup(ctx); err != nil {
+				return err
+			}
+			lastBackup = time.Now()
+		}
+	}
+}
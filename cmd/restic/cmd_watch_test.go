@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+type testWatcher struct {
+	events chan string
+	errors chan error
+}
+
+func newTestWatcher() *testWatcher {
+	return &testWatcher{
+		events: make(chan string),
+		errors: make(chan error),
+	}
+}
+
+func (w *testWatcher) Events() <-chan string { return w.events }
+func (w *testWatcher) Errors() <-chan error  { return w.errors }
+func (w *testWatcher) Close() error          { return nil }
+
+func TestWatchLoop(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	w := newTestWatcher()
+	backups := make(chan time.Time, 10)
+	selected := func(path string) bool {
+		return !strings.HasSuffix(path, ".tmp")
+	}
+	backup := func(_ context.Context) error {
+		backups <- time.Now()
+		return nil
+	}
+
+	const quiet = 50 * time.Millisecond
+	const minInterval = 300 * time.Millisecond
+
+	done := make(chan error, 1)
+	start := time.Now()
+	go func() {
+		done <- watchLoop(ctx, w, quiet, minInterval, selected, backup, t.Logf)
+	}()
+
+	// excluded paths must not trigger a backup
+	w.events <- "/foo/file.tmp"
+	select {
+	case <-backups:
+		t.Fatal("backup for excluded path")
+	case <-time.After(3 * quiet):
+	}
+
+	// several changes are coalesced into a single backup, which is delayed
+	// until the minimum interval has passed
+	for i := 0; i < 3; i++ {
+		w.events <- "/foo/file"
+	}
+	first := <-backups
+	rtest.Assert(t, first.Sub(start) >= minInterval, "backup after %v, before minimum interval %v", first.Sub(start), minInterval)
+
+	w.events <- "/foo/other"
+	second := <-backups
+	rtest.Assert(t, second.Sub(first) >= minInterval, "backups only %v apart", second.Sub(first))
+
+	select {
+	case <-backups:
+		t.Fatal("unexpected additional backup")
+	case <-time.After(3 * quiet):
+	}
+
+	cancel()
+	rtest.Equals(t, context.Canceled, <-done)
+}
+
+func TestWatchLoopClosedWatcher(t *testing.T) {
+	w := newTestWatcher()
+	close(w.events)
+
+	err := watchLoop(context.Background(), w, time.Second, time.Second, func(string) bool { return true },
+		func(context.Context) error { return nil }, t.Logf)
+	rtest.Assert(t, err != nil, "expected error for closed watcher")
+}
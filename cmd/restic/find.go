@@ -17,7 +17,10 @@ func initMultiSnapshotFilter(flags *pflag.FlagSet, filt *data.SnapshotFilter, ad
 	}
 	flags.StringArrayVarP(&filt.Hosts, "host", hostShorthand, nil, "only consider snapshots for this `host` (can be specified multiple times, use empty string to unset default value) (default: $RESTIC_HOST)")
 	flags.Var(&filt.Tags, "tag", "only consider snapshots including `tag[,tag,...]` (can be specified multiple times)")
+	flags.Var(&filt.Labels, "label-selector", "only consider snapshots whose labels match `key=value[,key!=value,...]` (can be specified multiple times)")
 	flags.StringArrayVar(&filt.Paths, "path", nil, "only consider snapshots including this (absolute) `path` (can be specified multiple times, snapshots must include all specified paths)")
+	flags.Var(&filt.NewerThan, "newer-than", "only consider snapshots created after `time` (a duration like 7d or a date like 2025-01-01)")
+	flags.Var(&filt.OlderThan, "older-than", "only consider snapshots created before `time` (a duration like 7d or a date like 2025-01-01)")
 }
 
 // initSingleSnapshotFilter is used for commands that work on a single snapshot
@@ -26,7 +29,10 @@ func initMultiSnapshotFilter(flags *pflag.FlagSet, filt *data.SnapshotFilter, ad
 func initSingleSnapshotFilter(flags *pflag.FlagSet, filt *data.SnapshotFilter) {
 	flags.StringArrayVarP(&filt.Hosts, "host", "H", nil, "only consider snapshots for this `host`, when snapshot ID \"latest\" is given (can be specified multiple times, use empty string to unset default value) (default: $RESTIC_HOST)")
 	flags.Var(&filt.Tags, "tag", "only consider snapshots including `tag[,tag,...]`, when snapshot ID \"latest\" is given (can be specified multiple times)")
+	flags.Var(&filt.Labels, "label-selector", "only consider snapshots whose labels match `key=value[,key!=value,...]`, when snapshot ID \"latest\" is given (can be specified multiple times)")
 	flags.StringArrayVar(&filt.Paths, "path", nil, "only consider snapshots including this (absolute) `path`, when snapshot ID \"latest\" is given (can be specified multiple times, snapshots must include all specified paths)")
+	flags.Var(&filt.NewerThan, "newer-than", "only consider snapshots created after `time` (a duration like 7d or a date like 2025-01-01), when snapshot ID \"latest\" is given")
+	flags.Var(&filt.OlderThan, "older-than", "only consider snapshots created before `time` (a duration like 7d or a date like 2025-01-01), when snapshot ID \"latest\" is given")
 }
 
 // finalizeSnapshotFilter applies RESTIC_HOST default only if --host flag wasn't explicitly set.
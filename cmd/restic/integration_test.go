@@ -69,7 +69,8 @@ func newOrderedListOnceBackend(be backend.Backend) *listOnceBackend {
 }
 
 func (be *listOnceBackend) List(ctx context.Context, t backend.FileType, fn func(backend.FileInfo) error) error {
-	if t != backend.LockFile && be.listedFileType[t] {
+	// each operation lists the audit log once to append its entry
+	if t != backend.LockFile && t != backend.AuditLogFile && be.listedFileType[t] {
 		return errors.Errorf("tried listing type %v the second time", t)
 	}
 	if be.strictOrder && t == backend.SnapshotFile && be.listedFileType[backend.IndexFile] {
@@ -15,12 +15,13 @@ func internalOpenWithLocked(ctx context.Context, gopts global.Options, dryRun bo
 	}
 
 	unlock := func() {}
-	if !dryRun {
-		unlock, ctx, err = repository.LockRepo(ctx, repo, exclusive, gopts.RetryLock, func(msg string) {
-			if !gopts.JSON {
-				printer.P("%s", msg)
-			}
-		}, printer.E)
+	if !dryRun && !exclusive && gopts.OptimisticLock {
+		unlock, ctx, err = repository.LockRepoOptimistic(ctx, repo, printer.E)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	} else if !dryRun {
+		unlock, ctx, err = repository.LockRepo(ctx, repo, exclusive, gopts.RetryLock, lockRetryPrinter(gopts, printer), printer.E)
 		if err != nil {
 			return nil, nil, nil, err
 		}
@@ -28,7 +29,30 @@ func internalOpenWithLocked(ctx context.Context, gopts global.Options, dryRun bo
 		repo.SetDryRun()
 	}
 
-	return ctx, repo, unlock, nil
+	return ctx, repo, func() {
+		unlock()
+		saveCacheStats(repo, printer)
+	}, nil
+}
+
+// lockRetryPrinter returns the function which prints the message that restic
+// waits for a lock.
+func lockRetryPrinter(gopts global.Options, printer restic.Printer) func(msg string) {
+	return func(msg string) {
+		if !gopts.JSON {
+			printer.P("%s", msg)
+		}
+	}
+}
+
+// saveCacheStats adds the cache statistics of this run to those shown by
+// "restic cache --stats".
+func saveCacheStats(repo *repository.Repository, printer restic.Printer) {
+	if c := repo.Cache(); c != nil {
+		if err := c.SaveStats(); err != nil {
+			printer.E("unable to save cache statistics: %v", err)
+		}
+	}
 }
 
 func openWithReadLock(ctx context.Context, gopts global.Options, noLock bool, printer restic.Printer) (context.Context, *repository.Repository, func(), error) {
@@ -43,6 +67,31 @@ func openWithAppendLock(ctx context.Context, gopts global.Options, dryRun bool,
 	return internalOpenWithLocked(ctx, gopts, dryRun, false, printer)
 }
 
+// openWithPruneLock opens the repository for a concurrent prune. Its lock
+// allows backups to run at the same time, but not another concurrent prune.
+// The lock file is therefore created even if optimistic locking is enabled.
+func openWithPruneLock(ctx context.Context, gopts global.Options, dryRun bool, printer restic.Printer) (context.Context, *repository.Repository, func(), error) {
+	repo, err := global.OpenRepository(ctx, gopts, printer)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	unlock := func() {}
+	if !dryRun {
+		unlock, ctx, err = repository.LockRepoForPrune(ctx, repo, gopts.RetryLock, lockRetryPrinter(gopts, printer), printer.E)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	} else {
+		repo.SetDryRun()
+	}
+
+	return ctx, repo, func() {
+		unlock()
+		saveCacheStats(repo, printer)
+	}, nil
+}
+
 func openWithExclusiveLock(ctx context.Context, gopts global.Options, dryRun bool, printer restic.Printer) (context.Context, *repository.Repository, func(), error) {
 	return internalOpenWithLocked(ctx, gopts, dryRun, true, printer)
 }
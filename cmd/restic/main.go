@@ -6,10 +6,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
 	"os"
 	"runtime"
 	godebug "runtime/debug"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"go.uber.org/automaxprocs/maxprocs"
@@ -52,7 +55,11 @@ The full documentation can be found at https://restic.readthedocs.io/ .
 			case cobra.ShellCompRequestCmd, cobra.ShellCompNoDescRequestCmd:
 				return nil
 			}
-			return globalOptions.PreRun(needsPassword(c.Name()))
+			if err := globalOptions.PreRun(needsPassword(c.Name())); err != nil {
+				return err
+			}
+			globalOptions.SetupLog(strings.TrimPrefix(c.CommandPath(), c.Root().Name()+" "))
+			return nil
 		},
 	}
 
@@ -74,17 +81,22 @@ The full documentation can be found at https://restic.readthedocs.io/ .
 
 	// globalOptions is passed to commands by reference to allow PersistentPreRunE to modify it
 	cmd.AddCommand(
+		newAuditLogCommand(globalOptions),
 		newBackupCommand(globalOptions),
 		newCacheCommand(globalOptions),
 		newCatCommand(globalOptions),
 		newCheckCommand(globalOptions),
+		newConfigCommand(globalOptions),
 		newCopyCommand(globalOptions),
 		newDiffCommand(globalOptions),
 		newDumpCommand(globalOptions),
+		newExportCommand(globalOptions),
 		newFeaturesCommand(globalOptions),
 		newFindCommand(globalOptions),
 		newForgetCommand(globalOptions),
+		newGCEstimateCommand(globalOptions),
 		newGenerateCommand(globalOptions),
+		newImportCommand(globalOptions),
 		newInitCommand(globalOptions),
 		newKeyCommand(globalOptions),
 		newListCommand(globalOptions),
@@ -94,14 +106,20 @@ The full documentation can be found at https://restic.readthedocs.io/ .
 		newPruneCommand(globalOptions),
 		newRebuildIndexCommand(globalOptions),
 		newRecoverCommand(globalOptions),
+		newRepoInfoCommand(globalOptions),
 		newRepairCommand(globalOptions),
+		newReplicateCommand(globalOptions),
 		newRestoreCommand(globalOptions),
 		newRewriteCommand(globalOptions),
+		newServeCommand(globalOptions),
 		newSnapshotsCommand(globalOptions),
 		newStatsCommand(globalOptions),
 		newTagCommand(globalOptions),
+		newTierCommand(globalOptions),
 		newUnlockCommand(globalOptions),
+		newVerifyCommand(globalOptions),
 		newVersionCommand(globalOptions),
+		newWatchCommand(globalOptions),
 	)
 
 	registerDebugCommand(cmd, globalOptions)
@@ -133,6 +151,11 @@ func tweakGoGC() {
 }
 
 func printExitError(globalOptions global.Options, code int, message string) {
+	if globalOptions.LogFormat != "" && termstatus.ParseLogFormat(globalOptions.LogFormat) == nil {
+		logger := slog.New(termstatus.NewLogHandler(os.Stderr, globalOptions.LogOptions()))
+		logger.Error(strings.TrimRight(message, "\n"), "exit_code", code)
+		return
+	}
 	if globalOptions.JSON {
 		type jsonExitError struct {
 			MessageType string `json:"message_type"` // exit_error
@@ -186,6 +209,9 @@ func main() {
 		globalOptions.Term = term
 		ctx := createGlobalContext(os.Stderr)
 		err = newRootCommand(&globalOptions).ExecuteContext(ctx)
+		if c, ok := globalOptions.Term.(io.Closer); ok {
+			_ = c.Close()
+		}
 		switch err {
 		case nil:
 			err = ctx.Err()
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	ioprioWhoProcess = 1
+	ioprioClassIdle  = 3
+	ioprioClassShift = 13
+)
+
+// setIdlePriority lowers the CPU and I/O priority of restic to idle. On Linux
+// both priorities are set per thread, thus they are changed for all existing
+// threads. New threads inherit the priority of the thread which creates them.
+func setIdlePriority() error {
+	tasks, err := os.ReadDir("/proc/self/task")
+	if err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		tid, err := strconv.Atoi(task.Name())
+		if err != nil {
+			continue
+		}
+		// the thread may have exited in the meantime
+		if err := unix.Setpriority(unix.PRIO_PROCESS, tid, 19); err != nil && err != unix.ESRCH {
+			return fmt.Errorf("setpriority: %w", err)
+		}
+		_, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), ioprioClassIdle<<ioprioClassShift)
+		if errno != 0 && errno != unix.ESRCH {
+			return fmt.Errorf("ioprio_set: %w", errno)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,10 @@
+//go:build !unix && !windows
+
+package main
+
+import "github.com/restic/restic/internal/errors"
+
+// setIdlePriority is not supported on this platform.
+func setIdlePriority() error {
+	return errors.New("changing the priority is not supported on this platform")
+}
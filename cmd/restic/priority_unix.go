@@ -0,0 +1,18 @@
+//go:build unix && !linux
+
+package main
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// setIdlePriority lowers the CPU priority of restic to the lowest value. The
+// I/O priority cannot be changed on this platform.
+func setIdlePriority() error {
+	if err := unix.Setpriority(unix.PRIO_PROCESS, 0, 19); err != nil {
+		return fmt.Errorf("setpriority: %w", err)
+	}
+	return nil
+}
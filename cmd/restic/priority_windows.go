@@ -0,0 +1,19 @@
+package main
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/windows"
+)
+
+// setIdlePriority lowers the CPU priority of restic to idle and enables the
+// background processing mode, which also lowers the I/O and memory priority.
+func setIdlePriority() error {
+	if err := windows.SetPriorityClass(windows.CurrentProcess(), windows.IDLE_PRIORITY_CLASS); err != nil {
+		return fmt.Errorf("SetPriorityClass: %w", err)
+	}
+	if err := windows.SetPriorityClass(windows.CurrentProcess(), windows.PROCESS_MODE_BACKGROUND_BEGIN); err != nil {
+		return fmt.Errorf("SetPriorityClass: %w", err)
+	}
+	return nil
+}
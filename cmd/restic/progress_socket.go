@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/global"
+	"github.com/restic/restic/internal/ui"
+	"github.com/restic/restic/internal/ui/progress"
+)
+
+// openProgressSocket connects to the socket passed via --progress-socket. It
+// returns nil if the option is not set.
+func openProgressSocket(gopts global.Options) (*progress.EventSocket, error) {
+	if gopts.ProgressSocket == "" {
+		return nil, nil
+	}
+	socket, err := progress.DialEventSocket(gopts.ProgressSocket)
+	if err != nil {
+		return nil, errors.Fatalf("%v", err)
+	}
+	return socket, nil
+}
+
+// progressStatusEnabled returns true if status updates are shown on the
+// terminal. Status updates are always sent to the progress socket.
+func progressStatusEnabled(gopts global.Options, term ui.Terminal) bool {
+	return progress.CalculateProgressInterval(!gopts.Quiet, gopts.JSON, term.CanUpdateStatus()) > 0
+}
+
+// printTiming prints the time spent in each phase of a command. The timing is
+// printed as JSON if --json is specified and otherwise only in verbose mode.
+func printTiming(gopts global.Options, timing progress.Timing) {
+	if gopts.JSON {
+		gopts.Term.Print(ui.ToJSONString(timing))
+		return
+	}
+	if gopts.Verbosity < 2 {
+		return
+	}
+
+	width := len("total")
+	for _, p := range timing.Phases {
+		width = max(width, len(p.Phase))
+	}
+
+	gopts.Term.Print("\ntime spent per phase:")
+	for _, p := range timing.Phases {
+		gopts.Term.Print(fmt.Sprintf("  %-*s  %s", width, p.Phase, ui.FormatSeconds(uint64(p.Seconds))))
+	}
+	gopts.Term.Print(fmt.Sprintf("  %-*s  %s", width, "total", ui.FormatSeconds(uint64(timing.TotalSeconds))))
+}
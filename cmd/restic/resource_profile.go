@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"runtime"
+	godebug "runtime/debug"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/global"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/repository/index"
+	"github.com/restic/restic/internal/ui"
+)
+
+// resourceProfile limits the CPU and memory usage of a backup.
+type resourceProfile struct {
+	// maxProcs returns the number of CPU cores to use, zero keeps the default
+	maxProcs func(numCPU int) int
+	// readConcurrency is the number of files which are read concurrently
+	readConcurrency uint
+	// memoryLimit is the soft memory limit of the Go runtime in bytes
+	memoryLimit int64
+	// compression is used unless a compression mode was set explicitly
+	compression repository.CompressionMode
+	// lowMemoryIndex stores the index in memory-mapped files if supported
+	lowMemoryIndex bool
+	idlePriority   bool
+}
+
+var resourceProfiles = map[string]resourceProfile{
+	"low": {
+		maxProcs:        func(int) int { return 1 },
+		readConcurrency: 1,
+		memoryLimit:     512 * 1024 * 1024,
+		compression:     repository.CompressionFastest,
+		lowMemoryIndex:  true,
+		idlePriority:    true,
+	},
+	"medium": {
+		maxProcs:        func(numCPU int) int { return max(1, numCPU/2) },
+		readConcurrency: 2,
+		memoryLimit:     2 * 1024 * 1024 * 1024,
+	},
+	"high": {},
+}
+
+func parseResourceProfile(s string) (resourceProfile, error) {
+	p, ok := resourceProfiles[s]
+	if !ok {
+		return resourceProfile{}, errors.Fatalf("invalid resource profile %q, must be one of low, medium or high", s)
+	}
+	return p, nil
+}
+
+// apply configures the Go runtime and the backup according to the profile.
+// Settings which were explicitly configured by the user, either using flags
+// or environment variables, take precedence. The returned function restores
+// the previous runtime settings, the process priority cannot be restored.
+func (p resourceProfile) apply(opts *BackupOptions, gopts *global.Options, warnf func(msg string, args ...interface{})) (restore func()) {
+	var restores []func()
+	restore = func() {
+		for i := len(restores) - 1; i >= 0; i-- {
+			restores[i]()
+		}
+	}
+
+	if p.maxProcs != nil && os.Getenv("GOMAXPROCS") == "" {
+		old := runtime.GOMAXPROCS(p.maxProcs(runtime.NumCPU()))
+		restores = append(restores, func() { runtime.GOMAXPROCS(old) })
+	}
+	if p.memoryLimit != 0 && os.Getenv("GOMEMLIMIT") == "" {
+		old := godebug.SetMemoryLimit(p.memoryLimit)
+		restores = append(restores, func() { godebug.SetMemoryLimit(old) })
+	}
+	if p.readConcurrency != 0 && opts.ReadConcurrency == 0 {
+		opts.ReadConcurrency = p.readConcurrency
+	}
+	if p.compression != repository.CompressionAuto && gopts.Compression == repository.CompressionAuto {
+		gopts.Compression = p.compression
+	}
+	if p.lowMemoryIndex && index.MappedIndexSupported {
+		gopts.LowMemoryIndex = true
+	}
+	if p.idlePriority {
+		if err := setIdlePriority(); err != nil {
+			warnf("lowering the priority failed: %v\n", err)
+		}
+	}
+	return restore
+}
+
+// formatResourceLimits describes the effective limits of the current process.
+func formatResourceLimits() string {
+	limit := "unlimited"
+	if l := godebug.SetMemoryLimit(-1); l != math.MaxInt64 {
+		limit = ui.FormatBytes(uint64(l))
+	}
+	return fmt.Sprintf("%d CPU cores, memory limit %v", runtime.GOMAXPROCS(0), limit)
+}
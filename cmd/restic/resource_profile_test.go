@@ -0,0 +1,77 @@
+package main
+
+import (
+	"runtime"
+	godebug "runtime/debug"
+	"testing"
+
+	"github.com/restic/restic/internal/global"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/repository/index"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestParseResourceProfile(t *testing.T) {
+	for _, name := range []string{"low", "medium", "high"} {
+		_, err := parseResourceProfile(name)
+		rtest.OK(t, err)
+	}
+	_, err := parseResourceProfile("extreme")
+	rtest.Assert(t, err != nil, "expected error for invalid profile")
+}
+
+func TestResourceProfileApply(t *testing.T) {
+	t.Setenv("GOMAXPROCS", "")
+	t.Setenv("GOMEMLIMIT", "")
+
+	oldProcs := runtime.GOMAXPROCS(0)
+	oldLimit := godebug.SetMemoryLimit(-1)
+
+	// the low profile without changing the priority of the test process
+	profile := resourceProfiles["low"]
+	profile.idlePriority = false
+
+	opts := BackupOptions{}
+	gopts := global.Options{}
+	restore := profile.apply(&opts, &gopts, t.Errorf)
+	rtest.Equals(t, 1, runtime.GOMAXPROCS(0))
+	rtest.Equals(t, int64(512*1024*1024), godebug.SetMemoryLimit(-1))
+	rtest.Equals(t, uint(1), opts.ReadConcurrency)
+	rtest.Equals(t, repository.CompressionFastest, gopts.Compression)
+	rtest.Equals(t, index.MappedIndexSupported, gopts.LowMemoryIndex)
+
+	restore()
+	rtest.Equals(t, oldProcs, runtime.GOMAXPROCS(0))
+	rtest.Equals(t, oldLimit, godebug.SetMemoryLimit(-1))
+
+	// explicit settings take precedence
+	opts = BackupOptions{ReadConcurrency: 5}
+	gopts = global.Options{Compression: repository.CompressionMax}
+	restore = profile.apply(&opts, &gopts, t.Errorf)
+	rtest.Equals(t, uint(5), opts.ReadConcurrency)
+	rtest.Equals(t, repository.CompressionMax, gopts.Compression)
+	restore()
+
+	// the high profile changes nothing
+	opts = BackupOptions{}
+	gopts = global.Options{}
+	restore = resourceProfiles["high"].apply(&opts, &gopts, t.Errorf)
+	rtest.Equals(t, oldProcs, runtime.GOMAXPROCS(0))
+	rtest.Equals(t, BackupOptions{}, opts)
+	restore()
+}
+
+func TestBackupResourceProfile(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	oldProcs := runtime.GOMAXPROCS(0)
+	testSetupBackupData(t, env)
+	testRunBackup(t, "", []string{env.testdata}, BackupOptions{ResourceProfile: "medium"}, env.gopts)
+	testListSnapshots(t, env.gopts, 1)
+	testRunCheck(t, env.gopts)
+	rtest.Equals(t, oldProcs, runtime.GOMAXPROCS(0))
+
+	err := testRunBackupAssumeFailure(t, "", []string{env.testdata}, BackupOptions{ResourceProfile: "foo"}, env.gopts)
+	rtest.Assert(t, err != nil, "expected error for invalid resource profile")
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path"
 	"runtime"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
@@ -78,6 +79,7 @@ type archiverRepo interface {
 	restic.SaverUnpacked[restic.WriteableFileType]
 
 	ChunkerFactory() restic.ChunkerFactory
+	HasBlob(bh restic.BlobHandle) bool
 }
 
 // Archiver saves a directory structure to the repo.
@@ -100,6 +102,13 @@ type Archiver struct {
 	treeSaver *treeSaver
 	mu        sync.Mutex
 	summary   *Summary
+	changes   *fs.ChangeSet
+	// absolute paths of items with errors, only collected for ChangeJournal
+	errorPaths []string
+
+	// walkers limits the number of additional goroutines which walk the
+	// targets, nil if they are walked sequentially
+	walkers chan struct{}
 
 	// Error is called for all errors that occur during backup.
 	Error ErrorFunc
@@ -130,6 +139,19 @@ type Archiver struct {
 	// Flags controlling change detection. See doc/040_backup.rst for details.
 	ChangeIgnoreFlags uint
 
+	// ChangeJournal is used to skip directories which have not changed since
+	// the parent snapshot. If it is nil, all directories are scanned.
+	ChangeJournal fs.ChangeJournal
+
+	// Fingerprints is used to detect unchanged files by their content if their
+	// metadata has changed. If it is nil, only the metadata is checked.
+	Fingerprints *FingerprintCache
+
+	// FollowSymlinks configures which symlinks are followed instead of being
+	// stored as links. Each symlinked directory is only descended into once.
+	FollowSymlinks FollowSymlinksMode
+	symlinks       *symlinkTracker
+
 	// for excluded items
 	ExcludedItem func(path string)
 }
@@ -150,6 +172,26 @@ type Options struct {
 	// SaveTreeConcurrency sets how many trees are marshalled and saved to the
 	// repo concurrently.
 	SaveTreeConcurrency uint
+
+	// ScanConcurrency sets how many files and directories are inspected
+	// concurrently while walking the targets. If it's zero or one, the targets
+	// are walked sequentially. This is also the case if symlinks are followed,
+	// as symlink cycles are detected using the directories which are currently
+	// being walked.
+	ScanConcurrency uint
+
+	// CompressionPolicy selects the compression level for the content of
+	// each file. If it's nil, the compression mode of the repository is used.
+	CompressionPolicy *CompressionPolicy
+
+	// ReadSpecial stores the content of block devices as regular files with
+	// the size of the device instead of only saving the device node.
+	ReadSpecial bool
+
+	// SkipZeroBlocks reuses the ID of blobs which only contain zero bytes
+	// without hashing and uploading them again. This speeds up saving disk
+	// images and sparse files.
+	SkipZeroBlocks bool
 }
 
 // applyDefaults returns a copy of o with the default options set for all unset
@@ -187,6 +229,8 @@ func New(repo archiverRepo, filesystem fs.FS, opts Options) *Archiver {
 		StartFile:    func(string) {},
 		CompleteBlob: func(uint64) {},
 		ExcludedItem: func(string) {},
+
+		symlinks: newSymlinkTracker(),
 	}
 
 	return arch
@@ -207,6 +251,10 @@ func (arch *Archiver) error(item string, err error) error {
 		err = fmt.Errorf("%v: %w", item, err)
 	}
 
+	if arch.ChangeJournal != nil {
+		arch.recordErrorPath(item)
+	}
+
 	errf := arch.Error(item, err)
 	if err != errf {
 		debug.Log("item %v: error was filtered by handler, before: %q, after: %v", item, err, errf)
@@ -214,6 +262,19 @@ func (arch *Archiver) error(item string, err error) error {
 	return errf
 }
 
+// recordErrorPath remembers that item could not be saved completely. The next
+// backup must not reuse the subtree from this snapshot for it.
+func (arch *Archiver) recordErrorPath(item string) {
+	abs, err := arch.FS.Abs(item)
+	if err != nil {
+		abs = item
+	}
+
+	arch.mu.Lock()
+	defer arch.mu.Unlock()
+	arch.errorPaths = append(arch.errorPaths, abs)
+}
+
 func (arch *Archiver) trackItem(item string, previous, current *data.Node, s ItemStats, d time.Duration) {
 	action := itemProgressAction(previous, current)
 	arch.CompleteItem(item, action, s, d)
@@ -314,24 +375,30 @@ func (arch *Archiver) saveDir(ctx context.Context, snPath string, dir string, me
 		return futureNode{}, err
 	}
 
-	nodes := make([]futureNode, 0, len(names))
-
 	finder := data.NewTreeFinder(previous)
 	defer finder.Close()
 
-	var lastExcluded string
+	// entries are saved concurrently if walkers are available, the results
+	// are collected in the order of names
+	results := make([]dirEntryResult, len(names))
+	var wg sync.WaitGroup
+	defer wg.Wait()
 
-	for _, name := range names {
+	for i, name := range names {
 		// test if context has been cancelled
 		if ctx.Err() != nil {
 			debug.Log("context has been cancelled, aborting")
 			return futureNode{}, ctx.Err()
 		}
 
-		if name == lastExcluded {
+		if i > 0 && name == names[i-1] {
 			// Skip duplicate directory entry if it was already excluded.
 			// This avoids printing errors about duplicate directory entries even though the entry in question is ignored.
-			continue
+			wg.Wait()
+			if results[i-1].excluded {
+				results[i].excluded = true
+				continue
+			}
 		}
 
 		pathname := arch.FS.Join(dir, name)
@@ -341,25 +408,34 @@ func (arch *Archiver) saveDir(ctx context.Context, snPath string, dir string, me
 			return futureNode{}, err
 		}
 		snItem := join(snPath, name)
-		fn, excluded, err := arch.save(ctx, snItem, pathname, oldNode, false)
 
-		// return error early if possible
-		if err != nil {
-			err = arch.error(pathname, err)
-			if err == nil {
-				// ignore error
-				continue
-			}
+		res := &results[i]
+		res.pathname = pathname
+		save := func() {
+			res.fn, res.excluded, res.err = arch.save(ctx, snItem, pathname, oldNode, false)
+		}
+		if arch.tryStartWalker(&wg, save) {
+			continue
+		}
+		save()
 
+		// return error early if possible
+		if err := arch.filterEntryError(res); err != nil {
 			return futureNode{}, err
 		}
+	}
+	wg.Wait()
 
-		if excluded {
-			lastExcluded = name
+	nodes := make([]futureNode, 0, len(names))
+	for i := range results {
+		res := &results[i]
+		if err := arch.filterEntryError(res); err != nil {
+			return futureNode{}, err
+		}
+		if res.excluded || res.ignored {
 			continue
 		}
-
-		nodes = append(nodes, fn)
+		nodes = append(nodes, res.fn)
 	}
 
 	fn := arch.treeSaver.Save(ctx, snPath, dir, treeNode, nodes, complete)
@@ -367,6 +443,51 @@ func (arch *Archiver) saveDir(ctx context.Context, snPath string, dir string, me
 	return fn, nil
 }
 
+// dirEntryResult is the result of saving an entry of a directory.
+type dirEntryResult struct {
+	pathname string
+	fn       futureNode
+	excluded bool
+	ignored  bool
+	err      error
+}
+
+// filterEntryError passes the error of res to arch.error, it only returns an
+// error if it is fatal. Entries whose error was ignored are marked as such.
+func (arch *Archiver) filterEntryError(res *dirEntryResult) error {
+	if res.err == nil {
+		return nil
+	}
+	err := arch.error(res.pathname, res.err)
+	res.err = nil
+	if err != nil {
+		return err
+	}
+	res.ignored = true
+	return nil
+}
+
+// tryStartWalker runs fn in a new goroutine which is tracked by wg if the
+// limit of walkers allows it. It returns false if fn was not started.
+func (arch *Archiver) tryStartWalker(wg *sync.WaitGroup, fn func()) bool {
+	if arch.walkers == nil {
+		return false
+	}
+	select {
+	case arch.walkers <- struct{}{}:
+	default:
+		return false
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer func() { <-arch.walkers }()
+		fn()
+	}()
+	return true
+}
+
 func (arch *Archiver) dirToNodeAndEntries(snPath, dir string, meta fs.File) (node *data.Node, names []string, err error) {
 	err = meta.MakeReadable()
 	if err != nil {
@@ -443,7 +564,7 @@ func (fn *futureNode) take(ctx context.Context) futureNodeResult {
 func (arch *Archiver) allBlobsPresent(previous *data.Node) bool {
 	// check if all blobs are contained in index
 	for _, id := range previous.Content {
-		if _, ok := arch.Repo.LookupBlobSize(restic.BlobHandle{Type: restic.DataBlob, ID: id}); !ok {
+		if !arch.Repo.HasBlob(restic.BlobHandle{Type: restic.DataBlob, ID: id}) {
 			return false
 		}
 	}
@@ -518,6 +639,19 @@ func (arch *Archiver) save(ctx context.Context, snPath, target string, previous
 		return futureNode{}, true, nil
 	}
 
+	if fi.Mode&os.ModeSymlink != 0 && arch.FollowSymlinks != FollowSymlinksNone {
+		if linkMeta, linkFi, ok := arch.followSymlink(target); ok {
+			debug.Log("  %v following symlink", target)
+			_ = meta.Close()
+			meta, fi = linkMeta, linkFi
+			if !explicit && !arch.Select(abstarget, fi, arch.FS) {
+				debug.Log("%v is excluded", target)
+				arch.ExcludedItem(abstarget)
+				return futureNode{}, true, nil
+			}
+		}
+	}
+
 	switch {
 	case fi.Mode.IsRegular():
 		debug.Log("  %v regular file", target)
@@ -536,6 +670,9 @@ func (arch *Archiver) save(ctx context.Context, snPath, target string, previous
 
 				// copy list of blobs
 				node.Content = previous.Content
+				if arch.Fingerprints != nil {
+					arch.Fingerprints.keep(snPath, previous)
+				}
 
 				fn = newFutureNodeWithResult(futureNodeResult{
 					snPath: snPath,
@@ -576,8 +713,14 @@ func (arch *Archiver) save(ctx context.Context, snPath, target string, previous
 
 		closeFile = false
 
+		var candidate *fingerprintCandidate
+		if arch.Fingerprints != nil && previous != nil && previous.Type == data.NodeTypeFile &&
+			previous.Size == uint64(fi.Size) && arch.allBlobsPresent(previous) {
+			candidate = arch.Fingerprints.candidate(snPath, previous)
+		}
+
 		// Save will close the file, we don't need to do that
-		fn = arch.fileSaver.Save(ctx, snPath, target, meta, func() {
+		fn = arch.fileSaver.Save(ctx, snPath, target, meta, candidate, func() {
 			arch.StartFile(snPath)
 		}, func() {
 			arch.trackItem(snPath, nil, nil, ItemStats{}, 0)
@@ -589,6 +732,18 @@ func (arch *Archiver) save(ctx context.Context, snPath, target string, previous
 		debug.Log("  %v dir", target)
 
 		snItem := snPath + "/"
+		if arch.dirUnchanged(abstarget, fi, previous) {
+			debug.Log("%v has not changed according to the change journal, using old subtree", target)
+			node := *previous
+			arch.trackItem(snItem, previous, &node, ItemStats{}, time.Since(start))
+			fn = newFutureNodeWithResult(futureNodeResult{
+				snPath: snPath,
+				target: target,
+				node:   &node,
+			})
+			return fn, false, nil
+		}
+
 		oldSubtree, err := arch.loadSubtree(ctx, previous)
 		if err != nil {
 			err = arch.error(abstarget, err)
@@ -597,10 +752,16 @@ func (arch *Archiver) save(ctx context.Context, snPath, target string, previous
 			return futureNode{}, false, err
 		}
 
+		if arch.FollowSymlinks != FollowSymlinksNone {
+			arch.symlinks.enter(fi)
+		}
 		fn, err = arch.saveDir(ctx, snPath, target, meta, oldSubtree,
 			func(node *data.Node, stats ItemStats) {
 				arch.trackItem(snItem, previous, node, stats, time.Since(start))
 			})
+		if arch.FollowSymlinks != FollowSymlinksNone {
+			arch.symlinks.leave(fi)
+		}
 		if err != nil {
 			debug.Log("SaveDir for %v returned error: %v", snPath, err)
 			return futureNode{}, false, err
@@ -610,6 +771,31 @@ func (arch *Archiver) save(ctx context.Context, snPath, target string, previous
 		debug.Log("  %v is a socket, ignoring", target)
 		return futureNode{}, true, nil
 
+	case arch.Options.ReadSpecial && fs.IsBlockDevice(fi.Mode):
+		debug.Log("  %v block device", target)
+
+		size, err := fs.DeviceSize(target)
+		if err != nil {
+			debug.Log("DeviceSize() for %v returned error: %v", target, err)
+			return filterError(err)
+		}
+
+		err = meta.MakeReadable()
+		if err != nil {
+			debug.Log("MakeReadable() for %v returned error: %v", target, err)
+			return filterError(err)
+		}
+		closeFile = false
+
+		// Save will close the file, we don't need to do that
+		fn = arch.fileSaver.Save(ctx, snPath, target, newDeviceFile(meta, size), nil, func() {
+			arch.StartFile(snPath)
+		}, func() {
+			arch.trackItem(snPath, nil, nil, ItemStats{}, 0)
+		}, func(node *data.Node, stats ItemStats) {
+			arch.trackItem(snPath, previous, node, stats, time.Since(start))
+		})
+
 	default:
 		debug.Log("  %v other", target)
 
@@ -629,6 +815,19 @@ func (arch *Archiver) save(ctx context.Context, snPath, target string, previous
 	return fn, false, nil
 }
 
+// dirUnchanged returns whether the subtree of the directory previous from the
+// parent snapshot can be reused, as the change journal did not report any
+// changes below the directory.
+func (arch *Archiver) dirUnchanged(abstarget string, fi *fs.ExtendedFileInfo, previous *data.Node) bool {
+	if previous == nil || previous.Type != data.NodeTypeDir || previous.Subtree == nil {
+		return false
+	}
+	if !fi.ModTime.Equal(previous.ModTime) || !arch.changes.Unchanged(abstarget) {
+		return false
+	}
+	return arch.Repo.HasBlob(restic.BlobHandle{Type: restic.TreeBlob, ID: *previous.Subtree})
+}
+
 // fileChanged tries to detect whether a file's content has changed compared
 // to the contents of node, which describes the same path in the parent backup.
 // It should only be run for regular files.
@@ -829,6 +1028,7 @@ func resolveRelativeTargets(filesys fs.FS, targets []string) ([]backupTarget, er
 // SnapshotOptions collect attributes for a new snapshot.
 type SnapshotOptions struct {
 	Tags           data.TagList
+	Labels         data.Labels
 	Hostname       string
 	Excludes       []string
 	BackupStart    time.Time
@@ -837,6 +1037,83 @@ type SnapshotOptions struct {
 	ProgramVersion string
 	// SkipIfUnchanged omits the snapshot creation if it is identical to the parent snapshot.
 	SkipIfUnchanged bool
+	// Filesystems contains the filesystem type for each absolute target path.
+	Filesystems map[string]string
+	// FilesystemSnapshots returns the btrfs or ZFS snapshots the files were
+	// read from, it is called after all files were read.
+	FilesystemSnapshots func() []data.FilesystemSnapshot
+	// BeforeSave is called after all files were read, before the snapshot is
+	// saved. It can add metadata to the snapshot. If it returns an error, the
+	// snapshot is not saved.
+	BeforeSave func(sn *data.Snapshot) error
+	// Selection identifies all options which select the files of the backup,
+	// such as exclude patterns and size limits. The change journal is only
+	// used if the parent snapshot was created using the same selection.
+	Selection string
+	// InheritedExcludeFiles lists the names of files in the backup whose
+	// exclude rules also apply to subdirectories, such as ignore files.
+	InheritedExcludeFiles []string
+}
+
+// readChangeJournal returns the current position of the change journal for
+// each target and collects the changes since the parent snapshot. This is only
+// possible if the parent snapshot was created using the same selection of
+// files. Items which could not be saved by the parent snapshot are considered
+// changed. Errors are not fatal, the affected targets are scanned completely
+// instead.
+func (arch *Archiver) readChangeJournal(targets []string, opts SnapshotOptions) map[string]string {
+	arch.errorPaths = nil
+	if arch.ChangeJournal == nil {
+		return nil
+	}
+
+	parent := opts.ParentSnapshot
+	useParent := parent != nil && slices.Equal(parent.Excludes, opts.Excludes) &&
+		parent.ChangeJournalSelection == opts.Selection
+
+	arch.changes = fs.NewChangeSet()
+	for _, name := range opts.InheritedExcludeFiles {
+		arch.changes.AddInheritedName(name)
+	}
+	if useParent {
+		for _, item := range parent.ChangeJournalErrors {
+			arch.changes.AddRecursive(item)
+		}
+	}
+	positions := make(map[string]string)
+	for _, target := range targets {
+		abstarget, err := arch.FS.Abs(target)
+		if err != nil {
+			debug.Log("unable to determine absolute path of %v: %v", target, err)
+			continue
+		}
+
+		// the position must be determined before scanning the target
+		pos, err := arch.ChangeJournal.Position(abstarget)
+		if err != nil {
+			debug.Log("unable to determine change journal position for %v: %v", abstarget, err)
+			continue
+		}
+		positions[abstarget] = pos
+
+		since, ok := "", false
+		if useParent {
+			since, ok = parent.ChangeJournal[abstarget]
+		}
+		if !ok {
+			continue
+		}
+
+		err = arch.ChangeJournal.Changes(abstarget, since, arch.changes)
+		if err != nil {
+			debug.Log("unable to read changes for %v since %v: %v", abstarget, since, err)
+		}
+	}
+
+	if len(positions) == 0 {
+		return nil
+	}
+	return positions
 }
 
 // loadParentTree loads a tree referenced by snapshot id. If id is null, nil is returned.
@@ -868,8 +1145,18 @@ func (arch *Archiver) runWorkers(ctx context.Context, wg *errgroup.Group, upload
 		arch.Options.ReadConcurrency)
 	arch.fileSaver.CompleteBlob = arch.CompleteBlob
 	arch.fileSaver.NodeFromFileInfo = arch.nodeFromFileInfo
+	arch.fileSaver.compressionPolicy = arch.Options.CompressionPolicy
+	arch.fileSaver.fingerprints = arch.Fingerprints
+	if arch.Options.SkipZeroBlocks {
+		arch.fileSaver.zeroBlobs = newZeroBlobCache()
+	}
 
 	arch.treeSaver = newTreeSaver(ctx, wg, arch.Options.SaveTreeConcurrency, uploader, arch.Error)
+
+	arch.walkers = nil
+	if arch.Options.ScanConcurrency > 1 && arch.FollowSymlinks == FollowSymlinksNone {
+		arch.walkers = make(chan struct{}, arch.Options.ScanConcurrency-1)
+	}
 }
 
 func (arch *Archiver) stopWorkers() {
@@ -884,11 +1171,16 @@ func (arch *Archiver) Snapshot(ctx context.Context, targets []string, opts Snaps
 	arch.summary = &Summary{
 		BackupStart: opts.BackupStart,
 	}
+	arch.symlinks = newSymlinkTracker()
+	journalPositions := arch.readChangeJournal(targets, opts)
 
 	cleanTargets, err := resolveRelativeTargets(arch.FS, targets)
 	if err != nil {
 		return nil, restic.ID{}, nil, err
 	}
+	if arch.FollowSymlinks != FollowSymlinksNone {
+		arch.enterTargetParents(cleanTargets)
+	}
 
 	atree, err := newTree(arch.FS, cleanTargets)
 	if err != nil {
@@ -957,7 +1249,17 @@ func (arch *Archiver) Snapshot(ctx context.Context, targets []string, opts Snaps
 	}
 
 	sn.ProgramVersion = opts.ProgramVersion
+	sn.Labels = opts.Labels
 	sn.Excludes = opts.Excludes
+	sn.ChangeJournal = journalPositions
+	if journalPositions != nil {
+		sn.ChangeJournalSelection = opts.Selection
+		sn.ChangeJournalErrors = arch.errorPaths
+	}
+	sn.Filesystems = opts.Filesystems
+	if opts.FilesystemSnapshots != nil {
+		sn.FilesystemSnapshots = opts.FilesystemSnapshots()
+	}
 	if opts.ParentSnapshot != nil {
 		sn.Parent = opts.ParentSnapshot.ID()
 	}
@@ -981,6 +1283,12 @@ func (arch *Archiver) Snapshot(ctx context.Context, targets []string, opts Snaps
 		TotalBytesProcessed: arch.summary.ProcessedBytes,
 	}
 
+	if opts.BeforeSave != nil {
+		if err := opts.BeforeSave(sn); err != nil {
+			return nil, restic.ID{}, nil, err
+		}
+	}
+
 	id, err := data.SaveSnapshot(ctx, arch.Repo, sn)
 	if err != nil {
 		return nil, restic.ID{}, nil, err
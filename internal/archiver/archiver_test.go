@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -82,7 +83,7 @@ func saveFile(t testing.TB, repo archiverRepo, filename string, filesystem fs.FS
 			t.Fatal(err)
 		}
 
-		res := arch.fileSaver.Save(ctx, "/", filename, file, start, completeReading, complete)
+		res := arch.fileSaver.Save(ctx, "/", filename, file, nil, start, completeReading, complete)
 
 		fnr = res.take(ctx)
 		if fnr.err != nil {
@@ -408,6 +409,30 @@ func BenchmarkArchiverSaveFileLarge(b *testing.B) {
 	}
 }
 
+func BenchmarkArchiverSmallFiles(b *testing.B) {
+	const numFiles = 1000
+	src := TestDir{}
+	for i := 0; i < numFiles; i++ {
+		src[fmt.Sprintf("file-%04d", i)] = TestFile{Content: string(rtest.Random(i, 1024))}
+	}
+	tempdir := rtest.TempDir(b)
+	TestCreateFiles(b, tempdir, src)
+	back := rtest.Chdir(b, tempdir)
+	defer back()
+
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		repo := repository.TestRepository(b)
+		arch := New(repo, fs.Track{FS: fs.NewLocal()}, Options{})
+		b.StartTimer()
+
+		_, _, _, err := arch.Snapshot(context.TODO(), []string{"."}, SnapshotOptions{Time: time.Now()})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
 type blobCountingRepo struct {
 	archiverRepo
 
@@ -1576,6 +1601,49 @@ func TestResolveRelativeTargetsSpecial(t *testing.T) {
 	}
 }
 
+func TestArchiverScanConcurrency(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	src := TestDir{}
+	for i := 0; i < 5; i++ {
+		dir := TestDir{}
+		for j := 0; j < 20; j++ {
+			dir[fmt.Sprintf("file%02d", j)] = TestFile{Content: fmt.Sprintf("file %d in dir %d", j, i)}
+			dir[fmt.Sprintf("file%02d.txt", j)] = TestFile{Content: "excluded"}
+		}
+		dir["subdir"] = TestDir{
+			"file":     TestFile{Content: fmt.Sprintf("file in subdir %d", i)},
+			"file.txt": TestFile{Content: "excluded"},
+		}
+		src[fmt.Sprintf("dir%d", i)] = dir
+	}
+	tempdir, repo := prepareTempdirRepoSrc(t, src)
+	back := rtest.Chdir(t, tempdir)
+	defer back()
+
+	var trees []restic.ID
+	for _, concurrency := range []uint{1, 8} {
+		var excluded atomic.Int64
+		arch := New(repo, fs.Track{FS: fs.NewLocal()}, Options{ScanConcurrency: concurrency})
+		arch.Select = func(item string, fi *fs.ExtendedFileInfo, fs fs.FS) bool {
+			if filepath.Ext(item) == ".txt" {
+				excluded.Add(1)
+				return false
+			}
+			return true
+		}
+		sn, _, _, err := arch.Snapshot(ctx, []string{"."}, SnapshotOptions{Time: time.Now()})
+		rtest.OK(t, err)
+		rtest.Equals(t, int64(5*21), excluded.Load())
+		trees = append(trees, *sn.Tree)
+	}
+
+	// walking the targets concurrently must result in the same snapshot
+	rtest.Equals(t, trees[0], trees[1])
+	checker.TestCheckRepo(t, repo)
+}
+
 func TestArchiverSnapshotSelect(t *testing.T) {
 	var tests = []struct {
 		name  string
@@ -2039,6 +2107,162 @@ func TestArchiverParent(t *testing.T) {
 	}
 }
 
+// fakeChangeJournal reports the paths in changed relative to the root.
+type fakeChangeJournal struct {
+	position string
+	changed  []string
+}
+
+func (j *fakeChangeJournal) Position(_ string) (string, error) {
+	return j.position, nil
+}
+
+func (j *fakeChangeJournal) Changes(root string, since string, changes *fs.ChangeSet) error {
+	if since != "first" {
+		return fs.ErrChangeJournalPositionInvalid
+	}
+	for _, p := range j.changed {
+		changes.Add(filepath.Join(root, p))
+	}
+	changes.AddRoot(root)
+	return nil
+}
+
+func TestArchiverChangeJournal(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tempdir, repo := prepareTempdirRepoSrc(t, TestDir{
+		"changed": TestDir{
+			"file": TestFile{Content: "foo"},
+		},
+		"unchanged": TestDir{
+			"file": TestFile{Content: "bar"},
+		},
+	})
+	back := rtest.Chdir(t, tempdir)
+	defer back()
+
+	journal := &fakeChangeJournal{position: "first"}
+	arch := New(repo, fs.Track{FS: fs.NewLocal()}, Options{})
+	arch.ChangeJournal = journal
+
+	first, _, _, err := arch.Snapshot(ctx, []string{"."}, SnapshotOptions{Time: time.Now()})
+	rtest.OK(t, err)
+	abstarget, err := filepath.Abs(".")
+	rtest.OK(t, err)
+	rtest.Equals(t, map[string]string{abstarget: "first"}, first.ChangeJournal)
+
+	// add a file to both directories, but only report one of them as changed
+	for _, dir := range []string{"changed", "unchanged"} {
+		fi, err := os.Stat(dir)
+		rtest.OK(t, err)
+		save(t, filepath.Join(dir, "new"), []byte("new"))
+		rtest.OK(t, os.Chtimes(dir, fi.ModTime(), fi.ModTime()))
+	}
+	journal.position = "second"
+	journal.changed = []string{filepath.Join("changed", "new")}
+
+	second, _, summary, err := arch.Snapshot(ctx, []string{"."}, SnapshotOptions{Time: time.Now(), ParentSnapshot: first})
+	rtest.OK(t, err)
+	rtest.Equals(t, map[string]string{abstarget: "second"}, second.ChangeJournal)
+	rtest.Equals(t, ChangeStats{1, 0, 1}, summary.Files)
+
+	listDir := func(sn *data.Snapshot, dir string) []string {
+		id, err := data.FindTreeDirectory(ctx, repo, sn.Tree, dir)
+		rtest.OK(t, err)
+		var names []string
+		tree, err := data.LoadTree(ctx, repo, *id)
+		rtest.OK(t, err)
+		for item := range tree {
+			rtest.OK(t, item.Error)
+			names = append(names, item.Node.Name)
+		}
+		return names
+	}
+	rtest.Equals(t, []string{"file", "new"}, listDir(second, "changed"))
+	// the unreported change is missed as the directory was not scanned
+	rtest.Equals(t, []string{"file"}, listDir(second, "unchanged"))
+
+	// changing the excludes requires a full scan
+	third, _, _, err := arch.Snapshot(ctx, []string{"."}, SnapshotOptions{Time: time.Now(), ParentSnapshot: second, Excludes: []string{"foo"}})
+	rtest.OK(t, err)
+	rtest.Equals(t, []string{"file", "new"}, listDir(third, "unchanged"))
+
+	// as does changing other options which select files
+	fi, err := os.Stat("unchanged")
+	rtest.OK(t, err)
+	save(t, filepath.Join("unchanged", "other"), []byte("other"))
+	rtest.OK(t, os.Chtimes("unchanged", fi.ModTime(), fi.ModTime()))
+	journal.changed = nil
+	fourth, _, _, err := arch.Snapshot(ctx, []string{"."}, SnapshotOptions{Time: time.Now(), ParentSnapshot: third, Excludes: []string{"foo"}, Selection: "other"})
+	rtest.OK(t, err)
+	rtest.Equals(t, "other", fourth.ChangeJournalSelection)
+	rtest.Equals(t, []string{"file", "new", "other"}, listDir(fourth, "unchanged"))
+}
+
+// failOpenFS fails to open files with the given base name.
+type failOpenFS struct {
+	fs.FS
+	name string
+}
+
+func (m *failOpenFS) OpenFile(name string, flag int, metadataOnly bool) (fs.File, error) {
+	if filepath.Base(name) == m.name {
+		return nil, fmt.Errorf("open %v: injected error", name)
+	}
+	return m.FS.OpenFile(name, flag, metadataOnly)
+}
+
+func TestArchiverChangeJournalErrors(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tempdir, repo := prepareTempdirRepoSrc(t, TestDir{
+		"dir": TestDir{
+			"sub": TestDir{
+				"broken": TestFile{Content: "foo"},
+				"file":   TestFile{Content: "bar"},
+			},
+		},
+	})
+	back := rtest.Chdir(t, tempdir)
+	defer back()
+
+	journal := &fakeChangeJournal{position: "first"}
+	arch := New(repo, &failOpenFS{FS: fs.NewLocal(), name: "broken"}, Options{})
+	arch.ChangeJournal = journal
+	arch.Error = func(item string, err error) error {
+		t.Logf("ignoring error for %v: %v", item, err)
+		return nil
+	}
+
+	first, _, _, err := arch.Snapshot(ctx, []string{"."}, SnapshotOptions{Time: time.Now()})
+	rtest.OK(t, err)
+	broken, err := filepath.Abs(filepath.Join("dir", "sub", "broken"))
+	rtest.OK(t, err)
+	rtest.Assert(t, slices.Contains(first.ChangeJournalErrors, broken), "error for %v not recorded in %v", broken, first.ChangeJournalErrors)
+
+	// nothing has changed, but the subtree with the error must not be reused
+	journal.position = "second"
+	arch = New(repo, fs.NewLocal(), Options{})
+	arch.ChangeJournal = journal
+	second, _, _, err := arch.Snapshot(ctx, []string{"."}, SnapshotOptions{Time: time.Now(), ParentSnapshot: first})
+	rtest.OK(t, err)
+	rtest.Equals(t, 0, len(second.ChangeJournalErrors))
+
+	id, err := data.FindTreeDirectory(ctx, repo, second.Tree, "dir/sub")
+	rtest.OK(t, err)
+	tree, err := data.LoadTree(ctx, repo, *id)
+	rtest.OK(t, err)
+	var names []string
+	for item := range tree {
+		rtest.OK(t, item.Error)
+		names = append(names, item.Node.Name)
+	}
+	rtest.Equals(t, []string{"broken", "file"}, names)
+}
+
 func TestArchiverErrorReporting(t *testing.T) {
 	ignoreErrorForBasename := func(basename string) ErrorFunc {
 		return func(item string, err error) error {
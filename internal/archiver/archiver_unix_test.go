@@ -3,8 +3,11 @@
 package archiver
 
 import (
+	"context"
 	"testing"
+	"time"
 
+	"github.com/restic/restic/internal/checker"
 	"github.com/restic/restic/internal/data"
 	"github.com/restic/restic/internal/feature"
 	"github.com/restic/restic/internal/fs"
@@ -49,3 +52,74 @@ func TestHardlinkMetadata(t *testing.T) {
 	_, node = statAndSnapshot(t, repo, "testdir")
 	rtest.Assert(t, node.DeviceID == 0, "device id mismatch for testdir expected %v got %v", 0, node.DeviceID)
 }
+
+func TestArchiverFollowSymlinks(t *testing.T) {
+	src := TestDir{
+		"data": TestDir{
+			"file": TestFile{Content: "foo"},
+			"sub": TestDir{
+				"other": TestFile{Content: "bar"},
+				"loop":  TestSymlink{Target: "../.."},
+			},
+		},
+		"broken":   TestSymlink{Target: "missing"},
+		"linkdir":  TestSymlink{Target: "data/sub"},
+		"linkdir2": TestSymlink{Target: "data/sub"},
+		"linkfile": TestSymlink{Target: "data/file"},
+	}
+
+	var tests = []struct {
+		mode FollowSymlinksMode
+		want TestDir
+	}{
+		{
+			mode: FollowSymlinksNone,
+			want: src,
+		},
+		{
+			mode: FollowSymlinksDirs,
+			want: TestDir{
+				"data":   src["data"],
+				"broken": TestSymlink{Target: "missing"},
+				// descended only once, the loop back to the root is not followed
+				"linkdir": TestDir{
+					"other": TestFile{Content: "bar"},
+					"loop":  TestSymlink{Target: "../.."},
+				},
+				"linkdir2": TestSymlink{Target: "data/sub"},
+				"linkfile": TestSymlink{Target: "data/file"},
+			},
+		},
+		{
+			mode: FollowSymlinksAll,
+			want: TestDir{
+				"data":   src["data"],
+				"broken": TestSymlink{Target: "missing"},
+				"linkdir": TestDir{
+					"other": TestFile{Content: "bar"},
+					"loop":  TestSymlink{Target: "../.."},
+				},
+				"linkdir2": TestSymlink{Target: "data/sub"},
+				"linkfile": TestFile{Content: "foo"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.mode.String(), func(t *testing.T) {
+			tempdir, repo := prepareTempdirRepoSrc(t, src)
+
+			arch := New(repo, fs.Track{FS: fs.NewLocal()}, Options{})
+			arch.FollowSymlinks = test.mode
+
+			back := rtest.Chdir(t, tempdir)
+			defer back()
+
+			_, snapshotID, _, err := arch.Snapshot(context.TODO(), []string{"."}, SnapshotOptions{Time: time.Now()})
+			rtest.OK(t, err)
+
+			TestEnsureSnapshot(t, repo, snapshotID, test.want)
+			checker.TestCheckRepo(t, repo)
+		})
+	}
+}
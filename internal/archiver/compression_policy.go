@@ -0,0 +1,144 @@
+package archiver
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+)
+
+// BuiltinCompressionPolicy is the name which selects the built-in compression
+// policy instead of loading the policy from a file.
+const BuiltinCompressionPolicy = "builtin"
+
+// builtinCompressionPolicy skips compression for formats which are already
+// compressed and compresses text more thoroughly.
+const builtinCompressionPolicy = `
+# images, audio and video
+off *.jpg *.jpeg *.png *.gif *.webp *.heic *.heif *.avif *.jxl
+off *.mp3 *.aac *.m4a *.ogg *.opus *.flac
+off *.mp4 *.m4v *.mkv *.mov *.avi *.webm *.wmv
+off image/jpeg image/png image/gif image/webp audio/* video/*
+
+# archives and compressed files
+off *.zip *.gz *.tgz *.bz2 *.tbz2 *.xz *.txz *.zst *.lz4 *.lzma *.7z *.rar *.br
+off *.jar *.apk *.docx *.xlsx *.pptx *.odt *.ods *.odp *.epub
+off application/zip application/x-gzip application/x-rar-compressed
+
+# text
+better *.txt *.log *.csv *.tsv *.json *.xml *.html *.htm *.md *.sql *.yaml *.yml
+better text/*
+`
+
+// compressionRule selects level for files whose name or content type matches
+// pattern.
+type compressionRule struct {
+	level   restic.CompressionLevel
+	pattern string
+	// if set, pattern is matched against the content type
+	contentType bool
+}
+
+// CompressionPolicy selects the compression level for the content of a file
+// based on its name and content type. The content type is determined by
+// sniffing the first bytes of the file.
+type CompressionPolicy struct {
+	rules []compressionRule
+}
+
+// ParseCompressionPolicy parses a compression policy from rd. Each line
+// consists of a compression level followed by one or more patterns, empty
+// lines and lines starting with '#' are ignored. Patterns containing a slash
+// match the content type of a file (e.g. "text/*"), all other patterns match
+// the file name (e.g. "*.jpg"), ignoring case. The first matching pattern
+// determines the compression level of a file.
+func ParseCompressionPolicy(rd io.Reader) (*CompressionPolicy, error) {
+	p := &CompressionPolicy{}
+
+	sc := bufio.NewScanner(rd)
+	line := 0
+	for sc.Scan() {
+		line++
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) < 2 {
+			return nil, errors.Errorf("line %d: expected compression level followed by patterns", line)
+		}
+
+		level, err := restic.ParseCompressionLevel(fields[0])
+		if err != nil {
+			return nil, errors.Errorf("line %d: %v", line, err)
+		}
+
+		for _, pattern := range fields[1:] {
+			pattern = strings.ToLower(pattern)
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, errors.Errorf("line %d: invalid pattern %q: %v", line, pattern, err)
+			}
+			p.rules = append(p.rules, compressionRule{
+				level:       level,
+				pattern:     pattern,
+				contentType: strings.Contains(pattern, "/"),
+			})
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// LoadCompressionPolicy loads the compression policy from filename. If
+// filename is BuiltinCompressionPolicy, the built-in policy is returned.
+func LoadCompressionPolicy(filename string) (*CompressionPolicy, error) {
+	if filename == BuiltinCompressionPolicy {
+		return ParseCompressionPolicy(strings.NewReader(builtinCompressionPolicy))
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	p, err := ParseCompressionPolicy(f)
+	if err != nil {
+		return nil, errors.Wrapf(err, "compression policy %v", filename)
+	}
+	return p, nil
+}
+
+// Level returns the compression level for the file filename, whose content
+// starts with head.
+func (p *CompressionPolicy) Level(filename string, head []byte) restic.CompressionLevel {
+	name := strings.ToLower(filepath.Base(filename))
+	contentType := ""
+
+	for _, rule := range p.rules {
+		subject := name
+		if rule.contentType {
+			if contentType == "" {
+				// strip parameters like "; charset=utf-8"
+				contentType, _, _ = strings.Cut(http.DetectContentType(head), ";")
+			}
+			subject = contentType
+		}
+
+		if ok, _ := path.Match(rule.pattern, subject); ok {
+			return rule.level
+		}
+	}
+
+	return restic.CompressionLevelDefault
+}
@@ -0,0 +1,59 @@
+package archiver
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestCompressionPolicy(t *testing.T) {
+	p, err := ParseCompressionPolicy(strings.NewReader(`
+# comment
+off *.JPG image/*
+max *.log
+better text/*
+`))
+	rtest.OK(t, err)
+
+	png := []byte("\x89PNG\x0D\x0A\x1A\x0A\x00\x00\x00\x0DIHDR")
+	for _, test := range []struct {
+		filename string
+		head     []byte
+		level    restic.CompressionLevel
+	}{
+		{"/home/user/photo.jpg", nil, restic.CompressionLevelOff},
+		{"/home/user/PHOTO.Jpg", nil, restic.CompressionLevelOff},
+		{"/home/user/image", png, restic.CompressionLevelOff},
+		{"/var/log/syslog.log", []byte("foo bar"), restic.CompressionLevelMax},
+		{"/home/user/README", []byte("some text\n"), restic.CompressionLevelBetter},
+		{"/home/user/data.bin", []byte{0, 1, 2, 3, 4}, restic.CompressionLevelDefault},
+		{"/home/user/empty", nil, restic.CompressionLevelBetter},
+	} {
+		t.Run(test.filename, func(t *testing.T) {
+			rtest.Equals(t, test.level, p.Level(test.filename, test.head))
+		})
+	}
+}
+
+func TestCompressionPolicyBuiltin(t *testing.T) {
+	p, err := LoadCompressionPolicy(BuiltinCompressionPolicy)
+	rtest.OK(t, err)
+
+	rtest.Equals(t, restic.CompressionLevelOff, p.Level("movie.mkv", nil))
+	rtest.Equals(t, restic.CompressionLevelOff, p.Level("archive", []byte("PK\x03\x04")))
+	rtest.Equals(t, restic.CompressionLevelBetter, p.Level("notes.txt", nil))
+	rtest.Equals(t, restic.CompressionLevelDefault, p.Level("program", []byte{0x7f, 'E', 'L', 'F', 2, 1, 1, 0}))
+}
+
+func TestCompressionPolicyInvalid(t *testing.T) {
+	for _, input := range []string{
+		"off",
+		"fast *.jpg",
+		"off [abc",
+	} {
+		_, err := ParseCompressionPolicy(strings.NewReader(input))
+		rtest.Assert(t, err != nil, "expected error for %q", input)
+	}
+}
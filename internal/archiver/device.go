@@ -0,0 +1,59 @@
+package archiver
+
+import (
+	"bufio"
+	"os"
+
+	"github.com/restic/restic/internal/data"
+	"github.com/restic/restic/internal/fs"
+)
+
+// deviceReadBufSize is the size of the reads from block devices. Devices are
+// read sequentially in large blocks, which is much faster than reading them
+// in the small blocks used by the chunker.
+const deviceReadBufSize = 8 * 1024 * 1024
+
+// deviceFile presents the content of a block device as a regular file with
+// the size of the device.
+type deviceFile struct {
+	fs.File
+	rd   *bufio.Reader
+	size int64
+}
+
+func newDeviceFile(f fs.File, size int64) *deviceFile {
+	return &deviceFile{
+		File: f,
+		rd:   bufio.NewReaderSize(f, deviceReadBufSize),
+		size: size,
+	}
+}
+
+func (f *deviceFile) Read(p []byte) (int, error) {
+	return f.rd.Read(p)
+}
+
+func (f *deviceFile) Stat() (*fs.ExtendedFileInfo, error) {
+	fi, err := f.File.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	file := *fi
+	file.Mode &^= os.ModeDevice | os.ModeCharDevice
+	file.Size = f.size
+	return &file, nil
+}
+
+func (f *deviceFile) ToNode(ignoreXattrListError bool, warnf func(format string, args ...any)) (*data.Node, error) {
+	node, err := f.File.ToNode(ignoreXattrListError, warnf)
+	if node == nil {
+		return nil, err
+	}
+
+	node.Type = data.NodeTypeFile
+	node.Mode &^= os.ModeDevice | os.ModeCharDevice
+	node.Size = uint64(f.size)
+	node.Device = 0
+	return node, err
+}
@@ -5,13 +5,16 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"sync"
 
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/filter"
 	"github.com/restic/restic/internal/fs"
+	"github.com/restic/restic/internal/ui"
 )
 
 // RejectByNameFunc is a function that takes a filename of a
@@ -84,10 +87,11 @@ func (rc *rejectionCache) Store(dir string, rejected bool) {
 // RejectIfPresent returns a RejectByNameFunc which itself returns whether a path
 // should be excluded. The RejectByNameFunc considers a file to be excluded when
 // it resides in a directory with an exclusion file, that is specified by
-// excludeFileSpec in the form "filename[:content]". The returned error is
-// non-nil if the filename component of excludeFileSpec is empty. If rc is
-// non-nil, it is going to be used in the RejectByNameFunc to expedite the evaluation
-// of a directory based on previous visits.
+// excludeFileSpec in the form "filename[:content]". The filename may be a glob
+// pattern as supported by filepath.Match. The returned error is non-nil if the
+// filename component of excludeFileSpec is empty or an invalid pattern. If rc
+// is non-nil, it is going to be used in the RejectByNameFunc to expedite the
+// evaluation of a directory based on previous visits.
 func RejectIfPresent(excludeFileSpec string, warnf func(msg string, args ...interface{})) (RejectFunc, error) {
 	if excludeFileSpec == "" {
 		return nil, errors.New("name for exclusion tagfile is empty")
@@ -103,6 +107,9 @@ func RejectIfPresent(excludeFileSpec string, warnf func(msg string, args ...inte
 	} else {
 		tf = excludeFileSpec
 	}
+	if _, err := filepath.Match(tf, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern for exclusion tagfile %q: %w", tf, err)
+	}
 	debug.Log("using %q as exclusion tagfile", tf)
 	rc := newRejectionCache()
 	return func(filename string, _ *fs.ExtendedFileInfo, fs fs.FS) bool {
@@ -110,6 +117,21 @@ func RejectIfPresent(excludeFileSpec string, warnf func(msg string, args ...inte
 	}, nil
 }
 
+// isTagFilePattern returns true if tagFilename contains glob wildcards.
+func isTagFilePattern(tagFilename string) bool {
+	return strings.ContainsAny(tagFilename, "*?[")
+}
+
+// matchesTagFilename returns true if name is matched by tagFilename, which
+// may be a glob pattern.
+func matchesTagFilename(tagFilename, name string) bool {
+	if !isTagFilePattern(tagFilename) {
+		return name == tagFilename
+	}
+	matched, _ := filepath.Match(tagFilename, name)
+	return matched
+}
+
 // isExcludedByFile interprets filename as a path and returns true if that file
 // is in an excluded directory. A directory is identified as excluded if it contains a
 // tagfile which bears the name specified in tagFilename and starts with
@@ -120,7 +142,7 @@ func isExcludedByFile(filename, tagFilename, header string, rc *rejectionCache,
 		return false
 	}
 
-	if fs.Base(filename) == tagFilename {
+	if matchesTagFilename(tagFilename, fs.Base(filename)) {
 		return false // do not exclude the tagfile itself
 	}
 	rc.Lock()
@@ -137,7 +159,25 @@ func isExcludedByFile(filename, tagFilename, header string, rc *rejectionCache,
 }
 
 func isDirExcludedByFile(dir, tagFilename, header string, fsInst fs.FS, warnf func(msg string, args ...interface{})) bool {
-	tf := fsInst.Join(dir, tagFilename)
+	if !isTagFilePattern(tagFilename) {
+		return isTagFile(fsInst.Join(dir, tagFilename), header, fsInst, warnf)
+	}
+
+	names, err := fs.Readdirnames(fsInst, dir, fs.O_NOFOLLOW)
+	if err != nil {
+		warnf("could not list directory %v for exclusion tagfiles: %v", dir, err)
+		return false
+	}
+	for _, name := range names {
+		if matchesTagFilename(tagFilename, name) && isTagFile(fsInst.Join(dir, name), header, fsInst, warnf) {
+			return true
+		}
+	}
+	return false
+}
+
+// isTagFile returns true if the file tf exists and starts with header.
+func isTagFile(tf, header string, fsInst fs.FS, warnf func(msg string, args ...interface{})) bool {
 	_, err := fsInst.Lstat(tf)
 	if errors.Is(err, os.ErrNotExist) {
 		return false
@@ -300,6 +340,54 @@ func RejectByDevice(samples []string, filesystem fs.FS) (RejectFunc, error) {
 	}, nil
 }
 
+// RejectNetworkFilesystems returns a RejectFunc which detects directories on
+// network or FUSE filesystems, for example mount points of NFS shares below
+// the backup targets. If skip is true, these directories are rejected,
+// otherwise only a warning is printed. The filesystems which contain the
+// files/dirs in samples are always accepted. The filesystem type is only
+// determined once per device.
+func RejectNetworkFilesystems(samples []string, filesystem fs.FS, skip bool, warnf func(msg string, args ...interface{})) (RejectFunc, error) {
+	var m sync.Mutex
+	network := make(map[uint64]bool)
+	for _, item := range samples {
+		fi, err := filesystem.Lstat(filesystem.Clean(item))
+		if err != nil {
+			return nil, err
+		}
+		network[fi.DeviceID] = false
+	}
+
+	return func(item string, fi *fs.ExtendedFileInfo, _ fs.FS) bool {
+		// only directories can be mount points
+		if !fi.Mode.IsDir() {
+			return false
+		}
+
+		m.Lock()
+		defer m.Unlock()
+
+		isNetwork, ok := network[fi.DeviceID]
+		if ok {
+			return isNetwork && skip
+		}
+
+		info, err := fs.FilesystemType(item)
+		if err != nil {
+			// if in doubt, accept
+			debug.Log("unable to determine filesystem of %v: %v", item, err)
+		}
+		network[fi.DeviceID] = info.Network
+		if info.Network {
+			if skip {
+				warnf("skipping %v, it is on a network filesystem (%v)\n", item, info.Type)
+			} else {
+				warnf("%v is on a network filesystem (%v)\n", item, info.Type)
+			}
+		}
+		return info.Network && skip
+	}, nil
+}
+
 func RejectBySize(maxSize int64) (RejectFunc, error) {
 	return func(item string, fi *fs.ExtendedFileInfo, _ fs.FS) bool {
 		// directory will be ignored
@@ -334,3 +422,212 @@ func RejectCloudFiles(warnf func(msg string, args ...interface{})) (RejectFunc,
 		return false
 	}, nil
 }
+
+// dirFileCache caches the settings read from files with a fixed name within
+// each directory. Directories without such a file are stored as nil.
+type dirFileCache[T any] struct {
+	mtx  sync.Mutex
+	m    map[string]*T
+	load func(path string, fs fs.FS) *T
+}
+
+func newDirFileCache[T any](filename string, load func(path string, fs fs.FS) *T) *dirFileCache[T] {
+	return &dirFileCache[T]{
+		m: make(map[string]*T),
+		load: func(dir string, fsInst fs.FS) *T {
+			return load(fsInst.Join(dir, filename), fsInst)
+		},
+	}
+}
+
+// Get returns the settings for dir, which are loaded on first use.
+func (c *dirFileCache[T]) Get(dir string, fs fs.FS) *T {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	v, ok := c.m[dir]
+	if !ok {
+		v = c.load(dir, fs)
+		c.m[dir] = v
+	}
+	return v
+}
+
+// relativePath returns the path of item relative to its parent directory dir,
+// starting with a separator.
+func relativePath(item, dir string, fs fs.FS) string {
+	rel := strings.TrimPrefix(item, dir)
+	if !strings.HasPrefix(rel, fs.Separator()) {
+		rel = fs.Separator() + rel
+	}
+	return rel
+}
+
+// dirExcludes are the settings read from an exclude file within a directory.
+type dirExcludes struct {
+	patterns []filter.Pattern
+	// maxSize is the size limit for files, negative if unset
+	maxSize int64
+}
+
+// RejectByDirExcludeFile returns a RejectFunc which excludes files based on
+// the files called filename within the directories. Each file contains
+// exclude patterns in the format used by --exclude-file and optionally a line
+// "--exclude-larger-than size". The settings apply to the directory containing
+// the file and all its subdirectories. Patterns are matched against the path
+// relative to that directory, the size limit of the innermost directory takes
+// precedence.
+func RejectByDirExcludeFile(filename string, warnf func(msg string, args ...interface{})) (RejectFunc, error) {
+	if filename == "" || strings.ContainsAny(filename, `/\`) {
+		return nil, errors.Errorf("invalid name %q for directory exclude files", filename)
+	}
+
+	cache := newDirFileCache(filename, func(path string, fsInst fs.FS) *dirExcludes {
+		return loadDirExcludes(path, fsInst, warnf)
+	})
+
+	return func(item string, fi *fs.ExtendedFileInfo, fsInst fs.FS) bool {
+		item = fsInst.Clean(item)
+		if fsInst.Base(item) == filename {
+			return false // do not exclude the exclude files themselves
+		}
+		sizeChecked := false
+		for dir := fsInst.Dir(item); ; dir = fsInst.Dir(dir) {
+			if ex := cache.Get(dir, fsInst); ex != nil {
+				matched, err := filter.List(ex.patterns, relativePath(item, dir, fsInst))
+				if err != nil {
+					warnf("error for exclude pattern in %v: %v", fsInst.Join(dir, filename), err)
+				}
+				if matched {
+					debug.Log("path %q excluded by %v", item, fsInst.Join(dir, filename))
+					return true
+				}
+
+				if !sizeChecked && ex.maxSize >= 0 {
+					sizeChecked = true
+					if fi != nil && !fi.Mode.IsDir() && fi.Size > ex.maxSize {
+						debug.Log("file %s is larger than the limit of %v", item, fsInst.Join(dir, filename))
+						return true
+					}
+				}
+			}
+
+			if fsInst.Dir(dir) == dir {
+				return false
+			}
+		}
+	}, nil
+}
+
+// loadDirExcludes reads the exclude file at path. It returns nil if the file
+// does not exist or cannot be parsed.
+func loadDirExcludes(path string, fsInst fs.FS, warnf func(msg string, args ...interface{})) *dirExcludes {
+	buf, err := readDirFile(path, fsInst)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		warnf("could not read exclude file: %v", err)
+		return nil
+	}
+
+	ex := &dirExcludes{maxSize: -1}
+	var patterns []string
+	for _, line := range strings.Split(string(buf), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if v, ok := strings.CutPrefix(line, "--exclude-larger-than"); ok {
+			size, err := ui.ParseBytes(strings.TrimLeft(strings.TrimSpace(v), "="))
+			if err != nil {
+				warnf("ignoring exclude file %v: invalid size: %v", path, err)
+				return nil
+			}
+			ex.maxSize = size
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+
+	if err := filter.ValidatePatterns(patterns); err != nil {
+		warnf("ignoring exclude file %v: %v", path, err)
+		return nil
+	}
+	ex.patterns = filter.ParsePatterns(patterns)
+	debug.Log("loaded exclude file %v: %d patterns, size limit %d", path, len(patterns), ex.maxSize)
+	return ex
+}
+
+// RejectByIgnoreFiles returns a RejectFunc which excludes files based on the
+// gitignore-style files called filename within the directories. The rules of
+// an ignore file apply to the directory containing the file and all its
+// subdirectories, rules in a subdirectory take precedence.
+func RejectByIgnoreFiles(filename string, warnf func(msg string, args ...interface{})) (RejectFunc, error) {
+	if filename == "" || strings.ContainsAny(filename, `/\`) {
+		return nil, errors.Errorf("invalid name %q for ignore files", filename)
+	}
+
+	cache := newDirFileCache(filename, func(path string, fsInst fs.FS) *filter.IgnoreFile {
+		return loadIgnoreFile(path, fsInst, warnf)
+	})
+
+	return func(item string, fi *fs.ExtendedFileInfo, fsInst fs.FS) bool {
+		item = fsInst.Clean(item)
+		isDir := fi != nil && fi.Mode.IsDir()
+		for dir := fsInst.Dir(item); ; dir = fsInst.Dir(dir) {
+			if f := cache.Get(dir, fsInst); f != nil {
+				ignored, decided, err := f.Match(relativePath(item, dir, fsInst), isDir)
+				if err != nil {
+					warnf("error for pattern in %v: %v", fsInst.Join(dir, filename), err)
+				}
+				if decided {
+					if ignored {
+						debug.Log("path %q excluded by %v", item, fsInst.Join(dir, filename))
+					}
+					return ignored
+				}
+			}
+
+			if fsInst.Dir(dir) == dir {
+				return false
+			}
+		}
+	}, nil
+}
+
+// loadIgnoreFile reads the ignore file at path. It returns nil if the file
+// does not exist or cannot be parsed.
+func loadIgnoreFile(path string, fsInst fs.FS, warnf func(msg string, args ...interface{})) *filter.IgnoreFile {
+	buf, err := readDirFile(path, fsInst)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		warnf("could not read ignore file: %v", err)
+		return nil
+	}
+
+	f, err := filter.ParseIgnoreFile(buf)
+	if err != nil {
+		warnf("ignoring ignore file %v: %v", path, err)
+		return nil
+	}
+	return f
+}
+
+// readDirFile returns the content of the file at path.
+func readDirFile(path string, fsInst fs.FS) ([]byte, error) {
+	f, err := fsInst.OpenFile(path, fs.O_RDONLY, false)
+	if err != nil {
+		return nil, err
+	}
+	buf, err := io.ReadAll(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "read %v", path)
+	}
+	return buf, nil
+}
@@ -1,6 +1,7 @@
 package archiver
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
@@ -211,6 +212,190 @@ func TestIsExcludedByFileSize(t *testing.T) {
 	}
 }
 
+func TestIsExcludedByFilePattern(t *testing.T) {
+	tempDir := test.TempDir(t)
+
+	files := []struct {
+		path string
+		incl bool
+	}{
+		{"42", true},
+		{"foodir/project.nobackup", true},
+		{"foodir/foo", false},
+		{"foodir/foosub/underfoo", false},
+		{"bardir/bar", true},
+		{"bardir/nobackup.txt", true},
+	}
+	var errs []error
+	for _, f := range files {
+		p := filepath.Join(tempDir, filepath.FromSlash(f.path))
+		errs = append(errs, os.MkdirAll(filepath.Dir(p), 0700))
+		errs = append(errs, os.WriteFile(p, []byte(f.path), 0600))
+	}
+	test.OKs(t, errs)
+
+	exclude, err := RejectIfPresent("*.nobackup", nil)
+	test.OK(t, err)
+
+	m := make(map[string]bool)
+	test.OK(t, filepath.Walk(tempDir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		excluded := exclude(p, nil, fs.NewLocal())
+		m[p] = !excluded
+		if excluded && fi.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	}))
+
+	for _, f := range files {
+		p := filepath.Join(tempDir, filepath.FromSlash(f.path))
+		if m[p] != f.incl {
+			t.Errorf("inclusion status of %s is wrong: want %v, got %v", f.path, f.incl, m[p])
+		}
+	}
+
+	_, err = RejectIfPresent("[invalid", nil)
+	test.Assert(t, err != nil, "expected error for invalid pattern")
+}
+
+func TestRejectByDirExcludeFile(t *testing.T) {
+	tempDir := test.TempDir(t)
+
+	files := []struct {
+		path    string
+		content string
+		incl    bool
+	}{
+		{"large", "0123456789", true},
+		{"project/.resticignore", "# build output\nbuild\n/*.log\n--exclude-larger-than 5\n", true},
+		{"project/main.go", "1234", true},
+		{"project/large", "0123456789", false},
+		{"project/test.log", "log", false},
+		{"project/build/out", "out", false},
+		{"project/sub/test.log", "log", true},
+		{"project/sub/large", "0123456789", false},
+		{"project/sub/build", "file", false},
+		{"project/data/.resticignore", "--exclude-larger-than=20\n", true},
+		{"project/data/large", "0123456789", true},
+		{"project/data/build/out", "out", false},
+		{"invalid/.resticignore", "--exclude-larger-than foo\n", true},
+		{"invalid/large", "0123456789", true},
+	}
+	var errs []error
+	for _, f := range files {
+		p := filepath.Join(tempDir, filepath.FromSlash(f.path))
+		errs = append(errs, os.MkdirAll(filepath.Dir(p), 0700))
+		errs = append(errs, os.WriteFile(p, []byte(f.content), 0600))
+	}
+	test.OKs(t, errs)
+
+	var warnings []string
+	exclude, err := RejectByDirExcludeFile(".resticignore", func(msg string, args ...interface{}) {
+		warnings = append(warnings, fmt.Sprintf(msg, args...))
+	})
+	test.OK(t, err)
+
+	m := make(map[string]bool)
+	test.OK(t, filepath.Walk(tempDir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		excluded := exclude(p, fs.ExtendedStat(fi), fs.NewLocal())
+		m[p] = !excluded
+		if excluded && fi.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	}))
+
+	for _, f := range files {
+		p := filepath.Join(tempDir, filepath.FromSlash(f.path))
+		if m[p] != f.incl {
+			t.Errorf("inclusion status of %s is wrong: want %v, got %v", f.path, f.incl, m[p])
+		}
+	}
+	test.Equals(t, 1, len(warnings))
+
+	_, err = RejectByDirExcludeFile("sub/.resticignore", nil)
+	test.Assert(t, err != nil, "expected error for invalid file name")
+}
+
+func TestRejectByIgnoreFiles(t *testing.T) {
+	tempDir := test.TempDir(t)
+
+	files := []struct {
+		path    string
+		content string
+		incl    bool
+	}{
+		{".resticignore", "*.log\nbuild/\n/data/*.tmp\n", true},
+		{"main.go", "", true},
+		{"test.log", "", false},
+		{"build/out", "", false},
+		{"src/build", "", true},
+		{"src/test.log", "", false},
+		{"src/important/.resticignore", "!*.log\n", true},
+		{"src/important/keep.log", "", true},
+		{"data/file.tmp", "", false},
+		{"data/sub/file.tmp", "", true},
+	}
+	var errs []error
+	for _, f := range files {
+		p := filepath.Join(tempDir, filepath.FromSlash(f.path))
+		errs = append(errs, os.MkdirAll(filepath.Dir(p), 0700))
+		errs = append(errs, os.WriteFile(p, []byte(f.content), 0600))
+	}
+	test.OKs(t, errs)
+
+	exclude, err := RejectByIgnoreFiles(".resticignore", func(msg string, args ...interface{}) {
+		t.Errorf(msg, args...)
+	})
+	test.OK(t, err)
+
+	m := make(map[string]bool)
+	test.OK(t, filepath.Walk(tempDir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		excluded := exclude(p, fs.ExtendedStat(fi), fs.NewLocal())
+		m[p] = !excluded
+		if excluded && fi.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	}))
+
+	for _, f := range files {
+		p := filepath.Join(tempDir, filepath.FromSlash(f.path))
+		if m[p] != f.incl {
+			t.Errorf("inclusion status of %s is wrong: want %v, got %v", f.path, f.incl, m[p])
+		}
+	}
+}
+
+func TestRejectNetworkFilesystems(t *testing.T) {
+	tempDir := test.TempDir(t)
+	test.OK(t, os.MkdirAll(filepath.Join(tempDir, "a", "b"), 0700))
+	test.OK(t, os.WriteFile(filepath.Join(tempDir, "a", "file"), []byte("foo"), 0600))
+
+	exclude, err := RejectNetworkFilesystems([]string{tempDir}, fs.NewLocal(), true, func(msg string, args ...interface{}) {
+		t.Errorf(msg, args...)
+	})
+	test.OK(t, err)
+
+	// the filesystem of the sample is always accepted
+	test.OK(t, filepath.Walk(tempDir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		test.Assert(t, !exclude(p, fs.ExtendedStat(fi), fs.NewLocal()), "%v was rejected", p)
+		return nil
+	}))
+}
+
 func TestDeviceMap(t *testing.T) {
 	deviceMap := deviceMap{
 		filepath.FromSlash("/"):          1,
@@ -6,6 +6,7 @@ import (
 	"io"
 	"sync"
 
+	"github.com/cespare/xxhash/v2"
 	"github.com/restic/restic/internal/data"
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/errors"
@@ -25,6 +26,15 @@ type fileSaver struct {
 
 	ch chan<- saveFileJob
 
+	// compressionPolicy selects the compression level per file, if set.
+	compressionPolicy *CompressionPolicy
+
+	// fingerprints records the content hash of each saved file, if set.
+	fingerprints *FingerprintCache
+
+	// zeroBlobs caches the IDs of blobs only containing zero bytes, if set.
+	zeroBlobs *zeroBlobCache
+
 	CompleteBlob func(bytes uint64)
 
 	NodeFromFileInfo func(snPath, filename string, meta toNoder, ignoreXattrListError bool) (*data.Node, error)
@@ -55,6 +65,26 @@ func newFileSaver(ctx context.Context, wg *errgroup.Group, uploader restic.BlobS
 	return s
 }
 
+// uploaderFor returns the uploader for the file target whose content starts
+// with head. If a compression policy is set and the uploader supports it, the
+// returned uploader uses the compression level selected by the policy.
+func (s *fileSaver) uploaderFor(target string, head []byte) restic.BlobSaverAsync {
+	if s.compressionPolicy == nil {
+		return s.uploader
+	}
+	sel, ok := s.uploader.(restic.CompressionSelector)
+	if !ok {
+		return s.uploader
+	}
+
+	level := s.compressionPolicy.Level(target, head)
+	if level == restic.CompressionLevelDefault {
+		return s.uploader
+	}
+	debug.Log("using compression level %v for %v", level, target)
+	return sel.WithCompression(level)
+}
+
 func (s *fileSaver) TriggerShutdown() {
 	close(s.ch)
 }
@@ -66,13 +96,16 @@ type fileCompleteFunc func(*data.Node, ItemStats)
 // file is closed by Save. completeReading is only called if the file was read
 // successfully. complete is always called. If completeReading is called, then
 // this will always happen before calling complete. The callbacks must not block.
-func (s *fileSaver) Save(ctx context.Context, snPath string, target string, file fs.File, start func(), completeReading func(), complete fileCompleteFunc) futureNode {
+// If candidate is not nil and the content of the file matches its fingerprint,
+// the content of the candidate is reused without chunking the file.
+func (s *fileSaver) Save(ctx context.Context, snPath string, target string, file fs.File, candidate *fingerprintCandidate, start func(), completeReading func(), complete fileCompleteFunc) futureNode {
 	fn, ch := newFutureNode()
 	job := saveFileJob{
-		snPath: snPath,
-		target: target,
-		file:   file,
-		ch:     ch,
+		snPath:    snPath,
+		target:    target,
+		file:      file,
+		candidate: candidate,
+		ch:        ch,
 
 		start:           start,
 		completeReading: completeReading,
@@ -91,10 +124,11 @@ func (s *fileSaver) Save(ctx context.Context, snPath string, target string, file
 }
 
 type saveFileJob struct {
-	snPath string
-	target string
-	file   fs.File
-	ch     chan<- futureNodeResult
+	snPath    string
+	target    string
+	file      fs.File
+	candidate *fingerprintCandidate
+	ch        chan<- futureNodeResult
 
 	start           func()
 	completeReading func()
@@ -157,7 +191,7 @@ func (s *fileChunkState) readNextChunk(rd io.Reader, chnker restic.Chunker, data
 }
 
 // saveFile stores the file f in the repo, then closes it.
-func (s *fileSaver) saveFile(ctx context.Context, chnker restic.Chunker, chunkState *fileChunkState, snPath string, target string, f fs.File, start func(), finishReading func(), finish func(res futureNodeResult)) {
+func (s *fileSaver) saveFile(ctx context.Context, chnker restic.Chunker, chunkState *fileChunkState, snPath string, target string, f fs.File, candidate *fingerprintCandidate, start func(), finishReading func(), finish func(res futureNodeResult)) {
 	start()
 
 	fnr := futureNodeResult{
@@ -167,6 +201,9 @@ func (s *fileSaver) saveFile(ctx context.Context, chnker restic.Chunker, chunkSt
 	var lock sync.Mutex
 	remaining := 0
 	isCompleted := false
+	// hash of the file content, only computed if fingerprints are recorded
+	var digest *xxhash.Digest
+	var fingerprint uint64
 
 	completeBlob := func() {
 		lock.Lock()
@@ -183,6 +220,9 @@ func (s *fileSaver) saveFile(ctx context.Context, chnker restic.Chunker, chunkSt
 				}
 			}
 			isCompleted = true
+			if digest != nil {
+				s.fingerprints.add(snPath, fnr.node, fingerprint)
+			}
 			finish(fnr)
 		}
 	}
@@ -217,15 +257,46 @@ func (s *fileSaver) saveFile(ctx context.Context, chnker restic.Chunker, chunkSt
 		return
 	}
 
+	if candidate != nil {
+		matched, err := candidate.matches(f)
+		if err != nil {
+			_ = f.Close()
+			completeError(err)
+			return
+		}
+		if matched {
+			debug.Log("%v has the same fingerprint, using old list of blobs", target)
+			err = f.Close()
+			if err != nil {
+				completeError(err)
+				return
+			}
+			node.Content = candidate.content
+			s.fingerprints.add(snPath, node, candidate.hash)
+			s.CompleteBlob(node.Size)
+			finishReading()
+			fnr.node = node
+			finish(fnr)
+			return
+		}
+	}
+
+	var rd io.Reader = f
+	if s.fingerprints != nil {
+		digest = xxhash.New()
+		rd = io.TeeReader(rd, digest)
+	}
+
 	chnker.Reset()
 	chunkState.reset()
 
 	node.Content = []restic.ID{}
 	node.Size = 0
 	var idx int
+	uploader := s.uploader
 	for {
 		buf := s.saveFilePool.Get()
-		chunkData, err := chunkState.readNextChunk(f, chnker, buf.Data)
+		chunkData, err := chunkState.readNextChunk(rd, chnker, buf.Data)
 		if err == io.EOF {
 			buf.Release()
 			break
@@ -249,20 +320,44 @@ func (s *fileSaver) saveFile(ctx context.Context, chnker restic.Chunker, chunkSt
 			return
 		}
 
+		if idx == 0 {
+			// the same compression level is used for all chunks of a file
+			uploader = s.uploaderFor(target, chunkData)
+		}
+
 		// add a place to store the saveBlob result
 		pos := idx
 
+		zero := s.zeroBlobs != nil && isZero(chunkData)
+		if zero {
+			if id, ok := s.zeroBlobs.get(len(chunkData)); ok {
+				// the blob is already stored in the repository
+				lock.Lock()
+				node.Content = append(node.Content, id)
+				lock.Unlock()
+				buf.Release()
+				idx++
+				completeBlob()
+				s.CompleteBlob(uint64(len(chunkData)))
+				continue
+			}
+		}
+
 		lock.Lock()
 		node.Content = append(node.Content, restic.ID{})
 		lock.Unlock()
 
-		s.uploader.SaveBlobAsync(ctx, restic.DataBlob, chunkData, restic.ID{}, false, func(newID restic.ID, known bool, sizeInRepo int, err error) {
+		uploader.SaveBlobAsync(ctx, restic.DataBlob, chunkData, restic.ID{}, false, func(newID restic.ID, known bool, sizeInRepo int, err error) {
 			defer buf.Release()
 			if err != nil {
 				completeError(err)
 				return
 			}
 
+			if zero {
+				s.zeroBlobs.add(len(chunkData), newID)
+			}
+
 			lock.Lock()
 			if !known {
 				fnr.stats.DataBlobs++
@@ -293,6 +388,9 @@ func (s *fileSaver) saveFile(ctx context.Context, chnker restic.Chunker, chunkSt
 	}
 
 	fnr.node = node
+	if digest != nil {
+		fingerprint = digest.Sum64()
+	}
 	lock.Lock()
 	// require one additional completeFuture() call to ensure that the future only completes
 	// after reaching the end of this method
@@ -318,7 +416,7 @@ func (s *fileSaver) worker(ctx context.Context, jobs <-chan saveFileJob) {
 			}
 		}
 
-		s.saveFile(ctx, chnker, chunkState, job.snPath, job.target, job.file, job.start, func() {
+		s.saveFile(ctx, chnker, chunkState, job.snPath, job.target, job.file, job.candidate, job.start, func() {
 			if job.completeReading != nil {
 				job.completeReading()
 			}
@@ -65,7 +65,7 @@ func TestFileSaver(t *testing.T) {
 			t.Fatal(err)
 		}
 
-		ff := s.Save(ctx, filename, filename, f, startFn, completeReadingFn, completeFn)
+		ff := s.Save(ctx, filename, filename, f, nil, startFn, completeReadingFn, completeFn)
 		results = append(results, ff)
 	}
 
@@ -85,3 +85,53 @@ func TestFileSaver(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestFileSaverZeroBlocks(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the zero bytes are split into chunks of the maximum chunk size
+	tempdir := test.TempDir(t)
+	buf := make([]byte, 17*1024*1024)
+	copy(buf[len(buf)-1024:], test.Random(23, 1024))
+	var files []string
+	for i := 0; i < 2; i++ {
+		filename := filepath.Join(tempdir, fmt.Sprintf("image-%d", i))
+		test.OK(t, os.WriteFile(filename, buf, 0600))
+		files = append(files, filename)
+	}
+
+	testFs := fs.NewLocal()
+	s, saver, ctx, wg := startFileSaver(ctx, t, testFs)
+	s.zeroBlobs = newZeroBlobCache()
+
+	saves := func() (n int) {
+		saver.mutex.Lock()
+		defer saver.mutex.Unlock()
+		for _, count := range saver.saved {
+			n += count
+		}
+		return n
+	}
+
+	var nodes []*data.Node
+	var calls []int
+	for _, filename := range files {
+		f, err := testFs.OpenFile(filename, os.O_RDONLY, false)
+		test.OK(t, err)
+
+		fn := s.Save(ctx, filename, filename, f, nil, func() {}, func() {}, func(*data.Node, ItemStats) {})
+		fnr := fn.take(ctx)
+		test.OK(t, fnr.err)
+		nodes = append(nodes, fnr.node)
+		calls = append(calls, saves())
+	}
+
+	test.Equals(t, nodes[0].Content, nodes[1].Content)
+	test.Equals(t, uint64(len(buf)), nodes[1].Size)
+	// the zero blocks of the second file are neither hashed nor uploaded
+	test.Assert(t, calls[1]-calls[0] == 1, "expected only one blob of the second file to be saved, got %d", calls[1]-calls[0])
+
+	s.TriggerShutdown()
+	test.OK(t, wg.Wait())
+}
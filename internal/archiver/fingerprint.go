@@ -0,0 +1,167 @@
+package archiver
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/restic/restic/internal/data"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+)
+
+// fingerprintVersion is the version of the serialized fingerprint cache.
+const fingerprintVersion = 1
+
+// fingerprintEntry is the fingerprint of a file stored in a snapshot.
+type fingerprintEntry struct {
+	Size uint64 `json:"size"`
+	// xxhash of the file content
+	Hash uint64 `json:"hash"`
+	// identifies the list of blobs the content was stored as
+	Content restic.ID `json:"content"`
+}
+
+// FingerprintCache stores a fast, non-cryptographic hash of the content of
+// each file. It is used to detect unchanged files whose metadata is not
+// reliable, for example after copying files using "cp -a": if the hash of a
+// file matches that recorded when the file was last saved, the list of blobs
+// from the parent snapshot is reused without running the chunker.
+//
+// Only the fingerprints of files visited during a backup are kept for the
+// next backup.
+type FingerprintCache struct {
+	m   sync.Mutex
+	old map[string]fingerprintEntry
+	new map[string]fingerprintEntry
+}
+
+// NewFingerprintCache returns an empty fingerprint cache.
+func NewFingerprintCache() *FingerprintCache {
+	return &FingerprintCache{
+		old: make(map[string]fingerprintEntry),
+		new: make(map[string]fingerprintEntry),
+	}
+}
+
+type fingerprintFile struct {
+	Version int                         `json:"version"`
+	Files   map[string]fingerprintEntry `json:"files"`
+}
+
+// LoadFingerprintCache reads a fingerprint cache written by Save.
+func LoadFingerprintCache(rd io.Reader) (*FingerprintCache, error) {
+	var f fingerprintFile
+	if err := json.NewDecoder(rd).Decode(&f); err != nil {
+		return nil, errors.Wrap(err, "decode fingerprints")
+	}
+	if f.Version != fingerprintVersion {
+		return nil, errors.Errorf("unsupported fingerprint cache version %d", f.Version)
+	}
+
+	c := NewFingerprintCache()
+	if f.Files != nil {
+		c.old = f.Files
+	}
+	return c, nil
+}
+
+// Save writes the fingerprints of all files visited during the backup to wr.
+func (c *FingerprintCache) Save(wr io.Writer) error {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	return json.NewEncoder(wr).Encode(fingerprintFile{
+		Version: fingerprintVersion,
+		Files:   c.new,
+	})
+}
+
+// contentKey returns an ID for the list of blobs in content.
+func contentKey(content restic.IDs) restic.ID {
+	buf := make([]byte, 0, len(content)*len(restic.ID{}))
+	for _, id := range content {
+		buf = append(buf, id[:]...)
+	}
+	return restic.Hash(buf)
+}
+
+// lookup returns the hash of the content of the file at snPath, if it was
+// recorded for the content stored in previous.
+func (c *FingerprintCache) lookup(snPath string, previous *data.Node) (uint64, bool) {
+	c.m.Lock()
+	entry, ok := c.old[snPath]
+	c.m.Unlock()
+
+	if !ok || entry.Size != previous.Size || !entry.Content.Equal(contentKey(previous.Content)) {
+		return 0, false
+	}
+	return entry.Hash, true
+}
+
+// keep carries over the fingerprint of the unchanged file at snPath to the
+// next backup.
+func (c *FingerprintCache) keep(snPath string, previous *data.Node) {
+	if hash, ok := c.lookup(snPath, previous); ok {
+		c.add(snPath, previous, hash)
+	}
+}
+
+// add records hash as the fingerprint of the file at snPath stored as node.
+func (c *FingerprintCache) add(snPath string, node *data.Node, hash uint64) {
+	entry := fingerprintEntry{
+		Size:    node.Size,
+		Hash:    hash,
+		Content: contentKey(node.Content),
+	}
+
+	c.m.Lock()
+	c.new[snPath] = entry
+	c.m.Unlock()
+}
+
+// candidate returns the content of previous together with its fingerprint,
+// if one was recorded for the file at snPath.
+func (c *FingerprintCache) candidate(snPath string, previous *data.Node) *fingerprintCandidate {
+	hash, ok := c.lookup(snPath, previous)
+	if !ok {
+		return nil
+	}
+	return &fingerprintCandidate{
+		content: previous.Content,
+		size:    previous.Size,
+		hash:    hash,
+	}
+}
+
+// fingerprintCandidate is the content of a file in the parent snapshot which
+// is reused if the file still has the same fingerprint.
+type fingerprintCandidate struct {
+	content restic.IDs
+	size    uint64
+	hash    uint64
+}
+
+// matches reads f and reports whether its content has the fingerprint of the
+// candidate. Afterwards, f is rewound to the start. Files which cannot be
+// rewound never match.
+func (c *fingerprintCandidate) matches(f io.Reader) (bool, error) {
+	sk, ok := f.(io.Seeker)
+	if !ok {
+		return false, nil
+	}
+	if _, err := sk.Seek(0, io.SeekCurrent); err != nil {
+		return false, nil
+	}
+
+	digest := xxhash.New()
+	n, err := io.Copy(digest, f)
+	if err != nil {
+		return false, err
+	}
+	if _, err := sk.Seek(0, io.SeekStart); err != nil {
+		return false, err
+	}
+	return uint64(n) == c.size && digest.Sum64() == c.hash, nil
+}
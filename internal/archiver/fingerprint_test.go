@@ -0,0 +1,123 @@
+package archiver
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/restic/restic/internal/data"
+	"github.com/restic/restic/internal/fs"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestFingerprintCacheSaveLoad(t *testing.T) {
+	node := &data.Node{Size: 10, Content: restic.IDs{restic.NewRandomID(), restic.NewRandomID()}}
+	other := &data.Node{Size: 10, Content: restic.IDs{restic.NewRandomID()}}
+
+	c := NewFingerprintCache()
+	c.add("/foo", node, 23)
+
+	buf := &bytes.Buffer{}
+	rtest.OK(t, c.Save(buf))
+	c, err := LoadFingerprintCache(buf)
+	rtest.OK(t, err)
+
+	hash, ok := c.lookup("/foo", node)
+	rtest.Assert(t, ok, "fingerprint not found")
+	rtest.Equals(t, uint64(23), hash)
+
+	_, ok = c.lookup("/foo", other)
+	rtest.Assert(t, !ok, "fingerprint found for different content")
+	_, ok = c.lookup("/bar", node)
+	rtest.Assert(t, !ok, "fingerprint found for unknown file")
+
+	// only visited files are carried over to the next backup
+	c.keep("/foo", node)
+	buf.Reset()
+	rtest.OK(t, c.Save(buf))
+	c, err = LoadFingerprintCache(buf)
+	rtest.OK(t, err)
+	_, ok = c.lookup("/foo", node)
+	rtest.Assert(t, ok, "fingerprint not kept")
+
+	c = NewFingerprintCache()
+	buf.Reset()
+	rtest.OK(t, c.Save(buf))
+	c, err = LoadFingerprintCache(buf)
+	rtest.OK(t, err)
+	_, ok = c.lookup("/foo", node)
+	rtest.Assert(t, !ok, "fingerprint not removed")
+
+	_, err = LoadFingerprintCache(strings.NewReader(`{"version": 2}`))
+	rtest.Assert(t, err != nil, "missing error for unsupported version")
+}
+
+func TestFingerprintCandidateMatches(t *testing.T) {
+	content := []byte("foobar")
+	c := &fingerprintCandidate{size: uint64(len(content)), hash: xxhash.Sum64(content)}
+
+	ok, err := c.matches(bytes.NewReader(content))
+	rtest.OK(t, err)
+	rtest.Assert(t, ok, "content does not match")
+
+	ok, err = c.matches(bytes.NewReader([]byte("foobaz")))
+	rtest.OK(t, err)
+	rtest.Assert(t, !ok, "different content matches")
+
+	// readers which cannot be rewound are never checked
+	ok, err = c.matches(struct{ io.Reader }{strings.NewReader("foobar")})
+	rtest.OK(t, err)
+	rtest.Assert(t, !ok, "unseekable reader matches")
+}
+
+func TestArchiverFingerprints(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		// fingerprint returns the fingerprint to record for the modified content
+		fingerprint func(content []byte) uint64
+		reused      bool
+	}{
+		{"stale", func(content []byte) uint64 { return xxhash.Sum64(content) + 1 }, false},
+		// a matching fingerprint reuses the content of the parent snapshot
+		// without reading the new content into the chunker
+		{"match", xxhash.Sum64, true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			src := TestDir{"file": TestFile{Content: string(rtest.Random(23, 5000))}}
+			tempdir, repo := prepareTempdirRepoSrc(t, src)
+			back := rtest.Chdir(t, tempdir)
+			defer back()
+
+			arch := New(repo, fs.Track{FS: fs.NewLocal()}, Options{})
+			arch.Fingerprints = NewFingerprintCache()
+			sn, _, _, err := arch.Snapshot(ctx, []string{"."}, SnapshotOptions{Time: time.Now()})
+			rtest.OK(t, err)
+			first, ok := arch.Fingerprints.new["/file"]
+			rtest.Assert(t, ok, "no fingerprint recorded, got %v", arch.Fingerprints.new)
+			rtest.Equals(t, xxhash.Sum64String(src["file"].(TestFile).Content), first.Hash)
+
+			// change the content but keep the size
+			modified := rtest.Random(42, 5000)
+			rtest.OK(t, os.WriteFile(filepath.Join(tempdir, "file"), modified, 0o644))
+			first.Hash = test.fingerprint(modified)
+			arch.Fingerprints = NewFingerprintCache()
+			arch.Fingerprints.old["/file"] = first
+
+			_, _, _, err = arch.Snapshot(ctx, []string{"."}, SnapshotOptions{Time: time.Now(), ParentSnapshot: sn})
+			rtest.OK(t, err)
+			second, ok := arch.Fingerprints.new["/file"]
+			rtest.Assert(t, ok, "no fingerprint recorded, got %v", arch.Fingerprints.new)
+			rtest.Equals(t, test.reused, second.Content.Equal(first.Content))
+		})
+	}
+}
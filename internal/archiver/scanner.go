@@ -3,20 +3,30 @@ package archiver
 import (
 	"context"
 	"sort"
+	"sync"
 
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/fs"
+	"golang.org/x/sync/errgroup"
 )
 
 // Scanner  traverses the targets and calls the function Result with cumulated
 // stats concerning the files and folders found. Select is used to decide which
 // items should be included. Error is called when an error occurs.
+//
+// If Concurrency is larger than one, up to Concurrency items are processed
+// concurrently. The order in which Result is called for the items is then no
+// longer deterministic, but calls to Result and Error are still serialized.
 type Scanner struct {
 	FS           fs.FS
 	SelectByName SelectByNameFunc
 	Select       SelectFunc
 	Error        ErrorFunc
 	Result       func(item string, s ScanStats)
+	Concurrency  uint
+	// ReadSpecial counts block devices as files with the size of the device,
+	// see Options.ReadSpecial.
+	ReadSpecial bool
 }
 
 // NewScanner initializes a new Scanner.
@@ -86,7 +96,12 @@ func (s *Scanner) Scan(ctx context.Context, targets []string) error {
 		return err
 	}
 
-	stats, err := s.scanTree(ctx, ScanStats{}, *tree)
+	var stats ScanStats
+	if s.Concurrency > 1 {
+		stats, err = s.scanConcurrent(ctx, *tree)
+	} else {
+		stats, err = s.scanTree(ctx, ScanStats{}, *tree)
+	}
 	if err != nil {
 		return err
 	}
@@ -122,6 +137,9 @@ func (s *Scanner) scan(ctx context.Context, stats ScanStats, target string, expl
 	case fi.Mode.IsRegular():
 		stats.Files++
 		stats.Bytes += uint64(fi.Size)
+	case s.ReadSpecial && fs.IsBlockDevice(fi.Mode):
+		stats.Files++
+		stats.Bytes += s.deviceSize(target)
 	case fi.Mode.IsDir():
 		names, err := fs.Readdirnames(s.FS, target, fs.O_NOFOLLOW)
 		if err != nil {
@@ -143,3 +161,132 @@ func (s *Scanner) scan(ctx context.Context, stats ScanStats, target string, expl
 	s.Result(target, stats)
 	return stats, nil
 }
+
+// deviceSize returns the size of the block device target. Errors are reported
+// by the archiver when reading the device, thus they are ignored here.
+func (s *Scanner) deviceSize(target string) uint64 {
+	size, err := fs.DeviceSize(target)
+	if err != nil {
+		debug.Log("unable to determine size of %v: %v", target, err)
+		return 0
+	}
+	return uint64(size)
+}
+
+// scanConcurrent traverses tree using up to s.Concurrency goroutines.
+func (s *Scanner) scanConcurrent(ctx context.Context, tree tree) (ScanStats, error) {
+	wg, ctx := errgroup.WithContext(ctx)
+	wg.SetLimit(int(s.Concurrency))
+
+	cs := &concurrentScan{Scanner: s, wg: wg}
+	wg.Go(func() error {
+		return cs.scanTree(ctx, tree)
+	})
+	err := wg.Wait()
+
+	return cs.stats, err
+}
+
+// concurrentScan holds the state of a concurrent scan. Subdirectories are
+// scanned in a new goroutine if the limit of the errgroup allows it and in
+// the current goroutine otherwise.
+type concurrentScan struct {
+	*Scanner
+	wg *errgroup.Group
+
+	m     sync.Mutex
+	stats ScanStats
+}
+
+func (s *concurrentScan) scanTree(ctx context.Context, tree tree) error {
+	if tree.Leaf() {
+		abstarget, err := s.FS.Abs(tree.Path)
+		if err != nil {
+			return err
+		}
+
+		return s.scan(ctx, abstarget, tree.Explicit)
+	}
+
+	for _, name := range tree.NodeNames() {
+		if err := s.scanTree(ctx, tree.Nodes[name]); err != nil {
+			return err
+		}
+
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (s *concurrentScan) scan(ctx context.Context, target string, explicit bool) error {
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	// exclude files by path before running stat to reduce number of lstat calls
+	if !explicit && !s.SelectByName(target) {
+		return nil
+	}
+
+	// get file information
+	fi, err := s.FS.Lstat(target)
+	if err != nil {
+		return s.error(target, err)
+	}
+
+	// run remaining select functions that require file information
+	if !explicit && !s.Select(target, fi, s.FS) {
+		return nil
+	}
+
+	switch {
+	case fi.Mode.IsRegular():
+		s.result(target, ScanStats{Files: 1, Bytes: uint64(fi.Size)})
+	case s.ReadSpecial && fs.IsBlockDevice(fi.Mode):
+		s.result(target, ScanStats{Files: 1, Bytes: s.deviceSize(target)})
+	case fi.Mode.IsDir():
+		names, err := fs.Readdirnames(s.FS, target, fs.O_NOFOLLOW)
+		if err != nil {
+			return s.error(target, err)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			item := s.FS.Join(target, name)
+			started := s.wg.TryGo(func() error {
+				return s.scan(ctx, item, false)
+			})
+			if started {
+				continue
+			}
+
+			if err := s.scan(ctx, item, false); err != nil {
+				return err
+			}
+		}
+		s.result(target, ScanStats{Dirs: 1})
+	default:
+		s.result(target, ScanStats{Others: 1})
+	}
+	return nil
+}
+
+func (s *concurrentScan) error(item string, err error) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return s.Error(item, err)
+}
+
+// result adds stats to the cumulated stats and reports them for item.
+func (s *concurrentScan) result(item string, stats ScanStats) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.stats.Files += stats.Files
+	s.stats.Dirs += stats.Dirs
+	s.stats.Others += stats.Others
+	s.stats.Bytes += stats.Bytes
+	s.Result(item, s.stats)
+}
@@ -2,12 +2,15 @@ package archiver
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/fs"
 	rtest "github.com/restic/restic/internal/test"
 )
@@ -319,3 +322,99 @@ func TestScannerCancel(t *testing.T) {
 		t.Errorf("wrong final result, want\n  %#v\ngot:\n  %#v", result, lastStats)
 	}
 }
+
+func TestScannerConcurrency(t *testing.T) {
+	src := TestDir{
+		"other": TestFile{Content: "another file"},
+		"work": TestDir{
+			"foo":     TestFile{Content: "foo"},
+			"foo.txt": TestFile{Content: "foo text file"},
+			"subdir": TestDir{
+				"other":   TestFile{Content: "other in subdir"},
+				"bar.txt": TestFile{Content: "bar.txt in subdir"},
+			},
+		},
+		"work2": TestDir{
+			"a": TestDir{"file": TestFile{Content: "a"}},
+			"b": TestDir{"file": TestFile{Content: "b"}},
+			"c": TestDir{"file": TestFile{Content: "c"}},
+		},
+	}
+
+	for _, concurrency := range []uint{2, 4, 16} {
+		t.Run(fmt.Sprintf("concurrency-%d", concurrency), func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			tempdir := rtest.TempDir(t)
+			TestCreateFiles(t, tempdir, src)
+
+			back := rtest.Chdir(t, tempdir)
+			defer back()
+
+			cur, err := os.Getwd()
+			rtest.OK(t, err)
+
+			sc := NewScanner(fs.Track{FS: fs.NewLocal()})
+			sc.Concurrency = concurrency
+
+			var items []string
+			var result ScanStats
+			sc.Result = func(item string, s ScanStats) {
+				if item == "" {
+					result = s
+					return
+				}
+				p, err := filepath.Rel(cur, item)
+				rtest.OK(t, err)
+				items = append(items, p)
+			}
+
+			rtest.OK(t, sc.Scan(ctx, []string{"."}))
+
+			sort.Strings(items)
+			want := []string{"other", "work", "work/foo", "work/foo.txt", "work/subdir", "work/subdir/bar.txt",
+				"work/subdir/other", "work2", "work2/a", "work2/a/file", "work2/b", "work2/b/file", "work2/c", "work2/c/file"}
+			for i := range want {
+				want[i] = filepath.FromSlash(want[i])
+			}
+			rtest.Equals(t, want, items)
+			rtest.Equals(t, ScanStats{Files: 8, Dirs: 6, Bytes: 63}, result)
+		})
+	}
+}
+
+func TestScannerConcurrentError(t *testing.T) {
+	src := TestDir{
+		"work": TestDir{
+			"bar": TestFile{Content: "bar"},
+			"baz": TestFile{Content: "baz"},
+			"foo": TestFile{Content: "foo"},
+		},
+	}
+
+	tempdir := rtest.TempDir(t)
+	TestCreateFiles(t, tempdir, src)
+
+	back := rtest.Chdir(t, tempdir)
+	defer back()
+
+	sc := NewScanner(fs.Track{FS: fs.NewLocal()})
+	sc.Concurrency = 4
+	// remove baz after it was listed such that lstat fails
+	sc.SelectByName = func(item string) bool {
+		if filepath.Base(item) == "baz" {
+			rtest.OK(t, os.Remove(item))
+		}
+		return true
+	}
+
+	testErr := errors.New("test error")
+	sc.Error = func(item string, err error) error {
+		rtest.Equals(t, "baz", filepath.Base(item))
+		return testErr
+	}
+
+	err := sc.Scan(context.Background(), []string{"."})
+	rtest.Assert(t, errors.Is(err, testErr), "expected test error, got %v", err)
+}
@@ -0,0 +1,158 @@
+package archiver
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/fs"
+)
+
+// FollowSymlinksMode configures which symlinks are followed by the archiver.
+type FollowSymlinksMode int
+
+const (
+	// FollowSymlinksNone stores all symlinks as links.
+	FollowSymlinksNone FollowSymlinksMode = iota
+	// FollowSymlinksDirs descends into symlinked directories.
+	FollowSymlinksDirs
+	// FollowSymlinksAll descends into symlinked directories and stores the
+	// content of symlinked files.
+	FollowSymlinksAll
+)
+
+// Set implements the method needed for pflag command flag parsing.
+func (m *FollowSymlinksMode) Set(s string) error {
+	switch s {
+	case "none":
+		*m = FollowSymlinksNone
+	case "dirs":
+		*m = FollowSymlinksDirs
+	case "all":
+		*m = FollowSymlinksAll
+	default:
+		return fmt.Errorf("invalid follow symlinks mode %q, must be one of (dirs|all)", s)
+	}
+	return nil
+}
+
+func (m *FollowSymlinksMode) String() string {
+	switch *m {
+	case FollowSymlinksDirs:
+		return "dirs"
+	case FollowSymlinksAll:
+		return "all"
+	default:
+		return "none"
+	}
+}
+
+func (m *FollowSymlinksMode) Type() string {
+	return "mode"
+}
+
+type fileID struct {
+	device, inode uint64
+}
+
+// symlinkTracker records the directories which are currently being archived
+// and those reached via a symlink, to descend into each symlinked directory
+// only once and to detect symlink cycles.
+type symlinkTracker struct {
+	mu        sync.Mutex
+	ancestors map[fileID]int
+	followed  map[fileID]struct{}
+}
+
+func newSymlinkTracker() *symlinkTracker {
+	return &symlinkTracker{
+		ancestors: make(map[fileID]int),
+		followed:  make(map[fileID]struct{}),
+	}
+}
+
+// enter marks the directory fi as being archived, until leave is called.
+func (t *symlinkTracker) enter(fi *fs.ExtendedFileInfo) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.ancestors[fileID{fi.DeviceID, fi.Inode}]++
+}
+
+func (t *symlinkTracker) leave(fi *fs.ExtendedFileInfo) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	id := fileID{fi.DeviceID, fi.Inode}
+	t.ancestors[id]--
+	if t.ancestors[id] <= 0 {
+		delete(t.ancestors, id)
+	}
+}
+
+// follow returns whether the symlinked directory fi should be descended into.
+// This is not the case if the directory contains the symlink or if it was
+// already reached via another symlink.
+func (t *symlinkTracker) follow(fi *fs.ExtendedFileInfo) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	id := fileID{fi.DeviceID, fi.Inode}
+	if t.ancestors[id] > 0 {
+		return false
+	}
+	if _, ok := t.followed[id]; ok {
+		return false
+	}
+	t.followed[id] = struct{}{}
+	return true
+}
+
+// followSymlink opens the target of the symlink at target. It returns false if
+// the symlink should be stored as is, because it's broken, points to a file
+// type which is not followed or would create a cycle.
+func (arch *Archiver) followSymlink(target string) (fs.File, *fs.ExtendedFileInfo, bool) {
+	meta, err := arch.FS.OpenFile(target, 0, true)
+	if err != nil {
+		debug.Log("unable to follow symlink %v: %v", target, err)
+		return nil, nil, false
+	}
+	fi, err := meta.Stat()
+	if err != nil {
+		debug.Log("unable to follow symlink %v: %v", target, err)
+		_ = meta.Close()
+		return nil, nil, false
+	}
+
+	switch {
+	case fi.Mode.IsDir() && arch.symlinks.follow(fi):
+		return meta, fi, true
+	case fi.Mode.IsDir():
+		debug.Log("symlink %v points to a directory which is already archived, not following", target)
+	case fi.Mode.IsRegular() && arch.FollowSymlinks == FollowSymlinksAll:
+		return meta, fi, true
+	}
+	_ = meta.Close()
+	return nil, nil, false
+}
+
+// enterTargetParents marks the parent directories of all targets as being
+// archived, such that symlinks pointing to them are not followed.
+func (arch *Archiver) enterTargetParents(targets []backupTarget) {
+	for _, target := range targets {
+		dir, err := arch.FS.Abs(target.Path)
+		if err != nil {
+			continue
+		}
+		for parent := arch.FS.Dir(dir); parent != dir; parent = arch.FS.Dir(dir) {
+			dir = parent
+			meta, err := arch.FS.OpenFile(dir, 0, true)
+			if err != nil {
+				continue
+			}
+			fi, err := meta.Stat()
+			_ = meta.Close()
+			if err != nil {
+				continue
+			}
+			arch.symlinks.enter(fi)
+		}
+	}
+}
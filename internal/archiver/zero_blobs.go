@@ -0,0 +1,49 @@
+package archiver
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/restic/restic/internal/restic"
+)
+
+var zeroBlock [64 * 1024]byte
+
+// isZero returns true if buf only contains zero bytes.
+func isZero(buf []byte) bool {
+	for len(buf) > 0 {
+		n := min(len(buf), len(zeroBlock))
+		if !bytes.Equal(buf[:n], zeroBlock[:n]) {
+			return false
+		}
+		buf = buf[n:]
+	}
+	return true
+}
+
+// zeroBlobCache remembers the IDs of blobs which only contain zero bytes,
+// indexed by the size of the blob. Long runs of zero bytes are split into
+// chunks of the maximum chunk size, thus the cache stays small.
+type zeroBlobCache struct {
+	m   sync.Mutex
+	ids map[int]restic.ID
+}
+
+func newZeroBlobCache() *zeroBlobCache {
+	return &zeroBlobCache{ids: make(map[int]restic.ID)}
+}
+
+// get returns the ID of the blob of size zero bytes, if it has already been saved.
+func (c *zeroBlobCache) get(size int) (restic.ID, bool) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	id, ok := c.ids[size]
+	return id, ok
+}
+
+// add records that the blob of size zero bytes has been saved as id.
+func (c *zeroBlobCache) add(size int, id restic.ID) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	c.ids[size] = id
+}
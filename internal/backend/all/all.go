@@ -6,6 +6,7 @@ import (
 	"github.com/restic/restic/internal/backend/gs"
 	"github.com/restic/restic/internal/backend/local"
 	"github.com/restic/restic/internal/backend/location"
+	"github.com/restic/restic/internal/backend/mirror"
 	"github.com/restic/restic/internal/backend/rclone"
 	"github.com/restic/restic/internal/backend/rest"
 	"github.com/restic/restic/internal/backend/s3"
@@ -19,6 +20,7 @@ func Backends() *location.Registry {
 	backends.Register(b2.NewFactory())
 	backends.Register(gs.NewFactory())
 	backends.Register(local.NewFactory())
+	backends.Register(mirror.NewFactory())
 	backends.Register(rclone.NewFactory())
 	backends.Register(rest.NewFactory())
 	backends.Register(s3.NewFactory())
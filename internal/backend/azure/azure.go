@@ -11,6 +11,7 @@ import (
 	"net/http"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/restic/restic/internal/backend"
 	"github.com/restic/restic/internal/backend/layout"
@@ -27,6 +28,7 @@ import (
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
 	azContainer "github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+	"github.com/cenkalti/backoff/v4"
 )
 
 // Backend stores data on an azure endpoint.
@@ -37,6 +39,11 @@ type Backend struct {
 	layout.Layout
 
 	accessTier blob.AccessTier
+
+	immutabilityMode blob.ImmutabilityPolicySetting
+	// immutableContainer is set if the container has a time-based retention
+	// policy or a legal hold, which prevents removing files.
+	immutableContainer bool
 }
 
 const singleUploadMaxSize = 256 * 1024 * 1024
@@ -142,24 +149,73 @@ func open(cfg Config, rt http.RoundTripper) (*Backend, error) {
 		}
 	}
 
+	var immutabilityMode blob.ImmutabilityPolicySetting
+	switch strings.ToLower(cfg.ImmutabilityMode) {
+	case "", "unlocked":
+		immutabilityMode = blob.ImmutabilityPolicySettingUnlocked
+	case "locked":
+		immutabilityMode = blob.ImmutabilityPolicySettingLocked
+	default:
+		return nil, errors.Fatalf("invalid immutability mode %q, must be \"unlocked\" or \"locked\"", cfg.ImmutabilityMode)
+	}
+	if cfg.ImmutabilityPeriod < 0 {
+		return nil, errors.Fatalf("invalid immutability period %v", cfg.ImmutabilityPeriod)
+	}
+
 	be := &Backend{
-		container:   client,
-		cfg:         cfg,
-		connections: cfg.Connections,
-		Layout:      layout.NewDefaultLayout(cfg.Prefix, path.Join),
-		accessTier:  accessTier,
+		container:        client,
+		cfg:              cfg,
+		connections:      cfg.Connections,
+		Layout:           layout.NewDefaultLayout(cfg.Prefix, path.Join),
+		accessTier:       accessTier,
+		immutabilityMode: immutabilityMode,
 	}
 
 	return be, nil
 }
 
+// detectImmutability checks whether the container is protected by an
+// immutability policy and whether it supports the immutability policy
+// requested for new files.
+func (be *Backend) detectImmutability(props azContainer.GetPropertiesResponse) error {
+	if (props.HasImmutabilityPolicy != nil && *props.HasImmutabilityPolicy) ||
+		(props.HasLegalHold != nil && *props.HasLegalHold) {
+		debug.Log(" - container has an immutability policy or legal hold")
+		be.immutableContainer = true
+	}
+
+	if be.cfg.ImmutabilityPeriod > 0 && (props.IsImmutableStorageWithVersioningEnabled == nil || !*props.IsImmutableStorageWithVersioningEnabled) {
+		return errors.Fatalf("container %v does not support version-level immutability, which is required for azure.immutability-period", be.cfg.Container)
+	}
+	return nil
+}
+
 func supportedAccessTiers() []blob.AccessTier {
 	return []blob.AccessTier{blob.AccessTierHot, blob.AccessTierCool, blob.AccessTierCold, blob.AccessTierArchive}
 }
 
 // Open opens the Azure backend at specified container.
-func Open(_ context.Context, cfg Config, rt http.RoundTripper, _ func(string, ...interface{})) (*Backend, error) {
-	return open(cfg, rt)
+func Open(ctx context.Context, cfg Config, rt http.RoundTripper, _ func(string, ...interface{})) (*Backend, error) {
+	be, err := open(cfg, rt)
+	if err != nil {
+		return nil, err
+	}
+
+	props, err := be.container.GetProperties(ctx, &azContainer.GetPropertiesOptions{})
+	if err != nil {
+		// Not all SAS tokens allow reading the container properties. Without
+		// them the immutability policy cannot be detected, but the files can
+		// still be accessed. Other errors are reported once files are accessed.
+		debug.Log("unable to detect immutability policy: %v", err)
+		if cfg.ImmutabilityPeriod > 0 {
+			return nil, errors.Wrap(err, "container.GetProperties")
+		}
+		return be, nil
+	}
+	if err := be.detectImmutability(props); err != nil {
+		return nil, err
+	}
+	return be, nil
 }
 
 // Create opens the Azure backend at specified container and creates the container if
@@ -171,7 +227,7 @@ func Create(ctx context.Context, cfg Config, rt http.RoundTripper, _ func(string
 		return nil, errors.Wrap(err, "open")
 	}
 
-	_, err = be.container.GetProperties(ctx, &azContainer.GetPropertiesOptions{})
+	props, err := be.container.GetProperties(ctx, &azContainer.GetPropertiesOptions{})
 
 	if err != nil && bloberror.HasCode(err, bloberror.ContainerNotFound) {
 		_, err = be.container.Create(ctx, &azContainer.CreateOptions{})
@@ -179,6 +235,9 @@ func Create(ctx context.Context, cfg Config, rt http.RoundTripper, _ func(string
 		if err != nil {
 			return nil, errors.Wrap(err, "container.Create")
 		}
+		if err := be.detectImmutability(azContainer.GetPropertiesResponse{}); err != nil {
+			return nil, err
+		}
 	} else if err != nil && bloberror.HasCode(err, bloberror.AuthorizationFailure) {
 		// We ignore this Auth. Failure, as the failure is related to the type
 		// of SAS/SAT, not an actual real failure. If the token is invalid, we
@@ -187,6 +246,8 @@ func Create(ctx context.Context, cfg Config, rt http.RoundTripper, _ func(string
 		debug.Log("Ignoring AuthorizationFailure when calling GetProperties")
 	} else if err != nil {
 		return be, errors.Wrap(err, "container.GetProperties")
+	} else if err := be.detectImmutability(props); err != nil {
+		return nil, err
 	}
 
 	return be, nil
@@ -202,6 +263,10 @@ func (be *Backend) IsPermanentError(err error) bool {
 		return true
 	}
 
+	if bloberror.HasCode(err, bloberror.BlobImmutableDueToPolicy) {
+		return true
+	}
+
 	var aerr *azcore.ResponseError
 	if errors.As(err, &aerr) {
 		if aerr.StatusCode == http.StatusRequestedRangeNotSatisfiable || aerr.StatusCode == http.StatusUnauthorized || aerr.StatusCode == http.StatusForbidden {
@@ -232,6 +297,18 @@ func (be *Backend) useAccessTier(h backend.Handle) bool {
 	return isDataFile || notArchiveClass
 }
 
+// immutabilityPolicy returns the expiry time and mode of the immutability
+// policy for a new file, or nil if the file is not protected. Lock files are
+// never protected as they must be removed after each operation.
+func (be *Backend) immutabilityPolicy(h backend.Handle) (*time.Time, *blob.ImmutabilityPolicySetting) {
+	if be.cfg.ImmutabilityPeriod <= 0 || h.Type == backend.LockFile {
+		return nil, nil
+	}
+	expiry := time.Now().Add(be.cfg.ImmutabilityPeriod)
+	mode := be.immutabilityMode
+	return &expiry, &mode
+}
+
 // Save stores data in the backend at the handle.
 func (be *Backend) Save(ctx context.Context, h backend.Handle, rd backend.RewindReader) error {
 	objName := be.Filename(h)
@@ -248,10 +325,11 @@ func (be *Backend) Save(ctx context.Context, h backend.Handle, rd backend.Rewind
 
 	// If the file size is less than or equal to the max size for a single blob, use the single blob upload
 	// otherwise, use the block-based upload
+	expiry, mode := be.immutabilityPolicy(h)
 	if fileSize <= singleUploadMaxSize {
-		err = be.saveSingleBlob(ctx, objName, rd, accessTier)
+		err = be.saveSingleBlob(ctx, objName, rd, accessTier, expiry, mode)
 	} else {
-		err = be.saveLarge(ctx, objName, rd, accessTier)
+		err = be.saveLarge(ctx, objName, rd, accessTier, expiry, mode)
 	}
 
 	return err
@@ -260,7 +338,8 @@ func (be *Backend) Save(ctx context.Context, h backend.Handle, rd backend.Rewind
 // saveSingleBlob uploads data using a single Put Blob operation.
 // This method is more efficient for files under 5000 MiB as it requires only one API call
 // instead of the two calls (StageBlock + CommitBlockList) required by the block-based approach.
-func (be *Backend) saveSingleBlob(ctx context.Context, objName string, rd backend.RewindReader, accessTier blob.AccessTier) error {
+func (be *Backend) saveSingleBlob(ctx context.Context, objName string, rd backend.RewindReader, accessTier blob.AccessTier,
+	expiry *time.Time, mode *blob.ImmutabilityPolicySetting) error {
 	blockBlobClient := be.container.NewBlockBlobClient(objName)
 
 	buf := make([]byte, rd.Length())
@@ -271,8 +350,10 @@ func (be *Backend) saveSingleBlob(ctx context.Context, objName string, rd backen
 
 	reader := bytes.NewReader(buf)
 	opts := &blockblob.UploadOptions{
-		Tier:                    &accessTier,
-		TransactionalValidation: blob.TransferValidationTypeMD5(rd.Hash()),
+		Tier:                         &accessTier,
+		TransactionalValidation:      blob.TransferValidationTypeMD5(rd.Hash()),
+		ImmutabilityPolicyExpiryTime: expiry,
+		ImmutabilityPolicyMode:       mode,
 	}
 
 	debug.Log("Upload single blob %v with %d bytes", objName, len(buf))
@@ -280,7 +361,8 @@ func (be *Backend) saveSingleBlob(ctx context.Context, objName string, rd backen
 	return errors.Wrap(err, "Upload")
 }
 
-func (be *Backend) saveLarge(ctx context.Context, objName string, rd backend.RewindReader, accessTier blob.AccessTier) error {
+func (be *Backend) saveLarge(ctx context.Context, objName string, rd backend.RewindReader, accessTier blob.AccessTier,
+	expiry *time.Time, mode *blob.ImmutabilityPolicySetting) error {
 	blockBlobClient := be.container.NewBlockBlobClient(objName)
 
 	buf := make([]byte, singleBlockMaxSize)
@@ -328,7 +410,9 @@ func (be *Backend) saveLarge(ctx context.Context, objName string, rd backend.Rew
 	}
 
 	_, err := blockBlobClient.CommitBlockList(ctx, blocks, &blockblob.CommitBlockListOptions{
-		Tier: &accessTier,
+		Tier:                         &accessTier,
+		ImmutabilityPolicyExpiryTime: expiry,
+		ImmutabilityPolicyMode:       mode,
 	})
 
 	debug.Log("uploaded %d parts: %v", len(blocks), blocks)
@@ -382,9 +466,27 @@ func (be *Backend) Stat(ctx context.Context, h backend.Handle) (backend.FileInfo
 	return fi, nil
 }
 
+// SetStorageClass changes the access tier of the file h.
+func (be *Backend) SetStorageClass(ctx context.Context, h backend.Handle, class string) error {
+	for _, tier := range supportedAccessTiers() {
+		if strings.EqualFold(string(tier), class) {
+			_, err := be.container.NewBlobClient(be.Filename(h)).SetTier(ctx, tier, nil)
+			return errors.Wrap(err, "SetTier")
+		}
+	}
+	return errors.Errorf("unsupported access tier %q", class)
+}
+
 // Remove removes the blob with the given name and type.
 func (be *Backend) Remove(ctx context.Context, h backend.Handle) error {
 	objName := be.Filename(h)
+
+	// Lock files are still removed if possible, otherwise every operation
+	// would fail until the lock files expire.
+	if be.immutableContainer && h.Type != backend.LockFile {
+		return backoff.Permanent(errors.Errorf("cannot remove %v: container %v is protected by an immutability policy", objName, be.cfg.Container))
+	}
+
 	blob := be.container.NewBlobClient(objName)
 
 	_, err := blob.Delete(ctx, &azblob.DeleteBlobOptions{})
@@ -392,6 +494,9 @@ func (be *Backend) Remove(ctx context.Context, h backend.Handle) error {
 	if be.IsNotExist(err) {
 		return nil
 	}
+	if bloberror.HasCode(err, bloberror.BlobImmutableDueToPolicy) {
+		return backoff.Permanent(errors.Errorf("cannot remove %v: file is protected by an immutability policy", objName))
+	}
 
 	return errors.Wrap(err, "client.RemoveObject")
 }
@@ -433,6 +538,9 @@ func (be *Backend) List(ctx context.Context, t backend.FileType, fn func(backend
 				Name: path.Base(m),
 				Size: *item.Properties.ContentLength,
 			}
+			if item.Properties.AccessTier != nil {
+				fi.StorageClass = string(*item.Properties.AccessTier)
+			}
 
 			if ctx.Err() != nil {
 				return ctx.Err()
@@ -0,0 +1,60 @@
+package azure
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/options"
+	rtest "github.com/restic/restic/internal/test"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	azContainer "github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+)
+
+func newTestBackend(cfg Config) (*Backend, error) {
+	cfg.AccountName = "account"
+	cfg.AccountSAS = options.NewSecretString("sig=test")
+	cfg.Container = "container"
+	return open(cfg, http.DefaultTransport)
+}
+
+func TestImmutabilityPolicy(t *testing.T) {
+	be, err := newTestBackend(Config{})
+	rtest.OK(t, err)
+	expiry, mode := be.immutabilityPolicy(backend.Handle{Type: backend.PackFile})
+	rtest.Assert(t, expiry == nil && mode == nil, "unexpected immutability policy without period")
+
+	be, err = newTestBackend(Config{ImmutabilityPeriod: time.Hour, ImmutabilityMode: "Locked"})
+	rtest.OK(t, err)
+	expiry, mode = be.immutabilityPolicy(backend.Handle{Type: backend.PackFile})
+	rtest.Assert(t, expiry != nil && mode != nil, "missing immutability policy")
+	rtest.Assert(t, time.Until(*expiry) > 59*time.Minute, "unexpected expiry %v", *expiry)
+	rtest.Equals(t, blob.ImmutabilityPolicySettingLocked, *mode)
+
+	expiry, mode = be.immutabilityPolicy(backend.Handle{Type: backend.LockFile})
+	rtest.Assert(t, expiry == nil && mode == nil, "lock files must not be immutable")
+
+	_, err = newTestBackend(Config{ImmutabilityMode: "forever"})
+	rtest.Assert(t, err != nil, "expected error for invalid immutability mode")
+}
+
+func TestDetectImmutability(t *testing.T) {
+	yes := true
+
+	be, err := newTestBackend(Config{})
+	rtest.OK(t, err)
+	rtest.OK(t, be.detectImmutability(azContainer.GetPropertiesResponse{HasImmutabilityPolicy: &yes}))
+	rtest.Assert(t, be.immutableContainer, "immutability policy not detected")
+
+	err = be.Remove(context.TODO(), backend.Handle{Type: backend.PackFile, Name: "foo"})
+	rtest.Assert(t, err != nil, "expected error when removing from immutable container")
+
+	be, err = newTestBackend(Config{ImmutabilityPeriod: time.Hour})
+	rtest.OK(t, err)
+	err = be.detectImmutability(azContainer.GetPropertiesResponse{})
+	rtest.Assert(t, err != nil, "expected error for container without version-level immutability")
+	rtest.OK(t, be.detectImmutability(azContainer.GetPropertiesResponse{IsImmutableStorageWithVersioningEnabled: &yes}))
+}
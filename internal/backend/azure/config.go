@@ -5,8 +5,10 @@ import (
 	"path"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/limiter"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/options"
 )
@@ -24,6 +26,12 @@ type Config struct {
 
 	Connections uint   `option:"connections" help:"set a limit for the number of concurrent connections (default: 5)"`
 	AccessTier  string `option:"access-tier" help:"set the access tier for the blob storage (default: inferred from the storage account defaults)"`
+
+	ImmutabilityPeriod time.Duration `option:"immutability-period" help:"protect new files by an immutability policy for this duration (requires version-level immutability support)"`
+	ImmutabilityMode   string        `option:"immutability-mode" help:"set the immutability policy mode, either \"unlocked\" or \"locked\" (default: unlocked)"`
+
+	Proxy     string `option:"proxy" help:"connect via this HTTP proxy URL instead of the proxy from the environment, 'none' connects directly"`
+	ProxyAuth string `option:"proxy-auth" help:"authentication method for the proxy: basic, ntlm or negotiate (default: basic)"`
 }
 
 // NewConfig returns a new Config with the default values filled in.
@@ -35,6 +43,7 @@ func NewConfig() Config {
 
 func init() {
 	options.Register("azure", Config{})
+	options.Register("azure", limiter.BackendOptions{})
 }
 
 // ParseConfig parses the string s and extracts the azure config. The
@@ -85,3 +94,15 @@ func (cfg *Config) ApplyEnvironment(prefix string) {
 		cfg.EndpointSuffix = os.Getenv(prefix + "AZURE_ENDPOINT_SUFFIX")
 	}
 }
+
+var _ backend.TransportConfigurer = &Config{}
+
+// ConfigureTransport sets the proxy if requested.
+func (cfg *Config) ConfigureTransport(opts *backend.TransportOptions) {
+	if cfg.Proxy != "" {
+		opts.Proxy = cfg.Proxy
+	}
+	if cfg.ProxyAuth != "" {
+		opts.ProxyAuth = cfg.ProxyAuth
+	}
+}
@@ -29,6 +29,8 @@ type b2Backend struct {
 	layout.Layout
 
 	canDelete bool
+	// caps is nil if the capabilities of the application key are unknown
+	caps *backend.Capabilities
 }
 
 var errTooShort = fmt.Errorf("file is too short")
@@ -45,7 +47,8 @@ func NewFactory() location.Factory {
 
 type sniffingRoundTripper struct {
 	sync.Mutex
-	lastErr error
+	lastErr      error
+	capabilities []string
 	http.RoundTripper
 }
 
@@ -55,16 +58,28 @@ func (s *sniffingRoundTripper) RoundTrip(req *http.Request) (*http.Response, err
 		s.Lock()
 		s.lastErr = err
 		s.Unlock()
+		return res, err
 	}
-	return res, err
+
+	if isAuthorizeAccount(req) && res.StatusCode == http.StatusOK {
+		caps, err := sniffCapabilities(res)
+		if err != nil {
+			debug.Log("unable to parse capabilities: %v", err)
+		} else {
+			s.Lock()
+			s.capabilities = caps
+			s.Unlock()
+		}
+	}
+	return res, nil
 }
 
-func newClient(ctx context.Context, cfg Config, rt http.RoundTripper) (*b2.Client, error) {
+func newClient(ctx context.Context, cfg Config, rt http.RoundTripper) (*b2.Client, *sniffingRoundTripper, error) {
 	if cfg.AccountID == "" {
-		return nil, errors.Fatalf("unable to open B2 backend: Account ID ($B2_ACCOUNT_ID) is empty")
+		return nil, nil, errors.Fatalf("unable to open B2 backend: Account ID ($B2_ACCOUNT_ID) is empty")
 	}
 	if cfg.Key.String() == "" {
-		return nil, errors.Fatalf("unable to open B2 backend: Key ($B2_ACCOUNT_KEY) is empty")
+		return nil, nil, errors.Fatalf("unable to open B2 backend: Key ($B2_ACCOUNT_KEY) is empty")
 	}
 
 	sniffer := &sniffingRoundTripper{RoundTripper: rt}
@@ -77,13 +92,13 @@ func newClient(ctx context.Context, cfg Config, rt http.RoundTripper) (*b2.Clien
 	c, err := b2.NewClient(ctx, cfg.AccountID, cfg.Key.Unwrap(), opts...)
 	if err == context.DeadlineExceeded {
 		if sniffer.lastErr != nil {
-			return nil, sniffer.lastErr
+			return nil, nil, sniffer.lastErr
 		}
-		return nil, errors.New("connection to B2 failed")
+		return nil, nil, errors.New("connection to B2 failed")
 	} else if err != nil {
-		return nil, errors.Wrap(err, "b2.NewClient")
+		return nil, nil, errors.Wrap(err, "b2.NewClient")
 	}
-	return c, nil
+	return c, sniffer, nil
 }
 
 // Open opens a connection to the B2 service.
@@ -93,7 +108,7 @@ func Open(ctx context.Context, cfg Config, rt http.RoundTripper, _ func(string,
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	client, err := newClient(ctx, cfg, rt)
+	client, sniffer, err := newClient(ctx, cfg, rt)
 	if err != nil {
 		return nil, err
 	}
@@ -112,6 +127,9 @@ func Open(ctx context.Context, cfg Config, rt http.RoundTripper, _ func(string,
 		Layout:    layout.NewDefaultLayout(cfg.Prefix, path.Join),
 		canDelete: true,
 	}
+	if err := be.detectCapabilities(sniffer); err != nil {
+		return nil, err
+	}
 
 	return be, nil
 }
@@ -124,7 +142,7 @@ func Create(ctx context.Context, cfg Config, rt http.RoundTripper, _ func(string
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	client, err := newClient(ctx, cfg, rt)
+	client, sniffer, err := newClient(ctx, cfg, rt)
 	if err != nil {
 		return nil, err
 	}
@@ -143,6 +161,12 @@ func Create(ctx context.Context, cfg Config, rt http.RoundTripper, _ func(string
 		cfg:    cfg,
 		Layout: layout.NewDefaultLayout(cfg.Prefix, path.Join),
 	}
+	if err := be.detectCapabilities(sniffer); err != nil {
+		return nil, err
+	}
+	if be.caps != nil && !be.caps.Write {
+		return nil, errors.Fatal("unable to create B2 repository: application key lacks the writeFiles capability")
+	}
 	return be, nil
 }
 
@@ -172,7 +196,7 @@ func (be *b2Backend) IsNotExist(err error) bool {
 
 func (be *b2Backend) IsPermanentError(err error) bool {
 	// the library unfortunately endlessly retries authentication errors
-	return be.IsNotExist(err) || errors.Is(err, errTooShort)
+	return be.IsNotExist(err) || errors.Is(err, errTooShort) || errors.Is(err, errMissingCapability)
 }
 
 // Load runs fn with a reader that yields the contents of the file at h at the
@@ -221,6 +245,10 @@ func (be *b2Backend) openReader(ctx context.Context, h backend.Handle, length in
 
 // Save stores data in the backend at the handle.
 func (be *b2Backend) Save(ctx context.Context, h backend.Handle, rd backend.RewindReader) error {
+	if be.caps != nil && !be.caps.Write {
+		return fmt.Errorf("save %v: %w (writeFiles)", h, errMissingCapability)
+	}
+
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
@@ -257,6 +285,11 @@ func (be *b2Backend) Stat(ctx context.Context, h backend.Handle) (bi backend.Fil
 
 // Remove removes the blob with the given name and type.
 func (be *b2Backend) Remove(ctx context.Context, h backend.Handle) error {
+	if be.caps != nil && !be.caps.Delete && !be.caps.Write {
+		// hiding files requires the writeFiles capability
+		return fmt.Errorf("remove %v: %w (deleteFiles or writeFiles)", h, errMissingCapability)
+	}
+
 	// the retry backend will also repeat the remove method up to 10 times
 	for i := 0; i < 3; i++ {
 		obj := be.bucket.Object(be.Filename(h))
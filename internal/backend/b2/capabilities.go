@@ -0,0 +1,96 @@
+package b2
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+)
+
+// errMissingCapability is returned for operations which are not permitted by
+// the application key. Retrying such operations is pointless.
+var errMissingCapability = errors.New("application key lacks the required capability")
+
+// authorizeAccountResponse contains the part of the b2_authorize_account
+// response that lists the capabilities of the application key.
+type authorizeAccountResponse struct {
+	APIInfo struct {
+		StorageAPI struct {
+			Capabilities []string `json:"capabilities"`
+		} `json:"storageApi"`
+	} `json:"apiInfo"`
+}
+
+// isAuthorizeAccount returns whether req authorizes the application key.
+func isAuthorizeAccount(req *http.Request) bool {
+	return req.Header.Get("X-Blazer-Method") == "b2_authorize_account"
+}
+
+// sniffCapabilities extracts the capabilities from the response to
+// b2_authorize_account. The response body is replaced such that it can
+// still be read by the client library.
+func sniffCapabilities(res *http.Response) ([]string, error) {
+	buf, err := io.ReadAll(res.Body)
+	_ = res.Body.Close()
+	res.Body = io.NopCloser(bytes.NewReader(buf))
+	if err != nil {
+		return nil, err
+	}
+
+	var resp authorizeAccountResponse
+	if err := json.Unmarshal(buf, &resp); err != nil {
+		return nil, err
+	}
+	return resp.APIInfo.StorageAPI.Capabilities, nil
+}
+
+// newCapabilities maps the capabilities of a B2 application key to the
+// operations used by restic.
+func newCapabilities(caps []string) backend.Capabilities {
+	has := func(c string) bool {
+		return slices.Contains(caps, c)
+	}
+
+	return backend.Capabilities{
+		Read:   has("listFiles") && has("readFiles"),
+		Write:  has("writeFiles"),
+		Delete: has("deleteFiles"),
+		Raw:    slices.Clone(caps),
+	}
+}
+
+// Capabilities returns the capabilities of the application key.
+func (be *b2Backend) Capabilities() (backend.Capabilities, bool) {
+	if be.caps == nil {
+		return backend.Capabilities{}, false
+	}
+	return *be.caps, true
+}
+
+// detectCapabilities configures the backend according to the capabilities of
+// the application key. Without the deleteFiles capability, files are hidden
+// instead of deleted, which allows using keys for append-only access.
+func (be *b2Backend) detectCapabilities(sniffer *sniffingRoundTripper) error {
+	sniffer.Lock()
+	raw := sniffer.capabilities
+	sniffer.Unlock()
+	if raw == nil {
+		debug.Log("capabilities of application key are unknown")
+		return nil
+	}
+
+	caps := newCapabilities(raw)
+	debug.Log("application key capabilities: %v", caps)
+	if !caps.Read {
+		return errors.Fatalf("unable to open B2 backend: application key lacks the listFiles or readFiles capability, has %v", raw)
+	}
+
+	be.caps = &caps
+	be.canDelete = caps.Delete
+	return nil
+}
@@ -0,0 +1,95 @@
+package b2
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/errors"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+const testAuthorizeResponse = `{
+  "accountId": "abc",
+  "apiInfo": {
+    "storageApi": {
+      "apiUrl": "https://api000.backblazeb2.com",
+      "capabilities": ["listBuckets", "listFiles", "readFiles", "writeFiles"]
+    }
+  },
+  "authorizationToken": "token"
+}`
+
+func TestSniffCapabilities(t *testing.T) {
+	sniffer := &sniffingRoundTripper{RoundTripper: roundTripperFunc(func(_ *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(testAuthorizeResponse)),
+		}, nil
+	})}
+
+	req, err := http.NewRequest(http.MethodGet, "https://api.backblazeb2.com/b2api/v3/b2_listBuckets", nil)
+	rtest.OK(t, err)
+	req.Header.Set("X-Blazer-Method", "b2_list_buckets")
+	_, err = sniffer.RoundTrip(req)
+	rtest.OK(t, err)
+	rtest.Assert(t, sniffer.capabilities == nil, "unexpected capabilities from other request: %v", sniffer.capabilities)
+
+	req.Header.Set("X-Blazer-Method", "b2_authorize_account")
+	res, err := sniffer.RoundTrip(req)
+	rtest.OK(t, err)
+	rtest.Equals(t, []string{"listBuckets", "listFiles", "readFiles", "writeFiles"}, sniffer.capabilities)
+
+	// the response must still be readable by the client library
+	buf, err := io.ReadAll(res.Body)
+	rtest.OK(t, err)
+	rtest.Equals(t, testAuthorizeResponse, string(buf))
+}
+
+func TestDetectCapabilities(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		caps      []string
+		known     bool
+		canDelete bool
+		err       bool
+	}{
+		{"unknown", nil, false, true, false},
+		{"full", []string{"listFiles", "readFiles", "writeFiles", "deleteFiles"}, true, true, false},
+		{"append-only", []string{"listFiles", "readFiles", "writeFiles"}, true, false, false},
+		{"read-only", []string{"listFiles", "readFiles"}, true, false, false},
+		{"no-read", []string{"listFiles", "writeFiles"}, false, true, true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			be := &b2Backend{canDelete: true}
+			err := be.detectCapabilities(&sniffingRoundTripper{capabilities: test.caps})
+			rtest.Equals(t, test.err, err != nil)
+
+			_, known := be.Capabilities()
+			rtest.Equals(t, test.known, known)
+			rtest.Equals(t, test.canDelete, be.canDelete)
+		})
+	}
+}
+
+func TestMissingCapabilities(t *testing.T) {
+	caps := newCapabilities([]string{"listFiles", "readFiles"})
+	be := &b2Backend{caps: &caps}
+	h := backend.Handle{Type: backend.PackFile, Name: "foo"}
+
+	err := be.Save(context.TODO(), h, backend.NewByteReader([]byte("foo"), nil))
+	rtest.Assert(t, errors.Is(err, errMissingCapability), "unexpected error %v", err)
+	rtest.Assert(t, be.IsPermanentError(err), "missing capability should be a permanent error")
+
+	err = be.Remove(context.TODO(), h)
+	rtest.Assert(t, errors.Is(err, errMissingCapability), "unexpected error %v", err)
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/limiter"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/options"
 )
@@ -31,6 +32,7 @@ func NewConfig() Config {
 
 func init() {
 	options.Register("b2", Config{})
+	options.Register("b2", limiter.BackendOptions{})
 }
 
 var bucketName = regexp.MustCompile("^[a-zA-Z0-9-]+$")
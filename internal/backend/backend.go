@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"hash"
 	"io"
+	"time"
 )
 
 var ErrNoRepository = fmt.Errorf("repository does not exist")
@@ -99,6 +100,11 @@ type Properties struct {
 	// HasFlakyErrors states whether the backend may temporarily return errors
 	// that are considered as permanent for existing files.
 	HasFlakyErrors bool
+
+	// Retention states for how long pack files are protected against deletion
+	// after they were saved, for example by an S3 object lock. Zero means that
+	// files can be deleted at any time.
+	Retention time.Duration
 }
 
 type Unwrapper interface {
@@ -131,13 +137,54 @@ type FreezeBackend interface {
 	Unfreeze()
 }
 
+// Capabilities describes which operations are permitted by the credentials
+// used to access a backend.
+type Capabilities struct {
+	Read   bool `json:"read"`
+	Write  bool `json:"write"`
+	Delete bool `json:"delete"`
+	// Raw contains the backend-specific permissions the capabilities were derived from.
+	Raw []string `json:"raw,omitempty"`
+}
+
+// CapabilitiesBackend is implemented by backends which can determine the
+// permissions of their credentials.
+type CapabilitiesBackend interface {
+	Backend
+	// Capabilities returns the capabilities of the credentials. The second
+	// return value is false if they could not be determined.
+	Capabilities() (Capabilities, bool)
+}
+
+// StorageClassBackend is implemented by backends which can move files between
+// storage classes.
+type StorageClassBackend interface {
+	Backend
+	// SetStorageClass moves the file h to the given storage class, using the
+	// name of the class as reported in FileInfo.StorageClass.
+	SetStorageClass(ctx context.Context, h Handle, class string) error
+}
+
 // FileInfo is contains information about a file in the backend.
 type FileInfo struct {
 	Size int64
 	Name string
+	// ModTime is the time the file was saved. It is only reported by backends
+	// which have a Retention.
+	ModTime time.Time
+	// StorageClass is the storage class or access tier of the file as named by
+	// the backend, for example "GLACIER". It is empty if the backend does not
+	// support storage classes.
+	StorageClass string
 }
 
 // ApplyEnvironmenter fills in a backend configuration from the environment
 type ApplyEnvironmenter interface {
 	ApplyEnvironment(prefix string)
 }
+
+// TransportConfigurer adjusts the options of the HTTP transport used by a
+// backend to its configuration
+type TransportConfigurer interface {
+	ConfigureTransport(opts *TransportOptions)
+}
@@ -48,7 +48,7 @@ func (b *cacheBackend) Remove(ctx context.Context, h backend.Handle) error {
 
 func autoCacheTypes(h backend.Handle) bool {
 	switch h.Type {
-	case backend.IndexFile, backend.SnapshotFile:
+	case backend.IndexFile, backend.SnapshotFile, backend.AuditLogFile:
 		return true
 	case backend.PackFile:
 		return h.IsMetadata
@@ -164,15 +164,17 @@ func (b *cacheBackend) Load(ctx context.Context, h backend.Handle, length int, o
 	// try loading from cache without checking that the handle is actually cached
 	inCache, err := b.loadFromCache(h, length, offset, consumer)
 	if inCache {
+		b.hits.Add(1)
 		if err != nil {
 			debug.Log("error loading %v from cache: %v", h, err)
 		}
 		// the caller must explicitly use cache.Forget() to remove the cache entry
 		return err
 	}
+	b.misses.Add(1)
 
 	// if we don't automatically cache this file type, fall back to the backend
-	if !autoCacheTypes(h) {
+	if !autoCacheTypes(h) && !b.Cache.cachesDataPack(h) {
 		debug.Log("Load(%v, %v, %v): delegating to backend", h, length, offset)
 		return b.Backend.Load(ctx, h, length, offset, consumer)
 	}
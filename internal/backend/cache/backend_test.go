@@ -82,6 +82,9 @@ func TestBackend(t *testing.T) {
 	if !c.Has(h) {
 		t.Errorf("cache doesn't have file after load")
 	}
+	if hits, misses := c.Stats(); hits != 0 || misses != 1 {
+		t.Errorf("wrong cache stats, want 0 hits and 1 miss, got %v hits and %v misses", hits, misses)
+	}
 
 	// remove via cache
 	remove(t, wbe, h)
@@ -100,6 +103,9 @@ func TestBackend(t *testing.T) {
 
 	// load data via cache
 	loadAndCompare(t, wbe, h, data)
+	if hits, misses := c.Stats(); hits != 1 || misses != 1 {
+		t.Errorf("wrong cache stats, want 1 hit and 1 miss, got %v hits and %v misses", hits, misses)
+	}
 
 	// remove directly
 	remove(t, be, h)
@@ -2,11 +2,14 @@ package cache
 
 import (
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/pkg/errors"
@@ -21,6 +24,16 @@ type Cache struct {
 	Created bool
 
 	forgotten sync.Map
+
+	// number of loads served from the cache and from the backend
+	hits, misses atomic.Uint64
+	// number of data packs evicted from the cache
+	evictions atomic.Uint64
+	// counters already written to the statistics file
+	savedStats Stats
+
+	// limit is only set if the size of the cache is limited
+	limit *sizeLimit
 }
 
 const dirMode = 0700
@@ -46,6 +59,7 @@ var cacheLayoutPaths = map[backend.FileType]string{
 	backend.PackFile:     "data",
 	backend.SnapshotFile: "snapshots",
 	backend.IndexFile:    "index",
+	backend.AuditLogFile: "auditlog",
 }
 
 const cachedirTagSignature = "Signature: 8a477f597d28d172789f06886806bc55\n"
@@ -131,7 +145,7 @@ func New(id string, basedir string) (c *Cache, err error) {
 		}
 	}
 
-	for _, p := range cacheLayoutPaths {
+	for _, p := range append(slices.Collect(maps.Values(cacheLayoutPaths)), dataPackLayoutPath) {
 		if err = os.MkdirAll(filepath.Join(cachedir, p), dirMode); err != nil {
 			return nil, errors.WithStack(err)
 		}
@@ -244,3 +258,14 @@ func (c *Cache) Wrap(be backend.Backend, errorLog func(string, ...interface{}))
 func (c *Cache) BaseDir() string {
 	return c.Base
 }
+
+// Dir returns the cache directory for the repository.
+func (c *Cache) Dir() string {
+	return c.path
+}
+
+// Stats returns how many files were loaded from the cache (hits) and how many
+// had to be loaded from the backend (misses) since the cache was opened.
+func (c *Cache) Stats() (hits, misses uint64) {
+	return c.hits.Load(), c.misses.Load()
+}
@@ -18,7 +18,24 @@ func (c *Cache) filename(h backend.Handle) string {
 		panic("Name is empty or too short")
 	}
 	subdir := h.Name[:2]
-	return filepath.Join(c.path, cacheLayoutPaths[h.Type], subdir, h.Name)
+	dir := cacheLayoutPaths[h.Type]
+	if isDataPack(h) {
+		dir = dataPackLayoutPath
+	}
+	return filepath.Join(c.path, dir, subdir, h.Name)
+}
+
+// filenames returns all locations at which the file h may be cached. As
+// callers do not always know whether a pack contains tree or data blobs,
+// both locations are returned for pack files.
+func (c *Cache) filenames(h backend.Handle) []string {
+	if h.Type != backend.PackFile {
+		return []string{c.filename(h)}
+	}
+	h.IsMetadata = true
+	treeName := c.filename(h)
+	h.IsMetadata = false
+	return []string{treeName, c.filename(h)}
 }
 
 func (c *Cache) canBeCached(t backend.FileType) bool {
@@ -65,6 +82,7 @@ func (c *Cache) load(h backend.Handle, length int, offset int64) (io.ReadCloser,
 		}
 	}
 
+	c.used(h)
 	if length <= 0 {
 		return f, true, nil
 	}
@@ -95,7 +113,7 @@ func (c *Cache) save(h backend.Handle, rd io.Reader) error {
 		return err
 	}
 
-	_, err = io.Copy(f, rd)
+	size, err := io.Copy(f, rd)
 	if err != nil {
 		_ = f.Close()
 		_ = os.Remove(f.Name())
@@ -121,6 +139,9 @@ func (c *Cache) save(h backend.Handle, rd io.Reader) error {
 		// and the other process has written the desired contents to f.
 		err = nil
 	}
+	if err == nil {
+		c.added(h, size)
+	}
 
 	return errors.WithStack(err)
 }
@@ -147,12 +168,26 @@ func (c *Cache) remove(h backend.Handle) (bool, error) {
 		return false, nil
 	}
 
-	err := os.Remove(c.filename(h))
-	removed := err == nil
-	if errors.Is(err, os.ErrNotExist) {
-		err = nil
+	removed := false
+	for _, filename := range c.filenames(h) {
+		var size int64
+		if c.limit != nil {
+			if fi, err := os.Stat(filename); err == nil {
+				size = fi.Size()
+			}
+		}
+
+		err := os.Remove(filename)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return removed, err
+		}
+		removed = true
+		c.removed(filename, size)
 	}
-	return removed, err
+	return removed, nil
 }
 
 // Clear removes all files of type t from the cache that are not contained in
@@ -173,8 +208,8 @@ func (c *Cache) Clear(t backend.FileType, valid map[string]struct{}) error {
 			continue
 		}
 
-		// ignore ErrNotExist to gracefully handle multiple processes running Clear() concurrently
-		if err = os.Remove(c.filename(backend.Handle{Type: t, Name: id})); err != nil && !errors.Is(err, os.ErrNotExist) {
+		// remove ignores ErrNotExist to gracefully handle multiple processes running Clear() concurrently
+		if _, err = c.remove(backend.Handle{Type: t, Name: id}); err != nil {
 			return err
 		}
 	}
@@ -193,26 +228,34 @@ func (c *Cache) list(t backend.FileType) (map[string]struct{}, error) {
 	}
 
 	list := make(map[string]struct{})
-	dir := filepath.Join(c.path, cacheLayoutPaths[t])
-	err := filepath.Walk(dir, func(name string, fi os.FileInfo, err error) error {
-		if err != nil {
-			// ignore ErrNotExist to gracefully handle multiple processes clearing the cache
-			if errors.Is(err, os.ErrNotExist) {
+	dirs := []string{cacheLayoutPaths[t]}
+	if t == backend.PackFile {
+		dirs = append(dirs, dataPackLayoutPath)
+	}
+	for _, dir := range dirs {
+		err := filepath.Walk(filepath.Join(c.path, dir), func(name string, fi os.FileInfo, err error) error {
+			if err != nil {
+				// ignore ErrNotExist to gracefully handle multiple processes clearing the cache
+				if errors.Is(err, os.ErrNotExist) {
+					return nil
+				}
+				return errors.Wrap(err, "Walk")
+			}
+
+			if !isFile(fi) {
 				return nil
 			}
-			return errors.Wrap(err, "Walk")
-		}
 
-		if !isFile(fi) {
+			id := filepath.Base(name)
+			list[id] = struct{}{}
 			return nil
+		})
+		if err != nil {
+			return nil, err
 		}
+	}
 
-		id := filepath.Base(name)
-		list[id] = struct{}{}
-		return nil
-	})
-
-	return list, err
+	return list, nil
 }
 
 // Has returns true if the file is cached.
@@ -221,6 +264,10 @@ func (c *Cache) Has(h backend.Handle) bool {
 		return false
 	}
 
-	_, err := os.Stat(c.filename(h))
-	return err == nil
+	for _, filename := range c.filenames(h) {
+		if _, err := os.Stat(filename); err == nil {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,185 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/debug"
+)
+
+// dataPackLayoutPath is the directory for cached data packs. Data packs are
+// only cached if the size of the cache is limited. In contrast to all other
+// files, which are pinned, they are evicted if the cache grows too large.
+const dataPackLayoutPath = "datapacks"
+
+// sizeLimit tracks the size of the cache and the last use of cached data packs.
+type sizeLimit struct {
+	m sync.Mutex
+
+	maxSize int64
+	// size of all pinned files
+	pinned int64
+	// size of all data packs
+	dataSize  int64
+	dataPacks map[string]*cachedDataPack
+}
+
+type cachedDataPack struct {
+	size     int64
+	lastUsed time.Time
+}
+
+// isDataPack returns true if h refers to a pack file containing data blobs.
+func isDataPack(h backend.Handle) bool {
+	return h.Type == backend.PackFile && !h.IsMetadata
+}
+
+// cachesDataPack returns true if h is a data pack which should be cached
+// when it is loaded. This is only the case if the size of the cache is limited.
+func (c *Cache) cachesDataPack(h backend.Handle) bool {
+	return c.limit != nil && isDataPack(h)
+}
+
+// SetMaxSize limits the size of the cache for this repository to maxSize
+// bytes. Index files, snapshots and tree packs are never evicted. Data packs
+// are also cached, but the least recently used ones are evicted as soon as
+// the cache grows larger than maxSize.
+func (c *Cache) SetMaxSize(maxSize int64) error {
+	limit := &sizeLimit{
+		maxSize:   maxSize,
+		dataPacks: make(map[string]*cachedDataPack),
+	}
+
+	dirs := []string{dataPackLayoutPath}
+	for _, p := range cacheLayoutPaths {
+		dirs = append(dirs, p)
+	}
+	for _, dir := range dirs {
+		err := filepath.Walk(filepath.Join(c.path, dir), func(name string, fi os.FileInfo, err error) error {
+			if err != nil {
+				if errors.Is(err, os.ErrNotExist) {
+					return nil
+				}
+				return errors.Wrap(err, "Walk")
+			}
+			if !isFile(fi) {
+				return nil
+			}
+
+			if dir == dataPackLayoutPath {
+				limit.dataPacks[filepath.Base(name)] = &cachedDataPack{size: fi.Size(), lastUsed: fi.ModTime()}
+				limit.dataSize += fi.Size()
+			} else {
+				limit.pinned += fi.Size()
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	c.limit = limit
+	c.evict()
+	return nil
+}
+
+// added records that a file of the given size was saved in the cache.
+func (c *Cache) added(h backend.Handle, size int64) {
+	if c.limit == nil {
+		return
+	}
+
+	c.limit.m.Lock()
+	if isDataPack(h) {
+		if old, ok := c.limit.dataPacks[h.Name]; ok {
+			c.limit.dataSize -= old.size
+		}
+		c.limit.dataPacks[h.Name] = &cachedDataPack{size: size, lastUsed: time.Now()}
+		c.limit.dataSize += size
+	} else {
+		c.limit.pinned += size
+	}
+	c.limit.m.Unlock()
+
+	c.evict()
+}
+
+// removed records that the file at filename was removed from the cache.
+func (c *Cache) removed(filename string, size int64) {
+	if c.limit == nil {
+		return
+	}
+
+	c.limit.m.Lock()
+	defer c.limit.m.Unlock()
+
+	if filepath.Base(filepath.Dir(filepath.Dir(filename))) == dataPackLayoutPath {
+		if old, ok := c.limit.dataPacks[filepath.Base(filename)]; ok {
+			c.limit.dataSize -= old.size
+			delete(c.limit.dataPacks, filepath.Base(filename))
+		}
+		return
+	}
+	c.limit.pinned -= size
+}
+
+// used marks the cached data pack h as recently used.
+func (c *Cache) used(h backend.Handle) {
+	if c.limit == nil || !isDataPack(h) {
+		return
+	}
+
+	now := time.Now()
+	c.limit.m.Lock()
+	if p, ok := c.limit.dataPacks[h.Name]; ok {
+		p.lastUsed = now
+	}
+	c.limit.m.Unlock()
+
+	// store the last use such that it survives restarts, ignore errors
+	_ = os.Chtimes(c.filename(h), now, now)
+}
+
+// evict removes the least recently used data packs until the cache is no
+// longer larger than its maximum size.
+func (c *Cache) evict() {
+	c.limit.m.Lock()
+	defer c.limit.m.Unlock()
+
+	if c.limit.pinned+c.limit.dataSize <= c.limit.maxSize {
+		return
+	}
+
+	names := make([]string, 0, len(c.limit.dataPacks))
+	for name := range c.limit.dataPacks {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return c.limit.dataPacks[names[i]].lastUsed.Before(c.limit.dataPacks[names[j]].lastUsed)
+	})
+
+	for _, name := range names {
+		if c.limit.pinned+c.limit.dataSize <= c.limit.maxSize {
+			break
+		}
+
+		h := backend.Handle{Type: backend.PackFile, Name: name}
+		debug.Log("evicting %v from the cache", h)
+		err := os.Remove(c.filename(h))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			// the file may still be in use on Windows
+			debug.Log("unable to evict %v: %v", h, err)
+			continue
+		}
+
+		c.limit.dataSize -= c.limit.dataPacks[name].size
+		delete(c.limit.dataPacks, name)
+		c.evictions.Add(1)
+	}
+}
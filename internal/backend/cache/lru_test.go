@@ -0,0 +1,105 @@
+package cache
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func saveRandom(t testing.TB, c *Cache, h backend.Handle, size int) {
+	buf := rtest.Random(int(time.Now().UnixNano()), size)
+	rtest.OK(t, c.save(h, bytes.NewReader(buf)))
+}
+
+func TestSizeLimitEviction(t *testing.T) {
+	c := TestNewCache(t)
+	rtest.OK(t, c.SetMaxSize(3000))
+
+	tree := backend.Handle{Type: backend.PackFile, Name: restic.NewRandomID().String(), IsMetadata: true}
+	saveRandom(t, c, tree, 1000)
+
+	var packs []backend.Handle
+	for i := 0; i < 2; i++ {
+		h := backend.Handle{Type: backend.PackFile, Name: restic.NewRandomID().String()}
+		saveRandom(t, c, h, 1000)
+		packs = append(packs, h)
+		// make sure that the last use differs
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// use the first data pack such that the second one is evicted next
+	load(t, c, packs[0])
+	time.Sleep(10 * time.Millisecond)
+
+	third := backend.Handle{Type: backend.PackFile, Name: restic.NewRandomID().String()}
+	saveRandom(t, c, third, 1000)
+
+	rtest.Assert(t, c.Has(tree), "tree pack was evicted")
+	rtest.Assert(t, c.Has(packs[0]), "recently used data pack was evicted")
+	rtest.Assert(t, !c.Has(packs[1]), "least recently used data pack was not evicted")
+	rtest.Assert(t, c.Has(third), "new data pack was evicted")
+	rtest.Equals(t, uint64(1), c.evictions.Load())
+
+	// pinned files are never evicted, even if the cache is too large
+	for i := 0; i < 3; i++ {
+		saveRandom(t, c, backend.Handle{Type: backend.IndexFile, Name: restic.NewRandomID().String()}, 1000)
+	}
+	rtest.Assert(t, c.Has(tree), "tree pack was evicted")
+	rtest.Assert(t, !c.Has(packs[0]) && !c.Has(third), "data packs were not evicted")
+	rtest.Equals(t, uint64(3), c.evictions.Load())
+}
+
+func TestSizeLimitExistingFiles(t *testing.T) {
+	c := TestNewCache(t)
+
+	var packs []backend.Handle
+	for i := 0; i < 3; i++ {
+		h := backend.Handle{Type: backend.PackFile, Name: restic.NewRandomID().String()}
+		packs = append(packs, h)
+	}
+
+	// data packs are only cached in the separate directory if the cache size
+	// is limited, enable the limit with enough space for all of them
+	rtest.OK(t, c.SetMaxSize(10000))
+	for i, h := range packs {
+		saveRandom(t, c, h, 1000)
+		ts := time.Now().Add(time.Duration(i-len(packs)) * time.Hour)
+		rtest.OK(t, os.Chtimes(c.filename(h), ts, ts))
+	}
+
+	// the last use of existing data packs is restored from the modification time
+	rtest.OK(t, c.SetMaxSize(1000))
+	rtest.Assert(t, !c.Has(packs[0]), "oldest data pack was not evicted")
+	rtest.Assert(t, !c.Has(packs[1]), "second oldest data pack was not evicted")
+	rtest.Assert(t, c.Has(packs[2]), "newest data pack was evicted")
+}
+
+func TestStats(t *testing.T) {
+	c := TestNewCache(t)
+
+	stats, err := LoadStats(c.path)
+	rtest.OK(t, err)
+	rtest.Equals(t, Stats{}, stats)
+	rtest.Equals(t, float64(0), stats.HitRate())
+
+	c.hits.Add(3)
+	c.misses.Add(1)
+	rtest.OK(t, c.SaveStats())
+	stats, err = LoadStats(c.path)
+	rtest.OK(t, err)
+	rtest.Equals(t, Stats{Hits: 3, Misses: 1}, stats)
+	rtest.Equals(t, float64(75), stats.HitRate())
+
+	// only the changes since the last save are added
+	c.hits.Add(1)
+	c.evictions.Add(2)
+	rtest.OK(t, c.SaveStats())
+	stats, err = LoadStats(c.path)
+	rtest.OK(t, err)
+	rtest.Equals(t, Stats{Hits: 4, Misses: 1, Evictions: 2}, stats)
+}
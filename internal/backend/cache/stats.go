@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// statsFile is the name of the file in the cache directory of a repository
+// which accumulates the statistics over all runs.
+const statsFile = "stats.json"
+
+// Stats are the statistics of a cache directory.
+type Stats struct {
+	// Hits is the number of files loaded from the cache.
+	Hits uint64 `json:"hits"`
+	// Misses is the number of files which had to be loaded from the backend.
+	Misses uint64 `json:"misses"`
+	// Evictions is the number of data packs evicted from the cache.
+	Evictions uint64 `json:"evictions"`
+}
+
+// HitRate returns the percentage of files which were loaded from the cache.
+func (s Stats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses) * 100
+}
+
+// LoadStats returns the statistics accumulated in the cache directory dir of
+// a repository. If no statistics were saved yet, all counters are zero.
+func LoadStats(dir string) (Stats, error) {
+	var stats Stats
+	buf, err := os.ReadFile(filepath.Join(dir, statsFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return stats, nil
+	}
+	if err != nil {
+		return stats, errors.WithStack(err)
+	}
+
+	err = json.Unmarshal(buf, &stats)
+	return stats, errors.Wrap(err, "Unmarshal")
+}
+
+// SaveStats adds the hits, misses and evictions since the last call to the
+// statistics stored in the cache directory.
+func (c *Cache) SaveStats() error {
+	current := Stats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.evictions.Load(),
+	}
+	if current == c.savedStats {
+		return nil
+	}
+
+	stats, err := LoadStats(c.path)
+	if err != nil {
+		// start from scratch if the file is damaged
+		stats = Stats{}
+	}
+	stats.Hits += current.Hits - c.savedStats.Hits
+	stats.Misses += current.Misses - c.savedStats.Misses
+	stats.Evictions += current.Evictions - c.savedStats.Evictions
+
+	buf, err := json.Marshal(stats)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	// write to a temporary file first, such that concurrent restic processes
+	// never read a partially written file
+	f, err := os.CreateTemp(c.path, "tmp-stats-")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if _, err := f.Write(buf); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return errors.WithStack(err)
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return errors.WithStack(err)
+	}
+	if err := os.Rename(f.Name(), filepath.Join(c.path, statsFile)); err != nil {
+		_ = os.Remove(f.Name())
+		return errors.WithStack(err)
+	}
+
+	c.savedStats = current
+	return nil
+}
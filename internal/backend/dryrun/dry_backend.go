@@ -4,6 +4,7 @@ import (
 	"context"
 	"hash"
 	"io"
+	"sync"
 
 	"github.com/restic/restic/internal/backend"
 	"github.com/restic/restic/internal/debug"
@@ -16,6 +17,23 @@ import (
 // This is used for `backup --dry-run`.
 type Backend struct {
 	b backend.Backend
+
+	m     sync.Mutex
+	stats Stats
+}
+
+// Stats describes the files which would have been saved to the backend.
+type Stats struct {
+	Files     int
+	DataPacks uint64
+	TreePacks uint64
+	Indexes   uint64
+	Other     uint64
+}
+
+// Total returns the size of all files which would have been saved.
+func (s Stats) Total() uint64 {
+	return s.DataPacks + s.TreePacks + s.Indexes + s.Other
 }
 
 // statically ensure that Backend implements backend.Backend.
@@ -28,15 +46,37 @@ func New(be backend.Backend) *Backend {
 }
 
 // Save adds new Data to the backend.
-func (be *Backend) Save(_ context.Context, h backend.Handle, _ backend.RewindReader) error {
+func (be *Backend) Save(_ context.Context, h backend.Handle, rd backend.RewindReader) error {
 	if err := h.Valid(); err != nil {
 		return err
 	}
 
-	// don't save anything, just return ok
+	// don't save anything, just record the size and return ok
+	size := uint64(rd.Length())
+	be.m.Lock()
+	defer be.m.Unlock()
+
+	be.stats.Files++
+	switch {
+	case h.Type == backend.PackFile && h.IsMetadata:
+		be.stats.TreePacks += size
+	case h.Type == backend.PackFile:
+		be.stats.DataPacks += size
+	case h.Type == backend.IndexFile:
+		be.stats.Indexes += size
+	default:
+		be.stats.Other += size
+	}
 	return nil
 }
 
+// Stats returns the number and size of the files which would have been saved.
+func (be *Backend) Stats() Stats {
+	be.m.Lock()
+	defer be.m.Unlock()
+	return be.stats
+}
+
 // Remove deletes a file from the backend.
 func (be *Backend) Remove(_ context.Context, _ backend.Handle) error {
 	return nil
@@ -117,3 +117,37 @@ func TestDry(t *testing.T) {
 		}
 	}
 }
+
+func TestDryStats(t *testing.T) {
+	ctx := context.TODO()
+	d, m := newBackends()
+
+	for _, h := range []backend.Handle{
+		{Type: backend.PackFile, Name: "data"},
+		{Type: backend.PackFile, Name: "tree", IsMetadata: true},
+		{Type: backend.IndexFile, Name: "index"},
+		{Type: backend.SnapshotFile, Name: "snapshot"},
+	} {
+		err := d.Save(ctx, h, backend.NewByteReader([]byte(h.Name), d.Hasher()))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := dryrun.Stats{Files: 4, DataPacks: 4, TreePacks: 4, Indexes: 5, Other: 8}
+	if stats := d.Stats(); stats != want {
+		t.Errorf("Stats() = %+v, want %+v", stats, want)
+	}
+	if total := d.Stats().Total(); total != 21 {
+		t.Errorf("Total() = %v, want 21", total)
+	}
+
+	// nothing was saved to the underlying backend
+	err := m.List(ctx, backend.PackFile, func(fi backend.FileInfo) error {
+		t.Errorf("unexpected file %v", fi.Name)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -18,6 +18,9 @@ const (
 	SnapshotFile
 	IndexFile
 	ConfigFile
+	AuditLogFile
+	GenerationFile
+	QuarantineFile
 )
 
 // Keep in sync with restic.FileType.String().
@@ -37,6 +40,12 @@ func (t FileType) String() string {
 		s = "index"
 	case ConfigFile:
 		s = "config"
+	case AuditLogFile:
+		s = "auditlog"
+	case GenerationFile:
+		s = "generation"
+	case QuarantineFile:
+		s = "quarantine"
 	}
 	return s
 }
@@ -65,6 +74,9 @@ func (h Handle) Valid() error {
 	case SnapshotFile:
 	case IndexFile:
 	case ConfigFile:
+	case AuditLogFile:
+	case GenerationFile:
+	case QuarantineFile:
 	default:
 		return errors.Errorf("invalid Type %d", h.Type)
 	}
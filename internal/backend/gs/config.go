@@ -1,11 +1,13 @@
 package gs
 
 import (
+	"encoding/base64"
 	"os"
 	"path"
 	"strings"
 
 	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/limiter"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/options"
 )
@@ -18,8 +20,10 @@ type Config struct {
 	Bucket    string
 	Prefix    string
 
-	Connections uint   `option:"connections" help:"set a limit for the number of concurrent connections (default: 5)"`
-	Region      string `option:"region" help:"region to create the bucket in (default: us)"`
+	Connections   uint                 `option:"connections" help:"set a limit for the number of concurrent connections (default: 5)"`
+	Region        string               `option:"region" help:"region to create the bucket in (default: us)"`
+	EncryptionKey options.SecretString `option:"encryption-key" help:"base64-encoded AES-256 customer-supplied encryption key for all objects (default: $GOOGLE_ENCRYPTION_KEY)"`
+	KMSKeyName    string               `option:"kms-key-name" help:"name of the Cloud KMS key to encrypt new objects with (default: $GOOGLE_KMS_KEY_NAME)"`
 }
 
 // NewConfig returns a new Config with the default values filled in.
@@ -32,6 +36,7 @@ func NewConfig() Config {
 
 func init() {
 	options.Register("gs", Config{})
+	options.Register("gs", limiter.BackendOptions{})
 }
 
 // ParseConfig parses the string s and extracts the gcs config. The
@@ -66,4 +71,32 @@ func (cfg *Config) ApplyEnvironment(prefix string) {
 	if cfg.ProjectID == "" {
 		cfg.ProjectID = os.Getenv(prefix + "GOOGLE_PROJECT_ID")
 	}
+	if cfg.EncryptionKey.String() == "" {
+		cfg.EncryptionKey = options.NewSecretString(os.Getenv(prefix + "GOOGLE_ENCRYPTION_KEY"))
+	}
+	if cfg.KMSKeyName == "" {
+		cfg.KMSKeyName = os.Getenv(prefix + "GOOGLE_KMS_KEY_NAME")
+	}
+}
+
+// encryptionKey returns the decoded customer-supplied encryption key, or nil
+// if none is configured.
+func (cfg *Config) encryptionKey() ([]byte, error) {
+	encoded := cfg.EncryptionKey.Unwrap()
+	if encoded == "" {
+		return nil, nil
+	}
+
+	if cfg.KMSKeyName != "" {
+		return nil, errors.Fatal("gs: encryption-key and kms-key-name cannot be used together")
+	}
+
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.Fatalf("gs: invalid encryption key: %v", err)
+	}
+	if len(key) != 32 {
+		return nil, errors.Fatalf("gs: invalid encryption key: expected 32 bytes for AES-256, got %d", len(key))
+	}
+	return key, nil
 }
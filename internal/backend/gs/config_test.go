@@ -1,9 +1,12 @@
 package gs
 
 import (
+	"encoding/base64"
 	"testing"
 
 	"github.com/restic/restic/internal/backend/test"
+	"github.com/restic/restic/internal/options"
+	rtest "github.com/restic/restic/internal/test"
 )
 
 var configTests = []test.ConfigTestData[Config]{
@@ -30,3 +33,26 @@ var configTests = []test.ConfigTestData[Config]{
 func TestParseConfig(t *testing.T) {
 	test.ParseConfigTester(t, ParseConfig, configTests)
 }
+
+func TestEncryptionKey(t *testing.T) {
+	cfg := NewConfig()
+	key, err := cfg.encryptionKey()
+	rtest.OK(t, err)
+	rtest.Assert(t, key == nil, "unexpected key %v", key)
+
+	cfg.EncryptionKey = options.NewSecretString(base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	key, err = cfg.encryptionKey()
+	rtest.OK(t, err)
+	rtest.Equals(t, 32, len(key))
+
+	cfg.KMSKeyName = "projects/p/locations/us/keyRings/r/cryptoKeys/k"
+	_, err = cfg.encryptionKey()
+	rtest.Assert(t, err != nil, "expected error for both encryption key and KMS key")
+
+	for _, invalid := range []string{"not base64!", base64.StdEncoding.EncodeToString(make([]byte, 16))} {
+		cfg := NewConfig()
+		cfg.EncryptionKey = options.NewSecretString(invalid)
+		_, err := cfg.encryptionKey()
+		rtest.Assert(t, err != nil, "expected error for key %q", invalid)
+	}
+}
@@ -42,6 +42,11 @@ type gs struct {
 	region      string
 	bucket      *storage.BucketHandle
 	layout.Layout
+
+	// customer-supplied encryption key, nil if unused
+	encryptionKey []byte
+	// Cloud KMS key for new objects, empty if unused
+	kmsKeyName string
 }
 
 // Ensure that *Backend implements backend.Backend.
@@ -95,6 +100,11 @@ func (be *gs) bucketExists(ctx context.Context, bucket *storage.BucketHandle) (b
 func open(cfg Config, rt http.RoundTripper) (*gs, error) {
 	debug.Log("open, config %#v", cfg)
 
+	encryptionKey, err := cfg.encryptionKey()
+	if err != nil {
+		return nil, err
+	}
+
 	gcsClient, err := getStorageClient(rt)
 	if err != nil {
 		return nil, errors.Wrap(err, "getStorageClient")
@@ -108,11 +118,24 @@ func open(cfg Config, rt http.RoundTripper) (*gs, error) {
 		region:      cfg.Region,
 		bucket:      gcsClient.Bucket(cfg.Bucket),
 		Layout:      layout.NewDefaultLayout(cfg.Prefix, path.Join),
+
+		encryptionKey: encryptionKey,
+		kmsKeyName:    cfg.KMSKeyName,
 	}
 
 	return be, nil
 }
 
+// object returns the handle for the object objName. If a customer-supplied
+// encryption key is configured, it is sent along with all requests.
+func (be *gs) object(objName string) *storage.ObjectHandle {
+	obj := be.bucket.Object(objName)
+	if be.encryptionKey != nil {
+		obj = obj.Key(be.encryptionKey)
+	}
+	return obj
+}
+
 // Open opens the gs backend at the specified bucket.
 func Open(_ context.Context, cfg Config, rt http.RoundTripper, _ func(string, ...interface{})) (backend.Backend, error) {
 	return open(cfg, rt)
@@ -144,6 +167,9 @@ func Create(ctx context.Context, cfg Config, rt http.RoundTripper, _ func(string
 		bucketAttrs := &storage.BucketAttrs{
 			Location: cfg.Region,
 		}
+		if cfg.KMSKeyName != "" {
+			bucketAttrs.Encryption = &storage.BucketEncryption{DefaultKMSKeyName: cfg.KMSKeyName}
+		}
 		// Bucket doesn't exist, try to create it.
 		if err := be.bucket.Create(ctx, be.projectID, bucketAttrs); err != nil {
 			// Always an error, as the bucket definitely doesn't exist.
@@ -216,8 +242,9 @@ func (be *gs) Save(ctx context.Context, h backend.Handle, rd backend.RewindReade
 	//
 	// restic typically writes small blobs (4MB-30MB), so the resumable
 	// uploads are not providing significant benefit anyways.
-	w := be.bucket.Object(objName).NewWriter(ctx)
+	w := be.object(objName).NewWriter(ctx)
 	w.ChunkSize = 0
+	w.KMSKeyName = be.kmsKeyName
 	w.MD5 = rd.Hash()
 	wbytes, err := io.Copy(w, rd)
 	cerr := w.Close()
@@ -253,7 +280,7 @@ func (be *gs) openReader(ctx context.Context, h backend.Handle, length int, offs
 
 	objName := be.Filename(h)
 
-	r, err := be.bucket.Object(objName).NewRangeReader(ctx, offset, int64(length))
+	r, err := be.object(objName).NewRangeReader(ctx, offset, int64(length))
 	if err != nil {
 		return nil, err
 	}
@@ -270,7 +297,7 @@ func (be *gs) openReader(ctx context.Context, h backend.Handle, length int, offs
 func (be *gs) Stat(ctx context.Context, h backend.Handle) (bi backend.FileInfo, err error) {
 	objName := be.Filename(h)
 
-	attr, err := be.bucket.Object(objName).Attrs(ctx)
+	attr, err := be.object(objName).Attrs(ctx)
 
 	if err != nil {
 		return backend.FileInfo{}, errors.WithStack(err)
@@ -283,7 +310,7 @@ func (be *gs) Stat(ctx context.Context, h backend.Handle) (bi backend.FileInfo,
 func (be *gs) Remove(ctx context.Context, h backend.Handle) error {
 	objName := be.Filename(h)
 
-	err := be.bucket.Object(objName).Delete(ctx)
+	err := be.object(objName).Delete(ctx)
 
 	if be.IsNotExist(err) {
 		err = nil
@@ -0,0 +1,280 @@
+package backend
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"golang.org/x/net/http/httpproxy"
+)
+
+// Supported values for the proxy authentication method.
+const (
+	ProxyAuthBasic     = "basic"
+	ProxyAuthNTLM      = "ntlm"
+	ProxyAuthNegotiate = "negotiate"
+)
+
+// ProxyNone disables the proxy for a backend, even if one is configured in
+// the environment.
+const ProxyNone = "none"
+
+// proxyFunc returns the function which selects the proxy for a request. If no
+// proxy is set in opts, the proxy is taken from the environment.
+func proxyFunc(opts TransportOptions) (func(*url.URL) (*url.URL, error), *url.URL, error) {
+	switch opts.Proxy {
+	case "":
+		return httpproxy.FromEnvironment().ProxyFunc(), nil, nil
+	case ProxyNone:
+		return func(*url.URL) (*url.URL, error) { return nil, nil }, nil, nil
+	}
+
+	proxy, err := url.Parse(proxyPasswordFromEnvironment(opts.Proxy))
+	if err != nil || proxy.Host == "" {
+		return nil, nil, errors.Errorf("invalid proxy URL %q", opts.Proxy)
+	}
+	if proxy.Scheme != "http" && proxy.Scheme != "https" {
+		return nil, nil, errors.Errorf("unsupported proxy scheme %q, only http and https are supported", proxy.Scheme)
+	}
+
+	// hosts listed in NO_PROXY are still accessed directly
+	cfg := httpproxy.Config{
+		HTTPProxy:  proxy.String(),
+		HTTPSProxy: proxy.String(),
+		NoProxy:    httpproxy.FromEnvironment().NoProxy,
+	}
+	return cfg.ProxyFunc(), proxy, nil
+}
+
+// configureProxy sets up the proxy of tr according to opts.
+func configureProxy(tr *http.Transport, opts TransportOptions) error {
+	selectProxy, proxy, err := proxyFunc(opts)
+	if err != nil {
+		return err
+	}
+
+	switch opts.ProxyAuth {
+	case "", ProxyAuthBasic:
+		// the transport sends the credentials contained in the proxy URL
+		tr.Proxy = func(req *http.Request) (*url.URL, error) {
+			return selectProxy(req.URL)
+		}
+		return nil
+	case ProxyAuthNTLM, ProxyAuthNegotiate:
+	default:
+		return errors.Errorf("unknown proxy authentication method %q", opts.ProxyAuth)
+	}
+
+	if proxy == nil {
+		return errors.Errorf("proxy authentication %q requires a proxy URL", opts.ProxyAuth)
+	}
+	password, hasPassword := proxy.User.Password()
+	if proxy.User.Username() == "" || !hasPassword {
+		return errors.Errorf("proxy authentication %q requires a username and password in the proxy URL", opts.ProxyAuth)
+	}
+
+	// NTLM authenticates a connection instead of a request, which the
+	// transport does not support. Thus, all connections are tunneled through
+	// the proxy using CONNECT and the authentication is handled here.
+	d := &proxyDialer{
+		proxy:   proxy,
+		scheme:  proxyAuthScheme(opts.ProxyAuth),
+		cred:    newNTLMCredentials(proxy.User.Username(), password),
+		dial:    tr.DialContext,
+		tlsConf: tr.TLSClientConfig,
+	}
+	tr.Proxy = nil
+	tr.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		target := &url.URL{Scheme: "https", Host: addr}
+		p, err := selectProxy(target)
+		if err != nil {
+			return nil, err
+		}
+		if p == nil {
+			return d.dial(ctx, network, addr)
+		}
+		return d.DialContext(ctx, network, addr)
+	}
+	return nil
+}
+
+func proxyAuthScheme(auth string) string {
+	if auth == ProxyAuthNegotiate {
+		return "Negotiate"
+	}
+	return "NTLM"
+}
+
+// proxyDialer opens connections tunneled through an HTTP proxy which
+// requires NTLM authentication. The Negotiate scheme is supported using NTLM
+// tokens, Kerberos is not used.
+type proxyDialer struct {
+	proxy   *url.URL
+	scheme  string
+	cred    ntlmCredentials
+	dial    func(ctx context.Context, network, addr string) (net.Conn, error)
+	tlsConf *tls.Config
+}
+
+// DialContext connects to addr through the proxy.
+func (d *proxyDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	proxyAddr := d.proxy.Host
+	if d.proxy.Port() == "" {
+		port := "80"
+		if d.proxy.Scheme == "https" {
+			port = "443"
+		}
+		proxyAddr = net.JoinHostPort(d.proxy.Hostname(), port)
+	}
+
+	conn, err := d.dial(ctx, network, proxyAddr)
+	if err != nil {
+		return nil, err
+	}
+	if d.proxy.Scheme == "https" {
+		cfg := d.tlsConf.Clone()
+		cfg.ServerName = d.proxy.Hostname()
+		cfg.NextProtos = nil
+		tlsConn := tls.Client(conn, cfg)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	// abort the handshake with the proxy if the context is cancelled
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	tunnel, err := d.connect(conn, addr)
+	if !stop() {
+		err = ctx.Err()
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return tunnel, nil
+}
+
+// connect sends a CONNECT request for addr and answers the authentication
+// challenge of the proxy.
+func (d *proxyDialer) connect(conn net.Conn, addr string) (net.Conn, error) {
+	br := bufio.NewReader(conn)
+
+	res, err := d.roundTrip(conn, br, addr, ntlmNegotiateMessage())
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode == http.StatusProxyAuthRequired {
+		challenge, err := d.challenge(res)
+		if err != nil {
+			return nil, err
+		}
+		if res.Close {
+			return nil, errors.Errorf("proxy %v closed the connection during authentication", d.proxy.Host)
+		}
+
+		msg, err := ntlmAuthenticateMessage(d.cred, challenge)
+		if err != nil {
+			return nil, err
+		}
+		res, err = d.roundTrip(conn, br, addr, msg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if res.StatusCode != http.StatusOK {
+		if res.StatusCode == http.StatusProxyAuthRequired {
+			return nil, errors.Errorf("proxy %v rejected the %v authentication for user %q", d.proxy.Host, d.scheme, d.proxy.User.Username())
+		}
+		return nil, errors.Errorf("proxy %v returned %v for CONNECT to %v", d.proxy.Host, res.Status, addr)
+	}
+
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+// roundTrip sends a CONNECT request with the authentication token and reads
+// the response. The response body is discarded.
+func (d *proxyDialer) roundTrip(conn net.Conn, br *bufio.Reader, addr string, token []byte) (*http.Response, error) {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: http.Header{
+			"Proxy-Authorization": []string{d.scheme + " " + base64.StdEncoding.EncodeToString(token)},
+			"Proxy-Connection":    []string{"Keep-Alive"},
+		},
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, errors.Wrap(err, "write CONNECT request")
+	}
+
+	res, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, errors.Wrap(err, "read CONNECT response")
+	}
+	debug.Log("CONNECT %v via %v: %v", addr, d.proxy.Host, res.Status)
+
+	if res.StatusCode != http.StatusOK {
+		_, _ = io.Copy(io.Discard, res.Body)
+		_ = res.Body.Close()
+	}
+	return res, nil
+}
+
+// challenge extracts the NTLM challenge from the response of the proxy.
+func (d *proxyDialer) challenge(res *http.Response) ([]byte, error) {
+	var offered []string
+	for _, header := range res.Header.Values("Proxy-Authenticate") {
+		scheme, token, _ := strings.Cut(strings.TrimSpace(header), " ")
+		offered = append(offered, scheme)
+		if !strings.EqualFold(scheme, d.scheme) || token == "" {
+			continue
+		}
+		return base64.StdEncoding.DecodeString(strings.TrimSpace(token))
+	}
+	return nil, errors.Errorf("proxy %v did not send a %v challenge, supported authentication methods: %v", d.proxy.Host, d.scheme, strings.Join(offered, ", "))
+}
+
+// bufferedConn returns data which was already read from the connection
+// before reading from it again.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+// proxyPasswordFromEnvironment fills in the password of the proxy URL from
+// the environment variable RESTIC_PROXY_PASSWORD, if the URL contains a
+// username but no password.
+func proxyPasswordFromEnvironment(proxy string) string {
+	u, err := url.Parse(proxy)
+	if err != nil || u.User == nil {
+		return proxy
+	}
+	if _, ok := u.User.Password(); ok {
+		return proxy
+	}
+	password := os.Getenv("RESTIC_PROXY_PASSWORD")
+	if password == "" {
+		return proxy
+	}
+	u.User = url.UserPassword(u.User.Username(), password)
+	return u.String()
+}
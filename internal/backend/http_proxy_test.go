@@ -0,0 +1,233 @@
+package backend
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func testNTLMChallengeMessage(serverChallenge [8]byte, targetInfo []byte) []byte {
+	msg := make([]byte, 48)
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], 2)
+	binary.LittleEndian.PutUint32(msg[20:], ntlmNegotiateFlags|ntlmNegotiateTargetInfo)
+	copy(msg[24:], serverChallenge[:])
+	binary.LittleEndian.PutUint16(msg[40:], uint16(len(targetInfo)))
+	binary.LittleEndian.PutUint16(msg[42:], uint16(len(targetInfo)))
+	binary.LittleEndian.PutUint32(msg[44:], uint32(len(msg)))
+	return append(msg, targetInfo...)
+}
+
+// verifyNTLMAuthenticate checks the NT response contained in an
+// AUTHENTICATE_MESSAGE.
+func verifyNTLMAuthenticate(msg []byte, cred ntlmCredentials, c ntlmChallenge) bool {
+	if len(msg) < 64 || binary.LittleEndian.Uint32(msg[8:]) != 3 {
+		return false
+	}
+	field := func(i int) []byte {
+		length := int(binary.LittleEndian.Uint16(msg[12+8*i:]))
+		offset := int(binary.LittleEndian.Uint32(msg[12+8*i+4:]))
+		if offset+length > len(msg) {
+			return nil
+		}
+		return msg[offset : offset+length]
+	}
+	nt := field(1)
+	if len(nt) < 16+28 || !bytes.Equal(field(3), utf16le(cred.User)) {
+		return false
+	}
+
+	var clientChallenge [8]byte
+	copy(clientChallenge[:], nt[32:40])
+	_, expected := ntlmv2Response(cred, c, clientChallenge, nt[24:32])
+	return bytes.Equal(nt, expected)
+}
+
+// ntlmProxy is an HTTP proxy which only supports CONNECT and requires NTLM
+// authentication for each connection.
+type ntlmProxy struct {
+	scheme string
+	cred   ntlmCredentials
+}
+
+func (p *ntlmProxy) serve(t testing.TB, conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	br := bufio.NewReader(conn)
+	c := ntlmChallenge{
+		ServerChallenge: [8]byte{1, 2, 3, 4, 5, 6, 7, 8},
+		TargetInfo:      []byte{ntlmAvEOL, 0, 0, 0},
+	}
+
+	for {
+		req, err := http.ReadRequest(br)
+		if err != nil {
+			return
+		}
+		if req.Method != http.MethodConnect {
+			t.Errorf("unexpected method %v", req.Method)
+			return
+		}
+
+		scheme, token, _ := strings.Cut(req.Header.Get("Proxy-Authorization"), " ")
+		msg, err := base64.StdEncoding.DecodeString(token)
+		if scheme != p.scheme || err != nil || len(msg) < 12 {
+			_, _ = io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\nProxy-Authenticate: "+p.scheme+"\r\nContent-Length: 0\r\n\r\n")
+			continue
+		}
+
+		switch binary.LittleEndian.Uint32(msg[8:]) {
+		case 1:
+			challenge := base64.StdEncoding.EncodeToString(testNTLMChallengeMessage(c.ServerChallenge, c.TargetInfo))
+			_, _ = io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\nProxy-Authenticate: Basic realm=\"proxy\"\r\nProxy-Authenticate: "+p.scheme+" "+challenge+"\r\nContent-Length: 4\r\n\r\ndeny")
+		case 3:
+			if !verifyNTLMAuthenticate(msg, p.cred, c) {
+				_, _ = io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\nContent-Length: 0\r\n\r\n")
+				continue
+			}
+
+			target, err := net.Dial("tcp", req.Host)
+			if err != nil {
+				_, _ = io.WriteString(conn, "HTTP/1.1 502 Bad Gateway\r\nContent-Length: 0\r\n\r\n")
+				return
+			}
+			_, _ = io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+			go func() {
+				_, _ = io.Copy(target, br)
+				_ = target.Close()
+			}()
+			_, _ = io.Copy(conn, target)
+			return
+		}
+	}
+}
+
+func startNTLMProxy(t testing.TB, p *ntlmProxy) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	rtest.OK(t, err)
+	t.Cleanup(func() { _ = l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go p.serve(t, conn)
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestProxyDialerNTLM(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, "hello")
+	}))
+	defer srv.Close()
+
+	for _, scheme := range []string{"NTLM", "Negotiate"} {
+		t.Run(scheme, func(t *testing.T) {
+			addr := startNTLMProxy(t, &ntlmProxy{
+				scheme: scheme,
+				cred:   ntlmCredentials{Domain: "CORP", User: "alice", Password: "secret"},
+			})
+
+			for _, test := range []struct {
+				user, password string
+				ok             bool
+			}{
+				{`CORP\alice`, "secret", true},
+				{`CORP\alice`, "wrong", false},
+			} {
+				d := &proxyDialer{
+					proxy:  &url.URL{Scheme: "http", Host: addr, User: url.UserPassword(test.user, test.password)},
+					scheme: scheme,
+					cred:   newNTLMCredentials(test.user, test.password),
+					dial:   (&net.Dialer{}).DialContext,
+				}
+				client := http.Client{Transport: &http.Transport{DialContext: d.DialContext}}
+
+				res, err := client.Get(srv.URL)
+				if !test.ok {
+					rtest.Assert(t, err != nil && strings.Contains(err.Error(), "rejected"), "unexpected error %v", err)
+					continue
+				}
+				rtest.OK(t, err)
+				body, err := io.ReadAll(res.Body)
+				rtest.OK(t, err)
+				rtest.OK(t, res.Body.Close())
+				rtest.Equals(t, "hello", string(body))
+			}
+		})
+	}
+}
+
+func TestProxyDialerCancel(t *testing.T) {
+	// a proxy which never answers
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	rtest.OK(t, err)
+	defer func() { _ = l.Close() }()
+
+	d := &proxyDialer{
+		proxy:  &url.URL{Scheme: "http", Host: l.Addr().String()},
+		scheme: "NTLM",
+		dial:   (&net.Dialer{}).DialContext,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = d.DialContext(ctx, "tcp", "example.com:443")
+	rtest.Assert(t, err != nil, "missing error")
+}
+
+func TestConfigureProxy(t *testing.T) {
+	t.Setenv("RESTIC_PROXY_PASSWORD", "")
+	for _, test := range []struct {
+		opts TransportOptions
+		ok   bool
+	}{
+		{TransportOptions{}, true},
+		{TransportOptions{Proxy: ProxyNone}, true},
+		{TransportOptions{Proxy: "http://proxy:3128"}, true},
+		{TransportOptions{Proxy: "http://user:pw@proxy:3128", ProxyAuth: ProxyAuthBasic}, true},
+		{TransportOptions{Proxy: "http://CORP%5Cuser:pw@proxy:3128", ProxyAuth: ProxyAuthNTLM}, true},
+		{TransportOptions{Proxy: "https://user:pw@proxy", ProxyAuth: ProxyAuthNegotiate}, true},
+		{TransportOptions{Proxy: "socks5://proxy:1080"}, false},
+		{TransportOptions{Proxy: "proxy:3128"}, false},
+		{TransportOptions{Proxy: "http://proxy:3128", ProxyAuth: "digest"}, false},
+		{TransportOptions{Proxy: "http://proxy:3128", ProxyAuth: ProxyAuthNTLM}, false},
+		{TransportOptions{Proxy: "http://user@proxy:3128", ProxyAuth: ProxyAuthNTLM}, false},
+		{TransportOptions{ProxyAuth: ProxyAuthNTLM}, false},
+	} {
+		err := configureProxy(&http.Transport{}, test.opts)
+		rtest.Assert(t, (err == nil) == test.ok, "unexpected result for %+v: %v", test.opts, err)
+	}
+
+	// the password may be set in the environment
+	t.Setenv("RESTIC_PROXY_PASSWORD", "pw")
+	rtest.OK(t, configureProxy(&http.Transport{}, TransportOptions{Proxy: "http://user@proxy:3128", ProxyAuth: ProxyAuthNTLM}))
+}
+
+func TestConfigureProxySelect(t *testing.T) {
+	tr := &http.Transport{}
+	rtest.OK(t, configureProxy(tr, TransportOptions{Proxy: "http://proxy:3128"}))
+	req, err := http.NewRequest(http.MethodGet, "https://s3.example.com/bucket", nil)
+	rtest.OK(t, err)
+	proxy, err := tr.Proxy(req)
+	rtest.OK(t, err)
+	rtest.Equals(t, "http://proxy:3128", proxy.String())
+
+	rtest.OK(t, configureProxy(tr, TransportOptions{Proxy: ProxyNone}))
+	proxy, err = tr.Proxy(req)
+	rtest.OK(t, err)
+	rtest.Assert(t, proxy == nil, "unexpected proxy %v", proxy)
+}
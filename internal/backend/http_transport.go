@@ -34,6 +34,20 @@ type TransportOptions struct {
 
 	// Timeout after which to retry stuck requests
 	StuckRequestTimeout time.Duration
+
+	// Multiplex all requests to a server over a single HTTP/2 connection. For
+	// http:// URLs, HTTP/2 is used without TLS (h2c with prior knowledge). For
+	// https:// URLs, a single HTTP/1.1 connection is used if the server does
+	// not support HTTP/2.
+	HTTP2Multiplexing bool
+
+	// URL of the HTTP proxy, or "none" to connect directly. If empty, the
+	// proxy is taken from the environment.
+	Proxy string
+
+	// Authentication method for the proxy: basic, ntlm or negotiate. The
+	// credentials are taken from the proxy URL.
+	ProxyAuth string
 }
 
 // readPEMCertKey reads a file and returns the PEM encoded certificate and key
@@ -96,6 +110,22 @@ func Transport(opts TransportOptions) (http.RoundTripper, error) {
 		h2.ReadIdleTimeout = 60 * time.Second
 		h2.PingTimeout = 60 * time.Second
 	}
+	if opts.HTTP2Multiplexing {
+		// use a single connection per server and wait for a free stream
+		// instead of opening additional connections
+		h2.StrictMaxConcurrentStreams = true
+		tr.MaxConnsPerHost = 1
+
+		// keep HTTP/1 to fall back to a single HTTP/1.1 connection if the
+		// server does not negotiate HTTP/2
+		tr.Protocols = new(http.Protocols)
+		tr.Protocols.SetHTTP1(true)
+		tr.Protocols.SetHTTP2(true)
+	}
+
+	if err := configureProxy(tr, opts); err != nil {
+		return nil, err
+	}
 
 	unixtransport.Register(tr)
 
@@ -134,6 +164,14 @@ func Transport(opts TransportOptions) (http.RoundTripper, error) {
 	}
 
 	rt := http.RoundTripper(tr)
+	if opts.HTTP2Multiplexing {
+		// unencrypted HTTP/2 is only used if HTTP/1 is disabled. As there is no
+		// protocol negotiation without TLS, this requires a separate transport.
+		h2c := tr.Clone()
+		h2c.Protocols = new(http.Protocols)
+		h2c.Protocols.SetUnencryptedHTTP2(true)
+		rt = &h2cRoundTripper{rt: tr, h2c: h2c}
+	}
 
 	// if the userAgent is set in the Transport Options, wrap the
 	// http.RoundTripper
@@ -152,3 +190,17 @@ func Transport(opts TransportOptions) (http.RoundTripper, error) {
 	// wrap in the debug round tripper (if active)
 	return debug.RoundTripper(rt), nil
 }
+
+// h2cRoundTripper sends requests for http:// URLs using unencrypted HTTP/2 and
+// all other requests using rt.
+type h2cRoundTripper struct {
+	rt  http.RoundTripper
+	h2c http.RoundTripper
+}
+
+func (t *h2cRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme == "http" {
+		return t.h2c.RoundTrip(req)
+	}
+	return t.rt.RoundTrip(req)
+}
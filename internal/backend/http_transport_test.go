@@ -0,0 +1,88 @@
+package backend
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestTransportHTTP2Multiplexing(t *testing.T) {
+	for _, useTLS := range []bool{false, true} {
+		t.Run(fmt.Sprintf("tls=%v", useTLS), func(t *testing.T) {
+			testTransportHTTP2Multiplexing(t, useTLS)
+		})
+	}
+}
+
+func testTransportHTTP2Multiplexing(t *testing.T, useTLS bool) {
+	var proto atomic.Value
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		proto.Store(r.Proto)
+	}))
+	srv.Config.Protocols = new(http.Protocols)
+	srv.Config.Protocols.SetUnencryptedHTTP2(true)
+
+	var conns atomic.Int32
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	if useTLS {
+		srv.EnableHTTP2 = true
+		srv.StartTLS()
+	} else {
+		srv.Start()
+	}
+	defer srv.Close()
+
+	rt, err := Transport(TransportOptions{HTTP2Multiplexing: true, InsecureTLS: true})
+	rtest.OK(t, err)
+	client := http.Client{Transport: rt}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Get(srv.URL)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			_ = resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+
+	rtest.Equals(t, "HTTP/2.0", proto.Load())
+	rtest.Equals(t, int32(1), conns.Load())
+}
+
+func TestTransportHTTP2MultiplexingFallback(t *testing.T) {
+	var proto atomic.Value
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		proto.Store(r.Proto)
+	}))
+	// the server only supports HTTP/1.1
+	srv.EnableHTTP2 = false
+	srv.StartTLS()
+	defer srv.Close()
+
+	rt, err := Transport(TransportOptions{HTTP2Multiplexing: true, InsecureTLS: true})
+	rtest.OK(t, err)
+	client := http.Client{Transport: rt}
+
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(srv.URL)
+		rtest.OK(t, err)
+		rtest.OK(t, resp.Body.Close())
+	}
+	rtest.Equals(t, "HTTP/1.1", proto.Load())
+}
@@ -20,11 +20,14 @@ type DefaultLayout struct {
 }
 
 var defaultLayoutPaths = map[backend.FileType]string{
-	backend.PackFile:     "data",
-	backend.SnapshotFile: "snapshots",
-	backend.IndexFile:    "index",
-	backend.LockFile:     "locks",
-	backend.KeyFile:      "keys",
+	backend.PackFile:       "data",
+	backend.SnapshotFile:   "snapshots",
+	backend.IndexFile:      "index",
+	backend.LockFile:       "locks",
+	backend.KeyFile:        "keys",
+	backend.AuditLogFile:   "auditlog",
+	backend.GenerationFile: "generations",
+	backend.QuarantineFile: "quarantine",
 }
 
 func NewDefaultLayout(path string, join func(...string) string) *DefaultLayout {
@@ -66,7 +69,19 @@ func (l *DefaultLayout) Filename(h backend.Handle) string {
 
 // Paths returns all directory names needed for a repo.
 func (l *DefaultLayout) Paths() (dirs []string) {
-	for _, p := range defaultLayoutPaths {
+	for t, p := range defaultLayoutPaths {
+		if t == backend.AuditLogFile {
+			// only created once the audit log is enabled
+			continue
+		}
+		if t == backend.GenerationFile {
+			// only created by the first exclusive operation
+			continue
+		}
+		if t == backend.QuarantineFile {
+			// only created once a damaged pack file is quarantined
+			continue
+		}
 		dirs = append(dirs, l.join(l.path, p))
 	}
 
@@ -50,7 +50,19 @@ func (l *RESTLayout) Filename(h backend.Handle) string {
 
 // Paths returns all directory names
 func (l *RESTLayout) Paths() (dirs []string) {
-	for _, p := range restLayoutPaths {
+	for t, p := range restLayoutPaths {
+		if t == backend.AuditLogFile {
+			// only created once the audit log is enabled
+			continue
+		}
+		if t == backend.GenerationFile {
+			// only created by the first exclusive operation
+			continue
+		}
+		if t == backend.QuarantineFile {
+			// only created once a damaged pack file is quarantined
+			continue
+		}
 		dirs = append(dirs, l.url+path.Join("/", p))
 	}
 	return dirs
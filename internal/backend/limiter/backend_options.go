@@ -0,0 +1,57 @@
+package limiter
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+// BackendOptions are the extended options which limit the bandwidth of a
+// single backend. They override the global limits.
+type BackendOptions struct {
+	Upload   string `option:"limit-upload" help:"limits uploads of this backend to a maximum rate in KiB/s, suffixes k/K, m/M and g/G are allowed (default: --limit-upload)"`
+	Download string `option:"limit-download" help:"limits downloads of this backend to a maximum rate in KiB/s, suffixes k/K, m/M and g/G are allowed (default: --limit-download)"`
+}
+
+// Apply returns l with the limits which are set in o replaced.
+func (o BackendOptions) Apply(l Limits) (Limits, error) {
+	var err error
+	if o.Upload != "" {
+		l.UploadKb, err = parseRate(o.Upload)
+		if err != nil {
+			return Limits{}, errors.Fatalf("invalid value for option limit-upload: %v", err)
+		}
+	}
+	if o.Download != "" {
+		l.DownloadKb, err = parseRate(o.Download)
+		if err != nil {
+			return Limits{}, errors.Fatalf("invalid value for option limit-download: %v", err)
+		}
+	}
+	return l, nil
+}
+
+// parseRate parses a rate in KiB/s, which may use the suffixes k/K, m/M or g/G.
+func parseRate(s string) (int, error) {
+	factor := 1
+	switch strings.ToLower(s[len(s)-1:]) {
+	case "k":
+		s = s[:len(s)-1]
+	case "m":
+		factor = 1 << 10
+		s = s[:len(s)-1]
+	case "g":
+		factor = 1 << 20
+		s = s[:len(s)-1]
+	}
+
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, err
+	}
+	if v < 0 {
+		return 0, errors.Errorf("rate %q must not be negative", s)
+	}
+	return v * factor, nil
+}
@@ -0,0 +1,29 @@
+package limiter
+
+import (
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestBackendOptionsApply(t *testing.T) {
+	for _, test := range []struct {
+		opts     BackendOptions
+		limits   Limits
+		expected Limits
+	}{
+		{BackendOptions{}, Limits{UploadKb: 1, DownloadKb: 2}, Limits{UploadKb: 1, DownloadKb: 2}},
+		{BackendOptions{Upload: "42"}, Limits{UploadKb: 1, DownloadKb: 2}, Limits{UploadKb: 42, DownloadKb: 2}},
+		{BackendOptions{Upload: "0", Download: "7k"}, Limits{UploadKb: 1, DownloadKb: 2}, Limits{UploadKb: 0, DownloadKb: 7}},
+		{BackendOptions{Upload: "10M", Download: "1g"}, Limits{}, Limits{UploadKb: 10 * 1024, DownloadKb: 1024 * 1024}},
+	} {
+		limits, err := test.opts.Apply(test.limits)
+		rtest.OK(t, err)
+		rtest.Equals(t, test.expected, limits)
+	}
+
+	for _, opts := range []BackendOptions{{Upload: "fast"}, {Download: "10MB"}, {Upload: "-1"}, {Download: "k"}} {
+		_, err := opts.Apply(Limits{})
+		rtest.Assert(t, err != nil, "expected error for %v", opts)
+	}
+}
@@ -3,6 +3,7 @@ package local
 import (
 	"strings"
 
+	"github.com/restic/restic/internal/backend/limiter"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/options"
 )
@@ -12,6 +13,7 @@ type Config struct {
 	Path string
 
 	Connections uint `option:"connections" help:"set a limit for the number of concurrent operations (default: 2)"`
+	Reflink     bool `option:"reflink" help:"store pack files as copy-on-write clones of the temporary files if supported by the filesystem (default: false)"`
 }
 
 // NewConfig returns a new config with default options applied.
@@ -23,6 +25,7 @@ func NewConfig() Config {
 
 func init() {
 	options.Register("local", Config{})
+	options.Register("local", limiter.BackendOptions{})
 }
 
 // ParseConfig parses a local backend config.
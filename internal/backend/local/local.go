@@ -7,6 +7,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sync/atomic"
 	"syscall"
 
 	"github.com/restic/restic/internal/backend"
@@ -26,6 +27,9 @@ type Local struct {
 	Config
 	layout.Layout
 	util.Modes
+
+	// noReflink is set once cloning a file has failed.
+	noReflink atomic.Bool
 }
 
 // ensure statically that *Local implements backend.Backend.
@@ -138,7 +142,7 @@ func (b *Local) Save(_ context.Context, h backend.Handle, rd backend.RewindReade
 		return errors.WithStack(err)
 	}
 
-	defer func(f *os.File) {
+	defer func() {
 		if err != nil {
 			_ = f.Close() // Double Close is harmless.
 			// Remove after Rename is harmless: we embed the final name in the
@@ -147,23 +151,33 @@ func (b *Local) Save(_ context.Context, h backend.Handle, rd backend.RewindReade
 			// goroutine.
 			_ = os.Remove(f.Name())
 		}
-	}(f)
+	}()
 
-	// preallocate disk space
-	if size := rd.Length(); size > 0 {
-		if err := fileio.PreallocateFile(f, size); err != nil {
-			debug.Log("Failed to preallocate %v with size %v: %v", finalname, size, err)
+	cloned := false
+	if b.Reflink && !b.noReflink.Load() {
+		f, cloned, err = b.cloneFile(f, rd)
+		if err != nil {
+			return errors.WithStack(err)
 		}
 	}
 
-	// save data, then sync
-	wbytes, err := io.Copy(f, rd)
-	if err != nil {
-		return errors.WithStack(err)
-	}
-	// sanity check
-	if wbytes != rd.Length() {
-		return errors.Errorf("wrote %d bytes instead of the expected %d bytes", wbytes, rd.Length())
+	if !cloned {
+		// preallocate disk space
+		if size := rd.Length(); size > 0 {
+			if err := fileio.PreallocateFile(f, size); err != nil {
+				debug.Log("Failed to preallocate %v with size %v: %v", finalname, size, err)
+			}
+		}
+
+		// save data, then sync
+		wbytes, err := io.Copy(f, rd)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		// sanity check
+		if wbytes != rd.Length() {
+			return errors.Errorf("wrote %d bytes instead of the expected %d bytes", wbytes, rd.Length())
+		}
 	}
 
 	// Ignore error if filesystem does not support fsync.
@@ -202,6 +216,47 @@ func (b *Local) Save(_ context.Context, h backend.Handle, rd backend.RewindReade
 
 var tempFile = os.CreateTemp // Overridden by test.
 
+// cloneFile replaces the empty temporary file f by a copy-on-write clone of
+// the file from which rd reads, if possible. It returns the file to use
+// instead of f and whether the data was cloned. Otherwise, the data must be
+// copied into the returned file.
+func (b *Local) cloneFile(f *os.File, rd backend.RewindReader) (*os.File, bool, error) {
+	fr, ok := rd.(*backend.FileReader)
+	if !ok {
+		return f, false, nil
+	}
+	src, ok := fr.ReadSeeker.(*os.File)
+	if !ok {
+		return f, false, nil
+	}
+	if fi, err := src.Stat(); err != nil || fi.Size() != rd.Length() {
+		return f, false, nil
+	}
+
+	name := f.Name()
+	if err := f.Close(); err != nil {
+		return f, false, err
+	}
+	if err := os.Remove(name); err != nil {
+		return f, false, err
+	}
+
+	flags := os.O_RDWR
+	cloneErr := fileio.CloneFile(src, name)
+	if cloneErr != nil {
+		// the files are most likely not located on the same filesystem
+		// or the filesystem does not support reflinks, don't try again
+		debug.Log("unable to clone %v, copying instead: %v", src.Name(), cloneErr)
+		b.noReflink.Store(true)
+		flags |= os.O_CREATE | os.O_EXCL
+	}
+	nf, err := os.OpenFile(name, flags, 0600)
+	if err != nil {
+		return f, false, err
+	}
+	return nf, cloneErr == nil, nil
+}
+
 // Load runs fn with a reader that yields the contents of the file at h at the
 // given offset.
 func (b *Local) Load(ctx context.Context, h backend.Handle, length int, offset int64, fn func(rd io.Reader) error) error {
@@ -1,10 +1,13 @@
 package local
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"syscall"
 	"testing"
 
@@ -40,3 +43,41 @@ func TestNoSpacePermanent(t *testing.T) {
 	rtest.Assert(t, errors.Is(err, syscall.ENOSPC),
 		"could not recover original ENOSPC error")
 }
+
+func TestSaveReflink(t *testing.T) {
+	dir := rtest.TempDir(t)
+	be, err := Open(context.Background(), Config{Path: dir, Connections: 2, Reflink: true}, t.Logf)
+	rtest.OK(t, err)
+	defer func() {
+		rtest.OK(t, be.Close())
+	}()
+
+	data := rtest.Random(23, 5*1024*1024)
+	for _, name := range []string{"file", "reader"} {
+		var rd backend.RewindReader
+		if name == "file" {
+			// a file on the same filesystem as the repository can be cloned
+			f, err := os.Create(filepath.Join(dir, "tmpfile"))
+			rtest.OK(t, err)
+			defer func() {
+				rtest.OK(t, f.Close())
+			}()
+			_, err = f.Write(data)
+			rtest.OK(t, err)
+			rd, err = backend.NewFileReader(f, nil)
+			rtest.OK(t, err)
+		} else {
+			rd = backend.NewByteReader(data, nil)
+		}
+
+		h := backend.Handle{Type: backend.PackFile, Name: fmt.Sprintf("%064x", len(name))}
+		rtest.OK(t, be.Save(context.Background(), h, rd))
+
+		var buf []byte
+		rtest.OK(t, be.Load(context.Background(), h, 0, 0, func(rd io.Reader) error {
+			buf, err = io.ReadAll(rd)
+			return err
+		}))
+		rtest.Assert(t, bytes.Equal(data, buf), "wrong data for %v", name)
+	}
+}
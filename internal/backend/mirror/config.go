@@ -0,0 +1,74 @@
+package mirror
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/errors"
+	"go.yaml.in/yaml/v3"
+)
+
+// Config holds all information needed to open a mirrored repository.
+type Config struct {
+	// Path is the location of the file which lists the mirrored repositories.
+	Path string
+
+	// Opener opens the backends of the mirrored repositories. It must be set
+	// before the mirror backend is created or opened.
+	Opener Opener
+}
+
+// Opener creates or opens the backend for a repository location.
+type Opener interface {
+	Open(ctx context.Context, location string, create bool) (backend.Backend, error)
+	// StripPassword removes the password from location such that it can be
+	// included in messages.
+	StripPassword(location string) string
+}
+
+// ParseConfig parses a mirror backend config.
+func ParseConfig(s string) (*Config, error) {
+	if !strings.HasPrefix(s, "mirror:") {
+		return nil, errors.New(`invalid format, prefix "mirror" not found`)
+	}
+
+	path := s[7:]
+	if path == "" {
+		return nil, errors.New("mirror: no configuration file specified")
+	}
+	return &Config{Path: path}, nil
+}
+
+// fileConfig is the content of the configuration file.
+type fileConfig struct {
+	Repositories []string `yaml:"repositories"`
+}
+
+// loadRepositories reads the locations of the mirrored repositories from the
+// configuration file at path.
+func loadRepositories(path string) ([]string, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "read mirror configuration")
+	}
+
+	var cfg fileConfig
+	if err := yaml.Unmarshal(buf, &cfg); err != nil {
+		return nil, errors.Wrapf(err, "parse mirror configuration %v", path)
+	}
+
+	if len(cfg.Repositories) < 2 {
+		return nil, errors.Errorf("mirror configuration %v must list at least two repositories", path)
+	}
+	for _, loc := range cfg.Repositories {
+		if loc == "" {
+			return nil, errors.Errorf("mirror configuration %v contains an empty repository location", path)
+		}
+		if strings.HasPrefix(loc, "mirror:") {
+			return nil, errors.Errorf("mirror configuration %v must not contain another mirror", path)
+		}
+	}
+	return cfg.Repositories, nil
+}
@@ -0,0 +1,464 @@
+package mirror
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/location"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+)
+
+// unhealthyDuration is the time for which a mirror is only used as a last
+// resort for reading after an operation on it has failed.
+var unhealthyDuration = 5 * time.Minute
+
+// Backend stores all files in two or more mirrored repositories. Files are
+// written to and removed from all mirrors, reads are served by the mirror
+// which has responded the fastest so far. If a read fails or a file whose name
+// is the hash of its content is corrupted, the next mirror is tried.
+type Backend struct {
+	mirrors  []*mirror
+	errorLog func(string, ...interface{})
+
+	m sync.Mutex
+}
+
+// make sure that *Backend implements backend.Backend
+var _ backend.Backend = &Backend{}
+
+type mirror struct {
+	be   backend.Backend
+	name string
+
+	// latency is a moving average of the time until a load returned data
+	latency time.Duration
+	// failedAt is the time of the last failed read, zero if the mirror is healthy
+	failedAt time.Time
+}
+
+func NewFactory() location.Factory {
+	return location.NewHTTPBackendFactory(
+		"mirror",
+		ParseConfig,
+		location.NoPassword,
+		func(ctx context.Context, cfg Config, _ http.RoundTripper, errorLog func(string, ...interface{})) (*Backend, error) {
+			return open(ctx, cfg, true, errorLog)
+		},
+		func(ctx context.Context, cfg Config, _ http.RoundTripper, errorLog func(string, ...interface{})) (*Backend, error) {
+			return open(ctx, cfg, false, errorLog)
+		},
+	)
+}
+
+func open(ctx context.Context, cfg Config, create bool, errorLog func(string, ...interface{})) (*Backend, error) {
+	if cfg.Opener == nil {
+		return nil, errors.New("mirror: no opener configured")
+	}
+
+	locations, err := loadRepositories(cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	be := &Backend{errorLog: errorLog}
+	for _, loc := range locations {
+		name := cfg.Opener.StripPassword(loc)
+		debug.Log("opening mirror %v", name)
+		mbe, err := cfg.Opener.Open(ctx, loc, create)
+		if err != nil {
+			_ = be.Close()
+			return nil, fmt.Errorf("mirror %v: %w", name, err)
+		}
+		be.mirrors = append(be.mirrors, &mirror{be: mbe, name: name})
+	}
+
+	if !create {
+		if err := be.checkInitialized(ctx); err != nil {
+			_ = be.Close()
+			return nil, err
+		}
+	}
+	return be, nil
+}
+
+// checkInitialized returns an error if some but not all mirrors contain a
+// repository config. As reads may be served by any mirror, all of them must
+// have been initialized.
+func (be *Backend) checkInitialized(ctx context.Context) error {
+	var missing *mirror
+	found := false
+	for _, m := range be.mirrors {
+		_, err := m.be.Stat(ctx, backend.Handle{Type: backend.ConfigFile})
+		if m.be.IsNotExist(err) {
+			missing = m
+		} else if err != nil {
+			return fmt.Errorf("mirror %v: %w", m.name, err)
+		} else {
+			found = true
+		}
+	}
+	if found && missing != nil {
+		return fmt.Errorf("mirror %v: %w", missing.name, backend.ErrNoRepository)
+	}
+	return nil
+}
+
+// New returns a backend which mirrors all files to the given backends. It is
+// used for tests.
+func New(backends ...backend.Backend) *Backend {
+	be := &Backend{errorLog: func(string, ...interface{}) {}}
+	for i, mbe := range backends {
+		be.mirrors = append(be.mirrors, &mirror{be: mbe, name: fmt.Sprintf("#%d", i+1)})
+	}
+	return be
+}
+
+// readOrder returns the mirrors in the order in which they should be used for
+// reading. Healthy mirrors come first, ordered by their latency.
+func (be *Backend) readOrder() []*mirror {
+	be.m.Lock()
+	defer be.m.Unlock()
+
+	now := time.Now()
+	healthy := func(m *mirror) bool {
+		return m.failedAt.IsZero() || now.Sub(m.failedAt) > unhealthyDuration
+	}
+
+	order := make([]*mirror, len(be.mirrors))
+	copy(order, be.mirrors)
+	sort.SliceStable(order, func(i, j int) bool {
+		hi, hj := healthy(order[i]), healthy(order[j])
+		if hi != hj {
+			return hi
+		}
+		if !hi {
+			return order[i].failedAt.Before(order[j].failedAt)
+		}
+		return order[i].latency < order[j].latency
+	})
+	return order
+}
+
+// readSucceeded updates the latency of m. Mirrors without a measured latency
+// are tried first, such that the latency of all mirrors is known after a few
+// reads.
+func (be *Backend) readSucceeded(m *mirror, d time.Duration) {
+	be.m.Lock()
+	defer be.m.Unlock()
+
+	m.failedAt = time.Time{}
+	if m.latency == 0 {
+		m.latency = d
+	} else {
+		m.latency = (3*m.latency + d) / 4
+	}
+}
+
+func (be *Backend) readFailed(ctx context.Context, m *mirror, err error) {
+	if ctx.Err() != nil || m.be.IsNotExist(err) {
+		return
+	}
+
+	be.m.Lock()
+	defer be.m.Unlock()
+	if m.failedAt.IsZero() {
+		be.errorLog("reading from mirror %v failed, trying the other mirrors: %v", m.name, err)
+	}
+	m.failedAt = time.Now()
+}
+
+// consumerError wraps errors returned by the consumer passed to Load. These
+// are returned without trying the other mirrors.
+type consumerError struct {
+	err error
+}
+
+func (e *consumerError) Error() string {
+	return e.err.Error()
+}
+
+// read runs fn for the mirrors in read order until it succeeds. If all
+// mirrors fail, the error of the first mirror is returned unless the file
+// exists on another mirror.
+func (be *Backend) read(ctx context.Context, fn func(m *mirror) error) error {
+	var firstErr error
+	for _, m := range be.readOrder() {
+		err := fn(m)
+		if err == nil {
+			return nil
+		}
+		var cerr *consumerError
+		if errors.As(err, &cerr) {
+			return cerr.err
+		}
+		debug.Log("read from mirror %v failed: %v", m.name, err)
+		be.readFailed(ctx, m, err)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if firstErr == nil || (be.IsNotExist(firstErr) && !m.be.IsNotExist(err)) {
+			firstErr = fmt.Errorf("mirror %v: %w", m.name, err)
+		}
+	}
+	return firstErr
+}
+
+// write runs fn for all mirrors and returns the first error.
+func (be *Backend) write(fn func(m *mirror) error) error {
+	var firstErr error
+	for _, m := range be.mirrors {
+		if err := fn(m); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("mirror %v: %w", m.name, err)
+		}
+	}
+	return firstErr
+}
+
+// Properties returns the properties shared by all mirrors.
+func (be *Backend) Properties() backend.Properties {
+	props := be.mirrors[0].be.Properties()
+	for _, m := range be.mirrors[1:] {
+		p := m.be.Properties()
+		props.Connections = min(props.Connections, p.Connections)
+		props.HasAtomicReplace = props.HasAtomicReplace && p.HasAtomicReplace
+		props.HasFlakyErrors = props.HasFlakyErrors || p.HasFlakyErrors
+		props.Retention = max(props.Retention, p.Retention)
+	}
+	return props
+}
+
+// Hasher returns nil, as the hash is calculated separately for each mirror.
+func (be *Backend) Hasher() hash.Hash {
+	return nil
+}
+
+// hashedReader overrides the hash of the wrapped RewindReader.
+type hashedReader struct {
+	backend.RewindReader
+	hash []byte
+}
+
+func (rd *hashedReader) Hash() []byte {
+	return rd.hash
+}
+
+// readerFor returns a reader for rd which provides the hash expected by m.
+func readerFor(m *mirror, rd backend.RewindReader) (backend.RewindReader, error) {
+	if err := rd.Rewind(); err != nil {
+		return nil, err
+	}
+
+	hasher := m.be.Hasher()
+	if hasher == nil {
+		return &hashedReader{RewindReader: rd}, nil
+	}
+	if _, err := io.Copy(hasher, rd); err != nil {
+		return nil, err
+	}
+	if err := rd.Rewind(); err != nil {
+		return nil, err
+	}
+	return &hashedReader{RewindReader: rd, hash: hasher.Sum(nil)}, nil
+}
+
+// Save stores the file in all mirrors. It fails if the file could not be
+// saved in any of the mirrors.
+func (be *Backend) Save(ctx context.Context, h backend.Handle, rd backend.RewindReader) error {
+	return be.write(func(m *mirror) error {
+		mrd, err := readerFor(m, rd)
+		if err != nil {
+			return err
+		}
+		return m.be.Save(ctx, h, mrd)
+	})
+}
+
+// Remove removes the file from all mirrors. Mirrors which do not contain the
+// file are ignored, unless the file is missing in all mirrors.
+func (be *Backend) Remove(ctx context.Context, h backend.Handle) error {
+	var notExistErr error
+	removed := false
+	err := be.write(func(m *mirror) error {
+		err := m.be.Remove(ctx, h)
+		if err != nil && m.be.IsNotExist(err) {
+			notExistErr = err
+			return nil
+		}
+		removed = removed || err == nil
+		return err
+	})
+	if err == nil && !removed {
+		return notExistErr
+	}
+	return err
+}
+
+// contentHash returns the hash of the content of the file at h, which is
+// encoded in the name of all files except the config.
+func contentHash(h backend.Handle) ([]byte, bool) {
+	if h.Type == backend.ConfigFile {
+		return nil, false
+	}
+	id, err := hex.DecodeString(h.Name)
+	if err != nil || len(id) != sha256.Size {
+		return nil, false
+	}
+	return id, true
+}
+
+// verifyHash calls fn with a reader for rd and afterwards checks that the
+// content of rd matches hash. As a corrupted file may also cause fn to fail,
+// a mismatch is reported instead of the error of fn, such that the other
+// mirrors are tried.
+func verifyHash(rd io.Reader, hash []byte, fn func(rd io.Reader) error) error {
+	hasher := sha256.New()
+	err := fn(io.TeeReader(rd, hasher))
+	if _, cerr := io.Copy(hasher, rd); cerr != nil {
+		return cerr
+	}
+	if !bytes.Equal(hasher.Sum(nil), hash) {
+		return errors.Errorf("file is corrupted, expected hash %x, got %x", hash, hasher.Sum(nil))
+	}
+	return err
+}
+
+// Load reads the file from the fastest mirror, the other mirrors are used if
+// that fails. If the whole file is read and its name is the hash of its
+// content, the content is verified as well.
+func (be *Backend) Load(ctx context.Context, h backend.Handle, length int, offset int64, fn func(rd io.Reader) error) error {
+	hash, verify := contentHash(h)
+	verify = verify && length == 0 && offset == 0
+
+	return be.read(ctx, func(m *mirror) error {
+		start := time.Now()
+		var latency time.Duration
+		err := m.be.Load(ctx, h, length, offset, func(rd io.Reader) error {
+			if latency == 0 {
+				latency = time.Since(start)
+			}
+			consume := func(rd io.Reader) error {
+				if err := fn(rd); err != nil {
+					return &consumerError{err}
+				}
+				return nil
+			}
+			if verify {
+				return verifyHash(rd, hash, consume)
+			}
+			return consume(rd)
+		})
+		if err == nil {
+			be.readSucceeded(m, latency)
+		}
+		return err
+	})
+}
+
+// Stat returns information about the file from the fastest mirror.
+func (be *Backend) Stat(ctx context.Context, h backend.Handle) (backend.FileInfo, error) {
+	var fi backend.FileInfo
+	err := be.read(ctx, func(m *mirror) error {
+		var err error
+		fi, err = m.be.Stat(ctx, h)
+		return err
+	})
+	return fi, err
+}
+
+// List runs fn for each file in the fastest mirror. Another mirror is only
+// used if listing fails before fn was called, as fn must be called at most
+// once per file.
+func (be *Backend) List(ctx context.Context, t backend.FileType, fn func(backend.FileInfo) error) error {
+	var firstErr error
+	for _, m := range be.readOrder() {
+		called := false
+		err := m.be.List(ctx, t, func(fi backend.FileInfo) error {
+			called = true
+			return fn(fi)
+		})
+		if err == nil || called {
+			return err
+		}
+		debug.Log("list from mirror %v failed: %v", m.name, err)
+		be.readFailed(ctx, m, err)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if firstErr == nil {
+			firstErr = fmt.Errorf("mirror %v: %w", m.name, err)
+		}
+	}
+	return firstErr
+}
+
+// IsNotExist returns true if the error was caused by a file which is missing
+// in one of the mirrors.
+func (be *Backend) IsNotExist(err error) bool {
+	for _, m := range be.mirrors {
+		if m.be.IsNotExist(err) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPermanentError returns true if one of the mirrors considers the error to
+// be permanent.
+func (be *Backend) IsPermanentError(err error) bool {
+	for _, m := range be.mirrors {
+		if m.be.IsPermanentError(err) {
+			return true
+		}
+	}
+	return false
+}
+
+// Delete removes all data in all mirrors.
+func (be *Backend) Delete(ctx context.Context) error {
+	return be.write(func(m *mirror) error {
+		return m.be.Delete(ctx)
+	})
+}
+
+// Close closes all mirrors.
+func (be *Backend) Close() error {
+	return be.write(func(m *mirror) error {
+		return m.be.Close()
+	})
+}
+
+// Warmup warms up the files in all mirrors, as reads may be served by any of
+// them.
+func (be *Backend) Warmup(ctx context.Context, handles []backend.Handle) ([]backend.Handle, error) {
+	seen := make(map[backend.Handle]struct{})
+	var warming []backend.Handle
+	err := be.write(func(m *mirror) error {
+		hs, err := m.be.Warmup(ctx, handles)
+		for _, h := range hs {
+			if _, ok := seen[h]; !ok {
+				seen[h] = struct{}{}
+				warming = append(warming, h)
+			}
+		}
+		return err
+	})
+	return warming, err
+}
+
+// WarmupWait waits until the files are warm in all mirrors.
+func (be *Backend) WarmupWait(ctx context.Context, handles []backend.Handle) error {
+	return be.write(func(m *mirror) error {
+		return m.be.WarmupWait(ctx, handles)
+	})
+}
@@ -0,0 +1,247 @@
+package mirror_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/local"
+	"github.com/restic/restic/internal/backend/mem"
+	"github.com/restic/restic/internal/backend/mirror"
+	"github.com/restic/restic/internal/backend/mock"
+	"github.com/restic/restic/internal/backend/test"
+	"github.com/restic/restic/internal/errors"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+// localOpener opens local repositories.
+type localOpener struct{}
+
+func (localOpener) Open(ctx context.Context, location string, create bool) (backend.Backend, error) {
+	cfg, err := local.ParseConfig("local:" + location)
+	if err != nil {
+		return nil, err
+	}
+	if create {
+		return local.Create(ctx, *cfg, nil)
+	}
+	return local.Open(ctx, *cfg, nil)
+}
+
+func (localOpener) StripPassword(location string) string {
+	return location
+}
+
+func newTestSuite(t testing.TB) *test.Suite[mirror.Config] {
+	return &test.Suite[mirror.Config]{
+		// NewConfig returns a config for a new temporary backend that will be used in tests.
+		NewConfig: func() (*mirror.Config, error) {
+			dir := rtest.TempDir(t)
+			t.Logf("create new backend at %v", dir)
+
+			path := filepath.Join(dir, "mirror.yml")
+			content := fmt.Sprintf("repositories:\n  - %v\n  - %v\n", filepath.Join(dir, "a"), filepath.Join(dir, "b"))
+			if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+				return nil, err
+			}
+
+			cfg, err := mirror.ParseConfig("mirror:" + path)
+			if err != nil {
+				return nil, err
+			}
+			cfg.Opener = localOpener{}
+			return cfg, nil
+		},
+
+		Factory: mirror.NewFactory(),
+	}
+}
+
+func TestBackend(t *testing.T) {
+	newTestSuite(t).RunTests(t)
+}
+
+func TestParseConfig(t *testing.T) {
+	cfg, err := mirror.ParseConfig("mirror:/etc/restic/mirror.yml")
+	rtest.OK(t, err)
+	rtest.Equals(t, "/etc/restic/mirror.yml", cfg.Path)
+
+	_, err = mirror.ParseConfig("mirror:")
+	rtest.Assert(t, err != nil, "expected error for missing configuration file")
+}
+
+func TestInvalidConfigFile(t *testing.T) {
+	dir := rtest.TempDir(t)
+	for _, content := range []string{
+		"repositories:\n  - /srv/a\n",
+		"repositories:\n  - /srv/a\n  - \"\"\n",
+		"repositories:\n  - /srv/a\n  - mirror:/srv/other.yml\n",
+		"repositories: [",
+	} {
+		path := filepath.Join(dir, "mirror.yml")
+		rtest.OK(t, os.WriteFile(path, []byte(content), 0o600))
+
+		cfg, err := mirror.ParseConfig("mirror:" + path)
+		rtest.OK(t, err)
+		cfg.Opener = localOpener{}
+		_, err = mirror.NewFactory().Open(context.TODO(), cfg, nil, nil, t.Logf)
+		rtest.Assert(t, err != nil, "expected error for configuration %q", content)
+	}
+}
+
+func save(t *testing.T, be backend.Backend, h backend.Handle, data string) {
+	t.Helper()
+	rtest.OK(t, be.Save(context.TODO(), h, backend.NewByteReader([]byte(data), be.Hasher())))
+}
+
+func load(be backend.Backend, h backend.Handle) (string, error) {
+	var data []byte
+	err := be.Load(context.TODO(), h, 0, 0, func(rd io.Reader) error {
+		var err error
+		data, err = io.ReadAll(rd)
+		return err
+	})
+	return string(data), err
+}
+
+func TestSaveWritesAllMirrors(t *testing.T) {
+	a, b := mem.New(), mem.New()
+	be := mirror.New(a, b)
+	h := backend.Handle{Type: backend.PackFile, Name: "foo"}
+	save(t, be, h, "content")
+
+	for _, mbe := range []backend.Backend{a, b} {
+		data, err := load(mbe, h)
+		rtest.OK(t, err)
+		rtest.Equals(t, "content", data)
+	}
+
+	rtest.OK(t, be.Remove(context.TODO(), h))
+	for _, mbe := range []backend.Backend{a, b} {
+		_, err := mbe.Stat(context.TODO(), h)
+		rtest.Assert(t, mbe.IsNotExist(err), "file was not removed: %v", err)
+	}
+	err := be.Remove(context.TODO(), h)
+	rtest.Assert(t, be.IsNotExist(err), "expected not exist error, got %v", err)
+}
+
+func TestSaveFailsIfAnyMirrorFails(t *testing.T) {
+	failing := mock.NewBackend()
+	failing.SaveFn = func(_ context.Context, _ backend.Handle, _ backend.RewindReader) error {
+		return errors.New("save failed")
+	}
+	a := mem.New()
+	be := mirror.New(a, failing)
+
+	h := backend.Handle{Type: backend.PackFile, Name: "foo"}
+	err := be.Save(context.TODO(), h, backend.NewByteReader([]byte("content"), be.Hasher()))
+	rtest.Assert(t, err != nil, "expected save error")
+}
+
+func TestLoadFailover(t *testing.T) {
+	h := backend.Handle{Type: backend.PackFile, Name: "foo"}
+	loads := 0
+	failing := mock.NewBackend()
+	failing.OpenReaderFn = func(_ context.Context, _ backend.Handle, _ int, _ int64) (io.ReadCloser, error) {
+		loads++
+		return nil, errors.New("load failed")
+	}
+	failing.StatFn = func(_ context.Context, _ backend.Handle) (backend.FileInfo, error) {
+		return backend.FileInfo{}, errors.New("stat failed")
+	}
+	a := mem.New()
+	save(t, a, h, "content")
+
+	be := mirror.New(failing, a)
+	for i := 0; i < 3; i++ {
+		data, err := load(be, h)
+		rtest.OK(t, err)
+		rtest.Equals(t, "content", data)
+	}
+	// the failing mirror is not used again after the first error
+	rtest.Equals(t, 1, loads)
+
+	fi, err := be.Stat(context.TODO(), h)
+	rtest.OK(t, err)
+	rtest.Equals(t, int64(len("content")), fi.Size)
+}
+
+func TestLoadMissingInOneMirror(t *testing.T) {
+	h := backend.Handle{Type: backend.PackFile, Name: "foo"}
+	a, b := mem.New(), mem.New()
+	save(t, b, h, "content")
+
+	be := mirror.New(a, b)
+	data, err := load(be, h)
+	rtest.OK(t, err)
+	rtest.Equals(t, "content", data)
+
+	_, err = load(be, backend.Handle{Type: backend.PackFile, Name: "missing"})
+	rtest.Assert(t, be.IsNotExist(err), "expected not exist error, got %v", err)
+}
+
+func TestLoadCorruptedInOneMirror(t *testing.T) {
+	content := "content"
+	hash := sha256.Sum256([]byte(content))
+	h := backend.Handle{Type: backend.PackFile, Name: hex.EncodeToString(hash[:])}
+	a, b := mem.New(), mem.New()
+	save(t, a, h, "corrupt")
+	save(t, b, h, content)
+
+	be := mirror.New(a, b)
+	data, err := load(be, h)
+	rtest.OK(t, err)
+	rtest.Equals(t, content, data)
+
+	// errors of the consumer caused by the corrupted file are not returned
+	be = mirror.New(a, b)
+	rtest.OK(t, be.Load(context.TODO(), h, 0, 0, func(rd io.Reader) error {
+		data, err := io.ReadAll(rd)
+		if err != nil {
+			return err
+		}
+		if string(data) != content {
+			return errors.New("invalid data")
+		}
+		return nil
+	}))
+
+	// partial reads cannot be verified
+	rtest.OK(t, be.Remove(context.TODO(), h))
+	save(t, a, h, "corrupt")
+	err = be.Load(context.TODO(), h, 3, 0, func(rd io.Reader) error {
+		data, err := io.ReadAll(rd)
+		rtest.Equals(t, "cor", string(data))
+		return err
+	})
+	rtest.OK(t, err)
+
+	// a corrupted file is reported if no mirror has an intact copy
+	_, err = load(be, h)
+	rtest.Assert(t, err != nil, "expected error for corrupted file")
+}
+
+func TestOpenUninitializedMirror(t *testing.T) {
+	dir := rtest.TempDir(t)
+	path := filepath.Join(dir, "mirror.yml")
+	content := fmt.Sprintf("repositories:\n  - %v\n  - %v\n", filepath.Join(dir, "a"), filepath.Join(dir, "b"))
+	rtest.OK(t, os.WriteFile(path, []byte(content), 0o600))
+
+	cfg, err := mirror.ParseConfig("mirror:" + path)
+	rtest.OK(t, err)
+	cfg.Opener = localOpener{}
+
+	a, err := localOpener{}.Open(context.TODO(), filepath.Join(dir, "a"), true)
+	rtest.OK(t, err)
+	save(t, a, backend.Handle{Type: backend.ConfigFile}, "config")
+	rtest.OK(t, a.Close())
+
+	_, err = mirror.NewFactory().Open(context.TODO(), cfg, nil, nil, t.Logf)
+	rtest.Assert(t, errors.Is(err, backend.ErrNoRepository), "expected ErrNoRepository, got %v", err)
+}
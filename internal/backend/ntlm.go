@@ -0,0 +1,210 @@
+package backend
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/binary"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/restic/restic/internal/errors"
+	"golang.org/x/crypto/md4" //nolint:staticcheck // MD4 is mandated by the NTLM protocol
+)
+
+// NTLM authentication as described in [MS-NLMP], only the NTLMv2 response is
+// supported.
+
+const (
+	ntlmNegotiateUnicode          = 0x00000001
+	ntlmRequestTarget             = 0x00000004
+	ntlmNegotiateNTLM             = 0x00000200
+	ntlmNegotiateAlwaysSign       = 0x00008000
+	ntlmNegotiateExtendedSecurity = 0x00080000
+	ntlmNegotiateTargetInfo       = 0x00800000
+	ntlmNegotiate128              = 0x20000000
+	ntlmNegotiate56               = 0x80000000
+
+	ntlmNegotiateFlags = ntlmNegotiateUnicode | ntlmRequestTarget | ntlmNegotiateNTLM |
+		ntlmNegotiateAlwaysSign | ntlmNegotiateExtendedSecurity | ntlmNegotiate128 | ntlmNegotiate56
+
+	ntlmAvEOL       = 0
+	ntlmAvTimestamp = 7
+)
+
+var ntlmSignature = []byte("NTLMSSP\x00")
+
+// ntlmCredentials are the credentials used for NTLM authentication.
+type ntlmCredentials struct {
+	Domain   string
+	User     string
+	Password string
+}
+
+// newNTLMCredentials splits the username into domain and user if it uses the
+// format `DOMAIN\user`. A username in the format `user@domain` is passed on
+// unchanged.
+func newNTLMCredentials(username, password string) ntlmCredentials {
+	domain, user, found := strings.Cut(username, `\`)
+	if !found {
+		domain, user = "", username
+	}
+	return ntlmCredentials{Domain: domain, User: user, Password: password}
+}
+
+// ntlmChallenge is the content of a CHALLENGE_MESSAGE sent by the server.
+type ntlmChallenge struct {
+	Flags           uint32
+	ServerChallenge [8]byte
+	TargetInfo      []byte
+}
+
+// ntlmNegotiateMessage returns the NEGOTIATE_MESSAGE which starts the
+// authentication.
+func ntlmNegotiateMessage() []byte {
+	msg := make([]byte, 32)
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], 1)
+	binary.LittleEndian.PutUint32(msg[12:], ntlmNegotiateFlags)
+	// the domain and workstation fields at offset 16 and 24 are left empty
+	return msg
+}
+
+// parseNTLMChallenge parses the CHALLENGE_MESSAGE sent by the server.
+func parseNTLMChallenge(msg []byte) (ntlmChallenge, error) {
+	var c ntlmChallenge
+	if len(msg) < 32 || !bytes.Equal(msg[:8], ntlmSignature) {
+		return c, errors.New("invalid NTLM challenge message")
+	}
+	if binary.LittleEndian.Uint32(msg[8:]) != 2 {
+		return c, errors.Errorf("unexpected NTLM message type %d", binary.LittleEndian.Uint32(msg[8:]))
+	}
+	c.Flags = binary.LittleEndian.Uint32(msg[20:])
+	copy(c.ServerChallenge[:], msg[24:32])
+
+	if c.Flags&ntlmNegotiateTargetInfo != 0 && len(msg) >= 48 {
+		length := int(binary.LittleEndian.Uint16(msg[40:]))
+		offset := int(binary.LittleEndian.Uint32(msg[44:]))
+		if offset > len(msg) || length > len(msg)-offset {
+			return c, errors.New("invalid target info in NTLM challenge message")
+		}
+		c.TargetInfo = msg[offset : offset+length]
+	}
+	return c, nil
+}
+
+// timestamp returns the timestamp contained in the target info, if any.
+func (c ntlmChallenge) timestamp() ([]byte, bool) {
+	info := c.TargetInfo
+	for len(info) >= 4 {
+		id := binary.LittleEndian.Uint16(info)
+		length := int(binary.LittleEndian.Uint16(info[2:]))
+		info = info[4:]
+		if id == ntlmAvEOL || length > len(info) {
+			break
+		}
+		if id == ntlmAvTimestamp && length == 8 {
+			return info[:8], true
+		}
+		info = info[length:]
+	}
+	return nil, false
+}
+
+func utf16le(s string) []byte {
+	codes := utf16.Encode([]rune(s))
+	buf := make([]byte, 2*len(codes))
+	for i, c := range codes {
+		binary.LittleEndian.PutUint16(buf[2*i:], c)
+	}
+	return buf
+}
+
+func hmacMD5(key []byte, data ...[]byte) []byte {
+	mac := hmac.New(md5.New, key)
+	for _, d := range data {
+		_, _ = mac.Write(d)
+	}
+	return mac.Sum(nil)
+}
+
+// ntowfv2 computes the NTLMv2 response key for the credentials.
+func ntowfv2(cred ntlmCredentials) []byte {
+	h := md4.New()
+	_, _ = h.Write(utf16le(cred.Password))
+	return hmacMD5(h.Sum(nil), utf16le(strings.ToUpper(cred.User)+cred.Domain))
+}
+
+// ntlmv2Response computes the LM and NT challenge responses.
+func ntlmv2Response(cred ntlmCredentials, c ntlmChallenge, clientChallenge [8]byte, timestamp []byte) (lm, nt []byte) {
+	key := ntowfv2(cred)
+
+	var temp []byte
+	temp = append(temp, 1, 1, 0, 0, 0, 0, 0, 0)
+	temp = append(temp, timestamp...)
+	temp = append(temp, clientChallenge[:]...)
+	temp = append(temp, 0, 0, 0, 0)
+	temp = append(temp, c.TargetInfo...)
+	temp = append(temp, 0, 0, 0, 0)
+
+	ntProof := hmacMD5(key, c.ServerChallenge[:], temp)
+	nt = append(ntProof, temp...)
+	lm = append(hmacMD5(key, c.ServerChallenge[:], clientChallenge[:]), clientChallenge[:]...)
+	return lm, nt
+}
+
+// ntlmFiletime converts t to the number of 100ns intervals since January 1, 1601.
+func ntlmFiletime(t time.Time) []byte {
+	buf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(buf, uint64(t.UnixNano()/100+116444736000000000))
+	return buf
+}
+
+// ntlmAuthenticateMessage returns the AUTHENTICATE_MESSAGE which answers the
+// challenge.
+func ntlmAuthenticateMessage(cred ntlmCredentials, challengeMsg []byte) ([]byte, error) {
+	c, err := parseNTLMChallenge(challengeMsg)
+	if err != nil {
+		return nil, err
+	}
+
+	var clientChallenge [8]byte
+	if _, err := rand.Read(clientChallenge[:]); err != nil {
+		return nil, errors.Wrap(err, "rand.Read")
+	}
+
+	timestamp, serverTime := c.timestamp()
+	if !serverTime {
+		timestamp = ntlmFiletime(time.Now())
+	}
+	lm, nt := ntlmv2Response(cred, c, clientChallenge, timestamp)
+	if serverTime {
+		// the LMv2 response must not be sent if the server provides a timestamp
+		lm = make([]byte, 24)
+	}
+
+	return buildNTLMAuthenticate(cred, c.Flags&ntlmNegotiateFlags|ntlmNegotiateUnicode, lm, nt), nil
+}
+
+func buildNTLMAuthenticate(cred ntlmCredentials, flags uint32, lm, nt []byte) []byte {
+	const headerSize = 64
+	payloads := [][]byte{lm, nt, utf16le(cred.Domain), utf16le(cred.User), nil, nil}
+
+	msg := make([]byte, headerSize)
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], 3)
+
+	// the fields for the LM and NT responses, domain, user, workstation and
+	// session key follow each other starting at offset 12
+	for i, payload := range payloads {
+		field := msg[12+8*i:]
+		binary.LittleEndian.PutUint16(field, uint16(len(payload)))
+		binary.LittleEndian.PutUint16(field[2:], uint16(len(payload)))
+		binary.LittleEndian.PutUint32(field[4:], uint32(len(msg)))
+		msg = append(msg, payload...)
+	}
+	binary.LittleEndian.PutUint32(msg[60:], flags)
+	return msg
+}
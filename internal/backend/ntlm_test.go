@@ -0,0 +1,58 @@
+package backend
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func mustDecodeHex(t testing.TB, s string) []byte {
+	buf, err := hex.DecodeString(s)
+	rtest.OK(t, err)
+	return buf
+}
+
+// test vectors from [MS-NLMP] section 4.2.4
+func TestNTLMv2Response(t *testing.T) {
+	cred := ntlmCredentials{Domain: "Domain", User: "User", Password: "Password"}
+	rtest.Equals(t, mustDecodeHex(t, "0c868a403bfd7a93a3001ef22ef02e3f"), ntowfv2(cred))
+
+	c := ntlmChallenge{
+		TargetInfo: mustDecodeHex(t, "02000c0044006f006d00610069006e00"+"01000c005300650072007600650072000000"+"0000"),
+	}
+	copy(c.ServerChallenge[:], mustDecodeHex(t, "0123456789abcdef"))
+	var clientChallenge [8]byte
+	copy(clientChallenge[:], bytes.Repeat([]byte{0xaa}, 8))
+
+	lm, nt := ntlmv2Response(cred, c, clientChallenge, make([]byte, 8))
+	rtest.Equals(t, mustDecodeHex(t, "86c35097ac9cec102554764a57cccc19aaaaaaaaaaaaaaaa"), lm)
+	rtest.Equals(t, mustDecodeHex(t, "68cd0ab851e51c96aabc927bebef6a1c"), nt[:16])
+}
+
+func TestNTLMCredentials(t *testing.T) {
+	rtest.Equals(t, ntlmCredentials{Domain: "CORP", User: "alice", Password: "pw"}, newNTLMCredentials(`CORP\alice`, "pw"))
+	rtest.Equals(t, ntlmCredentials{User: "alice@corp.example.com", Password: "pw"}, newNTLMCredentials("alice@corp.example.com", "pw"))
+}
+
+func TestParseNTLMChallenge(t *testing.T) {
+	for _, msg := range [][]byte{
+		nil,
+		[]byte("NTLMSSP\x00"),
+		ntlmNegotiateMessage(),
+	} {
+		_, err := parseNTLMChallenge(msg)
+		rtest.Assert(t, err != nil, "missing error for %x", msg)
+	}
+
+	info := []byte{ntlmAvTimestamp, 0, 8, 0, 1, 2, 3, 4, 5, 6, 7, 8, ntlmAvEOL, 0, 0, 0}
+	msg := testNTLMChallengeMessage([8]byte{1, 2, 3, 4, 5, 6, 7, 8}, info)
+	c, err := parseNTLMChallenge(msg)
+	rtest.OK(t, err)
+	rtest.Equals(t, [8]byte{1, 2, 3, 4, 5, 6, 7, 8}, c.ServerChallenge)
+	rtest.Equals(t, info, c.TargetInfo)
+	ts, ok := c.timestamp()
+	rtest.Assert(t, ok, "timestamp not found")
+	rtest.Equals(t, []byte{1, 2, 3, 4, 5, 6, 7, 8}, ts)
+}
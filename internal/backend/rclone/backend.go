@@ -31,6 +31,11 @@ import (
 // rclone is used to access data stored somewhere via rclone.
 type rclone struct {
 	*rest.Backend
+	sv *supervisor
+}
+
+// process is a running rclone instance.
+type process struct {
 	tr         *http2.Transport
 	cmd        *exec.Cmd
 	waitCh     <-chan struct{}
@@ -140,8 +145,31 @@ func wrapConn(c *StdioConn, lim limiter.Limiter) *wrappedConn {
 	return wc
 }
 
-// New initializes a Backend and starts the process.
+// newBackend starts the rclone process and sets up the supervisor which
+// restarts it if configured.
 func newBackend(ctx context.Context, cfg Config, lim limiter.Limiter, errorLog func(string, ...interface{})) (*rclone, error) {
+	var restart bool
+	switch cfg.Restart {
+	case "", "never":
+	case "on-failure":
+		restart = true
+	default:
+		return nil, errors.Fatalf("invalid value %q for rclone.restart, must be \"never\" or \"on-failure\"", cfg.Restart)
+	}
+
+	p, err := startProcess(ctx, cfg, lim, errorLog)
+	if err != nil {
+		return nil, err
+	}
+
+	start := func(ctx context.Context) (instance, error) {
+		return startProcess(ctx, cfg, lim, errorLog)
+	}
+	return &rclone{sv: newSupervisor(p, start, restart, errorLog)}, nil
+}
+
+// startProcess starts rclone and waits until it accepts HTTP requests.
+func startProcess(ctx context.Context, cfg Config, lim limiter.Limiter, errorLog func(string, ...interface{})) (*process, error) {
 	var (
 		args []string
 		err  error
@@ -196,7 +224,7 @@ func newBackend(ctx context.Context, cfg Config, lim limiter.Limiter, errorLog f
 	}
 
 	cmd := stdioConn.cmd
-	be := &rclone{
+	be := &process{
 		tr:     tr,
 		cmd:    cmd,
 		waitCh: waitCh,
@@ -286,7 +314,7 @@ func Open(ctx context.Context, cfg Config, lim limiter.Limiter, errorLog func(st
 		URL:         url,
 	}
 
-	restBackend, err := rest.Open(ctx, restConfig, debug.RoundTripper(be.tr), errorLog)
+	restBackend, err := rest.Open(ctx, restConfig, debug.RoundTripper(be.sv), errorLog)
 	if err != nil {
 		_ = be.Close()
 		return nil, err
@@ -315,7 +343,7 @@ func Create(ctx context.Context, cfg Config, lim limiter.Limiter, errorLog func(
 		URL:         url,
 	}
 
-	restBackend, err := rest.Create(ctx, restConfig, debug.RoundTripper(be.tr), errorLog)
+	restBackend, err := rest.Create(ctx, restConfig, debug.RoundTripper(be.sv), errorLog)
 	if err != nil {
 		_ = be.Close()
 		return nil, err
@@ -329,6 +357,21 @@ const waitForExit = 5 * time.Second
 
 // Close terminates the backend.
 func (be *rclone) Close() error {
+	return be.sv.Close()
+}
+
+// RoundTrip sends an HTTP request to rclone.
+func (be *process) RoundTrip(req *http.Request) (*http.Response, error) {
+	return be.tr.RoundTrip(req)
+}
+
+// Exited returns a channel which is closed as soon as rclone has exited.
+func (be *process) Exited() <-chan struct{} {
+	return be.waitCh
+}
+
+// Close terminates the rclone process.
+func (be *process) Close() error {
 	debug.Log("exiting rclone")
 	be.tr.CloseIdleConnections()
 
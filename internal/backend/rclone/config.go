@@ -4,6 +4,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/restic/restic/internal/backend/limiter"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/options"
 )
@@ -15,6 +16,7 @@ type Config struct {
 	Remote      string
 	Connections uint          `option:"connections" help:"set a limit for the number of concurrent connections (default: 5)"`
 	Timeout     time.Duration `option:"timeout"     help:"set a timeout limit to wait for rclone to establish a connection (default: 1m)"`
+	Restart     string        `option:"restart"     help:"restart rclone if it exits unexpectedly, either never or on-failure (default: never)"`
 }
 
 var defaultConfig = Config{
@@ -22,10 +24,12 @@ var defaultConfig = Config{
 	Args:        "serve restic --stdio --b2-hard-delete",
 	Connections: 5,
 	Timeout:     time.Minute,
+	Restart:     "never",
 }
 
 func init() {
 	options.Register("rclone", Config{})
+	options.Register("rclone", limiter.BackendOptions{})
 }
 
 // NewConfig returns a new Config with the default values filled in.
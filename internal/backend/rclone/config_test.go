@@ -15,6 +15,7 @@ var configTests = []test.ConfigTestData[Config]{
 			Args:        defaultConfig.Args,
 			Connections: defaultConfig.Connections,
 			Timeout:     defaultConfig.Timeout,
+			Restart:     defaultConfig.Restart,
 		},
 	},
 }
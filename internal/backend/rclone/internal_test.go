@@ -28,7 +28,7 @@ func TestRcloneExit(t *testing.T) {
 		_ = be.Close()
 	}()
 
-	err = be.(*rclone).cmd.Process.Kill()
+	err = be.(*rclone).sv.current.(*process).cmd.Process.Kill()
 	rtest.OK(t, err)
 	t.Log("killed rclone")
 
@@ -41,6 +41,43 @@ func TestRcloneExit(t *testing.T) {
 	}
 }
 
+// restic should restart rclone if it exits unexpectedly.
+func TestRcloneRestart(t *testing.T) {
+	t.Parallel()
+	dir := rtest.TempDir(t)
+	cfg := NewConfig()
+	cfg.Remote = dir
+	cfg.Restart = "on-failure"
+	be, err := Open(context.TODO(), cfg, nil, t.Logf)
+	var e *exec.Error
+	if errors.As(err, &e) && e.Err == exec.ErrNotFound {
+		t.Skipf("program %q not found", e.Name)
+		return
+	}
+	rtest.OK(t, err)
+	defer func() {
+		rtest.OK(t, be.Close())
+	}()
+
+	err = be.(*rclone).sv.current.(*process).cmd.Process.Kill()
+	rtest.OK(t, err)
+	t.Log("killed rclone")
+
+	_, err = be.Stat(context.TODO(), backend.Handle{
+		Name: "foo",
+		Type: backend.PackFile,
+	})
+	rtest.Assert(t, be.IsNotExist(err), "expected a not found error, got %v", err)
+}
+
+// restic should reject unknown restart policies
+func TestRcloneInvalidRestart(t *testing.T) {
+	cfg := NewConfig()
+	cfg.Restart = "always"
+	_, err := Open(context.TODO(), cfg, nil, t.Logf)
+	rtest.Assert(t, err != nil && errors.IsFatal(err), "expected a fatal error, got %v", err)
+}
+
 // restic should detect rclone startup failures
 func TestRcloneFailedStart(t *testing.T) {
 	cfg := NewConfig()
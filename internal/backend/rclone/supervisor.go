@@ -0,0 +1,161 @@
+package rclone
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+)
+
+// maxReplays is the maximum number of times a request is sent again after
+// rclone exited while the request was in flight.
+const maxReplays = 3
+
+// waitForFailure is the time to wait for rclone to exit after a request has
+// failed before assuming that rclone is still running.
+const waitForFailure = time.Second
+
+// instance is a running rclone process which accepts HTTP requests.
+type instance interface {
+	http.RoundTripper
+	// Exited returns a channel which is closed when the process has exited.
+	Exited() <-chan struct{}
+	// Close terminates the process and returns its exit status.
+	Close() error
+}
+
+// supervisor forwards HTTP requests to the current rclone instance. If
+// restarting is enabled, it starts a new instance after the previous one
+// has exited unexpectedly and replays the requests which were in flight.
+type supervisor struct {
+	start    func(ctx context.Context) (instance, error)
+	restart  bool
+	errorLog func(string, ...interface{})
+	// newBackOff returns the backoff strategy used for restarting rclone
+	newBackOff func() backoff.BackOff
+
+	m       sync.Mutex
+	current instance
+	closed  bool
+}
+
+func newSupervisor(current instance, start func(ctx context.Context) (instance, error), restart bool, errorLog func(string, ...interface{})) *supervisor {
+	return &supervisor{
+		start:    start,
+		restart:  restart,
+		errorLog: errorLog,
+		newBackOff: func() backoff.BackOff {
+			bo := backoff.NewExponentialBackOff()
+			bo.MaxElapsedTime = 2 * time.Minute
+			return bo
+		},
+		current: current,
+	}
+}
+
+func hasExited(inst instance) bool {
+	select {
+	case <-inst.Exited():
+		return true
+	default:
+		return false
+	}
+}
+
+// instance returns the running rclone instance. If the previous instance has
+// exited and restarting is enabled, a new instance is started.
+func (s *supervisor) instance(ctx context.Context) (instance, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if !s.restart || s.closed || !hasExited(s.current) {
+		return s.current, nil
+	}
+
+	// collect the exit status of the previous instance
+	err := s.current.Close()
+	s.errorLog("rclone exited unexpectedly (%v), restarting\n", err)
+
+	var inst instance
+	err = backoff.RetryNotify(func() error {
+		var err error
+		inst, err = s.start(ctx)
+		if ctx.Err() != nil {
+			return backoff.Permanent(ctx.Err())
+		}
+		return err
+	}, backoff.WithContext(s.newBackOff(), ctx), func(err error, d time.Duration) {
+		s.errorLog("restarting rclone failed: %v, retrying in %v\n", err, d)
+	})
+	if err != nil {
+		return nil, errors.Errorf("restarting rclone failed: %v", err)
+	}
+
+	debug.Log("rclone restarted")
+	s.current = inst
+	return inst, nil
+}
+
+// replayable returns a copy of req which can be sent again, or nil if this is
+// not possible.
+func replayable(req *http.Request) *http.Request {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req
+	}
+	if req.GetBody == nil {
+		return nil
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		debug.Log("unable to rewind request body: %v", err)
+		return nil
+	}
+	replay := req.Clone(req.Context())
+	replay.Body = body
+	return replay
+}
+
+// RoundTrip sends req to the current rclone instance. If the instance exits
+// while the request is in flight, the request is sent to the restarted
+// instance if it can be replayed safely.
+func (s *supervisor) RoundTrip(req *http.Request) (*http.Response, error) {
+	for replays := 0; ; replays++ {
+		inst, err := s.instance(req.Context())
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := inst.RoundTrip(req)
+		if err == nil || !s.restart || req.Context().Err() != nil || replays >= maxReplays {
+			return res, err
+		}
+
+		// only replay the request if rclone has failed
+		select {
+		case <-inst.Exited():
+		case <-time.After(waitForFailure):
+			return res, err
+		}
+
+		replay := replayable(req)
+		if replay == nil {
+			return res, err
+		}
+		debug.Log("rclone exited during %v %v, replaying request", req.Method, req.URL)
+		req = replay
+	}
+}
+
+// Close terminates the current rclone instance.
+func (s *supervisor) Close() error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.closed = true
+	return s.current.Close()
+}
@@ -0,0 +1,144 @@
+package rclone
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/restic/restic/internal/errors"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+// fakeInstance answers requests until it is killed.
+type fakeInstance struct {
+	m        sync.Mutex
+	exited   chan struct{}
+	requests []string
+	// failNext kills the instance while the next request is in flight
+	failNext bool
+}
+
+func newFakeInstance() *fakeInstance {
+	return &fakeInstance{exited: make(chan struct{})}
+}
+
+func (f *fakeInstance) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if f.failNext || hasExited(f) {
+		if !hasExited(f) {
+			close(f.exited)
+		}
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	f.requests = append(f.requests, req.Method+" "+string(body))
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+}
+
+func (f *fakeInstance) Exited() <-chan struct{} {
+	return f.exited
+}
+
+func (f *fakeInstance) Close() error {
+	return nil
+}
+
+func newTestSupervisor(t *testing.T, restart bool, starts int) (*supervisor, []*fakeInstance) {
+	var instances []*fakeInstance
+	for i := 0; i < starts; i++ {
+		instances = append(instances, newFakeInstance())
+	}
+
+	started := 1
+	start := func(_ context.Context) (instance, error) {
+		if started >= len(instances) {
+			return nil, errors.New("no more instances")
+		}
+		inst := instances[started]
+		started++
+		return inst, nil
+	}
+
+	s := newSupervisor(instances[0], start, restart, t.Logf)
+	s.newBackOff = func() backoff.BackOff {
+		return backoff.WithMaxRetries(&backoff.ZeroBackOff{}, 2)
+	}
+	return s, instances
+}
+
+func sendRequest(t *testing.T, s *supervisor, method string, body string) error {
+	var rd io.Reader
+	if body != "" {
+		rd = strings.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(context.TODO(), method, "http://localhost/foo", rd)
+	rtest.OK(t, err)
+
+	res, err := s.RoundTrip(req)
+	if err == nil {
+		rtest.OK(t, res.Body.Close())
+	}
+	return err
+}
+
+func TestSupervisorReplay(t *testing.T) {
+	s, instances := newTestSupervisor(t, true, 2)
+
+	instances[0].failNext = true
+	rtest.OK(t, sendRequest(t, s, http.MethodPost, "data"))
+	rtest.Equals(t, []string(nil), instances[0].requests)
+	rtest.Equals(t, []string{"POST data"}, instances[1].requests)
+
+	rtest.OK(t, sendRequest(t, s, http.MethodGet, ""))
+	rtest.Equals(t, []string{"POST data", "GET "}, instances[1].requests)
+	rtest.OK(t, s.Close())
+}
+
+func TestSupervisorNoRestart(t *testing.T) {
+	s, instances := newTestSupervisor(t, false, 2)
+
+	instances[0].failNext = true
+	err := sendRequest(t, s, http.MethodGet, "")
+	rtest.Assert(t, errors.Is(err, io.ErrUnexpectedEOF), "unexpected error %v", err)
+	err = sendRequest(t, s, http.MethodGet, "")
+	rtest.Assert(t, errors.Is(err, io.ErrUnexpectedEOF), "unexpected error %v", err)
+	rtest.Equals(t, []string(nil), instances[1].requests)
+}
+
+func TestSupervisorNotReplayable(t *testing.T) {
+	s, instances := newTestSupervisor(t, true, 2)
+
+	// the body of this request cannot be read again
+	instances[0].failNext = true
+	req, err := http.NewRequestWithContext(context.TODO(), http.MethodPost, "http://localhost/foo", io.NopCloser(bytes.NewReader([]byte("data"))))
+	rtest.OK(t, err)
+	_, err = s.RoundTrip(req)
+	rtest.Assert(t, errors.Is(err, io.ErrUnexpectedEOF), "unexpected error %v", err)
+
+	// the next request is sent to the restarted instance
+	rtest.OK(t, sendRequest(t, s, http.MethodGet, ""))
+	rtest.Equals(t, []string{"GET "}, instances[1].requests)
+}
+
+func TestSupervisorRestartFailed(t *testing.T) {
+	s, instances := newTestSupervisor(t, true, 1)
+
+	instances[0].failNext = true
+	err := sendRequest(t, s, http.MethodGet, "")
+	rtest.Assert(t, err != nil && strings.Contains(err.Error(), "restarting rclone failed"), "unexpected error %v", err)
+}
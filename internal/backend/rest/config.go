@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/limiter"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/options"
 )
@@ -14,16 +15,23 @@ import (
 type Config struct {
 	URL         *url.URL
 	Connections uint `option:"connections" help:"set a limit for the number of concurrent connections (default: 5)"`
+	HTTP2       bool `option:"http2" help:"multiplex all requests over a single HTTP/2 connection, http:// URLs require server support for HTTP/2 without TLS"`
+	MaxStreams  uint `option:"max-streams" help:"set a limit for the number of concurrent requests when using HTTP/2 (default: 32)"`
+
+	Proxy     string `option:"proxy" help:"connect via this HTTP proxy URL instead of the proxy from the environment, 'none' connects directly"`
+	ProxyAuth string `option:"proxy-auth" help:"authentication method for the proxy: basic, ntlm or negotiate (default: basic)"`
 }
 
 func init() {
 	options.Register("rest", Config{})
+	options.Register("rest", limiter.BackendOptions{})
 }
 
 // NewConfig returns a new Config with the default values filled in.
 func NewConfig() Config {
 	return Config{
 		Connections: 5,
+		MaxStreams:  32,
 	}
 }
 
@@ -88,3 +96,19 @@ func (cfg *Config) ApplyEnvironment(prefix string) {
 		cfg.URL.User = url.UserPassword(envName, envPwd)
 	}
 }
+
+var _ backend.TransportConfigurer = &Config{}
+
+// ConfigureTransport enables HTTP/2 multiplexing and sets the proxy if
+// requested.
+func (cfg *Config) ConfigureTransport(opts *backend.TransportOptions) {
+	if cfg.HTTP2 {
+		opts.HTTP2Multiplexing = true
+	}
+	if cfg.Proxy != "" {
+		opts.Proxy = cfg.Proxy
+	}
+	if cfg.ProxyAuth != "" {
+		opts.ProxyAuth = cfg.ProxyAuth
+	}
+}
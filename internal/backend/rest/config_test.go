@@ -22,6 +22,7 @@ var configTests = []test.ConfigTestData[Config]{
 		Cfg: Config{
 			URL:         parseURL("http://localhost:1234/"),
 			Connections: 5,
+			MaxStreams:  32,
 		},
 	},
 	{
@@ -29,6 +30,7 @@ var configTests = []test.ConfigTestData[Config]{
 		Cfg: Config{
 			URL:         parseURL("http://localhost:1234/"),
 			Connections: 5,
+			MaxStreams:  32,
 		},
 	},
 	{
@@ -36,6 +38,7 @@ var configTests = []test.ConfigTestData[Config]{
 		Cfg: Config{
 			URL:         parseURL("http+unix:///tmp/rest.socket:/my_backup_repo/"),
 			Connections: 5,
+			MaxStreams:  32,
 		},
 	},
 }
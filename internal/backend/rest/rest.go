@@ -62,11 +62,17 @@ func Open(_ context.Context, cfg Config, rt http.RoundTripper, _ func(string, ..
 		url = url[:len(url)-1]
 	}
 
+	connections := cfg.Connections
+	if cfg.HTTP2 {
+		// requests are sent as streams over a single connection
+		connections = cfg.MaxStreams
+	}
+
 	be := &Backend{
 		url:         cfg.URL,
 		client:      http.Client{Transport: rt},
 		Layout:      layout.NewRESTLayout(url),
-		connections: cfg.Connections,
+		connections: connections,
 	}
 
 	return be, nil
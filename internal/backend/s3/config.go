@@ -1,13 +1,16 @@
 package s3
 
 import (
+	"net/http"
 	"net/url"
 	"os"
 	"path"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/limiter"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/options"
 )
@@ -34,6 +37,16 @@ type Config struct {
 	ListObjectsV1       bool   `option:"list-objects-v1" help:"use deprecated V1 api for ListObjects calls"`
 	UnsafeAnonymousAuth bool   `option:"unsafe-anonymous-auth" help:"use anonymous authentication"`
 
+	Retention     string `option:"retention" help:"protect pack files against deletion for this duration using S3 object lock, e.g. 30d (requires a bucket with object lock enabled)"`
+	RetentionMode string `option:"retention-mode" help:"object lock mode used for --retention: COMPLIANCE or GOVERNANCE (default: COMPLIANCE)"`
+
+	Proxy     string `option:"proxy" help:"connect via this HTTP proxy URL instead of the proxy from the environment, 'none' connects directly"`
+	ProxyAuth string `option:"proxy-auth" help:"authentication method for the proxy: basic, ntlm or negotiate (default: basic)"`
+
+	RequesterPays bool   `option:"requester-pays" help:"accept the charges for downloads and listing of a requester pays bucket"`
+	Headers       string `option:"headers" help:"send additional HTTP headers with every request, as comma-separated name=value pairs (x-amz-* headers are not supported)"`
+	Tags          string `option:"tags" help:"add tags to uploaded files, as comma-separated key=value pairs, e.g. cost-center=1234"`
+
 	// For testing only
 	KeyID  string
 	Secret options.SecretString
@@ -53,6 +66,7 @@ func NewConfig() Config {
 
 func init() {
 	options.Register("s3", Config{})
+	options.Register("s3", limiter.BackendOptions{})
 }
 
 // ParseConfig parses the string s and extracts the s3 config. The two
@@ -107,6 +121,71 @@ func createConfig(endpoint, bucket, prefix string, useHTTP bool) (*Config, error
 	return &cfg, nil
 }
 
+// parseRetention parses a retention period. In addition to the units supported
+// by time.ParseDuration, a number of days can be specified as "30d".
+func parseRetention(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	var d time.Duration
+	var err error
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		var n int
+		n, err = strconv.Atoi(days)
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		d, err = time.ParseDuration(s)
+	}
+	if err != nil || d <= 0 {
+		return 0, errors.Errorf("invalid retention %q, expected a positive duration like 30d or 12h", s)
+	}
+	return d, nil
+}
+
+// parseKeyValues parses a comma-separated list of key=value pairs.
+func parseKeyValues(s string) (map[string]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	m := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, errors.Errorf("invalid pair %q, expected key=value", pair)
+		}
+		if _, ok := m[key]; ok {
+			return nil, errors.Errorf("key %q is specified multiple times", key)
+		}
+		m[key] = strings.TrimSpace(value)
+	}
+	return m, nil
+}
+
+// parseHeaders parses the additional HTTP headers. Headers starting with
+// x-amz- are rejected, as S3 requires them to be signed, which is not possible
+// for headers added to every request.
+func parseHeaders(s string) (http.Header, error) {
+	m, err := parseKeyValues(s)
+	if err != nil {
+		return nil, errors.Wrap(err, "headers")
+	}
+	if m == nil {
+		return nil, nil
+	}
+	headers := make(http.Header)
+	for name, value := range m {
+		if strings.ContainsAny(name, " \t:") {
+			return nil, errors.Errorf("invalid header name %q", name)
+		}
+		if strings.HasPrefix(strings.ToLower(name), "x-amz-") {
+			return nil, errors.Errorf("header %q is not supported, x-amz-* headers must be signed", name)
+		}
+		headers.Set(name, value)
+	}
+	return headers, nil
+}
+
 var _ backend.ApplyEnvironmenter = &Config{}
 
 // ApplyEnvironment saves values from the environment to the config.
@@ -115,3 +194,15 @@ func (cfg *Config) ApplyEnvironment(prefix string) {
 		cfg.Region = os.Getenv(prefix + "AWS_DEFAULT_REGION")
 	}
 }
+
+var _ backend.TransportConfigurer = &Config{}
+
+// ConfigureTransport sets the proxy if requested.
+func (cfg *Config) ConfigureTransport(opts *backend.TransportOptions) {
+	if cfg.Proxy != "" {
+		opts.Proxy = cfg.Proxy
+	}
+	if cfg.ProxyAuth != "" {
+		opts.ProxyAuth = cfg.ProxyAuth
+	}
+}
@@ -1,6 +1,8 @@
 package s3
 
 import (
+	"net/http"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -130,3 +132,68 @@ func TestParseError(t *testing.T) {
 		}
 	}
 }
+
+func TestParseRetention(t *testing.T) {
+	for _, test := range []struct {
+		s string
+		d time.Duration
+	}{
+		{"", 0},
+		{"30d", 30 * 24 * time.Hour},
+		{"12h", 12 * time.Hour},
+		{"90m", 90 * time.Minute},
+	} {
+		d, err := parseRetention(test.s)
+		if err != nil {
+			t.Errorf("parseRetention(%q) returned error %v", test.s, err)
+		}
+		if d != test.d {
+			t.Errorf("parseRetention(%q) = %v, want %v", test.s, d, test.d)
+		}
+	}
+
+	for _, s := range []string{"d", "-1d", "0h", "30", "1w"} {
+		if _, err := parseRetention(s); err == nil {
+			t.Errorf("parseRetention(%q) did not return an error", s)
+		}
+	}
+}
+
+func TestParseKeyValues(t *testing.T) {
+	m, err := parseKeyValues("")
+	if err != nil || m != nil {
+		t.Errorf("parseKeyValues(\"\") = %v, %v, want nil", m, err)
+	}
+
+	m, err = parseKeyValues("cost-center=1234, team = backup,empty=")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"cost-center": "1234", "team": "backup", "empty": ""}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("parseKeyValues() = %v, want %v", m, want)
+	}
+
+	for _, s := range []string{"foo", "=bar", "a=1,,b=2", "a=1,a=2"} {
+		if _, err := parseKeyValues(s); err == nil {
+			t.Errorf("parseKeyValues(%q) did not return an error", s)
+		}
+	}
+}
+
+func TestParseHeaders(t *testing.T) {
+	headers, err := parseHeaders("x-tenant=foo,Cache-Control=no-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := http.Header{"X-Tenant": {"foo"}, "Cache-Control": {"no-cache"}}
+	if !reflect.DeepEqual(headers, want) {
+		t.Errorf("parseHeaders() = %v, want %v", headers, want)
+	}
+
+	for _, s := range []string{"x-amz-request-payer=requester", "X-Amz-Foo=bar", "a b=c", "a:b=c"} {
+		if _, err := parseHeaders(s); err == nil {
+			t.Errorf("parseHeaders(%q) did not return an error", s)
+		}
+	}
+}
@@ -23,6 +23,7 @@ import (
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/tags"
 )
 
 // s3 stores data on an S3 endpoint.
@@ -30,6 +31,15 @@ type s3 struct {
 	client *minio.Client
 	cfg    Config
 	layout.Layout
+
+	// packRetention is the object lock retention set for saved pack files
+	packRetention time.Duration
+	retentionMode minio.RetentionMode
+	// retention is the effective retention of pack files, including the
+	// default retention of the bucket
+	retention time.Duration
+	// tags are added to all uploaded files
+	tags map[string]string
 }
 
 // make sure that *Backend implements backend.Backend
@@ -61,11 +71,42 @@ func open(cfg Config, rt http.RoundTripper) (*s3, error) {
 		minio.MaxRetry = int(cfg.MaxRetries)
 	}
 
+	retention, err := parseRetention(cfg.Retention)
+	if err != nil {
+		return nil, err
+	}
+	retentionMode := minio.Compliance
+	if cfg.RetentionMode != "" {
+		retentionMode = minio.RetentionMode(strings.ToUpper(cfg.RetentionMode))
+		if !retentionMode.IsValid() {
+			return nil, fmt.Errorf(`bad retention-mode %q must be "COMPLIANCE" or "GOVERNANCE"`, cfg.RetentionMode)
+		}
+	}
+
+	headers, err := parseHeaders(cfg.Headers)
+	if err != nil {
+		return nil, err
+	}
+	userTags, err := parseKeyValues(cfg.Tags)
+	if err != nil {
+		return nil, errors.Wrap(err, "tags")
+	}
+	if userTags != nil {
+		if _, err := tags.NewTags(userTags, true); err != nil {
+			return nil, errors.Wrap(err, "tags")
+		}
+	}
+
 	creds, err := getCredentials(cfg, rt)
 	if err != nil {
 		return nil, errors.Wrap(err, "s3.getCredentials")
 	}
 
+	// the headers are only sent to the S3 endpoint, not to credential providers
+	if headers != nil {
+		rt = &headerRoundTripper{rt: rt, headers: headers}
+	}
+
 	options := &minio.Options{
 		Creds:     creds,
 		Secure:    !cfg.UseHTTP,
@@ -90,14 +131,76 @@ func open(cfg Config, rt http.RoundTripper) (*s3, error) {
 	}
 
 	be := &s3{
-		client: client,
-		cfg:    cfg,
-		Layout: layout.NewDefaultLayout(cfg.Prefix, path.Join),
+		client:        client,
+		cfg:           cfg,
+		Layout:        layout.NewDefaultLayout(cfg.Prefix, path.Join),
+		packRetention: retention,
+		retentionMode: retentionMode,
+		retention:     retention,
+		tags:          userTags,
 	}
 
 	return be, nil
 }
 
+// headerRoundTripper adds headers to all requests.
+type headerRoundTripper struct {
+	rt      http.RoundTripper
+	headers http.Header
+}
+
+func (h *headerRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for name, values := range h.headers {
+		req.Header[name] = values
+	}
+	return h.rt.RoundTrip(req)
+}
+
+// getOptions returns the options for downloading or stat'ing a file.
+func (be *s3) getOptions() minio.GetObjectOptions {
+	opts := minio.GetObjectOptions{}
+	if be.cfg.RequesterPays {
+		opts.Set("x-amz-request-payer", "requester")
+	}
+	return opts
+}
+
+// detectObjectLock reads the object lock configuration of the bucket. The
+// default retention of the bucket also protects pack files against deletion.
+func (be *s3) detectObjectLock(ctx context.Context) error {
+	objectLock, _, validity, unit, err := be.client.GetObjectLockConfig(ctx, be.cfg.Bucket)
+	if err != nil {
+		// buckets without object lock return an error, some S3-compatible
+		// services do not support the request at all
+		debug.Log("GetObjectLockConfig(%v) returned err %v", be.cfg.Bucket, err)
+		if be.packRetention > 0 {
+			return errors.Wrap(err, "client.GetObjectLockConfig")
+		}
+		return nil
+	}
+
+	if objectLock != "Enabled" {
+		if be.packRetention > 0 {
+			return errors.Errorf("object lock is not enabled for bucket %v, which is required for -o s3.retention", be.cfg.Bucket)
+		}
+		return nil
+	}
+
+	if validity != nil && unit != nil {
+		var defaultRetention time.Duration
+		switch *unit {
+		case minio.Days:
+			defaultRetention = time.Duration(*validity) * 24 * time.Hour
+		case minio.Years:
+			defaultRetention = time.Duration(*validity) * 365 * 24 * time.Hour
+		}
+		debug.Log("bucket %v has default retention %v", be.cfg.Bucket, defaultRetention)
+		be.retention = max(be.retention, defaultRetention)
+	}
+	return nil
+}
+
 // getCredentials -- runs through the various credential types and returns the first one that works.
 // additionally if the user has specified a role to assume, it will do that as well.
 func getCredentials(cfg Config, tr http.RoundTripper) (*credentials.Credentials, error) {
@@ -197,8 +300,15 @@ func getCredentials(cfg Config, tr http.RoundTripper) (*credentials.Credentials,
 
 // Open opens the S3 backend at bucket and region. The bucket is created if it
 // does not exist yet.
-func Open(_ context.Context, cfg Config, rt http.RoundTripper, _ func(string, ...interface{})) (backend.Backend, error) {
-	return open(cfg, rt)
+func Open(ctx context.Context, cfg Config, rt http.RoundTripper, _ func(string, ...interface{})) (backend.Backend, error) {
+	be, err := open(cfg, rt)
+	if err != nil {
+		return nil, err
+	}
+	if err := be.detectObjectLock(ctx); err != nil {
+		return nil, err
+	}
+	return be, nil
 }
 
 // Create opens the S3 backend at bucket and region and creates the bucket if
@@ -222,12 +332,15 @@ func Create(ctx context.Context, cfg Config, rt http.RoundTripper, _ func(string
 
 	if !found {
 		// create new bucket with default ACL in default region
-		err = be.client.MakeBucket(ctx, cfg.Bucket, minio.MakeBucketOptions{})
+		err = be.client.MakeBucket(ctx, cfg.Bucket, minio.MakeBucketOptions{ObjectLocking: be.packRetention > 0})
 		if err != nil {
 			return nil, errors.Wrap(err, "client.MakeBucket")
 		}
 	}
 
+	if err := be.detectObjectLock(ctx); err != nil {
+		return nil, err
+	}
 	return be, nil
 }
 
@@ -264,6 +377,7 @@ func (be *s3) Properties() backend.Properties {
 	return backend.Properties{
 		Connections:      be.cfg.Connections,
 		HasAtomicReplace: true,
+		Retention:        be.retention,
 	}
 }
 
@@ -295,6 +409,11 @@ func (be *s3) Save(ctx context.Context, h backend.Handle, rd backend.RewindReade
 	if be.useStorageClass(h) {
 		opts.StorageClass = be.cfg.StorageClass
 	}
+	if be.packRetention > 0 && h.Type == backend.PackFile {
+		opts.Mode = be.retentionMode
+		opts.RetainUntilDate = time.Now().Add(be.packRetention)
+	}
+	opts.UserTags = be.tags
 
 	info, err := be.client.PutObject(ctx, be.cfg.Bucket, objName, io.NopCloser(rd), rd.Length(), opts)
 
@@ -317,7 +436,7 @@ func (be *s3) Load(ctx context.Context, h backend.Handle, length int, offset int
 
 func (be *s3) openReader(ctx context.Context, h backend.Handle, length int, offset int64) (io.ReadCloser, error) {
 	objName := be.Filename(h)
-	opts := minio.GetObjectOptions{}
+	opts := be.getOptions()
 
 	var err error
 	if length > 0 {
@@ -351,7 +470,7 @@ func (be *s3) Stat(ctx context.Context, h backend.Handle) (bi backend.FileInfo,
 	objName := be.Filename(h)
 	var obj *minio.Object
 
-	opts := minio.GetObjectOptions{}
+	opts := be.getOptions()
 
 	obj, err = be.client.GetObject(ctx, be.cfg.Bucket, objName, opts)
 	if err != nil {
@@ -374,6 +493,25 @@ func (be *s3) Stat(ctx context.Context, h backend.Handle) (bi backend.FileInfo,
 	return backend.FileInfo{Size: fi.Size, Name: h.Name}, nil
 }
 
+// SetStorageClass moves the file h to another storage class by copying the
+// object onto itself.
+func (be *s3) SetStorageClass(ctx context.Context, h backend.Handle, class string) error {
+	objName := be.Filename(h)
+	headers := map[string]string{
+		"x-amz-storage-class":      strings.ToUpper(class),
+		"x-amz-metadata-directive": "COPY",
+	}
+	if be.packRetention > 0 && h.Type == backend.PackFile {
+		headers["x-amz-object-lock-mode"] = be.retentionMode.String()
+		headers["x-amz-object-lock-retain-until-date"] = time.Now().Add(be.packRetention).UTC().Format(time.RFC3339)
+	}
+
+	coreClient := minio.Core{Client: be.client}
+	_, err := coreClient.CopyObject(ctx, be.cfg.Bucket, objName, be.cfg.Bucket, objName, headers,
+		minio.CopySrcOptions{Bucket: be.cfg.Bucket, Object: objName}, minio.PutObjectOptions{})
+	return errors.Wrap(err, "client.CopyObject")
+}
+
 // Remove removes the blob with the given name and type.
 func (be *s3) Remove(ctx context.Context, h backend.Handle) error {
 	objName := be.Filename(h)
@@ -405,11 +543,15 @@ func (be *s3) List(ctx context.Context, t backend.FileType, fn func(backend.File
 	// NB: unfortunately we can't protect this with be.sem.GetToken() here.
 	// Doing so would enable a deadlock situation (gh-1399), as ListObjects()
 	// starts its own goroutine and returns results via a channel.
-	listresp := be.client.ListObjects(ctx, be.cfg.Bucket, minio.ListObjectsOptions{
+	listOpts := minio.ListObjectsOptions{
 		Prefix:    prefix,
 		Recursive: recursive,
 		UseV1:     be.cfg.ListObjectsV1,
-	})
+	}
+	if be.cfg.RequesterPays {
+		listOpts.Set("x-amz-request-payer", "requester")
+	}
+	listresp := be.client.ListObjects(ctx, be.cfg.Bucket, listOpts)
 
 	for obj := range listresp {
 		if obj.Err != nil {
@@ -422,8 +564,10 @@ func (be *s3) List(ctx context.Context, t backend.FileType, fn func(backend.File
 		}
 
 		fi := backend.FileInfo{
-			Name: path.Base(m),
-			Size: obj.Size,
+			Name:         path.Base(m),
+			Size:         obj.Size,
+			ModTime:      obj.LastModified,
+			StorageClass: obj.StorageClass,
 		}
 
 		if ctx.Err() != nil {
@@ -474,7 +618,7 @@ func (be *s3) Warmup(ctx context.Context, handles []backend.Handle) ([]backend.H
 
 // requestRestore sends a glacier restore request on a given file.
 func (be *s3) requestRestore(ctx context.Context, filename string) (bool, error) {
-	objectInfo, err := be.client.StatObject(ctx, be.cfg.Bucket, filename, minio.StatObjectOptions{})
+	objectInfo, err := be.client.StatObject(ctx, be.cfg.Bucket, filename, be.getOptions())
 	if err != nil {
 		return false, err
 	}
@@ -569,7 +713,7 @@ func (be *s3) waitForRestore(ctx context.Context, filename string) error {
 		b = backoff.WithContext(b, ctx)
 		err := backoff.Retry(
 			func() (err error) {
-				objectInfo, err = be.client.StatObject(ctx, be.cfg.Bucket, filename, minio.StatObjectOptions{})
+				objectInfo, err = be.client.StatObject(ctx, be.cfg.Bucket, filename, be.getOptions())
 				return
 			},
 			b,
@@ -5,6 +5,7 @@ import (
 	"path"
 	"strings"
 
+	"github.com/restic/restic/internal/backend/limiter"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/options"
 )
@@ -16,7 +17,7 @@ type Config struct {
 	Command string `option:"command" help:"specify command to create sftp connection"`
 	Args    string `option:"args"    help:"specify arguments for ssh"`
 
-	Connections uint `option:"connections" help:"set a limit for the number of concurrent connections (default: 5)"`
+	Connections uint `option:"connections" help:"set a limit for the number of concurrent sftp connections (default: 5)"`
 }
 
 // NewConfig returns a new config with default options applied.
@@ -28,6 +29,7 @@ func NewConfig() Config {
 
 func init() {
 	options.Register("sftp", Config{})
+	options.Register("sftp", limiter.BackendOptions{})
 }
 
 // ParseConfig parses the string s and extracts the sftp config. The
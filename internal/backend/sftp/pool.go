@@ -0,0 +1,236 @@
+package sftp
+
+import (
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+
+	"github.com/pkg/sftp"
+)
+
+// healthCheckInterval is the duration after which an idle connection is
+// checked before it is used again.
+var healthCheckInterval = 30 * time.Second
+
+// connection is a single sftp session, usually running via an ssh process.
+type connection struct {
+	c *sftp.Client
+
+	cmd    *exec.Cmd
+	result <-chan error
+
+	// the following fields are protected by the pool mutex
+	inflight int
+	lastUsed time.Time
+	broken   bool
+}
+
+// exited returns an error if the underlying process has exited. Otherwise, nil
+// is returned immediately.
+func (conn *connection) exited() error {
+	select {
+	case err := <-conn.result:
+		if err == nil {
+			err = errors.New("ssh command exited")
+		}
+		return err
+	default:
+	}
+	return nil
+}
+
+var closeTimeout = 2 * time.Second
+
+// close closes the sftp session and terminates the underlying command.
+func (conn *connection) close() error {
+	err := errors.Wrap(conn.c.Close(), "Close")
+	debug.Log("Close returned error %v", err)
+
+	// wait for closeTimeout before killing the process
+	select {
+	case err := <-conn.result:
+		return err
+	case <-time.After(closeTimeout):
+	}
+
+	if conn.cmd == nil {
+		return nil
+	}
+	if err := conn.cmd.Process.Kill(); err != nil {
+		return err
+	}
+
+	// get the error, but ignore it
+	<-conn.result
+	return nil
+}
+
+// connectionPool manages up to max connections. Operations are spread across
+// the connections, which are only started once all existing connections are
+// in use. Connections which were lost are replaced transparently.
+type connectionPool struct {
+	start func() (*connection, error)
+	max   int
+
+	m        sync.Mutex
+	conns    []*connection
+	starting int
+	closed   bool
+}
+
+func newConnectionPool(first *connection, max uint, start func() (*connection, error)) *connectionPool {
+	if max == 0 {
+		max = 1
+	}
+	first.lastUsed = time.Now()
+	return &connectionPool{
+		start: start,
+		max:   int(max),
+		conns: []*connection{first},
+	}
+}
+
+// leastLoaded returns the connection with the fewest running operations.
+func (p *connectionPool) leastLoaded() *connection {
+	var best *connection
+	for _, conn := range p.conns {
+		if best == nil || conn.inflight < best.inflight {
+			best = conn
+		}
+	}
+	return best
+}
+
+// removeLocked removes conn from the pool. It is closed once all running
+// operations have finished.
+func (p *connectionPool) removeLocked(conn *connection) {
+	for i, c := range p.conns {
+		if c == conn {
+			p.conns = append(p.conns[:i], p.conns[i+1:]...)
+			break
+		}
+	}
+	conn.broken = true
+	if conn.inflight == 0 {
+		go func() {
+			_ = conn.close()
+		}()
+	}
+}
+
+// get returns a healthy connection. The connection must be returned using put.
+func (p *connectionPool) get() (*connection, error) {
+	p.m.Lock()
+	if p.closed {
+		p.m.Unlock()
+		return nil, errors.New("sftp backend is closed")
+	}
+
+	for {
+		for _, conn := range p.conns {
+			if err := conn.exited(); err != nil {
+				debug.Log("dropping connection: %v", err)
+				p.removeLocked(conn)
+			}
+		}
+
+		conn := p.leastLoaded()
+		if conn == nil || (conn.inflight > 0 && len(p.conns)+p.starting < p.max) {
+			break
+		}
+
+		if conn.inflight == 0 && time.Since(conn.lastUsed) > healthCheckInterval {
+			// make sure the connection still works before using it
+			conn.inflight++
+			p.m.Unlock()
+			_, err := conn.c.Getwd()
+			p.m.Lock()
+			conn.inflight--
+			if err != nil {
+				debug.Log("health check failed, dropping connection: %v", err)
+				p.removeLocked(conn)
+				continue
+			}
+			conn.lastUsed = time.Now()
+		}
+
+		conn.inflight++
+		p.m.Unlock()
+		return conn, nil
+	}
+
+	p.starting++
+	p.m.Unlock()
+
+	debug.Log("starting new connection")
+	conn, err := p.start()
+
+	p.m.Lock()
+	defer p.m.Unlock()
+	p.starting--
+
+	if err != nil {
+		// an additional connection is optional, fall back to an existing one
+		if existing := p.leastLoaded(); existing != nil {
+			debug.Log("unable to start additional connection: %v", err)
+			existing.inflight++
+			return existing, nil
+		}
+		return nil, err
+	}
+	if p.closed {
+		go func() {
+			_ = conn.close()
+		}()
+		return nil, errors.New("sftp backend is closed")
+	}
+
+	conn.inflight++
+	p.conns = append(p.conns, conn)
+	return conn, nil
+}
+
+// put returns conn to the pool. err is the result of the operation that used
+// the connection, if it indicates that the connection was lost, the connection
+// is removed from the pool.
+func (p *connectionPool) put(conn *connection, err error) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	conn.inflight--
+	conn.lastUsed = time.Now()
+
+	if conn.broken {
+		if conn.inflight == 0 {
+			go func() {
+				_ = conn.close()
+			}()
+		}
+		return
+	}
+
+	if errors.Is(err, sftp.ErrSSHFxConnectionLost) || conn.exited() != nil {
+		debug.Log("connection lost: %v", err)
+		p.removeLocked(conn)
+	}
+}
+
+// close closes all connections.
+func (p *connectionPool) close() error {
+	p.m.Lock()
+	p.closed = true
+	conns := p.conns
+	p.conns = nil
+	p.m.Unlock()
+
+	var firstErr error
+	for _, conn := range conns {
+		if err := conn.close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,137 @@
+package sftp
+
+import (
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+// testConnections starts sftp sessions served in-process.
+type testConnections struct {
+	t       testing.TB
+	started atomic.Int32
+	// closing a pipe writer disconnects the corresponding session
+	pipes []*io.PipeWriter
+}
+
+func (tc *testConnections) start() (*connection, error) {
+	clientRd, serverWr := io.Pipe()
+	serverRd, clientWr := io.Pipe()
+
+	server, err := sftp.NewServer(struct {
+		io.Reader
+		io.WriteCloser
+	}{serverRd, serverWr})
+	rtest.OK(tc.t, err)
+	go func() {
+		_ = server.Serve()
+		// terminate the session like an exiting ssh process
+		_ = serverWr.Close()
+	}()
+
+	client, err := sftp.NewClientPipe(clientRd, clientWr)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan error, 1)
+	go func() {
+		err := client.Wait()
+		for {
+			ch <- err
+		}
+	}()
+
+	tc.started.Add(1)
+	tc.pipes = append(tc.pipes, serverWr)
+	return &connection{c: client, result: ch}, nil
+}
+
+func newTestPool(t testing.TB, max uint) (*connectionPool, *testConnections) {
+	tc := &testConnections{t: t}
+	first, err := tc.start()
+	rtest.OK(t, err)
+	pool := newConnectionPool(first, max, tc.start)
+	t.Cleanup(func() {
+		_ = pool.close()
+	})
+	return pool, tc
+}
+
+func TestConnectionPoolGrows(t *testing.T) {
+	pool, tc := newTestPool(t, 2)
+
+	c1, err := pool.get()
+	rtest.OK(t, err)
+	c2, err := pool.get()
+	rtest.OK(t, err)
+	rtest.Assert(t, c1 != c2, "expected a second connection while the first one is in use")
+
+	// the pool is full, the least loaded connection is shared
+	c3, err := pool.get()
+	rtest.OK(t, err)
+	rtest.Assert(t, c3 == c1 || c3 == c2, "unexpected new connection")
+	rtest.Equals(t, int32(2), tc.started.Load())
+
+	for _, conn := range []*connection{c1, c2, c3} {
+		pool.put(conn, nil)
+	}
+
+	// idle connections are reused
+	c4, err := pool.get()
+	rtest.OK(t, err)
+	pool.put(c4, nil)
+	rtest.Equals(t, int32(2), tc.started.Load())
+}
+
+func TestConnectionPoolReconnect(t *testing.T) {
+	pool, tc := newTestPool(t, 1)
+
+	// disconnect the session
+	rtest.OK(t, tc.pipes[0].Close())
+
+	// the lost connection is replaced by a new one, at most one operation fails
+	var err error
+	for i := 0; i < 2; i++ {
+		var conn *connection
+		conn, err = pool.get()
+		rtest.OK(t, err)
+		_, err = conn.c.Getwd()
+		pool.put(conn, err)
+		if err == nil {
+			break
+		}
+		t.Logf("operation failed: %v", err)
+	}
+	rtest.OK(t, err)
+	rtest.Equals(t, int32(2), tc.started.Load())
+}
+
+func TestConnectionPoolHealthCheck(t *testing.T) {
+	defer func(interval time.Duration) {
+		healthCheckInterval = interval
+	}(healthCheckInterval)
+	healthCheckInterval = 0
+
+	pool, tc := newTestPool(t, 1)
+	first, err := pool.get()
+	rtest.OK(t, err)
+	pool.put(first, nil)
+
+	// pretend that the process is still running, such that only the health
+	// check is able to detect the lost connection
+	first.result = make(chan error)
+	rtest.OK(t, tc.pipes[0].Close())
+
+	conn, err := pool.get()
+	rtest.OK(t, err)
+	rtest.Assert(t, conn != first, "broken connection was not replaced")
+	_, err = conn.c.Getwd()
+	pool.put(conn, err)
+	rtest.OK(t, err)
+}
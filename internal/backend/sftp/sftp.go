@@ -13,7 +13,6 @@ import (
 	"path"
 	"sync/atomic"
 	"syscall"
-	"time"
 
 	"github.com/restic/restic/internal/backend"
 	"github.com/restic/restic/internal/backend/layout"
@@ -32,11 +31,8 @@ import (
 
 // SFTP is a backend in a directory accessed via SFTP.
 type SFTP struct {
-	c *sftp.Client
-	p string
-
-	cmd    *exec.Cmd
-	result <-chan error
+	pool *connectionPool
+	p    string
 
 	posixRename       bool
 	chmodBeforeRemove atomic.Bool
@@ -55,6 +51,24 @@ func NewFactory() location.Factory {
 }
 
 func startClient(cfg Config, errorLog func(string, ...interface{})) (*SFTP, error) {
+	conn, err := startConnection(cfg, errorLog)
+	if err != nil {
+		return nil, err
+	}
+
+	_, posixRename := conn.c.HasExtension("posix-rename@openssh.com")
+	return &SFTP{
+		pool: newConnectionPool(conn, cfg.Connections, func() (*connection, error) {
+			return startConnection(cfg, errorLog)
+		}),
+		posixRename: posixRename,
+		Layout:      layout.NewDefaultLayout(cfg.Path, path.Join),
+	}, nil
+}
+
+// startConnection starts a new sftp session by running "ssh" with the
+// appropriate arguments (or cfg.Command, if set).
+func startConnection(cfg Config, errorLog func(string, ...interface{})) (*connection, error) {
 	program, args, err := buildSSHCommand(cfg)
 	if err != nil {
 		return nil, err
@@ -122,27 +136,23 @@ func startClient(cfg Config, errorLog func(string, ...interface{})) (*SFTP, erro
 		return nil, errors.Wrap(err, "bg")
 	}
 
-	_, posixRename := client.HasExtension("posix-rename@openssh.com")
-	return &SFTP{
-		c:           client,
-		cmd:         cmd,
-		result:      ch,
-		posixRename: posixRename,
-		Layout:      layout.NewDefaultLayout(cfg.Path, path.Join),
+	return &connection{
+		c:      client,
+		cmd:    cmd,
+		result: ch,
 	}, nil
 }
 
-// clientError returns an error if the client has exited. Otherwise, nil is
-// returned immediately.
-func (r *SFTP) clientError() error {
-	select {
-	case err := <-r.result:
-		debug.Log("client has exited with err %v", err)
-		return backoff.Permanent(err)
-	default:
+// withClient runs fn with a client from the connection pool. If the
+// connection was lost, it is replaced for the next operation.
+func (r *SFTP) withClient(fn func(c *sftp.Client) error) error {
+	conn, err := r.pool.get()
+	if err != nil {
+		return err
 	}
-
-	return nil
+	err = fn(conn.c)
+	r.pool.put(conn, err)
+	return err
 }
 
 // Open opens an sftp backend as described by the config by running
@@ -150,24 +160,29 @@ func (r *SFTP) clientError() error {
 func Open(_ context.Context, cfg Config, errorLog func(string, ...interface{})) (*SFTP, error) {
 	debug.Log("open backend with config %#v", cfg)
 
-	sftp, err := startClient(cfg, errorLog)
+	be, err := startClient(cfg, errorLog)
 	if err != nil {
 		debug.Log("unable to start program: %v", err)
 		return nil, err
 	}
 
-	return open(sftp, cfg)
+	return open(be, cfg)
 }
 
-func open(sftp *SFTP, cfg Config) (*SFTP, error) {
-	fi, err := sftp.c.Stat(sftp.Layout.Filename(backend.Handle{Type: backend.ConfigFile}))
+func open(be *SFTP, cfg Config) (*SFTP, error) {
+	var fi os.FileInfo
+	err := be.withClient(func(c *sftp.Client) error {
+		var err error
+		fi, err = c.Stat(be.Layout.Filename(backend.Handle{Type: backend.ConfigFile}))
+		return err
+	})
 	m := util.DeriveModesFromFileInfo(fi, err)
 	debug.Log("using (%03O file, %03O dir) permissions", m.File, m.Dir)
 
-	sftp.Config = cfg
-	sftp.p = cfg.Path
-	sftp.Modes = m
-	return sftp, nil
+	be.Config = cfg
+	be.p = cfg.Path
+	be.Modes = m
+	return be, nil
 }
 
 func (r *SFTP) mkdirAllDataSubdirs(ctx context.Context, nconn uint) error {
@@ -184,10 +199,12 @@ func (r *SFTP) mkdirAllDataSubdirs(ctx context.Context, nconn uint) error {
 			// this is two round trips. pkg/sftp has no mkdir that sets a
 			// mode. When the parent is missing, fall back to mkdirAll, which
 			// adds a Stat and recurses, taking several more round trips.
-			if err := r.c.Mkdir(d); err == nil {
-				return errors.Wrapf(r.c.Chmod(d, r.Modes.Dir), "Chmod %v", d)
-			}
-			return errors.Wrapf(r.mkdirAll(d, r.Modes.Dir), "MkdirAll %v", d)
+			return r.withClient(func(c *sftp.Client) error {
+				if err := c.Mkdir(d); err == nil {
+					return errors.Wrapf(c.Chmod(d, r.Modes.Dir), "Chmod %v", d)
+				}
+				return errors.Wrapf(r.mkdirAll(c, d, r.Modes.Dir), "MkdirAll %v", d)
+			})
 		})
 	}
 
@@ -197,9 +214,9 @@ func (r *SFTP) mkdirAllDataSubdirs(ctx context.Context, nconn uint) error {
 // mkdirAll creates dir and any missing parent directories with the given mode.
 // (*sftp.Client).MkdirAll does not accept a mode, so directories would
 // otherwise inherit the SFTP server's umask.
-func (r *SFTP) mkdirAll(dir string, mode os.FileMode) error {
+func (r *SFTP) mkdirAll(c *sftp.Client, dir string, mode os.FileMode) error {
 	// If dir already exists, leave it and its mode untouched.
-	if fi, err := r.c.Stat(dir); err == nil {
+	if fi, err := c.Stat(dir); err == nil {
 		if fi.IsDir() {
 			return nil
 		}
@@ -208,20 +225,20 @@ func (r *SFTP) mkdirAll(dir string, mode os.FileMode) error {
 
 	// Create the parent directory first, then dir itself.
 	if parent := path.Dir(dir); parent != dir && parent != "." {
-		if err := r.mkdirAll(parent, mode); err != nil {
+		if err := r.mkdirAll(c, parent, mode); err != nil {
 			return err
 		}
 	}
 
-	if err := r.c.Mkdir(dir); err != nil {
+	if err := c.Mkdir(dir); err != nil {
 		// Ignore the error if another connection created dir concurrently.
-		if fi, statErr := r.c.Lstat(dir); statErr == nil && fi.IsDir() {
+		if fi, statErr := c.Lstat(dir); statErr == nil && fi.IsDir() {
 			return nil
 		}
 		return err
 	}
 
-	return r.c.Chmod(dir, mode)
+	return c.Chmod(dir, mode)
 }
 
 // IsNotExist returns true if the error is caused by a not existing file.
@@ -274,27 +291,30 @@ func buildSSHCommand(cfg Config) (cmd string, args []string, err error) {
 // Create creates an sftp backend as described by the config by running "ssh"
 // with the appropriate arguments (or cfg.Command, if set).
 func Create(ctx context.Context, cfg Config, errorLog func(string, ...interface{})) (*SFTP, error) {
-	sftp, err := startClient(cfg, errorLog)
+	be, err := startClient(cfg, errorLog)
 	if err != nil {
 		debug.Log("unable to start program: %v", err)
 		return nil, err
 	}
 
-	sftp.Modes = util.DefaultModes
+	be.Modes = util.DefaultModes
 
 	// test if config file already exists
-	_, err = sftp.c.Lstat(sftp.Layout.Filename(backend.Handle{Type: backend.ConfigFile}))
+	err = be.withClient(func(c *sftp.Client) error {
+		_, err := c.Lstat(be.Layout.Filename(backend.Handle{Type: backend.ConfigFile}))
+		return err
+	})
 	if err == nil {
 		return nil, errors.New("config file already exists")
 	}
 
 	// create paths for data and refs
-	if err = sftp.mkdirAllDataSubdirs(ctx, cfg.Connections); err != nil {
+	if err = be.mkdirAllDataSubdirs(ctx, cfg.Connections); err != nil {
 		return nil, err
 	}
 
 	// repurpose existing connection
-	return open(sftp, cfg)
+	return open(be, cfg)
 }
 
 func (r *SFTP) Properties() backend.Properties {
@@ -334,25 +354,27 @@ func setFileReadonly(client *sftp.Client, path string, mode os.FileMode) error {
 
 // Save stores data in the backend at the handle.
 func (r *SFTP) Save(_ context.Context, h backend.Handle, rd backend.RewindReader) error {
-	if err := r.clientError(); err != nil {
-		return err
-	}
+	return r.withClient(func(c *sftp.Client) error {
+		return r.save(c, h, rd)
+	})
+}
 
+func (r *SFTP) save(c *sftp.Client, h backend.Handle, rd backend.RewindReader) error {
 	filename := r.Filename(h)
 	tmpFilename := filename + "-restic-temp-" + tempSuffix()
 	dirname := r.Dirname(h)
 
 	// create new file
-	f, err := r.c.OpenFile(tmpFilename, os.O_CREATE|os.O_EXCL|os.O_WRONLY)
+	f, err := c.OpenFile(tmpFilename, os.O_CREATE|os.O_EXCL|os.O_WRONLY)
 
 	if r.IsNotExist(err) {
 		// error is caused by a missing directory, try to create it
-		mkdirErr := r.mkdirAll(r.Dirname(h), r.Modes.Dir)
+		mkdirErr := r.mkdirAll(c, r.Dirname(h), r.Modes.Dir)
 		if mkdirErr != nil {
 			debug.Log("error creating dir %v: %v", r.Dirname(h), mkdirErr)
 		} else {
 			// try again
-			f, err = r.c.OpenFile(tmpFilename, os.O_CREATE|os.O_EXCL|os.O_WRONLY)
+			f, err = c.OpenFile(tmpFilename, os.O_CREATE|os.O_EXCL|os.O_WRONLY)
 		}
 	}
 
@@ -376,7 +398,7 @@ func (r *SFTP) Save(_ context.Context, h backend.Handle, rd backend.RewindReader
 		}
 
 		// Try not to leave a partial file behind.
-		rmErr := r.c.Remove(f.Name())
+		rmErr := c.Remove(f.Name())
 		if rmErr != nil {
 			debug.Log("sftp: failed to remove broken file %v: %v",
 				f.Name(), rmErr)
@@ -387,7 +409,7 @@ func (r *SFTP) Save(_ context.Context, h backend.Handle, rd backend.RewindReader
 	wbytes, err := f.ReadFromWithConcurrency(rd, 0)
 	if err != nil {
 		_ = f.Close()
-		err = r.checkNoSpace(dirname, rd.Length(), err)
+		err = r.checkNoSpace(c, dirname, rd.Length(), err)
 		return errors.Wrapf(err, "Write %v", tmpFilename)
 	}
 
@@ -403,31 +425,31 @@ func (r *SFTP) Save(_ context.Context, h backend.Handle, rd backend.RewindReader
 
 	// Prefer POSIX atomic rename if available.
 	if r.posixRename {
-		err = r.c.PosixRename(tmpFilename, filename)
+		err = c.PosixRename(tmpFilename, filename)
 	} else {
-		err = r.c.Rename(tmpFilename, filename)
+		err = c.Rename(tmpFilename, filename)
 	}
 	if err != nil {
 		return errors.Wrapf(err, "Rename %v", tmpFilename)
 	}
-	err = setFileReadonly(r.c, filename, r.Modes.File)
+	err = setFileReadonly(c, filename, r.Modes.File)
 	return errors.Wrapf(err, "setFileReadonly %v", filename)
 }
 
 // checkNoSpace checks if err was likely caused by lack of available space
 // on the remote, and if so, makes it permanent.
-func (r *SFTP) checkNoSpace(dir string, size int64, origErr error) error {
+func (r *SFTP) checkNoSpace(c *sftp.Client, dir string, size int64, origErr error) error {
 	// The SFTP protocol has a message for ENOSPC,
 	// but pkg/sftp doesn't export it and OpenSSH's sftp-server
 	// sends FX_FAILURE instead.
 
 	e, ok := origErr.(*sftp.StatusError)
-	_, hasExt := r.c.HasExtension("statvfs@openssh.com")
+	_, hasExt := c.HasExtension("statvfs@openssh.com")
 	if !ok || e.FxCode() != sftp.ErrSSHFxFailure || !hasExt {
 		return origErr
 	}
 
-	fsinfo, err := r.c.StatVFS(dir)
+	fsinfo, err := c.StatVFS(dir)
 	if err != nil {
 		debug.Log("sftp: StatVFS returned %v", err)
 		return origErr
@@ -442,10 +464,6 @@ func (r *SFTP) checkNoSpace(dir string, size int64, origErr error) error {
 // Load runs fn with a reader that yields the contents of the file at h at the
 // given offset.
 func (r *SFTP) Load(ctx context.Context, h backend.Handle, length int, offset int64, fn func(rd io.Reader) error) error {
-	if err := r.clientError(); err != nil {
-		return err
-	}
-
 	return util.DefaultLoad(ctx, h, length, offset, r.openReader, func(rd io.Reader) error {
 		if length == 0 || !feature.Flag.Enabled(feature.BackendErrorRedesign) {
 			return fn(rd)
@@ -467,8 +485,14 @@ func (r *SFTP) Load(ctx context.Context, h backend.Handle, length int, offset in
 }
 
 func (r *SFTP) openReader(_ context.Context, h backend.Handle, length int, offset int64) (io.ReadCloser, error) {
-	f, err := r.c.Open(r.Filename(h))
+	conn, err := r.pool.get()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := conn.c.Open(r.Filename(h))
 	if err != nil {
+		r.pool.put(conn, err)
 		return nil, errors.Wrapf(err, "Open %v", r.Filename(h))
 	}
 
@@ -476,26 +500,61 @@ func (r *SFTP) openReader(_ context.Context, h backend.Handle, length int, offse
 		_, err = f.Seek(offset, 0)
 		if err != nil {
 			_ = f.Close()
+			r.pool.put(conn, err)
 			return nil, errors.Wrapf(err, "Seek %v", r.Filename(h))
 		}
 	}
 
+	// the connection is returned to the pool once the file is closed
+	rd := &pooledFile{File: f, pool: r.pool, conn: conn}
 	if length > 0 {
 		// unlimited reads usually use io.Copy which needs WriteTo support at the underlying reader
 		// limited reads are usually combined with io.ReadFull which reads all required bytes into a buffer in one go
-		return util.LimitReadCloser(f, int64(length)), nil
+		return util.LimitReadCloser(rd, int64(length)), nil
 	}
 
-	return f, nil
+	return rd, nil
 }
 
-// Stat returns information about a blob.
-func (r *SFTP) Stat(_ context.Context, h backend.Handle) (backend.FileInfo, error) {
-	if err := r.clientError(); err != nil {
-		return backend.FileInfo{}, err
+// pooledFile returns the connection used to read the file to the pool on Close.
+type pooledFile struct {
+	*sftp.File
+	pool *connectionPool
+	conn *connection
+	// the last error returned by a read, used to detect lost connections
+	err error
+}
+
+func (f *pooledFile) Read(p []byte) (int, error) {
+	n, err := f.File.Read(p)
+	if err != nil && err != io.EOF {
+		f.err = err
 	}
+	return n, err
+}
+
+func (f *pooledFile) WriteTo(w io.Writer) (int64, error) {
+	n, err := f.File.WriteTo(w)
+	if err != nil {
+		f.err = err
+	}
+	return n, err
+}
 
-	fi, err := r.c.Lstat(r.Filename(h))
+func (f *pooledFile) Close() error {
+	err := f.File.Close()
+	f.pool.put(f.conn, f.err)
+	return err
+}
+
+// Stat returns information about a blob.
+func (r *SFTP) Stat(_ context.Context, h backend.Handle) (backend.FileInfo, error) {
+	var fi os.FileInfo
+	err := r.withClient(func(c *sftp.Client) error {
+		var err error
+		fi, err = c.Lstat(r.Filename(h))
+		return err
+	})
 	if err != nil {
 		return backend.FileInfo{}, errors.Wrapf(err, "Lstat %v", r.Filename(h))
 	}
@@ -505,18 +564,18 @@ func (r *SFTP) Stat(_ context.Context, h backend.Handle) (backend.FileInfo, erro
 
 // Remove removes the content stored at name.
 func (r *SFTP) Remove(_ context.Context, h backend.Handle) error {
-	if err := r.clientError(); err != nil {
-		return err
-	}
-
-	path := r.Filename(h)
+	return r.withClient(func(c *sftp.Client) error {
+		return r.remove(c, r.Filename(h))
+	})
+}
 
+func (r *SFTP) remove(c *sftp.Client, path string) error {
 	if r.chmodBeforeRemove.Load() {
-		return r.removeWithChmod(path)
+		return r.removeWithChmod(c, path)
 	}
 
 	// optimistically try to remove the file
-	err := r.c.Remove(path)
+	err := c.Remove(path)
 	if err == nil {
 		return nil
 	}
@@ -526,31 +585,33 @@ func (r *SFTP) Remove(_ context.Context, h backend.Handle) error {
 
 	// fallback to chmod + remove
 	// this is necessary on Windows where read-only files cannot be deleted without chmod.
-	if err := r.removeWithChmod(path); err != nil {
+	if err := r.removeWithChmod(c, path); err != nil {
 		return err
 	}
 	r.chmodBeforeRemove.Store(true)
 	return nil
 }
 
-func (r *SFTP) removeWithChmod(path string) error {
-	err := r.c.Chmod(path, r.Modes.File)
+func (r *SFTP) removeWithChmod(c *sftp.Client, path string) error {
+	err := c.Chmod(path, r.Modes.File)
 	if err != nil {
 		return errors.Wrapf(err, "Chmod %v", path)
 	}
 
-	return errors.Wrapf(r.c.Remove(path), "Remove %v", path)
+	return errors.Wrapf(c.Remove(path), "Remove %v", path)
 }
 
 // List runs fn for each file in the backend which has the type t. When an
 // error occurs (or fn returns an error), List stops and returns it.
 func (r *SFTP) List(ctx context.Context, t backend.FileType, fn func(backend.FileInfo) error) error {
-	if err := r.clientError(); err != nil {
-		return err
-	}
+	return r.withClient(func(c *sftp.Client) error {
+		return r.list(ctx, c, t, fn)
+	})
+}
 
+func (r *SFTP) list(ctx context.Context, c *sftp.Client, t backend.FileType, fn func(backend.FileInfo) error) error {
 	basedir, subdirs := r.Basedir(t)
-	walker := r.c.Walk(basedir)
+	walker := c.Walk(basedir)
 	for {
 		ok := walker.Step()
 		if !ok {
@@ -603,35 +664,17 @@ func (r *SFTP) List(ctx context.Context, t backend.FileType, fn func(backend.Fil
 	return ctx.Err()
 }
 
-var closeTimeout = 2 * time.Second
-
-// Close closes the sftp connection and terminates the underlying command.
+// Close closes all sftp connections and terminates the underlying commands.
 func (r *SFTP) Close() error {
 	if r == nil {
 		return nil
 	}
 
-	err := errors.Wrap(r.c.Close(), "Close")
-	debug.Log("Close returned error %v", err)
-
-	// wait for closeTimeout before killing the process
-	select {
-	case err := <-r.result:
-		return err
-	case <-time.After(closeTimeout):
-	}
-
-	if err := r.cmd.Process.Kill(); err != nil {
-		return err
-	}
-
-	// get the error, but ignore it
-	<-r.result
-	return nil
+	return r.pool.close()
 }
 
-func (r *SFTP) deleteRecursive(ctx context.Context, name string) error {
-	entries, err := r.c.ReadDir(name)
+func (r *SFTP) deleteRecursive(ctx context.Context, c *sftp.Client, name string) error {
+	entries, err := c.ReadDir(name)
 	if err != nil {
 		return errors.Wrapf(err, "ReadDir %v", name)
 	}
@@ -643,12 +686,12 @@ func (r *SFTP) deleteRecursive(ctx context.Context, name string) error {
 
 		itemName := path.Join(name, fi.Name())
 		if fi.IsDir() {
-			err := r.deleteRecursive(ctx, itemName)
+			err := r.deleteRecursive(ctx, c, itemName)
 			if err != nil {
 				return err
 			}
 
-			err = r.c.RemoveDirectory(itemName)
+			err = c.RemoveDirectory(itemName)
 			if err != nil {
 				return errors.Wrapf(err, "RemoveDirectory %v", itemName)
 			}
@@ -656,7 +699,7 @@ func (r *SFTP) deleteRecursive(ctx context.Context, name string) error {
 			continue
 		}
 
-		err := r.c.Remove(itemName)
+		err := c.Remove(itemName)
 		if err != nil {
 			return errors.Wrapf(err, "Remove %v", itemName)
 		}
@@ -667,7 +710,9 @@ func (r *SFTP) deleteRecursive(ctx context.Context, name string) error {
 
 // Delete removes all data in the backend.
 func (r *SFTP) Delete(ctx context.Context) error {
-	return r.deleteRecursive(ctx, r.p)
+	return r.withClient(func(c *sftp.Client) error {
+		return r.deleteRecursive(ctx, c, r.p)
+	})
 }
 
 // Warmup not implemented
@@ -3,8 +3,10 @@ package swift
 import (
 	"os"
 	"strings"
+	"time"
 
 	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/limiter"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/options"
 )
@@ -36,11 +38,18 @@ type Config struct {
 	Prefix                 string
 	DefaultContainerPolicy string
 
-	Connections uint `option:"connections" help:"set a limit for the number of concurrent connections (default: 5)"`
+	Connections uint          `option:"connections" help:"set a limit for the number of concurrent connections (default: 5)"`
+	LockExpiry  time.Duration `option:"lock-expiry" help:"let Swift delete lock files after this duration, which removes stale locks of crashed processes (at least 30m, default: disabled)"`
 }
 
+// minLockExpiry is the minimum value for the lock-expiry option. Lock files
+// are only considered stale by restic after 30 minutes, thus deleting them
+// earlier could remove the lock of a running process.
+const minLockExpiry = 30 * time.Minute
+
 func init() {
 	options.Register("swift", Config{})
+	options.Register("swift", limiter.BackendOptions{})
 }
 
 // NewConfig returns a new config with the default values filled in.
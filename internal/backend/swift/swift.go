@@ -28,6 +28,7 @@ import (
 type beSwift struct {
 	conn        *swift.Connection
 	connections uint
+	lockExpiry  time.Duration
 	container   string // Container name
 	prefix      string // Prefix of object names in the container
 	layout.Layout
@@ -45,6 +46,14 @@ func NewFactory() location.Factory {
 func Open(ctx context.Context, cfg Config, rt http.RoundTripper, _ func(string, ...interface{})) (backend.Backend, error) {
 	debug.Log("config %#v", cfg)
 
+	if cfg.LockExpiry != 0 && cfg.LockExpiry < minLockExpiry {
+		return nil, errors.Fatalf("invalid lock expiry %v, must be at least %v", cfg.LockExpiry, minLockExpiry)
+	}
+	hasCredential := cfg.ApplicationCredentialID != "" || cfg.ApplicationCredentialName != ""
+	if hasCredential != (cfg.ApplicationCredentialSecret.String() != "") {
+		return nil, errors.Fatal("application credential authentication requires OS_APPLICATION_CREDENTIAL_SECRET and either OS_APPLICATION_CREDENTIAL_ID or OS_APPLICATION_CREDENTIAL_NAME")
+	}
+
 	be := &beSwift{
 		conn: &swift.Connection{
 			UserName:                    cfg.UserName,
@@ -70,6 +79,7 @@ func Open(ctx context.Context, cfg Config, rt http.RoundTripper, _ func(string,
 			Transport: rt,
 		},
 		connections: cfg.Connections,
+		lockExpiry:  cfg.LockExpiry,
 		container:   cfg.Container,
 		prefix:      cfg.Prefix,
 		Layout:      layout.NewDefaultLayout(cfg.Prefix, path.Join),
@@ -167,6 +177,10 @@ func (be *beSwift) Save(ctx context.Context, h backend.Handle, rd backend.Rewind
 	encoding := "binary/octet-stream"
 
 	hdr := swift.Headers{"Content-Length": strconv.FormatInt(rd.Length(), 10)}
+	if h.Type == backend.LockFile && be.lockExpiry > 0 {
+		// let swift remove locks which are left behind by a crashed process
+		hdr["X-Delete-After"] = strconv.FormatInt(int64(be.lockExpiry/time.Second), 10)
+	}
 	_, err := be.conn.ObjectPut(ctx,
 		be.container, objName, rd, true, hex.EncodeToString(rd.Hash()),
 		encoding, hdr)
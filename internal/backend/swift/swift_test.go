@@ -1,14 +1,22 @@
 package swift_test
 
 import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
 	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/restic/restic/internal/backend"
 	"github.com/restic/restic/internal/backend/swift"
 	"github.com/restic/restic/internal/backend/test"
+	"github.com/restic/restic/internal/options"
 	rtest "github.com/restic/restic/internal/test"
 )
 
@@ -75,3 +83,56 @@ func BenchmarkBackendSwift(t *testing.B) {
 	t.Logf("run tests")
 	newSwiftTestSuite(t).RunBenchmarks(t)
 }
+
+func TestLockExpiry(t *testing.T) {
+	var mu sync.Mutex
+	deleteAfter := make(map[string]string)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodHead:
+			// container exists
+			w.Header().Set("X-Container-Bytes-Used", "0")
+			w.Header().Set("X-Container-Object-Count", "0")
+			w.WriteHeader(http.StatusNoContent)
+		case http.MethodPut:
+			buf, err := io.ReadAll(r.Body)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			mu.Lock()
+			deleteAfter[r.URL.Path] = r.Header.Get("X-Delete-After")
+			mu.Unlock()
+			hash := md5.Sum(buf)
+			w.Header().Set("Etag", hex.EncodeToString(hash[:]))
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer srv.Close()
+
+	cfg := swift.NewConfig()
+	cfg.StorageURL = srv.URL
+	cfg.AuthToken = options.NewSecretString("token")
+	cfg.Container = "container"
+	cfg.Prefix = "repo"
+	cfg.LockExpiry = time.Hour
+
+	be, err := swift.Open(context.TODO(), cfg, http.DefaultTransport, nil)
+	rtest.OK(t, err)
+
+	buf := []byte("data")
+	for _, tpe := range []backend.FileType{backend.LockFile, backend.SnapshotFile} {
+		rtest.OK(t, be.Save(context.TODO(), backend.Handle{Type: tpe, Name: "abcd"}, backend.NewByteReader(buf, be.Hasher())))
+	}
+
+	rtest.Equals(t, map[string]string{
+		"/container/repo/locks/abcd":     "3600",
+		"/container/repo/snapshots/abcd": "",
+	}, deleteAfter)
+
+	cfg.LockExpiry = time.Minute
+	_, err = swift.Open(context.TODO(), cfg, http.DefaultTransport, nil)
+	rtest.Assert(t, err != nil, "expected error for too short lock expiry")
+}
@@ -343,7 +343,7 @@ func TestCheckerModifiedData(t *testing.T) {
 			&errorOnceBackend{Backend: be},
 			func() {},
 			func(t *testing.T, err error) {
-				if !strings.Contains(err.Error(), "check successful on second attempt, original error pack") {
+				if !strings.Contains(err.Error(), "check successful on attempt 2, original error pack") {
 					t.Fatalf("wrong error found, got %v", err)
 				}
 			},
@@ -0,0 +1,116 @@
+package checker
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+)
+
+// VerificationState records when the data of each pack was last read
+// successfully. This allows spreading the verification of all data in a
+// repository across several runs of check.
+type VerificationState struct {
+	m     sync.Mutex
+	packs map[restic.ID]time.Time
+}
+
+type verificationStateFile struct {
+	Packs map[string]time.Time `json:"packs"`
+}
+
+// LoadVerificationState loads the state from filename. A missing file results
+// in an empty state.
+func LoadVerificationState(filename string) (*VerificationState, error) {
+	s := &VerificationState{packs: make(map[restic.ID]time.Time)}
+
+	buf, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		debug.Log("no verification state at %v", filename)
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var f verificationStateFile
+	if err := json.Unmarshal(buf, &f); err != nil {
+		return nil, errors.Wrapf(err, "invalid verification state %v", filename)
+	}
+	for k, t := range f.Packs {
+		id, err := restic.ParseID(k)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid verification state %v", filename)
+		}
+		s.packs[id] = t
+	}
+	return s, nil
+}
+
+// Verified records that the pack id was read successfully at time t. It can be
+// called concurrently.
+func (s *VerificationState) Verified(id restic.ID, t time.Time) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.packs[id] = t
+}
+
+// Stale returns the packs which were not read successfully since cutoff.
+func (s *VerificationState) Stale(packs map[restic.ID]int64, cutoff time.Time) map[restic.ID]int64 {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	stale := make(map[restic.ID]int64)
+	for id, size := range packs {
+		if t, ok := s.packs[id]; ok && !t.Before(cutoff) {
+			continue
+		}
+		stale[id] = size
+	}
+	return stale
+}
+
+// Save stores the state in filename. Packs which are not contained in packs,
+// for example because they were removed by prune, are dropped.
+func (s *VerificationState) Save(filename string, packs map[restic.ID]int64) error {
+	s.m.Lock()
+	f := verificationStateFile{Packs: make(map[string]time.Time, len(s.packs))}
+	for id, t := range s.packs {
+		if _, ok := packs[id]; ok {
+			f.Packs[id.String()] = t
+		}
+	}
+	s.m.Unlock()
+
+	buf, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+		return err
+	}
+
+	// replace the state atomically to not lose it if check is interrupted
+	tmp, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".tmp-")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(buf)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filename)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package checker_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/checker"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestVerificationState(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "state", "check-state.json")
+
+	state, err := checker.LoadVerificationState(filename)
+	rtest.OK(t, err)
+
+	id1, id2, id3 := restic.NewRandomID(), restic.NewRandomID(), restic.NewRandomID()
+	packs := map[restic.ID]int64{id1: 10, id2: 20, id3: 30}
+	rtest.Equals(t, packs, state.Stale(packs, time.Now()))
+
+	now := time.Now()
+	state.Verified(id1, now.Add(-48*time.Hour))
+	state.Verified(id2, now)
+	rtest.Equals(t, map[restic.ID]int64{id1: 10, id3: 30}, state.Stale(packs, now.Add(-24*time.Hour)))
+
+	// id1 was removed from the repository
+	delete(packs, id1)
+	rtest.OK(t, state.Save(filename, packs))
+
+	state, err = checker.LoadVerificationState(filename)
+	rtest.OK(t, err)
+	rtest.Equals(t, map[restic.ID]int64{id1: 10, id3: 30},
+		state.Stale(map[restic.ID]int64{id1: 10, id2: 20, id3: 30}, now.Add(-time.Hour)))
+}
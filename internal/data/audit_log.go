@@ -0,0 +1,397 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+)
+
+// AuditLogInit is the operation of the first entry of an audit log.
+const AuditLogInit = "init"
+
+// AuditLogEntry records an operation which modified the repository. Each entry
+// references the newest entries at the time it was created. As entries are
+// encrypted and authenticated using the repository key and the ID of an entry
+// is the hash of its content, the entries form a hash chain: modifying or
+// removing an entry breaks the references of the following entries. The
+// entries are not signed, thus anyone with access to the repository key can
+// create valid entries.
+type AuditLogEntry struct {
+	Time      time.Time `json:"time"`
+	Operation string    `json:"operation"`
+	// Sequence is zero for the initial entry and is incremented for each
+	// following entry.
+	Sequence  uint64     `json:"sequence"`
+	Hostname  string     `json:"hostname,omitempty"`
+	Username  string     `json:"username,omitempty"`
+	Snapshots restic.IDs `json:"snapshots,omitempty"`
+	Keys      restic.IDs `json:"keys,omitempty"`
+	Message   string     `json:"message,omitempty"`
+	Previous  restic.IDs `json:"previous,omitempty"`
+
+	id *restic.ID
+}
+
+// AuditLogRepository is the interface required to read and append to the
+// audit log.
+type AuditLogRepository interface {
+	restic.ListerLoaderUnpacked
+	restic.SaverUnpacked[restic.WriteableFileType]
+	Config() restic.Config
+}
+
+// AuditLogHead identifies the newest entry of the audit log which was seen
+// by the current host. As removing the newest entries does not break the hash
+// chain, the head is stored outside of the repository to detect this.
+type AuditLogHead struct {
+	ID       restic.ID `json:"id"`
+	Sequence uint64    `json:"sequence"`
+}
+
+// NewAuditLogEntry returns an entry for the operation by the current user.
+func NewAuditLogEntry(operation string) *AuditLogEntry {
+	e := &AuditLogEntry{
+		Time:      time.Now(),
+		Operation: operation,
+	}
+	e.Hostname, _ = os.Hostname()
+	if usr, err := user.Current(); err == nil {
+		e.Username = usr.Username
+	}
+	return e
+}
+
+// ID returns the entry's ID.
+func (e *AuditLogEntry) ID() *restic.ID {
+	return e.id
+}
+
+// LoadAuditLogEntry loads the audit log entry with the given id.
+func LoadAuditLogEntry(ctx context.Context, loader restic.LoaderUnpacked, id restic.ID) (*AuditLogEntry, error) {
+	e := &AuditLogEntry{id: &id}
+	err := restic.LoadJSONUnpacked(ctx, loader, restic.AuditLogFile, id, e)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load audit log entry %v: %w", id.Str(), err)
+	}
+	return e, nil
+}
+
+// LoadAuditLog loads all audit log entries sorted by time.
+func LoadAuditLog(ctx context.Context, repo restic.ListerLoaderUnpacked) ([]*AuditLogEntry, error) {
+	var m sync.Mutex
+	var entries []*AuditLogEntry
+
+	err := restic.ParallelList(ctx, repo, restic.AuditLogFile, repo.Connections(), func(ctx context.Context, id restic.ID, _ int64) error {
+		e, err := LoadAuditLogEntry(ctx, repo, id)
+		if err != nil {
+			return err
+		}
+		m.Lock()
+		defer m.Unlock()
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].Time.Equal(entries[j].Time) {
+			return entries[i].Time.Before(entries[j].Time)
+		}
+		return entries[i].id.String() < entries[j].id.String()
+	})
+	return entries, nil
+}
+
+// AuditLogHeads returns the IDs of the entries which are not referenced by
+// another entry.
+func AuditLogHeads(entries []*AuditLogEntry) restic.IDs {
+	heads := restic.NewIDSet()
+	for _, e := range entries {
+		heads.Insert(*e.id)
+	}
+	for _, e := range entries {
+		for _, id := range e.Previous {
+			heads.Delete(id)
+		}
+	}
+	return heads.List()
+}
+
+// NewestAuditLogEntry returns the entry with the highest sequence number or
+// nil if there are no entries.
+func NewestAuditLogEntry(entries []*AuditLogEntry) *AuditLogEntry {
+	var newest *AuditLogEntry
+	for _, e := range entries {
+		if newest == nil || e.Sequence > newest.Sequence {
+			newest = e
+		}
+	}
+	return newest
+}
+
+// InitAuditLog saves the first entry of the audit log. The audit log must be
+// marked as enabled in the config afterwards. If the repository already
+// contains entries, for example because an earlier run was interrupted before
+// the config was changed, the ID of the initial entry is returned instead.
+func InitAuditLog(ctx context.Context, repo AuditLogRepository, e *AuditLogEntry) (restic.ID, error) {
+	if repo.Config().AuditLog {
+		return restic.ID{}, errors.Fatal("the audit log is already enabled")
+	}
+
+	entries, err := LoadAuditLog(ctx, repo)
+	if err != nil {
+		return restic.ID{}, err
+	}
+	for _, e := range entries {
+		if len(e.Previous) == 0 {
+			return *e.id, nil
+		}
+	}
+
+	e.Operation = AuditLogInit
+	e.Sequence = 0
+	e.Previous = nil
+	return saveAuditLogEntry(ctx, repo, e)
+}
+
+// AppendAuditLog appends the entry to the audit log. If the audit log is not
+// enabled for the repository, nothing is saved and a null ID is returned. If
+// the audit log is enabled but contains no entries or head is not nil and the
+// audit log no longer contains an entry at least as new as head, an error is
+// returned and nothing is saved.
+func AppendAuditLog(ctx context.Context, repo AuditLogRepository, e *AuditLogEntry, head *AuditLogHead) (restic.ID, error) {
+	entries, err := LoadAuditLog(ctx, repo)
+	if err != nil {
+		return restic.ID{}, err
+	}
+	if !repo.Config().AuditLog {
+		if len(entries) != 0 {
+			return restic.ID{}, errors.New("the repository contains audit log entries, but the audit log is not enabled in the config, run `restic audit-log init`")
+		}
+		return restic.ID{}, nil
+	}
+	if err := CheckAuditLogHead(entries, head); err != nil {
+		return restic.ID{}, err
+	}
+
+	e.Sequence = NewestAuditLogEntry(entries).Sequence + 1
+	e.Previous = AuditLogHeads(entries)
+	return saveAuditLogEntry(ctx, repo, e)
+}
+
+// CheckAuditLogHead returns an error if the audit log contains no entries or,
+// if head is not nil, no entry whose sequence number is at least the one of
+// head. The sequence number is checked instead of the ID of head, as the
+// latter changes when the audit log is re-encrypted.
+func CheckAuditLogHead(entries []*AuditLogEntry, head *AuditLogHead) error {
+	newest := NewestAuditLogEntry(entries)
+	if newest == nil {
+		return errors.New("the audit log is enabled, but contains no entries, it was removed")
+	}
+	if head != nil && newest.Sequence < head.Sequence {
+		return errors.Errorf("the newest entry of the audit log has sequence number %d, but entry %v with sequence number %d was seen before, newer entries were removed",
+			newest.Sequence, head.ID.Str(), head.Sequence)
+	}
+	return nil
+}
+
+// LoadAuditLogHead loads the head from filename. A missing file results in a
+// nil head.
+func LoadAuditLogHead(filename string) (*AuditLogHead, error) {
+	buf, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var head AuditLogHead
+	if err := json.Unmarshal(buf, &head); err != nil {
+		return nil, errors.Wrapf(err, "invalid audit log head %v", filename)
+	}
+	return &head, nil
+}
+
+// SaveAuditLogHead stores the head in filename, unless filename already
+// contains a head with a higher sequence number.
+func SaveAuditLogHead(filename string, head AuditLogHead) error {
+	old, err := LoadAuditLogHead(filename)
+	if err != nil {
+		return err
+	}
+	if old != nil && old.Sequence > head.Sequence {
+		return nil
+	}
+
+	buf, err := json.Marshal(head)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+		return err
+	}
+
+	// replace the head atomically to not lose it if restic is interrupted
+	tmp, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".tmp-")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(buf)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filename)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+func saveAuditLogEntry(ctx context.Context, repo AuditLogRepository, e *AuditLogEntry) (restic.ID, error) {
+	id, err := restic.SaveJSONUnpacked(ctx, repo, restic.WriteableAuditLogFile, e)
+	if err != nil {
+		return restic.ID{}, err
+	}
+	e.id = &id
+	return id, nil
+}
+
+// AuditLogReencrypter is the interface required to re-encrypt the audit log
+// during a rotation of the master key.
+type AuditLogReencrypter interface {
+	AuditLogRepository
+	restic.RemoverUnpacked[restic.WriteableFileType]
+	EncryptedWithPreviousKey(ctx context.Context, t restic.FileType, id restic.ID) (bool, error)
+}
+
+// ReencryptAuditLog saves the entries which are still encrypted using the
+// previous master key again. As this changes their IDs, all following entries
+// are saved again as well to update their references. References to snapshots
+// are updated using the map of old to new snapshot IDs. If an earlier run was
+// interrupted, the entries it already saved are reused.
+func ReencryptAuditLog(ctx context.Context, repo AuditLogReencrypter, snapshots map[restic.ID]restic.ID) error {
+	entries, err := LoadAuditLog(ctx, repo)
+	if err != nil {
+		return err
+	}
+
+	previousKey := restic.NewIDSet()
+	current := make(map[string]restic.ID)
+	for _, e := range entries {
+		previous, err := repo.EncryptedWithPreviousKey(ctx, restic.AuditLogFile, *e.id)
+		if err != nil {
+			return err
+		}
+		if previous {
+			previousKey.Insert(*e.id)
+			continue
+		}
+		buf, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		current[string(buf)] = *e.id
+	}
+	if len(previousKey) == 0 {
+		return nil
+	}
+
+	// entries are sorted by time, thus predecessors are processed first
+	ids := make(map[restic.ID]restic.ID)
+	obsolete := restic.NewIDSet()
+	for _, e := range entries {
+		changed := previousKey.Has(*e.id)
+		newEntry := *e
+		newEntry.Previous = remapIDs(e.Previous, ids, &changed)
+		newEntry.Snapshots = remapIDs(e.Snapshots, snapshots, &changed)
+		if !changed {
+			continue
+		}
+
+		buf, err := json.Marshal(&newEntry)
+		if err != nil {
+			return err
+		}
+		id, ok := current[string(buf)]
+		if !ok {
+			id, err = saveAuditLogEntry(ctx, repo, &newEntry)
+			if err != nil {
+				return err
+			}
+		}
+		ids[*e.id] = id
+		obsolete.Insert(*e.id)
+	}
+
+	return restic.ParallelRemove(ctx, repo, obsolete, restic.WriteableAuditLogFile, nil, restic.NoopCounter)
+}
+
+// remapIDs replaces the IDs contained in m and sets changed if an ID was
+// replaced.
+func remapIDs(ids restic.IDs, m map[restic.ID]restic.ID, changed *bool) restic.IDs {
+	if len(ids) == 0 {
+		return ids
+	}
+	result := make(restic.IDs, 0, len(ids))
+	for _, id := range ids {
+		if newID, ok := m[id]; ok {
+			id = newID
+			*changed = true
+		}
+		result = append(result, id)
+	}
+	return result
+}
+
+// VerifyAuditLog checks that the entries form an unbroken chain which starts at
+// a single initial entry and that the sequence numbers increase along the
+// chain. It returns all problems which were found.
+func VerifyAuditLog(entries []*AuditLogEntry) []error {
+	var errs []error
+
+	byID := make(map[restic.ID]*AuditLogEntry, len(entries))
+	for _, e := range entries {
+		byID[*e.id] = e
+	}
+
+	var initial restic.IDs
+	for _, e := range entries {
+		if len(e.Previous) == 0 {
+			initial = append(initial, *e.id)
+			if e.Operation != AuditLogInit {
+				errs = append(errs, errors.Errorf("entry %v of operation %q has no predecessor", e.id.Str(), e.Operation))
+			}
+		}
+		for _, prev := range e.Previous {
+			p, ok := byID[prev]
+			if !ok {
+				errs = append(errs, errors.Errorf("entry %v references missing entry %v", e.id.Str(), prev.Str()))
+				continue
+			}
+			if p.Sequence >= e.Sequence {
+				errs = append(errs, errors.Errorf("entry %v has sequence number %d, but its predecessor %v has sequence number %d",
+					e.id.Str(), e.Sequence, prev.Str(), p.Sequence))
+			}
+		}
+	}
+
+	if len(entries) != 0 && len(initial) != 1 {
+		errs = append(errs, errors.Errorf("expected one initial entry, found %d: %v", len(initial), initial))
+	}
+	return errs
+}
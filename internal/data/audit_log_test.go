@@ -0,0 +1,121 @@
+package data_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/data"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestAuditLog(t *testing.T) {
+	repo, _, be := repository.TestRepositoryWithVersion(t, 0)
+
+	// appending is a no-op as long as the audit log is not enabled
+	id, err := data.AppendAuditLog(context.TODO(), repo, data.NewAuditLogEntry("backup"), nil)
+	rtest.OK(t, err)
+	rtest.Assert(t, id.IsNull(), "expected no entry, got %v", id)
+
+	initID, err := data.InitAuditLog(context.TODO(), repo, data.NewAuditLogEntry(""))
+	rtest.OK(t, err)
+	// entries without the audit log being enabled in the config are rejected
+	_, err = data.AppendAuditLog(context.TODO(), repo, data.NewAuditLogEntry("backup"), nil)
+	rtest.Assert(t, err != nil, "expected error for audit log which is not enabled in the config")
+	// an interrupted initialization is continued
+	id, err = data.InitAuditLog(context.TODO(), repo, data.NewAuditLogEntry(""))
+	rtest.OK(t, err)
+	rtest.Equals(t, initID, id)
+	rtest.OK(t, repo.EnableAuditLog(context.TODO()))
+	_, err = data.InitAuditLog(context.TODO(), repo, data.NewAuditLogEntry(""))
+	rtest.Assert(t, err != nil, "expected error for duplicate initialization")
+
+	backupID, err := data.AppendAuditLog(context.TODO(), repo, data.NewAuditLogEntry("backup"), nil)
+	rtest.OK(t, err)
+	forgetEntry := data.NewAuditLogEntry("forget")
+	forgetID, err := data.AppendAuditLog(context.TODO(), repo, forgetEntry, &data.AuditLogHead{ID: backupID, Sequence: 1})
+	rtest.OK(t, err)
+	rtest.Equals(t, uint64(2), forgetEntry.Sequence)
+
+	entries, err := data.LoadAuditLog(context.TODO(), repo)
+	rtest.OK(t, err)
+	rtest.Equals(t, 3, len(entries))
+	rtest.Equals(t, restic.IDs{initID}, entries[1].Previous)
+	rtest.Equals(t, restic.IDs{backupID}, entries[2].Previous)
+	rtest.Equals(t, restic.IDs{forgetID}, data.AuditLogHeads(entries))
+	rtest.Equals(t, 0, len(data.VerifyAuditLog(entries)))
+
+	// removing an entry from the middle of the chain must be detected
+	rtest.OK(t, be.Remove(context.TODO(), backend.Handle{Type: backend.AuditLogFile, Name: backupID.String()}))
+	entries, err = data.LoadAuditLog(context.TODO(), repo)
+	rtest.OK(t, err)
+	rtest.Equals(t, 1, len(data.VerifyAuditLog(entries)))
+
+	// removing the newest entry is only detected using the head
+	rtest.OK(t, be.Remove(context.TODO(), backend.Handle{Type: backend.AuditLogFile, Name: forgetID.String()}))
+	entries, err = data.LoadAuditLog(context.TODO(), repo)
+	rtest.OK(t, err)
+	rtest.OK(t, data.CheckAuditLogHead(entries, nil))
+	head := &data.AuditLogHead{ID: forgetID, Sequence: 2}
+	rtest.Assert(t, data.CheckAuditLogHead(entries, head) != nil, "expected error for removed head")
+	_, err = data.AppendAuditLog(context.TODO(), repo, data.NewAuditLogEntry("prune"), head)
+	rtest.Assert(t, err != nil, "expected append to fail for removed head")
+
+	// removing all entries must be detected
+	rtest.OK(t, be.Remove(context.TODO(), backend.Handle{Type: backend.AuditLogFile, Name: initID.String()}))
+	_, err = data.AppendAuditLog(context.TODO(), repo, data.NewAuditLogEntry("prune"), nil)
+	rtest.Assert(t, err != nil, "expected append to fail for removed audit log")
+}
+
+func TestAuditLogHead(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "sub", "head.json")
+
+	head, err := data.LoadAuditLogHead(filename)
+	rtest.OK(t, err)
+	rtest.Assert(t, head == nil, "expected no head, got %v", head)
+
+	newest := data.AuditLogHead{ID: restic.NewRandomID(), Sequence: 5}
+	rtest.OK(t, data.SaveAuditLogHead(filename, newest))
+	// an older head does not replace a newer one
+	rtest.OK(t, data.SaveAuditLogHead(filename, data.AuditLogHead{ID: restic.NewRandomID(), Sequence: 4}))
+
+	head, err = data.LoadAuditLogHead(filename)
+	rtest.OK(t, err)
+	rtest.Equals(t, newest, *head)
+}
+
+func TestReencryptAuditLog(t *testing.T) {
+	repo, _, be := repository.TestRepositoryWithVersion(t, 3)
+	ctx := context.TODO()
+
+	snapshotID, err := repo.SaveUnpacked(ctx, restic.WriteableSnapshotFile, []byte(`{"time":"2024-01-01T00:00:00Z","tree":null,"paths":["/"]}`))
+	rtest.OK(t, err)
+	_, err = data.InitAuditLog(ctx, repo, data.NewAuditLogEntry(""))
+	rtest.OK(t, err)
+	rtest.OK(t, repo.EnableAuditLog(ctx))
+	entry := data.NewAuditLogEntry("backup")
+	entry.Snapshots = restic.IDs{snapshotID}
+	_, err = data.AppendAuditLog(ctx, repo, entry, nil)
+	rtest.OK(t, err)
+
+	var snapshots map[restic.ID]restic.ID
+	stats, err := repo.RotateMasterKey(ctx, repository.RotateMasterKeyOptions{
+		ReencryptAuditLog: func(ctx context.Context, m map[restic.ID]restic.ID) error {
+			snapshots = m
+			return data.ReencryptAuditLog(ctx, repo, m)
+		},
+	}, restic.NewNoopPrinter())
+	rtest.OK(t, err)
+	rtest.Assert(t, stats.Finished, "expected finished key rotation")
+
+	// the entries can be loaded without the previous key and still form a chain
+	repo = repository.TestOpenBackend(t, be)
+	entries, err := data.LoadAuditLog(ctx, repo)
+	rtest.OK(t, err)
+	rtest.Equals(t, 2, len(entries))
+	rtest.Equals(t, 0, len(data.VerifyAuditLog(entries)))
+	rtest.Equals(t, restic.IDs{snapshots[snapshotID]}, entries[1].Snapshots)
+}
@@ -0,0 +1,186 @@
+package data
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+// calendarSearchDays limits how far back the previous occurrence of a
+// calendar spec is searched for.
+const calendarSearchDays = 8 * 366
+
+// CalendarSpec is a cron-like schedule with the five fields minute, hour, day
+// of month, month and day of week. Each field is either "*" or a comma
+// separated list of values and ranges, optionally followed by a step, e.g.
+// "0 0 1 1,4,7,10 *" for the start of each quarter.
+type CalendarSpec struct {
+	spec string
+
+	minute, hour, dom, month, dow uint64
+	// set if the day of month or day of week field is "*"
+	domStar, dowStar bool
+}
+
+// ParseCalendarSpec parses a cron-like calendar spec.
+func ParseCalendarSpec(s string) (CalendarSpec, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 5 {
+		return CalendarSpec{}, errors.Errorf("invalid calendar spec %q: expected 5 fields (minute hour day-of-month month day-of-week), got %d", s, len(fields))
+	}
+
+	c := CalendarSpec{
+		spec:    strings.Join(fields, " "),
+		domStar: fields[2] == "*",
+		dowStar: fields[4] == "*",
+	}
+
+	for _, f := range []struct {
+		dst      *uint64
+		name     string
+		min, max int
+	}{
+		{&c.minute, "minute", 0, 59},
+		{&c.hour, "hour", 0, 23},
+		{&c.dom, "day of month", 1, 31},
+		{&c.month, "month", 1, 12},
+		{&c.dow, "day of week", 0, 7},
+	} {
+		bits, err := parseCalendarField(fields[0], f.min, f.max)
+		if err != nil {
+			return CalendarSpec{}, errors.Errorf("invalid calendar spec %q: %v: %v", s, f.name, err)
+		}
+		*f.dst = bits
+		fields = fields[1:]
+	}
+
+	// both 0 and 7 are sunday
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+
+	return c, nil
+}
+
+// parseCalendarField returns a bit set of all values in field.
+func parseCalendarField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepStr)
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q", stepStr)
+			}
+		}
+
+		first, last := min, max
+		if rng != "*" {
+			lo, hi, isRange := strings.Cut(rng, "-")
+			var err error
+			first, err = strconv.Atoi(lo)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value %q", lo)
+			}
+			last = first
+			if isRange {
+				last, err = strconv.Atoi(hi)
+				if err != nil {
+					return 0, fmt.Errorf("invalid value %q", hi)
+				}
+			} else if hasStep {
+				last = max
+			}
+		}
+
+		if first < min || last > max || first > last {
+			return 0, fmt.Errorf("value %q out of range %d-%d", rng, min, max)
+		}
+
+		for v := first; v <= last; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (c CalendarSpec) String() string {
+	return c.spec
+}
+
+// matchesDay returns true if the schedule has an occurrence on day d.
+func (c CalendarSpec) matchesDay(d time.Time) bool {
+	if c.month&(1<<uint(d.Month())) == 0 {
+		return false
+	}
+
+	domMatch := c.dom&(1<<uint(d.Day())) != 0
+	dowMatch := c.dow&(1<<uint(d.Weekday())) != 0
+	// like cron, if both fields are restricted, matching either one is sufficient
+	if !c.domStar && !c.dowStar {
+		return domMatch || dowMatch
+	}
+	return domMatch && dowMatch
+}
+
+// Prev returns the latest occurrence of the schedule which is not after t. If
+// there is no such occurrence within the last years, false is returned.
+func (c CalendarSpec) Prev(t time.Time) (time.Time, bool) {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	for i := 0; i < calendarSearchDays; i++ {
+		if c.matchesDay(day) {
+			for h := 23; h >= 0; h-- {
+				if c.hour&(1<<uint(h)) == 0 {
+					continue
+				}
+				for m := 59; m >= 0; m-- {
+					if c.minute&(1<<uint(m)) == 0 {
+						continue
+					}
+					occ := time.Date(day.Year(), day.Month(), day.Day(), h, m, 0, 0, t.Location())
+					if !occ.After(t) {
+						return occ, true
+					}
+				}
+			}
+		}
+		day = day.AddDate(0, 0, -1)
+	}
+	return time.Time{}, false
+}
+
+// CalendarSpecs consists of several CalendarSpec.
+type CalendarSpecs []CalendarSpec
+
+func (l CalendarSpecs) String() string {
+	return fmt.Sprint([]CalendarSpec(l))
+}
+
+// Set parses s and adds it to the list.
+func (l *CalendarSpecs) Set(s string) error {
+	c, err := ParseCalendarSpec(s)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, c)
+	return nil
+}
+
+// Type returns a description of the type.
+func (CalendarSpecs) Type() string {
+	return "CalendarSpecs"
+}
+
+// MonthlyOnDay returns a calendar spec which matches the start of day in each
+// month.
+func MonthlyOnDay(day int) (CalendarSpec, error) {
+	if day < 1 || day > 31 {
+		return CalendarSpec{}, errors.Errorf("invalid day of month %d, must be between 1 and 31", day)
+	}
+	return ParseCalendarSpec(fmt.Sprintf("0 0 %d * *", day))
+}
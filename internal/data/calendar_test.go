@@ -0,0 +1,69 @@
+package data_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/data"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestCalendarSpecPrev(t *testing.T) {
+	for _, test := range []struct {
+		spec string
+		t    string
+		prev string
+	}{
+		// first day of each quarter
+		{"0 0 1 1,4,7,10 *", "2024-05-17 10:00:00", "2024-04-01 00:00:00"},
+		{"0 0 1 1,4,7,10 *", "2024-04-01 00:00:00", "2024-04-01 00:00:00"},
+		{"0 0 1 */3 *", "2024-03-31 23:59:59", "2024-01-01 00:00:00"},
+		// every sunday at 02:30, both 0 and 7 are sunday
+		{"30 2 * * 0", "2024-05-15 12:00:00", "2024-05-12 02:30:00"},
+		{"30 2 * * 7", "2024-05-12 02:29:00", "2024-05-05 02:30:00"},
+		// every 15 minutes during working hours
+		{"*/15 9-17 * * 1-5", "2024-05-15 12:44:00", "2024-05-15 12:30:00"},
+		{"*/15 9-17 * * 1-5", "2024-05-13 08:00:00", "2024-05-10 17:45:00"},
+		// if both day fields are restricted, either one matches
+		{"0 0 13 * 5", "2024-05-16 00:00:00", "2024-05-13 00:00:00"},
+		{"0 0 13 * 5", "2024-05-18 00:00:00", "2024-05-17 00:00:00"},
+		// leap day
+		{"0 0 29 2 *", "2027-01-01 00:00:00", "2024-02-29 00:00:00"},
+	} {
+		t.Run("", func(t *testing.T) {
+			spec, err := data.ParseCalendarSpec(test.spec)
+			rtest.OK(t, err)
+
+			prev, ok := spec.Prev(parseTimeUTC(test.t))
+			rtest.Assert(t, ok, "no occurrence found for %v before %v", test.spec, test.t)
+			rtest.Equals(t, parseTimeUTC(test.prev), prev)
+		})
+	}
+}
+
+func TestCalendarSpecNoOccurrence(t *testing.T) {
+	spec, err := data.ParseCalendarSpec("0 0 31 2 *")
+	rtest.OK(t, err)
+
+	_, ok := spec.Prev(time.Now())
+	rtest.Assert(t, !ok, "found occurrence for impossible date")
+}
+
+func TestParseCalendarSpecInvalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"0 0 1 *",
+		"0 0 1 * * *",
+		"60 0 1 * *",
+		"0 24 1 * *",
+		"0 0 0 * *",
+		"0 0 1 13 *",
+		"0 0 1 * 8",
+		"0 0 5-1 * *",
+		"0 0 1/0 * *",
+		"0 0 x * *",
+	} {
+		_, err := data.ParseCalendarSpec(spec)
+		rtest.Assert(t, err != nil, "expected error for %q", spec)
+	}
+}
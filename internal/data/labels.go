@@ -0,0 +1,136 @@
+package data
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Labels are key-value pairs attached to a snapshot.
+type Labels map[string]string
+
+// parseLabel splits a string of the form "key=value" into key and value.
+func parseLabel(s string) (key, value string, err error) {
+	key, value, ok := strings.Cut(s, "=")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" || strings.ContainsAny(key, ",!") {
+		return "", "", fmt.Errorf("invalid label %q, must be in the format key=value", s)
+	}
+	return key, strings.TrimSpace(value), nil
+}
+
+func (l Labels) String() string {
+	keys := make([]string, 0, len(l))
+	for key := range l {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for i, key := range keys {
+		keys[i] = key + "=" + l[key]
+	}
+	return "[" + strings.Join(keys, ", ") + "]"
+}
+
+// Set adds the label in the format "key=value". A label which already exists
+// is replaced.
+func (l *Labels) Set(s string) error {
+	key, value, err := parseLabel(s)
+	if err != nil {
+		return err
+	}
+	if *l == nil {
+		*l = make(Labels)
+	}
+	(*l)[key] = value
+	return nil
+}
+
+// Type returns a description of the type.
+func (Labels) Type() string {
+	return "Labels"
+}
+
+// labelRequirement is a single condition of a LabelSelector.
+type labelRequirement struct {
+	key   string
+	value string
+	// negate inverts the comparison, that is the label must not have value.
+	negate bool
+}
+
+func (r labelRequirement) String() string {
+	if r.negate {
+		return r.key + "!=" + r.value
+	}
+	return r.key + "=" + r.value
+}
+
+// matches returns true if the labels fulfill the requirement. A label which
+// does not exist has the empty string as value.
+func (r labelRequirement) matches(labels Labels) bool {
+	return (labels[r.key] == r.value) != r.negate
+}
+
+// LabelSelector is a list of conditions on the labels of a snapshot, all of
+// which must be fulfilled.
+type LabelSelector []labelRequirement
+
+// parseLabelSelector parses a selector in the format "key=value,key!=value".
+func parseLabelSelector(s string) (LabelSelector, error) {
+	var sel LabelSelector
+	for _, item := range strings.Split(s, ",") {
+		var r labelRequirement
+		key, value, ok := strings.Cut(item, "=")
+		if ok && strings.HasSuffix(key, "!") {
+			key = strings.TrimSuffix(key, "!")
+			r.negate = true
+		}
+		r.key = strings.TrimSpace(key)
+		r.value = strings.TrimSpace(value)
+		if !ok || r.key == "" || strings.Contains(r.key, "!") {
+			return nil, fmt.Errorf("invalid label selector %q, must be in the format key=value or key!=value", item)
+		}
+		sel = append(sel, r)
+	}
+	return sel, nil
+}
+
+func (sel LabelSelector) String() string {
+	items := make([]string, 0, len(sel))
+	for _, r := range sel {
+		items = append(items, r.String())
+	}
+	return strings.Join(items, ",")
+}
+
+// Matches returns true if the labels fulfill all conditions of the selector.
+func (sel LabelSelector) Matches(labels Labels) bool {
+	for _, r := range sel {
+		if !r.matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// LabelSelectors consists of several LabelSelector.
+type LabelSelectors []LabelSelector
+
+func (l LabelSelectors) String() string {
+	return fmt.Sprint([]LabelSelector(l))
+}
+
+// Set adds a LabelSelector in the format "key=value,key!=value".
+func (l *LabelSelectors) Set(s string) error {
+	sel, err := parseLabelSelector(s)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, sel)
+	return nil
+}
+
+// Type returns a description of the type.
+func (LabelSelectors) Type() string {
+	return "LabelSelectors"
+}
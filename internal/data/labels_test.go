@@ -0,0 +1,53 @@
+package data
+
+import (
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestLabelsSet(t *testing.T) {
+	var l Labels
+	rtest.OK(t, l.Set("env=prod"))
+	rtest.OK(t, l.Set(" app = db "))
+	rtest.OK(t, l.Set("empty="))
+	rtest.OK(t, l.Set("env=dev"))
+	rtest.Equals(t, Labels{"env": "dev", "app": "db", "empty": ""}, l)
+	rtest.Equals(t, "[app=db, empty=, env=dev]", l.String())
+
+	for _, s := range []string{"", "env", "=prod", "a!=b", "a,b=c"} {
+		rtest.Assert(t, l.Set(s) != nil, "expected error for label %q", s)
+	}
+}
+
+func TestLabelSelectors(t *testing.T) {
+	labels := Labels{"env": "prod", "app": "db"}
+
+	for _, test := range []struct {
+		selectors []string
+		match     bool
+	}{
+		{nil, true},
+		{[]string{"env=prod"}, true},
+		{[]string{"env=dev"}, false},
+		{[]string{"env=prod,app=db"}, true},
+		{[]string{"env=prod,app!=db"}, false},
+		{[]string{"env=prod,app!=cache"}, true},
+		{[]string{"region="}, true},
+		{[]string{"region!="}, false},
+		{[]string{"env=dev", "app=db"}, true},
+		{[]string{"env=dev", "app=cache"}, false},
+	} {
+		var sel LabelSelectors
+		for _, s := range test.selectors {
+			rtest.OK(t, sel.Set(s))
+		}
+		sn := &Snapshot{Labels: labels}
+		rtest.Equals(t, test.match, sn.HasLabelSelectors(sel), sel.String())
+	}
+
+	var sel LabelSelectors
+	for _, s := range []string{"", "env", "=prod", "env=prod,", "a!!=b"} {
+		rtest.Assert(t, sel.Set(s) != nil, "expected error for selector %q", s)
+	}
+}
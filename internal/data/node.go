@@ -47,12 +47,21 @@ const (
 	// TypeSecurityDescriptor is the GenericAttributeType used for storing security descriptors including owner, group, discretionary access control list (DACL), system access control list (SACL)) for windows files within the generic attributes map.
 	TypeSecurityDescriptor GenericAttributeType = "windows.security_descriptor"
 
+	// Below are FreeBSD specific attributes.
+
+	// TypeACLAccess is the GenericAttributeType used for storing the POSIX.1e access ACL for FreeBSD files within the generic attributes map.
+	TypeACLAccess GenericAttributeType = "freebsd.acl_access"
+	// TypeACLDefault is the GenericAttributeType used for storing the POSIX.1e default ACL for FreeBSD directories within the generic attributes map.
+	TypeACLDefault GenericAttributeType = "freebsd.acl_default"
+	// TypeACLNFS4 is the GenericAttributeType used for storing the NFSv4 ACL for FreeBSD files within the generic attributes map.
+	TypeACLNFS4 GenericAttributeType = "freebsd.acl_nfs4"
+
 	// Generic Attributes for other OS types should be defined here.
 )
 
 // init is called when the package is initialized. Any new GenericAttributeTypes being created must be added here as well.
 func init() {
-	storeGenericAttributeType(TypeCreationTime, TypeFileAttributes, TypeSecurityDescriptor)
+	storeGenericAttributeType(TypeCreationTime, TypeFileAttributes, TypeSecurityDescriptor, TypeACLAccess, TypeACLDefault, TypeACLNFS4)
 }
 
 // genericAttributesForOS maintains a map of known genericAttributesForOS to the OSType
@@ -0,0 +1,26 @@
+package data
+
+import (
+	"encoding/json"
+	"reflect"
+	"runtime"
+)
+
+// FreeBSDAttributes are the genericAttributes for FreeBSD
+type FreeBSDAttributes struct {
+	// ACLAccess is used for storing the POSIX.1e access ACL if it is not
+	// equivalent to the file mode.
+	ACLAccess *[]byte `generic:"acl_access"`
+	// ACLDefault is used for storing the POSIX.1e default ACL of directories.
+	ACLDefault *[]byte `generic:"acl_default"`
+	// ACLNFS4 is used for storing the NFSv4 ACL if it is not equivalent to the
+	// file mode.
+	ACLNFS4 *[]byte `generic:"acl_nfs4"`
+}
+
+// FreeBSDAttrsToGenericAttributes converts the FreeBSDAttributes to a generic attributes map using reflection
+func FreeBSDAttrsToGenericAttributes(freebsdAttributes FreeBSDAttributes) (attrs map[GenericAttributeType]json.RawMessage, err error) {
+	// Get the value of the FreeBSDAttributes
+	freebsdAttributesValue := reflect.ValueOf(freebsdAttributes)
+	return OSAttrsToGenericAttributes(reflect.TypeOf(freebsdAttributes), &freebsdAttributesValue, runtime.GOOS)
+}
@@ -24,14 +24,48 @@ type Snapshot struct {
 	GID      uint32     `json:"gid,omitempty"`
 	Excludes []string   `json:"excludes,omitempty"`
 	Tags     []string   `json:"tags,omitempty"`
+	Labels   Labels     `json:"labels,omitempty"`
 	Original *restic.ID `json:"original,omitempty"`
 
+	// ChangeJournal contains the position of the filesystem change journal
+	// for each absolute target path at the start of the backup.
+	ChangeJournal map[string]string `json:"change_journal,omitempty"`
+	// ChangeJournalSelection identifies the options which selected the files
+	// of a backup which recorded ChangeJournal.
+	ChangeJournalSelection string `json:"change_journal_selection,omitempty"`
+	// ChangeJournalErrors lists the absolute paths of the items which could
+	// not be saved completely by a backup which recorded ChangeJournal.
+	ChangeJournalErrors []string `json:"change_journal_errors,omitempty"`
+
+	// Filesystems contains the type of the filesystem, for example "ext4" or
+	// "nfs", for each absolute target path.
+	Filesystems map[string]string `json:"filesystems,omitempty"`
+
+	// FilesystemSnapshots lists the btrfs or ZFS snapshots the data was read
+	// from, if the backup was created using --use-fs-snapshot.
+	FilesystemSnapshots []FilesystemSnapshot `json:"filesystem_snapshots,omitempty"`
+
 	ProgramVersion string           `json:"program_version,omitempty"`
 	Summary        *SnapshotSummary `json:"summary,omitempty"`
 
 	id *restic.ID // plaintext ID, used during restore
 }
 
+// FilesystemSnapshot describes a temporary snapshot of a btrfs subvolume or ZFS
+// dataset which was created to read a consistent state of the files.
+type FilesystemSnapshot struct {
+	// Type is either "btrfs" or "zfs".
+	Type string `json:"type"`
+	// Source is the path of the btrfs subvolume or the name of the ZFS dataset.
+	Source string `json:"source"`
+	// Path is the directory at which the source is mounted.
+	Path string `json:"path"`
+	// Name is the path of the btrfs snapshot or the name of the ZFS snapshot.
+	Name string `json:"name"`
+	// GUID is the UUID of the btrfs snapshot or the GUID of the ZFS snapshot.
+	GUID string `json:"guid,omitempty"`
+}
+
 type SnapshotSummary struct {
 	BackupStart time.Time `json:"backup_start"`
 	BackupEnd   time.Time `json:"backup_end"`
@@ -217,6 +251,23 @@ func (sn *Snapshot) HasTagList(l []TagList) bool {
 	return false
 }
 
+// HasLabelSelectors returns true if either
+//   - the labels of the snapshot fulfill at least one LabelSelector in l, or
+//   - l is empty
+func (sn *Snapshot) HasLabelSelectors(l []LabelSelector) bool {
+	if len(l) == 0 {
+		return true
+	}
+
+	for _, sel := range l {
+		if sel.Matches(sn.Labels) {
+			return true
+		}
+	}
+
+	return false
+}
+
 // HasPaths returns true if the snapshot has all of the paths.
 func (sn *Snapshot) HasPaths(paths []string) bool {
 	m := make(map[string]struct{}, len(sn.Paths))
@@ -14,23 +14,40 @@ import (
 // ErrNoSnapshotFound is returned when no snapshot for the given criteria could be found.
 var ErrNoSnapshotFound = errors.New("no snapshot found")
 
-// A SnapshotFilter denotes a set of snapshots based on hosts, tags and paths.
+// A SnapshotFilter denotes a set of snapshots based on hosts, tags, labels,
+// paths and the time of the snapshots.
 type SnapshotFilter struct {
 	_ struct{} // Force naming fields in literals.
 
-	Hosts []string
-	Tags  TagLists
-	Paths []string
+	Hosts  []string
+	Tags   TagLists
+	Labels LabelSelectors
+	Paths  []string
+	// Match snapshots created after or before the given time. Zero for no limit.
+	NewerThan TimeExpr
+	OlderThan TimeExpr
 	// Match snapshots from before this timestamp. Zero for no limit.
 	TimestampLimit time.Time
 }
 
 func (f *SnapshotFilter) Empty() bool {
-	return len(f.Hosts)+len(f.Tags)+len(f.Paths) == 0
+	return len(f.Hosts)+len(f.Tags)+len(f.Labels)+len(f.Paths) == 0 && f.NewerThan.Zero() && f.OlderThan.Zero()
 }
 
 func (f *SnapshotFilter) matches(sn *Snapshot) bool {
-	return sn.HasHostname(f.Hosts) && sn.HasTagList(f.Tags) && sn.HasPaths(f.Paths)
+	return sn.HasHostname(f.Hosts) && sn.HasTagList(f.Tags) && sn.HasLabelSelectors(f.Labels) && sn.HasPaths(f.Paths) && f.matchesTime(sn, time.Now())
+}
+
+// matchesTime returns true if the snapshot was created within the time window
+// given by NewerThan and OlderThan.
+func (f *SnapshotFilter) matchesTime(sn *Snapshot, now time.Time) bool {
+	if !f.NewerThan.Zero() && !sn.Time.After(f.NewerThan.Time(now)) {
+		return false
+	}
+	if !f.OlderThan.Zero() && !sn.Time.Before(f.OlderThan.Time(now)) {
+		return false
+	}
+	return true
 }
 
 // findLatest finds the latest snapshot with optional target/directory,
@@ -3,6 +3,7 @@ package data_test
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/restic/restic/internal/data"
 	"github.com/restic/restic/internal/repository"
@@ -45,6 +46,43 @@ func TestFindLatestSnapshotWithMaxTimestamp(t *testing.T) {
 	}
 }
 
+func TestFindAllTimeWindow(t *testing.T) {
+	repo := repository.TestRepository(t)
+	data.TestCreateSnapshot(t, repo, parseTimeUTC("2015-05-05 05:05:05"), 1)
+	desiredSnapshot := data.TestCreateSnapshot(t, repo, parseTimeUTC("2017-07-07 07:07:07"), 1)
+	data.TestCreateSnapshot(t, repo, parseTimeUTC("2019-09-09 09:09:09"), 1)
+	recentSnapshot := data.TestCreateSnapshot(t, repo, time.Now().Add(-time.Hour), 1)
+
+	for _, tc := range []struct {
+		newerThan, olderThan string
+		expected             []*data.Snapshot
+	}{
+		{"2016-01-01", "2018-01-01", []*data.Snapshot{desiredSnapshot}},
+		{"2017-07-06", "2017-07-09", []*data.Snapshot{desiredSnapshot}},
+		{"1d", "", []*data.Snapshot{recentSnapshot}},
+		{"", "2015-05-05", nil},
+	} {
+		f := data.SnapshotFilter{}
+		if tc.newerThan != "" {
+			test.OK(t, f.NewerThan.Set(tc.newerThan))
+		}
+		if tc.olderThan != "" {
+			test.OK(t, f.OlderThan.Set(tc.olderThan))
+		}
+
+		var found []*data.Snapshot
+		err := f.FindAll(context.TODO(), repo, repo, nil, func(_ string, sn *data.Snapshot, err error) error {
+			found = append(found, sn)
+			return err
+		})
+		test.OK(t, err)
+		test.Equals(t, len(tc.expected), len(found), "newer than "+tc.newerThan+", older than "+tc.olderThan)
+		for i := range found {
+			test.Equals(t, *tc.expected[i].ID(), *found[i].ID())
+		}
+	}
+}
+
 func TestFindLatestWithSubpath(t *testing.T) {
 	repo := repository.TestRepository(t)
 	data.TestCreateSnapshot(t, repo, parseTimeUTC("2015-05-05 05:05:05"), 1)
@@ -12,19 +12,22 @@ import (
 
 // ExpirePolicy configures which snapshots should be automatically removed.
 type ExpirePolicy struct {
-	Last          int       // keep the last n snapshots
-	Hourly        int       // keep the last n hourly snapshots
-	Daily         int       // keep the last n daily snapshots
-	Weekly        int       // keep the last n weekly snapshots
-	Monthly       int       // keep the last n monthly snapshots
-	Yearly        int       // keep the last n yearly snapshots
-	Within        Duration  // keep snapshots made within this duration
-	WithinHourly  Duration  // keep hourly snapshots made within this duration
-	WithinDaily   Duration  // keep daily snapshots made within this duration
-	WithinWeekly  Duration  // keep weekly snapshots made within this duration
-	WithinMonthly Duration  // keep monthly snapshots made within this duration
-	WithinYearly  Duration  // keep yearly snapshots made within this duration
-	Tags          []TagList // keep all snapshots that include at least one of the tag lists.
+	Last            int            // keep the last n snapshots
+	Hourly          int            // keep the last n hourly snapshots
+	Daily           int            // keep the last n daily snapshots
+	Weekly          int            // keep the last n weekly snapshots
+	Monthly         int            // keep the last n monthly snapshots
+	Quarterly       int            // keep the last n quarterly snapshots
+	Yearly          int            // keep the last n yearly snapshots
+	Within          Duration       // keep snapshots made within this duration
+	WithinHourly    Duration       // keep hourly snapshots made within this duration
+	WithinDaily     Duration       // keep daily snapshots made within this duration
+	WithinWeekly    Duration       // keep weekly snapshots made within this duration
+	WithinMonthly   Duration       // keep monthly snapshots made within this duration
+	WithinQuarterly Duration       // keep quarterly snapshots made within this duration
+	WithinYearly    Duration       // keep yearly snapshots made within this duration
+	Tags            []TagList      // keep all snapshots that include at least one of the tag lists.
+	Calendar        []CalendarSpec // keep the first snapshot made at or after each occurrence of the schedules.
 }
 
 func (e ExpirePolicy) String() (s string) {
@@ -40,6 +43,7 @@ func (e ExpirePolicy) String() (s string) {
 		{e.Daily, "daily"},
 		{e.Weekly, "weekly"},
 		{e.Monthly, "monthly"},
+		{e.Quarterly, "quarterly"},
 		{e.Yearly, "yearly"},
 	} {
 		if opt.count > 0 {
@@ -65,6 +69,10 @@ func (e ExpirePolicy) String() (s string) {
 		keepw = append(keepw, fmt.Sprintf("monthly snapshots within %v", e.WithinMonthly))
 	}
 
+	if !e.WithinQuarterly.Zero() {
+		keepw = append(keepw, fmt.Sprintf("quarterly snapshots within %v", e.WithinQuarterly))
+	}
+
 	if !e.WithinYearly.Zero() {
 		keepw = append(keepw, fmt.Sprintf("yearly snapshots within %v", e.WithinYearly))
 	}
@@ -87,6 +95,13 @@ func (e ExpirePolicy) String() (s string) {
 		s += fmt.Sprintf("all snapshots with tags %s", e.Tags)
 	}
 
+	if len(e.Calendar) > 0 {
+		if s != "" {
+			s += " and "
+		}
+		s += fmt.Sprintf("the first snapshot after each occurrence of %q", e.Calendar)
+	}
+
 	if !e.Within.Zero() {
 		if s != "" {
 			s += " and "
@@ -105,11 +120,11 @@ func (e ExpirePolicy) String() (s string) {
 
 // Empty returns true if no policy has been configured (all values zero).
 func (e ExpirePolicy) Empty() bool {
-	if len(e.Tags) != 0 {
+	if len(e.Tags) != 0 || len(e.Calendar) != 0 {
 		return false
 	}
 
-	empty := ExpirePolicy{Tags: e.Tags}
+	empty := ExpirePolicy{Tags: e.Tags, Calendar: e.Calendar}
 	return reflect.DeepEqual(e, empty)
 }
 
@@ -134,6 +149,11 @@ func ym(d time.Time, _ int) int {
 	return d.Year()*100 + int(d.Month())
 }
 
+// yq returns an integer in the form YYYYQ, where Q is the quarter.
+func yq(d time.Time, _ int) int {
+	return d.Year()*10 + (int(d.Month())-1)/3 + 1
+}
+
 // y returns the year of d.
 func y(d time.Time, _ int) int {
 	return d.Year()
@@ -174,12 +194,13 @@ type KeepReason struct {
 
 	// the counters after evaluating the current snapshot
 	Counters struct {
-		Last    int `json:"last,omitempty"`
-		Hourly  int `json:"hourly,omitempty"`
-		Daily   int `json:"daily,omitempty"`
-		Weekly  int `json:"weekly,omitempty"`
-		Monthly int `json:"monthly,omitempty"`
-		Yearly  int `json:"yearly,omitempty"`
+		Last      int `json:"last,omitempty"`
+		Hourly    int `json:"hourly,omitempty"`
+		Daily     int `json:"daily,omitempty"`
+		Weekly    int `json:"weekly,omitempty"`
+		Monthly   int `json:"monthly,omitempty"`
+		Quarterly int `json:"quarterly,omitempty"`
+		Yearly    int `json:"yearly,omitempty"`
 	} `json:"counters"`
 }
 
@@ -195,7 +216,7 @@ func ApplyPolicy(list Snapshots, p ExpirePolicy) (keep, remove Snapshots, reason
 	}
 
 	// These buckets are for keeping last n snapshots of given type
-	var buckets = [6]struct {
+	var buckets = [7]struct {
 		Count  int
 		bucker func(d time.Time, nr int) int
 		Last   int
@@ -206,11 +227,12 @@ func ApplyPolicy(list Snapshots, p ExpirePolicy) (keep, remove Snapshots, reason
 		{p.Daily, ymd, -1, "daily snapshot"},
 		{p.Weekly, yw, -1, "weekly snapshot"},
 		{p.Monthly, ym, -1, "monthly snapshot"},
+		{p.Quarterly, yq, -1, "quarterly snapshot"},
 		{p.Yearly, y, -1, "yearly snapshot"},
 	}
 
 	// These buckets are for keeping snapshots of given type within duration
-	var bucketsWithin = [5]struct {
+	var bucketsWithin = [6]struct {
 		Within Duration
 		bucker func(d time.Time, nr int) int
 		Last   int
@@ -220,11 +242,23 @@ func ApplyPolicy(list Snapshots, p ExpirePolicy) (keep, remove Snapshots, reason
 		{p.WithinDaily, ymd, -1, "daily within"},
 		{p.WithinWeekly, yw, -1, "weekly within"},
 		{p.WithinMonthly, ym, -1, "monthly within"},
+		{p.WithinQuarterly, yq, -1, "quarterly within"},
 		{p.WithinYearly, y, -1, "yearly within"},
 	}
 
 	latest := findLatestTimestamp(list)
 
+	// For each calendar spec, find the latest occurrence before each snapshot.
+	// A snapshot is the first one after an occurrence if the next older
+	// snapshot was made before that occurrence.
+	occurrences := make([][]time.Time, len(p.Calendar))
+	for i, spec := range p.Calendar {
+		occurrences[i] = make([]time.Time, len(list))
+		for nr, sn := range list {
+			occurrences[i][nr], _ = spec.Prev(sn.Time)
+		}
+	}
+
 	for nr, cur := range list {
 		var keepSnap bool
 		var keepSnapReasons []string
@@ -246,6 +280,18 @@ func ApplyPolicy(list Snapshots, p ExpirePolicy) (keep, remove Snapshots, reason
 			}
 		}
 
+		for i, spec := range p.Calendar {
+			occ := occurrences[i][nr]
+			if occ.IsZero() {
+				continue
+			}
+			if nr == len(list)-1 || !occurrences[i][nr+1].Equal(occ) {
+				debug.Log("keep %v %v, first snapshot after %v (%v)\n", cur.Time, cur.id.Str(), occ, spec)
+				keepSnap = true
+				keepSnapReasons = append(keepSnapReasons, fmt.Sprintf("calendar %v", spec))
+			}
+		}
+
 		// Now update the other buckets and see if they have some counts left.
 		for i, b := range buckets {
 			// -1 means "keep all"
@@ -268,7 +314,7 @@ func ApplyPolicy(list Snapshots, p ExpirePolicy) (keep, remove Snapshots, reason
 			}
 		}
 
-		// If the timestamp is within range, and the snapshot is an hourly/daily/weekly/monthly/yearly snapshot, then keep it
+		// If the timestamp is within range, and the snapshot is an hourly/daily/weekly/monthly/quarterly/yearly snapshot, then keep it
 		for i, b := range bucketsWithin {
 			if !b.Within.Zero() {
 				t := latest.AddDate(-b.Within.Years, -b.Within.Months, -b.Within.Days).Add(time.Hour * time.Duration(-b.Within.Hours))
@@ -299,7 +345,8 @@ func ApplyPolicy(list Snapshots, p ExpirePolicy) (keep, remove Snapshots, reason
 			kr.Counters.Daily = buckets[2].Count
 			kr.Counters.Weekly = buckets[3].Count
 			kr.Counters.Monthly = buckets[4].Count
-			kr.Counters.Yearly = buckets[5].Count
+			kr.Counters.Quarterly = buckets[5].Count
+			kr.Counters.Yearly = buckets[6].Count
 			reasons = append(reasons, kr)
 		} else {
 			remove = append(remove, cur)
@@ -24,13 +24,13 @@ func parseTimeUTC(s string) time.Time {
 }
 
 // Returns the maximum number of snapshots to be kept according to this policy.
-// If any of the counts is -1 it will return 0.
+// If any of the counts is -1 or calendar specs are set, it will return 0.
 func policySum(e *data.ExpirePolicy) int {
-	if e.Last == -1 || e.Hourly == -1 || e.Daily == -1 || e.Weekly == -1 || e.Monthly == -1 || e.Yearly == -1 {
+	if len(e.Calendar) > 0 || e.Last == -1 || e.Hourly == -1 || e.Daily == -1 || e.Weekly == -1 || e.Monthly == -1 || e.Quarterly == -1 || e.Yearly == -1 {
 		return 0
 	}
 
-	return e.Last + e.Hourly + e.Daily + e.Weekly + e.Monthly + e.Yearly
+	return e.Last + e.Hourly + e.Daily + e.Weekly + e.Monthly + e.Quarterly + e.Yearly
 }
 
 func TestExpireSnapshotOps(t *testing.T) {
@@ -245,6 +245,12 @@ func TestApplyPolicy(t *testing.T) {
 		{Last: -1, Hourly: -1}, // keep all (Last overrides Hourly)
 		{Hourly: -1},           // keep all hourlies
 		{Daily: 3, Weekly: 2, Monthly: -1, Yearly: -1},
+		{Quarterly: 3},
+		{Quarterly: -1},
+		{WithinQuarterly: data.ParseDurationOrPanic("1y")},
+		{Calendar: []data.CalendarSpec{data.ParseCalendarSpecOrPanic("0 0 1 1,4,7,10 *")}},
+		{Calendar: []data.CalendarSpec{data.ParseCalendarSpecOrPanic("0 12 * * 1")}},
+		{Daily: 3, Calendar: []data.CalendarSpec{data.ParseCalendarSpecOrPanic("0 0 15 * *")}},
 	}
 
 	for i, p := range tests {
@@ -180,6 +180,17 @@ func ParseDurationOrPanic(s string) Duration {
 	return d
 }
 
+// ParseCalendarSpecOrPanic parses a calendar spec from a string or panics if
+// the string is invalid.
+func ParseCalendarSpecOrPanic(s string) CalendarSpec {
+	c, err := ParseCalendarSpec(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return c
+}
+
 // TestLoadAllSnapshots returns a list of all snapshots in the repo.
 // If a snapshot ID is in excludeIDs, it will not be included in the result.
 func TestLoadAllSnapshots(ctx context.Context, repo restic.ListerLoaderUnpacked, excludeIDs restic.IDSet) (snapshots Snapshots, err error) {
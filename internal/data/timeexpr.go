@@ -0,0 +1,81 @@
+package data
+
+import (
+	"strings"
+	"time"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+// timeExprLayouts are the layouts accepted for absolute times, in the local
+// time zone unless the layout contains one.
+var timeExprLayouts = []string{
+	"2006-01-02",
+	"2006-01-02 15:04",
+	"2006-01-02 15:04:05",
+	time.RFC3339,
+}
+
+// TimeExpr is a point in time, which is either given relative to the current
+// time as a Duration such as "7d" or as an absolute time such as "2025-01-01".
+type TimeExpr struct {
+	raw      string
+	absolute time.Time
+	relative Duration
+}
+
+// ParseTimeExpr parses an absolute time or a duration relative to now.
+func ParseTimeExpr(s string) (TimeExpr, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return TimeExpr{}, errors.New("empty time expression")
+	}
+
+	for _, layout := range timeExprLayouts {
+		t, err := time.ParseInLocation(layout, s, time.Local)
+		if err == nil {
+			return TimeExpr{raw: s, absolute: t}, nil
+		}
+	}
+
+	d, err := ParseDuration(s)
+	if err != nil || d.Zero() {
+		return TimeExpr{}, errors.Errorf("invalid time %q, must be a duration like 7d or 1y6m, or a date like 2025-01-01", s)
+	}
+	return TimeExpr{raw: s, relative: d}, nil
+}
+
+// Time returns the point in time, relative durations are subtracted from now.
+func (t TimeExpr) Time(now time.Time) time.Time {
+	if !t.absolute.IsZero() {
+		return t.absolute
+	}
+	d := t.relative
+	return now.AddDate(-d.Years, -d.Months, -d.Days).Add(-time.Duration(d.Hours) * time.Hour)
+}
+
+// Zero returns true if no time was set.
+func (t TimeExpr) Zero() bool {
+	return t.raw == ""
+}
+
+func (t TimeExpr) String() string {
+	return t.raw
+}
+
+// Set calls ParseTimeExpr and updates t.
+func (t *TimeExpr) Set(s string) error {
+	v, err := ParseTimeExpr(s)
+	if err != nil {
+		return err
+	}
+
+	*t = v
+	return nil
+}
+
+// Type returns the type of TimeExpr, usable within github.com/spf13/pflag and
+// in help texts.
+func (t TimeExpr) Type() string {
+	return "time"
+}
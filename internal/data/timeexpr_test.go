@@ -0,0 +1,38 @@
+package data
+
+import (
+	"testing"
+	"time"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestParseTimeExpr(t *testing.T) {
+	now := time.Date(2025, 3, 15, 12, 0, 0, 0, time.Local)
+
+	for _, test := range []struct {
+		input    string
+		expected time.Time
+	}{
+		{"2025-01-01", time.Date(2025, 1, 1, 0, 0, 0, 0, time.Local)},
+		{"2025-01-01 10:30", time.Date(2025, 1, 1, 10, 30, 0, 0, time.Local)},
+		{"2025-01-01 10:30:15", time.Date(2025, 1, 1, 10, 30, 15, 0, time.Local)},
+		{"2025-01-01T10:30:15Z", time.Date(2025, 1, 1, 10, 30, 15, 0, time.UTC)},
+		{"7d", time.Date(2025, 3, 8, 12, 0, 0, 0, time.Local)},
+		{"1y2m", time.Date(2024, 1, 15, 12, 0, 0, 0, time.Local)},
+		{"36h", time.Date(2025, 3, 14, 0, 0, 0, 0, time.Local)},
+	} {
+		expr, err := ParseTimeExpr(test.input)
+		rtest.OK(t, err)
+		rtest.Equals(t, test.input, expr.String())
+		rtest.Assert(t, expr.Time(now).Equal(test.expected), "%q: expected %v, got %v", test.input, test.expected, expr.Time(now))
+	}
+
+	for _, input := range []string{"", "0d", "7x", "2025-13-01", "yesterday"} {
+		_, err := ParseTimeExpr(input)
+		rtest.Assert(t, err != nil, "expected error for %q", input)
+	}
+
+	var expr TimeExpr
+	rtest.Assert(t, expr.Zero(), "expected zero TimeExpr")
+}
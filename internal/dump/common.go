@@ -19,6 +19,11 @@ type Dumper struct {
 	format string
 	repo   restic.Loader
 	w      io.Writer
+
+	// SelectFilter determines whether the item at path is included in the
+	// archive and whether any of its children may be included. If it is nil,
+	// all items are included.
+	SelectFilter func(path string, isDir bool) (selected bool, childMayBeSelected bool)
 }
 
 func New(format string, repo restic.Loader, w io.Writer) *Dumper {
@@ -31,12 +36,34 @@ func New(format string, repo restic.Loader, w io.Writer) *Dumper {
 }
 
 func (d *Dumper) DumpTree(ctx context.Context, tree data.TreeNodeIterator, rootPath string) error {
+	return d.dump(ctx, func(ctx context.Context, ch chan *data.Node) error {
+		return d.sendTrees(ctx, tree, rootPath, ch)
+	})
+}
+
+// DumpNodes writes the nodes, including the contents of directories, to a
+// single archive. The Path of each node must be set to its location in the
+// archive.
+func (d *Dumper) DumpNodes(ctx context.Context, nodes []*data.Node) error {
+	return d.dump(ctx, func(ctx context.Context, ch chan *data.Node) error {
+		defer close(ch)
+		for _, node := range nodes {
+			if err := d.sendNodes(ctx, node, ch); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// dump writes the nodes sent by send to the archive. send must close ch.
+func (d *Dumper) dump(ctx context.Context, send func(ctx context.Context, ch chan *data.Node) error) error {
 	wg, ctx := errgroup.WithContext(ctx)
 
 	// ch is buffered to deal with variable download/write speeds.
 	ch := make(chan *data.Node, 10)
 	wg.Go(func() error {
-		return sendTrees(ctx, d.repo, tree, rootPath, ch)
+		return send(ctx, ch)
 	})
 
 	wg.Go(func() error {
@@ -52,7 +79,14 @@ func (d *Dumper) DumpTree(ctx context.Context, tree data.TreeNodeIterator, rootP
 	return wg.Wait()
 }
 
-func sendTrees(ctx context.Context, repo restic.BlobLoader, nodes data.TreeNodeIterator, rootPath string, ch chan *data.Node) error {
+func (d *Dumper) selectNode(node *data.Node) (selected bool, childMayBeSelected bool) {
+	if d.SelectFilter == nil {
+		return true, true
+	}
+	return d.SelectFilter(node.Path, node.Type == data.NodeTypeDir)
+}
+
+func (d *Dumper) sendTrees(ctx context.Context, nodes data.TreeNodeIterator, rootPath string, ch chan *data.Node) error {
 	defer close(ch)
 
 	for item := range nodes {
@@ -61,26 +95,29 @@ func sendTrees(ctx context.Context, repo restic.BlobLoader, nodes data.TreeNodeI
 		}
 		node := item.Node
 		node.Path = path.Join(rootPath, node.Name)
-		if err := sendNodes(ctx, repo, node, ch); err != nil {
+		if err := d.sendNodes(ctx, node, ch); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func sendNodes(ctx context.Context, repo restic.BlobLoader, root *data.Node, ch chan *data.Node) error {
-	select {
-	case ch <- root:
-	case <-ctx.Done():
-		return ctx.Err()
+func (d *Dumper) sendNodes(ctx context.Context, root *data.Node, ch chan *data.Node) error {
+	selected, childMayBeSelected := d.selectNode(root)
+	if selected {
+		select {
+		case ch <- root:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
 	}
 
 	// If this is no directory we are finished
-	if root.Type != data.NodeTypeDir {
+	if root.Type != data.NodeTypeDir || !childMayBeSelected {
 		return nil
 	}
 
-	err := walker.Walk(ctx, repo, *root.Subtree, walker.WalkVisitor{ProcessNode: func(_ restic.ID, nodepath string, node *data.Node, err error) error {
+	err := walker.Walk(ctx, d.repo, *root.Subtree, walker.WalkVisitor{ProcessNode: func(_ restic.ID, nodepath string, node *data.Node, err error) error {
 		if err != nil {
 			return err
 		}
@@ -94,12 +131,18 @@ func sendNodes(ctx context.Context, repo restic.BlobLoader, root *data.Node, ch
 			return nil
 		}
 
-		select {
-		case ch <- node:
-		case <-ctx.Done():
-			return ctx.Err()
+		selected, childMayBeSelected := d.selectNode(node)
+		if selected {
+			select {
+			case ch <- node:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
 		}
 
+		if node.Type == data.NodeTypeDir && !childMayBeSelected {
+			return walker.ErrSkipNode
+		}
 		return nil
 	}})
 
@@ -1,8 +1,10 @@
 package dump
 
 import (
+	"archive/tar"
 	"bytes"
 	"context"
+	"io"
 	"testing"
 
 	"github.com/restic/restic/internal/archiver"
@@ -115,3 +117,108 @@ func WriteTest(t *testing.T, format string, cd CheckDump) {
 		})
 	}
 }
+
+func TestDumpSelectFilter(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tmpdir, repo, _ := prepareTempdirRepoSrc(t, archiver.TestDir{
+		"file1": archiver.TestFile{Content: "string"},
+		"firstDir": archiver.TestDir{
+			"another": archiver.TestFile{Content: "string"},
+			"skipped": archiver.TestFile{Content: "string"},
+		},
+		"secondDir": archiver.TestDir{
+			"another2": archiver.TestFile{Content: "string"},
+		},
+	})
+	arch := archiver.New(repo, fs.Track{FS: fs.NewLocal()}, archiver.Options{})
+
+	back := rtest.Chdir(t, tmpdir)
+	defer back()
+
+	sn, _, _, err := arch.Snapshot(ctx, []string{"."}, archiver.SnapshotOptions{})
+	rtest.OK(t, err)
+
+	tree, err := data.LoadTree(ctx, repo, *sn.Tree)
+	rtest.OK(t, err)
+
+	var visited []string
+	dst := &bytes.Buffer{}
+	d := New("tar", repo, dst)
+	d.SelectFilter = func(path string, isDir bool) (bool, bool) {
+		visited = append(visited, path)
+		switch path {
+		case "/firstDir":
+			return false, true
+		case "/firstDir/another":
+			return true, false
+		}
+		return false, false
+	}
+	rtest.OK(t, d.DumpTree(ctx, tree, "/"))
+
+	// secondDir must not be entered
+	rtest.Equals(t, []string{"/file1", "/firstDir", "/firstDir/another", "/firstDir/skipped", "/secondDir"}, visited)
+
+	tr := tar.NewReader(dst)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		rtest.OK(t, err)
+		names = append(names, hdr.Name)
+	}
+	rtest.Equals(t, []string{"firstDir/another"}, names)
+}
+
+func TestDumpNodes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tmpdir, repo, _ := prepareTempdirRepoSrc(t, archiver.TestDir{
+		"file1": archiver.TestFile{Content: "string"},
+		"firstDir": archiver.TestDir{
+			"another": archiver.TestFile{Content: "string"},
+		},
+		"secondDir": archiver.TestDir{
+			"another2": archiver.TestFile{Content: "string"},
+		},
+	})
+	arch := archiver.New(repo, fs.Track{FS: fs.NewLocal()}, archiver.Options{})
+
+	back := rtest.Chdir(t, tmpdir)
+	defer back()
+
+	sn, _, _, err := arch.Snapshot(ctx, []string{"."}, archiver.SnapshotOptions{})
+	rtest.OK(t, err)
+
+	tree, err := data.LoadTree(ctx, repo, *sn.Tree)
+	rtest.OK(t, err)
+
+	var nodes []*data.Node
+	for item := range tree {
+		rtest.OK(t, item.Error)
+		if item.Node.Name != "secondDir" {
+			item.Node.Path = "/" + item.Node.Name
+			nodes = append(nodes, item.Node)
+		}
+	}
+
+	dst := &bytes.Buffer{}
+	rtest.OK(t, New("tar", repo, dst).DumpNodes(ctx, nodes))
+
+	tr := tar.NewReader(dst)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		rtest.OK(t, err)
+		names = append(names, hdr.Name)
+	}
+	rtest.Equals(t, []string{"file1", "firstDir/", "firstDir/another"}, names)
+}
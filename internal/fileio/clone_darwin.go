@@ -0,0 +1,18 @@
+package fileio
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// CloneFile creates dst as a copy-on-write clone of src, which shares the
+// data blocks with src. This requires that both files are located on the same
+// APFS volume. dst must not exist.
+func CloneFile(src *os.File, dst string) error {
+	err := unix.Fclonefileat(int(src.Fd()), unix.AT_FDCWD, dst, 0)
+	if err != nil {
+		return &os.LinkError{Op: "clone", Old: src.Name(), New: dst, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package fileio
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// CloneFile creates dst as a copy-on-write clone of src, which shares the
+// data blocks with src. This requires that both files are located on the same
+// filesystem which supports reflinks, like btrfs or XFS. dst must not exist.
+func CloneFile(src *os.File, dst string) error {
+	f, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	err = unix.IoctlFileClone(int(f.Fd()), int(src.Fd()))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(dst)
+		return &os.LinkError{Op: "clone", Old: src.Name(), New: dst, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+//go:build !linux && !darwin
+
+package fileio
+
+import (
+	"errors"
+	"os"
+)
+
+// CloneFile is not supported on this platform and always returns
+// errors.ErrUnsupported.
+func CloneFile(_ *os.File, _ string) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,40 @@
+package fileio
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// PunchHole deallocates the range of length bytes at offset in wr, such that
+// it reads as zeros afterwards. The file size is not changed. Returns an error
+// if the filesystem does not support holes.
+func PunchHole(wr *os.File, offset, length int64) error {
+	if length <= 0 {
+		return nil
+	}
+
+	// F_PUNCHHOLE only accepts ranges aligned to the filesystem block size,
+	// thus write zeros to the unaligned start and end of the range.
+	var st unix.Statfs_t
+	if err := unix.Fstatfs(int(wr.Fd()), &st); err != nil {
+		return err
+	}
+	bsize := int64(st.Bsize)
+	start := (offset + bsize - 1) / bsize * bsize
+	end := (offset + length) / bsize * bsize
+	if start >= end {
+		return WriteZeros(wr, offset, length)
+	}
+
+	// struct fpunchhole has the same layout as the start of Fstore_t:
+	// uint32 flags, uint32 reserved, off_t offset, off_t length
+	args := unix.Fstore_t{Offset: start, Length: end - start}
+	if err := unix.FcntlFstore(wr.Fd(), unix.F_PUNCHHOLE, &args); err != nil {
+		return err
+	}
+	if err := WriteZeros(wr, offset, start-offset); err != nil {
+		return err
+	}
+	return WriteZeros(wr, end, offset+length-end)
+}
@@ -0,0 +1,19 @@
+package fileio
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// PunchHole deallocates the range of length bytes at offset in wr, such that
+// it reads as zeros afterwards. The file size is not changed. Returns an error
+// if the filesystem does not support holes.
+func PunchHole(wr *os.File, offset, length int64) error {
+	if length <= 0 {
+		return nil
+	}
+	return ignoringEINTR(func() error {
+		return unix.Fallocate(int(wr.Fd()), unix.FALLOC_FL_PUNCH_HOLE|unix.FALLOC_FL_KEEP_SIZE, offset, length)
+	})
+}
@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !windows
+
+package fileio
+
+import (
+	"errors"
+	"os"
+)
+
+// PunchHole is not supported on this platform and always returns
+// errors.ErrUnsupported.
+func PunchHole(_ *os.File, _, _ int64) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,26 @@
+package fileio
+
+import (
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// PunchHole deallocates the range of length bytes at offset in wr, such that
+// it reads as zeros afterwards. The file size is not changed. The space is
+// only released if the sparse attribute is set for the file.
+func PunchHole(wr *os.File, offset, length int64) error {
+	if length <= 0 {
+		return nil
+	}
+
+	// FILE_ZERO_DATA_INFORMATION
+	info := struct {
+		FileOffset      int64
+		BeyondFinalZero int64
+	}{offset, offset + length}
+	var t uint32
+	return windows.DeviceIoControl(windows.Handle(wr.Fd()), windows.FSCTL_SET_ZERO_DATA,
+		(*byte)(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info)), nil, 0, &t, nil)
+}
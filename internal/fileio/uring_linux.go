@@ -0,0 +1,334 @@
+package fileio
+
+import (
+	"io"
+	"os"
+	"runtime"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"golang.org/x/sys/unix"
+)
+
+// constants of the io_uring ABI, see include/uapi/linux/io_uring.h
+const (
+	ioringOffSQRing = 0
+	ioringOffCQRing = 0x8000000
+	ioringOffSQEs   = 0x10000000
+
+	ioringEnterGetEvents = 1 << 0
+
+	// ioringFeatRWCurPos was added in Linux 5.6 together with the write and
+	// fallocate operations
+	ioringFeatRWCurPos = 1 << 3
+
+	ioringOpFallocate = 17
+	ioringOpWrite     = 23
+)
+
+type ioSQRingOffsets struct {
+	head, tail, ringMask, ringEntries, flags, dropped, array, resv1 uint32
+	userAddr                                                        uint64
+}
+
+type ioCQRingOffsets struct {
+	head, tail, ringMask, ringEntries, overflow, cqes, flags, resv1 uint32
+	userAddr                                                        uint64
+}
+
+type ioUringParams struct {
+	sqEntries, cqEntries, flags, sqThreadCPU, sqThreadIdle, features, wqFD uint32
+	resv                                                                   [3]uint32
+	sqOff                                                                  ioSQRingOffsets
+	cqOff                                                                  ioCQRingOffsets
+}
+
+// ioUringSQE is a submission queue entry.
+type ioUringSQE struct {
+	opcode      uint8
+	flags       uint8
+	ioprio      uint16
+	fd          int32
+	off         uint64
+	addr        uint64
+	len         uint32
+	opFlags     uint32
+	userData    uint64
+	bufIndex    uint16
+	personality uint16
+	spliceFDIn  int32
+	addr3       uint64
+	pad         uint64
+}
+
+// ioUringCQE is a completion queue entry.
+type ioUringCQE struct {
+	userData uint64
+	res      int32
+	flags    uint32
+}
+
+// ringRequest is a single operation submitted to the ring.
+type ringRequest struct {
+	sqe ioUringSQE
+	// buf is referenced to keep the buffer alive while the kernel uses it
+	buf  []byte
+	res  int32
+	done chan struct{}
+}
+
+// Ring submits positional writes and fallocate calls to an io_uring instance.
+// The requests of all concurrent callers are collected by a single goroutine
+// and submitted in batches, such that many writes only require a single
+// system call.
+type Ring struct {
+	fd      int
+	entries uint32
+
+	sqRing, cqRing, sqesMem []byte
+	sqHead, sqTail, sqMask  *uint32
+	sqArray                 []uint32
+	sqes                    []ioUringSQE
+	cqHead, cqTail, cqMask  *uint32
+	cqes                    []ioUringCQE
+
+	requests chan *ringRequest
+	done     chan struct{}
+	// broken is set if the ring has failed, all further requests fail with
+	// this error
+	broken syscall.Errno
+}
+
+// NewRing sets up an io_uring instance with the given number of submission
+// queue entries. An error is returned if io_uring is not supported, for
+// example because it is disabled.
+func NewRing(entries uint32) (*Ring, error) {
+	var params ioUringParams
+	fd, _, errno := unix.Syscall(unix.SYS_IO_URING_SETUP, uintptr(entries), uintptr(unsafe.Pointer(&params)), 0)
+	if errno != 0 {
+		return nil, errors.Wrap(errno, "io_uring_setup")
+	}
+	if params.features&ioringFeatRWCurPos == 0 {
+		_ = unix.Close(int(fd))
+		return nil, errors.New("io_uring does not support write operations, requires Linux 5.6 or newer")
+	}
+
+	r := &Ring{
+		fd:       int(fd),
+		entries:  params.sqEntries,
+		requests: make(chan *ringRequest),
+		done:     make(chan struct{}),
+	}
+
+	err := r.mmap(&params)
+	if err != nil {
+		r.unmap()
+		_ = unix.Close(r.fd)
+		return nil, err
+	}
+
+	go r.run()
+	return r, nil
+}
+
+func (r *Ring) mmap(params *ioUringParams) error {
+	var err error
+	sqSize := params.sqOff.array + params.sqEntries*4
+	r.sqRing, err = unix.Mmap(r.fd, ioringOffSQRing, int(sqSize), unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED|unix.MAP_POPULATE)
+	if err != nil {
+		return errors.Wrap(err, "mmap")
+	}
+	cqSize := params.cqOff.cqes + params.cqEntries*uint32(unsafe.Sizeof(ioUringCQE{}))
+	r.cqRing, err = unix.Mmap(r.fd, ioringOffCQRing, int(cqSize), unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED|unix.MAP_POPULATE)
+	if err != nil {
+		return errors.Wrap(err, "mmap")
+	}
+	sqesSize := params.sqEntries * uint32(unsafe.Sizeof(ioUringSQE{}))
+	r.sqesMem, err = unix.Mmap(r.fd, ioringOffSQEs, int(sqesSize), unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED|unix.MAP_POPULATE)
+	if err != nil {
+		return errors.Wrap(err, "mmap")
+	}
+
+	r.sqHead = (*uint32)(unsafe.Pointer(&r.sqRing[params.sqOff.head]))
+	r.sqTail = (*uint32)(unsafe.Pointer(&r.sqRing[params.sqOff.tail]))
+	r.sqMask = (*uint32)(unsafe.Pointer(&r.sqRing[params.sqOff.ringMask]))
+	r.sqArray = unsafe.Slice((*uint32)(unsafe.Pointer(&r.sqRing[params.sqOff.array])), params.sqEntries)
+	r.sqes = unsafe.Slice((*ioUringSQE)(unsafe.Pointer(&r.sqesMem[0])), params.sqEntries)
+	r.cqHead = (*uint32)(unsafe.Pointer(&r.cqRing[params.cqOff.head]))
+	r.cqTail = (*uint32)(unsafe.Pointer(&r.cqRing[params.cqOff.tail]))
+	r.cqMask = (*uint32)(unsafe.Pointer(&r.cqRing[params.cqOff.ringMask]))
+	r.cqes = unsafe.Slice((*ioUringCQE)(unsafe.Pointer(&r.cqRing[params.cqOff.cqes])), params.cqEntries)
+	return nil
+}
+
+func (r *Ring) unmap() {
+	for _, mem := range [][]byte{r.sqRing, r.cqRing, r.sqesMem} {
+		if mem != nil {
+			_ = unix.Munmap(mem)
+		}
+	}
+}
+
+// run collects requests and submits them in batches until the ring is closed.
+func (r *Ring) run() {
+	defer close(r.done)
+
+	batch := make([]*ringRequest, 0, r.entries)
+	for req := range r.requests {
+		if r.broken != 0 {
+			req.res = -int32(r.broken)
+			close(req.done)
+			continue
+		}
+		batch = append(batch[:0], req)
+
+		// add all other pending requests to the batch
+	collect:
+		for len(batch) < int(r.entries) {
+			select {
+			case req, ok := <-r.requests:
+				if !ok {
+					break collect
+				}
+				batch = append(batch, req)
+			default:
+				break collect
+			}
+		}
+
+		r.submit(batch)
+	}
+}
+
+// submit passes all requests in batch to the kernel and waits until they are
+// completed.
+func (r *Ring) submit(batch []*ringRequest) {
+	tail := atomic.LoadUint32(r.sqTail)
+	mask := atomic.LoadUint32(r.sqMask)
+	for i, req := range batch {
+		idx := (tail + uint32(i)) & mask
+		r.sqes[idx] = req.sqe
+		r.sqes[idx].userData = uint64(i)
+		r.sqArray[idx] = idx
+	}
+	atomic.StoreUint32(r.sqTail, tail+uint32(len(batch)))
+
+	toSubmit := uint32(len(batch))
+	pending := len(batch)
+	for pending > 0 {
+		n, _, errno := unix.Syscall6(unix.SYS_IO_URING_ENTER, uintptr(r.fd), uintptr(toSubmit), uintptr(pending), ioringEnterGetEvents, 0, 0)
+		if errno != 0 && errno != syscall.EINTR && errno != syscall.EAGAIN && errno != syscall.EBUSY {
+			// the ring is unusable, fail all requests which did not complete yet
+			debug.Log("io_uring_enter failed: %v", errno)
+			r.broken = errno
+			for _, req := range batch {
+				if req.done != nil {
+					req.res = -int32(errno)
+					close(req.done)
+					req.done = nil
+				}
+			}
+			return
+		}
+		if errno == 0 {
+			toSubmit -= uint32(n)
+		}
+
+		// reap completions
+		head := atomic.LoadUint32(r.cqHead)
+		cqTail := atomic.LoadUint32(r.cqTail)
+		cqMask := atomic.LoadUint32(r.cqMask)
+		for ; head != cqTail; head++ {
+			cqe := r.cqes[head&cqMask]
+			req := batch[cqe.userData]
+			req.res = cqe.res
+			close(req.done)
+			req.done = nil
+			pending--
+		}
+		atomic.StoreUint32(r.cqHead, head)
+	}
+}
+
+// do submits the request and waits for its result.
+func (r *Ring) do(req *ringRequest) (int, error) {
+	done := make(chan struct{})
+	req.done = done
+	r.requests <- req
+	<-done
+	runtime.KeepAlive(req.buf)
+
+	if req.res < 0 {
+		return 0, syscall.Errno(-req.res)
+	}
+	return int(req.res), nil
+}
+
+// WriteAt writes p to f at offset, see os.File.WriteAt.
+func (r *Ring) WriteAt(f *os.File, p []byte, offset int64) (int, error) {
+	fd := int32(f.Fd())
+	written := 0
+	for written < len(p) {
+		buf := p[written:]
+		n, err := r.do(&ringRequest{
+			sqe: ioUringSQE{
+				opcode: ioringOpWrite,
+				fd:     fd,
+				off:    uint64(offset + int64(written)),
+				addr:   uint64(uintptr(unsafe.Pointer(&buf[0]))),
+				len:    uint32(len(buf)),
+			},
+			buf: buf,
+		})
+		if err == syscall.EINTR || err == syscall.EAGAIN {
+			continue
+		}
+		if err != nil {
+			runtime.KeepAlive(f)
+			return written, &os.PathError{Op: "write", Path: f.Name(), Err: err}
+		}
+		if n == 0 {
+			runtime.KeepAlive(f)
+			return written, &os.PathError{Op: "write", Path: f.Name(), Err: io.ErrShortWrite}
+		}
+		written += n
+	}
+	runtime.KeepAlive(f)
+	return written, nil
+}
+
+// PreallocateFile allocates size bytes for f and also changes its size, see
+// the package level PreallocateFile.
+func (r *Ring) PreallocateFile(f *os.File, size int64) error {
+	if size <= 0 {
+		return nil
+	}
+	for {
+		_, err := r.do(&ringRequest{
+			sqe: ioUringSQE{
+				opcode: ioringOpFallocate,
+				fd:     int32(f.Fd()),
+				off:    0,
+				// for fallocate, addr holds the length and len the mode
+				addr: uint64(size),
+				len:  0,
+			},
+		})
+		runtime.KeepAlive(f)
+		if err != syscall.EINTR {
+			return err
+		}
+	}
+}
+
+// Close waits for all pending requests and releases the ring. The ring must
+// not be used afterwards.
+func (r *Ring) Close() error {
+	close(r.requests)
+	<-r.done
+	r.unmap()
+	return unix.Close(r.fd)
+}
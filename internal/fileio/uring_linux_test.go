@@ -0,0 +1,72 @@
+package fileio
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"sync"
+	"testing"
+
+	"github.com/restic/restic/internal/test"
+)
+
+func TestRingWriteAt(t *testing.T) {
+	ring, err := NewRing(8)
+	if err != nil {
+		t.Skipf("io_uring not available: %v", err)
+	}
+	defer func() {
+		test.OK(t, ring.Close())
+	}()
+
+	const blockSize = 4096
+	const blocks = 64
+
+	filename := path.Join(test.TempDir(t), "test")
+	f, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY, 0600)
+	test.OK(t, err)
+	test.OK(t, ring.PreallocateFile(f, blockSize*blocks))
+
+	fi, err := f.Stat()
+	test.OK(t, err)
+	test.Equals(t, int64(blockSize*blocks), fi.Size())
+
+	// write more blocks concurrently than the ring has entries
+	var wg sync.WaitGroup
+	for i := 0; i < blocks; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			n, err := ring.WriteAt(f, bytes.Repeat([]byte{byte(i)}, blockSize), int64(i*blockSize))
+			test.OK(t, err)
+			test.Equals(t, blockSize, n)
+		}(i)
+	}
+	wg.Wait()
+	test.OK(t, f.Close())
+
+	buf, err := os.ReadFile(filename)
+	test.OK(t, err)
+	for i := 0; i < blocks; i++ {
+		test.Assert(t, bytes.Equal(buf[i*blockSize:(i+1)*blockSize], bytes.Repeat([]byte{byte(i)}, blockSize)), "unexpected content of block %d", i)
+	}
+}
+
+func TestRingWriteAtClosedFile(t *testing.T) {
+	ring, err := NewRing(8)
+	if err != nil {
+		t.Skipf("io_uring not available: %v", err)
+	}
+	defer func() {
+		test.OK(t, ring.Close())
+	}()
+
+	f, err := os.OpenFile(path.Join(test.TempDir(t), "test"), os.O_CREATE|os.O_RDONLY, 0600)
+	test.OK(t, err)
+	defer func() {
+		test.OK(t, f.Close())
+	}()
+
+	_, err = ring.WriteAt(f, []byte("foo"), 0)
+	test.Assert(t, err != nil, "expected error when writing to read-only file")
+}
@@ -0,0 +1,29 @@
+//go:build !linux
+
+package fileio
+
+import (
+	"os"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+// Ring is only supported on Linux.
+type Ring struct{}
+
+// NewRing always returns an error as io_uring is only available on Linux.
+func NewRing(_ uint32) (*Ring, error) {
+	return nil, errors.New("io_uring is only supported on Linux")
+}
+
+func (r *Ring) WriteAt(f *os.File, p []byte, offset int64) (int, error) {
+	return f.WriteAt(p, offset)
+}
+
+func (r *Ring) PreallocateFile(f *os.File, size int64) error {
+	return PreallocateFile(f, size)
+}
+
+func (r *Ring) Close() error {
+	return nil
+}
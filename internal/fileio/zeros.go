@@ -0,0 +1,33 @@
+package fileio
+
+import "os"
+
+// zeroBufferSize is the maximum amount of zeros written at once by WriteZeros.
+const zeroBufferSize = 1 << 20
+
+// WriteZeros writes length zero bytes to wr at offset.
+func WriteZeros(wr *os.File, offset, length int64) error {
+	if length <= 0 {
+		return nil
+	}
+	zeros := make([]byte, min(length, zeroBufferSize))
+	for length > 0 {
+		n := min(length, int64(len(zeros)))
+		if _, err := wr.WriteAt(zeros[:n], offset); err != nil {
+			return err
+		}
+		offset += n
+		length -= n
+	}
+	return nil
+}
+
+// ZeroRange makes sure that the range of length bytes at offset in wr reads
+// as zeros. It punches a hole into the file if supported and otherwise writes
+// zeros.
+func ZeroRange(wr *os.File, offset, length int64) error {
+	if err := PunchHole(wr, offset, length); err == nil {
+		return nil
+	}
+	return WriteZeros(wr, offset, length)
+}
@@ -0,0 +1,32 @@
+package fileio
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"strconv"
+	"testing"
+
+	"github.com/restic/restic/internal/test"
+)
+
+func TestZeroRange(t *testing.T) {
+	const size = 3 * zeroBufferSize
+	for _, r := range [][2]int64{{0, 0}, {0, size}, {1, 1}, {4096, 8192}, {13, zeroBufferSize + 4711}, {size - 42, 42}} {
+		t.Run(strconv.FormatInt(r[0], 10)+"-"+strconv.FormatInt(r[1], 10), func(t *testing.T) {
+			filename := path.Join(test.TempDir(t), "test")
+			content := bytes.Repeat([]byte{0xff}, size)
+			test.OK(t, os.WriteFile(filename, content, 0600))
+
+			wr, err := os.OpenFile(filename, os.O_WRONLY, 0600)
+			test.OK(t, err)
+			test.OK(t, ZeroRange(wr, r[0], r[1]))
+			test.OK(t, wr.Close())
+
+			clear(content[r[0] : r[0]+r[1]])
+			buf, err := os.ReadFile(filename)
+			test.OK(t, err)
+			test.Assert(t, bytes.Equal(content, buf), "unexpected file content after zeroing range %v", r)
+		})
+	}
+}
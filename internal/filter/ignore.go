@@ -0,0 +1,111 @@
+package filter
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+)
+
+// IgnoreFile contains the rules of an ignore file, which uses the syntax of
+// gitignore files. In contrast to exclude patterns, a pattern which contains a
+// slash at the beginning or in the middle is anchored at the directory
+// containing the ignore file, and a trailing slash restricts the pattern to
+// directories.
+type IgnoreFile struct {
+	rules []ignoreRule
+}
+
+type ignoreRule struct {
+	pattern Pattern
+	negated bool
+	dirOnly bool
+}
+
+// ParseIgnoreFile parses the content of an ignore file. Empty lines and lines
+// starting with "#" are ignored, a leading backslash escapes "#" and "!". A
+// pattern starting with "!" re-includes files excluded by a previous pattern.
+func ParseIgnoreFile(data []byte) (*IgnoreFile, error) {
+	f := &IgnoreFile{}
+	var invalid []string
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := trimIgnoreLine(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var rule ignoreRule
+		if strings.HasPrefix(line, "!") {
+			rule.negated = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\#`) {
+			line = line[1:]
+		}
+
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+
+		// patterns containing a slash are relative to the directory of the
+		// ignore file, all others match at any depth
+		if strings.Contains(line, "/") && !strings.HasPrefix(line, "/") {
+			line = "/" + line
+		}
+
+		if err := ValidatePatterns([]string{line}); err != nil {
+			invalid = append(invalid, scanner.Text())
+			continue
+		}
+		rule.pattern = preparePattern(line)
+		f.rules = append(f.rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(invalid) > 0 {
+		return nil, &InvalidPatternError{InvalidPatterns: invalid}
+	}
+	return f, nil
+}
+
+// trimIgnoreLine removes trailing spaces from line, unless they are escaped
+// with a backslash.
+func trimIgnoreLine(line string) string {
+	line = strings.TrimRight(line, "\r")
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-1]
+	}
+	return line
+}
+
+// Match reports whether path, which is relative to the directory containing
+// the ignore file and starts with a slash, is ignored. The last matching rule
+// decides. If no rule matches, decided is false.
+func (f *IgnoreFile) Match(path string, isDir bool) (ignored bool, decided bool, err error) {
+	strs, err := prepareStr(path)
+	if err != nil {
+		return false, false, err
+	}
+
+	for i := len(f.rules) - 1; i >= 0; i-- {
+		rule := f.rules[i]
+		if rule.dirOnly && !isDir {
+			continue
+		}
+
+		m, err := match(rule.pattern, strs)
+		if err != nil {
+			return false, false, err
+		}
+		if m {
+			return !rule.negated, true, nil
+		}
+	}
+	return false, false, nil
+}
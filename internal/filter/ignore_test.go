@@ -0,0 +1,65 @@
+package filter
+
+import (
+	"testing"
+)
+
+func TestIgnoreFile(t *testing.T) {
+	f, err := ParseIgnoreFile([]byte(`# comment
+*.log
+!important.log
+build/
+/root.txt
+doc/*.html
+**/cache/tmp
+\#hash
+\!bang
+trailing
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		path    string
+		isDir   bool
+		ignored bool
+		decided bool
+	}{
+		{"/test.log", false, true, true},
+		{"/sub/test.log", false, true, true},
+		{"/important.log", false, false, true},
+		{"/sub/important.log", false, false, true},
+		{"/build", true, true, true},
+		{"/sub/build", true, true, true},
+		{"/build", false, false, false},
+		{"/root.txt", false, true, true},
+		{"/sub/root.txt", false, false, false},
+		{"/doc/index.html", false, true, true},
+		{"/sub/doc/index.html", false, false, false},
+		{"/cache/tmp", true, true, true},
+		{"/a/b/cache/tmp", false, true, true},
+		{"/#hash", false, true, true},
+		{"/!bang", false, true, true},
+		{"/trailing", false, true, true},
+		{"/main.go", false, false, false},
+	}
+
+	for _, tc := range tests {
+		ignored, decided, err := f.Match(tc.path, tc.isDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ignored != tc.ignored || decided != tc.decided {
+			t.Errorf("wrong result for %v (dir %v): want %v/%v, got %v/%v",
+				tc.path, tc.isDir, tc.ignored, tc.decided, ignored, decided)
+		}
+	}
+}
+
+func TestIgnoreFileInvalid(t *testing.T) {
+	_, err := ParseIgnoreFile([]byte("*.log\n[invalid\n"))
+	if err == nil {
+		t.Fatal("expected error for invalid pattern")
+	}
+}
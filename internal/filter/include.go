@@ -1,6 +1,7 @@
 package filter
 
 import (
+	"path/filepath"
 	"strings"
 
 	"github.com/restic/restic/internal/errors"
@@ -102,3 +103,37 @@ func IncludeByInsensitivePattern(patterns []string, warnf func(msg string, args
 		return includeFunc(strings.ToLower(item))
 	}
 }
+
+// IncludeByPaths returns an IncludeByNameFunc which includes the given paths
+// and everything below them. Unlike patterns, the paths are compared verbatim.
+func IncludeByPaths(paths []string) IncludeByNameFunc {
+	selected := make(map[string]struct{}, len(paths))
+	parents := make(map[string]struct{})
+	for _, p := range paths {
+		p = filepath.Clean(p)
+		selected[p] = struct{}{}
+
+		for dir := filepath.Dir(p); ; dir = filepath.Dir(dir) {
+			if _, ok := parents[dir]; ok {
+				// all parents of dir have already been added
+				break
+			}
+			parents[dir] = struct{}{}
+			if dir == filepath.Dir(dir) {
+				break
+			}
+		}
+	}
+
+	return func(item string) (matched bool, childMayMatch bool) {
+		_, childMayMatch = parents[item]
+		for p := item; ; p = filepath.Dir(p) {
+			if _, ok := selected[p]; ok {
+				return true, true
+			}
+			if p == filepath.Dir(p) {
+				return false, childMayMatch
+			}
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package filter
 
 import (
+	"path/filepath"
 	"testing"
 )
 
@@ -57,3 +58,33 @@ func TestIncludeByInsensitivePattern(t *testing.T) {
 		})
 	}
 }
+
+func TestIncludeByPaths(t *testing.T) {
+	var tests = []struct {
+		filename      string
+		include       bool
+		childMayMatch bool
+	}{
+		{filename: "/", include: false, childMayMatch: true},
+		{filename: "/home", include: false, childMayMatch: true},
+		{filename: "/home/user", include: false, childMayMatch: true},
+		{filename: "/home/user/*.go", include: true, childMayMatch: true},
+		{filename: "/home/user/foo.go", include: false, childMayMatch: false},
+		{filename: "/home/user/dir", include: true, childMayMatch: true},
+		{filename: "/home/user/dir/subdir/file", include: true, childMayMatch: true},
+		{filename: "/home/user/dir2", include: false, childMayMatch: false},
+		{filename: "/home/other", include: false, childMayMatch: false},
+		{filename: "/srv", include: true, childMayMatch: true},
+	}
+
+	includeFunc := IncludeByPaths([]string{"/home/user/*.go", "/home/user/dir/", "/srv"})
+	for _, tc := range tests {
+		t.Run(tc.filename, func(t *testing.T) {
+			matched, childMayMatch := includeFunc(filepath.FromSlash(tc.filename))
+			if matched != tc.include || childMayMatch != tc.childMayMatch {
+				t.Fatalf("wrong result for filename %v: want %v, %v, got %v, %v",
+					tc.filename, tc.include, tc.childMayMatch, matched, childMayMatch)
+			}
+		})
+	}
+}
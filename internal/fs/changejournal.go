@@ -0,0 +1,187 @@
+package fs
+
+import (
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+// ErrChangeJournalUnsupported is returned by NewChangeJournal if the operating
+// system does not provide a filesystem change journal.
+var ErrChangeJournalUnsupported = errors.New("filesystem change journal is not supported on this platform")
+
+// ErrChangeJournalPositionInvalid is returned by ChangeJournal.Changes if the
+// changes since the given position are no longer available, for example because
+// the journal was recreated or has wrapped around.
+var ErrChangeJournalPositionInvalid = errors.New("change journal position is no longer valid")
+
+// ChangeJournal enumerates the paths changed on a filesystem using a journal
+// maintained by the operating system, for example the USN journal on NTFS or
+// FSEvents on macOS. This allows skipping the scan of directories in which
+// nothing has changed since a previous backup.
+type ChangeJournal interface {
+	// Position returns an opaque token which describes the current position
+	// of the journal for the volume containing the absolute path root.
+	Position(root string) (string, error)
+
+	// Changes adds all paths below root which have changed since the
+	// position returned by an earlier call to Position for root to changes.
+	Changes(root string, since string, changes *ChangeSet) error
+}
+
+// ChangeSet collects the paths reported as changed by a ChangeJournal.
+type ChangeSet struct {
+	m sync.Mutex
+	// roots for which all changes are known
+	roots map[string]struct{}
+	// changed paths and all their parent directories
+	dirty map[string]struct{}
+	// directories below which everything must be considered changed
+	recursive map[string]struct{}
+	// names of files whose changes affect all subdirectories
+	inherited map[string]struct{}
+}
+
+// NewChangeSet returns an empty ChangeSet.
+func NewChangeSet() *ChangeSet {
+	return &ChangeSet{
+		roots:     make(map[string]struct{}),
+		dirty:     make(map[string]struct{}),
+		recursive: make(map[string]struct{}),
+		inherited: make(map[string]struct{}),
+	}
+}
+
+// AddInheritedName records that a file called name contains rules which also
+// apply to all subdirectories of the directory containing it, for example an
+// ignore file. A change of such a file is treated like a change of everything
+// below its directory. It must be called before adding changes.
+func (c *ChangeSet) AddInheritedName(name string) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	c.inherited[changeSetKey(name)] = struct{}{}
+}
+
+// changeSetKey normalizes path for lookups. On Windows and macOS, filesystems
+// are usually case-insensitive, thus paths reported by the journal may differ
+// in case from the paths passed on the command line. Ignoring the case there
+// can only cause additional paths to be considered changed.
+func changeSetKey(path string) string {
+	path = filepath.Clean(path)
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		path = strings.ToLower(path)
+	}
+	return path
+}
+
+// AddRoot records that the changes below root are completely known.
+func (c *ChangeSet) AddRoot(root string) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	c.roots[changeSetKey(root)] = struct{}{}
+}
+
+// Add records that path was changed.
+func (c *ChangeSet) Add(path string) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	key := changeSetKey(path)
+	if _, ok := c.inherited[filepath.Base(key)]; ok {
+		c.recursive[filepath.Dir(key)] = struct{}{}
+	}
+
+	for p := key; ; {
+		if _, ok := c.dirty[p]; ok {
+			// all parents have already been added
+			return
+		}
+		c.dirty[p] = struct{}{}
+
+		parent := filepath.Dir(p)
+		if parent == p {
+			return
+		}
+		p = parent
+	}
+}
+
+// AddRecursive records that path and everything below it may have changed.
+// This must be used for directories which were created or moved, as the
+// previous content at their path belongs to a different directory.
+func (c *ChangeSet) AddRecursive(path string) {
+	c.Add(path)
+
+	c.m.Lock()
+	defer c.m.Unlock()
+	c.recursive[changeSetKey(path)] = struct{}{}
+}
+
+// Unchanged returns whether neither the directory dir nor anything below it
+// has changed. This is only the case if dir is located below a root for which
+// all changes are known.
+func (c *ChangeSet) Unchanged(dir string) bool {
+	if c == nil {
+		return false
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	key := changeSetKey(dir)
+	if _, ok := c.dirty[key]; ok {
+		return false
+	}
+
+	covered := false
+	for p := key; ; {
+		if _, ok := c.recursive[p]; ok {
+			return false
+		}
+		if _, ok := c.roots[p]; ok {
+			covered = true
+		}
+
+		parent := filepath.Dir(p)
+		if parent == p {
+			break
+		}
+		p = parent
+	}
+	return covered
+}
+
+// resolvedRoot translates the paths reported by a journal, which refer to the
+// real location of files, to paths below a root which may contain symlinks.
+type resolvedRoot struct {
+	root, real string
+}
+
+func newResolvedRoot(root string) (resolvedRoot, error) {
+	real, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return resolvedRoot{}, err
+	}
+	return resolvedRoot{root: filepath.Clean(root), real: filepath.Clean(real)}, nil
+}
+
+// translate returns the path below root for a path reported by the journal.
+// Paths outside of root are ignored.
+func (r resolvedRoot) translate(path string) (string, bool) {
+	key, real := changeSetKey(path), changeSetKey(r.real)
+	if key == real {
+		return r.root, true
+	}
+
+	prefix := real
+	if !strings.HasSuffix(prefix, string(filepath.Separator)) {
+		prefix += string(filepath.Separator)
+	}
+	if !strings.HasPrefix(key, prefix) {
+		return "", false
+	}
+	return filepath.Join(r.root, key[len(prefix):]), true
+}
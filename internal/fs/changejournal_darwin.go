@@ -0,0 +1,198 @@
+//go:build darwin && cgo
+
+package fs
+
+/*
+#cgo LDFLAGS: -framework CoreServices
+#include <CoreServices/CoreServices.h>
+#include <dispatch/dispatch.h>
+#include <stdlib.h>
+
+extern void resticFSEventsCallback(uintptr_t handle, size_t n, char **paths, FSEventStreamEventFlags *flags);
+
+static void fsevents_callback(ConstFSEventStreamRef stream, void *info, size_t n, void *paths,
+		const FSEventStreamEventFlags flags[], const FSEventStreamEventId ids[]) {
+	resticFSEventsCallback((uintptr_t)info, n, (char **)paths, (FSEventStreamEventFlags *)flags);
+}
+
+static FSEventStreamRef fsevents_create(uintptr_t handle, const char *path, FSEventStreamEventId since) {
+	CFStringRef str = CFStringCreateWithCString(NULL, path, kCFStringEncodingUTF8);
+	if (str == NULL) {
+		return NULL;
+	}
+	CFArrayRef paths = CFArrayCreate(NULL, (const void **)&str, 1, &kCFTypeArrayCallBacks);
+	CFRelease(str);
+	if (paths == NULL) {
+		return NULL;
+	}
+
+	FSEventStreamContext ctx = {0, (void *)handle, NULL, NULL, NULL};
+	FSEventStreamRef stream = FSEventStreamCreate(NULL, fsevents_callback, &ctx, paths, since, 0,
+		kFSEventStreamCreateFlagFileEvents | kFSEventStreamCreateFlagNoDefer);
+	CFRelease(paths);
+	return stream;
+}
+
+static int fsevents_start(FSEventStreamRef stream, dispatch_queue_t queue) {
+	FSEventStreamSetDispatchQueue(stream, queue);
+	return FSEventStreamStart(stream);
+}
+
+static void fsevents_stop(FSEventStreamRef stream) {
+	FSEventStreamStop(stream);
+	FSEventStreamInvalidate(stream);
+	FSEventStreamRelease(stream);
+}
+
+static dispatch_queue_t fsevents_queue(void) {
+	return dispatch_queue_create("restic.fsevents", DISPATCH_QUEUE_SERIAL);
+}
+
+static void fsevents_release_queue(dispatch_queue_t queue) {
+	dispatch_release(queue);
+}
+
+// fsevents_device_uuid writes the UUID of the event database of dev to buf.
+static int fsevents_device_uuid(dev_t dev, char *buf, CFIndex len) {
+	CFUUIDRef uuid = FSEventsCopyUUIDForDevice(dev);
+	if (uuid == NULL) {
+		return 0;
+	}
+	CFStringRef str = CFUUIDCreateString(NULL, uuid);
+	CFRelease(uuid);
+	if (str == NULL) {
+		return 0;
+	}
+	Boolean ok = CFStringGetCString(str, buf, len, kCFStringEncodingUTF8);
+	CFRelease(str);
+	return ok;
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"os"
+	"runtime/cgo"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+)
+
+// fseventsTimeout limits the time spent waiting for the historical events
+const fseventsTimeout = 10 * time.Minute
+
+// fsEvents reads changes from the FSEvents database of macOS
+type fsEvents struct{}
+
+// NewChangeJournal returns the change journal of the operating system.
+func NewChangeJournal() (ChangeJournal, error) {
+	return fsEvents{}, nil
+}
+
+// deviceUUID returns the UUID of the event database for the device containing
+// path. The UUID changes whenever the event IDs for the device are reset.
+func deviceUUID(path string) (string, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	stat, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", errors.Errorf("unable to determine device of %v", path)
+	}
+
+	buf := make([]byte, 64)
+	if C.fsevents_device_uuid(C.dev_t(stat.Dev), (*C.char)(unsafe.Pointer(&buf[0])), C.CFIndex(len(buf))) == 0 {
+		return "", errors.Errorf("FSEvents are not available for %v", path)
+	}
+	return C.GoString((*C.char)(unsafe.Pointer(&buf[0]))), nil
+}
+
+func (fsEvents) Position(root string) (string, error) {
+	uuid, err := deviceUUID(root)
+	if err != nil {
+		return "", err
+	}
+	id := uint64(C.FSEventsGetCurrentEventId())
+	return fmt.Sprintf("fsevents:%s:%d", uuid, id), nil
+}
+
+// fseventsCollector receives the events of a stream
+type fseventsCollector struct {
+	resolved resolvedRoot
+	changes  *ChangeSet
+	err      error
+	done     chan struct{}
+	finished bool
+}
+
+func (c *fseventsCollector) finish() {
+	c.finished = true
+	close(c.done)
+}
+
+func (fsEvents) Changes(root string, since string, changes *ChangeSet) error {
+	var uuid string
+	var id uint64
+	if _, err := fmt.Sscanf(since, "fsevents:%36s:%d", &uuid, &id); err != nil {
+		return fmt.Errorf("invalid journal position %q: %w", since, err)
+	}
+
+	current, err := deviceUUID(root)
+	if err != nil {
+		return err
+	}
+	if current != uuid {
+		return ErrChangeJournalPositionInvalid
+	}
+
+	resolved, err := newResolvedRoot(root)
+	if err != nil {
+		return err
+	}
+
+	c := &fseventsCollector{
+		resolved: resolved,
+		changes:  changes,
+		done:     make(chan struct{}),
+	}
+	handle := cgo.NewHandle(c)
+	defer handle.Delete()
+
+	cpath := C.CString(resolved.real)
+	defer C.free(unsafe.Pointer(cpath))
+
+	stream := C.fsevents_create(C.uintptr_t(handle), cpath, C.FSEventStreamEventId(id))
+	if stream == nil {
+		return errors.New("unable to create FSEvents stream")
+	}
+
+	// all callbacks are run on this serial queue
+	queue := C.fsevents_queue()
+	defer C.fsevents_release_queue(queue)
+
+	if C.fsevents_start(stream, queue) == 0 {
+		C.fsevents_stop(stream)
+		return errors.New("unable to start FSEvents stream")
+	}
+
+	select {
+	case <-c.done:
+	case <-time.After(fseventsTimeout):
+		debug.Log("timeout while reading FSEvents for %v", root)
+		C.fsevents_stop(stream)
+		return errors.New("timeout while reading FSEvents")
+	}
+	// stopping the stream waits for running callbacks to finish
+	C.fsevents_stop(stream)
+
+	if c.err != nil {
+		return c.err
+	}
+	changes.AddRoot(root)
+	return nil
+}
@@ -0,0 +1,57 @@
+//go:build darwin && cgo
+
+package fs
+
+// The exported callback is kept in a separate file, as the preamble of files
+// with exported functions must not contain any C definitions.
+
+/*
+#include <CoreServices/CoreServices.h>
+*/
+import "C"
+
+import (
+	"runtime/cgo"
+	"unsafe"
+)
+
+//export resticFSEventsCallback
+func resticFSEventsCallback(handle C.uintptr_t, n C.size_t, paths **C.char, flags *C.FSEventStreamEventFlags) {
+	c := cgo.Handle(handle).Value().(*fseventsCollector)
+	if c.finished {
+		return
+	}
+
+	pathList := unsafe.Slice(paths, int(n))
+	flagList := unsafe.Slice(flags, int(n))
+	for i := range pathList {
+		path := C.GoString(pathList[i])
+		flag := flagList[i]
+
+		if flag&(C.kFSEventStreamEventFlagUserDropped|C.kFSEventStreamEventFlagKernelDropped|
+			C.kFSEventStreamEventFlagEventIdsWrapped|C.kFSEventStreamEventFlagRootChanged) != 0 {
+			c.err = ErrChangeJournalPositionInvalid
+			c.finish()
+			return
+		}
+		if flag&C.kFSEventStreamEventFlagHistoryDone != 0 {
+			c.finish()
+			return
+		}
+
+		p, ok := c.resolved.translate(path)
+		if !ok {
+			continue
+		}
+
+		isDir := flag&C.kFSEventStreamEventFlagItemIsDir != 0
+		switch {
+		case flag&C.kFSEventStreamEventFlagMustScanSubDirs != 0:
+			c.changes.AddRecursive(p)
+		case isDir && flag&(C.kFSEventStreamEventFlagItemCreated|C.kFSEventStreamEventFlagItemRenamed) != 0:
+			c.changes.AddRecursive(p)
+		default:
+			c.changes.Add(p)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+//go:build darwin && !cgo
+
+package fs
+
+import "fmt"
+
+// NewChangeJournal returns the change journal of the operating system. Reading
+// FSEvents requires cgo, which the official release binaries are built without.
+func NewChangeJournal() (ChangeJournal, error) {
+	return nil, fmt.Errorf("%w: FSEvents is only available if restic was built with cgo enabled", ErrChangeJournalUnsupported)
+}
@@ -0,0 +1,67 @@
+package fs
+
+import (
+	"path/filepath"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestChangeSet(t *testing.T) {
+	root := fromSlashAbs("/home/user")
+	p := func(name string) string {
+		return filepath.Join(root, filepath.FromSlash(name))
+	}
+
+	var empty *ChangeSet
+	rtest.Assert(t, !empty.Unchanged(root), "nil change set must report changes")
+
+	c := NewChangeSet()
+	c.AddInheritedName(".resticignore")
+	c.Add(p("changed/sub/file"))
+	c.AddRecursive(p("moved"))
+	c.Add(p("ignored/.resticignore"))
+	rtest.Assert(t, !c.Unchanged(p("other")), "directory outside of a root must not be unchanged")
+	c.AddRoot(root)
+
+	for _, test := range []struct {
+		dir       string
+		unchanged bool
+	}{
+		{root, false},
+		{p("changed"), false},
+		{p("changed/sub"), false},
+		{p("changed/sub/file"), false},
+		{p("changed/other"), true},
+		{p("moved"), false},
+		{p("moved/sub"), false},
+		{p("ignored"), false},
+		{p("ignored/sub"), false},
+		{p("other"), true},
+		{p("other/sub"), true},
+		{fromSlashAbs("/home"), false},
+		{fromSlashAbs("/srv"), false},
+	} {
+		rtest.Equals(t, test.unchanged, c.Unchanged(test.dir), test.dir)
+	}
+}
+
+func TestResolvedRootTranslate(t *testing.T) {
+	r := resolvedRoot{root: fromSlashAbs("/tmp/data"), real: fromSlashAbs("/private/tmp/data")}
+
+	for _, test := range []struct {
+		path, result string
+		ok           bool
+	}{
+		{"/private/tmp/data", "/tmp/data", true},
+		{"/private/tmp/data/dir/file", "/tmp/data/dir/file", true},
+		{"/private/tmp/database", "", false},
+		{"/tmp/data/file", "", false},
+	} {
+		result, ok := r.translate(fromSlashAbs(test.path))
+		rtest.Equals(t, test.ok, ok, test.path)
+		if ok {
+			rtest.Equals(t, changeSetKey(fromSlashAbs(test.result)), changeSetKey(result), test.path)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !windows && !darwin
+
+package fs
+
+// NewChangeJournal returns the change journal of the operating system.
+func NewChangeJournal() (ChangeJournal, error) {
+	return nil, ErrChangeJournalUnsupported
+}
@@ -0,0 +1,308 @@
+//go:build windows
+
+package fs
+
+import (
+	"encoding/binary"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unsafe"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"golang.org/x/sys/windows"
+)
+
+const (
+	fsctlQueryUsnJournal = 0x000900f4
+	fsctlReadUsnJournal  = 0x000900bb
+
+	usnReasonFileCreate    = 0x00000100
+	usnReasonRenameNewName = 0x00002000
+
+	// size of the buffer used to read the journal
+	usnReadBufferSize = 1024 * 1024
+)
+
+var procOpenFileByID = windows.NewLazySystemDLL("kernel32.dll").NewProc("OpenFileById")
+
+// usnJournalData is USN_JOURNAL_DATA_V0
+type usnJournalData struct {
+	UsnJournalID    uint64
+	FirstUsn        int64
+	NextUsn         int64
+	LowestValidUsn  int64
+	MaxUsn          int64
+	MaximumSize     uint64
+	AllocationDelta uint64
+}
+
+// readUsnJournalData is READ_USN_JOURNAL_DATA_V0
+type readUsnJournalData struct {
+	StartUsn          int64
+	ReasonMask        uint32
+	ReturnOnlyOnClose uint32
+	Timeout           uint64
+	BytesToWaitFor    uint64
+	UsnJournalID      uint64
+}
+
+// fileIDDescriptor is FILE_ID_DESCRIPTOR with the FileIdType
+type fileIDDescriptor struct {
+	Size   uint32
+	Type   uint32
+	FileID [16]byte
+}
+
+// usnJournal reads changes from the NTFS USN journal. Opening the volume
+// requires administrator privileges.
+type usnJournal struct{}
+
+// NewChangeJournal returns the change journal of the operating system.
+func NewChangeJournal() (ChangeJournal, error) {
+	return usnJournal{}, nil
+}
+
+// openVolume opens the volume containing root.
+func openVolume(root string) (windows.Handle, error) {
+	rootPtr, err := windows.UTF16PtrFromString(fixpath(root))
+	if err != nil {
+		return windows.InvalidHandle, err
+	}
+
+	buf := make([]uint16, windows.MAX_LONG_PATH)
+	if err := windows.GetVolumePathName(rootPtr, &buf[0], uint32(len(buf))); err != nil {
+		return windows.InvalidHandle, fmt.Errorf("GetVolumePathName: %w", err)
+	}
+	mountPoint := &buf[0]
+
+	name := make([]uint16, windows.MAX_LONG_PATH)
+	if err := windows.GetVolumeNameForVolumeMountPoint(mountPoint, &name[0], uint32(len(name))); err != nil {
+		return windows.InvalidHandle, fmt.Errorf("GetVolumeNameForVolumeMountPoint: %w", err)
+	}
+
+	// the volume must be opened without trailing backslash
+	volume := strings.TrimSuffix(windows.UTF16ToString(name), `\`)
+	volumePtr, err := windows.UTF16PtrFromString(volume)
+	if err != nil {
+		return windows.InvalidHandle, err
+	}
+
+	h, err := windows.CreateFile(volumePtr, windows.GENERIC_READ,
+		windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE,
+		nil, windows.OPEN_EXISTING, 0, 0)
+	if err != nil {
+		return windows.InvalidHandle, fmt.Errorf("open volume %v: %w", volume, err)
+	}
+	return h, nil
+}
+
+func queryUsnJournal(volume windows.Handle) (usnJournalData, error) {
+	var data usnJournalData
+	var n uint32
+	err := windows.DeviceIoControl(volume, fsctlQueryUsnJournal, nil, 0,
+		(*byte)(unsafe.Pointer(&data)), uint32(unsafe.Sizeof(data)), &n, nil)
+	if err != nil {
+		return usnJournalData{}, fmt.Errorf("query USN journal: %w", err)
+	}
+	return data, nil
+}
+
+func (usnJournal) Position(root string) (string, error) {
+	volume, err := openVolume(root)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = windows.CloseHandle(volume)
+	}()
+
+	data, err := queryUsnJournal(volume)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("usn:%x:%d", data.UsnJournalID, data.NextUsn), nil
+}
+
+func (usnJournal) Changes(root string, since string, changes *ChangeSet) error {
+	var journalID uint64
+	var startUsn int64
+	if _, err := fmt.Sscanf(since, "usn:%x:%d", &journalID, &startUsn); err != nil {
+		return fmt.Errorf("invalid journal position %q: %w", since, err)
+	}
+
+	resolved, err := newResolvedRoot(root)
+	if err != nil {
+		return err
+	}
+
+	volume, err := openVolume(root)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = windows.CloseHandle(volume)
+	}()
+
+	data, err := queryUsnJournal(volume)
+	if err != nil {
+		return err
+	}
+	if data.UsnJournalID != journalID || startUsn < data.LowestValidUsn || startUsn > data.NextUsn {
+		return ErrChangeJournalPositionInvalid
+	}
+
+	r := &usnReader{
+		volume:  volume,
+		parents: make(map[uint64]string),
+	}
+
+	req := readUsnJournalData{
+		StartUsn:     startUsn,
+		ReasonMask:   0xffffffff,
+		UsnJournalID: journalID,
+	}
+	buf := make([]byte, usnReadBufferSize)
+
+	// only read the changes up to the current end of the journal
+	for req.StartUsn < data.NextUsn {
+		var n uint32
+		err := windows.DeviceIoControl(volume, fsctlReadUsnJournal,
+			(*byte)(unsafe.Pointer(&req)), uint32(unsafe.Sizeof(req)),
+			&buf[0], uint32(len(buf)), &n, nil)
+		if errors.Is(err, windows.ERROR_JOURNAL_ENTRY_DELETED) {
+			return ErrChangeJournalPositionInvalid
+		}
+		if err != nil {
+			return fmt.Errorf("read USN journal: %w", err)
+		}
+		if n < 8 {
+			return errors.New("read USN journal: short read")
+		}
+
+		next := int64(binary.LittleEndian.Uint64(buf))
+		if next <= req.StartUsn {
+			break
+		}
+
+		for records := buf[8:n]; len(records) > 0; {
+			length, err := r.processRecord(records, resolved, changes)
+			if err != nil {
+				return err
+			}
+			records = records[length:]
+		}
+
+		req.StartUsn = next
+	}
+
+	changes.AddRoot(root)
+	return nil
+}
+
+// usnReader resolves the records of the journal to paths.
+type usnReader struct {
+	volume windows.Handle
+	// cache of paths of parent directories, empty if a directory no longer exists
+	parents map[uint64]string
+}
+
+// processRecord adds the path of the USN_RECORD_V2 at the start of buf to
+// changes and returns the length of the record.
+func (r *usnReader) processRecord(buf []byte, resolved resolvedRoot, changes *ChangeSet) (int, error) {
+	if len(buf) < 60 {
+		return 0, errors.New("read USN journal: truncated record")
+	}
+	length := int(binary.LittleEndian.Uint32(buf[0:]))
+	major := binary.LittleEndian.Uint16(buf[4:])
+	if length < 60 || length > len(buf) {
+		return 0, errors.New("read USN journal: invalid record length")
+	}
+	if major != 2 {
+		return 0, fmt.Errorf("read USN journal: unsupported record version %d", major)
+	}
+
+	parent := binary.LittleEndian.Uint64(buf[16:])
+	reason := binary.LittleEndian.Uint32(buf[40:])
+	attrs := binary.LittleEndian.Uint32(buf[52:])
+	nameLength := int(binary.LittleEndian.Uint16(buf[56:]))
+	nameOffset := int(binary.LittleEndian.Uint16(buf[58:]))
+	if nameOffset+nameLength > length {
+		return 0, errors.New("read USN journal: invalid file name")
+	}
+
+	name := make([]uint16, nameLength/2)
+	for i := range name {
+		name[i] = binary.LittleEndian.Uint16(buf[nameOffset+2*i:])
+	}
+
+	dir, err := r.resolve(parent)
+	if err != nil {
+		return 0, err
+	}
+	if dir == "" {
+		// the parent directory was deleted, which is recorded separately
+		return length, nil
+	}
+
+	path, ok := resolved.translate(filepath.Join(dir, windows.UTF16ToString(name)))
+	if !ok {
+		return length, nil
+	}
+
+	if attrs&windows.FILE_ATTRIBUTE_DIRECTORY != 0 && reason&(usnReasonFileCreate|usnReasonRenameNewName) != 0 {
+		changes.AddRecursive(path)
+	} else {
+		changes.Add(path)
+	}
+	return length, nil
+}
+
+// resolve returns the current path of the directory with the given file
+// reference number, or an empty string if it no longer exists.
+func (r *usnReader) resolve(frn uint64) (string, error) {
+	if path, ok := r.parents[frn]; ok {
+		return path, nil
+	}
+
+	desc := fileIDDescriptor{Size: uint32(unsafe.Sizeof(fileIDDescriptor{}))}
+	binary.LittleEndian.PutUint64(desc.FileID[:], frn)
+
+	res, _, err := procOpenFileByID.Call(uintptr(r.volume), uintptr(unsafe.Pointer(&desc)), 0,
+		windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE, 0,
+		windows.FILE_FLAG_BACKUP_SEMANTICS)
+	h := windows.Handle(res)
+	if h == windows.InvalidHandle {
+		if errors.Is(err, windows.ERROR_INVALID_PARAMETER) || errors.Is(err, windows.ERROR_FILE_NOT_FOUND) ||
+			errors.Is(err, windows.ERROR_PATH_NOT_FOUND) {
+			debug.Log("directory with file reference number %x no longer exists", frn)
+			r.parents[frn] = ""
+			return "", nil
+		}
+		return "", fmt.Errorf("OpenFileById: %w", err)
+	}
+	defer func() {
+		_ = windows.CloseHandle(h)
+	}()
+
+	buf := make([]uint16, windows.MAX_LONG_PATH)
+	n, err := windows.GetFinalPathNameByHandle(h, &buf[0], uint32(len(buf)), 0)
+	if err != nil {
+		return "", fmt.Errorf("GetFinalPathNameByHandle: %w", err)
+	}
+	if int(n) > len(buf) {
+		return "", errors.New("GetFinalPathNameByHandle: path too long")
+	}
+
+	path := windows.UTF16ToString(buf[:n])
+	switch {
+	case strings.HasPrefix(path, uncPathPrefix):
+		path = `\\` + path[len(uncPathPrefix):]
+	case strings.HasPrefix(path, extendedPathPrefix):
+		path = path[len(extendedPathPrefix):]
+	}
+
+	r.parents[frn] = path
+	return path, nil
+}
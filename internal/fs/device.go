@@ -0,0 +1,30 @@
+package fs
+
+import (
+	"io"
+	"os"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+// IsBlockDevice returns true if mode describes a block device.
+func IsBlockDevice(mode os.FileMode) bool {
+	return mode&os.ModeDevice != 0 && mode&os.ModeCharDevice == 0
+}
+
+// DeviceSize returns the size of the block device name in bytes. As stat does
+// not report the size of block devices, the size is determined by seeking to
+// the end of the device.
+func DeviceSize(name string) (int64, error) {
+	f, err := os.Open(fixpath(name))
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		_ = f.Close()
+		return 0, errors.Wrap(err, "Seek")
+	}
+	return size, errors.WithStack(f.Close())
+}
@@ -0,0 +1,29 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/restic/restic/internal/errors"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestDeviceSize(t *testing.T) {
+	// the size is determined in the same way for regular files
+	filename := filepath.Join(t.TempDir(), "image")
+	rtest.OK(t, os.WriteFile(filename, make([]byte, 12345), 0600))
+
+	size, err := DeviceSize(filename)
+	rtest.OK(t, err)
+	rtest.Equals(t, int64(12345), size)
+
+	_, err = DeviceSize(filepath.Join(t.TempDir(), "missing"))
+	rtest.Assert(t, errors.Is(err, os.ErrNotExist), "unexpected error %v", err)
+}
+
+func TestIsBlockDevice(t *testing.T) {
+	rtest.Assert(t, IsBlockDevice(os.ModeDevice|0600), "block device not detected")
+	rtest.Assert(t, !IsBlockDevice(os.ModeDevice|os.ModeCharDevice|0600), "character device detected as block device")
+	rtest.Assert(t, !IsBlockDevice(0600), "regular file detected as block device")
+}
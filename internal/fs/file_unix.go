@@ -13,6 +13,12 @@ func fixpath(name string) string {
 	return name
 }
 
+// IsAlternateDataStream returns false, alternate data streams only exist on
+// Windows.
+func IsAlternateDataStream(_ string) bool {
+	return false
+}
+
 // isNotSupported returns true if the error is caused by an unsupported file system feature.
 func isNotSupported(err error) bool {
 	if perr, ok := err.(*os.PathError); ok && perr.Err == syscall.ENOTSUP {
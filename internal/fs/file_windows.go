@@ -41,6 +41,12 @@ func fixpath(name string) string {
 	return name
 }
 
+// IsAlternateDataStream returns true if name refers to an alternate data
+// stream of a file, for example "file.txt:stream".
+func IsAlternateDataStream(name string) bool {
+	return strings.Contains(name, ":")
+}
+
 // Chmod changes the mode of the named file to mode.
 func chmod(name string, mode os.FileMode) error {
 	return os.Chmod(fixpath(name), mode)
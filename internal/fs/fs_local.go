@@ -14,14 +14,29 @@ func init() {
 	}
 }
 
+// LocalOptions configures how the local file system is accessed.
+type LocalOptions struct {
+	// DropPageCache advises the kernel to drop the contents of files from the
+	// page cache once they were read. This is only supported on Linux.
+	DropPageCache bool
+}
+
 // local is the local file system. Most methods are just passed on to the stdlib.
-type local struct{}
+type local struct {
+	opts LocalOptions
+}
 
 // NewLocal returns an FS for the local file system. Most methods are just passed on to the stdlib.
 func NewLocal() FS {
 	return local{}
 }
 
+// NewLocalWithOptions returns an FS for the local file system which accesses
+// files as configured by opts.
+func NewLocalWithOptions(opts LocalOptions) FS {
+	return local{opts: opts}
+}
+
 // statically ensure that local implements FS.
 var _ FS = &local{}
 
@@ -41,7 +56,7 @@ func (fs local) VolumeName(path string) string {
 //
 // Only the O_NOFOLLOW and O_DIRECTORY flags are supported.
 func (fs local) OpenFile(name string, flag int, metadataOnly bool) (File, error) {
-	return newLocalFile(name, flag, metadataOnly)
+	return newLocalFile(name, flag, metadataOnly, fs.opts)
 }
 
 // Lstat returns the FileInfo structure describing the named file.
@@ -102,12 +117,22 @@ type localFile struct {
 	flag int
 	f    *os.File
 	fi   *ExtendedFileInfo
+	opts LocalOptions
+
+	// pos is the current offset in the file, the page cache has been dropped
+	// up to offset dropped.
+	pos     int64
+	dropped int64
 }
 
+// dropPageCacheInterval is the amount of data read from a file after which
+// it is dropped from the page cache.
+const dropPageCacheInterval = 8 * 1024 * 1024
+
 // See the File interface for a description of each method
 var _ File = &localFile{}
 
-func newLocalFile(name string, flag int, metadataOnly bool) (*localFile, error) {
+func newLocalFile(name string, flag int, metadataOnly bool, opts LocalOptions) (*localFile, error) {
 	var f *os.File
 	if !metadataOnly {
 		var err error
@@ -121,6 +146,7 @@ func newLocalFile(name string, flag int, metadataOnly bool) (*localFile, error)
 		name: name,
 		flag: flag,
 		f:    f,
+		opts: opts,
 	}, nil
 }
 
@@ -129,7 +155,7 @@ func (f *localFile) MakeReadable() error {
 		panic("file is already readable")
 	}
 
-	newF, err := newLocalFile(f.name, f.flag, false)
+	newF, err := newLocalFile(f.name, f.flag, false, f.opts)
 	if err != nil {
 		return err
 	}
@@ -172,7 +198,25 @@ func (f *localFile) ToNode(ignoreXattrListError bool, warnf func(format string,
 }
 
 func (f *localFile) Read(p []byte) (n int, err error) {
-	return f.f.Read(p)
+	n, err = f.f.Read(p)
+	if f.opts.DropPageCache {
+		f.pos += int64(n)
+		if f.pos-f.dropped >= dropPageCacheInterval {
+			_ = dropPageCache(f.f, f.dropped, f.pos-f.dropped)
+			f.dropped = f.pos
+		}
+	}
+	return n, err
+}
+
+// Seek sets the offset for the next Read on the file.
+func (f *localFile) Seek(offset int64, whence int) (int64, error) {
+	pos, err := f.f.Seek(offset, whence)
+	if err == nil {
+		f.pos = pos
+		f.dropped = pos
+	}
+	return pos, err
 }
 
 func (f *localFile) Readdirnames(n int) ([]string, error) {
@@ -181,6 +225,10 @@ func (f *localFile) Readdirnames(n int) ([]string, error) {
 
 func (f *localFile) Close() error {
 	if f.f != nil {
+		if f.opts.DropPageCache {
+			// drop the whole file, this also covers ranges skipped by Seek
+			_ = dropPageCache(f.f, 0, 0)
+		}
 		return f.f.Close()
 	}
 	return nil
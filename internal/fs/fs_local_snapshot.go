@@ -0,0 +1,437 @@
+package fs
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/restic/restic/internal/data"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+)
+
+// btrfs uses the inode number 256 for the root directory of a subvolume.
+const btrfsSubvolumeRootInode = 256
+
+// commandRunner runs a command and returns its standard output.
+type commandRunner func(name string, args ...string) (string, error)
+
+func runCommand(name string, args ...string) (string, error) {
+	debug.Log("running %v %v", name, args)
+	cmd := exec.Command(name, args...)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg != "" {
+			return "", errors.Errorf("%v %v failed: %v: %v", name, strings.Join(args, " "), err, msg)
+		}
+		return "", errors.Errorf("%v %v failed: %v", name, strings.Join(args, " "), err)
+	}
+	return string(out), nil
+}
+
+// fsSnapshot is a read-only snapshot whose content replaces the directory
+// mountpoint. If path is empty, the files below mountpoint are read from the
+// live filesystem.
+type fsSnapshot struct {
+	info       data.FilesystemSnapshot
+	mountpoint string
+	path       string
+	// device is the device ID of mountpoint on the live filesystem
+	device uint64
+	delete func() error
+}
+
+// LocalFsSnapshot is a wrapper around the local file system, which reads files
+// from temporary read-only snapshots of btrfs subvolumes and ZFS datasets.
+// The snapshots are created for the backup targets when the wrapper is created
+// and the paths are translated transparently.
+type LocalFsSnapshot struct {
+	FS
+	msgError   ErrorHandler
+	msgMessage MessageHandler
+	run        commandRunner
+	// suffix is appended to the name of all snapshots
+	suffix string
+
+	mutex sync.RWMutex
+	// snapshots is sorted by the length of the mountpoint, longest first
+	snapshots []*fsSnapshot
+}
+
+// statically ensure that LocalFsSnapshot implements FS.
+var _ FS = &LocalFsSnapshot{}
+
+// NewLocalFsSnapshot creates read-only snapshots of the btrfs subvolumes and
+// ZFS datasets which contain the targets. Targets on other filesystems, or for
+// which no snapshot could be created, are read from the live filesystem.
+func NewLocalFsSnapshot(base FS, targets []string, msgError ErrorHandler, msgMessage MessageHandler) *LocalFsSnapshot {
+	fs := newLocalFsSnapshot(base, runCommand, msgError, msgMessage)
+
+	var abs []string
+	for _, target := range targets {
+		p, err := filepath.Abs(target)
+		if err != nil {
+			msgError(target, err)
+			continue
+		}
+		abs = append(abs, p)
+	}
+	// a recursive ZFS snapshot of a parent dataset must be created before
+	// the snapshots of its children, otherwise the snapshot names collide
+	sort.Strings(abs)
+	for _, target := range abs {
+		fs.snapshotTarget(target)
+	}
+	return fs
+}
+
+func newLocalFsSnapshot(base FS, run commandRunner, msgError ErrorHandler, msgMessage MessageHandler) *LocalFsSnapshot {
+	var buf [4]byte
+	_, _ = rand.Read(buf[:])
+	return &LocalFsSnapshot{
+		FS:         base,
+		msgError:   msgError,
+		msgMessage: msgMessage,
+		run:        run,
+		suffix:     hex.EncodeToString(buf[:]),
+	}
+}
+
+// snapshotTarget creates a snapshot for target unless it is already contained
+// in a snapshot.
+func (fs *LocalFsSnapshot) snapshotTarget(target string) {
+	if sn := fs.lookup(target); sn != nil {
+		fi, err := fs.FS.Lstat(target)
+		if err != nil || fi.DeviceID == sn.device {
+			return
+		}
+		// the target is located on a different filesystem or subvolume
+	}
+
+	info, err := FilesystemType(target)
+	if err != nil {
+		fs.msgError(target, err)
+		return
+	}
+
+	var sn *fsSnapshot
+	switch info.Type {
+	case "btrfs":
+		var root string
+		root, err = fs.btrfsSubvolumeRoot(target)
+		if err == nil {
+			sn, err = fs.createBtrfsSnapshot(root)
+		}
+	case "zfs":
+		err = fs.createZFSSnapshots(target)
+	default:
+		fs.msgMessage("%v is not located on btrfs or ZFS, reading from the live filesystem\n", target)
+		return
+	}
+	if err != nil {
+		fs.msgError(target, errors.Errorf("failed to create %v snapshot: %v", info.Type, err))
+		return
+	}
+	if sn != nil {
+		fs.add(sn)
+	}
+}
+
+// add registers a snapshot, the caller must not hold the mutex.
+func (fs *LocalFsSnapshot) add(sn *fsSnapshot) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	fs.addLocked(sn)
+}
+
+func (fs *LocalFsSnapshot) addLocked(sn *fsSnapshot) {
+	if sn.info.Name != "" {
+		fs.msgMessage("created %v snapshot %v of %v\n", sn.info.Type, sn.info.Name, sn.mountpoint)
+	}
+	fs.snapshots = append(fs.snapshots, sn)
+	sort.SliceStable(fs.snapshots, func(i, j int) bool {
+		return len(fs.snapshots[i].mountpoint) > len(fs.snapshots[j].mountpoint)
+	})
+}
+
+// lookup returns the snapshot which contains name, or nil.
+func (fs *LocalFsSnapshot) lookup(name string) *fsSnapshot {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+	return fs.lookupLocked(name)
+}
+
+func (fs *LocalFsSnapshot) lookupLocked(name string) *fsSnapshot {
+	for _, sn := range fs.snapshots {
+		if HasPathPrefix(sn.mountpoint, name) {
+			return sn
+		}
+	}
+	return nil
+}
+
+// snapshotPath returns the path of name within the snapshot which contains it.
+func (fs *LocalFsSnapshot) snapshotPath(name string) (string, *fsSnapshot) {
+	name = filepath.Clean(name)
+	sn := fs.lookup(name)
+	if sn == nil || sn.path == "" {
+		return name, nil
+	}
+	rel, err := filepath.Rel(sn.mountpoint, name)
+	if err != nil {
+		return name, nil
+	}
+	return filepath.Join(sn.path, rel), sn
+}
+
+// OpenFile wraps the OpenFile method of the underlying file system.
+func (fs *LocalFsSnapshot) OpenFile(name string, flag int, metadataOnly bool) (File, error) {
+	p, _ := fs.snapshotPath(name)
+	return fs.FS.OpenFile(p, flag, metadataOnly)
+}
+
+// Lstat wraps the Lstat method of the underlying file system. Directories at
+// which another filesystem or subvolume is mounted are empty within a
+// snapshot. For these, a new snapshot is created or the live filesystem is
+// used.
+func (fs *LocalFsSnapshot) Lstat(name string) (*ExtendedFileInfo, error) {
+	name = filepath.Clean(name)
+	p, sn := fs.snapshotPath(name)
+	fi, err := fs.FS.Lstat(p)
+	if err == nil && sn != nil && fi.Mode.IsDir() && p != sn.path && !fs.isSnapshotPath(name) {
+		live, liveErr := fs.FS.Lstat(name)
+		if liveErr == nil && live.DeviceID != sn.device {
+			fs.mountpointInSnapshot(name, live)
+			p, _ = fs.snapshotPath(name)
+			fi, err = fs.FS.Lstat(p)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// the root directory of a snapshot has a different name
+	fi.Name = filepath.Base(name)
+	return fi, nil
+}
+
+// isSnapshotPath returns true if name is the directory of a btrfs snapshot
+// created by this wrapper. Within the snapshot, it is an empty directory.
+func (fs *LocalFsSnapshot) isSnapshotPath(name string) bool {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+	for _, sn := range fs.snapshots {
+		if sn.path == name {
+			return true
+		}
+	}
+	return false
+}
+
+// mountpointInSnapshot handles a directory within a snapshot at which another
+// filesystem or a nested btrfs subvolume is mounted.
+func (fs *LocalFsSnapshot) mountpointInSnapshot(name string, live *ExtendedFileInfo) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	if sn := fs.lookupLocked(name); sn != nil && sn.mountpoint == name {
+		// already handled concurrently
+		return
+	}
+
+	info, err := FilesystemType(name)
+	if err == nil && info.Type == "btrfs" && live.Inode == btrfsSubvolumeRootInode {
+		sn, err := fs.createBtrfsSnapshot(name)
+		if err == nil {
+			fs.addLocked(sn)
+			return
+		}
+		fs.msgError(name, errors.Errorf("failed to create btrfs snapshot: %v", err))
+	}
+
+	fs.msgMessage("%v is a mountpoint without snapshot, reading from the live filesystem\n", name)
+	fs.addLocked(&fsSnapshot{mountpoint: name, device: live.DeviceID})
+}
+
+// btrfsSubvolumeRoot returns the root directory of the subvolume which
+// contains path. All files of a subvolume share the same device ID.
+func (fs *LocalFsSnapshot) btrfsSubvolumeRoot(path string) (string, error) {
+	fi, err := fs.FS.Lstat(path)
+	if err != nil {
+		return "", err
+	}
+	if !fi.Mode.IsDir() {
+		path = filepath.Dir(path)
+	}
+
+	device := fi.DeviceID
+	for {
+		fi, err := fs.FS.Lstat(path)
+		if err != nil {
+			return "", err
+		}
+		if fi.DeviceID == device && fi.Inode == btrfsSubvolumeRootInode {
+			return path, nil
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return "", errors.Errorf("no btrfs subvolume found for %v", path)
+		}
+		path = parent
+	}
+}
+
+// createBtrfsSnapshot creates a read-only snapshot of the subvolume at root.
+// The snapshot is stored in the subvolume itself and does not contain itself.
+func (fs *LocalFsSnapshot) createBtrfsSnapshot(root string) (*fsSnapshot, error) {
+	fi, err := fs.FS.Lstat(root)
+	if err != nil {
+		return nil, err
+	}
+
+	dst := filepath.Join(root, ".restic-snapshot-"+fs.suffix)
+	if _, err := fs.run("btrfs", "subvolume", "snapshot", "-r", root, dst); err != nil {
+		return nil, err
+	}
+
+	sn := &fsSnapshot{
+		info: data.FilesystemSnapshot{
+			Type:   "btrfs",
+			Source: root,
+			Path:   root,
+			Name:   dst,
+		},
+		mountpoint: root,
+		path:       dst,
+		device:     fi.DeviceID,
+		delete: func() error {
+			_, err := fs.run("btrfs", "subvolume", "delete", dst)
+			return err
+		},
+	}
+
+	out, err := fs.run("btrfs", "subvolume", "show", dst)
+	if err != nil {
+		fs.msgError(dst, err)
+		return sn, nil
+	}
+	for _, line := range strings.Split(out, "\n") {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(line), "UUID:"); ok {
+			sn.info.GUID = strings.TrimSpace(value)
+			break
+		}
+	}
+	return sn, nil
+}
+
+// createZFSSnapshots recursively snapshots the dataset which contains target
+// and registers the snapshots of all mounted datasets.
+func (fs *LocalFsSnapshot) createZFSSnapshots(target string) error {
+	out, err := fs.run("zfs", "list", "-H", "-o", "name", target)
+	if err != nil {
+		return err
+	}
+	dataset := strings.TrimSpace(out)
+	if dataset == "" {
+		return errors.Errorf("no ZFS dataset found for %v", target)
+	}
+
+	name := "restic-" + fs.suffix
+	snapshot := dataset + "@" + name
+	if _, err := fs.run("zfs", "snapshot", "-r", snapshot); err != nil {
+		return err
+	}
+	remove := func() error {
+		_, err := fs.run("zfs", "destroy", "-r", snapshot)
+		return err
+	}
+
+	out, err = fs.run("zfs", "list", "-H", "-t", "filesystem", "-o", "name,mountpoint,mounted", "-r", dataset)
+	if err != nil {
+		if err := remove(); err != nil {
+			fs.msgError(snapshot, err)
+		}
+		return err
+	}
+
+	datasets := out
+
+	var guid string
+	if out, err := fs.run("zfs", "get", "-H", "-p", "-o", "value", "guid", snapshot); err != nil {
+		fs.msgError(snapshot, err)
+	} else {
+		guid = strings.TrimSpace(out)
+	}
+
+	for _, line := range strings.Split(strings.TrimSpace(datasets), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 3 || fields[2] != "yes" || !filepath.IsAbs(fields[1]) {
+			// the dataset is not mounted or uses a legacy mountpoint
+			continue
+		}
+		mountpoint := fields[1]
+		fi, err := fs.FS.Lstat(mountpoint)
+		if err != nil {
+			fs.msgError(mountpoint, err)
+			continue
+		}
+
+		sn := &fsSnapshot{
+			info: data.FilesystemSnapshot{
+				Type:   "zfs",
+				Source: fields[0],
+				Path:   mountpoint,
+				Name:   fields[0] + "@" + name,
+			},
+			mountpoint: mountpoint,
+			path:       filepath.Join(mountpoint, ".zfs", "snapshot", name),
+			device:     fi.DeviceID,
+		}
+		if fields[0] == dataset {
+			// the recursive snapshot is removed at once
+			sn.info.GUID = guid
+			sn.delete = remove
+		}
+		fs.add(sn)
+	}
+	return nil
+}
+
+// Snapshots returns the filesystem snapshots which were created.
+func (fs *LocalFsSnapshot) Snapshots() []data.FilesystemSnapshot {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+
+	var list []data.FilesystemSnapshot
+	for _, sn := range fs.snapshots {
+		if sn.delete != nil {
+			list = append(list, sn.info)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Path < list[j].Path })
+	return list
+}
+
+// DeleteSnapshots deletes all snapshots that were created.
+func (fs *LocalFsSnapshot) DeleteSnapshots() {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	var remaining []*fsSnapshot
+	for _, sn := range fs.snapshots {
+		if sn.delete == nil {
+			continue
+		}
+		if err := sn.delete(); err != nil {
+			fs.msgError(sn.info.Name, errors.Errorf("failed to delete %v snapshot: %v", sn.info.Type, err))
+			remaining = append(remaining, sn)
+		}
+	}
+	fs.snapshots = remaining
+}
@@ -0,0 +1,151 @@
+package fs
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/restic/restic/internal/data"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+type fakeCommands struct {
+	calls   []string
+	outputs map[string]string
+	// hook is called for each command before the output is returned
+	hook func(args []string)
+}
+
+func (c *fakeCommands) run(name string, args ...string) (string, error) {
+	cmd := strings.Join(append([]string{name}, args...), " ")
+	c.calls = append(c.calls, cmd)
+	if c.hook != nil {
+		c.hook(append([]string{name}, args...))
+	}
+	for prefix, out := range c.outputs {
+		if strings.HasPrefix(cmd, prefix) {
+			return out, nil
+		}
+	}
+	return "", nil
+}
+
+func readFSFile(t *testing.T, fs FS, name string) string {
+	f, err := fs.OpenFile(name, O_RDONLY, false)
+	rtest.OK(t, err)
+	buf, err := io.ReadAll(f)
+	rtest.OK(t, err)
+	rtest.OK(t, f.Close())
+	return string(buf)
+}
+
+func TestLocalFsSnapshotZFS(t *testing.T) {
+	tempdir := t.TempDir()
+	live := filepath.Join(tempdir, "tank")
+	rtest.OK(t, os.MkdirAll(filepath.Join(live, "dir"), 0o700))
+	rtest.OK(t, os.WriteFile(filepath.Join(live, "dir", "file"), []byte("live"), 0o600))
+
+	cmds := &fakeCommands{
+		outputs: map[string]string{
+			"zfs list -H -o name ":    "pool/tank\n",
+			"zfs list -H -t":          "pool/tank\t" + live + "\tyes\npool/tank/legacy\tlegacy\tyes\npool/tank/off\t" + live + "/off\tno\n",
+			"zfs get -H -p -o value ": "1234567890\n",
+		},
+	}
+	fs := newLocalFsSnapshot(NewLocal(), cmds.run, func(item string, err error) { t.Errorf("%v: %v", item, err) }, func(string, ...interface{}) {})
+	fs.suffix = "test"
+
+	// the snapshot is available in the .zfs directory of the dataset
+	snapshotDir := filepath.Join(live, ".zfs", "snapshot", "restic-test")
+	rtest.OK(t, os.MkdirAll(filepath.Join(snapshotDir, "dir"), 0o700))
+	rtest.OK(t, os.WriteFile(filepath.Join(snapshotDir, "dir", "file"), []byte("snapshot"), 0o600))
+
+	rtest.OK(t, fs.createZFSSnapshots(filepath.Join(live, "dir")))
+	rtest.Equals(t, []string{
+		"zfs list -H -o name " + filepath.Join(live, "dir"),
+		"zfs snapshot -r pool/tank@restic-test",
+		"zfs list -H -t filesystem -o name,mountpoint,mounted -r pool/tank",
+		"zfs get -H -p -o value guid pool/tank@restic-test",
+	}, cmds.calls)
+
+	rtest.Equals(t, "snapshot", readFSFile(t, fs, filepath.Join(live, "dir", "file")))
+	fi, err := fs.Lstat(live)
+	rtest.OK(t, err)
+	rtest.Equals(t, "tank", fi.Name)
+
+	rtest.Equals(t, []data.FilesystemSnapshot{{
+		Type:   "zfs",
+		Source: "pool/tank",
+		Path:   live,
+		Name:   "pool/tank@restic-test",
+		GUID:   "1234567890",
+	}}, fs.Snapshots())
+
+	cmds.calls = nil
+	fs.DeleteSnapshots()
+	rtest.Equals(t, []string{"zfs destroy -r pool/tank@restic-test"}, cmds.calls)
+	rtest.Equals(t, 0, len(fs.Snapshots()))
+}
+
+func TestLocalFsSnapshotBtrfs(t *testing.T) {
+	root := t.TempDir()
+	rtest.OK(t, os.WriteFile(filepath.Join(root, "file"), []byte("live"), 0o600))
+
+	cmds := &fakeCommands{
+		outputs: map[string]string{
+			"btrfs subvolume show": "home/.restic-snapshot-test\n\tName: \t\t\t.restic-snapshot-test\n" +
+				"\tUUID: \t\t\t3b3e2f1c-0000-4e6e-9a1d-6d5b2c4f0a11\n" +
+				"\tParent UUID: \t\t99999999-0000-0000-0000-000000000000\n",
+		},
+		hook: func(args []string) {
+			if len(args) == 6 && args[2] == "snapshot" {
+				// simulate the snapshot by copying the file
+				rtest.OK(t, os.Mkdir(args[5], 0o700))
+				rtest.OK(t, os.WriteFile(filepath.Join(args[5], "file"), []byte("snapshot"), 0o600))
+			}
+		},
+	}
+	fs := newLocalFsSnapshot(NewLocal(), cmds.run, func(item string, err error) { t.Errorf("%v: %v", item, err) }, func(string, ...interface{}) {})
+	fs.suffix = "test"
+
+	sn, err := fs.createBtrfsSnapshot(root)
+	rtest.OK(t, err)
+	fs.add(sn)
+
+	dst := filepath.Join(root, ".restic-snapshot-test")
+	rtest.Equals(t, []string{
+		"btrfs subvolume snapshot -r " + root + " " + dst,
+		"btrfs subvolume show " + dst,
+	}, cmds.calls)
+	rtest.Equals(t, "3b3e2f1c-0000-4e6e-9a1d-6d5b2c4f0a11", sn.info.GUID)
+	rtest.Equals(t, "snapshot", readFSFile(t, fs, filepath.Join(root, "file")))
+
+	cmds.calls = nil
+	fs.DeleteSnapshots()
+	rtest.Equals(t, []string{"btrfs subvolume delete " + dst}, cmds.calls)
+}
+
+func TestLocalFsSnapshotOtherFilesystem(t *testing.T) {
+	tempdir := t.TempDir()
+	rtest.OK(t, os.WriteFile(filepath.Join(tempdir, "file"), []byte("live"), 0o600))
+
+	info, err := FilesystemType(tempdir)
+	rtest.OK(t, err)
+	if info.Type == "btrfs" || info.Type == "zfs" {
+		t.Skipf("test requires a filesystem without snapshots, found %v", info.Type)
+	}
+
+	cmds := &fakeCommands{}
+	var messages []string
+	fs := newLocalFsSnapshot(NewLocal(), cmds.run, func(item string, err error) { t.Errorf("%v: %v", item, err) }, func(msg string, args ...interface{}) {
+		messages = append(messages, msg)
+	})
+	fs.snapshotTarget(tempdir)
+
+	// the files are read from the live filesystem
+	rtest.Equals(t, 0, len(cmds.calls))
+	rtest.Equals(t, 1, len(messages))
+	rtest.Equals(t, "live", readFSFile(t, fs, filepath.Join(tempdir, "file")))
+}
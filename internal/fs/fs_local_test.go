@@ -134,6 +134,34 @@ func openReadable(t *testing.T, path string, useMakeReadable bool) File {
 	return f
 }
 
+func TestFSLocalReadDropPageCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "item")
+	testdata := rtest.Random(23, 2*dropPageCacheInterval+123)
+	rtest.OK(t, os.WriteFile(path, testdata, 0o600))
+
+	for _, makeReadable := range []bool{false, true} {
+		testFs := NewLocalWithOptions(LocalOptions{DropPageCache: true})
+		f, err := testFs.OpenFile(path, O_NOFOLLOW, makeReadable)
+		rtest.OK(t, err)
+		if makeReadable {
+			rtest.OK(t, f.MakeReadable())
+		}
+
+		data, err := io.ReadAll(f)
+		rtest.OK(t, err)
+		rtest.Equals(t, testdata, data, "file content mismatch")
+
+		// reading again after seeking must not be affected
+		_, err = f.(io.Seeker).Seek(dropPageCacheInterval/2, io.SeekStart)
+		rtest.OK(t, err)
+		data, err = io.ReadAll(f)
+		rtest.OK(t, err)
+		rtest.Equals(t, testdata[dropPageCacheInterval/2:], data, "file content mismatch after seek")
+
+		rtest.OK(t, f.Close())
+	}
+}
+
 func TestFSLocalReaddir(t *testing.T) {
 	testFSLocalReaddir(t, false)
 	testFSLocalReaddir(t, true)
@@ -1,6 +1,7 @@
 package fs
 
 import (
+	"fmt"
 	"path/filepath"
 	"runtime"
 	"strings"
@@ -16,7 +17,10 @@ type VSSConfig struct {
 	ExcludeAllMountPoints bool          `option:"exclude-all-mount-points" help:"exclude mountpoints from snapshotting on all volumes"`
 	ExcludeVolumes        string        `option:"exclude-volumes" help:"semicolon separated list of volumes to exclude from snapshotting (ex. 'c:\\;e:\\mnt;\\\\?\\Volume{...}')"`
 	Timeout               time.Duration `option:"timeout" help:"time that the VSS can spend creating snapshot before timing out"`
+	VolumeTimeouts        string        `option:"volume-timeouts" help:"semicolon separated list of per-volume timeouts overriding vss.timeout (ex. 'c:\\=2m;e:\\=10m')"`
 	Provider              string        `option:"provider" help:"VSS provider identifier which will be used for snapshotting"`
+	IncludeWriters        string        `option:"include-writers" help:"semicolon separated list of VSS writer names or class IDs, only these writers take part in snapshots"`
+	ExcludeWriters        string        `option:"exclude-writers" help:"semicolon separated list of VSS writer names or class IDs which do not take part in snapshots"`
 }
 
 func init() {
@@ -35,9 +39,89 @@ func ParseVSSConfig(o options.Options) (VSSConfig, error) {
 		return VSSConfig{}, err
 	}
 
+	if cfg.IncludeWriters != "" && cfg.ExcludeWriters != "" {
+		return VSSConfig{}, errors.Fatal("vss.include-writers and vss.exclude-writers cannot be used at the same time")
+	}
+	if _, err := splitVolumeTimeouts(cfg.VolumeTimeouts); err != nil {
+		return VSSConfig{}, err
+	}
+
 	return cfg, nil
 }
 
+// splitVolumeTimeouts parses a semicolon separated list of volume=duration
+// pairs. The volumes are returned unmodified.
+func splitVolumeTimeouts(list string) (map[string]time.Duration, error) {
+	if list == "" {
+		return nil, nil
+	}
+	timeouts := make(map[string]time.Duration)
+	for _, s := range strings.Split(list, ";") {
+		volume, value, ok := strings.Cut(s, "=")
+		if !ok || volume == "" {
+			return nil, errors.Fatalf("invalid vss.volume-timeouts entry %q, must be in the format volume=duration", s)
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return nil, errors.Fatalf("invalid timeout in vss.volume-timeouts entry %q", s)
+		}
+		timeouts[volume] = d
+	}
+	return timeouts, nil
+}
+
+// splitWriters splits a semicolon separated list of VSS writer names or class
+// IDs. The entries are lowercased, as writer names and IDs are compared
+// case-insensitively.
+func splitWriters(list string) []string {
+	var writers []string
+	for _, s := range strings.Split(list, ";") {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if s != "" {
+			writers = append(writers, s)
+		}
+	}
+	return writers
+}
+
+// writerSelection describes which VSS writers take part in a snapshot.
+type writerSelection struct {
+	include, exclude []string
+}
+
+// matchWriter returns true if the writer with the class ID and name is
+// contained in list.
+func matchWriter(list []string, classID, name string) bool {
+	classID = strings.ToLower(strings.Trim(classID, "{}"))
+	name = strings.ToLower(name)
+	for _, entry := range list {
+		if entry == name || strings.Trim(entry, "{}") == classID {
+			return true
+		}
+	}
+	return false
+}
+
+// VSSError is reported if no VSS snapshot could be created for a volume.
+type VSSError struct {
+	Volume string
+	Err    error
+}
+
+func (e *VSSError) Error() string {
+	return fmt.Sprintf("failed to create snapshot for [%s]: %s", e.Volume, e.Err)
+}
+
+func (e *VSSError) Unwrap() error {
+	return e.Err
+}
+
+// Code returns the HRESULT of the failed VSS call and its symbolic name, or
+// zero and an empty string if the error was not returned by a VSS call.
+func (e *VSSError) Code() (uint32, string) {
+	return vssErrorCode(e.Err)
+}
+
 // ErrorHandler is used to report errors via callback.
 type ErrorHandler func(item string, err error)
 
@@ -59,7 +143,9 @@ type LocalVss struct {
 	excludeAllMountPoints bool
 	excludeVolumes        map[string]struct{}
 	timeout               time.Duration
+	volumeTimeouts        map[string]time.Duration
 	provider              string
+	writers               writerSelection
 }
 
 // statically ensure that LocalVss implements FS.
@@ -86,6 +172,25 @@ func parseMountPoints(list string, msgError ErrorHandler) (volumes map[string]st
 	return
 }
 
+// parseVolumeTimeouts converts the volumes of vss.volume-timeouts to
+// lowercased volume GUID paths.
+func parseVolumeTimeouts(list string, msgError ErrorHandler) map[string]time.Duration {
+	// the syntax was already checked by ParseVSSConfig
+	timeouts, _ := splitVolumeTimeouts(list)
+	if len(timeouts) == 0 {
+		return nil
+	}
+	result := make(map[string]time.Duration)
+	for s, d := range timeouts {
+		if v, err := getVolumeNameForVolumeMountPoint(s); err != nil {
+			msgError(s, errors.Errorf("failed to parse vss.volume-timeouts [%s]: %s", s, err))
+		} else {
+			result[strings.ToLower(v)] = d
+		}
+	}
+	return result
+}
+
 // NewLocalVss creates a new wrapper around the windows filesystem using volume
 // shadow copy service to access locked files.
 func NewLocalVss(msgError ErrorHandler, msgMessage MessageHandler, cfg VSSConfig) *LocalVss {
@@ -98,7 +203,12 @@ func NewLocalVss(msgError ErrorHandler, msgMessage MessageHandler, cfg VSSConfig
 		excludeAllMountPoints: cfg.ExcludeAllMountPoints,
 		excludeVolumes:        parseMountPoints(cfg.ExcludeVolumes, msgError),
 		timeout:               cfg.Timeout,
+		volumeTimeouts:        parseVolumeTimeouts(cfg.VolumeTimeouts, msgError),
 		provider:              cfg.Provider,
+		writers: writerSelection{
+			include: splitWriters(cfg.IncludeWriters),
+			exclude: splitWriters(cfg.ExcludeWriters),
+		},
 	}
 }
 
@@ -145,6 +255,22 @@ func (fs *LocalVss) isMountPointIncluded(mountPoint string) bool {
 	return !ok
 }
 
+// volumeTimeout returns the timeout for creating a snapshot of the volume.
+func (fs *LocalVss) volumeTimeout(mountPoint string) time.Duration {
+	if fs.volumeTimeouts == nil {
+		return fs.timeout
+	}
+
+	volume, err := getVolumeNameForVolumeMountPoint(mountPoint)
+	if err != nil {
+		return fs.timeout
+	}
+	if timeout, ok := fs.volumeTimeouts[strings.ToLower(volume)]; ok {
+		return timeout
+	}
+	return fs.timeout
+}
+
 // snapshotPath returns the path inside a VSS snapshots if it already exists.
 // If the path is not yet available as a snapshot, a snapshot is created.
 // If creation of a snapshot fails the file's original path is returned as
@@ -192,9 +318,8 @@ func (fs *LocalVss) snapshotPath(path string) string {
 					}
 				}
 
-				if snapshot, err := newVssSnapshot(fs.provider, vssVolume, fs.timeout, includeVolume, fs.msgError); err != nil {
-					fs.msgError(vssVolume, errors.Errorf("failed to create snapshot for [%s]: %s",
-						vssVolume, err))
+				if snapshot, err := newVssSnapshot(fs.provider, vssVolume, fs.volumeTimeout(vssVolume), includeVolume, fs.writers, fs.msgError); err != nil {
+					fs.msgError(vssVolume, &VSSError{Volume: vssVolume, Err: err})
 					fs.failedSnapshots[volumeNameLower] = struct{}{}
 				} else {
 					fs.snapshots[volumeNameLower] = snapshot
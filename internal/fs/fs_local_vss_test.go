@@ -122,6 +122,50 @@ func TestVSSConfig(t *testing.T) {
 	}
 }
 
+func TestVSSConfigWriters(t *testing.T) {
+	cfg, err := ParseVSSConfig(options.Options{
+		"vss.include-writers": "SqlServerWriter; {A65FAA63-5EA8-4EBC-9DBD-A0C4DB26912A}",
+	})
+	rtest.OK(t, err)
+
+	dst := NewLocalVss(func(item string, err error) {
+		t.Fatalf("unexpected error (%v)", err)
+	}, func(msg string, args ...interface{}) {
+		t.Fatalf("unexpected message (%s)", fmt.Sprintf(msg, args...))
+	}, cfg)
+	rtest.Equals(t, []string{"sqlserverwriter", "{a65faa63-5ea8-4ebc-9dbd-a0c4db26912a}"}, dst.writers.include)
+	rtest.Assert(t, matchWriter(dst.writers.include, "{A65FAA63-5EA8-4EBC-9DBD-A0C4DB26912A}", "SqlServerWriter"), "writer not matched by ID")
+	rtest.Assert(t, matchWriter(dst.writers.include, "{00000000-0000-0000-0000-000000000000}", "sqlserverwriter"), "writer not matched by name")
+	rtest.Assert(t, !matchWriter(dst.writers.include, "{00000000-0000-0000-0000-000000000000}", "Registry Writer"), "unexpected match")
+
+	_, err = ParseVSSConfig(options.Options{
+		"vss.include-writers": "SqlServerWriter",
+		"vss.exclude-writers": "Registry Writer",
+	})
+	rtest.Assert(t, err != nil, "expected error for include and exclude writers")
+}
+
+func TestVSSConfigVolumeTimeouts(t *testing.T) {
+	for _, invalid := range []string{"c:", "c:=", "=5m", `c:\=5m;d:`, "c:=-1m"} {
+		_, err := ParseVSSConfig(options.Options{"vss.volume-timeouts": invalid})
+		rtest.Assert(t, err != nil, "expected error for %q", invalid)
+	}
+
+	cfg, err := ParseVSSConfig(options.Options{
+		"vss.timeout":         "1m",
+		"vss.volume-timeouts": `c:\=5m`,
+	})
+	rtest.OK(t, err)
+
+	dst := NewLocalVss(func(item string, err error) {
+		t.Fatalf("unexpected error (%v)", err)
+	}, func(msg string, args ...interface{}) {
+		t.Fatalf("unexpected message (%s)", fmt.Sprintf(msg, args...))
+	}, cfg)
+	rtest.Equals(t, 5*time.Minute, dst.volumeTimeout(`C:\`))
+	rtest.Equals(t, time.Minute, dst.volumeTimeout(`\\?\Volume{39b9cac2-bcdb-4d51-97c8-0d0677d607fb}\`))
+}
+
 func TestParseMountPoints(t *testing.T) {
 	volumeMatch := regexp.MustCompile(`^\\\\\?\\Volume\{[0-9a-f]{8}(?:-[0-9a-f]{4}){3}-[0-9a-f]{12}\}\\$`)
 
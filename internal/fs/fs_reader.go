@@ -43,12 +43,42 @@ var _ FS = &reader{}
 // be opened once, all subsequent open calls return syscall.EIO. For Lstat(),
 // the provided FileInfo is returned.
 func NewReader(name string, r io.ReadCloser, opts ReaderOptions) (FS, error) {
+	return NewMultiReader([]ReaderFile{{Name: name, Reader: r}}, opts)
+}
+
+// ReaderFile is a file provided by the FS returned by NewMultiReader.
+type ReaderFile struct {
+	Name   string
+	Reader io.ReadCloser
+}
+
+// NewMultiReader works like NewReader, but provides several files. A file
+// must not be a parent directory of another file.
+func NewMultiReader(files []ReaderFile, opts ReaderOptions) (FS, error) {
 	items := make(map[string]readerItem)
+	for _, file := range files {
+		if err := addReaderFile(items, file.Name, file.Reader, opts); err != nil {
+			return nil, err
+		}
+	}
+	return &reader{
+		items: items,
+	}, nil
+}
+
+func addReaderFile(items map[string]readerItem, name string, r io.ReadCloser, opts ReaderOptions) error {
 	name = readerCleanPath(name)
 	if name == "/" {
-		return nil, fmt.Errorf("invalid filename specified")
+		return fmt.Errorf("invalid filename specified")
+	}
+	if item, ok := items[name]; ok {
+		if item.rc != nil {
+			return fmt.Errorf("duplicate filename %v", name)
+		}
+		return fmt.Errorf("filename %v is also used as a directory", name)
 	}
 
+	var childName string
 	isFile := true
 	for {
 		if isFile {
@@ -66,6 +96,17 @@ func NewReader(name string, r io.ReadCloser, opts ReaderOptions) (FS, error) {
 			}
 			isFile = false
 		} else {
+			item, ok := items[name]
+			if ok && item.rc != nil {
+				return fmt.Errorf("filename %v is also used as a directory", name)
+			}
+			if ok {
+				// directory was already created for another file
+				item.children = append(item.children, childName)
+				items[name] = item
+				return nil
+			}
+
 			fi := &ExtendedFileInfo{
 				Name:    path.Base(name),
 				Mode:    os.ModeDir | 0755,
@@ -73,9 +114,8 @@ func NewReader(name string, r io.ReadCloser, opts ReaderOptions) (FS, error) {
 				Size:    0,
 			}
 			items[name] = readerItem{
-				fi: fi,
-				// keep the children set during the previous iteration
-				children: items[name].children,
+				fi:       fi,
+				children: []string{childName},
 			}
 		}
 
@@ -83,16 +123,10 @@ func NewReader(name string, r io.ReadCloser, opts ReaderOptions) (FS, error) {
 		if parent == name {
 			break
 		}
-		// add the current file to the children of the parent directory
-		item := items[parent]
-		item.children = append(item.children, path.Base(name))
-		items[parent] = item
-
+		childName = path.Base(name)
 		name = parent
 	}
-	return &reader{
-		items: items,
-	}, nil
+	return nil
 }
 
 func readerCleanPath(name string) string {
@@ -102,3 +102,44 @@ func (fp *commandReader) Close() error {
 	_ = fp.cmd.Cancel()
 	return fp.wait()
 }
+
+// lazyCommandReader only starts the command once data is read from it.
+type lazyCommandReader struct {
+	ctx         context.Context
+	args        []string
+	errorOutput func(msg string, args ...interface{})
+
+	rd  io.ReadCloser
+	err error
+}
+
+// NewLazyCommandReader works like NewCommandReader, but only starts the
+// command when its output is read for the first time. This allows preparing
+// several commands without running all of them concurrently.
+func NewLazyCommandReader(ctx context.Context, args []string, errorOutput func(msg string, args ...interface{})) (io.ReadCloser, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("no command was specified as argument")
+	}
+	return &lazyCommandReader{
+		ctx:         ctx,
+		args:        args,
+		errorOutput: errorOutput,
+	}, nil
+}
+
+func (fp *lazyCommandReader) Read(p []byte) (int, error) {
+	if fp.rd == nil && fp.err == nil {
+		fp.rd, fp.err = NewCommandReader(fp.ctx, fp.args, fp.errorOutput)
+	}
+	if fp.err != nil {
+		return 0, fp.err
+	}
+	return fp.rd.Read(p)
+}
+
+func (fp *lazyCommandReader) Close() error {
+	if fp.rd == nil {
+		return nil
+	}
+	return fp.rd.Close()
+}
@@ -63,3 +63,28 @@ func TestCommandReaderQuickClose(t *testing.T) {
 	_ = reader.Close()
 	test.OK(t, ctx.Err())
 }
+
+func TestLazyCommandReader(t *testing.T) {
+	reader, err := fs.NewLazyCommandReader(context.TODO(), []string{"echo", "hello world"}, func(msg string, args ...interface{}) {})
+	test.OK(t, err)
+
+	var buf bytes.Buffer
+
+	_, err = io.Copy(&buf, reader)
+	test.OK(t, err)
+	test.OK(t, reader.Close())
+
+	test.Equals(t, "hello world", strings.TrimSpace(buf.String()))
+}
+
+func TestLazyCommandReaderNotStarted(t *testing.T) {
+	// the command is only started once data is read
+	reader, err := fs.NewLazyCommandReader(context.TODO(), []string{"w54fy098hj7fy5twijouytfrj098y645wr"}, func(msg string, args ...interface{}) {})
+	test.OK(t, err)
+	test.OK(t, reader.Close())
+
+	reader, err = fs.NewLazyCommandReader(context.TODO(), []string{"w54fy098hj7fy5twijouytfrj098y645wr"}, func(msg string, args ...interface{}) {})
+	test.OK(t, err)
+	_, err = io.Copy(io.Discard, reader)
+	test.Assert(t, err != nil, "missing error")
+}
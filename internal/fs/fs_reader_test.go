@@ -228,6 +228,46 @@ func TestFSReaderNested(t *testing.T) {
 	}
 }
 
+func TestFSMultiReader(t *testing.T) {
+	data1 := test.Random(55, 1<<18+588)
+	data2 := test.Random(56, 1234)
+	now := time.Now()
+
+	fs, err := NewMultiReader([]ReaderFile{
+		{Name: "db/one.sql", Reader: io.NopCloser(bytes.NewReader(data1))},
+		{Name: "db/two.sql", Reader: io.NopCloser(bytes.NewReader(data2))},
+		{Name: "top", Reader: io.NopCloser(bytes.NewReader(data2))},
+	}, ReaderOptions{
+		Mode:    0644,
+		ModTime: now,
+	})
+	test.OK(t, err)
+
+	verifyDirectoryContents(t, fs, "/", []string{"db", "top"})
+	verifyDirectoryContents(t, fs, "/db", []string{"one.sql", "two.sql"})
+	verifyFileContentOpenFile(t, fs, "/db/one.sql", data1)
+	verifyFileContentOpenFile(t, fs, "/db/two.sql", data2)
+	verifyFileContentOpenFile(t, fs, "/top", data2)
+}
+
+func TestFSMultiReaderConflicts(t *testing.T) {
+	for _, names := range [][]string{
+		{"foo", "foo"},
+		{"/foo", "foo"},
+		{"foo", "foo/bar"},
+		{"foo/bar", "foo"},
+	} {
+		t.Run(strings.Join(names, ","), func(t *testing.T) {
+			var files []ReaderFile
+			for _, name := range names {
+				files = append(files, ReaderFile{Name: name, Reader: io.NopCloser(strings.NewReader("data"))})
+			}
+			_, err := NewMultiReader(files, ReaderOptions{Mode: 0644})
+			test.Assert(t, err != nil, "expected error for conflicting filenames %v", names)
+		})
+	}
+}
+
 func TestFSReaderDir(t *testing.T) {
 	data := test.Random(55, 1<<18+588)
 	now := time.Now()
@@ -0,0 +1,107 @@
+package fs
+
+import (
+	"io"
+	"os"
+	"time"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+// Retry is a wrapper around another file system which repeats failed
+// operations up to Retries times before returning the error. This hides
+// transient errors, for example of network file systems. Errors that a file
+// does not exist or cannot be accessed are returned immediately.
+type Retry struct {
+	FS
+
+	Retries int
+	// Delay is the time to wait before the first retry, it doubles for
+	// every following retry.
+	Delay time.Duration
+	// Report is called for every failed attempt that is retried, if set.
+	Report func(item string, err error)
+}
+
+// retry runs fn until it succeeds or all retries were used.
+func (fs Retry) retry(item string, fn func() error) error {
+	delay := fs.Delay
+	for i := 0; ; i++ {
+		err := fn()
+		if err == nil || i >= fs.Retries || errors.Is(err, io.EOF) ||
+			errors.Is(err, os.ErrNotExist) || errors.Is(err, os.ErrPermission) {
+			return err
+		}
+
+		if fs.Report != nil {
+			fs.Report(item, err)
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// OpenFile wraps the OpenFile method of the underlying file system.
+func (fs Retry) OpenFile(name string, flag int, metadataOnly bool) (File, error) {
+	var f File
+	err := fs.retry(name, func() (err error) {
+		f, err = fs.FS.OpenFile(name, flag, metadataOnly)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &retryFile{File: f, fs: fs, name: name}, nil
+}
+
+// Lstat wraps the Lstat method of the underlying file system.
+func (fs Retry) Lstat(name string) (*ExtendedFileInfo, error) {
+	var fi *ExtendedFileInfo
+	err := fs.retry(name, func() (err error) {
+		fi, err = fs.FS.Lstat(name)
+		return err
+	})
+	return fi, err
+}
+
+type retryFile struct {
+	File
+	fs   Retry
+	name string
+}
+
+func (f *retryFile) MakeReadable() error {
+	return f.fs.retry(f.name, f.File.MakeReadable)
+}
+
+// Read repeats failed reads which did not return any data. The file offset is
+// unchanged in this case, thus no data is skipped.
+func (f *retryFile) Read(p []byte) (n int, err error) {
+	err = f.fs.retry(f.name, func() error {
+		n, err = f.File.Read(p)
+		if n > 0 {
+			// report the error on the next call
+			return nil
+		}
+		return err
+	})
+	return n, err
+}
+
+func (f *retryFile) Stat() (*ExtendedFileInfo, error) {
+	var fi *ExtendedFileInfo
+	err := f.fs.retry(f.name, func() (err error) {
+		fi, err = f.File.Stat()
+		return err
+	})
+	return fi, err
+}
+
+// Seek forwards to the underlying file, if it supports seeking.
+func (f *retryFile) Seek(offset int64, whence int) (int64, error) {
+	sk, ok := f.File.(io.Seeker)
+	if !ok {
+		return 0, errors.New("seek not supported")
+	}
+	return sk.Seek(offset, whence)
+}
@@ -0,0 +1,96 @@
+package fs
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/restic/restic/internal/errors"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+// flakyFS fails the first failures calls to OpenFile and Read.
+type flakyFS struct {
+	FS
+	failures *int
+}
+
+func (fs flakyFS) fail() bool {
+	if *fs.failures > 0 {
+		*fs.failures--
+		return true
+	}
+	return false
+}
+
+func (fs flakyFS) OpenFile(name string, flag int, metadataOnly bool) (File, error) {
+	if fs.fail() {
+		return nil, syscall.EIO
+	}
+	f, err := fs.FS.OpenFile(name, flag, metadataOnly)
+	if err != nil {
+		return nil, err
+	}
+	return flakyFile{File: f, fs: fs}, nil
+}
+
+type flakyFile struct {
+	File
+	fs flakyFS
+}
+
+func (f flakyFile) Read(p []byte) (int, error) {
+	if f.fs.fail() {
+		return 0, syscall.EIO
+	}
+	return f.File.Read(p)
+}
+
+func TestRetryFS(t *testing.T) {
+	tempdir := rtest.TempDir(t)
+	filename := filepath.Join(tempdir, "file")
+	rtest.OK(t, os.WriteFile(filename, []byte("content"), 0o600))
+
+	failures := 0
+	var reported int
+	fs := Retry{
+		FS:      flakyFS{FS: NewLocal(), failures: &failures},
+		Retries: 2,
+		Report: func(item string, err error) {
+			rtest.Equals(t, filename, item)
+			reported++
+		},
+	}
+
+	read := func() error {
+		f, err := fs.OpenFile(filename, O_NOFOLLOW, false)
+		if err != nil {
+			return err
+		}
+		buf, err := io.ReadAll(f)
+		rtest.OK(t, f.Close())
+		if err == nil {
+			rtest.Equals(t, "content", string(buf))
+		}
+		return err
+	}
+
+	// failed open and read
+	failures = 2
+	rtest.OK(t, read())
+	rtest.Equals(t, 2, reported)
+
+	// too many failures
+	failures = 3
+	reported = 0
+	rtest.Assert(t, read() == syscall.EIO, "expected EIO")
+	rtest.Equals(t, 2, reported)
+
+	// permanent errors are not retried
+	reported = 0
+	_, err := fs.OpenFile(filepath.Join(tempdir, "missing"), O_NOFOLLOW, false)
+	rtest.Assert(t, errors.Is(err, os.ErrNotExist), "expected not exist error, got %v", err)
+	rtest.Equals(t, 0, reported)
+}
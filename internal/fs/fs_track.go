@@ -2,9 +2,12 @@ package fs
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"runtime"
 	"runtime/debug"
+
+	"github.com/restic/restic/internal/errors"
 )
 
 // Track is a wrapper around another file system which installs finalizers
@@ -42,3 +45,12 @@ func (f *trackFile) Close() error {
 	runtime.SetFinalizer(f, nil)
 	return f.File.Close()
 }
+
+// Seek forwards to the underlying file, if it supports seeking.
+func (f *trackFile) Seek(offset int64, whence int) (int64, error) {
+	sk, ok := f.File.(io.Seeker)
+	if !ok {
+		return 0, errors.New("seek not supported")
+	}
+	return sk.Seek(offset, whence)
+}
@@ -0,0 +1,46 @@
+package fs
+
+import "strings"
+
+// FilesystemInfo describes the filesystem which contains a path.
+type FilesystemInfo struct {
+	// Type is the name of the filesystem type, for example "ext4" or "nfs".
+	// It is empty if the type is unknown.
+	Type string
+	// Network is true if the filesystem is a network or FUSE filesystem.
+	Network bool
+}
+
+// networkFilesystems contains the names of filesystem types which store their
+// data on another host, or which are provided by a userspace process.
+var networkFilesystems = map[string]struct{}{
+	"9p":        {},
+	"afs":       {},
+	"ceph":      {},
+	"cifs":      {},
+	"coda":      {},
+	"fuse":      {},
+	"fusefs":    {},
+	"glusterfs": {},
+	"lustre":    {},
+	"macfuse":   {},
+	"ncp":       {},
+	"nfs":       {},
+	"nfs4":      {},
+	"smb":       {},
+	"smb2":      {},
+	"smbfs":     {},
+	"sshfs":     {},
+	"webdav":    {},
+}
+
+// isNetworkFilesystemType returns true if fstype is the name of a network or
+// FUSE filesystem.
+func isNetworkFilesystemType(fstype string) bool {
+	fstype = strings.ToLower(fstype)
+	if strings.HasPrefix(fstype, "fuse.") || strings.HasPrefix(fstype, "osxfuse") {
+		return true
+	}
+	_, ok := networkFilesystems[fstype]
+	return ok
+}
@@ -0,0 +1,23 @@
+//go:build darwin || freebsd
+
+package fs
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// FilesystemType returns information about the filesystem containing path.
+func FilesystemType(path string) (FilesystemInfo, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(fixpath(path), &st); err != nil {
+		return FilesystemInfo{}, &os.PathError{Op: "statfs", Path: path, Err: err}
+	}
+
+	name := unix.ByteSliceToString(st.Fstypename[:])
+	return FilesystemInfo{
+		Type:    name,
+		Network: uint64(st.Flags)&unix.MNT_LOCAL == 0 || isNetworkFilesystemType(name),
+	}, nil
+}
@@ -0,0 +1,64 @@
+package fs
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// filesystemMagic maps the filesystem type reported by statfs to its name, see
+// the statfs(2) man page.
+var filesystemMagic = map[int64]string{
+	0x0000002f: "qnx4",
+	0x00001373: "devfs",
+	0x0000137d: "ext",
+	0x00004244: "hfs",
+	0x00004d44: "msdos",
+	0x0000517b: "smb",
+	0x0000564c: "ncp",
+	0x00006969: "nfs",
+	0x00009660: "isofs",
+	0x00009fa0: "proc",
+	0x0000adf5: "adfs",
+	0x0000ef53: "ext4",
+	0x0000f15f: "ecryptfs",
+	0x00c36400: "ceph",
+	0x01021994: "tmpfs",
+	0x01021997: "9p",
+	0x0bd00bd0: "lustre",
+	0x2011bab0: "exfat",
+	0x24051905: "ubifs",
+	0x2fc12fc1: "zfs",
+	0x3153464a: "jfs",
+	0x42465331: "befs",
+	0x52654973: "reiserfs",
+	0x5346414f: "afs",
+	0x5346544e: "ntfs",
+	0x58465342: "xfs",
+	0x6165676c: "pstorefs",
+	0x62656572: "sysfs",
+	0x65735546: "fuse",
+	0x73717368: "squashfs",
+	0x73757245: "coda",
+	0x794c7630: "overlay",
+	0x9123683e: "btrfs",
+	0xcafe4a11: "bpf",
+	0xf2f52010: "f2fs",
+	0xfe534d42: "smb2",
+	0xff534d42: "cifs",
+}
+
+// FilesystemType returns information about the filesystem containing path.
+func FilesystemType(path string) (FilesystemInfo, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(fixpath(path), &st); err != nil {
+		return FilesystemInfo{}, &os.PathError{Op: "statfs", Path: path, Err: err}
+	}
+
+	// the magic number is a 32 bit value, but is sign extended on some architectures
+	name := filesystemMagic[int64(uint32(st.Type))]
+	return FilesystemInfo{
+		Type:    name,
+		Network: isNetworkFilesystemType(name),
+	}, nil
+}
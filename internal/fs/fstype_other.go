@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package fs
+
+// FilesystemType returns information about the filesystem containing path.
+// The filesystem type cannot be determined on this platform, thus an empty
+// FilesystemInfo is returned.
+func FilesystemType(_ string) (FilesystemInfo, error) {
+	return FilesystemInfo{}, nil
+}
@@ -0,0 +1,30 @@
+package fs
+
+import (
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestIsNetworkFilesystemType(t *testing.T) {
+	for fstype, network := range map[string]bool{
+		"nfs":        true,
+		"NFS4":       true,
+		"cifs":       true,
+		"smb2":       true,
+		"fuse":       true,
+		"fuse.sshfs": true,
+		"osxfusefs":  true,
+		"ext4":       false,
+		"apfs":       false,
+		"NTFS":       false,
+		"":           false,
+	} {
+		rtest.Equals(t, network, isNetworkFilesystemType(fstype), "unexpected result for %q", fstype)
+	}
+}
+
+func TestFilesystemType(t *testing.T) {
+	_, err := FilesystemType(rtest.TempDir(t))
+	rtest.OK(t, err)
+}
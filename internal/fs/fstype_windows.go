@@ -0,0 +1,32 @@
+package fs
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// FilesystemType returns information about the filesystem containing path.
+// Volumes on network drives and UNC paths are reported as network filesystems.
+func FilesystemType(path string) (FilesystemInfo, error) {
+	pathp, err := windows.UTF16PtrFromString(fixpath(path))
+	if err != nil {
+		return FilesystemInfo{}, &os.PathError{Op: "GetVolumePathName", Path: path, Err: err}
+	}
+	volume := make([]uint16, windows.MAX_PATH+1)
+	if err := windows.GetVolumePathName(pathp, &volume[0], uint32(len(volume))); err != nil {
+		return FilesystemInfo{}, &os.PathError{Op: "GetVolumePathName", Path: path, Err: err}
+	}
+
+	var info FilesystemInfo
+	info.Network = windows.GetDriveType(&volume[0]) == windows.DRIVE_REMOTE
+
+	name := make([]uint16, windows.MAX_PATH+1)
+	err = windows.GetVolumeInformation(&volume[0], nil, 0, nil, nil, nil, &name[0], uint32(len(name)))
+	if err != nil {
+		// the volume may not be reachable, the drive type is still meaningful
+		return info, nil
+	}
+	info.Type = windows.UTF16ToString(name)
+	return info, nil
+}
@@ -293,9 +293,73 @@ func mkfifo(path string, mode uint32) (err error) {
 	return mknod(path, mode|syscall.S_IFIFO, 0)
 }
 
+// RestoreMetadataOptions controls which metadata NodeRestoreMetadata restores.
+type RestoreMetadataOptions struct {
+	// OwnershipByName restores the owner by user and group name instead of
+	// by UID and GID.
+	OwnershipByName bool
+	// NoOwner skips restoring the owner.
+	NoOwner bool
+	// NoPerms skips restoring the permissions, including ACLs.
+	NoPerms bool
+	// SecurityDescriptors selects which parts of Windows security descriptors
+	// are restored.
+	SecurityDescriptors SecurityDescriptorMode
+	// ReportNotRestored is called with the parts of the metadata which could
+	// not be restored due to missing privileges, if set.
+	ReportNotRestored func(metadata []string)
+}
+
+// SecurityDescriptorMode selects which parts of a Windows security descriptor
+// are restored.
+type SecurityDescriptorMode int
+
+const (
+	// SecurityDescriptorFull restores the owner, group, DACL and SACL. Without
+	// the required privileges only the DACL is restored.
+	SecurityDescriptorFull SecurityDescriptorMode = iota
+	// SecurityDescriptorDACL only restores the DACL.
+	SecurityDescriptorDACL
+	// SecurityDescriptorNone does not restore security descriptors.
+	SecurityDescriptorNone
+	SecurityDescriptorInvalid
+)
+
+func (m *SecurityDescriptorMode) Set(s string) error {
+	switch s {
+	case "full":
+		*m = SecurityDescriptorFull
+	case "dacl":
+		*m = SecurityDescriptorDACL
+	case "none":
+		*m = SecurityDescriptorNone
+	default:
+		*m = SecurityDescriptorInvalid
+		return fmt.Errorf("invalid security descriptor mode %q, must be one of (full|dacl|none)", s)
+	}
+	return nil
+}
+
+func (m *SecurityDescriptorMode) String() string {
+	switch *m {
+	case SecurityDescriptorFull:
+		return "full"
+	case SecurityDescriptorDACL:
+		return "dacl"
+	case SecurityDescriptorNone:
+		return "none"
+	default:
+		return "invalid"
+	}
+}
+
+func (m *SecurityDescriptorMode) Type() string {
+	return "mode"
+}
+
 // NodeRestoreMetadata restores node metadata
-func NodeRestoreMetadata(node *data.Node, path string, warn func(msg string), xattrSelectFilter func(xattrName string) bool, ownershipByName bool) error {
-	err := nodeRestoreMetadata(node, path, warn, xattrSelectFilter, ownershipByName)
+func NodeRestoreMetadata(node *data.Node, path string, warn func(msg string), xattrSelectFilter func(xattrName string) bool, opts RestoreMetadataOptions) error {
+	err := nodeRestoreMetadata(node, path, warn, xattrSelectFilter, opts)
 	if err != nil {
 		// It is common to have permission errors for folders like /home
 		// unless you're running as root, so ignore those.
@@ -310,11 +374,13 @@ func NodeRestoreMetadata(node *data.Node, path string, warn func(msg string), xa
 	return err
 }
 
-func nodeRestoreMetadata(node *data.Node, path string, warn func(msg string), xattrSelectFilter func(xattrName string) bool, ownershipByName bool) error {
+func nodeRestoreMetadata(node *data.Node, path string, warn func(msg string), xattrSelectFilter func(xattrName string) bool, opts RestoreMetadataOptions) error {
 	var firsterr error
 
-	if err := lchown(path, node, ownershipByName); err != nil {
-		firsterr = errors.WithStack(err)
+	if !opts.NoOwner {
+		if err := lchown(path, node, opts.OwnershipByName); err != nil {
+			firsterr = errors.WithStack(err)
+		}
 	}
 
 	if err := nodeRestoreExtendedAttributes(node, path, xattrSelectFilter); err != nil {
@@ -324,7 +390,7 @@ func nodeRestoreMetadata(node *data.Node, path string, warn func(msg string), xa
 		}
 	}
 
-	if err := nodeRestoreGenericAttributes(node, path, warn); err != nil {
+	if err := nodeRestoreGenericAttributes(node, path, warn, opts); err != nil {
 		debug.Log("error restoring generic attributes for %v: %v", path, err)
 		if firsterr == nil {
 			firsterr = err
@@ -341,6 +407,10 @@ func nodeRestoreMetadata(node *data.Node, path string, warn func(msg string), xa
 	// Moving RestoreTimestamps and restoreExtendedAttributes calls above as for readonly files in windows
 	// calling Chmod below will no longer allow any modifications to be made on the file and the
 	// calls above would fail.
+	if opts.NoPerms {
+		return firsterr
+	}
+
 	if node.Type != data.NodeTypeSymlink {
 		if err := chmod(path, node.Mode); err != nil {
 			if firsterr == nil {
@@ -349,6 +419,14 @@ func nodeRestoreMetadata(node *data.Node, path string, warn func(msg string), xa
 		}
 	}
 
+	// Changing the mode can modify or discard ACLs, thus restore them last.
+	if err := nodeRestoreACL(node, path); err != nil {
+		debug.Log("error restoring ACL for %v: %v", path, err)
+		if firsterr == nil {
+			firsterr = err
+		}
+	}
+
 	return firsterr
 }
 
@@ -0,0 +1,163 @@
+package fs
+
+import (
+	"encoding/binary"
+	"os"
+	"unsafe"
+
+	"github.com/restic/restic/internal/errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// ACL types, tags and limits from sys/acl.h.
+const (
+	aclTypeAccess  = 2
+	aclTypeDefault = 3
+	aclTypeNFS4    = 4
+
+	aclUserObj  = 0x01
+	aclGroupObj = 0x04
+	aclOther    = 0x20
+	aclEveryone = 0x40
+
+	aclMaxEntries = 254
+)
+
+// aclEntry mirrors struct acl_entry from sys/acl.h.
+type aclEntry struct {
+	Tag       uint32
+	ID        uint32
+	Perm      uint32
+	EntryType uint16
+	Flags     uint16
+}
+
+// aclEntrySize is the size of an encoded aclEntry.
+const aclEntrySize = 16
+
+// acl mirrors struct acl from sys/acl.h.
+type acl struct {
+	MaxCnt  uint32
+	Cnt     uint32
+	Spare   [4]int32
+	Entries [aclMaxEntries]aclEntry
+}
+
+// aclGet returns the ACL of the given type for path without following symlinks.
+func aclGet(path string, aclType int) (*acl, error) {
+	p, err := unix.BytePtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	a := &acl{MaxCnt: aclMaxEntries}
+	_, _, errno := unix.Syscall(unix.SYS___ACL_GET_LINK, uintptr(unsafe.Pointer(p)), uintptr(aclType), uintptr(unsafe.Pointer(a)))
+	if errno != 0 {
+		return nil, errno
+	}
+	return a, nil
+}
+
+// aclSet replaces the ACL of the given type for path without following symlinks.
+func aclSet(path string, aclType int, a *acl) error {
+	p, err := unix.BytePtrFromString(path)
+	if err != nil {
+		return err
+	}
+	a.MaxCnt = aclMaxEntries
+	_, _, errno := unix.Syscall(unix.SYS___ACL_SET_LINK, uintptr(unsafe.Pointer(p)), uintptr(aclType), uintptr(unsafe.Pointer(a)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// isTrivialACL returns whether the ACL only contains entries for the owner,
+// the group and others or everyone without inheritance flags. The file system
+// derives such an ACL from the file mode, thus it is not necessary to store it.
+func isTrivialACL(a *acl) bool {
+	for _, e := range a.Entries[:a.Cnt] {
+		switch e.Tag {
+		case aclUserObj, aclGroupObj, aclOther, aclEveryone:
+		default:
+			return false
+		}
+		if e.Flags != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// encodeACL returns a platform independent representation of the ACL entries.
+func encodeACL(a *acl) []byte {
+	buf := make([]byte, 0, int(a.Cnt)*aclEntrySize)
+	for _, e := range a.Entries[:a.Cnt] {
+		buf = binary.LittleEndian.AppendUint32(buf, e.Tag)
+		buf = binary.LittleEndian.AppendUint32(buf, e.ID)
+		buf = binary.LittleEndian.AppendUint32(buf, e.Perm)
+		buf = binary.LittleEndian.AppendUint16(buf, e.EntryType)
+		buf = binary.LittleEndian.AppendUint16(buf, e.Flags)
+	}
+	return buf
+}
+
+// decodeACL is the inverse of encodeACL.
+func decodeACL(buf []byte) (*acl, error) {
+	if len(buf)%aclEntrySize != 0 || len(buf)/aclEntrySize > aclMaxEntries {
+		return nil, errors.Errorf("invalid ACL of length %d", len(buf))
+	}
+
+	a := &acl{MaxCnt: aclMaxEntries, Cnt: uint32(len(buf) / aclEntrySize)}
+	for i := range a.Entries[:a.Cnt] {
+		e := buf[i*aclEntrySize:]
+		a.Entries[i] = aclEntry{
+			Tag:       binary.LittleEndian.Uint32(e[0:]),
+			ID:        binary.LittleEndian.Uint32(e[4:]),
+			Perm:      binary.LittleEndian.Uint32(e[8:]),
+			EntryType: binary.LittleEndian.Uint16(e[12:]),
+			Flags:     binary.LittleEndian.Uint16(e[14:]),
+		}
+	}
+	return a, nil
+}
+
+// getACL returns the encoded ACL of the given type for path. It returns nil if
+// the ACL is trivial or the file system does not support this type of ACL.
+func getACL(path string, aclType int) (*[]byte, error) {
+	a, err := aclGet(path, aclType)
+	if err == unix.EINVAL || err == unix.EOPNOTSUPP {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, &os.PathError{Op: "acl_get", Path: path, Err: err}
+	}
+
+	if aclType == aclTypeDefault {
+		if a.Cnt == 0 {
+			return nil, nil
+		}
+	} else if isTrivialACL(a) {
+		return nil, nil
+	}
+
+	buf := encodeACL(a)
+	return &buf, nil
+}
+
+// setACL restores an ACL returned by getACL. An ACL which is not supported by
+// the file system is ignored.
+func setACL(path string, aclType int, buf []byte) error {
+	a, err := decodeACL(buf)
+	if err != nil {
+		return err
+	}
+	err = aclSet(path, aclType, a)
+	if err == unix.EOPNOTSUPP {
+		return nil
+	}
+	if err != nil {
+		return &os.PathError{Op: "acl_set", Path: path, Err: err}
+	}
+	return nil
+}
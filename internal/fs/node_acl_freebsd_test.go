@@ -0,0 +1,73 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/restic/restic/internal/data"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestEncodeDecodeACL(t *testing.T) {
+	a := &acl{Cnt: 4}
+	a.Entries[0] = aclEntry{Tag: aclUserObj, Perm: 6}
+	a.Entries[1] = aclEntry{Tag: 0x02, ID: 1000, Perm: 4}
+	a.Entries[2] = aclEntry{Tag: aclGroupObj, Perm: 4, EntryType: 0x100, Flags: 0x3}
+	a.Entries[3] = aclEntry{Tag: aclOther}
+
+	buf := encodeACL(a)
+	rtest.Equals(t, 4*aclEntrySize, len(buf))
+	decoded, err := decodeACL(buf)
+	rtest.OK(t, err)
+	rtest.Equals(t, a.Cnt, decoded.Cnt)
+	rtest.Equals(t, a.Entries, decoded.Entries)
+
+	_, err = decodeACL(buf[:len(buf)-1])
+	rtest.Assert(t, err != nil, "expected error for truncated ACL")
+}
+
+func TestIsTrivialACL(t *testing.T) {
+	a := &acl{Cnt: 3}
+	a.Entries[0] = aclEntry{Tag: aclUserObj, Perm: 6}
+	a.Entries[1] = aclEntry{Tag: aclGroupObj, Perm: 4}
+	a.Entries[2] = aclEntry{Tag: aclOther, Perm: 4}
+	rtest.Assert(t, isTrivialACL(a), "ACL should be trivial")
+
+	// inherited entries are never trivial
+	a.Entries[2].Flags = 0x1
+	rtest.Assert(t, !isTrivialACL(a), "ACL with inheritance flags should not be trivial")
+	a.Entries[2].Flags = 0
+
+	// named users are never trivial
+	a.Entries[3] = aclEntry{Tag: 0x02, ID: 1000, Perm: 4}
+	a.Cnt = 4
+	rtest.Assert(t, !isTrivialACL(a), "ACL with named user should not be trivial")
+}
+
+func TestNodeRestoreACL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	rtest.OK(t, os.WriteFile(path, []byte("content"), 0o644))
+
+	a := &acl{Cnt: 5}
+	a.Entries[0] = aclEntry{Tag: aclUserObj, Perm: 6}
+	a.Entries[1] = aclEntry{Tag: 0x02, ID: 12345, Perm: 4}
+	a.Entries[2] = aclEntry{Tag: aclGroupObj, Perm: 4}
+	a.Entries[3] = aclEntry{Tag: 0x10, Perm: 4}
+	a.Entries[4] = aclEntry{Tag: aclOther, Perm: 4}
+	if err := aclSet(path, aclTypeAccess, a); err != nil {
+		t.Skipf("POSIX.1e ACLs are not supported: %v", err)
+	}
+
+	node := &data.Node{Type: data.NodeTypeFile}
+	rtest.OK(t, nodeFillGenericAttributes(node, path, nil))
+	rtest.Assert(t, node.GenericAttributes[data.TypeACLAccess] != nil, "missing access ACL in %v", node.GenericAttributes)
+
+	target := filepath.Join(t.TempDir(), "file")
+	rtest.OK(t, os.WriteFile(target, []byte("content"), 0o644))
+	rtest.OK(t, nodeRestoreACL(node, target))
+
+	restored := &data.Node{Type: data.NodeTypeFile}
+	rtest.OK(t, nodeFillGenericAttributes(restored, target, nil))
+	rtest.Assert(t, node.Equals(*restored), "ACL mismatch got %v expected %v", restored.GenericAttributes, node.GenericAttributes)
+}
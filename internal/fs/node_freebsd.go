@@ -3,8 +3,14 @@
 package fs
 
 import (
+	"encoding/json"
+	"fmt"
 	"os"
+	"reflect"
 	"syscall"
+
+	"github.com/restic/restic/internal/data"
+	"github.com/restic/restic/internal/errors"
 )
 
 func nodeRestoreSymlinkTimestamps(path string, utimes [2]syscall.Timespec) error {
@@ -18,3 +24,81 @@ func mknod(path string, mode uint32, dev uint64) error {
 	}
 	return err
 }
+
+// nodeFillGenericAttributes fills in the generic attributes for FreeBSD, which
+// are the ACLs of files and directories.
+func nodeFillGenericAttributes(node *data.Node, path string, _ *ExtendedFileInfo) error {
+	if node.Type != data.NodeTypeFile && node.Type != data.NodeTypeDir {
+		return nil
+	}
+
+	var attrs data.FreeBSDAttributes
+	var err error
+	if attrs.ACLNFS4, err = getACL(path, aclTypeNFS4); err != nil {
+		return err
+	}
+	if attrs.ACLAccess, err = getACL(path, aclTypeAccess); err != nil {
+		return err
+	}
+	if node.Type == data.NodeTypeDir {
+		if attrs.ACLDefault, err = getACL(path, aclTypeDefault); err != nil {
+			return err
+		}
+	}
+
+	if attrs == (data.FreeBSDAttributes{}) {
+		return nil
+	}
+	node.GenericAttributes, err = data.FreeBSDAttrsToGenericAttributes(attrs)
+	return err
+}
+
+// nodeRestoreGenericAttributes only checks for unknown generic attributes. The
+// ACLs are restored by nodeRestoreACL after the file mode.
+func nodeRestoreGenericAttributes(node *data.Node, path string, warn func(msg string), _ RestoreMetadataOptions) error {
+	if len(node.GenericAttributes) == 0 {
+		return nil
+	}
+	_, unknownAttribs, err := genericAttributesToFreeBSDAttrs(node.GenericAttributes)
+	if err != nil {
+		return fmt.Errorf("error parsing generic attribute for: %s : %v", path, err)
+	}
+	data.HandleUnknownGenericAttributesFound(unknownAttribs, warn)
+	return nil
+}
+
+// nodeRestoreACL restores the ACLs stored in the generic attributes.
+func nodeRestoreACL(node *data.Node, path string) error {
+	if len(node.GenericAttributes) == 0 {
+		return nil
+	}
+	attrs, _, err := genericAttributesToFreeBSDAttrs(node.GenericAttributes)
+	if err != nil {
+		return fmt.Errorf("error parsing generic attribute for: %s : %v", path, err)
+	}
+
+	var errs []error
+	for _, acl := range []struct {
+		aclType int
+		buf     *[]byte
+	}{
+		{aclTypeNFS4, attrs.ACLNFS4},
+		{aclTypeAccess, attrs.ACLAccess},
+		{aclTypeDefault, attrs.ACLDefault},
+	} {
+		if acl.buf == nil {
+			continue
+		}
+		if err := setACL(path, acl.aclType, *acl.buf); err != nil {
+			errs = append(errs, fmt.Errorf("error restoring ACL for: %s : %v", path, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// genericAttributesToFreeBSDAttrs converts the generic attributes map to a FreeBSDAttributes and also returns a string of unknown attributes that it could not convert.
+func genericAttributesToFreeBSDAttrs(attrs map[data.GenericAttributeType]json.RawMessage) (freebsdAttributes data.FreeBSDAttributes, unknownAttribs []data.GenericAttributeType, err error) {
+	faValue := reflect.ValueOf(&freebsdAttributes).Elem()
+	unknownAttribs, err = data.GenericAttributesToOSAttrs(attrs, reflect.TypeOf(freebsdAttributes), &faValue, "freebsd")
+	return freebsdAttributes, unknownAttribs, err
+}
@@ -0,0 +1,10 @@
+//go:build !freebsd
+
+package fs
+
+import "github.com/restic/restic/internal/data"
+
+// nodeRestoreACL is a no-op.
+func nodeRestoreACL(_ *data.Node, _ string) error {
+	return nil
+}
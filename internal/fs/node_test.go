@@ -220,7 +220,7 @@ func TestNodeRestoreAt(t *testing.T) {
 				rtest.OK(t, NodeCreateAt(&test, nodePath))
 				// Restore metadata, restoring all xattrs
 				rtest.OK(t, NodeRestoreMetadata(&test, nodePath, func(msg string) { rtest.OK(t, fmt.Errorf("Warning triggered for path: %s: %s", nodePath, msg)) },
-					func(_ string) bool { return true }, ownershipByName))
+					func(_ string) bool { return true }, RestoreMetadataOptions{OwnershipByName: ownershipByName}))
 
 				fs := NewLocal()
 				meta, err := fs.OpenFile(nodePath, O_NOFOLLOW, true)
@@ -297,6 +297,6 @@ func TestNodeRestoreMetadataError(t *testing.T) {
 
 	// This will fail because the target file does not exist
 	err := NodeRestoreMetadata(node, nodePath, func(msg string) { rtest.OK(t, fmt.Errorf("Warning triggered for path: %s: %s", nodePath, msg)) },
-		func(_ string) bool { return true }, false)
+		func(_ string) bool { return true }, RestoreMetadataOptions{})
 	rtest.Assert(t, errors.Is(err, os.ErrNotExist), "failed for an unexpected reason")
 }
@@ -20,13 +20,3 @@ func lchown(name string, node *data.Node, lookupByName bool) error {
 
 	return os.Lchown(name, int(uid), int(gid))
 }
-
-// nodeRestoreGenericAttributes is no-op.
-func nodeRestoreGenericAttributes(node *data.Node, _ string, warn func(msg string)) error {
-	return data.HandleAllUnknownGenericAttributesFound(node.GenericAttributes, warn)
-}
-
-// nodeFillGenericAttributes is a no-op.
-func nodeFillGenericAttributes(_ *data.Node, _ string, _ *ExtendedFileInfo) error {
-	return nil
-}
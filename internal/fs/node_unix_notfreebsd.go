@@ -0,0 +1,15 @@
+//go:build !windows && !freebsd
+
+package fs
+
+import "github.com/restic/restic/internal/data"
+
+// nodeRestoreGenericAttributes is no-op.
+func nodeRestoreGenericAttributes(node *data.Node, _ string, warn func(msg string), _ RestoreMetadataOptions) error {
+	return data.HandleAllUnknownGenericAttributesFound(node.GenericAttributes, warn)
+}
+
+// nodeFillGenericAttributes is a no-op.
+func nodeFillGenericAttributes(_ *data.Node, _ string, _ *ExtendedFileInfo) error {
+	return nil
+}
@@ -188,7 +188,7 @@ func restoreExtendedAttributes(nodeType data.NodeType, path string, eas []extend
 }
 
 // restoreGenericAttributes restores generic attributes for Windows
-func nodeRestoreGenericAttributes(node *data.Node, path string, warn func(msg string)) (err error) {
+func nodeRestoreGenericAttributes(node *data.Node, path string, warn func(msg string), opts RestoreMetadataOptions) (err error) {
 	if len(node.GenericAttributes) == 0 {
 		return nil
 	}
@@ -207,9 +207,12 @@ func nodeRestoreGenericAttributes(node *data.Node, path string, warn func(msg st
 			errs = append(errs, fmt.Errorf("error restoring file attributes for: %s : %v", path, err))
 		}
 	}
-	if windowsAttributes.SecurityDescriptor != nil {
-		if err := setSecurityDescriptor(path, windowsAttributes.SecurityDescriptor); err != nil {
+	if windowsAttributes.SecurityDescriptor != nil && opts.SecurityDescriptors != SecurityDescriptorNone {
+		notRestored, err := setSecurityDescriptor(path, windowsAttributes.SecurityDescriptor, opts.SecurityDescriptors == SecurityDescriptorDACL)
+		if err != nil {
 			errs = append(errs, fmt.Errorf("error restoring security descriptor for: %s : %v", path, err))
+		} else if len(notRestored) > 0 && opts.ReportNotRestored != nil {
+			opts.ReportNotRestored(notRestored)
 		}
 	}
 
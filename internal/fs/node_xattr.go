@@ -13,19 +13,6 @@ import (
 	"github.com/pkg/xattr"
 )
 
-// getxattr retrieves extended attribute data associated with path.
-func getxattr(path, name string) ([]byte, error) {
-	b, err := xattr.LGet(path, name)
-	return b, handleXattrErr(err)
-}
-
-// listxattr retrieves a list of names of extended attributes associated with the
-// given path in the file system.
-func listxattr(path string) ([]string, error) {
-	l, err := xattr.LList(path)
-	return l, handleXattrErr(err)
-}
-
 func isListxattrPermissionError(err error) bool {
 	var xerr *xattr.Error
 	if errors.As(err, &xerr) {
@@ -34,16 +21,6 @@ func isListxattrPermissionError(err error) bool {
 	return false
 }
 
-// setxattr associates name and data together as an attribute of path.
-func setxattr(path, name string, data []byte) error {
-	return handleXattrErr(xattr.LSet(path, name, data))
-}
-
-// removexattr removes the attribute name from path.
-func removexattr(path, name string) error {
-	return handleXattrErr(xattr.LRemove(path, name))
-}
-
 func handleXattrErr(err error) error {
 	switch e := err.(type) {
 	case nil:
@@ -0,0 +1,119 @@
+//go:build freebsd || netbsd
+
+package fs
+
+import (
+	"runtime"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"github.com/pkg/xattr"
+	"golang.org/x/sys/unix"
+)
+
+// The BSDs store extended attributes in separate namespaces. Attributes in the
+// user namespace are stored using their plain name, as done by earlier
+// versions of restic. Attributes in the system namespace are stored with the
+// prefix "system.". Accessing these requires root privileges.
+const systemXattrPrefix = "system."
+
+// ufsACLExtattrs are the attributes in the system namespace which UFS uses to
+// store ACLs. On FreeBSD these are backed up using the ACL syscalls instead,
+// as not all file systems store ACLs as extended attributes.
+var ufsACLExtattrs = map[string]struct{}{
+	"posix1e.acl_access":  {},
+	"posix1e.acl_default": {},
+	"nfs4.acl":            {},
+}
+
+// getxattr retrieves extended attribute data associated with path.
+func getxattr(path, name string) ([]byte, error) {
+	if !strings.HasPrefix(name, systemXattrPrefix) {
+		b, err := xattr.LGet(path, name)
+		return b, handleXattrErr(err)
+	}
+
+	size, err := unix.Lgetxattr(path, name, nil)
+	if err != nil {
+		return nil, handleXattrErr(&xattr.Error{Op: "xattr.get", Path: path, Name: name, Err: err})
+	}
+	buf := make([]byte, size)
+	size, err = unix.Lgetxattr(path, name, buf)
+	if err != nil {
+		return nil, handleXattrErr(&xattr.Error{Op: "xattr.get", Path: path, Name: name, Err: err})
+	}
+	return buf[:size], nil
+}
+
+// listxattr retrieves a list of names of extended attributes associated with the
+// given path in the file system. Attributes in the system namespace are only
+// included if the user is allowed to access them.
+func listxattr(path string) ([]string, error) {
+	l, err := xattr.LList(path)
+	if err != nil {
+		return nil, handleXattrErr(err)
+	}
+
+	// unix.LlistxattrNS does not return errors, thus use the syscall directly
+	size, err := unix.ExtattrListLink(path, unix.EXTATTR_NAMESPACE_SYSTEM, 0, 0)
+	if err == nil && size > 0 {
+		buf := make([]byte, size)
+		size, err = unix.ExtattrListLink(path, unix.EXTATTR_NAMESPACE_SYSTEM, uintptr(unsafe.Pointer(&buf[0])), len(buf))
+		if err == nil {
+			l = appendSystemXattrNames(l, buf[:min(size, len(buf))])
+		}
+	}
+	if err == syscall.EPERM {
+		// only root can access the system namespace
+		err = nil
+	}
+	if err != nil {
+		return nil, handleXattrErr(&xattr.Error{Op: "xattr.list", Path: path, Err: err})
+	}
+	return l, nil
+}
+
+// appendSystemXattrNames parses the list of attribute names in the system
+// namespace returned by extattr_list_link. Each entry consists of a length
+// byte followed by the name.
+func appendSystemXattrNames(l []string, buf []byte) []string {
+	for len(buf) > 0 {
+		n := int(buf[0])
+		if n+1 > len(buf) {
+			break
+		}
+		name := string(buf[1 : n+1])
+		buf = buf[n+1:]
+
+		if _, ok := ufsACLExtattrs[name]; ok && runtime.GOOS == "freebsd" {
+			continue
+		}
+		l = append(l, systemXattrPrefix+name)
+	}
+	return l
+}
+
+// setxattr associates name and data together as an attribute of path.
+func setxattr(path, name string, data []byte) error {
+	if !strings.HasPrefix(name, systemXattrPrefix) {
+		return handleXattrErr(xattr.LSet(path, name, data))
+	}
+	err := unix.Lsetxattr(path, name, data, 0)
+	if err != nil {
+		return handleXattrErr(&xattr.Error{Op: "xattr.set", Path: path, Name: name, Err: err})
+	}
+	return nil
+}
+
+// removexattr removes the attribute name from path.
+func removexattr(path, name string) error {
+	if !strings.HasPrefix(name, systemXattrPrefix) {
+		return handleXattrErr(xattr.LRemove(path, name))
+	}
+	err := unix.Lremovexattr(path, name)
+	if err != nil {
+		return handleXattrErr(&xattr.Error{Op: "xattr.remove", Path: path, Name: name, Err: err})
+	}
+	return nil
+}
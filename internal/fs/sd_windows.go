@@ -69,12 +69,13 @@ func getSecurityDescriptor(filePath string) (securityDescriptor *[]byte, err err
 // This needs admin permissions or SeRestorePrivilege, SeSecurityPrivilege and SeTakeOwnershipPrivilege
 // for setting the full SD.
 // If there are no admin permissions/required privileges, only the DACL from the SD can be set and
-// owner and group will be set based on the current user.
-func setSecurityDescriptor(filePath string, securityDescriptor *[]byte) error {
+// owner and group will be set based on the current user. The parts of the SD which could not be
+// set are returned. If daclOnly is set, only the DACL is set deliberately.
+func setSecurityDescriptor(filePath string, securityDescriptor *[]byte, daclOnly bool) (notRestored []string, err error) {
 	// Set the security descriptor on the file
 	sd, err := securityDescriptorBytesToStruct(*securityDescriptor)
 	if err != nil {
-		return fmt.Errorf("error converting bytes to security descriptor: %w", err)
+		return nil, fmt.Errorf("error converting bytes to security descriptor: %w", err)
 	}
 
 	owner, _, err := sd.Owner()
@@ -102,10 +103,18 @@ func setSecurityDescriptor(filePath string, securityDescriptor *[]byte) error {
 	control, _, err := sd.Control()
 	if err != nil {
 		// This is unlikely to fail if the sd is valid, but handle it.
-		return fmt.Errorf("could not get security descriptor control flags: %w", err)
+		return nil, fmt.Errorf("could not get security descriptor control flags: %w", err)
 	}
+	if daclOnly {
+		if err := setNamedSecurityInfoLow(filePath, dacl, control); err != nil {
+			return nil, fmt.Errorf("set named security info failed with: %w", err)
+		}
+		return nil, nil
+	}
+
 	// store original value to avoid unrelated changes in the error check
 	useLowerPrivileges := lowerPrivileges.Load()
+	lowRestored := useLowerPrivileges
 	if useLowerPrivileges {
 		err = setNamedSecurityInfoLow(filePath, dacl, control)
 	} else {
@@ -113,6 +122,7 @@ func setSecurityDescriptor(filePath string, securityDescriptor *[]byte) error {
 		// See corresponding fallback in getSecurityDescriptor for an explanation
 		if err != nil && isAccessDeniedError(err) {
 			err = setNamedSecurityInfoLow(filePath, dacl, control)
+			lowRestored = true
 		}
 	}
 
@@ -120,12 +130,25 @@ func setSecurityDescriptor(filePath string, securityDescriptor *[]byte) error {
 		if !useLowerPrivileges && isHandlePrivilegeNotHeldError(err) {
 			// If ERROR_PRIVILEGE_NOT_HELD is encountered, fallback to backups/restores using lower non-admin privileges.
 			lowerPrivileges.Store(true)
-			return setSecurityDescriptor(filePath, securityDescriptor)
+			return setSecurityDescriptor(filePath, securityDescriptor, daclOnly)
 		} else {
-			return fmt.Errorf("set named security info failed with: %w", err)
+			return nil, fmt.Errorf("set named security info failed with: %w", err)
+		}
+	}
+
+	if lowRestored {
+		// only the DACL was set, report the remaining parts of the SD
+		if owner != nil {
+			notRestored = append(notRestored, "owner")
+		}
+		if group != nil {
+			notRestored = append(notRestored, "group")
+		}
+		if sacl != nil {
+			notRestored = append(notRestored, "sacl")
 		}
 	}
-	return nil
+	return notRestored, nil
 }
 
 // getNamedSecurityInfoHigh gets the higher level SecurityDescriptor which requires admin permissions.
@@ -47,7 +47,7 @@ func testSecurityDescriptors(t *testing.T, testSDs []string, testPath string) {
 		sdInputBytes, err := base64.StdEncoding.DecodeString(testSD)
 		test.OK(t, errors.Wrapf(err, "Error decoding SD: %s", testPath))
 
-		err = setSecurityDescriptor(testPath, &sdInputBytes)
+		_, err = setSecurityDescriptor(testPath, &sdInputBytes, false)
 		test.OK(t, errors.Wrapf(err, "Error setting file security descriptor for: %s", testPath))
 
 		var sdOutputBytes *[]byte
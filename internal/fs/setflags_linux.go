@@ -19,3 +19,10 @@ func setFlags(f *os.File) error {
 	}
 	return err
 }
+
+// dropPageCache advises the kernel that the given range of f is no longer
+// needed, such that it can be dropped from the page cache. A length of zero
+// refers to the remainder of the file. Only clean pages are dropped.
+func dropPageCache(f *os.File, offset, length int64) error {
+	return unix.Fadvise(int(f.Fd()), offset, length, unix.FADV_DONTNEED)
+}
@@ -9,3 +9,8 @@ import "os"
 func setFlags(_ *os.File) error {
 	return nil
 }
+
+// dropPageCache is only supported on Linux.
+func dropPageCache(_ *os.File, _, _ int64) error {
+	return nil
+}
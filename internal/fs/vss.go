@@ -41,7 +41,7 @@ func getVolumeNameForVolumeMountPoint(mountPoint string) (string, error) {
 // newVssSnapshot creates a new vss snapshot. If creating the snapshots doesn't
 // finish within the timeout an error is returned.
 func newVssSnapshot(_ string,
-	_ string, _ time.Duration, _ volumeFilter, _ ErrorHandler) (vssSnapshot, error) {
+	_ string, _ time.Duration, _ volumeFilter, _ writerSelection, _ ErrorHandler) (vssSnapshot, error) {
 	return vssSnapshot{}, errors.New("VSS snapshots are only supported on windows")
 }
 
@@ -55,3 +55,8 @@ func (p *vssSnapshot) Delete() error {
 func (p *vssSnapshot) GetSnapshotDeviceObject() string {
 	return ""
 }
+
+// vssErrorCode returns the HRESULT contained in err.
+func vssErrorCode(_ error) (uint32, string) {
+	return 0, ""
+}
@@ -361,6 +361,37 @@ func (vss *IVssBackupComponents) GatherWriterMetadata() (*IVSSAsync, error) {
 	return vss.convertToVSSAsync(oleIUnknown, err)
 }
 
+// GetWriterMetadataCount calls the equivalent VSS api.
+func (vss *IVssBackupComponents) GetWriterMetadataCount() (uint, error) {
+	var count uint32
+	result, _, _ := syscall.Syscall(vss.getVTable().getWriterMetadataCount, 2,
+		uintptr(unsafe.Pointer(vss)), uintptr(unsafe.Pointer(&count)), 0)
+
+	return uint(count), newVssErrorIfResultNotOK("GetWriterMetadataCount() failed", HRESULT(result))
+}
+
+// GetWriterMetadata calls the equivalent VSS api.
+func (vss *IVssBackupComponents) GetWriterMetadata(index uint) (*IVssExamineWriterMetadata, error) {
+	var instanceID ole.GUID
+	var metadata *IVssExamineWriterMetadata
+	result, _, _ := syscall.Syscall6(vss.getVTable().getWriterMetadata, 4,
+		uintptr(unsafe.Pointer(vss)), uintptr(index), uintptr(unsafe.Pointer(&instanceID)),
+		uintptr(unsafe.Pointer(&metadata)), 0, 0)
+
+	return metadata, newVssErrorIfResultNotOK("GetWriterMetadata() failed", HRESULT(result))
+}
+
+// DisableWriterClasses calls the equivalent VSS api.
+func (vss *IVssBackupComponents) DisableWriterClasses(classIDs []ole.GUID) error {
+	if len(classIDs) == 0 {
+		return nil
+	}
+	result, _, _ := syscall.Syscall(vss.getVTable().disableWriterClasses, 3,
+		uintptr(unsafe.Pointer(vss)), uintptr(unsafe.Pointer(&classIDs[0])), uintptr(len(classIDs)))
+
+	return newVssErrorIfResultNotOK("DisableWriterClasses() failed", HRESULT(result))
+}
+
 // convertToVSSAsync looks up IVSSAsync interface if given result
 // is a success.
 func (vss *IVssBackupComponents) convertToVSSAsync(
@@ -599,6 +630,52 @@ func (p *vssSnapshotProperties) GetSnapshotDeviceObject() string {
 	return ole.UTF16PtrToString(p.snapshotDeviceObject)
 }
 
+// IVssExamineWriterMetadata VSS api interface.
+type IVssExamineWriterMetadata struct {
+	ole.IUnknown
+}
+
+// IVssExamineWriterMetadataVTable is the vtable for IVssExamineWriterMetadata.
+// nolint:structcheck
+type IVssExamineWriterMetadataVTable struct {
+	ole.IUnknownVtbl
+	getIdentity                 uintptr
+	getFileCounts               uintptr
+	getIncludeFile              uintptr
+	getExcludeFile              uintptr
+	getComponent                uintptr
+	getRestoreMethod            uintptr
+	getAlternateLocationMapping uintptr
+	getBackupSchema             uintptr
+	getDocument                 uintptr
+	saveAsXML                   uintptr
+	loadFromXML                 uintptr
+}
+
+// getVTable returns the vtable for IVssExamineWriterMetadata.
+func (m *IVssExamineWriterMetadata) getVTable() *IVssExamineWriterMetadataVTable {
+	return (*IVssExamineWriterMetadataVTable)(unsafe.Pointer(m.RawVTable))
+}
+
+// GetIdentity calls the equivalent VSS api and returns the class ID and the
+// name of the writer.
+func (m *IVssExamineWriterMetadata) GetIdentity() (ole.GUID, string, error) {
+	var instanceID, classID ole.GUID
+	var name *uint16
+	var usage, source uint32
+	result, _, _ := syscall.Syscall6(m.getVTable().getIdentity, 6,
+		uintptr(unsafe.Pointer(m)), uintptr(unsafe.Pointer(&instanceID)), uintptr(unsafe.Pointer(&classID)),
+		uintptr(unsafe.Pointer(&name)), uintptr(unsafe.Pointer(&usage)), uintptr(unsafe.Pointer(&source)))
+	if err := newVssErrorIfResultNotOK("GetIdentity() failed", HRESULT(result)); err != nil {
+		return ole.GUID{}, "", err
+	}
+	defer func() {
+		_ = ole.SysFreeString((*int16)(unsafe.Pointer(name)))
+	}()
+
+	return classID, ole.BstrToString(name), nil
+}
+
 // UIID_IVSS_ASYNC defines to GUID of IVSSAsync.
 var UIID_IVSS_ASYNC = ole.NewGUID("{507C37B4-CF5B-4e95-B0AF-14EB9767467E}")
 
@@ -886,7 +963,7 @@ func getVolumeNameForVolumeMountPoint(mountPoint string) (string, error) {
 // newVssSnapshot creates a new vss snapshot. If creating the snapshots doesn't
 // finish within the timeout an error is returned.
 func newVssSnapshot(provider string,
-	volume string, timeout time.Duration, filter volumeFilter, msgError ErrorHandler) (vssSnapshot, error) {
+	volume string, timeout time.Duration, filter volumeFilter, writers writerSelection, msgError ErrorHandler) (vssSnapshot, error) {
 	is64Bit, err := isRunningOn64BitWindows()
 	if err != nil {
 		return vssSnapshot{}, newVssTextError(fmt.Sprintf(
@@ -963,6 +1040,11 @@ func newVssSnapshot(provider string,
 		return vssSnapshot{}, err
 	}
 
+	if err := selectWriters(iVssBackupComponents, volume, writers, msgError); err != nil {
+		iVssBackupComponents.Release()
+		return vssSnapshot{}, err
+	}
+
 	if isSupported, err := iVssBackupComponents.IsVolumeSupported(providerID, volume); err != nil {
 		iVssBackupComponents.Release()
 		return vssSnapshot{}, err
@@ -1088,6 +1170,67 @@ func newVssSnapshot(provider string,
 	}, nil
 }
 
+// selectWriters disables the writers which must not take part in the
+// snapshot according to writers. Writers listed in the selection which do not
+// exist are reported using msgError.
+func selectWriters(vss *IVssBackupComponents, volume string, writers writerSelection, msgError ErrorHandler) error {
+	list := writers.include
+	if len(list) == 0 {
+		list = writers.exclude
+	}
+	if len(list) == 0 {
+		return nil
+	}
+
+	count, err := vss.GetWriterMetadataCount()
+	if err != nil {
+		return err
+	}
+
+	found := make(map[string]struct{})
+	var disabled []ole.GUID
+	for i := uint(0); i < count; i++ {
+		metadata, err := vss.GetWriterMetadata(i)
+		if err != nil {
+			return err
+		}
+		classID, name, err := metadata.GetIdentity()
+		metadata.Release()
+		if err != nil {
+			return err
+		}
+
+		listed := false
+		for _, entry := range list {
+			if matchWriter([]string{entry}, classID.String(), name) {
+				found[entry] = struct{}{}
+				listed = true
+			}
+		}
+		// disable the listed writers for exclude-writers and all others for include-writers
+		if listed == (len(writers.exclude) > 0) {
+			disabled = append(disabled, classID)
+		}
+	}
+
+	for _, entry := range list {
+		if _, ok := found[entry]; !ok {
+			msgError(volume, errors.Errorf("VSS writer %q not found", entry))
+		}
+	}
+
+	return vss.DisableWriterClasses(disabled)
+}
+
+// vssErrorCode returns the HRESULT contained in err.
+func vssErrorCode(err error) (uint32, string) {
+	var hresult HRESULT
+	if errors.As(err, &hresult) {
+		return uint32(hresult), hresult.Str()
+	}
+	return 0, ""
+}
+
 // Delete deletes the created snapshot.
 func (p *vssSnapshot) Delete() error {
 	var err error
@@ -0,0 +1,66 @@
+package fs
+
+// Watcher reports changes of files and directories below a set of paths as
+// they happen, for example using inotify on Linux. On platforms without such
+// a mechanism, the paths are scanned for changes regularly.
+type Watcher interface {
+	// Events returns a channel which receives the paths of changed items.
+	// Several changes of the same item may be reported only once. If the
+	// watcher has lost track of changes, the watched path itself is reported.
+	Events() <-chan string
+
+	// Errors returns a channel which receives errors that occur while
+	// watching. The watcher keeps running after an error.
+	Errors() <-chan error
+
+	// Close stops watching and closes both channels.
+	Close() error
+}
+
+// watcherChannels implements the channel handling shared by all watchers.
+type watcherChannels struct {
+	events chan string
+	errors chan error
+	done   chan struct{}
+}
+
+func newWatcherChannels() watcherChannels {
+	return watcherChannels{
+		events: make(chan string, 128),
+		errors: make(chan error, 8),
+		done:   make(chan struct{}),
+	}
+}
+
+func (w *watcherChannels) Events() <-chan string {
+	return w.events
+}
+
+func (w *watcherChannels) Errors() <-chan error {
+	return w.errors
+}
+
+// sendEvent reports path as changed. It returns false if the watcher is closed.
+func (w *watcherChannels) sendEvent(path string) bool {
+	select {
+	case w.events <- path:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+// sendError reports err. Errors are dropped if nobody reads them.
+func (w *watcherChannels) sendError(err error) {
+	select {
+	case w.errors <- err:
+	default:
+	}
+}
+
+// finish closes the channels, it must be called by the goroutine sending
+// events once it has stopped.
+func (w *watcherChannels) finish() {
+	close(w.events)
+	close(w.errors)
+}
@@ -0,0 +1,93 @@
+//go:build darwin && cgo
+
+package fs
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+// fseventsPollInterval is the interval in which the FSEvents database is
+// queried for new changes.
+const fseventsPollInterval = time.Second
+
+// fseventsWatcher watches paths by regularly reading the changes recorded
+// by FSEvents since the last query.
+type fseventsWatcher struct {
+	watcherChannels
+	journal   ChangeJournal
+	positions map[string]string
+	closeOnce sync.Once
+}
+
+// NewWatcher returns a watcher for all files and directories below paths.
+func NewWatcher(paths []string) (Watcher, error) {
+	w := &fseventsWatcher{
+		watcherChannels: newWatcherChannels(),
+		journal:         fsEvents{},
+		positions:       make(map[string]string),
+	}
+
+	for _, path := range paths {
+		path, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		pos, err := w.journal.Position(path)
+		if err != nil {
+			return nil, err
+		}
+		w.positions[path] = pos
+	}
+
+	go w.run()
+	return w, nil
+}
+
+func (w *fseventsWatcher) run() {
+	defer w.finish()
+
+	ticker := time.NewTicker(fseventsPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+		}
+
+		for root, since := range w.positions {
+			pos, err := w.journal.Position(root)
+			if err != nil {
+				w.sendError(err)
+				continue
+			}
+
+			changes := NewChangeSet()
+			err = w.journal.Changes(root, since, changes)
+			if err != nil && !errors.Is(err, ErrChangeJournalPositionInvalid) {
+				w.sendError(err)
+				continue
+			}
+			w.positions[root] = pos
+
+			// if the position became invalid, the changes are unknown
+			if err != nil || !changes.Unchanged(root) {
+				if !w.sendEvent(root) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func (w *fseventsWatcher) Close() error {
+	w.closeOnce.Do(func() {
+		close(w.done)
+	})
+	return nil
+}
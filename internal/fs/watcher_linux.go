@@ -0,0 +1,197 @@
+package fs
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+)
+
+const inotifyDirMask = unix.IN_CREATE | unix.IN_DELETE | unix.IN_MODIFY | unix.IN_CLOSE_WRITE |
+	unix.IN_MOVED_FROM | unix.IN_MOVED_TO | unix.IN_ATTRIB | unix.IN_DELETE_SELF | unix.IN_MOVE_SELF
+
+const inotifyFileMask = unix.IN_MODIFY | unix.IN_CLOSE_WRITE | unix.IN_ATTRIB | unix.IN_DELETE_SELF | unix.IN_MOVE_SELF
+
+// inotifyWatcher watches paths using inotify. As inotify is not recursive, a
+// watch is added for every directory below the watched paths.
+type inotifyWatcher struct {
+	watcherChannels
+	fd    int
+	file  *os.File
+	roots []string
+
+	m sync.Mutex
+	// paths of the watched items by watch descriptor
+	paths     map[int]string
+	closeOnce sync.Once
+}
+
+// NewWatcher returns a watcher for all files and directories below paths.
+func NewWatcher(paths []string) (Watcher, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, errors.Wrap(err, "inotify_init1")
+	}
+
+	w := &inotifyWatcher{
+		watcherChannels: newWatcherChannels(),
+		fd:              fd,
+		// as the file descriptor is non-blocking, reads use the runtime poller
+		// and are interrupted by Close. Calling Fd() on the file would make it
+		// blocking again, thus fd is kept separately.
+		file:  os.NewFile(uintptr(fd), "inotify"),
+		paths: make(map[int]string),
+	}
+
+	for _, path := range paths {
+		path, err = filepath.Abs(path)
+		if err != nil {
+			_ = w.file.Close()
+			return nil, err
+		}
+		if err := w.addRecursive(path, true); err != nil {
+			_ = w.file.Close()
+			return nil, err
+		}
+		w.roots = append(w.roots, path)
+	}
+
+	go w.run()
+	return w, nil
+}
+
+// addRecursive adds watches for path and all directories below it. Errors for
+// items below path are ignored if strict is false, as those may have been
+// removed in the meantime.
+func (w *inotifyWatcher) addRecursive(path string, strict bool) error {
+	return filepath.WalkDir(path, func(item string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if item == path && strict {
+				return err
+			}
+			debug.Log("unable to watch %v: %v", item, err)
+			if d != nil && d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		mask := uint32(inotifyDirMask)
+		if !d.IsDir() {
+			if item != path {
+				// files within directories are covered by the directory watch
+				return nil
+			}
+			mask = inotifyFileMask
+		}
+
+		wd, err := unix.InotifyAddWatch(w.fd, item, mask|unix.IN_DONT_FOLLOW)
+		if err != nil {
+			if item == path && strict {
+				return errors.Wrapf(err, "inotify_add_watch %v", item)
+			}
+			debug.Log("unable to watch %v: %v", item, err)
+			return nil
+		}
+
+		w.m.Lock()
+		w.paths[wd] = item
+		w.m.Unlock()
+		return nil
+	})
+}
+
+func (w *inotifyWatcher) run() {
+	defer w.finish()
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			select {
+			case <-w.done:
+			default:
+				w.sendError(errors.Wrap(err, "read inotify events"))
+			}
+			return
+		}
+
+		if !w.handleEvents(buf[:n]) {
+			return
+		}
+	}
+}
+
+// handleEvents reports all events contained in buf. It returns false if the
+// watcher was closed.
+func (w *inotifyWatcher) handleEvents(buf []byte) bool {
+	for offset := 0; offset+unix.SizeofInotifyEvent <= len(buf); {
+		ev := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+		end := offset + unix.SizeofInotifyEvent + int(ev.Len)
+		if end > len(buf) {
+			w.sendError(errors.Errorf("invalid inotify event: name length %d exceeds the %d bytes read", ev.Len, len(buf)-offset-unix.SizeofInotifyEvent))
+			return true
+		}
+
+		if !w.handleEvent(ev, buf[offset+unix.SizeofInotifyEvent:end]) {
+			return false
+		}
+		offset = end
+	}
+	return true
+}
+
+// handleEvent reports the path of a single event. It returns false if the
+// watcher was closed.
+func (w *inotifyWatcher) handleEvent(ev *unix.InotifyEvent, nameBuf []byte) bool {
+	if ev.Mask&unix.IN_Q_OVERFLOW != 0 {
+		debug.Log("inotify event queue overflowed")
+		for _, root := range w.roots {
+			if !w.sendEvent(root) {
+				return false
+			}
+		}
+		return true
+	}
+
+	w.m.Lock()
+	path, ok := w.paths[int(ev.Wd)]
+	if ev.Mask&unix.IN_IGNORED != 0 {
+		delete(w.paths, int(ev.Wd))
+	}
+	w.m.Unlock()
+	if !ok {
+		return true
+	}
+
+	name := unix.ByteSliceToString(nameBuf)
+	if name != "" {
+		path = filepath.Join(path, name)
+	}
+
+	if ev.Mask&unix.IN_ISDIR != 0 && ev.Mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 {
+		if err := w.addRecursive(path, false); err != nil {
+			w.sendError(err)
+		}
+	}
+
+	if ev.Mask&unix.IN_IGNORED != 0 {
+		return true
+	}
+	return w.sendEvent(path)
+}
+
+func (w *inotifyWatcher) Close() error {
+	var err error
+	w.closeOnce.Do(func() {
+		close(w.done)
+		err = w.file.Close()
+	})
+	return err
+}
@@ -0,0 +1,65 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestWatcher(t *testing.T) {
+	tempdir := rtest.TempDir(t)
+	rtest.OK(t, os.Mkdir(filepath.Join(tempdir, "dir"), 0700))
+
+	w, err := NewWatcher([]string{tempdir})
+	rtest.OK(t, err)
+
+	file := filepath.Join(tempdir, "dir", "file")
+	rtest.OK(t, os.WriteFile(file, []byte("foo"), 0600))
+	waitForEvent(t, w, file)
+
+	// changes within newly created directories are reported
+	subdir := filepath.Join(tempdir, "new")
+	rtest.OK(t, os.Mkdir(subdir, 0700))
+	waitForEvent(t, w, subdir)
+	file = filepath.Join(subdir, "file")
+	rtest.OK(t, os.WriteFile(file, []byte("bar"), 0600))
+	waitForEvent(t, w, file)
+
+	rtest.OK(t, w.Close())
+	// the channels are closed after Close
+	for range w.Events() {
+	}
+}
+
+func TestWatcherMissingPath(t *testing.T) {
+	_, err := NewWatcher([]string{filepath.Join(rtest.TempDir(t), "missing")})
+	rtest.Assert(t, err != nil, "missing error for nonexistent path")
+}
+
+func TestWatcherInvalidEvent(t *testing.T) {
+	w := &inotifyWatcher{watcherChannels: newWatcherChannels(), paths: make(map[int]string)}
+
+	// the name length exceeds the buffer
+	buf := make([]byte, unix.SizeofInotifyEvent+4)
+	ev := (*unix.InotifyEvent)(unsafe.Pointer(&buf[0]))
+	ev.Len = 64
+	rtest.Assert(t, w.handleEvents(buf), "watcher must not stop")
+
+	select {
+	case err := <-w.Errors():
+		rtest.Assert(t, strings.Contains(err.Error(), "invalid inotify event"), "unexpected error %v", err)
+	default:
+		t.Fatal("missing error for invalid event")
+	}
+	select {
+	case path := <-w.Events():
+		t.Fatalf("unexpected event for %v", path)
+	default:
+	}
+}
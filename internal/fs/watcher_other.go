@@ -0,0 +1,10 @@
+//go:build !linux && !windows && !(darwin && cgo)
+
+package fs
+
+// NewWatcher returns a watcher for all files and directories below paths. As
+// the operating system does not report changes, the paths are scanned
+// regularly.
+func NewWatcher(paths []string) (Watcher, error) {
+	return newPollWatcher(paths, pollWatcherInterval)
+}
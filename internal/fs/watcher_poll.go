@@ -0,0 +1,118 @@
+package fs
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/restic/restic/internal/debug"
+)
+
+// pollWatcherInterval is the interval in which the watched paths are scanned
+// for changes if the operating system does not report them.
+const pollWatcherInterval = 10 * time.Second
+
+// pollItem is the metadata of an item which is compared between two scans.
+type pollItem struct {
+	modTime time.Time
+	size    int64
+	mode    os.FileMode
+}
+
+// pollWatcher detects changes by regularly scanning all files and directories
+// below the watched paths and comparing their metadata with the previous scan.
+// It is used on platforms which do not provide a way to watch for changes.
+type pollWatcher struct {
+	watcherChannels
+	roots     []string
+	interval  time.Duration
+	items     map[string]pollItem
+	closeOnce sync.Once
+}
+
+func newPollWatcher(paths []string, interval time.Duration) (*pollWatcher, error) {
+	w := &pollWatcher{
+		watcherChannels: newWatcherChannels(),
+		interval:        interval,
+	}
+
+	for _, path := range paths {
+		path, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := os.Lstat(path); err != nil {
+			return nil, err
+		}
+		w.roots = append(w.roots, path)
+	}
+	w.items = w.scan()
+
+	go w.run()
+	return w, nil
+}
+
+// scan returns the metadata of all items below the watched paths. Items which
+// cannot be read are skipped, as they may have been removed in the meantime.
+func (w *pollWatcher) scan() map[string]pollItem {
+	items := make(map[string]pollItem)
+	for _, root := range w.roots {
+		_ = filepath.WalkDir(root, func(item string, d fs.DirEntry, err error) error {
+			if err != nil {
+				debug.Log("unable to scan %v: %v", item, err)
+				return nil
+			}
+			fi, err := d.Info()
+			if err != nil {
+				debug.Log("unable to scan %v: %v", item, err)
+				return nil
+			}
+			items[item] = pollItem{modTime: fi.ModTime(), size: fi.Size(), mode: fi.Mode()}
+			return nil
+		})
+	}
+	return items
+}
+
+func (w *pollWatcher) run() {
+	defer w.finish()
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+		}
+
+		items := w.scan()
+		for path, item := range items {
+			if old, ok := w.items[path]; ok && old == item {
+				continue
+			}
+			if !w.sendEvent(path) {
+				return
+			}
+		}
+		for path := range w.items {
+			if _, ok := items[path]; ok {
+				continue
+			}
+			if !w.sendEvent(path) {
+				return
+			}
+		}
+		w.items = items
+	}
+}
+
+func (w *pollWatcher) Close() error {
+	w.closeOnce.Do(func() {
+		close(w.done)
+	})
+	return nil
+}
@@ -0,0 +1,59 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func waitForEvent(t *testing.T, w Watcher, want string) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case path := <-w.Events():
+			if path == want {
+				return
+			}
+		case err := <-w.Errors():
+			t.Fatal(err)
+		case <-timeout:
+			t.Fatalf("timeout waiting for event for %v", want)
+		}
+	}
+}
+
+func TestPollWatcher(t *testing.T) {
+	tempdir := rtest.TempDir(t)
+	file := filepath.Join(tempdir, "file")
+	rtest.OK(t, os.WriteFile(file, []byte("foo"), 0600))
+	removed := filepath.Join(tempdir, "removed")
+	rtest.OK(t, os.WriteFile(removed, []byte("bar"), 0600))
+
+	w, err := newPollWatcher([]string{tempdir}, 10*time.Millisecond)
+	rtest.OK(t, err)
+
+	rtest.OK(t, os.WriteFile(file, []byte("foo and more"), 0600))
+	waitForEvent(t, w, file)
+
+	created := filepath.Join(tempdir, "dir", "created")
+	rtest.OK(t, os.MkdirAll(filepath.Dir(created), 0700))
+	rtest.OK(t, os.WriteFile(created, []byte("baz"), 0600))
+	waitForEvent(t, w, created)
+
+	rtest.OK(t, os.Remove(removed))
+	waitForEvent(t, w, removed)
+
+	rtest.OK(t, w.Close())
+	// the channels are closed after Close
+	for range w.Events() {
+	}
+}
+
+func TestPollWatcherMissingPath(t *testing.T) {
+	_, err := newPollWatcher([]string{filepath.Join(rtest.TempDir(t), "missing")}, time.Second)
+	rtest.Assert(t, err != nil, "missing error for nonexistent path")
+}
@@ -0,0 +1,151 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+)
+
+const readDirectoryChangesMask = windows.FILE_NOTIFY_CHANGE_FILE_NAME | windows.FILE_NOTIFY_CHANGE_DIR_NAME |
+	windows.FILE_NOTIFY_CHANGE_ATTRIBUTES | windows.FILE_NOTIFY_CHANGE_SIZE |
+	windows.FILE_NOTIFY_CHANGE_LAST_WRITE | windows.FILE_NOTIFY_CHANGE_CREATION |
+	windows.FILE_NOTIFY_CHANGE_SECURITY
+
+// readDirectoryChangesWatcher watches paths using ReadDirectoryChangesW. A
+// directory is watched including its subtree, a file is watched using its
+// parent directory.
+type readDirectoryChangesWatcher struct {
+	watcherChannels
+
+	handles   []windows.Handle
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// NewWatcher returns a watcher for all files and directories below paths.
+func NewWatcher(paths []string) (Watcher, error) {
+	w := &readDirectoryChangesWatcher{
+		watcherChannels: newWatcherChannels(),
+	}
+
+	type watch struct {
+		handle windows.Handle
+		dir    string
+		// name of the watched file within dir, empty for directories
+		file string
+	}
+	var watches []watch
+
+	for _, path := range paths {
+		path, err := filepath.Abs(path)
+		if err != nil {
+			w.closeHandles()
+			return nil, err
+		}
+		fi, err := os.Stat(path)
+		if err != nil {
+			w.closeHandles()
+			return nil, err
+		}
+
+		wt := watch{dir: path}
+		if !fi.IsDir() {
+			wt.dir, wt.file = filepath.Split(path)
+		}
+
+		p, err := windows.UTF16PtrFromString(fixpath(wt.dir))
+		if err != nil {
+			w.closeHandles()
+			return nil, err
+		}
+		wt.handle, err = windows.CreateFile(p, windows.FILE_LIST_DIRECTORY,
+			windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE,
+			nil, windows.OPEN_EXISTING, windows.FILE_FLAG_BACKUP_SEMANTICS, 0)
+		if err != nil {
+			w.closeHandles()
+			return nil, errors.Wrapf(err, "open %v", wt.dir)
+		}
+		w.handles = append(w.handles, wt.handle)
+		watches = append(watches, wt)
+	}
+
+	for _, wt := range watches {
+		w.wg.Add(1)
+		go func() {
+			defer w.wg.Done()
+			w.run(wt.handle, wt.dir, wt.file)
+		}()
+	}
+	go func() {
+		w.wg.Wait()
+		w.finish()
+	}()
+
+	return w, nil
+}
+
+// run reads the changes for a single directory until the watcher is closed.
+func (w *readDirectoryChangesWatcher) run(handle windows.Handle, dir, file string) {
+	buf := make([]byte, 64*1024)
+	for {
+		var n uint32
+		err := windows.ReadDirectoryChanges(handle, &buf[0], uint32(len(buf)), file == "",
+			readDirectoryChangesMask, &n, nil, 0)
+		select {
+		case <-w.done:
+			return
+		default:
+		}
+		if err != nil {
+			w.sendError(errors.Wrapf(err, "ReadDirectoryChanges %v", dir))
+			return
+		}
+
+		if n == 0 {
+			// the buffer overflowed, the changes are lost
+			debug.Log("ReadDirectoryChanges buffer overflowed for %v", dir)
+			if !w.sendEvent(filepath.Join(dir, file)) {
+				return
+			}
+			continue
+		}
+
+		for offset := uint32(0); ; {
+			info := (*windows.FileNotifyInformation)(unsafe.Pointer(&buf[offset]))
+			name := windows.UTF16ToString(unsafe.Slice(&info.FileName, info.FileNameLength/2))
+
+			if file == "" || strings.EqualFold(name, file) {
+				if !w.sendEvent(filepath.Join(dir, name)) {
+					return
+				}
+			}
+
+			if info.NextEntryOffset == 0 {
+				break
+			}
+			offset += info.NextEntryOffset
+		}
+	}
+}
+
+func (w *readDirectoryChangesWatcher) closeHandles() {
+	for _, h := range w.handles {
+		_ = windows.CancelIoEx(h, nil)
+		_ = windows.CloseHandle(h)
+	}
+}
+
+func (w *readDirectoryChangesWatcher) Close() error {
+	w.closeOnce.Do(func() {
+		close(w.done)
+		w.closeHandles()
+	})
+	return nil
+}
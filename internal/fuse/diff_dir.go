@@ -0,0 +1,292 @@
+//go:build darwin || freebsd || linux
+
+package fuse
+
+import (
+	"context"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/anacrolix/fuse"
+	"github.com/anacrolix/fuse/fs"
+
+	"github.com/restic/restic/internal/data"
+	"github.com/restic/restic/internal/debug"
+)
+
+// diffDirName is the name of the directory in the root of the mount which
+// contains the differences between two snapshots.
+const diffDirName = "diff"
+
+// diffRootDir is the "diff" directory in the root of the mount. It has no
+// entries of its own, looking up a name of the form "<snapA>..<snapB>" returns
+// a directory containing the items added or modified from snapA to snapB.
+type diffRootDir struct {
+	root  *Root
+	inode uint64
+	cache treeCache
+}
+
+// ensure that *diffRootDir implements these interfaces
+var _ = fs.HandleReadDirAller(&diffRootDir{})
+var _ = fs.NodeStringLookuper(&diffRootDir{})
+
+func newDiffRootDir(root *Root) *diffRootDir {
+	return &diffRootDir{
+		root:  root,
+		inode: inodeFromName(rootInode, diffDirName),
+		cache: *newTreeCache(),
+	}
+}
+
+func (d *diffRootDir) Attr(_ context.Context, attr *fuse.Attr) error {
+	attr.Inode = d.inode
+	attr.Mode = os.ModeDir | 0555
+	attr.Uid = d.root.uid
+	attr.Gid = d.root.gid
+	return nil
+}
+
+// ReadDirAll only returns "." and "..", the possible pairs of snapshots are
+// not listed.
+func (d *diffRootDir) ReadDirAll(_ context.Context) ([]fuse.Dirent, error) {
+	return []fuse.Dirent{
+		{
+			Inode: d.inode,
+			Name:  ".",
+			Type:  fuse.DT_Dir,
+		},
+		{
+			Inode: rootInode,
+			Name:  "..",
+			Type:  fuse.DT_Dir,
+		},
+	}, nil
+}
+
+func (d *diffRootDir) Lookup(ctx context.Context, name string) (fs.Node, error) {
+	debug.Log("Lookup(%v)", name)
+
+	from, to, ok := strings.Cut(name, "..")
+	if !ok || from == "" || to == "" {
+		return nil, syscall.ENOENT
+	}
+
+	return d.cache.lookupOrCreate(name, -1, func(forget forgetFn) (fs.Node, error) {
+		fromNode, err := d.loadSnapshotNode(ctx, from)
+		if err != nil {
+			return nil, err
+		}
+		toNode, err := d.loadSnapshotNode(ctx, to)
+		if err != nil {
+			return nil, err
+		}
+		return newDiffDir(d.root, forget, inodeFromName(d.inode, name), d.inode, fromNode, toNode), nil
+	})
+}
+
+// loadSnapshotNode returns a directory node for the snapshot (or subfolder of
+// a snapshot) described by s.
+func (d *diffRootDir) loadSnapshotNode(ctx context.Context, s string) (*data.Node, error) {
+	sn, subfolder, err := data.FindSnapshot(ctx, d.root.repo, d.root.repo, s)
+	if err != nil {
+		debug.Log("  unable to find snapshot %v: %v", s, err)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, syscall.ENOENT
+	}
+
+	tree, err := data.FindTreeDirectory(ctx, d.root.repo, sn.Tree, subfolder)
+	if err != nil {
+		debug.Log("  unable to find subfolder %v: %v", subfolder, err)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, syscall.ENOENT
+	}
+
+	return &data.Node{
+		Type:       data.NodeTypeDir,
+		AccessTime: sn.Time,
+		ModTime:    sn.Time,
+		ChangeTime: sn.Time,
+		Mode:       os.ModeDir | 0555,
+		Subtree:    tree,
+	}, nil
+}
+
+// diffDir is a directory which only contains the items which were added or
+// modified between the directories from and to. Directories which only
+// exist in to are represented by a plain dir, as all of their content is new.
+type diffDir struct {
+	root        *Root
+	forget      forgetFn
+	inode       uint64
+	parentInode uint64
+	from        *data.Node
+	to          *data.Node
+	items       map[string]*data.Node
+	fromItems   map[string]*data.Node
+	m           sync.Mutex
+	cache       treeCache
+}
+
+// ensure that *diffDir implements these interfaces
+var _ = fs.HandleReadDirAller(&diffDir{})
+var _ = fs.NodeForgetter(&diffDir{})
+var _ = fs.NodeStringLookuper(&diffDir{})
+
+func newDiffDir(root *Root, forget forgetFn, inode, parentInode uint64, from, to *data.Node) *diffDir {
+	debug.Log("new diff dir for %v (%v -> %v)", to.Name, from.Subtree, to.Subtree)
+	return &diffDir{
+		root:        root,
+		forget:      forget,
+		inode:       inode,
+		parentInode: parentInode,
+		from:        from,
+		to:          to,
+		cache:       *newTreeCache(),
+	}
+}
+
+func (d *diffDir) open(ctx context.Context) error {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	if d.items != nil {
+		return nil
+	}
+
+	toItems, err := loadDirItems(ctx, d.root.repo, *d.to.Subtree)
+	if err != nil {
+		return err
+	}
+	fromItems, err := loadDirItems(ctx, d.root.repo, *d.from.Subtree)
+	if err != nil {
+		return err
+	}
+
+	items := make(map[string]*data.Node)
+	for name, node := range toItems {
+		if nodeChanged(fromItems[name], node) {
+			items[name] = node
+		}
+	}
+	d.items = items
+	d.fromItems = fromItems
+	return nil
+}
+
+// nodeChanged returns true if node was added or modified compared to old,
+// which may be nil. Changes of metadata only are ignored.
+func nodeChanged(old, node *data.Node) bool {
+	if old == nil || old.Type != node.Type {
+		return true
+	}
+
+	switch node.Type {
+	case data.NodeTypeDir:
+		return old.Subtree == nil || node.Subtree == nil || !old.Subtree.Equal(*node.Subtree)
+	case data.NodeTypeFile:
+		return !slices.Equal(old.Content, node.Content)
+	case data.NodeTypeSymlink:
+		return old.LinkTarget != node.LinkTarget
+	default:
+		return old.Device != node.Device
+	}
+}
+
+func (d *diffDir) Attr(_ context.Context, a *fuse.Attr) error {
+	a.Inode = d.inode
+	a.Mode = d.root.perms(os.ModeDir | d.to.Mode)
+
+	d.root.setOwner(a, d.to.UID, d.to.GID)
+	a.Atime = d.to.AccessTime
+	a.Ctime = d.to.ChangeTime
+	a.Mtime = d.to.ModTime
+
+	a.Nlink = 2
+	for _, node := range d.items {
+		if node.Type == data.NodeTypeDir {
+			a.Nlink++
+		}
+	}
+
+	return nil
+}
+
+func (d *diffDir) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
+	debug.Log("ReadDirAll()")
+	err := d.open(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]fuse.Dirent, 0, len(d.items)+2)
+	ret = append(ret, fuse.Dirent{
+		Inode: d.inode,
+		Name:  ".",
+		Type:  fuse.DT_Dir,
+	})
+	ret = append(ret, fuse.Dirent{
+		Inode: d.parentInode,
+		Name:  "..",
+		Type:  fuse.DT_Dir,
+	})
+
+	for name, node := range d.items {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		ret = append(ret, fuse.Dirent{
+			Inode: inodeFromNode(d.inode, node),
+			Type:  direntType(node),
+			Name:  name,
+		})
+	}
+
+	return ret, nil
+}
+
+func (d *diffDir) Lookup(ctx context.Context, name string) (fs.Node, error) {
+	debug.Log("Lookup(%v)", name)
+
+	err := d.open(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.cache.lookupOrCreate(name, -1, func(forget forgetFn) (fs.Node, error) {
+		node, ok := d.items[name]
+		if !ok {
+			debug.Log("  Lookup(%v) -> not found", name)
+			return nil, syscall.ENOENT
+		}
+
+		inode := inodeFromNode(d.inode, node)
+		switch node.Type {
+		case data.NodeTypeDir:
+			if old := d.fromItems[name]; old != nil && old.Type == data.NodeTypeDir && old.Subtree != nil && node.Subtree != nil {
+				return newDiffDir(d.root, forget, inode, d.inode, old, node), nil
+			}
+			return newDir(d.root, forget, inode, d.inode, node)
+		case data.NodeTypeFile:
+			return newFile(d.root, forget, inode, node)
+		case data.NodeTypeSymlink:
+			return newLink(d.root, forget, inode, node)
+		case data.NodeTypeDev, data.NodeTypeCharDev, data.NodeTypeFifo, data.NodeTypeSocket:
+			return newOther(d.root, forget, inode, node)
+		default:
+			debug.Log("  node %v has unknown type %v", name, node.Type)
+			return nil, syscall.ENOENT
+		}
+	})
+}
+
+func (d *diffDir) Forget() {
+	d.forget()
+}
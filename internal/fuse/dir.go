@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 	"slices"
+	"strings"
 	"sync"
 	"syscall"
 
@@ -21,9 +22,14 @@ import (
 
 // Statically ensure that *dir implement those interface
 var _ = fs.HandleReadDirAller(&dir{})
+var _ = fs.NodeCreater(&dir{})
 var _ = fs.NodeForgetter(&dir{})
 var _ = fs.NodeGetxattrer(&dir{})
 var _ = fs.NodeListxattrer(&dir{})
+var _ = fs.NodeMkdirer(&dir{})
+var _ = fs.NodeRemover(&dir{})
+var _ = fs.NodeRenamer(&dir{})
+var _ = fs.NodeSetattrer(&dir{})
 var _ = fs.NodeStringLookuper(&dir{})
 
 type dir struct {
@@ -35,6 +41,11 @@ type dir struct {
 	node        *data.Node
 	m           sync.Mutex
 	cache       treeCache
+
+	// path of the directory in the scratch directory, empty for read-only mounts
+	scratch string
+	// the directory only exists in the scratch directory
+	scratchOnly bool
 }
 
 func cleanupNodeName(name string) string {
@@ -83,6 +94,21 @@ func replaceSpecialNodes(ctx context.Context, repo restic.BlobLoader, node *data
 	return tree, nil
 }
 
+// newScratchDir returns a directory which was created in the scratch directory.
+func newScratchDir(root *Root, forget forgetFn, inode, parentInode uint64, path string) *dir {
+	debug.Log("new scratch dir %v", path)
+	return &dir{
+		root:        root,
+		forget:      forget,
+		node:        &data.Node{Name: filepath.Base(path), Type: data.NodeTypeDir},
+		inode:       inode,
+		parentInode: parentInode,
+		cache:       *newTreeCache(),
+		scratch:     path,
+		scratchOnly: true,
+	}
+}
+
 func newDirFromSnapshot(root *Root, forget forgetFn, inode uint64, snapshot *data.Snapshot) (*dir, error) {
 	debug.Log("new dir for snapshot %v (%v)", snapshot.ID(), snapshot.Tree)
 	return &dir{
@@ -95,8 +121,9 @@ func newDirFromSnapshot(root *Root, forget forgetFn, inode uint64, snapshot *dat
 			Mode:       os.ModeDir | 0555,
 			Subtree:    snapshot.Tree,
 		},
-		inode: inode,
-		cache: *newTreeCache(),
+		inode:   inode,
+		cache:   *newTreeCache(),
+		scratch: root.snapshotScratchDir(snapshot),
 	}, nil
 }
 
@@ -107,49 +134,69 @@ func (d *dir) open(ctx context.Context) error {
 	if d.items != nil {
 		return nil
 	}
+	if d.scratchOnly {
+		d.items = make(map[string]*data.Node)
+		return nil
+	}
 
 	debug.Log("open dir %v (%v)", d.node.Name, d.node.Subtree)
 
-	tree, err := data.LoadTree(ctx, d.root.repo, *d.node.Subtree)
+	items, err := loadDirItems(ctx, d.root.repo, *d.node.Subtree)
+	if err != nil {
+		return err
+	}
+	d.items = items
+	return nil
+}
+
+// loadDirItems loads the tree with the given id and returns its items by name.
+func loadDirItems(ctx context.Context, repo restic.BlobLoader, id restic.ID) (map[string]*data.Node, error) {
+	tree, err := data.LoadTree(ctx, repo, id)
 	if err != nil {
-		debug.Log("  error loading tree %v: %v", d.node.Subtree, err)
-		return unwrapCtxCanceled(err)
+		debug.Log("  error loading tree %v: %v", id, err)
+		return nil, unwrapCtxCanceled(err)
 	}
 	items := make(map[string]*data.Node)
 	for item := range tree {
 		if item.Error != nil {
-			return unwrapCtxCanceled(item.Error)
+			return nil, unwrapCtxCanceled(item.Error)
 		}
 		if ctx.Err() != nil {
-			return ctx.Err()
+			return nil, ctx.Err()
 		}
 		n := item.Node
 
-		nodes, err := replaceSpecialNodes(ctx, d.root.repo, n)
+		nodes, err := replaceSpecialNodes(ctx, repo, n)
 		if err != nil {
 			debug.Log("  replaceSpecialNodes(%v) failed: %v", n, err)
-			return err
+			return nil, err
 		}
 		for item := range nodes {
 			if item.Error != nil {
-				return unwrapCtxCanceled(item.Error)
+				return nil, unwrapCtxCanceled(item.Error)
 			}
 			items[cleanupNodeName(item.Node.Name)] = item.Node
 		}
 	}
-	d.items = items
-	return nil
+	return items, nil
 }
 
 func (d *dir) Attr(_ context.Context, a *fuse.Attr) error {
 	debug.Log("Attr()")
+	if d.scratchOnly {
+		fi, err := os.Lstat(d.scratch)
+		if err != nil {
+			return toErrno(err)
+		}
+		attrFromFileInfo(d.root, d.inode, fi, a)
+		a.Inode = d.inode
+		return nil
+	}
+
 	a.Inode = d.inode
-	a.Mode = os.ModeDir | d.node.Mode
+	a.Mode = d.root.perms(os.ModeDir | d.node.Mode)
 
-	if !d.root.cfg.OwnerIsRoot {
-		a.Uid = d.node.UID
-		a.Gid = d.node.GID
-	}
+	d.root.setOwner(a, d.node.UID, d.node.GID)
 	a.Atime = d.node.AccessTime
 	a.Ctime = d.node.ChangeTime
 	a.Mtime = d.node.ModTime
@@ -191,24 +238,34 @@ func (d *dir) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
 		Type:  fuse.DT_Dir,
 	})
 
+	scratchItems, whiteouts, err := d.readScratch()
+	if err != nil {
+		return nil, err
+	}
+
 	for _, node := range d.items {
 		if ctx.Err() != nil {
 			return nil, ctx.Err()
 		}
 
 		name := cleanupNodeName(node.Name)
-		var typ fuse.DirentType
-		switch node.Type {
-		case data.NodeTypeDir:
-			typ = fuse.DT_Dir
-		case data.NodeTypeFile:
-			typ = fuse.DT_File
-		case data.NodeTypeSymlink:
-			typ = fuse.DT_Link
+		if _, ok := scratchItems[name]; ok || whiteouts[name] {
+			continue
 		}
-
 		ret = append(ret, fuse.Dirent{
 			Inode: inodeFromNode(d.inode, node),
+			Type:  direntType(node),
+			Name:  name,
+		})
+	}
+
+	for name, typ := range scratchItems {
+		inode := inodeFromName(d.inode, name)
+		if node, ok := d.items[name]; ok && !whiteouts[name] {
+			inode = inodeFromNode(d.inode, node)
+		}
+		ret = append(ret, fuse.Dirent{
+			Inode: inode,
 			Type:  typ,
 			Name:  name,
 		})
@@ -217,6 +274,56 @@ func (d *dir) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
 	return ret, nil
 }
 
+// direntType returns the type of the directory entry for node.
+func direntType(node *data.Node) fuse.DirentType {
+	switch node.Type {
+	case data.NodeTypeDir:
+		return fuse.DT_Dir
+	case data.NodeTypeFile:
+		return fuse.DT_File
+	case data.NodeTypeSymlink:
+		return fuse.DT_Link
+	}
+	return fuse.DT_Unknown
+}
+
+// readScratch returns the types of the items in the scratch directory and the
+// names of the removed items of the snapshot.
+func (d *dir) readScratch() (map[string]fuse.DirentType, map[string]bool, error) {
+	if d.scratch == "" {
+		return nil, nil, nil
+	}
+
+	entries, err := os.ReadDir(d.scratch)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, toErrno(err)
+	}
+
+	items := make(map[string]fuse.DirentType)
+	whiteouts := make(map[string]bool)
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, whiteoutPrefix) {
+			whiteouts[strings.TrimPrefix(name, whiteoutPrefix)] = true
+			continue
+		}
+		if isScratchInternal(name) {
+			continue
+		}
+
+		switch {
+		case entry.IsDir():
+			items[name] = fuse.DT_Dir
+		case entry.Type().IsRegular():
+			items[name] = fuse.DT_File
+		}
+	}
+	return items, whiteouts, nil
+}
+
 func (d *dir) Lookup(ctx context.Context, name string) (fs.Node, error) {
 	debug.Log("Lookup(%v)", name)
 
@@ -227,6 +334,24 @@ func (d *dir) Lookup(ctx context.Context, name string) (fs.Node, error) {
 
 	return d.cache.lookupOrCreate(name, -1, func(forget forgetFn) (fs.Node, error) {
 		node, ok := d.items[name]
+		if d.scratch != "" {
+			if isScratchInternal(name) {
+				return nil, syscall.ENOENT
+			}
+			whiteout := hasWhiteout(d.scratch, name)
+			if fi, err := os.Lstat(d.scratchPath(name)); err == nil {
+				// items in the scratch directory replace items of the snapshot
+				// which were removed or have a different type
+				overlay := ok && !whiteout && ((fi.IsDir() && node.Type == data.NodeTypeDir) ||
+					(fi.Mode().IsRegular() && node.Type == data.NodeTypeFile))
+				if !overlay {
+					return d.newScratchNode(forget, name, fi)
+				}
+			} else if whiteout {
+				ok = false
+			}
+		}
+
 		if !ok {
 			debug.Log("  Lookup(%v) -> not found", name)
 			return nil, syscall.ENOENT
@@ -234,9 +359,17 @@ func (d *dir) Lookup(ctx context.Context, name string) (fs.Node, error) {
 		inode := inodeFromNode(d.inode, node)
 		switch node.Type {
 		case data.NodeTypeDir:
-			return newDir(d.root, forget, inode, d.inode, node)
+			sub, err := newDir(d.root, forget, inode, d.inode, node)
+			if err == nil {
+				sub.scratch = d.scratchPath(name)
+			}
+			return sub, err
 		case data.NodeTypeFile:
-			return newFile(d.root, forget, inode, node)
+			f, err := newFile(d.root, forget, inode, node)
+			if err == nil {
+				f.scratch = d.scratchPath(name)
+			}
+			return f, err
 		case data.NodeTypeSymlink:
 			return newLink(d.root, forget, inode, node)
 		case data.NodeTypeDev, data.NodeTypeCharDev, data.NodeTypeFifo, data.NodeTypeSocket:
@@ -248,6 +381,206 @@ func (d *dir) Lookup(ctx context.Context, name string) (fs.Node, error) {
 	})
 }
 
+// scratchPath returns the path of the item name in the scratch directory.
+func (d *dir) scratchPath(name string) string {
+	if d.scratch == "" {
+		return ""
+	}
+	return filepath.Join(d.scratch, name)
+}
+
+// newScratchNode returns the node for an item which only exists in the
+// scratch directory.
+func (d *dir) newScratchNode(forget forgetFn, name string, fi os.FileInfo) (fs.Node, error) {
+	inode := inodeFromName(d.inode, name)
+	switch {
+	case fi.IsDir():
+		return newScratchDir(d.root, forget, inode, d.inode, d.scratchPath(name)), nil
+	case fi.Mode().IsRegular():
+		return newScratchFile(d.root, forget, inode, d.scratchPath(name)), nil
+	default:
+		debug.Log("  scratch item %v has unsupported type %v", name, fi.Mode())
+		return nil, syscall.ENOENT
+	}
+}
+
+// checkWritable returns an error if name cannot be created in the directory.
+func (d *dir) checkWritable(name string) error {
+	if d.scratch == "" {
+		return syscall.EROFS
+	}
+	if isScratchInternal(name) {
+		return syscall.EPERM
+	}
+	return nil
+}
+
+func (d *dir) Create(ctx context.Context, req *fuse.CreateRequest, _ *fuse.CreateResponse) (fs.Node, fs.Handle, error) {
+	debug.Log("Create(%v)", req.Name)
+	if err := d.checkWritable(req.Name); err != nil {
+		return nil, nil, err
+	}
+
+	d.root.scratchMu.Lock()
+	defer d.root.scratchMu.Unlock()
+
+	if err := os.MkdirAll(d.scratch, 0700); err != nil {
+		return nil, nil, toErrno(err)
+	}
+	f, err := os.OpenFile(d.scratchPath(req.Name), os.O_RDWR|os.O_CREATE|os.O_EXCL, req.Mode.Perm()&^req.Umask)
+	if err != nil {
+		return nil, nil, toErrno(err)
+	}
+
+	d.cache.remove(req.Name)
+	node, err := d.Lookup(ctx, req.Name)
+	if err != nil {
+		_ = f.Close()
+		return nil, nil, err
+	}
+	return node, &scratchHandle{file: f}, nil
+}
+
+func (d *dir) Mkdir(ctx context.Context, req *fuse.MkdirRequest) (fs.Node, error) {
+	debug.Log("Mkdir(%v)", req.Name)
+	if err := d.checkWritable(req.Name); err != nil {
+		return nil, err
+	}
+
+	d.root.scratchMu.Lock()
+	defer d.root.scratchMu.Unlock()
+
+	if err := os.MkdirAll(d.scratch, 0700); err != nil {
+		return nil, toErrno(err)
+	}
+	if err := os.Mkdir(d.scratchPath(req.Name), req.Mode.Perm()&^req.Umask); err != nil {
+		return nil, toErrno(err)
+	}
+
+	d.cache.remove(req.Name)
+	return d.Lookup(ctx, req.Name)
+}
+
+// snapshotItem returns the item name of the snapshot unless it was removed.
+func (d *dir) snapshotItem(name string) (*data.Node, bool) {
+	node, ok := d.items[name]
+	if !ok || hasWhiteout(d.scratch, name) {
+		return nil, false
+	}
+	return node, true
+}
+
+func (d *dir) Remove(ctx context.Context, req *fuse.RemoveRequest) error {
+	debug.Log("Remove(%v)", req.Name)
+	if err := d.checkWritable(req.Name); err != nil {
+		return err
+	}
+
+	child, err := d.Lookup(ctx, req.Name)
+	if err != nil {
+		return err
+	}
+	childDir, isDir := child.(*dir)
+	switch {
+	case req.Dir && !isDir:
+		return syscall.ENOTDIR
+	case !req.Dir && isDir:
+		return syscall.EISDIR
+	case isDir:
+		entries, err := childDir.ReadDirAll(ctx)
+		if err != nil {
+			return err
+		}
+		// ignore "." and ".."
+		if len(entries) > 2 {
+			return syscall.ENOTEMPTY
+		}
+	}
+
+	d.root.scratchMu.Lock()
+	defer d.root.scratchMu.Unlock()
+
+	if err := os.RemoveAll(d.scratchPath(req.Name)); err != nil {
+		return toErrno(err)
+	}
+	if _, ok := d.items[req.Name]; ok {
+		if err := addWhiteout(d.scratch, req.Name); err != nil {
+			return toErrno(err)
+		}
+	}
+
+	d.cache.remove(req.Name)
+	return nil
+}
+
+// Rename moves items within the scratch directory. Items of the snapshot
+// cannot be moved, which is reported as a rename across filesystems. Tools
+// like mv then copy the item instead.
+func (d *dir) Rename(ctx context.Context, req *fuse.RenameRequest, newDir fs.Node) error {
+	debug.Log("Rename(%v -> %v)", req.OldName, req.NewName)
+	if err := d.checkWritable(req.OldName); err != nil {
+		return err
+	}
+	target, ok := newDir.(*dir)
+	if !ok {
+		return syscall.EXDEV
+	}
+	if err := target.checkWritable(req.NewName); err != nil {
+		return err
+	}
+	if err := d.open(ctx); err != nil {
+		return err
+	}
+	if err := target.open(ctx); err != nil {
+		return err
+	}
+
+	d.root.scratchMu.Lock()
+	defer d.root.scratchMu.Unlock()
+
+	fi, err := os.Lstat(d.scratchPath(req.OldName))
+	if err != nil {
+		return syscall.EXDEV
+	}
+	if node, ok := d.snapshotItem(req.OldName); ok && fi.IsDir() && node.Type == data.NodeTypeDir {
+		// the directory is merged with the snapshot
+		return syscall.EXDEV
+	}
+	if node, ok := target.snapshotItem(req.NewName); ok && node.Type == data.NodeTypeDir {
+		return syscall.EXDEV
+	}
+
+	if err := os.MkdirAll(target.scratch, 0700); err != nil {
+		return toErrno(err)
+	}
+	if err := os.Rename(d.scratchPath(req.OldName), target.scratchPath(req.NewName)); err != nil {
+		return toErrno(err)
+	}
+	if _, ok := d.items[req.OldName]; ok {
+		if err := addWhiteout(d.scratch, req.OldName); err != nil {
+			return toErrno(err)
+		}
+	}
+
+	d.cache.remove(req.OldName)
+	target.cache.remove(req.NewName)
+	return nil
+}
+
+func (d *dir) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp *fuse.SetattrResponse) error {
+	if d.scratch == "" {
+		return syscall.EROFS
+	}
+	if !d.scratchOnly {
+		// the metadata of directories in the snapshot cannot be changed
+		return syscall.EPERM
+	}
+	if err := setattr(d.scratch, req); err != nil {
+		return err
+	}
+	return d.Attr(ctx, &resp.Attr)
+}
+
 func (d *dir) Listxattr(_ context.Context, req *fuse.ListxattrRequest, resp *fuse.ListxattrResponse) error {
 	nodeToXattrList(d.node, req, resp)
 	return nil
@@ -4,7 +4,10 @@ package fuse
 
 import (
 	"context"
+	"os"
+	"path/filepath"
 	"sort"
+	"syscall"
 
 	"github.com/restic/restic/internal/data"
 	"github.com/restic/restic/internal/debug"
@@ -21,15 +24,19 @@ const blockSize = 512
 // Statically ensure that *file and *openFile implement the given interfaces
 var _ = fs.HandleReader(&openFile{})
 var _ = fs.NodeForgetter(&file{})
+var _ = fs.NodeFsyncer(&file{})
 var _ = fs.NodeGetxattrer(&file{})
 var _ = fs.NodeListxattrer(&file{})
 var _ = fs.NodeOpener(&file{})
+var _ = fs.NodeSetattrer(&file{})
 
 type file struct {
 	root   *Root
 	forget forgetFn
 	node   *data.Node
 	inode  uint64
+	// path of the copy in the scratch directory, empty for read-only mounts
+	scratch string
 }
 
 type openFile struct {
@@ -48,10 +55,14 @@ func newFile(root *Root, forget forgetFn, inode uint64, node *data.Node) (fusefi
 	}, nil
 }
 
-func (f *file) Attr(_ context.Context, a *fuse.Attr) error {
+func (f *file) Attr(ctx context.Context, a *fuse.Attr) error {
 	debug.Log("Attr(%v)", f.node.Name)
+	if sf := f.scratchFile(); sf != nil {
+		return sf.Attr(ctx, a)
+	}
+
 	a.Inode = f.inode
-	a.Mode = f.node.Mode
+	a.Mode = f.root.perms(f.node.Mode)
 	a.Size = f.node.Size
 	a.Blocks = (f.node.Size + blockSize - 1) / blockSize
 	a.BlockSize = blockSize
@@ -60,10 +71,7 @@ func (f *file) Attr(_ context.Context, a *fuse.Attr) error {
 	// (e.g. Samba) accept the file.
 	a.Nlink = max(uint32(1), uint32(f.node.Links))
 
-	if !f.root.cfg.OwnerIsRoot {
-		a.Uid = f.node.UID
-		a.Gid = f.node.GID
-	}
+	f.root.setOwner(a, f.node.UID, f.node.GID)
 	a.Atime = f.node.AccessTime
 	a.Ctime = f.node.ChangeTime
 	a.Mtime = f.node.ModTime
@@ -72,8 +80,21 @@ func (f *file) Attr(_ context.Context, a *fuse.Attr) error {
 
 }
 
-func (f *file) Open(ctx context.Context, _ *fuse.OpenRequest, _ *fuse.OpenResponse) (fs.Handle, error) {
+func (f *file) Open(ctx context.Context, req *fuse.OpenRequest, resp *fuse.OpenResponse) (fs.Handle, error) {
 	debug.Log("open file %v with %d blobs", f.node.Name, len(f.node.Content))
+	if f.scratch != "" && req != nil {
+		sf := f.scratchFile()
+		if sf == nil && !req.Flags.IsReadOnly() {
+			var err error
+			sf, err = f.copyUp(ctx)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if sf != nil {
+			return sf.Open(ctx, req, resp)
+		}
+	}
 
 	var bytes uint64
 	cumsize := make([]uint64, 1+len(f.node.Content))
@@ -170,6 +191,95 @@ func (f *openFile) Read(ctx context.Context, req *fuse.ReadRequest, resp *fuse.R
 	return nil
 }
 
+// scratchFile returns the copy of the file in the scratch directory, or nil if
+// the file was not modified.
+func (f *file) scratchFile() *scratchFile {
+	if f.scratch == "" {
+		return nil
+	}
+	if _, err := os.Lstat(f.scratch); err != nil {
+		return nil
+	}
+	return newScratchFile(f.root, func() {}, f.inode, f.scratch)
+}
+
+// copyUp restores the file to the scratch directory, such that it can be
+// modified without changing the snapshot.
+func (f *file) copyUp(ctx context.Context) (*scratchFile, error) {
+	f.root.scratchMu.Lock()
+	defer f.root.scratchMu.Unlock()
+
+	if sf := f.scratchFile(); sf != nil {
+		return sf, nil
+	}
+
+	debug.Log("restore %v to %v", f.node.Name, f.scratch)
+	dir := filepath.Dir(f.scratch)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, toErrno(err)
+	}
+
+	tmp := filepath.Join(dir, tempPrefix+filepath.Base(f.scratch))
+	err := f.restoreTo(ctx, tmp)
+	if err == nil {
+		err = os.Rename(tmp, f.scratch)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return nil, toErrno(unwrapCtxCanceled(err))
+	}
+
+	return newScratchFile(f.root, func() {}, f.inode, f.scratch), nil
+}
+
+// restoreTo writes the content and metadata of the file to path.
+func (f *file) restoreTo(ctx context.Context, path string) error {
+	out, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range f.node.Content {
+		var blob []byte
+		blob, err = f.root.repo.LoadBlob(ctx, restic.BlobHandle{Type: restic.DataBlob, ID: id}, nil)
+		if err != nil {
+			break
+		}
+		if _, err = out.Write(blob); err != nil {
+			break
+		}
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.Chmod(path, f.node.Mode.Perm()); err != nil {
+		return err
+	}
+	return os.Chtimes(path, f.node.AccessTime, f.node.ModTime)
+}
+
+func (f *file) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp *fuse.SetattrResponse) error {
+	if f.scratch == "" {
+		return syscall.EROFS
+	}
+	sf, err := f.copyUp(ctx)
+	if err != nil {
+		return err
+	}
+	return sf.Setattr(ctx, req, resp)
+}
+
+func (f *file) Fsync(ctx context.Context, req *fuse.FsyncRequest) error {
+	if sf := f.scratchFile(); sf != nil {
+		return sf.Fsync(ctx, req)
+	}
+	return nil
+}
+
 func (f *file) Listxattr(_ context.Context, req *fuse.ListxattrRequest, resp *fuse.ListxattrResponse) error {
 	nodeToXattrList(f.node, req, resp)
 	return nil
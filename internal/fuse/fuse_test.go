@@ -8,10 +8,15 @@ import (
 	"fmt"
 	"math/rand"
 	"os"
+	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
+	"syscall"
 	"testing"
 	"time"
 
+	"github.com/restic/restic/internal/archiver"
 	"github.com/restic/restic/internal/bloblru"
 	"github.com/restic/restic/internal/data"
 	"github.com/restic/restic/internal/repository"
@@ -219,6 +224,33 @@ func testTopUIDGID(t *testing.T, cfg Config, repo restic.Repository, uid, gid ui
 	rtest.Equals(t, uint32(0), attr.Gid)
 }
 
+func TestSquashOwnerAndPerms(t *testing.T) {
+	uid, gid := uint32(1234), uint32(5678)
+	perms := os.FileMode(0640)
+	root := &Root{cfg: Config{OwnerIsRoot: true, SquashUID: &uid, SquashGID: &gid, DefaultPerms: &perms}}
+
+	for _, tc := range []struct {
+		node *data.Node
+		mode os.FileMode
+	}{
+		{&data.Node{Type: data.NodeTypeFile, Mode: 0600, UID: 1, GID: 2}, 0640},
+		{&data.Node{Type: data.NodeTypeFile, Mode: 0700, UID: 1, GID: 2}, 0750},
+		{&data.Node{Type: data.NodeTypeDir, Mode: 0700, UID: 1, GID: 2}, os.ModeDir | 0750},
+	} {
+		var a fuse.Attr
+		if tc.node.Type == data.NodeTypeDir {
+			d := &dir{root: root, node: tc.node}
+			rtest.OK(t, d.Attr(context.TODO(), &a))
+		} else {
+			f := &file{root: root, node: tc.node}
+			rtest.OK(t, f.Attr(context.TODO(), &a))
+		}
+		rtest.Equals(t, uid, a.Uid)
+		rtest.Equals(t, gid, a.Gid)
+		rtest.Equals(t, tc.mode, a.Mode)
+	}
+}
+
 // The Lookup method must return the same Node object unless it was forgotten in the meantime
 func testStableLookup(t *testing.T, node fs.Node, path string) fs.Node {
 	t.Helper()
@@ -381,6 +413,190 @@ func TestLink(t *testing.T) {
 	rtest.Assert(t, err != nil, "missing error on reading invalid xattr")
 }
 
+func lookupNode(t testing.TB, node fs.Node, name string) fs.Node {
+	t.Helper()
+	result, err := node.(fs.NodeStringLookuper).Lookup(context.TODO(), name)
+	rtest.OK(t, err)
+	return result
+}
+
+func readFile(t testing.TB, node fs.Node) []byte {
+	t.Helper()
+	var attr fuse.Attr
+	rtest.OK(t, node.Attr(context.TODO(), &attr))
+
+	h, err := node.(fs.NodeOpener).Open(context.TODO(), &fuse.OpenRequest{Flags: fuse.OpenReadOnly}, &fuse.OpenResponse{})
+	rtest.OK(t, err)
+	buf := make([]byte, attr.Size)
+	testRead(t, h, 0, len(buf), buf)
+	if r, ok := h.(fs.HandleReleaser); ok {
+		rtest.OK(t, r.Release(context.TODO(), &fuse.ReleaseRequest{}))
+	}
+	return buf
+}
+
+func dirNames(t testing.TB, node fs.Node) []string {
+	t.Helper()
+	entries, err := node.(fs.HandleReadDirAller).ReadDirAll(context.TODO())
+	rtest.OK(t, err)
+	var names []string
+	for _, entry := range entries {
+		if entry.Name != "." && entry.Name != ".." {
+			names = append(names, entry.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestWritableMount(t *testing.T) {
+	repo := repository.TestRepository(t)
+	data.TestCreateSnapshot(t, repo, time.Unix(1460289341, 207401672), 2)
+	snapID := loadFirstSnapshot(t, repo).ID().Str()
+	scratch := rtest.TempDir(t)
+	ctx := context.TODO()
+
+	openDir := func(cfg Config) fs.Node {
+		root := NewRoot(repo, cfg)
+		return lookupNode(t, lookupNode(t, lookupNode(t, root, "ids"), snapID), "dir-0")
+	}
+
+	dir := openDir(Config{ScratchDir: scratch})
+	names := dirNames(t, dir)
+	var modified, removed string
+	for _, name := range names {
+		if !strings.HasPrefix(name, "file-") {
+			continue
+		}
+		var attr fuse.Attr
+		rtest.OK(t, lookupNode(t, dir, name).Attr(ctx, &attr))
+		if modified == "" && attr.Size >= 3 {
+			modified = name
+		} else if removed == "" {
+			removed = name
+		}
+	}
+	rtest.Assert(t, modified != "" && removed != "", "test snapshot contains too few files: %v", names)
+
+	// modify a file
+	node := lookupNode(t, dir, modified)
+	orig := readFile(t, node)
+	h, err := node.(fs.NodeOpener).Open(ctx, &fuse.OpenRequest{Flags: fuse.OpenReadWrite}, &fuse.OpenResponse{})
+	rtest.OK(t, err)
+	rtest.OK(t, h.(fs.HandleWriter).Write(ctx, &fuse.WriteRequest{Data: []byte("foo")}, &fuse.WriteResponse{}))
+	rtest.OK(t, h.(fs.HandleReleaser).Release(ctx, &fuse.ReleaseRequest{}))
+	expected := append([]byte("foo"), orig[3:]...)
+	rtest.Equals(t, expected, readFile(t, node))
+
+	// create a file and a directory, remove a file
+	newNode, h, err := dir.(fs.NodeCreater).Create(ctx, &fuse.CreateRequest{Name: "new", Flags: fuse.OpenReadWrite, Mode: 0644}, &fuse.CreateResponse{})
+	rtest.OK(t, err)
+	rtest.OK(t, h.(fs.HandleWriter).Write(ctx, &fuse.WriteRequest{Data: []byte("bar")}, &fuse.WriteResponse{}))
+	rtest.OK(t, h.(fs.HandleReleaser).Release(ctx, &fuse.ReleaseRequest{}))
+	rtest.Equals(t, []byte("bar"), readFile(t, newNode))
+
+	_, err = dir.(fs.NodeMkdirer).Mkdir(ctx, &fuse.MkdirRequest{Name: "subdir", Mode: 0755})
+	rtest.OK(t, err)
+	rtest.OK(t, dir.(fs.NodeRemover).Remove(ctx, &fuse.RemoveRequest{Name: removed}))
+	_, err = dir.(fs.NodeStringLookuper).Lookup(ctx, removed)
+	rtest.Equals(t, error(syscall.ENOENT), err)
+
+	// items of the snapshot must be copied instead of renamed
+	err = dir.(fs.NodeRenamer).Rename(ctx, &fuse.RenameRequest{OldName: "dir-0", NewName: "moved"}, dir)
+	rtest.Equals(t, error(syscall.EXDEV), err)
+	rtest.OK(t, dir.(fs.NodeRenamer).Rename(ctx, &fuse.RenameRequest{OldName: "new", NewName: "renamed"}, dir))
+
+	var expNames []string
+	for _, name := range names {
+		if name != removed {
+			expNames = append(expNames, name)
+		}
+	}
+	expNames = append(expNames, "renamed", "subdir")
+	sort.Strings(expNames)
+	rtest.Equals(t, expNames, dirNames(t, dir))
+
+	// the changes are visible when reusing the scratch directory
+	dir = openDir(Config{ScratchDir: scratch})
+	rtest.Equals(t, expNames, dirNames(t, dir))
+	rtest.Equals(t, expected, readFile(t, lookupNode(t, dir, modified)))
+
+	// the snapshot is unchanged
+	dir = openDir(Config{})
+	rtest.Equals(t, names, dirNames(t, dir))
+	rtest.Equals(t, orig, readFile(t, lookupNode(t, dir, modified)))
+	_, _, err = dir.(fs.NodeCreater).Create(ctx, &fuse.CreateRequest{Name: "new"}, &fuse.CreateResponse{})
+	rtest.Equals(t, error(syscall.EROFS), err)
+}
+
+func TestDiffDir(t *testing.T) {
+	repo := repository.TestRepository(t)
+	tempdir := rtest.TempDir(t)
+
+	archiver.TestCreateFiles(t, tempdir, archiver.TestDir{
+		"same":     archiver.TestFile{Content: "same"},
+		"modified": archiver.TestFile{Content: "old content"},
+		"removed":  archiver.TestFile{Content: "removed"},
+		"sub": archiver.TestDir{
+			"file": archiver.TestFile{Content: "unchanged"},
+		},
+		"sub2": archiver.TestDir{
+			"file":  archiver.TestFile{Content: "old content"},
+			"other": archiver.TestFile{Content: "unchanged"},
+		},
+	})
+	archiver.TestSnapshot(t, repo, tempdir, nil)
+	id1 := firstSnapshotID(t, repo)
+
+	rtest.OK(t, os.WriteFile(filepath.Join(tempdir, "modified"), []byte("new content"), 0644))
+	rtest.OK(t, os.WriteFile(filepath.Join(tempdir, "sub2", "file"), []byte("new content"), 0644))
+	rtest.OK(t, os.Remove(filepath.Join(tempdir, "removed")))
+	archiver.TestCreateFiles(t, tempdir, archiver.TestDir{
+		"added": archiver.TestFile{Content: "added"},
+		"newdir": archiver.TestDir{
+			"file": archiver.TestFile{Content: "new file"},
+		},
+	})
+	archiver.TestSnapshot(t, repo, tempdir, nil)
+	var id2 restic.ID
+	rtest.OK(t, repo.List(context.TODO(), restic.SnapshotFile, func(id restic.ID, _ int64) error {
+		if id != id1 {
+			id2 = id
+		}
+		return nil
+	}))
+
+	root := NewRoot(repo, Config{})
+	rtest.Assert(t, slices.Contains(dirNames(t, root), "diff"), "diff dir missing in %v", dirNames(t, root))
+	diff := lookupNode(t, root, "diff")
+	rtest.Equals(t, []string(nil), dirNames(t, diff))
+
+	lookupPath := func(node fs.Node) fs.Node {
+		for _, name := range strings.Split(filepath.ToSlash(tempdir), "/") {
+			if name != "" {
+				node = lookupNode(t, node, name)
+			}
+		}
+		return node
+	}
+
+	dir := lookupPath(lookupNode(t, diff, id1.Str()+".."+id2.Str()))
+	rtest.Equals(t, []string{"added", "modified", "newdir", "sub2"}, dirNames(t, dir))
+	rtest.Equals(t, []byte("new content"), readFile(t, lookupNode(t, dir, "modified")))
+	rtest.Equals(t, []string{"file"}, dirNames(t, lookupNode(t, dir, "newdir")))
+	rtest.Equals(t, []string{"file"}, dirNames(t, lookupNode(t, dir, "sub2")))
+
+	// the reverse direction only contains the removed and modified items
+	dir = lookupPath(lookupNode(t, diff, id2.String()+".."+id1.String()))
+	rtest.Equals(t, []string{"modified", "removed", "sub2"}, dirNames(t, dir))
+	rtest.Equals(t, []byte("old content"), readFile(t, lookupNode(t, dir, "modified")))
+
+	for _, name := range []string{"foo", id1.Str() + "..", "..", "invalid.." + id2.Str()} {
+		_, err := diff.(fs.NodeStringLookuper).Lookup(context.TODO(), name)
+		rtest.Equals(t, error(syscall.ENOENT), err, name)
+	}
+}
+
 var sink uint64
 
 func BenchmarkInode(b *testing.B) {
@@ -3,13 +3,17 @@
 package fuse
 
 import (
+	"context"
 	"os"
+	"path/filepath"
+	"sync"
 
 	"github.com/restic/restic/internal/bloblru"
 	"github.com/restic/restic/internal/data"
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/restic"
 
+	"github.com/anacrolix/fuse"
 	"github.com/anacrolix/fuse/fs"
 )
 
@@ -19,6 +23,17 @@ type Config struct {
 	Filter        data.SnapshotFilter
 	TimeTemplate  string
 	PathTemplates []string
+	// ScratchDir makes the mount writable. All modifications are stored in
+	// this directory, the repository is never modified.
+	ScratchDir string
+	// SquashUID and SquashGID, if set, are reported as the owner and group of
+	// all files and directories instead of those stored in the snapshot.
+	SquashUID *uint32
+	SquashGID *uint32
+	// DefaultPerms, if set, replaces the permissions of all files and
+	// directories. Directories and executable files additionally get the
+	// execute permission for each read permission.
+	DefaultPerms *os.FileMode
 }
 
 // Root is the root node of the fuse mount of a repository.
@@ -26,8 +41,11 @@ type Root struct {
 	repo      restic.Repository
 	cfg       Config
 	blobCache *bloblru.Cache
+	// serializes modifications of the scratch directory
+	scratchMu sync.Mutex
 
 	*SnapshotsDir
+	diffDir *diffRootDir
 
 	uid, gid uint32
 }
@@ -67,12 +85,74 @@ func NewRoot(repo restic.Repository, cfg Config) *Root {
 	}
 
 	root.SnapshotsDir = NewSnapshotsDir(root, func() {}, rootInode, rootInode, NewSnapshotsDirStructure(root, cfg.PathTemplates, cfg.TimeTemplate), "")
+	root.diffDir = newDiffRootDir(root)
 
 	return root
 }
 
+// setOwner sets the owner and group of a to uid and gid, or to the squashed
+// IDs if configured.
+func (r *Root) setOwner(a *fuse.Attr, uid, gid uint32) {
+	if r.cfg.OwnerIsRoot {
+		uid, gid = 0, 0
+	}
+	if r.cfg.SquashUID != nil {
+		uid = *r.cfg.SquashUID
+	}
+	if r.cfg.SquashGID != nil {
+		gid = *r.cfg.SquashGID
+	}
+	a.Uid = uid
+	a.Gid = gid
+}
+
+// perms returns mode with the permissions replaced by the configured default
+// permissions.
+func (r *Root) perms(mode os.FileMode) os.FileMode {
+	if r.cfg.DefaultPerms == nil || mode&os.ModeSymlink != 0 {
+		return mode
+	}
+	perm := *r.cfg.DefaultPerms & os.ModePerm
+	if mode.IsDir() || mode&0111 != 0 {
+		perm |= (perm & 0444) >> 2
+	}
+	return mode&^os.ModePerm | perm
+}
+
+// snapshotScratchDir returns the scratch directory for a snapshot, or an
+// empty string for read-only mounts.
+func (r *Root) snapshotScratchDir(sn *data.Snapshot) string {
+	if r.cfg.ScratchDir == "" {
+		return ""
+	}
+	return filepath.Join(r.cfg.ScratchDir, sn.ID().String())
+}
+
 // Root is just there to satisfy fs.Root, it returns itself.
 func (r *Root) Root() (fs.Node, error) {
 	debug.Log("Root()")
 	return r, nil
 }
+
+// ReadDirAll returns the entries of the snapshots directory structure and the
+// "diff" directory.
+func (r *Root) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
+	items, err := r.SnapshotsDir.ReadDirAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return append(items, fuse.Dirent{
+		Inode: r.diffDir.inode,
+		Name:  diffDirName,
+		Type:  fuse.DT_Dir,
+	}), nil
+}
+
+// Lookup returns the "diff" directory or an entry of the snapshots directory
+// structure.
+func (r *Root) Lookup(ctx context.Context, name string) (fs.Node, error) {
+	if name == diffDirName {
+		return r.diffDir, nil
+	}
+	return r.SnapshotsDir.Lookup(ctx, name)
+}
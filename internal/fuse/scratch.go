@@ -0,0 +1,228 @@
+//go:build darwin || freebsd || linux
+
+package fuse
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/anacrolix/fuse"
+	"github.com/anacrolix/fuse/fs"
+
+	"github.com/restic/restic/internal/debug"
+)
+
+// Modifications of a writable mount are stored in a scratch directory which
+// mirrors the structure of the snapshots. The scratch directory contains one
+// sub directory per snapshot ID. An item in the scratch directory hides the item
+// with the same name in the snapshot, except for directories which are merged
+// with the corresponding directory of the snapshot. Removed items of the
+// snapshot are recorded as hidden whiteout files.
+
+// scratchPrefix is used for internal files in the scratch directory. Items
+// with this prefix are not shown in the mount.
+const scratchPrefix = ".restic-scratch."
+
+const (
+	whiteoutPrefix = scratchPrefix + "whiteout."
+	tempPrefix     = scratchPrefix + "tmp."
+)
+
+func isScratchInternal(name string) bool {
+	return strings.HasPrefix(name, scratchPrefix)
+}
+
+func whiteoutPath(dir, name string) string {
+	return filepath.Join(dir, whiteoutPrefix+name)
+}
+
+func hasWhiteout(dir, name string) bool {
+	_, err := os.Lstat(whiteoutPath(dir, name))
+	return err == nil
+}
+
+// addWhiteout hides the item name of the snapshot directory.
+func addWhiteout(dir, name string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(whiteoutPath(dir, name), os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// toErrno extracts the errno from err, as the fuse library is unable to
+// return the correct error code for wrapped errors.
+func toErrno(err error) error {
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		return errno
+	}
+	return err
+}
+
+// attrFromFileInfo fills a with the attributes of a file in the scratch directory.
+func attrFromFileInfo(root *Root, inode uint64, fi os.FileInfo, a *fuse.Attr) {
+	a.Inode = inode
+	a.Mode = fi.Mode()
+	a.Size = uint64(fi.Size())
+	a.Blocks = (a.Size + blockSize - 1) / blockSize
+	a.BlockSize = blockSize
+	a.Nlink = 1
+	if fi.IsDir() {
+		a.Nlink = 2
+	}
+
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		root.setOwner(a, st.Uid, st.Gid)
+	}
+	a.Atime = fi.ModTime()
+	a.Ctime = fi.ModTime()
+	a.Mtime = fi.ModTime()
+}
+
+// setattr applies the attribute changes in req to the file at path.
+func setattr(path string, req *fuse.SetattrRequest) error {
+	if req.Valid.Uid() || req.Valid.Gid() {
+		return syscall.EPERM
+	}
+
+	if req.Valid.Size() {
+		if err := os.Truncate(path, int64(req.Size)); err != nil {
+			return toErrno(err)
+		}
+	}
+
+	if req.Valid.Mode() {
+		if err := os.Chmod(path, req.Mode.Perm()); err != nil {
+			return toErrno(err)
+		}
+	}
+
+	if req.Valid.Atime() || req.Valid.Mtime() || req.Valid.AtimeNow() || req.Valid.MtimeNow() {
+		fi, err := os.Lstat(path)
+		if err != nil {
+			return toErrno(err)
+		}
+		// the access time is not tracked by the mount
+		atime, mtime := fi.ModTime(), fi.ModTime()
+
+		switch {
+		case req.Valid.AtimeNow():
+			atime = time.Now()
+		case req.Valid.Atime():
+			atime = req.Atime
+		}
+		switch {
+		case req.Valid.MtimeNow():
+			mtime = time.Now()
+		case req.Valid.Mtime():
+			mtime = req.Mtime
+		}
+
+		if err := os.Chtimes(path, atime, mtime); err != nil {
+			return toErrno(err)
+		}
+	}
+
+	return nil
+}
+
+// Statically ensure that *scratchFile and *scratchHandle implement the given interfaces
+var _ = fs.NodeForgetter(&scratchFile{})
+var _ = fs.NodeFsyncer(&scratchFile{})
+var _ = fs.NodeOpener(&scratchFile{})
+var _ = fs.NodeSetattrer(&scratchFile{})
+var _ = fs.HandleReader(&scratchHandle{})
+var _ = fs.HandleReleaser(&scratchHandle{})
+var _ = fs.HandleWriter(&scratchHandle{})
+
+// scratchFile is a regular file in the scratch directory.
+type scratchFile struct {
+	root   *Root
+	forget forgetFn
+	inode  uint64
+	path   string
+}
+
+func newScratchFile(root *Root, forget forgetFn, inode uint64, path string) *scratchFile {
+	debug.Log("new scratch file %v", path)
+	return &scratchFile{root: root, forget: forget, inode: inode, path: path}
+}
+
+func (f *scratchFile) Attr(_ context.Context, a *fuse.Attr) error {
+	fi, err := os.Lstat(f.path)
+	if err != nil {
+		return toErrno(err)
+	}
+	attrFromFileInfo(f.root, f.inode, fi, a)
+	return nil
+}
+
+func (f *scratchFile) Open(_ context.Context, req *fuse.OpenRequest, _ *fuse.OpenResponse) (fs.Handle, error) {
+	debug.Log("open scratch file %v, flags %v", f.path, req.Flags)
+
+	// the kernel passes the offset for appending writes, thus only the access
+	// mode is relevant
+	file, err := os.OpenFile(f.path, int(req.Flags&fuse.OpenAccessModeMask), 0)
+	if err != nil {
+		return nil, toErrno(err)
+	}
+	return &scratchHandle{file: file}, nil
+}
+
+func (f *scratchFile) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp *fuse.SetattrResponse) error {
+	debug.Log("Setattr(%v, %v)", f.path, req)
+	if err := setattr(f.path, req); err != nil {
+		return err
+	}
+	return f.Attr(ctx, &resp.Attr)
+}
+
+func (f *scratchFile) Fsync(_ context.Context, _ *fuse.FsyncRequest) error {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return toErrno(err)
+	}
+	err = file.Sync()
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	return toErrno(err)
+}
+
+func (f *scratchFile) Forget() {
+	f.forget()
+}
+
+// scratchHandle is an open file in the scratch directory.
+type scratchHandle struct {
+	file *os.File
+}
+
+func (h *scratchHandle) Read(_ context.Context, req *fuse.ReadRequest, resp *fuse.ReadResponse) error {
+	n, err := h.file.ReadAt(resp.Data[:req.Size], req.Offset)
+	if err != nil && err != io.EOF {
+		return toErrno(err)
+	}
+	resp.Data = resp.Data[:n]
+	return nil
+}
+
+func (h *scratchHandle) Write(_ context.Context, req *fuse.WriteRequest, resp *fuse.WriteResponse) error {
+	n, err := h.file.WriteAt(req.Data, req.Offset)
+	resp.Size = n
+	return toErrno(err)
+}
+
+func (h *scratchHandle) Release(_ context.Context, _ *fuse.ReleaseRequest) error {
+	return toErrno(h.file.Close())
+}
@@ -41,6 +41,9 @@ type SnapshotsDirStructure struct {
 	// that way we don't need path processing special cases when using the entries tree
 	entries map[string]*MetaDirData
 
+	// templates caches the compiled path templates using the Go template syntax
+	templates map[string]*goPathTemplate
+
 	hash      [sha256.Size]byte // Hash at last check.
 	lastCheck time.Time
 
@@ -165,6 +168,15 @@ func filenameFromTag(tag string) string {
 
 // determine static path prefix
 func staticPrefix(pathTemplate string) (prefix string) {
+	if isGoTemplate(pathTemplate) {
+		p, _, _ := strings.Cut(pathTemplate, "{{")
+		idx := strings.LastIndex(p, "/")
+		if idx < 0 {
+			return ""
+		}
+		return p[:idx]
+	}
+
 	inVerb := false
 	patternStart := -1
 outer:
@@ -264,7 +276,22 @@ func (d *SnapshotsDirStructure) makeDirs(snapshots data.Snapshots) {
 	latestTime := make(map[string]time.Time)
 	for _, sn := range snapshots {
 		for _, templ := range d.pathTemplates {
-			paths, timeSuffix := pathsFromSn(templ, d.timeTemplate, sn)
+			var paths []string
+			var timeSuffix string
+			if isGoTemplate(templ) {
+				t := d.goTemplate(templ)
+				if t == nil {
+					continue
+				}
+				var err error
+				paths, timeSuffix, err = t.paths(sn, d.timeTemplate)
+				if err != nil {
+					debug.Log("path template %q failed for snapshot %v: %v", templ, sn.ID().Str(), err)
+					continue
+				}
+			} else {
+				paths, timeSuffix = pathsFromSn(templ, d.timeTemplate, sn)
+			}
 			for _, p := range paths {
 				if p != "" {
 					p = "/" + p
@@ -0,0 +1,253 @@
+//go:build darwin || freebsd || linux
+
+package fuse
+
+import (
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/restic/restic/internal/data"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+)
+
+// pathTemplateData is the data which can be used in path templates written
+// using the Go template syntax, e.g. "{{.Host}}/{{.Time.Format "2006-01"}}".
+type pathTemplateData struct {
+	ID       string
+	ShortID  string
+	Host     string
+	Username string
+	Time     time.Time
+	// Timestamp is the time of the snapshot formatted using the time template
+	Timestamp string
+	// Tag is set to each tag of the snapshot in turn, a template which uses
+	// Tag creates one path per tag
+	Tag   string
+	Tags  []string
+	Paths []string
+}
+
+// isGoTemplate returns true if the path template uses the Go template syntax
+// instead of the %-patterns.
+func isGoTemplate(pathTemplate string) bool {
+	return strings.Contains(pathTemplate, "{{")
+}
+
+// goPathTemplate is a compiled path template using the Go template syntax.
+type goPathTemplate struct {
+	full *template.Template
+	// if the last path element only depends on the time of the snapshot, dir
+	// and last contain the templates for the directory and the last element,
+	// such that "latest" links can be created
+	dir, last *template.Template
+	usesTag   bool
+}
+
+// compileGoPathTemplate parses a path template using the Go template syntax.
+func compileGoPathTemplate(text string) (*goPathTemplate, error) {
+	full, err := template.New("path").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	fields := templateFields(full)
+
+	t := &goPathTemplate{full: full, usesTag: fields["Tag"]}
+
+	dirText, lastText := splitTemplatePath(text)
+	last, err := template.New("last").Parse(lastText)
+	if err != nil {
+		// the last element is not a valid template on its own
+		return t, nil
+	}
+	if !onlyTimeFields(templateFields(last)) {
+		return t, nil
+	}
+	t.last = last
+	if dirText != "" {
+		// an empty template cannot be executed, thus dir stays nil
+		t.dir, err = template.New("dir").Parse(dirText)
+		if err != nil {
+			t.last = nil
+		}
+	}
+	return t, nil
+}
+
+// onlyTimeFields returns true if fields only contains fields for the time of
+// the snapshot.
+func onlyTimeFields(fields map[string]bool) bool {
+	if len(fields) == 0 {
+		return false
+	}
+	for field := range fields {
+		if field != "Time" && field != "Timestamp" {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckPathTemplate returns an error if the path template is not valid.
+func CheckPathTemplate(text string) error {
+	if !isGoTemplate(text) {
+		return nil
+	}
+	t, err := compileGoPathTemplate(text)
+	if err != nil {
+		return errors.Errorf("invalid path template %q: %v", text, err)
+	}
+	sn := &data.Snapshot{Hostname: "host", Username: "user", Tags: []string{"tag"}, Time: time.Now()}
+	if _, _, err := t.paths(sn, time.RFC3339); err != nil {
+		return errors.Errorf("invalid path template %q: %v", text, err)
+	}
+	return nil
+}
+
+// splitTemplatePath splits the template text at the last slash which is not
+// part of an action. The slash is included in dir.
+func splitTemplatePath(text string) (dir, last string) {
+	idx := -1
+	inAction := false
+	for i := 0; i < len(text); i++ {
+		switch {
+		case strings.HasPrefix(text[i:], "{{"):
+			inAction = true
+			i++
+		case strings.HasPrefix(text[i:], "}}"):
+			inAction = false
+			i++
+		case text[i] == '/' && !inAction:
+			idx = i
+		}
+	}
+	return text[:idx+1], text[idx+1:]
+}
+
+// templateFields returns the names of all fields of the data which are used
+// by the template.
+func templateFields(t *template.Template) map[string]bool {
+	fields := make(map[string]bool)
+	var walk func(node parse.Node)
+	walk = func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, child := range n.Nodes {
+				walk(child)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe)
+		case *parse.IfNode:
+			walk(&n.BranchNode)
+		case *parse.RangeNode:
+			walk(&n.BranchNode)
+		case *parse.WithNode:
+			walk(&n.BranchNode)
+		case *parse.BranchNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.TemplateNode:
+			walk(n.Pipe)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, cmd := range n.Cmds {
+				walk(cmd)
+			}
+		case *parse.CommandNode:
+			for _, arg := range n.Args {
+				walk(arg)
+			}
+		case *parse.ChainNode:
+			walk(n.Node)
+		case *parse.FieldNode:
+			fields[n.Ident[0]] = true
+		case *parse.VariableNode:
+			if len(n.Ident) > 1 && n.Ident[0] == "$" {
+				fields[n.Ident[1]] = true
+			}
+		}
+	}
+	if t.Tree != nil {
+		walk(t.Tree.Root)
+	}
+	return fields
+}
+
+// paths returns the paths of the snapshot generated by the template. The time
+// is returned as suffix if the last path element only depends on the time.
+func (t *goPathTemplate) paths(sn *data.Snapshot, timeTemplate string) (paths []string, timeSuffix string, err error) {
+	d := pathTemplateData{
+		Host:      sn.Hostname,
+		Username:  sn.Username,
+		Time:      sn.Time,
+		Timestamp: sn.Time.Format(timeTemplate),
+		Tags:      sn.Tags,
+		Paths:     sn.Paths,
+	}
+
+	if id := sn.ID(); id != nil {
+		d.ID, d.ShortID = id.String(), id.Str()
+	}
+
+	tags := []string{""}
+	if t.usesTag {
+		tags = sn.Tags
+	}
+
+	execute := func(tmpl *template.Template) (string, error) {
+		var buf strings.Builder
+		err := tmpl.Execute(&buf, d)
+		return buf.String(), err
+	}
+
+	seen := make(map[string]struct{})
+	for _, tag := range tags {
+		d.Tag = filenameFromTag(tag)
+
+		var p string
+		if t.last != nil {
+			if t.dir != nil {
+				p, err = execute(t.dir)
+			}
+			if err == nil {
+				timeSuffix, err = execute(t.last)
+			}
+		} else {
+			p, err = execute(t.full)
+		}
+		if err != nil {
+			return nil, "", err
+		}
+
+		if _, ok := seen[p]; !ok {
+			seen[p] = struct{}{}
+			paths = append(paths, p)
+		}
+	}
+	return paths, timeSuffix, nil
+}
+
+// goTemplate returns the compiled path template, templates are compiled once
+// and cached afterwards.
+func (d *SnapshotsDirStructure) goTemplate(text string) *goPathTemplate {
+	if t, ok := d.templates[text]; ok {
+		return t
+	}
+	if d.templates == nil {
+		d.templates = make(map[string]*goPathTemplate)
+	}
+	t, err := compileGoPathTemplate(text)
+	if err != nil {
+		debug.Log("invalid path template %q: %v", text, err)
+	}
+	d.templates[text] = t
+	return t
+}
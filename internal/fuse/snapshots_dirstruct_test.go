@@ -289,3 +289,84 @@ func TestFilenameFromTag(t *testing.T) {
 		test.Equals(t, c.filename, filenameFromTag(c.tag))
 	}
 }
+
+func TestGoPathTemplates(t *testing.T) {
+	id1, _ := restic.ParseID("1234567812345678123456781234567812345678123456781234567812345678")
+	time1, _ := time.Parse("2006-01-02T15:04:05", "2021-01-01T00:00:01")
+	sn1 := &data.Snapshot{Hostname: "host", Username: "user", Tags: []string{"tag1", "tag/2"}, Time: time1}
+	data.TestSetSnapshotID(t, sn1, id1)
+
+	for _, tc := range []struct {
+		template string
+		paths    []string
+		suffix   string
+	}{
+		{"{{.Host}}/{{.Time.Format \"2006-01\"}}/{{.ShortID}}", []string{"host/2021-01/12345678"}, ""},
+		{"by-host/{{.Host}}/{{.Time.Format \"2006-01-02\"}}", []string{"by-host/host/"}, "2021-01-01"},
+		{"{{.Timestamp}}", []string{""}, "2021/01/01"},
+		{"{{.Username}}/{{.ID}}", []string{"user/1234567812345678123456781234567812345678123456781234567812345678"}, ""},
+		{"tags/{{.Tag}}/{{.Timestamp}}", []string{"tags/tag1/", "tags/tag_2/"}, "2021/01/01"},
+		{"{{if .Tags}}tagged{{else}}untagged{{end}}/{{.ShortID}}", []string{"tagged/12345678"}, ""},
+	} {
+		tmpl, err := compileGoPathTemplate(tc.template)
+		test.OK(t, err)
+		paths, suffix, err := tmpl.paths(sn1, "2006/01/02")
+		test.OK(t, err)
+		test.Equals(t, tc.paths, paths, tc.template)
+		test.Equals(t, tc.suffix, suffix, tc.template)
+	}
+
+	// snapshots without tags are skipped by templates using the tag
+	tmpl, err := compileGoPathTemplate("tags/{{.Tag}}")
+	test.OK(t, err)
+	paths, _, err := tmpl.paths(&data.Snapshot{Time: time1}, "2006")
+	test.OK(t, err)
+	test.Equals(t, 0, len(paths))
+
+	test.Equals(t, "by-host", staticPrefix("by-host/{{.Host}}/{{.Timestamp}}"))
+	test.Equals(t, "", staticPrefix("{{.Host}}/{{.Timestamp}}"))
+
+	for _, templ := range []string{"ids/%i", "{{.Host}}/{{.ShortID}}"} {
+		test.OK(t, CheckPathTemplate(templ))
+	}
+	for _, templ := range []string{"{{.Host", "{{.Hostname}}", "{{.Time.Foo}}"} {
+		test.Assert(t, CheckPathTemplate(templ) != nil, "missing error for %q", templ)
+	}
+}
+
+func TestMakeDirsGoTemplate(t *testing.T) {
+	sds := &SnapshotsDirStructure{
+		pathTemplates: []string{"{{.Host}}/{{.Time.Format \"2006-01\"}}/{{.ShortID}}", "hosts/{{.Host}}/{{.Timestamp}}"},
+		timeTemplate:  "2006-01-02",
+	}
+
+	id0, _ := restic.ParseID("0000000012345678123456781234567812345678123456781234567812345678")
+	time0, _ := time.Parse("2006-01-02T15:04:05", "2020-12-31T00:00:01")
+	sn0 := &data.Snapshot{Hostname: "host", Time: time0}
+	data.TestSetSnapshotID(t, sn0, id0)
+
+	id1, _ := restic.ParseID("1234567812345678123456781234567812345678123456781234567812345678")
+	time1, _ := time.Parse("2006-01-02T15:04:05", "2021-01-01T00:00:01")
+	sn1 := &data.Snapshot{Hostname: "host", Time: time1}
+	data.TestSetSnapshotID(t, sn1, id1)
+
+	sds.makeDirs(data.Snapshots{sn0, sn1})
+
+	expNames := map[string]*data.Snapshot{
+		"":                       nil,
+		"/host":                  nil,
+		"/host/2020-12":          nil,
+		"/host/2020-12/00000000": sn0,
+		"/host/2021-01":          nil,
+		"/host/2021-01/12345678": sn1,
+		"/hosts":                 nil,
+		"/hosts/host":            nil,
+		"/hosts/host/2020-12-31": sn0,
+		"/hosts/host/2021-01-01": sn1,
+		"/hosts/host/latest":     sn1,
+	}
+	expLatest := map[string]string{
+		"/hosts/host/latest": "2021-01-01",
+	}
+	verifyEntries(t, expNames, expLatest, sds.entries)
+}
@@ -54,3 +54,12 @@ func (t *treeCache) lookupOrCreate(name string, generation int64, create func(fo
 	t.nodes[name] = node
 	return node, nil
 }
+
+// remove drops the node for name from the cache, for example after the
+// corresponding item was replaced.
+func (t *treeCache) remove(name string) {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	delete(t.nodes, name)
+}
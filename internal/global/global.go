@@ -3,6 +3,7 @@ package global
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/exec"
@@ -17,14 +18,17 @@ import (
 	"github.com/restic/restic/internal/backend/limiter"
 	"github.com/restic/restic/internal/backend/location"
 	"github.com/restic/restic/internal/backend/logger"
+	"github.com/restic/restic/internal/backend/mirror"
 	"github.com/restic/restic/internal/backend/retry"
 	"github.com/restic/restic/internal/backend/sema"
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/options"
 	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/repository/crypto"
 	"github.com/restic/restic/internal/restic"
 	"github.com/restic/restic/internal/textfile"
 	"github.com/restic/restic/internal/ui"
+	"github.com/restic/restic/internal/ui/termstatus"
 	"github.com/spf13/pflag"
 
 	"github.com/restic/restic/internal/errors"
@@ -48,17 +52,24 @@ type Options struct {
 	PasswordFile       string
 	PasswordCommand    string
 	KeyHint            string
+	IdentityFile       string
 	Quiet              bool
 	Verbose            int
 	NoLock             bool
+	OptimisticLock     bool
 	RetryLock          time.Duration
 	JSON               bool
 	CacheDir           string
 	NoCache            bool
+	CacheSize          string
 	CleanupCache       bool
 	Compression        repository.CompressionMode
 	PackSize           uint
+	TreePackSize       uint
+	DataPackSize       uint
 	NoExtraVerify      bool
+	LowMemoryIndex     bool
+	HedgeReadAfter     time.Duration
 	InsecureNoPassword bool
 
 	backend.TransportOptions
@@ -77,6 +88,15 @@ type Options struct {
 	//  3 means: print very detailed debug messages, this is used when --verbose=2 is specified
 	Verbosity uint
 
+	// LogFormat and LogLevel configure structured log output, all messages
+	// are printed as log records if LogFormat is set.
+	LogFormat string
+	LogLevel  string
+
+	// ProgressSocket is the path of a Unix socket to which progress events
+	// are sent as JSON lines.
+	ProgressSocket string
+
 	Options []string
 
 	Extended options.Options
@@ -93,14 +113,20 @@ func (opts *Options) AddFlags(f *pflag.FlagSet) {
 	f.StringVarP(&opts.PasswordFile, "password-file", "p", "", "`file` to read the repository password from (default: $RESTIC_PASSWORD_FILE)")
 	f.StringVarP(&opts.KeyHint, "key-hint", "", "", "`key` ID of key to try decrypting first (default: $RESTIC_KEY_HINT)")
 	f.StringVarP(&opts.PasswordCommand, "password-command", "", "", "shell `command` to obtain the repository password from (default: $RESTIC_PASSWORD_COMMAND)")
+	f.StringVar(&opts.IdentityFile, "identity-file", "", "`file` to read X25519 identities from instead of using a password (default: $RESTIC_IDENTITY_FILE)")
 	f.BoolVarP(&opts.Quiet, "quiet", "q", false, "do not output comprehensive progress report")
 	// use empty parameter name as `-v, --verbose n` instead of the correct `--verbose=n` is confusing
 	f.CountVarP(&opts.Verbose, "verbose", "v", "be verbose (specify multiple times or a level using --verbose=n``, max level/times is 2)")
 	f.BoolVar(&opts.NoLock, "no-lock", false, "do not lock the repository, this allows some operations on read-only repositories")
+	f.BoolVar(&opts.OptimisticLock, "optimistic-lock", false, "do not create lock files for non-exclusive operations, detect concurrent exclusive operations using generation markers instead")
 	f.DurationVar(&opts.RetryLock, "retry-lock", 0, "retry to lock the repository if it is already locked, takes a value like 5m or 2h (default: no retries)")
 	f.BoolVarP(&opts.JSON, "json", "", false, "set output mode to JSON for commands that support it")
+	f.StringVar(&opts.LogFormat, "log-format", "", "print all messages as structured log records in `format` text or json, with timestamps (default: plain messages)")
+	f.StringVar(&opts.LogLevel, "log-level", "", "minimum `level` of messages to print with --log-format, one of (error|warn|info|debug) (default: info, or debug with --verbose)")
+	f.StringVar(&opts.ProgressSocket, "progress-socket", "", "send progress events as JSON lines to the Unix socket at `path` (supported by backup, restore and prune)")
 	f.StringVar(&opts.CacheDir, "cache-dir", "", "set the cache `directory`. (default: use system default cache directory)")
 	f.BoolVar(&opts.NoCache, "no-cache", false, "do not use a local cache")
+	f.StringVar(&opts.CacheSize, "cache-size", "", "limit the cache of each repository to `size` (allowed suffixes: k/K, m/M, g/G, t/T), data packs are then cached as well (default: unlimited)")
 	f.StringSliceVar(&opts.RootCertFilenames, "cacert", nil, "`file` to load root certificates from (default: use system certificates or $RESTIC_CACERT)")
 	f.StringVar(&opts.TLSClientCertKeyFilename, "tls-client-cert", "", "path to a `file` containing PEM encoded TLS client certificate and private key (default: $RESTIC_TLS_CLIENT_CERT)")
 	f.BoolVar(&opts.InsecureNoPassword, "insecure-no-password", false, "use an empty password for the repository, must be passed to every restic command (insecure)")
@@ -109,19 +135,24 @@ func (opts *Options) AddFlags(f *pflag.FlagSet) {
 	const compressionFlag = "compression"
 	f.Var(&opts.Compression, compressionFlag, "compression mode (only available for repository format version 2), one of (auto|off|fastest|better|max) (default: $RESTIC_COMPRESSION)")
 	f.BoolVar(&opts.NoExtraVerify, "no-extra-verify", false, "skip additional verification of data before upload (see documentation)")
+	f.BoolVar(&opts.LowMemoryIndex, "low-memory-index", false, "store the repository index in memory-mapped files in the cache directory to reduce memory usage")
 	f.IntVar(&opts.Limits.UploadKb, "limit-upload", 0, "limits uploads to a maximum `rate` in KiB/s. (default: unlimited)")
 	f.IntVar(&opts.Limits.DownloadKb, "limit-download", 0, "limits downloads to a maximum `rate` in KiB/s. (default: unlimited)")
 	const packSizeFlag = "pack-size"
 	f.UintVar(&opts.PackSize, packSizeFlag, 0, "set target pack `size` in MiB, created pack files may be larger (default: $RESTIC_PACK_SIZE)")
+	f.UintVar(&opts.TreePackSize, "pack-size-tree", 0, "set target pack `size` in MiB for packs containing tree blobs (default: --pack-size)")
+	f.UintVar(&opts.DataPackSize, "pack-size-data", 0, "set target pack `size` in MiB for packs containing data blobs (default: --pack-size)")
 	f.StringSliceVarP(&opts.Options, "option", "o", []string{}, "set extended option (`key=value`, can be specified multiple times)")
 	f.StringVar(&opts.HTTPUserAgent, "http-user-agent", "", "set a http user agent for outgoing http requests")
 	f.DurationVar(&opts.StuckRequestTimeout, "stuck-request-timeout", 5*time.Minute, "`duration` after which to retry stuck requests")
+	f.DurationVar(&opts.HedgeReadAfter, "hedge-read-after", 0, "issue a second request when loading a blob takes longer than `duration` and use the first response (default: disabled)")
 
 	opts.Repo = os.Getenv("RESTIC_REPOSITORY")
 	opts.RepositoryFile = os.Getenv("RESTIC_REPOSITORY_FILE")
 	opts.PasswordFile = os.Getenv("RESTIC_PASSWORD_FILE")
 	opts.KeyHint = os.Getenv("RESTIC_KEY_HINT")
 	opts.PasswordCommand = os.Getenv("RESTIC_PASSWORD_COMMAND")
+	opts.IdentityFile = os.Getenv("RESTIC_IDENTITY_FILE")
 	if os.Getenv("RESTIC_CACERT") != "" {
 		opts.RootCertFilenames = strings.Split(os.Getenv("RESTIC_CACERT"), ",")
 	}
@@ -164,6 +195,40 @@ func (opts *Options) PreRun(needsPassword bool) error {
 		opts.Verbosity = 0
 	}
 
+	if opts.HedgeReadAfter < 0 {
+		return errors.Fatal("--hedge-read-after must not be negative")
+	}
+
+	if opts.LogLevel != "" && opts.LogFormat == "" {
+		return errors.Fatal("--log-level requires --log-format")
+	}
+	if opts.LogFormat != "" {
+		if err := termstatus.ParseLogFormat(opts.LogFormat); err != nil {
+			return errors.Fatalf("%v", err)
+		}
+		if opts.JSON {
+			return errors.Fatal("--log-format cannot be combined with --json")
+		}
+		if opts.LogLevel != "" {
+			if opts.Quiet || opts.Verbose > 0 {
+				return errors.Fatal("--log-level cannot be combined with --quiet or --verbose")
+			}
+			level, err := termstatus.ParseLogLevel(opts.LogLevel)
+			if err != nil {
+				return errors.Fatalf("%v", err)
+			}
+			// the log level replaces the verbosity
+			switch {
+			case level <= slog.LevelDebug:
+				opts.Verbosity = 3
+			case level <= slog.LevelInfo:
+				opts.Verbosity = 1
+			default:
+				opts.Verbosity = 0
+			}
+		}
+	}
+
 	// parse extended options
 	extendedOpts, err := options.Parse(opts.Options)
 	if err != nil {
@@ -181,6 +246,30 @@ func (opts *Options) PreRun(needsPassword bool) error {
 	return nil
 }
 
+// LogOptions returns the configuration of the structured log output.
+func (opts *Options) LogOptions() termstatus.LogOptions {
+	level := slog.LevelInfo
+	if opts.LogLevel != "" {
+		// already validated by PreRun
+		level, _ = termstatus.ParseLogLevel(opts.LogLevel)
+	} else if opts.Verbosity >= 2 {
+		level = slog.LevelDebug
+	}
+	return termstatus.LogOptions{Format: opts.LogFormat, Level: level}
+}
+
+// SetupLog replaces the terminal by one which prints all messages as
+// structured log records, if --log-format is set. The name of the command is
+// added to every log record.
+func (opts *Options) SetupLog(command string) {
+	if opts.LogFormat == "" {
+		return
+	}
+	logOpts := opts.LogOptions()
+	logOpts.Attrs = []slog.Attr{slog.String("command", command)}
+	opts.Term = termstatus.NewLogTerminal(opts.Term, logOpts)
+}
+
 // resolvePassword determines the password to be used for opening the repository.
 func resolvePassword(opts *Options, envStr string) (string, error) {
 	if opts.PasswordFile != "" && opts.PasswordCommand != "" {
@@ -336,6 +425,16 @@ func OpenRepository(ctx context.Context, gopts Options, printer restic.Printer)
 	return s, nil
 }
 
+// OpenBackend opens the backend of the repository without loading the
+// repository config, which may not exist yet.
+func OpenBackend(ctx context.Context, gopts Options, printer restic.Printer) (backend.Backend, error) {
+	repo, err := readRepo(gopts)
+	if err != nil {
+		return nil, err
+	}
+	return innerOpenBackend(ctx, repo, gopts, gopts.Extended, false, printer)
+}
+
 // hasRepositoryConfig checks if the repository config file exists and is not empty.
 func hasRepositoryConfig(ctx context.Context, be backend.Backend, repo string, gopts Options) error {
 	fi, err := be.Stat(ctx, backend.Handle{Type: backend.ConfigFile})
@@ -356,11 +455,20 @@ func hasRepositoryConfig(ctx context.Context, be backend.Backend, repo string, g
 
 // createRepositoryInstance creates a new repository instance with the given options.
 func createRepositoryInstance(be backend.Backend, gopts Options) (*repository.Repository, error) {
-	s, err := repository.New(be, repository.Options{
-		Compression:   gopts.Compression,
-		PackSize:      gopts.PackSize * 1024 * 1024,
-		NoExtraVerify: gopts.NoExtraVerify,
-	})
+	opts := repository.Options{
+		Compression:    gopts.Compression,
+		PackSize:       gopts.PackSize * 1024 * 1024,
+		TreePackSize:   gopts.TreePackSize * 1024 * 1024,
+		DataPackSize:   gopts.DataPackSize * 1024 * 1024,
+		NoExtraVerify:  gopts.NoExtraVerify,
+		LowMemoryIndex: gopts.LowMemoryIndex,
+		HedgeReadAfter: gopts.HedgeReadAfter,
+	}
+	if err := opts.ApplyExtendedOptions(gopts.Extended); err != nil {
+		return nil, err
+	}
+
+	s, err := repository.New(be, opts)
 	if err != nil {
 		return nil, errors.Fatalf("%s", err)
 	}
@@ -369,6 +477,10 @@ func createRepositoryInstance(be backend.Backend, gopts Options) (*repository.Re
 
 // decryptRepository handles password reading and decrypts the repository.
 func decryptRepository(ctx context.Context, s *repository.Repository, gopts *Options, printer restic.Printer) error {
+	if gopts.IdentityFile != "" {
+		return decryptRepositoryWithIdentities(ctx, s, gopts)
+	}
+
 	passwordTriesLeft := 1
 	if gopts.Term.InputIsTerminal() && gopts.Password == "" && !gopts.InsecureNoPassword {
 		passwordTriesLeft = 3
@@ -404,6 +516,60 @@ func decryptRepository(ctx context.Context, s *repository.Repository, gopts *Opt
 	return nil
 }
 
+// decryptRepositoryWithIdentities decrypts the repository using the identities
+// from the identity file instead of a password.
+func decryptRepositoryWithIdentities(ctx context.Context, s *repository.Repository, gopts *Options) error {
+	if gopts.PasswordFile != "" || gopts.PasswordCommand != "" || gopts.InsecureNoPassword {
+		return errors.Fatal("--identity-file must not be specified together with a password file, password command or --insecure-no-password")
+	}
+
+	identities, err := LoadIdentitiesFromFile(gopts.IdentityFile)
+	if err != nil {
+		return err
+	}
+
+	err = s.SearchKeyWithIdentities(ctx, identities, maxKeys, gopts.KeyHint)
+	if err != nil {
+		if errors.IsFatal(err) {
+			return err
+		}
+		if errors.Is(err, repository.ErrNoKeyFound) {
+			return errors.Fatalf("no key found for the identities in %v", gopts.IdentityFile)
+		}
+		return errors.Fatalf("%s", err)
+	}
+	return nil
+}
+
+// LoadIdentitiesFromFile loads the X25519 identities from a file in the format
+// used by age-keygen. Empty lines and lines starting with # are ignored.
+func LoadIdentitiesFromFile(filename string) ([]*crypto.Identity, error) {
+	buf, err := textfile.Read(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errors.Fatalf("%s does not exist", filename)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "Readfile")
+	}
+
+	var identities []*crypto.Identity
+	for _, line := range strings.Split(string(buf), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		identity, err := crypto.ParseIdentity(line)
+		if err != nil {
+			return nil, errors.Fatalf("invalid identity file %v: %v", filename, err)
+		}
+		identities = append(identities, identity)
+	}
+	if len(identities) == 0 {
+		return nil, errors.Fatalf("no identities found in %v", filename)
+	}
+	return identities, nil
+}
+
 // printRepositoryInfo displays the repository ID, version and compression level.
 func printRepositoryInfo(s *repository.Repository, gopts Options, printer restic.Printer) {
 	id := s.Config().ID
@@ -429,6 +595,17 @@ func setupCache(s *repository.Repository, gopts Options, printer restic.Printer)
 		printer.PT("created new cache in %v", c.Base)
 	}
 
+	if gopts.CacheSize != "" {
+		maxSize, err := ui.ParseBytes(gopts.CacheSize)
+		if err != nil {
+			return errors.Fatalf("invalid --cache-size %q: %v", gopts.CacheSize, err)
+		}
+		if err := c.SetMaxSize(maxSize); err != nil {
+			printer.E("unable to determine the size of the cache: %v", err)
+			return err
+		}
+	}
+
 	// start using the cache
 	s.UseCache(c, printer.E)
 
@@ -459,8 +636,8 @@ func setupCache(s *repository.Repository, gopts Options, printer restic.Printer)
 	return nil
 }
 
-// CreateRepository a repository with the given version and chunker polynomial.
-func CreateRepository(ctx context.Context, gopts Options, version uint, chunkerPolynomial *chunker.Pol, printer restic.Printer) (*repository.Repository, error) {
+// CreateRepository a repository with the given version and chunker parameters.
+func CreateRepository(ctx context.Context, gopts Options, version uint, chunkerPolynomial *chunker.Pol, chunkerParams *restic.ChunkerParams, printer restic.Printer) (*repository.Repository, error) {
 	if version < restic.MinRepoVersion || version > restic.MaxRepoVersion {
 		return nil, errors.Fatalf("only repository versions between %v and %v are allowed", restic.MinRepoVersion, restic.MaxRepoVersion)
 	}
@@ -487,7 +664,7 @@ func CreateRepository(ctx context.Context, gopts Options, version uint, chunkerP
 		return nil, err
 	}
 
-	err = s.Init(ctx, version, gopts.Password, chunkerPolynomial)
+	err = s.Init(ctx, version, gopts.Password, chunkerPolynomial, chunkerParams)
 	if err != nil {
 		return nil, errors.Fatalf("create key in repository at %s failed: %v", location.StripPassword(gopts.Backends, repo), err)
 	}
@@ -498,12 +675,19 @@ func CreateRepository(ctx context.Context, gopts Options, version uint, chunkerP
 func innerOpenBackend(ctx context.Context, s string, gopts Options, opts options.Options, create bool, printer restic.Printer) (backend.Backend, error) {
 	debug.Log("parsing location %v", location.StripPassword(gopts.Backends, s))
 
-	scheme, cfg, err := parseConfig(gopts.Backends, s, opts)
+	scheme, cfg, limits, err := parseConfig(gopts.Backends, s, opts, gopts.Limits)
 	if err != nil {
 		return nil, err
 	}
 
-	rt, lim, err := setupTransport(gopts)
+	if cfg, ok := cfg.(backend.TransportConfigurer); ok {
+		cfg.ConfigureTransport(&gopts.TransportOptions)
+	}
+	if cfg, ok := cfg.(*mirror.Config); ok {
+		cfg.Opener = &mirrorOpener{gopts: gopts, opts: opts, printer: printer}
+	}
+
+	rt, lim, err := setupTransport(gopts, limits)
 	if err != nil {
 		return nil, err
 	}
@@ -521,11 +705,59 @@ func innerOpenBackend(ctx context.Context, s string, gopts Options, opts options
 	return be, nil
 }
 
-// parseConfig parses the repository location and extended options and returns the scheme and configuration.
-func parseConfig(backends *location.Registry, s string, opts options.Options) (string, interface{}, error) {
+// mirrorOpener opens the backends of the repositories mirrored by the mirror
+// backend. The retry wrapper is only applied to the mirror backend, such that
+// reads fail over to another mirror without delay.
+type mirrorOpener struct {
+	gopts   Options
+	opts    options.Options
+	printer restic.Printer
+}
+
+func (o *mirrorOpener) Open(ctx context.Context, s string, create bool) (backend.Backend, error) {
+	scheme, cfg, limits, err := parseConfig(o.gopts.Backends, s, o.opts, o.gopts.Limits)
+	if err != nil {
+		return nil, err
+	}
+
+	// the transport options of one mirror must not affect the others
+	gopts := o.gopts
+	if cfg, ok := cfg.(backend.TransportConfigurer); ok {
+		cfg.ConfigureTransport(&gopts.TransportOptions)
+	}
+
+	rt, lim, err := setupTransport(gopts, limits)
+	if err != nil {
+		return nil, err
+	}
+
+	factory := o.gopts.Backends.Lookup(scheme)
+	if factory == nil {
+		return nil, errors.Fatalf("invalid backend: %q", scheme)
+	}
+
+	var be backend.Backend
+	if create {
+		be, err = factory.Create(ctx, cfg, rt, lim, o.printer.E)
+	} else {
+		be, err = factory.Open(ctx, cfg, rt, lim, o.printer.E)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return logger.New(sema.NewBackend(be)), nil
+}
+
+func (o *mirrorOpener) StripPassword(s string) string {
+	return location.StripPassword(o.gopts.Backends, s)
+}
+
+// parseConfig parses the repository location and extended options and returns
+// the scheme, configuration and the bandwidth limits for the backend.
+func parseConfig(backends *location.Registry, s string, opts options.Options, limits limiter.Limits) (string, interface{}, limiter.Limits, error) {
 	loc, err := location.Parse(backends, s)
 	if err != nil {
-		return "", nil, errors.Fatalf("parsing repository location failed: %v", err)
+		return "", nil, limiter.Limits{}, errors.Fatalf("parsing repository location failed: %v", err)
 	}
 
 	cfg := loc.Config
@@ -535,23 +767,41 @@ func parseConfig(backends *location.Registry, s string, opts options.Options) (s
 
 	// only apply options for a particular backend here
 	opts = opts.Extract(loc.Scheme)
+
+	// the bandwidth limits are supported by all backends
+	limitOpts := make(options.Options)
+	for _, key := range []string{"limit-upload", "limit-download"} {
+		if v, ok := opts[key]; ok {
+			limitOpts[key] = v
+			delete(opts, key)
+		}
+	}
+	var backendLimits limiter.BackendOptions
+	if err := limitOpts.Apply(loc.Scheme, &backendLimits); err != nil {
+		return "", nil, limiter.Limits{}, err
+	}
+	limits, err = backendLimits.Apply(limits)
+	if err != nil {
+		return "", nil, limiter.Limits{}, err
+	}
+
 	if err := opts.Apply(loc.Scheme, cfg); err != nil {
-		return "", nil, err
+		return "", nil, limiter.Limits{}, err
 	}
 
 	debug.Log("opening %v repository at %#v", loc.Scheme, cfg)
-	return loc.Scheme, cfg, nil
+	return loc.Scheme, cfg, limits, nil
 }
 
 // setupTransport creates and configures the transport with rate limiting.
-func setupTransport(gopts Options) (http.RoundTripper, limiter.Limiter, error) {
+func setupTransport(gopts Options, limits limiter.Limits) (http.RoundTripper, limiter.Limiter, error) {
 	rt, err := backend.Transport(gopts.TransportOptions)
 	if err != nil {
 		return nil, nil, errors.Fatalf("%s", err)
 	}
 
 	// wrap the transport so that the throughput via HTTP is limited
-	lim := limiter.NewStaticLimiter(gopts.Limits)
+	lim := limiter.NewStaticLimiter(limits)
 	rt = lim.Transport(rt)
 
 	return rt, lim, nil
@@ -9,7 +9,11 @@ import (
 
 	"github.com/spf13/pflag"
 
+	"github.com/restic/restic/internal/backend/all"
+	"github.com/restic/restic/internal/backend/limiter"
+	"github.com/restic/restic/internal/backend/local"
 	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/options"
 	rtest "github.com/restic/restic/internal/test"
 )
 
@@ -128,3 +132,21 @@ func TestCompressionEnvIgnoredWhenFlagSet(t *testing.T) {
 	rtest.OK(t, err)
 	rtest.Equals(t, "off", gopts.Compression.String())
 }
+
+func TestParseConfigLimits(t *testing.T) {
+	backends := all.Backends()
+	global := limiter.Limits{UploadKb: 1, DownloadKb: 2}
+
+	_, _, limits, err := parseConfig(backends, "/srv/repo", options.Options{}, global)
+	rtest.OK(t, err)
+	rtest.Equals(t, global, limits)
+
+	opts := options.Options{"local.limit-upload": "10M", "local.connections": "3", "s3.limit-download": "5"}
+	_, cfg, limits, err := parseConfig(backends, "/srv/repo", opts, global)
+	rtest.OK(t, err)
+	rtest.Equals(t, limiter.Limits{UploadKb: 10 * 1024, DownloadKb: 2}, limits)
+	rtest.Equals(t, uint(3), cfg.(*local.Config).Connections)
+
+	_, _, _, err = parseConfig(backends, "/srv/repo", options.Options{"local.limit-upload": "fast"}, global)
+	rtest.Assert(t, err != nil, "expected error for invalid limit")
+}
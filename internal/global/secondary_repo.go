@@ -4,6 +4,7 @@ import (
 	"context"
 	"os"
 
+	"github.com/restic/restic/internal/backend/limiter"
 	"github.com/restic/restic/internal/errors"
 	"github.com/spf13/pflag"
 )
@@ -17,6 +18,7 @@ type SecondaryRepoOptions struct {
 	PasswordCommand    string
 	KeyHint            string
 	InsecureNoPassword bool
+	Limits             limiter.Limits
 	// repo2 options
 	LegacyRepo            string
 	LegacyRepositoryFile  string
@@ -51,6 +53,8 @@ func (opts *SecondaryRepoOptions) AddFlags(f *pflag.FlagSet, repoPrefix string,
 	f.StringVarP(&opts.KeyHint, "from-key-hint", "", "", "key ID of key to try decrypting the source repository first (default: $RESTIC_FROM_KEY_HINT)")
 	f.StringVarP(&opts.PasswordCommand, "from-password-command", "", "", "shell `command` to obtain the source repository password from (default: $RESTIC_FROM_PASSWORD_COMMAND)")
 	f.BoolVar(&opts.InsecureNoPassword, "from-insecure-no-password", false, "use an empty password for the source repository (insecure)")
+	f.IntVar(&opts.Limits.UploadKb, "from-limit-upload", 0, "limits uploads to the source repository to a maximum `rate` in KiB/s. (default: --limit-upload)")
+	f.IntVar(&opts.Limits.DownloadKb, "from-limit-download", 0, "limits downloads from the source repository to a maximum `rate` in KiB/s. (default: --limit-download)")
 
 	opts.Repo = os.Getenv("RESTIC_FROM_REPOSITORY")
 	opts.RepositoryFile = os.Getenv("RESTIC_FROM_REPOSITORY_FILE")
@@ -75,6 +79,8 @@ func (opts *SecondaryRepoOptions) FillGlobalOpts(ctx context.Context, gopts Opti
 
 	var err error
 	dstGopts := gopts
+	// identities are only used for the primary repository
+	dstGopts.IdentityFile = ""
 	var pwdEnv string
 
 	if hasFromRepo {
@@ -107,6 +113,13 @@ func (opts *SecondaryRepoOptions) FillGlobalOpts(ctx context.Context, gopts Opti
 		pwdEnv = "RESTIC_PASSWORD2"
 	}
 
+	if opts.Limits.UploadKb != 0 {
+		dstGopts.Limits.UploadKb = opts.Limits.UploadKb
+	}
+	if opts.Limits.DownloadKb != 0 {
+		dstGopts.Limits.DownloadKb = opts.Limits.DownloadKb
+	}
+
 	if opts.Password != "" {
 		dstGopts.Password = opts.Password
 	} else {
@@ -0,0 +1,65 @@
+//go:build darwin || freebsd || linux
+
+package nfs
+
+import (
+	"context"
+)
+
+// MOUNT version 3 procedures (RFC 1813, Appendix I)
+const (
+	mountProcNull    = 0
+	mountProcMnt     = 1
+	mountProcDump    = 2
+	mountProcUmnt    = 3
+	mountProcUmntAll = 4
+	mountProcExport  = 5
+
+	mountOK       = 0
+	mountErrNoEnt = 2
+
+	maxPathLen = 1024
+)
+
+// handleMount implements the MOUNT protocol, which clients use to obtain the
+// file handle of the exported directory.
+func (s *Server) handleMount(_ context.Context, call *rpcCall, w *xdrWriter) uint32 {
+	if call.vers != 3 {
+		return acceptProgMismatch
+	}
+
+	switch call.proc {
+	case mountProcNull, mountProcUmntAll:
+	case mountProcMnt:
+		path := call.args.string(maxPathLen)
+		if call.args.err != nil {
+			return acceptGarbageArgs
+		}
+		if path != ExportPath {
+			w.uint32(mountErrNoEnt)
+			return acceptSuccess
+		}
+		w.uint32(mountOK)
+		w.opaque(fileHandle(s.rootID))
+		// supported authentication flavors
+		w.uint32(1)
+		w.uint32(authUnix)
+	case mountProcUmnt:
+		call.args.string(maxPathLen)
+		if call.args.err != nil {
+			return acceptGarbageArgs
+		}
+	case mountProcDump:
+		// the list of mounts is not tracked
+		w.bool(false)
+	case mountProcExport:
+		w.bool(true)
+		w.string(ExportPath)
+		// no restriction on groups
+		w.bool(false)
+		w.bool(false)
+	default:
+		return acceptProcUnavail
+	}
+	return acceptSuccess
+}
@@ -0,0 +1,571 @@
+//go:build darwin || freebsd || linux
+
+package nfs
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/anacrolix/fuse"
+	"github.com/anacrolix/fuse/fs"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+)
+
+// NFS version 3 procedures (RFC 1813)
+const (
+	nfs3ProcNull        = 0
+	nfs3ProcGetAttr     = 1
+	nfs3ProcSetAttr     = 2
+	nfs3ProcLookup      = 3
+	nfs3ProcAccess      = 4
+	nfs3ProcReadlink    = 5
+	nfs3ProcRead        = 6
+	nfs3ProcWrite       = 7
+	nfs3ProcCreate      = 8
+	nfs3ProcMkdir       = 9
+	nfs3ProcSymlink     = 10
+	nfs3ProcMknod       = 11
+	nfs3ProcRemove      = 12
+	nfs3ProcRmdir       = 13
+	nfs3ProcRename      = 14
+	nfs3ProcLink        = 15
+	nfs3ProcReadDir     = 16
+	nfs3ProcReadDirPlus = 17
+	nfs3ProcFsStat      = 18
+	nfs3ProcFsInfo      = 19
+	nfs3ProcPathConf    = 20
+	nfs3ProcCommit      = 21
+)
+
+// NFS version 3 status codes
+const (
+	nfs3OK             = 0
+	nfs3ErrNoEnt       = 2
+	nfs3ErrIO          = 5
+	nfs3ErrAccess      = 13
+	nfs3ErrNotDir      = 20
+	nfs3ErrIsDir       = 21
+	nfs3ErrInval       = 22
+	nfs3ErrROFS        = 30
+	nfs3ErrNameTooLong = 63
+	nfs3ErrStale       = 70
+	nfs3ErrBadHandle   = 10001
+	nfs3ErrTooSmall    = 10005
+)
+
+// file types of fattr3
+const (
+	nf3Reg  = 1
+	nf3Dir  = 2
+	nf3Blk  = 3
+	nf3Chr  = 4
+	nf3Lnk  = 5
+	nf3Sock = 6
+	nf3Fifo = 7
+)
+
+// ACCESS permission bits which are granted on the read-only file system
+const (
+	access3Read    = 0x01
+	access3Lookup  = 0x02
+	access3Execute = 0x20
+)
+
+const (
+	// maximum size of a file handle
+	fhSize3 = 64
+	// maximum length of a file name
+	maxNameLen = 255
+	// maximum amount of data returned by a single READ
+	maxReadSize = 1 << 20
+
+	// FSINFO properties: hard links, symlinks and a constant time_delta
+	fsf3Link        = 0x01
+	fsf3Symlink     = 0x02
+	fsf3Homogeneous = 0x08
+
+	// file system ID reported for all files
+	fsid = 0x72657374
+)
+
+// handleNFS implements the NFS protocol version 3.
+func (s *Server) handleNFS(ctx context.Context, call *rpcCall, w *xdrWriter) uint32 {
+	if call.vers != 3 {
+		return acceptProgMismatch
+	}
+
+	var handler func(context.Context, *xdrReader, *xdrWriter)
+	switch call.proc {
+	case nfs3ProcNull:
+		handler = func(context.Context, *xdrReader, *xdrWriter) {}
+	case nfs3ProcGetAttr:
+		handler = s.getAttr
+	case nfs3ProcLookup:
+		handler = s.lookupName
+	case nfs3ProcAccess:
+		handler = s.access
+	case nfs3ProcReadlink:
+		handler = s.readlink
+	case nfs3ProcRead:
+		handler = s.read
+	case nfs3ProcReadDir:
+		handler = func(ctx context.Context, args *xdrReader, w *xdrWriter) {
+			s.readDir(ctx, args, w, false)
+		}
+	case nfs3ProcReadDirPlus:
+		handler = func(ctx context.Context, args *xdrReader, w *xdrWriter) {
+			s.readDir(ctx, args, w, true)
+		}
+	case nfs3ProcFsStat:
+		handler = s.fsStat
+	case nfs3ProcFsInfo:
+		handler = s.fsInfo
+	case nfs3ProcPathConf:
+		handler = s.pathConf
+	case nfs3ProcSetAttr, nfs3ProcWrite, nfs3ProcCreate, nfs3ProcMkdir, nfs3ProcSymlink,
+		nfs3ProcMknod, nfs3ProcRemove, nfs3ProcRmdir, nfs3ProcCommit:
+		// the result contains a wcc_data, which is empty
+		w.uint32(nfs3ErrROFS)
+		w.bool(false)
+		w.bool(false)
+		return acceptSuccess
+	case nfs3ProcRename:
+		// wcc_data for the source and target directory
+		w.uint32(nfs3ErrROFS)
+		for i := 0; i < 4; i++ {
+			w.bool(false)
+		}
+		return acceptSuccess
+	case nfs3ProcLink:
+		// post_op_attr of the file and wcc_data of the directory
+		w.uint32(nfs3ErrROFS)
+		for i := 0; i < 3; i++ {
+			w.bool(false)
+		}
+		return acceptSuccess
+	default:
+		return acceptProcUnavail
+	}
+
+	// the result is discarded if the arguments cannot be decoded
+	res := &xdrWriter{}
+	handler(ctx, call.args, res)
+	if call.args.err != nil {
+		return acceptGarbageArgs
+	}
+	w.buf = append(w.buf, res.buf...)
+	return acceptSuccess
+}
+
+// nfsStatus converts an error returned by a node to an NFS status.
+func nfsStatus(err error) uint32 {
+	var errno syscall.Errno
+	var errnum fuse.ErrorNumber
+	switch {
+	case errors.As(err, &errno):
+	case errors.As(err, &errnum):
+		errno = syscall.Errno(errnum.Errno())
+	default:
+		debug.Log("error %v", err)
+		return nfs3ErrIO
+	}
+
+	switch errno {
+	case syscall.ENOENT:
+		return nfs3ErrNoEnt
+	case syscall.EACCES, syscall.EPERM:
+		return nfs3ErrAccess
+	case syscall.ENOTDIR:
+		return nfs3ErrNotDir
+	case syscall.EISDIR:
+		return nfs3ErrIsDir
+	case syscall.EINVAL:
+		return nfs3ErrInval
+	case syscall.EROFS:
+		return nfs3ErrROFS
+	case syscall.ENAMETOOLONG:
+		return nfs3ErrNameTooLong
+	default:
+		return nfs3ErrIO
+	}
+}
+
+// fileType returns the fattr3 type for a file mode.
+func fileType(mode os.FileMode) uint32 {
+	switch {
+	case mode&os.ModeDir != 0:
+		return nf3Dir
+	case mode&os.ModeSymlink != 0:
+		return nf3Lnk
+	case mode&os.ModeDevice != 0 && mode&os.ModeCharDevice != 0:
+		return nf3Chr
+	case mode&os.ModeDevice != 0:
+		return nf3Blk
+	case mode&os.ModeNamedPipe != 0:
+		return nf3Fifo
+	case mode&os.ModeSocket != 0:
+		return nf3Sock
+	default:
+		return nf3Reg
+	}
+}
+
+func writeTime(w *xdrWriter, t time.Time) {
+	if t.IsZero() || t.Unix() < 0 {
+		w.uint32(0)
+		w.uint32(0)
+		return
+	}
+	w.uint32(uint32(t.Unix()))
+	w.uint32(uint32(t.Nanosecond()))
+}
+
+// writeAttr writes the fattr3 of a file.
+func writeAttr(w *xdrWriter, id uint64, a *fuse.Attr) {
+	mode := uint32(a.Mode.Perm())
+	if a.Mode&os.ModeSetuid != 0 {
+		mode |= 0o4000
+	}
+	if a.Mode&os.ModeSetgid != 0 {
+		mode |= 0o2000
+	}
+	if a.Mode&os.ModeSticky != 0 {
+		mode |= 0o1000
+	}
+
+	w.uint32(fileType(a.Mode))
+	w.uint32(mode)
+	w.uint32(max(a.Nlink, 1))
+	w.uint32(a.Uid)
+	w.uint32(a.Gid)
+	w.uint64(a.Size)
+	w.uint64(a.Blocks * 512)
+	// specdata3, major and minor device number
+	w.uint32(a.Rdev >> 8 & 0xfff)
+	w.uint32(a.Rdev&0xff | a.Rdev>>12&0xfff00)
+	w.uint64(fsid)
+	w.uint64(id)
+	writeTime(w, a.Atime)
+	writeTime(w, a.Mtime)
+	writeTime(w, a.Ctime)
+}
+
+// attr returns the attributes of a node.
+func attr(ctx context.Context, node fs.Node) (*fuse.Attr, uint32) {
+	var a fuse.Attr
+	if err := node.Attr(ctx, &a); err != nil {
+		return nil, nfsStatus(err)
+	}
+	return &a, nfs3OK
+}
+
+// writePostOpAttr writes the optional attributes of a file.
+func writePostOpAttr(ctx context.Context, w *xdrWriter, id uint64, node fs.Node) {
+	a, stat := attr(ctx, node)
+	w.bool(stat == nfs3OK)
+	if stat == nfs3OK {
+		writeAttr(w, id, a)
+	}
+}
+
+func (s *Server) getAttr(ctx context.Context, args *xdrReader, w *xdrWriter) {
+	id, e, stat := s.lookup(args.opaque(fhSize3))
+	if stat != nfs3OK {
+		w.uint32(stat)
+		return
+	}
+	a, stat := attr(ctx, e.node)
+	w.uint32(stat)
+	if stat == nfs3OK {
+		writeAttr(w, id, a)
+	}
+}
+
+// child returns the ID and node of the entry name in the directory dir.
+func (s *Server) child(ctx context.Context, dirID uint64, dir *entry, name string) (uint64, fs.Node, uint32) {
+	switch name {
+	case ".":
+		return dirID, dir.node, nfs3OK
+	case "..":
+		s.m.Lock()
+		parent := s.entries[dir.parent]
+		s.m.Unlock()
+		return dir.parent, parent.node, nfs3OK
+	}
+	if len(name) > maxNameLen {
+		return 0, nil, nfs3ErrNameTooLong
+	}
+
+	var node fs.Node
+	var err error
+	switch d := dir.node.(type) {
+	case fs.NodeStringLookuper:
+		node, err = d.Lookup(ctx, name)
+	case fs.NodeRequestLookuper:
+		node, err = d.Lookup(ctx, &fuse.LookupRequest{Name: name}, &fuse.LookupResponse{})
+	default:
+		return 0, nil, nfs3ErrNotDir
+	}
+	if err != nil {
+		return 0, nil, nfsStatus(err)
+	}
+
+	id, err := s.register(ctx, node, dirID)
+	if err != nil {
+		return 0, nil, nfsStatus(err)
+	}
+	return id, node, nfs3OK
+}
+
+func (s *Server) lookupName(ctx context.Context, args *xdrReader, w *xdrWriter) {
+	fh := args.opaque(fhSize3)
+	name := args.string(maxNameLen + 1)
+	dirID, dir, stat := s.lookup(fh)
+	if stat != nfs3OK {
+		w.uint32(stat)
+		w.bool(false)
+		return
+	}
+
+	id, node, stat := s.child(ctx, dirID, dir, name)
+	w.uint32(stat)
+	if stat != nfs3OK {
+		writePostOpAttr(ctx, w, dirID, dir.node)
+		return
+	}
+	w.opaque(fileHandle(id))
+	writePostOpAttr(ctx, w, id, node)
+	writePostOpAttr(ctx, w, dirID, dir.node)
+}
+
+func (s *Server) access(ctx context.Context, args *xdrReader, w *xdrWriter) {
+	id, e, stat := s.lookup(args.opaque(fhSize3))
+	requested := args.uint32()
+	if stat != nfs3OK {
+		w.uint32(stat)
+		w.bool(false)
+		return
+	}
+	w.uint32(nfs3OK)
+	writePostOpAttr(ctx, w, id, e.node)
+	w.uint32(requested & (access3Read | access3Lookup | access3Execute))
+}
+
+func (s *Server) readlink(ctx context.Context, args *xdrReader, w *xdrWriter) {
+	id, e, stat := s.lookup(args.opaque(fhSize3))
+	if stat != nfs3OK {
+		w.uint32(stat)
+		w.bool(false)
+		return
+	}
+
+	link, ok := e.node.(fs.NodeReadlinker)
+	if !ok {
+		w.uint32(nfs3ErrInval)
+		writePostOpAttr(ctx, w, id, e.node)
+		return
+	}
+	target, err := link.Readlink(ctx, &fuse.ReadlinkRequest{})
+	if err != nil {
+		w.uint32(nfsStatus(err))
+		writePostOpAttr(ctx, w, id, e.node)
+		return
+	}
+	w.uint32(nfs3OK)
+	writePostOpAttr(ctx, w, id, e.node)
+	w.string(target)
+}
+
+func (s *Server) read(ctx context.Context, args *xdrReader, w *xdrWriter) {
+	id, e, stat := s.lookup(args.opaque(fhSize3))
+	offset := args.uint64()
+	count := min(args.uint32(), maxReadSize)
+	if stat != nfs3OK {
+		w.uint32(stat)
+		w.bool(false)
+		return
+	}
+
+	a, stat := attr(ctx, e.node)
+	if stat == nfs3OK && a.Mode.IsDir() {
+		stat = nfs3ErrIsDir
+	}
+	if stat != nfs3OK {
+		w.uint32(stat)
+		w.bool(false)
+		return
+	}
+
+	// the nodes expect that reads do not extend beyond the end of the file
+	var data []byte
+	if offset < a.Size {
+		count = uint32(min(uint64(count), a.Size-offset))
+		rd, err := e.reader(ctx)
+		if err != nil {
+			w.uint32(nfsStatus(err))
+			w.bool(true)
+			writeAttr(w, id, a)
+			return
+		}
+
+		req := &fuse.ReadRequest{Offset: int64(offset), Size: int(count)}
+		resp := &fuse.ReadResponse{Data: make([]byte, count)}
+		if err := rd.Read(ctx, req, resp); err != nil {
+			w.uint32(nfsStatus(err))
+			w.bool(true)
+			writeAttr(w, id, a)
+			return
+		}
+		data = resp.Data
+	}
+
+	w.uint32(nfs3OK)
+	w.bool(true)
+	writeAttr(w, id, a)
+	w.uint32(uint32(len(data)))
+	w.bool(offset+uint64(len(data)) >= a.Size)
+	w.opaque(data)
+}
+
+func (s *Server) readDir(ctx context.Context, args *xdrReader, w *xdrWriter, plus bool) {
+	dirID, dir, stat := s.lookup(args.opaque(fhSize3))
+	cookie := args.uint64()
+	args.fixedOpaque(8)
+	// READDIRPLUS limits the size of the directory information and the
+	// size of the result separately
+	maxCount := args.uint32()
+	if plus {
+		maxCount = args.uint32()
+	}
+	if stat != nfs3OK {
+		w.uint32(stat)
+		w.bool(false)
+		return
+	}
+
+	lister, ok := dir.node.(fs.HandleReadDirAller)
+	if !ok {
+		w.uint32(nfs3ErrNotDir)
+		writePostOpAttr(ctx, w, dirID, dir.node)
+		return
+	}
+	dirents, err := lister.ReadDirAll(ctx)
+	if err != nil {
+		w.uint32(nfsStatus(err))
+		writePostOpAttr(ctx, w, dirID, dir.node)
+		return
+	}
+
+	w.uint32(nfs3OK)
+	writePostOpAttr(ctx, w, dirID, dir.node)
+	// the cookie verifier is not used, cookies are indices into the list
+	// of entries, which does not change for a directory in a snapshot
+	w.fixedOpaque(make([]byte, 8))
+	start := len(w.buf)
+	// the list of entries ends with a false and the eof flag
+	const trailer = 8
+
+	eof := true
+	for i := cookie; i < uint64(len(dirents)); i++ {
+		d := dirents[i]
+		entry := &xdrWriter{}
+		entry.bool(true)
+		entry.uint64(d.Inode)
+		entry.string(d.Name)
+		entry.uint64(i + 1)
+
+		if plus {
+			id, node, stat := s.child(ctx, dirID, dir, d.Name)
+			if stat == nfs3OK {
+				writePostOpAttr(ctx, entry, id, node)
+				entry.bool(true)
+				entry.opaque(fileHandle(id))
+			} else {
+				entry.bool(false)
+				entry.bool(false)
+			}
+		}
+
+		if uint32(len(w.buf)-start+len(entry.buf)+trailer) > maxCount {
+			if len(w.buf) == start {
+				// the result is too small for a single entry
+				w.buf = w.buf[:0]
+				w.uint32(nfs3ErrTooSmall)
+				writePostOpAttr(ctx, w, dirID, dir.node)
+				return
+			}
+			eof = false
+			break
+		}
+		w.buf = append(w.buf, entry.buf...)
+	}
+	w.bool(false)
+	w.bool(eof)
+}
+
+func (s *Server) fsStat(ctx context.Context, args *xdrReader, w *xdrWriter) {
+	id, e, stat := s.lookup(args.opaque(fhSize3))
+	if stat != nfs3OK {
+		w.uint32(stat)
+		w.bool(false)
+		return
+	}
+	w.uint32(nfs3OK)
+	writePostOpAttr(ctx, w, id, e.node)
+	// total, free and available bytes and files
+	for i := 0; i < 6; i++ {
+		w.uint64(0)
+	}
+	// invarsec
+	w.uint32(0)
+}
+
+func (s *Server) fsInfo(ctx context.Context, args *xdrReader, w *xdrWriter) {
+	id, e, stat := s.lookup(args.opaque(fhSize3))
+	if stat != nfs3OK {
+		w.uint32(stat)
+		w.bool(false)
+		return
+	}
+	w.uint32(nfs3OK)
+	writePostOpAttr(ctx, w, id, e.node)
+	// rtmax, rtpref, rtmult
+	w.uint32(maxReadSize)
+	w.uint32(maxReadSize)
+	w.uint32(4096)
+	// wtmax, wtpref, wtmult
+	w.uint32(maxReadSize)
+	w.uint32(maxReadSize)
+	w.uint32(4096)
+	// dtpref
+	w.uint32(64 * 1024)
+	// maxfilesize
+	w.uint64(1<<63 - 1)
+	// time_delta
+	w.uint32(0)
+	w.uint32(1)
+	w.uint32(fsf3Link | fsf3Symlink | fsf3Homogeneous)
+}
+
+func (s *Server) pathConf(ctx context.Context, args *xdrReader, w *xdrWriter) {
+	id, e, stat := s.lookup(args.opaque(fhSize3))
+	if stat != nfs3OK {
+		w.uint32(stat)
+		w.bool(false)
+		return
+	}
+	w.uint32(nfs3OK)
+	writePostOpAttr(ctx, w, id, e.node)
+	// linkmax, name_max
+	w.uint32(1<<32 - 1)
+	w.uint32(maxNameLen)
+	// no_trunc, chown_restricted, case_insensitive, case_preserving
+	w.bool(true)
+	w.bool(true)
+	w.bool(false)
+	w.bool(true)
+}
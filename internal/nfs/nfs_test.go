@@ -0,0 +1,311 @@
+//go:build darwin || freebsd || linux
+
+package nfs
+
+import (
+	"context"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/anacrolix/fuse"
+	"github.com/anacrolix/fuse/fs"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+type testDir struct {
+	inode   uint64
+	entries map[string]fs.Node
+}
+
+func (d *testDir) Root() (fs.Node, error) { return d, nil }
+
+func (d *testDir) Attr(_ context.Context, a *fuse.Attr) error {
+	a.Inode = d.inode
+	a.Mode = os.ModeDir | 0o555
+	return nil
+}
+
+func (d *testDir) Lookup(_ context.Context, name string) (fs.Node, error) {
+	if node, ok := d.entries[name]; ok {
+		return node, nil
+	}
+	return nil, syscall.ENOENT
+}
+
+func (d *testDir) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
+	var dirents []fuse.Dirent
+	for _, name := range []string{"file", "link", "sub"} {
+		if node, ok := d.entries[name]; ok {
+			var a fuse.Attr
+			_ = node.Attr(ctx, &a)
+			dirents = append(dirents, fuse.Dirent{Inode: a.Inode, Name: name})
+		}
+	}
+	return dirents, nil
+}
+
+type testFile struct {
+	inode uint64
+	data  []byte
+}
+
+func (f *testFile) Attr(_ context.Context, a *fuse.Attr) error {
+	a.Inode = f.inode
+	a.Mode = 0o444
+	a.Size = uint64(len(f.data))
+	return nil
+}
+
+func (f *testFile) Read(_ context.Context, req *fuse.ReadRequest, resp *fuse.ReadResponse) error {
+	// like the nodes of the fuse package, rely on the size of resp.Data
+	n := copy(resp.Data[:req.Size], f.data[req.Offset:])
+	resp.Data = resp.Data[:n]
+	return nil
+}
+
+type testLink struct {
+	inode  uint64
+	target string
+}
+
+func (l *testLink) Attr(_ context.Context, a *fuse.Attr) error {
+	a.Inode = l.inode
+	a.Mode = os.ModeSymlink | 0o777
+	return nil
+}
+
+func (l *testLink) Readlink(_ context.Context, _ *fuse.ReadlinkRequest) (string, error) {
+	return l.target, nil
+}
+
+type testClient struct {
+	t    *testing.T
+	conn net.Conn
+	xid  uint32
+}
+
+func startServer(t *testing.T) *testClient {
+	root := &testDir{inode: 1, entries: map[string]fs.Node{
+		"file": &testFile{inode: 2, data: []byte("hello world")},
+		"link": &testLink{inode: 3, target: "file"},
+		"sub":  &testDir{inode: 4, entries: map[string]fs.Node{}},
+	}}
+
+	l, err := net.Listen("tcp", "localhost:0")
+	rtest.OK(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- NewServer(root).Serve(ctx, l)
+	}()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	rtest.OK(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+		cancel()
+		rtest.OK(t, <-done)
+	})
+	return &testClient{t: t, conn: conn}
+}
+
+// call sends an RPC call and returns the decoded results. The accept status
+// must match stat.
+func (c *testClient) call(prog, vers, proc uint32, args []byte, stat uint32) *xdrReader {
+	c.xid++
+	w := &xdrWriter{}
+	w.uint32(c.xid)
+	w.uint32(msgCall)
+	w.uint32(rpcVersion)
+	w.uint32(prog)
+	w.uint32(vers)
+	w.uint32(proc)
+	w.uint32(authNone)
+	w.opaque(nil)
+	w.uint32(authNone)
+	w.opaque(nil)
+	w.buf = append(w.buf, args...)
+	rtest.OK(c.t, writeRecord(c.conn, w.buf))
+
+	record, err := readRecord(c.conn)
+	rtest.OK(c.t, err)
+	r := &xdrReader{buf: record}
+	rtest.Equals(c.t, c.xid, r.uint32())
+	rtest.Equals(c.t, uint32(msgReply), r.uint32())
+	rtest.Equals(c.t, uint32(replyAccepted), r.uint32())
+	r.uint32()
+	r.opaque(maxAuthSize)
+	rtest.Equals(c.t, stat, r.uint32())
+	rtest.OK(c.t, r.err)
+	return r
+}
+
+func (c *testClient) mount() []byte {
+	w := &xdrWriter{}
+	w.string("/")
+	r := c.call(progMount, 3, mountProcMnt, w.buf, acceptSuccess)
+	rtest.Equals(c.t, uint32(mountOK), r.uint32())
+	return r.opaque(fhSize3)
+}
+
+func (c *testClient) lookup(dir []byte, name string) (uint32, []byte) {
+	w := &xdrWriter{}
+	w.opaque(dir)
+	w.string(name)
+	r := c.call(progNFS, 3, nfs3ProcLookup, w.buf, acceptSuccess)
+	stat := r.uint32()
+	if stat != nfs3OK {
+		return stat, nil
+	}
+	return stat, r.opaque(fhSize3)
+}
+
+// skipAttr skips a fattr3 and returns the type and size.
+func skipAttr(r *xdrReader) (uint32, uint64) {
+	typ := r.uint32()
+	for i := 0; i < 4; i++ {
+		r.uint32()
+	}
+	size := r.uint64()
+	r.fixedOpaque(8 + 8 + 8 + 8 + 3*8)
+	return typ, size
+}
+
+func TestMountExport(t *testing.T) {
+	c := startServer(t)
+
+	w := &xdrWriter{}
+	w.string("/other")
+	r := c.call(progMount, 3, mountProcMnt, w.buf, acceptSuccess)
+	rtest.Equals(t, uint32(mountErrNoEnt), r.uint32())
+
+	rtest.Equals(t, fileHandle(1), c.mount())
+
+	c.call(progMount, 1, mountProcMnt, nil, acceptProgMismatch)
+	c.call(42, 3, 0, nil, acceptProgUnavail)
+	c.call(progNFS, 3, 99, nil, acceptProcUnavail)
+}
+
+func TestLookupRead(t *testing.T) {
+	c := startServer(t)
+	root := c.mount()
+
+	stat, _ := c.lookup(root, "missing")
+	rtest.Equals(t, uint32(nfs3ErrNoEnt), stat)
+
+	stat, fh := c.lookup(root, "file")
+	rtest.Equals(t, uint32(nfs3OK), stat)
+	rtest.Equals(t, fileHandle(2), fh)
+
+	for _, test := range []struct {
+		offset uint64
+		count  uint32
+		data   string
+		eof    bool
+	}{
+		{0, 5, "hello", false},
+		{6, 100, "world", true},
+		{20, 10, "", true},
+	} {
+		w := &xdrWriter{}
+		w.opaque(fh)
+		w.uint64(test.offset)
+		w.uint32(test.count)
+		r := c.call(progNFS, 3, nfs3ProcRead, w.buf, acceptSuccess)
+		rtest.Equals(t, uint32(nfs3OK), r.uint32())
+		rtest.Assert(t, r.bool(), "missing attributes")
+		typ, size := skipAttr(r)
+		rtest.Equals(t, uint32(nf3Reg), typ)
+		rtest.Equals(t, uint64(11), size)
+		rtest.Equals(t, uint32(len(test.data)), r.uint32())
+		rtest.Equals(t, test.eof, r.bool())
+		rtest.Equals(t, test.data, string(r.opaque(maxReadSize)))
+	}
+
+	_, fh = c.lookup(root, "link")
+	w := &xdrWriter{}
+	w.opaque(fh)
+	r := c.call(progNFS, 3, nfs3ProcReadlink, w.buf, acceptSuccess)
+	rtest.Equals(t, uint32(nfs3OK), r.uint32())
+	rtest.Assert(t, r.bool(), "missing attributes")
+	typ, _ := skipAttr(r)
+	rtest.Equals(t, uint32(nf3Lnk), typ)
+	rtest.Equals(t, "file", r.string(maxPathLen))
+
+	// modifications are rejected
+	w = &xdrWriter{}
+	w.opaque(root)
+	w.string("new")
+	r = c.call(progNFS, 3, nfs3ProcMkdir, w.buf, acceptSuccess)
+	rtest.Equals(t, uint32(nfs3ErrROFS), r.uint32())
+
+	// unknown file handles
+	stat, _ = c.lookup(fileHandle(100), "file")
+	rtest.Equals(t, uint32(nfs3ErrStale), stat)
+	stat, _ = c.lookup([]byte("short"), "file")
+	rtest.Equals(t, uint32(nfs3ErrBadHandle), stat)
+}
+
+func TestReadDirPlus(t *testing.T) {
+	c := startServer(t)
+	root := c.mount()
+
+	readDir := func(cookie uint64, maxCount uint32) (uint32, []string, uint64, bool) {
+		w := &xdrWriter{}
+		w.opaque(root)
+		w.uint64(cookie)
+		w.fixedOpaque(make([]byte, 8))
+		w.uint32(maxCount)
+		w.uint32(maxCount)
+		r := c.call(progNFS, 3, nfs3ProcReadDirPlus, w.buf, acceptSuccess)
+		stat := r.uint32()
+		if r.bool() {
+			skipAttr(r)
+		}
+		if stat != nfs3OK {
+			return stat, nil, 0, false
+		}
+		r.fixedOpaque(8)
+
+		var names []string
+		for r.bool() {
+			r.uint64()
+			names = append(names, r.string(maxNameLen))
+			cookie = r.uint64()
+			if r.bool() {
+				skipAttr(r)
+			}
+			rtest.Assert(t, r.bool(), "missing file handle")
+			r.opaque(fhSize3)
+		}
+		eof := r.bool()
+		rtest.OK(t, r.err)
+		return stat, names, cookie, eof
+	}
+
+	stat, names, _, eof := readDir(0, 4096)
+	rtest.Equals(t, uint32(nfs3OK), stat)
+	rtest.Equals(t, []string{"file", "link", "sub"}, names)
+	rtest.Assert(t, eof, "expected end of directory")
+
+	// continue reading after a partial result
+	stat, names, cookie, eof := readDir(0, 200)
+	rtest.Equals(t, uint32(nfs3OK), stat)
+	rtest.Equals(t, []string{"file"}, names)
+	rtest.Assert(t, !eof, "unexpected end of directory")
+	_, names, _, eof = readDir(cookie, 4096)
+	rtest.Equals(t, []string{"link", "sub"}, names)
+	rtest.Assert(t, eof, "expected end of directory")
+
+	stat, _, _, _ = readDir(0, 10)
+	rtest.Equals(t, uint32(nfs3ErrTooSmall), stat)
+
+	// entries returned by READDIRPLUS can be used without LOOKUP
+	stat, fh := c.lookup(fileHandle(4), "..")
+	rtest.Equals(t, uint32(nfs3OK), stat)
+	rtest.Equals(t, root, fh)
+}
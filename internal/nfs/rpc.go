@@ -0,0 +1,203 @@
+//go:build darwin || freebsd || linux
+
+package nfs
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+)
+
+// ONC RPC version 2 (RFC 5531) message constants.
+const (
+	rpcVersion = 2
+
+	msgCall  = 0
+	msgReply = 1
+
+	replyAccepted = 0
+	replyDenied   = 1
+
+	rejectRPCMismatch = 0
+
+	acceptSuccess      = 0
+	acceptProgUnavail  = 1
+	acceptProgMismatch = 2
+	acceptProcUnavail  = 3
+	acceptGarbageArgs  = 4
+
+	authNone = 0
+	authUnix = 1
+
+	// maximum size of the credentials and verifier
+	maxAuthSize = 400
+)
+
+// lastFragment is set in the record marking header of the last fragment of
+// a record.
+const lastFragment = 1 << 31
+
+// maxRecordSize limits the size of a request. Requests are small, only
+// replies to READ contain a large amount of data.
+const maxRecordSize = 1 << 20
+
+// rpcCall is a decoded RPC call message.
+type rpcCall struct {
+	xid  uint32
+	prog uint32
+	vers uint32
+	proc uint32
+	args *xdrReader
+}
+
+// rpcProgram handles the calls for a version of an RPC program. The results
+// are written to w, the return value is the accept status of the reply.
+type rpcProgram func(ctx context.Context, call *rpcCall, w *xdrWriter) uint32
+
+// readRecord reads a record which may consist of several fragments.
+func readRecord(rd io.Reader) ([]byte, error) {
+	var record []byte
+	for {
+		var hdr [4]byte
+		if _, err := io.ReadFull(rd, hdr[:]); err != nil {
+			return nil, err
+		}
+		h := binary.BigEndian.Uint32(hdr[:])
+		size := h &^ lastFragment
+		if len(record)+int(size) > maxRecordSize {
+			return nil, errors.Errorf("record of %d bytes is too large", len(record)+int(size))
+		}
+
+		start := len(record)
+		record = append(record, make([]byte, size)...)
+		if _, err := io.ReadFull(rd, record[start:]); err != nil {
+			return nil, err
+		}
+		if h&lastFragment != 0 {
+			return record, nil
+		}
+	}
+}
+
+// writeRecord writes buf as a single fragment.
+func writeRecord(wr io.Writer, buf []byte) error {
+	hdr := binary.BigEndian.AppendUint32(nil, lastFragment|uint32(len(buf)))
+	_, err := wr.Write(append(hdr, buf...))
+	return err
+}
+
+// parseCall decodes an RPC call message. The credentials are ignored.
+func parseCall(buf []byte) (*rpcCall, uint32, error) {
+	r := &xdrReader{buf: buf}
+	call := &rpcCall{xid: r.uint32()}
+	if r.uint32() != msgCall {
+		return nil, 0, errors.New("not an RPC call")
+	}
+	version := r.uint32()
+	call.prog = r.uint32()
+	call.vers = r.uint32()
+	call.proc = r.uint32()
+	// credentials and verifier
+	for i := 0; i < 2; i++ {
+		r.uint32()
+		r.opaque(maxAuthSize)
+	}
+	if r.err != nil {
+		return nil, 0, r.err
+	}
+	call.args = r
+	return call, version, nil
+}
+
+// serveConn handles the RPC calls received via conn. Calls are processed
+// concurrently, as clients send several requests without waiting for replies.
+func serveConn(ctx context.Context, conn net.Conn, programs map[uint32]rpcProgram) {
+	debug.Log("new connection from %v", conn.RemoteAddr())
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	var writeMu sync.Mutex
+	rd := bufio.NewReader(conn)
+	for {
+		record, err := readRecord(rd)
+		if err != nil {
+			if err != io.EOF {
+				debug.Log("connection from %v: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			reply := handleCall(ctx, record, programs)
+			if reply == nil {
+				return
+			}
+			writeMu.Lock()
+			defer writeMu.Unlock()
+			if err := writeRecord(conn, reply); err != nil {
+				debug.Log("connection from %v: %v", conn.RemoteAddr(), err)
+				_ = conn.Close()
+			}
+		}()
+	}
+}
+
+// handleCall dispatches a call to the program and returns the encoded reply.
+// Invalid messages are dropped by returning nil.
+func handleCall(ctx context.Context, record []byte, programs map[uint32]rpcProgram) []byte {
+	call, version, err := parseCall(record)
+	if err != nil {
+		debug.Log("invalid RPC call: %v", err)
+		return nil
+	}
+
+	w := &xdrWriter{}
+	w.uint32(call.xid)
+	w.uint32(msgReply)
+	if version != rpcVersion {
+		w.uint32(replyDenied)
+		w.uint32(rejectRPCMismatch)
+		w.uint32(rpcVersion)
+		w.uint32(rpcVersion)
+		return w.buf
+	}
+	w.uint32(replyAccepted)
+	// verifier
+	w.uint32(authNone)
+	w.opaque(nil)
+
+	program, ok := programs[call.prog]
+	if !ok {
+		w.uint32(acceptProgUnavail)
+		return w.buf
+	}
+
+	header := len(w.buf)
+	// reserve space for the accept status
+	w.uint32(acceptSuccess)
+	stat := program(ctx, call, w)
+	if stat != acceptSuccess {
+		// drop partially encoded results
+		w.buf = w.buf[:header]
+		w.uint32(stat)
+		if stat == acceptProgMismatch {
+			// both MOUNT and NFS are only supported in version 3
+			w.uint32(3)
+			w.uint32(3)
+		}
+	}
+	return w.buf
+}
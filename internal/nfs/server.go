@@ -0,0 +1,180 @@
+//go:build darwin || freebsd || linux
+
+// Package nfs serves a read-only file system via NFS version 3 (RFC 1813), as
+// an alternative to mounting it using FUSE. The file system is described by the
+// same interfaces used for FUSE mounts, thus the package is only available on
+// the platforms supported by the fuse package.
+package nfs
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"github.com/anacrolix/fuse"
+	"github.com/anacrolix/fuse/fs"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+)
+
+// RPC program numbers
+const (
+	progNFS   = 100003
+	progMount = 100005
+)
+
+// ExportPath is the only directory which can be mounted by clients.
+const ExportPath = "/"
+
+// entry is a file or directory which was looked up by a client.
+type entry struct {
+	node fs.Node
+	// ID of the parent directory
+	parent uint64
+
+	// handle for reading, opened on first use
+	openMu sync.Mutex
+	handle fs.Handle
+}
+
+// Server serves a file system via NFS.
+type Server struct {
+	root fs.FS
+
+	m       sync.Mutex
+	entries map[uint64]*entry
+	rootID  uint64
+
+	// used for nodes without an inode number
+	nextID atomic.Uint64
+}
+
+// NewServer returns a server for the file system with the root node root.
+func NewServer(root fs.FS) *Server {
+	s := &Server{
+		root:    root,
+		entries: make(map[uint64]*entry),
+	}
+	s.nextID.Store(1 << 63)
+	return s
+}
+
+// Serve accepts connections on l until ctx is cancelled. Both the MOUNT and
+// the NFS protocol are served on the same port, clients must be configured to
+// use this port for both, as no portmapper is available.
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	node, err := s.root.Root()
+	if err != nil {
+		return err
+	}
+	s.rootID, err = s.register(ctx, node, 0)
+	if err != nil {
+		return err
+	}
+	s.entries[s.rootID].parent = s.rootID
+
+	programs := map[uint32]rpcProgram{
+		progMount: s.handleMount,
+		progNFS:   s.handleNFS,
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		_ = l.Close()
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			go func() {
+				// abort blocked reads once the server is stopped
+				<-ctx.Done()
+				_ = conn.Close()
+			}()
+			serveConn(ctx, conn, programs)
+		}()
+	}
+}
+
+// register adds node to the list of entries and returns its ID. The ID is
+// the inode number of the node, such that a node which is looked up again
+// keeps its file handle.
+func (s *Server) register(ctx context.Context, node fs.Node, parent uint64) (uint64, error) {
+	var attr fuse.Attr
+	if err := node.Attr(ctx, &attr); err != nil {
+		return 0, err
+	}
+	id := attr.Inode
+	if id == 0 {
+		id = s.nextID.Add(1)
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+	if _, ok := s.entries[id]; !ok {
+		s.entries[id] = &entry{node: node, parent: parent}
+	}
+	return id, nil
+}
+
+// lookup returns the entry for a file handle.
+func (s *Server) lookup(fh []byte) (uint64, *entry, uint32) {
+	if len(fh) != 8 {
+		return 0, nil, nfs3ErrBadHandle
+	}
+	id := binary.BigEndian.Uint64(fh)
+
+	s.m.Lock()
+	defer s.m.Unlock()
+	e, ok := s.entries[id]
+	if !ok {
+		return 0, nil, nfs3ErrStale
+	}
+	return id, e, nfs3OK
+}
+
+// fileHandle returns the NFS file handle for an entry ID.
+func fileHandle(id uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, id)
+}
+
+// reader returns a handle to read the file of e.
+func (e *entry) reader(ctx context.Context) (fs.HandleReader, error) {
+	e.openMu.Lock()
+	defer e.openMu.Unlock()
+
+	if e.handle == nil {
+		if opener, ok := e.node.(fs.NodeOpener); ok {
+			h, err := opener.Open(ctx, &fuse.OpenRequest{Flags: fuse.OpenReadOnly}, &fuse.OpenResponse{})
+			if err != nil {
+				return nil, err
+			}
+			e.handle = h
+		} else {
+			e.handle = e.node
+		}
+	}
+
+	rd, ok := e.handle.(fs.HandleReader)
+	if !ok {
+		debug.Log("node %T cannot be read", e.node)
+		return nil, errors.New("not a regular file")
+	}
+	return rd, nil
+}
@@ -0,0 +1,108 @@
+//go:build darwin || freebsd || linux
+
+package nfs
+
+import (
+	"encoding/binary"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+var errGarbageArgs = errors.New("invalid XDR encoding")
+
+// xdrWriter encodes values in the External Data Representation (RFC 4506).
+type xdrWriter struct {
+	buf []byte
+}
+
+func (w *xdrWriter) uint32(v uint32) {
+	w.buf = binary.BigEndian.AppendUint32(w.buf, v)
+}
+
+func (w *xdrWriter) uint64(v uint64) {
+	w.buf = binary.BigEndian.AppendUint64(w.buf, v)
+}
+
+func (w *xdrWriter) bool(v bool) {
+	if v {
+		w.uint32(1)
+	} else {
+		w.uint32(0)
+	}
+}
+
+// fixedOpaque writes b padded to a multiple of four bytes.
+func (w *xdrWriter) fixedOpaque(b []byte) {
+	w.buf = append(w.buf, b...)
+	for i := len(b); i%4 != 0; i++ {
+		w.buf = append(w.buf, 0)
+	}
+}
+
+// opaque writes the length of b followed by b.
+func (w *xdrWriter) opaque(b []byte) {
+	w.uint32(uint32(len(b)))
+	w.fixedOpaque(b)
+}
+
+func (w *xdrWriter) string(s string) {
+	w.opaque([]byte(s))
+}
+
+// xdrReader decodes values in the External Data Representation. After the
+// first error, all methods return zero values and err is set.
+type xdrReader struct {
+	buf []byte
+	err error
+}
+
+func (r *xdrReader) next(n int) []byte {
+	if r.err != nil || n < 0 || len(r.buf) < n {
+		r.err = errGarbageArgs
+		return nil
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+func (r *xdrReader) uint32() uint32 {
+	b := r.next(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+func (r *xdrReader) uint64() uint64 {
+	b := r.next(8)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(b)
+}
+
+func (r *xdrReader) bool() bool {
+	return r.uint32() != 0
+}
+
+// fixedOpaque reads n bytes and the following padding.
+func (r *xdrReader) fixedOpaque(n int) []byte {
+	b := r.next(n)
+	r.next((4 - n%4) % 4)
+	return b
+}
+
+// opaque reads variable-length data of at most max bytes.
+func (r *xdrReader) opaque(max int) []byte {
+	n := r.uint32()
+	if n > uint32(max) {
+		r.err = errGarbageArgs
+		return nil
+	}
+	return r.fixedOpaque(int(n))
+}
+
+func (r *xdrReader) string(max int) string {
+	return string(r.opaque(max))
+}
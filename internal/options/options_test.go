@@ -185,6 +185,23 @@ func TestOptionsApply(t *testing.T) {
 	}
 }
 
+func TestOptionsApplySecret(t *testing.T) {
+	var dst struct {
+		Key SecretString `option:"key"`
+	}
+	err := Options{"key": "secret"}.Apply("", &dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if dst.Key.Unwrap() != "secret" {
+		t.Fatalf("wrong value, want %q, got %q", "secret", dst.Key.Unwrap())
+	}
+	if dst.Key.String() != "**redacted**" {
+		t.Fatalf("secret is not redacted: %v", dst.Key.String())
+	}
+}
+
 var invalidSetTests = []struct {
 	input     Options
 	namespace string
@@ -0,0 +1,11 @@
+package repository
+
+import "context"
+
+// EnableAuditLog marks the audit log as enabled in the config. The caller must
+// hold an exclusive lock.
+func (r *Repository) EnableAuditLog(ctx context.Context) error {
+	cfg := r.Config()
+	cfg.AuditLog = true
+	return r.replaceConfig(ctx, cfg, "restic-config-audit-log-")
+}
@@ -68,22 +68,48 @@ func (e *ErrPackMetadata) Error() string {
 // ErrPackData is returned if errors are discovered while verifying a packfile
 type ErrPackData struct {
 	PackID restic.ID
-	errs   []error
+	// Attempts is the number of times the pack was read.
+	Attempts int
+	// Quarantined is set if the pack was moved to the quarantine directory.
+	Quarantined bool
+	// Reconstructable is set if all blobs of the pack can be reconstructed
+	// using the parity section of the pack.
+	Reconstructable bool
+	errs            []error
 }
 
 func (e *ErrPackData) Error() string {
-	return fmt.Sprintf("pack %v contains %v errors: %v", e.PackID, len(e.errs), e.errs)
+	msg := fmt.Sprintf("pack %v contains %v errors: %v", e.PackID, len(e.errs), e.errs)
+	if e.Reconstructable {
+		msg += " (can be reconstructed using parity)"
+	}
+	if e.Quarantined {
+		msg += " (moved to quarantine)"
+	}
+	return msg
 }
 
 // Checker handles index-related operations for repository checking.
 type Checker struct {
 	repo *Repository
+
+	// PackChecked is called by ReadPacks for each pack whose data was read
+	// successfully. It may be called concurrently.
+	PackChecked func(id restic.ID)
+
+	// PackRetries is the number of times ReadPacks downloads a damaged pack
+	// again to detect transient errors before reporting it as damaged.
+	PackRetries int
+	// Quarantine instructs ReadPacks to move packs which are confirmed to be
+	// damaged to the quarantine directory of the repository.
+	Quarantine bool
 }
 
 // newChecker creates a new Checker.
 func newChecker(repo *Repository) *Checker {
 	return &Checker{
-		repo: repo,
+		repo:        repo,
+		PackRetries: 1,
 	}
 }
 func computePackTypes(ctx context.Context, idx restic.ListBlobser) (map[restic.ID]restic.BlobType, error) {
@@ -234,6 +260,11 @@ func (c *Checker) Packs(ctx context.Context, errChan chan<- error) {
 		}
 	}
 
+	// packs marked for deletion by prune are still referenced by the index
+	for id := range c.repo.idx.PacksToDelete() {
+		delete(repoPacks, id)
+	}
+
 	// orphaned: present in the repo but not in c.packs
 	for orphanID := range repoPacks {
 		select {
@@ -282,9 +313,10 @@ func (c *Checker) ReadPacks(ctx context.Context, filter func(packs map[restic.ID
 
 	g, ctx := errgroup.WithContext(ctx)
 	type checkTask struct {
-		id    restic.ID
-		size  int64
-		blobs pack.Blobs
+		id     restic.ID
+		size   int64
+		blobs  pack.Blobs
+		parity *pack.Parity
 	}
 	ch := make(chan checkTask)
 
@@ -312,16 +344,36 @@ func (c *Checker) ReadPacks(ctx context.Context, filter func(packs map[restic.ID
 					}
 				}
 
-				err := checkPack(ctx, c.repo, ps.id, ps.blobs, ps.size, bufRd, dec)
+				err := checkPack(ctx, c.repo, ps.id, ps.blobs, ps.size, c.PackRetries, bufRd, dec)
 				p.Add(1)
 				if err == nil {
+					if c.PackChecked != nil {
+						c.PackChecked(ps.id)
+					}
 					continue
 				}
 
-				select {
-				case <-ctx.Done():
-					return nil
-				case errChan <- err:
+				if perr, ok := err.(*ErrPackData); ok && ps.parity != nil && ctx.Err() == nil {
+					res, perr2 := c.repo.checkParity(ctx, ps.id, ps.blobs)
+					debug.Log("reconstructing pack %v: %+v, %v", ps.id, res, perr2)
+					perr.Reconstructable = perr2 == nil
+				}
+
+				errs := []error{err}
+				if perr, ok := err.(*ErrPackData); ok && c.Quarantine && ctx.Err() == nil {
+					if qerr := c.repo.QuarantinePack(ctx, ps.id); qerr != nil {
+						errs = append(errs, fmt.Errorf("pack %v: moving to quarantine failed: %w", ps.id, qerr))
+					} else {
+						perr.Quarantined = true
+					}
+				}
+
+				for _, err := range errs {
+					select {
+					case <-ctx.Done():
+						return nil
+					case errChan <- err:
+					}
 				}
 			}
 		})
@@ -332,7 +384,7 @@ func (c *Checker) ReadPacks(ctx context.Context, filter func(packs map[restic.ID
 		size := packs[pbs.PackID]
 		debug.Log("listed %v", pbs.PackID)
 		select {
-		case ch <- checkTask{id: pbs.PackID, size: size, blobs: pbs.Blobs}:
+		case ch <- checkTask{id: pbs.PackID, size: size, blobs: pbs.Blobs, parity: pbs.Parity}:
 		case <-ctx.Done():
 		}
 	}
@@ -348,22 +400,28 @@ func (c *Checker) ReadPacks(ctx context.Context, filter func(packs map[restic.ID
 	}
 }
 
-// checkPack reads a pack and checks the integrity of all blobs.
-func checkPack(ctx context.Context, r *Repository, id restic.ID, blobs pack.Blobs, size int64, bufRd *bufio.Reader, dec *zstd.Decoder) error {
+// checkPack reads a pack and checks the integrity of all blobs. If errors are
+// found, the pack is read up to retries more times to detect transient errors.
+// An *ErrPackData is only returned if all attempts failed.
+func checkPack(ctx context.Context, r *Repository, id restic.ID, blobs pack.Blobs, size int64, retries int, bufRd *bufio.Reader, dec *zstd.Decoder) error {
 	err := checkPackInner(ctx, r, id, blobs, size, bufRd, dec)
-	if err != nil {
+	attempts := 1
+	for ; err != nil && attempts <= retries && ctx.Err() == nil; attempts++ {
 		if r.cache != nil {
 			// ignore error as there's not much we can do here
 			_ = r.cache.Forget(backend.Handle{Type: backend.PackFile, Name: id.String()})
 		}
 
-		// retry pack verification to detect transient errors
+		debug.Log("retrying check of pack %v, attempt %d: %v", id, attempts+1, err)
 		err2 := checkPackInner(ctx, r, id, blobs, size, bufRd, dec)
-		if err2 != nil {
-			err = err2
-		} else {
-			err = fmt.Errorf("check successful on second attempt, original error %w", err)
+		if err2 == nil {
+			return fmt.Errorf("check successful on attempt %d, original error %w", attempts+1, err)
 		}
+		err = err2
+	}
+
+	if perr, ok := err.(*ErrPackData); ok {
+		perr.Attempts = attempts
 	}
 	return err
 }
@@ -383,6 +441,9 @@ func checkPackInner(ctx context.Context, r *Repository, id restic.ID, blobs pack
 	// sanity check blobs in index
 	blobs.Sort()
 	idxHdrSize := pack.CalculateHeaderSize(blobs)
+	if _, ok := r.idx.Parity(id); ok {
+		idxHdrSize += pack.ParityEntrySize
+	}
 	lastBlobEnd := 0
 	nonContinuousPack := false
 	for _, blob := range blobs {
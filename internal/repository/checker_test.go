@@ -30,7 +30,7 @@ func testWrapCheckPack(ctx context.Context, t *testing.T, repo *Repository,
 	dec, err := zstd.NewReader(nil)
 	rtest.OK(t, err)
 
-	return checkPack(ctx, repo, packID, blobs, size, bufRd, dec)
+	return checkPack(ctx, repo, packID, blobs, size, 1, bufRd, dec)
 }
 
 // TestGapInBlobs creates a gap in the blob list by omitting the first entry before passing it to checkPack
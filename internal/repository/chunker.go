@@ -6,12 +6,22 @@ import (
 )
 
 type baseChunker struct {
-	bc  *chunker.BaseChunker
-	pol chunker.Pol
+	bc     *chunker.BaseChunker
+	pol    chunker.Pol
+	params restic.ChunkerParams
+}
+
+func newBaseChunker(pol chunker.Pol, params restic.ChunkerParams) *baseChunker {
+	bc := chunker.NewBase(pol,
+		chunker.WithBaseBoundaries(params.MinSize, params.MaxSize),
+		chunker.WithBaseAverageBits(int(params.AverageBits)))
+	return &baseChunker{bc: bc, pol: pol, params: params}
 }
 
 func (c *baseChunker) Reset() {
-	c.bc.Reset(c.pol)
+	c.bc.Reset(c.pol,
+		chunker.WithBaseBoundaries(c.params.MinSize, c.params.MaxSize),
+		chunker.WithBaseAverageBits(int(c.params.AverageBits)))
 }
 
 func (c *baseChunker) NextSplitPoint(buf []byte) int {
@@ -20,22 +30,24 @@ func (c *baseChunker) NextSplitPoint(buf []byte) int {
 
 type chunkerFactory struct {
 	pol       chunker.Pol
+	params    restic.ChunkerParams
 	zeroChunk func() restic.ID
 }
 
 func newChunkerFactory(r *Repository) *chunkerFactory {
 	return &chunkerFactory{
 		pol:       r.Config().ChunkerPolynomial,
+		params:    r.Config().ChunkerParameters(),
 		zeroChunk: r.zeroChunk,
 	}
 }
 
 func (f *chunkerFactory) NewChunker() restic.Chunker {
-	return &baseChunker{bc: chunker.NewBase(f.pol), pol: f.pol}
+	return newBaseChunker(f.pol, f.params)
 }
 
 func (f *chunkerFactory) MaxChunkSize() int {
-	return chunker.MaxSize
+	return int(f.params.MaxSize)
 }
 
 func (f *chunkerFactory) ZeroChunk() restic.ID {
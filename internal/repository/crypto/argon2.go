@@ -0,0 +1,57 @@
+package crypto
+
+import (
+	"github.com/restic/restic/internal/errors"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Argon2Params are the parameters of the Argon2id key derivation function.
+type Argon2Params struct {
+	// Time is the number of passes over the memory.
+	Time uint32
+	// Memory is the amount of memory used in KiB.
+	Memory uint32
+	// Threads is the degree of parallelism.
+	Threads uint8
+}
+
+// DefaultArgon2Params are the parameters recommended by RFC 9106 for
+// environments with limited memory.
+var DefaultArgon2Params = Argon2Params{
+	Time:    3,
+	Memory:  64 * 1024,
+	Threads: 4,
+}
+
+// Check returns an error if the parameters are invalid.
+func (p Argon2Params) Check() error {
+	if p.Time < 1 {
+		return errors.Errorf("invalid argon2id time parameter %d", p.Time)
+	}
+	if p.Threads < 1 {
+		return errors.Errorf("invalid argon2id parallelism %d", p.Threads)
+	}
+	// argon2 requires at least 8 KiB of memory per thread
+	if p.Memory < 8*uint32(p.Threads) {
+		return errors.Errorf("invalid argon2id memory parameter %d KiB", p.Memory)
+	}
+	return nil
+}
+
+// Argon2idKDF derives encryption and message authentication keys from the
+// password using Argon2id with the supplied parameters and the salt.
+func Argon2idKDF(p Argon2Params, salt []byte, password string) (*Key, error) {
+	if len(salt) != saltLength {
+		return nil, errors.Errorf("argon2id() called with invalid salt bytes (len %d)", len(salt))
+	}
+	if err := p.Check(); err != nil {
+		return nil, err
+	}
+
+	buf := argon2.IDKey([]byte(password), salt, p.Time, p.Memory, p.Threads, macKeySize+aesKeySize)
+
+	derKeys := &Key{}
+	keyFromKDFOutput(derKeys, buf)
+	return derKeys, nil
+}
@@ -0,0 +1,131 @@
+package crypto
+
+import (
+	"strings"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+// bech32 as specified in BIP 173, without the length limit of 90 characters.
+// It is used to encode X25519 recipients and identities in the same way as age.
+
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+var bech32Generator = [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+
+func bech32Polymod(values []byte) uint32 {
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i, g := range bech32Generator {
+			if (top>>uint(i))&1 == 1 {
+				chk ^= g
+			}
+		}
+	}
+	return chk
+}
+
+func bech32ExpandHRP(hrp string) []byte {
+	out := make([]byte, 0, 2*len(hrp)+1)
+	for i := 0; i < len(hrp); i++ {
+		out = append(out, hrp[i]>>5)
+	}
+	out = append(out, 0)
+	for i := 0; i < len(hrp); i++ {
+		out = append(out, hrp[i]&31)
+	}
+	return out
+}
+
+// convertBits regroups data from groups of fromBits to groups of toBits.
+func convertBits(data []byte, fromBits, toBits uint, pad bool) ([]byte, error) {
+	var acc uint32
+	var bits uint
+	maxv := uint32(1)<<toBits - 1
+	out := make([]byte, 0, len(data)*int(fromBits)/int(toBits)+1)
+
+	for _, b := range data {
+		if uint32(b)>>fromBits != 0 {
+			return nil, errors.New("invalid data range")
+		}
+		acc = acc<<fromBits | uint32(b)
+		bits += fromBits
+		for bits >= toBits {
+			bits -= toBits
+			out = append(out, byte(acc>>bits&maxv))
+		}
+	}
+
+	if pad {
+		if bits > 0 {
+			out = append(out, byte(acc<<(toBits-bits)&maxv))
+		}
+	} else if bits >= fromBits || acc<<(toBits-bits)&maxv != 0 {
+		return nil, errors.New("invalid padding")
+	}
+	return out, nil
+}
+
+// bech32Encode encodes data with the lower case human readable part hrp.
+func bech32Encode(hrp string, data []byte) (string, error) {
+	values, err := convertBits(data, 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+
+	checksumInput := append(bech32ExpandHRP(hrp), values...)
+	checksumInput = append(checksumInput, 0, 0, 0, 0, 0, 0)
+	polymod := bech32Polymod(checksumInput) ^ 1
+
+	var sb strings.Builder
+	sb.WriteString(hrp)
+	sb.WriteByte('1')
+	for _, v := range values {
+		sb.WriteByte(bech32Charset[v])
+	}
+	for i := 0; i < 6; i++ {
+		sb.WriteByte(bech32Charset[(polymod>>uint(5*(5-i)))&31])
+	}
+	return sb.String(), nil
+}
+
+// bech32Decode returns the lower case human readable part and the data of s.
+func bech32Decode(s string) (string, []byte, error) {
+	if strings.ToLower(s) != s && strings.ToUpper(s) != s {
+		return "", nil, errors.New("mixed case")
+	}
+	s = strings.ToLower(s)
+
+	pos := strings.LastIndexByte(s, '1')
+	if pos < 1 || pos+7 > len(s) {
+		return "", nil, errors.New("invalid separator position")
+	}
+
+	hrp := s[:pos]
+	for i := 0; i < len(hrp); i++ {
+		if hrp[i] < 33 || hrp[i] > 126 {
+			return "", nil, errors.New("invalid character in human readable part")
+		}
+	}
+
+	values := make([]byte, 0, len(s)-pos-1)
+	for i := pos + 1; i < len(s); i++ {
+		v := strings.IndexByte(bech32Charset, s[i])
+		if v == -1 {
+			return "", nil, errors.New("invalid character in data part")
+		}
+		values = append(values, byte(v))
+	}
+
+	if bech32Polymod(append(bech32ExpandHRP(hrp), values...)) != 1 {
+		return "", nil, errors.New("invalid checksum")
+	}
+
+	data, err := convertBits(values[:len(values)-6], 5, 8, false)
+	if err != nil {
+		return "", nil, err
+	}
+	return hrp, data, nil
+}
@@ -36,6 +36,23 @@ var (
 type Key struct {
 	MACKey        `json:"mac"`
 	EncryptionKey `json:"encrypt"`
+
+	// previous is tried by Open if the ciphertext cannot be authenticated
+	// using this key.
+	previous *Key
+}
+
+// WithPrevious returns a copy of k which can also decrypt ciphertexts created
+// using the key previous, for example during a rotation of the master key.
+// Seal always uses k.
+func (k *Key) WithPrevious(previous *Key) *Key {
+	return &Key{MACKey: k.MACKey, EncryptionKey: k.EncryptionKey, previous: previous}
+}
+
+// WithoutPrevious returns a copy of k which only decrypts ciphertexts
+// created using k itself.
+func (k *Key) WithoutPrevious() *Key {
+	return &Key{MACKey: k.MACKey, EncryptionKey: k.EncryptionKey}
 }
 
 // EncryptionKey is key used for encryption
@@ -307,6 +324,10 @@ func (k *Key) Open(dst, nonce, ciphertext, _ []byte) ([]byte, error) {
 
 	// verify mac
 	if !poly1305Verify(ct, nonce, &k.MACKey, mac) {
+		if k.previous != nil {
+			// dst was not modified yet
+			return k.previous.Open(dst, nonce, ciphertext, nil)
+		}
 		return nil, ErrUnauthenticated
 	}
 
@@ -43,6 +43,36 @@ func TestEncryptDecrypt(t *testing.T) {
 	}
 }
 
+func TestPreviousKey(t *testing.T) {
+	previous := crypto.NewRandomKey()
+	current := crypto.NewRandomKey()
+	k := current.WithPrevious(previous)
+
+	data := rtest.Random(23, 1000)
+	for _, key := range []*crypto.Key{previous, k} {
+		nonce := crypto.NewRandomNonce()
+		ciphertext := key.Seal(nil, nonce, data, nil)
+
+		plaintext, err := k.Open(nil, nonce, ciphertext, nil)
+		rtest.OK(t, err)
+		rtest.Equals(t, data, plaintext)
+	}
+
+	// data sealed by k must not depend on the previous key
+	nonce := crypto.NewRandomNonce()
+	ciphertext := k.Seal(nil, nonce, data, nil)
+	_, err := previous.Open(nil, nonce, ciphertext, nil)
+	rtest.Assert(t, err == crypto.ErrUnauthenticated, "expected ErrUnauthenticated, got %v", err)
+	plaintext, err := current.Open(nil, nonce, ciphertext, nil)
+	rtest.OK(t, err)
+	rtest.Equals(t, data, plaintext)
+
+	// without the previous key old ciphertexts are rejected
+	ciphertext = previous.Seal(nil, nonce, data, nil)
+	_, err = k.WithoutPrevious().Open(nil, nonce, ciphertext, nil)
+	rtest.Assert(t, err == crypto.ErrUnauthenticated, "expected ErrUnauthenticated, got %v", err)
+}
+
 func TestSmallBuffer(t *testing.T) {
 	k := crypto.NewRandomKey()
 
@@ -80,13 +80,18 @@ func KDF(p Params, salt []byte, password string) (*Key, error) {
 		return nil, errors.Errorf("invalid numbers of bytes expanded from scrypt(): %d", len(scryptKeys))
 	}
 
-	// first 32 byte of scrypt output is the encryption key
-	copy(derKeys.EncryptionKey[:], scryptKeys[:aesKeySize])
+	keyFromKDFOutput(derKeys, scryptKeys)
+	return derKeys, nil
+}
 
-	// next 32 byte of scrypt output is the mac key, in the form k||r
-	macKeyFromSlice(&derKeys.MACKey, scryptKeys[aesKeySize:])
+// keyFromKDFOutput fills k with the output of a KDF, which must be
+// macKeySize+aesKeySize bytes long.
+func keyFromKDFOutput(k *Key, buf []byte) {
+	// first 32 byte of the output is the encryption key
+	copy(k.EncryptionKey[:], buf[:aesKeySize])
 
-	return derKeys, nil
+	// next 32 byte of the output is the mac key, in the form k||r
+	macKeyFromSlice(&k.MACKey, buf[aesKeySize:])
 }
 
 // NewSalt returns new random salt bytes to use with KDF(). If NewSalt returns
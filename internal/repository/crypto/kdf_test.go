@@ -12,3 +12,48 @@ func TestCalibrate(t *testing.T) {
 	}
 	t.Logf("testing calibrate, params after: %v", params)
 }
+
+func TestArgon2idKDF(t *testing.T) {
+	salt, err := NewSalt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := Argon2Params{Time: 1, Memory: 64, Threads: 1}
+
+	k1, err := Argon2idKDF(params, salt, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !k1.Valid() {
+		t.Fatal("derived key is invalid")
+	}
+
+	k2, err := Argon2idKDF(params, salt, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k1.EncryptionKey != k2.EncryptionKey || k1.MACKey != k2.MACKey {
+		t.Fatal("same password resulted in different keys")
+	}
+
+	k3, err := Argon2idKDF(params, salt, "other password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k1.EncryptionKey == k3.EncryptionKey {
+		t.Fatal("different passwords resulted in the same key")
+	}
+
+	for _, p := range []Argon2Params{
+		{Time: 0, Memory: 64, Threads: 1},
+		{Time: 1, Memory: 64, Threads: 0},
+		{Time: 1, Memory: 4, Threads: 1},
+	} {
+		if _, err := Argon2idKDF(p, salt, "password"); err == nil {
+			t.Errorf("missing error for invalid parameters %v", p)
+		}
+	}
+	if _, err := Argon2idKDF(params, salt[:10], "password"); err == nil {
+		t.Error("missing error for short salt")
+	}
+}
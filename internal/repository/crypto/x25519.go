@@ -0,0 +1,150 @@
+package crypto
+
+import (
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"strings"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+// A key can also be encrypted for an X25519 recipient instead of a password.
+// The user key is then derived from the shared secret between an ephemeral key
+// and the recipient. Recipients and identities use the same encoding as age
+// (https://age-encryption.org), thus they can be generated using age-keygen.
+
+const (
+	recipientHRP = "age"
+	identityHRP  = "age-secret-key-"
+
+	x25519Info = "restic x25519 key"
+)
+
+// Recipient is an X25519 public key for which keys can be encrypted.
+type Recipient struct {
+	key *ecdh.PublicKey
+}
+
+// Identity is an X25519 private key which can decrypt keys encrypted for its
+// recipient.
+type Identity struct {
+	key *ecdh.PrivateKey
+}
+
+// NewIdentity returns a new random identity.
+func NewIdentity() (*Identity, error) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "GenerateKey")
+	}
+	return &Identity{key: key}, nil
+}
+
+// ParseRecipient parses a recipient in the format "age1...".
+func ParseRecipient(s string) (*Recipient, error) {
+	hrp, data, err := bech32Decode(s)
+	if err != nil {
+		return nil, errors.Errorf("malformed recipient %q: %v", s, err)
+	}
+	if hrp != recipientHRP {
+		return nil, errors.Errorf("malformed recipient %q: invalid type %q", s, hrp)
+	}
+	key, err := ecdh.X25519().NewPublicKey(data)
+	if err != nil {
+		return nil, errors.Errorf("malformed recipient %q: %v", s, err)
+	}
+	return &Recipient{key: key}, nil
+}
+
+// ParseIdentity parses an identity in the format "AGE-SECRET-KEY-1...".
+func ParseIdentity(s string) (*Identity, error) {
+	hrp, data, err := bech32Decode(s)
+	if err != nil {
+		return nil, errors.Errorf("malformed identity: %v", err)
+	}
+	if hrp != identityHRP {
+		return nil, errors.Errorf("malformed identity: invalid type %q", hrp)
+	}
+	key, err := ecdh.X25519().NewPrivateKey(data)
+	if err != nil {
+		return nil, errors.Errorf("malformed identity: %v", err)
+	}
+	return &Identity{key: key}, nil
+}
+
+// String returns the encoded recipient.
+func (r *Recipient) String() string {
+	s, err := bech32Encode(recipientHRP, r.key.Bytes())
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// String returns the encoded identity.
+func (id *Identity) String() string {
+	s, err := bech32Encode(identityHRP, id.key.Bytes())
+	if err != nil {
+		panic(err)
+	}
+	return strings.ToUpper(s)
+}
+
+// Recipient returns the recipient of the identity.
+func (id *Identity) Recipient() *Recipient {
+	return &Recipient{key: id.key.PublicKey()}
+}
+
+// NewUserKey returns a new user key which can only be derived again by the
+// identity of the recipient. The ephemeral public key must be stored along
+// with the data encrypted by the user key.
+func (r *Recipient) NewUserKey() (userKey *Key, ephemeral []byte, err error) {
+	ephemeralKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "GenerateKey")
+	}
+	shared, err := ephemeralKey.ECDH(r.key)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "ECDH")
+	}
+
+	ephemeral = ephemeralKey.PublicKey().Bytes()
+	userKey, err = deriveUserKey(shared, ephemeral, r.key.Bytes())
+	if err != nil {
+		return nil, nil, err
+	}
+	return userKey, ephemeral, nil
+}
+
+// UserKey derives the user key created by Recipient.NewUserKey for the
+// recipient of the identity.
+func (id *Identity) UserKey(ephemeral []byte) (*Key, error) {
+	ephemeralKey, err := ecdh.X25519().NewPublicKey(ephemeral)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid ephemeral key")
+	}
+	shared, err := id.key.ECDH(ephemeralKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "ECDH")
+	}
+	return deriveUserKey(shared, ephemeral, id.key.PublicKey().Bytes())
+}
+
+func deriveUserKey(shared, ephemeral, recipient []byte) (*Key, error) {
+	salt := make([]byte, 0, len(ephemeral)+len(recipient))
+	salt = append(salt, ephemeral...)
+	salt = append(salt, recipient...)
+
+	keys, err := hkdf.Key(sha256.New, shared, salt, x25519Info, aesKeySize+macKeySize)
+	if err != nil {
+		return nil, errors.Wrap(err, "hkdf.Key")
+	}
+
+	k := &Key{}
+	// like for KDF(), the encryption key is followed by the mac key k||r
+	copy(k.EncryptionKey[:], keys[:aesKeySize])
+	macKeyFromSlice(&k.MACKey, keys[aesKeySize:])
+	return k, nil
+}
@@ -0,0 +1,74 @@
+package crypto_test
+
+import (
+	"testing"
+
+	"github.com/restic/restic/internal/repository/crypto"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+// generated using age-keygen
+const (
+	testIdentity  = "AGE-SECRET-KEY-1ML6CLAPGZJJZGRVN92CUL52HE3MCSZXQ7GUVX2LH7G09TYPPVNAQSQFYAF"
+	testRecipient = "age1xqjef7656quzyzw6sk2m6tcgzj7wkz5ge0uhngjt5t3a98al5fvqk9zat8"
+)
+
+func TestParseIdentity(t *testing.T) {
+	id, err := crypto.ParseIdentity(testIdentity)
+	rtest.OK(t, err)
+	rtest.Equals(t, testIdentity, id.String())
+	rtest.Equals(t, testRecipient, id.Recipient().String())
+
+	r, err := crypto.ParseRecipient(testRecipient)
+	rtest.OK(t, err)
+	rtest.Equals(t, testRecipient, r.String())
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"age1",
+		// wrong checksum
+		testRecipient[:len(testRecipient)-1] + "9",
+		// mixed case
+		"Age" + testRecipient[3:],
+		// identity used as recipient
+		testIdentity,
+	} {
+		_, err := crypto.ParseRecipient(s)
+		rtest.Assert(t, err != nil, "expected error for recipient %q", s)
+	}
+
+	_, err := crypto.ParseIdentity(testRecipient)
+	rtest.Assert(t, err != nil, "expected error for identity %q", testRecipient)
+}
+
+func TestRecipientUserKey(t *testing.T) {
+	id, err := crypto.NewIdentity()
+	rtest.OK(t, err)
+
+	parsed, err := crypto.ParseIdentity(id.String())
+	rtest.OK(t, err)
+	rtest.Equals(t, id.Recipient().String(), parsed.Recipient().String())
+
+	userKey, ephemeral, err := id.Recipient().NewUserKey()
+	rtest.OK(t, err)
+	rtest.Assert(t, userKey.Valid(), "user key is invalid")
+
+	nonce := crypto.NewRandomNonce()
+	ciphertext := userKey.Seal(nil, nonce, []byte("master key"), nil)
+
+	derived, err := parsed.UserKey(ephemeral)
+	rtest.OK(t, err)
+	plaintext, err := derived.Open(nil, nonce, ciphertext, nil)
+	rtest.OK(t, err)
+	rtest.Equals(t, "master key", string(plaintext))
+
+	// a different identity must not be able to derive the key
+	other, err := crypto.NewIdentity()
+	rtest.OK(t, err)
+	derived, err = other.UserKey(ephemeral)
+	rtest.OK(t, err)
+	_, err = derived.Open(nil, nonce, ciphertext, nil)
+	rtest.Assert(t, err == crypto.ErrUnauthenticated, "expected ErrUnauthenticated, got %v", err)
+}
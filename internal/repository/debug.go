@@ -120,7 +120,7 @@ func ExaminePack(ctx context.Context, repo *Repository, id restic.ID, opts Exami
 			continue
 		}
 
-		checkPackSize(b.Blobs, len(buf), printer)
+		checkPackSize(b.Blobs, b.Parity, len(buf), printer)
 
 		err := loadBlobs(ctx, opts, repo, id, b.Blobs, printer)
 		if err != nil {
@@ -133,11 +133,11 @@ func ExaminePack(ctx context.Context, repo *Repository, id restic.ID, opts Exami
 	printer.S("  ========================================")
 	printer.S("  inspect the pack itself")
 
-	blobs, err := repo.listPack(ctx, id, int64(len(buf)))
+	blobs, parity, err := repo.listPackWithParity(ctx, id, int64(len(buf)))
 	if err != nil {
 		return fmt.Errorf("pack %v: %v", id.Str(), err)
 	}
-	checkPackSize(blobs, len(buf), printer)
+	checkPackSize(blobs, parity, len(buf), printer)
 
 	if !blobsLoaded {
 		return loadBlobs(ctx, opts, repo, id, blobs, printer)
@@ -145,7 +145,7 @@ func ExaminePack(ctx context.Context, repo *Repository, id restic.ID, opts Exami
 	return nil
 }
 
-func checkPackSize(blobs pack.Blobs, fileSize int, printer restic.Printer) {
+func checkPackSize(blobs pack.Blobs, parity *pack.Parity, fileSize int, printer restic.Printer) {
 	// track current size and offset
 	var size, offset uint64
 
@@ -160,6 +160,10 @@ func checkPackSize(blobs pack.Blobs, fileSize int, printer restic.Printer) {
 		size += uint64(pb.Length)
 	}
 	size += uint64(pack.CalculateHeaderSize(blobs))
+	if parity != nil {
+		printer.S("      parity section %v, chunk size %d, length %d", parity, parity.ChunkSize, parity.SectionLength(uint(offset)))
+		size += uint64(pack.ParityEntrySize + parity.SectionLength(uint(offset)))
+	}
 
 	if uint64(fileSize) != size {
 		printer.S("      file sizes do not match: computed %v, file size is %v", size, fileSize)
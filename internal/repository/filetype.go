@@ -14,4 +14,7 @@ var (
 	_ = [1]struct{}{}[backend.SnapshotFile-backend.FileType(restic.SnapshotFile)]
 	_ = [1]struct{}{}[backend.IndexFile-backend.FileType(restic.IndexFile)]
 	_ = [1]struct{}{}[backend.ConfigFile-backend.FileType(restic.ConfigFile)]
+	_ = [1]struct{}{}[backend.AuditLogFile-backend.FileType(restic.AuditLogFile)]
+	_ = [1]struct{}{}[backend.GenerationFile-backend.FileType(restic.GenerationFile)]
+	_ = [1]struct{}{}[backend.QuarantineFile-backend.FileType(restic.QuarantineFile)]
 )
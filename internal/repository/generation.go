@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+)
+
+// generationMarker is stored by every exclusive operation before it modifies
+// the repository. Operations which do not create a lock file detect concurrent
+// exclusive operations by a change of the set of generation markers, see
+// LockRepoOptimistic.
+type generationMarker struct {
+	Time     time.Time `json:"time"`
+	Hostname string    `json:"hostname"`
+	PID      int       `json:"pid"`
+}
+
+// ErrGenerationChanged is returned if an exclusive operation ran while the
+// repository was used without a lock file.
+var ErrGenerationChanged = errors.New("the repository was modified by an exclusive operation in the meantime")
+
+// bumpGeneration replaces the generation markers of the repository with a
+// new one. Old markers which cannot be removed are ignored, the set of markers
+// has changed nevertheless.
+func bumpGeneration(ctx context.Context, repo restic.Unpacked[restic.FileType]) error {
+	old, err := loadGeneration(ctx, repo)
+	if err != nil {
+		return err
+	}
+
+	marker := generationMarker{
+		Time: time.Now(),
+		PID:  os.Getpid(),
+	}
+	if hn, err := os.Hostname(); err == nil {
+		marker.Hostname = hn
+	}
+
+	id, err := restic.SaveJSONUnpacked(ctx, repo, restic.GenerationFile, marker)
+	if err != nil {
+		return fmt.Errorf("unable to store generation marker: %w", err)
+	}
+	debug.Log("new generation %v", id.Str())
+
+	for oldID := range old {
+		if err := repo.RemoveUnpacked(ctx, restic.GenerationFile, oldID); err != nil {
+			debug.Log("unable to remove generation marker %v: %v", oldID.Str(), err)
+		}
+	}
+	return nil
+}
+
+// loadGeneration returns the IDs of the generation markers in the repository.
+func loadGeneration(ctx context.Context, repo restic.Lister) (restic.IDSet, error) {
+	ids := restic.NewIDSet()
+	err := repo.List(ctx, restic.GenerationFile, func(id restic.ID, _ int64) error {
+		ids.Insert(id)
+		return nil
+	})
+	return ids, err
+}
+
+// checkGeneration returns ErrGenerationChanged if an exclusive operation
+// started since gen was loaded or is still holding its lock.
+func checkGeneration(ctx context.Context, repo restic.ListerLoaderUnpacked, gen restic.IDSet) error {
+	current, err := loadGeneration(ctx, repo)
+	if err != nil {
+		return err
+	}
+	if !current.Equals(gen) {
+		return ErrGenerationChanged
+	}
+	// an exclusive operation may not have stored its generation marker yet
+	return checkExclusiveLocks(ctx, repo)
+}
+
+// checkExclusiveLocks returns an error which satisfies IsAlreadyLocked if the
+// repository is locked exclusively.
+func checkExclusiveLocks(ctx context.Context, repo restic.ListerLoaderUnpacked) error {
+	return forAllLocks(ctx, repo, nil, func(_ restic.ID, lock *lockHandle, err error) error {
+		if err != nil {
+			// unreadable locks are handled once a lock file is created
+			return nil
+		}
+		if lock.Exclusive {
+			return &alreadyLockedError{otherLock: lock}
+		}
+		return nil
+	})
+}
+
+// LockRepoOptimistic is an alternative to a non-exclusive LockRepo which does
+// not create a lock file and therefore also works with backends that forbid
+// deleting files. Instead, the generation markers of the repository are
+// checked regularly. If an exclusive operation has modified the repository in
+// the meantime, the returned context is cancelled. The final check is made by
+// Repository.CheckGeneration.
+func LockRepoOptimistic(ctx context.Context, repo *Repository, logger func(format string, args ...interface{})) (func(), context.Context, error) {
+	return lockerInst.LockOptimistic(ctx, repo, logger)
+}
+
+func (l *locker) LockOptimistic(ctx context.Context, r *Repository, logger func(format string, args ...interface{})) (func(), context.Context, error) {
+	if err := checkExclusiveLocks(ctx, r); err != nil {
+		return nil, ctx, err
+	}
+	gen, err := loadGeneration(ctx, r)
+	if err != nil {
+		return nil, ctx, fmt.Errorf("unable to load generation markers: %w", err)
+	}
+	// an exclusive lock may have been created while the markers were listed
+	if err := checkExclusiveLocks(ctx, r); err != nil {
+		return nil, ctx, err
+	}
+	debug.Log("using generation %v", gen)
+	r.generation = gen
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(l.refreshInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				err := checkGeneration(ctx, r, gen)
+				if ctx.Err() != nil {
+					return
+				}
+				if err != nil {
+					logger("Fatal: %v\n", err)
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}, ctx, nil
+}
+
+// CheckGeneration returns an error if the repository was opened using
+// LockRepoOptimistic and an exclusive operation has modified the repository
+// in the meantime. Otherwise, it returns nil.
+func (r *Repository) CheckGeneration(ctx context.Context) error {
+	if r.generation == nil {
+		return nil
+	}
+	return checkGeneration(ctx, r, r.generation)
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func countLocks(t *testing.T, repo *Repository) int {
+	count := 0
+	rtest.OK(t, repo.List(context.TODO(), restic.LockFile, func(_ restic.ID, _ int64) error {
+		count++
+		return nil
+	}))
+	return count
+}
+
+func TestLockOptimistic(t *testing.T) {
+	t.Parallel()
+	repo, be := openLockTestRepo(t, nil)
+	repo2 := TestOpenBackend(t, be)
+
+	unlock, _, err := LockRepoOptimistic(context.TODO(), repo, t.Logf)
+	rtest.OK(t, err)
+	defer unlock()
+	rtest.Equals(t, 0, countLocks(t, repo))
+	rtest.OK(t, repo.CheckGeneration(context.TODO()))
+
+	// non-exclusive locks do not change the generation
+	unlock2, _, err := LockRepo(context.TODO(), repo2, false, 0, func(string) {}, t.Logf)
+	rtest.OK(t, err)
+	rtest.OK(t, repo.CheckGeneration(context.TODO()))
+	unlock2()
+
+	unlock2, _, err = LockRepo(context.TODO(), repo2, true, 0, func(string) {}, t.Logf)
+	rtest.OK(t, err)
+	err = repo.CheckGeneration(context.TODO())
+	rtest.Assert(t, err == ErrGenerationChanged, "expected generation change, got %v", err)
+
+	// exclusive locks prevent using the repository without a lock file
+	_, _, err = LockRepoOptimistic(context.TODO(), TestOpenBackend(t, be), t.Logf)
+	rtest.Assert(t, IsAlreadyLocked(err), "expected already locked error, got %v", err)
+	unlock2()
+
+	// only the latest generation marker is kept
+	gen, err := loadGeneration(context.TODO(), repo)
+	rtest.OK(t, err)
+	rtest.Equals(t, 1, len(gen))
+}
+
+func TestLockOptimisticCancel(t *testing.T) {
+	t.Parallel()
+	repo, be := openLockTestRepo(t, nil)
+
+	l := &locker{refreshInterval: 20 * time.Millisecond}
+	unlock, ctx, err := l.LockOptimistic(context.TODO(), repo, t.Logf)
+	rtest.OK(t, err)
+	defer unlock()
+
+	rtest.OK(t, bumpGeneration(context.TODO(), &internalRepository{TestOpenBackend(t, be)}))
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("context was not cancelled after the generation changed")
+	}
+}
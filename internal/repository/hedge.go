@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/debug"
+)
+
+// readAt reads len(buf) bytes starting at offset from the file h. If
+// Options.HedgeReadAfter is set and the read does not complete within that
+// duration, a second request is issued and the data of the request which
+// completes first is used. The returned buffer is either buf or a newly
+// allocated one.
+func (r *Repository) readAt(ctx context.Context, h backend.Handle, offset int64, buf []byte) ([]byte, error) {
+	if r.opts.HedgeReadAfter <= 0 {
+		_, err := backend.ReadAt(ctx, r.be, h, offset, buf)
+		return buf, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		buf    []byte
+		err    error
+		hedged bool
+	}
+	// buffered such that no request blocks if its result is not needed
+	results := make(chan result, 2)
+	read := func(buf []byte, hedged bool) {
+		_, err := backend.ReadAt(ctx, r.be, h, offset, buf)
+		results <- result{buf: buf, err: err, hedged: hedged}
+	}
+
+	go read(buf, false)
+	running := 1
+
+	timer := time.NewTimer(r.opts.HedgeReadAfter)
+	defer timer.Stop()
+
+	var firstErr error
+	for {
+		select {
+		case <-timer.C:
+			debug.Log("issuing hedged request for %v at offset %d", h, offset)
+			running++
+			// the first request may still write to buf, thus use a separate buffer
+			go read(make([]byte, len(buf)), true)
+
+		case res := <-results:
+			running--
+			if res.err == nil {
+				if res.hedged && running > 0 {
+					// wait until the first request no longer writes to buf
+					cancel()
+					<-results
+				}
+				return res.buf, nil
+			}
+
+			if firstErr == nil {
+				firstErr = res.err
+			}
+			// a failed request is retried by the caller, unless the hedged
+			// request is still running
+			if running == 0 {
+				return buf, firstErr
+			}
+		}
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"io"
 	"iter"
 	"math"
+	"runtime"
 	"slices"
 	"sync"
 	"time"
@@ -56,6 +57,13 @@ type Index struct {
 	m      sync.RWMutex
 	byType [restic.NumBlobTypes]indexMap
 	packs  restic.IDs
+	// parity contains the parity section of all packs which have one
+	parity map[restic.ID]pack.Parity
+	// toDelete contains the packs which were marked for deletion by prune.
+	// Their blobs are not returned by Lookup.
+	toDelete map[restic.ID]PackBlobs
+	// toDeleteBlobs maps the blobs of the packs in toDelete to their location
+	toDeleteBlobs map[restic.BlobHandle][]pack.PackedBlob
 
 	final   bool       // set to true for all indexes read from the backend ("finalized")
 	ids     restic.IDs // set to the IDs of the contained finalized indexes
@@ -69,6 +77,19 @@ func NewIndex() *Index {
 	}
 }
 
+// newMappedIndex returns a new index which stores its entries in
+// memory-mapped temporary files in dir.
+func newMappedIndex(dir string) *Index {
+	idx := NewIndex()
+	alloc := newMappedAllocator(dir)
+	for typ := range idx.byType {
+		idx.byType[typ].alloc = alloc
+	}
+	// the mappings are not managed by the garbage collector
+	runtime.AddCleanup(idx, func(alloc *mappedAllocator) { alloc.freeAll() }, alloc)
+	return idx
+}
+
 // addToPacks saves the given pack ID and return the index.
 // This procedere allows to use pack IDs which can be easily garbage collected after.
 func (idx *Index) addToPacks(id restic.ID) uint32 {
@@ -155,6 +176,12 @@ func (idx *Index) Preallocate(t restic.BlobType, numEntries int) {
 // StorePack remembers the ids of all blobs of a given pack
 // in the index
 func (idx *Index) StorePack(id restic.ID, blobs pack.Blobs) {
+	idx.StorePackWithParity(id, blobs, nil)
+}
+
+// StorePackWithParity works like StorePack, but additionally remembers the
+// parity section of the pack. parity may be nil.
+func (idx *Index) StorePackWithParity(id restic.ID, blobs pack.Blobs, parity *pack.Parity) {
 	idx.m.Lock()
 	defer idx.m.Unlock()
 
@@ -168,6 +195,101 @@ func (idx *Index) StorePack(id restic.ID, blobs pack.Blobs) {
 	for _, blob := range blobs {
 		idx.store(packIndex, blob)
 	}
+	if parity != nil {
+		idx.storeParity(id, *parity)
+	}
+}
+
+// StorePackToDelete remembers a pack which was marked for deletion at
+// pbs.DeleteTime. Its blobs are only returned by lookups of the MasterIndex if
+// no regular pack contains them. The pack is kept in the index until it is
+// actually deleted.
+func (idx *Index) StorePackToDelete(pbs PackBlobs) {
+	idx.m.Lock()
+	defer idx.m.Unlock()
+
+	if idx.final {
+		panic("store new item in finalized index")
+	}
+	idx.storePackToDelete(pbs)
+}
+
+func (idx *Index) storePackToDelete(pbs PackBlobs) {
+	if idx.toDelete == nil {
+		idx.toDelete = make(map[restic.ID]PackBlobs)
+		idx.toDeleteBlobs = make(map[restic.BlobHandle][]pack.PackedBlob)
+	}
+	if _, ok := idx.toDelete[pbs.PackID]; !ok {
+		for _, blob := range pbs.Blobs {
+			idx.toDeleteBlobs[blob.BlobHandle] = append(idx.toDeleteBlobs[blob.BlobHandle], pack.PackedBlob{Pack: pbs.PackID, Blob: blob})
+		}
+	}
+	idx.toDelete[pbs.PackID] = pbs
+}
+
+// lookupToDelete works like Lookup, but only returns the blobs contained in
+// packs which were marked for deletion.
+func (idx *Index) lookupToDelete(bh restic.BlobHandle, pbs []*pack.PackedBlob) []*pack.PackedBlob {
+	idx.m.RLock()
+	defer idx.m.RUnlock()
+
+	for _, pb := range idx.toDeleteBlobs[bh] {
+		pbs = append(pbs, &pb)
+	}
+	return pbs
+}
+
+// EachPackToDelete calls fn for each pack marked for deletion, except for
+// those in packBlacklist. This blocks any modification of the index.
+func (idx *Index) EachPackToDelete(packBlacklist restic.IDSet, fn func(pbs PackBlobs)) {
+	idx.m.RLock()
+	defer idx.m.RUnlock()
+
+	for id, pbs := range idx.toDelete {
+		if !packBlacklist.Has(id) {
+			fn(pbs)
+		}
+	}
+}
+
+// hasPackToDelete returns whether any pack in packs is marked for deletion.
+func (idx *Index) hasPackToDelete(packs restic.IDSet) bool {
+	idx.m.RLock()
+	defer idx.m.RUnlock()
+
+	for id := range idx.toDelete {
+		if packs.Has(id) {
+			return true
+		}
+	}
+	return false
+}
+
+func (idx *Index) storeParity(id restic.ID, parity pack.Parity) {
+	if idx.parity == nil {
+		idx.parity = make(map[restic.ID]pack.Parity)
+	}
+	idx.parity[id] = parity
+}
+
+// Parity returns the parity section of the given pack.
+func (idx *Index) Parity(id restic.ID) (pack.Parity, bool) {
+	idx.m.RLock()
+	defer idx.m.RUnlock()
+
+	p, ok := idx.parity[id]
+	return p, ok
+}
+
+// EachParity calls fn for each pack with a parity section. This blocks any
+// modification of the index.
+func (idx *Index) EachParity(fn func(id restic.ID, parity pack.Parity)) {
+	idx.m.RLock()
+	defer idx.m.RUnlock()
+
+	for id, p := range idx.parity {
+		fn(id, p)
+	}
 }
 
 func (idx *Index) toPackedBlob(e *indexEntry, t restic.BlobType) *pack.PackedBlob {
@@ -244,6 +366,11 @@ func (idx *Index) Values() iter.Seq[*pack.PackedBlob] {
 type PackBlobs struct {
 	PackID restic.ID
 	Blobs  pack.Blobs
+	// Parity is the parity section of the pack, if it has one
+	Parity *pack.Parity
+	// DeleteTime is the time at which the pack was marked for deletion, it
+	// is zero for regular packs
+	DeleteTime time.Time
 }
 
 // EachByPack returns a channel that yields all blobs known to the index,
@@ -284,6 +411,9 @@ func (idx *Index) EachByPack(ctx context.Context, packBlacklist restic.IDSet) <-
 					result.Blobs = append(result.Blobs, idx.toPackedBlob(e, restic.BlobType(typ)).Blob)
 				}
 			}
+			if parity, ok := idx.parity[packID]; ok {
+				result.Parity = &parity
+			}
 			// allow GC once entry is no longer necessary
 			delete(byPack, packID)
 			select {
@@ -311,8 +441,11 @@ func (idx *Index) Packs() restic.IDSet {
 }
 
 type packJSON struct {
-	ID    restic.ID  `json:"id"`
-	Blobs []blobJSON `json:"blobs"`
+	ID     restic.ID    `json:"id"`
+	Blobs  []blobJSON   `json:"blobs"`
+	Parity *pack.Parity `json:"parity,omitempty"`
+	// Time is only set for packs marked for deletion
+	Time *time.Time `json:"time,omitempty"`
 }
 
 type blobJSON struct {
@@ -340,6 +473,9 @@ func (idx *Index) generatePackList() ([]packJSON, error) {
 			if !ok {
 				i = len(list)
 				list = append(list, packJSON{ID: packID})
+				if parity, ok := idx.parity[packID]; ok {
+					list[i].Parity = &parity
+				}
 				packs[packID] = i
 			}
 			p := &list[i]
@@ -358,9 +494,32 @@ func (idx *Index) generatePackList() ([]packJSON, error) {
 	return list, nil
 }
 
+// generatePacksToDelete returns the list of packs marked for deletion.
+func (idx *Index) generatePacksToDelete() []packJSON {
+	var list []packJSON
+	for id, pbs := range idx.toDelete {
+		p := packJSON{ID: id, Parity: pbs.Parity}
+		t := pbs.DeleteTime
+		p.Time = &t
+		for _, blob := range pbs.Blobs {
+			p.Blobs = append(p.Blobs, blobJSON{
+				ID:                 blob.ID,
+				Type:               blob.Type,
+				Offset:             blob.Offset,
+				Length:             blob.Length,
+				UncompressedLength: blob.UncompressedLength,
+			})
+		}
+		list = append(list, p)
+	}
+	return list
+}
+
 type jsonIndex struct {
 	// removed: Supersedes restic.IDs `json:"supersedes,omitempty"`
 	Packs []packJSON `json:"packs"`
+	// PacksToDelete lists packs which were marked for deletion by prune
+	PacksToDelete []packJSON `json:"packs_to_delete,omitempty"`
 }
 
 // Encode writes the JSON serialization of the index to the writer w.
@@ -376,7 +535,8 @@ func (idx *Index) Encode(w io.Writer) error {
 
 	enc := json.NewEncoder(w)
 	idxJSON := jsonIndex{
-		Packs: list,
+		Packs:         list,
+		PacksToDelete: idx.generatePacksToDelete(),
 	}
 	return enc.Encode(idxJSON)
 }
@@ -453,7 +613,8 @@ func (idx *Index) Dump(w io.Writer) error {
 	}
 
 	outer := jsonIndex{
-		Packs: list,
+		Packs:         list,
+		PacksToDelete: idx.generatePacksToDelete(),
 	}
 
 	buf, err := json.MarshalIndent(outer, "", "  ")
@@ -518,6 +679,13 @@ func (idx *Index) merge(idx2 *Index) error {
 		}
 	}
 
+	for id, parity := range idx2.parity {
+		idx.storeParity(id, parity)
+	}
+	for _, pbs := range idx2.toDelete {
+		idx.storePackToDelete(pbs)
+	}
+
 	idx.ids = append(idx.ids, idx2.ids...)
 
 	return nil
@@ -548,6 +716,24 @@ func DecodeIndex(buf []byte, id restic.ID) (idx *Index, err error) {
 				UncompressedLength: blob.UncompressedLength,
 			})
 		}
+		if p.Parity != nil {
+			idx.storeParity(p.ID, *p.Parity)
+		}
+	}
+	for _, p := range idxJSON.PacksToDelete {
+		pbs := PackBlobs{PackID: p.ID, Parity: p.Parity}
+		if p.Time != nil {
+			pbs.DeleteTime = *p.Time
+		}
+		for _, blob := range p.Blobs {
+			pbs.Blobs = append(pbs.Blobs, pack.Blob{
+				BlobHandle:         restic.BlobHandle{Type: blob.Type, ID: blob.ID},
+				Offset:             blob.Offset,
+				Length:             blob.Length,
+				UncompressedLength: blob.UncompressedLength,
+			})
+		}
+		idx.storePackToDelete(pbs)
 	}
 	idx.ids = append(idx.ids, id)
 	idx.final = true
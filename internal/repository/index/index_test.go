@@ -7,6 +7,7 @@ import (
 	"math/rand"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/restic/restic/internal/repository/index"
 	"github.com/restic/restic/internal/repository/pack"
@@ -629,3 +630,92 @@ func TestEachByPackIgnoes(t *testing.T) {
 	}
 	rtest.Equals(t, expected, reported)
 }
+
+func TestIndexParity(t *testing.T) {
+	idx := index.NewIndex()
+
+	parity := &pack.Parity{DataShards: 10, ParityShards: 2, ChunkSize: 65536}
+	parityPack := restic.NewRandomID()
+	idx.StorePackWithParity(parityPack, pack.Blobs{{BlobHandle: restic.NewRandomBlobHandle(), Length: 42}}, parity)
+	plainPack := restic.NewRandomID()
+	idx.StorePack(plainPack, pack.Blobs{{BlobHandle: restic.NewRandomBlobHandle(), Length: 23}})
+
+	wr := bytes.NewBuffer(nil)
+	rtest.OK(t, idx.Encode(wr))
+	rtest.Assert(t, bytes.Contains(wr.Bytes(), []byte(`"parity":{"data_shards":10,"parity_shards":2,"chunk_size":65536}`)),
+		"parity missing in serialized index: %s", wr.Bytes())
+
+	idx2, err := index.DecodeIndex(wr.Bytes(), restic.NewRandomID())
+	rtest.OK(t, err)
+
+	p, ok := idx2.Parity(parityPack)
+	rtest.Assert(t, ok, "parity missing for pack %v", parityPack)
+	rtest.Equals(t, *parity, p)
+	_, ok = idx2.Parity(plainPack)
+	rtest.Assert(t, !ok, "unexpected parity for pack %v", plainPack)
+
+	for bp := range idx2.EachByPack(context.TODO(), restic.NewIDSet()) {
+		if bp.PackID == parityPack {
+			rtest.Equals(t, parity, bp.Parity)
+		} else {
+			rtest.Assert(t, bp.Parity == nil, "unexpected parity for pack %v", bp.PackID)
+		}
+	}
+}
+
+func TestIndexPacksToDelete(t *testing.T) {
+	idx := index.NewIndex()
+
+	regularPack := restic.NewRandomID()
+	idx.StorePack(regularPack, pack.Blobs{{BlobHandle: restic.NewRandomBlobHandle(), Length: 23}})
+	deleteTime := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	marked := index.PackBlobs{
+		PackID:     restic.NewRandomID(),
+		Blobs:      pack.Blobs{{BlobHandle: restic.NewRandomBlobHandle(), Length: 42, Offset: 0}},
+		DeleteTime: deleteTime,
+	}
+	idx.StorePackToDelete(marked)
+
+	wr := bytes.NewBuffer(nil)
+	rtest.OK(t, idx.Encode(wr))
+	rtest.Assert(t, bytes.Contains(wr.Bytes(), []byte(`"packs_to_delete":[`)),
+		"packs_to_delete missing in serialized index: %s", wr.Bytes())
+
+	idx2, err := index.DecodeIndex(wr.Bytes(), restic.NewRandomID())
+	rtest.OK(t, err)
+
+	// blobs of packs marked for deletion are not visible
+	rtest.Assert(t, !idx2.Has(marked.Blobs[0].BlobHandle), "blob of marked pack must not be found")
+	rtest.Equals(t, restic.NewIDSet(regularPack), idx2.Packs())
+	for bp := range idx2.EachByPack(context.TODO(), restic.NewIDSet()) {
+		rtest.Equals(t, regularPack, bp.PackID)
+	}
+
+	var pending []index.PackBlobs
+	idx2.EachPackToDelete(nil, func(pbs index.PackBlobs) {
+		pending = append(pending, pbs)
+	})
+	rtest.Equals(t, 1, len(pending))
+	rtest.Equals(t, marked.PackID, pending[0].PackID)
+	rtest.Equals(t, marked.Blobs, pending[0].Blobs)
+	rtest.Assert(t, deleteTime.Equal(pending[0].DeleteTime), "wrong delete time %v", pending[0].DeleteTime)
+
+	pending = nil
+	idx2.EachPackToDelete(restic.NewIDSet(marked.PackID), func(pbs index.PackBlobs) {
+		pending = append(pending, pbs)
+	})
+	rtest.Equals(t, 0, len(pending))
+
+	// the master index falls back to marked packs for lookups
+	mi := index.NewMasterIndex()
+	mi.Insert(idx2)
+	bh := marked.Blobs[0].BlobHandle
+	pbs := mi.Lookup(bh)
+	rtest.Equals(t, 1, len(pbs))
+	rtest.Equals(t, marked.PackID, pbs[0].PackID())
+	rtest.Equals(t, marked.Blobs[0], pbs[0].Blob)
+	size, found := mi.LookupSize(bh)
+	rtest.Assert(t, found, "blob of marked pack not found")
+	rtest.Equals(t, marked.Blobs[0].DataLength(), size)
+	rtest.Assert(t, !mi.Has(bh), "blob of marked pack must not be reported by Has")
+}
@@ -34,6 +34,9 @@ type indexMap struct {
 	mh maphash.Hash
 
 	blockList hashedArrayTree
+
+	// alloc allocates the buckets and blocks, nil allocates on the heap.
+	alloc *mappedAllocator
 }
 
 const (
@@ -156,7 +159,8 @@ func (m *indexMap) preallocate(numEntries int) {
 		return
 	}
 
-	m.buckets = make([]uint, newSize)
+	freeMapped(m.alloc, m.buckets)
+	m.buckets = makeMapped[uint](m.alloc, newSize)
 
 	blockCount := m.blockList.Size()
 	for i := uint(1); i < blockCount; i++ {
@@ -185,9 +189,9 @@ func (m *indexMap) hash(id restic.ID) uint {
 
 func (m *indexMap) init() {
 	const initialBuckets = 64
-	m.buckets = make([]uint, initialBuckets)
+	m.buckets = makeMapped[uint](m.alloc, initialBuckets)
 	// first entry in blockList serves as null byte
-	m.blockList = *newHAT()
+	m.blockList = *newHAT(m.alloc)
 	m.newEntry()
 }
 
@@ -259,9 +263,11 @@ type hashedArrayTree struct {
 
 	size      uint
 	blockList [][]indexEntry
+
+	alloc *mappedAllocator
 }
 
-func newHAT() *hashedArrayTree {
+func newHAT(alloc *mappedAllocator) *hashedArrayTree {
 	// start with a small block size
 	blockSizePower := uint(2)
 	blockSize := uint(1 << blockSizePower)
@@ -272,6 +278,7 @@ func newHAT() *hashedArrayTree {
 		blockSize: blockSize,
 		size:      0,
 		blockList: make([][]indexEntry, blockSize),
+		alloc:     alloc,
 	}
 }
 
@@ -320,12 +327,13 @@ func (h *hashedArrayTree) preallocate(numEntries uint) {
 				// merged all blocks with data. Grow will allocate the block later on
 				break
 			}
-			block := make([]indexEntry, 0, h.blockSize)
-			block = append(block, oldBlocks[i]...)
-			block = append(block, oldBlocks[i+1]...)
-			// make sure to set the correct length as not all old blocks may contain entries yet
-			h.blockList[i/2] = block[0:h.blockSize]
+			block := makeMapped[indexEntry](h.alloc, int(h.blockSize))
+			n := copy(block, oldBlocks[i])
+			copy(block[n:], oldBlocks[i+1])
+			h.blockList[i/2] = block
 			// allow GC
+			freeMapped(h.alloc, oldBlocks[i])
+			freeMapped(h.alloc, oldBlocks[i+1])
 			oldBlocks[i] = nil
 			oldBlocks[i+1] = nil
 		}
@@ -338,6 +346,6 @@ func (h *hashedArrayTree) grow() {
 	idx, subIdx := h.index(h.size)
 	if subIdx == 0 {
 		// new index entry batch
-		h.blockList[idx] = make([]indexEntry, h.blockSize)
+		h.blockList[idx] = makeMapped[indexEntry](h.alloc, int(h.blockSize))
 	}
 }
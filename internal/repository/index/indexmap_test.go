@@ -114,7 +114,7 @@ func TestIndexMapForeachWithID(t *testing.T) {
 }
 
 func TestHashedArrayTree(t *testing.T) {
-	hat := newHAT()
+	hat := newHAT(nil)
 	const testSize = 1024
 	for i := uint(0); i < testSize; i++ {
 		rtest.Assert(t, hat.Size() == i, "expected hat size %v got %v", i, hat.Size())
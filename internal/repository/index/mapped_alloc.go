@@ -0,0 +1,136 @@
+package index
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"unsafe"
+)
+
+// A mappedAllocator allocates the large arrays of an indexMap in memory-mapped
+// temporary files instead of on the Go heap. For huge repositories, this
+// allows the operating system to write the pages of the index back to disk
+// instead of having to keep the whole index in memory. The arrays must not
+// contain pointers, as the garbage collector does not scan mapped memory.
+//
+// To keep the number of mappings low, arrays are allocated from arenas of at
+// least mappedArenaSize bytes. An arena is unmapped once all arrays allocated
+// from it are freed. Small arrays are allocated on the heap. A nil
+// *mappedAllocator allocates all arrays on the heap.
+type mappedAllocator struct {
+	dir string
+
+	m      sync.Mutex
+	arenas []*mappedArena
+}
+
+type mappedArena struct {
+	buf  []byte
+	used int // number of bytes allocated from buf
+	live int // number of arrays which were not freed yet
+}
+
+const (
+	// minMappedSize is the minimum size of an array that is stored in an arena.
+	minMappedSize = 4096
+	// mappedArenaSize is the default size of an arena.
+	mappedArenaSize = 64 * 1024 * 1024
+	// mappedAlignment is the alignment of arrays within an arena.
+	mappedAlignment = 64
+)
+
+func newMappedAllocator(dir string) *mappedAllocator {
+	return &mappedAllocator{dir: dir}
+}
+
+// makeMapped returns a zeroed slice of n elements.
+func makeMapped[T any](a *mappedAllocator, n int) []T {
+	var zero T
+	size := n * int(unsafe.Sizeof(zero))
+	if a == nil || size < minMappedSize {
+		return make([]T, n)
+	}
+	return unsafe.Slice((*T)(a.alloc(size)), n)
+}
+
+// freeMapped releases a slice returned by makeMapped. The slice must not be
+// used afterwards.
+func freeMapped[T any](a *mappedAllocator, s []T) {
+	var zero T
+	if a == nil || cap(s)*int(unsafe.Sizeof(zero)) < minMappedSize {
+		return
+	}
+	a.free(unsafe.Pointer(unsafe.SliceData(s)))
+}
+
+func (a *mappedAllocator) alloc(size int) unsafe.Pointer {
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	size = (size + mappedAlignment - 1) &^ (mappedAlignment - 1)
+
+	var arena *mappedArena
+	if len(a.arenas) > 0 {
+		last := a.arenas[len(a.arenas)-1]
+		if len(last.buf)-last.used >= size {
+			arena = last
+		}
+	}
+	if arena == nil {
+		buf, err := mapFile(a.dir, max(size, mappedArenaSize))
+		if err != nil {
+			// like running out of memory, there is no way to recover from this
+			panic(fmt.Sprintf("unable to allocate memory-mapped index: %v", err))
+		}
+		arena = &mappedArena{buf: buf}
+		a.arenas = append(a.arenas, arena)
+	}
+
+	p := unsafe.Pointer(&arena.buf[arena.used])
+	arena.used += size
+	arena.live++
+	return p
+}
+
+func (a *mappedAllocator) free(p unsafe.Pointer) {
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	for i, arena := range a.arenas {
+		start := uintptr(unsafe.Pointer(unsafe.SliceData(arena.buf)))
+		if uintptr(p) < start || uintptr(p) >= start+uintptr(len(arena.buf)) {
+			continue
+		}
+
+		arena.live--
+		if arena.live == 0 {
+			_ = unmapFile(arena.buf)
+			a.arenas = slices.Delete(a.arenas, i, i+1)
+		}
+		return
+	}
+	panic("freeing memory which was not allocated by the index")
+}
+
+// freeAll releases all arenas.
+func (a *mappedAllocator) freeAll() {
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	for _, arena := range a.arenas {
+		_ = unmapFile(arena.buf)
+	}
+	a.arenas = nil
+}
+
+// mappedSize returns the total size of all arenas.
+func (a *mappedAllocator) mappedSize() int {
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	size := 0
+	for _, arena := range a.arenas {
+		size += len(arena.buf)
+	}
+	return size
+}
@@ -0,0 +1,45 @@
+package index
+
+import (
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestMappedAllocator(t *testing.T) {
+	if !MappedIndexSupported {
+		t.Skip("memory-mapped index is not supported on this platform")
+	}
+
+	a := newMappedAllocator(t.TempDir())
+
+	// small arrays are allocated on the heap
+	small := makeMapped[uint](a, 16)
+	rtest.Equals(t, 0, a.mappedSize())
+	freeMapped(a, small)
+
+	s1 := makeMapped[uint](a, 1024)
+	s2 := makeMapped[indexEntry](a, 1024)
+	rtest.Equals(t, mappedArenaSize, a.mappedSize())
+	for i := range s1 {
+		rtest.Equals(t, uint(0), s1[i])
+		s1[i] = uint(i)
+	}
+	rtest.Equals(t, indexEntry{}, s2[1023])
+	s2[1023].offset = 42
+	rtest.Equals(t, uint(1023), s1[1023])
+
+	// the arena is only unmapped once all arrays are freed
+	freeMapped(a, s1)
+	rtest.Equals(t, mappedArenaSize, a.mappedSize())
+	freeMapped(a, s2)
+	rtest.Equals(t, 0, a.mappedSize())
+
+	// arrays larger than an arena get their own mapping
+	large := makeMapped[uint](a, mappedArenaSize/8+1)
+	rtest.Assert(t, a.mappedSize() > mappedArenaSize, "unexpected mapped size %v", a.mappedSize())
+	large[len(large)-1] = 1
+
+	a.freeAll()
+	rtest.Equals(t, 0, a.mappedSize())
+}
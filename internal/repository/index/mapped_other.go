@@ -0,0 +1,17 @@
+//go:build !unix
+
+package index
+
+import "github.com/restic/restic/internal/errors"
+
+// MappedIndexSupported is true if the index can be stored in memory-mapped
+// files on this platform.
+const MappedIndexSupported = false
+
+func mapFile(_ string, _ int) ([]byte, error) {
+	return nil, errors.New("memory-mapped index is not supported on this platform")
+}
+
+func unmapFile(_ []byte) error {
+	return nil
+}
@@ -0,0 +1,43 @@
+//go:build unix
+
+package index
+
+import (
+	"os"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/fileio"
+	"golang.org/x/sys/unix"
+)
+
+// MappedIndexSupported is true if the index can be stored in memory-mapped
+// files on this platform.
+const MappedIndexSupported = true
+
+// mapFile maps a new temporary file of the given size in dir. The file is
+// removed immediately, it only exists as long as it is mapped.
+func mapFile(dir string, size int) ([]byte, error) {
+	f, err := os.CreateTemp(dir, "restic-index-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+	}()
+
+	// allocate the disk space upfront, writing to a sparse file on a full
+	// disk would crash restic
+	if err := fileio.PreallocateFile(f, int64(size)); err != nil {
+		debug.Log("preallocating %v failed: %v", f.Name(), err)
+		if err := f.Truncate(int64(size)); err != nil {
+			return nil, err
+		}
+	}
+
+	return unix.Mmap(int(f.Fd()), 0, size, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+}
+
+func unmapFile(buf []byte) error {
+	return unix.Munmap(buf)
+}
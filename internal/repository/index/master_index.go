@@ -6,6 +6,7 @@ import (
 	"iter"
 	"runtime"
 	"sync"
+	"time"
 
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/repository/pack"
@@ -18,6 +19,10 @@ type MasterIndex struct {
 	idx          []*Index
 	pendingBlobs map[restic.BlobHandle]uint
 	idxMutex     sync.RWMutex
+
+	// mappedDir is the directory for the memory-mapped files of the main
+	// index, if it is not stored on the heap.
+	mappedDir string
 }
 
 // NewMasterIndex creates a new master index.
@@ -27,9 +32,24 @@ func NewMasterIndex() *MasterIndex {
 	return mi
 }
 
+// NewMappedMasterIndex creates a new master index which stores the entries
+// of all loaded indexes in memory-mapped temporary files in dir. This reduces
+// the memory usage for huge repositories, as the operating system can write
+// the index back to disk. Check MappedIndexSupported first.
+func NewMappedMasterIndex(dir string) *MasterIndex {
+	mi := &MasterIndex{mappedDir: dir}
+	mi.clear()
+	return mi
+}
+
 func (mi *MasterIndex) clear() {
 	// Always add an empty final index, such that MergeFinalIndexes can merge into this.
-	mi.idx = []*Index{NewIndex()}
+	// Only this index can grow large, thus the others are always kept on the heap.
+	if mi.mappedDir != "" {
+		mi.idx = []*Index{newMappedIndex(mi.mappedDir)}
+	} else {
+		mi.idx = []*Index{NewIndex()}
+	}
 	mi.idx[0].Finalize()
 	mi.clearPendingBlobs()
 }
@@ -38,7 +58,10 @@ func (mi *MasterIndex) clearPendingBlobs() {
 	mi.pendingBlobs = make(map[restic.BlobHandle]uint)
 }
 
-// Lookup queries all known Indexes for the ID and returns all matches.
+// Lookup queries all known Indexes for the ID and returns all matches. If
+// the blob is only contained in packs marked for deletion, these are returned
+// instead. This keeps snapshots readable which were created while prune ran
+// concurrently.
 func (mi *MasterIndex) Lookup(bh restic.BlobHandle) []*pack.PackedBlob {
 	mi.idxMutex.RLock()
 	defer mi.idxMutex.RUnlock()
@@ -47,12 +70,36 @@ func (mi *MasterIndex) Lookup(bh restic.BlobHandle) []*pack.PackedBlob {
 	for _, idx := range mi.idx {
 		pbs = idx.Lookup(bh, pbs)
 	}
+	if len(pbs) == 0 {
+		for _, idx := range mi.idx {
+			pbs = idx.lookupToDelete(bh, pbs)
+		}
+	}
 
 	return pbs
 }
 
+// Has returns whether the blob is pending or contained in the index. Unlike
+// Lookup, blobs which are only contained in packs marked for deletion are not
+// reported, as new data must not reference them.
+func (mi *MasterIndex) Has(bh restic.BlobHandle) bool {
+	mi.idxMutex.RLock()
+	defer mi.idxMutex.RUnlock()
+
+	if _, ok := mi.pendingBlobs[bh]; ok {
+		return true
+	}
+	for _, idx := range mi.idx {
+		if idx.Has(bh) {
+			return true
+		}
+	}
+	return false
+}
+
 // LookupSize queries all known Indexes for the ID and returns the first match.
-// Also returns true if the ID is pending.
+// Also returns true if the ID is pending. Like Lookup, it falls back to packs
+// marked for deletion.
 func (mi *MasterIndex) LookupSize(bh restic.BlobHandle) (uint, bool) {
 	mi.idxMutex.RLock()
 	defer mi.idxMutex.RUnlock()
@@ -67,6 +114,11 @@ func (mi *MasterIndex) LookupSize(bh restic.BlobHandle) (uint, bool) {
 			return size, found
 		}
 	}
+	for _, idx := range mi.idx {
+		if pbs := idx.lookupToDelete(bh, nil); len(pbs) > 0 {
+			return pbs[0].PlaintextLength(), true
+		}
+	}
 
 	return 0, false
 }
@@ -147,11 +199,17 @@ func (mi *MasterIndex) Insert(idx *Index) {
 
 // StorePack remembers the id and pack in the index.
 func (mi *MasterIndex) StorePack(ctx context.Context, id restic.ID, blobs pack.Blobs, r restic.SaverUnpacked[restic.FileType]) error {
-	mi.storePack(id, blobs)
+	return mi.StorePackWithParity(ctx, id, blobs, nil, r)
+}
+
+// StorePackWithParity works like StorePack, but additionally remembers the
+// parity section of the pack. parity may be nil.
+func (mi *MasterIndex) StorePackWithParity(ctx context.Context, id restic.ID, blobs pack.Blobs, parity *pack.Parity, r restic.SaverUnpacked[restic.FileType]) error {
+	mi.storePack(id, blobs, parity)
 	return mi.saveFullIndex(ctx, r)
 }
 
-func (mi *MasterIndex) storePack(id restic.ID, blobs pack.Blobs) {
+func (mi *MasterIndex) storePack(id restic.ID, blobs pack.Blobs, parity *pack.Parity) {
 	mi.idxMutex.Lock()
 	defer mi.idxMutex.Unlock()
 
@@ -162,16 +220,43 @@ func (mi *MasterIndex) storePack(id restic.ID, blobs pack.Blobs) {
 
 	for _, idx := range mi.idx {
 		if !idx.Final() {
-			idx.StorePack(id, blobs)
+			idx.StorePackWithParity(id, blobs, parity)
 			return
 		}
 	}
 
 	newIdx := NewIndex()
-	newIdx.StorePack(id, blobs)
+	newIdx.StorePackWithParity(id, blobs, parity)
 	mi.idx = append(mi.idx, newIdx)
 }
 
+// Parity returns the parity section of the given pack.
+func (mi *MasterIndex) Parity(id restic.ID) (pack.Parity, bool) {
+	mi.idxMutex.RLock()
+	defer mi.idxMutex.RUnlock()
+
+	for _, idx := range mi.idx {
+		if p, ok := idx.Parity(id); ok {
+			return p, true
+		}
+	}
+	return pack.Parity{}, false
+}
+
+// ListParity calls fn for each pack with a parity section.
+func (mi *MasterIndex) ListParity(ctx context.Context, fn func(id restic.ID, parity pack.Parity)) error {
+	mi.idxMutex.RLock()
+	defer mi.idxMutex.RUnlock()
+
+	for _, idx := range mi.idx {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		idx.EachParity(fn)
+	}
+	return nil
+}
+
 // finalizeNotFinalIndexes finalizes all indexes that
 // have not yet been saved and returns that list
 func (mi *MasterIndex) finalizeNotFinalIndexes() []*Index {
@@ -366,9 +451,19 @@ type MasterIndexRewriteOpts struct {
 	SaveProgress   restic.Counter
 	DeleteProgress func() restic.Counter
 	DeleteReport   func(id restic.ID, err error)
+
+	// MarkPacks are moved to the packs_to_delete section of the index with
+	// the deletion time MarkTime.
+	MarkPacks restic.IDSet
+	MarkTime  time.Time
+	// UnmarkPacks are moved from the packs_to_delete section back to the
+	// regular index.
+	UnmarkPacks restic.IDSet
 }
 
 // Rewrite removes packs whose ID is in excludePacks from all known indexes.
+// This includes packs marked for deletion. Packs in opts.MarkPacks or
+// opts.UnmarkPacks are marked for deletion or unmarked, respectively.
 // It also removes the rewritten index files and those listed in extraObsolete.
 // If oldIndexes is not nil, then only the indexes in this set are processed.
 // This is used by repair index to only rewrite and delete the old indexes.
@@ -407,6 +502,10 @@ func (mi *MasterIndex) Rewrite(ctx context.Context, repo restic.Unpacked[restic.
 		excludePacks = restic.NewIDSet()
 	}
 	debug.Log("start rebuilding index of %d indexes, excludePacks: %v", len(indexes), excludePacks)
+	// indexes containing one of these packs must always be rewritten
+	changedPacks := excludePacks.Clone()
+	changedPacks.Merge(opts.MarkPacks)
+	changedPacks.Merge(opts.UnmarkPacks)
 	wg, wgCtx := errgroup.WithContext(ctx)
 
 	idxCh := make(chan restic.ID)
@@ -474,8 +573,8 @@ func (mi *MasterIndex) Rewrite(ctx context.Context, repo restic.Unpacked[restic.
 		packBlobsIDSet := restic.NewIDSet()
 		newIndex := NewIndex()
 		for task := range rewriteCh {
-			// always rewrite indexes that include a pack that must be removed or is a duplicate or that are not full
-			if len(task.idx.Packs().Intersect(excludePacks)) == 0 && Full(task.idx) && !Oversized(task.idx) {
+			// always rewrite indexes that include a pack that must be removed, (un)marked or is a duplicate or that are not full
+			if len(task.idx.Packs().Intersect(changedPacks)) == 0 && !task.idx.hasPackToDelete(changedPacks) && Full(task.idx) && !Oversized(task.idx) {
 				// check that no pack index entry is a duplicate of an already processed one
 				idxPackBlobsIDSet := restic.NewIDSet()
 				for pbs := range task.idx.EachByPack(wgCtx, excludePacks) {
@@ -504,7 +603,12 @@ func (mi *MasterIndex) Rewrite(ctx context.Context, repo restic.Unpacked[restic.
 				}
 				packBlobsIDSet.Insert(packBlobsID)
 
-				newIndex.StorePack(pbs.PackID, pbs.Blobs)
+				if opts.MarkPacks.Has(pbs.PackID) {
+					pbs.DeleteTime = opts.MarkTime
+					newIndex.StorePackToDelete(pbs)
+				} else {
+					newIndex.StorePackWithParity(pbs.PackID, pbs.Blobs, pbs.Parity)
+				}
 				if Full(newIndex) {
 					select {
 					case saveCh <- newIndex:
@@ -517,6 +621,23 @@ func (mi *MasterIndex) Rewrite(ctx context.Context, repo restic.Unpacked[restic.
 			if wgCtx.Err() != nil {
 				return wgCtx.Err()
 			}
+
+			var pending []PackBlobs
+			task.idx.EachPackToDelete(excludePacks, func(pbs PackBlobs) {
+				pending = append(pending, pbs)
+			})
+			for _, pbs := range pending {
+				if opts.UnmarkPacks.Has(pbs.PackID) {
+					packBlobsID := PackBlobsHash(pbs)
+					if packBlobsIDSet.Has(packBlobsID) {
+						continue
+					}
+					packBlobsIDSet.Insert(packBlobsID)
+					newIndex.StorePackWithParity(pbs.PackID, pbs.Blobs, pbs.Parity)
+				} else {
+					newIndex.StorePackToDelete(pbs)
+				}
+			}
 			p.Add(1)
 		}
 
@@ -535,7 +656,7 @@ func (mi *MasterIndex) Rewrite(ctx context.Context, repo restic.Unpacked[restic.
 	for idx := range saveCh {
 		savers.Go(func() error {
 			idx.Finalize()
-			if len(idx.packs) == 0 {
+			if len(idx.packs) == 0 && len(idx.toDelete) == 0 {
 				return nil
 			}
 			_, err := idx.SaveIndex(wgCtx, repo)
@@ -597,7 +718,7 @@ func (mi *MasterIndex) SaveFallback(ctx context.Context, repo restic.SaverRemove
 			}
 
 			for pbs := range idx.EachByPack(wgCtx, excludePacks) {
-				newIndex.StorePack(pbs.PackID, pbs.Blobs)
+				newIndex.StorePackWithParity(pbs.PackID, pbs.Blobs, pbs.Parity)
 				p.Add(1)
 				if Full(newIndex) {
 					select {
@@ -611,6 +732,7 @@ func (mi *MasterIndex) SaveFallback(ctx context.Context, repo restic.SaverRemove
 			if wgCtx.Err() != nil {
 				return wgCtx.Err()
 			}
+			idx.EachPackToDelete(excludePacks, newIndex.StorePackToDelete)
 		}
 
 		select {
@@ -636,6 +758,20 @@ func (mi *MasterIndex) SaveFallback(ctx context.Context, repo restic.SaverRemove
 	return err
 }
 
+// PacksToDelete returns all packs which were marked for deletion by prune.
+func (mi *MasterIndex) PacksToDelete() map[restic.ID]PackBlobs {
+	mi.idxMutex.RLock()
+	defer mi.idxMutex.RUnlock()
+
+	packs := make(map[restic.ID]PackBlobs)
+	for _, idx := range mi.idx {
+		idx.EachPackToDelete(nil, func(pbs PackBlobs) {
+			packs[pbs.PackID] = pbs
+		})
+	}
+	return packs
+}
+
 // saveIndex saves all indexes in the backend.
 func (mi *MasterIndex) saveIndex(ctx context.Context, r restic.SaverUnpacked[restic.FileType], indexes ...*Index) error {
 	for i, idx := range indexes {
@@ -692,8 +828,12 @@ func (mi *MasterIndex) ListPacks(ctx context.Context, packs restic.IDSet) <-chan
 			for packID, pbs := range packBlob {
 				// allow GC
 				packBlob[packID] = nil
+				result := PackBlobs{PackID: packID, Blobs: pbs}
+				if parity, ok := mi.Parity(packID); ok {
+					result.Parity = &parity
+				}
 				select {
-				case out <- PackBlobs{PackID: packID, Blobs: pbs}:
+				case out <- result:
 				case <-ctx.Done():
 					return
 				}
@@ -282,6 +282,36 @@ func TestMasterMergeFinalIndexes(t *testing.T) {
 	rtest.Equals(t, 2, blobCount)
 }
 
+func TestMappedMasterIndex(t *testing.T) {
+	if !index.MappedIndexSupported {
+		t.Skip("memory-mapped index is not supported on this platform")
+	}
+
+	mIdx := index.NewMasterIndex()
+	mappedIdx := index.NewMappedMasterIndex(t.TempDir())
+	for _, mi := range []*index.MasterIndex{mIdx, mappedIdx} {
+		// create identical indexes for both master indexes
+		rng := rand.New(rand.NewSource(0))
+		for i := 0; i < 5; i++ {
+			idx, _ := createRandomIndex(rng, 1000)
+			mi.Insert(idx)
+		}
+		index.TestMergeIndex(t, mi)
+	}
+
+	count := 0
+	for pb := range mIdx.Values() {
+		rtest.Equals(t, mIdx.Lookup(pb.Blob.BlobHandle), mappedIdx.Lookup(pb.Blob.BlobHandle))
+		count++
+	}
+	mappedCount := 0
+	for range mappedIdx.Values() {
+		mappedCount++
+	}
+	rtest.Equals(t, count, mappedCount)
+	rtest.Equals(t, mIdx.Packs(nil), mappedIdx.Packs(nil))
+}
+
 func createRandomMasterIndex(t testing.TB, rng *rand.Rand, num, size int) (*index.MasterIndex, restic.BlobHandle) {
 	mIdx := index.NewMasterIndex()
 	for i := 0; i < num-1; i++ {
@@ -755,3 +785,55 @@ func TestRewriteFullPacks(t *testing.T) {
 	rtest.Equals(t, []*pack.PackedBlob{blobA}, mi2.Lookup(blobA.Handle()))
 	rtest.Equals(t, []*pack.PackedBlob{blobB}, mi2.Lookup(blobB.Handle()))
 }
+
+func TestRewriteMarkPacks(t *testing.T) {
+	repo, unpacked, _ := repository.TestRepositoryWithVersion(t, restic.StableRepoVersion)
+
+	packs := make([]restic.ID, 3)
+	blobs := make([]restic.BlobHandle, 3)
+	idx := index.NewIndex()
+	for i := range packs {
+		packs[i] = restic.NewRandomID()
+		blobs[i] = restic.NewRandomBlobHandle()
+		idx.StorePack(packs[i], pack.Blobs{{BlobHandle: blobs[i], Length: 42}})
+	}
+	idx.Finalize()
+	_, err := idx.SaveIndex(context.TODO(), unpacked)
+	rtest.OK(t, err)
+
+	load := func() *index.MasterIndex {
+		mi := index.NewMasterIndex()
+		rtest.OK(t, mi.Load(context.TODO(), repo, restic.NoopCounter, nil))
+		return mi
+	}
+
+	// mark the first two packs for deletion
+	markTime := time.Now().Truncate(time.Second)
+	mi := load()
+	rtest.OK(t, mi.Rewrite(context.TODO(), unpacked, nil, nil, nil, index.MasterIndexRewriteOpts{
+		MarkPacks: restic.NewIDSet(packs[0], packs[1]),
+		MarkTime:  markTime,
+	}))
+
+	mi = load()
+	rtest.Equals(t, restic.NewIDSet(packs[2]), mi.Packs(restic.NewIDSet()))
+	// blobs of marked packs are only found by lookups
+	rtest.Assert(t, !mi.Has(blobs[0]), "blob of marked pack must not be reported by Has")
+	pbs := mi.Lookup(blobs[0])
+	rtest.Equals(t, 1, len(pbs))
+	rtest.Equals(t, packs[0], pbs[0].PackID())
+	pending := mi.PacksToDelete()
+	rtest.Equals(t, 2, len(pending))
+	rtest.Assert(t, markTime.Equal(pending[packs[0]].DeleteTime), "wrong delete time %v", pending[packs[0]].DeleteTime)
+
+	// restore the first pack and remove the second one
+	rtest.OK(t, mi.Rewrite(context.TODO(), unpacked, restic.NewIDSet(packs[1]), nil, nil, index.MasterIndexRewriteOpts{
+		UnmarkPacks: restic.NewIDSet(packs[0]),
+	}))
+
+	mi = load()
+	rtest.Equals(t, restic.NewIDSet(packs[0], packs[2]), mi.Packs(restic.NewIDSet()))
+	rtest.Assert(t, mi.Has(blobs[0]), "blob of restored pack not found")
+	rtest.Equals(t, 0, len(mi.Lookup(blobs[1])))
+	rtest.Equals(t, 0, len(mi.PacksToDelete()))
+}
@@ -38,6 +38,16 @@ type Key struct {
 	Salt []byte `json:"salt"`
 	Data []byte `json:"data"`
 
+	// Time and Memory (in KiB) are only set for keys using the KDF
+	// "argon2id", which stores the parallelism in P.
+	Time   int `json:"t,omitempty"`
+	Memory int `json:"m,omitempty"`
+
+	// Recipient and Ephemeral are only set for keys encrypted for an X25519
+	// recipient instead of a password, which use the KDF "x25519".
+	Recipient string `json:"recipient,omitempty"`
+	Ephemeral []byte `json:"ephemeral,omitempty"`
+
 	user   *crypto.Key
 	master *crypto.Key
 
@@ -51,6 +61,23 @@ var params *crypto.Params
 // testKeyInjection is used to speed up tests by skipping the key decryption step.
 var testKeyInjection = sync.Map{}
 
+// kdfX25519 is used instead of a KDF for keys encrypted for an X25519 recipient.
+const kdfX25519 = "x25519"
+
+// Names of the KDFs which can be used to derive the user key from a password.
+const (
+	KDFScrypt   = "scrypt"
+	KDFArgon2id = "argon2id"
+)
+
+// KDFOptions selects the KDF used for a new key.
+type KDFOptions struct {
+	// KDF is either KDFScrypt or KDFArgon2id. If it is empty, scrypt is used.
+	KDF string
+	// Argon2 contains the parameters for argon2id.
+	Argon2 crypto.Argon2Params
+}
+
 const (
 	// KDFTimeout specifies the maximum runtime for the KDF.
 	KDFTimeout = 500 * time.Millisecond
@@ -67,6 +94,22 @@ func createMasterKey(ctx context.Context, s *Repository, password string) (*Key,
 
 // openKey tries do decrypt the key specified by name with the given password.
 func openKey(ctx context.Context, s *Repository, id restic.ID, password string) (*Key, error) {
+	return openKeyWith(ctx, s, id, func(k *Key) (*crypto.Key, error) {
+		return k.passwordUserKey(password)
+	})
+}
+
+// openIdentityKey tries to decrypt the key specified by name with one of the
+// given identities.
+func openIdentityKey(ctx context.Context, s *Repository, id restic.ID, identities []*crypto.Identity) (*Key, error) {
+	return openKeyWith(ctx, s, id, func(k *Key) (*crypto.Key, error) {
+		return k.identityUserKey(identities)
+	})
+}
+
+// openKeyWith decrypts the key specified by name with the user key returned by
+// userKey.
+func openKeyWith(ctx context.Context, s *Repository, id restic.ID, userKey func(k *Key) (*crypto.Key, error)) (*Key, error) {
 	if key, ok := testKeyInjection.Load(id); ok {
 		return &Key{
 			master: key.(*crypto.Key),
@@ -81,20 +124,9 @@ func openKey(ctx context.Context, s *Repository, id restic.ID, password string)
 		return nil, err
 	}
 
-	// check KDF
-	if k.KDF != "scrypt" {
-		return nil, errors.New("only supported KDF is scrypt()")
-	}
-
-	// derive user key
-	params := crypto.Params{
-		N: k.N,
-		R: k.R,
-		P: k.P,
-	}
-	k.user, err = crypto.KDF(params, k.Salt, password)
+	k.user, err = userKey(k)
 	if err != nil {
-		return nil, errors.Wrap(err, "crypto.KDF")
+		return nil, err
 	}
 
 	// decrypt master keys
@@ -124,18 +156,70 @@ func openKey(ctx context.Context, s *Repository, id restic.ID, password string)
 	return k, nil
 }
 
-// searchKey tries to decrypt at most maxKeys keys in the backend with the
-// given password. If none could be found, ErrNoKeyFound is returned. When
-// maxKeys is reached, ErrMaxKeysReached is returned. When setting maxKeys to
-// zero, all keys in the repo are checked.
-func searchKey(ctx context.Context, s *Repository, password string, maxKeys int, keyHint string) (k *Key, err error) {
+// passwordUserKey derives the user key from the password.
+func (k *Key) passwordUserKey(password string) (*crypto.Key, error) {
+	switch k.KDF {
+	case KDFScrypt:
+		params := crypto.Params{
+			N: k.N,
+			R: k.R,
+			P: k.P,
+		}
+		user, err := crypto.KDF(params, k.Salt, password)
+		if err != nil {
+			return nil, errors.Wrap(err, "crypto.KDF")
+		}
+		return user, nil
+	case KDFArgon2id:
+		if k.Time < 0 || k.Memory < 0 || k.P < 0 || k.P > 255 {
+			return nil, errors.New("invalid argon2id parameters")
+		}
+		params := crypto.Argon2Params{
+			Time:    uint32(k.Time),
+			Memory:  uint32(k.Memory),
+			Threads: uint8(k.P),
+		}
+		user, err := crypto.Argon2idKDF(params, k.Salt, password)
+		if err != nil {
+			return nil, errors.Wrap(err, "crypto.Argon2idKDF")
+		}
+		return user, nil
+	case kdfX25519:
+		// try the next key
+		return nil, fmt.Errorf("key requires an identity: %w", crypto.ErrUnauthenticated)
+	default:
+		return nil, errors.Errorf("unsupported KDF %q", k.KDF)
+	}
+}
+
+// identityUserKey derives the user key using the identity for the recipient
+// of the key.
+func (k *Key) identityUserKey(identities []*crypto.Identity) (*crypto.Key, error) {
+	if k.KDF != kdfX25519 {
+		// try the next key
+		return nil, fmt.Errorf("key requires a password: %w", crypto.ErrUnauthenticated)
+	}
+
+	for _, identity := range identities {
+		if identity.Recipient().String() == k.Recipient {
+			return identity.UserKey(k.Ephemeral)
+		}
+	}
+	return nil, fmt.Errorf("no identity for recipient %v: %w", k.Recipient, crypto.ErrUnauthenticated)
+}
+
+// searchKey tries to decrypt at most maxKeys keys in the backend using open.
+// If none could be found, ErrNoKeyFound is returned. When maxKeys is reached,
+// ErrMaxKeysReached is returned. When setting maxKeys to zero, all keys in the
+// repo are checked.
+func searchKey(ctx context.Context, s *Repository, open func(ctx context.Context, id restic.ID) (*Key, error), maxKeys int, keyHint string) (k *Key, err error) {
 	checked := 0
 
 	if len(keyHint) > 0 {
 		id, err := restic.Find(ctx, s, restic.KeyFile, keyHint)
 
 		if err == nil {
-			key, err := openKey(ctx, s, id, password)
+			key, err := open(ctx, id)
 
 			if err == nil {
 				debug.Log("successfully opened hinted key %v", id)
@@ -159,11 +243,11 @@ func searchKey(ctx context.Context, s *Repository, password string, maxKeys int,
 		}
 
 		debug.Log("trying key %q", id.String())
-		key, err := openKey(ctx, s, id, password)
+		key, err := open(ctx, id)
 		if err != nil {
 			debug.Log("key %v returned error %v", id.String(), err)
 
-			// ErrUnauthenticated means the password is wrong, try the next key
+			// ErrUnauthenticated means the password or identity is wrong, try the next key
 			if errors.Is(err, crypto.ErrUnauthenticated) {
 				return nil
 			}
@@ -210,27 +294,93 @@ func LoadKey(ctx context.Context, s *Repository, id restic.ID) (k *Key, err erro
 
 // AddKey adds a new key to an already existing repository.
 func AddKey(ctx context.Context, s *Repository, password, username, hostname string, template *crypto.Key) (*Key, error) {
-	// make sure we have valid KDF parameters
-	if params == nil {
-		p, err := crypto.Calibrate(KDFTimeout, KDFMemory)
+	return AddKeyWithKDF(ctx, s, KDFOptions{KDF: KDFScrypt}, password, username, hostname, template)
+}
+
+// AddKeyWithKDF adds a new key to an already existing repository, which
+// derives the user key from the password using the KDF selected by kdf.
+func AddKeyWithKDF(ctx context.Context, s *Repository, kdf KDFOptions, password, username, hostname string, template *crypto.Key) (*Key, error) {
+	// fill meta data about key
+	newkey := newKey(username, hostname)
+
+	// generate random salt
+	var err error
+	newkey.Salt, err = crypto.NewSalt()
+	if err != nil {
+		panic("unable to read enough random bytes for salt: " + err.Error())
+	}
+
+	switch kdf.KDF {
+	case "", KDFScrypt:
+		// make sure we have valid KDF parameters
+		if params == nil {
+			p, err := crypto.Calibrate(KDFTimeout, KDFMemory)
+			if err != nil {
+				return nil, errors.Wrap(err, "Calibrate")
+			}
+
+			params = &p
+			debug.Log("calibrated KDF parameters are %v", p)
+		}
+
+		newkey.KDF = KDFScrypt
+		newkey.N = params.N
+		newkey.R = params.R
+		newkey.P = params.P
+
+		// call KDF to derive user key
+		newkey.user, err = crypto.KDF(*params, newkey.Salt, password)
 		if err != nil {
-			return nil, errors.Wrap(err, "Calibrate")
+			return nil, err
 		}
+	case KDFArgon2id:
+		newkey.KDF = KDFArgon2id
+		newkey.Time = int(kdf.Argon2.Time)
+		newkey.Memory = int(kdf.Argon2.Memory)
+		newkey.P = int(kdf.Argon2.Threads)
 
-		params = &p
-		debug.Log("calibrated KDF parameters are %v", p)
+		newkey.user, err = crypto.Argon2idKDF(kdf.Argon2, newkey.Salt, password)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.Errorf("unsupported KDF %q", kdf.KDF)
 	}
 
-	// fill meta data about key
+	err = saveKey(ctx, s, newkey, template)
+	if err != nil {
+		return nil, err
+	}
+	return newkey, nil
+}
+
+// AddRecipientKey adds a new key to an already existing repository, which is
+// encrypted for an X25519 recipient instead of a password. Only the identity
+// of the recipient can decrypt the key.
+func AddRecipientKey(ctx context.Context, s *Repository, recipient *crypto.Recipient, username, hostname string, template *crypto.Key) (*Key, error) {
+	newkey := newKey(username, hostname)
+	newkey.KDF = kdfX25519
+	newkey.Recipient = recipient.String()
+
+	var err error
+	newkey.user, newkey.Ephemeral, err = recipient.NewUserKey()
+	if err != nil {
+		return nil, err
+	}
+
+	err = saveKey(ctx, s, newkey, template)
+	if err != nil {
+		return nil, err
+	}
+	return newkey, nil
+}
+
+// newKey returns a key with the meta data filled in.
+func newKey(username, hostname string) *Key {
 	newkey := &Key{
 		Created:  time.Now(),
 		Username: username,
 		Hostname: hostname,
-
-		KDF: "scrypt",
-		N:   params.N,
-		R:   params.R,
-		P:   params.P,
 	}
 
 	if newkey.Hostname == "" {
@@ -243,20 +393,12 @@ func AddKey(ctx context.Context, s *Repository, password, username, hostname str
 			newkey.Username = usr.Username
 		}
 	}
+	return newkey
+}
 
-	// generate random salt
-	var err error
-	newkey.Salt, err = crypto.NewSalt()
-	if err != nil {
-		panic("unable to read enough random bytes for salt: " + err.Error())
-	}
-
-	// call KDF to derive user key
-	newkey.user, err = crypto.KDF(*params, newkey.Salt, password)
-	if err != nil {
-		return nil, err
-	}
-
+// saveKey encrypts the master key with the user key of newkey and stores the
+// key in the repository. If template is nil, a new master key is generated.
+func saveKey(ctx context.Context, s *Repository, newkey *Key, template *crypto.Key) error {
 	if template == nil {
 		// generate new random master keys
 		newkey.master = crypto.NewRandomKey()
@@ -268,7 +410,7 @@ func AddKey(ctx context.Context, s *Repository, password, username, hostname str
 	// encrypt master keys (as json) with user key
 	buf, err := json.Marshal(newkey.master)
 	if err != nil {
-		return nil, errors.Wrap(err, "Marshal")
+		return errors.Wrap(err, "Marshal")
 	}
 
 	nonce := crypto.NewRandomNonce()
@@ -280,7 +422,7 @@ func AddKey(ctx context.Context, s *Repository, password, username, hostname str
 	// dump as json
 	buf, err = json.Marshal(newkey)
 	if err != nil {
-		return nil, errors.Wrap(err, "Marshal")
+		return errors.Wrap(err, "Marshal")
 	}
 
 	id := restic.Hash(buf)
@@ -289,12 +431,12 @@ func AddKey(ctx context.Context, s *Repository, password, username, hostname str
 
 	err = s.be.Save(ctx, h, backend.NewByteReader(buf, s.be.Hasher()))
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	newkey.id = id
 
-	return newkey, nil
+	return nil
 }
 
 func RemoveKey(ctx context.Context, repo *Repository, id restic.ID) error {
@@ -0,0 +1,355 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/repository/crypto"
+	"github.com/restic/restic/internal/restic"
+)
+
+// RotateMasterKeyOptions collects the options for RotateMasterKey.
+type RotateMasterKeyOptions struct {
+	// MaxRepackBytes limits the size of the pack files which are re-encrypted
+	// by a single run, zero means no limit.
+	MaxRepackBytes uint64
+
+	// RemoveOtherKeys allows removing the key files other than the one which
+	// was used to open the repository when starting the rotation. They can
+	// only decrypt the previous master key. If other key files exist and
+	// RemoveOtherKeys is not set, the rotation is not started.
+	RemoveOtherKeys bool
+
+	// ReencryptAuditLog is called once the snapshots were re-encrypted. It
+	// must re-encrypt the audit log, snapshots maps the old to the new
+	// snapshot IDs.
+	ReencryptAuditLog func(ctx context.Context, snapshots map[restic.ID]restic.ID) error
+}
+
+// RotateMasterKeyStats describes the progress made by RotateMasterKey.
+type RotateMasterKeyStats struct {
+	// Started is set if the rotation was started by this run.
+	Started bool
+	// RemovedKeys lists the key files of other users which were removed as
+	// they cannot decrypt the new master key.
+	RemovedKeys restic.IDs
+	// ReencryptedFiles counts the re-encrypted files except pack files.
+	ReencryptedFiles int
+	ReencryptedPacks int
+	RemainingPacks   int
+	// Finished is set once all data is encrypted using the new master key.
+	Finished bool
+}
+
+// RotateMasterKey replaces the master key of the repository. The first run
+// generates a new master key, re-wraps it using the key file which was used to
+// open the repository and removes all other key files if
+// opts.RemoveOtherKeys is set. Afterwards all files
+// except pack files are re-encrypted. The pack files are then re-encrypted by
+// repacking them, each run processes at most opts.MaxRepackBytes. The progress
+// is recorded in the config, which allows interrupting the rotation at any
+// time. Until the rotation is finished, data is decrypted using either key.
+// The rotation requires repository format version 3.
+//
+// The caller must hold an exclusive lock on the repository.
+func (r *Repository) RotateMasterKey(ctx context.Context, opts RotateMasterKeyOptions, printer restic.Printer) (RotateMasterKeyStats, error) {
+	var stats RotateMasterKeyStats
+
+	if r.cfg.Version < 3 {
+		return stats, errors.New("master key rotation requires at least repository format version 3")
+	}
+
+	if r.cfg.KeyRotation == nil {
+		removed, err := r.startKeyRotation(ctx, opts.RemoveOtherKeys)
+		if err != nil {
+			return stats, err
+		}
+		stats.Started = true
+		stats.RemovedKeys = removed
+	}
+
+	if !r.cfg.KeyRotation.MetadataDone {
+		n, err := r.reencryptMetadata(ctx, opts, printer)
+		stats.ReencryptedFiles = n
+		if err != nil {
+			return stats, err
+		}
+	}
+
+	done, remaining, err := r.reencryptPacks(ctx, opts.MaxRepackBytes, printer)
+	stats.ReencryptedPacks = done
+	stats.RemainingPacks = remaining
+	if err != nil {
+		return stats, err
+	}
+
+	if remaining == 0 {
+		cfg := r.Config()
+		cfg.KeyRotation = nil
+		if err := r.replaceConfig(ctx, cfg, "restic-key-rotation-"); err != nil {
+			return stats, fmt.Errorf("finishing the key rotation failed: %w", err)
+		}
+		r.key = r.key.WithoutPrevious()
+		stats.Finished = true
+	}
+	return stats, nil
+}
+
+// startKeyRotation generates a new master key, saves it as a new key file for
+// the current user key, which replaces the current key file, and records the
+// rotation in the config. Other key files are only removed if removeOthers is
+// set, otherwise their existence is an error. Returned are the other key files
+// which were removed.
+func (r *Repository) startKeyRotation(ctx context.Context, removeOthers bool) (restic.IDs, error) {
+	if r.keyFile == nil || r.keyFile.user == nil {
+		return nil, errors.New("the key file used to open the repository is unknown")
+	}
+
+	// all other keys can only decrypt the previous master key
+	var others restic.IDs
+	err := r.List(ctx, restic.KeyFile, func(id restic.ID, _ int64) error {
+		if id != r.keyID {
+			others = append(others, id)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(others) > 0 && !removeOthers {
+		return nil, errors.Errorf("the rotation would remove the other keys %v, which cannot decrypt the new master key", others)
+	}
+
+	var packs restic.IDs
+	err = r.List(ctx, restic.PackFile, func(id restic.ID, _ int64) error {
+		packs = append(packs, id)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	newKey := *r.keyFile
+	err = saveKey(ctx, r, &newKey, crypto.NewRandomKey())
+	if err != nil {
+		return nil, fmt.Errorf("saving the new key failed: %w", err)
+	}
+	debug.Log("saved new key %v", newKey.ID())
+
+	previous := r.key
+	r.key = newKey.master.WithPrevious(previous)
+
+	cfg := r.Config()
+	cfg.KeyRotation = &restic.KeyRotation{
+		PreviousKey:  previous,
+		PendingPacks: packs,
+	}
+	if err := r.replaceConfig(ctx, cfg, "restic-key-rotation-"); err != nil {
+		r.key = previous
+		_ = r.removeUnpacked(ctx, restic.KeyFile, newKey.ID())
+		return nil, err
+	}
+	oldKeyID := r.keyID
+	r.keyID = newKey.ID()
+	r.keyFile = &newKey
+
+	for _, id := range append(restic.IDs{oldKeyID}, others...) {
+		if err := r.removeUnpacked(ctx, restic.KeyFile, id); err != nil {
+			return nil, err
+		}
+	}
+	return others, nil
+}
+
+// reencryptMetadata re-encrypts all files except the pack files, keys and
+// locks. It returns the number of re-encrypted files.
+func (r *Repository) reencryptMetadata(ctx context.Context, opts RotateMasterKeyOptions, printer restic.Printer) (int, error) {
+	printer.P("re-encrypting index, snapshot and audit log files\n")
+
+	count := 0
+	var snapshots map[restic.ID]restic.ID
+	for _, t := range []restic.FileType{restic.IndexFile, restic.SnapshotFile, restic.GenerationFile} {
+		ids, err := r.reencryptUnpacked(ctx, t)
+		count += len(ids)
+		if err != nil {
+			return count, err
+		}
+		if t == restic.SnapshotFile {
+			snapshots = ids
+		}
+	}
+
+	if opts.ReencryptAuditLog != nil {
+		if err := opts.ReencryptAuditLog(ctx, snapshots); err != nil {
+			return count, fmt.Errorf("re-encrypting the audit log failed: %w", err)
+		}
+	}
+
+	cfg := r.Config()
+	rotation := *cfg.KeyRotation
+	rotation.MetadataDone = true
+	cfg.KeyRotation = &rotation
+	return count, r.replaceConfig(ctx, cfg, "restic-key-rotation-")
+}
+
+// reencryptUnpacked saves all files of type t which are still encrypted using
+// the previous master key again and removes the old files. It returns a map
+// from the old to the new file IDs.
+func (r *Repository) reencryptUnpacked(ctx context.Context, t restic.FileType) (map[restic.ID]restic.ID, error) {
+	var m sync.Mutex
+	ids := make(map[restic.ID]restic.ID)
+
+	err := restic.ParallelList(ctx, r, t, r.Connections(), func(ctx context.Context, id restic.ID, _ int64) error {
+		buf, err := r.LoadRaw(ctx, t, id)
+		if err != nil {
+			return err
+		}
+		previous, err := r.isPreviousKeyCiphertext(buf)
+		if err != nil {
+			return fmt.Errorf("%v %v: %w", t, id.Str(), err)
+		}
+		if !previous {
+			return nil
+		}
+
+		nonce, ciphertext := buf[:r.key.NonceSize()], buf[r.key.NonceSize():]
+		plaintext, err := r.key.Open(ciphertext[:0], nonce, ciphertext, nil)
+		if err != nil {
+			return err
+		}
+		plaintext, err = r.decompressUnpacked(plaintext)
+		if err != nil {
+			return err
+		}
+
+		newID, err := r.saveUnpacked(ctx, t, plaintext)
+		if err != nil {
+			return err
+		}
+		if err := r.removeUnpacked(ctx, t, id); err != nil {
+			return err
+		}
+		debug.Log("re-encrypted %v %v as %v", t, id, newID)
+
+		m.Lock()
+		ids[id] = newID
+		m.Unlock()
+		return nil
+	})
+	return ids, err
+}
+
+// EncryptedWithPreviousKey reports whether the file is still encrypted using
+// the previous master key of an unfinished master key rotation.
+func (r *Repository) EncryptedWithPreviousKey(ctx context.Context, t restic.FileType, id restic.ID) (bool, error) {
+	if r.cfg.KeyRotation == nil {
+		return false, nil
+	}
+	buf, err := r.LoadRaw(ctx, t, id)
+	if err != nil {
+		return false, err
+	}
+	return r.isPreviousKeyCiphertext(buf)
+}
+
+func (r *Repository) isPreviousKeyCiphertext(buf []byte) (bool, error) {
+	if len(buf) < crypto.CiphertextLength(0) {
+		return false, errors.New("invalid data, too short")
+	}
+	nonce, ciphertext := buf[:r.key.NonceSize()], buf[r.key.NonceSize():]
+	_, err := r.key.WithoutPrevious().Open(nil, nonce, ciphertext, nil)
+	if err == nil {
+		return false, nil
+	}
+	if _, perr := r.cfg.KeyRotation.PreviousKey.Open(nil, nonce, ciphertext, nil); perr != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// reencryptPacks repacks the pending pack files of the key rotation, at most
+// maxBytes per run. It returns the number of re-encrypted and of remaining
+// pack files.
+func (r *Repository) reencryptPacks(ctx context.Context, maxBytes uint64, printer restic.Printer) (int, int, error) {
+	rotation := *r.cfg.KeyRotation
+	if len(rotation.PendingPacks) == 0 {
+		return 0, 0, nil
+	}
+
+	if err := r.LoadIndex(ctx, printer); err != nil {
+		return 0, 0, err
+	}
+	defer r.clearIndex()
+
+	// pack files which are not referenced by the index are removed by prune
+	var packs []restic.ID
+	packSizes := make(map[restic.ID]uint64)
+	keepBlobs := restic.NewBlobSet()
+	for pbs := range r.listPacksFromIndex(ctx, restic.NewIDSet(rotation.PendingPacks...)) {
+		packs = append(packs, pbs.PackID)
+		for _, blob := range pbs.Blobs {
+			packSizes[pbs.PackID] += uint64(blob.Length)
+		}
+	}
+	if ctx.Err() != nil {
+		return 0, 0, ctx.Err()
+	}
+	sort.Sort(restic.IDs(packs))
+
+	batch := restic.NewIDSet()
+	var size uint64
+	for _, id := range packs {
+		if maxBytes > 0 && len(batch) > 0 && size+packSizes[id] > maxBytes {
+			break
+		}
+		batch.Insert(id)
+		size += packSizes[id]
+	}
+
+	if len(batch) > 0 {
+		for pbs := range r.listPacksFromIndex(ctx, batch) {
+			for _, blob := range pbs.Blobs {
+				keepBlobs.Insert(blob.BlobHandle)
+			}
+		}
+		if ctx.Err() != nil {
+			return 0, 0, ctx.Err()
+		}
+
+		printer.P("re-encrypting %d pack files\n", len(batch))
+		bar := printer.NewCounter("packs re-encrypted")
+		err := r.WithBlobUploader(ctx, func(ctx context.Context, uploader restic.BlobSaverWithAsync) error {
+			return CopyBlobs(ctx, r, r, uploader, batch, keepBlobs, bar, printer.P)
+		})
+		if err != nil {
+			return 0, 0, err
+		}
+		if err := rewriteIndexFiles(ctx, r, batch, nil, nil, printer); err != nil {
+			return 0, 0, err
+		}
+		printer.P("removing %d old pack files\n", len(batch))
+		_ = deleteFiles(ctx, true, &internalRepository{r}, batch, restic.PackFile, printer)
+	}
+
+	var remaining restic.IDs
+	for _, id := range packs {
+		if !batch.Has(id) {
+			remaining = append(remaining, id)
+		}
+	}
+	if len(remaining) == 0 {
+		return len(batch), 0, nil
+	}
+
+	cfg := r.Config()
+	rotation.PendingPacks = remaining
+	cfg.KeyRotation = &rotation
+	if err := r.replaceConfig(ctx, cfg, "restic-key-rotation-"); err != nil {
+		return len(batch), len(remaining), err
+	}
+	return len(batch), len(remaining), nil
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/restic/restic/internal/repository/crypto"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestRotateMasterKey(t *testing.T) {
+	repo, _, be := TestRepositoryWithVersion(t, 3)
+	ctx := context.TODO()
+	oldKey := repo.Key()
+
+	otherKey, err := AddKey(ctx, repo, "other password", "", "", repo.Key())
+	rtest.OK(t, err)
+
+	// each upload creates a separate pack file
+	var blobs restic.IDs
+	for i := 0; i < 3; i++ {
+		rtest.OK(t, repo.WithBlobUploader(ctx, func(ctx context.Context, uploader restic.BlobSaverWithAsync) error {
+			id, _, _, err := uploader.SaveBlob(ctx, restic.DataBlob, rtest.Random(i, 1000), restic.ID{}, false)
+			blobs = append(blobs, id)
+			return err
+		}))
+	}
+	snapshot := []byte(`{"time":"2024-01-01T00:00:00Z","tree":null,"paths":["/"]}`)
+	snapshotID, err := repo.SaveUnpacked(ctx, restic.WriteableSnapshotFile, snapshot)
+	rtest.OK(t, err)
+
+	// other keys are only removed if requested
+	_, err = repo.RotateMasterKey(ctx, RotateMasterKeyOptions{MaxRepackBytes: 1}, restic.NewNoopPrinter())
+	rtest.Assert(t, err != nil, "expected error for other keys")
+	rtest.Assert(t, TestOpenBackend(t, be).Config().KeyRotation == nil, "unexpected key rotation")
+
+	var runs []RotateMasterKeyStats
+	for len(runs) < 10 {
+		stats, err := repo.RotateMasterKey(ctx, RotateMasterKeyOptions{MaxRepackBytes: 1, RemoveOtherKeys: true}, restic.NewNoopPrinter())
+		rtest.OK(t, err)
+		runs = append(runs, stats)
+		if stats.Finished {
+			break
+		}
+
+		// the repository remains readable during the rotation
+		repo = TestOpenBackend(t, be)
+		rtest.Assert(t, repo.Config().KeyRotation != nil, "expected unfinished key rotation")
+		rtest.OK(t, repo.LoadIndex(ctx, restic.NewNoopPrinter()))
+		for _, id := range blobs {
+			_, err := repo.LoadBlob(ctx, restic.BlobHandle{Type: restic.DataBlob, ID: id}, nil)
+			rtest.OK(t, err)
+		}
+	}
+
+	rtest.Equals(t, 3, len(runs))
+	rtest.Assert(t, runs[0].Started, "expected first run to start the rotation")
+	rtest.Equals(t, restic.IDs{otherKey.ID()}, runs[0].RemovedKeys)
+	// three index files and the snapshot
+	rtest.Equals(t, 4, runs[0].ReencryptedFiles)
+	for i, stats := range runs {
+		rtest.Equals(t, 1, stats.ReencryptedPacks)
+		rtest.Equals(t, 2-i, stats.RemainingPacks)
+	}
+
+	repo = TestOpenBackend(t, be)
+	rtest.Assert(t, repo.Config().KeyRotation == nil, "expected finished key rotation")
+	rtest.OK(t, repo.LoadIndex(ctx, restic.NewNoopPrinter()))
+	for _, id := range blobs {
+		_, err := repo.LoadBlob(ctx, restic.BlobHandle{Type: restic.DataBlob, ID: id}, nil)
+		rtest.OK(t, err)
+	}
+	TestCheckRepo(t, repo)
+
+	// the snapshot was saved again using the new key
+	var snapshots restic.IDs
+	rtest.OK(t, repo.List(ctx, restic.SnapshotFile, func(id restic.ID, _ int64) error {
+		snapshots = append(snapshots, id)
+		return nil
+	}))
+	rtest.Equals(t, 1, len(snapshots))
+	rtest.Assert(t, snapshots[0] != snapshotID, "expected new snapshot ID")
+	buf, err := repo.LoadUnpacked(ctx, restic.SnapshotFile, snapshots[0])
+	rtest.OK(t, err)
+	rtest.Equals(t, snapshot, buf)
+
+	// nothing can be decrypted using the old master key
+	isOldKey := func(buf []byte) bool {
+		_, err := oldKey.Open(nil, buf[:oldKey.NonceSize()], buf[oldKey.NonceSize():], nil)
+		return err != crypto.ErrUnauthenticated
+	}
+	buf, err = repo.LoadRaw(ctx, restic.ConfigFile, restic.ID{})
+	rtest.OK(t, err)
+	rtest.Assert(t, !isOldKey(buf), "config can be decrypted using the old key")
+	for _, tpe := range []restic.FileType{restic.IndexFile, restic.SnapshotFile} {
+		rtest.OK(t, repo.List(ctx, tpe, func(id restic.ID, _ int64) error {
+			buf, err := repo.LoadRaw(ctx, tpe, id)
+			rtest.OK(t, err)
+			rtest.Assert(t, !isOldKey(buf), "%v %v can be decrypted using the old key", tpe, id)
+			return nil
+		}))
+	}
+}
+
+func TestRotateMasterKeyVersion(t *testing.T) {
+	repo, _, _ := TestRepositoryWithVersion(t, 2)
+
+	_, err := repo.RotateMasterKey(context.TODO(), RotateMasterKeyOptions{}, restic.NewNoopPrinter())
+	rtest.Assert(t, err != nil, "expected error for repository version 2")
+	rtest.Assert(t, repo.Config().KeyRotation == nil, "unexpected key rotation")
+}
@@ -48,7 +48,20 @@ func LockRepo(ctx context.Context, repo *Repository, exclusive bool, retryLock t
 	return lockerInst.Lock(ctx, repo, exclusive, retryLock, printRetry, logger)
 }
 
+// LockRepoForPrune acquires the non-exclusive repository lock of a concurrent
+// prune. Backups can run at the same time, but no other concurrent prune.
+func LockRepoForPrune(ctx context.Context, repo *Repository, retryLock time.Duration, printRetry func(msg string), logger func(format string, args ...interface{})) (func(), context.Context, error) {
+	return lockerInst.lock(ctx, repo, newPruneLock, retryLock, printRetry, logger)
+}
+
 func (l *locker) Lock(ctx context.Context, r *Repository, exclusive bool, retryLock time.Duration, printRetry func(msg string), logger func(format string, args ...interface{})) (func(), context.Context, error) {
+	create := func(ctx context.Context, repo restic.Unpacked[restic.FileType]) (*lockHandle, error) {
+		return newLock(ctx, repo, exclusive)
+	}
+	return l.lock(ctx, r, create, retryLock, printRetry, logger)
+}
+
+func (l *locker) lock(ctx context.Context, r *Repository, create func(context.Context, restic.Unpacked[restic.FileType]) (*lockHandle, error), retryLock time.Duration, printRetry func(msg string), logger func(format string, args ...interface{})) (func(), context.Context, error) {
 	var lock *lockHandle
 	var err error
 
@@ -60,7 +73,7 @@ func (l *locker) Lock(ctx context.Context, r *Repository, exclusive bool, retryL
 
 retryLoop:
 	for {
-		lock, err = newLock(ctx, repo, exclusive)
+		lock, err = create(ctx, repo)
 		if err != nil && IsAlreadyLocked(err) {
 
 			if !retryMessagePrinted {
@@ -77,7 +90,7 @@ retryLoop:
 			case <-retryTimeout:
 				debug.Log("repo already locked, timeout expired")
 				// Last lock attempt
-				lock, err = newLock(ctx, repo, exclusive)
+				lock, err = create(ctx, repo)
 				break retryLoop
 			case <-retrySleepCh:
 				retrySleep = minDuration(retrySleep*2, l.retrySleepMax)
@@ -93,7 +106,15 @@ retryLoop:
 	if err != nil {
 		return nil, ctx, fmt.Errorf("unable to create lock in backend: %w", err)
 	}
-	debug.Log("create lock %p (exclusive %v)", lock, exclusive)
+	debug.Log("create lock %p (exclusive %v)", lock, lock.Exclusive)
+
+	if lock.Exclusive {
+		// signal the upcoming modifications to operations without a lock file
+		if err := bumpGeneration(ctx, repo); err != nil {
+			_ = lock.unlock(ctx)
+			return nil, ctx, err
+		}
+	}
 
 	ctx, cancel := context.WithCancel(ctx)
 	unlocker := &unlocker{
@@ -21,12 +21,15 @@ const unlockCancelDelay = 1 * time.Minute
 // Lock is the in-repository representation of a repository lock file.
 // There are two types of locks: exclusive and non-exclusive. There may be many
 // different non-exclusive locks, but at most one exclusive lock, which can
-// only be acquired while no non-exclusive lock is held.
+// only be acquired while no non-exclusive lock is held. A non-exclusive lock
+// with Prune set is held by a concurrent prune, at most one such lock can
+// exist at a time.
 //
 // A lock must be refreshed regularly to not be considered stale.
 type Lock struct {
 	Time      time.Time `json:"time"`
 	Exclusive bool      `json:"exclusive"`
+	Prune     bool      `json:"prune,omitempty"`
 	Hostname  string    `json:"hostname"`
 	Username  string    `json:"username"`
 	PID       int       `json:"pid"`
@@ -50,6 +53,8 @@ func (e *alreadyLockedError) Error() string {
 	s := ""
 	if e.otherLock.Exclusive {
 		s = "exclusively "
+	} else if e.otherLock.Prune {
+		s = "for a concurrent prune "
 	}
 	return fmt.Sprintf("repository is already locked %sby %v", s, e.otherLock)
 }
@@ -100,11 +105,23 @@ func TestSetLockTimeout(t testing.TB, d time.Duration) {
 // that satisfies IsAlreadyLocked. If the new lock is exclusive, then other
 // non-exclusive locks also result in an IsAlreadyLocked error.
 func newLock(ctx context.Context, repo restic.Unpacked[restic.FileType], exclusive bool) (*lockHandle, error) {
+	return createNewLock(ctx, repo, Lock{Exclusive: exclusive})
+}
+
+// newPruneLock returns a new non-exclusive lock for a concurrent prune. In
+// addition to exclusive locks, the locks of other concurrent prunes result in
+// an IsAlreadyLocked error.
+func newPruneLock(ctx context.Context, repo restic.Unpacked[restic.FileType]) (*lockHandle, error) {
+	return createNewLock(ctx, repo, Lock{Prune: true})
+}
+
+func createNewLock(ctx context.Context, repo restic.Unpacked[restic.FileType], template Lock) (*lockHandle, error) {
 	lock := &lockHandle{
 		Lock: Lock{
 			Time:      time.Now(),
 			PID:       os.Getpid(),
-			Exclusive: exclusive,
+			Exclusive: template.Exclusive,
+			Prune:     template.Prune,
 		},
 		repo: repo,
 	}
@@ -155,7 +172,7 @@ func (l *lockHandle) fillUserInfo() error {
 // If an exclusive lock is to be created, checkForOtherLocks returns an error
 // if there are any other locks, regardless if exclusive or not. If a
 // non-exclusive lock is to be created, an error is only returned when an
-// exclusive lock is found.
+// exclusive lock is found, or for a prune lock when another prune lock is found.
 func (l *lockHandle) checkForOtherLocks(ctx context.Context) error {
 	var err error
 	checkedIDs := restic.NewIDSet()
@@ -183,7 +200,7 @@ func (l *lockHandle) checkForOtherLocks(ctx context.Context) error {
 				return err
 			}
 
-			if l.Exclusive || lock.Exclusive {
+			if l.Exclusive || lock.Exclusive || (l.Prune && lock.Prune) {
 				return &alreadyLockedError{otherLock: lock}
 			}
 
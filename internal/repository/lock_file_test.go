@@ -102,6 +102,30 @@ func TestLockOnExclusiveLockedRepo(t *testing.T) {
 	rtest.OK(t, elock.unlock(context.TODO()))
 }
 
+func TestPruneLock(t *testing.T) {
+	repo := TestRepository(t)
+	TestSetLockTimeout(t, 5*time.Millisecond)
+
+	plock, err := newPruneLock(context.TODO(), &internalRepository{repo})
+	rtest.OK(t, err)
+
+	// backups can run alongside a concurrent prune
+	lock, err := newLock(context.TODO(), &internalRepository{repo}, false)
+	rtest.OK(t, err)
+	rtest.OK(t, lock.unlock(context.TODO()))
+
+	for _, create := range []func() (*lockHandle, error){
+		func() (*lockHandle, error) { return newPruneLock(context.TODO(), &internalRepository{repo}) },
+		func() (*lockHandle, error) { return newLock(context.TODO(), &internalRepository{repo}, true) },
+	} {
+		lock, err := create()
+		rtest.Assert(t, IsAlreadyLocked(err), "expected lock conflict, got %v", err)
+		rtest.OK(t, lock.unlock(context.TODO()))
+	}
+
+	rtest.OK(t, plock.unlock(context.TODO()))
+}
+
 func TestExclusiveLockOnLockedRepo(t *testing.T) {
 	repo := TestRepository(t)
 	TestSetLockTimeout(t, 5*time.Millisecond)
@@ -0,0 +1,203 @@
+package pack
+
+import (
+	"github.com/restic/restic/internal/errors"
+)
+
+// This file implements a systematic Reed-Solomon erasure code over GF(2^8).
+// The data shards are stored unmodified, the parity shards are computed using
+// a Cauchy matrix. Every square submatrix of a Cauchy matrix is invertible,
+// thus the data can be reconstructed from any dataShards intact shards.
+
+// gfPoly is the irreducible polynomial x^8 + x^4 + x^3 + x^2 + 1 used to
+// construct GF(2^8).
+const gfPoly = 0x11d
+
+var (
+	gfExp [510]byte
+	gfLog [256]byte
+	// gfMulTable contains the products of all pairs of field elements
+	gfMulTable [256][256]byte
+)
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		gfExp[i] = byte(x)
+		gfExp[i+255] = byte(x)
+		gfLog[x] = byte(i)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= gfPoly
+		}
+	}
+
+	for a := 1; a < 256; a++ {
+		for b := 1; b < 256; b++ {
+			gfMulTable[a][b] = gfExp[int(gfLog[a])+int(gfLog[b])]
+		}
+	}
+}
+
+func gfMul(a, b byte) byte {
+	return gfMulTable[a][b]
+}
+
+func gfInv(a byte) byte {
+	if a == 0 {
+		panic("inverse of zero")
+	}
+	return gfExp[255-int(gfLog[a])]
+}
+
+// mulAddSlice computes dst[i] ^= c * src[i].
+func mulAddSlice(c byte, src, dst []byte) {
+	if c == 0 {
+		return
+	}
+	mt := &gfMulTable[c]
+	dst = dst[:len(src)]
+	for i, v := range src {
+		dst[i] ^= mt[v]
+	}
+}
+
+// erasureCode is a Reed-Solomon code with a fixed number of data and parity
+// shards.
+type erasureCode struct {
+	dataShards, parityShards int
+	// parity contains one row of coefficients for each parity shard
+	parity [][]byte
+}
+
+// maxShards is the maximum number of data and parity shards, the elements of
+// the Cauchy matrix must be distinct field elements.
+const maxShards = 256
+
+func newErasureCode(dataShards, parityShards int) (*erasureCode, error) {
+	if dataShards <= 0 || parityShards <= 0 || dataShards+parityShards > maxShards {
+		return nil, errors.Errorf("invalid number of shards %d/%d", parityShards, dataShards)
+	}
+
+	c := &erasureCode{dataShards: dataShards, parityShards: parityShards}
+	for j := 0; j < parityShards; j++ {
+		row := make([]byte, dataShards)
+		for i := range row {
+			// 1 / (x_j + y_i) with x_j = dataShards + j and y_i = i
+			row[i] = gfInv(byte(dataShards+j) ^ byte(i))
+		}
+		c.parity = append(c.parity, row)
+	}
+	return c, nil
+}
+
+// row returns the coefficients used to compute the given shard from the data
+// shards.
+func (c *erasureCode) row(shard int) []byte {
+	if shard >= c.dataShards {
+		return c.parity[shard-c.dataShards]
+	}
+	row := make([]byte, c.dataShards)
+	row[shard] = 1
+	return row
+}
+
+// encode computes the parity shards from the data shards. All shards must have
+// the same length.
+func (c *erasureCode) encode(data, parity [][]byte) {
+	for j, p := range parity {
+		clear(p)
+		for i, d := range data {
+			mulAddSlice(c.parity[j][i], d, p)
+		}
+	}
+}
+
+// reconstruct recomputes all data shards for which present is false. shards
+// contains the data shards followed by the parity shards, at least dataShards
+// of them must be present.
+func (c *erasureCode) reconstruct(shards [][]byte, present []bool) error {
+	var missing []int
+	for i := 0; i < c.dataShards; i++ {
+		if !present[i] {
+			missing = append(missing, i)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	// select the first dataShards intact shards
+	var rows []int
+	for i := range shards {
+		if present[i] {
+			rows = append(rows, i)
+			if len(rows) == c.dataShards {
+				break
+			}
+		}
+	}
+	if len(rows) < c.dataShards {
+		return errors.Errorf("too many damaged shards, %d of %d are intact", len(rows), c.dataShards)
+	}
+
+	m := make([][]byte, c.dataShards)
+	for i, shard := range rows {
+		m[i] = append([]byte(nil), c.row(shard)...)
+	}
+	inv, err := invertMatrix(m)
+	if err != nil {
+		return err
+	}
+
+	for _, i := range missing {
+		out := shards[i]
+		clear(out)
+		for k, shard := range rows {
+			mulAddSlice(inv[i][k], shards[shard], out)
+		}
+	}
+	return nil
+}
+
+// invertMatrix inverts the square matrix m using Gauss-Jordan elimination.
+// m is modified.
+func invertMatrix(m [][]byte) ([][]byte, error) {
+	n := len(m)
+	inv := make([][]byte, n)
+	for i := range inv {
+		inv[i] = make([]byte, n)
+		inv[i][i] = 1
+	}
+
+	for col := 0; col < n; col++ {
+		pivot := -1
+		for r := col; r < n; r++ {
+			if m[r][col] != 0 {
+				pivot = r
+				break
+			}
+		}
+		if pivot < 0 {
+			return nil, errors.New("matrix is singular")
+		}
+		m[col], m[pivot] = m[pivot], m[col]
+		inv[col], inv[pivot] = inv[pivot], inv[col]
+
+		f := gfInv(m[col][col])
+		for k := 0; k < n; k++ {
+			m[col][k] = gfMul(m[col][k], f)
+			inv[col][k] = gfMul(inv[col][k], f)
+		}
+
+		for r := 0; r < n; r++ {
+			if r == col || m[r][col] == 0 {
+				continue
+			}
+			f := m[r][col]
+			mulAddSlice(f, m[col], m[r])
+			mulAddSlice(f, inv[col], inv[r])
+		}
+	}
+	return inv, nil
+}
@@ -0,0 +1,73 @@
+package pack
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestGFInverse(t *testing.T) {
+	for a := 1; a < 256; a++ {
+		rtest.Equals(t, byte(1), gfMul(byte(a), gfInv(byte(a))))
+	}
+}
+
+func TestErasureCode(t *testing.T) {
+	rnd := rand.New(rand.NewSource(42))
+
+	for _, test := range []struct{ data, parity int }{
+		{1, 1}, {4, 2}, {10, 2}, {10, 4}, {17, 3}, {200, 55},
+	} {
+		code, err := newErasureCode(test.data, test.parity)
+		rtest.OK(t, err)
+
+		shards := make([][]byte, test.data+test.parity)
+		for i := range shards {
+			shards[i] = make([]byte, 100)
+			if i < test.data {
+				_, _ = rnd.Read(shards[i])
+			}
+		}
+		code.encode(shards[:test.data], shards[test.data:])
+
+		orig := make([][]byte, len(shards))
+		for i := range shards {
+			orig[i] = append([]byte(nil), shards[i]...)
+		}
+
+		// remove random shards, up to the number of parity shards
+		present := make([]bool, len(shards))
+		for i := range present {
+			present[i] = true
+		}
+		for _, i := range rnd.Perm(len(shards))[:test.parity] {
+			present[i] = false
+			clear(shards[i])
+		}
+
+		rtest.OK(t, code.reconstruct(shards, present))
+		for i := 0; i < test.data; i++ {
+			rtest.Assert(t, bytes.Equal(orig[i], shards[i]), "%d/%d: shard %d was not reconstructed", test.parity, test.data, i)
+		}
+
+		// one more missing shard cannot be reconstructed
+		for i := range present {
+			if present[i] {
+				present[i] = false
+				break
+			}
+		}
+		rtest.Assert(t, code.reconstruct(shards, present) != nil, "%d/%d: expected error", test.parity, test.data)
+	}
+}
+
+func TestErasureCodeInvalid(t *testing.T) {
+	for _, test := range []struct{ data, parity int }{
+		{0, 1}, {1, 0}, {200, 57},
+	} {
+		_, err := newErasureCode(test.data, test.parity)
+		rtest.Assert(t, err != nil, "expected error for %d/%d", test.parity, test.data)
+	}
+}
@@ -28,6 +28,9 @@ type Packer struct {
 	wr    io.Writer
 	err   error // packer is unusable after the first error
 
+	parity     *parityEncoder
+	parityInfo *Parity
+
 	m sync.Mutex
 }
 
@@ -36,6 +39,24 @@ func NewPacker(k *crypto.Key, wr io.Writer) *Packer {
 	return &Packer{k: k, wr: wr}
 }
 
+// SetParity configures the packer to write a parity section, which allows
+// reconstructing damaged blobs. It must be called before the first blob is
+// added. The ChunkSize of p is ignored.
+func (p *Packer) SetParity(par Parity) error {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	if len(p.blobs) > 0 {
+		return errors.New("parity must be configured before adding blobs")
+	}
+	enc, err := newParityEncoder(par)
+	if err != nil {
+		return err
+	}
+	p.parity = enc
+	return nil
+}
+
 // Add saves the data read from rd as a new blob to the packer. Returned is the
 // number of bytes written to the pack plus the pack header entry size.
 func (p *Packer) Add(t restic.BlobType, id restic.ID, data []byte, uncompressedLength int) (int, error) {
@@ -55,6 +76,9 @@ func (p *Packer) Add(t restic.BlobType, id restic.ID, data []byte, uncompressedL
 		p.err = errors.New("short write")
 		return n, p.err
 	}
+	if p.parity != nil {
+		p.parity.Write(data)
+	}
 
 	c := Blob{
 		BlobHandle:         restic.BlobHandle{Type: t, ID: id},
@@ -102,17 +126,38 @@ func (p *Packer) Finalize() error {
 		return err
 	}
 
+	var paritySection []byte
+	if p.parity != nil {
+		var entry []byte
+		paritySection, entry = p.parity.Finish()
+		header = append(header, entry...)
+		p.parityInfo = &p.parity.Parity
+	}
+
 	encryptedHeader := make([]byte, 0, crypto.CiphertextLength(len(header)))
 	nonce := crypto.NewRandomNonce()
 	encryptedHeader = append(encryptedHeader, nonce...)
 	encryptedHeader = p.k.Seal(encryptedHeader, nonce, header, nil)
 	encryptedHeader = binary.LittleEndian.AppendUint32(encryptedHeader, uint32(len(encryptedHeader)))
 
-	if err := verifyHeader(p.k, encryptedHeader, p.blobs); err != nil {
+	if err := verifyHeader(p.k, encryptedHeader, p.blobs, p.parityInfo); err != nil {
 		//nolint:revive,staticcheck // ignore linter warnings about error message spelling
 		return fmt.Errorf("Detected data corruption while writing pack-file header: %w\nCorrupted data is either caused by hardware issues or software bugs. Please open an issue at https://github.com/restic/restic/issues/new/choose for further troubleshooting.", err)
 	}
 
+	if len(paritySection) > 0 {
+		n, err := p.wr.Write(paritySection)
+		if err != nil {
+			p.err = errors.Wrap(err, "Write")
+			return p.err
+		}
+		if n != len(paritySection) {
+			p.err = errors.New("wrong number of bytes written")
+			return p.err
+		}
+		p.bytes += uint(len(paritySection))
+	}
+
 	// append the header
 	n, err := p.wr.Write(encryptedHeader)
 	if err != nil {
@@ -129,11 +174,11 @@ func (p *Packer) Finalize() error {
 	return nil
 }
 
-func verifyHeader(k *crypto.Key, header []byte, expected []Blob) error {
+func verifyHeader(k *crypto.Key, header []byte, expected []Blob, expectedParity *Parity) error {
 	// do not offer a way to skip the pack header verification, as pack headers are usually small enough
 	// to not result in a significant performance impact
 
-	decoded, hdrSize, err := List(k, bytes.NewReader(header), int64(len(header)))
+	decoded, parity, hdrSize, err := ListWithParity(k, bytes.NewReader(header), int64(len(header)))
 	if err != nil {
 		return fmt.Errorf("header decoding failed: %w", err)
 	}
@@ -148,12 +193,19 @@ func verifyHeader(k *crypto.Key, header []byte, expected []Blob) error {
 			return fmt.Errorf("pack header entry mismatch got %v instead of %v", decoded[i], expected[i])
 		}
 	}
+	if (parity == nil) != (expectedParity == nil) || (parity != nil && *parity != *expectedParity) {
+		return fmt.Errorf("pack header parity entry mismatch got %v instead of %v", parity, expectedParity)
+	}
 	return nil
 }
 
 // HeaderOverhead returns an estimate of the number of bytes written by a call to Finalize.
 func (p *Packer) HeaderOverhead() int {
-	return crypto.CiphertextLength(0) + binary.Size(uint32(0))
+	overhead := crypto.CiphertextLength(0) + binary.Size(uint32(0))
+	if p.parity != nil {
+		overhead += ParityEntrySize
+	}
+	return overhead
 }
 
 // makeHeader constructs the header for p.
@@ -228,7 +280,20 @@ func (p *Packer) Count() int {
 func (p *Packer) HeaderFull() bool {
 	p.m.Lock()
 	defer p.m.Unlock()
-	return headerSize+uint(len(p.blobs)+1)*entrySize > MaxHeaderSize
+	size := headerSize + uint(len(p.blobs)+1)*entrySize
+	if p.parity != nil {
+		size += ParityEntrySize
+	}
+	return size > MaxHeaderSize
+}
+
+// Parity returns the parity section written by Finalize, it is nil if the
+// pack file has no parity section.
+func (p *Packer) Parity() *Parity {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	return p.parityInfo
 }
 
 // Blobs returns the slice of blobs that have been written.
@@ -349,13 +414,18 @@ func (e InvalidFileError) Error() string {
 // List returns the list of entries found in a pack file and the length of the
 // header (including header size and crypto overhead)
 func List(k *crypto.Key, rd io.ReaderAt, size int64) (entries Blobs, hdrSize uint32, err error) {
+	entries, _, hdrSize, err = list(k, rd, size)
+	return entries, hdrSize, err
+}
+
+func list(k *crypto.Key, rd io.ReaderAt, size int64) (entries Blobs, parity *parityEntry, hdrSize uint32, err error) {
 	buf, err := readHeader(rd, size)
 	if err != nil {
-		return nil, 0, err
+		return nil, nil, 0, err
 	}
 
 	if len(buf) < crypto.CiphertextLength(0) {
-		return nil, 0, errors.New("invalid header, too short")
+		return nil, nil, 0, errors.New("invalid header, too short")
 	}
 
 	hdrSize = headerLengthSize + uint32(len(buf))
@@ -363,7 +433,7 @@ func List(k *crypto.Key, rd io.ReaderAt, size int64) (entries Blobs, hdrSize uin
 	nonce, buf := buf[:k.NonceSize()], buf[k.NonceSize():]
 	buf, err = k.Open(buf[:0], nonce, buf, nil)
 	if err != nil {
-		return nil, 0, err
+		return nil, nil, 0, err
 	}
 
 	// might over allocate a bit if all blobs have EntrySize but only by a few percent
@@ -371,9 +441,23 @@ func List(k *crypto.Key, rd io.ReaderAt, size int64) (entries Blobs, hdrSize uin
 
 	pos := uint(0)
 	for len(buf) > 0 {
+		if buf[0] == parityEntryType {
+			// the parity section is always described by the last entry
+			if len(buf) != ParityEntrySize {
+				return nil, nil, 0, errors.New("parity entry is not the last header entry")
+			}
+			pe, err := parseParityEntry(buf)
+			if err != nil {
+				return nil, nil, 0, err
+			}
+			pe.Offset = pos
+			parity = &pe
+			break
+		}
+
 		entry, headerSize, err := parseHeaderEntry(buf)
 		if err != nil {
-			return nil, 0, err
+			return nil, nil, 0, err
 		}
 		entry.Offset = pos
 
@@ -382,7 +466,7 @@ func List(k *crypto.Key, rd io.ReaderAt, size int64) (entries Blobs, hdrSize uin
 		buf = buf[headerSize:]
 	}
 
-	return entries, hdrSize, nil
+	return entries, parity, hdrSize, nil
 }
 
 func parseHeaderEntry(p []byte) (b Blob, size uint, err error) {
@@ -435,12 +519,25 @@ func CalculateHeaderSize(blobs Blobs) int {
 	return size
 }
 
+// ParityLister is implemented by indexes which know the parity sections of
+// pack files.
+type ParityLister interface {
+	ListParity(ctx context.Context, fn func(id restic.ID, parity Parity)) error
+}
+
 // Size returns the size of all packs computed by index information.
-// If onlyHdr is set to true, only the size of the header is returned
+// If onlyHdr is set to true, only the size of the header and of the parity
+// section is returned. The parity sections are only included if idx
+// implements ParityLister.
 // Note that this function only gives correct sizes, if there are no
 // duplicates in the index.
 func Size(ctx context.Context, idx restic.ListBlobser, onlyHdr bool) (map[restic.ID]int64, error) {
 	packSize := make(map[restic.ID]int64)
+	parityLister, hasParity := idx.(ParityLister)
+	var dataLength map[restic.ID]uint
+	if hasParity {
+		dataLength = make(map[restic.ID]uint)
+	}
 
 	err := idx.ListBlobs(ctx, func(blob restic.PackBlob) {
 		packID := blob.PackID()
@@ -451,8 +548,19 @@ func Size(ctx context.Context, idx restic.ListBlobser, onlyHdr bool) (map[restic
 		if !onlyHdr {
 			size += int64(blob.CiphertextLength())
 		}
+		if hasParity {
+			dataLength[packID] += uint(blob.CiphertextLength())
+		}
 		packSize[packID] = size + int64(CalculateEntrySize(blob.IsCompressed()))
 	})
+	if err != nil || !hasParity {
+		return packSize, err
+	}
 
+	err = parityLister.ListParity(ctx, func(id restic.ID, parity Parity) {
+		if size, ok := packSize[id]; ok {
+			packSize[id] = size + ParityEntrySize + int64(parity.SectionLength(dataLength[id]))
+		}
+	})
 	return packSize, err
 }
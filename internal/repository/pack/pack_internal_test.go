@@ -227,7 +227,7 @@ func TestUnpackedVerification(t *testing.T) {
 			encryptedHeader[len(encryptedHeader)-1] ^= 0x42
 		}
 
-		err = verifyHeader(k, encryptedHeader, blobs)
+		err = verifyHeader(k, encryptedHeader, blobs, nil)
 		if test.msg == "" {
 			rtest.Assert(t, err == nil, "expected no error, got %v", err)
 		} else {
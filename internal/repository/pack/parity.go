@@ -0,0 +1,330 @@
+package pack
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/repository/crypto"
+)
+
+// Pack files may contain a parity section between the last blob and the
+// header. The blob data is split into stripes of DataShards chunks of
+// ChunkSize bytes each, the last stripe is padded with zeros. For each stripe,
+// ParityShards parity chunks are computed using a Reed-Solomon code. This
+// allows reconstructing up to ParityShards damaged chunks per stripe.
+//
+// The parity section starts with a table of the CRC-32C checksums of all data
+// and parity chunks, which is used to locate damaged chunks, followed by the
+// parity chunks:
+//
+//	[checksums stripe 0] ... [checksums stripe n] [parity stripe 0] ... [parity stripe n]
+//
+// The section is described by the last entry of the pack header, which has
+// the type parityEntryType:
+//
+//	Type         uint8   // parityEntryType
+//	Length       uint32  // length of the parity section
+//	DataShards   uint8
+//	ParityShards uint8
+//	ChunkSize    uint32
+//	Checksum     [32]byte // SHA-256 of the checksum table
+
+const (
+	parityEntryType = 4
+	// ParityEntrySize is the size of the pack header entry for the parity section
+	ParityEntrySize = 1 + 4 + 1 + 1 + 4 + sha256.Size
+
+	// maxParityChunkSize is the chunk size used for pack files which are
+	// larger than DataShards * maxParityChunkSize.
+	maxParityChunkSize = 64 * 1024
+	// maxParityShards limits the number of data or parity shards such that
+	// they can be stored in a single byte.
+	maxParityShards = 255
+)
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// Parity describes the parity section of a pack file.
+type Parity struct {
+	DataShards   uint `json:"data_shards"`
+	ParityShards uint `json:"parity_shards"`
+	ChunkSize    uint `json:"chunk_size"`
+}
+
+func (p Parity) String() string {
+	return fmt.Sprintf("%d/%d", p.ParityShards, p.DataShards)
+}
+
+// ParseParity parses a parity configuration of the form "parity/data", e.g.
+// "2/10" adds two parity chunks for every ten data chunks.
+func ParseParity(s string) (Parity, error) {
+	par, data, ok := strings.Cut(s, "/")
+	if !ok {
+		return Parity{}, errors.Errorf("invalid parity %q, expected parity/data, e.g. 2/10", s)
+	}
+	parityShards, err := strconv.ParseUint(par, 10, 8)
+	if err != nil {
+		return Parity{}, errors.Errorf("invalid number of parity shards %q", par)
+	}
+	dataShards, err := strconv.ParseUint(data, 10, 8)
+	if err != nil {
+		return Parity{}, errors.Errorf("invalid number of data shards %q", data)
+	}
+
+	p := Parity{DataShards: uint(dataShards), ParityShards: uint(parityShards)}
+	if err := p.validate(); err != nil {
+		return Parity{}, err
+	}
+	return p, nil
+}
+
+func (p Parity) validate() error {
+	if p.DataShards == 0 || p.ParityShards == 0 {
+		return errors.New("the number of data and parity shards must be at least one")
+	}
+	if p.DataShards > maxParityShards || p.ParityShards > maxParityShards || p.DataShards+p.ParityShards > maxShards {
+		return errors.Errorf("the number of data and parity shards must not exceed %d", maxShards)
+	}
+	return nil
+}
+
+// stripes returns the number of stripes for dataLength bytes of blob data.
+func (p Parity) stripes(dataLength uint) uint {
+	stripeSize := p.DataShards * p.ChunkSize
+	return (dataLength + stripeSize - 1) / stripeSize
+}
+
+func (p Parity) checksumTableLength(dataLength uint) uint {
+	return p.stripes(dataLength) * (p.DataShards + p.ParityShards) * 4
+}
+
+// SectionLength returns the length of the parity section for dataLength bytes
+// of blob data.
+func (p Parity) SectionLength(dataLength uint) uint {
+	return p.checksumTableLength(dataLength) + p.stripes(dataLength)*p.ParityShards*p.ChunkSize
+}
+
+// parityEncoder computes the parity section while the blob data is written.
+type parityEncoder struct {
+	Parity
+	code *erasureCode
+
+	stripe    []byte
+	checksums []byte
+	parity    []byte
+	length    uint
+}
+
+func newParityEncoder(p Parity) (*parityEncoder, error) {
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	code, err := newErasureCode(int(p.DataShards), int(p.ParityShards))
+	if err != nil {
+		return nil, err
+	}
+	p.ChunkSize = maxParityChunkSize
+	return &parityEncoder{
+		Parity: p,
+		code:   code,
+		stripe: make([]byte, 0, p.DataShards*p.ChunkSize),
+	}, nil
+}
+
+// Write adds blob data.
+func (e *parityEncoder) Write(buf []byte) {
+	e.length += uint(len(buf))
+	for len(buf) > 0 {
+		n := min(len(buf), cap(e.stripe)-len(e.stripe))
+		e.stripe = append(e.stripe, buf[:n]...)
+		buf = buf[n:]
+		if len(e.stripe) == cap(e.stripe) {
+			e.encodeStripe()
+		}
+	}
+}
+
+func (e *parityEncoder) encodeStripe() {
+	chunks := make([][]byte, e.DataShards+e.ParityShards)
+	// pad the stripe with zeros
+	data := e.stripe[:cap(e.stripe)]
+	clear(data[len(e.stripe):])
+	for i := uint(0); i < e.DataShards; i++ {
+		chunks[i] = data[i*e.ChunkSize : (i+1)*e.ChunkSize]
+	}
+	start := uint(len(e.parity))
+	e.parity = append(e.parity, make([]byte, e.ParityShards*e.ChunkSize)...)
+	for j := uint(0); j < e.ParityShards; j++ {
+		offset := start + j*e.ChunkSize
+		chunks[e.DataShards+j] = e.parity[offset : offset+e.ChunkSize]
+	}
+	e.code.encode(chunks[:e.DataShards], chunks[e.DataShards:])
+
+	for _, chunk := range chunks {
+		e.checksums = binary.LittleEndian.AppendUint32(e.checksums, crc32.Checksum(chunk, crc32c))
+	}
+	e.stripe = e.stripe[:0]
+}
+
+// Finish encodes the remaining data and returns the parity section together
+// with the pack header entry.
+func (e *parityEncoder) Finish() (section []byte, entry []byte) {
+	if e.length == 0 {
+		return nil, nil
+	}
+	if e.length < e.DataShards*maxParityChunkSize {
+		// small pack files only use a single stripe with smaller chunks
+		e.ChunkSize = (e.length + e.DataShards - 1) / e.DataShards
+		e.stripe = e.stripe[: e.length : e.DataShards*e.ChunkSize]
+	}
+	if len(e.stripe) > 0 {
+		e.encodeStripe()
+	}
+
+	section = append(e.checksums, e.parity...)
+	return section, e.Parity.entry(uint(len(section)), sha256.Sum256(e.checksums))
+}
+
+func (p Parity) entry(sectionLength uint, checksum [sha256.Size]byte) []byte {
+	buf := make([]byte, 0, ParityEntrySize)
+	buf = append(buf, parityEntryType)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(sectionLength))
+	buf = append(buf, byte(p.DataShards), byte(p.ParityShards))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(p.ChunkSize))
+	return append(buf, checksum[:]...)
+}
+
+// parityEntry is the decoded pack header entry of a parity section.
+type parityEntry struct {
+	Parity
+	Offset   uint
+	Length   uint
+	Checksum [sha256.Size]byte
+}
+
+func parseParityEntry(p []byte) (e parityEntry, err error) {
+	if len(p) < ParityEntrySize {
+		return e, errors.Errorf("parseParityEntry: buffer of size %d too short", len(p))
+	}
+	e.Length = uint(binary.LittleEndian.Uint32(p[1:5]))
+	e.DataShards = uint(p[5])
+	e.ParityShards = uint(p[6])
+	e.ChunkSize = uint(binary.LittleEndian.Uint32(p[7:11]))
+	copy(e.Checksum[:], p[11:ParityEntrySize])
+
+	if err := e.validate(); err != nil {
+		return e, err
+	}
+	if e.ChunkSize == 0 {
+		return e, errors.New("invalid parity chunk size zero")
+	}
+	return e, nil
+}
+
+// ListWithParity works like List, but additionally returns the parity section
+// of the pack file. The parity is nil if the pack file has no parity section.
+func ListWithParity(k *crypto.Key, rd io.ReaderAt, size int64) (entries Blobs, parity *Parity, hdrSize uint32, err error) {
+	entries, pe, hdrSize, err := list(k, rd, size)
+	if err != nil || pe == nil {
+		return entries, nil, hdrSize, err
+	}
+	return entries, &pe.Parity, hdrSize, nil
+}
+
+// ReconstructResult describes the result of reconstructing a pack file.
+type ReconstructResult struct {
+	// DamagedChunks is the number of data chunks which were reconstructed.
+	DamagedChunks int
+	// DamagedParity is the number of damaged parity chunks.
+	DamagedParity int
+}
+
+// Reconstruct repairs damaged blob data of the pack file in buf using the
+// parity section of the pack file. buf is modified in place. An error is
+// returned if the pack file has no parity section or if a stripe contains more
+// damaged chunks than parity chunks. The reconstructed blobs must be verified
+// by the caller.
+func Reconstruct(k *crypto.Key, buf []byte) (ReconstructResult, error) {
+	var res ReconstructResult
+
+	entries, pe, _, err := list(k, bytes.NewReader(buf), int64(len(buf)))
+	if err != nil {
+		return res, err
+	}
+	if pe == nil {
+		return res, errors.New("pack file has no parity section")
+	}
+
+	dataLength := pe.Offset
+	for _, entry := range entries {
+		if entry.Offset+entry.Length > dataLength {
+			return res, errors.New("blob overlaps parity section")
+		}
+	}
+	if pe.Length != pe.SectionLength(dataLength) || pe.Offset+pe.Length > uint(len(buf)) {
+		return res, errors.Errorf("parity section has invalid length %d", pe.Length)
+	}
+
+	section := buf[pe.Offset : pe.Offset+pe.Length]
+	tableLength := pe.checksumTableLength(dataLength)
+	checksums, parity := section[:tableLength], section[tableLength:]
+	if sha256.Sum256(checksums) != pe.Checksum {
+		return res, errors.New("checksums of the parity section are damaged")
+	}
+
+	code, err := newErasureCode(int(pe.DataShards), int(pe.ParityShards))
+	if err != nil {
+		return res, err
+	}
+
+	shards := int(pe.DataShards + pe.ParityShards)
+	stripeSize := pe.DataShards * pe.ChunkSize
+	chunks := make([][]byte, shards)
+	present := make([]bool, shards)
+	for s := uint(0); s < pe.stripes(dataLength); s++ {
+		// copy the data of the stripe to handle the zero padding of the last stripe
+		start := s * stripeSize
+		end := min(start+stripeSize, dataLength)
+		stripe := make([]byte, stripeSize)
+		copy(stripe, buf[start:end])
+
+		damaged := 0
+		for i := 0; i < shards; i++ {
+			if uint(i) < pe.DataShards {
+				chunks[i] = stripe[uint(i)*pe.ChunkSize : uint(i+1)*pe.ChunkSize]
+			} else {
+				offset := (s*pe.ParityShards + uint(i) - pe.DataShards) * pe.ChunkSize
+				chunks[i] = parity[offset : offset+pe.ChunkSize]
+			}
+			checksum := binary.LittleEndian.Uint32(checksums[(s*uint(shards)+uint(i))*4:])
+			present[i] = crc32.Checksum(chunks[i], crc32c) == checksum
+			if !present[i] {
+				damaged++
+				if uint(i) < pe.DataShards {
+					res.DamagedChunks++
+				} else {
+					res.DamagedParity++
+				}
+			}
+		}
+		if damaged == 0 {
+			continue
+		}
+		debug.Log("stripe %d contains %d damaged chunks", s, damaged)
+
+		if err := code.reconstruct(chunks, present); err != nil {
+			return res, fmt.Errorf("stripe %d: %w", s, err)
+		}
+		copy(buf[start:end], stripe)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,149 @@
+package pack_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/restic/restic/internal/repository/crypto"
+	"github.com/restic/restic/internal/repository/pack"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func newParityPack(t testing.TB, k *crypto.Key, lengths []int, parity pack.Parity) ([]Buf, []byte) {
+	bufs := createBuffers(t, lengths)
+
+	var buf bytes.Buffer
+	p := pack.NewPacker(k, &buf)
+	rtest.OK(t, p.SetParity(parity))
+	for _, b := range bufs {
+		_, err := p.Add(restic.DataBlob, b.id, b.data, 0)
+		rtest.OK(t, err)
+	}
+	rtest.OK(t, p.Finalize())
+	rtest.Equals(t, uint(buf.Len()), p.Size())
+
+	return bufs, buf.Bytes()
+}
+
+func TestParseParity(t *testing.T) {
+	for _, test := range []struct {
+		s      string
+		parity pack.Parity
+		err    bool
+	}{
+		{"2/10", pack.Parity{DataShards: 10, ParityShards: 2}, false},
+		{"1/1", pack.Parity{DataShards: 1, ParityShards: 1}, false},
+		{"16/200", pack.Parity{DataShards: 200, ParityShards: 16}, false},
+		{"", pack.Parity{}, true},
+		{"2", pack.Parity{}, true},
+		{"0/10", pack.Parity{}, true},
+		{"2/0", pack.Parity{}, true},
+		{"x/10", pack.Parity{}, true},
+		{"2/256", pack.Parity{}, true},
+		{"100/200", pack.Parity{}, true},
+	} {
+		t.Run(test.s, func(t *testing.T) {
+			parity, err := pack.ParseParity(test.s)
+			if test.err {
+				rtest.Assert(t, err != nil, "expected error for %q", test.s)
+				return
+			}
+			rtest.OK(t, err)
+			rtest.Equals(t, test.parity, parity)
+			rtest.Equals(t, test.s, parity.String())
+		})
+	}
+}
+
+func TestParityPack(t *testing.T) {
+	k := crypto.NewRandomKey()
+
+	for _, test := range []struct {
+		name    string
+		lengths []int
+	}{
+		{"small", testLens},
+		{"single blob", []int{23}},
+		{"multiple stripes", []int{300000, 500000, 123457}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			bufs, packData := newParityPack(t, k, test.lengths, pack.Parity{DataShards: 4, ParityShards: 2})
+
+			entries, parity, _, err := pack.ListWithParity(k, bytes.NewReader(packData), int64(len(packData)))
+			rtest.OK(t, err)
+			rtest.Equals(t, len(bufs), len(entries))
+			rtest.Assert(t, parity != nil, "expected parity section")
+			rtest.Equals(t, uint(4), parity.DataShards)
+			rtest.Equals(t, uint(2), parity.ParityShards)
+
+			// List must ignore the parity section
+			listEntries, _, err := pack.List(k, bytes.NewReader(packData), int64(len(packData)))
+			rtest.OK(t, err)
+			rtest.Equals(t, entries, listEntries)
+
+			dataLength := uint(0)
+			for _, e := range entries {
+				dataLength += e.Length
+			}
+			expected := dataLength + parity.SectionLength(dataLength) + uint(pack.CalculateHeaderSize(entries)) + pack.ParityEntrySize
+			rtest.Equals(t, expected, uint(len(packData)))
+
+			// an intact pack file needs no reconstruction
+			res, err := pack.Reconstruct(k, packData)
+			rtest.OK(t, err)
+			rtest.Equals(t, pack.ReconstructResult{}, res)
+		})
+	}
+}
+
+func TestParityReconstruct(t *testing.T) {
+	k := crypto.NewRandomKey()
+	_, packData := newParityPack(t, k, []int{300000, 500000, 123457}, pack.Parity{DataShards: 4, ParityShards: 2})
+
+	entries, _, _, err := pack.ListWithParity(k, bytes.NewReader(packData), int64(len(packData)))
+	rtest.OK(t, err)
+	dataLength := 0
+	for _, e := range entries {
+		dataLength += int(e.Length)
+	}
+
+	// damage two chunks of the first stripe and one chunk of the last stripe
+	damaged := append([]byte(nil), packData...)
+	damaged[10] ^= 0xff
+	damaged[70000] ^= 0xff
+	damaged[dataLength-1] ^= 0xff
+
+	res, err := pack.Reconstruct(k, damaged)
+	rtest.OK(t, err)
+	rtest.Equals(t, 3, res.DamagedChunks)
+	rtest.Equals(t, 0, res.DamagedParity)
+	rtest.Assert(t, bytes.Equal(packData, damaged), "reconstructed pack file differs from the original")
+
+	// three damaged chunks in a single stripe exceed the parity
+	damaged = append([]byte(nil), packData...)
+	damaged[10] ^= 0xff
+	damaged[70000] ^= 0xff
+	damaged[140000] ^= 0xff
+	_, err = pack.Reconstruct(k, damaged)
+	rtest.Assert(t, err != nil, "expected error for too many damaged chunks")
+
+	// damaged parity chunks are reported but do not affect the data
+	damaged = append([]byte(nil), packData...)
+	damaged[len(packData)-pack.CalculateHeaderSize(entries)-pack.ParityEntrySize-1] ^= 0xff
+	res, err = pack.Reconstruct(k, damaged)
+	rtest.OK(t, err)
+	rtest.Equals(t, pack.ReconstructResult{DamagedParity: 1}, res)
+}
+
+func TestReconstructWithoutParity(t *testing.T) {
+	k := crypto.NewRandomKey()
+	_, packData, _ := newPack(t, k, testLens)
+
+	_, err := pack.Reconstruct(k, packData)
+	rtest.Assert(t, err != nil, "expected error for pack file without parity")
+
+	_, parity, _, err := pack.ListWithParity(k, bytes.NewReader(packData), int64(len(packData)))
+	rtest.OK(t, err)
+	rtest.Assert(t, parity == nil, "unexpected parity section")
+}
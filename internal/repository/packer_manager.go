@@ -37,6 +37,8 @@ type packerManager struct {
 	pm       sync.Mutex
 	packers  []*packer
 	packSize uint
+	// parity is added to all new packs if set
+	parity *pack.Parity
 }
 
 const defaultPackerCount = 2
@@ -208,6 +210,12 @@ func (r *packerManager) newPacker() (pck *packer, err error) {
 
 	bufWr := bufio.NewWriter(tmpfile)
 	p := pack.NewPacker(r.key, bufWr)
+	if r.parity != nil {
+		if err := p.SetParity(*r.parity); err != nil {
+			_ = tmpfile.Close()
+			return nil, err
+		}
+	}
 	pck = &packer{
 		Packer:  p,
 		tmpfile: tmpfile,
@@ -274,5 +282,5 @@ func (r *Repository) savePacker(ctx context.Context, t restic.BlobType, p *packe
 
 	// update blobs in the index
 	debug.Log("  updating blobs %v to pack %v", p.Packer.Blobs(), id)
-	return r.idx.StorePack(ctx, id, p.Packer.Blobs(), &internalRepository{r})
+	return r.idx.StorePackWithParity(ctx, id, p.Packer.Blobs(), p.Packer.Parity(), &internalRepository{r})
 }
@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/options"
+	"github.com/restic/restic/internal/repository/pack"
+	"github.com/restic/restic/internal/restic"
+)
+
+// ExtendedOptions are the repository options which can be set using
+// "-o repo.<name>=<value>".
+type ExtendedOptions struct {
+	Parity string `option:"parity" help:"add Reed-Solomon parity to new pack files as parity/data chunks, e.g. 2/10 (default: disabled)"`
+}
+
+func init() {
+	options.Register("repo", ExtendedOptions{})
+}
+
+// ApplyExtendedOptions sets the repository options configured using
+// "-o repo.<name>=<value>".
+func (opts *Options) ApplyExtendedOptions(o options.Options) error {
+	var ext ExtendedOptions
+	if err := o.Extract("repo").Apply("repo", &ext); err != nil {
+		return err
+	}
+
+	if ext.Parity != "" {
+		parity, err := pack.ParseParity(ext.Parity)
+		if err != nil {
+			return errors.Fatalf("invalid repo.parity: %v", err)
+		}
+		opts.Parity = parity
+	}
+	return nil
+}
+
+// reconstructPack loads the pack file and repairs damaged blob data using the
+// parity section of the pack file. The last reconstructed pack file is kept in
+// memory, as usually several blobs of a damaged pack file are requested.
+func (r *Repository) reconstructPack(ctx context.Context, id restic.ID) ([]byte, pack.ReconstructResult, error) {
+	r.parityMu.Lock()
+	defer r.parityMu.Unlock()
+
+	if r.parityPack != nil && r.parityPackID == id {
+		return r.parityPack, r.parityResult, nil
+	}
+
+	buf, err := r.LoadRaw(ctx, restic.PackFile, id)
+	if err != nil && !errors.Is(err, restic.ErrInvalidData) {
+		return nil, pack.ReconstructResult{}, err
+	}
+
+	res, err := pack.Reconstruct(r.key, buf)
+	if err != nil {
+		return nil, res, fmt.Errorf("reconstructing pack %v failed: %w", id.Str(), err)
+	}
+	debug.Log("reconstructed pack %v: %d damaged data chunks, %d damaged parity chunks", id, res.DamagedChunks, res.DamagedParity)
+
+	r.parityPackID, r.parityPack, r.parityResult = id, buf, res
+	return buf, res, nil
+}
+
+// loadBlobFromParity loads a blob from a pack file which was reconstructed
+// using its parity section. Only pack files with a parity section are used.
+func (r *Repository) loadBlobFromParity(ctx context.Context, blobs []*pack.PackedBlob, buf []byte) ([]byte, error) {
+	var lastError error
+	for _, blob := range blobs {
+		packID := blob.PackID()
+		if _, ok := r.idx.Parity(packID); !ok {
+			continue
+		}
+
+		data, _, err := r.reconstructPack(ctx, packID)
+		if err != nil {
+			lastError = err
+			continue
+		}
+		start, end := blob.Blob.Offset, blob.Blob.Offset+blob.Blob.Length
+		if end > uint(len(data)) {
+			lastError = errors.Errorf("blob %v exceeds pack %v", blob.Blob.ID.Str(), packID.Str())
+			continue
+		}
+
+		// decryption modifies the buffer, thus copy the blob
+		if cap(buf) < int(blob.Blob.Length) {
+			buf = make([]byte, blob.Blob.Length)
+		}
+		buf = buf[:blob.Blob.Length]
+		copy(buf, data[start:end])
+
+		it := newPackBlobIterator(packID, newByteReader(buf), start, pack.Blobs{blob.Blob}, r.key, r.getZstdDecoder())
+		pbv, err := it.Next()
+		if err == nil {
+			err = pbv.Err
+		}
+		if err != nil {
+			lastError = err
+			continue
+		}
+
+		debug.Log("blob %v reconstructed using the parity of pack %v", blob.Blob.ID, packID)
+		plaintext := pbv.Plaintext
+		if len(plaintext) > cap(buf) {
+			return plaintext, nil
+		}
+		buf = buf[:len(plaintext)]
+		copy(buf, plaintext)
+		return buf, nil
+	}
+
+	if lastError == nil {
+		lastError = errors.New("no pack file containing the blob has a parity section")
+	}
+	return nil, lastError
+}
+
+// checkParity verifies that all blobs of the pack file can be reconstructed
+// using its parity section.
+func (r *Repository) checkParity(ctx context.Context, id restic.ID, blobs pack.Blobs) (pack.ReconstructResult, error) {
+	data, res, err := r.reconstructPack(ctx, id)
+	if err != nil {
+		return res, err
+	}
+
+	// decryption modifies the buffer
+	buf := make([]byte, len(data))
+	copy(buf, data)
+
+	blobs = append(pack.Blobs(nil), blobs...)
+	blobs.Sort()
+	it := newPackBlobIterator(id, newByteReader(buf), 0, blobs, r.key, r.getZstdDecoder())
+	for {
+		val, err := it.Next()
+		if err == errPackEOF {
+			return res, nil
+		} else if err != nil {
+			return res, err
+		}
+		if val.Err != nil {
+			return res, fmt.Errorf("blob %v: %w", val.Handle.ID.Str(), val.Err)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/restic/restic/internal/backend"
+	backendtest "github.com/restic/restic/internal/backend/test"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/options"
+	"github.com/restic/restic/internal/repository/pack"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestApplyExtendedOptions(t *testing.T) {
+	var opts Options
+	rtest.OK(t, opts.ApplyExtendedOptions(options.Options{"repo.parity": "2/10"}))
+	rtest.Equals(t, pack.Parity{DataShards: 10, ParityShards: 2}, opts.Parity)
+
+	opts = Options{}
+	rtest.OK(t, opts.ApplyExtendedOptions(options.Options{"s3.region": "foo"}))
+	rtest.Equals(t, pack.Parity{}, opts.Parity)
+
+	rtest.Assert(t, opts.ApplyExtendedOptions(options.Options{"repo.parity": "10"}) != nil, "expected error for invalid parity")
+	rtest.Assert(t, opts.ApplyExtendedOptions(options.Options{"repo.foo": "bar"}) != nil, "expected error for unknown option")
+}
+
+// saveParityBlobs stores random blobs in a single pack file with a parity
+// section and damages the first bytes of the pack file.
+func saveParityBlobs(t *testing.T) (*Repository, restic.ID, map[restic.ID][]byte) {
+	repo, be := TestRepositoryWithBackend(t, nil, 3, Options{Parity: pack.Parity{DataShards: 4, ParityShards: 1}})
+
+	blobs := make(map[restic.ID][]byte)
+	rtest.OK(t, repo.WithBlobUploader(context.TODO(), func(ctx context.Context, uploader restic.BlobSaverWithAsync) error {
+		for i := 0; i < 5; i++ {
+			buf := rtest.Random(23+i, 10000)
+			id, _, _, err := uploader.SaveBlob(ctx, restic.DataBlob, buf, restic.ID{}, false)
+			if err != nil {
+				return err
+			}
+			blobs[id] = buf
+		}
+		return nil
+	}))
+
+	var packID restic.ID
+	for id := range blobs {
+		packID = repo.LookupBlob(restic.BlobHandle{Type: restic.DataBlob, ID: id})[0].PackID()
+	}
+	parity, ok := repo.idx.Parity(packID)
+	rtest.Assert(t, ok, "pack %v has no parity", packID)
+	rtest.Equals(t, uint(4), parity.DataShards)
+
+	h := backend.Handle{Type: backend.PackFile, Name: packID.String()}
+	buf, err := backendtest.LoadAll(context.TODO(), be, h)
+	rtest.OK(t, err)
+	buf[0] ^= 0xff
+	buf[1] ^= 0xff
+	rtest.OK(t, be.Remove(context.TODO(), h))
+	rtest.OK(t, be.Save(context.TODO(), h, backend.NewByteReader(buf, be.Hasher())))
+
+	return repo, packID, blobs
+}
+
+func TestParityRequiresRepoV3(t *testing.T) {
+	repo, _ := TestRepositoryWithBackend(t, nil, 2, Options{Parity: pack.Parity{DataShards: 4, ParityShards: 1}})
+	err := repo.WithBlobUploader(context.TODO(), func(_ context.Context, _ restic.BlobSaverWithAsync) error {
+		return nil
+	})
+	rtest.Assert(t, err != nil, "expected error for parity in repository version 2")
+}
+
+func TestLoadBlobFromParity(t *testing.T) {
+	repo, _, blobs := saveParityBlobs(t)
+
+	for id, expected := range blobs {
+		buf, err := repo.LoadBlob(context.TODO(), restic.BlobHandle{Type: restic.DataBlob, ID: id}, nil)
+		rtest.OK(t, err)
+		rtest.Assert(t, bytes.Equal(expected, buf), "data mismatch for blob %v", id)
+	}
+}
+
+func TestCheckParity(t *testing.T) {
+	repo, packID, _ := saveParityBlobs(t)
+
+	chkr := newChecker(repo)
+	errs := runReadPacks(chkr)
+	rtest.Equals(t, 1, len(errs))
+
+	var packErr *ErrPackData
+	rtest.Assert(t, errors.As(errs[0], &packErr), "expected ErrPackData, got: %T %v", errs[0], errs[0])
+	rtest.Equals(t, packID, packErr.PackID)
+	rtest.Assert(t, packErr.Reconstructable, "expected pack to be reconstructable: %v", packErr)
+
+	// repair packs heals all blobs using the parity
+	damaged, err := RepairPacks(context.TODO(), repo, restic.NewIDSet(packID), restic.NewNoopPrinter())
+	rtest.OK(t, err)
+	rtest.Equals(t, 0, len(damaged))
+
+	errs = runReadPacks(newChecker(repo))
+	rtest.Equals(t, 0, len(errs))
+}
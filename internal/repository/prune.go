@@ -7,7 +7,10 @@ import (
 	"math"
 	"slices"
 	"sort"
+	"strings"
+	"time"
 
+	"github.com/restic/restic/internal/backend"
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/repository/index"
@@ -30,6 +33,23 @@ type PruneOptions struct {
 
 	RepackCacheableOnly bool
 	RepackUncompressed  bool
+
+	// AvoidStorageClasses lists storage classes which are expensive to read
+	// from, compared case-insensitively to backend.FileInfo.StorageClass. Packs
+	// in these classes are only repacked if necessary to reach MaxUnusedBytes.
+	AvoidStorageClasses []string
+
+	// RepackDeadline stops repacking once it is reached. Packs which were not
+	// repacked yet are kept and can be repacked by a later prune run. There is
+	// no deadline if it is zero.
+	RepackDeadline time.Time
+
+	// Concurrent allows backups to run while pruning. Unused packs are only
+	// marked for deletion and are removed by a later prune run once
+	// GracePeriod has passed. Unindexed packs are also only removed after
+	// the grace period.
+	Concurrent  bool
+	GracePeriod time.Duration
 }
 
 type PruneStats struct {
@@ -50,6 +70,11 @@ type PruneStats struct {
 		Duplicate    uint64 `json:"duplicate"`
 		Unused       uint64 `json:"unused"`
 		Unref        uint64 `json:"unreferenced"`
+		UnrefLocked  uint64 `json:"unreferenced_locked"`
+		UnrefRecent  uint64 `json:"unreferenced_recent"`
+		Pending      uint64 `json:"pending"`
+		PendingRm    uint64 `json:"pending_remove"`
+		PendingKeep  uint64 `json:"pending_restore"`
 		Uncompressed uint64 `json:"uncompressed"`
 		Total        uint64 `json:"total"`
 		Repack       uint64 `json:"repack"`
@@ -58,26 +83,38 @@ type PruneStats struct {
 		RemoveTotal  uint64 `json:"remove_total"`
 		Remain       uint64 `json:"remaining"`
 		RemainUnused uint64 `json:"remaining_unused"`
+		Retrieve     uint64 `json:"retrieve"`
 	} `json:"bytes"`
 	Packs struct {
 		Used        uint `json:"used"`
 		Unused      uint `json:"unused"`
 		PartlyUsed  uint `json:"partly_used"`
 		Unref       uint `json:"unreferenced"`
+		UnrefLocked uint `json:"unreferenced_locked"`
+		UnrefRecent uint `json:"unreferenced_recent"`
+		Pending     uint `json:"pending"`
+		PendingRm   uint `json:"pending_remove"`
+		PendingKeep uint `json:"pending_restore"`
+		Locked      uint `json:"locked"`
 		Total       uint `json:"total"`
 		Keep        uint `json:"keep"`
 		Repack      uint `json:"repack"`
 		Remove      uint `json:"remove"`
 		RemoveTotal uint `json:"remove_total"`
+		Retrieve    uint `json:"retrieve"`
 	} `json:"packfiles"`
 }
 
 type PrunePlan struct {
 	removePacksFirst restic.IDSet                // packs to remove first (unreferenced packs)
 	repackPacks      restic.IDSet                // packs to repack
+	repackOrder      restic.IDs                  // packs to repack, sorted by priority
 	keepBlobs        *index.AssociatedSet[uint8] // blobs to keep during repacking
-	removePacks      restic.IDSet                // packs to remove
+	removePacks      restic.IDSet                // packs to remove, or to mark for deletion in concurrent mode
+	sweepPacks       restic.IDSet                // packs marked for deletion which are removed
+	restorePacks     restic.IDSet                // packs marked for deletion which are still needed
 	ignorePacks      restic.IDSet                // packs to ignore when rebuilding the index
+	packSizes        map[restic.ID]int64         // sizes of the packs which are removed or repacked
 
 	repo  *Repository
 	stats PruneStats
@@ -99,6 +136,9 @@ type packInfoWithID struct {
 	ID restic.ID
 	packInfo
 	mustCompress bool
+	// avoid is set if the pack is stored in a storage class which is
+	// expensive to read from
+	avoid bool
 }
 
 // PlanPrune selects which files to rewrite and which to delete and which blobs to keep.
@@ -119,6 +159,12 @@ func PlanPrune(ctx context.Context, opts PruneOptions, repo *Repository, getUsed
 	if opts.SmallPackBytes > uint64(repo.PackSize()) {
 		return nil, fmt.Errorf("repack-smaller-than exceeds repository packsize")
 	}
+	if opts.Concurrent && repo.Config().Version < 3 {
+		return nil, fmt.Errorf("concurrent prune requires at least repository format version 3")
+	}
+	if opts.Concurrent && opts.UnsafeRecovery {
+		return nil, fmt.Errorf("concurrent prune cannot be used to recover a repository")
+	}
 
 	usedBlobs := index.NewAssociatedSet[uint8](repo.idx)
 	err := getUsedBlobs(ctx, repo, usedBlobs)
@@ -126,6 +172,13 @@ func PlanPrune(ctx context.Context, opts PruneOptions, repo *Repository, getUsed
 		return nil, err
 	}
 
+	pending := repo.idx.PacksToDelete()
+	restorePacks := findPacksToRestore(repo, usedBlobs, pending)
+	for id := range restorePacks {
+		printer.V("will restore pack %v marked for deletion as it is still needed", id.Str())
+		delete(pending, id)
+	}
+
 	printer.P("searching used packs...\n")
 	keepBlobs, indexPack, err := packInfoFromIndex(ctx, repo, usedBlobs, &stats, printer)
 	if err != nil {
@@ -133,7 +186,7 @@ func PlanPrune(ctx context.Context, opts PruneOptions, repo *Repository, getUsed
 	}
 
 	printer.P("collecting packs for deletion and repacking\n")
-	plan, err := decidePackAction(ctx, opts, repo, indexPack, &stats, printer)
+	plan, err := decidePackAction(ctx, opts, repo, indexPack, pending, restorePacks, &stats, printer)
 	if err != nil {
 		return nil, err
 	}
@@ -161,12 +214,14 @@ func PlanPrune(ctx context.Context, opts PruneOptions, repo *Repository, getUsed
 	stats.Blobs.Total = stats.Blobs.Used + stats.Blobs.Unused + stats.Blobs.Duplicate
 	stats.Blobs.RemoveTotal = stats.Blobs.Remove + stats.Blobs.Repackrm
 	stats.Blobs.Remain = stats.Blobs.Total - stats.Blobs.RemoveTotal
-	stats.Size.Total = stats.Size.Used + stats.Size.Duplicate + stats.Size.Unused + stats.Size.Unref
-	stats.Size.RemoveTotal = stats.Size.Remove + stats.Size.Repackrm + stats.Size.Unref
+	stats.Size.Total = stats.Size.Used + stats.Size.Duplicate + stats.Size.Unused + stats.Size.Unref + stats.Size.UnrefLocked +
+		stats.Size.UnrefRecent + stats.Size.Pending + stats.Size.PendingRm + stats.Size.PendingKeep
+	stats.Size.RemoveTotal = stats.Size.Remove + stats.Size.Repackrm + stats.Size.Unref + stats.Size.PendingRm
 	stats.Size.Remain = stats.Size.Total - stats.Size.RemoveTotal
 	stats.Size.RemainUnused = stats.Size.Duplicate + stats.Size.Unused - stats.Size.Remove - stats.Size.Repackrm
-	stats.Packs.Total = stats.Packs.Used + stats.Packs.PartlyUsed + stats.Packs.Unused + stats.Packs.Unref
-	stats.Packs.RemoveTotal = stats.Packs.Unref + stats.Packs.Remove
+	stats.Packs.Total = stats.Packs.Used + stats.Packs.PartlyUsed + stats.Packs.Unused + stats.Packs.Unref + stats.Packs.UnrefLocked +
+		stats.Packs.UnrefRecent + stats.Packs.Pending + stats.Packs.PendingRm + stats.Packs.PendingKeep
+	stats.Packs.RemoveTotal = stats.Packs.Unref + stats.Packs.Remove + stats.Packs.PendingRm
 
 	plan.repo = repo
 	plan.stats = stats
@@ -175,6 +230,42 @@ func PlanPrune(ctx context.Context, opts PruneOptions, repo *Repository, getUsed
 	return &plan, nil
 }
 
+// findPacksToRestore returns the packs marked for deletion which contain a
+// used blob that is missing from the regular index, for example as it was
+// added by a backup which ran concurrently to the prune run that marked the
+// pack. The used blobs which are only contained in such packs are removed from
+// usedBlobs as they are kept anyways. Packs which are also contained in the
+// regular index are removed from pending and handled like regular packs.
+func findPacksToRestore(repo *Repository, usedBlobs *index.AssociatedSet[uint8], pending map[restic.ID]index.PackBlobs) restic.IDSet {
+	restorePacks := restic.NewIDSet()
+	if len(pending) == 0 {
+		return restorePacks
+	}
+
+	indexedPacks := repo.idx.Packs(restic.NewIDSet())
+	for id, pbs := range pending {
+		if indexedPacks.Has(id) {
+			delete(pending, id)
+			continue
+		}
+		for _, blob := range pbs.Blobs {
+			if usedBlobs.Has(blob.BlobHandle) && !repo.idx.Has(blob.BlobHandle) {
+				restorePacks.Insert(id)
+				break
+			}
+		}
+	}
+
+	for id := range restorePacks {
+		for _, blob := range pending[id].Blobs {
+			if !repo.idx.Has(blob.BlobHandle) {
+				usedBlobs.Delete(blob.BlobHandle)
+			}
+		}
+	}
+	return restorePacks
+}
+
 func packInfoFromIndex(ctx context.Context, idx restic.ListBlobser, usedBlobs *index.AssociatedSet[uint8], stats *PruneStats, printer restic.Printer) (*index.AssociatedSet[uint8], map[restic.ID]packInfo, error) {
 	// iterate over all blobs in index to find out which blobs are duplicates
 	// The counter in usedBlobs describes how many instances of the blob exist in the repository index
@@ -383,25 +474,95 @@ func calculateTargetPacksize(opts PruneOptions, indexPack map[restic.ID]packInfo
 	return targetPackSize
 }
 
-func decidePackAction(ctx context.Context, opts PruneOptions, repo *Repository, indexPack map[restic.ID]packInfo, stats *PruneStats, printer restic.Printer) (PrunePlan, error) {
+func decidePackAction(ctx context.Context, opts PruneOptions, repo *Repository, indexPack map[restic.ID]packInfo, pending map[restic.ID]index.PackBlobs, restorePacks restic.IDSet, stats *PruneStats, printer restic.Printer) (PrunePlan, error) {
 	removePacksFirst := restic.NewIDSet()
 	removePacks := restic.NewIDSet()
+	sweepPacks := restic.NewIDSet()
+	missingRestorePacks := restorePacks.Clone()
 	repackPacks := restic.NewIDSet()
+	packSizes := make(map[restic.ID]int64)
 
 	var repackCandidates []packInfoWithID
 	var repackSmallCandidates []packInfoWithID
 	repoVersion := repo.Config().Version
 
 	targetPackSize := calculateTargetPacksize(opts, indexPack)
+
+	// pack files cannot be deleted before their retention period expires
+	retention := repo.be.Properties().Retention
+	now := time.Now()
+	isLocked := func(modTime time.Time) bool {
+		return retention > 0 && (modTime.IsZero() || now.Before(modTime.Add(retention)))
+	}
+	// in concurrent mode, packs must not be removed before the grace period expires
+	inGracePeriod := func(t time.Time) bool {
+		return opts.Concurrent && (t.IsZero() || now.Before(t.Add(opts.GracePeriod)))
+	}
+	isAvoided := func(storageClass string) bool {
+		for _, class := range opts.AvoidStorageClasses {
+			if storageClass != "" && strings.EqualFold(class, storageClass) {
+				return true
+			}
+		}
+		return false
+	}
+
 	// loop over all packs and decide what to do
 	bar := printer.NewCounter("packs processed")
 	bar.SetMax(uint64(len(indexPack)))
-	err := repo.List(ctx, restic.PackFile, func(id restic.ID, packSize int64) error {
+	err := repo.be.List(ctx, backend.PackFile, func(fi backend.FileInfo) error {
+		id, err := restic.ParseID(fi.Name)
+		if err != nil {
+			debug.Log("unable to parse %v as an ID", fi.Name)
+			return nil
+		}
+		packSize := fi.Size
+		locked := isLocked(fi.ModTime)
+		avoid := isAvoided(fi.StorageClass)
+
+		if restorePacks.Has(id) {
+			// pack is marked for deletion, but still needed => keep pack!
+			delete(missingRestorePacks, id)
+			stats.Packs.PendingKeep++
+			stats.Size.PendingKeep += uint64(packSize)
+			return nil
+		}
+		if pbs, ok := pending[id]; ok {
+			delete(pending, id)
+			if locked || inGracePeriod(pbs.DeleteTime) {
+				debug.Log("pack %v is marked for deletion since %v", id, pbs.DeleteTime)
+				stats.Packs.Pending++
+				stats.Size.Pending += uint64(packSize)
+				return nil
+			}
+			// pack was marked for deletion long enough ago => remove pack!
+			printer.V("will remove pack %v marked for deletion at %v", id.Str(), pbs.DeleteTime.Format(time.RFC3339))
+			sweepPacks.Insert(id)
+			packSizes[id] = packSize
+			stats.Packs.PendingRm++
+			stats.Size.PendingRm += uint64(packSize)
+			return nil
+		}
+
 		p, ok := indexPack[id]
+		if !ok && inGracePeriod(fi.ModTime) {
+			// the pack may have been uploaded by a concurrent backup
+			printer.V("will not remove unindexed pack %v before the grace period expires", id.Str())
+			stats.Packs.UnrefRecent++
+			stats.Size.UnrefRecent += uint64(packSize)
+			return nil
+		}
+		if !ok && locked {
+			printer.V("will not remove unindexed pack %v before its retention period expires", id.Str())
+			stats.Packs.UnrefLocked++
+			stats.Size.UnrefLocked += uint64(packSize)
+			return nil
+		}
 		if !ok {
 			// Pack was not referenced in index and is not used  => immediately remove!
 			printer.V("will remove pack %v as it is unused and not indexed", id.Str())
 			removePacksFirst.Insert(id)
+			packSizes[id] = packSize
 			stats.Size.Unref += uint64(packSize)
 			return nil
 		}
@@ -437,9 +598,16 @@ func decidePackAction(ctx context.Context, opts PruneOptions, repo *Repository,
 
 		// decide what to do
 		switch {
+		case locked:
+			// the pack can neither be removed nor repacked yet => keep pack!
+			debug.Log("pack %v is locked until %v", id, fi.ModTime.Add(retention))
+			stats.Packs.Locked++
+			stats.Packs.Keep++
+
 		case p.usedBlobs == 0:
 			// All blobs in pack are no longer used => remove pack!
 			removePacks.Insert(id)
+			packSizes[id] = packSize
 			stats.Blobs.Remove += p.unusedBlobs
 			stats.Size.Remove += p.unusedSize
 
@@ -447,6 +615,10 @@ func decidePackAction(ctx context.Context, opts PruneOptions, repo *Repository,
 			// if this is a data pack and --repack-cacheable-only is set => keep pack!
 			stats.Packs.Keep++
 
+		case avoid && p.unusedBlobs == 0:
+			// the pack is expensive to read and contains no unused data => keep pack!
+			stats.Packs.Keep++
+
 		case p.unusedBlobs == 0 && p.tpe != restic.InvalidBlob && !mustCompress:
 			if packSize >= int64(targetPackSize) {
 				// All blobs in pack are used and not mixed => keep pack!
@@ -457,7 +629,7 @@ func decidePackAction(ctx context.Context, opts PruneOptions, repo *Repository,
 
 		default:
 			// all other packs are candidates for repacking
-			repackCandidates = append(repackCandidates, packInfoWithID{ID: id, packInfo: p, mustCompress: mustCompress})
+			repackCandidates = append(repackCandidates, packInfoWithID{ID: id, packInfo: p, mustCompress: mustCompress, avoid: avoid})
 		}
 
 		delete(indexPack, id)
@@ -483,11 +655,21 @@ func decidePackAction(ctx context.Context, opts PruneOptions, repo *Repository,
 		}
 	}
 
-	if len(indexPack) != 0 {
-		printer.E("The index references %d needed pack files which are missing from the repository:", len(indexPack))
+	// packs marked for deletion which were already removed only have to be
+	// removed from the index
+	for id := range pending {
+		debug.Log("pack %v marked for deletion is already removed", id)
+		ignorePacks.Insert(id)
+	}
+
+	if len(indexPack) != 0 || len(missingRestorePacks) != 0 {
+		printer.E("The index references %d needed pack files which are missing from the repository:", len(indexPack)+len(missingRestorePacks))
 		for id := range indexPack {
 			printer.E("  %v", id)
 		}
+		for id := range missingRestorePacks {
+			printer.E("  %v", id)
+		}
 		return PrunePlan{}, ErrPacksMissing
 	}
 	if len(ignorePacks) != 0 {
@@ -510,8 +692,12 @@ func decidePackAction(ctx context.Context, opts PruneOptions, repo *Repository,
 	// Instead of unused[i] / used[i] > unused[j] / used[j] we use
 	// unused[i] * used[j] > unused[j] * used[i] as uint32*uint32 < uint64
 	// Moreover packs containing trees and too short packs are sorted to the beginning
+	// and packs which are expensive to read to the end.
 	debug.Log("%d candidate packfiles to repack", len(repackCandidates))
 	sort.Slice(repackCandidates, func(i, j int) bool {
+		if repackCandidates[i].avoid != repackCandidates[j].avoid {
+			return repackCandidates[j].avoid
+		}
 		pi := repackCandidates[i].packInfo
 		pj := repackCandidates[j].packInfo
 		switch {
@@ -527,8 +713,15 @@ func decidePackAction(ctx context.Context, opts PruneOptions, repo *Repository,
 		return pi.unusedSize*pj.usedSize > pj.unusedSize*pi.usedSize
 	})
 
-	repack := func(id restic.ID, p packInfo) {
+	var repackOrder restic.IDs
+	repack := func(id restic.ID, p packInfo, avoid bool) {
+		if avoid {
+			stats.Packs.Retrieve++
+			stats.Size.Retrieve += p.unusedSize + p.usedSize
+		}
 		repackPacks.Insert(id)
+		repackOrder = append(repackOrder, id)
+		packSizes[id] = int64(p.unusedSize + p.usedSize)
 		stats.Blobs.Repack += p.unusedBlobs + p.usedBlobs
 		stats.Size.Repack += p.unusedSize + p.usedSize
 		stats.Blobs.Repackrm += p.unusedBlobs
@@ -552,16 +745,20 @@ func decidePackAction(ctx context.Context, opts PruneOptions, repo *Repository,
 		case reachedRepackSize:
 			stats.Packs.Keep++
 
+		case p.avoid && reachedUnusedSizeAfter:
+			// packs which are expensive to read are only repacked to reach the tolerated unused size
+			stats.Packs.Keep++
+
 		case p.tpe != restic.DataBlob, p.mustCompress:
 			// repacking non-data packs / uncompressed-trees is only limited by repackSize
-			repack(p.ID, p.packInfo)
+			repack(p.ID, p.packInfo, p.avoid)
 
 		case reachedUnusedSizeAfter && packIsLargeEnough:
 			// for all other packs stop repacking if tolerated unused size is reached.
 			stats.Packs.Keep++
 
 		default:
-			repack(p.ID, p.packInfo)
+			repack(p.ID, p.packInfo, p.avoid)
 		}
 	}
 
@@ -575,9 +772,13 @@ func decidePackAction(ctx context.Context, opts PruneOptions, repo *Repository,
 	}
 
 	return PrunePlan{removePacksFirst: removePacksFirst,
-		removePacks: removePacks,
-		repackPacks: repackPacks,
-		ignorePacks: ignorePacks,
+		removePacks:  removePacks,
+		sweepPacks:   sweepPacks,
+		restorePacks: restorePacks,
+		repackPacks:  repackPacks,
+		repackOrder:  repackOrder,
+		ignorePacks:  ignorePacks,
+		packSizes:    packSizes,
 	}, nil
 }
 
@@ -590,6 +791,8 @@ func (plan *PrunePlan) Stats() PruneStats {
 // - repack given pack files while keeping the given blobs
 // - rebuild the index while ignoring all files that will be deleted
 // - delete the files
+// In concurrent mode, the unused and repacked packs are only marked for
+// deletion in the index instead of deleting them.
 // plan.removePacks and plan.ignorePacks are modified in this function.
 func (plan *PrunePlan) Execute(ctx context.Context, printer restic.Printer) error {
 	if plan.opts.DryRun {
@@ -597,8 +800,19 @@ func (plan *PrunePlan) Execute(ctx context.Context, printer restic.Printer) erro
 		if len(plan.removePacksFirst) > 0 {
 			printer.V("Would have removed the following unreferenced packs:\n%v\n\n", plan.removePacksFirst)
 		}
-		printer.V("Would have repacked and removed the following packs:\n%v\n\n", plan.repackPacks)
-		printer.V("Would have removed the following no longer used packs:\n%v\n\n", plan.removePacks)
+		if plan.opts.Concurrent {
+			printer.V("Would have repacked and marked the following packs for deletion:\n%v\n\n", plan.repackPacks)
+			printer.V("Would have marked the following no longer used packs for deletion:\n%v\n\n", plan.removePacks)
+		} else {
+			printer.V("Would have repacked and removed the following packs:\n%v\n\n", plan.repackPacks)
+			printer.V("Would have removed the following no longer used packs:\n%v\n\n", plan.removePacks)
+		}
+		if len(plan.sweepPacks) > 0 {
+			printer.V("Would have removed the following packs marked for deletion:\n%v\n\n", plan.sweepPacks)
+		}
+		if len(plan.restorePacks) > 0 {
+			printer.V("Would have restored the following packs marked for deletion:\n%v\n\n", plan.restorePacks)
+		}
 		// Always quit here if DryRun was set!
 		return nil
 	}
@@ -621,18 +835,21 @@ func (plan *PrunePlan) Execute(ctx context.Context, printer restic.Printer) erro
 
 	if len(plan.repackPacks) != 0 {
 		printer.P("repacking packs\n")
-		bar := printer.NewCounter("packs repacked")
-		err := repo.WithBlobUploader(ctx, func(ctx context.Context, uploader restic.BlobSaverWithAsync) error {
-			return CopyBlobs(ctx, repo, repo, uploader, plan.repackPacks, plan.keepBlobs, bar, printer.P)
-		})
+		repacked, err := plan.repack(ctx, repo, printer)
 		if err != nil {
 			return errors.Fatalf("%s", err)
 		}
 
 		// Also remove repacked packs
-		plan.removePacks.Merge(plan.repackPacks)
+		plan.removePacks.Merge(repacked)
+		if len(repacked) != len(plan.repackPacks) {
+			printer.P("time limit reached, repacked %d of %d packs. Run prune again to continue.\n",
+				len(repacked), len(plan.repackPacks))
+			plan.dropBlobsOfSkippedPacks(repo, repacked)
+		}
 		// forget unused data
 		plan.repackPacks = nil
+		plan.repackOrder = nil
 
 		if plan.keepBlobs.Len() != 0 {
 			printer.E("%v was not repacked\n\n"+
@@ -646,6 +863,14 @@ func (plan *PrunePlan) Execute(ctx context.Context, printer restic.Printer) erro
 		plan.keepBlobs = nil
 	}
 
+	var markPacks restic.IDSet
+	if plan.opts.Concurrent {
+		// concurrent backups may still use these packs, only mark them for deletion
+		markPacks = plan.removePacks
+		plan.removePacks = restic.NewIDSet()
+	}
+	plan.removePacks.Merge(plan.sweepPacks)
+
 	if len(plan.ignorePacks) == 0 {
 		plan.ignorePacks = plan.removePacks
 	} else {
@@ -659,8 +884,16 @@ func (plan *PrunePlan) Execute(ctx context.Context, printer restic.Printer) erro
 		if err != nil {
 			return errors.Fatalf("%s", err)
 		}
-	} else if len(plan.ignorePacks) != 0 {
-		err := rewriteIndexFiles(ctx, repo, plan.ignorePacks, nil, nil, printer)
+	} else if len(plan.ignorePacks) != 0 || len(markPacks) != 0 || len(plan.restorePacks) != 0 {
+		if len(markPacks) != 0 {
+			printer.P("marking %d packs for deletion", len(markPacks))
+		}
+		printer.P("rebuilding index\n")
+		opts := indexRewriteOpts(printer)
+		opts.MarkPacks = markPacks
+		opts.MarkTime = time.Now()
+		opts.UnmarkPacks = plan.restorePacks
+		err := repo.idx.Rewrite(ctx, &internalRepository{repo}, plan.ignorePacks, nil, nil, opts)
 		if err != nil {
 			return errors.Fatalf("%s", err)
 		}
@@ -689,6 +922,71 @@ func (plan *PrunePlan) Execute(ctx context.Context, printer restic.Printer) erro
 	return nil
 }
 
+// repackBatchSize is the number of packs which are repacked at once if
+// RepackDeadline is set. The deadline is checked before each batch.
+const repackBatchSize = 32
+
+// repack copies the blobs to keep from plan.repackPacks into new packs and
+// returns the packs which were repacked. If the deadline passes, the remaining
+// packs are skipped.
+func (plan *PrunePlan) repack(ctx context.Context, repo *Repository, printer restic.Printer) (restic.IDSet, error) {
+	if plan.opts.RepackDeadline.IsZero() {
+		bar := printer.NewCounter("packs repacked")
+		err := repo.WithBlobUploader(ctx, func(ctx context.Context, uploader restic.BlobSaverWithAsync) error {
+			return CopyBlobs(ctx, repo, repo, uploader, plan.repackPacks, plan.keepBlobs, bar, printer.P)
+		})
+		return plan.repackPacks, err
+	}
+
+	bar := printer.NewCounter("packs repacked")
+	bar.SetMax(uint64(len(plan.repackOrder)))
+	defer bar.Done()
+
+	repacked := restic.NewIDSet()
+	for batch := range slices.Chunk(plan.repackOrder, repackBatchSize) {
+		if time.Now().After(plan.opts.RepackDeadline) {
+			debug.Log("repack deadline reached after %d packs", len(repacked))
+			break
+		}
+
+		packs := restic.NewIDSet(batch...)
+		err := repo.WithBlobUploader(ctx, func(ctx context.Context, uploader restic.BlobSaverWithAsync) error {
+			return CopyBlobs(ctx, repo, repo, uploader, packs, plan.keepBlobs, batchCounter{bar}, printer.P)
+		})
+		if err != nil {
+			return nil, err
+		}
+		repacked.Merge(packs)
+	}
+	return repacked, nil
+}
+
+// dropBlobsOfSkippedPacks removes the blobs from plan.keepBlobs which are
+// still contained in a pack which was not repacked, as these packs are kept.
+func (plan *PrunePlan) dropBlobsOfSkippedPacks(repo *Repository, repacked restic.IDSet) {
+	var skipped []restic.BlobHandle
+	for bh := range plan.keepBlobs.Keys() {
+		for _, pb := range repo.LookupBlob(bh) {
+			if plan.repackPacks.Has(pb.PackID()) && !repacked.Has(pb.PackID()) {
+				skipped = append(skipped, bh)
+				break
+			}
+		}
+	}
+	for _, bh := range skipped {
+		plan.keepBlobs.Delete(bh)
+	}
+}
+
+// batchCounter forwards the progress of a single repack batch to a counter
+// which tracks the progress of all batches.
+type batchCounter struct {
+	restic.Counter
+}
+
+func (batchCounter) SetMax(uint64) {}
+func (batchCounter) Done()         {}
+
 // deleteFiles deletes the given fileList of fileType in parallel
 // if ignoreError=true, it will print a warning if there was an error, else it will abort.
 func deleteFiles(ctx context.Context, ignoreError bool, repo restic.RemoverUnpacked[restic.FileType], fileList restic.IDSet, fileType restic.FileType, printer restic.Printer) error {
@@ -0,0 +1,218 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/repository/index"
+	"github.com/restic/restic/internal/restic"
+)
+
+// PrunePlanVersion is the version of the prune plan file format.
+const PrunePlanVersion = 1
+
+var ErrPrunePlanOutdated = errors.Fatal("the repository was modified after the prune plan was created, please create a new plan")
+
+// PrunePlanFile is the machine-readable representation of a PrunePlan. It is
+// created by PrunePlan.Export and can be applied later on using LoadPrunePlan.
+type PrunePlanFile struct {
+	Version      uint       `json:"version"`
+	RepositoryID string     `json:"repository_id"`
+	Created      time.Time  `json:"created"`
+	Snapshots    restic.IDs `json:"snapshots"`
+	Indexes      restic.IDs `json:"indexes"`
+
+	RemoveUnreferenced []PrunePlanPack `json:"remove_unreferenced"`
+	Repack             []PrunePlanPack `json:"repack"`
+	Remove             []PrunePlanPack `json:"remove"`
+	ForgetMissing      restic.IDs      `json:"forget_missing"`
+	// Restore lists packs marked for deletion which are still needed
+	Restore restic.IDs `json:"restore,omitempty"`
+
+	Stats PruneStats `json:"stats"`
+}
+
+// PrunePlanPack is a pack file which is modified by a prune plan.
+type PrunePlanPack struct {
+	ID   restic.ID `json:"id"`
+	Size int64     `json:"size"`
+}
+
+// Export returns the machine-readable representation of the plan. snapshots
+// must contain the snapshots which were used to determine the used blobs.
+func (plan *PrunePlan) Export(snapshots restic.IDs) *PrunePlanFile {
+	packs := func(ids restic.IDs) []PrunePlanPack {
+		list := make([]PrunePlanPack, 0, len(ids))
+		for _, id := range ids {
+			list = append(list, PrunePlanPack{ID: id, Size: plan.packSizes[id]})
+		}
+		return list
+	}
+
+	return &PrunePlanFile{
+		Version:      PrunePlanVersion,
+		RepositoryID: plan.repo.Config().ID,
+		Created:      time.Now(),
+		Snapshots:    restic.NewIDSet(snapshots...).List(),
+		Indexes:      plan.repo.idx.IDs().List(),
+
+		RemoveUnreferenced: packs(plan.removePacksFirst.List()),
+		// keep the priority order in case repacking stops at the deadline
+		Repack:        packs(plan.repackOrder),
+		Remove:        packs(append(plan.removePacks.List(), plan.sweepPacks.List()...)),
+		ForgetMissing: plan.ignorePacks.List(),
+		Restore:       plan.restorePacks.List(),
+
+		Stats: plan.stats,
+	}
+}
+
+// LoadPrunePlan checks that a plan created by PrunePlan.Export still matches
+// the state of the repository and returns a PrunePlan which executes it. The
+// index must be loaded and unchanged since the plan was created. getUsedBlobs
+// must add all blobs which are still in use, it is used to verify that the
+// plan does not remove needed data. The caller is responsible for checking
+// that the snapshots match planFile.Snapshots.
+func LoadPrunePlan(ctx context.Context, opts PruneOptions, repo *Repository, planFile *PrunePlanFile, getUsedBlobs func(ctx context.Context, repo restic.Repository, usedBlobs restic.FindBlobSet) error, printer restic.Printer) (*PrunePlan, error) {
+	if repo.Connections() < 2 {
+		return nil, fmt.Errorf("prune requires a backend connection limit of at least two")
+	}
+	if planFile.Version != PrunePlanVersion {
+		return nil, errors.Fatalf("unsupported prune plan version %d", planFile.Version)
+	}
+	if planFile.RepositoryID != repo.Config().ID {
+		return nil, errors.Fatalf("prune plan was created for repository %v", planFile.RepositoryID)
+	}
+	if !repo.idx.IDs().Equals(restic.NewIDSet(planFile.Indexes...)) {
+		printer.E("the index files do not match the prune plan")
+		return nil, ErrPrunePlanOutdated
+	}
+
+	plan := PrunePlan{
+		removePacksFirst: restic.NewIDSet(),
+		repackPacks:      restic.NewIDSet(),
+		removePacks:      restic.NewIDSet(),
+		restorePacks:     restic.NewIDSet(planFile.Restore...),
+		ignorePacks:      restic.NewIDSet(planFile.ForgetMissing...),
+		packSizes:        make(map[restic.ID]int64),
+	}
+	for _, p := range planFile.RemoveUnreferenced {
+		plan.removePacksFirst.Insert(p.ID)
+		plan.packSizes[p.ID] = p.Size
+	}
+	for _, p := range planFile.Repack {
+		plan.repackPacks.Insert(p.ID)
+		plan.repackOrder = append(plan.repackOrder, p.ID)
+		plan.packSizes[p.ID] = p.Size
+	}
+	for _, p := range planFile.Remove {
+		plan.removePacks.Insert(p.ID)
+		plan.packSizes[p.ID] = p.Size
+	}
+
+	printer.P("checking packs of the prune plan...\n")
+	remaining := make(map[restic.ID]int64, len(plan.packSizes))
+	for id, size := range plan.packSizes {
+		remaining[id] = size
+	}
+	err := repo.List(ctx, restic.PackFile, func(id restic.ID, size int64) error {
+		if plan.ignorePacks.Has(id) {
+			printer.E("pack %v is no longer missing", id.Str())
+			return ErrPrunePlanOutdated
+		}
+		expected, ok := remaining[id]
+		if !ok {
+			return nil
+		}
+		if expected != size {
+			printer.E("pack %v: size %d does not match size %d from the prune plan", id.Str(), size, expected)
+			return ErrPrunePlanOutdated
+		}
+		delete(remaining, id)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(remaining) != 0 {
+		for id := range remaining {
+			printer.E("pack %v from the prune plan is missing", id.Str())
+		}
+		return nil, ErrPrunePlanOutdated
+	}
+
+	printer.P("checking that the prune plan keeps all used data...\n")
+	usedBlobs := index.NewAssociatedSet[uint8](repo.idx)
+	err = getUsedBlobs(ctx, repo, usedBlobs)
+	if err != nil {
+		return nil, err
+	}
+
+	// mark used blobs with 1 if they are only contained in packs which are
+	// repacked and with 2 if they are contained in a pack which is kept
+	indexedPacks := restic.NewIDSet()
+	err = repo.ListBlobs(ctx, func(blob restic.PackBlob) {
+		packID := blob.PackID()
+		indexedPacks.Insert(packID)
+		bh := blob.Handle()
+		count, ok := usedBlobs.Get(bh)
+		switch {
+		case !ok || count == 2:
+		case plan.repackPacks.Has(packID):
+			usedBlobs.Set(bh, 1)
+		case !plan.removePacks.Has(packID) && !plan.ignorePacks.Has(packID):
+			usedBlobs.Set(bh, 2)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	pending := repo.idx.PacksToDelete()
+	for id := range plan.restorePacks {
+		pbs, ok := pending[id]
+		if !ok {
+			printer.E("pack %v from the prune plan is not marked for deletion", id.Str())
+			return nil, ErrPrunePlanOutdated
+		}
+		// blobs of restored packs are kept
+		for _, blob := range pbs.Blobs {
+			if count, ok := usedBlobs.Get(blob.BlobHandle); ok && count == 0 {
+				usedBlobs.Set(blob.BlobHandle, 2)
+			}
+		}
+	}
+
+	for id := range plan.removePacksFirst {
+		if indexedPacks.Has(id) {
+			printer.E("unreferenced pack %v from the prune plan is contained in the index", id.Str())
+			return nil, ErrPrunePlanOutdated
+		}
+	}
+
+	keepBlobs := index.NewAssociatedSet[uint8](repo.idx)
+	missingBlobs := restic.NewBlobSet()
+	for bh, count := range usedBlobs.All() {
+		switch count {
+		case 0:
+			missingBlobs.Insert(bh)
+		case 1:
+			keepBlobs.Insert(bh)
+		}
+	}
+	if len(missingBlobs) != 0 {
+		printer.E("%v would be removed by the prune plan although still in use", missingBlobs)
+		return nil, ErrPrunePlanOutdated
+	}
+	if len(plan.repackPacks) != 0 {
+		plan.keepBlobs = keepBlobs
+	}
+
+	plan.repo = repo
+	plan.stats = planFile.Stats
+	plan.opts = opts
+
+	return &plan, nil
+}
@@ -2,12 +2,16 @@ package repository_test
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"math"
 	"math/rand"
+	"sort"
 	"testing"
 	"time"
 
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/repository"
 	"github.com/restic/restic/internal/repository/pack"
 	"github.com/restic/restic/internal/restic"
@@ -98,6 +102,15 @@ func TestPrune(t *testing.T) {
 			},
 			errOnUnused: true,
 		},
+		{
+			name: "deadline",
+			opts: repository.PruneOptions{
+				MaxRepackBytes: math.MaxUint64,
+				MaxUnusedBytes: func(used uint64) (unused uint64) { return 0 },
+				RepackDeadline: time.Now().Add(time.Hour),
+			},
+			errOnUnused: true,
+		},
 	} {
 		t.Run(test.name, func(t *testing.T) {
 			testPrune(t, test.opts, test.errOnUnused)
@@ -111,6 +124,314 @@ func TestPrune(t *testing.T) {
 	}
 }
 
+func TestPruneDeadlineReached(t *testing.T) {
+	seed := time.Now().UnixNano()
+	random := rand.New(rand.NewSource(seed))
+	t.Logf("rand initialized with seed %d", seed)
+
+	repo, _, be := repository.TestRepositoryWithVersion(t, 0)
+	// store a single pack which contains used and unused blobs
+	keep := restic.NewBlobSet()
+	rtest.OK(t, repo.WithBlobUploader(context.TODO(), func(ctx context.Context, uploader restic.BlobSaverWithAsync) error {
+		for i := 0; i < 4; i++ {
+			buf := make([]byte, 1024)
+			random.Read(buf)
+			id, _, _, err := uploader.SaveBlob(ctx, restic.DataBlob, buf, restic.ID{}, false)
+			rtest.OK(t, err)
+			if i%2 == 0 {
+				keep.Insert(restic.BlobHandle{Type: restic.DataBlob, ID: id})
+			}
+		}
+		return nil
+	}))
+
+	prune := func(opts repository.PruneOptions) {
+		repo := repository.TestOpenBackend(t, be)
+		rtest.OK(t, repo.LoadIndex(context.TODO(), restic.NoopTerminalCounterFactory))
+
+		plan, err := repository.PlanPrune(context.TODO(), opts, repo, func(ctx context.Context, repo restic.Repository, usedBlobs restic.FindBlobSet) error {
+			for blob := range keep {
+				usedBlobs.Insert(blob)
+			}
+			return nil
+		}, restic.NewNoopPrinter())
+		rtest.OK(t, err)
+		rtest.Assert(t, plan.Stats().Packs.Repack > 0, "expected packs to repack")
+		rtest.OK(t, plan.Execute(context.TODO(), restic.NewNoopPrinter()))
+	}
+
+	opts := repository.PruneOptions{
+		MaxRepackBytes: math.MaxUint64,
+		MaxUnusedBytes: func(used uint64) (unused uint64) { return 0 },
+		RepackDeadline: time.Now().Add(-time.Second),
+	}
+	prune(opts)
+
+	// nothing was repacked, but the repository must still be intact
+	repo = repository.TestOpenBackend(t, be)
+	repository.TestCheckRepo(t, repo)
+	existing := listBlobs(repo)
+	rtest.Equals(t, 0, keep.Sub(existing).Len(), "blobs to keep were removed")
+	rtest.Assert(t, !existing.Equals(keep), "expected unused blobs to remain")
+
+	// a later run completes the prune
+	opts.RepackDeadline = time.Time{}
+	prune(opts)
+
+	repo = repository.TestOpenBackend(t, be)
+	repository.TestCheckRepo(t, repo)
+	existing = listBlobs(repo)
+	rtest.Assert(t, existing.Equals(keep), "unexpected blobs, wanted %v got %v", keep, existing)
+}
+
+// retentionBackend reports a retention period and the modification times of
+// pack files like a backend which uses an object lock.
+type retentionBackend struct {
+	backend.Backend
+	retention time.Duration
+	modTimes  map[string]time.Time
+}
+
+func (be *retentionBackend) Properties() backend.Properties {
+	props := be.Backend.Properties()
+	props.Retention = be.retention
+	return props
+}
+
+func (be *retentionBackend) List(ctx context.Context, t backend.FileType, fn func(backend.FileInfo) error) error {
+	return be.Backend.List(ctx, t, func(fi backend.FileInfo) error {
+		fi.ModTime = be.modTimes[fi.Name]
+		return fn(fi)
+	})
+}
+
+func TestPruneRetention(t *testing.T) {
+	repo, _, be := repository.TestRepositoryWithVersion(t, 0)
+
+	// each upload creates a separate pack file, none of the blobs is used
+	for i := 0; i < 3; i++ {
+		rtest.OK(t, repo.WithBlobUploader(context.TODO(), func(ctx context.Context, uploader restic.BlobSaverWithAsync) error {
+			_, _, _, err := uploader.SaveBlob(ctx, restic.DataBlob, rtest.Random(i, 1000), restic.ID{}, false)
+			return err
+		}))
+	}
+	buf := []byte("foo")
+	unindexed := restic.Hash(buf)
+	rtest.OK(t, be.Save(context.TODO(), backend.Handle{Type: backend.PackFile, Name: unindexed.String()}, backend.NewByteReader(buf, be.Hasher())))
+
+	var packs []string
+	rtest.OK(t, be.List(context.TODO(), backend.PackFile, func(fi backend.FileInfo) error {
+		packs = append(packs, fi.Name)
+		return nil
+	}))
+	rtest.Equals(t, 4, len(packs))
+
+	// all pack files except the first indexed one and the unindexed one are
+	// past their retention period
+	lockedIndexed := packs[0]
+	if lockedIndexed == unindexed.String() {
+		lockedIndexed = packs[1]
+	}
+	rbe := &retentionBackend{Backend: be, retention: time.Hour, modTimes: make(map[string]time.Time)}
+	for _, name := range packs {
+		rbe.modTimes[name] = time.Now().Add(-2 * time.Hour)
+	}
+	rbe.modTimes[lockedIndexed] = time.Now()
+	rbe.modTimes[unindexed.String()] = time.Now()
+
+	repo = repository.TestOpenBackend(t, rbe)
+	rtest.OK(t, repo.LoadIndex(context.TODO(), restic.NoopTerminalCounterFactory))
+	plan, err := repository.PlanPrune(context.TODO(), repository.PruneOptions{
+		MaxRepackBytes: math.MaxUint64,
+		MaxUnusedBytes: func(used uint64) (unused uint64) { return 0 },
+	}, repo, func(ctx context.Context, repo restic.Repository, usedBlobs restic.FindBlobSet) error {
+		return nil
+	}, restic.NewNoopPrinter())
+	rtest.OK(t, err)
+
+	stats := plan.Stats()
+	rtest.Equals(t, uint(1), stats.Packs.Locked)
+	rtest.Equals(t, uint(1), stats.Packs.UnrefLocked)
+	rtest.Equals(t, uint(2), stats.Packs.Remove)
+	rtest.Equals(t, uint(4), stats.Packs.Total)
+	rtest.OK(t, plan.Execute(context.TODO(), restic.NewNoopPrinter()))
+
+	var remaining []string
+	rtest.OK(t, be.List(context.TODO(), backend.PackFile, func(fi backend.FileInfo) error {
+		remaining = append(remaining, fi.Name)
+		return nil
+	}))
+	sort.Strings(remaining)
+	expected := []string{lockedIndexed, unindexed.String()}
+	sort.Strings(expected)
+	rtest.Equals(t, expected, remaining)
+}
+
+func TestPruneConcurrent(t *testing.T) {
+	repo, _, be := repository.TestRepositoryWithVersion(t, 3)
+
+	// each upload creates a separate pack file with a single blob
+	blobs := make([]restic.BlobHandle, 3)
+	for i := range blobs {
+		rtest.OK(t, repo.WithBlobUploader(context.TODO(), func(ctx context.Context, uploader restic.BlobSaverWithAsync) error {
+			id, _, _, err := uploader.SaveBlob(ctx, restic.DataBlob, rtest.Random(i, 1000), restic.ID{}, false)
+			blobs[i] = restic.BlobHandle{Type: restic.DataBlob, ID: id}
+			return err
+		}))
+	}
+	unusedBlob, usedBlob, laterUsedBlob := blobs[0], blobs[1], blobs[2]
+	rbe := &retentionBackend{Backend: be, modTimes: make(map[string]time.Time)}
+
+	listPacks := func() restic.IDSet {
+		packs := restic.NewIDSet()
+		rtest.OK(t, be.List(context.TODO(), backend.PackFile, func(fi backend.FileInfo) error {
+			packs.Insert(restic.TestParseID(fi.Name))
+			return nil
+		}))
+		return packs
+	}
+	prune := func(gracePeriod time.Duration, used ...restic.BlobHandle) repository.PruneStats {
+		repo := repository.TestOpenBackend(t, rbe)
+		rtest.OK(t, repo.LoadIndex(context.TODO(), restic.NoopTerminalCounterFactory))
+		plan, err := repository.PlanPrune(context.TODO(), repository.PruneOptions{
+			MaxRepackBytes: math.MaxUint64,
+			MaxUnusedBytes: func(used uint64) (unused uint64) { return 0 },
+			Concurrent:     true,
+			GracePeriod:    gracePeriod,
+		}, repo, func(ctx context.Context, repo restic.Repository, usedBlobs restic.FindBlobSet) error {
+			for _, bh := range used {
+				usedBlobs.Insert(bh)
+			}
+			return nil
+		}, restic.NewNoopPrinter())
+		rtest.OK(t, err)
+		rtest.OK(t, plan.Execute(context.TODO(), restic.NewNoopPrinter()))
+		return plan.Stats()
+	}
+
+	packsBefore := listPacks()
+	stats := prune(time.Hour, usedBlob)
+	rtest.Equals(t, uint(2), stats.Packs.Remove)
+
+	// unused packs are only marked for deletion
+	rtest.Equals(t, packsBefore, listPacks())
+	repo = repository.TestOpenBackend(t, be)
+	repository.TestCheckRepo(t, repo)
+	rtest.Equals(t, restic.NewBlobSet(usedBlob), listBlobs(repo))
+
+	// blobs of marked packs remain readable, but must not be used by new data
+	_, found := repo.LookupBlobSize(laterUsedBlob)
+	rtest.Assert(t, found, "blob of marked pack not found")
+	rtest.Assert(t, !repo.HasBlob(laterUsedBlob), "blob of marked pack must not be reused")
+	_, err := repo.LoadBlob(context.TODO(), laterUsedBlob, nil)
+	rtest.OK(t, err)
+
+	// a pack file uploaded by a concurrent backup which is not indexed yet
+	buf := []byte("foo")
+	unindexed := restic.Hash(buf)
+	rtest.OK(t, be.Save(context.TODO(), backend.Handle{Type: backend.PackFile, Name: unindexed.String()}, backend.NewByteReader(buf, be.Hasher())))
+	rbe.modTimes[unindexed.String()] = time.Now()
+	packsBefore.Insert(unindexed)
+
+	// the grace period has not expired yet
+	stats = prune(time.Hour, usedBlob)
+	rtest.Equals(t, uint(2), stats.Packs.Pending)
+	rtest.Equals(t, uint(1), stats.Packs.UnrefRecent)
+	rtest.Equals(t, packsBefore, listPacks())
+
+	// a concurrent backup has used a blob of a marked pack, the pack is
+	// restored while the other marked pack and the unindexed pack are removed
+	// once the grace period has expired
+	rbe.modTimes[unindexed.String()] = time.Now().Add(-time.Minute)
+	stats = prune(0, usedBlob, laterUsedBlob)
+	rtest.Equals(t, uint(1), stats.Packs.PendingKeep)
+	rtest.Equals(t, uint(1), stats.Packs.PendingRm)
+	rtest.Equals(t, uint(1), stats.Packs.Unref)
+
+	repo = repository.TestOpenBackend(t, be)
+	repository.TestCheckRepo(t, repo)
+	rtest.Equals(t, restic.NewBlobSet(usedBlob, laterUsedBlob), listBlobs(repo))
+	rtest.Equals(t, 2, len(listPacks()))
+	rtest.Assert(t, !listBlobs(repo).Has(unusedBlob), "unused blob was not removed")
+}
+
+func TestPruneConcurrentRequiresRepoV3(t *testing.T) {
+	repo, _, _ := repository.TestRepositoryWithVersion(t, 2)
+	_, err := repository.PlanPrune(context.TODO(), repository.PruneOptions{
+		MaxUnusedBytes: func(used uint64) (unused uint64) { return 0 },
+		Concurrent:     true,
+	}, repo, func(ctx context.Context, repo restic.Repository, usedBlobs restic.FindBlobSet) error {
+		return nil
+	}, restic.NewNoopPrinter())
+	rtest.Assert(t, err != nil, "expected error for concurrent prune in repository version 2")
+}
+
+type storageClassBackend struct {
+	backend.Backend
+	classes map[string]string
+}
+
+func (be *storageClassBackend) List(ctx context.Context, t backend.FileType, fn func(backend.FileInfo) error) error {
+	return be.Backend.List(ctx, t, func(fi backend.FileInfo) error {
+		fi.StorageClass = be.classes[fi.Name]
+		return fn(fi)
+	})
+}
+
+func TestPruneAvoidStorageClass(t *testing.T) {
+	repo, _, be := repository.TestRepositoryWithVersion(t, 0)
+
+	// each upload creates a separate pack file with a used and an unused blob
+	usedBlobs := restic.NewBlobSet()
+	for i := 0; i < 3; i++ {
+		rtest.OK(t, repo.WithBlobUploader(context.TODO(), func(ctx context.Context, uploader restic.BlobSaverWithAsync) error {
+			id, _, _, err := uploader.SaveBlob(ctx, restic.DataBlob, rtest.Random(i, 1000), restic.ID{}, false)
+			usedBlobs.Insert(restic.BlobHandle{Type: restic.DataBlob, ID: id})
+			if err != nil {
+				return err
+			}
+			_, _, _, err = uploader.SaveBlob(ctx, restic.DataBlob, rtest.Random(10+i, 1000), restic.ID{}, false)
+			return err
+		}))
+	}
+
+	var packs []string
+	rtest.OK(t, be.List(context.TODO(), backend.PackFile, func(fi backend.FileInfo) error {
+		packs = append(packs, fi.Name)
+		return nil
+	}))
+	rtest.Equals(t, 3, len(packs))
+	sbe := &storageClassBackend{Backend: be, classes: map[string]string{packs[0]: "GLACIER"}}
+
+	for _, test := range []struct {
+		maxUnused uint64
+		repack    uint
+		retrieve  uint
+	}{
+		// repacking the other packs is sufficient to reach the tolerated unused size
+		{maxUnused: 1500, repack: 2, retrieve: 0},
+		{maxUnused: 0, repack: 3, retrieve: 1},
+	} {
+		repo = repository.TestOpenBackend(t, sbe)
+		rtest.OK(t, repo.LoadIndex(context.TODO(), restic.NoopTerminalCounterFactory))
+		plan, err := repository.PlanPrune(context.TODO(), repository.PruneOptions{
+			MaxRepackBytes:      math.MaxUint64,
+			MaxUnusedBytes:      func(used uint64) (unused uint64) { return test.maxUnused },
+			AvoidStorageClasses: []string{"glacier"},
+		}, repo, func(ctx context.Context, repo restic.Repository, blobs restic.FindBlobSet) error {
+			for bh := range usedBlobs {
+				blobs.Insert(bh)
+			}
+			return nil
+		}, restic.NewNoopPrinter())
+		rtest.OK(t, err)
+
+		stats := plan.Stats()
+		rtest.Equals(t, test.repack, stats.Packs.Repack)
+		rtest.Equals(t, test.retrieve, stats.Packs.Retrieve)
+	}
+}
+
 /*
 1.) create repository with packsize of 2M.
 2.) create enough data for 11 packfiles (31 packs)
@@ -191,3 +512,61 @@ func TestPruneSmall(t *testing.T) {
 	rtest.Equals(t, lenPackfilesBefore > lenPackfilesAfter, true,
 		fmt.Sprintf("the number packfiles before %d and after repack %d", lenPackfilesBefore, lenPackfilesAfter))
 }
+
+func TestPrunePlanFile(t *testing.T) {
+	seed := time.Now().UnixNano()
+	random := rand.New(rand.NewSource(seed))
+	t.Logf("rand initialized with seed %d", seed)
+
+	repo, _, be := repository.TestRepositoryWithVersion(t, 0)
+	createRandomBlobs(t, random, repo, 4, 0.5, true)
+	createRandomBlobs(t, random, repo, 5, 0.5, true)
+	keep, _ := selectBlobs(t, random, repo, 0.5)
+	// ensure that the plan removes at least one pack
+	createRandomBlobs(t, random, repo, 3, 0.5, true)
+	getUsedBlobs := func(ctx context.Context, repo restic.Repository, usedBlobs restic.FindBlobSet) error {
+		for blob := range keep {
+			usedBlobs.Insert(blob)
+		}
+		return nil
+	}
+
+	opts := repository.PruneOptions{
+		MaxRepackBytes: math.MaxUint64,
+		MaxUnusedBytes: func(used uint64) (unused uint64) { return 0 },
+	}
+	plan, err := repository.PlanPrune(context.TODO(), opts, repo, getUsedBlobs, restic.NewNoopPrinter())
+	rtest.OK(t, err)
+	snapshots := restic.IDs{restic.NewRandomID()}
+	buf, err := json.Marshal(plan.Export(snapshots))
+	rtest.OK(t, err)
+
+	var planFile repository.PrunePlanFile
+	rtest.OK(t, json.Unmarshal(buf, &planFile))
+	rtest.Equals(t, snapshots, planFile.Snapshots)
+	rtest.Equals(t, plan.Stats(), planFile.Stats)
+
+	// a plan which would remove a used blob must be rejected
+	repo = repository.TestOpenBackend(t, be)
+	rtest.OK(t, repo.LoadIndex(context.TODO(), restic.NoopTerminalCounterFactory))
+	_, err = repository.LoadPrunePlan(context.TODO(), opts, repo, &planFile, func(ctx context.Context, repo restic.Repository, usedBlobs restic.FindBlobSet) error {
+		return repo.ListBlobs(ctx, func(blob restic.PackBlob) {
+			usedBlobs.Insert(blob.Handle())
+		})
+	}, restic.NewNoopPrinter())
+	rtest.Assert(t, errors.Is(err, repository.ErrPrunePlanOutdated), "unexpected error %v", err)
+
+	plan, err = repository.LoadPrunePlan(context.TODO(), opts, repo, &planFile, getUsedBlobs, restic.NewNoopPrinter())
+	rtest.OK(t, err)
+	rtest.OK(t, plan.Execute(context.TODO(), restic.NewNoopPrinter()))
+
+	repo = repository.TestOpenBackend(t, be)
+	repository.TestCheckRepo(t, repo)
+	existing := listBlobs(repo)
+	rtest.Assert(t, existing.Equals(keep), "unexpected blobs, wanted %v got %v", keep, existing)
+
+	// the plan cannot be applied twice
+	rtest.OK(t, repo.LoadIndex(context.TODO(), restic.NoopTerminalCounterFactory))
+	_, err = repository.LoadPrunePlan(context.TODO(), opts, repo, &planFile, getUsedBlobs, restic.NewNoopPrinter())
+	rtest.Assert(t, errors.Is(err, repository.ErrPrunePlanOutdated), "unexpected error %v", err)
+}
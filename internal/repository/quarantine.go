@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+)
+
+// QuarantinePack moves the pack file id to the quarantine directory of the
+// repository. The index is not modified, thus the pack file is reported as
+// missing until it is salvaged using RepairPacks.
+func (r *Repository) QuarantinePack(ctx context.Context, id restic.ID) error {
+	return r.movePack(ctx, id, backend.PackFile, backend.QuarantineFile)
+}
+
+// RestoreQuarantinedPacks moves the pack files in ids which only exist in the
+// quarantine directory back to the data directory. It returns the restored
+// pack files.
+func RestoreQuarantinedPacks(ctx context.Context, repo *Repository, ids restic.IDSet, printer restic.Printer) (restic.IDSet, error) {
+	restored := restic.NewIDSet()
+	for id := range ids {
+		_, err := repo.be.Stat(ctx, backend.Handle{Type: backend.QuarantineFile, Name: id.String()})
+		if repo.be.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return restored, err
+		}
+
+		_, err = repo.be.Stat(ctx, backend.Handle{Type: backend.PackFile, Name: id.String()})
+		if err == nil {
+			printer.E("pack file %v exists both in the data and the quarantine directory, using the former", id)
+			continue
+		} else if !repo.be.IsNotExist(err) {
+			return restored, err
+		}
+
+		printer.V("restoring quarantined pack file %v", id)
+		if err := repo.movePack(ctx, id, backend.QuarantineFile, backend.PackFile); err != nil {
+			return restored, err
+		}
+		restored.Insert(id)
+	}
+	return restored, nil
+}
+
+// movePack copies the pack file id from the src to the dst file type and
+// removes it afterwards. The content is not verified, as the pack file is
+// usually damaged.
+func (r *Repository) movePack(ctx context.Context, id restic.ID, src, dst backend.FileType) error {
+	srcHandle := backend.Handle{Type: src, Name: id.String()}
+	dstHandle := backend.Handle{Type: dst, Name: id.String()}
+	debug.Log("moving %v to %v", srcHandle, dstHandle)
+
+	buf, err := loadRaw(ctx, r.be, srcHandle)
+	if err != nil {
+		return errors.Wrapf(err, "load %v", srcHandle)
+	}
+	if err := r.be.Save(ctx, dstHandle, backend.NewByteReader(buf, r.be.Hasher())); err != nil {
+		return errors.Wrapf(err, "save %v", dstHandle)
+	}
+	if r.cache != nil {
+		// ignore error as there's not much we can do here
+		_ = r.cache.Forget(srcHandle)
+	}
+	return r.be.Remove(ctx, srcHandle)
+}
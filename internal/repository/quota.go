@@ -0,0 +1,27 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/restic/restic/internal/restic"
+)
+
+// Size returns the total size of the pack files as reported by the backend.
+// The pack files hold almost all data of a repository, the other files are
+// not listed to avoid listing index and snapshot files more than once.
+func (r *Repository) Size(ctx context.Context) (uint64, error) {
+	var size uint64
+	err := r.List(ctx, restic.PackFile, func(_ restic.ID, s int64) error {
+		size += uint64(s)
+		return nil
+	})
+	return size, err
+}
+
+// SetMaxRepoSize stores the size budget of the repository in the config, a
+// size of zero removes the budget. The caller must hold an exclusive lock.
+func (r *Repository) SetMaxRepoSize(ctx context.Context, size uint64) error {
+	cfg := r.Config()
+	cfg.MaxRepoSize = size
+	return r.replaceConfig(ctx, cfg, "restic-config-max-repo-size-")
+}
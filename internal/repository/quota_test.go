@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestSetMaxRepoSize(t *testing.T) {
+	repo, _, be := TestRepositoryWithVersion(t, 0)
+	id := repo.Config().ID
+
+	rtest.OK(t, repo.SetMaxRepoSize(context.TODO(), 1<<30))
+	rtest.Equals(t, uint64(1<<30), repo.Config().MaxRepoSize)
+
+	// the budget is persisted and the remaining config is unchanged
+	repo2 := TestOpenBackend(t, be)
+	rtest.Equals(t, uint64(1<<30), repo2.Config().MaxRepoSize)
+	rtest.Equals(t, id, repo2.Config().ID)
+
+	rtest.OK(t, repo2.SetMaxRepoSize(context.TODO(), 0))
+	rtest.Equals(t, uint64(0), TestOpenBackend(t, be).Config().MaxRepoSize)
+}
+
+func TestRepositorySize(t *testing.T) {
+	repo := TestRepository(t)
+
+	size, err := repo.Size(context.TODO())
+	rtest.OK(t, err)
+	rtest.Equals(t, uint64(0), size)
+
+	var packSize uint64
+	rtest.OK(t, repo.WithBlobUploader(context.TODO(), func(ctx context.Context, uploader restic.BlobSaverWithAsync) error {
+		_, _, _, err := uploader.SaveBlob(ctx, restic.DataBlob, []byte("data"), restic.ID{}, false)
+		return err
+	}))
+	rtest.OK(t, repo.List(context.TODO(), restic.PackFile, func(_ restic.ID, size int64) error {
+		packSize += uint64(size)
+		return nil
+	}))
+
+	size, err = repo.Size(context.TODO())
+	rtest.OK(t, err)
+	rtest.Assert(t, size > 0, "expected non-zero size")
+	rtest.Equals(t, packSize, size)
+}
+
+func TestSetMaxRepoSizeFailure(t *testing.T) {
+	// fail uploading the config after the initial write
+	be := &failBackend{
+		ConfigFileSavesUntilError: 1,
+		Backend:                   TestBackend(t),
+	}
+	repo, _ := TestRepositoryWithBackend(t, be, 0, Options{})
+	raw, err := repo.LoadRaw(context.TODO(), restic.ConfigFile, restic.ID{})
+	rtest.OK(t, err)
+
+	err = repo.SetMaxRepoSize(context.TODO(), 1<<30)
+	rtest.Assert(t, err != nil, "expected error")
+	rtest.Equals(t, uint64(0), repo.Config().MaxRepoSize)
+
+	// the previous config is kept in a local backup
+	var replaceErr *replaceConfigError
+	rtest.Assert(t, errors.As(err, &replaceErr), "unexpected error %v", err)
+	rtest.Assert(t, strings.Contains(err.Error(), replaceErr.BackupFilePath), "backup path missing in %q", err)
+	backup, err := os.ReadFile(replaceErr.BackupFilePath)
+	rtest.OK(t, err)
+	rtest.Equals(t, raw, backup)
+	rtest.OK(t, os.RemoveAll(filepath.Dir(replaceErr.BackupFilePath)))
+}
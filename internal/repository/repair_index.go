@@ -50,9 +50,14 @@ func RepairIndex(ctx context.Context, repo *Repository, opts RepairIndexOptions,
 	}
 
 	oldIndexes := repo.idx.IDs()
+	// packs marked for deletion by prune are kept as is
+	pending := repo.idx.PacksToDelete()
 
 	printer.P("getting pack files to read...\n")
 	err := repo.List(ctx, restic.PackFile, func(id restic.ID, packSize int64) error {
+		if _, ok := pending[id]; ok {
+			return nil
+		}
 		size, ok := packSizeFromIndex[id]
 		if !ok || size != packSize {
 			// Pack was not referenced in index or size does not match
@@ -104,10 +109,14 @@ func RepairIndex(ctx context.Context, repo *Repository, opts RepairIndexOptions,
 
 func rewriteIndexFiles(ctx context.Context, repo *Repository, removePacks restic.IDSet, oldIndexes restic.IDSet, extraObsolete restic.IDs, printer restic.Printer) error {
 	printer.P("rebuilding index\n")
+	return repo.idx.Rewrite(ctx, &internalRepository{repo}, removePacks, oldIndexes, extraObsolete, indexRewriteOpts(printer))
+}
 
-	bar := printer.NewCounter("indexes processed")
-	return repo.idx.Rewrite(ctx, &internalRepository{repo}, removePacks, oldIndexes, extraObsolete, index.MasterIndexRewriteOpts{
-		SaveProgress: bar,
+// indexRewriteOpts returns the options to report the progress of an index
+// rewrite using printer.
+func indexRewriteOpts(printer restic.Printer) index.MasterIndexRewriteOpts {
+	return index.MasterIndexRewriteOpts{
+		SaveProgress: printer.NewCounter("indexes processed"),
 		DeleteProgress: func() restic.Counter {
 			return printer.NewCounter("old indexes deleted")
 		},
@@ -118,5 +127,5 @@ func rewriteIndexFiles(ctx context.Context, repo *Repository, removePacks restic
 				printer.VV("removed index %v\n", id.String())
 			}
 		},
-	})
+	}
 }
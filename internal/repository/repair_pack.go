@@ -10,7 +10,11 @@ import (
 	"github.com/restic/restic/internal/restic"
 )
 
-func RepairPacks(ctx context.Context, repo *Repository, ids restic.IDSet, printer restic.Printer) error {
+// RepairPacks salvages the intact blobs from the pack files ids and removes
+// the pack files afterwards. Damaged blobs are healed using an intact copy from
+// another pack file if available. The returned set contains all blobs for which
+// no intact copy was found, these are lost.
+func RepairPacks(ctx context.Context, repo *Repository, ids restic.IDSet, printer restic.Printer) (restic.BlobSet, error) {
 	printer.P("salvaging intact data from specified pack files")
 	bar := printer.NewCounter("pack files")
 	bar.SetMax(uint64(len(ids)))
@@ -18,14 +22,19 @@ func RepairPacks(ctx context.Context, repo *Repository, ids restic.IDSet, printe
 
 	packToBlobs, err := resolveBlobsForPacks(ctx, repo, ids)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	stats := &salvageStats{
+		healed: restic.NewBlobSet(),
+		lost:   restic.NewBlobSet(),
 	}
 
 	err = repo.WithBlobUploader(ctx, func(ctx context.Context, uploader restic.BlobSaverWithAsync) error {
 		// examine all data the indexes have for the pack file
 		for b := range repo.listPacksFromIndex(ctx, ids) {
 			indexBlobs := b.Blobs
-			err := reuploadBlobsFromPack(ctx, repo, b.PackID, indexBlobs, printer, uploader)
+			err := reuploadBlobsFromPack(ctx, repo, b.PackID, indexBlobs, printer, uploader, stats)
 			if err != nil {
 				return err
 			}
@@ -37,7 +46,7 @@ func RepairPacks(ctx context.Context, repo *Repository, ids restic.IDSet, printe
 				// handle case where the index entry is broken or incomplete.
 				// this can result in duplicate blobs, which can be cleaned up by running prune.
 				printer.E("repairing incomplete index entry for pack %v", b.PackID)
-				err := reuploadBlobsFromPack(ctx, repo, b.PackID, packBlobs, printer, uploader)
+				err := reuploadBlobsFromPack(ctx, repo, b.PackID, packBlobs, printer, uploader, stats)
 				if err != nil {
 					return err
 				}
@@ -51,14 +60,23 @@ func RepairPacks(ctx context.Context, repo *Repository, ids restic.IDSet, printe
 		return nil
 	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 	bar.Done()
 
+	// a blob may have been lost in one pack file, but salvaged from another one
+	stats.lost = stats.lost.Sub(stats.healed)
+	if len(stats.healed) > 0 {
+		printer.P("healed %d damaged blobs using intact copies from other pack files or parity", len(stats.healed))
+	}
+	if len(stats.lost) > 0 {
+		printer.E("%d blobs could not be salvaged", len(stats.lost))
+	}
+
 	// remove salvaged packs from index
 	err = rewriteIndexFiles(ctx, repo, ids, nil, nil, printer)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// cleanup
@@ -74,7 +92,13 @@ func RepairPacks(ctx context.Context, repo *Repository, ids restic.IDSet, printe
 	}, bar)
 	bar.Done()
 
-	return nil
+	return stats.lost, nil
+}
+
+// salvageStats tracks which damaged blobs were healed and which are lost.
+type salvageStats struct {
+	healed restic.BlobSet
+	lost   restic.BlobSet
 }
 
 func resolveBlobsForPacks(ctx context.Context, repo *Repository, ids restic.IDSet) (map[restic.ID]pack.Blobs, error) {
@@ -97,10 +121,21 @@ func resolveBlobsForPacks(ctx context.Context, repo *Repository, ids restic.IDSe
 	return packToBlobs, nil
 }
 
-func reuploadBlobsFromPack(ctx context.Context, repo *Repository, packID restic.ID, blobs pack.Blobs, printer restic.Printer, uploader restic.BlobSaverWithAsync) error {
-	err := repo.loadBlobsFromPack(ctx, packID, blobs, func(blob restic.BlobHandle, buf []byte, err error) error {
+func reuploadBlobsFromPack(ctx context.Context, repo *Repository, packID restic.ID, blobs pack.Blobs, printer restic.Printer, uploader restic.BlobSaverWithAsync, stats *salvageStats) error {
+	// loadBlob is only called for blobs which are damaged in the pack file
+	loadBlob := func(ctx context.Context, blob restic.BlobHandle, buf []byte) ([]byte, error) {
+		buf, err := repo.LoadBlob(ctx, blob, buf)
+		if err == nil {
+			printer.V("healed blob %v using an intact copy or parity", blob)
+			stats.healed.Insert(blob)
+		}
+		return buf, err
+	}
+
+	err := streamPack(ctx, repo.be.Load, loadBlob, repo.getZstdDecoder(), repo.key, packID, blobs, func(blob restic.BlobHandle, buf []byte, err error) error {
 		if err != nil {
 			printer.E("failed to load blob %v: %v", blob.ID, err)
+			stats.lost.Insert(blob)
 			return nil
 		}
 		id, _, _, err := uploader.SaveBlob(ctx, blob.Type, buf, restic.ID{}, true)
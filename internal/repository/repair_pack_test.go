@@ -74,6 +74,22 @@ func testRepairBrokenPack(t *testing.T, version uint) {
 
 				return restic.NewIDSet(damagedID), restic.NewBlobSet(damagedBlob)
 			},
+		}, {
+			"broken pack with intact duplicate",
+			func(t *testing.T, random *rand.Rand, repo *repository.Repository, be backend.Backend, packsBefore restic.IDSet) (restic.IDSet, restic.BlobSet) {
+				// store a damaged duplicate of an existing blob in a new pack file
+				blob := listBlobs(repo).List()[0]
+				buf, err := repo.LoadBlob(context.TODO(), blob, nil)
+				rtest.OK(t, err)
+				buf[0] ^= 0xff
+				rtest.OK(t, repo.WithBlobUploader(context.TODO(), func(ctx context.Context, uploader restic.BlobSaverWithAsync) error {
+					_, _, _, err := uploader.SaveBlob(ctx, blob.Type, buf, blob.ID, true)
+					return err
+				}))
+
+				// the damaged blob is healed using the intact copy
+				return listPacks(t, repo).Sub(packsBefore), restic.NewBlobSet()
+			},
 		}, {
 			"truncated pack",
 			func(t *testing.T, random *rand.Rand, repo *repository.Repository, be backend.Backend, packsBefore restic.IDSet) (restic.IDSet, restic.BlobSet) {
@@ -128,7 +144,9 @@ func testRepairBrokenPack(t *testing.T, version uint) {
 
 			toRepair, damagedBlobs := test.damage(t, random, repo, be, packsBefore)
 
-			rtest.OK(t, repository.RepairPacks(context.TODO(), repo, toRepair, restic.NewNoopPrinter()))
+			lost, err := repository.RepairPacks(context.TODO(), repo, toRepair, restic.NewNoopPrinter())
+			rtest.OK(t, err)
+			rtest.Assert(t, lost.Equals(damagedBlobs), "unexpected lost blobs, got %v, expected %v", lost, damagedBlobs)
 			// reload index
 			rtest.OK(t, repo.LoadIndex(context.TODO(), restic.NoopTerminalCounterFactory))
 
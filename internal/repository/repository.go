@@ -6,8 +6,10 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"os"
 	"runtime"
 	"sync"
+	"time"
 
 	"github.com/klauspost/compress/zstd"
 	"github.com/restic/chunker"
@@ -37,6 +39,12 @@ type Repository struct {
 	idx   *index.MasterIndex
 	cache *cache.Cache
 
+	// keyFile is the key file which was used to open the repository
+	keyFile *Key
+
+	// dryRun is set if the repository is in dry-run mode
+	dryRun *dryrun.Backend
+
 	opts Options
 
 	packerWg    *errgroup.Group
@@ -52,8 +60,21 @@ type Repository struct {
 	enc      *zstd.Encoder
 	dec      *zstd.Decoder
 
+	// encoders for data blobs which override the compression mode
+	levelEncM sync.Mutex
+	levelEnc  map[zstd.EncoderLevel]*zstd.Encoder
+
 	zeroChunkOnce sync.Once
 	zeroChunkID   restic.ID
+
+	// generation markers seen by LockRepoOptimistic
+	generation restic.IDSet
+
+	// last pack file reconstructed using its parity section
+	parityMu     sync.Mutex
+	parityPackID restic.ID
+	parityPack   []byte
+	parityResult pack.ReconstructResult
 }
 
 // internalRepository allows using SaveUnpacked and RemoveUnpacked with all FileTypes
@@ -65,6 +86,19 @@ type Options struct {
 	Compression   CompressionMode
 	PackSize      uint
 	NoExtraVerify bool
+	// TreePackSize and DataPackSize override PackSize for packs containing
+	// tree and data blobs, respectively. Zero means PackSize is used.
+	TreePackSize uint
+	DataPackSize uint
+	// LowMemoryIndex stores the index in memory-mapped files in the cache
+	// directory instead of keeping it in memory.
+	LowMemoryIndex bool
+	// HedgeReadAfter is the duration after which a second request is issued
+	// if loading a blob has not completed yet. Zero disables hedged requests.
+	HedgeReadAfter time.Duration
+	// Parity configures the Reed-Solomon parity section added to new pack
+	// files. A zero ParityShards disables parity sections.
+	Parity pack.Parity
 }
 
 // CompressionMode configures if data should be compressed.
@@ -131,18 +165,29 @@ func New(be backend.Backend, opts Options) (*Repository, error) {
 	if opts.PackSize == 0 {
 		opts.PackSize = DefaultPackSize
 	}
-	if opts.PackSize > MaxPackSize {
-		return nil, fmt.Errorf("pack size larger than limit of %v MiB", MaxPackSize/1024/1024)
-	} else if opts.PackSize < MinPackSize {
-		return nil, fmt.Errorf("pack size smaller than minimum of %v MiB", MinPackSize/1024/1024)
+	if opts.LowMemoryIndex && !index.MappedIndexSupported {
+		return nil, errors.New("low memory index is not supported on this platform")
+	}
+	if opts.TreePackSize == 0 {
+		opts.TreePackSize = opts.PackSize
+	}
+	if opts.DataPackSize == 0 {
+		opts.DataPackSize = opts.PackSize
+	}
+	for _, size := range []uint{opts.PackSize, opts.TreePackSize, opts.DataPackSize} {
+		if size > MaxPackSize {
+			return nil, fmt.Errorf("pack size larger than limit of %v MiB", MaxPackSize/1024/1024)
+		} else if size < MinPackSize {
+			return nil, fmt.Errorf("pack size smaller than minimum of %v MiB", MinPackSize/1024/1024)
+		}
 	}
 
 	repo := &Repository{
 		be:          be,
 		opts:        opts,
-		idx:         index.NewMasterIndex(),
 		packerCount: defaultPackerCount,
 	}
+	repo.idx = repo.newMasterIndex()
 
 	return repo, nil
 }
@@ -162,6 +207,30 @@ func (r *Repository) PackSize() uint {
 	return r.opts.PackSize
 }
 
+// PackSizeFor returns the target size of a pack file containing blobs of
+// type t when uploading.
+func (r *Repository) PackSizeFor(t restic.BlobType) uint {
+	switch t {
+	case restic.TreeBlob:
+		return r.opts.TreePackSize
+	case restic.DataBlob:
+		return r.opts.DataPackSize
+	default:
+		return r.opts.PackSize
+	}
+}
+
+// BackendCapabilities returns the permissions of the credentials used to
+// access the backend. The second return value is false if the backend cannot
+// determine them.
+func (r *Repository) BackendCapabilities() (backend.Capabilities, bool) {
+	be := backend.AsBackend[backend.CapabilitiesBackend](r.be)
+	if be == nil {
+		return backend.Capabilities{}, false
+	}
+	return be.Capabilities()
+}
+
 // UseCache replaces the backend with the wrapped cache.
 func (r *Repository) UseCache(c *cache.Cache, errorLog func(string, ...interface{})) {
 	if c == nil {
@@ -170,6 +239,10 @@ func (r *Repository) UseCache(c *cache.Cache, errorLog func(string, ...interface
 	debug.Log("using cache")
 	r.cache = c
 	r.be = c.Wrap(r.be, errorLog)
+	if r.opts.LowMemoryIndex {
+		// store the index in the cache directory, the index is not loaded yet
+		r.idx = r.newMasterIndex()
+	}
 }
 
 func (r *Repository) Cache() *cache.Cache {
@@ -178,7 +251,14 @@ func (r *Repository) Cache() *cache.Cache {
 
 // SetDryRun sets the repo backend into dry-run mode.
 func (r *Repository) SetDryRun() {
-	r.be = dryrun.New(r.be)
+	r.dryRun = dryrun.New(r.be)
+	r.be = r.dryRun
+}
+
+// DryRunStats returns the files which would have been uploaded in dry-run
+// mode. It must only be called after SetDryRun.
+func (r *Repository) DryRunStats() dryrun.Stats {
+	return r.dryRun.Stats()
 }
 
 func (r *Repository) Checker() *Checker {
@@ -270,6 +350,14 @@ func (r *Repository) LoadBlob(ctx context.Context, bh restic.BlobHandle, buf []b
 
 		buf, err = r.loadBlob(ctx, blobs, buf)
 	}
+	if err != nil {
+		// the blob is damaged in all pack files, try to reconstruct it
+		pbuf, perr := r.loadBlobFromParity(ctx, blobs, buf)
+		if perr == nil {
+			return pbuf, nil
+		}
+		debug.Log("reconstructing %v using parity failed: %v", bh, perr)
+	}
 	return buf, err
 }
 
@@ -287,7 +375,8 @@ func (r *Repository) loadBlob(ctx context.Context, blobs []*pack.PackedBlob, buf
 			buf = buf[:blob.Blob.Length]
 		}
 
-		_, err := backend.ReadAt(ctx, r.be, h, int64(blob.Blob.Offset), buf)
+		var err error
+		buf, err = r.readAt(ctx, h, int64(blob.Blob.Offset), buf)
 		if err != nil {
 			debug.Log("error loading blob %v: %v", blob, err)
 			lastError = err
@@ -338,26 +427,61 @@ func (r *Repository) getZstdEncoder() *zstd.Encoder {
 			level = zstd.SpeedDefault
 		}
 
-		opts := []zstd.EOption{
-			// Set the compression level configured.
-			zstd.WithEncoderLevel(level),
-			// Disable CRC, we have enough checks in place, makes the
-			// compressed data four bytes shorter.
-			zstd.WithEncoderCRC(false),
-			// Set a window of 512kbyte, so we have good lookbehind for usual
-			// blob sizes.
-			zstd.WithWindowSize(512 * 1024),
-		}
-
-		enc, err := zstd.NewWriter(nil, opts...)
-		if err != nil {
-			panic(err)
-		}
-		r.enc = enc
+		r.enc = newZstdEncoder(level)
 	})
 	return r.enc
 }
 
+// getZstdEncoderFor returns the encoder for data blobs saved using the
+// compression level l.
+func (r *Repository) getZstdEncoderFor(l restic.CompressionLevel) *zstd.Encoder {
+	var level zstd.EncoderLevel
+	switch l {
+	case restic.CompressionLevelFastest:
+		level = zstd.SpeedFastest
+	case restic.CompressionLevelAuto:
+		level = zstd.SpeedDefault
+	case restic.CompressionLevelBetter:
+		level = zstd.SpeedBetterCompression
+	case restic.CompressionLevelMax:
+		level = zstd.SpeedBestCompression
+	default:
+		return r.getZstdEncoder()
+	}
+
+	r.levelEncM.Lock()
+	defer r.levelEncM.Unlock()
+
+	enc, ok := r.levelEnc[level]
+	if !ok {
+		if r.levelEnc == nil {
+			r.levelEnc = make(map[zstd.EncoderLevel]*zstd.Encoder)
+		}
+		enc = newZstdEncoder(level)
+		r.levelEnc[level] = enc
+	}
+	return enc
+}
+
+func newZstdEncoder(level zstd.EncoderLevel) *zstd.Encoder {
+	opts := []zstd.EOption{
+		// Set the compression level configured.
+		zstd.WithEncoderLevel(level),
+		// Disable CRC, we have enough checks in place, makes the
+		// compressed data four bytes shorter.
+		zstd.WithEncoderCRC(false),
+		// Set a window of 512kbyte, so we have good lookbehind for usual
+		// blob sizes.
+		zstd.WithWindowSize(512 * 1024),
+	}
+
+	enc, err := zstd.NewWriter(nil, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return enc
+}
+
 func (r *Repository) getZstdDecoder() *zstd.Decoder {
 	r.allocDec.Do(func() {
 		opts := []zstd.DOption{
@@ -381,7 +505,8 @@ func (r *Repository) getZstdDecoder() *zstd.Decoder {
 // is small enough, it will be packed together with other small blobs. The
 // caller must ensure that the id matches the data. Returned is the size data
 // occupies in the repo (compressed or not, including the encryption overhead).
-func (r *Repository) saveAndEncrypt(ctx context.Context, t restic.BlobType, data []byte, id restic.ID) (size int, err error) {
+// level overrides the compression mode for data blobs.
+func (r *Repository) saveAndEncrypt(ctx context.Context, t restic.BlobType, data []byte, id restic.ID, level restic.CompressionLevel) (size int, err error) {
 	debug.Log("save id %v (%v, %d bytes)", id, t, len(data))
 
 	uncompressedLength := 0
@@ -392,9 +517,15 @@ func (r *Repository) saveAndEncrypt(ctx context.Context, t restic.BlobType, data
 		// uncompressedLength != 0 is used to indicate compressed data. Thus, a zero-sized blob
 		// cannot be compressed. This special case is only relevant for tests, normal operation does not
 		// generate zero-sized blobs.
-		if len(data) > 0 && (r.opts.Compression != CompressionOff || t != restic.DataBlob) {
+		enc := r.getZstdEncoder()
+		compress := r.opts.Compression != CompressionOff || t != restic.DataBlob
+		if t == restic.DataBlob && level != restic.CompressionLevelDefault {
+			compress = level != restic.CompressionLevelOff
+			enc = r.getZstdEncoderFor(level)
+		}
+		if len(data) > 0 && compress {
 			uncompressedLength = len(data)
-			data = r.getZstdEncoder().EncodeAll(data, nil)
+			data = enc.EncodeAll(data, nil)
 		}
 	}
 
@@ -569,6 +700,11 @@ func (r *Repository) removeUnpacked(ctx context.Context, t restic.FileType, id r
 }
 
 func (r *Repository) WithBlobUploader(ctx context.Context, fn func(ctx context.Context, uploader restic.BlobSaverWithAsync) error) error {
+	if r.opts.Parity.ParityShards > 0 && r.cfg.Version < 3 {
+		// earlier restic versions cannot read pack files with a parity section
+		return errors.Fatal("repo.parity requires at least repository format version 3")
+	}
+
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 	wg, ctx := errgroup.WithContext(ctx)
@@ -609,8 +745,12 @@ func (r *Repository) startPackUploader(ctx context.Context, wg *errgroup.Group)
 	innerWg, ctx := errgroup.WithContext(ctx)
 	r.packerWg = innerWg
 	r.uploader = newPackerUploader(ctx, innerWg, r, r.Connections())
-	r.treePM = newPackerManager(r.key, restic.TreeBlob, r.PackSize(), r.packerCount, r.uploader.QueuePacker)
-	r.dataPM = newPackerManager(r.key, restic.DataBlob, r.PackSize(), r.packerCount, r.uploader.QueuePacker)
+	r.treePM = newPackerManager(r.key, restic.TreeBlob, r.PackSizeFor(restic.TreeBlob), r.packerCount, r.uploader.QueuePacker)
+	r.dataPM = newPackerManager(r.key, restic.DataBlob, r.PackSizeFor(restic.DataBlob), r.packerCount, r.uploader.QueuePacker)
+	if r.opts.Parity.ParityShards > 0 {
+		r.treePM.parity = &r.opts.Parity
+		r.dataPM.parity = &r.opts.Parity
+	}
 
 	wg.Go(func() error {
 		return innerWg.Wait()
@@ -618,15 +758,23 @@ func (r *Repository) startPackUploader(ctx context.Context, wg *errgroup.Group)
 }
 
 type blobSaverRepo struct {
-	repo *Repository
+	repo        *Repository
+	compression restic.CompressionLevel
 }
 
+var _ restic.CompressionSelector = &blobSaverRepo{}
+
 func (r *blobSaverRepo) SaveBlob(ctx context.Context, t restic.BlobType, buf []byte, id restic.ID, storeDuplicate bool) (newID restic.ID, known bool, size int, err error) {
-	return r.repo.saveBlob(ctx, t, buf, id, storeDuplicate)
+	return r.repo.saveBlob(ctx, t, buf, id, storeDuplicate, r.compression)
 }
 
 func (r *blobSaverRepo) SaveBlobAsync(ctx context.Context, t restic.BlobType, buf []byte, id restic.ID, storeDuplicate bool, cb func(newID restic.ID, known bool, size int, err error)) {
-	r.repo.saveBlobAsync(ctx, t, buf, id, storeDuplicate, cb)
+	r.repo.saveBlobAsync(ctx, t, buf, id, storeDuplicate, r.compression, cb)
+}
+
+// WithCompression returns a blob saver which compresses data blobs using level.
+func (r *blobSaverRepo) WithCompression(level restic.CompressionLevel) restic.BlobSaverWithAsync {
+	return &blobSaverRepo{repo: r.repo, compression: level}
 }
 
 // Flush saves all remaining packs and the index
@@ -692,6 +840,13 @@ func (r *Repository) LookupBlobSize(bh restic.BlobHandle) (uint, bool) {
 	return r.idx.LookupSize(bh)
 }
 
+// HasBlob returns whether new data can reference the blob. Also returns true
+// for pending blobs, but not for blobs which are only contained in packs
+// marked for deletion by prune.
+func (r *Repository) HasBlob(bh restic.BlobHandle) bool {
+	return r.idx.Has(bh)
+}
+
 // ListBlobs runs fn on all blobs known to the index. When the context is cancelled,
 // the index iteration returns immediately with ctx.Err(). This blocks any modification of the index.
 func (r *Repository) ListBlobs(ctx context.Context, fn func(restic.PackBlob)) error {
@@ -704,13 +859,31 @@ func (r *Repository) ListBlobs(ctx context.Context, fn func(restic.PackBlob)) er
 	return nil
 }
 
+// ListParity runs fn on all packs with a parity section known to the index.
+func (r *Repository) ListParity(ctx context.Context, fn func(id restic.ID, parity pack.Parity)) error {
+	return r.idx.ListParity(ctx, fn)
+}
+
 // listPacksFromIndex returns index entries for the given packs, grouped by pack file.
 func (r *Repository) listPacksFromIndex(ctx context.Context, packs restic.IDSet) <-chan index.PackBlobs {
 	return r.idx.ListPacks(ctx, packs)
 }
 
 func (r *Repository) clearIndex() {
-	r.idx = index.NewMasterIndex()
+	r.idx = r.newMasterIndex()
+}
+
+// newMasterIndex returns an empty master index. With the LowMemoryIndex
+// option, it is stored in memory-mapped files in the cache directory.
+func (r *Repository) newMasterIndex() *index.MasterIndex {
+	if !r.opts.LowMemoryIndex {
+		return index.NewMasterIndex()
+	}
+	dir := os.TempDir()
+	if r.cache != nil {
+		dir = r.cache.BaseDir()
+	}
+	return index.NewMappedMasterIndex(dir)
 }
 
 // LoadIndex loads all index files from the backend in parallel and stores them
@@ -792,13 +965,13 @@ func (r *Repository) createIndexFromPacks(ctx context.Context, packsize map[rest
 	// a worker receives an pack ID from ch, reads the pack contents, and adds them to idx
 	worker := func() error {
 		for fi := range ch {
-			entries, err := r.listPack(wgCtx, fi.ID, fi.Size)
+			entries, parity, err := r.listPackWithParity(wgCtx, fi.ID, fi.Size)
 			if err != nil {
 				debug.Log("unable to list pack file %v", fi.ID.Str())
 				m.Lock()
 				invalid = append(invalid, fi.ID)
 				m.Unlock()
-			} else if err := r.idx.StorePack(wgCtx, fi.ID, entries, &internalRepository{r}); err != nil {
+			} else if err := r.idx.StorePackWithParity(wgCtx, fi.ID, entries, parity, &internalRepository{r}); err != nil {
 				return err
 			}
 			p.Add(1)
@@ -865,20 +1038,59 @@ func (r *Repository) prepareCache() error {
 // SearchKey finds a key with the supplied password, afterwards the config is
 // read and parsed. It tries at most maxKeys key files in the repo.
 func (r *Repository) SearchKey(ctx context.Context, password string, maxKeys int, keyHint string) error {
-	key, err := searchKey(ctx, r, password, maxKeys, keyHint)
-	if err != nil {
-		return err
+	return r.searchAndUseKey(ctx, func(ctx context.Context, id restic.ID) (*Key, error) {
+		return openKey(ctx, r, id, password)
+	}, maxKeys, keyHint)
+}
+
+// SearchKeyWithIdentities finds a key which can be decrypted by one of the
+// identities, afterwards the config is read and parsed. It tries at most
+// maxKeys key files in the repo.
+func (r *Repository) SearchKeyWithIdentities(ctx context.Context, identities []*crypto.Identity, maxKeys int, keyHint string) error {
+	return r.searchAndUseKey(ctx, func(ctx context.Context, id restic.ID) (*Key, error) {
+		return openIdentityKey(ctx, r, id, identities)
+	}, maxKeys, keyHint)
+}
+
+// searchAndUseKey switches to the first key which can be opened and which
+// can decrypt the config. Keys which cannot decrypt the config are skipped,
+// this happens for keys left over by an interrupted master key rotation.
+func (r *Repository) searchAndUseKey(ctx context.Context, open func(ctx context.Context, id restic.ID) (*Key, error), maxKeys int, keyHint string) error {
+	var configErr error
+	_, err := searchKey(ctx, r, func(ctx context.Context, id restic.ID) (*Key, error) {
+		key, err := open(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		err = r.useKey(ctx, key)
+		if err != nil {
+			if errors.Is(err, crypto.ErrUnauthenticated) && configErr == nil {
+				configErr = err
+			}
+			return nil, err
+		}
+		return key, nil
+	}, maxKeys, keyHint)
+	if errors.Is(err, ErrNoKeyFound) && configErr != nil {
+		return configErr
 	}
+	return err
+}
 
+// useKey switches to the master key of key and loads the config.
+func (r *Repository) useKey(ctx context.Context, key *Key) error {
 	oldKey := r.key
 	oldKeyID := r.keyID
+	oldKeyFile := r.keyFile
 
 	r.key = key.master
 	r.keyID = key.ID()
+	r.keyFile = key
 	cfg, err := restic.LoadConfig(ctx, r)
 	if err != nil {
 		r.key = oldKey
 		r.keyID = oldKeyID
+		r.keyFile = oldKeyFile
 
 		if err == crypto.ErrUnauthenticated {
 			return fmt.Errorf("config or key %v is damaged: %w", key.ID(), err)
@@ -886,13 +1098,17 @@ func (r *Repository) SearchKey(ctx context.Context, password string, maxKeys int
 		return fmt.Errorf("config cannot be loaded: %w", err)
 	}
 
+	if cfg.KeyRotation != nil {
+		// data stored before the rotation of the master key started
+		r.key = key.master.WithPrevious(cfg.KeyRotation.PreviousKey)
+	}
 	r.setConfig(cfg)
 	return nil
 }
 
 // Init creates a new master key with the supplied password, initializes and
 // saves the repository config.
-func (r *Repository) Init(ctx context.Context, version uint, password string, chunkerPolynomial *chunker.Pol) error {
+func (r *Repository) Init(ctx context.Context, version uint, password string, chunkerPolynomial *chunker.Pol, chunkerParams *restic.ChunkerParams) error {
 	if version > restic.MaxRepoVersion {
 		return fmt.Errorf("repository version %v too high", version)
 	}
@@ -926,7 +1142,7 @@ func (r *Repository) Init(ctx context.Context, version uint, password string, ch
 		return err
 	}
 
-	cfg, err := restic.CreateConfig(version, chunkerPolynomial)
+	cfg, err := restic.CreateConfig(version, chunkerPolynomial, chunkerParams)
 	if err != nil {
 		return err
 	}
@@ -944,6 +1160,7 @@ func (r *Repository) init(ctx context.Context, password string, cfg restic.Confi
 
 	r.key = key.master
 	r.keyID = key.ID()
+	r.keyFile = key
 	r.setConfig(cfg)
 	return restic.SaveConfig(ctx, &internalRepository{r}, cfg)
 }
@@ -972,9 +1189,16 @@ func (r *Repository) List(ctx context.Context, t restic.FileType, fn func(restic
 
 // listPack returns blob entries from the pack file header including offsets.
 func (r *Repository) listPack(ctx context.Context, id restic.ID, size int64) (pack.Blobs, error) {
+	entries, _, err := r.listPackWithParity(ctx, id, size)
+	return entries, err
+}
+
+// listPackWithParity works like listPack, but additionally returns the parity
+// section of the pack file.
+func (r *Repository) listPackWithParity(ctx context.Context, id restic.ID, size int64) (pack.Blobs, *pack.Parity, error) {
 	h := backend.Handle{Type: backend.PackFile, Name: id.String()}
 
-	entries, _, err := pack.List(r.Key(), backend.ReaderAt(ctx, r.be, h), size)
+	entries, parity, _, err := pack.ListWithParity(r.Key(), backend.ReaderAt(ctx, r.be, h), size)
 	if err != nil {
 		if r.cache != nil {
 			// ignore error as there is not much we can do here
@@ -982,9 +1206,9 @@ func (r *Repository) listPack(ctx context.Context, id restic.ID, size int64) (pa
 		}
 
 		// retry on error
-		entries, _, err = pack.List(r.Key(), backend.ReaderAt(ctx, r.be, h), size)
+		entries, parity, _, err = pack.ListWithParity(r.Key(), backend.ReaderAt(ctx, r.be, h), size)
 	}
-	return pack.Blobs(entries), err
+	return pack.Blobs(entries), parity, err
 }
 
 // ListPackHandles returns the blob handles stored in the pack file header.
@@ -1018,7 +1242,7 @@ func (r *Repository) Close() error {
 // Also returns if the blob was already known before.
 // If the blob was not known before, it returns the number of bytes the blob
 // occupies in the repo (compressed or not, including encryption overhead).
-func (r *Repository) saveBlob(ctx context.Context, t restic.BlobType, buf []byte, id restic.ID, storeDuplicate bool) (newID restic.ID, known bool, size int, err error) {
+func (r *Repository) saveBlob(ctx context.Context, t restic.BlobType, buf []byte, id restic.ID, storeDuplicate bool, level restic.CompressionLevel) (newID restic.ID, known bool, size int, err error) {
 
 	if int64(len(buf)) > math.MaxUint32 {
 		return restic.ID{}, false, 0, fmt.Errorf("blob is larger than 4GB")
@@ -1029,7 +1253,7 @@ func (r *Repository) saveBlob(ctx context.Context, t restic.BlobType, buf []byte
 		// Special case the hash calculation for all zero chunks. This is especially
 		// useful for sparse files containing large all zero regions. For these we can
 		// process chunks as fast as we can read the from disk.
-		if len(buf) == chunker.MinSize && restic.ZeroPrefixLen(buf) == chunker.MinSize {
+		if minSize := int(r.cfg.ChunkerParameters().MinSize); len(buf) == minSize && restic.ZeroPrefixLen(buf) == minSize {
 			newID = r.zeroChunk()
 		} else {
 			newID = restic.Hash(buf)
@@ -1043,20 +1267,20 @@ func (r *Repository) saveBlob(ctx context.Context, t restic.BlobType, buf []byte
 
 	// only save when needed or explicitly told
 	if !known || storeDuplicate {
-		size, err = r.saveAndEncrypt(ctx, t, buf, newID)
+		size, err = r.saveAndEncrypt(ctx, t, buf, newID, level)
 	}
 
 	return newID, known, size, err
 }
 
-func (r *Repository) saveBlobAsync(ctx context.Context, t restic.BlobType, buf []byte, id restic.ID, storeDuplicate bool, cb func(newID restic.ID, known bool, size int, err error)) {
+func (r *Repository) saveBlobAsync(ctx context.Context, t restic.BlobType, buf []byte, id restic.ID, storeDuplicate bool, level restic.CompressionLevel, cb func(newID restic.ID, known bool, size int, err error)) {
 	r.mainWg.Go(func() error {
 		if ctx.Err() != nil {
 			// fail fast if the context is cancelled
 			cb(restic.ID{}, false, 0, ctx.Err())
 			return ctx.Err()
 		}
-		newID, known, size, err := r.saveBlob(ctx, t, buf, id, storeDuplicate)
+		newID, known, size, err := r.saveBlob(ctx, t, buf, id, storeDuplicate, level)
 		cb(newID, known, size, err)
 		return err
 	})
@@ -1349,7 +1573,7 @@ func (b *packBlobIterator) Next() (packBlobValue, error) {
 
 func (r *Repository) zeroChunk() restic.ID {
 	r.zeroChunkOnce.Do(func() {
-		r.zeroChunkID = restic.Hash(make([]byte, chunker.MinSize))
+		r.zeroChunkID = restic.Hash(make([]byte, r.cfg.ChunkerParameters().MinSize))
 	})
 	return r.zeroChunkID
 }
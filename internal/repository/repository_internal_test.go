@@ -224,7 +224,7 @@ func testStreamPack(t *testing.T, version uint) {
 	switch version {
 	case 1:
 		compress = false
-	case 2:
+	case 2, 3:
 		compress = true
 	default:
 		t.Fatal("test does not support repository version", version)
@@ -101,6 +101,40 @@ func testSaveLoadZeroSizedBlob(t *testing.T, version uint) {
 	rtest.Equals(t, 0, len(buf))
 }
 
+func TestSaveWithCompression(t *testing.T) {
+	repo, _, _ := repository.TestRepositoryWithVersion(t, 2)
+	data := bytes.Repeat([]byte("compressible "), 1000)
+
+	for _, test := range []struct {
+		level      restic.CompressionLevel
+		compressed bool
+	}{
+		{restic.CompressionLevelOff, false},
+		{restic.CompressionLevelMax, true},
+	} {
+		t.Run(test.level.String(), func(t *testing.T) {
+			// make the blob unique for each level
+			buf := append([]byte(test.level.String()), data...)
+			id := restic.Hash(buf)
+
+			rtest.OK(t, repo.WithBlobUploader(context.TODO(), func(ctx context.Context, uploader restic.BlobSaverWithAsync) error {
+				sel, ok := uploader.(restic.CompressionSelector)
+				rtest.Assert(t, ok, "uploader does not support selecting the compression level")
+				_, _, _, err := sel.WithCompression(test.level).SaveBlob(ctx, restic.DataBlob, buf, id, false)
+				return err
+			}))
+
+			pbs := repo.LookupBlob(restic.BlobHandle{Type: restic.DataBlob, ID: id})
+			rtest.Equals(t, 1, len(pbs))
+			rtest.Equals(t, test.compressed, pbs[0].IsCompressed())
+
+			loaded, err := repo.LoadBlob(context.TODO(), restic.BlobHandle{Type: restic.DataBlob, ID: id}, nil)
+			rtest.OK(t, err)
+			rtest.Assert(t, bytes.Equal(loaded, buf), "data mismatch")
+		})
+	}
+}
+
 func TestSavePackMerging(t *testing.T) {
 	t.Run("75%", func(t *testing.T) {
 		testSavePackMerging(t, 75, 1)
@@ -339,6 +373,46 @@ func loadIndex(ctx context.Context, repo restic.LoaderUnpacked, id restic.ID) (*
 	return index.DecodeIndex(buf, id)
 }
 
+// stallOnceBackend blocks the first load of each pack file until it is
+// canceled.
+type stallOnceBackend struct {
+	backend.Backend
+	m        sync.Map
+	canceled atomic.Int32
+}
+
+func (be *stallOnceBackend) Load(ctx context.Context, h backend.Handle, length int, offset int64, fn func(rd io.Reader) error) error {
+	if h.Type == backend.PackFile {
+		if _, loaded := be.m.LoadOrStore(h.Name, true); !loaded {
+			<-ctx.Done()
+			be.canceled.Add(1)
+			return ctx.Err()
+		}
+	}
+	return be.Backend.Load(ctx, h, length, offset, fn)
+}
+
+func TestLoadBlobHedged(t *testing.T) {
+	be := &stallOnceBackend{Backend: mem.New()}
+	repo, _ := repository.TestRepositoryWithBackend(t, be, restic.StableRepoVersion, repository.Options{HedgeReadAfter: 10 * time.Millisecond})
+	buf := rtest.Random(42, 1000)
+
+	var id restic.ID
+	rtest.OK(t, repo.WithBlobUploader(context.TODO(), func(ctx context.Context, uploader restic.BlobSaverWithAsync) error {
+		var err error
+		id, _, _, err = uploader.SaveBlob(ctx, restic.DataBlob, buf, restic.ID{}, false)
+		return err
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	data, err := repo.LoadBlob(ctx, restic.BlobHandle{Type: restic.DataBlob, ID: id}, make([]byte, 0, 2000))
+	rtest.OK(t, err)
+	rtest.Assert(t, bytes.Equal(buf, data), "data mismatch")
+	// the stalled request is canceled once the hedged request has completed
+	rtest.Equals(t, int32(1), be.canceled.Load())
+}
+
 func TestRepositoryLoadUnpackedBroken(t *testing.T) {
 	repo, _, be := repository.TestRepositoryWithVersion(t, 0)
 
@@ -453,6 +527,24 @@ func TestInvalidCompression(t *testing.T) {
 	rtest.Assert(t, err != nil, "missing error")
 }
 
+func TestPackSizePerBlobType(t *testing.T) {
+	repo, err := repository.New(nil, repository.Options{DataPackSize: 64 * 1024 * 1024})
+	rtest.OK(t, err)
+	rtest.Equals(t, uint(repository.DefaultPackSize), repo.PackSize())
+	rtest.Equals(t, uint(repository.DefaultPackSize), repo.PackSizeFor(restic.TreeBlob))
+	rtest.Equals(t, uint(64*1024*1024), repo.PackSizeFor(restic.DataBlob))
+
+	repo, err = repository.New(nil, repository.Options{PackSize: 32 * 1024 * 1024, TreePackSize: repository.MinPackSize})
+	rtest.OK(t, err)
+	rtest.Equals(t, uint(repository.MinPackSize), repo.PackSizeFor(restic.TreeBlob))
+	rtest.Equals(t, uint(32*1024*1024), repo.PackSizeFor(restic.DataBlob))
+
+	_, err = repository.New(nil, repository.Options{TreePackSize: 1024})
+	rtest.Assert(t, err != nil, "missing error for too small tree pack size")
+	_, err = repository.New(nil, repository.Options{DataPackSize: 2 * repository.MaxPackSize})
+	rtest.Assert(t, err != nil, "missing error for too large data pack size")
+}
+
 func TestListPack(t *testing.T) {
 	be := mem.New()
 	repo, _ := repository.TestRepositoryWithBackend(t, &damageOnceBackend{Backend: be}, restic.StableRepoVersion, repository.Options{})
@@ -496,12 +588,12 @@ func TestNoDoubleInit(t *testing.T) {
 	rtest.OK(t, err)
 
 	pol := r.Config().ChunkerPolynomial
-	err = repo.Init(context.TODO(), r.Config().Version, rtest.TestPassword, &pol)
+	err = repo.Init(context.TODO(), r.Config().Version, rtest.TestPassword, &pol, nil)
 	rtest.Assert(t, strings.Contains(err.Error(), "repository master key and config already initialized"), "expected config exist error, got %q", err)
 
 	// must also prevent init if only keys exist
 	rtest.OK(t, be.Remove(context.TODO(), backend.Handle{Type: backend.ConfigFile}))
-	err = repo.Init(context.TODO(), r.Config().Version, rtest.TestPassword, &pol)
+	err = repo.Init(context.TODO(), r.Config().Version, rtest.TestPassword, &pol, nil)
 	rtest.Assert(t, strings.Contains(err.Error(), "repository already contains keys"), "expected already contains keys error, got %q", err)
 
 	// must also prevent init if a snapshot exists and keys were deleted
@@ -511,7 +603,7 @@ func TestNoDoubleInit(t *testing.T) {
 	rtest.OK(t, be.List(context.TODO(), backend.KeyFile, func(fi backend.FileInfo) error {
 		return be.Remove(context.TODO(), backend.Handle{Type: backend.KeyFile, Name: fi.Name})
 	}))
-	err = repo.Init(context.TODO(), r.Config().Version, rtest.TestPassword, &pol)
+	err = repo.Init(context.TODO(), r.Config().Version, rtest.TestPassword, &pol, nil)
 	rtest.Assert(t, strings.Contains(err.Error(), "repository already contains snapshots"), "expected already contains snapshots error, got %q", err)
 }
 
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"strings"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+)
+
+// ErrStorageClassesNotSupported is returned by TierPacks if the backend cannot
+// move files between storage classes.
+var ErrStorageClassesNotSupported = errors.New("the backend does not support changing the storage class of files")
+
+// TierOptions configures TierPacks.
+type TierOptions struct {
+	// StorageClass is the backend storage class the pack files are moved to.
+	StorageClass string
+	DryRun       bool
+}
+
+// TierStats contains statistics about the pack files moved by TierPacks.
+type TierStats struct {
+	// Packs and Size count the pack files which are moved to the storage class.
+	Packs uint
+	Size  uint64
+	// Skipped counts the pack files which were already in the storage class.
+	Skipped uint
+}
+
+// TierPacks moves the data pack files which contain blobs from coldBlobs to
+// opts.StorageClass. Pack files which contain tree blobs or any blob from
+// hotBlobs are not moved, such that they can still be read immediately. The
+// index must be loaded.
+func TierPacks(ctx context.Context, repo *Repository, opts TierOptions, hotBlobs, coldBlobs restic.FindBlobSet, printer restic.Printer) (TierStats, error) {
+	var stats TierStats
+	be := backend.AsBackend[backend.StorageClassBackend](repo.be)
+	if be == nil {
+		return stats, ErrStorageClassesNotSupported
+	}
+
+	hotPacks := restic.NewIDSet()
+	coldPacks := restic.NewIDSet()
+	err := repo.ListBlobs(ctx, func(pb restic.PackBlob) {
+		h := pb.Handle()
+		switch {
+		case h.Type == restic.TreeBlob || hotBlobs.Has(h):
+			hotPacks.Insert(pb.PackID())
+		case coldBlobs.Has(h):
+			coldPacks.Insert(pb.PackID())
+		}
+	})
+	if err != nil {
+		return stats, err
+	}
+	coldPacks = coldPacks.Sub(hotPacks)
+
+	var packs restic.IDs
+	err = repo.be.List(ctx, backend.PackFile, func(fi backend.FileInfo) error {
+		id, err := restic.ParseID(fi.Name)
+		if err != nil || !coldPacks.Has(id) {
+			return nil
+		}
+		if strings.EqualFold(fi.StorageClass, opts.StorageClass) {
+			stats.Skipped++
+			return nil
+		}
+		packs = append(packs, id)
+		stats.Packs++
+		stats.Size += uint64(fi.Size)
+		return nil
+	})
+	if err != nil || opts.DryRun {
+		return stats, err
+	}
+
+	bar := printer.NewCounter("packs moved")
+	bar.SetMax(uint64(len(packs)))
+	defer bar.Done()
+	for _, id := range packs {
+		debug.Log("moving pack %v to storage class %v", id, opts.StorageClass)
+		err := be.SetStorageClass(ctx, backend.Handle{Type: backend.PackFile, Name: id.String()}, opts.StorageClass)
+		if err != nil {
+			return stats, errors.Wrapf(err, "pack %v", id.Str())
+		}
+		bar.Add(1)
+	}
+	return stats, nil
+}
@@ -0,0 +1,76 @@
+package repository_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+type tierBackend struct {
+	backend.Backend
+	mu      sync.Mutex
+	classes map[string]string
+}
+
+func (be *tierBackend) List(ctx context.Context, t backend.FileType, fn func(backend.FileInfo) error) error {
+	return be.Backend.List(ctx, t, func(fi backend.FileInfo) error {
+		be.mu.Lock()
+		fi.StorageClass = be.classes[fi.Name]
+		be.mu.Unlock()
+		return fn(fi)
+	})
+}
+
+func (be *tierBackend) SetStorageClass(_ context.Context, h backend.Handle, class string) error {
+	be.mu.Lock()
+	defer be.mu.Unlock()
+	be.classes[h.Name] = class
+	return nil
+}
+
+func TestTierPacks(t *testing.T) {
+	repo, _, be := repository.TestRepositoryWithVersion(t, 0)
+
+	// each upload creates a separate pack file
+	var blobs []restic.BlobHandle
+	for i, tpe := range []restic.BlobType{restic.DataBlob, restic.DataBlob, restic.DataBlob, restic.TreeBlob} {
+		rtest.OK(t, repo.WithBlobUploader(context.TODO(), func(ctx context.Context, uploader restic.BlobSaverWithAsync) error {
+			id, _, _, err := uploader.SaveBlob(ctx, tpe, rtest.Random(i, 1000), restic.ID{}, false)
+			blobs = append(blobs, restic.BlobHandle{Type: tpe, ID: id})
+			return err
+		}))
+	}
+	cold, alreadyCold, hot, tree := blobs[0], blobs[1], blobs[2], blobs[3]
+	packOf := func(bh restic.BlobHandle) string {
+		return repo.LookupBlob(bh)[0].PackID().String()
+	}
+
+	tbe := &tierBackend{Backend: be, classes: map[string]string{packOf(alreadyCold): "GLACIER"}}
+	repo = repository.TestOpenBackend(t, tbe)
+	rtest.OK(t, repo.LoadIndex(context.TODO(), restic.NoopTerminalCounterFactory))
+
+	hotBlobs := restic.NewBlobSet(hot)
+	coldBlobs := restic.NewBlobSet(cold, alreadyCold, tree)
+
+	stats, err := repository.TierPacks(context.TODO(), repo, repository.TierOptions{StorageClass: "glacier", DryRun: true}, hotBlobs, coldBlobs, restic.NewNoopPrinter())
+	rtest.OK(t, err)
+	rtest.Equals(t, uint(1), stats.Packs)
+	rtest.Equals(t, uint(1), stats.Skipped)
+	rtest.Equals(t, "", tbe.classes[packOf(cold)])
+
+	_, err = repository.TierPacks(context.TODO(), repo, repository.TierOptions{StorageClass: "glacier"}, hotBlobs, coldBlobs, restic.NewNoopPrinter())
+	rtest.OK(t, err)
+	rtest.Equals(t, "glacier", tbe.classes[packOf(cold)])
+	rtest.Equals(t, "", tbe.classes[packOf(hot)])
+	rtest.Equals(t, "", tbe.classes[packOf(tree)])
+
+	// backends without storage classes are rejected
+	repo, _, _ = repository.TestRepositoryWithVersion(t, 0)
+	_, err = repository.TierPacks(context.TODO(), repo, repository.TierOptions{StorageClass: "glacier"}, hotBlobs, coldBlobs, restic.NewNoopPrinter())
+	rtest.Assert(t, err == repository.ErrStorageClassesNotSupported, "unexpected error %v", err)
+}
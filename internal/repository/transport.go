@@ -0,0 +1,319 @@
+package repository
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+)
+
+// manifestName is the name of the first entry in each export volume.
+const manifestName = "manifest.json"
+
+// exportTypes lists the file types contained in an export in the order in
+// which they are written. Pack files are stored before the index files which
+// reference them, snapshots are stored last.
+var exportTypes = []backend.FileType{backend.ConfigFile, backend.KeyFile, backend.PackFile, backend.IndexFile, backend.SnapshotFile}
+
+// ExportFile is a repository file contained in an export volume.
+type ExportFile struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+func (f ExportFile) handle() (backend.Handle, error) {
+	for _, t := range exportTypes {
+		if t.String() == f.Type {
+			h := backend.Handle{Type: t, Name: f.Name}
+			if t == backend.ConfigFile {
+				h.Name = ""
+			}
+			return h, nil
+		}
+	}
+	return backend.Handle{}, errors.Errorf("invalid file type %q", f.Type)
+}
+
+func (f ExportFile) key() string {
+	return f.Type + "/" + f.Name
+}
+
+// ExportManifest describes the content of an export volume.
+type ExportManifest struct {
+	RepositoryID string       `json:"repository_id"`
+	Created      time.Time    `json:"created"`
+	Volume       int          `json:"volume"`
+	Volumes      int          `json:"volumes"`
+	Files        []ExportFile `json:"files"`
+}
+
+// ExportOptions configures Export.
+type ExportOptions struct {
+	// VolumeSize is the maximum size of the files in a volume, zero for no
+	// limit. Files larger than VolumeSize are stored in a volume on their own.
+	VolumeSize int64
+	// Exclude lists files which are not exported, for example because they
+	// were already contained in a previous export.
+	Exclude []ExportFile
+}
+
+// ExportStats contains statistics about an export.
+type ExportStats struct {
+	Volumes int
+	Files   int
+	Size    uint64
+}
+
+// Export writes the config, keys, pack, index and snapshot files of the
+// repository to export volumes, which are tar archives. The volumes are
+// created by calling newVolume with the volume number, starting at one. The
+// exported files are stored without modification, such that they can be
+// imported into a repository with the same master key using ImportVolume.
+func (r *Repository) Export(ctx context.Context, opts ExportOptions, newVolume func(volume int) (io.WriteCloser, error), p restic.Counter) (ExportStats, error) {
+	var stats ExportStats
+	exclude := make(map[string]struct{}, len(opts.Exclude))
+	for _, f := range opts.Exclude {
+		exclude[f.key()] = struct{}{}
+	}
+
+	// plan the content of all volumes first, as each volume starts with its manifest
+	var volumes [][]ExportFile
+	var volumeSize int64
+	for _, t := range exportTypes {
+		var files []ExportFile
+		if t == backend.ConfigFile {
+			fi, err := r.be.Stat(ctx, backend.Handle{Type: t})
+			if err != nil {
+				return stats, err
+			}
+			files = append(files, ExportFile{Type: t.String(), Name: "config", Size: fi.Size})
+		} else {
+			err := r.be.List(ctx, t, func(fi backend.FileInfo) error {
+				if _, err := restic.ParseID(fi.Name); err != nil {
+					debug.Log("skipping invalid file name %v", fi.Name)
+					return nil
+				}
+				files = append(files, ExportFile{Type: t.String(), Name: fi.Name, Size: fi.Size})
+				return nil
+			})
+			if err != nil {
+				return stats, err
+			}
+		}
+
+		for _, f := range files {
+			if _, ok := exclude[f.key()]; ok {
+				continue
+			}
+			if len(volumes) == 0 || (opts.VolumeSize > 0 && volumeSize+f.Size > opts.VolumeSize && volumeSize > 0) {
+				volumes = append(volumes, nil)
+				volumeSize = 0
+			}
+			volumes[len(volumes)-1] = append(volumes[len(volumes)-1], f)
+			volumeSize += f.Size
+			stats.Files++
+			stats.Size += uint64(f.Size)
+		}
+	}
+
+	p.SetMax(uint64(stats.Files))
+	defer p.Done()
+
+	created := time.Now()
+	for i, files := range volumes {
+		manifest := ExportManifest{
+			RepositoryID: r.Config().ID,
+			Created:      created,
+			Volume:       i + 1,
+			Volumes:      len(volumes),
+			Files:        files,
+		}
+		if err := r.exportVolume(ctx, manifest, newVolume, p); err != nil {
+			return stats, fmt.Errorf("volume %d: %w", manifest.Volume, err)
+		}
+		stats.Volumes++
+	}
+	return stats, nil
+}
+
+func (r *Repository) exportVolume(ctx context.Context, manifest ExportManifest, newVolume func(volume int) (io.WriteCloser, error), p restic.Counter) error {
+	wr, err := newVolume(manifest.Volume)
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(wr)
+
+	writeEntry := func(name string, buf []byte) error {
+		err := tw.WriteHeader(&tar.Header{
+			Name:    name,
+			Mode:    0o600,
+			Size:    int64(len(buf)),
+			ModTime: manifest.Created,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = tw.Write(buf)
+		return err
+	}
+
+	buf, err := json.MarshalIndent(manifest, "", "  ")
+	if err == nil {
+		err = writeEntry(manifestName, buf)
+	}
+	for _, f := range manifest.Files {
+		if err != nil {
+			break
+		}
+		var h backend.Handle
+		h, err = f.handle()
+		if err != nil {
+			break
+		}
+		var id restic.ID
+		if h.Type != backend.ConfigFile {
+			id, err = restic.ParseID(f.Name)
+			if err != nil {
+				break
+			}
+		}
+		debug.Log("exporting %v", h)
+		buf, err = r.LoadRaw(ctx, restic.FileType(h.Type), id)
+		if err == nil && int64(len(buf)) != f.Size {
+			err = errors.Errorf("%v: size changed from %d to %d bytes", h, f.Size, len(buf))
+		}
+		if err == nil {
+			err = writeEntry(f.key(), buf)
+		}
+		p.Add(1)
+	}
+
+	if err == nil {
+		err = tw.Close()
+	}
+	if cerr := wr.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// ReadExportManifest reads the manifest at the start of an export volume.
+func ReadExportManifest(rd io.Reader) (*ExportManifest, *tar.Reader, error) {
+	tr := tar.NewReader(rd)
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "read manifest")
+	}
+	if hdr.Name != manifestName {
+		return nil, nil, errors.Errorf("not an export volume, first entry is %q instead of %q", hdr.Name, manifestName)
+	}
+	var manifest ExportManifest
+	if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+		return nil, nil, errors.Wrap(err, "decode manifest")
+	}
+	return &manifest, tr, nil
+}
+
+// ImportStats contains statistics about an import.
+type ImportStats struct {
+	Files   int
+	Size    uint64
+	Skipped int
+}
+
+// ImportVolume stores the files of an export volume in the repository. Files
+// which already exist are skipped. The content of all files except the config
+// is verified using their name. The volume must have been exported from a
+// repository with the same ID.
+func (r *Repository) ImportVolume(ctx context.Context, rd io.Reader, p restic.Counter) (ImportStats, error) {
+	return importVolume(ctx, r.be, rd, r.Config().ID, false, p)
+}
+
+// ImportConfig stores the config and key files of an export volume in the
+// backend, which allows opening the repository afterwards. The other files of
+// the volume are ignored.
+func ImportConfig(ctx context.Context, be backend.Backend, rd io.Reader) (ImportStats, error) {
+	return importVolume(ctx, be, rd, "", true, restic.NoopCounter)
+}
+
+func importVolume(ctx context.Context, be backend.Backend, rd io.Reader, repositoryID string, onlyConfig bool, p restic.Counter) (ImportStats, error) {
+	var stats ImportStats
+	manifest, tr, err := ReadExportManifest(rd)
+	if err != nil {
+		return stats, err
+	}
+	if repositoryID != "" && manifest.RepositoryID != repositoryID {
+		return stats, errors.Errorf("volume was exported from repository %v, not from this repository %v", manifest.RepositoryID, repositoryID)
+	}
+	p.SetMax(uint64(len(manifest.Files)))
+	defer p.Done()
+
+	files := make(map[string]ExportFile, len(manifest.Files))
+	for _, f := range manifest.Files {
+		files[f.key()] = f
+	}
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return stats, err
+		}
+
+		f, ok := files[hdr.Name]
+		if !ok {
+			return stats, errors.Errorf("file %v is not listed in the manifest", hdr.Name)
+		}
+		delete(files, hdr.Name)
+		h, err := f.handle()
+		if err != nil {
+			return stats, err
+		}
+		if onlyConfig && h.Type != backend.ConfigFile && h.Type != backend.KeyFile {
+			break
+		}
+
+		if _, err := be.Stat(ctx, h); err == nil {
+			debug.Log("skipping existing file %v", h)
+			stats.Skipped++
+			p.Add(1)
+			continue
+		} else if !be.IsNotExist(err) {
+			return stats, err
+		}
+
+		buf, err := io.ReadAll(tr)
+		if err != nil {
+			return stats, errors.Wrapf(err, "read %v", hdr.Name)
+		}
+		if int64(len(buf)) != f.Size {
+			return stats, errors.Errorf("%v: size %d does not match the manifest size %d", hdr.Name, len(buf), f.Size)
+		}
+		if h.Type != backend.ConfigFile && restic.Hash(buf).String() != h.Name {
+			return stats, errors.Errorf("%v: content does not match the file name, the volume is damaged", hdr.Name)
+		}
+
+		debug.Log("importing %v", h)
+		if err := be.Save(ctx, h, backend.NewByteReader(buf, be.Hasher())); err != nil {
+			return stats, err
+		}
+		stats.Files++
+		stats.Size += uint64(len(buf))
+		p.Add(1)
+	}
+
+	if len(files) > 0 && !onlyConfig {
+		return stats, errors.Errorf("volume %d is truncated, %d files are missing", manifest.Volume, len(files))
+	}
+	return stats, nil
+}
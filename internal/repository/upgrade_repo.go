@@ -10,14 +10,14 @@ import (
 	"github.com/restic/restic/internal/restic"
 )
 
-type upgradeRepoV2Error struct {
+type replaceConfigError struct {
 	UploadNewConfigError   error
 	ReuploadOldConfigError error
 
 	BackupFilePath string
 }
 
-func (err *upgradeRepoV2Error) Error() string {
+func (err *replaceConfigError) Error() string {
 	if err.ReuploadOldConfigError != nil {
 		return fmt.Sprintf("error uploading config (%v), re-uploading old config filed failed as well (%v), but there is a backup of the config file in %v", err.UploadNewConfigError, err.ReuploadOldConfigError, err.BackupFilePath)
 	}
@@ -25,40 +25,38 @@ func (err *upgradeRepoV2Error) Error() string {
 	return fmt.Sprintf("error uploading config (%v), re-uploaded old config was successful, there is a backup of the config file in %v", err.UploadNewConfigError, err.BackupFilePath)
 }
 
-func (err *upgradeRepoV2Error) Unwrap() error {
+func (err *replaceConfigError) Unwrap() error {
 	// consider the original upload error as the primary cause
 	return err.UploadNewConfigError
 }
 
-func upgradeRepository(ctx context.Context, repo *Repository) error {
+// saveConfig uploads cfg as the new config file of the repository.
+func (r *Repository) saveConfig(ctx context.Context, cfg restic.Config) error {
 	h := backend.Handle{Type: backend.ConfigFile}
 
-	if !repo.be.Properties().HasAtomicReplace {
+	if !r.be.Properties().HasAtomicReplace {
 		// remove the original file for backends which do not support atomic overwriting
-		err := repo.be.Remove(ctx, h)
+		err := r.be.Remove(ctx, h)
 		if err != nil {
 			return fmt.Errorf("remove config failed: %w", err)
 		}
 	}
 
-	// upgrade config
-	cfg := repo.Config()
-	cfg.Version = 2
-
-	err := restic.SaveConfig(ctx, &internalRepository{repo}, cfg)
+	err := restic.SaveConfig(ctx, &internalRepository{r}, cfg)
 	if err != nil {
 		return fmt.Errorf("save new config file failed: %w", err)
 	}
+	r.setConfig(cfg)
 
 	return nil
 }
 
-func UpgradeRepo(ctx context.Context, repo *Repository) error {
-	if repo.Config().Version != 1 {
-		return fmt.Errorf("repository has version %v, only upgrades from version 1 are supported", repo.Config().Version)
-	}
-
-	tempdir, err := os.MkdirTemp("", "restic-migrate-upgrade-repo-v2-")
+// replaceConfig overwrites the config file of the repository. The current
+// config file is first saved to a local temporary directory whose name starts
+// with tempPrefix. If uploading the new config fails, the old config is
+// uploaded again and the returned error contains the path of the backup.
+func (r *Repository) replaceConfig(ctx context.Context, cfg restic.Config, tempPrefix string) error {
+	tempdir, err := os.MkdirTemp("", tempPrefix)
 	if err != nil {
 		return fmt.Errorf("create temp dir failed: %w", err)
 	}
@@ -66,7 +64,7 @@ func UpgradeRepo(ctx context.Context, repo *Repository) error {
 	h := backend.Handle{Type: backend.ConfigFile}
 
 	// read raw config file and save it to a temp dir, just in case
-	rawConfigFile, err := repo.LoadRaw(ctx, restic.ConfigFile, restic.ID{})
+	rawConfigFile, err := r.LoadRaw(ctx, restic.ConfigFile, restic.ID{})
 	if err != nil {
 		return fmt.Errorf("load config file failed: %w", err)
 	}
@@ -77,19 +75,18 @@ func UpgradeRepo(ctx context.Context, repo *Repository) error {
 		return fmt.Errorf("write config file backup to %v failed: %w", tempdir, err)
 	}
 
-	// run the upgrade
-	err = upgradeRepository(ctx, repo)
+	err = r.saveConfig(ctx, cfg)
 	if err != nil {
 
 		// build an error we can return to the caller
-		repoError := &upgradeRepoV2Error{
+		repoError := &replaceConfigError{
 			UploadNewConfigError: err,
 			BackupFilePath:       backupFileName,
 		}
 
 		// try contingency methods, reupload the original file
-		_ = repo.be.Remove(ctx, h)
-		err = repo.be.Save(ctx, h, backend.NewByteReader(rawConfigFile, nil))
+		_ = r.be.Remove(ctx, h)
+		err = r.be.Save(ctx, h, backend.NewByteReader(rawConfigFile, nil))
 		if err != nil {
 			repoError.ReuploadOldConfigError = err
 		}
@@ -101,3 +98,23 @@ func UpgradeRepo(ctx context.Context, repo *Repository) error {
 	_ = os.Remove(tempdir)
 	return nil
 }
+
+// UpgradeRepo upgrades a repository from version 1 to version 2.
+func UpgradeRepo(ctx context.Context, repo *Repository) error {
+	return upgradeRepoVersion(ctx, repo, 2)
+}
+
+// UpgradeRepoV3 upgrades a repository from version 2 to version 3.
+func UpgradeRepoV3(ctx context.Context, repo *Repository) error {
+	return upgradeRepoVersion(ctx, repo, 3)
+}
+
+func upgradeRepoVersion(ctx context.Context, repo *Repository, version uint) error {
+	if repo.Config().Version != version-1 {
+		return fmt.Errorf("repository has version %v, only upgrades from version %v are supported", repo.Config().Version, version-1)
+	}
+
+	cfg := repo.Config()
+	cfg.Version = version
+	return repo.replaceConfig(ctx, cfg, fmt.Sprintf("restic-migrate-upgrade-repo-v%d-", version))
+}
@@ -22,6 +22,16 @@ func TestUpgradeRepoV2(t *testing.T) {
 	rtest.OK(t, err)
 }
 
+func TestUpgradeRepoV3(t *testing.T) {
+	repo, _, _ := TestRepositoryWithVersion(t, 2)
+	rtest.OK(t, UpgradeRepoV3(context.Background(), repo))
+	rtest.Equals(t, uint(3), repo.Config().Version)
+
+	// only version 2 repositories can be upgraded to version 3
+	repo, _, _ = TestRepositoryWithVersion(t, 1)
+	rtest.Assert(t, UpgradeRepoV3(context.Background(), repo) != nil, "expected error upgrading version 1 repository")
+}
+
 type failBackend struct {
 	backend.Backend
 
@@ -65,7 +75,7 @@ func TestUpgradeRepoV2Failure(t *testing.T) {
 		t.Fatal("expected error returned from Apply(), got nil")
 	}
 
-	upgradeErr := err.(*upgradeRepoV2Error)
+	upgradeErr := err.(*replaceConfigError)
 	if upgradeErr.UploadNewConfigError == nil {
 		t.Fatal("expected upload error, got nil")
 	}
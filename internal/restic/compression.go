@@ -0,0 +1,63 @@
+package restic
+
+import "fmt"
+
+// CompressionLevel overrides the compression mode of the repository for
+// individual data blobs.
+type CompressionLevel uint8
+
+// Constants for the different compression levels.
+const (
+	// CompressionLevelDefault uses the compression mode of the repository.
+	CompressionLevelDefault CompressionLevel = iota
+	CompressionLevelOff
+	CompressionLevelFastest
+	CompressionLevelAuto
+	CompressionLevelBetter
+	CompressionLevelMax
+)
+
+// ParseCompressionLevel parses one of "default", "off", "fastest", "auto",
+// "better" or "max".
+func ParseCompressionLevel(s string) (CompressionLevel, error) {
+	switch s {
+	case "default":
+		return CompressionLevelDefault, nil
+	case "off":
+		return CompressionLevelOff, nil
+	case "fastest":
+		return CompressionLevelFastest, nil
+	case "auto":
+		return CompressionLevelAuto, nil
+	case "better":
+		return CompressionLevelBetter, nil
+	case "max":
+		return CompressionLevelMax, nil
+	}
+	return CompressionLevelDefault, fmt.Errorf("invalid compression level %q, must be one of (default|off|fastest|auto|better|max)", s)
+}
+
+func (l CompressionLevel) String() string {
+	switch l {
+	case CompressionLevelDefault:
+		return "default"
+	case CompressionLevelOff:
+		return "off"
+	case CompressionLevelFastest:
+		return "fastest"
+	case CompressionLevelAuto:
+		return "auto"
+	case CompressionLevelBetter:
+		return "better"
+	case CompressionLevelMax:
+		return "max"
+	}
+	return fmt.Sprintf("invalid (%d)", uint8(l))
+}
+
+// CompressionSelector is implemented by blob savers which allow overriding the
+// compression level for individual data blobs. Tree blobs are not affected.
+type CompressionSelector interface {
+	// WithCompression returns a blob saver which compresses data blobs using level.
+	WithCompression(level CompressionLevel) BlobSaverWithAsync
+}
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/repository/crypto"
 
 	"github.com/restic/restic/internal/debug"
 
@@ -17,18 +18,95 @@ type Config struct {
 	Version           uint        `json:"version"`
 	ID                string      `json:"id"`
 	ChunkerPolynomial chunker.Pol `json:"chunker_polynomial"`
+	// Chunker overrides the default chunk sizes, nil means the defaults are
+	// used. It requires repository version 3, as older restic versions ignore
+	// this field.
+	Chunker *ChunkerParams `json:"chunker,omitempty"`
+	// MaxRepoSize is the size budget of the repository in bytes, zero means
+	// unlimited. Older restic versions ignore this field.
+	MaxRepoSize uint64 `json:"max_repo_size,omitempty"`
+	// KeyRotation is set while the repository is re-encrypted using a new
+	// master key. It requires repository version 3, as older restic versions
+	// cannot read data which was not yet re-encrypted.
+	KeyRotation *KeyRotation `json:"key_rotation,omitempty"`
+	// AuditLog is set once the audit log was enabled. It allows detecting that
+	// all entries of the audit log were removed. Older restic versions ignore
+	// this field and do not record operations in the audit log.
+	AuditLog bool `json:"audit_log,omitempty"`
+}
+
+// KeyRotation records the progress of a master key rotation.
+type KeyRotation struct {
+	// PreviousKey is the master key which was used before the rotation.
+	PreviousKey *crypto.Key `json:"previous_key"`
+	// MetadataDone is set once all files except the pack files were
+	// re-encrypted.
+	MetadataDone bool `json:"metadata_done"`
+	// PendingPacks lists the pack files which were not yet re-encrypted.
+	PendingPacks IDs `json:"pending_packs"`
+}
+
+// ChunkerParams configures the sizes of the chunks created by the content
+// defined chunker. The average chunk size is a power of two.
+type ChunkerParams struct {
+	MinSize     uint `json:"min_size"`
+	MaxSize     uint `json:"max_size"`
+	AverageBits uint `json:"average_bits"`
+}
+
+// DefaultChunkerParams are the chunk sizes used by repositories without
+// custom chunker parameters.
+var DefaultChunkerParams = ChunkerParams{
+	MinSize:     chunker.MinSize,
+	MaxSize:     chunker.MaxSize,
+	AverageBits: 20,
+}
+
+const (
+	// MinChunkSize is the lower limit for ChunkerParams.MinSize.
+	MinChunkSize = 64 * 1024
+	// MaxChunkSize is the upper limit for ChunkerParams.MaxSize.
+	MaxChunkSize = 128 * 1024 * 1024
+)
+
+// AverageSize returns the average chunk size.
+func (p ChunkerParams) AverageSize() uint {
+	return 1 << p.AverageBits
+}
+
+// Validate returns an error if the chunk sizes are out of range or if the
+// average chunk size is not between the minimum and maximum chunk size.
+func (p ChunkerParams) Validate() error {
+	if p.MinSize < MinChunkSize {
+		return errors.Errorf("minimum chunk size %d is smaller than %d", p.MinSize, MinChunkSize)
+	}
+	if p.MaxSize > MaxChunkSize {
+		return errors.Errorf("maximum chunk size %d is larger than %d", p.MaxSize, MaxChunkSize)
+	}
+	if p.AverageBits >= 32 || p.AverageSize() <= p.MinSize || p.AverageSize() >= p.MaxSize {
+		return errors.Errorf("average chunk size 2^%d must be between the minimum chunk size %d and the maximum chunk size %d", p.AverageBits, p.MinSize, p.MaxSize)
+	}
+	return nil
+}
+
+// ChunkerParameters returns the chunk sizes used by the repository.
+func (cfg Config) ChunkerParameters() ChunkerParams {
+	if cfg.Chunker == nil {
+		return DefaultChunkerParams
+	}
+	return *cfg.Chunker
 }
 
 const MinRepoVersion = 1
-const MaxRepoVersion = 2
+const MaxRepoVersion = 3
 
 // StableRepoVersion is the version that is written to the config when a repository
 // is newly created with Init().
 const StableRepoVersion = 2
 
 // CreateConfig creates a config file with a randomly selected polynomial and
-// ID.
-func CreateConfig(version uint, pol *chunker.Pol) (Config, error) {
+// ID. If params is not nil, it overrides the default chunk sizes.
+func CreateConfig(version uint, pol *chunker.Pol, params *ChunkerParams) (Config, error) {
 	var (
 		err error
 		cfg Config
@@ -43,6 +121,17 @@ func CreateConfig(version uint, pol *chunker.Pol) (Config, error) {
 		cfg.ChunkerPolynomial = *pol
 	}
 
+	if params != nil && *params != DefaultChunkerParams {
+		if err := params.Validate(); err != nil {
+			return Config{}, errors.Wrap(err, "invalid chunker parameters")
+		}
+		if version < 3 {
+			return Config{}, errors.New("custom chunk sizes require at least repository format version 3")
+		}
+		p := *params
+		cfg.Chunker = &p
+	}
+
 	cfg.ID = NewRandomID().String()
 	cfg.Version = version
 
@@ -83,6 +172,19 @@ func LoadConfig(ctx context.Context, r LoaderUnpacked) (Config, error) {
 		}
 	}
 
+	if cfg.Chunker != nil {
+		if err := cfg.Chunker.Validate(); err != nil {
+			return Config{}, errors.Wrap(err, "invalid chunker parameters")
+		}
+		if cfg.Version < 3 {
+			return Config{}, errors.New("custom chunk sizes require at least repository format version 3")
+		}
+	}
+
+	if cfg.KeyRotation != nil && cfg.Version < 3 {
+		return Config{}, errors.New("master key rotation requires at least repository format version 3")
+	}
+
 	return cfg, nil
 }
 
@@ -43,7 +43,7 @@ func TestConfig(t *testing.T) {
 		return restic.ID{}, nil
 	}
 
-	cfg1, err := restic.CreateConfig(restic.MaxRepoVersion, nil)
+	cfg1, err := restic.CreateConfig(restic.MaxRepoVersion, nil, nil)
 	rtest.OK(t, err)
 
 	err = restic.SaveConfig(context.TODO(), saver{save}, cfg1)
@@ -63,3 +63,67 @@ func TestConfig(t *testing.T) {
 	rtest.Assert(t, cfg1 == cfg2,
 		"configs aren't equal: %v != %v", cfg1, cfg2)
 }
+
+func TestChunkerParams(t *testing.T) {
+	for _, test := range []struct {
+		params restic.ChunkerParams
+		valid  bool
+	}{
+		{restic.DefaultChunkerParams, true},
+		{restic.ChunkerParams{MinSize: 64 * 1024, MaxSize: 1024 * 1024, AverageBits: 18}, true},
+		{restic.ChunkerParams{MinSize: 4 * 1024 * 1024, MaxSize: 64 * 1024 * 1024, AverageBits: 24}, true},
+		{restic.ChunkerParams{MinSize: 1024, MaxSize: 1024 * 1024, AverageBits: 18}, false},
+		{restic.ChunkerParams{MinSize: 512 * 1024, MaxSize: 256 * 1024 * 1024, AverageBits: 20}, false},
+		{restic.ChunkerParams{MinSize: 512 * 1024, MaxSize: 8 * 1024 * 1024, AverageBits: 19}, false},
+		{restic.ChunkerParams{MinSize: 512 * 1024, MaxSize: 8 * 1024 * 1024, AverageBits: 23}, false},
+		{restic.ChunkerParams{MinSize: 512 * 1024, MaxSize: 8 * 1024 * 1024, AverageBits: 64}, false},
+	} {
+		err := test.params.Validate()
+		rtest.Assert(t, (err == nil) == test.valid, "unexpected result for %v: %v", test.params, err)
+	}
+
+	cfg, err := restic.CreateConfig(restic.MaxRepoVersion, nil, &restic.DefaultChunkerParams)
+	rtest.OK(t, err)
+	rtest.Assert(t, cfg.Chunker == nil, "default chunker parameters should not be stored")
+
+	params := restic.ChunkerParams{MinSize: 64 * 1024, MaxSize: 1024 * 1024, AverageBits: 18}
+	cfg, err = restic.CreateConfig(restic.MaxRepoVersion, nil, &params)
+	rtest.OK(t, err)
+	rtest.Equals(t, params, cfg.ChunkerParameters())
+
+	params.AverageBits = 16
+	_, err = restic.CreateConfig(restic.MaxRepoVersion, nil, &params)
+	rtest.Assert(t, err != nil, "expected error for invalid chunker parameters")
+
+	params.AverageBits = 18
+	_, err = restic.CreateConfig(2, nil, &params)
+	rtest.Assert(t, err != nil, "expected error for custom chunker parameters in repository version 2")
+}
+
+func TestConfigVersion(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		modify func(cfg *restic.Config)
+	}{
+		{"key rotation", func(cfg *restic.Config) { cfg.KeyRotation = &restic.KeyRotation{} }},
+		{"chunker", func(cfg *restic.Config) {
+			cfg.Chunker = &restic.ChunkerParams{MinSize: 64 * 1024, MaxSize: 1024 * 1024, AverageBits: 18}
+		}},
+	} {
+		for _, version := range []uint{2, 3} {
+			cfg, err := restic.CreateConfig(version, nil, nil)
+			rtest.OK(t, err)
+			test.modify(&cfg)
+
+			var buf []byte
+			rtest.OK(t, restic.SaveConfig(context.TODO(), saver{func(_ restic.FileType, data []byte) (restic.ID, error) {
+				buf = data
+				return restic.ID{}, nil
+			}}, cfg))
+			_, err = restic.LoadConfig(context.TODO(), loader{func(restic.FileType, restic.ID) ([]byte, error) {
+				return buf, nil
+			}})
+			rtest.Assert(t, (err == nil) == (version >= 3), "unexpected result for %v in version %v: %v", test.name, version, err)
+		}
+	}
+}
@@ -12,6 +12,9 @@ const (
 	SnapshotFile
 	IndexFile
 	ConfigFile
+	AuditLogFile
+	GenerationFile
+	QuarantineFile
 )
 
 // Keep in sync with backend.FileType.String().
@@ -31,6 +34,12 @@ func (t FileType) String() string {
 		s = "index"
 	case ConfigFile:
 		s = "config"
+	case AuditLogFile:
+		s = "auditlog"
+	case GenerationFile:
+		s = "generation"
+	case QuarantineFile:
+		s = "quarantine"
 	}
 	return s
 }
@@ -41,12 +50,16 @@ type WriteableFileType FileType
 const (
 	// WriteableSnapshotFile is the WriteableFileType for snapshots.
 	WriteableSnapshotFile = WriteableFileType(SnapshotFile)
+	// WriteableAuditLogFile is the WriteableFileType for audit log entries.
+	WriteableAuditLogFile = WriteableFileType(AuditLogFile)
 )
 
 func (w *WriteableFileType) ToFileType() FileType {
 	switch *w {
 	case WriteableSnapshotFile:
 		return SnapshotFile
+	case WriteableAuditLogFile:
+		return AuditLogFile
 	default:
 		panic("invalid WriteableFileType")
 	}
@@ -23,6 +23,9 @@ type Repository interface {
 
 	LookupBlob(bh BlobHandle) []PackBlob
 	LookupBlobSize(bh BlobHandle) (size uint, exists bool)
+	// HasBlob returns whether new data can reference the blob. Unlike the
+	// lookup methods, it ignores blobs only contained in packs marked for deletion.
+	HasBlob(bh BlobHandle) bool
 
 	NewAssociatedBlobSet() AssociatedBlobSet
 	// ListBlobs runs fn on all blobs known to the index. When the context is cancelled,
@@ -5,17 +5,25 @@ import (
 	"fmt"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 
 	"golang.org/x/sync/errgroup"
 
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/feature"
+	"github.com/restic/restic/internal/fs"
 	"github.com/restic/restic/internal/restic"
 )
 
 const (
 	largeFileBlobCount = 25
+
+	// minDownloadRateKb is the rate in KiB/s which each concurrent pack
+	// download should get at least if downloads are limited. Otherwise the
+	// downloads share the bandwidth such that every pack file and thus every
+	// file restored from it takes much longer to complete.
+	minDownloadRateKb = 1024
 )
 
 // information about regular file being restored
@@ -27,6 +35,22 @@ type fileInfo struct {
 	location   string      // file on local filesystem relative to restorer basedir
 	blobs      interface{} // blobs of the file
 	state      *fileState
+	bytesDone  atomic.Int64 // bytes of the file that are already restored
+}
+
+// sparseMode returns how runs of zeros are written to the file.
+func (file *fileInfo) sparseMode() sparseMode {
+	switch {
+	case !file.sparse:
+		return sparseNone
+	case file.state != nil:
+		// sections that contained data but should be sparse after restoring
+		// the snapshot must be overwritten, otherwise they would still contain
+		// the old data
+		return sparsePunch
+	default:
+		return sparseSkip
+	}
 }
 
 type fileBlobInfo struct {
@@ -43,6 +67,15 @@ type packInfo struct {
 type blobsLoaderFn func(ctx context.Context, packID restic.ID, blobs []restic.BlobHandle, handleBlobFn func(blob restic.BlobHandle, buf []byte, err error) error) error
 type startWarmupFn func(context.Context, restic.IDSet) (restic.WarmupJob, error)
 
+// downloadWorkers returns the number of concurrent pack downloads for the
+// available backend connections and a download limit in KiB/s.
+func downloadWorkers(connections uint, limitKb int) uint {
+	if limitKb <= 0 {
+		return connections
+	}
+	return max(1, min(connections, uint(limitKb/minDownloadRateKb)))
+}
+
 // fileRestorer restores set of files
 type fileRestorer struct {
 	idx         func(restic.BlobHandle) []restic.PackBlob
@@ -62,6 +95,10 @@ type fileRestorer struct {
 	files []*fileInfo
 	Error func(string, error) error
 	Info  func(string)
+	// FileDone is called once the content of a file was completely restored.
+	FileDone func(location string) error
+	// fsync flushes files to disk before FileDone is called.
+	fsync bool
 }
 
 func newFileRestorer(dst string,
@@ -90,6 +127,7 @@ func newFileRestorer(dst string,
 		dst:                  dst,
 		Error:                restorerAbortOnAllErrors,
 		Info:                 func(_ string) {},
+		FileDone:             func(_ string) error { return nil },
 	}
 }
 
@@ -142,6 +180,7 @@ func (r *fileRestorer) restoreFiles(ctx context.Context) error {
 			file.blobs = packsMap
 		}
 		restoredBlobs := false
+		var reusedBytes uint64
 		err := r.forEachBlob(fileBlobs, func(blob restic.PackBlob, idx int, fileOffset int64) {
 			packID := blob.PackID()
 			if !file.state.HasMatchingBlob(idx) {
@@ -151,6 +190,8 @@ func (r *fileRestorer) restoreFiles(ctx context.Context) error {
 				restoredBlobs = true
 			} else {
 				r.reportBlobProgress(file, uint64(blob.PlaintextLength()))
+				r.progress.AddReusedBytes(uint64(blob.PlaintextLength()))
+				reusedBytes += uint64(blob.PlaintextLength())
 				// completely ignore blob
 				return
 			}
@@ -178,12 +219,6 @@ func (r *fileRestorer) restoreFiles(ctx context.Context) error {
 			// in addition, a short chunk will never match r.zeroChunk which would prevent sparseness for short files
 			file.sparse = r.sparse
 		}
-		if file.state != nil {
-			// The restorer currently cannot punch new holes into an existing files.
-			// Thus sections that contained data but should be sparse after restoring
-			// the snapshot would still contain the old data resulting in a corrupt restore.
-			file.sparse = false
-		}
 
 		// empty file or one with already up-to-date content. Make sure that the file size is correct
 		if !restoredBlobs {
@@ -196,6 +231,14 @@ func (r *fileRestorer) restoreFiles(ctx context.Context) error {
 			if file.size == 0 {
 				r.reportBlobProgress(file, 0)
 			}
+			if err != nil {
+				// the file does not have its final size, thus it is not done
+				continue
+			}
+		}
+		// reused blobs are only done once the file has its final size
+		if err := r.reportBlobDone(file, reusedBytes); err != nil {
+			return err
 		}
 	}
 	// drop no longer necessary file list
@@ -252,10 +295,16 @@ func (r *fileRestorer) restoreFiles(ctx context.Context) error {
 }
 
 func (r *fileRestorer) truncateFileToSize(location string, size int64) error {
-	f, err := createFile(r.targetPath(location), size, false, r.allowRecursiveDelete)
+	f, err := createFile(r.targetPath(location), size, false, r.allowRecursiveDelete, nil, fs.O_WRONLY)
 	if err != nil {
 		return err
 	}
+	if r.fsync {
+		if err := f.Sync(); err != nil {
+			_ = f.Close()
+			return err
+		}
+	}
 	return f.Close()
 }
 
@@ -359,13 +408,25 @@ func (r *fileRestorer) downloadBlobs(ctx context.Context, packID restic.ID,
 				}
 				return nil
 			}
+			isZeroChunk := h.ID.Equal(r.zeroChunk)
 			for file, offsets := range blob.files {
-				for _, offset := range offsets {
+				for len(offsets) > 0 {
 					// avoid long cancellation delays for frequently used blobs
 					if ctx.Err() != nil {
 						return ctx.Err()
 					}
 
+					offset := offsets[0]
+					count := 1
+					if isZeroChunk && file.sparse {
+						// coalesce adjacent copies of the zero chunk into a single hole
+						for count < len(offsets) && offsets[count] == offset+int64(count*len(blobData)) {
+							count++
+						}
+					}
+					offsets = offsets[count:]
+					size := uint64(count * len(blobData))
+
 					writeToFile := func() error {
 						// this looks overly complicated and needs explanation
 						// two competing requirements:
@@ -382,9 +443,17 @@ func (r *fileRestorer) downloadBlobs(ctx context.Context, packID restic.ID,
 							file.inProgress = true
 							createSize = file.size
 						}
-						writeErr := r.filesWriter.writeToFile(r.targetPath(file.location), blobData, offset, createSize, file.sparse)
-						r.reportBlobProgress(file, uint64(len(blobData)))
-						return writeErr
+						var writeErr error
+						if count > 1 {
+							writeErr = r.filesWriter.writeZeros(r.targetPath(file.location), offset, int64(size), createSize, file.sparseMode())
+						} else {
+							writeErr = r.filesWriter.writeToFile(r.targetPath(file.location), blobData, offset, createSize, file.sparseMode())
+						}
+						r.reportBlobProgress(file, size)
+						if writeErr != nil {
+							return writeErr
+						}
+						return r.reportBlobDone(file, size)
 					}
 					err := r.sanitizeError(file, writeToFile())
 					if err != nil {
@@ -403,3 +472,18 @@ func (r *fileRestorer) reportBlobProgress(file *fileInfo, blobSize uint64) {
 	}
 	r.progress.AddProgress(file.location, action, blobSize, uint64(file.size))
 }
+
+// reportBlobDone tracks the successfully restored parts of a file and
+// calls FileDone once the file is complete. If enabled, the file is flushed to
+// disk first.
+func (r *fileRestorer) reportBlobDone(file *fileInfo, blobSize uint64) error {
+	if file.bytesDone.Add(int64(blobSize)) == file.size {
+		if r.fsync {
+			if err := r.filesWriter.syncFile(r.targetPath(file.location)); err != nil {
+				return err
+			}
+		}
+		return r.FileDone(file.location)
+	}
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
 	"slices"
 	"strings"
 	"testing"
@@ -368,6 +369,46 @@ func TestErrorRestoreFiles(t *testing.T) {
 	rtest.Assert(t, errors.Is(err, loadError), "got %v, expected contained error %v", err, loadError)
 }
 
+func TestFileRestorerReusedFileDone(t *testing.T) {
+	for _, truncateFails := range []bool{false, true} {
+		tempdir := rtest.TempDir(t)
+		repo := newTestRepo([]TestFile{{
+			name:  "file1",
+			blobs: []TestBlob{{"data1-1", "pack1-1"}},
+		}})
+
+		r := newFileRestorer(tempdir, repo.loader, repo.Lookup, 2, false, false, repo.StartWarmup, nil,
+			repository.TestRepository(t).ChunkerFactory().ZeroChunk())
+		r.files = repo.files
+		// the content of the only blob is already present
+		r.files[0].state = &fileState{blobMatches: []bool{true}}
+		r.files[0].size = int64(len("data1-1"))
+		target := r.targetPath(r.files[0].location)
+		if truncateFails {
+			rtest.OK(t, os.MkdirAll(filepath.Join(target, "dir"), 0700))
+		} else {
+			rtest.OK(t, os.WriteFile(target, []byte("data1-1 and some trailing data"), 0600))
+		}
+
+		r.Error = func(_ string, _ error) error { return nil }
+		var done []string
+		r.FileDone = func(location string) error {
+			data, err := os.ReadFile(target)
+			rtest.OK(t, err)
+			rtest.Equals(t, "data1-1", string(data), "file must be truncated before it is done")
+			done = append(done, location)
+			return nil
+		}
+
+		rtest.OK(t, r.restoreFiles(context.TODO()))
+		if truncateFails {
+			rtest.Equals(t, 0, len(done), "file which could not be truncated must not be done")
+		} else {
+			rtest.Equals(t, []string{"file1"}, done)
+		}
+	}
+}
+
 func TestFatalDownloadError(t *testing.T) {
 	tempdir := rtest.TempDir(t)
 	content := []TestFile{
@@ -419,3 +460,19 @@ func TestFatalDownloadError(t *testing.T) {
 	rtest.Assert(t, len(errors) == 1, "unexpected number of restore errors, expected: 1, got: %v", len(errors))
 	rtest.Assert(t, errors[0] == "file2", "expected error for file2, got: %v", errors[0])
 }
+
+func TestDownloadWorkers(t *testing.T) {
+	for _, test := range []struct {
+		connections uint
+		limitKb     int
+		workers     uint
+	}{
+		{5, 0, 5},
+		{5, 100, 1},
+		{5, 2048, 2},
+		{5, 3000, 2},
+		{5, 100000, 5},
+	} {
+		rtest.Equals(t, test.workers, downloadWorkers(test.connections, test.limitKb))
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/hashicorp/golang-lru/v2/simplelru"
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/feature"
 	"github.com/restic/restic/internal/fileio"
 	"github.com/restic/restic/internal/fs"
 )
@@ -24,6 +25,16 @@ type filesWriter struct {
 	allowRecursiveDelete bool
 	cacheMu              sync.Mutex
 	cache                *simplelru.LRU[string, *partialFile]
+	// checkPath is called before a file is opened, if set.
+	checkPath func(path string) error
+	// verify reads back the data directly after writing it, see verifyAt.
+	// Files are then opened for reading and writing.
+	verify bool
+
+	// ring is used to write files via io_uring, if available. It is set up
+	// when the first file is opened and closed by flush.
+	ringOnce sync.Once
+	ring     *fileio.Ring
 }
 
 type filesWriterBucket struct {
@@ -34,7 +45,8 @@ type filesWriterBucket struct {
 type partialFile struct {
 	*os.File
 	users  int // Reference count.
-	sparse bool
+	sparse sparseMode
+	ring   *fileio.Ring
 }
 
 func newFilesWriter(count int, allowRecursiveDelete bool) *filesWriter {
@@ -62,8 +74,16 @@ func newFilesWriter(count int, allowRecursiveDelete bool) *filesWriter {
 	}
 }
 
-func openFile(path string) (*os.File, error) {
-	f, err := fs.OpenFile(path, fs.O_WRONLY|fs.O_NOFOLLOW, 0600)
+// openFlags returns the access mode used to open files.
+func (w *filesWriter) openFlags() int {
+	if w.verify {
+		return fs.O_RDWR
+	}
+	return fs.O_WRONLY
+}
+
+func openFile(path string, mode int) (*os.File, error) {
+	f, err := fs.OpenFile(path, mode|fs.O_NOFOLLOW, 0600)
 	if err != nil {
 		return nil, err
 	}
@@ -79,8 +99,32 @@ func openFile(path string) (*os.File, error) {
 	return f, nil
 }
 
-func createFile(path string, createSize int64, sparse bool, allowRecursiveDelete bool) (*os.File, error) {
-	f, err := fs.OpenFile(path, fs.O_CREATE|fs.O_WRONLY|fs.O_NOFOLLOW, 0600)
+// restoreRingEntries is the number of submission queue entries of the
+// io_uring used to write files.
+const restoreRingEntries = 256
+
+// openRing sets up the io_uring for writing files, if enabled and supported.
+func (w *filesWriter) openRing() *fileio.Ring {
+	w.ringOnce.Do(func() {
+		if !feature.Flag.Enabled(feature.RestoreIOUring) {
+			return
+		}
+		ring, err := fileio.NewRing(restoreRingEntries)
+		if err != nil {
+			// fall back to regular system calls
+			debug.Log("io_uring not available: %v", err)
+			return
+		}
+		w.ring = ring
+	})
+	return w.ring
+}
+
+// createFile creates or reuses the file at path and ensures that it has
+// createSize bytes. If ring is not nil, it is used to preallocate the file.
+// The file is opened using the access mode, either fs.O_WRONLY or fs.O_RDWR.
+func createFile(path string, createSize int64, sparse bool, allowRecursiveDelete bool, ring *fileio.Ring, mode int) (*os.File, error) {
+	f, err := fs.OpenFile(path, fs.O_CREATE|mode|fs.O_NOFOLLOW, 0600)
 	if err != nil && fs.IsAccessDenied(err) {
 		// If file is readonly, clear the readonly flag by resetting the
 		// permissions of the file and try again
@@ -89,7 +133,7 @@ func createFile(path string, createSize int64, sparse bool, allowRecursiveDelete
 		if err = fs.ResetPermissions(path); err != nil {
 			return nil, err
 		}
-		if f, err = fs.OpenFile(path, fs.O_WRONLY|fs.O_NOFOLLOW, 0600); err != nil {
+		if f, err = fs.OpenFile(path, mode|fs.O_NOFOLLOW, 0600); err != nil {
 			return nil, err
 		}
 	} else if err != nil && (errors.Is(err, syscall.ELOOP) || errors.Is(err, syscall.EISDIR)) {
@@ -138,7 +182,7 @@ func createFile(path string, createSize int64, sparse bool, allowRecursiveDelete
 			}
 		}
 		// create a new file, pass O_EXCL to make sure there are no surprises
-		f, err = fs.OpenFile(path, fs.O_CREATE|fs.O_WRONLY|fs.O_EXCL|fs.O_NOFOLLOW, 0600)
+		f, err = fs.OpenFile(path, fs.O_CREATE|mode|fs.O_EXCL|fs.O_NOFOLLOW, 0600)
 		if err != nil {
 			return nil, err
 		}
@@ -149,10 +193,10 @@ func createFile(path string, createSize int64, sparse bool, allowRecursiveDelete
 		}
 	}
 
-	return ensureSize(f, fi, createSize, sparse)
+	return ensureSize(f, fi, createSize, sparse, ring)
 }
 
-func ensureSize(f *os.File, fi os.FileInfo, createSize int64, sparse bool) (*os.File, error) {
+func ensureSize(f *os.File, fi os.FileInfo, createSize int64, sparse bool, ring *fileio.Ring) (*os.File, error) {
 	if sparse {
 		err := truncateSparse(f, createSize)
 		if err != nil {
@@ -167,7 +211,12 @@ func ensureSize(f *os.File, fi os.FileInfo, createSize int64, sparse bool) (*os.
 			return nil, err
 		}
 	} else if createSize > 0 {
-		err := fileio.PreallocateFile(f, createSize)
+		var err error
+		if ring != nil {
+			err = ring.PreallocateFile(f, createSize)
+		} else {
+			err = fileio.PreallocateFile(f, createSize)
+		}
 		if err != nil {
 			// Just log the preallocate error but don't let it cause the restore process to fail.
 			// Preallocate might return an error if the filesystem (implementation) does not
@@ -180,7 +229,38 @@ func ensureSize(f *os.File, fi os.FileInfo, createSize int64, sparse bool) (*os.
 	return f, nil
 }
 
-func (w *filesWriter) writeToFile(path string, blob []byte, offset int64, createSize int64, sparse bool) error {
+func (w *filesWriter) writeToFile(path string, blob []byte, offset int64, createSize int64, sparse sparseMode) error {
+	return w.withFile(path, createSize, sparse, func(wr *partialFile) error {
+		_, err := wr.WriteAt(blob, offset)
+		if err != nil || !w.verify {
+			return err
+		}
+		return wr.verifyAt(blob, offset)
+	})
+}
+
+// writeZeros makes sure that the range of length bytes at offset reads as
+// zeros. For sparse files, the range is turned into a hole.
+func (w *filesWriter) writeZeros(path string, offset int64, length int64, createSize int64, sparse sparseMode) error {
+	return w.withFile(path, createSize, sparse, func(wr *partialFile) error {
+		err := wr.zeroRange(offset, length)
+		if err != nil || !w.verify {
+			return err
+		}
+		return wr.verifyAt(make([]byte, length), offset)
+	})
+}
+
+// syncFile flushes the content of the file at path to disk.
+func (w *filesWriter) syncFile(path string) error {
+	return w.withFile(path, -1, sparseNone, func(wr *partialFile) error {
+		return wr.Sync()
+	})
+}
+
+// withFile calls fn with the writer for path, which is created with
+// createSize if createSize is not negative.
+func (w *filesWriter) withFile(path string, createSize int64, sparse sparseMode, fn func(wr *partialFile) error) error {
 	bucket := &w.buckets[uint(xxhash.Sum64String(path))%uint(len(w.buckets))]
 
 	acquireWriter := func() (*partialFile, error) {
@@ -209,18 +289,24 @@ func (w *filesWriter) writeToFile(path string, blob []byte, offset int64, create
 		w.cacheMu.Unlock()
 
 		// Not in cache, open/create the file
+		if w.checkPath != nil {
+			if err := w.checkPath(path); err != nil {
+				return nil, err
+			}
+		}
+		ring := w.openRing()
 		var f *os.File
 		var err error
 		if createSize >= 0 {
-			f, err = createFile(path, createSize, sparse, w.allowRecursiveDelete)
+			f, err = createFile(path, createSize, sparse != sparseNone, w.allowRecursiveDelete, ring, w.openFlags())
 			if err != nil {
 				return nil, err
 			}
-		} else if f, err = openFile(path); err != nil {
+		} else if f, err = openFile(path, w.openFlags()); err != nil {
 			return nil, err
 		}
 
-		wr := &partialFile{File: f, users: 1, sparse: sparse}
+		wr := &partialFile{File: f, users: 1, sparse: sparse, ring: ring}
 		bucket.files[path] = wr
 
 		return wr, nil
@@ -246,8 +332,7 @@ func (w *filesWriter) writeToFile(path string, blob []byte, offset int64, create
 		return err
 	}
 
-	_, err = wr.WriteAt(blob, offset)
-
+	err = fn(wr)
 	if err != nil {
 		// ignore subsequent errors
 		_ = releaseWriter(wr)
@@ -262,4 +347,11 @@ func (w *filesWriter) flush() {
 	defer w.cacheMu.Unlock()
 
 	w.cache.Purge()
+
+	if w.ring != nil {
+		if err := w.ring.Close(); err != nil {
+			debug.Log("closing io_uring failed: %v", err)
+		}
+		w.ring = nil
+	}
 }
@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/fs"
 	rtest "github.com/restic/restic/internal/test"
 )
 
@@ -18,16 +19,16 @@ func TestFilesWriterBasic(t *testing.T) {
 	f1 := dir + "/f1"
 	f2 := dir + "/f2"
 
-	rtest.OK(t, w.writeToFile(f1, []byte{1}, 0, 2, false))
+	rtest.OK(t, w.writeToFile(f1, []byte{1}, 0, 2, sparseNone))
 	rtest.Equals(t, 0, len(w.buckets[0].files))
 
-	rtest.OK(t, w.writeToFile(f2, []byte{2}, 0, 2, false))
+	rtest.OK(t, w.writeToFile(f2, []byte{2}, 0, 2, sparseNone))
 	rtest.Equals(t, 0, len(w.buckets[0].files))
 
-	rtest.OK(t, w.writeToFile(f1, []byte{1}, 1, -1, false))
+	rtest.OK(t, w.writeToFile(f1, []byte{1}, 1, -1, sparseNone))
 	rtest.Equals(t, 0, len(w.buckets[0].files))
 
-	rtest.OK(t, w.writeToFile(f2, []byte{2}, 1, -1, false))
+	rtest.OK(t, w.writeToFile(f2, []byte{2}, 1, -1, sparseNone))
 	rtest.Equals(t, 0, len(w.buckets[0].files))
 
 	w.flush()
@@ -41,6 +42,64 @@ func TestFilesWriterBasic(t *testing.T) {
 	rtest.Equals(t, []byte{2, 2}, buf)
 }
 
+func TestFilesWriterZeros(t *testing.T) {
+	dir := rtest.TempDir(t)
+	path := filepath.Join(dir, "file")
+	w := newFilesWriter(1, false)
+
+	for _, mode := range []sparseMode{sparseNone, sparseSkip, sparsePunch} {
+		rtest.OK(t, os.WriteFile(path, []byte("old data"), 0o600))
+		if mode == sparseSkip {
+			// the zeros are only skipped for files which don't contain old data
+			rtest.OK(t, os.Truncate(path, 0))
+		}
+
+		rtest.OK(t, w.writeToFile(path, []byte{1}, 0, 8, mode))
+		rtest.OK(t, w.writeZeros(path, 1, 4, -1, mode))
+		rtest.OK(t, w.writeToFile(path, []byte{0, 0, 0}, 5, -1, mode))
+		w.flush()
+
+		buf, err := os.ReadFile(path)
+		rtest.OK(t, err)
+		rtest.Equals(t, []byte{1, 0, 0, 0, 0, 0, 0, 0}, buf, fmt.Sprintf("mode %v", mode))
+	}
+}
+
+func TestFilesWriterVerify(t *testing.T) {
+	dir := rtest.TempDir(t)
+	path := filepath.Join(dir, "file")
+	w := newFilesWriter(1, false)
+	w.verify = true
+
+	for _, mode := range []sparseMode{sparseNone, sparseSkip, sparsePunch} {
+		rtest.OK(t, os.WriteFile(path, []byte("old data"), 0o600))
+		if mode == sparseSkip {
+			rtest.OK(t, os.Truncate(path, 0))
+		}
+
+		rtest.OK(t, w.writeToFile(path, []byte{1}, 0, 8, mode))
+		rtest.OK(t, w.writeZeros(path, 1, 4, -1, mode))
+		rtest.OK(t, w.writeToFile(path, []byte{0, 0, 2}, 5, -1, mode))
+		rtest.OK(t, w.syncFile(path))
+		w.flush()
+
+		buf, err := os.ReadFile(path)
+		rtest.OK(t, err)
+		rtest.Equals(t, []byte{1, 0, 0, 0, 0, 0, 0, 2}, buf, fmt.Sprintf("mode %v", mode))
+	}
+
+	// data which differs from the expected content is reported
+	f, err := openFile(path, fs.O_RDWR)
+	rtest.OK(t, err)
+	defer func() {
+		rtest.OK(t, f.Close())
+	}()
+	wr := &partialFile{File: f}
+	rtest.OK(t, wr.verifyAt([]byte{0, 2}, 6))
+	rtest.Assert(t, wr.verifyAt([]byte{1, 2}, 6) != nil, "expected error for mismatching data")
+	rtest.Assert(t, wr.verifyAt([]byte{2, 0}, 7) != nil, "expected error for data beyond the end of the file")
+}
+
 func TestFilesWriterRecursiveOverwrite(t *testing.T) {
 	path := filepath.Join(t.TempDir(), "test")
 
@@ -50,14 +109,14 @@ func TestFilesWriterRecursiveOverwrite(t *testing.T) {
 
 	// must error if recursive delete is not allowed
 	w := newFilesWriter(1, false)
-	err := w.writeToFile(path, []byte{1}, 0, 2, false)
+	err := w.writeToFile(path, []byte{1}, 0, 2, sparseNone)
 	rtest.Assert(t, errors.Is(err, notEmptyDirError()), "unexpected error got %v", err)
 	rtest.Equals(t, 0, len(w.buckets[0].files))
 	w.flush()
 
 	// must replace directory
 	w = newFilesWriter(1, true)
-	rtest.OK(t, w.writeToFile(path, []byte{1, 1}, 0, 2, false))
+	rtest.OK(t, w.writeToFile(path, []byte{1, 1}, 0, 2, sparseNone))
 	rtest.Equals(t, 0, len(w.buckets[0].files))
 	w.flush()
 
@@ -137,7 +196,7 @@ func TestCreateFile(t *testing.T) {
 			for j, test := range tests {
 				path := basepath + fmt.Sprintf("%v%v", i, j)
 				sc.create(t, path)
-				f, err := createFile(path, test.size, test.isSparse, false)
+				f, err := createFile(path, test.size, test.isSparse, false, nil, fs.O_WRONLY)
 				if sc.err == nil {
 					rtest.OK(t, err)
 					fi, err := f.Stat()
@@ -165,7 +224,7 @@ func TestCreateFileRecursiveDelete(t *testing.T) {
 	rtest.OK(t, os.WriteFile(filepath.Join(path, "file"), []byte("data"), 0o400))
 
 	// replace it
-	f, err := createFile(path, 42, false, true)
+	f, err := createFile(path, 42, false, true, nil, fs.O_WRONLY)
 	rtest.OK(t, err)
 	fi, err := f.Stat()
 	rtest.OK(t, err)
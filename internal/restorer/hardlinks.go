@@ -0,0 +1,165 @@
+package restorer
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/fs"
+	"github.com/restic/restic/internal/restic"
+)
+
+const hardlinkIndexHeader = "restic hardlink index v1"
+
+type hardlinkIndexKey struct {
+	inode, device uint64
+}
+
+// hardlinkIndex records the path of the first restored file for each
+// hardlinked inode of a snapshot. It allows separate restore runs, for example
+// of different subdirectories, to link files which share an inode in the
+// snapshot instead of restoring them as independent copies.
+//
+// The file format is line based. The first line contains a header followed
+// by the ID of the restored snapshot. Each following line contains the inode, the
+// device ID and the quoted absolute path of the restored file.
+type hardlinkIndex struct {
+	path       string
+	snapshotID restic.ID
+	links      map[hardlinkIndexKey]string
+}
+
+// loadHardlinkIndex loads the index at path. If the file does not exist yet,
+// an empty index is returned. An index that was written for a different
+// snapshot is rejected.
+func loadHardlinkIndex(path string, snapshotID restic.ID) (*hardlinkIndex, error) {
+	idx := &hardlinkIndex{
+		path:       path,
+		snapshotID: snapshotID,
+		links:      make(map[hardlinkIndexKey]string),
+	}
+
+	f, err := fs.OpenFile(path, os.O_RDONLY, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		return idx, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "open hardlink index")
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	header := fmt.Sprintf("%s %s", hardlinkIndexHeader, snapshotID)
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 1024*1024)
+	first := true
+	for sc.Scan() {
+		line := sc.Text()
+		if first {
+			first = false
+			if line != header {
+				return nil, errors.Errorf("hardlink index %v belongs to a different snapshot", path)
+			}
+			continue
+		}
+
+		key, target, err := parseHardlinkIndexLine(line)
+		if err != nil {
+			return nil, errors.Errorf("hardlink index %v is invalid: %v", path, err)
+		}
+		idx.links[key] = target
+	}
+	if err := sc.Err(); err != nil {
+		return nil, errors.Wrap(err, "read hardlink index")
+	}
+
+	return idx, nil
+}
+
+func parseHardlinkIndexLine(line string) (hardlinkIndexKey, string, error) {
+	fields := strings.SplitN(line, " ", 3)
+	if len(fields) != 3 {
+		return hardlinkIndexKey{}, "", errors.Errorf("malformed line %q", line)
+	}
+
+	inode, err := strconv.ParseUint(fields[0], 10, 64)
+	if err != nil {
+		return hardlinkIndexKey{}, "", err
+	}
+	device, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return hardlinkIndexKey{}, "", err
+	}
+	target, err := strconv.Unquote(fields[2])
+	if err != nil {
+		return hardlinkIndexKey{}, "", errors.Errorf("malformed path in line %q", line)
+	}
+
+	return hardlinkIndexKey{inode, device}, target, nil
+}
+
+// lookup returns the path of the file restored by a previous run for the
+// given inode. Files which no longer exist and the file at target itself are
+// ignored.
+func (idx *hardlinkIndex) lookup(inode, device uint64, target string) (string, bool) {
+	if idx == nil {
+		return "", false
+	}
+
+	path, ok := idx.links[hardlinkIndexKey{inode, device}]
+	if !ok || path == target {
+		return "", false
+	}
+
+	fi, err := fs.Lstat(path)
+	if err != nil || !fi.Mode().IsRegular() {
+		return "", false
+	}
+	return path, true
+}
+
+// add records that the file for the given inode is restored to target.
+func (idx *hardlinkIndex) add(inode, device uint64, target string) {
+	if idx == nil {
+		return
+	}
+	idx.links[hardlinkIndexKey{inode, device}] = target
+}
+
+// save atomically replaces the index file with the current content of the index.
+func (idx *hardlinkIndex) save() error {
+	if idx == nil {
+		return nil
+	}
+
+	tmp := idx.path + ".tmp"
+	f, err := fs.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return errors.Wrap(err, "write hardlink index")
+	}
+
+	wr := bufio.NewWriter(f)
+	_, _ = fmt.Fprintf(wr, "%s %s\n", hardlinkIndexHeader, idx.snapshotID)
+	for key, target := range idx.links {
+		_, _ = fmt.Fprintf(wr, "%d %d %s\n", key.inode, key.device, strconv.Quote(target))
+	}
+
+	err = wr.Flush()
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = fs.Remove(tmp)
+		return errors.Wrap(err, "write hardlink index")
+	}
+
+	return errors.Wrap(os.Rename(tmp, idx.path), "write hardlink index")
+}
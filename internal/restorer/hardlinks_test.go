@@ -0,0 +1,41 @@
+package restorer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestHardlinkIndex(t *testing.T) {
+	tempdir := rtest.TempDir(t)
+	path := filepath.Join(tempdir, "index")
+	snapshotID := restic.NewRandomID()
+
+	existing := filepath.Join(tempdir, "with\nnewline")
+	rtest.OK(t, os.WriteFile(existing, []byte("foo"), 0600))
+
+	idx, err := loadHardlinkIndex(path, snapshotID)
+	rtest.OK(t, err)
+	idx.add(1, 2, existing)
+	idx.add(3, 2, filepath.Join(tempdir, "missing"))
+	rtest.OK(t, idx.save())
+
+	idx, err = loadHardlinkIndex(path, snapshotID)
+	rtest.OK(t, err)
+	target, ok := idx.lookup(1, 2, filepath.Join(tempdir, "other"))
+	rtest.Assert(t, ok, "missing entry for existing file")
+	rtest.Equals(t, existing, target)
+
+	_, ok = idx.lookup(1, 2, existing)
+	rtest.Assert(t, !ok, "file must not be linked to itself")
+	_, ok = idx.lookup(3, 2, filepath.Join(tempdir, "other"))
+	rtest.Assert(t, !ok, "unexpected entry for missing file")
+	_, ok = idx.lookup(1, 3, filepath.Join(tempdir, "other"))
+	rtest.Assert(t, !ok, "unexpected entry for other device")
+
+	_, err = loadHardlinkIndex(path, restic.NewRandomID())
+	rtest.Assert(t, err != nil, "expected error for index of a different snapshot")
+}
@@ -0,0 +1,91 @@
+package restorer
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/restic/restic/internal/data"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/fs"
+)
+
+// restoreMetadataOnly applies the metadata of all items in the snapshot to
+// the existing items below dst, without creating, removing or modifying the
+// content of any file. Items which do not exist or whose type differs from
+// the snapshot are skipped.
+func (res *Restorer) restoreMetadataOnly(ctx context.Context, dst string) (uint64, error) {
+	count := uint64(0)
+
+	// applyTo restores the metadata of node if target is an item of the same type
+	applyTo := func(node *data.Node, target, location string, action ItemAction) error {
+		res.opts.Progress.AddFile(0)
+		fi, err := fs.Lstat(target)
+		if errors.Is(err, os.ErrNotExist) {
+			res.warn("skipping %v, it does not exist in the target directory", location)
+			res.opts.Progress.AddSkippedFile(location, 0)
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if !nodeTypeMatches(node, fi) {
+			res.warn("skipping %v, its type does not match the snapshot", location)
+			res.opts.Progress.AddSkippedFile(location, 0)
+			return nil
+		}
+
+		if err := res.restoreNodeMetadataTo(node, target, location); err != nil {
+			return err
+		}
+		count++
+		res.opts.Progress.AddProgress(location, action, 0, 0)
+		return nil
+	}
+
+	err := res.traverseTree(ctx, dst, *res.sn.Tree, treeVisitor{
+		visitNode: func(node *data.Node, target, location string) error {
+			debug.Log("metadata only, visitNode: restore metadata %q", location)
+			action := ActionOtherRestored
+			if node.Type == data.NodeTypeFile {
+				action = ActionFileUpdated
+			}
+			return applyTo(node, target, location, action)
+		},
+		leaveDir: func(node *data.Node, target, location string, _ []string) error {
+			// the metadata of the target directory itself is not part of the snapshot
+			if node == nil {
+				return nil
+			}
+			return applyTo(node, target, location, ActionDirRestored)
+		},
+	})
+	return count, err
+}
+
+// nodeTypeMatches returns whether the item described by fi has the type of node.
+func nodeTypeMatches(node *data.Node, fi os.FileInfo) bool {
+	mode := fi.Mode()
+	switch node.Type {
+	case data.NodeTypeFile:
+		return mode.IsRegular()
+	case data.NodeTypeDir:
+		return mode.IsDir()
+	case data.NodeTypeSymlink:
+		return mode&os.ModeSymlink != 0
+	case data.NodeTypeDev:
+		return mode&os.ModeDevice != 0 && mode&os.ModeCharDevice == 0
+	case data.NodeTypeCharDev:
+		return mode&os.ModeCharDevice != 0
+	case data.NodeTypeFifo:
+		return mode&os.ModeNamedPipe != 0
+	default:
+		return false
+	}
+}
+
+func (res *Restorer) warn(msg string, args ...interface{}) {
+	if res.Warn != nil {
+		res.Warn(fmt.Sprintf(msg, args...))
+	}
+}
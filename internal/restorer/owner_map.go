@@ -0,0 +1,106 @@
+package restorer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/restic/restic/internal/data"
+)
+
+// OwnerMap remaps the numeric user and group IDs of restored items.
+type OwnerMap struct {
+	uid map[uint32]uint32
+	gid map[uint32]uint32
+	// set if all IDs without an explicit mapping are mapped to a single ID
+	defaultUID *uint32
+	defaultGID *uint32
+}
+
+// ParseOwnerMap parses rules in the format "uid:from=to" or "gid:from=to",
+// several rules can be separated by commas. Using "*" as from maps all IDs
+// without an explicit rule.
+func ParseOwnerMap(rules []string) (*OwnerMap, error) {
+	m := &OwnerMap{
+		uid: make(map[uint32]uint32),
+		gid: make(map[uint32]uint32),
+	}
+
+	for _, rule := range rules {
+		for _, r := range strings.Split(rule, ",") {
+			if err := m.addRule(strings.TrimSpace(r)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return m, nil
+}
+
+func (m *OwnerMap) addRule(rule string) error {
+	kind, mapping, ok := strings.Cut(rule, ":")
+	if !ok {
+		return fmt.Errorf("invalid owner mapping %q, expected uid:from=to or gid:from=to", rule)
+	}
+	from, to, ok := strings.Cut(mapping, "=")
+	if !ok {
+		return fmt.Errorf("invalid owner mapping %q, expected uid:from=to or gid:from=to", rule)
+	}
+
+	toID, err := strconv.ParseUint(to, 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid target id in owner mapping %q: %w", rule, err)
+	}
+	target := uint32(toID)
+
+	var ids map[uint32]uint32
+	var defaultID **uint32
+	switch kind {
+	case "uid":
+		ids, defaultID = m.uid, &m.defaultUID
+	case "gid":
+		ids, defaultID = m.gid, &m.defaultGID
+	default:
+		return fmt.Errorf("invalid owner mapping %q, must start with uid: or gid:", rule)
+	}
+
+	if from == "*" {
+		if *defaultID != nil {
+			return fmt.Errorf("duplicate default %v mapping %q", kind, rule)
+		}
+		*defaultID = &target
+		return nil
+	}
+
+	fromID, err := strconv.ParseUint(from, 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid source id in owner mapping %q: %w", rule, err)
+	}
+	if _, ok := ids[uint32(fromID)]; ok {
+		return fmt.Errorf("duplicate %v mapping for %v", kind, fromID)
+	}
+	ids[uint32(fromID)] = target
+	return nil
+}
+
+func mapID(id uint32, ids map[uint32]uint32, defaultID *uint32) uint32 {
+	if to, ok := ids[id]; ok {
+		return to
+	}
+	if defaultID != nil {
+		return *defaultID
+	}
+	return id
+}
+
+// apply returns node with the user and group IDs remapped. node is not modified.
+func (m *OwnerMap) apply(node *data.Node) *data.Node {
+	if m == nil {
+		return node
+	}
+
+	n := *node
+	n.UID = mapID(node.UID, m.uid, m.defaultUID)
+	n.GID = mapID(node.GID, m.gid, m.defaultGID)
+	return &n
+}
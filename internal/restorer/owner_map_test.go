@@ -0,0 +1,50 @@
+package restorer
+
+import (
+	"testing"
+
+	"github.com/restic/restic/internal/data"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestOwnerMap(t *testing.T) {
+	m, err := ParseOwnerMap([]string{"uid:1000=0,gid:100=0", "uid:*=65534"})
+	rtest.OK(t, err)
+
+	for _, test := range []struct {
+		uid, gid         uint32
+		wantUID, wantGID uint32
+	}{
+		{1000, 100, 0, 0},
+		{1001, 101, 65534, 101},
+		{0, 0, 65534, 0},
+	} {
+		node := &data.Node{UID: test.uid, GID: test.gid}
+		mapped := m.apply(node)
+		rtest.Equals(t, test.wantUID, mapped.UID)
+		rtest.Equals(t, test.wantGID, mapped.GID)
+		// the original node is not modified
+		rtest.Equals(t, test.uid, node.UID)
+	}
+
+	var nilMap *OwnerMap
+	node := &data.Node{UID: 42}
+	rtest.Equals(t, node, nilMap.apply(node))
+}
+
+func TestOwnerMapInvalid(t *testing.T) {
+	for _, rule := range []string{
+		"",
+		"uid",
+		"uid:1000",
+		"user:1000=0",
+		"uid:foo=0",
+		"uid:1000=bar",
+		"gid:1=2,gid:1=3",
+		"uid:*=1,uid:*=2",
+		"uid:1000=-1",
+	} {
+		_, err := ParseOwnerMap([]string{rule})
+		rtest.Assert(t, err != nil, "missing error for %q", rule)
+	}
+}
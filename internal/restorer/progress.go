@@ -17,7 +17,13 @@ type ProgressReporter interface {
 	AddFile(size uint64)
 	AddProgress(name string, action ItemAction, bytesWrittenPortion, bytesTotal uint64)
 	AddSkippedFile(name string, size uint64)
+	// AddReusedBytes records file content that already existed in the target
+	// and therefore did not have to be downloaded.
+	AddReusedBytes(size uint64)
 	ReportDeletion(name string)
+	// ReportMetadataNotRestored records metadata of an item which could not be
+	// restored due to missing privileges.
+	ReportMetadataNotRestored(name string, metadata []string)
 }
 
 type noopProgressReporter struct{}
@@ -28,7 +34,10 @@ func (noopProgressReporter) AddFile(uint64) {}
 func (noopProgressReporter) AddProgress(string, ItemAction, uint64, uint64) {
 }
 func (noopProgressReporter) AddSkippedFile(string, uint64) {}
+func (noopProgressReporter) AddReusedBytes(uint64)         {}
 func (noopProgressReporter) ReportDeletion(string)         {}
+func (noopProgressReporter) ReportMetadataNotRestored(string, []string) {
+}
 
 func progressOrNoop(p ProgressReporter) ProgressReporter {
 	if p == nil {
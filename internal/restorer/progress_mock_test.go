@@ -14,6 +14,9 @@ type progressState struct {
 	AllBytesWritten uint64
 	AllBytesTotal   uint64
 	AllBytesSkipped uint64
+	AllBytesReused  uint64
+
+	MetadataNotRestored uint64
 }
 
 type testProgress struct {
@@ -54,10 +57,18 @@ func (p *testProgress) AddSkippedFile(_ string, size uint64) {
 	p.s.AllBytesSkipped += size
 }
 
+func (p *testProgress) AddReusedBytes(size uint64) {
+	p.s.AllBytesReused += size
+}
+
 func (p *testProgress) ReportDeletion(_ string) {
 	p.s.FilesDeleted++
 }
 
+func (p *testProgress) ReportMetadataNotRestored(_ string, _ []string) {
+	p.s.MetadataNotRestored++
+}
+
 func (p *testProgress) state() progressState {
 	return p.s
 }
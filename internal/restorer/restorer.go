@@ -44,6 +44,40 @@ type Options struct {
 	Overwrite       OverwriteBehavior
 	Delete          bool
 	OwnershipByName bool
+	// OwnerMap remaps the user and group IDs of restored items, if set.
+	OwnerMap *OwnerMap
+	// NoOwner and NoPerms skip restoring the owner and the permissions.
+	NoOwner bool
+	NoPerms bool
+	// ResumeState is the path of a journal that records completely restored
+	// files. Files listed in it are skipped when an interrupted restore is resumed.
+	ResumeState string
+	// HardlinkIndex is the path of a file that records where hardlinked files
+	// were restored. It allows linking files across separate restore runs.
+	HardlinkIndex string
+	// SecureTarget refuses to follow symlinks inside the target directory and
+	// to restore files outside of it. The path of each item is checked before
+	// the item is restored, a symlink created between the check and the
+	// restore of the item is not detected.
+	SecureTarget bool
+	// DownloadLimitKb is the download limit of the repository in KiB/s. If
+	// set, fewer pack files are downloaded concurrently.
+	DownloadLimitKb int
+	// MetadataOnly only restores the metadata of items which already exist in
+	// the target directory. No items are created or removed and the content
+	// of files is not modified.
+	MetadataOnly bool
+	// SecurityDescriptors selects which parts of Windows security descriptors
+	// are restored.
+	SecurityDescriptors fs.SecurityDescriptorMode
+	// SkipADS skips restoring the alternate data streams of files on Windows.
+	SkipADS bool
+	// Verify selects how the content of restored files is verified. Only
+	// VerifyInline is handled by the restorer, VerifyAfter requires calling
+	// VerifyFiles once the restore has finished.
+	Verify VerifyMode
+	// Fsync flushes each file to disk once its content was completely restored.
+	Fsync bool
 }
 
 type OverwriteBehavior int
@@ -97,6 +131,49 @@ func (c *OverwriteBehavior) Type() string {
 	return "behavior"
 }
 
+// VerifyMode selects how the content of restored files is verified.
+type VerifyMode int
+
+const (
+	// VerifyNone does not verify restored files.
+	VerifyNone VerifyMode = iota
+	// VerifyAfter reads all restored files again after the restore has finished.
+	VerifyAfter
+	// VerifyInline reads back the data written to a file directly after
+	// writing it and compares it with the blob.
+	VerifyInline
+)
+
+// Set implements the method needed for pflag command flag parsing.
+func (m *VerifyMode) Set(s string) error {
+	switch s {
+	case "none":
+		*m = VerifyNone
+	case "after":
+		*m = VerifyAfter
+	case "inline":
+		*m = VerifyInline
+	default:
+		return fmt.Errorf("invalid verify mode %q, must be one of (after|inline)", s)
+	}
+	return nil
+}
+
+func (m *VerifyMode) String() string {
+	switch *m {
+	case VerifyAfter:
+		return "after"
+	case VerifyInline:
+		return "inline"
+	default:
+		return "none"
+	}
+}
+
+func (m *VerifyMode) Type() string {
+	return "mode"
+}
+
 // NewRestorer creates a restorer preloaded with the content from the snapshot id.
 func NewRestorer(repo restic.Repository, sn *data.Snapshot, opts Options) *Restorer {
 	opts.Progress = progressOrNoop(opts.Progress)
@@ -135,6 +212,9 @@ func (res *Restorer) sanitizeError(location string, err error) error {
 // target is the path in the file system, location within the snapshot.
 func (res *Restorer) traverseTree(ctx context.Context, target string, treeID restic.ID, visitor treeVisitor) error {
 	location := string(filepath.Separator)
+	if res.opts.SecureTarget {
+		visitor = secureTreeVisitor(target, visitor)
+	}
 
 	if visitor.enterDir != nil {
 		err := res.sanitizeError(location, visitor.enterDir(nil, target, location))
@@ -214,6 +294,11 @@ func (res *Restorer) traverseTreeInner(ctx context.Context, target, location str
 			continue
 		}
 
+		if res.opts.SkipADS && fs.IsAlternateDataStream(node.Name) {
+			debug.Log("skipping alternate data stream %v", nodeLocation)
+			continue
+		}
+
 		selectedForRestore, childMayBeSelected := res.SelectFilter(nodeLocation, node.Type == data.NodeTypeDir)
 		debug.Log("SelectFilter returned %v %v for %q", selectedForRestore, childMayBeSelected, nodeLocation)
 
@@ -296,7 +381,20 @@ func (res *Restorer) restoreNodeMetadataTo(node *data.Node, target, location str
 		return nil
 	}
 	debug.Log("restoreNodeMetadata %v %v %v", node.Name, target, location)
-	err := fs.NodeRestoreMetadata(node, target, res.Warn, res.XattrSelectFilter, res.opts.OwnershipByName)
+	if res.opts.SecureTarget && node.Type != data.NodeTypeSymlink {
+		if err := checkNotSymlink(target); err != nil {
+			return err
+		}
+	}
+	err := fs.NodeRestoreMetadata(res.opts.OwnerMap.apply(node), target, res.Warn, res.XattrSelectFilter, fs.RestoreMetadataOptions{
+		OwnershipByName:     res.opts.OwnershipByName,
+		NoOwner:             res.opts.NoOwner,
+		NoPerms:             res.opts.NoPerms,
+		SecurityDescriptors: res.opts.SecurityDescriptors,
+		ReportNotRestored: func(metadata []string) {
+			res.opts.Progress.ReportMetadataNotRestored(location, metadata)
+		},
+	})
 	if err != nil {
 		debug.Log("node.RestoreMetadata(%s) error %v", target, err)
 	}
@@ -352,6 +450,10 @@ func (res *Restorer) RestoreTo(ctx context.Context, dst string) (uint64, error)
 		}
 	}
 
+	if res.opts.MetadataOnly {
+		return res.restoreMetadataOnly(ctx, dst)
+	}
+
 	if !res.opts.DryRun {
 		// ensure that the target directory exists and is actually a directory
 		// Using ensureDir is too aggressive here as it also removes unexpected files
@@ -360,12 +462,54 @@ func (res *Restorer) RestoreTo(ctx context.Context, dst string) (uint64, error)
 		}
 	}
 
+	var resume *resumeState
+	if res.opts.ResumeState != "" && !res.opts.DryRun {
+		res.opts.ResumeState, err = filepath.Abs(res.opts.ResumeState)
+		if err != nil {
+			return restoredFileCount, errors.Wrap(err, "Abs")
+		}
+		resume, err = openResumeState(res.opts.ResumeState, *res.sn.Tree)
+		if err != nil {
+			return restoredFileCount, err
+		}
+		defer func() {
+			_ = resume.Close()
+		}()
+	}
+
+	var links *hardlinkIndex
+	if res.opts.HardlinkIndex != "" {
+		res.opts.HardlinkIndex, err = filepath.Abs(res.opts.HardlinkIndex)
+		if err != nil {
+			return restoredFileCount, errors.Wrap(err, "Abs")
+		}
+		// the index is shared by restores of different subfolders of a snapshot
+		snapshotID := *res.sn.Tree
+		if res.sn.ID() != nil {
+			snapshotID = *res.sn.ID()
+		}
+		links, err = loadHardlinkIndex(res.opts.HardlinkIndex, snapshotID)
+		if err != nil {
+			return restoredFileCount, err
+		}
+	}
+
+	// idx maps hardlinked inodes to the target path of the first restored file
 	idx := data.NewHardlinkIndex[string]()
 	filerestorer := newFileRestorer(dst, res.repo.LoadBlobsFromPack, res.repo.LookupBlob,
-		res.repo.Connections(), res.opts.Sparse, res.opts.Delete, res.repo.StartWarmup, res.opts.Progress,
+		downloadWorkers(res.repo.Connections(), res.opts.DownloadLimitKb), res.opts.Sparse, res.opts.Delete, res.repo.StartWarmup, res.opts.Progress,
 		res.repo.ChunkerFactory().ZeroChunk())
 	filerestorer.Error = res.Error
 	filerestorer.Info = res.Info
+	filerestorer.FileDone = resume.markDone
+	// a file must only be recorded in the resume state once its content is on disk
+	filerestorer.fsync = res.opts.Fsync || resume != nil
+	filerestorer.filesWriter.verify = res.opts.Verify == VerifyInline
+	if res.opts.SecureTarget {
+		filerestorer.filesWriter.checkPath = func(path string) error {
+			return checkSecureTarget(dst, path)
+		}
+	}
 
 	debug.Log("first pass for %q", dst)
 
@@ -393,12 +537,27 @@ func (res *Restorer) RestoreTo(ctx context.Context, dst string) (uint64, error)
 			}
 
 			if node.Links > 1 {
+				if !idx.Has(node.Inode, node.DeviceID) {
+					// link to the file restored by a previous run, if any
+					// a secure restore never links to files outside of the target directory
+					if path, ok := links.lookup(node.Inode, node.DeviceID, target); ok && (!res.opts.SecureTarget || fs.HasPathPrefix(dst, path)) {
+						idx.Add(node.Inode, node.DeviceID, path)
+					}
+				}
 				if idx.Has(node.Inode, node.DeviceID) {
 					// a hardlinked file does not increase the restore size
 					res.opts.Progress.AddFile(0)
 					return nil
 				}
-				idx.Add(node.Inode, node.DeviceID, location)
+				idx.Add(node.Inode, node.DeviceID, target)
+				links.add(node.Inode, node.DeviceID, target)
+			}
+
+			if resume.isDone(location) && fileHasSize(target, node.Size) {
+				debug.Log("skipping %q, already restored by previous run", location)
+				res.opts.Progress.AddSkippedFile(location, node.Size)
+				res.trackFile(location, true)
+				return nil
 			}
 
 			buf, err = res.withOverwriteCheck(ctx, node, target, location, false, buf, func(updateMetadataOnly bool, matches *fileState) error {
@@ -450,9 +609,9 @@ func (res *Restorer) RestoreTo(ctx context.Context, dst string) (uint64, error)
 				return err
 			}
 
-			if idx.Has(node.Inode, node.DeviceID) && idx.Value(node.Inode, node.DeviceID) != location {
+			if idx.Has(node.Inode, node.DeviceID) && idx.Value(node.Inode, node.DeviceID) != target {
 				_, err := res.withOverwriteCheck(ctx, node, target, location, true, nil, func(_ bool, _ *fileState) error {
-					return res.restoreHardlinkAt(node, filerestorer.targetPath(idx.Value(node.Inode, node.DeviceID)), target, location)
+					return res.restoreHardlinkAt(node, idx.Value(node.Inode, node.DeviceID), target, location)
 				})
 				return err
 			}
@@ -481,7 +640,31 @@ func (res *Restorer) RestoreTo(ctx context.Context, dst string) (uint64, error)
 			return err
 		},
 	})
-	return restoredFileCount, err
+	if err != nil {
+		return restoredFileCount, err
+	}
+
+	if !res.opts.DryRun {
+		if err := links.save(); err != nil {
+			return restoredFileCount, err
+		}
+	}
+
+	// the restore is complete, thus the journal is no longer needed
+	if err := resume.Remove(); err != nil {
+		return restoredFileCount, errors.Wrap(err, "remove restore state")
+	}
+	resume = nil
+	return restoredFileCount, nil
+}
+
+// fileHasSize returns whether target is a regular file of the given size.
+func fileHasSize(target string, size uint64) bool {
+	fi, err := fs.Lstat(target)
+	if err != nil {
+		return false
+	}
+	return fi.Mode().IsRegular() && uint64(fi.Size()) == size
 }
 
 func (res *Restorer) removeUnexpectedFiles(ctx context.Context, target, location string, expectedFilenames []string) error {
@@ -517,6 +700,11 @@ func (res *Restorer) removeUnexpectedFiles(ctx context.Context, target, location
 			return fmt.Errorf("skipping deletion due to invalid filename: %v", entry)
 		}
 
+		if res.opts.ResumeState != "" && nodeTarget == res.opts.ResumeState {
+			// never delete the journal of the running restore
+			continue
+		}
+
 		// TODO pass a proper value to the isDir parameter once this becomes relevant for the filters
 		selectedForRestore, _ := res.SelectFilter(nodeLocation, false)
 		// only delete files that were selected for restore
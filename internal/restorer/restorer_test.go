@@ -1031,6 +1031,7 @@ func TestRestorerOverwriteBehavior(t *testing.T) {
 				AllBytesWritten: 40,
 				AllBytesTotal:   40,
 				AllBytesSkipped: 0,
+				AllBytesReused:  12,
 			},
 		},
 		{
@@ -1047,6 +1048,7 @@ func TestRestorerOverwriteBehavior(t *testing.T) {
 				AllBytesWritten: 40,
 				AllBytesTotal:   40,
 				AllBytesSkipped: 0,
+				AllBytesReused:  12,
 			},
 		},
 		{
@@ -1144,6 +1146,8 @@ func TestRestorerOverwritePartial(t *testing.T) {
 		AllBytesWritten: uint64(size),
 		AllBytesTotal:   uint64(size),
 		AllBytesSkipped: 0,
+		// the first five parts of both files are already present in the target
+		AllBytesReused: 10,
 	}, progress.state())
 }
 
@@ -1233,6 +1237,39 @@ func TestRestoreModified(t *testing.T) {
 	}
 }
 
+func TestRestoreVerifyInline(t *testing.T) {
+	snapshots := []Snapshot{
+		{
+			Nodes: map[string]Node{
+				"foo": File{Data: "content: foo\n", ModTime: time.Now()},
+				"bar": File{Data: "content: a\n", ModTime: time.Now()},
+			},
+		},
+		{
+			Nodes: map[string]Node{
+				"foo": File{Data: "content: a\n", ModTime: time.Now()},
+				"bar": File{Data: "content: bar\n", ModTime: time.Now()},
+			},
+		},
+	}
+
+	repo := repository.TestRepository(t)
+	tempdir := filepath.Join(rtest.TempDir(t), "target")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for _, snapshot := range snapshots {
+		sn, _ := saveSnapshot(t, repo, snapshot, noopGetGenericAttributes)
+
+		res := NewRestorer(repo, sn, Options{Verify: VerifyInline, Fsync: true})
+		countRestoredFiles, err := res.RestoreTo(ctx, tempdir)
+		rtest.OK(t, err)
+		n, err := res.VerifyFiles(ctx, tempdir, countRestoredFiles, restic.NoopCounter)
+		rtest.OK(t, err)
+		rtest.Equals(t, 2, n, "unexpected number of verified files")
+	}
+}
+
 func TestRestoreIfChanged(t *testing.T) {
 	origData := "content: foo\n"
 	modData := "content: bar\n"
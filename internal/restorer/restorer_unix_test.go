@@ -4,9 +4,11 @@ package restorer
 
 import (
 	"context"
+	"errors"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 	"syscall"
 	"testing"
 	"time"
@@ -53,6 +55,46 @@ func TestRestorerRestoreEmptyHardlinkedFields(t *testing.T) {
 	}
 }
 
+func TestRestorerHardlinkIndex(t *testing.T) {
+	repo := repository.TestRepository(t)
+
+	sn, _ := saveSnapshot(t, repo, Snapshot{
+		Nodes: map[string]Node{
+			"dir1": Dir{
+				Nodes: map[string]Node{
+					"file": File{Data: "content: file\n", Links: 2, Inode: 1},
+				},
+			},
+			"dir2": Dir{
+				Nodes: map[string]Node{
+					"link": File{Data: "content: file\n", Links: 2, Inode: 1},
+				},
+			},
+		},
+	}, noopGetGenericAttributes)
+
+	tempdir := rtest.TempDir(t)
+	indexPath := filepath.Join(rtest.TempDir(t), "hardlinks")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// restore each directory in a separate run
+	for _, dir := range []string{"/dir1", "/dir2"} {
+		res := NewRestorer(repo, sn, Options{HardlinkIndex: indexPath})
+		res.SelectFilter = func(item string, _ bool) (bool, bool) {
+			return item == dir || strings.HasPrefix(item, dir+"/"), item == "/" || item == dir || strings.HasPrefix(item, dir+"/")
+		}
+		_, err := res.RestoreTo(ctx, tempdir)
+		rtest.OK(t, err)
+	}
+
+	f1, err := os.Stat(filepath.Join(tempdir, "dir1/file"))
+	rtest.OK(t, err)
+	f2, err := os.Stat(filepath.Join(tempdir, "dir2/link"))
+	rtest.OK(t, err)
+	rtest.Assert(t, os.SameFile(f1, f2), "expected files from separate restores to be hardlinked")
+}
+
 func getBlockCount(t *testing.T, filename string) int64 {
 	fi, err := os.Stat(filename)
 	rtest.OK(t, err)
@@ -138,3 +180,126 @@ func TestRestorePermissions(t *testing.T) {
 		rtest.Equals(t, fs.FileMode(0o600), fi.Mode().Perm(), "unexpected permissions")
 	}
 }
+
+func TestRestoreNoPerms(t *testing.T) {
+	snapshot := Snapshot{
+		Nodes: map[string]Node{
+			"foo": File{Data: "content: foo\n", Mode: 0o444, ModTime: time.Now()},
+		},
+	}
+
+	repo := repository.TestRepository(t)
+	tempdir := filepath.Join(rtest.TempDir(t), "target")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sn, _ := saveSnapshot(t, repo, snapshot, noopGetGenericAttributes)
+
+	res := NewRestorer(repo, sn, Options{NoPerms: true, NoOwner: true})
+	_, err := res.RestoreTo(ctx, tempdir)
+	rtest.OK(t, err)
+
+	// the file keeps the permissions it was created with
+	fi, err := os.Stat(filepath.Join(tempdir, "foo"))
+	rtest.OK(t, err)
+	rtest.Equals(t, fs.FileMode(0o600), fi.Mode().Perm(), "unexpected permissions")
+}
+
+func TestRestoreSecureTarget(t *testing.T) {
+	repo := repository.TestRepository(t)
+	sn, _ := saveSnapshot(t, repo, Snapshot{
+		Nodes: map[string]Node{
+			"a": Dir{
+				Nodes: map[string]Node{
+					"b": Dir{
+						Nodes: map[string]Node{
+							"file": File{Data: "content: file\n"},
+						},
+					},
+				},
+			},
+		},
+	}, noopGetGenericAttributes)
+
+	for _, secure := range []bool{false, true} {
+		tempdir := rtest.TempDir(t)
+		dst := filepath.Join(tempdir, "target")
+		outside := filepath.Join(tempdir, "outside")
+		rtest.OK(t, os.MkdirAll(filepath.Join(outside, "b"), 0700))
+		rtest.OK(t, os.MkdirAll(dst, 0700))
+		// the directory "a" is replaced by a symlink to a directory outside of the target
+		rtest.OK(t, os.Symlink(outside, filepath.Join(dst, "a")))
+
+		res := NewRestorer(repo, sn, Options{SecureTarget: secure})
+		// only restore the file such that the parent directories are not recreated
+		res.SelectFilter = func(item string, isDir bool) (bool, bool) {
+			return item == "/a/b/file", true
+		}
+
+		_, err := res.RestoreTo(context.TODO(), dst)
+		_, statErr := os.Stat(filepath.Join(outside, "b", "file"))
+		if secure {
+			rtest.Assert(t, err != nil && strings.Contains(err.Error(), "refusing to follow symlink"), "unexpected error %v", err)
+			rtest.Assert(t, os.IsNotExist(statErr), "file was restored outside of the target directory")
+		} else {
+			rtest.OK(t, err)
+			rtest.OK(t, statErr)
+		}
+	}
+}
+
+func TestRestoreMetadataOnly(t *testing.T) {
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	snapshot := Snapshot{
+		Nodes: map[string]Node{
+			"dir": Dir{
+				Mode:    os.ModeDir | 0o750,
+				ModTime: modTime,
+				Nodes: map[string]Node{
+					"foo":     File{Data: "content: foo\n", Mode: 0o640, ModTime: modTime},
+					"missing": File{Data: "content: missing\n", Mode: 0o640, ModTime: modTime},
+				},
+			},
+		},
+	}
+
+	repo := repository.TestRepository(t)
+	tempdir := filepath.Join(rtest.TempDir(t), "target")
+	sn, _ := saveSnapshot(t, repo, snapshot, noopGetGenericAttributes)
+
+	// the target was restored using a different tool, which did not preserve any metadata
+	foo := filepath.Join(tempdir, "dir", "foo")
+	rtest.OK(t, os.MkdirAll(filepath.Join(tempdir, "dir"), 0o700))
+	rtest.OK(t, os.WriteFile(foo, []byte("modified content\n"), 0o600))
+
+	var warnings []string
+	res := NewRestorer(repo, sn, Options{MetadataOnly: true})
+	res.Warn = func(message string) {
+		warnings = append(warnings, message)
+	}
+	count, err := res.RestoreTo(context.TODO(), tempdir)
+	rtest.OK(t, err)
+	rtest.Equals(t, uint64(2), count)
+	rtest.Assert(t, len(warnings) == 1, "unexpected warnings %v", warnings)
+	rtest.Assert(t, strings.Contains(warnings[0], "missing"), "unexpected warning %q", warnings[0])
+
+	for _, item := range []struct {
+		path string
+		mode fs.FileMode
+	}{
+		{filepath.Join(tempdir, "dir"), 0o750},
+		{foo, 0o640},
+	} {
+		fi, err := os.Lstat(item.path)
+		rtest.OK(t, err)
+		rtest.Equals(t, item.mode, fi.Mode().Perm(), "unexpected permissions for %v", item.path)
+		rtest.Assert(t, fi.ModTime().Equal(modTime), "unexpected modification time %v for %v", fi.ModTime(), item.path)
+	}
+
+	// neither the content of existing files is modified nor are missing files created
+	buf, err := os.ReadFile(foo)
+	rtest.OK(t, err)
+	rtest.Equals(t, "modified content\n", string(buf))
+	_, err = os.Lstat(filepath.Join(tempdir, "dir", "missing"))
+	rtest.Assert(t, errors.Is(err, os.ErrNotExist), "missing file was created: %v", err)
+}
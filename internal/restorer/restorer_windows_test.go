@@ -578,3 +578,36 @@ func TestRestoreDeleteCaseInsensitive(t *testing.T) {
 	_, err = os.Stat(filepath.Join(tempdir, "anotherfile"))
 	rtest.OK(t, err)
 }
+
+func TestRestoreSkipADS(t *testing.T) {
+	repo := repository.TestRepository(t)
+
+	sn, _ := saveSnapshot(t, repo, Snapshot{
+		Nodes: map[string]Node{
+			"file":        File{Data: "content: file\n"},
+			"file:stream": File{Data: "content: stream\n"},
+		},
+	}, noopGetGenericAttributes)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for _, skip := range []bool{false, true} {
+		tempdir := rtest.TempDir(t)
+		res := NewRestorer(repo, sn, Options{SkipADS: skip})
+		_, err := res.RestoreTo(ctx, tempdir)
+		rtest.OK(t, err)
+
+		content, err := os.ReadFile(filepath.Join(tempdir, "file"))
+		rtest.OK(t, err)
+		rtest.Equals(t, "content: file\n", string(content))
+
+		content, err = os.ReadFile(filepath.Join(tempdir, "file:stream"))
+		if skip {
+			rtest.Assert(t, errors.Is(err, os.ErrNotExist), "expected stream to be skipped, got %v", err)
+		} else {
+			rtest.OK(t, err)
+			rtest.Equals(t, "content: stream\n", string(content))
+		}
+	}
+}
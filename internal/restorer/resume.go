@@ -0,0 +1,151 @@
+package restorer
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/fs"
+	"github.com/restic/restic/internal/restic"
+)
+
+const resumeStateHeader = "restic restore state v1"
+
+// resumeState is a journal of files whose content was completely restored.
+// It allows an interrupted restore to skip these files when it is resumed.
+//
+// The file format is line based. The first line contains a header followed
+// by the ID of the restored tree. Each following line contains the quoted
+// location of a completely restored file.
+type resumeState struct {
+	path string
+	done map[string]struct{}
+
+	m sync.Mutex
+	f *os.File
+}
+
+// openResumeState loads the journal at path, or creates a new one if it does
+// not exist yet. A journal that was written for a different tree is rejected.
+func openResumeState(path string, treeID restic.ID) (*resumeState, error) {
+	s := &resumeState{
+		path: path,
+		done: make(map[string]struct{}),
+	}
+
+	header := fmt.Sprintf("%s %s", resumeStateHeader, treeID)
+
+	f, err := fs.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, errors.Wrap(err, "open restore state")
+	}
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 1024*1024)
+	first := true
+	for sc.Scan() {
+		line := sc.Text()
+		if first {
+			first = false
+			if line != header {
+				_ = f.Close()
+				return nil, errors.Errorf("restore state %v belongs to a different restore", path)
+			}
+			continue
+		}
+
+		location, err := strconv.Unquote(line)
+		if err != nil {
+			// the last line may be incomplete if restic was interrupted while writing it
+			continue
+		}
+		s.done[location] = struct{}{}
+	}
+	if err := sc.Err(); err != nil {
+		_ = f.Close()
+		return nil, errors.Wrap(err, "read restore state")
+	}
+
+	if first {
+		if _, err := f.WriteString(header + "\n"); err != nil {
+			_ = f.Close()
+			return nil, errors.Wrap(err, "write restore state")
+		}
+	} else if err := ensureTrailingNewline(f); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	s.f = f
+	return s, nil
+}
+
+// ensureTrailingNewline terminates a partially written last line such that
+// new entries start on a line of their own.
+func ensureTrailingNewline(f *os.File) error {
+	fi, err := f.Stat()
+	if err != nil {
+		return errors.Wrap(err, "stat restore state")
+	}
+	if fi.Size() == 0 {
+		return nil
+	}
+
+	buf := make([]byte, 1)
+	if _, err := f.ReadAt(buf, fi.Size()-1); err != nil {
+		return errors.Wrap(err, "read restore state")
+	}
+	if buf[0] != '\n' {
+		if _, err := f.WriteString("\n"); err != nil {
+			return errors.Wrap(err, "write restore state")
+		}
+	}
+	return nil
+}
+
+// isDone returns whether the content of the file at location was completely
+// restored by a previous run.
+func (s *resumeState) isDone(location string) bool {
+	if s == nil {
+		return false
+	}
+	_, ok := s.done[location]
+	return ok
+}
+
+// markDone records that the content of the file at location was completely restored.
+func (s *resumeState) markDone(location string) error {
+	if s == nil {
+		return nil
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if _, err := s.f.WriteString(strconv.Quote(location) + "\n"); err != nil {
+		return errors.Wrap(err, "write restore state")
+	}
+	return errors.Wrap(s.f.Sync(), "sync restore state")
+}
+
+// Close closes the journal.
+func (s *resumeState) Close() error {
+	if s == nil {
+		return nil
+	}
+	return s.f.Close()
+}
+
+// Remove closes and deletes the journal once the restore has completed.
+func (s *resumeState) Remove() error {
+	if s == nil {
+		return nil
+	}
+	if err := s.f.Close(); err != nil {
+		return err
+	}
+	return fs.Remove(s.path)
+}
@@ -0,0 +1,145 @@
+package restorer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestResumeState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state")
+	treeID := restic.NewRandomID()
+
+	s, err := openResumeState(path, treeID)
+	rtest.OK(t, err)
+	rtest.OK(t, s.markDone("/foo"))
+	rtest.OK(t, s.markDone("/dir/with\nnewline"))
+	rtest.OK(t, s.Close())
+
+	// simulate an interrupted write of the last entry
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	rtest.OK(t, err)
+	_, err = f.WriteString(`"/incompl`)
+	rtest.OK(t, err)
+	rtest.OK(t, f.Close())
+
+	s, err = openResumeState(path, treeID)
+	rtest.OK(t, err)
+	rtest.Assert(t, s.isDone("/foo"), "missing entry for /foo")
+	rtest.Assert(t, s.isDone("/dir/with\nnewline"), "missing entry with newline")
+	rtest.Assert(t, !s.isDone("/incompl"), "unexpected entry for incomplete line")
+	rtest.OK(t, s.markDone("/bar"))
+	rtest.OK(t, s.Close())
+
+	s, err = openResumeState(path, treeID)
+	rtest.OK(t, err)
+	rtest.Assert(t, s.isDone("/bar"), "missing entry for /bar")
+	rtest.OK(t, s.Remove())
+
+	_, err = os.Stat(path)
+	rtest.Assert(t, os.IsNotExist(err), "expected state file to be removed, got %v", err)
+}
+
+func TestResumeStateOtherTree(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state")
+
+	s, err := openResumeState(path, restic.NewRandomID())
+	rtest.OK(t, err)
+	rtest.OK(t, s.Close())
+
+	_, err = openResumeState(path, restic.NewRandomID())
+	rtest.Assert(t, err != nil, "expected error for state of a different tree")
+}
+
+func TestRestoreResume(t *testing.T) {
+	snapshot := Snapshot{
+		Nodes: map[string]Node{
+			"foo": File{Data: "content: foo\n", ModTime: time.Now()},
+			"bar": File{Data: "content: bar\n", ModTime: time.Now()},
+		},
+	}
+
+	repo := repository.TestRepository(t)
+	tempdir := filepath.Join(rtest.TempDir(t), "target")
+	statePath := filepath.Join(tempdir, ".restic-restore-state")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sn, _ := saveSnapshot(t, repo, snapshot, noopGetGenericAttributes)
+
+	// pretend that a previous run already restored "foo"
+	rtest.OK(t, os.MkdirAll(tempdir, 0700))
+	modData := "content: mod\n"
+	rtest.OK(t, os.WriteFile(filepath.Join(tempdir, "foo"), []byte(modData), 0600))
+	s, err := openResumeState(statePath, *sn.Tree)
+	rtest.OK(t, err)
+	rtest.OK(t, s.markDone("/foo"))
+	rtest.OK(t, s.Close())
+
+	progress := newTestProgress()
+	res := NewRestorer(repo, sn, Options{ResumeState: statePath, Delete: true, Progress: progress})
+	_, err = res.RestoreTo(ctx, tempdir)
+	rtest.OK(t, err)
+
+	data, err := os.ReadFile(filepath.Join(tempdir, "foo"))
+	rtest.OK(t, err)
+	rtest.Equals(t, modData, string(data), "expected file restored by previous run to be skipped")
+	data, err = os.ReadFile(filepath.Join(tempdir, "bar"))
+	rtest.OK(t, err)
+	rtest.Equals(t, "content: bar\n", string(data))
+	rtest.Equals(t, uint64(1), progress.s.FilesSkipped)
+
+	_, err = os.Stat(statePath)
+	rtest.Assert(t, os.IsNotExist(err), "expected state file to be removed after restore, got %v", err)
+}
+
+func TestRestoreResumeRecordsFiles(t *testing.T) {
+	snapshot := Snapshot{
+		Nodes: map[string]Node{
+			"foo":   File{Data: "content: foo\n"},
+			"empty": File{Data: ""},
+		},
+	}
+
+	repo := repository.TestRepository(t)
+	tempdir := filepath.Join(rtest.TempDir(t), "target")
+	statePath := filepath.Join(rtest.TempDir(t), "state")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sn, _ := saveSnapshot(t, repo, snapshot, noopGetGenericAttributes)
+
+	// cancel the restore once the content of both files was restored
+	progress := &abortingProgress{testProgress: newTestProgress(), after: 2, cancel: cancel}
+	res := NewRestorer(repo, sn, Options{ResumeState: statePath, Progress: progress})
+	_, err := res.RestoreTo(ctx, tempdir)
+	rtest.Assert(t, err != nil, "expected restore to be aborted")
+
+	s, err := openResumeState(statePath, *sn.Tree)
+	rtest.OK(t, err)
+	defer func() {
+		_ = s.Close()
+	}()
+	rtest.Assert(t, s.isDone("/foo"), "missing entry for /foo")
+	rtest.Assert(t, s.isDone("/empty"), "missing entry for /empty")
+}
+
+// abortingProgress cancels the restore once the given number of files was finished.
+type abortingProgress struct {
+	*testProgress
+	after  uint64
+	cancel func()
+}
+
+func (p *abortingProgress) AddProgress(name string, action ItemAction, bytesWrittenPortion, bytesTotal uint64) {
+	p.testProgress.AddProgress(name, action, bytesWrittenPortion, bytesTotal)
+	if p.s.FilesFinished >= p.after {
+		p.cancel()
+	}
+}
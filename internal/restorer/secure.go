@@ -0,0 +1,90 @@
+package restorer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/restic/restic/internal/data"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/fs"
+)
+
+// checkSecureTarget returns an error if target is not located below dst or if
+// one of the directories between dst and target is a symlink. Directories
+// which do not exist yet are fine, they are created by the restorer. As the
+// directories are checked using their path, a directory which is replaced by
+// a symlink after the check is not detected.
+func checkSecureTarget(dst, target string) error {
+	rel, err := filepath.Rel(dst, target)
+	if err != nil {
+		return err
+	}
+	if rel == "." {
+		return nil
+	}
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || filepath.IsAbs(rel) {
+		return errors.Errorf("refusing to restore %v outside of the target directory", target)
+	}
+
+	parts := strings.Split(rel, string(filepath.Separator))
+	dir := dst
+	for _, part := range parts[:len(parts)-1] {
+		dir = filepath.Join(dir, part)
+		fi, err := fs.Lstat(dir)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			return errors.Errorf("refusing to follow symlink %v", dir)
+		}
+	}
+	return nil
+}
+
+// checkNotSymlink returns an error if target is a symlink.
+func checkNotSymlink(target string) error {
+	fi, err := fs.Lstat(target)
+	if err != nil {
+		// errors are reported by the subsequent operation
+		return nil
+	}
+	if fi.Mode()&os.ModeSymlink != 0 {
+		return errors.Errorf("refusing to follow symlink %v", target)
+	}
+	return nil
+}
+
+// secureTreeVisitor wraps visitor such that the path of each node is checked
+// using checkSecureTarget before it is visited.
+func secureTreeVisitor(dst string, visitor treeVisitor) treeVisitor {
+	secure := visitor
+	if visitor.enterDir != nil {
+		secure.enterDir = func(node *data.Node, target, location string) error {
+			if err := checkSecureTarget(dst, target); err != nil {
+				return err
+			}
+			return visitor.enterDir(node, target, location)
+		}
+	}
+	if visitor.visitNode != nil {
+		secure.visitNode = func(node *data.Node, target, location string) error {
+			if err := checkSecureTarget(dst, target); err != nil {
+				return err
+			}
+			return visitor.visitNode(node, target, location)
+		}
+	}
+	if visitor.leaveDir != nil {
+		secure.leaveDir = func(node *data.Node, target, location string, entries []string) error {
+			if err := checkSecureTarget(dst, target); err != nil {
+				return err
+			}
+			return visitor.leaveDir(node, target, location, entries)
+		}
+	}
+	return secure
+}
@@ -1,14 +1,41 @@
 package restorer
 
 import (
+	"bytes"
+	"io"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/fileio"
 	"github.com/restic/restic/internal/restic"
 )
 
+// sparseMode controls how runs of zeros are written to a file.
+type sparseMode int
+
+const (
+	// sparseNone writes all zeros to the file.
+	sparseNone sparseMode = iota
+	// sparseSkip skips runs of zeros, as the file was truncated to its final
+	// size and thus already reads as zeros where no data was written.
+	sparseSkip
+	// sparsePunch punches holes into the file for runs of zeros, as the file
+	// may still contain old data.
+	sparsePunch
+)
+
+// writeAt writes p to f.File at offset, using the io_uring if available.
+func (f *partialFile) writeAt(p []byte, offset int64) (int, error) {
+	if f.ring != nil {
+		return f.ring.WriteAt(f.File, p, offset)
+	}
+	return f.File.WriteAt(p, offset)
+}
+
 // WriteAt writes p to f.File at offset. It tries to do a sparse write
 // and updates f.size.
 func (f *partialFile) WriteAt(p []byte, offset int64) (n int, err error) {
-	if !f.sparse {
-		return f.File.WriteAt(p, offset)
+	if f.sparse == sparseNone {
+		return f.writeAt(p, offset)
 	}
 
 	n = len(p)
@@ -16,6 +43,16 @@ func (f *partialFile) WriteAt(p []byte, offset int64) (n int, err error) {
 	// Skip the longest all-zero prefix of p.
 	// If it's long enough, we can punch a hole in the file.
 	skipped := restic.ZeroPrefixLen(p)
+
+	if f.sparse == sparsePunch {
+		// only punch holes for blobs that consist of zeros only, to avoid
+		// one additional system call per blob
+		if skipped < len(p) {
+			return f.writeAt(p, offset)
+		}
+		return n, fileio.ZeroRange(f.File, offset, int64(n))
+	}
+
 	p = p[skipped:]
 	offset += int64(skipped)
 
@@ -26,9 +63,41 @@ func (f *partialFile) WriteAt(p []byte, offset int64) (n int, err error) {
 
 	default:
 		var n2 int
-		n2, err = f.File.WriteAt(p, offset)
+		n2, err = f.writeAt(p, offset)
 		n = skipped + n2
 	}
 
 	return n, err
 }
+
+// zeroRange makes sure that the range of length bytes at offset reads as
+// zeros.
+func (f *partialFile) zeroRange(offset, length int64) error {
+	switch f.sparse {
+	case sparseSkip:
+		// already zeros, see WriteAt
+		return nil
+	case sparsePunch:
+		return fileio.ZeroRange(f.File, offset, length)
+	default:
+		return fileio.WriteZeros(f.File, offset, length)
+	}
+}
+
+// verifyAt reads back the data at offset and compares it with expected. The
+// blob data was already verified against its ID when it was loaded from the
+// repository, thus this detects data which was not written correctly.
+func (f *partialFile) verifyAt(expected []byte, offset int64) error {
+	buf := make([]byte, len(expected))
+	n, err := f.File.ReadAt(buf, offset)
+	if err == io.EOF && n == len(buf) {
+		err = nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "verify")
+	}
+	if !bytes.Equal(buf, expected) {
+		return errors.Errorf("verification failed: data written at offset %d does not match", offset)
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+// Package server implements a read-only HTTP API which exposes the snapshots,
+// trees and data blobs of a repository as JSON.
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/restic/restic/internal/data"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+)
+
+// Repository is the subset of the repository used by the server.
+type Repository interface {
+	restic.Lister
+	restic.LoaderUnpacked
+	LoadBlob(ctx context.Context, bh restic.BlobHandle, buf []byte) ([]byte, error)
+	LookupBlobSize(bh restic.BlobHandle) (size uint, exists bool)
+}
+
+// Snapshot is the JSON representation of a snapshot including its ID.
+type Snapshot struct {
+	*data.Snapshot
+
+	ID      *restic.ID `json:"id"`
+	ShortID string     `json:"short_id"`
+}
+
+type errorResponse struct {
+	Message string `json:"message"`
+}
+
+// Server serves the API for a repository. Each request must carry the token
+// passed to New as a bearer token in the Authorization header.
+type Server struct {
+	repo  Repository
+	token string
+	mux   *http.ServeMux
+}
+
+// New returns a server for repo. The index of repo must already be loaded.
+func New(repo Repository, token string) *Server {
+	s := &Server{
+		repo:  repo,
+		token: token,
+		mux:   http.NewServeMux(),
+	}
+
+	s.mux.HandleFunc("GET /snapshots", s.listSnapshots)
+	s.mux.HandleFunc("GET /snapshots/{id}", s.getSnapshot)
+	s.mux.HandleFunc("GET /trees/{id}", s.getTree)
+	s.mux.HandleFunc("GET /blobs/{id}", s.getBlob)
+
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="restic"`)
+		writeError(w, http.StatusUnauthorized, errors.New("unauthorized"))
+		return
+	}
+
+	debug.Log("%v %v", r.Method, r.URL.Path)
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+// listSnapshots returns all snapshots, oldest first. The list can be
+// filtered using the query parameters host, path and tag, which can be
+// specified multiple times.
+func (s *Server) listSnapshots(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := data.SnapshotFilter{
+		Hosts: query["host"],
+		Paths: query["path"],
+	}
+	for _, tags := range query["tag"] {
+		var l data.TagList
+		_ = l.Set(tags)
+		filter.Tags = append(filter.Tags, l)
+	}
+
+	var snapshots data.Snapshots
+	err := filter.FindAll(r.Context(), s.repo, s.repo, nil, func(_ string, sn *data.Snapshot, err error) error {
+		if err != nil {
+			return err
+		}
+		snapshots = append(snapshots, sn)
+		return nil
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	sort.Sort(sort.Reverse(snapshots))
+
+	list := make([]Snapshot, 0, len(snapshots))
+	for _, sn := range snapshots {
+		list = append(list, newSnapshot(sn))
+	}
+	writeJSON(w, list)
+}
+
+// getSnapshot returns the snapshot with the given ID or unique ID prefix.
+func (s *Server) getSnapshot(w http.ResponseWriter, r *http.Request) {
+	prefix := r.PathValue("id")
+	if strings.Contains(prefix, ":") {
+		writeError(w, http.StatusBadRequest, data.ErrInvalidSnapshotSyntax)
+		return
+	}
+
+	id, err := restic.Find(r.Context(), s.repo, restic.SnapshotFile, prefix)
+	if err != nil {
+		writeError(w, findErrorStatus(err), err)
+		return
+	}
+
+	sn, err := data.LoadSnapshot(r.Context(), s.repo, id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, newSnapshot(sn))
+}
+
+// getTree returns the JSON encoded tree with the given ID.
+func (s *Server) getTree(w http.ResponseWriter, r *http.Request) {
+	buf, ok := s.loadBlob(w, r, restic.TreeBlob)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(buf)
+}
+
+// getBlob returns the content of the data blob with the given ID.
+func (s *Server) getBlob(w http.ResponseWriter, r *http.Request) {
+	buf, ok := s.loadBlob(w, r, restic.DataBlob)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	_, _ = w.Write(buf)
+}
+
+// loadBlob loads the blob of type t whose ID is contained in the request
+// path. If the blob cannot be loaded, an error is written to w.
+func (s *Server) loadBlob(w http.ResponseWriter, r *http.Request, t restic.BlobType) ([]byte, bool) {
+	id, err := restic.ParseID(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return nil, false
+	}
+
+	bh := restic.BlobHandle{Type: t, ID: id}
+	if _, ok := s.repo.LookupBlobSize(bh); !ok {
+		writeError(w, http.StatusNotFound, errors.Errorf("%v not found", bh))
+		return nil, false
+	}
+
+	buf, err := s.repo.LoadBlob(r.Context(), bh, nil)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return nil, false
+	}
+	return buf, true
+}
+
+func newSnapshot(sn *data.Snapshot) Snapshot {
+	return Snapshot{
+		Snapshot: sn,
+		ID:       sn.ID(),
+		ShortID:  sn.ID().Str(),
+	}
+}
+
+func findErrorStatus(err error) int {
+	var noMatch *restic.NoIDByPrefixError
+	var multipleMatches *restic.MultipleIDMatchesError
+	switch {
+	case errors.As(err, &noMatch):
+		return http.StatusNotFound
+	case errors.As(err, &multipleMatches):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		debug.Log("encoding response failed: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	debug.Log("request failed with status %d: %v", status, err)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(errorResponse{Message: err.Error()})
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/data"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+const testToken = "secret"
+
+func get(t *testing.T, srv http.Handler, path string, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServer(t *testing.T) {
+	repo := repository.TestRepository(t)
+	sn1 := data.TestCreateSnapshot(t, repo, time.Unix(1460289341, 207401672), 2)
+	sn2 := data.TestCreateSnapshot(t, repo, time.Unix(1460289342, 207401672), 2)
+	srv := New(repo, testToken)
+
+	rec := get(t, srv, "/snapshots", testToken)
+	rtest.Equals(t, http.StatusOK, rec.Code)
+	var list []Snapshot
+	rtest.OK(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	rtest.Equals(t, 2, len(list))
+	rtest.Equals(t, *sn1.ID(), *list[0].ID)
+	rtest.Equals(t, *sn2.ID(), *list[1].ID)
+
+	rec = get(t, srv, "/snapshots?host=other", testToken)
+	rtest.Equals(t, http.StatusOK, rec.Code)
+	rtest.OK(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	rtest.Equals(t, 0, len(list))
+
+	rec = get(t, srv, "/snapshots/"+sn2.ID().Str(), testToken)
+	rtest.Equals(t, http.StatusOK, rec.Code)
+	var sn Snapshot
+	rtest.OK(t, json.Unmarshal(rec.Body.Bytes(), &sn))
+	rtest.Equals(t, *sn2.ID(), *sn.ID)
+	rtest.Equals(t, *sn2.Tree, *sn.Tree)
+
+	rec = get(t, srv, "/trees/"+sn.Tree.String(), testToken)
+	rtest.Equals(t, http.StatusOK, rec.Code)
+	tree, err := data.NewTreeNodeIterator(rec.Body)
+	rtest.OK(t, err)
+
+	var content restic.IDs
+	for item := range tree {
+		rtest.OK(t, item.Error)
+		if item.Node.Type == data.NodeTypeFile && len(item.Node.Content) > 0 {
+			content = item.Node.Content
+			break
+		}
+	}
+	rtest.Assert(t, len(content) > 0, "no file found in tree")
+
+	rec = get(t, srv, "/blobs/"+content[0].String(), testToken)
+	rtest.Equals(t, http.StatusOK, rec.Code)
+	buf, err := io.ReadAll(rec.Body)
+	rtest.OK(t, err)
+	expected, err := repo.LoadBlob(context.TODO(), restic.BlobHandle{Type: restic.DataBlob, ID: content[0]}, nil)
+	rtest.OK(t, err)
+	rtest.Equals(t, expected, buf)
+}
+
+func TestServerErrors(t *testing.T) {
+	repo := repository.TestRepository(t)
+	sn := data.TestCreateSnapshot(t, repo, time.Unix(1460289341, 207401672), 1)
+	srv := New(repo, testToken)
+
+	for _, test := range []struct {
+		path   string
+		token  string
+		status int
+	}{
+		{"/snapshots", "", http.StatusUnauthorized},
+		{"/snapshots", "wrong", http.StatusUnauthorized},
+		{"/snapshots/" + restic.NewRandomID().String(), testToken, http.StatusNotFound},
+		{"/snapshots/" + sn.ID().Str() + ":foo", testToken, http.StatusBadRequest},
+		{"/trees/invalid", testToken, http.StatusBadRequest},
+		{"/trees/" + restic.NewRandomID().String(), testToken, http.StatusNotFound},
+		// trees are not served as data blobs
+		{"/blobs/" + sn.Tree.String(), testToken, http.StatusNotFound},
+		{"/unknown", testToken, http.StatusNotFound},
+	} {
+		t.Run(test.path, func(t *testing.T) {
+			rec := get(t, srv, test.path, test.token)
+			rtest.Equals(t, test.status, rec.Code)
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, "/snapshots/"+sn.ID().String(), nil)
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	rtest.Equals(t, http.StatusMethodNotAllowed, rec.Code)
+}
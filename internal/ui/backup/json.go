@@ -2,9 +2,12 @@ package backup
 
 import (
 	"sort"
+	"sync"
 	"time"
 
 	"github.com/restic/restic/internal/archiver"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/fs"
 	"github.com/restic/restic/internal/restic"
 	"github.com/restic/restic/internal/ui"
 	"github.com/restic/restic/internal/ui/progress"
@@ -16,6 +19,10 @@ type jsonProgress struct {
 
 	term ui.Terminal
 	v    uint
+
+	// items that could not be saved, listed in the summary
+	skippedMu sync.Mutex
+	skipped   []skippedItem
 }
 
 // assert that Backup implements the ProgressPrinter interface
@@ -77,12 +84,26 @@ func (b *jsonProgress) ScannerError(item string, err error) error {
 
 // Error is the error callback function for the archiver, it prints the error and returns nil.
 func (b *jsonProgress) Error(item string, err error) error {
-	b.error(errorUpdate{
+	b.skippedMu.Lock()
+	b.skipped = append(b.skipped, skippedItem{Item: item, Error: errorObject{err.Error()}})
+	b.skippedMu.Unlock()
+
+	update := errorUpdate{
 		MessageType: "error",
 		Error:       errorObject{err.Error()},
 		During:      "archival",
 		Item:        item,
-	})
+	}
+	var vssErr *fs.VSSError
+	if errors.As(err, &vssErr) {
+		code, name := vssErr.Code()
+		update.VSS = &vssErrorDetails{
+			Volume:   vssErr.Volume,
+			Code:     code,
+			CodeName: name,
+		}
+	}
+	b.error(update)
 	return nil
 }
 
@@ -169,6 +190,8 @@ func (b *jsonProgress) Finish(snapshotID restic.ID, summary *archiver.Summary, d
 	if !snapshotID.IsNull() {
 		id = snapshotID.String()
 	}
+	b.skippedMu.Lock()
+	defer b.skippedMu.Unlock()
 	b.print(summaryOutput{
 		MessageType:         "summary",
 		FilesNew:            summary.Files.New,
@@ -188,6 +211,20 @@ func (b *jsonProgress) Finish(snapshotID restic.ID, summary *archiver.Summary, d
 		TotalDuration:       summary.BackupEnd.Sub(summary.BackupStart).Seconds(),
 		SnapshotID:          id,
 		DryRun:              dryRun,
+		SkippedItems:        b.skipped,
+	})
+}
+
+// ReportPrediction prints the predicted upload size of a dry run.
+func (b *jsonProgress) ReportPrediction(p UploadPrediction) {
+	b.print(predictionOutput{
+		MessageType:   "upload_prediction",
+		FilesUploaded: p.Files,
+		DataPacksSize: p.DataPacks,
+		TreePacksSize: p.TreePacks,
+		IndexSize:     p.Indexes,
+		SnapshotSize:  p.Other,
+		TotalSize:     p.Total(),
 	})
 }
 
@@ -213,10 +250,18 @@ type errorObject struct {
 }
 
 type errorUpdate struct {
-	MessageType string      `json:"message_type"` // "error"
-	Error       errorObject `json:"error"`
-	During      string      `json:"during"`
-	Item        string      `json:"item"`
+	MessageType string           `json:"message_type"` // "error"
+	Error       errorObject      `json:"error"`
+	During      string           `json:"during"`
+	Item        string           `json:"item"`
+	VSS         *vssErrorDetails `json:"vss,omitempty"`
+}
+
+// vssErrorDetails describes why no VSS snapshot could be created for a volume.
+type vssErrorDetails struct {
+	Volume   string `json:"volume"`
+	Code     uint32 `json:"code,omitempty"`
+	CodeName string `json:"code_name,omitempty"`
 }
 
 type verboseUpdate struct {
@@ -232,24 +277,40 @@ type verboseUpdate struct {
 }
 
 type summaryOutput struct {
-	MessageType         string    `json:"message_type"` // "summary"
-	FilesNew            uint      `json:"files_new"`
-	FilesChanged        uint      `json:"files_changed"`
-	FilesUnmodified     uint      `json:"files_unmodified"`
-	DirsNew             uint      `json:"dirs_new"`
-	DirsChanged         uint      `json:"dirs_changed"`
-	DirsUnmodified      uint      `json:"dirs_unmodified"`
-	DataBlobs           int       `json:"data_blobs"`
-	TreeBlobs           int       `json:"tree_blobs"`
-	DataAdded           uint64    `json:"data_added"`
-	DataAddedPacked     uint64    `json:"data_added_packed"`
-	TotalFilesProcessed uint      `json:"total_files_processed"`
-	TotalBytesProcessed uint64    `json:"total_bytes_processed"`
-	TotalDuration       float64   `json:"total_duration"` // in seconds
-	BackupStart         time.Time `json:"backup_start"`
-	BackupEnd           time.Time `json:"backup_end"`
-	SnapshotID          string    `json:"snapshot_id,omitempty"`
-	DryRun              bool      `json:"dry_run,omitempty"`
+	MessageType         string        `json:"message_type"` // "summary"
+	FilesNew            uint          `json:"files_new"`
+	FilesChanged        uint          `json:"files_changed"`
+	FilesUnmodified     uint          `json:"files_unmodified"`
+	DirsNew             uint          `json:"dirs_new"`
+	DirsChanged         uint          `json:"dirs_changed"`
+	DirsUnmodified      uint          `json:"dirs_unmodified"`
+	DataBlobs           int           `json:"data_blobs"`
+	TreeBlobs           int           `json:"tree_blobs"`
+	DataAdded           uint64        `json:"data_added"`
+	DataAddedPacked     uint64        `json:"data_added_packed"`
+	TotalFilesProcessed uint          `json:"total_files_processed"`
+	TotalBytesProcessed uint64        `json:"total_bytes_processed"`
+	TotalDuration       float64       `json:"total_duration"` // in seconds
+	BackupStart         time.Time     `json:"backup_start"`
+	BackupEnd           time.Time     `json:"backup_end"`
+	SnapshotID          string        `json:"snapshot_id,omitempty"`
+	DryRun              bool          `json:"dry_run,omitempty"`
+	SkippedItems        []skippedItem `json:"skipped_items,omitempty"`
+}
+
+type predictionOutput struct {
+	MessageType   string `json:"message_type"` // "upload_prediction"
+	FilesUploaded int    `json:"files_uploaded"`
+	DataPacksSize uint64 `json:"data_packs_size"`
+	TreePacksSize uint64 `json:"tree_packs_size"`
+	IndexSize     uint64 `json:"index_size"`
+	SnapshotSize  uint64 `json:"snapshot_size"`
+	TotalSize     uint64 `json:"total_size"`
+}
+
+type skippedItem struct {
+	Item  string      `json:"item"`
+	Error errorObject `json:"error"`
 }
 
 type VerboseExclude struct {
@@ -1,9 +1,13 @@
 package backup
 
 import (
+	"encoding/json"
 	"testing"
 
+	"github.com/restic/restic/internal/archiver"
 	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/fs"
+	"github.com/restic/restic/internal/restic"
 	"github.com/restic/restic/internal/test"
 	"github.com/restic/restic/internal/ui"
 )
@@ -20,8 +24,31 @@ func TestJSONError(t *testing.T) {
 	test.Equals(t, []string{"{\"message_type\":\"error\",\"error\":{\"message\":\"error \\\"message\\\"\"},\"during\":\"archival\",\"item\":\"/path\"}\n"}, term.Errors)
 }
 
+func TestJSONVSSError(t *testing.T) {
+	term, printer := createJSONProgress()
+	err := &fs.VSSError{Volume: `c:\`, Err: errors.New("timeout")}
+	test.Equals(t, printer.Error(`c:\`, err), nil)
+	test.Equals(t, []string{"{\"message_type\":\"error\",\"error\":{\"message\":\"failed to create snapshot for [c:\\\\]: timeout\"},\"during\":\"archival\",\"item\":\"c:\\\\\",\"vss\":{\"volume\":\"c:\\\\\"}}\n"}, term.Errors)
+}
+
 func TestJSONScannerError(t *testing.T) {
 	term, printer := createJSONProgress()
 	test.Equals(t, printer.ScannerError("/path", errors.New("error \"message\"")), nil)
 	test.Equals(t, []string{"{\"message_type\":\"error\",\"error\":{\"message\":\"error \\\"message\\\"\"},\"during\":\"scan\",\"item\":\"/path\"}\n"}, term.Errors)
 }
+
+func TestJSONSkippedItems(t *testing.T) {
+	term, printer := createJSONProgress()
+	test.Equals(t, printer.Error("/path", errors.New("error message")), nil)
+	printer.Finish(restic.ID{}, &archiver.Summary{}, false)
+
+	var summary summaryOutput
+	test.OK(t, json.Unmarshal([]byte(term.Output[len(term.Output)-1]), &summary))
+	test.Equals(t, []skippedItem{{Item: "/path", Error: errorObject{"error message"}}}, summary.SkippedItems)
+}
+
+func TestJSONPrediction(t *testing.T) {
+	term, printer := createJSONProgress()
+	printer.ReportPrediction(UploadPrediction{Files: 4, DataPacks: 1000, TreePacks: 100, Indexes: 10, Other: 1})
+	test.Equals(t, []string{"{\"message_type\":\"upload_prediction\",\"files_uploaded\":4,\"data_packs_size\":1000,\"tree_packs_size\":100,\"index_size\":10,\"snapshot_size\":1,\"total_size\":1111}\n"}, term.Output)
+}
@@ -18,6 +18,7 @@ type ProgressPrinter interface {
 	CompleteItem(messageType string, item string, s archiver.ItemStats, d time.Duration)
 	ReportTotal(start time.Time, s archiver.ScanStats)
 	Finish(snapshotID restic.ID, summary *archiver.Summary, dryRun bool)
+	ReportPrediction(p UploadPrediction)
 	Reset()
 	ExcludedItem(path string)
 
@@ -28,6 +29,21 @@ type Counter struct {
 	Files, Dirs, Bytes uint64
 }
 
+// UploadPrediction describes the files a backup would upload, as determined
+// by a dry run.
+type UploadPrediction struct {
+	Files     int
+	DataPacks uint64
+	TreePacks uint64
+	Indexes   uint64
+	Other     uint64
+}
+
+// Total returns the size of all files which would be uploaded.
+func (p UploadPrediction) Total() uint64 {
+	return p.DataPacks + p.TreePacks + p.Indexes + p.Other
+}
+
 // Progress reports progress for the `backup` command.
 type Progress struct {
 	progress.Updater
@@ -164,6 +180,11 @@ func (p *Progress) Finish(snapshotID restic.ID, summary *archiver.Summary, dryru
 	p.printer.Finish(snapshotID, summary, dryrun)
 }
 
+// ReportPrediction prints the predicted upload size, it must be called after Finish.
+func (p *Progress) ReportPrediction(prediction UploadPrediction) {
+	p.printer.ReportPrediction(prediction)
+}
+
 func (p *Progress) ExcludedItem(path string) {
 	p.printer.ExcludedItem(path)
 }
@@ -41,8 +41,9 @@ func (p *mockPrinter) Finish(id restic.ID, _ *archiver.Summary, _ bool) {
 	p.id = id
 }
 
-func (p *mockPrinter) Reset()                {}
-func (p *mockPrinter) ExcludedItem(_ string) {}
+func (p *mockPrinter) ReportPrediction(_ UploadPrediction) {}
+func (p *mockPrinter) Reset()                              {}
+func (p *mockPrinter) ExcludedItem(_ string)               {}
 
 func TestProgress(t *testing.T) {
 	t.Parallel()
@@ -0,0 +1,80 @@
+package backup
+
+import (
+	"time"
+
+	"github.com/restic/restic/internal/archiver"
+	"github.com/restic/restic/internal/restic"
+)
+
+// teeProgress forwards the progress to two printers, for example to the
+// terminal and to a progress socket.
+type teeProgress struct {
+	restic.Printer
+
+	primary, secondary ProgressPrinter
+	primaryStatus      bool
+}
+
+// assert that teeProgress implements the ProgressPrinter interface
+var _ ProgressPrinter = &teeProgress{}
+
+// NewTeeProgress returns a progress printer which reports the progress to
+// primary and secondary. Messages are printed using printer. Status updates
+// are only passed to primary if primaryStatus is true, which allows sending
+// status updates to secondary while they are disabled for the terminal.
+func NewTeeProgress(primary, secondary ProgressPrinter, printer restic.Printer, primaryStatus bool) ProgressPrinter {
+	return &teeProgress{
+		Printer:       printer,
+		primary:       primary,
+		secondary:     secondary,
+		primaryStatus: primaryStatus,
+	}
+}
+
+func (t *teeProgress) Update(total, processed Counter, errors uint, currentFiles map[string]struct{}, start time.Time, secs uint64) {
+	if t.primaryStatus {
+		t.primary.Update(total, processed, errors, currentFiles, start, secs)
+	}
+	t.secondary.Update(total, processed, errors, currentFiles, start, secs)
+}
+
+func (t *teeProgress) Error(item string, err error) error {
+	_ = t.secondary.Error(item, err)
+	return t.primary.Error(item, err)
+}
+
+func (t *teeProgress) ScannerError(item string, err error) error {
+	_ = t.secondary.ScannerError(item, err)
+	return t.primary.ScannerError(item, err)
+}
+
+func (t *teeProgress) CompleteItem(messageType string, item string, s archiver.ItemStats, d time.Duration) {
+	t.primary.CompleteItem(messageType, item, s, d)
+	t.secondary.CompleteItem(messageType, item, s, d)
+}
+
+func (t *teeProgress) ReportTotal(start time.Time, s archiver.ScanStats) {
+	t.primary.ReportTotal(start, s)
+	t.secondary.ReportTotal(start, s)
+}
+
+func (t *teeProgress) Finish(snapshotID restic.ID, summary *archiver.Summary, dryRun bool) {
+	t.primary.Finish(snapshotID, summary, dryRun)
+	t.secondary.Finish(snapshotID, summary, dryRun)
+}
+
+func (t *teeProgress) ReportPrediction(p UploadPrediction) {
+	t.primary.ReportPrediction(p)
+	t.secondary.ReportPrediction(p)
+}
+
+func (t *teeProgress) Reset() {
+	t.primary.Reset()
+	t.secondary.Reset()
+}
+
+func (t *teeProgress) ExcludedItem(path string) {
+	t.primary.ExcludedItem(path)
+	t.secondary.ExcludedItem(path)
+}
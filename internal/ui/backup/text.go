@@ -159,6 +159,16 @@ func (b *textProgress) Finish(id restic.ID, summary *archiver.Summary, dryRun bo
 	}
 }
 
+// ReportPrediction prints the predicted upload size of a dry run.
+func (b *textProgress) ReportPrediction(p UploadPrediction) {
+	b.P("\n")
+	b.P("Predicted upload: %v in %d files\n", ui.FormatBytes(p.Total()), p.Files)
+	b.P("  data packs:     %v\n", ui.FormatBytes(p.DataPacks))
+	b.P("  tree packs:     %v\n", ui.FormatBytes(p.TreePacks))
+	b.P("  index:          %v\n", ui.FormatBytes(p.Indexes))
+	b.P("  snapshot:       %v\n", ui.FormatBytes(p.Other))
+}
+
 func (b *textProgress) ExcludedItem(path string) {
 	b.VV("excluded %s", path)
 }
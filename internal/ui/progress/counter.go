@@ -56,3 +56,17 @@ func (c *Counter) Get() (v, max uint64) {
 func (c *Counter) Done() {
 	c.Updater.Done()
 }
+
+// minEstimateTime is the minimum runtime before an estimate of the remaining
+// time is computed, earlier estimates are too unreliable.
+const minEstimateTime = 3 * time.Second
+
+// EstimateRemaining returns the estimated time until value reaches total,
+// assuming that the average rate since the start stays constant. Zero is
+// returned if no estimate is possible.
+func EstimateRemaining(value, total uint64, runtime time.Duration) time.Duration {
+	if total == 0 || value == 0 || value >= total || runtime < minEstimateTime {
+		return 0
+	}
+	return time.Duration(float64(runtime) * float64(total-value) / float64(value))
+}
@@ -58,3 +58,21 @@ func TestCounter(t *testing.T) {
 
 	t.Log("number of calls:", ncalls)
 }
+
+func TestEstimateRemaining(t *testing.T) {
+	for _, tc := range []struct {
+		value, total uint64
+		runtime      time.Duration
+		expected     time.Duration
+	}{
+		{25, 100, 10 * time.Second, 30 * time.Second},
+		{50, 100, time.Minute, time.Minute},
+		// no estimate without progress, total or enough runtime
+		{0, 100, 10 * time.Second, 0},
+		{25, 0, 10 * time.Second, 0},
+		{100, 100, 10 * time.Second, 0},
+		{25, 100, time.Second, 0},
+	} {
+		test.Equals(t, tc.expected, progress.EstimateRemaining(tc.value, tc.total, tc.runtime))
+	}
+}
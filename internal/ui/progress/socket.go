@@ -0,0 +1,211 @@
+package progress
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/ui"
+)
+
+// EventSocket sends progress events as JSON lines to a Unix socket, which is
+// provided by a frontend displaying the progress.
+type EventSocket struct {
+	mu     sync.Mutex
+	conn   net.Conn
+	failed bool
+}
+
+// DialEventSocket connects to the Unix socket at path.
+func DialEventSocket(path string) (*EventSocket, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, errors.Wrap(err, "connect to progress socket")
+	}
+	return &EventSocket{conn: conn}, nil
+}
+
+// SendLine writes a single line to the socket. If writing to the socket fails,
+// for example because the frontend has exited, all further lines are dropped
+// such that the running operation is not interrupted.
+func (s *EventSocket) SendLine(line string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failed {
+		return
+	}
+
+	if !strings.HasSuffix(line, "\n") {
+		line += "\n"
+	}
+	if _, err := io.WriteString(s.conn, line); err != nil {
+		debug.Log("writing to progress socket failed, dropping further events: %v", err)
+		s.failed = true
+	}
+}
+
+// Send writes event encoded as JSON to the socket.
+func (s *EventSocket) Send(event interface{}) {
+	s.SendLine(ui.ToJSONString(event))
+}
+
+// Close closes the connection to the socket.
+func (s *EventSocket) Close() error {
+	return s.conn.Close()
+}
+
+// Terminal returns a terminal which sends all printed lines to the socket.
+// It is used to reuse the JSON progress printers of the individual commands.
+func (s *EventSocket) Terminal() ui.Terminal {
+	return &socketTerminal{s: s}
+}
+
+type socketTerminal struct {
+	s *EventSocket
+}
+
+var _ ui.Terminal = &socketTerminal{}
+
+func (t *socketTerminal) Print(line string)       { t.s.SendLine(line) }
+func (t *socketTerminal) Error(line string)       { t.s.SendLine(line) }
+func (t *socketTerminal) SetStatus(_ []string)    {}
+func (t *socketTerminal) CanUpdateStatus() bool   { return false }
+func (t *socketTerminal) InputRaw() io.ReadCloser { return nil }
+func (t *socketTerminal) InputIsTerminal() bool   { return false }
+func (t *socketTerminal) OutputWriter() io.Writer { return io.Discard }
+func (t *socketTerminal) OutputRaw() io.Writer    { return io.Discard }
+func (t *socketTerminal) OutputIsTerminal() bool  { return false }
+func (t *socketTerminal) ReadPassword(_ context.Context, _ string) (string, error) {
+	return "", errors.New("cannot read a password from the progress socket")
+}
+
+// messageEvent is sent for each message printed by a command.
+type messageEvent struct {
+	MessageType string `json:"message_type"` // "message"
+	Level       string `json:"level"`
+	Message     string `json:"message"`
+}
+
+// progressEvent reports the progress of a phase of a command, for example
+// the number of processed pack files during prune.
+type progressEvent struct {
+	MessageType      string  `json:"message_type"` // "progress"
+	Phase            string  `json:"phase"`
+	SecondsElapsed   uint64  `json:"seconds_elapsed"`
+	Done             uint64  `json:"done"`
+	Total            uint64  `json:"total,omitempty"`
+	PercentDone      float64 `json:"percent_done,omitempty"`
+	SecondsRemaining uint64  `json:"seconds_remaining,omitempty"`
+	Finished         bool    `json:"finished"`
+}
+
+// eventPrinter wraps a restic.Printer and additionally sends all messages and
+// progress counters to an EventSocket.
+type eventPrinter struct {
+	restic.Printer
+	s *EventSocket
+}
+
+// Printer returns a printer which forwards all calls to printer and sends the
+// messages and the progress of all counters to s. Messages are sent
+// independent of the verbosity of printer, except for the debug messages
+// printed by VV.
+func (s *EventSocket) Printer(printer restic.Printer) restic.Printer {
+	return &eventPrinter{Printer: printer, s: s}
+}
+
+func (p *eventPrinter) message(level string, msg string, args ...interface{}) {
+	p.s.Send(messageEvent{
+		MessageType: "message",
+		Level:       level,
+		Message:     strings.TrimSuffix(fmt.Sprintf(msg, args...), "\n"),
+	})
+}
+
+func (p *eventPrinter) counter(phase string) *Counter {
+	interval := CalculateProgressInterval(true, true, false)
+	return NewCounter(interval, 0, func(v uint64, max uint64, d time.Duration, final bool) {
+		event := progressEvent{
+			MessageType:    "progress",
+			Phase:          phase,
+			SecondsElapsed: uint64(d / time.Second),
+			Done:           v,
+			Total:          max,
+			Finished:       final,
+		}
+		if max > 0 {
+			event.PercentDone = float64(v) / float64(max)
+		}
+		if !final {
+			event.SecondsRemaining = uint64(EstimateRemaining(v, max, d) / time.Second)
+		}
+		p.s.Send(event)
+	})
+}
+
+func (p *eventPrinter) NewCounter(description string) restic.Counter {
+	return &teeCounter{p.Printer.NewCounter(description), p.counter(description)}
+}
+
+func (p *eventPrinter) NewCounterTerminalOnly(description string) restic.Counter {
+	return &teeCounter{p.Printer.NewCounterTerminalOnly(description), p.counter(description)}
+}
+
+func (p *eventPrinter) E(msg string, args ...interface{}) {
+	p.Printer.E(msg, args...)
+	p.message("error", msg, args...)
+}
+
+func (p *eventPrinter) S(msg string, args ...interface{}) {
+	p.Printer.S(msg, args...)
+	p.message("info", msg, args...)
+}
+
+func (p *eventPrinter) PT(msg string, args ...interface{}) {
+	p.Printer.PT(msg, args...)
+	p.message("info", msg, args...)
+}
+
+func (p *eventPrinter) P(msg string, args ...interface{}) {
+	p.Printer.P(msg, args...)
+	p.message("info", msg, args...)
+}
+
+func (p *eventPrinter) V(msg string, args ...interface{}) {
+	p.Printer.V(msg, args...)
+	p.message("verbose", msg, args...)
+}
+
+// teeCounter forwards all updates to two counters.
+type teeCounter struct {
+	restic.Counter
+	events *Counter
+}
+
+func (c *teeCounter) Add(v uint64) {
+	c.Counter.Add(v)
+	c.events.Add(v)
+}
+
+func (c *teeCounter) SetMax(max uint64) {
+	c.Counter.SetMax(max)
+	c.events.SetMax(max)
+}
+
+func (c *teeCounter) Done() {
+	c.Counter.Done()
+	c.events.Done()
+}
+
+// Get returns the values of the event counter, as the other counter may
+// discard all updates.
+func (c *teeCounter) Get() (uint64, uint64) {
+	return c.events.Get()
+}
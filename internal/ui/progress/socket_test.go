@@ -0,0 +1,76 @@
+package progress_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+	"github.com/restic/restic/internal/ui"
+	"github.com/restic/restic/internal/ui/progress"
+)
+
+func TestEventSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "progress.sock")
+	l, err := net.Listen("unix", path)
+	rtest.OK(t, err)
+	defer func() {
+		_ = l.Close()
+	}()
+
+	events := make(chan []map[string]interface{})
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			close(events)
+			return
+		}
+		var result []map[string]interface{}
+		sc := bufio.NewScanner(conn)
+		for sc.Scan() {
+			var event map[string]interface{}
+			if err := json.Unmarshal(sc.Bytes(), &event); err != nil {
+				t.Errorf("invalid event %q: %v", sc.Text(), err)
+			}
+			result = append(result, event)
+		}
+		events <- result
+	}()
+
+	socket, err := progress.DialEventSocket(path)
+	rtest.OK(t, err)
+
+	term := &ui.MockTerminal{}
+	printer := socket.Printer(progress.NewTerminalPrinter(false, 1, term))
+	printer.P("loading indexes...")
+	printer.V("verbose message")
+	printer.E("some error")
+	counter := printer.NewCounter("packs processed")
+	counter.SetMax(2)
+	counter.Add(2)
+	counter.Done()
+	v, maxV := counter.Get()
+	rtest.Equals(t, uint64(2), v)
+	rtest.Equals(t, uint64(2), maxV)
+	rtest.OK(t, socket.Close())
+
+	// the wrapped printer must still print the messages
+	rtest.Equals(t, []string{"some error"}, term.Errors)
+
+	result := <-events
+	rtest.Assert(t, len(result) >= 4, "expected at least four events, got %v", result)
+	rtest.Equals(t, "message", result[0]["message_type"])
+	rtest.Equals(t, "info", result[0]["level"])
+	rtest.Equals(t, "loading indexes...", result[0]["message"])
+	rtest.Equals(t, "verbose", result[1]["level"])
+	rtest.Equals(t, "error", result[2]["level"])
+
+	last := result[len(result)-1]
+	rtest.Equals(t, "progress", last["message_type"])
+	rtest.Equals(t, "packs processed", last["phase"])
+	rtest.Equals(t, 2.0, last["done"])
+	rtest.Equals(t, 2.0, last["total"])
+	rtest.Equals(t, true, last["finished"])
+}
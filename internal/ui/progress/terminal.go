@@ -2,6 +2,7 @@ package progress
 
 import (
 	"fmt"
+	"log/slog"
 	"os"
 	"strconv"
 	"time"
@@ -42,6 +43,9 @@ func newProgressMax(show bool, max uint64, description string, term ui.Terminal)
 		} else {
 			status = fmt.Sprintf("[%s] %s  %d / %d %s",
 				ui.FormatDuration(d), ui.FormatPercent(v, max), v, max, description)
+			if eta := EstimateRemaining(v, max, d); eta > 0 && !final {
+				status += fmt.Sprintf("  ETA %s", ui.FormatDuration(eta))
+			}
 		}
 
 		if final {
@@ -88,14 +92,24 @@ func (t *terminalPrinter) P(msg string, args ...interface{}) {
 
 func (t *terminalPrinter) V(msg string, args ...interface{}) {
 	if t.v >= 2 {
-		t.term.Print(fmt.Sprintf(msg, args...))
+		t.debug(fmt.Sprintf(msg, args...))
 	}
 }
 
 func (t *terminalPrinter) VV(msg string, args ...interface{}) {
 	if t.v >= 3 {
-		t.term.Print(fmt.Sprintf(msg, args...))
+		t.debug(fmt.Sprintf(msg, args...))
+	}
+}
+
+// debug prints verbose messages, which are logged at the debug level if the
+// terminal prints log records.
+func (t *terminalPrinter) debug(line string) {
+	if lt, ok := t.term.(ui.LevelTerminal); ok {
+		lt.Log(slog.LevelDebug, line)
+		return
 	}
+	t.term.Print(line)
 }
 
 func NewTerminalPrinter(json bool, verbosity uint, term ui.Terminal) restic.Printer {
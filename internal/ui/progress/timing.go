@@ -0,0 +1,129 @@
+package progress
+
+import (
+	"sync"
+	"time"
+
+	"github.com/restic/restic/internal/restic"
+)
+
+// PhaseTime is the time spent in a phase of a command.
+type PhaseTime struct {
+	Phase   string  `json:"phase"`
+	Seconds float64 `json:"seconds"`
+}
+
+// Timing is the duration of a command, broken down by phase.
+type Timing struct {
+	MessageType  string      `json:"message_type"` // "timing"
+	TotalSeconds float64     `json:"total_seconds"`
+	Phases       []PhaseTime `json:"phases"`
+}
+
+// PhaseTimer records the duration of the phases of a command. Each progress
+// counter of a printer returned by Printer is a phase, which is named after
+// the description of the counter and lasts until the counter is done.
+type PhaseTimer struct {
+	start time.Time
+
+	m      sync.Mutex
+	phases []PhaseTime
+	// running holds the start time of all counters which are not done yet
+	running map[*timedCounter]time.Time
+}
+
+// NewPhaseTimer returns a PhaseTimer which measures the total time from now on.
+func NewPhaseTimer() *PhaseTimer {
+	return &PhaseTimer{
+		start:   time.Now(),
+		running: make(map[*timedCounter]time.Time),
+	}
+}
+
+// Printer returns a printer which forwards all calls to printer and records
+// the runtime of all counters.
+func (t *PhaseTimer) Printer(printer restic.Printer) restic.Printer {
+	return &timedPrinter{Printer: printer, t: t}
+}
+
+func (t *PhaseTimer) add(phase string, d time.Duration) {
+	// counters with the same description are accumulated into one phase
+	for i := range t.phases {
+		if t.phases[i].Phase == phase {
+			t.phases[i].Seconds += d.Seconds()
+			return
+		}
+	}
+	t.phases = append(t.phases, PhaseTime{Phase: phase, Seconds: d.Seconds()})
+}
+
+// Timing returns the total runtime and the duration of all phases in the
+// order in which they were started. Phases which are still running are
+// included with their runtime so far.
+func (t *PhaseTimer) Timing() Timing {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	phases := append([]PhaseTime{}, t.phases...)
+	for c, start := range t.running {
+		found := false
+		for i := range phases {
+			if phases[i].Phase == c.phase {
+				phases[i].Seconds += time.Since(start).Seconds()
+				found = true
+				break
+			}
+		}
+		if !found {
+			phases = append(phases, PhaseTime{Phase: c.phase, Seconds: time.Since(start).Seconds()})
+		}
+	}
+
+	return Timing{
+		MessageType:  "timing",
+		TotalSeconds: time.Since(t.start).Seconds(),
+		Phases:       phases,
+	}
+}
+
+type timedPrinter struct {
+	restic.Printer
+	t *PhaseTimer
+}
+
+func (p *timedPrinter) counter(phase string, c restic.Counter) restic.Counter {
+	tc := &timedCounter{Counter: c, phase: phase, t: p.t}
+	p.t.m.Lock()
+	p.t.running[tc] = time.Now()
+	p.t.m.Unlock()
+	return tc
+}
+
+func (p *timedPrinter) NewCounter(description string) restic.Counter {
+	return p.counter(description, p.Printer.NewCounter(description))
+}
+
+func (p *timedPrinter) NewCounterTerminalOnly(description string) restic.Counter {
+	return p.counter(description, p.Printer.NewCounterTerminalOnly(description))
+}
+
+// timedCounter records the runtime of a counter once it is done.
+type timedCounter struct {
+	restic.Counter
+	phase string
+	t     *PhaseTimer
+}
+
+func (c *timedCounter) Done() {
+	c.Counter.Done()
+
+	c.t.m.Lock()
+	defer c.t.m.Unlock()
+	start, ok := c.t.running[c]
+	if !ok {
+		// Done was already called
+		return
+	}
+	delete(c.t.running, c)
+	c.t.add(c.phase, time.Since(start))
+}
@@ -0,0 +1,39 @@
+package progress_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/test"
+	"github.com/restic/restic/internal/ui/progress"
+)
+
+func TestPhaseTimer(t *testing.T) {
+	timer := progress.NewPhaseTimer()
+	printer := timer.Printer(restic.NewNoopPrinter())
+
+	c := printer.NewCounter("packs repacked")
+	time.Sleep(10 * time.Millisecond)
+	c.Done()
+	// calling Done twice must not count the phase twice
+	c.Done()
+
+	c = printer.NewCounterTerminalOnly("files deleted")
+	c.Done()
+	c = printer.NewCounter("packs repacked")
+	time.Sleep(10 * time.Millisecond)
+	c.Done()
+
+	running := printer.NewCounter("index files loaded")
+	defer running.Done()
+
+	timing := timer.Timing()
+	test.Equals(t, "timing", timing.MessageType)
+	test.Equals(t, 3, len(timing.Phases))
+	test.Equals(t, "packs repacked", timing.Phases[0].Phase)
+	test.Equals(t, "files deleted", timing.Phases[1].Phase)
+	test.Equals(t, "index files loaded", timing.Phases[2].Phase)
+	test.Assert(t, timing.Phases[0].Seconds >= 0.02, "expected both repack phases to be added, got %v", timing.Phases[0].Seconds)
+	test.Assert(t, timing.Phases[0].Seconds <= timing.TotalSeconds, "phase took longer than total")
+}
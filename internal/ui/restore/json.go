@@ -94,6 +94,15 @@ func (t *jsonPrinter) CompleteItem(messageType restorer.ItemAction, item string,
 	t.print(status)
 }
 
+func (t *jsonPrinter) MetadataNotRestored(item string, metadata []string) {
+	t.error(metadataNotRestoredUpdate{
+		MessageType: "metadata_not_restored",
+		Item:        item,
+		Metadata:    metadata,
+		Reason:      "missing privileges",
+	})
+}
+
 func (t *jsonPrinter) Finish(p State, duration time.Duration) {
 	status := summaryOutput{
 		MessageType:    "summary",
@@ -105,6 +114,7 @@ func (t *jsonPrinter) Finish(p State, duration time.Duration) {
 		TotalBytes:     p.AllBytesTotal,
 		BytesRestored:  p.AllBytesWritten,
 		BytesSkipped:   p.AllBytesSkipped,
+		BytesReused:    p.AllBytesReused,
 	}
 	t.print(status)
 }
@@ -133,6 +143,13 @@ type errorUpdate struct {
 	Item        string      `json:"item"`
 }
 
+type metadataNotRestoredUpdate struct {
+	MessageType string   `json:"message_type"` // "metadata_not_restored"
+	Item        string   `json:"item"`
+	Metadata    []string `json:"metadata"`
+	Reason      string   `json:"reason"`
+}
+
 type verboseUpdate struct {
 	MessageType string `json:"message_type"` // "verbose_status"
 	Action      string `json:"action"`
@@ -150,4 +167,5 @@ type summaryOutput struct {
 	TotalBytes     uint64 `json:"total_bytes,omitempty"`
 	BytesRestored  uint64 `json:"bytes_restored,omitempty"`
 	BytesSkipped   uint64 `json:"bytes_skipped,omitempty"`
+	BytesReused    uint64 `json:"bytes_reused,omitempty"`
 }
@@ -18,34 +18,40 @@ func createJSONProgress() (*ui.MockTerminal, ProgressPrinter) {
 
 func TestJSONPrintUpdate(t *testing.T) {
 	term, printer := createJSONProgress()
-	printer.Update(State{3, 11, 0, 0, 29, 47, 0}, 5*time.Second)
+	printer.Update(State{3, 11, 0, 0, 29, 47, 0, 0}, 5*time.Second)
 	test.Equals(t, []string{"{\"message_type\":\"status\",\"seconds_elapsed\":5,\"percent_done\":0.6170212765957447,\"total_files\":11,\"files_restored\":3,\"total_bytes\":47,\"bytes_restored\":29}\n"}, term.Output)
 }
 
 func TestJSONPrintUpdateWithSkipped(t *testing.T) {
 	term, printer := createJSONProgress()
-	printer.Update(State{3, 11, 2, 0, 29, 47, 59}, 5*time.Second)
+	printer.Update(State{3, 11, 2, 0, 29, 47, 59, 0}, 5*time.Second)
 	test.Equals(t, []string{"{\"message_type\":\"status\",\"seconds_elapsed\":5,\"percent_done\":0.6170212765957447,\"total_files\":11,\"files_restored\":3,\"files_skipped\":2,\"total_bytes\":47,\"bytes_restored\":29,\"bytes_skipped\":59}\n"}, term.Output)
 }
 
 func TestJSONPrintSummaryOnSuccess(t *testing.T) {
 	term, printer := createJSONProgress()
-	printer.Finish(State{11, 11, 0, 0, 47, 47, 0}, 5*time.Second)
+	printer.Finish(State{11, 11, 0, 0, 47, 47, 0, 0}, 5*time.Second)
 	test.Equals(t, []string{"{\"message_type\":\"summary\",\"seconds_elapsed\":5,\"total_files\":11,\"files_restored\":11,\"total_bytes\":47,\"bytes_restored\":47}\n"}, term.Output)
 }
 
 func TestJSONPrintSummaryOnErrors(t *testing.T) {
 	term, printer := createJSONProgress()
-	printer.Finish(State{3, 11, 0, 0, 29, 47, 0}, 5*time.Second)
+	printer.Finish(State{3, 11, 0, 0, 29, 47, 0, 0}, 5*time.Second)
 	test.Equals(t, []string{"{\"message_type\":\"summary\",\"seconds_elapsed\":5,\"total_files\":11,\"files_restored\":3,\"total_bytes\":47,\"bytes_restored\":29}\n"}, term.Output)
 }
 
 func TestJSONPrintSummaryOnSuccessWithSkipped(t *testing.T) {
 	term, printer := createJSONProgress()
-	printer.Finish(State{11, 11, 2, 0, 47, 47, 59}, 5*time.Second)
+	printer.Finish(State{11, 11, 2, 0, 47, 47, 59, 0}, 5*time.Second)
 	test.Equals(t, []string{"{\"message_type\":\"summary\",\"seconds_elapsed\":5,\"total_files\":11,\"files_restored\":11,\"files_skipped\":2,\"total_bytes\":47,\"bytes_restored\":47,\"bytes_skipped\":59}\n"}, term.Output)
 }
 
+func TestJSONPrintSummaryOnSuccessWithReused(t *testing.T) {
+	term, printer := createJSONProgress()
+	printer.Finish(State{11, 11, 0, 0, 47, 47, 0, 20}, 5*time.Second)
+	test.Equals(t, []string{"{\"message_type\":\"summary\",\"seconds_elapsed\":5,\"total_files\":11,\"files_restored\":11,\"total_bytes\":47,\"bytes_restored\":47,\"bytes_reused\":20}\n"}, term.Output)
+}
+
 func TestJSONPrintCompleteItem(t *testing.T) {
 	for _, data := range []struct {
 		action   restorer.ItemAction
@@ -69,3 +75,9 @@ func TestJSONError(t *testing.T) {
 	test.Equals(t, printer.Error("/path", errors.New("error \"message\"")), nil)
 	test.Equals(t, []string{"{\"message_type\":\"error\",\"error\":{\"message\":\"error \\\"message\\\"\"},\"during\":\"restore\",\"item\":\"/path\"}\n"}, term.Errors)
 }
+
+func TestJSONMetadataNotRestored(t *testing.T) {
+	term, printer := createJSONProgress()
+	printer.MetadataNotRestored("/path", []string{"owner", "sacl"})
+	test.Equals(t, []string{"{\"message_type\":\"metadata_not_restored\",\"item\":\"/path\",\"metadata\":[\"owner\",\"sacl\"],\"reason\":\"missing privileges\"}\n"}, term.Errors)
+}
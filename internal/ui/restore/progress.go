@@ -17,6 +17,7 @@ type State struct {
 	AllBytesWritten uint64
 	AllBytesTotal   uint64
 	AllBytesSkipped uint64
+	AllBytesReused  uint64
 }
 
 type Progress struct {
@@ -41,6 +42,7 @@ type ProgressPrinter interface {
 	Update(progress State, duration time.Duration)
 	Error(item string, err error) error
 	CompleteItem(action restorer.ItemAction, item string, size uint64)
+	MetadataNotRestored(item string, metadata []string)
 	Finish(progress State, duration time.Duration)
 	restic.Printer
 }
@@ -124,6 +126,19 @@ func (p *Progress) AddSkippedFile(name string, size uint64) {
 	p.printer.CompleteItem(restorer.ActionFileUnchanged, name, size)
 }
 
+// AddReusedBytes accumulates the number of bytes that were already present in
+// the target and did not have to be downloaded
+func (p *Progress) AddReusedBytes(size uint64) {
+	if p == nil {
+		return
+	}
+
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	p.s.AllBytesReused += size
+}
+
 func (p *Progress) ReportDeletion(name string) {
 	if p == nil {
 		return
@@ -137,6 +152,19 @@ func (p *Progress) ReportDeletion(name string) {
 	p.printer.CompleteItem(restorer.ActionDeleted, name, 0)
 }
 
+// ReportMetadataNotRestored reports metadata of an item which could not be
+// restored due to missing privileges
+func (p *Progress) ReportMetadataNotRestored(name string, metadata []string) {
+	if p == nil {
+		return
+	}
+
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	p.printer.MetadataNotRestored(name, metadata)
+}
+
 func (p *Progress) Error(item string, err error) error {
 	if p == nil {
 		return nil
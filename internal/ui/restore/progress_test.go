@@ -53,6 +53,7 @@ func (p *mockPrinter) Error(item string, err error) error {
 func (p *mockPrinter) CompleteItem(action restorer.ItemAction, item string, size uint64) {
 	p.items = append(p.items, itemTraceEntry{action, item, size})
 }
+func (p *mockPrinter) MetadataNotRestored(_ string, _ []string) {}
 func (p *mockPrinter) Finish(progress State, _ time.Duration) {
 	p.trace = append(p.trace, printerTraceEntry{progress, mockFinishDuration, true})
 }
@@ -75,7 +76,7 @@ func TestNew(t *testing.T) {
 		return false
 	})
 	test.Equals(t, printerTrace{
-		printerTraceEntry{State{0, 0, 0, 0, 0, 0, 0}, 0, false},
+		printerTraceEntry{State{0, 0, 0, 0, 0, 0, 0, 0}, 0, false},
 	}, result)
 	test.Equals(t, itemTrace{}, items)
 }
@@ -88,7 +89,7 @@ func TestAddFile(t *testing.T) {
 		return false
 	})
 	test.Equals(t, printerTrace{
-		printerTraceEntry{State{0, 1, 0, 0, 0, fileSize, 0}, 0, false},
+		printerTraceEntry{State{0, 1, 0, 0, 0, fileSize, 0, 0}, 0, false},
 	}, result)
 	test.Equals(t, itemTrace{}, items)
 }
@@ -103,7 +104,7 @@ func TestFirstProgressOnAFile(t *testing.T) {
 		return false
 	})
 	test.Equals(t, printerTrace{
-		printerTraceEntry{State{0, 1, 0, 0, expectedBytesWritten, expectedBytesTotal, 0}, 0, false},
+		printerTraceEntry{State{0, 1, 0, 0, expectedBytesWritten, expectedBytesTotal, 0, 0}, 0, false},
 	}, result)
 	test.Equals(t, itemTrace{}, items)
 }
@@ -119,7 +120,7 @@ func TestLastProgressOnAFile(t *testing.T) {
 		return false
 	})
 	test.Equals(t, printerTrace{
-		printerTraceEntry{State{1, 1, 0, 0, fileSize, fileSize, 0}, 0, false},
+		printerTraceEntry{State{1, 1, 0, 0, fileSize, fileSize, 0, 0}, 0, false},
 	}, result)
 	test.Equals(t, itemTrace{
 		itemTraceEntry{action: restorer.ActionFileUpdated, item: "test", size: fileSize},
@@ -138,7 +139,7 @@ func TestLastProgressOnLastFile(t *testing.T) {
 		return false
 	})
 	test.Equals(t, printerTrace{
-		printerTraceEntry{State{2, 2, 0, 0, 50 + fileSize, 50 + fileSize, 0}, 0, false},
+		printerTraceEntry{State{2, 2, 0, 0, 50 + fileSize, 50 + fileSize, 0, 0}, 0, false},
 	}, result)
 	test.Equals(t, itemTrace{
 		itemTraceEntry{action: restorer.ActionFileUpdated, item: "test1", size: 50},
@@ -157,7 +158,7 @@ func TestSummaryOnSuccess(t *testing.T) {
 		return true
 	})
 	test.Equals(t, printerTrace{
-		printerTraceEntry{State{2, 2, 0, 0, 50 + fileSize, 50 + fileSize, 0}, mockFinishDuration, true},
+		printerTraceEntry{State{2, 2, 0, 0, 50 + fileSize, 50 + fileSize, 0, 0}, mockFinishDuration, true},
 	}, result)
 }
 
@@ -172,7 +173,7 @@ func TestSummaryOnErrors(t *testing.T) {
 		return true
 	})
 	test.Equals(t, printerTrace{
-		printerTraceEntry{State{1, 2, 0, 0, 50 + fileSize/2, 50 + fileSize, 0}, mockFinishDuration, true},
+		printerTraceEntry{State{1, 2, 0, 0, 50 + fileSize/2, 50 + fileSize, 0, 0}, mockFinishDuration, true},
 	}, result)
 }
 
@@ -184,13 +185,28 @@ func TestSkipFile(t *testing.T) {
 		return true
 	})
 	test.Equals(t, printerTrace{
-		printerTraceEntry{State{0, 0, 1, 0, 0, 0, fileSize}, mockFinishDuration, true},
+		printerTraceEntry{State{0, 0, 1, 0, 0, 0, fileSize, 0}, mockFinishDuration, true},
 	}, result)
 	test.Equals(t, itemTrace{
 		itemTraceEntry{restorer.ActionFileUnchanged, "test", fileSize},
 	}, items)
 }
 
+func TestReusedBytes(t *testing.T) {
+	fileSize := uint64(100)
+
+	result, _, _ := testProgress(func(progress *Progress) bool {
+		progress.AddFile(fileSize)
+		progress.AddProgress("test", restorer.ActionFileUpdated, fileSize/2, fileSize)
+		progress.AddReusedBytes(fileSize / 2)
+		progress.AddProgress("test", restorer.ActionFileUpdated, fileSize/2, fileSize)
+		return true
+	})
+	test.Equals(t, printerTrace{
+		printerTraceEntry{State{1, 1, 0, 0, fileSize, fileSize, 0, fileSize / 2}, mockFinishDuration, true},
+	}, result)
+}
+
 func TestProgressTypes(t *testing.T) {
 	fileSize := uint64(100)
 
@@ -0,0 +1,60 @@
+package restore
+
+import (
+	"time"
+
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/restorer"
+)
+
+// teeProgress forwards the progress to two printers, for example to the
+// terminal and to a progress socket.
+type teeProgress struct {
+	restic.Printer
+
+	primary, secondary ProgressPrinter
+	primaryStatus      bool
+}
+
+// assert that teeProgress implements the ProgressPrinter interface
+var _ ProgressPrinter = &teeProgress{}
+
+// NewTeeProgress returns a progress printer which reports the progress to
+// primary and secondary. Messages are printed using printer. Status updates
+// are only passed to primary if primaryStatus is true, which allows sending
+// status updates to secondary while they are disabled for the terminal.
+func NewTeeProgress(primary, secondary ProgressPrinter, printer restic.Printer, primaryStatus bool) ProgressPrinter {
+	return &teeProgress{
+		Printer:       printer,
+		primary:       primary,
+		secondary:     secondary,
+		primaryStatus: primaryStatus,
+	}
+}
+
+func (t *teeProgress) Update(progress State, duration time.Duration) {
+	if t.primaryStatus {
+		t.primary.Update(progress, duration)
+	}
+	t.secondary.Update(progress, duration)
+}
+
+func (t *teeProgress) Error(item string, err error) error {
+	_ = t.secondary.Error(item, err)
+	return t.primary.Error(item, err)
+}
+
+func (t *teeProgress) CompleteItem(action restorer.ItemAction, item string, size uint64) {
+	t.primary.CompleteItem(action, item, size)
+	t.secondary.CompleteItem(action, item, size)
+}
+
+func (t *teeProgress) MetadataNotRestored(item string, metadata []string) {
+	t.primary.MetadataNotRestored(item, metadata)
+	t.secondary.MetadataNotRestored(item, metadata)
+}
+
+func (t *teeProgress) Finish(progress State, duration time.Duration) {
+	t.primary.Finish(progress, duration)
+	t.secondary.Finish(progress, duration)
+}
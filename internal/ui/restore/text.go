@@ -2,6 +2,7 @@ package restore
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/restic/restic/internal/restic"
@@ -71,6 +72,10 @@ func (t *textPrinter) CompleteItem(messageType restorer.ItemAction, item string,
 	}
 }
 
+func (t *textPrinter) MetadataNotRestored(item string, metadata []string) {
+	t.E("Warning: could not restore %s of %s due to missing privileges\n", strings.Join(metadata, ", "), item)
+}
+
 func (t *textPrinter) Finish(p State, duration time.Duration) {
 	t.terminal.SetStatus(nil)
 
@@ -88,6 +93,9 @@ func (t *textPrinter) Finish(p State, duration time.Duration) {
 	if p.FilesSkipped > 0 {
 		summary += fmt.Sprintf(", skipped %v files/dirs %v", p.FilesSkipped, ui.FormatBytes(p.AllBytesSkipped))
 	}
+	if p.AllBytesReused > 0 {
+		summary += fmt.Sprintf(", reused %v from existing files", ui.FormatBytes(p.AllBytesReused))
+	}
 	if p.FilesDeleted > 0 {
 		summary += fmt.Sprintf(", deleted %v files/dirs", p.FilesDeleted)
 	}
@@ -18,34 +18,40 @@ func createTextProgress() (*ui.MockTerminal, ProgressPrinter) {
 
 func TestPrintUpdate(t *testing.T) {
 	term, printer := createTextProgress()
-	printer.Update(State{3, 11, 0, 0, 29, 47, 0}, 5*time.Second)
+	printer.Update(State{3, 11, 0, 0, 29, 47, 0, 0}, 5*time.Second)
 	test.Equals(t, []string{"[0:05] 61.70%  3 files/dirs 29 B, total 11 files/dirs 47 B"}, term.Output)
 }
 
 func TestPrintUpdateWithSkipped(t *testing.T) {
 	term, printer := createTextProgress()
-	printer.Update(State{3, 11, 2, 0, 29, 47, 59}, 5*time.Second)
+	printer.Update(State{3, 11, 2, 0, 29, 47, 59, 0}, 5*time.Second)
 	test.Equals(t, []string{"[0:05] 61.70%  3 files/dirs 29 B, total 11 files/dirs 47 B, skipped 2 files/dirs 59 B"}, term.Output)
 }
 
 func TestPrintSummaryOnSuccess(t *testing.T) {
 	term, printer := createTextProgress()
-	printer.Finish(State{11, 11, 0, 0, 47, 47, 0}, 5*time.Second)
+	printer.Finish(State{11, 11, 0, 0, 47, 47, 0, 0}, 5*time.Second)
 	test.Equals(t, []string{"Summary: Restored 11 files/dirs (47 B) in 0:05"}, term.Output)
 }
 
 func TestPrintSummaryOnErrors(t *testing.T) {
 	term, printer := createTextProgress()
-	printer.Finish(State{3, 11, 0, 0, 29, 47, 0}, 5*time.Second)
+	printer.Finish(State{3, 11, 0, 0, 29, 47, 0, 0}, 5*time.Second)
 	test.Equals(t, []string{"Summary: Restored 3 / 11 files/dirs (29 B / 47 B) in 0:05"}, term.Output)
 }
 
 func TestPrintSummaryOnSuccessWithSkipped(t *testing.T) {
 	term, printer := createTextProgress()
-	printer.Finish(State{11, 11, 2, 0, 47, 47, 59}, 5*time.Second)
+	printer.Finish(State{11, 11, 2, 0, 47, 47, 59, 0}, 5*time.Second)
 	test.Equals(t, []string{"Summary: Restored 11 files/dirs (47 B) in 0:05, skipped 2 files/dirs 59 B"}, term.Output)
 }
 
+func TestPrintSummaryOnSuccessWithReused(t *testing.T) {
+	term, printer := createTextProgress()
+	printer.Finish(State{11, 11, 0, 0, 47, 47, 0, 20}, 5*time.Second)
+	test.Equals(t, []string{"Summary: Restored 11 files/dirs (47 B) in 0:05, reused 20 B from existing files"}, term.Output)
+}
+
 func TestPrintCompleteItem(t *testing.T) {
 	for _, data := range []struct {
 		action   restorer.ItemAction
@@ -70,3 +76,9 @@ func TestError(t *testing.T) {
 	test.Equals(t, printer.Error("/path", errors.New("error \"message\"")), nil)
 	test.Equals(t, []string{"ignoring error for /path: error \"message\"\n"}, term.Errors)
 }
+
+func TestMetadataNotRestored(t *testing.T) {
+	term, printer := createTextProgress()
+	printer.MetadataNotRestored("/path", []string{"owner", "sacl"})
+	test.Equals(t, []string{"Warning: could not restore owner, sacl of /path due to missing privileges\n"}, term.Errors)
+}
@@ -3,6 +3,7 @@ package ui
 import (
 	"context"
 	"io"
+	"log/slog"
 )
 
 // Terminal is used to write messages and display status lines which can be
@@ -34,3 +35,10 @@ type Terminal interface {
 	// OutputIsTerminal returns true if the output is a terminal.
 	OutputIsTerminal() bool
 }
+
+// LevelTerminal is implemented by terminals which print messages as log
+// records. It allows printing messages with a log level other than the
+// default level used by Print.
+type LevelTerminal interface {
+	Log(level slog.Level, line string)
+}
@@ -0,0 +1,157 @@
+package termstatus
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/restic/restic/internal/ui"
+)
+
+var _ ui.Terminal = &logTerminal{}
+var _ ui.LevelTerminal = &logTerminal{}
+
+// LogOptions configures the structured log output of NewLogTerminal.
+type LogOptions struct {
+	// Format is either "text" or "json".
+	Format string
+	// Level is the minimum level of the messages to print.
+	Level slog.Level
+	// Attrs are added to every log record, for example the current command.
+	Attrs []slog.Attr
+}
+
+// ParseLogFormat checks that format is a supported log format.
+func ParseLogFormat(format string) error {
+	switch format {
+	case "text", "json":
+		return nil
+	default:
+		return fmt.Errorf("invalid log format %q, must be one of (text|json)", format)
+	}
+}
+
+// ParseLogLevel parses a log level, one of "error", "warn", "info" or "debug".
+func ParseLogLevel(level string) (slog.Level, error) {
+	switch strings.ToLower(level) {
+	case "error":
+		return slog.LevelError, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "debug":
+		return slog.LevelDebug, nil
+	default:
+		return 0, fmt.Errorf("invalid log level %q, must be one of (error|warn|info|debug)", level)
+	}
+}
+
+// NewLogHandler returns a slog.Handler which writes log records to wr.
+func NewLogHandler(wr io.Writer, opts LogOptions) slog.Handler {
+	handlerOpts := &slog.HandlerOptions{Level: opts.Level}
+	var handler slog.Handler
+	if opts.Format == "json" {
+		handler = slog.NewJSONHandler(wr, handlerOpts)
+	} else {
+		handler = slog.NewTextHandler(wr, handlerOpts)
+	}
+	return handler.WithAttrs(opts.Attrs)
+}
+
+// logTerminal writes all messages as structured log records. Messages are
+// written to the same output as by the wrapped terminal, that is errors and
+// warnings to stderr and all other messages to stdout. Status lines are not
+// printed.
+type logTerminal struct {
+	term   ui.Terminal
+	out    *slog.Logger
+	errOut *slog.Logger
+
+	outputWriter     io.WriteCloser
+	outputWriterOnce sync.Once
+}
+
+// NewLogTerminal wraps term such that all messages are printed as structured
+// log records.
+func NewLogTerminal(term ui.Terminal, opts LogOptions) ui.Terminal {
+	return &logTerminal{
+		term:   term,
+		out:    slog.New(NewLogHandler(newLineWriter(term.Print), opts)),
+		errOut: slog.New(NewLogHandler(newLineWriter(term.Error), opts)),
+	}
+}
+
+// Log prints a message with the given level.
+func (t *logTerminal) Log(level slog.Level, line string) {
+	logger := t.out
+	if level >= slog.LevelWarn {
+		logger = t.errOut
+	}
+	logger.Log(context.Background(), level, strings.TrimRight(line, "\n"))
+}
+
+// Print logs line as an informational message.
+func (t *logTerminal) Print(line string) {
+	t.Log(slog.LevelInfo, line)
+}
+
+// Error logs line as an error, or as a warning if it starts with "Warning".
+func (t *logTerminal) Error(line string) {
+	level := slog.LevelError
+	if strings.HasPrefix(strings.ToLower(line), "warning") {
+		level = slog.LevelWarn
+	}
+	t.Log(level, line)
+}
+
+// SetStatus discards the status lines, as they only show the progress.
+func (t *logTerminal) SetStatus(_ []string) {}
+
+func (t *logTerminal) CanUpdateStatus() bool {
+	return false
+}
+
+func (t *logTerminal) InputRaw() io.ReadCloser {
+	return t.term.InputRaw()
+}
+
+func (t *logTerminal) InputIsTerminal() bool {
+	return t.term.InputIsTerminal()
+}
+
+func (t *logTerminal) ReadPassword(ctx context.Context, prompt string) (string, error) {
+	return t.term.ReadPassword(ctx, prompt)
+}
+
+// OutputWriter returns a writer which logs every line as an informational
+// message.
+func (t *logTerminal) OutputWriter() io.Writer {
+	t.outputWriterOnce.Do(func() {
+		t.outputWriter = newLineWriter(func(s string) {
+			for _, line := range strings.Split(strings.TrimRight(s, "\n"), "\n") {
+				t.Print(line)
+			}
+		})
+	})
+	return t.outputWriter
+}
+
+func (t *logTerminal) OutputRaw() io.Writer {
+	return t.term.OutputRaw()
+}
+
+func (t *logTerminal) OutputIsTerminal() bool {
+	return false
+}
+
+// Close flushes the output writer.
+func (t *logTerminal) Close() error {
+	if t.outputWriter != nil {
+		return t.outputWriter.Close()
+	}
+	return nil
+}
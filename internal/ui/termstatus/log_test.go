@@ -0,0 +1,72 @@
+package termstatus
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+	"github.com/restic/restic/internal/ui"
+)
+
+type testLogRecord struct {
+	Level   string `json:"level"`
+	Msg     string `json:"msg"`
+	Command string `json:"command"`
+}
+
+func parseLogRecords(t *testing.T, lines []string) []testLogRecord {
+	var records []testLogRecord
+	for _, line := range lines {
+		var record testLogRecord
+		rtest.OK(t, json.Unmarshal([]byte(line), &record))
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestLogTerminal(t *testing.T) {
+	mock := &ui.MockTerminal{}
+	term := NewLogTerminal(mock, LogOptions{
+		Format: "json",
+		Level:  slog.LevelInfo,
+		Attrs:  []slog.Attr{slog.String("command", "backup")},
+	})
+
+	term.Print("first message\n")
+	term.Error("Warning: something happened")
+	term.Error("an error")
+	term.(ui.LevelTerminal).Log(slog.LevelDebug, "not printed")
+	term.SetStatus([]string{"status"})
+	_, err := fmt.Fprintf(term.OutputWriter(), "line 1\nline 2\n")
+	rtest.OK(t, err)
+
+	rtest.Equals(t, []testLogRecord{
+		{"INFO", "first message", "backup"},
+		{"INFO", "line 1", "backup"},
+		{"INFO", "line 2", "backup"},
+	}, parseLogRecords(t, mock.Output))
+	rtest.Equals(t, []testLogRecord{
+		{"WARN", "Warning: something happened", "backup"},
+		{"ERROR", "an error", "backup"},
+	}, parseLogRecords(t, mock.Errors))
+	rtest.Assert(t, !term.CanUpdateStatus(), "log terminal must not update the status")
+}
+
+func TestParseLogLevel(t *testing.T) {
+	for s, level := range map[string]slog.Level{
+		"error": slog.LevelError,
+		"warn":  slog.LevelWarn,
+		"INFO":  slog.LevelInfo,
+		"debug": slog.LevelDebug,
+	} {
+		parsed, err := ParseLogLevel(s)
+		rtest.OK(t, err)
+		rtest.Equals(t, level, parsed)
+	}
+
+	_, err := ParseLogLevel("trace")
+	rtest.Assert(t, err != nil, "expected error for invalid level")
+	rtest.Assert(t, ParseLogFormat("xml") != nil, "expected error for invalid format")
+}
@@ -33,6 +33,16 @@ type RewriteOpts struct {
 
 type idMap map[restic.ID]restic.ID
 
+// hashSaver computes the ID of blobs without saving them.
+type hashSaver struct{}
+
+func (hashSaver) SaveBlob(_ context.Context, _ restic.BlobType, buf []byte, id restic.ID, _ bool) (restic.ID, bool, int, error) {
+	if id.IsNull() {
+		id = restic.Hash(buf)
+	}
+	return id, false, len(buf), nil
+}
+
 type TreeRewriter struct {
 	opts RewriteOpts
 
@@ -118,7 +128,8 @@ func (t *TreeRewriter) RewriteTree(ctx context.Context, loader restic.BlobLoader
 		// check that we can properly encode this tree without losing information
 		// The alternative of using json/Decoder.DisallowUnknownFields() doesn't work as we use
 		// a custom UnmarshalJSON to decode trees, see also https://github.com/golang/go/issues/41144
-		testID, err := data.SaveTree(ctx, saver, curTree)
+		// The tree is only hashed as the saver may belong to a different repository.
+		testID, err := data.SaveTree(ctx, hashSaver{}, curTree)
 		if err != nil {
 			return restic.ID{}, err
 		}